@@ -368,6 +368,13 @@ build_snapshot() {
     popd >/dev/null
 }
 
+build_changefeed() {
+    pushd $SrcPath >/dev/null
+    echo -n "build cfs-changefeed	"
+    CGO_ENABLED=0 go build ${MODFLAGS} -gcflags=all=-trimpath=${SrcPath} -asmflags=all=-trimpath=${SrcPath} -ldflags="${LDFlags}" -o ${BuildBinPath}/cfs-changefeed ${SrcPath}/tool/changefeed/*.go  && echo "success" || echo "failed"
+    popd >/dev/null
+}
+
 build_libsdkpre() {
     case `uname` in
         Linux)
@@ -515,6 +522,9 @@ case "$cmd" in
     "snapshot")
         build_snapshot
         ;;
+    "changefeed")
+        build_changefeed
+        ;;
     "libsdkpre")
         build_libsdkpre
         ;;
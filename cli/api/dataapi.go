@@ -0,0 +1,90 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// DataHttpClient requests the http api of a datanode.
+type DataHttpClient struct {
+	client *MetaHttpClient
+}
+
+func NewDataHttpClient(host string, useSSL bool) *DataHttpClient {
+	return &DataHttpClient{client: NewMetaHttpClient(host, useSSL)}
+}
+
+func (dc *DataHttpClient) serveRequest(r *request) (respData []byte, err error) {
+	schema := "http"
+	if dc.client.useSSL {
+		schema = "https"
+	}
+	url := fmt.Sprintf("%s://%s%s", schema, dc.client.host, r.path)
+	resp, err := dc.client.httpRequest(r.method, url, r.params, r.header, r.body)
+	if err != nil {
+		log.LogErrorf("serveRequest: send http request fail: method(%v) url(%v) err(%v)", r.method, url, err)
+		return
+	}
+	respData, err = io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		log.LogErrorf("serveRequest: read http response body fail: err(%v)", err)
+		return
+	}
+	body := new(proto.HTTPReplyRaw)
+	if err = body.Unmarshal(respData); err != nil {
+		return nil, fmt.Errorf("status(%v) body(%s): %v", resp.StatusCode, respData, err)
+	}
+	if body.Code != http.StatusOK {
+		return nil, fmt.Errorf("code(%v) msg(%v)", body.Code, body.Msg)
+	}
+	return body.Bytes(), nil
+}
+
+func (dc *DataHttpClient) GetPartitionScrub(pid uint64) (info *proto.DataPartitionScrubInfo, err error) {
+	request := newAPIRequest(http.MethodGet, "/getPartitionScrub")
+	request.params["id"] = fmt.Sprintf("%v", pid)
+	respData, err := dc.serveRequest(request)
+	if err != nil {
+		return
+	}
+	info = &proto.DataPartitionScrubInfo{}
+	if err = json.Unmarshal(respData, info); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (dc *DataHttpClient) GetDiskScrub(disk string) (infos []proto.DiskScrubInfo, err error) {
+	request := newAPIRequest(http.MethodGet, "/getDiskScrub")
+	if disk != "" {
+		request.params["disk"] = disk
+	}
+	respData, err := dc.serveRequest(request)
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(respData, &infos); err != nil {
+		return nil, err
+	}
+	return
+}
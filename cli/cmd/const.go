@@ -58,6 +58,8 @@ const (
 
 	CliOpSetDecommissionLimit    = "set-decommission-limit"
 	CliOpQueryDecommissionStatus = "query-decommission-status"
+	CliOpScrub                   = "scrub"
+	CliOpScrubDisk               = "scrub-disk"
 
 	// Shorthand format of operation name
 	CliOpDecommissionShortHand = "dec"
@@ -91,6 +93,8 @@ const (
 	CliFlagVolType                      = "vol-type"
 	CliFlagFollowerRead                 = "follower-read"
 	CliFlagMetaFollowerRead             = "meta-follower-read"
+	CliFlagMetaFollowerReadIndex        = "meta-follower-read-index"
+	CliFlagMetaStoreMode                = "meta-store-mode"
 	CliFlagCacheRuleKey                 = "cache-rule-key"
 	CliFlagEbsBlkSize                   = "ebs-blk-size"
 	CliFlagCacheCapacity                = "cache-capacity"
@@ -132,6 +136,9 @@ const (
 	CliTxForceReset                     = "transaction-force-reset"
 	CliFlagMaxFiles                     = "maxFiles"
 	CliFlagMaxBytes                     = "maxBytes"
+	CliFlagSoftFiles                    = "softFiles"
+	CliFlagSoftBytes                    = "softBytes"
+	CliFlagGracePeriod                  = "grace"
 	CliFlagMaxConcurrencyInode          = "maxConcurrencyInode"
 	CliFlagForceInode                   = "forceInode"
 	CliFlagEnableQuota                  = "enableQuota"
@@ -150,6 +157,8 @@ const (
 	CliFlagDecommissionDpLimit          = "decommissionDpLimit"
 	CliFlagDecommissionDiskLimit        = "decommissionDiskLimit"
 	CliFlagTrashInterval                = "trashInterval"
+	CliFlagInlineDataThreshold          = "inlineDataThreshold"
+	CliFlagCompression                  = "compression"
 	CliFlagAccessTimeValidInterval      = "accessTimeValidInterval"
 	CliFlagEnablePersistAccessTime      = "enablePersistAccessTime"
 	CliFlagDecommissionRaftForce        = "raftForceDel"
@@ -159,6 +168,7 @@ const (
 	CliForbidWriteOpOfProtoVersion0     = "forbidWriteOpOfProtoVersion0"
 	CliFlagVolQuotaClass                = "quotaClass"
 	CliFlagVolQuotaOfClass              = "quotaOfStorageClass"
+	CliFlagProfPort                     = "prof-port"
 	// CliFlagSetDataPartitionCount	= "count" use dp-count instead
 
 	// Shorthand format of resource name
@@ -176,6 +186,9 @@ const (
 	CliFlagVersionSetStrategy = "verSetStrategy"
 )
 
+// compressionOff disables the compression of the volume in the cli.
+const compressionOff = "off"
+
 type MasterOp int
 
 const (
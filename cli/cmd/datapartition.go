@@ -18,7 +18,9 @@ import (
 	"fmt"
 	"sort"
 	"strconv"
+	"strings"
 
+	"github.com/cubefs/cubefs/cli/api"
 	"github.com/cubefs/cubefs/proto"
 	"github.com/cubefs/cubefs/sdk/master"
 	"github.com/spf13/cobra"
@@ -44,6 +46,8 @@ func newDataPartitionCmd(client *master.MasterClient) *cobra.Command {
 		newDataPartitionSetDiscardCmd(client),
 		newDataPartitionQueryDecommissionProgress(client),
 		newDataPartitionResetRestoreStatusCmd(client),
+		newDataPartitionScrubCmd(client),
+		newDataPartitionScrubDiskCmd(),
 	)
 	return cmd
 }
@@ -58,8 +62,12 @@ const (
 	cmdDataPartitionSetDiscardShort                = "Set discard flag for data partition"
 	cmdDataPartitionQueryDecommissionProgressShort = "Query data partition decommission progress"
 	cmdDataPartitionResetRestoreStatusShort        = "Reset data partition restore status"
+	cmdDataPartitionScrubShort                     = "Display the scrub progress and findings of a data partition"
+	cmdDataPartitionScrubDiskShort                 = "Display the scrub progress of the disks on a data node"
 )
 
+const defaultDataNodeProfPort = "17320"
+
 func newDataPartitionGetCmd(client *master.MasterClient) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   CliOpInfo + " [DATA PARTITION ID]",
@@ -518,3 +526,83 @@ func newDataPartitionResetRestoreStatusCmd(client *master.MasterClient) *cobra.C
 	}
 	return cmd
 }
+
+func newDataPartitionScrubCmd(client *master.MasterClient) *cobra.Command {
+	var profPort string
+	cmd := &cobra.Command{
+		Use:   CliOpScrub + " [DATA PARTITION ID]",
+		Short: cmdDataPartitionScrubShort,
+		Long: `The data partition is scrubbed by the data node holding its leader replica, which re-reads the extents
+of every replica from disk, compares the block crcs among the replicas and repairs the ones diverging from the majority.
+The scrub information of every replica is displayed, along with the findings of the replicas having scrubbed it.`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var (
+				err         error
+				partitionID uint64
+				partition   *proto.DataPartitionInfo
+			)
+			defer func() {
+				errout(err)
+			}()
+			if partitionID, err = strconv.ParseUint(args[0], 10, 64); err != nil {
+				return
+			}
+			if partition, err = client.AdminAPI().GetDataPartition("", partitionID); err != nil {
+				return
+			}
+			rows := table{formatDataPartitionScrubTableHeader()}
+			findings := make([]*proto.ScrubFinding, 0)
+			for _, replica := range partition.Replicas {
+				dc := api.NewDataHttpClient(replaceAddrPort(replica.Addr, profPort), false)
+				info, e := dc.GetPartitionScrub(partitionID)
+				if e != nil {
+					rows = rows.append(arow(replica.Addr, replica.DiskPath, e.Error(), "-", "-", "-", "-", "-", "-", "-"))
+					continue
+				}
+				rows = rows.append(formatDataPartitionScrubRow(replica.Addr, info))
+				findings = append(findings, info.Findings...)
+			}
+			stdoutln(alignTable(rows...))
+			if len(findings) > 0 {
+				stdoutln("[Findings]:")
+				stdoutln(formatScrubFindings(findings))
+			}
+		},
+	}
+	cmd.Flags().StringVar(&profPort, CliFlagProfPort, defaultDataNodeProfPort, "the http port of the data nodes")
+	return cmd
+}
+
+func newDataPartitionScrubDiskCmd() *cobra.Command {
+	var (
+		profPort string
+		diskPath string
+	)
+	cmd := &cobra.Command{
+		Use:   CliOpScrubDisk + " [DATA NODE ADDRESS]",
+		Short: cmdDataPartitionScrubDiskShort,
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var (
+				err   error
+				disks []proto.DiskScrubInfo
+			)
+			defer func() {
+				errout(err)
+			}()
+			dc := api.NewDataHttpClient(replaceAddrPort(args[0], profPort), false)
+			if disks, err = dc.GetDiskScrub(diskPath); err != nil {
+				return
+			}
+			stdoutln(formatDiskScrubList(disks))
+		},
+	}
+	cmd.Flags().StringVar(&profPort, CliFlagProfPort, defaultDataNodeProfPort, "the http port of the data node")
+	cmd.Flags().StringVar(&diskPath, CliFlagDiskPath, "", "only display the disk of the path")
+	return cmd
+}
+
+func replaceAddrPort(addr, port string) string {
+	return strings.Split(addr, ":")[0] + ":" + port
+}
@@ -234,6 +234,7 @@ func formatSimpleVolView(svv *proto.SimpleVolView) string {
 	sb.WriteString(fmt.Sprintf("  DpReplicaNum                    : %v\n", svv.DpReplicaNum))
 	sb.WriteString(fmt.Sprintf("  Follower read                   : %v\n", formatEnabledDisabled(svv.FollowerRead)))
 	sb.WriteString(fmt.Sprintf("  Meta Follower read              : %v\n", formatEnabledDisabled(svv.MetaFollowerRead)))
+	sb.WriteString(fmt.Sprintf("  Meta Follower read index        : %v\n", formatEnabledDisabled(svv.MetaFollowerReadIndex)))
 	sb.WriteString(fmt.Sprintf("  Direct Read                     : %v\n", formatEnabledDisabled(svv.DirectRead)))
 	sb.WriteString(fmt.Sprintf("  Inode count                     : %v\n", svv.InodeCount))
 	sb.WriteString(fmt.Sprintf("  Max metaPartition ID            : %v\n", svv.MaxMetaPartitionID))
@@ -253,13 +254,22 @@ func formatSimpleVolView(svv *proto.SimpleVolView) string {
 	sb.WriteString(fmt.Sprintf("  Forbidden                       : %v\n", svv.Forbidden))
 	sb.WriteString(fmt.Sprintf("  DisableAuditLog                 : %v\n", svv.DisableAuditLog))
 	sb.WriteString(fmt.Sprintf("  TrashInterval                   : %v\n", time.Duration(svv.TrashInterval)*time.Minute))
+	sb.WriteString(fmt.Sprintf("  InlineDataThreshold             : %v\n", svv.InlineDataThreshold))
+	sb.WriteString(fmt.Sprintf("  Compression                     : %v\n", formatCompression(svv.Compression)))
 	sb.WriteString(fmt.Sprintf("  DpRepairBlockSize               : %v\n", strutil.FormatSize(svv.DpRepairBlockSize)))
 	sb.WriteString(fmt.Sprintf("  EnableAutoDpMetaRepair          : %v\n", svv.EnableAutoDpMetaRepair))
 	sb.WriteString(fmt.Sprintf("  Quota                           : %v\n", formatEnabledDisabled(svv.EnableQuota)))
+	sb.WriteString(fmt.Sprintf("  MetaStoreMode                   : %v\n", svv.MetaStoreMode))
 	sb.WriteString(fmt.Sprintf("  AccessTimeValidInterval         : %v\n", time.Duration(svv.AccessTimeInterval)*time.Second))
 	sb.WriteString(fmt.Sprintf("  MetaLeaderRetryTimeout          : %v\n", time.Duration(svv.LeaderRetryTimeOut)*time.Second))
 	sb.WriteString(fmt.Sprintf("  EnablePersistAccessTime         : %v\n", svv.EnablePersistAccessTime))
 	sb.WriteString(fmt.Sprintf("  ForbidWriteOpOfProtoVer0        : %v\n", svv.ForbidWriteOpOfProtoVer0))
+	if svv.CloneSource != "" {
+		sb.WriteString(fmt.Sprintf("  CloneSource                     : %v (ver %v, %v)\n", svv.CloneSource, svv.CloneVer, proto.VolCloneStatusString(svv.CloneStatus)))
+	}
+	if len(svv.Clones) > 0 {
+		sb.WriteString(fmt.Sprintf("  Clones                          : %v\n", strings.Join(svv.Clones, ",")))
+	}
 	if svv.Forbidden && svv.Status == 1 {
 		sb.WriteString(fmt.Sprintf("  DeleteDelayTime                 : %v\n", time.Until(svv.DeleteExecTime)))
 	}
@@ -292,6 +302,23 @@ func formatSimpleVolView(svv *proto.SimpleVolView) string {
 	return sb.String()
 }
 
+func formatCompression(encoding string) string {
+	if encoding == "" {
+		return compressionOff
+	}
+	return encoding
+}
+
+func formatVolUsage(info *proto.VolStatInfo) string {
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("  Logical used                    : %v\n", formatSize(info.LogicalUsedSize)))
+	sb.WriteString(fmt.Sprintf("  Physical used                   : %v\n", formatSize(info.PhysicalUsedSize)))
+	if info.PhysicalUsedSize > 0 {
+		sb.WriteString(fmt.Sprintf("  Compression ratio               : %.2f\n", float64(info.LogicalUsedSize)/float64(info.PhysicalUsedSize)))
+	}
+	return sb.String()
+}
+
 func formatVolOp(opv *proto.OpLogView, logNum int, dpId string, filterOp string) string {
 	maxLines := 1000
 	if logNum > 0 && logNum < maxLines {
@@ -370,6 +397,41 @@ func formatUidInfoTableRow(uidInfo *proto.UidSpaceInfo) string {
 		uidInfo.Uid, time.Unix(uidInfo.CTime, 0).Format(time.RFC1123), uidInfo.Enabled, uidInfo.Limited, uidInfo.LimitSize, uidInfo.UsedSize)
 }
 
+var (
+	ownerQuotaPattern     = "%-10v    %-10v    %-10v    %-10v    %-10v    %-10v    %-10v    %-10v    %-10v"
+	ownerQuotaTableHeader = fmt.Sprintf(ownerQuotaPattern, "ID", "USED", "SOFT", "HARD", "GRACE", "FILES", "SOFT", "HARD", "GRACE")
+)
+
+func formatOwnerQuotaTableRow(info *proto.UidSpaceInfo) string {
+	limit := func(v uint64, format func(uint64) string) string {
+		if v == 0 || !info.Enabled {
+			return "-"
+		}
+		return format(v)
+	}
+	count := func(v uint64) string { return strconv.FormatUint(v, 10) }
+	return fmt.Sprintf(ownerQuotaPattern, info.Uid,
+		formatSize(info.UsedSize), limit(info.SoftSize, formatSize), limit(info.LimitSize, formatSize),
+		formatQuotaGrace(info.SizeGraceExpire, info.Limited),
+		info.UsedFiles, limit(info.SoftFiles, count), limit(info.LimitFiles, count),
+		formatQuotaGrace(info.FilesGraceExpire, info.LimitedFiles))
+}
+
+// formatQuotaGrace formats the time left before the soft limit is enforced.
+func formatQuotaGrace(expire int64, limited bool) string {
+	if limited {
+		return "limited"
+	}
+	if expire == 0 {
+		return "-"
+	}
+	left := time.Until(time.Unix(expire, 0))
+	if left <= 0 {
+		return "expired"
+	}
+	return left.Round(time.Minute).String()
+}
+
 func formatVerInfoTableRow(verInfo *proto.VolVersionInfo) string {
 	return fmt.Sprintf(volumeVersionPattern,
 		verInfo.Ver, time.UnixMicro(int64(verInfo.Ver)).Local().Format(time.RFC1123), verInfo.Status, "")
@@ -1279,3 +1341,55 @@ func formatHybridCloudStorageTableRow(view *proto.StatOfStorageClass) (row strin
 	row = fmt.Sprintf(hybridCloudStorageTablePattern, proto.StorageClassString(view.StorageClass), view.InodeCount, strutil.FormatSize(view.UsedSizeBytes), quotaLimitStr(view.QuotaGB))
 	return
 }
+
+var (
+	trashEntryTablePattern = "%-19v    %-10v    %-10v    %-12v    %v"
+	trashEntryTableHeader  = fmt.Sprintf(trashEntryTablePattern, "DELETE TIME", "INODE", "UID", "SIZE", "PATH")
+)
+
+func formatTrashEntryTableRow(e *proto.TrashEntry) string {
+	deleteTime := "-"
+	if e.DeleteTime > 0 {
+		deleteTime = formatTime(e.DeleteTime)
+	}
+	entryPath := e.Path
+	if proto.IsDir(e.Mode) {
+		entryPath += "/"
+	}
+	return fmt.Sprintf(trashEntryTablePattern, deleteTime, e.Inode, e.Uid, formatSize(e.Size), entryPath)
+}
+
+func formatScrubTime(timeUnix int64) string {
+	if timeUnix == 0 {
+		return "-"
+	}
+	return formatTime(timeUnix)
+}
+
+func formatDataPartitionScrubTableHeader() []interface{} {
+	return arow("ADDRESS", "DISK", "STATUS", "ROUNDS", "EXTENTS", "SCANNED", "MISMATCH", "REPAIRED", "LAST START", "LAST FINISH")
+}
+
+func formatDataPartitionScrubRow(addr string, info *proto.DataPartitionScrubInfo) []interface{} {
+	return arow(addr, info.Disk, info.Status, info.Rounds,
+		fmt.Sprintf("%v/%v", info.ScannedExtents, info.TotalExtents), formatSize(info.ScannedBytes),
+		info.MismatchBlocks, info.RepairedBlocks, formatScrubTime(info.LastStartTime), formatScrubTime(info.LastFinishTime))
+}
+
+func formatScrubFindings(findings []*proto.ScrubFinding) string {
+	rows := table{arow("TIME", "EXTENT", "OFFSET", "REPLICA", "EXPECTED CRC", "ACTUAL CRC", "REPAIRED", "ERROR")}
+	for _, f := range findings {
+		rows = rows.append(arow(formatTime(f.Time), f.ExtentId, f.Offset, f.Replica, f.ExpectedCrc, f.ActualCrc, f.Repaired, f.Err))
+	}
+	return alignTable(rows...)
+}
+
+func formatDiskScrubList(disks []proto.DiskScrubInfo) string {
+	rows := table{arow("DISK", "STATUS", "RATE", "ROUNDS", "PARTITIONS", "CURRENT", "SCANNED", "MISMATCH", "REPAIRED", "LAST START", "LAST FINISH")}
+	for _, d := range disks {
+		rows = rows.append(arow(d.Disk, d.Status, formatSize(uint64(d.BytesPerSec))+"/s", d.Rounds,
+			fmt.Sprintf("%v/%v", d.ScannedPartitions, d.TotalPartitions), d.CurrentPartition, formatSize(d.ScannedBytes),
+			d.MismatchBlocks, d.RepairedBlocks, formatScrubTime(d.LastStartTime), formatScrubTime(d.LastFinishTime)))
+	}
+	return alignTable(rows...)
+}
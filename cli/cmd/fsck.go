@@ -0,0 +1,179 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/master"
+	"github.com/spf13/cobra"
+)
+
+const (
+	cmdFsckUse         = "fsck [COMMAND]"
+	cmdFsckShort       = "Check and repair the volume online by lcnode"
+	cmdFsckStartUse    = "start [volname]"
+	cmdFsckStartShort  = "Start the fsck of the volume, only report the issues unless dry-run is disabled"
+	cmdFsckStopUse     = "stop [volname]"
+	cmdFsckStopShort   = "Stop the fsck of the volume"
+	cmdFsckStatusUse   = "status [volname]"
+	cmdFsckStatusShort = "Show the progress and report of the fsck tasks"
+)
+
+func newFsckCmd(client *master.MasterClient) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   cmdFsckUse,
+		Short: cmdFsckShort,
+		Args:  cobra.MinimumNArgs(0),
+	}
+	cmd.AddCommand(
+		newFsckStartCmd(client),
+		newFsckStopCmd(client),
+		newFsckStatusCmd(client),
+	)
+	return cmd
+}
+
+func newFsckStartCmd(client *master.MasterClient) *cobra.Command {
+	var (
+		optDryRun      bool
+		optCheckExtent bool
+		optSafeWindow  time.Duration
+	)
+	cmd := &cobra.Command{
+		Use:   cmdFsckStartUse,
+		Short: cmdFsckStartShort,
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			defer func() {
+				errout(err)
+			}()
+			var task *proto.FsckTask
+			if task, err = client.AdminAPI().StartFsck(args[0], optDryRun, optCheckExtent, int64(optSafeWindow/time.Second)); err != nil {
+				return
+			}
+			stdout("fsck task %v of volume %v started: dryRun %v checkExtent %v safeWindow %v\n",
+				task.Id, task.VolName, task.DryRun, task.CheckExtent, time.Duration(task.SafeWindow)*time.Second)
+		},
+	}
+	cmd.Flags().BoolVar(&optDryRun, "dry-run", true, "Only report the issues without repairing")
+	cmd.Flags().BoolVar(&optCheckExtent, "check-extent", true, "Check the extents of data partitions referenced by no inode")
+	cmd.Flags().DurationVar(&optSafeWindow, "safe-window", time.Duration(proto.DefaultFsckSafeWindow)*time.Second,
+		"The inodes and extents modified within it are never taken as orphans")
+	return cmd
+}
+
+func newFsckStopCmd(client *master.MasterClient) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   cmdFsckStopUse,
+		Short: cmdFsckStopShort,
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			defer func() {
+				errout(err)
+			}()
+			if err = client.AdminAPI().StopFsck(args[0]); err != nil {
+				return
+			}
+			stdout("fsck of volume %v is notified to stop\n", args[0])
+		},
+	}
+	return cmd
+}
+
+func newFsckStatusCmd(client *master.MasterClient) *cobra.Command {
+	var optShowIssues bool
+	cmd := &cobra.Command{
+		Use:   cmdFsckStatusUse,
+		Short: cmdFsckStatusShort,
+		Args:  cobra.MinimumNArgs(0),
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			defer func() {
+				errout(err)
+			}()
+			var volName string
+			if len(args) > 0 {
+				volName = args[0]
+			}
+			var results []*proto.FsckTaskResponse
+			if results, err = client.AdminAPI().GetFsckStatus(volName); err != nil {
+				return
+			}
+			for _, r := range results {
+				stdout("%v\n", formatFsckResult(r))
+				if !optShowIssues {
+					continue
+				}
+				for _, issue := range r.Issues {
+					stdout("  %v\n", formatFsckIssue(issue))
+				}
+			}
+		},
+	}
+	cmd.Flags().BoolVar(&optShowIssues, "issues", false, "Show the issues found")
+	return cmd
+}
+
+func formatFsckResult(r *proto.FsckTaskResponse) string {
+	state := "running"
+	if r.Done {
+		state = "succeeded"
+		if r.Status == proto.TaskFailed {
+			state = "failed"
+		}
+	} else if r.LcNode == "" {
+		state = "waiting"
+	}
+	var dryRun bool
+	if r.FsckTask != nil {
+		dryRun = r.FsckTask.DryRun
+	}
+	msg := fmt.Sprintf("task %v: %v lcnode(%v) dryRun(%v)\n", r.ID, state, r.LcNode, dryRun)
+	msg += fmt.Sprintf("  meta partitions %v/%v, data partitions %v/%v, current partition %v inode %v\n",
+		r.MetaPartitionChecked, r.MetaPartitionNum, r.DataPartitionChecked, r.DataPartitionNum, r.CurrentPartitionID, r.CurrentInode)
+	msg += fmt.Sprintf("  scanned inodes %v dentries %v extents %v\n", r.InodeScannedNum, r.DentryScannedNum, r.ExtentScannedNum)
+	msg += fmt.Sprintf("  dangling dentries %v, orphan inodes %v, orphan extents %v, repaired %v, errors %v",
+		r.DanglingDentryNum, r.OrphanInodeNum, r.OrphanExtentNum, r.RepairedNum, r.ErrorSkippedNum)
+	if r.OrphanCheckSkipped {
+		msg += ", orphans not checked"
+	}
+	if r.Result != "" {
+		msg += fmt.Sprintf("\n  result: %v", r.Result)
+	}
+	return msg
+}
+
+func formatFsckIssue(issue *proto.FsckIssue) string {
+	var msg string
+	switch issue.Type {
+	case proto.FsckIssueDanglingDentry:
+		msg = fmt.Sprintf("%v: parent(%v) name(%v) inode(%v)", issue.Type, issue.ParentID, issue.Name, issue.Inode)
+	case proto.FsckIssueOrphanInode:
+		msg = fmt.Sprintf("%v: inode(%v) size(%v)", issue.Type, issue.Inode, issue.Size)
+	default:
+		msg = fmt.Sprintf("%v: partition(%v) extent(%v) size(%v)", issue.Type, issue.PartitionID, issue.ExtentID, issue.Size)
+	}
+	if issue.Repaired {
+		msg += " repaired"
+	} else if issue.Err != "" {
+		msg += fmt.Sprintf(" repair failed: %v", issue.Err)
+	}
+	return msg
+}
@@ -70,6 +70,8 @@ func newQuotaCmd(client *master.MasterClient) *cobra.Command {
 		newQuotaListAllCmd(client),
 		newQuotaApplyCmd(client),
 		newQuotaRevokeCmd(client),
+		newQuotaOwnerCmd(client, proto.QuotaOwnerUser),
+		newQuotaOwnerCmd(client, proto.QuotaOwnerGroup),
 	)
 	return cmd
 }
@@ -0,0 +1,194 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/master"
+	"github.com/cubefs/cubefs/util"
+	"github.com/spf13/cobra"
+)
+
+const (
+	cmdQuotaOwnerSetUse     = "set [volname] [id]"
+	cmdQuotaOwnerDeleteUse  = "delete [volname] [id]"
+	cmdQuotaOwnerGetUse     = "get [volname] [id]"
+	cmdQuotaOwnerReportUse  = "report [volname]"
+	cmdQuotaOwnerSetShort   = "set the hard and soft limits of the %v"
+	cmdQuotaOwnerDelShort   = "delete the limits of the %v"
+	cmdQuotaOwnerGetShort   = "show the usage and limits of the %v"
+	cmdQuotaOwnerRepShort   = "report the usage and limits of all the %vs of the volume"
+	cmdQuotaOwnerShort      = "Manage the %v quotas of the volume"
+	cmdQuotaOwnerUserUse    = "user [COMMAND]"
+	cmdQuotaOwnerGroupUse   = "group [COMMAND]"
+	cmdQuotaOwnerUnlimitVal = 0
+)
+
+// newQuotaOwnerCmd returns the commands of the user or group quotas, which limit the bytes and
+// files owned by the uid or gid in the volume.
+func newQuotaOwnerCmd(client *master.MasterClient, typ proto.QuotaOwnerType) *cobra.Command {
+	use := cmdQuotaOwnerUserUse
+	if typ == proto.QuotaOwnerGroup {
+		use = cmdQuotaOwnerGroupUse
+	}
+	cmd := &cobra.Command{
+		Use:   use,
+		Short: fmt.Sprintf(cmdQuotaOwnerShort, typ),
+		Args:  cobra.MinimumNArgs(0),
+	}
+	cmd.AddCommand(
+		newQuotaOwnerSetCmd(client, typ),
+		newQuotaOwnerDeleteCmd(client, typ),
+		newQuotaOwnerGetCmd(client, typ),
+		newQuotaOwnerReportCmd(client, typ),
+	)
+	return cmd
+}
+
+func newQuotaOwnerSetCmd(client *master.MasterClient, typ proto.QuotaOwnerType) *cobra.Command {
+	var (
+		maxBytes  uint64
+		softBytes uint64
+		maxFiles  uint64
+		softFiles uint64
+		grace     time.Duration
+	)
+	cmd := &cobra.Command{
+		Use:   cmdQuotaOwnerSetUse,
+		Short: fmt.Sprintf(cmdQuotaOwnerSetShort, typ),
+		Args:  cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			defer func() {
+				errout(err)
+			}()
+			volName := args[0]
+			var id uint64
+			if id, err = strconv.ParseUint(args[1], 10, 32); err != nil {
+				return
+			}
+			limit := &proto.UidSpaceInfo{
+				Type:        typ,
+				Uid:         uint32(id),
+				LimitSize:   maxBytes,
+				SoftSize:    softBytes,
+				LimitFiles:  maxFiles,
+				SoftFiles:   softFiles,
+				GracePeriod: int64(grace / time.Second),
+			}
+			var rsp *proto.UidSpaceRsp
+			if rsp, err = client.UserAPI().SetOwnerQuota(volName, limit); err != nil {
+				return
+			}
+			if !rsp.OK {
+				err = fmt.Errorf("set %v %v quota of volume %v is not accepted, try again later", typ, id, volName)
+				return
+			}
+			stdout("set %v %v quota of volume %v: maxBytes %v softBytes %v maxFiles %v softFiles %v grace %v success.\n",
+				typ, id, volName, maxBytes, softBytes, maxFiles, softFiles, grace)
+		},
+	}
+	cmd.Flags().Uint64Var(&maxBytes, CliFlagMaxBytes, cmdQuotaOwnerUnlimitVal, "Specify the hard limit of bytes, 0 means no limit")
+	cmd.Flags().Uint64Var(&softBytes, CliFlagSoftBytes, cmdQuotaOwnerUnlimitVal, "Specify the soft limit of bytes, 0 means no limit")
+	cmd.Flags().Uint64Var(&maxFiles, CliFlagMaxFiles, cmdQuotaOwnerUnlimitVal, "Specify the hard limit of files, 0 means no limit")
+	cmd.Flags().Uint64Var(&softFiles, CliFlagSoftFiles, cmdQuotaOwnerUnlimitVal, "Specify the soft limit of files, 0 means no limit")
+	cmd.Flags().DurationVar(&grace, CliFlagGracePeriod, 0,
+		fmt.Sprintf("Specify how long the soft limits may be exceeded, 0 means %v", time.Duration(proto.DefaultQuotaGracePeriod)*time.Second))
+	return cmd
+}
+
+func newQuotaOwnerDeleteCmd(client *master.MasterClient, typ proto.QuotaOwnerType) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   cmdQuotaOwnerDeleteUse,
+		Short: fmt.Sprintf(cmdQuotaOwnerDelShort, typ),
+		Args:  cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			defer func() {
+				errout(err)
+			}()
+			var rsp *proto.UidSpaceRsp
+			if rsp, err = client.UserAPI().OwnerQuotaOperation(args[0], typ, args[1], util.UidDelLimit); err != nil {
+				return
+			}
+			if !rsp.OK {
+				err = fmt.Errorf("delete %v %v quota of volume %v is not accepted, try again later", typ, args[1], args[0])
+				return
+			}
+			stdout("delete %v %v quota of volume %v success.\n", typ, args[1], args[0])
+		},
+	}
+	return cmd
+}
+
+func newQuotaOwnerGetCmd(client *master.MasterClient, typ proto.QuotaOwnerType) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   cmdQuotaOwnerGetUse,
+		Short: fmt.Sprintf(cmdQuotaOwnerGetShort, typ),
+		Args:  cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			defer func() {
+				errout(err)
+			}()
+			var rsp *proto.UidSpaceRsp
+			if rsp, err = client.UserAPI().OwnerQuotaOperation(args[0], typ, args[1], util.UidGetLimit); err != nil {
+				return
+			}
+			if !rsp.OK || len(rsp.UidSpaceArr) == 0 || rsp.UidSpaceArr[0] == nil {
+				err = fmt.Errorf("%v %v has neither quota nor usage in volume %v", typ, args[1], args[0])
+				return
+			}
+			stdout("%v\n", ownerQuotaTableHeader)
+			stdout("%v\n", formatOwnerQuotaTableRow(rsp.UidSpaceArr[0]))
+		},
+	}
+	return cmd
+}
+
+func newQuotaOwnerReportCmd(client *master.MasterClient, typ proto.QuotaOwnerType) *cobra.Command {
+	var optLimitedOnly bool
+	cmd := &cobra.Command{
+		Use:   cmdQuotaOwnerReportUse,
+		Short: fmt.Sprintf(cmdQuotaOwnerRepShort, typ),
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			defer func() {
+				errout(err)
+			}()
+			var rsp *proto.UidSpaceRsp
+			if rsp, err = client.UserAPI().OwnerQuotaOperation(args[0], typ, "", util.UidLimitList); err != nil {
+				return
+			}
+			infos := rsp.UidSpaceArr
+			sort.Slice(infos, func(i, j int) bool { return infos[i].Uid < infos[j].Uid })
+			stdout("%v\n", ownerQuotaTableHeader)
+			for _, info := range infos {
+				if optLimitedOnly && !info.Enabled {
+					continue
+				}
+				stdout("%v\n", formatOwnerQuotaTableRow(info))
+			}
+		},
+	}
+	cmd.Flags().BoolVar(&optLimitedOnly, "limited-only", false, "Only report the ones with limits")
+	return cmd
+}
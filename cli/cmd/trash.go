@@ -0,0 +1,259 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/master"
+	"github.com/spf13/cobra"
+)
+
+const (
+	cmdTrashUse          = "trash [COMMAND]"
+	cmdTrashShort        = "Manage the entries deleted into the trash of the volume"
+	cmdTrashListUse      = "list [VOLUME NAME]"
+	cmdTrashListShort    = "List the entries in the trash"
+	cmdTrashRestoreUse   = "restore [VOLUME NAME]"
+	cmdTrashRestoreShort = "Restore the entries in the trash to their original paths"
+	cmdTrashPurgeUse     = "purge [VOLUME NAME]"
+	cmdTrashPurgeShort   = "Delete the entries in the trash permanently"
+
+	trashTaskPollInterval = time.Second
+)
+
+type trashFilterOptions struct {
+	subdir string
+	path   string
+	uid    int64
+	since  string
+	until  string
+}
+
+func (o *trashFilterOptions) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.subdir, "subdir", "/", "The subdir mounted by the clients, whose .Trash directory is the trash")
+	cmd.Flags().StringVar(&o.path, "path", "", "The entries deleted from the path, or from under the directory")
+	cmd.Flags().Int64Var(&o.uid, "uid", -1, "The entries owned by the user")
+	cmd.Flags().StringVar(&o.since, "since", "", "The entries deleted since the time, as \"2006-01-02 15:04:05\" or the duration ago like 24h")
+	cmd.Flags().StringVar(&o.until, "until", "", "The entries deleted before the time, as \"2006-01-02 15:04:05\" or the duration ago like 24h")
+}
+
+func (o *trashFilterOptions) filter() (filter *proto.TrashFilter, err error) {
+	filter = &proto.TrashFilter{Path: o.path, Uid: o.uid}
+	if filter.From, err = parseTrashTime(o.since); err != nil {
+		return
+	}
+	filter.To, err = parseTrashTime(o.until)
+	return
+}
+
+func (o *trashFilterOptions) selectsAll() bool {
+	return (o.path == "" || o.path == "/") && o.uid < 0 && o.since == "" && o.until == ""
+}
+
+func parseTrashTime(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d).Unix(), nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t.Unix(), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid time %v", value)
+}
+
+// runTrashTask starts the trash task of the volume through the master, which is run by an
+// lcnode, and waits for it done. The result is returned with the error if the task fails, since
+// the entries may be partly restored or purged.
+func runTrashTask(client *master.MasterClient, volName, op, subdir string, filter *proto.TrashFilter, conflict string) (result *proto.TrashTaskResponse, err error) {
+	var task *proto.TrashTask
+	if task, err = client.AdminAPI().StartTrashTask(volName, op, subdir, filter, conflict); err != nil {
+		return
+	}
+	for {
+		time.Sleep(trashTaskPollInterval)
+		var results []*proto.TrashTaskResponse
+		if results, err = client.AdminAPI().GetTrashTaskStatus(volName); err != nil {
+			return
+		}
+		result = nil
+		for _, r := range results {
+			if r.ID == task.Id {
+				result = r
+				break
+			}
+		}
+		if result == nil {
+			return nil, fmt.Errorf("trash task %v not found", task.Id)
+		}
+		if !result.Done {
+			continue
+		}
+		if result.Status == proto.TaskFailed {
+			err = fmt.Errorf("trash task %v failed in lcnode(%v): %v", task.Id, result.LcNode, result.Result)
+		}
+		return
+	}
+}
+
+func stdoutTrashTruncated(result *proto.TrashTaskResponse, shown int) {
+	if result.Truncated {
+		stdout("only %v of %v entries are shown\n", shown, result.EntryNum+result.FailedNum)
+	}
+}
+
+func newTrashCmd(client *master.MasterClient) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   cmdTrashUse,
+		Short: cmdTrashShort,
+	}
+	cmd.AddCommand(
+		newTrashListCmd(client),
+		newTrashRestoreCmd(client),
+		newTrashPurgeCmd(client),
+	)
+	return cmd
+}
+
+func newTrashListCmd(client *master.MasterClient) *cobra.Command {
+	var opts trashFilterOptions
+	cmd := &cobra.Command{
+		Use:     cmdTrashListUse,
+		Short:   cmdTrashListShort,
+		Aliases: []string{"ls"},
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			defer func() {
+				errout(err)
+			}()
+			var filter *proto.TrashFilter
+			if filter, err = opts.filter(); err != nil {
+				return
+			}
+			var result *proto.TrashTaskResponse
+			if result, err = runTrashTask(client, args[0], proto.TrashOpList, opts.subdir, filter, ""); err != nil {
+				return
+			}
+			stdoutln(trashEntryTableHeader)
+			for _, e := range result.Entries {
+				stdoutln(formatTrashEntryTableRow(e))
+			}
+			stdoutTrashTruncated(result, len(result.Entries))
+		},
+	}
+	opts.addFlags(cmd)
+	return cmd
+}
+
+func newTrashRestoreCmd(client *master.MasterClient) *cobra.Command {
+	var (
+		opts        trashFilterOptions
+		optConflict string
+		optYes      bool
+	)
+	cmd := &cobra.Command{
+		Use:   cmdTrashRestoreUse,
+		Short: cmdTrashRestoreShort,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			defer func() {
+				errout(err)
+			}()
+			switch optConflict {
+			case proto.TrashConflictSkip, proto.TrashConflictRename, proto.TrashConflictOverwrite:
+			default:
+				err = fmt.Errorf("invalid conflict way %v", optConflict)
+				return
+			}
+			var filter *proto.TrashFilter
+			if filter, err = opts.filter(); err != nil {
+				return
+			}
+			if !confirmTrashAll(&opts, optYes, "restored") {
+				return
+			}
+			var result *proto.TrashTaskResponse
+			if result, err = runTrashTask(client, args[0], proto.TrashOpRestore, opts.subdir, filter, optConflict); result == nil {
+				return
+			}
+			for _, r := range result.Restored {
+				if r.Err != "" {
+					stdout("%v -> %v: %v\n", r.Entry.TrashPath, r.Entry.Path, r.Err)
+				} else {
+					stdout("%v -> %v\n", r.Entry.TrashPath, r.Restored)
+				}
+			}
+			stdoutTrashTruncated(result, len(result.Restored))
+			stdout("%v entries restored, %v failed\n", result.EntryNum, result.FailedNum)
+		},
+	}
+	opts.addFlags(cmd)
+	cmd.Flags().StringVar(&optConflict, "conflict", proto.TrashConflictSkip,
+		"What to do if the original path is taken [skip | rename | overwrite], rename restores as <path>.restored.<inode>")
+	cmd.Flags().BoolVarP(&optYes, "yes", "y", false, "Answer yes for all questions")
+	return cmd
+}
+
+func newTrashPurgeCmd(client *master.MasterClient) *cobra.Command {
+	var (
+		opts   trashFilterOptions
+		optYes bool
+	)
+	cmd := &cobra.Command{
+		Use:   cmdTrashPurgeUse,
+		Short: cmdTrashPurgeShort,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			defer func() {
+				errout(err)
+			}()
+			var filter *proto.TrashFilter
+			if filter, err = opts.filter(); err != nil {
+				return
+			}
+			if !confirmTrashAll(&opts, optYes, "deleted permanently") {
+				return
+			}
+			var result *proto.TrashTaskResponse
+			if result, err = runTrashTask(client, args[0], proto.TrashOpPurge, opts.subdir, filter, ""); result == nil {
+				return
+			}
+			stdout("%v entries deleted\n", result.EntryNum)
+		},
+	}
+	opts.addFlags(cmd)
+	cmd.Flags().BoolVarP(&optYes, "yes", "y", false, "Answer yes for all questions")
+	return cmd
+}
+
+func confirmTrashAll(opts *trashFilterOptions, yes bool, done string) bool {
+	if yes || !opts.selectsAll() {
+		return true
+	}
+	stdout("All the entries in the trash of %v will be %v.\n", opts.subdir, done)
+	stdout("\nConfirm (yes/no)[no]: ")
+	var userConfirm string
+	_, _ = fmt.Scanln(&userConfirm)
+	return userConfirm == "yes"
+}
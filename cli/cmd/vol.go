@@ -28,6 +28,7 @@ import (
 	"github.com/cubefs/cubefs/sdk/master"
 	"github.com/cubefs/cubefs/sdk/meta"
 	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/compressor"
 	"github.com/cubefs/cubefs/util/strutil"
 	"github.com/spf13/cobra"
 )
@@ -62,6 +63,8 @@ func newVolCmd(client *master.MasterClient) *cobra.Command {
 		newVolAddAllowedStorageClassCmd(client),
 		newVolQueryOpCmd(client),
 		newVolGetInodeByIdCmd(client),
+		newVolCloneCmd(client),
+		newVolDirStatCmd(client),
 	)
 	return cmd
 }
@@ -149,6 +152,7 @@ func newVolCreateCmd(client *master.MasterClient) *cobra.Command {
 	var clientIDKey string
 	var optVolStorageClass uint32
 	var optAllowedStorageClass string
+	var optMetaStoreMode string
 	var optYes bool
 
 	cmd := &cobra.Command{
@@ -231,6 +235,7 @@ func newVolCreateCmd(client *master.MasterClient) *cobra.Command {
 				stdout("  allowedStorageClass      : %v\n", optAllowedStorageClass)
 				stdout("  enableQuota              : %v\n", optEnableQuota)
 				stdout("  metaFollowerRead         : %v\n", optMetaFollowerRead)
+				stdout("  metaStoreMode            : %v\n", optMetaStoreMode)
 				stdout("\nConfirm (yes/no)[yes]: ")
 				var userConfirm string
 				_, _ = fmt.Scanln(&userConfirm)
@@ -247,7 +252,7 @@ func newVolCreateCmd(client *master.MasterClient) *cobra.Command {
 				optCacheAction, optCacheThreshold, optCacheTTL, optCacheHighWater,
 				optCacheLowWater, optCacheLRUInterval, dpReadOnlyWhenVolFull,
 				optTxMask, optTxTimeout, optTxConflictRetryNum, optTxConflictRetryInterval, optEnableQuota, clientIDKey,
-				optVolStorageClass, optAllowedStorageClass, optMetaFollowerRead)
+				optVolStorageClass, optAllowedStorageClass, optMetaFollowerRead, optMetaStoreMode)
 			if err != nil {
 				err = fmt.Errorf("Create volume failed case:\n%v\n", err)
 				return
@@ -290,6 +295,8 @@ func newVolCreateCmd(client *master.MasterClient) *cobra.Command {
 	cmd.Flags().StringVar(&optAllowedStorageClass, CliFlagAllowedStorageClass, cmdVolDefaultAllowedStorageClass,
 		"Specify which StorageClasses the vol will support, \nformat is comma separated uint32:\"StorageClass1, StorageClass2\",\n"+
 			"1:SSD, 2:HDD, empty value means determine by master")
+	cmd.Flags().StringVar(&optMetaStoreMode, CliFlagMetaStoreMode, "mem",
+		"Specify where the meta partitions keep the metadata: [mem | rocksdb]")
 
 	return cmd
 }
@@ -306,6 +313,7 @@ func newVolUpdateCmd(client *master.MasterClient) *cobra.Command {
 	var optCapacity uint64
 	var optFollowerRead string
 	var optMetaFollowerRead string
+	var optMetaFollowerReadIndex string
 	var optDirectRead string
 	var optEbsBlkSize int
 	var optCacheCap string
@@ -331,6 +339,8 @@ func newVolUpdateCmd(client *master.MasterClient) *cobra.Command {
 	var optEnableQuota string
 	var optEnableDpAutoMetaRepair string
 	var optTrashInterval int64
+	var optInlineDataThreshold int64
+	var optCompression string
 	var optAccessTimeValidInterval int64
 	var optEnablePersistAccessTime string
 	var optVolStorageClass int
@@ -414,6 +424,16 @@ func newVolUpdateCmd(client *master.MasterClient) *cobra.Command {
 				vv.MetaFollowerRead = enable
 			}
 
+			if optMetaFollowerReadIndex != "" {
+				isChange = true
+				var enable bool
+				if enable, err = strconv.ParseBool(optMetaFollowerReadIndex); err != nil {
+					return
+				}
+				confirmString.WriteString(fmt.Sprintf("  Allow meta follower read index : %v -> %v\n", formatEnabledDisabled(vv.MetaFollowerReadIndex), formatEnabledDisabled(enable)))
+				vv.MetaFollowerReadIndex = enable
+			}
+
 			if optDirectRead != "" {
 				isChange = true
 				var enable bool
@@ -681,6 +701,38 @@ func newVolUpdateCmd(client *master.MasterClient) *cobra.Command {
 			} else {
 				confirmString.WriteString(fmt.Sprintf("  TrashInterval            : %v min\n", vv.TrashInterval))
 			}
+			if optInlineDataThreshold >= 0 {
+				if optInlineDataThreshold > proto.MaxInlineDataSize {
+					err = fmt.Errorf("InlineDataThreshold must be less than or equal to %v\n", proto.MaxInlineDataSize)
+					return
+				}
+				if uint32(optInlineDataThreshold) != vv.InlineDataThreshold {
+					isChange = true
+					confirmString.WriteString(fmt.Sprintf("  InlineDataThreshold      : %v -> %v\n", vv.InlineDataThreshold, optInlineDataThreshold))
+					vv.InlineDataThreshold = uint32(optInlineDataThreshold)
+				} else {
+					confirmString.WriteString(fmt.Sprintf("  InlineDataThreshold      : %v\n", vv.InlineDataThreshold))
+				}
+			} else {
+				confirmString.WriteString(fmt.Sprintf("  InlineDataThreshold      : %v\n", vv.InlineDataThreshold))
+			}
+			if optCompression != "" {
+				if optCompression == compressionOff {
+					optCompression = ""
+				} else if !compressor.IsValidEncoding(optCompression) {
+					err = fmt.Errorf("Compression must be one of gzip, zstd, lz4, snappy or %v\n", compressionOff)
+					return
+				}
+				if optCompression != vv.Compression {
+					isChange = true
+					confirmString.WriteString(fmt.Sprintf("  Compression              : %v -> %v\n", formatCompression(vv.Compression), formatCompression(optCompression)))
+					vv.Compression = optCompression
+				} else {
+					confirmString.WriteString(fmt.Sprintf("  Compression              : %v\n", formatCompression(vv.Compression)))
+				}
+			} else {
+				confirmString.WriteString(fmt.Sprintf("  Compression              : %v\n", formatCompression(vv.Compression)))
+			}
 			if optAccessTimeValidInterval >= 0 {
 				if optAccessTimeValidInterval < proto.MinAccessTimeValidInterval {
 					err = fmt.Errorf("AccessTimeValidInterval must greater than or equal to %v\n", proto.MinAccessTimeValidInterval)
@@ -830,6 +882,7 @@ func newVolUpdateCmd(client *master.MasterClient) *cobra.Command {
 	cmd.Flags().Uint64Var(&optCapacity, CliFlagCapacity, 0, "Specify volume datanode capacity [Unit: GB]")
 	cmd.Flags().StringVar(&optFollowerRead, CliFlagEnableFollowerRead, "", "Enable read form replica follower (default false)")
 	cmd.Flags().StringVar(&optMetaFollowerRead, CliFlagMetaFollowerRead, "", "Enable read form mp follower (true|false, default false)")
+	cmd.Flags().StringVar(&optMetaFollowerReadIndex, CliFlagMetaFollowerReadIndex, "", "Enable linearizable read from mp follower by the read index of the leader (true|false, default false)")
 	cmd.Flags().StringVar(&optDirectRead, "directRead", "", "Enable read direct from disk (true|false, default false)")
 	cmd.Flags().IntVar(&optEbsBlkSize, CliFlagEbsBlkSize, 0, "Specify ebsBlk Size[Unit: byte]")
 	cmd.Flags().StringVar(&optCacheCap, CliFlagCacheCapacity, "", "Specify low volume capacity[Unit: GB]")
@@ -859,6 +912,10 @@ func newVolUpdateCmd(client *master.MasterClient) *cobra.Command {
 	cmd.Flags().IntVar(&optVolQuotaOfClass, CliFlagVolQuotaOfClass, -1, "specify quota of target storage class, GB")
 
 	cmd.Flags().Int64Var(&optTrashInterval, CliFlagTrashInterval, -1, "The retention period for files in trash")
+	cmd.Flags().Int64Var(&optInlineDataThreshold, CliFlagInlineDataThreshold, -1,
+		fmt.Sprintf("Store the files not larger than it in the inode, at most %v, 0 to disable [Unit: byte]", proto.MaxInlineDataSize))
+	cmd.Flags().StringVar(&optCompression, CliFlagCompression, "",
+		fmt.Sprintf("Compress the data written afterwards with gzip/zstd/lz4/snappy, %v to disable", compressionOff))
 	cmd.Flags().Int64Var(&optAccessTimeValidInterval, CliFlagAccessTimeValidInterval, -1, fmt.Sprintf("Effective time interval for accesstime, at least %v [Unit: second]", proto.MinAccessTimeValidInterval))
 	cmd.Flags().StringVar(&optEnablePersistAccessTime, CliFlagEnablePersistAccessTime, "", "true/false to enable/disable persisting access time")
 	cmd.Flags().StringVar(&optForbidWriteOpOfProtoVer0, CliForbidWriteOpOfProtoVersion0, "",
@@ -897,6 +954,15 @@ func newVolInfoCmd(client *master.MasterClient) *cobra.Command {
 			// print summary info
 			stdout("Summary:\n%s\n", formatSimpleVolView(svv))
 
+			if svv.VolType == proto.VolumeTypeHot {
+				var info *proto.VolStatInfo
+				if info, err = client.ClientAPI().GetVolumeStat(volumeName); err != nil {
+					err = fmt.Errorf("Get volume usage failed:\n%v\n", err)
+					return
+				}
+				stdout("Usage:\n%s\n", formatVolUsage(info))
+			}
+
 			if opHybridCloudDetail {
 				var info *proto.VolStatInfo
 				if info, err = client.ClientAPI().GetVolumeStat(volumeName); err != nil {
@@ -0,0 +1,141 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/master"
+	"github.com/cubefs/cubefs/util/strutil"
+	"github.com/spf13/cobra"
+)
+
+const (
+	cmdVolCloneUse         = "clone [VOLUME NAME] [CLONE NAME]"
+	cmdVolCloneShort       = "Create a writable clone of the volume from a snapshot"
+	cmdVolCloneStatusUse   = "status [VOLUME NAME]"
+	cmdVolCloneStatusShort = "Show the progress of the clones of the volume, or the clone itself"
+	cmdVolCloneDetachUse   = "detach [CLONE NAME]"
+	cmdVolCloneDetachShort = "Rewrite the data shared with the source into the clone, to make it independent of the source"
+	cmdVolCloneVerUsage    = "The snapshot to be cloned, by the name listed in the snapshot directory or the version"
+	cmdVolCloneOwnerUsage  = "The owner of the clone, the owner of the volume by default"
+)
+
+func newVolCloneCmd(client *master.MasterClient) *cobra.Command {
+	var (
+		optVer   string
+		optOwner string
+	)
+	cmd := &cobra.Command{
+		Use:   cmdVolCloneUse,
+		Short: cmdVolCloneShort,
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			defer func() {
+				errout(err)
+			}()
+			if optVer == "" {
+				err = fmt.Errorf("the snapshot to be cloned is not specified by --ver")
+				return
+			}
+			var task *proto.VolCloneTask
+			if task, err = client.AdminAPI().CloneVolume(args[0], args[1], optVer, optOwner); err != nil {
+				return
+			}
+			stdout("clone %v of volume %v at version %v is created, the metadata is being copied by task %v\n",
+				task.CloneVol, task.SourceVol, task.Ver, task.Id)
+		},
+	}
+	cmd.Flags().StringVar(&optVer, "ver", "", cmdVolCloneVerUsage)
+	cmd.Flags().StringVar(&optOwner, "owner", "", cmdVolCloneOwnerUsage)
+	cmd.AddCommand(newVolCloneStatusCmd(client))
+	cmd.AddCommand(newVolCloneDetachCmd(client))
+	return cmd
+}
+
+func newVolCloneDetachCmd(client *master.MasterClient) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   cmdVolCloneDetachUse,
+		Short: cmdVolCloneDetachShort,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			defer func() {
+				errout(err)
+			}()
+			var task *proto.VolCloneTask
+			if task, err = client.AdminAPI().DetachVolClone(args[0]); err != nil {
+				return
+			}
+			stdout("clone %v is being detached from volume %v by task %v\n", task.CloneVol, task.SourceVol, task.Id)
+		},
+	}
+	return cmd
+}
+
+func newVolCloneStatusCmd(client *master.MasterClient) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   cmdVolCloneStatusUse,
+		Short: cmdVolCloneStatusShort,
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			defer func() {
+				errout(err)
+			}()
+			var volName string
+			if len(args) > 0 {
+				volName = args[0]
+			}
+			var results []*proto.VolCloneTaskResponse
+			if results, err = client.AdminAPI().GetVolCloneStatus(volName); err != nil {
+				return
+			}
+			for _, r := range results {
+				stdout("%v\n", formatVolCloneResult(r))
+			}
+		},
+	}
+	return cmd
+}
+
+func formatVolCloneResult(r *proto.VolCloneTaskResponse) string {
+	state := "running"
+	if r.Done {
+		state = "succeeded"
+		if r.Status == proto.TaskFailed {
+			state = "failed"
+		}
+	} else if r.LcNode == "" {
+		state = "waiting"
+	}
+	msg := fmt.Sprintf("task %v: %v lcnode(%v)", r.ID, state, r.LcNode)
+	if r.Task != nil {
+		msg += fmt.Sprintf(" source(%v) ver(%v) clone(%v)", r.Task.SourceVol, r.Task.Ver, r.Task.CloneVol)
+	}
+	if r.Task != nil && r.Task.Detach {
+		msg += fmt.Sprintf("\n  detach: dirs %v, files %v, extents %v, rewritten %v",
+			r.DirCount, r.FileCount, r.ExtentNum, strutil.FormatSize(r.DetachedBytes))
+	} else {
+		msg += fmt.Sprintf("\n  dirs %v, files %v, symlinks %v, hard links %v, extents %v, shared %v",
+			r.DirCount, r.FileCount, r.SymlinkCount, r.HardLinkNum, r.ExtentNum, strutil.FormatSize(r.SharedBytes))
+	}
+	if r.Result != "" && r.Result != "waiting" {
+		msg += fmt.Sprintf("\n  result: %v", r.Result)
+	}
+	return msg
+}
@@ -0,0 +1,76 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/master"
+	"github.com/cubefs/cubefs/sdk/meta"
+	"github.com/cubefs/cubefs/util/strutil"
+	"github.com/spf13/cobra"
+)
+
+const (
+	cmdVolDirStatUse   = "dirstat [VOLUME NAME] [PATH]"
+	cmdVolDirStatShort = "Show the recursive file count, directory count and bytes of the directory, the root by default"
+)
+
+func newVolDirStatCmd(client *master.MasterClient) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   cmdVolDirStatUse,
+		Short: cmdVolDirStatShort,
+		Args:  cobra.RangeArgs(1, 2),
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			defer func() {
+				errout(err)
+			}()
+			dirPath := "/"
+			if len(args) > 1 {
+				dirPath = path.Clean("/" + args[1])
+			}
+			var mw *meta.MetaWrapper
+			if mw, err = meta.NewMetaWrapper(&meta.MetaConfig{Volume: args[0], Masters: client.Nodes()}); err != nil {
+				return
+			}
+			defer mw.Close()
+			ino := proto.RootIno
+			if dirPath != "/" {
+				if ino, err = mw.LookupPath(dirPath); err != nil {
+					err = fmt.Errorf("lookup %v: %v", dirPath, err)
+					return
+				}
+			}
+			var stat *proto.DirStat
+			if stat, err = mw.GetDirStat_ll(ino); err != nil {
+				err = fmt.Errorf("get stats of %v: %v", dirPath, err)
+				return
+			}
+			stdout("%v\n", formatDirStat(dirPath, ino, stat))
+		},
+	}
+	return cmd
+}
+
+func formatDirStat(dirPath string, ino uint64, stat *proto.DirStat) string {
+	return fmt.Sprintf("  Path        : %v\n", dirPath) +
+		fmt.Sprintf("  Inode       : %v\n", ino) +
+		fmt.Sprintf("  Files       : %v\n", stat.Files) +
+		fmt.Sprintf("  Directories : %v\n", stat.Dirs) +
+		fmt.Sprintf("  Bytes       : %v (%v)", stat.Bytes, strutil.FormatSize(uint64(stat.Bytes)))
+}
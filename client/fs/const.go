@@ -51,6 +51,11 @@ const (
 	MaxSizePutOnce = int64(1) << 23
 )
 
+const (
+	// the data copied by one copy_file_range request if the file is not cloned
+	copyFileRangeMaxSize = uint64(1) << 26
+)
+
 const (
 	DefaultFlag = 0x0f
 )
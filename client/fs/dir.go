@@ -151,6 +151,9 @@ func (d *Dir) Release(ctx context.Context, req *fuse.ReleaseRequest) (err error)
 
 // Create handles the create request.
 func (d *Dir) Create(ctx context.Context, req *fuse.CreateRequest, resp *fuse.CreateResponse) (fs.Node, fs.Handle, error) {
+	if err := d.super.checkWritable(); err != nil {
+		return nil, nil, err
+	}
 	start := time.Now()
 
 	bgTime := stat.BeginStat()
@@ -217,6 +220,9 @@ func (d *Dir) Forget() {
 
 // Mkdir handles the mkdir request.
 func (d *Dir) Mkdir(ctx context.Context, req *fuse.MkdirRequest) (fs.Node, error) {
+	if err := d.super.checkWritable(); err != nil {
+		return nil, err
+	}
 	start := time.Now()
 
 	bgTime := stat.BeginStat()
@@ -253,6 +259,9 @@ func (d *Dir) Mkdir(ctx context.Context, req *fuse.MkdirRequest) (fs.Node, error
 
 // Remove handles the remove request.
 func (d *Dir) Remove(ctx context.Context, req *fuse.RemoveRequest) error {
+	if err := d.super.checkWritable(); err != nil {
+		return err
+	}
 	start := time.Now()
 	d.dcache.Delete(req.Name)
 	dcacheKey := d.buildDcacheKey(d.info.Inode, req.Name)
@@ -309,6 +318,11 @@ func (d *Dir) Lookup(ctx context.Context, req *fuse.LookupRequest, resp *fuse.Lo
 		stat.EndStat("Lookup", err, bgTime, 1)
 	}()
 
+	if d.super.snapshots != nil && d.info.Inode == d.super.rootIno && req.Name == proto.SnapshotDirName {
+		resp.EntryValid = LookupValidDuration
+		return d.super.snapshots.dir, nil
+	}
+
 	log.LogDebugf("TRACE Lookup: parent(%v) req(%v)", d.info.Inode, req)
 	log.LogDebugf("TRACE Lookup: parent(%v) path(%v) d.super.bcacheDir(%v)", d.info.Inode, d.getCwd(), d.super.bcacheDir)
 
@@ -626,6 +640,12 @@ func (d *Dir) Rename(ctx context.Context, req *fuse.RenameRequest, newDir fs.Nod
 		log.LogErrorf("Rename: NOT DIR, parent(%v) req(%v)", d.info.Inode, req)
 		return fuse.ENOTSUP
 	}
+	if err := d.super.checkWritable(); err != nil {
+		return err
+	}
+	if err := dstDir.super.checkWritable(); err != nil {
+		return err
+	}
 	log.LogDebugf("TRACE Rename: enter")
 	start := time.Now()
 	var srcInode uint64 // must exist
@@ -693,6 +713,9 @@ func (d *Dir) Rename(ctx context.Context, req *fuse.RenameRequest, newDir fs.Nod
 
 // Setattr handles the setattr request.
 func (d *Dir) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.SetattrResponse) error {
+	if err := d.super.checkWritable(); err != nil {
+		return err
+	}
 	var err error
 	bgTime := stat.BeginStat()
 	defer func() {
@@ -724,6 +747,9 @@ func (d *Dir) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.
 }
 
 func (d *Dir) Mknod(ctx context.Context, req *fuse.MknodRequest) (fs.Node, error) {
+	if err := d.super.checkWritable(); err != nil {
+		return nil, err
+	}
 	if req.Rdev != 0 {
 		return nil, fuse.ENOSYS
 	}
@@ -759,6 +785,9 @@ func (d *Dir) Mknod(ctx context.Context, req *fuse.MknodRequest) (fs.Node, error
 
 // Symlink handles the symlink request.
 func (d *Dir) Symlink(ctx context.Context, req *fuse.SymlinkRequest) (fs.Node, error) {
+	if err := d.super.checkWritable(); err != nil {
+		return nil, err
+	}
 	parentIno := d.info.Inode
 	start := time.Now()
 
@@ -794,10 +823,16 @@ func (d *Dir) Link(ctx context.Context, req *fuse.LinkRequest, old fs.Node) (fs.
 	var oldInode *proto.InodeInfo
 	switch old := old.(type) {
 	case *File:
+		if old.super != d.super {
+			return nil, fuse.Errno(syscall.EXDEV)
+		}
 		oldInode = old.info
 	default:
 		return nil, fuse.EPERM
 	}
+	if err := d.super.checkWritable(); err != nil {
+		return nil, err
+	}
 
 	if !proto.IsRegular(oldInode.Mode) {
 		log.LogErrorf("Link: not regular, parent(%v) name(%v) ino(%v) mode(%v)", d.info.Inode, req.NewName, oldInode.Inode, proto.OsMode(oldInode.Mode))
@@ -940,6 +975,9 @@ func (d *Dir) Listxattr(ctx context.Context, req *fuse.ListxattrRequest, resp *f
 
 // Setxattr has not been implemented yet.
 func (d *Dir) Setxattr(ctx context.Context, req *fuse.SetxattrRequest) error {
+	if err := d.super.checkWritable(); err != nil {
+		return err
+	}
 	if !d.super.enableXattr {
 		return fuse.ENOSYS
 	}
@@ -957,6 +995,10 @@ func (d *Dir) Setxattr(ctx context.Context, req *fuse.SetxattrRequest) error {
 		log.LogErrorf("Set 'DirStat' is not supported.")
 		return fuse.ENOSYS
 	}
+	if proto.IsDirStatKey(name) {
+		log.LogErrorf("Set '%v' is not supported.", name)
+		return fuse.EPERM
+	}
 	// TODO： implement flag to improve compatible (Mofei Zhang)
 	if err = d.super.mw.XAttrSet_ll(ino, []byte(name), []byte(value)); err != nil {
 		log.LogErrorf("Setxattr: ino(%v) name(%v) err(%v)", ino, name, err)
@@ -968,6 +1010,9 @@ func (d *Dir) Setxattr(ctx context.Context, req *fuse.SetxattrRequest) error {
 
 // Removexattr has not been implemented yet.
 func (d *Dir) Removexattr(ctx context.Context, req *fuse.RemovexattrRequest) error {
+	if err := d.super.checkWritable(); err != nil {
+		return err
+	}
 	if !d.super.enableXattr {
 		return fuse.ENOSYS
 	}
@@ -984,6 +1029,10 @@ func (d *Dir) Removexattr(ctx context.Context, req *fuse.RemovexattrRequest) err
 		log.LogErrorf("Remove 'DirStat' is not supported.")
 		return fuse.ENOSYS
 	}
+	if proto.IsDirStatKey(name) {
+		log.LogErrorf("Remove '%v' is not supported.", name)
+		return fuse.EPERM
+	}
 	if err = d.super.mw.XAttrDel_ll(ino, name); err != nil {
 		log.LogErrorf("Removexattr: ino(%v) name(%v) err(%v)", ino, name, err)
 		return ParseError(err)
@@ -29,6 +29,7 @@ import (
 	"github.com/cubefs/cubefs/depends/bazil.org/fuse/fs"
 	"github.com/cubefs/cubefs/proto"
 	"github.com/cubefs/cubefs/sdk/data/blobstore"
+	"github.com/cubefs/cubefs/util"
 	"github.com/cubefs/cubefs/util/exporter"
 	"github.com/cubefs/cubefs/util/log"
 	"github.com/cubefs/cubefs/util/stat"
@@ -49,21 +50,24 @@ type File struct {
 
 // Functions that File needs to implement
 var (
-	_ fs.Node              = (*File)(nil)
-	_ fs.Handle            = (*File)(nil)
-	_ fs.NodeForgetter     = (*File)(nil)
-	_ fs.NodeOpener        = (*File)(nil)
-	_ fs.HandleReleaser    = (*File)(nil)
-	_ fs.HandleReader      = (*File)(nil)
-	_ fs.HandleWriter      = (*File)(nil)
-	_ fs.HandleFlusher     = (*File)(nil)
-	_ fs.NodeFsyncer       = (*File)(nil)
-	_ fs.NodeSetattrer     = (*File)(nil)
-	_ fs.NodeReadlinker    = (*File)(nil)
-	_ fs.NodeGetxattrer    = (*File)(nil)
-	_ fs.NodeListxattrer   = (*File)(nil)
-	_ fs.NodeSetxattrer    = (*File)(nil)
-	_ fs.NodeRemovexattrer = (*File)(nil)
+	_ fs.Node                 = (*File)(nil)
+	_ fs.Handle               = (*File)(nil)
+	_ fs.NodeForgetter        = (*File)(nil)
+	_ fs.NodeOpener           = (*File)(nil)
+	_ fs.HandleReleaser       = (*File)(nil)
+	_ fs.HandleReader         = (*File)(nil)
+	_ fs.HandleWriter         = (*File)(nil)
+	_ fs.HandleFlusher        = (*File)(nil)
+	_ fs.NodeFsyncer          = (*File)(nil)
+	_ fs.NodeSetattrer        = (*File)(nil)
+	_ fs.NodeReadlinker       = (*File)(nil)
+	_ fs.NodeGetxattrer       = (*File)(nil)
+	_ fs.NodeListxattrer      = (*File)(nil)
+	_ fs.NodeSetxattrer       = (*File)(nil)
+	_ fs.NodeRemovexattrer    = (*File)(nil)
+	_ fs.HandleLocker         = (*File)(nil)
+	_ fs.HandleFallocater     = (*File)(nil)
+	_ fs.HandleCopyFileRanger = (*File)(nil)
 )
 
 // NewFile returns a new file.
@@ -230,6 +234,9 @@ func (f *File) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenR
 	openForWrite := false
 	if req.Flags&0x0f != syscall.O_RDONLY {
 		openForWrite = true
+		if err = f.super.checkWritable(); err != nil {
+			return nil, err
+		}
 	}
 	isCache := false
 	if proto.IsCold(f.super.volType) || proto.IsStorageClassBlobStore(f.info.StorageClass) {
@@ -313,6 +320,10 @@ func (f *File) Release(ctx context.Context, req *fuse.ReleaseRequest) (err error
 
 	log.LogDebugf("TRACE Release enter: ino(%v) req(%v)", ino, req)
 
+	if f.super.locker != nil && req.ReleaseFlags&fuse.ReleaseFlockUnlock != 0 {
+		f.super.locker.ReleaseOwner(ino, req.LockOwner, true)
+	}
+
 	start := time.Now()
 
 	//log.LogErrorf("TRACE Release close stream: ino(%v) req(%v)", ino, req)
@@ -412,6 +423,9 @@ func (f *File) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadR
 
 // Write handles the write request.
 func (f *File) Write(ctx context.Context, req *fuse.WriteRequest, resp *fuse.WriteResponse) (err error) {
+	if err := f.super.checkWritable(); err != nil {
+		return err
+	}
 	bgTime := stat.BeginStat()
 	defer func() {
 		stat.EndStat("Write", err, bgTime, 1)
@@ -465,22 +479,7 @@ func (f *File) Write(ctx context.Context, req *fuse.WriteRequest, resp *fuse.Wri
 		metric.SetWithLabels(err, map[string]string{exporter.Vol: f.super.volname})
 	}()
 
-	checkFunc := func() error {
-		if !f.super.mw.EnableQuota {
-			return nil
-		}
-		if ok := f.super.ec.UidIsLimited(req.Uid); ok {
-			return ParseError(syscall.ENOSPC)
-		}
-		var quotaIds []uint32
-		for quotaId := range f.info.QuotaInfos {
-			quotaIds = append(quotaIds, quotaId)
-		}
-		if limited := f.super.mw.IsQuotaLimited(quotaIds); limited {
-			return ParseError(syscall.ENOSPC)
-		}
-		return nil
-	}
+	checkFunc := f.quotaCheckFunc(req.Uid, req.Gid)
 	var size int
 	if f.shouldAccessReplicaStorageClass() {
 		f.super.ec.GetStreamer(ino).SetParentInode(f.parentIno)
@@ -524,6 +523,30 @@ func (f *File) Write(ctx context.Context, req *fuse.WriteRequest, resp *fuse.Wri
 	return nil
 }
 
+// quotaCheckFunc returns the check of the quotas of the user, the group and the file before the
+// data is written.
+func (f *File) quotaCheckFunc(uid, gid uint32) func() error {
+	return func() error {
+		if !f.super.mw.EnableQuota {
+			return nil
+		}
+		if ok := f.super.ec.UidIsLimited(uid); ok {
+			return ParseError(syscall.ENOSPC)
+		}
+		if ok := f.super.ec.GidIsLimited(gid); ok {
+			return ParseError(syscall.ENOSPC)
+		}
+		var quotaIds []uint32
+		for quotaId := range f.info.QuotaInfos {
+			quotaIds = append(quotaIds, quotaId)
+		}
+		if limited := f.super.mw.IsQuotaLimited(quotaIds); limited {
+			return ParseError(syscall.ENOSPC)
+		}
+		return nil
+	}
+}
+
 // Flush only when fsyncOnClose is enabled.
 func (f *File) Flush(ctx context.Context, req *fuse.FlushRequest) (err error) {
 	bgTime := stat.BeginStat()
@@ -531,7 +554,16 @@ func (f *File) Flush(ctx context.Context, req *fuse.FlushRequest) (err error) {
 		stat.EndStat("Flush", err, bgTime, 1)
 	}()
 
+	if f.super.locker != nil && req != nil {
+		// the POSIX locks of the owner are released once any of its descriptors is closed
+		f.super.locker.ReleaseOwner(f.info.Inode, req.LockOwner, false)
+	}
+
 	if !f.super.fsyncOnClose {
+		if f.super.locker != nil {
+			// ENOSYS stops the kernel from sending flush requests, which are needed to release locks
+			return nil
+		}
 		return fuse.ENOSYS
 	}
 	log.LogDebugf("TRACE Flush enter: ino(%v)", f.info.Inode)
@@ -593,6 +625,9 @@ func (f *File) Fsync(ctx context.Context, req *fuse.FsyncRequest) (err error) {
 
 // Setattr handles the setattr request.
 func (f *File) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.SetattrResponse) error {
+	if err := f.super.checkWritable(); err != nil {
+		return err
+	}
 	var err error
 	bgTime := stat.BeginStat()
 	defer func() {
@@ -659,6 +694,155 @@ func (f *File) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse
 	return nil
 }
 
+// Fallocate handles the fallocate request, which punches or zeroes the byte range. Since the
+// extents are not preallocated, the modes only allocating the range are rejected with EOPNOTSUPP,
+// and the callers like posix_fallocate write the zeros themselves.
+func (f *File) Fallocate(ctx context.Context, req *fuse.FallocateRequest) (err error) {
+	if err := f.super.checkWritable(); err != nil {
+		return err
+	}
+	bgTime := stat.BeginStat()
+	defer func() {
+		stat.EndStat("Fallocate", err, bgTime, 1)
+	}()
+
+	ino := f.info.Inode
+	start := time.Now()
+	mode := uint32(req.Mode)
+	if !proto.IsValidFallocateMode(mode) || !proto.IsHot(f.super.volType) || !proto.IsStorageClassReplica(f.info.StorageClass) {
+		return fuse.Errno(syscall.EOPNOTSUPP)
+	}
+
+	fullPath := path.Join(f.getParentPath(), f.name)
+	err = f.super.ec.Fallocate(ino, mode, req.Offset, req.Length, f.info.StorageClass, fullPath)
+	f.super.ic.Delete(ino)
+	if err != nil {
+		log.LogErrorf("Fallocate: ino(%v) req(%v) err(%v)", ino, req, err)
+		return ParseError(err)
+	}
+
+	elapsed := time.Since(start)
+	log.LogDebugf("TRACE Fallocate: ino(%v) req(%v) (%v)ns", ino, req, elapsed.Nanoseconds())
+	return nil
+}
+
+// CopyFileRange handles the copy_file_range request. Copying the whole file into an empty file of
+// the same meta partition clones the extent keys instead of the data. The other ranges, and the
+// files which can't be cloned, are copied by the client without passing the data through the
+// kernel. The targets out of the mount fail with EXDEV, and the files not stored in replicas with
+// EOPNOTSUPP, which the kernel serves by copying the data itself.
+func (f *File) CopyFileRange(ctx context.Context, req *fuse.CopyFileRangeRequest, out fs.Handle) (size uint64, err error) {
+	if err := f.super.checkWritable(); err != nil {
+		return 0, err
+	}
+	bgTime := stat.BeginStat()
+	defer func() {
+		stat.EndStat("CopyFileRange", err, bgTime, 1)
+	}()
+
+	ino := f.info.Inode
+	dst, ok := out.(*File)
+	if !ok {
+		log.LogWarnf("CopyFileRange: ino(%v) req(%v) the target is not a file of the volume", ino, req)
+		return 0, fuse.Errno(syscall.EXDEV)
+	}
+	if !proto.IsHot(f.super.volType) || !f.shouldAccessReplicaStorageClass() || !dst.shouldAccessReplicaStorageClass() {
+		log.LogWarnf("CopyFileRange: ino(%v) req(%v) only the files in replicas are copied", ino, req)
+		return 0, fuse.Errno(syscall.EOPNOTSUPP)
+	}
+	dstIno := dst.info.Inode
+	start := time.Now()
+	if err = f.super.ec.Flush(ino); err != nil {
+		log.LogErrorf("CopyFileRange: flush ino(%v) err(%v)", ino, err)
+		return 0, ParseError(err)
+	}
+	if err = f.super.ec.Flush(dstIno); err != nil {
+		log.LogErrorf("CopyFileRange: flush ino(%v) err(%v)", dstIno, err)
+		return 0, ParseError(err)
+	}
+	srcInfo, err := f.super.mw.InodeGet_ll(ino)
+	if err != nil {
+		return 0, ParseError(err)
+	}
+	dstInfo, err := f.super.mw.InodeGet_ll(dstIno)
+	if err != nil {
+		return 0, ParseError(err)
+	}
+
+	if req.Offset == 0 && req.OffsetOut == 0 && req.Len >= srcInfo.Size && dstInfo.Size == 0 {
+		info, err := f.super.mw.CloneInode(ino, dstIno, dstInfo.Uid, dstInfo.Gid)
+		if err == nil {
+			f.super.ic.Delete(dstIno)
+			f.super.ec.ForceRefreshExtentsCache(ino)
+			f.super.ec.ForceRefreshExtentsCache(dstIno)
+			log.LogDebugf("TRACE CopyFileRange: ino(%v) cloned into ino(%v) size(%v) (%v)ns", ino, dstIno, info.Size,
+				time.Since(start).Nanoseconds())
+			return info.Size, nil
+		}
+		if err != syscall.EOPNOTSUPP && err != syscall.EXDEV {
+			log.LogErrorf("CopyFileRange: clone ino(%v) into ino(%v) err(%v)", ino, dstIno, err)
+			return 0, ParseError(err)
+		}
+		log.LogDebugf("CopyFileRange: ino(%v) dst(%v) can not be cloned, copy the data, err(%v)", ino, dstIno, err)
+	}
+
+	size, err = f.copyRange(dst, req, srcInfo.Size)
+	if err != nil {
+		log.LogErrorf("CopyFileRange: copy ino(%v) into ino(%v) req(%v) copied(%v) err(%v)", ino, dstIno, req, size, err)
+		if size == 0 {
+			return 0, ParseError(err)
+		}
+	}
+	log.LogDebugf("TRACE CopyFileRange: ino(%v) copied into ino(%v) req(%v) size(%v) (%v)ns", ino, dstIno, req, size,
+		time.Since(start).Nanoseconds())
+	return size, nil
+}
+
+// copyRange copies the data of the range through the client. At most copyFileRangeMaxSize bytes
+// are copied by a request, the caller of copy_file_range copies the rest by the next call.
+func (f *File) copyRange(dst *File, req *fuse.CopyFileRangeRequest, srcSize uint64) (size uint64, err error) {
+	if req.Offset >= srcSize {
+		return 0, nil
+	}
+	length := req.Len
+	if left := srcSize - req.Offset; length > left {
+		length = left
+	}
+	if length > copyFileRangeMaxSize {
+		length = copyFileRangeMaxSize
+	}
+
+	dstIno := dst.info.Inode
+	defer f.super.ic.Delete(dstIno)
+	if s := f.super.ec.GetStreamer(dstIno); s != nil {
+		s.SetParentInode(dst.parentIno)
+	}
+	checkFunc := dst.quotaCheckFunc(req.Uid, req.Gid)
+	buf := make([]byte, util.BlockSize)
+	for size < length {
+		n := len(buf)
+		if left := length - size; left < uint64(n) {
+			n = int(left)
+		}
+		var read, written int
+		read, err = f.super.ec.Read(f.info.Inode, buf[:n], int(req.Offset+size), n, f.info.StorageClass, false)
+		if err != nil && err != io.EOF {
+			return
+		}
+		err = nil
+		// the file is truncated meanwhile
+		if read == 0 {
+			break
+		}
+		if written, err = f.super.ec.Write(dstIno, int(req.OffsetOut+size), buf[:read], 0, checkFunc,
+			dst.info.StorageClass, false); err != nil {
+			return
+		}
+		size += uint64(written)
+	}
+	return
+}
+
 // Readlink handles the readlink request.
 func (f *File) Readlink(ctx context.Context, req *fuse.ReadlinkRequest) (string, error) {
 	var err error
@@ -738,6 +922,9 @@ func (f *File) Listxattr(ctx context.Context, req *fuse.ListxattrRequest, resp *
 
 // Setxattr has not been implemented yet.
 func (f *File) Setxattr(ctx context.Context, req *fuse.SetxattrRequest) error {
+	if err := f.super.checkWritable(); err != nil {
+		return err
+	}
 	var err error
 	bgTime := stat.BeginStat()
 	defer func() {
@@ -761,6 +948,9 @@ func (f *File) Setxattr(ctx context.Context, req *fuse.SetxattrRequest) error {
 
 // Removexattr has not been implemented yet.
 func (f *File) Removexattr(ctx context.Context, req *fuse.RemovexattrRequest) error {
+	if err := f.super.checkWritable(); err != nil {
+		return err
+	}
 	var err error
 	bgTime := stat.BeginStat()
 	defer func() {
@@ -780,6 +970,36 @@ func (f *File) Removexattr(ctx context.Context, req *fuse.RemovexattrRequest) er
 	return nil
 }
 
+// Setlk handles the fcntl(F_SETLK/F_SETLKW) and flock requests.
+func (f *File) Setlk(ctx context.Context, req *fuse.SetlkRequest) (err error) {
+	bgTime := stat.BeginStat()
+	defer func() {
+		stat.EndStat("Setlk", err, bgTime, 1)
+	}()
+
+	if f.super.locker == nil {
+		return fuse.ENOSYS
+	}
+	err = f.super.locker.Setlk(ctx, f.info.Inode, req)
+	log.LogDebugf("TRACE Setlk: ino(%v) req(%v) err(%v)", f.info.Inode, req, err)
+	return err
+}
+
+// Getlk handles the fcntl(F_GETLK) requests.
+func (f *File) Getlk(ctx context.Context, req *fuse.GetlkRequest, resp *fuse.GetlkResponse) (err error) {
+	bgTime := stat.BeginStat()
+	defer func() {
+		stat.EndStat("Getlk", err, bgTime, 1)
+	}()
+
+	if f.super.locker == nil {
+		return fuse.ENOSYS
+	}
+	err = f.super.locker.Getlk(f.info.Inode, req, resp)
+	log.LogDebugf("TRACE Getlk: ino(%v) req(%v) resp(%v) err(%v)", f.info.Inode, req, resp, err)
+	return err
+}
+
 func (f *File) fileSize(ino uint64) (size int, gen uint64) {
 	size, gen, valid := f.super.ec.FileSize(ino)
 	if !valid {
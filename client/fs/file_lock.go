@@ -0,0 +1,216 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package fs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"math"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cubefs/cubefs/depends/bazil.org/fuse"
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/meta"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const (
+	FileLockLease       = 30 // seconds
+	FileLockRenewPeriod = 10 * time.Second
+
+	fileLockMinRetryWait = 10 * time.Millisecond
+	fileLockMaxRetryWait = time.Second
+)
+
+// FileLocker keeps the POSIX and flock locks in the metanode, so that the locks are honoured by
+// all the clients of the volume. The locks are held with leases which are renewed periodically,
+// and are released by the metanode once the client is gone.
+type FileLocker struct {
+	mw     *meta.MetaWrapper
+	client uint64 // session id of this client
+
+	mu     sync.Mutex
+	seq    uint64
+	inodes map[uint64]uint64 // inodes that this client may hold locks on, to the seq of last lock
+}
+
+// NewFileLocker returns a new FileLocker, which renews the leases until closeC is closed.
+func NewFileLocker(mw *meta.MetaWrapper, closeC <-chan struct{}) *FileLocker {
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		binary.BigEndian.PutUint64(id[:], uint64(time.Now().UnixNano()))
+	}
+	l := &FileLocker{
+		mw:     mw,
+		client: binary.BigEndian.Uint64(id[:]),
+		inodes: make(map[uint64]uint64),
+	}
+	go l.loopRenew(closeC)
+	return l
+}
+
+func (l *FileLocker) fileLock(owner uint64, lock fuse.FileLock, flags fuse.LockFlags) *proto.FileLock {
+	lk := &proto.FileLock{
+		Client: l.client,
+		Owner:  owner,
+		Pid:    lock.Pid,
+		Flock:  flags&fuse.LockFlock != 0,
+		Start:  lock.Start,
+		End:    lock.End,
+	}
+	switch lock.Type {
+	case fuse.LockRead:
+		lk.Type = proto.FileLockRead
+	case fuse.LockWrite:
+		lk.Type = proto.FileLockWrite
+	default:
+		lk.Type = proto.FileLockUnlock
+	}
+	return lk
+}
+
+// Setlk acquires or releases the lock, and retries until the lock is granted or the request is
+// interrupted if req.Wait is set.
+func (l *FileLocker) Setlk(ctx context.Context, ino uint64, req *fuse.SetlkRequest) error {
+	lk := l.fileLock(req.LockOwner, req.Lock, req.LockFlags)
+	if lk.Type == proto.FileLockUnlock {
+		if _, err := l.mw.SetFileLock(ino, lk, FileLockLease); err != nil {
+			log.LogErrorf("FileLocker Setlk: ino(%v) lock(%v) err(%v)", ino, lk, err)
+			return ParseError(err)
+		}
+		return nil
+	}
+
+	l.track(ino)
+	wait := fileLockMinRetryWait
+	for {
+		conflict, err := l.mw.SetFileLock(ino, lk, FileLockLease)
+		if err == nil {
+			// track again in case the inode is dropped by the renewal in the meantime
+			l.track(ino)
+			return nil
+		}
+		if err != syscall.EAGAIN || !req.Wait {
+			log.LogDebugf("FileLocker Setlk: ino(%v) lock(%v) conflict(%v) err(%v)", ino, lk, conflict, err)
+			return ParseError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return fuse.Errno(syscall.EINTR)
+		case <-time.After(wait):
+		}
+		if wait *= 2; wait > fileLockMaxRetryWait {
+			wait = fileLockMaxRetryWait
+		}
+	}
+}
+
+func (l *FileLocker) track(ino uint64) {
+	l.mu.Lock()
+	l.seq++
+	l.inodes[ino] = l.seq
+	l.mu.Unlock()
+}
+
+// Getlk looks for the lock conflicting with the requested one.
+func (l *FileLocker) Getlk(ino uint64, req *fuse.GetlkRequest, resp *fuse.GetlkResponse) error {
+	lk := l.fileLock(req.LockOwner, req.Lock, req.LockFlags)
+	conflict, err := l.mw.GetFileLock(ino, lk)
+	if err != nil {
+		log.LogErrorf("FileLocker Getlk: ino(%v) lock(%v) err(%v)", ino, lk, err)
+		return ParseError(err)
+	}
+	if conflict == nil {
+		resp.Lock.Type = fuse.LockUnlock
+		return nil
+	}
+	resp.Lock = fuse.FileLock{
+		Start: conflict.Start,
+		End:   conflict.End,
+		Type:  fuse.LockRead,
+	}
+	if conflict.Type == proto.FileLockWrite {
+		resp.Lock.Type = fuse.LockWrite
+	}
+	if conflict.Client == l.client {
+		// the pid makes no sense on the other clients
+		resp.Lock.Pid = conflict.Pid
+	}
+	return nil
+}
+
+// ReleaseOwner releases all the locks of the lock owner on the inode, which is called once the
+// file is closed by the owner.
+func (l *FileLocker) ReleaseOwner(ino uint64, owner uint64, flock bool) {
+	l.mu.Lock()
+	_, ok := l.inodes[ino]
+	l.mu.Unlock()
+	if !ok {
+		return
+	}
+	lk := &proto.FileLock{
+		Client: l.client,
+		Owner:  owner,
+		Flock:  flock,
+		Type:   proto.FileLockUnlock,
+		Start:  0,
+		End:    math.MaxUint64,
+	}
+	if _, err := l.mw.SetFileLock(ino, lk, FileLockLease); err != nil {
+		log.LogWarnf("FileLocker ReleaseOwner: ino(%v) lock(%v) err(%v)", ino, lk, err)
+	}
+}
+
+func (l *FileLocker) loopRenew(closeC <-chan struct{}) {
+	t := time.NewTicker(FileLockRenewPeriod)
+	defer t.Stop()
+	for {
+		select {
+		case <-closeC:
+			return
+		case <-t.C:
+			l.renew()
+		}
+	}
+}
+
+func (l *FileLocker) renew() {
+	l.mu.Lock()
+	inodes := make(map[uint64]uint64, len(l.inodes))
+	for ino, seq := range l.inodes {
+		inodes[ino] = seq
+	}
+	l.mu.Unlock()
+
+	for ino, seq := range inodes {
+		held, err := l.mw.RenewFileLocks(ino, l.client, FileLockLease)
+		if err != nil {
+			log.LogWarnf("FileLocker renew: ino(%v) client(%v) err(%v)", ino, l.client, err)
+			continue
+		}
+		if held > 0 {
+			continue
+		}
+		// drop the inode unless it is locked again during the renewal
+		l.mu.Lock()
+		if l.inodes[ino] == seq {
+			delete(l.inodes, ino)
+		}
+		l.mu.Unlock()
+	}
+}
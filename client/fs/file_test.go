@@ -0,0 +1,68 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package fs
+
+import (
+	"context"
+	"syscall"
+	"testing"
+
+	"github.com/cubefs/cubefs/depends/bazil.org/fuse"
+	"github.com/cubefs/cubefs/depends/bazil.org/fuse/fs"
+	"github.com/cubefs/cubefs/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileFallocateRejected(t *testing.T) {
+	super := &Super{volType: proto.VolumeTypeHot}
+	f := &File{super: super, info: &proto.InodeInfo{Inode: 10, StorageClass: proto.StorageClass_Replica_HDD}}
+	fallocate := func(mode fuse.FallocateFlags) error {
+		return f.Fallocate(context.Background(), &fuse.FallocateRequest{Offset: 0, Length: 4096, Mode: mode})
+	}
+
+	// the modes only allocating the range can not reserve the space, posix_fallocate falls back
+	// to writing zeros on EOPNOTSUPP
+	require.Equal(t, fuse.Errno(syscall.EOPNOTSUPP), fallocate(0))
+	require.Equal(t, fuse.Errno(syscall.EOPNOTSUPP), fallocate(fuse.FallocateFlags(proto.FallocKeepSize)))
+	// punching hole has to keep the size
+	require.Equal(t, fuse.Errno(syscall.EOPNOTSUPP), fallocate(fuse.FallocateFlags(proto.FallocPunchHole)))
+
+	f.info.StorageClass = proto.StorageClass_BlobStore
+	require.Equal(t, fuse.Errno(syscall.EOPNOTSUPP), fallocate(fuse.FallocateFlags(proto.FallocZeroRange)))
+
+	super.readOnly = true
+	require.Equal(t, fuse.Errno(syscall.EROFS), fallocate(fuse.FallocateFlags(proto.FallocZeroRange)))
+}
+
+func TestFileCopyFileRangeRejected(t *testing.T) {
+	super := &Super{volType: proto.VolumeTypeHot}
+	f := &File{super: super, info: &proto.InodeInfo{Inode: 10, StorageClass: proto.StorageClass_Replica_HDD}}
+	dst := &File{super: super, info: &proto.InodeInfo{Inode: 11, StorageClass: proto.StorageClass_BlobStore}}
+	copyFileRange := func(out fs.Handle) error {
+		_, err := f.CopyFileRange(context.Background(), &fuse.CopyFileRangeRequest{Offset: 4096, Len: 4096}, out)
+		return err
+	}
+
+	// the kernel copies the data itself on EXDEV and EOPNOTSUPP
+	require.Equal(t, fuse.Errno(syscall.EXDEV), copyFileRange(&Dir{super: super}))
+	require.Equal(t, fuse.Errno(syscall.EOPNOTSUPP), copyFileRange(dst))
+
+	super.volType = proto.VolumeTypeCold
+	dst.info.StorageClass = proto.StorageClass_Replica_HDD
+	require.Equal(t, fuse.Errno(syscall.EOPNOTSUPP), copyFileRange(dst))
+
+	super.readOnly = true
+	require.Equal(t, fuse.Errno(syscall.EROFS), copyFileRange(dst))
+}
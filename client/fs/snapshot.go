@@ -0,0 +1,225 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package fs
+
+import (
+	"context"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cubefs/cubefs/depends/bazil.org/fuse"
+	"github.com/cubefs/cubefs/depends/bazil.org/fuse/fs"
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/master"
+	"github.com/cubefs/cubefs/sdk/meta"
+	"github.com/cubefs/cubefs/util/log"
+	"github.com/cubefs/cubefs/util/stat"
+)
+
+// SnapshotDirInode is the inode number reported for the virtual snapshot directory, which is
+// never allocated by the meta partitions.
+const SnapshotDirInode = math.MaxUint64 - 1
+
+// snapshotManager serves the snapshots of the volume under the virtual snapshot directory of the
+// mount root. Each snapshot is read through a read-only Super of its own, created on the first
+// access and dropped once the version is deleted.
+type snapshotManager struct {
+	super     *Super
+	opt       proto.MountOptions
+	mc        *master.MasterClient
+	dir       *SnapshotDir
+	mountTime time.Time
+
+	sync.Mutex
+	supers map[uint64]*snapshotSuper
+}
+
+type snapshotSuper struct {
+	super *Super
+	root  fs.Node
+}
+
+func newSnapshotManager(s *Super, opt *proto.MountOptions) *snapshotManager {
+	m := &snapshotManager{
+		super:     s,
+		opt:       *opt,
+		mc:        master.NewMasterClient(strings.Split(opt.Master, meta.HostsSeparator), false),
+		mountTime: time.Now(),
+		supers:    make(map[uint64]*snapshotSuper),
+	}
+	m.dir = &SnapshotDir{mgr: m}
+	return m
+}
+
+// listSnapshots returns the snapshots of the volume and releases the ones deleted.
+func (m *snapshotManager) listSnapshots() ([]*proto.VolSnapshot, error) {
+	verList, err := m.mc.AdminAPI().GetVerList(m.super.volname)
+	if err != nil {
+		log.LogErrorf("listSnapshots: vol(%v) err(%v)", m.super.volname, err)
+		return nil, err
+	}
+	snapshots := verList.GetSnapshots()
+
+	valid := make(map[uint64]bool, len(snapshots))
+	for _, snapshot := range snapshots {
+		valid[snapshot.Ver] = true
+	}
+	m.Lock()
+	for ver, ss := range m.supers {
+		if !valid[ver] {
+			delete(m.supers, ver)
+			go ss.close()
+			log.LogInfof("listSnapshots: vol(%v) snapshot ver(%v) is deleted, release it", m.super.volname, ver)
+		}
+	}
+	m.Unlock()
+	return snapshots, nil
+}
+
+// getRoot returns the root directory of the snapshot, the read-only Super of the snapshot is
+// created if it does not exist.
+func (m *snapshotManager) getRoot(snapshot *proto.VolSnapshot) (fs.Node, error) {
+	m.Lock()
+	defer m.Unlock()
+	if ss, ok := m.supers[snapshot.Ver]; ok {
+		return ss.root, nil
+	}
+
+	opt := m.opt
+	opt.VerReadSeq = 0
+	opt.SnapshotDir = false
+	opt.EnableFileLock = false
+	opt.EnableBcache = false
+	opt.BcacheDir = ""
+	opt.NeedRestoreFuse = false
+	s, err := NewSuper(&opt)
+	if err != nil {
+		log.LogErrorf("getRoot: vol(%v) snapshot(%v) ver(%v) err(%v)", m.super.volname, snapshot.Name, snapshot.Ver, err)
+		return nil, err
+	}
+	// the metadata is read as it was right before the next version was created
+	s.readOnly = true
+	s.mw.VerReadSeq = snapshot.ReadSeq
+
+	root, err := s.Root()
+	if err != nil {
+		s.Close()
+		_ = s.ec.Close()
+		return nil, err
+	}
+	m.supers[snapshot.Ver] = &snapshotSuper{super: s, root: root}
+	log.LogInfof("getRoot: vol(%v) snapshot(%v) ver(%v) readSeq(%v) loaded", m.super.volname, snapshot.Name, snapshot.Ver, snapshot.ReadSeq)
+	return root, nil
+}
+
+func (m *snapshotManager) close() {
+	m.Lock()
+	defer m.Unlock()
+	for ver, ss := range m.supers {
+		delete(m.supers, ver)
+		ss.close()
+	}
+}
+
+func (ss *snapshotSuper) close() {
+	ss.super.Close()
+	_ = ss.super.ec.Close()
+}
+
+// SnapshotDir is the virtual read-only directory which lists the snapshots of the volume.
+type SnapshotDir struct {
+	mgr *snapshotManager
+}
+
+// Functions that SnapshotDir needs to implement
+var (
+	_ fs.Node                = (*SnapshotDir)(nil)
+	_ fs.NodeRequestLookuper = (*SnapshotDir)(nil)
+	_ fs.HandleReadDirAller  = (*SnapshotDir)(nil)
+)
+
+// Attr set the attributes of the snapshot directory.
+func (d *SnapshotDir) Attr(ctx context.Context, a *fuse.Attr) error {
+	a.Valid = AttrValidDuration
+	a.Inode = SnapshotDirInode
+	a.Mode = os.ModeDir | 0o555
+	a.Nlink = 2
+	a.Atime = d.mgr.mountTime
+	a.Ctime = d.mgr.mountTime
+	a.Mtime = d.mgr.mountTime
+	a.BlockSize = DefaultBlksize
+	return nil
+}
+
+// Lookup returns the root directory of the snapshot.
+func (d *SnapshotDir) Lookup(ctx context.Context, req *fuse.LookupRequest, resp *fuse.LookupResponse) (fs.Node, error) {
+	var err error
+	bgTime := stat.BeginStat()
+	defer func() {
+		stat.EndStat("SnapshotLookup", err, bgTime, 1)
+	}()
+
+	snapshots, err := d.mgr.listSnapshots()
+	if err != nil {
+		return nil, fuse.EIO
+	}
+	snapshot := proto.FindSnapshot(snapshots, req.Name)
+	if snapshot == nil {
+		return nil, fuse.ENOENT
+	}
+
+	root, err := d.mgr.getRoot(snapshot)
+	if err != nil {
+		return nil, ParseError(err)
+	}
+	resp.EntryValid = LookupValidDuration
+	log.LogDebugf("TRACE SnapshotLookup: name(%v) ver(%v)", req.Name, snapshot.Ver)
+	return root, nil
+}
+
+// ReadDirAll lists the snapshots of the volume.
+func (d *SnapshotDir) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
+	var err error
+	bgTime := stat.BeginStat()
+	defer func() {
+		stat.EndStat("SnapshotReadDirAll", err, bgTime, 1)
+	}()
+
+	snapshots, err := d.mgr.listSnapshots()
+	if err != nil {
+		return make([]fuse.Dirent, 0), fuse.EIO
+	}
+	dirents := make([]fuse.Dirent, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		dirents = append(dirents, fuse.Dirent{
+			Inode: d.mgr.super.rootIno,
+			Type:  fuse.DT_Dir,
+			Name:  snapshot.Name,
+		})
+	}
+	return dirents, nil
+}
+
+// checkWritable refuses the modifications in the snapshots.
+func (s *Super) checkWritable() error {
+	if s.readOnly {
+		return fuse.Errno(syscall.EROFS)
+	}
+	return nil
+}
@@ -65,6 +65,7 @@ type Super struct {
 	fsyncOnClose  bool
 	enableXattr   bool
 	rootIno       uint64
+	locker        *FileLocker
 
 	state     fs.FSStatType
 	sockaddr  string
@@ -92,6 +93,10 @@ type Super struct {
 	closeC   chan struct{}
 
 	cacheDpStorageClass uint32
+
+	// the snapshots served under the snapshot directory, and whether this is one of them
+	snapshots *snapshotManager
+	readOnly  bool
 }
 
 // Functions that Super needs to implement
@@ -220,24 +225,28 @@ func NewSuper(opt *proto.MountOptions) (s *Super, err error) {
 	s.cacheDpStorageClass = opt.VolCacheDpStorageClass
 
 	extentConfig := &stream.ExtentConfig{
-		Volume:            opt.Volname,
-		Masters:           masters,
-		FollowerRead:      opt.FollowerRead,
-		NearRead:          opt.NearRead,
-		ReadRate:          opt.ReadRate,
-		WriteRate:         opt.WriteRate,
-		BcacheEnable:      opt.EnableBcache,
-		BcacheDir:         opt.BcacheDir,
-		MaxStreamerLimit:  opt.MaxStreamerLimit,
-		VerReadSeq:        opt.VerReadSeq,
-		OnAppendExtentKey: s.mw.AppendExtentKey,
-		OnSplitExtentKey:  s.mw.SplitExtentKey,
-		OnGetExtents:      s.mw.GetExtents,
-		OnTruncate:        s.mw.Truncate,
-		OnEvictIcache:     s.ic.Delete,
-		OnLoadBcache:      s.bc.Get,
-		OnCacheBcache:     s.bc.Put,
-		OnEvictBcache:     s.bc.Evict,
+		Volume:             opt.Volname,
+		Masters:            masters,
+		FollowerRead:       opt.FollowerRead,
+		NearRead:           opt.NearRead,
+		ReadRate:           opt.ReadRate,
+		WriteRate:          opt.WriteRate,
+		BcacheEnable:       opt.EnableBcache,
+		BcacheDir:          opt.BcacheDir,
+		MaxStreamerLimit:   opt.MaxStreamerLimit,
+		VerReadSeq:         opt.VerReadSeq,
+		OnAppendExtentKey:  s.mw.AppendExtentKey,
+		OnSplitExtentKey:   s.mw.SplitExtentKey,
+		OnGetExtents:       s.mw.GetExtents,
+		OnGetInlineExtents: s.mw.GetInlineExtents,
+		OnWriteInline:      s.mw.WriteInline,
+		OnTruncate:         s.mw.Truncate,
+		OnFallocate:        s.mw.Fallocate,
+		OnIsSharedExtent:   s.mw.IsSharedExtent,
+		OnEvictIcache:      s.ic.Delete,
+		OnLoadBcache:       s.bc.Get,
+		OnCacheBcache:      s.bc.Put,
+		OnEvictBcache:      s.bc.Evict,
 
 		DisableMetaCache:             DisableMetaCache,
 		MinWriteAbleDataPartitionCnt: opt.MinWriteAbleDataPartitionCnt,
@@ -313,6 +322,14 @@ func NewSuper(opt *proto.MountOptions) (s *Super, err error) {
 	log.LogInfof("NewSuper: cluster(%v) volname(%v) icacheExpiration(%v) LookupValidDuration(%v) AttrValidDuration(%v) state(%v) cacheDpStorageClass(%v)",
 		s.cluster, s.volname, inodeExpiration, LookupValidDuration, AttrValidDuration, s.state, s.cacheDpStorageClass)
 
+	if opt.EnableFileLock {
+		s.locker = NewFileLocker(s.mw, s.closeC)
+	}
+
+	if opt.SnapshotDir && opt.VerReadSeq == 0 {
+		s.snapshots = newSnapshotManager(s, opt)
+	}
+
 	go s.loopSyncMeta()
 
 	return s, nil
@@ -773,6 +790,9 @@ func getDelInodes(src []uint64, act []*proto.InodeInfo) []uint64 {
 }
 
 func (s *Super) Close() {
+	if s.snapshots != nil {
+		s.snapshots.close()
+	}
 	close(s.closeC)
 	s.mw.Close()
 }
@@ -862,6 +862,10 @@ func mount(opt *proto.MountOptions) (fsConn *fuse.Conn, super *cfs.Super, err er
 		options = append(options, fuse.DefaultPermissions())
 	}
 
+	if opt.EnableFileLock {
+		options = append(options, fuse.LockingPOSIX(), fuse.LockingFlock())
+	}
+
 	fsConn, err = fuse.Mount(opt.MountPoint, opt.NeedRestoreFuse, options...)
 	return
 }
@@ -934,6 +938,7 @@ func parseMountOption(cfg *config.Config) (*proto.MountOptions, error) {
 	opt.EnablePosixACL = GlobalMountOptions[proto.EnablePosixACL].GetBool()
 	opt.EnableSummary = GlobalMountOptions[proto.EnableSummary].GetBool()
 	opt.EnableUnixPermission = GlobalMountOptions[proto.EnableUnixPermission].GetBool()
+	opt.EnableFileLock = GlobalMountOptions[proto.EnableFileLock].GetBool()
 	opt.ReadThreads = GlobalMountOptions[proto.ReadThreads].GetInt64()
 	opt.WriteThreads = GlobalMountOptions[proto.WriteThreads].GetInt64()
 
@@ -957,6 +962,7 @@ func parseMountOption(cfg *config.Config) (*proto.MountOptions, error) {
 		}
 		log.LogDebugf("oonfig.verReadSeq %v opt.VerReadSeq %v", verReadSeq, opt.VerReadSeq)
 	}
+	opt.SnapshotDir = GlobalMountOptions[proto.SnapshotDir].GetBool()
 	opt.MetaSendTimeout = GlobalMountOptions[proto.MetaSendTimeout].GetInt64()
 
 	opt.BuffersTotalLimit = GlobalMountOptions[proto.BuffersTotalLimit].GetInt64()
@@ -261,7 +261,11 @@ func (c *Client) Start() (err error) {
 		FollowerRead:                c.cfg.FollowerRead,
 		OnAppendExtentKey:           mw.AppendExtentKey,
 		OnGetExtents:                mw.GetExtents,
+		OnGetInlineExtents:          mw.GetInlineExtents,
+		OnWriteInline:               mw.WriteInline,
 		OnTruncate:                  mw.Truncate,
+		OnFallocate:                 mw.Fallocate,
+		OnIsSharedExtent:            mw.IsSharedExtent,
 		BcacheEnable:                c.cfg.EnableBcache,
 		OnLoadBcache:                c.bc.Get,
 		OnCacheBcache:               c.bc.Put,
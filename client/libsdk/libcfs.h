@@ -178,6 +178,7 @@ extern int cfs_unlock_dir(int64_t id, char *path);
 extern int cfs_get_dir_lock(int64_t id, char *path, int64_t *lock_id, char **valid_time);
 extern int cfs_symlink(int64_t id, char *src_path, char *dst_path);
 extern int cfs_link(int64_t id, char *src_path, char *dst_path);
+extern int cfs_copy_file(int64_t id, char *src_path, char *dst_path);
 extern int cfs_IsDir(mode_t mode);
 extern int cfs_IsRegular(mode_t mode);
 extern int cfs_list_vols(int64_t id, GoSlice cfs_vol_info, int count);
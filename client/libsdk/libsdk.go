@@ -191,6 +191,8 @@ func newClient() *client {
 		sc:                  fs.NewSummaryCache(fs.DefaultSummaryExpiration, fs.MaxSummaryCache),
 		ic:                  fs.NewInodeCache(fs.DefaultInodeExpiration, fs.MaxInodeCache),
 		dc:                  fs.NewDentryCache(),
+		enableSnapshotDir:   true,
+		snapshots:           make(map[uint64]*client),
 	}
 
 	gClientManager.mu.Lock()
@@ -230,6 +232,9 @@ type file struct {
 	path         string
 	storageClass uint32
 	openForWrite bool
+
+	// the snapshot which the file is opened in
+	snap *client
 }
 
 type dirStream struct {
@@ -269,12 +274,20 @@ type client struct {
 	volAllowedStorageClass []uint32
 	cacheDpStorageClass    uint32
 	enableInnerReq         bool
+	enableSnapshotDir      bool
 
 	// runtime context
-	cwd    string // current working directory
-	fdmap  map[uint]*file
-	fdset  *bitset.BitSet
-	fdlock sync.RWMutex
+	cwd       string // current working directory
+	fdmap     map[uint]*file
+	fdset     *bitset.BitSet
+	fdlock    sync.RWMutex
+	startTime time.Time
+
+	// the clients of the snapshots read through the snapshot directory
+	snapshots map[uint64]*client
+	snapMu    sync.Mutex
+	// the snapshot which the client reads, nil for the live volume
+	snapshot *proto.VolSnapshot
 
 	// server info
 	mw   *meta.MetaWrapper
@@ -475,6 +488,63 @@ func cfs_link(id C.int64_t, src_path *C.char, dst_path *C.char) C.int {
 	return statusOK
 }
 
+// cfs_copy_file creates dst_path as a copy of the regular file src_path, sharing the extents
+// of the source instead of copying the data. It fails with EXDEV or EOPNOTSUPP if the file can't
+// be cloned, the caller should copy the data instead.
+//
+//export cfs_copy_file
+func cfs_copy_file(id C.int64_t, src_path *C.char, dst_path *C.char) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+
+	fullSrcPath := c.absPath(C.GoString(src_path))
+	fullDstPath := c.absPath(C.GoString(dst_path))
+	if _, _, ok := c.splitSnapshotPath(fullDstPath); ok {
+		return errorToStatus(syscall.EROFS)
+	}
+	info, err := c.lookupPath(fullSrcPath)
+	if err != nil {
+		return errorToStatus(err)
+	}
+	src_ino := info.Inode
+	if !proto.IsRegular(info.Mode) {
+		log.LogErrorf("CopyFile: not regular, src_path(%s) src_ino(%v) mode(%v)\n", fullSrcPath, src_ino, proto.OsMode(info.Mode))
+		return statusEPERM
+	}
+	if !proto.IsHot(c.volType) {
+		return errorToStatus(syscall.EOPNOTSUPP)
+	}
+	// the data written by the opened files of the source is cloned as well
+	if c.ec.GetStreamer(src_ino) != nil {
+		if err = c.ec.Flush(src_ino); err != nil {
+			return statusEIO
+		}
+	}
+
+	parent_dir := path.Dir(fullDstPath)
+	filename := path.Base(fullDstPath)
+	info, err = c.lookupPath(parent_dir)
+	if err != nil {
+		return errorToStatus(err)
+	}
+	parentIno := info.Inode
+
+	info, err = c.mw.CopyFile_ll(src_ino, parentIno, filename, 0, 0, fullDstPath)
+	if err != nil {
+		log.LogErrorf("CopyFile: src_path(%s) src_ino(%v) dst_path(%s) parent(%v) err(%v)\n", fullSrcPath, src_ino, fullDstPath, parentIno, err)
+		return errorToStatus(err)
+	}
+
+	c.ic.Put(info)
+	c.ic.Delete(parentIno)
+	c.ec.ForceRefreshExtentsCache(src_ino)
+	log.LogDebugf("CopyFile: src_path(%s) src_ino(%v) dst_path(%s) dst_ino(%v) parent(%v)\n", fullSrcPath, src_ino, fullDstPath, info.Inode, parentIno)
+
+	return statusOK
+}
+
 //export cfs_get_dir_lock
 func cfs_get_dir_lock(id C.int64_t, path *C.char, lock_id *C.int64_t, valid_time **C.char) C.int {
 	c, exist := getClient(int64(id))
@@ -639,6 +709,12 @@ func cfs_set_client(id C.int64_t, key, val *C.char) C.int {
 		} else {
 			c.enableInnerReq = false
 		}
+	case "enableSnapshotDir":
+		if v == "true" {
+			c.enableSnapshotDir = true
+		} else {
+			c.enableSnapshotDir = false
+		}
 	default:
 		return statusEINVAL
 	}
@@ -663,6 +739,7 @@ func cfs_start_client(id C.int64_t) C.int {
 //export cfs_close_client
 func cfs_close_client(id C.int64_t) {
 	if c, exist := getClient(int64(id)); exist {
+		c.closeSnapshots()
 		if c.ec != nil {
 			_ = c.ec.Close()
 		}
@@ -682,7 +759,7 @@ func cfs_chdir(id C.int64_t, path *C.char) C.int {
 		return statusEINVAL
 	}
 	cwd := c.absPath(C.GoString(path))
-	dirInfo, err := c.lookupPath(cwd)
+	_, dirInfo, err := c.lookupSnapshotPath(cwd)
 	if err != nil {
 		return errorToStatus(err)
 	}
@@ -709,7 +786,7 @@ func cfs_getattr(id C.int64_t, path *C.char, stat *C.struct_cfs_stat_info) C.int
 		return statusEINVAL
 	}
 
-	info, err := c.lookupPath(c.absPath(C.GoString(path)))
+	_, info, err := c.lookupSnapshotPath(c.absPath(C.GoString(path)))
 	if err != nil {
 		return errorToStatus(err)
 	}
@@ -788,6 +865,13 @@ func cfs_open(id C.int64_t, path *C.char, flags C.int, mode C.mode_t) C.int {
 	accFlags := fuseFlags & uint32(C.O_ACCMODE)
 
 	absPath := c.absPath(C.GoString(path))
+	if _, _, ok := c.splitSnapshotPath(absPath); ok {
+		f, err := c.openSnapshot(absPath, fuseFlags, fuseMode)
+		if err != nil {
+			return errorToStatus(err)
+		}
+		return C.int(f.fd)
+	}
 
 	var info *proto.InodeInfo
 	var parentIno uint64
@@ -844,7 +928,7 @@ func cfs_open(id C.int64_t, path *C.char, flags C.int, mode C.mode_t) C.int {
 		fileCachePattern := fmt.Sprintf(".*%s.*", c.cacheRuleKey)
 		fileCache, _ = regexp.MatchString(fileCachePattern, absPath)
 	}
-	f := c.allocFD(info.Inode, fuseFlags, fuseMode, fileCache, info.Size, parentIno, absPath, info.StorageClass)
+	f := c.allocFD(info.Inode, fuseFlags, fuseMode, fileCache, info.Size, parentIno, absPath, info.StorageClass, nil)
 	if f == nil {
 		return statusEMFILE
 	}
@@ -880,11 +964,12 @@ func cfs_flush(id C.int64_t, fd C.int) C.int {
 		return statusEBADFD
 	}
 
-	err := c.flush(f)
+	fc := c.fileClient(f)
+	err := fc.flush(f)
 	if err != nil {
 		return statusEIO
 	}
-	c.ic.Delete(f.ino)
+	fc.ic.Delete(f.ino)
 	return statusOK
 }
 
@@ -900,16 +985,22 @@ func cfs_close(id C.int64_t, fd C.int) {
 		return
 	}
 
-	info := c.ic.Get(f.ino)
+	if isSnapshotDirFile(f) {
+		c.releaseFD(uint(fd))
+		return
+	}
+
+	fc := c.fileClient(f)
+	info := fc.ic.Get(f.ino)
 	if info == nil {
-		info, _ = c.mw.InodeGet_ll(f.ino)
+		info, _ = fc.mw.InodeGet_ll(f.ino)
 	}
 
 	f = c.releaseFD(uint(fd))
 	// Consistent with cfs open, do close and closeStream only if f is regular file
 	if f != nil && info != nil && proto.IsRegular(info.Mode) {
-		c.flush(f)
-		c.closeStream(f)
+		fc.flush(f)
+		fc.closeStream(f)
 	}
 }
 
@@ -923,12 +1014,41 @@ func cfs_truncate(id C.int64_t, fd C.int, size C.size_t) C.int {
 	if f == nil {
 		return statusEBADFD
 	}
+	if f.snap != nil || isSnapshotDirFile(f) {
+		return errorToStatus(syscall.EROFS)
+	}
 	if err := c.truncate(f, int(size)); err != nil {
 		return statusEIO
 	}
 	return statusOK
 }
 
+//export cfs_fallocate
+func cfs_fallocate(id C.int64_t, fd C.int, mode C.int, off C.off_t, length C.off_t) C.int {
+	c, exist := getClient(int64(id))
+	if !exist {
+		return statusEINVAL
+	}
+	f := c.getFile(uint(fd))
+	if f == nil {
+		return statusEBADFD
+	}
+	accFlags := f.flags & uint32(C.O_ACCMODE)
+	if accFlags != uint32(C.O_WRONLY) && accFlags != uint32(C.O_RDWR) {
+		return statusEBADFD
+	}
+	if off < 0 || length <= 0 {
+		return statusEINVAL
+	}
+	if !proto.IsValidFallocateMode(uint32(mode)) || !proto.IsHot(c.volType) || !proto.IsStorageClassReplica(f.storageClass) {
+		return errorToStatus(syscall.EOPNOTSUPP)
+	}
+	if err := c.ec.Fallocate(f.ino, uint32(mode), uint64(off), uint64(length), f.storageClass, f.path); err != nil {
+		return errorToStatus(err)
+	}
+	return statusOK
+}
+
 //export cfs_write
 func cfs_write(id C.int64_t, fd C.int, buf unsafe.Pointer, size C.size_t, off C.off_t) C.ssize_t {
 	c, exist := getClient(int64(id))
@@ -1007,7 +1127,7 @@ func cfs_read(id C.int64_t, fd C.int, buf unsafe.Pointer, size C.size_t, off C.o
 	hdr.Len = int(size)
 	hdr.Cap = int(size)
 
-	n, err := c.read(f, int(off), buffer)
+	n, err := c.fileClient(f).read(f, int(off), buffer)
 	if err != nil {
 		return C.ssize_t(statusEIO)
 	}
@@ -1034,7 +1154,7 @@ func cfs_batch_get_inodes(id C.int64_t, fd C.int, iids unsafe.Pointer, stats []C
 	hdr.Len = int(count)
 	hdr.Cap = int(count)
 
-	infos := c.mw.BatchInodeGet(inodeIDS)
+	infos := c.fileClient(f).mw.BatchInodeGet(inodeIDS)
 	if len(infos) > int(count) {
 		return statusEINVAL
 	}
@@ -1120,7 +1240,7 @@ func cfs_readdir(id C.int64_t, fd C.int, dirents []C.struct_cfs_dirent, count C.
 
 	if f.dirp == nil {
 		f.dirp = &dirStream{}
-		dentries, err := c.mw.ReadDir_ll(f.ino)
+		dentries, err := c.fileClient(f).mw.ReadDir_ll(f.ino)
 		if err != nil {
 			return errorToStatus(err)
 		}
@@ -1174,7 +1294,7 @@ func cfs_lsdir(id C.int64_t, fd C.int, direntsInfo []C.struct_cfs_dirent_info, c
 
 	if f.dirp == nil {
 		f.dirp = &dirStream{}
-		dentries, err := c.mw.ReadDir_ll(f.ino)
+		dentries, err := c.fileClient(f).mw.ReadDir_ll(f.ino)
 		if err != nil {
 			return errorToStatus(err)
 		}
@@ -1214,7 +1334,14 @@ func cfs_lsdir(id C.int64_t, fd C.int, direntsInfo []C.struct_cfs_dirent_info, c
 	if n == 0 {
 		return n
 	}
-	infos := c.mw.BatchInodeGet(inodeIDS)
+	if isSnapshotDirFile(f) {
+		// the snapshots share the same root inode, so only the mode is filled
+		for i := C.int(0); i < n; i++ {
+			direntsInfo[i].stat.mode = C.uint32_t(C.S_IFDIR) | C.uint32_t(0o555)
+		}
+		return n
+	}
+	infos := c.fileClient(f).mw.BatchInodeGet(inodeIDS)
 	if len(infos) != int(n) {
 		return statusEIO
 	}
@@ -1436,6 +1563,10 @@ func cfs_fchmod(id C.int64_t, fd C.int, mode C.mode_t) C.int {
 		return statusEBADFD
 	}
 
+	if f.snap != nil || isSnapshotDirFile(f) {
+		return errorToStatus(syscall.EROFS)
+	}
+
 	info, err := c.mw.InodeGet_ll(f.ino)
 	if err != nil {
 		return errorToStatus(err)
@@ -1509,6 +1640,7 @@ func (c *client) absPath(path string) string {
 
 func (c *client) start() (err error) {
 	masters := strings.Split(c.masterAddr, ",")
+	c.startTime = time.Now()
 	if c.logDir != "" {
 		if c.logLevel == "" {
 			c.logLevel = "WARN"
@@ -1564,6 +1696,15 @@ func (c *client) start() (err error) {
 			return
 		}
 	}
+	if err = c.newWrappers(masters); err != nil {
+		return
+	}
+	c.ebsc = ebsc
+	return nil
+}
+
+// newWrappers creates the meta wrapper and the extent client of the client.
+func (c *client) newWrappers(masters []string) (err error) {
 	var mw *meta.MetaWrapper
 	if mw, err = meta.NewMetaWrapper(&meta.MetaConfig{
 		Volume:        c.volName,
@@ -1583,7 +1724,11 @@ func (c *client) start() (err error) {
 		OnAppendExtentKey:           mw.AppendExtentKey,
 		OnSplitExtentKey:            mw.SplitExtentKey,
 		OnGetExtents:                mw.GetExtents,
+		OnGetInlineExtents:          mw.GetInlineExtents,
+		OnWriteInline:               mw.WriteInline,
 		OnTruncate:                  mw.Truncate,
+		OnFallocate:                 mw.Fallocate,
+		OnIsSharedExtent:            mw.IsSharedExtent,
 		BcacheEnable:                c.enableBcache,
 		OnLoadBcache:                c.bc.Get,
 		OnCacheBcache:               c.bc.Put,
@@ -1601,7 +1746,6 @@ func (c *client) start() (err error) {
 
 	c.mw = mw
 	c.ec = ec
-	c.ebsc = ebsc
 	return nil
 }
 
@@ -1639,7 +1783,7 @@ func (c *client) checkPermission() (err error) {
 	return
 }
 
-func (c *client) allocFD(ino uint64, flags, mode uint32, fileCache bool, fileSize uint64, parentInode uint64, path string, storageClass uint32) *file {
+func (c *client) allocFD(ino uint64, flags, mode uint32, fileCache bool, fileSize uint64, parentInode uint64, path string, storageClass uint32, snap *client) *file {
 	c.fdlock.Lock()
 	defer c.fdlock.Unlock()
 	fd, ok := c.fdset.NextClear(0)
@@ -1647,19 +1791,20 @@ func (c *client) allocFD(ino uint64, flags, mode uint32, fileCache bool, fileSiz
 		return nil
 	}
 	c.fdset.Set(fd)
-	f := &file{fd: fd, ino: ino, flags: flags, mode: mode, pino: parentInode, path: path, storageClass: storageClass}
+	f := &file{fd: fd, ino: ino, flags: flags, mode: mode, pino: parentInode, path: path, storageClass: storageClass, snap: snap}
 	if flags&0x0f != syscall.O_RDONLY {
 		f.openForWrite = true
 	}
+	fc := c.fileClient(f)
 	if proto.IsCold(c.volType) || proto.IsStorageClassBlobStore(storageClass) {
 		clientConf := blobstore.ClientConfig{
 			VolName:         c.volName,
 			VolType:         c.volType,
 			BlockSize:       c.ebsBlockSize,
 			Ino:             ino,
-			Bc:              c.bc,
-			Mw:              c.mw,
-			Ec:              c.ec,
+			Bc:              fc.bc,
+			Mw:              fc.mw,
+			Ec:              fc.ec,
 			Ebsc:            c.ebsc,
 			EnableBcache:    c.enableBcache,
 			WConcurrency:    c.writeBlockThread,
@@ -0,0 +1,238 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/cubefs/cubefs/client/fs"
+	"github.com/cubefs/cubefs/proto"
+	masterSDK "github.com/cubefs/cubefs/sdk/master"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// splitSnapshotPath splits the absolute path in the snapshot directory into the snapshot name
+// and the path inside the snapshot, the name is empty for the snapshot directory itself.
+func (c *client) splitSnapshotPath(path string) (name, subPath string, ok bool) {
+	if !c.enableSnapshotDir || c.snapshot != nil {
+		return
+	}
+	dir := "/" + proto.SnapshotDirName
+	if path == dir {
+		return "", "", true
+	}
+	if !strings.HasPrefix(path, dir+"/") {
+		return
+	}
+	name = path[len(dir)+1:]
+	subPath = "/"
+	if idx := strings.IndexByte(name, '/'); idx >= 0 {
+		name, subPath = name[:idx], name[idx:]
+	}
+	return name, subPath, true
+}
+
+// lookupSnapshotPath looks up the path which may be in the snapshot directory, it returns the
+// client which the inode belongs to, or nil for the snapshot directory itself.
+func (c *client) lookupSnapshotPath(path string) (sc *client, info *proto.InodeInfo, err error) {
+	name, subPath, ok := c.splitSnapshotPath(path)
+	if !ok {
+		info, err = c.lookupPath(path)
+		return c, info, err
+	}
+	if name == "" {
+		return nil, c.snapshotDirInfo(), nil
+	}
+	if sc, err = c.getSnapshotClient(name); err != nil {
+		return nil, nil, err
+	}
+	info, err = sc.lookupPath(subPath)
+	return sc, info, err
+}
+
+func (c *client) snapshotDirInfo() *proto.InodeInfo {
+	return &proto.InodeInfo{
+		Inode:      fs.SnapshotDirInode,
+		Mode:       proto.Mode(os.ModeDir | 0o555),
+		Nlink:      2,
+		AccessTime: c.startTime,
+		ModifyTime: c.startTime,
+		CreateTime: c.startTime,
+	}
+}
+
+// listSnapshots returns the snapshots of the volume and closes the clients of the ones deleted.
+func (c *client) listSnapshots() ([]*proto.VolSnapshot, error) {
+	mc := masterSDK.NewMasterClientFromString(c.masterAddr, false)
+	verList, err := mc.AdminAPI().GetVerList(c.volName)
+	if err != nil {
+		log.LogErrorf("listSnapshots: vol(%v) err(%v)", c.volName, err)
+		return nil, err
+	}
+	snapshots := verList.GetSnapshots()
+
+	valid := make(map[uint64]bool, len(snapshots))
+	for _, snapshot := range snapshots {
+		valid[snapshot.Ver] = true
+	}
+	c.snapMu.Lock()
+	for ver, sc := range c.snapshots {
+		if !valid[ver] {
+			delete(c.snapshots, ver)
+			sc.closeSnapshot()
+			log.LogInfof("listSnapshots: vol(%v) snapshot ver(%v) is deleted, release it", c.volName, ver)
+		}
+	}
+	c.snapMu.Unlock()
+	return snapshots, nil
+}
+
+// readSnapshotDir returns the dentries of the snapshot directory.
+func (c *client) readSnapshotDir() ([]proto.Dentry, error) {
+	snapshots, err := c.listSnapshots()
+	if err != nil {
+		return nil, err
+	}
+	dentries := make([]proto.Dentry, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		dentries = append(dentries, proto.Dentry{
+			Name:  snapshot.Name,
+			Inode: proto.RootIno,
+			Type:  proto.Mode(os.ModeDir | 0o555),
+		})
+	}
+	return dentries, nil
+}
+
+// getSnapshotClient returns the read-only client of the snapshot, which is created on the first
+// access.
+func (c *client) getSnapshotClient(name string) (*client, error) {
+	c.snapMu.Lock()
+	for _, sc := range c.snapshots {
+		if proto.FindSnapshot([]*proto.VolSnapshot{sc.snapshot}, name) != nil {
+			c.snapMu.Unlock()
+			return sc, nil
+		}
+	}
+	c.snapMu.Unlock()
+
+	snapshots, err := c.listSnapshots()
+	if err != nil {
+		return nil, err
+	}
+	snapshot := proto.FindSnapshot(snapshots, name)
+	if snapshot == nil {
+		return nil, syscall.ENOENT
+	}
+
+	c.snapMu.Lock()
+	defer c.snapMu.Unlock()
+	if sc, ok := c.snapshots[snapshot.Ver]; ok {
+		return sc, nil
+	}
+	sc := &client{
+		id:                     c.id,
+		volName:                c.volName,
+		masterAddr:             c.masterAddr,
+		followerRead:           c.followerRead,
+		volType:                c.volType,
+		cacheAction:            c.cacheAction,
+		ebsBlockSize:           c.ebsBlockSize,
+		readBlockThread:        c.readBlockThread,
+		writeBlockThread:       c.writeBlockThread,
+		cacheRuleKey:           c.cacheRuleKey,
+		cacheThreshold:         c.cacheThreshold,
+		cluster:                c.cluster,
+		volStorageClass:        c.volStorageClass,
+		volAllowedStorageClass: c.volAllowedStorageClass,
+		cacheDpStorageClass:    c.cacheDpStorageClass,
+		enableInnerReq:         c.enableInnerReq,
+		cwd:                    "/",
+		ic:                     fs.NewInodeCache(fs.DefaultInodeExpiration, fs.MaxInodeCache),
+		dc:                     fs.NewDentryCache(),
+		ebsc:                   c.ebsc,
+		snapshot:               snapshot,
+		startTime:              c.startTime,
+	}
+	if err = sc.newWrappers(strings.Split(c.masterAddr, ",")); err != nil {
+		log.LogErrorf("getSnapshotClient: vol(%v) snapshot(%v) ver(%v) err(%v)", c.volName, snapshot.Name, snapshot.Ver, err)
+		return nil, err
+	}
+	// the metadata is read as it was right before the next version was created
+	sc.mw.VerReadSeq = snapshot.ReadSeq
+	c.snapshots[snapshot.Ver] = sc
+	log.LogInfof("getSnapshotClient: vol(%v) snapshot(%v) ver(%v) readSeq(%v) loaded", c.volName, snapshot.Name, snapshot.Ver, snapshot.ReadSeq)
+	return sc, nil
+}
+
+// openSnapshot opens the file or directory in the snapshot directory, which is read only.
+func (c *client) openSnapshot(path string, flags, mode uint32) (*file, error) {
+	if flags&syscall.O_ACCMODE != syscall.O_RDONLY || flags&(syscall.O_CREAT|syscall.O_TRUNC) != 0 {
+		return nil, syscall.EROFS
+	}
+	sc, info, err := c.lookupSnapshotPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if sc == nil {
+		dentries, err := c.readSnapshotDir()
+		if err != nil {
+			return nil, err
+		}
+		f := c.allocFD(info.Inode, flags, mode, false, 0, info.Inode, path, c.volStorageClass, nil)
+		if f == nil {
+			return nil, syscall.EMFILE
+		}
+		f.dirp = &dirStream{dirents: dentries}
+		return f, nil
+	}
+
+	f := c.allocFD(info.Inode, flags, mode, false, info.Size, 0, path, info.StorageClass, sc)
+	if f == nil {
+		return nil, syscall.EMFILE
+	}
+	if proto.IsRegular(info.Mode) {
+		sc.openStream(f)
+	}
+	return f, nil
+}
+
+// fileClient returns the client which the file is opened in.
+func (c *client) fileClient(f *file) *client {
+	if f.snap != nil {
+		return f.snap
+	}
+	return c
+}
+
+func (c *client) closeSnapshots() {
+	c.snapMu.Lock()
+	defer c.snapMu.Unlock()
+	for ver, sc := range c.snapshots {
+		delete(c.snapshots, ver)
+		sc.closeSnapshot()
+	}
+}
+
+func (c *client) closeSnapshot() {
+	_ = c.ec.Close()
+	_ = c.mw.Close()
+}
+
+func isSnapshotDirFile(f *file) bool {
+	return f.snap == nil && f.ino == fs.SnapshotDirInode
+}
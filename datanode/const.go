@@ -75,6 +75,11 @@ const (
 	ActionRecoverBadDisk              = "ActionRecoverBadDisk"
 	ActionQueryBadDiskRecoverProgress = "ActionQueryBadDiskRecoverProgress"
 	ActionDeleteBackupDirectories     = "ActionDeleteBackupDirectories"
+	ActionRepairExtentBlocks          = "ActionRepairExtentBlocks"
+	ActionScrubExtentBlocks           = "ActionScrubExtentBlocks"
+	ActionMarkSharedExtents           = "ActionMarkSharedExtents"
+	ActionReleaseSharedExtents        = "ActionReleaseSharedExtents"
+	ActionReleaseCloneHolds           = "ActionReleaseCloneHolds"
 )
 
 // Apply the raft log operation. Currently we only have the random write operation.
@@ -0,0 +1,146 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datanode
+
+import (
+	"fmt"
+	"hash/crc32"
+	"net"
+
+	"github.com/cubefs/cubefs/datanode/repl"
+	"github.com/cubefs/cubefs/datanode/storage"
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/errors"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// repairExtentBlocks checks the blocks of the normal extent overlapped by the range against the crc
+// stored with them, and rewrites the blocks failing the check by the data read from the other
+// replicas, which is accepted only if it matches the crc. If expectedCrcs is not empty,
+// expectedCrcs[i] replaces the stored crc of the i-th block from offset. It returns the number of
+// the blocks rewritten.
+func (dp *DataPartition) repairExtentBlocks(extentID uint64, offset, size int64, expectedCrcs []uint32) (repaired int, err error) {
+	store := dp.ExtentStore()
+	if storage.IsTinyExtent(extentID) || !store.HasExtent(extentID) || store.IsDeletedNormalExtent(extentID) {
+		return
+	}
+	ei, err := store.Watermark(extentID)
+	if err != nil {
+		return
+	}
+	end := (offset + size + util.BlockSize - 1) / util.BlockSize * util.BlockSize
+	if end > int64(ei.Size) {
+		end = int64(ei.Size)
+	}
+	data := make([]byte, util.BlockSize)
+	start := offset / util.BlockSize * util.BlockSize
+	for blockOffset := start; blockOffset+util.BlockSize <= end; blockOffset += util.BlockSize {
+		var (
+			ok       bool
+			expected uint32
+		)
+		if i := int((blockOffset - start) / util.BlockSize); i < len(expectedCrcs) {
+			expected = expectedCrcs[i]
+		}
+		if ok, err = dp.repairExtentBlock(extentID, blockOffset, expected, data); err != nil {
+			return
+		}
+		if ok {
+			repaired++
+		}
+	}
+	return
+}
+
+func (dp *DataPartition) repairExtentBlock(extentID uint64, blockOffset int64, expected uint32, data []byte) (repaired bool, err error) {
+	store := dp.ExtentStore()
+	// the crc persisted is checked again before the block is rewritten, as the random writes are
+	// applied meanwhile
+	crcs, err := store.BlockCrcs(extentID, blockOffset, util.BlockSize)
+	if err != nil || len(crcs) == 0 {
+		return
+	}
+	stored := crcs[0]
+	if expected != 0 {
+		crcs[0] = expected
+	} else if stored == 0 {
+		return
+	}
+	if _, err = store.Read(extentID, blockOffset, util.BlockSize, data, true, false); err != nil {
+		return
+	}
+	if err = proto.VerifyBlockCrcs(blockOffset, data, crcs); err == nil {
+		return
+	}
+	log.LogWarnf("action[repairExtentBlock] dp %v extent %v offset %v err %v, repair it from the other replicas",
+		dp.partitionID, extentID, blockOffset, err)
+
+	for _, host := range dp.getReplicaCopy() {
+		if host == dp.dataNode.localServerAddr {
+			continue
+		}
+		if err = dp.readExtentBlockFromReplica(host, extentID, blockOffset, data); err != nil {
+			log.LogWarnf("action[repairExtentBlock] dp %v extent %v offset %v read from %v err %v",
+				dp.partitionID, extentID, blockOffset, host, err)
+			continue
+		}
+		if err = proto.VerifyBlockCrcs(blockOffset, data, crcs); err != nil {
+			log.LogWarnf("action[repairExtentBlock] dp %v extent %v offset %v replica %v err %v",
+				dp.partitionID, extentID, blockOffset, host, err)
+			continue
+		}
+		if repaired, err = store.RepairBlock(extentID, blockOffset, data, stored, crcs[0]); err != nil || !repaired {
+			return
+		}
+		log.LogWarnf("action[repairExtentBlock] dp %v extent %v offset %v repaired from %v",
+			dp.partitionID, extentID, blockOffset, host)
+		return true, nil
+	}
+	err = fmt.Errorf("dp %v extent %v offset %v no replica has the data matching the crc", dp.partitionID, extentID, blockOffset)
+	return
+}
+
+func (dp *DataPartition) readExtentBlockFromReplica(host string, extentID uint64, blockOffset int64, data []byte) (err error) {
+	var conn net.Conn
+	if conn, err = dp.getRepairConn(host); err != nil {
+		return
+	}
+	defer func() {
+		dp.putRepairConn(conn, err != nil || dp.enableSmux())
+	}()
+	request := repl.NewBlockCrcReadPacket(dp.partitionID, extentID, int(blockOffset), len(data))
+	if err = request.WriteToConn(conn); err != nil {
+		return
+	}
+	for readSize := 0; readSize < len(data); {
+		reply := repl.NewPacket()
+		if err = reply.ReadFromConnWithVer(conn, proto.ReadDeadlineTime); err != nil {
+			return
+		}
+		if reply.ResultCode != proto.OpOk {
+			return errors.NewErrorf("reply %v", reply.GetResultMsg())
+		}
+		if reply.ReqID != request.GetReqID() || reply.ExtentOffset != blockOffset+int64(readSize) ||
+			readSize+int(reply.Size) > len(data) {
+			return errors.NewErrorf("invalid reply %v", reply.GetUniqueLogId())
+		}
+		if crc := crc32.ChecksumIEEE(reply.Data[:reply.Size]); crc != reply.CRC {
+			return errors.NewErrorf("reply crc mismatch, expected(%v) actual(%v)", reply.CRC, crc)
+		}
+		readSize += copy(data[readSize:], reply.Data[:reply.Size])
+	}
+	return
+}
@@ -45,6 +45,7 @@ type DataPartitionRepairTask struct {
 	ExtentsToBeRepaired            []*storage.ExtentInfo
 	LeaderTinyDeleteRecordFileSize int64
 	LeaderAddr                     string
+	SharedExtents                  []uint64 // the shared extents of the leader, marked before the repair
 }
 
 func NewDataPartitionRepairTask(extentFiles []*storage.ExtentInfo, tinyDeleteRecordFileSize int64, source, leaderAddr string, extentType uint8) (task *DataPartitionRepairTask) {
@@ -170,6 +171,11 @@ func (dp *DataPartition) buildDataPartitionRepairTask(repairTasks []*DataPartiti
 	if !found {
 		return errors.NewErrorf("cannot found %v in replica %v, stop repairing", dp.dataNode.localServerAddr, replica)
 	}
+	// the replica repaired or created by the migration may miss the marks applied by raft
+	var sharedExtents []uint64
+	if proto.IsNormalExtentType(extentType) {
+		sharedExtents = dp.extentStore.GetSharedExtents()
+	}
 	// new repair tasks for the followers
 	for index := 0; index < len(followers); index++ {
 		extents, err := dp.getRemoteExtentInfo(extentType, tinyExtents, followers[index])
@@ -180,6 +186,7 @@ func (dp *DataPartition) buildDataPartitionRepairTask(repairTasks []*DataPartiti
 		log.LogInfof("buildDataPartitionRepairTask dp %v,add new add %v,  extent type %v", dp.partitionID, followers[index], extentType)
 		repairTasks[index+1] = NewDataPartitionRepairTask(extents, leaderTinyDeleteRecordFileSize, followers[index], dp.dataNode.localServerAddr, extentType)
 		repairTasks[index+1].addr = followers[index]
+		repairTasks[index+1].SharedExtents = sharedExtents
 	}
 
 	return
@@ -581,6 +588,14 @@ func (dp *DataPartition) NormalExtentRepairRead(p repl.PacketInterface, connect
 	store := dp.ExtentStore()
 
 	log.LogDebugf("extentRepairReadPacket dp %v offset %v needSize %v", dp.partitionID, offset, needReplySize)
+	if p.GetExtentType()&proto.BlockCrcFlag != 0 {
+		// the blocks the request covers in part are not verified by the reader
+		if err = store.VerifyPartialBlocks(p.GetExtentID(), offset, int64(needReplySize)); err != nil {
+			log.LogErrorf("action[operatePacket] dp %v extent %v offset %v size %v err %v",
+				dp.partitionID, p.GetExtentID(), offset, needReplySize, err)
+			return
+		}
+	}
 	for {
 		if needReplySize <= 0 {
 			break
@@ -589,6 +604,13 @@ func (dp *DataPartition) NormalExtentRepairRead(p repl.PacketInterface, connect
 		reply := makeRspPacket(p.GetReqID(), p.GetPartitionID(), p.GetExtentID())
 		reply.SetStartT(p.GetStartT())
 		currReadSize := uint32(util.Min(int(needReplySize), int(dp.GetRepairBlockSize())))
+		if p.GetExtentType()&proto.BlockCrcFlag != 0 && currReadSize < needReplySize {
+			// end the reply at the boundary of the block, so that each block the request covers in
+			// whole is in one reply and verified by its crc
+			if end := (offset + int64(currReadSize)) / util.BlockSize * util.BlockSize; end > offset {
+				currReadSize = uint32(end - offset)
+			}
+		}
 		if currReadSize == util.RepairReadBlockSize {
 			var data []byte
 			data, err = proto.Buffers.Get(util.RepairReadBlockSize)
@@ -635,6 +657,17 @@ func (dp *DataPartition) NormalExtentRepairRead(p repl.PacketInterface, connect
 			log.LogErrorf("action[operatePacket] err %v", err)
 			return
 		}
+		if p.GetExtentType()&proto.BlockCrcFlag != 0 {
+			var crcs []uint32
+			if crcs, err = store.BlockCrcs(reply.GetExtentID(), offset, int64(currReadSize)); err != nil {
+				log.LogErrorf("action[operatePacket] dp %v extent %v get block crc err %v", dp.partitionID, reply.GetExtentID(), err)
+				return
+			}
+			if len(crcs) > 0 {
+				reply.SetArg(proto.MarshalBlockCrcs(crcs))
+				reply.SetArglen(uint32(len(reply.GetArg())))
+			}
+		}
 		reply.SetSize(currReadSize)
 		reply.SetResultCode(proto.OpOk)
 		reply.SetOpCode(p.GetOpcode())
@@ -95,6 +95,10 @@ func (p *repairWorker) GetArgLen() uint32 {
 	return p.ArgLen
 }
 
+func (p *repairWorker) GetExtentType() uint8 {
+	return p.ExtentType
+}
+
 func (p *repairWorker) GetData() []byte {
 	return p.Data
 }
@@ -0,0 +1,503 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datanode
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/datanode/repl"
+	"github.com/cubefs/cubefs/datanode/storage"
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/errors"
+	"github.com/cubefs/cubefs/util/exporter"
+	"github.com/cubefs/cubefs/util/log"
+	"golang.org/x/time/rate"
+)
+
+const (
+	DefaultScrubBytesPerSec  = 16 * util.MB
+	DefaultScrubIntervalHour = 24 * 7
+)
+
+const (
+	scrubStartDelay    = 10 * time.Minute
+	scrubRetryInterval = time.Minute
+	scrubBatchSize     = 32 * util.BlockSize
+	scrubDeadlineTime  = 60
+	maxScrubFindings   = 128
+	// the extents modified recently may be under writing, leave them to the next round
+	scrubSkipModifiedSeconds = 10 * 60
+)
+
+// dataScrubber re-reads the normal extents of the data partitions on a disk at a limited rate,
+// compares the block crcs computed from disk among the replicas, and repairs the replicas diverging
+// from the majority. Only the partitions led by this node are scrubbed, the other replicas just
+// compute the crcs asked by the leader.
+type dataScrubber struct {
+	sync.RWMutex
+	disk       *Disk
+	limiter    *rate.Limiter
+	info       proto.DiskScrubInfo
+	partitions map[uint64]*proto.DataPartitionScrubInfo
+}
+
+func newDataScrubber(d *Disk) *dataScrubber {
+	bytesPerSec := d.dataNode.scrubBytesPerSec
+	limiter := rate.NewLimiter(rate.Inf, 0)
+	if bytesPerSec > 0 {
+		burst := bytesPerSec
+		if burst < util.BlockSize {
+			burst = util.BlockSize
+		}
+		limiter = rate.NewLimiter(rate.Limit(bytesPerSec), burst)
+	}
+	return &dataScrubber{
+		disk:       d,
+		limiter:    limiter,
+		info:       proto.DiskScrubInfo{Disk: d.Path, Status: proto.ScrubStatusIdle, BytesPerSec: bytesPerSec},
+		partitions: make(map[uint64]*proto.DataPartitionScrubInfo),
+	}
+}
+
+func (d *Disk) startScheduleToScrub() {
+	go func() {
+		timer := time.NewTimer(scrubStartDelay)
+		defer timer.Stop()
+		for range timer.C {
+			if !d.dataNode.enableScrub || !d.dataNode.HasStarted() || d.Status == proto.Unavailable {
+				timer.Reset(scrubRetryInterval)
+				continue
+			}
+			d.scrubber.scrubRound()
+			timer.Reset(d.dataNode.scrubInterval)
+		}
+	}()
+}
+
+func (sc *dataScrubber) wait(size int) {
+	if err := sc.limiter.WaitN(context.Background(), size); err != nil {
+		log.LogWarnf("action[scrub] disk %v wait %v bytes err %v", sc.disk.Path, size, err)
+	}
+}
+
+func (sc *dataScrubber) scrubRound() {
+	partitionIDs := sc.disk.DataPartitionList()
+	begin := time.Now()
+	log.LogInfof("action[scrubRound] disk %v begin to scrub %v partitions", sc.disk.Path, len(partitionIDs))
+
+	sc.Lock()
+	sc.info.Status = proto.ScrubStatusRunning
+	sc.info.LastStartTime = begin.Unix()
+	sc.info.TotalPartitions = len(partitionIDs)
+	sc.info.ScannedPartitions = 0
+	sc.info.ScannedBytes = 0
+	sc.info.MismatchBlocks = 0
+	sc.info.RepairedBlocks = 0
+	for id := range sc.partitions {
+		if sc.disk.GetDataPartition(id) == nil {
+			delete(sc.partitions, id)
+		}
+	}
+	sc.Unlock()
+
+	for _, id := range partitionIDs {
+		if dp := sc.disk.GetDataPartition(id); dp != nil {
+			sc.Lock()
+			sc.info.CurrentPartition = id
+			sc.Unlock()
+			sc.scrubPartition(dp)
+		}
+		sc.Lock()
+		sc.info.ScannedPartitions++
+		sc.Unlock()
+	}
+
+	sc.Lock()
+	sc.info.Status = proto.ScrubStatusIdle
+	sc.info.Rounds++
+	sc.info.LastFinishTime = time.Now().Unix()
+	sc.info.CurrentPartition = 0
+	info := sc.info
+	sc.Unlock()
+	log.LogInfof("action[scrubRound] disk %v scrubbed %v bytes, mismatch blocks %v, repaired blocks %v, cost %v",
+		sc.disk.Path, info.ScannedBytes, info.MismatchBlocks, info.RepairedBlocks, time.Since(begin))
+}
+
+func (sc *dataScrubber) scrubPartition(dp *DataPartition) {
+	if !dp.isNormalType() || dp.Status() == proto.Unavailable {
+		return
+	}
+	if _, isLeader := dp.IsRaftLeader(); !isLeader {
+		return
+	}
+	replicas := dp.getReplicaCopy()
+	if len(replicas) < 2 {
+		return
+	}
+	extents, _, err := dp.ExtentStore().GetAllWatermarks(storage.NormalExtentFilter())
+	if err != nil {
+		log.LogWarnf("action[scrubPartition] dp %v get extents err %v", dp.partitionID, err)
+		return
+	}
+
+	sc.Lock()
+	info, ok := sc.partitions[dp.partitionID]
+	if !ok {
+		info = &proto.DataPartitionScrubInfo{PartitionId: dp.partitionID, Disk: sc.disk.Path}
+		sc.partitions[dp.partitionID] = info
+	}
+	info.Status = proto.ScrubStatusRunning
+	info.LastStartTime = time.Now().Unix()
+	info.TotalExtents = len(extents)
+	info.ScannedExtents = 0
+	info.ScannedBytes = 0
+	info.MismatchBlocks = 0
+	info.RepairedBlocks = 0
+	sc.Unlock()
+	defer func() {
+		sc.Lock()
+		info.Status = proto.ScrubStatusIdle
+		info.Rounds++
+		info.LastFinishTime = time.Now().Unix()
+		sc.Unlock()
+	}()
+
+	for _, ei := range extents {
+		if _, isLeader := dp.IsRaftLeader(); !isLeader {
+			log.LogInfof("action[scrubPartition] dp %v is not the leader any more, stop scrubbing", dp.partitionID)
+			return
+		}
+		if !ei.IsDeleted && time.Now().Unix()-ei.ModifyTime >= scrubSkipModifiedSeconds {
+			if err = sc.scrubExtent(dp, replicas, ei); err != nil {
+				log.LogWarnf("action[scrubPartition] dp %v extent %v err %v", dp.partitionID, ei.FileID, err)
+			}
+		}
+		sc.Lock()
+		info.ScannedExtents++
+		sc.Unlock()
+	}
+}
+
+// scrubExtent compares the crcs of the full blocks of the extent among the replicas. A divergent
+// block is checked once more before being repaired since it may be under writing.
+func (sc *dataScrubber) scrubExtent(dp *DataPartition, replicas []string, ei *storage.ExtentInfo) (err error) {
+	size := int64(ei.Size) / util.BlockSize * util.BlockSize
+	for offset := int64(0); offset < size; offset += scrubBatchSize {
+		batch := size - offset
+		if batch > scrubBatchSize {
+			batch = scrubBatchSize
+		}
+		var crcs map[string][]uint32
+		if crcs, err = sc.collectBlockCrcs(dp, replicas, ei.FileID, offset, batch); err != nil {
+			return
+		}
+		for i := 0; i < int(batch/util.BlockSize); i++ {
+			if isBlockConsistent(blockCrcsAt(crcs, i)) {
+				continue
+			}
+			blockOffset := offset + int64(i)*util.BlockSize
+			var recheck map[string][]uint32
+			if recheck, err = sc.collectBlockCrcs(dp, replicas, ei.FileID, blockOffset, util.BlockSize); err != nil {
+				return
+			}
+			blockCrcs := blockCrcsAt(recheck, 0)
+			if isBlockConsistent(blockCrcs) {
+				continue
+			}
+			sc.repairBlock(dp, len(replicas), ei.FileID, blockOffset, blockCrcs)
+		}
+	}
+	return
+}
+
+// collectBlockCrcs returns the block crcs in the range computed by every replica. The replicas
+// failing to answer are left out.
+func (sc *dataScrubber) collectBlockCrcs(dp *DataPartition, replicas []string, extentID uint64, offset, size int64) (crcs map[string][]uint32, err error) {
+	crcs = make(map[string][]uint32, len(replicas))
+	for _, host := range replicas {
+		var blockCrcs []uint32
+		if host == dp.dataNode.localServerAddr {
+			if _, blockCrcs, err = dp.scrubExtentBlocks(extentID, offset, size); err != nil {
+				return
+			}
+			sc.Lock()
+			scanned := uint64(len(blockCrcs)) * util.BlockSize
+			sc.info.ScannedBytes += scanned
+			if info, ok := sc.partitions[dp.partitionID]; ok {
+				info.ScannedBytes += scanned
+			}
+			sc.Unlock()
+		} else if blockCrcs, err = dp.getRemoteBlockCrcs(host, extentID, offset, size); err != nil {
+			log.LogWarnf("action[collectBlockCrcs] dp %v extent %v offset %v size %v replica %v err %v",
+				dp.partitionID, extentID, offset, size, host, err)
+			err = nil
+			continue
+		}
+		crcs[host] = blockCrcs
+	}
+	return
+}
+
+func blockCrcsAt(crcs map[string][]uint32, index int) (blockCrcs map[string]uint32) {
+	blockCrcs = make(map[string]uint32, len(crcs))
+	for host, c := range crcs {
+		if index < len(c) {
+			blockCrcs[host] = c[index]
+		}
+	}
+	return
+}
+
+func isBlockConsistent(blockCrcs map[string]uint32) bool {
+	var (
+		first uint32
+		seen  bool
+	)
+	for _, crc := range blockCrcs {
+		if !seen {
+			first, seen = crc, true
+		} else if crc != first {
+			return false
+		}
+	}
+	return true
+}
+
+// repairBlock repairs the replicas whose crc of the block differs from the one held by a strict
+// majority of the replicas. Without such a majority, every replica is asked to check the block
+// against the crc stored with it instead.
+func (sc *dataScrubber) repairBlock(dp *DataPartition, replicaNum int, extentID uint64, blockOffset int64, blockCrcs map[string]uint32) {
+	var (
+		majority uint32
+		found    bool
+		counts   = make(map[uint32]int)
+	)
+	for _, crc := range blockCrcs {
+		counts[crc]++
+	}
+	for crc, n := range counts {
+		if n*2 > replicaNum {
+			majority, found = crc, true
+		}
+	}
+	sc.Lock()
+	sc.info.MismatchBlocks++
+	if info, ok := sc.partitions[dp.partitionID]; ok {
+		info.MismatchBlocks++
+	}
+	sc.Unlock()
+
+	for host, crc := range blockCrcs {
+		if found && crc == majority {
+			continue
+		}
+		finding := &proto.ScrubFinding{
+			ExtentId:    extentID,
+			Offset:      blockOffset,
+			Replica:     host,
+			ExpectedCrc: majority,
+			ActualCrc:   crc,
+			Time:        time.Now().Unix(),
+		}
+		var expectedCrcs []uint32
+		if found {
+			expectedCrcs = []uint32{majority}
+		}
+		repaired, err := dp.repairReplicaExtentBlocks(host, extentID, blockOffset, util.BlockSize, expectedCrcs)
+		finding.Repaired = repaired > 0
+		if err != nil {
+			finding.Err = err.Error()
+		} else if !found && !finding.Repaired {
+			finding.Err = "no crc is held by a majority of the replicas"
+		}
+		msg := fmt.Sprintf("action[scrub] dp %v extent %v offset %v replica %v crc %v, majority crc %v, repaired %v, err %v",
+			dp.partitionID, extentID, blockOffset, host, crc, majority, finding.Repaired, finding.Err)
+		if finding.Repaired {
+			log.LogWarn(msg)
+		} else {
+			log.LogError(msg)
+			exporter.Warning(msg)
+		}
+		sc.addFinding(dp.partitionID, finding)
+	}
+}
+
+func (sc *dataScrubber) addFinding(partitionID uint64, finding *proto.ScrubFinding) {
+	sc.Lock()
+	defer sc.Unlock()
+	if finding.Repaired {
+		sc.info.RepairedBlocks++
+	}
+	info, ok := sc.partitions[partitionID]
+	if !ok {
+		return
+	}
+	if finding.Repaired {
+		info.RepairedBlocks++
+	}
+	if len(info.Findings) >= maxScrubFindings {
+		copy(info.Findings, info.Findings[1:])
+		info.Findings[len(info.Findings)-1] = finding
+		return
+	}
+	info.Findings = append(info.Findings, finding)
+}
+
+func (sc *dataScrubber) diskInfo() (info proto.DiskScrubInfo) {
+	sc.RLock()
+	info = sc.info
+	sc.RUnlock()
+	if !sc.disk.dataNode.enableScrub {
+		info.Status = proto.ScrubStatusDisabled
+	}
+	return
+}
+
+func (sc *dataScrubber) partitionInfo(partitionID uint64) (info *proto.DataPartitionScrubInfo) {
+	sc.RLock()
+	if stored, ok := sc.partitions[partitionID]; ok {
+		info = new(proto.DataPartitionScrubInfo)
+		*info = *stored
+		info.Findings = append([]*proto.ScrubFinding(nil), stored.Findings...)
+	}
+	sc.RUnlock()
+	if info == nil {
+		info = &proto.DataPartitionScrubInfo{PartitionId: partitionID, Disk: sc.disk.Path, Status: proto.ScrubStatusIdle}
+	}
+	if !sc.disk.dataNode.enableScrub {
+		info.Status = proto.ScrubStatusDisabled
+	}
+	return
+}
+
+// scrubExtentBlocks re-reads the full blocks of the normal extent in the range from disk at the
+// scrub rate of the disk, and returns the size of the extent with the crcs of the blocks. The offset
+// is expected to be block aligned.
+func (dp *DataPartition) scrubExtentBlocks(extentID uint64, offset, size int64) (extentSize int64, crcs []uint32, err error) {
+	if storage.IsTinyExtent(extentID) {
+		err = fmt.Errorf("tiny extent %v can not be scrubbed", extentID)
+		return
+	}
+	store := dp.ExtentStore()
+	ei, err := store.Watermark(extentID)
+	if err != nil {
+		return
+	}
+	extentSize = int64(ei.Size)
+	end := offset + size
+	if end > extentSize {
+		end = extentSize
+	}
+	data := make([]byte, util.BlockSize)
+	for blockOffset := offset; blockOffset+util.BlockSize <= end; blockOffset += util.BlockSize {
+		dp.disk.scrubber.wait(util.BlockSize)
+		var crc uint32
+		if crc, err = store.Read(extentID, blockOffset, util.BlockSize, data, true, false); err != nil {
+			dp.checkIsDiskError(err, ReadFlag)
+			return
+		}
+		crcs = append(crcs, crc)
+	}
+	return
+}
+
+func (dp *DataPartition) getRemoteBlockCrcs(host string, extentID uint64, offset, size int64) (crcs []uint32, err error) {
+	data, err := json.Marshal(&proto.ScrubExtentBlocksRequest{
+		PartitionId: dp.partitionID,
+		ExtentId:    extentID,
+		Offset:      offset,
+		Size:        size,
+	})
+	if err != nil {
+		return
+	}
+	var conn *net.TCPConn
+	if conn, err = gConnPool.GetConnect(host); err != nil {
+		return
+	}
+	defer func() {
+		gConnPool.PutConnect(conn, err != nil)
+	}()
+	p := repl.NewPacketToScrubExtentBlocks(dp.partitionID, data)
+	if err = p.WriteToConn(conn); err != nil {
+		return
+	}
+	reply := new(repl.Packet)
+	if err = reply.ReadFromConnWithVer(conn, scrubDeadlineTime); err != nil {
+		return
+	}
+	if reply.ResultCode != proto.OpOk {
+		return nil, errors.NewErrorf("reply %v", string(reply.Data[:reply.Size]))
+	}
+	resp := &proto.ScrubExtentBlocksResponse{}
+	if err = json.Unmarshal(reply.Data[:reply.Size], resp); err != nil {
+		return
+	}
+	return resp.Crcs, nil
+}
+
+// repairReplicaExtentBlocks asks the replica to repair the blocks of the extent in the range, see
+// repairExtentBlocks.
+func (dp *DataPartition) repairReplicaExtentBlocks(host string, extentID uint64, offset, size int64, expectedCrcs []uint32) (repaired int, err error) {
+	if host == dp.dataNode.localServerAddr {
+		return dp.repairExtentBlocks(extentID, offset, size, expectedCrcs)
+	}
+	task := proto.NewAdminTask(proto.OpRepairExtentBlocks, host, &proto.RepairExtentBlocksRequest{
+		PartitionId: dp.partitionID,
+		ExtentId:    extentID,
+		Offset:      offset,
+		Size:        size,
+		BlockCrcs:   expectedCrcs,
+	})
+	task.PartitionID = dp.partitionID
+	data, err := json.Marshal(task)
+	if err != nil {
+		return
+	}
+	var conn *net.TCPConn
+	if conn, err = gConnPool.GetConnect(host); err != nil {
+		return
+	}
+	defer func() {
+		gConnPool.PutConnect(conn, err != nil)
+	}()
+	p := repl.NewPacket()
+	p.Opcode = proto.OpRepairExtentBlocks
+	p.ReqID = proto.GenerateRequestID()
+	p.PartitionID = dp.partitionID
+	p.Data = data
+	p.Size = uint32(len(data))
+	if err = p.WriteToConn(conn); err != nil {
+		return
+	}
+	reply := new(repl.Packet)
+	if err = reply.ReadFromConnWithVer(conn, scrubDeadlineTime); err != nil {
+		return
+	}
+	if reply.ResultCode != proto.OpOk {
+		return 0, errors.NewErrorf("reply %v", string(reply.Data[:reply.Size]))
+	}
+	if reply.Size >= 8 {
+		repaired = int(binary.BigEndian.Uint64(reply.Data[:8]))
+	}
+	return
+}
@@ -0,0 +1,37 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datanode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScrubBlockCrcs(t *testing.T) {
+	crcs := map[string][]uint32{
+		"a": {1, 2, 3},
+		"b": {1, 2, 4},
+		"c": {1, 5},
+	}
+	require.Equal(t, map[string]uint32{"a": 1, "b": 1, "c": 1}, blockCrcsAt(crcs, 0))
+	require.True(t, isBlockConsistent(blockCrcsAt(crcs, 0)))
+	require.False(t, isBlockConsistent(blockCrcsAt(crcs, 1)))
+	// the replica without the block is left out
+	require.Equal(t, map[string]uint32{"a": 3, "b": 4}, blockCrcsAt(crcs, 2))
+	require.False(t, isBlockConsistent(blockCrcsAt(crcs, 2)))
+	require.True(t, isBlockConsistent(map[string]uint32{"a": 0, "b": 0}))
+	require.True(t, isBlockConsistent(blockCrcsAt(crcs, 3)))
+}
@@ -99,6 +99,7 @@ type Disk struct {
 	BackupDataPartitions        sync.Map
 	recoverStatus               uint32
 	BackupReplicaLk             sync.RWMutex
+	scrubber                    *dataScrubber
 }
 
 const (
@@ -161,6 +162,8 @@ func NewDisk(path string, reservedSpace, diskRdonlySpace uint64, maxErrCnt int,
 	d.extentRepairReadLimit = make(chan struct{}, MaxExtentRepairReadLimit)
 	d.extentRepairReadLimit <- struct{}{}
 	d.enableExtentRepairReadLimit = diskEnableReadRepairExtentLimit
+	d.scrubber = newDataScrubber(d)
+	d.startScheduleToScrub()
 	return
 }
 
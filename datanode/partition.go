@@ -496,6 +496,97 @@ func (partition *DataPartition) HandleVersionOp(req *proto.MultiVersionOpRequest
 	return
 }
 
+// MarkSharedExtents submits the extents shared by the files cloned to raft, the random writes to
+// them applied afterwards are refused.
+func (partition *DataPartition) MarkSharedExtents(extentIDs []uint64) (err error) {
+	data, err := json.Marshal(extentIDs)
+	if err != nil {
+		return
+	}
+	pItem := &RaftCmdItem{
+		Op: uint32(proto.OpMarkSharedExtents),
+		K:  []byte("shared"),
+		V:  data,
+	}
+	cmd, _ := MarshalRaftCmd(pItem)
+	_, err = partition.Submit(cmd)
+	return
+}
+
+// HoldSharedExtents submits the extents held by the meta partition of the clone to raft, which
+// are marked shared as well.
+func (partition *DataPartition) HoldSharedExtents(holder uint64, extentIDs []uint64) (err error) {
+	data, err := json.Marshal(&proto.MarkSharedExtentsRequest{PartitionId: partition.partitionID, ExtentIds: extentIDs, Holder: holder})
+	if err != nil {
+		return
+	}
+	pItem := &RaftCmdItem{
+		Op: uint32(proto.OpMarkSharedExtents),
+		K:  []byte("shared"),
+		V:  data,
+	}
+	cmd, _ := MarshalRaftCmd(pItem)
+	_, err = partition.Submit(cmd)
+	return
+}
+
+// fsmMarkSharedExtents marks the extents shared, the value is the list of the ids, or the request
+// if the extents are held by the clone.
+func (partition *DataPartition) fsmMarkSharedExtents(opItem *RaftCmdItem) (err error) {
+	req := new(proto.MarkSharedExtentsRequest)
+	if len(opItem.V) > 0 && opItem.V[0] == '{' {
+		err = json.Unmarshal(opItem.V, req)
+	} else {
+		err = json.Unmarshal(opItem.V, &req.ExtentIds)
+	}
+	if err != nil {
+		log.LogErrorf("action[fsmMarkSharedExtents] dp[%v] op item %v err %v", partition.partitionID, opItem, err)
+		return
+	}
+	if req.Holder != 0 {
+		err = partition.ExtentStore().HoldSharedExtents(req.Holder, req.ExtentIds)
+	} else {
+		err = partition.ExtentStore().MarkSharedExtents(req.ExtentIds)
+	}
+	if err != nil {
+		log.LogErrorf("action[fsmMarkSharedExtents] dp[%v] extents %v holder %v err %v", partition.partitionID, req.ExtentIds, req.Holder, err)
+		return
+	}
+	log.LogInfof("action[fsmMarkSharedExtents] dp[%v] extents %v holder %v", partition.partitionID, req.ExtentIds, req.Holder)
+	return
+}
+
+// ReleaseSharedExtents submits the release of the extents held by the meta partitions of the
+// clone to raft, all the extents they hold are released if extentIDs is empty.
+func (partition *DataPartition) ReleaseSharedExtents(holders, extentIDs []uint64) (err error) {
+	data, err := json.Marshal(&proto.ReleaseSharedExtentsRequest{PartitionId: partition.partitionID, Holders: holders, ExtentIds: extentIDs})
+	if err != nil {
+		return
+	}
+	pItem := &RaftCmdItem{
+		Op: uint32(proto.OpReleaseSharedExtents),
+		K:  []byte("shared"),
+		V:  data,
+	}
+	cmd, _ := MarshalRaftCmd(pItem)
+	_, err = partition.Submit(cmd)
+	return
+}
+
+func (partition *DataPartition) fsmReleaseSharedExtents(opItem *RaftCmdItem) (err error) {
+	req := new(proto.ReleaseSharedExtentsRequest)
+	if err = json.Unmarshal(opItem.V, req); err != nil {
+		log.LogErrorf("action[fsmReleaseSharedExtents] dp[%v] op item %v err %v", partition.partitionID, opItem, err)
+		return
+	}
+	if err = partition.ExtentStore().ReleaseSharedExtents(req.Holders, req.ExtentIds); err != nil {
+		log.LogErrorf("action[fsmReleaseSharedExtents] dp[%v] holders %v extents %v err %v", partition.partitionID, req.Holders, req.ExtentIds, err)
+		return
+	}
+	log.LogInfof("action[fsmReleaseSharedExtents] dp[%v] holders %v extents %v", partition.partitionID, req.Holders, req.ExtentIds)
+	return
+}
+
 func (partition *DataPartition) fsmVersionOp(opItem *RaftCmdItem) (err error) {
 	req := new(proto.MultiVersionOpRequest)
 	if err = json.Unmarshal(opItem.V, req); err != nil {
@@ -679,6 +770,26 @@ func (dp *DataPartition) IsExistReplicaWithNodeId(addr string, nodeID uint64) bo
 	return false
 }
 
+// addPeerChangeType returns the raft member change to add the peer, as a learner if it's requested
+// so, by promoting the learner added before, or as a voter. It returns false if the peer is added.
+func (dp *DataPartition) addPeerChangeType(peer proto.Peer) (changeType raftProto.ConfChangeType, ok bool) {
+	dp.replicasLock.RLock()
+	defer dp.replicasLock.RUnlock()
+	for _, localPeer := range dp.config.Peers {
+		if localPeer.Addr != peer.Addr || localPeer.ID != peer.ID {
+			continue
+		}
+		if localPeer.IsLearner && !peer.IsLearner {
+			return raftProto.ConfPromoteLearner, true
+		}
+		return
+	}
+	if peer.IsLearner {
+		return raftProto.ConfAddLearner, true
+	}
+	return raftProto.ConfAddNode, true
+}
+
 func (dp *DataPartition) ReloadSnapshot() {
 	files, err := dp.extentStore.SnapShot()
 	if err != nil {
@@ -1086,6 +1197,14 @@ func (dp *DataPartition) DoExtentStoreRepair(repairTask *DataPartitionRepairTask
 		return
 	}
 	store := dp.extentStore
+	// the extents are marked before they are repaired, or the random writes may overwrite them
+	if len(repairTask.SharedExtents) > 0 {
+		if err := store.MarkSharedExtents(repairTask.SharedExtents); err != nil {
+			log.LogErrorf("DoExtentStoreRepair dp %v mark shared extents(%v) err(%v)",
+				dp.partitionID, len(repairTask.SharedExtents), err)
+			return
+		}
+	}
 	log.LogDebugf("DoExtentStoreRepair dp %v len extents to created %v type %v",
 		dp.partitionID, len(repairTask.ExtentsToBeCreated), repairTask.TaskType)
 	for _, extentInfo := range repairTask.ExtentsToBeCreated {
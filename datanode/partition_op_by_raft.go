@@ -192,6 +192,7 @@ func (dp *DataPartition) CheckLeader(request *repl.Packet, connect net.Conn) (er
 
 type ItemIterator struct {
 	applyID uint64
+	data    []byte // the shared extents, sent once if any
 }
 
 // NewItemIterator creates a new item iterator.
@@ -216,6 +217,10 @@ func (si *ItemIterator) Next() (data []byte, err error) {
 	// appIDBuf := make([]byte, 8)
 	// binary.BigEndian.PutUint64(appIDBuf, si.applyID)
 	// data = appIDBuf[:]
+	if si.data != nil {
+		data, si.data = si.data, nil
+		return
+	}
 	err = io.EOF
 	return
 }
@@ -259,6 +264,14 @@ func (dp *DataPartition) ApplyRandomWrite(command []byte, raftApplyID uint64) (r
 	log.LogDebugf("[ApplyRandomWrite] ApplyID(%v) Partition(%v)_Extent(%v)_ExtentOffset(%v)_Size(%v)",
 		raftApplyID, dp.partitionID, opItem.extentID, opItem.offset, opItem.size)
 
+	if isRandomWriteOp(opItem.opcode) && dp.ExtentStore().IsSharedExtent(opItem.extentID) {
+		// the extent is shared by the files cloned, which copy on write
+		log.LogWarnf("[ApplyRandomWrite] ApplyID(%v) Partition(%v)_Extent(%v) is shared, refuse to overwrite",
+			raftApplyID, dp.partitionID, opItem.extentID)
+		respStatus = proto.OpTryOtherExtent
+		return
+	}
+
 	for i := 0; i < 20; i++ {
 		dp.disk.allocCheckLimit(proto.FlowWriteType, uint32(opItem.size))
 		dp.disk.allocCheckLimit(proto.IopsWriteType, 1)
@@ -317,6 +330,16 @@ func (dp *DataPartition) ApplyRandomWrite(command []byte, raftApplyID uint64) (r
 	return
 }
 
+// isRandomWriteOp returns true if the op overwrites the data of the extent in place.
+func isRandomWriteOp(opcode uint8) bool {
+	switch opcode {
+	case proto.OpRandomWrite, proto.OpSyncRandomWrite, proto.OpRandomWriteVer, proto.OpSyncRandomWriteVer:
+		return true
+	default:
+		return false
+	}
+}
+
 // RandomWriteSubmit submits the proposal to raft.
 func (dp *DataPartition) RandomWriteSubmit(pkg *repl.Packet) (err error) {
 	val, err := MarshalRandWriteRaftLog(pkg.Opcode, pkg.ExtentID, pkg.ExtentOffset, int64(pkg.Size), pkg.Data, pkg.CRC)
@@ -131,6 +131,9 @@ func (dp *DataPartition) StartRaft(isLoad bool) (err error) {
 			HeartbeatPort: heartbeatPort,
 			ReplicaPort:   replicaPort,
 		}
+		if peer.IsLearner {
+			rp.Peer.Type = raftproto.PeerLearner
+		}
 		peers = append(peers, rp)
 	}
 	log.LogDebugf("start partition(%v) raft peers: %s path: %s applyid:%v",
@@ -457,6 +460,19 @@ func (dp *DataPartition) addRaftNode(req *proto.AddDataPartitionRaftMemberReques
 	return
 }
 
+// Promote the raft learner to a voter.
+func (dp *DataPartition) promoteRaftLearner(req *proto.AddDataPartitionRaftMemberRequest) (isUpdated bool) {
+	dp.replicasLock.Lock()
+	defer dp.replicasLock.Unlock()
+	for i, peer := range dp.config.Peers {
+		if peer.ID == req.AddPeer.ID && peer.IsLearner {
+			dp.config.Peers[i].IsLearner = false
+			isUpdated = true
+		}
+	}
+	return
+}
+
 // Delete a raft node.
 func (dp *DataPartition) removeRaftNode(req *proto.RemoveDataPartitionRaftMemberRequest, index uint64) (isUpdated bool, err error) {
 	// cache or preload partition not support raft and repair.
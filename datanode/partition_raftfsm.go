@@ -19,6 +19,7 @@ import (
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"io"
 	"sync"
 	"sync/atomic"
 
@@ -52,6 +53,18 @@ func (dp *DataPartition) Apply(command []byte, index uint64) (resp interface{},
 			dp.fsmVersionOp(opItem)
 			return
 		}
+		if opItem.Op == uint32(proto.OpMarkSharedExtents) {
+			if err = dp.fsmMarkSharedExtents(opItem); err != nil {
+				panic(newRaftApplyError(err))
+			}
+			return
+		}
+		if opItem.Op == uint32(proto.OpReleaseSharedExtents) {
+			if err = dp.fsmReleaseSharedExtents(opItem); err != nil {
+				panic(newRaftApplyError(err))
+			}
+			return
+		}
 		return
 	}
 	if index > dp.metaAppliedID {
@@ -93,12 +106,12 @@ func (dp *DataPartition) ApplyMemberChange(confChange *raftproto.ConfChange, ind
 	}
 
 	switch confChange.Type {
-	case raftproto.ConfAddNode:
+	case raftproto.ConfAddNode, raftproto.ConfAddLearner:
 		req := &proto.AddDataPartitionRaftMemberRequest{}
 		if err = json.Unmarshal(confChange.Context, req); err != nil {
 			return
 		}
-		msg = fmt.Sprintf("ConfAddNode [%v], partitionId [%v] index(%v)", req.AddPeer, req.PartitionId, index)
+		msg = fmt.Sprintf("%v [%v], partitionId [%v] index(%v)", confChange.Type, req.AddPeer, req.PartitionId, index)
 		log.LogInfof("action[ApplyMemberChange] %v", msg)
 		isUpdated, err = dp.addRaftNode(req, index)
 		if isUpdated && err == nil {
@@ -121,6 +134,15 @@ func (dp *DataPartition) ApplyMemberChange(confChange *raftproto.ConfChange, ind
 			updateWG.Wait()
 		}
 		auditlog.LogDataNodeOp("DataPartitionMemberChange", msg, err)
+	case raftproto.ConfPromoteLearner:
+		req := &proto.AddDataPartitionRaftMemberRequest{}
+		if err = json.Unmarshal(confChange.Context, req); err != nil {
+			return
+		}
+		msg = fmt.Sprintf("ConfPromoteLearner [%v], partitionId [%v] index(%v)", req.AddPeer, req.PartitionId, index)
+		log.LogInfof("action[ApplyMemberChange] %v", msg)
+		isUpdated = dp.promoteRaftLearner(req)
+		auditlog.LogDataNodeOp("DataPartitionMemberChange", msg, err)
 	case raftproto.ConfRemoveNode:
 		req := &proto.RemoveDataPartitionRaftMemberRequest{}
 		if err = json.Unmarshal(confChange.Context, req); err != nil {
@@ -155,6 +177,23 @@ func (dp *DataPartition) ApplyMemberChange(confChange *raftproto.ConfChange, ind
 // snapshot in this case.
 func (dp *DataPartition) Snapshot() (raftproto.Snapshot, error) {
 	snapIterator := NewItemIterator(dp.raftPartition.AppliedIndex())
+	// the shared extents are sent, as the random writes applied after the snapshot check them, and
+	// so are the holds of the clones, which the deletions check
+	extentIDs := dp.ExtentStore().GetSharedExtents()
+	holders, deletes := dp.ExtentStore().GetSharedHolds()
+	if len(holders) > 0 {
+		data, err := json.Marshal(&sharedExtentsSnapshot{Extents: extentIDs, Holders: holders, Deletes: deletes})
+		if err != nil {
+			return nil, err
+		}
+		snapIterator.data = data
+	} else if len(extentIDs) > 0 {
+		data, err := json.Marshal(extentIDs)
+		if err != nil {
+			return nil, err
+		}
+		snapIterator.data = data
+	}
 	log.LogInfof("SendSnapShot PartitionID(%v) Snapshot lastTruncateID(%v) currentApplyID(%v) firstCommitID(%v)",
 		dp.partitionID, dp.lastTruncateID, dp.appliedID, dp.raftPartition.CommittedIndex())
 	return snapIterator, nil
@@ -164,7 +203,46 @@ func (dp *DataPartition) Snapshot() (raftproto.Snapshot, error) {
 func (dp *DataPartition) ApplySnapshot(peers []raftproto.Peer, iterator raftproto.SnapIterator) (err error) {
 	// Never delete the raft log which hadn't applied, so snapshot no need.
 	log.LogInfof("PartitionID(%v) ApplySnapshot to (%v)", dp.partitionID, dp.raftPartition.CommittedIndex())
-	return
+	for {
+		var data []byte
+		if data, err = iterator.Next(); err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return
+		}
+		snap := new(sharedExtentsSnapshot)
+		if len(data) > 0 && data[0] == '{' {
+			err = json.Unmarshal(data, snap)
+		} else {
+			err = json.Unmarshal(data, &snap.Extents)
+		}
+		if err != nil {
+			log.LogErrorf("PartitionID(%v) ApplySnapshot unmarshal shared extents err(%v)", dp.partitionID, err)
+			return
+		}
+		if err = dp.ExtentStore().MarkSharedExtents(snap.Extents); err != nil {
+			return
+		}
+		for extentID, holders := range snap.Holders {
+			for _, holder := range holders {
+				if err = dp.ExtentStore().HoldSharedExtents(holder, []uint64{extentID}); err != nil {
+					return
+				}
+			}
+		}
+		if err = dp.ExtentStore().AddSharedDeletes(snap.Deletes); err != nil {
+			return
+		}
+	}
+}
+
+// sharedExtentsSnapshot is the snapshot of the shared extents with the holds of the clones, the
+// ids of the shared extents are sent alone if no extent is held.
+type sharedExtentsSnapshot struct {
+	Extents []uint64                                `json:"extents"`
+	Holders map[uint64][]uint64                     `json:"holders"`
+	Deletes map[uint64][]storage.SharedExtentDelete `json:"deletes,omitempty"`
 }
 
 // HandleFatalEvent notifies the application when panic happens.
@@ -47,6 +47,7 @@ type PacketInterface interface {
 	GetCRC() uint32
 	GetArg() []byte
 	GetArgLen() uint32
+	GetExtentType() uint8
 	GetData() []byte
 	GetResultCode() uint8
 	GetExtentOffset() int64
@@ -242,6 +243,19 @@ func NewPacketToGetAllWatermarks(partitionID uint64, extentType uint8) (p *Packe
 	return
 }
 
+func NewPacketToScrubExtentBlocks(partitionID uint64, data []byte) (p *Packet) {
+	p = new(Packet)
+	p.Opcode = proto.OpScrubExtentBlocks
+	p.PartitionID = partitionID
+	p.Magic = proto.ProtoMagic
+	p.ReqID = proto.GenerateRequestID()
+	p.ExtentType = proto.NormalExtentType
+	p.Data = data
+	p.Size = uint32(len(data))
+
+	return
+}
+
 func NewPacketToReadTinyDeleteRecord(partitionID uint64, offset int64) (p *Packet) {
 	p = new(Packet)
 	p.Opcode = proto.OpReadTinyDeleteRecord
@@ -338,6 +352,22 @@ func NewNormalExtentWithHoleStreamReadResponsePacket(requestID int64, partitionI
 	return
 }
 
+// NewBlockCrcReadPacket returns the packet to read the normal extent from the replica, whose reply
+// carries the crc of the blocks stored by the replica.
+func NewBlockCrcReadPacket(partitionID uint64, extentID uint64, offset, size int) (p PacketInterface) {
+	pr := new(Packet)
+	pr.ExtentID = extentID
+	pr.PartitionID = partitionID
+	pr.Magic = proto.ProtoMagic
+	pr.ExtentOffset = int64(offset)
+	pr.Size = uint32(size)
+	pr.Opcode = proto.OpStreamFollowerRead
+	pr.ExtentType = proto.NormalExtentType | proto.BlockCrcFlag
+	pr.ReqID = proto.GenerateRequestID()
+
+	return pr
+}
+
 func NewStreamReadResponsePacket(requestID int64, partitionID uint64, extentID uint64) (p PacketInterface) {
 	pr := new(Packet)
 	pr.ExtentID = extentID
@@ -412,6 +442,10 @@ func (p *Packet) GetArgLen() uint32 {
 	return p.ArgLen
 }
 
+func (p *Packet) GetExtentType() uint8 {
+	return p.ExtentType
+}
+
 func (p *Packet) GetData() []byte {
 	return p.Data
 }
@@ -471,6 +505,8 @@ func (p *Packet) identificationErrorResultCode(errLog string, errMsg string) {
 		p.ResultCode = proto.OpWriteOpOfProtoVerForbidden
 	} else if strings.Contains(errMsg, storage.VolForbidWriteOpOfProtoVer.Error()) {
 		p.ResultCode = proto.OpWriteOpOfProtoVerForbidden
+	} else if strings.Contains(errMsg, storage.BlockCrcMismatchError.Error()) {
+		p.ResultCode = proto.OpBlockCrcMismatchErr
 	} else {
 		log.LogErrorf("action[identificationErrorResultCode] error %v, errmsg %v", errLog, errMsg)
 		p.ResultCode = proto.OpIntraGroupNetErr
@@ -509,7 +545,9 @@ func (p *Packet) IsMasterCommand() bool {
 		proto.OpRecoverBackupDataReplica,
 		proto.OpRecoverBadDisk,
 		proto.OpQueryBadDiskRecoverProgress,
-		proto.OpDeleteBackupDirectories:
+		proto.OpDeleteBackupDirectories,
+		proto.OpRepairExtentBlocks,
+		proto.OpReleaseCloneHolds:
 		return true
 	default:
 		return false
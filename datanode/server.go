@@ -137,6 +137,11 @@ const (
 
 	// storage device media type, for hybrid cloud, in string: SDD or HDD
 	ConfigMediaType = "mediaType"
+
+	// background scrubbing of the data partitions
+	ConfigKeyEnableScrub       = "enableScrub"       // bool
+	ConfigKeyScrubBytesPerSec  = "scrubBytesPerSec"  // int, bytes read per second on each disk
+	ConfigKeyScrubIntervalHour = "scrubIntervalHour" // int, hours between two rounds on a disk
 )
 
 const cpuSampleDuration = 1 * time.Second
@@ -214,6 +219,9 @@ type DataNode struct {
 	nodeForbidWriteOpOfProtoVer0       bool                // whether forbid by node granularity,
 	VolsForbidWriteOpOfProtoVer0       map[string]struct{} // whether forbid by volume granularity,
 	DirectReadVols                     map[string]struct{}
+	enableScrub                        bool
+	scrubBytesPerSec                   int
+	scrubInterval                      time.Duration
 }
 
 type verOp2Phase struct {
@@ -446,10 +454,23 @@ func (s *DataNode) parseConfig(cfg *config.Config) (err error) {
 	}
 	s.mediaType = mediaType
 
+	s.enableScrub = cfg.GetBoolWithDefault(ConfigKeyEnableScrub, true)
+	s.scrubBytesPerSec = cfg.GetInt(ConfigKeyScrubBytesPerSec)
+	if s.scrubBytesPerSec <= 0 {
+		s.scrubBytesPerSec = DefaultScrubBytesPerSec
+	}
+	scrubIntervalHour := cfg.GetInt(ConfigKeyScrubIntervalHour)
+	if scrubIntervalHour <= 0 {
+		scrubIntervalHour = DefaultScrubIntervalHour
+	}
+	s.scrubInterval = time.Duration(scrubIntervalHour) * time.Hour
+
 	log.LogDebugf("action[parseConfig] load masterAddrs(%v).", MasterClient.Nodes())
 	log.LogDebugf("action[parseConfig] load port(%v).", s.port)
 	log.LogDebugf("action[parseConfig] load zoneName(%v).", s.zoneName)
 	log.LogDebugf("action[parseConfig] load mediaType(%v).", s.mediaType)
+	log.LogDebugf("action[parseConfig] load enableScrub(%v) scrubBytesPerSec(%v) scrubInterval(%v).",
+		s.enableScrub, s.scrubBytesPerSec, s.scrubInterval)
 	return
 }
 
@@ -849,6 +870,8 @@ func (s *DataNode) registerHandler() {
 	http.HandleFunc("/getAllExtent", s.getAllExtent)
 	http.HandleFunc("/setOpLog", s.setOpLog)
 	http.HandleFunc("/getOpLog", s.getOpLog)
+	http.HandleFunc("/getPartitionScrub", s.getPartitionScrub)
+	http.HandleFunc("/getDiskScrub", s.getDiskScrub)
 	http.HandleFunc(exporter.SetEnablePidPath, exporter.SetEnablePid)
 }
 
@@ -798,3 +798,40 @@ func (s *DataNode) loadDataPartition(w http.ResponseWriter, r *http.Request) {
 		s.buildSuccessResp(w, "success")
 	}
 }
+
+func (s *DataNode) getPartitionScrub(w http.ResponseWriter, r *http.Request) {
+	var (
+		pid common.Uint
+		err error
+	)
+	if err = parseArgs(r, pid.ID()); err != nil {
+		s.buildFailureResp(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	partition := s.space.Partition(pid.V)
+	if partition == nil || partition.Disk().scrubber == nil {
+		s.buildFailureResp(w, http.StatusNotFound, "partition not exist")
+		return
+	}
+	s.buildSuccessResp(w, partition.Disk().scrubber.partitionInfo(pid.V))
+}
+
+func (s *DataNode) getDiskScrub(w http.ResponseWriter, r *http.Request) {
+	var diskPath common.String
+	if err := parseArgs(r, diskPath.Disk().OmitEmpty()); err != nil {
+		s.buildFailureResp(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	disks := make([]proto.DiskScrubInfo, 0)
+	for _, d := range s.space.GetDisks() {
+		if d.scrubber == nil || (diskPath.V != "" && d.Path != diskPath.V) {
+			continue
+		}
+		disks = append(disks, d.scrubber.diskInfo())
+	}
+	if diskPath.V != "" && len(disks) == 0 {
+		s.buildFailureResp(w, http.StatusNotFound, "disk not exist")
+		return
+	}
+	s.buildSuccessResp(w, disks)
+}
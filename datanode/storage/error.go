@@ -39,10 +39,12 @@ var (
 	ForbidWriteError                 = errors.New("single replica decommission forbid write")
 	VerNotConsistentError            = errors.New("ver not consistent")
 	SnapshotNeedNewExtentError       = errors.New("snapshot need new extent error")
+	SharedExtentError                = errors.New("extent is shared and can not be overwritten")
 	NoDiskReadRepairExtentTokenError = errors.New("no disk read repair extent token")
 	ReachMaxExtentsCountError        = errors.New("reached max extents count")
 	ClusterForbidWriteOpOfProtoVer   = errors.New("cluster forbid write operate of packet protocol version")
 	VolForbidWriteOpOfProtoVer       = errors.New("vol forbid write operate of packet protocol version")
+	BlockCrcMismatchError            = errors.New("block crc mismatch")
 )
 
 func newParameterError(format string, a ...interface{}) error {
@@ -553,7 +553,15 @@ func (e *Extent) Write(param *WriteParam, crcFunc UpdateCrcFunc) (status uint8,
 
 		// NOTE: aliagn, compute crc
 		if offsetInBlock == 0 && sizeInBlock == util.BlockSize {
-			err = crcFunc(e, int(blockNo), param.Crc)
+			blockCrc := param.Crc
+			if param.IsHole {
+				blockCrc = 0
+			} else if param.Size != util.BlockSize {
+				// NOTE: the crc of the param covers more than the block
+				dataOffset := beginOffset - param.Offset
+				blockCrc = crc32.ChecksumIEEE(param.Data[dataOffset : dataOffset+sizeInBlock])
+			}
+			err = crcFunc(e, int(blockNo), blockCrc)
 			log.LogDebugf("action[Extent.Write] write param(%v) err %v crcOffset %v", param, err, beginOffset)
 			beginOffset += sizeInBlock
 			continue
@@ -669,6 +677,36 @@ func (e *Extent) Flush() (err error) {
 	return
 }
 
+// RepairBlock rewrites the block at blockOffset by the data matching crc. The block is rewritten
+// only if the crc persisted for it is still stored and the data on disk still fails the check,
+// both checked under the write lock, so that the block written since it's checked is not clobbered.
+func (e *Extent) RepairBlock(blockOffset int64, data []byte, stored, crc uint32, crcFunc UpdateCrcFunc) (repaired bool, err error) {
+	e.Lock()
+	defer e.Unlock()
+	blockNo := blockOffset / util.BlockSize
+	if e.GetCrc(blockNo) != stored {
+		log.LogWarnf("action[Extent.RepairBlock] path %v offset %v is written since checked", e.filePath, blockOffset)
+		return
+	}
+	local := make([]byte, util.BlockSize)
+	if _, err = e.file.ReadAt(local, blockOffset); err != nil {
+		return
+	}
+	if crc32.ChecksumIEEE(local) == crc {
+		return
+	}
+	if _, err = e.file.WriteAt(data[:util.BlockSize], blockOffset); err != nil {
+		return
+	}
+	if err = e.file.Sync(); err != nil {
+		return
+	}
+	if err = crcFunc(e, int(blockNo), crc); err != nil {
+		return
+	}
+	return true, nil
+}
+
 func (e *Extent) GetCrc(blockNo int64) uint32 {
 	if int64(len(e.header)) < (blockNo+1)*util.PerBlockCrcSize {
 		return 0
@@ -151,6 +151,12 @@ type ExtentStore struct {
 	stopC                             chan interface{}
 	ApplyId                           uint64
 	DirectRead                        bool
+	sharedExtents                     map[uint64]struct{}
+	sharedRecords                     int // the records in the file of the shared extents
+	sharedHolds                       map[uint64]*sharedHold
+	holdRecords                       int                    // the records in the file of the holds
+	releasedDeletes                   []releasedSharedExtent // the deletions to be applied once loaded
+	sharedMutex                       sync.RWMutex
 }
 
 func MkdirAll(name string) (err error) {
@@ -240,6 +246,14 @@ func NewExtentStore(dataDir string, partitionID uint64, storeSize, dpType, cap i
 		err = fmt.Errorf("init base field ID: %v", err)
 		return
 	}
+	if err = s.loadSharedExtents(); err != nil {
+		err = fmt.Errorf("load shared extents: %v", err)
+		return
+	}
+	if err = s.loadSharedHolds(); err != nil {
+		err = fmt.Errorf("load shared extent holds: %v", err)
+		return
+	}
 	s.hasAllocSpaceExtentIDOnVerfiyFile = s.GetPreAllocSpaceExtentIDOnVerifyFile()
 	s.storeSize = storeSize
 	s.closed = 0
@@ -248,6 +262,10 @@ func NewExtentStore(dataDir string, partitionID uint64, storeSize, dpType, cap i
 		return
 	}
 	s.stopC = make(chan interface{})
+	if err = s.applyReleasedDeletes(s.releasedDeletes); err != nil {
+		return
+	}
+	s.releasedDeletes = nil
 	go func() {
 		time.Sleep(15 * time.Minute)
 		s.startFlushCache()
@@ -823,6 +841,15 @@ func (s *ExtentStore) punchDelete(extentID uint64, offset, size int64) (err erro
 	if hasDelete {
 		return
 	}
+	if !IsTinyExtent(extentID) {
+		// NOTE: the crc of the blocks punched is no longer valid
+		for blockNo := offset / util.BlockSize; blockNo*util.BlockSize < offset+size; blockNo++ {
+			if err = s.PersistenceBlockCrc(e, int(blockNo), 0); err != nil {
+				return
+			}
+		}
+		return
+	}
 	if err = s.RecordTinyDelete(e.extentID, offset, size); err != nil {
 		return
 	}
@@ -869,6 +896,10 @@ func (s *ExtentStore) MarkDelete(extentID uint64, offset, size int64) (err error
 	if IsTinyExtent(extentID) {
 		return s.punchDelete(extentID, offset, size)
 	}
+	// the extent held by the clones is deleted once they all release it
+	if deferred, err := s.deferSharedDelete(extentID, offset, size); deferred || err != nil {
+		return err
+	}
 
 	ei, _ = s.GetExtentInfo(extentID)
 	if err != nil {
@@ -927,6 +958,11 @@ func (s *ExtentStore) MarkDelete(extentID uint64, offset, size int64) (err error
 		return
 	}
 	s.PutNormalExtentToDeleteCache(extentID)
+	// the id left in the shared ones is harmless since the ids are never reused
+	if err = s.UnmarkSharedExtent(extentID); err != nil {
+		log.LogWarnf("[MarkDelete] store(%v) failed to unmark shared extent(%v), err(%v)", s.dataPath, extentID, err)
+		err = nil
+	}
 
 	s.eiMutex.Lock()
 	delete(s.extentInfoMap, extentID)
@@ -1529,6 +1565,106 @@ func (s *ExtentStore) ScanBlocks(extentID uint64) (bcs []*BlockCrc, err error) {
 	return
 }
 
+// BlockCrcs returns the crc stored of the blocks of the normal extent overlapped by the range, the
+// first of which is the block containing the offset. The crc is 0 if it's unknown, or the block
+// isn't fully written.
+func (s *ExtentStore) BlockCrcs(extentID uint64, offset, size int64) (crcs []uint32, err error) {
+	if !proto.IsNormalDp(s.partitionType) || IsTinyExtent(extentID) || size <= 0 {
+		return
+	}
+	ei, _ := s.GetExtentInfo(extentID)
+	e, err := s.extentWithHeader(ei)
+	if err != nil {
+		return
+	}
+
+	crcs = make([]uint32, 0, (offset%util.BlockSize+size+util.BlockSize-1)/util.BlockSize)
+	for blockNo := offset / util.BlockSize; blockNo*util.BlockSize < offset+size; blockNo++ {
+		blockEnd := (blockNo + 1) * util.BlockSize
+		extSize := e.Size()
+		if blockEnd > util.ExtentSize {
+			extSize = int64(e.snapshotDataOff)
+		}
+		var crc uint32
+		if blockEnd <= extSize {
+			crc = e.GetCrc(blockNo)
+		}
+		crcs = append(crcs, crc)
+	}
+	return
+}
+
+// VerifyPartialBlocks checks the blocks of the normal extent the range overlaps in part, at its
+// head and tail, by reading them in whole against the crc stored. The reader of the range gets
+// only the blocks it covers in whole verified by the crc sent with them. The block whose crc is
+// changed by a write meanwhile is skipped.
+func (s *ExtentStore) VerifyPartialBlocks(extentID uint64, offset, size int64) (err error) {
+	if IsTinyExtent(extentID) || size <= 0 {
+		return
+	}
+	var blocks []int64
+	if offset%util.BlockSize != 0 {
+		blocks = append(blocks, offset/util.BlockSize)
+	}
+	if end := offset + size; end%util.BlockSize != 0 {
+		if last := (end - 1) / util.BlockSize; len(blocks) == 0 || blocks[0] != last {
+			blocks = append(blocks, last)
+		}
+	}
+
+	var data []byte
+	for _, blockNo := range blocks {
+		blockOffset := blockNo * util.BlockSize
+		var crcs []uint32
+		if crcs, err = s.BlockCrcs(extentID, blockOffset, util.BlockSize); err != nil {
+			return
+		}
+		if len(crcs) == 0 || crcs[0] == 0 {
+			continue
+		}
+		if data == nil {
+			data = make([]byte, util.BlockSize)
+		}
+		var crc uint32
+		if crc, err = s.Read(extentID, blockOffset, util.BlockSize, data, false, false); err != nil {
+			return
+		}
+		if crc == crcs[0] {
+			continue
+		}
+		if recheck, _ := s.BlockCrcs(extentID, blockOffset, util.BlockSize); len(recheck) == 0 || recheck[0] != crcs[0] {
+			continue
+		}
+		return errors.Trace(BlockCrcMismatchError, "dp %v extent %v block %v expected crc %v actual %v",
+			s.partitionID, extentID, blockNo, crcs[0], crc)
+	}
+	return
+}
+
+// RepairBlock rewrites the block of the normal extent by the data matching crc, if the crc of the
+// block persisted is still stored. See Extent.RepairBlock.
+func (s *ExtentStore) RepairBlock(extentID uint64, blockOffset int64, data []byte, stored, crc uint32) (repaired bool, err error) {
+	s.stopMutex.RLock()
+	defer s.stopMutex.RUnlock()
+	if s.IsClosed() {
+		return false, ErrStoreAlreadyClosed
+	}
+	if IsTinyExtent(extentID) || blockOffset%util.BlockSize != 0 || len(data) < util.BlockSize {
+		return false, newParameterError("extent=%d offset=%d size=%d", extentID, blockOffset, len(data))
+	}
+	ei, _ := s.GetExtentInfo(extentID)
+	e, err := s.extentWithHeader(ei)
+	if err != nil {
+		return
+	}
+	stat.RecordStat(s.partitionID, "WriteRepair", s.dataPath)
+	if repaired, err = e.RepairBlock(blockOffset, data, stored, crc, s.PersistenceBlockCrc); err != nil {
+		return
+	}
+	ei.UpdateExtentInfo(e, 0)
+	return
+}
+
 type ExtentInfoArr []*ExtentInfo
 
 func (arr ExtentInfoArr) Len() int           { return len(arr) }
@@ -294,3 +294,238 @@ func TestExtentStores(t *testing.T) {
 		ExtentStoreTest(t, ty)
 	}
 }
+
+func TestExtentStoreBlockCrcs(t *testing.T) {
+	path, clean, err := getTestPathExtentStore()
+	require.NoError(t, err)
+	defer clean()
+	s, err := storage.NewExtentStore(path, 0, 1*util.GB, proto.PartitionTypeNormal, 0, true)
+	require.NoError(t, err)
+	defer s.Close()
+	id, err := s.NextExtentID()
+	require.NoError(t, err)
+	require.NoError(t, s.Create(id))
+
+	// the repair write covers two blocks and a half
+	data := make([]byte, 2*util.BlockSize+util.BlockSize/2)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	_, err = s.Write(&storage.WriteParam{
+		ExtentID:  id,
+		Size:      int64(len(data)),
+		Data:      data,
+		Crc:       crc32.ChecksumIEEE(data),
+		WriteType: storage.AppendWriteType,
+		IsSync:    true,
+		IsRepair:  true,
+	})
+	require.NoError(t, err)
+	crcs, err := s.BlockCrcs(id, 1, int64(len(data))-1)
+	require.NoError(t, err)
+	require.Equal(t, []uint32{
+		crc32.ChecksumIEEE(data[:util.BlockSize]),
+		crc32.ChecksumIEEE(data[util.BlockSize : 2*util.BlockSize]),
+		0,
+	}, crcs)
+
+	// the crc of the block punched is reset
+	require.NoError(t, s.MarkDelete(id, util.BlockSize, util.PageSize))
+	crcs, err = s.BlockCrcs(id, 0, 2*util.BlockSize)
+	require.NoError(t, err)
+	require.Equal(t, []uint32{crc32.ChecksumIEEE(data[:util.BlockSize]), 0}, crcs)
+}
+
+func TestExtentStoreRepairBlock(t *testing.T) {
+	path, clean, err := getTestPathExtentStore()
+	require.NoError(t, err)
+	defer clean()
+	s, err := storage.NewExtentStore(path, 0, 1*util.GB, proto.PartitionTypeNormal, 0, true)
+	require.NoError(t, err)
+	defer s.Close()
+	id, err := s.NextExtentID()
+	require.NoError(t, err)
+	require.NoError(t, s.Create(id))
+
+	data := make([]byte, util.BlockSize)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	crc := crc32.ChecksumIEEE(data)
+	_, err = s.Write(&storage.WriteParam{
+		ExtentID:  id,
+		Size:      int64(len(data)),
+		Data:      data,
+		Crc:       crc,
+		WriteType: storage.AppendWriteType,
+		IsSync:    true,
+	})
+	require.NoError(t, err)
+
+	// the block matching the crc is not rewritten
+	repaired, err := s.RepairBlock(id, 0, data, crc, crc)
+	require.NoError(t, err)
+	require.False(t, repaired)
+
+	fp, err := os.OpenFile(filepath.Join(path, fmt.Sprint(id)), os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = fp.WriteAt([]byte("corrupted"), 100)
+	require.NoError(t, err)
+	require.NoError(t, fp.Close())
+
+	// the block written since checked is not rewritten
+	repaired, err = s.RepairBlock(id, 0, data, 0, crc)
+	require.NoError(t, err)
+	require.False(t, repaired)
+
+	repaired, err = s.RepairBlock(id, 0, data, crc, crc)
+	require.NoError(t, err)
+	require.True(t, repaired)
+	read := make([]byte, util.BlockSize)
+	_, err = s.Read(id, 0, util.BlockSize, read, false, false)
+	require.NoError(t, err)
+	require.Equal(t, data, read)
+}
+
+func TestExtentStoreVerifyPartialBlocks(t *testing.T) {
+	path, clean, err := getTestPathExtentStore()
+	require.NoError(t, err)
+	defer clean()
+	s, err := storage.NewExtentStore(path, 0, 1*util.GB, proto.PartitionTypeNormal, 0, true)
+	require.NoError(t, err)
+	defer s.Close()
+	id, err := s.NextExtentID()
+	require.NoError(t, err)
+	require.NoError(t, s.Create(id))
+
+	data := make([]byte, 2*util.BlockSize)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	_, err = s.Write(&storage.WriteParam{
+		ExtentID:  id,
+		Size:      int64(len(data)),
+		Data:      data,
+		Crc:       crc32.ChecksumIEEE(data),
+		WriteType: storage.AppendWriteType,
+		IsSync:    true,
+		IsRepair:  true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, s.VerifyPartialBlocks(id, 10, 2*util.BlockSize-20))
+
+	fp, err := os.OpenFile(filepath.Join(path, fmt.Sprint(id)), os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = fp.WriteAt([]byte("corrupted"), util.BlockSize+100)
+	require.NoError(t, err)
+	require.NoError(t, fp.Close())
+
+	// the block read in whole is left to the reader
+	require.NoError(t, s.VerifyPartialBlocks(id, 0, 2*util.BlockSize))
+	require.NoError(t, s.VerifyPartialBlocks(id, 10, util.BlockSize-10))
+	// the corrupted block at the head or tail of the range
+	err = s.VerifyPartialBlocks(id, util.BlockSize+10, 4096)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), storage.BlockCrcMismatchError.Error())
+	err = s.VerifyPartialBlocks(id, 10, 2*util.BlockSize-20)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), storage.BlockCrcMismatchError.Error())
+}
+
+func TestExtentStoreSharedExtents(t *testing.T) {
+	path, clean, err := getTestPathExtentStore()
+	require.NoError(t, err)
+	defer clean()
+	s, err := storage.NewExtentStore(path, 0, 1*util.GB, proto.PartitionTypeNormal, 0, true)
+	require.NoError(t, err)
+	require.False(t, s.IsSharedExtent(1024))
+
+	require.NoError(t, s.MarkSharedExtents([]uint64{1024, 1025, 1}))
+	require.NoError(t, s.MarkSharedExtents([]uint64{1025, 1026}))
+	require.True(t, s.IsSharedExtent(1024))
+	require.True(t, s.IsSharedExtent(1026))
+	// tiny extents are never shared
+	require.False(t, s.IsSharedExtent(1))
+	s.Close()
+
+	s, err = storage.NewExtentStore(path, 0, 1*util.GB, proto.PartitionTypeNormal, 0, false)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []uint64{1024, 1025, 1026}, s.GetSharedExtents())
+
+	// the extent deleted is removed from the shared ones
+	require.NoError(t, s.Create(1024))
+	require.NoError(t, s.MarkDelete(1024, 0, 0))
+	require.NoError(t, s.UnmarkSharedExtent(1026))
+	require.False(t, s.IsSharedExtent(1024))
+	require.ElementsMatch(t, []uint64{1025}, s.GetSharedExtents())
+
+	// the file is compacted once the most records are removed
+	ids := make([]uint64, 2000)
+	for i := range ids {
+		ids[i] = uint64(2000 + i)
+	}
+	require.NoError(t, s.MarkSharedExtents(ids))
+	for _, id := range ids[:1500] {
+		require.NoError(t, s.UnmarkSharedExtent(id))
+	}
+	s.Close()
+	info, err := os.Stat(filepath.Join(path, storage.SharedExtentsFileName))
+	require.NoError(t, err)
+	require.Less(t, info.Size(), int64(3000*8))
+
+	s, err = storage.NewExtentStore(path, 0, 1*util.GB, proto.PartitionTypeNormal, 0, false)
+	require.NoError(t, err)
+	defer s.Close()
+	require.Len(t, s.GetSharedExtents(), 501)
+	require.True(t, s.IsSharedExtent(1025))
+	require.True(t, s.IsSharedExtent(3999))
+	require.False(t, s.IsSharedExtent(2000))
+}
+
+func TestExtentStoreSharedExtentHolds(t *testing.T) {
+	path, clean, err := getTestPathExtentStore()
+	require.NoError(t, err)
+	defer clean()
+	s, err := storage.NewExtentStore(path, 0, 1*util.GB, proto.PartitionTypeNormal, 0, true)
+	require.NoError(t, err)
+	require.NoError(t, s.Create(1024))
+	require.NoError(t, s.Create(1025))
+
+	require.NoError(t, s.HoldSharedExtents(7, []uint64{1024, 1025}))
+	require.NoError(t, s.HoldSharedExtents(8, []uint64{1024}))
+	require.True(t, s.IsSharedExtent(1024))
+	require.True(t, s.IsHeldExtent(1025))
+
+	// the deletion of the extent held is deferred
+	require.NoError(t, s.MarkDelete(1024, 0, 0))
+	require.True(t, s.HasExtent(1024))
+	require.NoError(t, s.ReleaseSharedExtents([]uint64{7}, []uint64{1024}))
+	require.True(t, s.HasExtent(1024))
+	s.Close()
+
+	s, err = storage.NewExtentStore(path, 0, 1*util.GB, proto.PartitionTypeNormal, 0, false)
+	require.NoError(t, err)
+	holders, deletes := s.GetSharedHolds()
+	require.Equal(t, map[uint64][]uint64{1024: {8}, 1025: {7}}, holders)
+	require.Equal(t, map[uint64][]storage.SharedExtentDelete{1024: {{}}}, deletes)
+
+	// the extent is deleted once the last holder releases it
+	require.NoError(t, s.ReleaseSharedExtents([]uint64{8}, nil))
+	require.False(t, s.HasExtent(1024))
+	require.False(t, s.IsHeldExtent(1024))
+	require.True(t, s.HasExtent(1025))
+
+	// the extent released with no deletion deferred is left to the source
+	require.NoError(t, s.ReleaseSharedExtents([]uint64{7}, nil))
+	require.True(t, s.HasExtent(1025))
+	require.False(t, s.IsHeldExtent(1025))
+	require.NoError(t, s.MarkDelete(1025, 0, 0))
+	require.False(t, s.HasExtent(1025))
+	s.Close()
+
+	s, err = storage.NewExtentStore(path, 0, 1*util.GB, proto.PartitionTypeNormal, 0, false)
+	require.NoError(t, err)
+	defer s.Close()
+	holders, _ = s.GetSharedHolds()
+	require.Empty(t, holders)
+}
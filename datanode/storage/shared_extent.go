@@ -0,0 +1,516 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"encoding/binary"
+	"os"
+	"path"
+
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// The normal extents shared by the files cloned in the metanode are marked before the clone is
+// committed, and are never overwritten in place afterwards, so that the writers which don't know
+// the extent is shared copy on write. The ids are appended to the file as they are marked, and the
+// id of the extent deleted is appended with the removed flag. The file is rewritten with the ids
+// left once the records removed are the most of it.
+//
+// The extents shared with the clones of the volume are also held by the metanode partitions of
+// the clones referring to them, which are the refs of the extent across the volumes. The deletions
+// of the extent held, by the source volume, are deferred until the last holder releases it, and are
+// applied then, so the extent is freed once neither the source nor any clone refers to it. The
+// holds are recorded in another file the same way, and the deletions are applied again when the
+// file is loaded, which is harmless since the ids are never reused.
+
+const (
+	SharedExtentsFileName   = "SHARED_EXTENTS"
+	sharedExtentsTmpName    = ".SHARED_EXTENTS.tmp"
+	sharedExtentsRecordSize = 8
+	sharedExtentRemoved     = uint64(1) << 63
+	sharedExtentsMinCompact = 1024 // the records in the file before it is compacted
+
+	SharedHoldsFileName   = "SHARED_EXTENT_HOLDS"
+	sharedHoldsTmpName    = ".SHARED_EXTENT_HOLDS.tmp"
+	sharedHoldsRecordSize = 25
+)
+
+// the kinds of the records of the holds
+const (
+	holdRecordHold    uint8 = 1 // the extent is held by the holder
+	holdRecordRelease uint8 = 2 // the holder releases the extent
+	holdRecordDelete  uint8 = 3 // the deletion of the range of the extent is deferred
+)
+
+// SharedExtentDelete is the deletion of the range of the extent held, deferred until the extent
+// is released by all the holders.
+type SharedExtentDelete struct {
+	Offset int64 `json:"off"`
+	Size   int64 `json:"size"`
+}
+
+type sharedHold struct {
+	holders map[uint64]struct{}
+	deletes []SharedExtentDelete
+}
+
+type holdRecord struct {
+	kind     uint8
+	extentID uint64
+	arg1     uint64 // the holder, or the offset of the deletion
+	arg2     uint64 // the size of the deletion
+}
+
+func (s *ExtentStore) loadSharedExtents() (err error) {
+	s.sharedExtents = make(map[uint64]struct{})
+	data, err := os.ReadFile(path.Join(s.dataPath, SharedExtentsFileName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return
+	}
+	for off := 0; off+sharedExtentsRecordSize <= len(data); off += sharedExtentsRecordSize {
+		id := binary.BigEndian.Uint64(data[off:])
+		if id&sharedExtentRemoved != 0 {
+			delete(s.sharedExtents, id&^sharedExtentRemoved)
+		} else {
+			s.sharedExtents[id] = struct{}{}
+		}
+		s.sharedRecords++
+	}
+	log.LogInfof("[loadSharedExtents] dp(%v) shared extents count(%v) records(%v)", s.partitionID, len(s.sharedExtents), s.sharedRecords)
+	return
+}
+
+// appendSharedRecords appends the records to the file, with the lock held.
+func (s *ExtentStore) appendSharedRecords(ids []uint64) (err error) {
+	data := make([]byte, len(ids)*sharedExtentsRecordSize)
+	for i, id := range ids {
+		binary.BigEndian.PutUint64(data[i*sharedExtentsRecordSize:], id)
+	}
+	fp, err := os.OpenFile(path.Join(s.dataPath, SharedExtentsFileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o666)
+	if err != nil {
+		return
+	}
+	defer fp.Close()
+	if _, err = fp.Write(data); err != nil {
+		return
+	}
+	if err = fp.Sync(); err != nil {
+		return
+	}
+	s.sharedRecords += len(ids)
+	return
+}
+
+// compactSharedExtents rewrites the file with the ids left, with the lock held.
+func (s *ExtentStore) compactSharedExtents() (err error) {
+	data := make([]byte, 0, len(s.sharedExtents)*sharedExtentsRecordSize)
+	for id := range s.sharedExtents {
+		var buf [sharedExtentsRecordSize]byte
+		binary.BigEndian.PutUint64(buf[:], id)
+		data = append(data, buf[:]...)
+	}
+	tmpName := path.Join(s.dataPath, sharedExtentsTmpName)
+	fp, err := os.OpenFile(tmpName, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o666)
+	if err != nil {
+		return
+	}
+	if _, err = fp.Write(data); err == nil {
+		err = fp.Sync()
+	}
+	if closeErr := fp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpName)
+		return
+	}
+	if err = os.Rename(tmpName, path.Join(s.dataPath, SharedExtentsFileName)); err != nil {
+		return
+	}
+	s.sharedRecords = len(s.sharedExtents)
+	return
+}
+
+// MarkSharedExtents marks the normal extents shared, the tiny extents are never shared.
+func (s *ExtentStore) MarkSharedExtents(extentIDs []uint64) (err error) {
+	s.sharedMutex.Lock()
+	defer s.sharedMutex.Unlock()
+
+	ids := make([]uint64, 0, len(extentIDs))
+	for _, id := range extentIDs {
+		if _, ok := s.sharedExtents[id]; ok || IsTinyExtent(id) || id&sharedExtentRemoved != 0 {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return
+	}
+	if err = s.appendSharedRecords(ids); err != nil {
+		return
+	}
+	for _, id := range ids {
+		s.sharedExtents[id] = struct{}{}
+	}
+	return
+}
+
+// UnmarkSharedExtent removes the extent deleted from the shared ones.
+func (s *ExtentStore) UnmarkSharedExtent(extentID uint64) (err error) {
+	s.sharedMutex.Lock()
+	defer s.sharedMutex.Unlock()
+
+	if _, ok := s.sharedExtents[extentID]; !ok {
+		return
+	}
+	if s.sharedRecords >= sharedExtentsMinCompact && s.sharedRecords >= 2*len(s.sharedExtents) {
+		delete(s.sharedExtents, extentID)
+		if err = s.compactSharedExtents(); err != nil {
+			s.sharedExtents[extentID] = struct{}{}
+		}
+		return
+	}
+	if err = s.appendSharedRecords([]uint64{extentID | sharedExtentRemoved}); err != nil {
+		return
+	}
+	delete(s.sharedExtents, extentID)
+	return
+}
+
+func (s *ExtentStore) IsSharedExtent(extentID uint64) bool {
+	s.sharedMutex.RLock()
+	defer s.sharedMutex.RUnlock()
+	_, ok := s.sharedExtents[extentID]
+	return ok
+}
+
+// GetSharedExtents returns the ids of the shared extents.
+func (s *ExtentStore) GetSharedExtents() (extentIDs []uint64) {
+	s.sharedMutex.RLock()
+	defer s.sharedMutex.RUnlock()
+	extentIDs = make([]uint64, 0, len(s.sharedExtents))
+	for id := range s.sharedExtents {
+		extentIDs = append(extentIDs, id)
+	}
+	return
+}
+
+func (s *ExtentStore) loadSharedHolds() (err error) {
+	s.sharedHolds = make(map[uint64]*sharedHold)
+	data, err := os.ReadFile(path.Join(s.dataPath, SharedHoldsFileName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return
+	}
+	for off := 0; off+sharedHoldsRecordSize <= len(data); off += sharedHoldsRecordSize {
+		s.applySharedHoldRecord(holdRecord{
+			kind:     data[off],
+			extentID: binary.BigEndian.Uint64(data[off+1:]),
+			arg1:     binary.BigEndian.Uint64(data[off+9:]),
+			arg2:     binary.BigEndian.Uint64(data[off+17:]),
+		})
+		s.holdRecords++
+	}
+	// the deletions of the extents released before the restart may not be applied
+	for extentID, hold := range s.sharedHolds {
+		if len(hold.holders) == 0 {
+			s.releasedDeletes = append(s.releasedDeletes, releasedSharedExtent{extentID: extentID, deletes: hold.deletes})
+			delete(s.sharedHolds, extentID)
+		}
+	}
+	log.LogInfof("[loadSharedHolds] dp(%v) held extents count(%v) records(%v) released(%v)",
+		s.partitionID, len(s.sharedHolds), s.holdRecords, len(s.releasedDeletes))
+	return
+}
+
+// applySharedHoldRecord applies the record to the holds in memory, with the lock held. The hold
+// released by all the holders is left with no holder, to be removed by the caller.
+func (s *ExtentStore) applySharedHoldRecord(r holdRecord) {
+	hold, ok := s.sharedHolds[r.extentID]
+	switch r.kind {
+	case holdRecordHold:
+		if !ok {
+			hold = &sharedHold{holders: make(map[uint64]struct{})}
+			s.sharedHolds[r.extentID] = hold
+		}
+		hold.holders[r.arg1] = struct{}{}
+	case holdRecordRelease:
+		if ok {
+			delete(hold.holders, r.arg1)
+		}
+	case holdRecordDelete:
+		if ok {
+			hold.deletes = append(hold.deletes, SharedExtentDelete{Offset: int64(r.arg1), Size: int64(r.arg2)})
+		}
+	}
+}
+
+// appendSharedHoldRecords appends the records to the file, with the lock held.
+func (s *ExtentStore) appendSharedHoldRecords(records []holdRecord) (err error) {
+	fp, err := os.OpenFile(path.Join(s.dataPath, SharedHoldsFileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o666)
+	if err != nil {
+		return
+	}
+	defer fp.Close()
+	if _, err = fp.Write(marshalSharedHoldRecords(records)); err != nil {
+		return
+	}
+	if err = fp.Sync(); err != nil {
+		return
+	}
+	s.holdRecords += len(records)
+	return
+}
+
+func marshalSharedHoldRecords(records []holdRecord) []byte {
+	data := make([]byte, len(records)*sharedHoldsRecordSize)
+	for i, r := range records {
+		off := i * sharedHoldsRecordSize
+		data[off] = r.kind
+		binary.BigEndian.PutUint64(data[off+1:], r.extentID)
+		binary.BigEndian.PutUint64(data[off+9:], r.arg1)
+		binary.BigEndian.PutUint64(data[off+17:], r.arg2)
+	}
+	return data
+}
+
+// compactSharedHolds rewrites the file with the holds left, with the lock held.
+func (s *ExtentStore) compactSharedHolds() (err error) {
+	records := make([]holdRecord, 0, len(s.sharedHolds))
+	for extentID, hold := range s.sharedHolds {
+		for holder := range hold.holders {
+			records = append(records, holdRecord{kind: holdRecordHold, extentID: extentID, arg1: holder})
+		}
+		for _, d := range hold.deletes {
+			records = append(records, holdRecord{kind: holdRecordDelete, extentID: extentID, arg1: uint64(d.Offset), arg2: uint64(d.Size)})
+		}
+	}
+	tmpName := path.Join(s.dataPath, sharedHoldsTmpName)
+	fp, err := os.OpenFile(tmpName, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o666)
+	if err != nil {
+		return
+	}
+	if _, err = fp.Write(marshalSharedHoldRecords(records)); err == nil {
+		err = fp.Sync()
+	}
+	if closeErr := fp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpName)
+		return
+	}
+	if err = os.Rename(tmpName, path.Join(s.dataPath, SharedHoldsFileName)); err != nil {
+		return
+	}
+	s.holdRecords = len(records)
+	return
+}
+
+// HoldSharedExtents marks the normal extents shared and held by the holder, which is the metanode
+// partition of the clone referring to them.
+func (s *ExtentStore) HoldSharedExtents(holder uint64, extentIDs []uint64) (err error) {
+	if err = s.MarkSharedExtents(extentIDs); err != nil {
+		return
+	}
+	s.sharedMutex.Lock()
+	defer s.sharedMutex.Unlock()
+
+	records := make([]holdRecord, 0, len(extentIDs))
+	for _, id := range extentIDs {
+		if IsTinyExtent(id) {
+			continue
+		}
+		if hold, ok := s.sharedHolds[id]; ok {
+			if _, ok = hold.holders[holder]; ok {
+				continue
+			}
+		}
+		records = append(records, holdRecord{kind: holdRecordHold, extentID: id, arg1: holder})
+	}
+	if len(records) == 0 {
+		return
+	}
+	if err = s.appendSharedHoldRecords(records); err != nil {
+		return
+	}
+	for _, r := range records {
+		s.applySharedHoldRecord(r)
+	}
+	return
+}
+
+// ReleaseSharedExtents releases the extents held by the holders, or all the extents they hold if
+// extentIDs is empty. The deletions deferred are applied to the extents no longer held.
+func (s *ExtentStore) ReleaseSharedExtents(holders []uint64, extentIDs []uint64) (err error) {
+	s.sharedMutex.Lock()
+	if len(extentIDs) == 0 {
+		for id := range s.sharedHolds {
+			extentIDs = append(extentIDs, id)
+		}
+	}
+	records := make([]holdRecord, 0, len(extentIDs))
+	for _, id := range extentIDs {
+		hold, ok := s.sharedHolds[id]
+		if !ok {
+			continue
+		}
+		for _, holder := range holders {
+			if _, ok = hold.holders[holder]; ok {
+				records = append(records, holdRecord{kind: holdRecordRelease, extentID: id, arg1: holder})
+			}
+		}
+	}
+	if len(records) > 0 {
+		if err = s.appendSharedHoldRecords(records); err != nil {
+			s.sharedMutex.Unlock()
+			return
+		}
+	}
+	var released []releasedSharedExtent
+	for _, r := range records {
+		s.applySharedHoldRecord(r)
+		if hold := s.sharedHolds[r.extentID]; hold != nil && len(hold.holders) == 0 {
+			released = append(released, releasedSharedExtent{extentID: r.extentID, deletes: hold.deletes})
+			delete(s.sharedHolds, r.extentID)
+		}
+	}
+	if s.holdRecords >= sharedExtentsMinCompact && s.holdRecords >= 2*s.sharedHoldsLen() {
+		if err = s.compactSharedHolds(); err != nil {
+			log.LogWarnf("[ReleaseSharedExtents] store(%v) compact holds err(%v)", s.dataPath, err)
+			err = nil
+		}
+	}
+	s.sharedMutex.Unlock()
+	return s.applyReleasedDeletes(released)
+}
+
+type releasedSharedExtent struct {
+	extentID uint64
+	deletes  []SharedExtentDelete
+}
+
+// sharedHoldsLen returns the number of the records of the holds in memory, with the lock held.
+func (s *ExtentStore) sharedHoldsLen() (n int) {
+	for _, hold := range s.sharedHolds {
+		n += len(hold.holders) + len(hold.deletes)
+	}
+	return
+}
+
+// applyReleasedDeletes applies the deletions deferred of the extents released by all the holders.
+func (s *ExtentStore) applyReleasedDeletes(released []releasedSharedExtent) (err error) {
+	for _, r := range released {
+		for _, d := range r.deletes {
+			if err = s.MarkDelete(r.extentID, d.Offset, d.Size); err != nil {
+				log.LogErrorf("[applyReleasedDeletes] store(%v) extent(%v) offset(%v) size(%v) err(%v)",
+					s.dataPath, r.extentID, d.Offset, d.Size, err)
+				return
+			}
+		}
+		if len(r.deletes) > 0 {
+			log.LogInfof("[applyReleasedDeletes] store(%v) extent(%v) released, deletions(%v) applied", s.dataPath, r.extentID, r.deletes)
+		}
+	}
+	return
+}
+
+// deferSharedDelete defers the deletion of the range of the extent if it is held, and returns
+// true if it is deferred.
+func (s *ExtentStore) deferSharedDelete(extentID uint64, offset, size int64) (deferred bool, err error) {
+	s.sharedMutex.Lock()
+	defer s.sharedMutex.Unlock()
+	hold, ok := s.sharedHolds[extentID]
+	if !ok {
+		return false, nil
+	}
+	r := holdRecord{kind: holdRecordDelete, extentID: extentID, arg1: uint64(offset), arg2: uint64(size)}
+	if err = s.appendSharedHoldRecords([]holdRecord{r}); err != nil {
+		return
+	}
+	s.applySharedHoldRecord(r)
+	log.LogInfof("[deferSharedDelete] store(%v) extent(%v) offset(%v) size(%v) deferred, holders(%v)",
+		s.dataPath, extentID, offset, size, len(hold.holders))
+	return true, nil
+}
+
+// GetSharedHolds returns the holders and the deletions deferred of the extents held.
+func (s *ExtentStore) GetSharedHolds() (holders map[uint64][]uint64, deletes map[uint64][]SharedExtentDelete) {
+	s.sharedMutex.RLock()
+	defer s.sharedMutex.RUnlock()
+	holders = make(map[uint64][]uint64, len(s.sharedHolds))
+	deletes = make(map[uint64][]SharedExtentDelete)
+	for id, hold := range s.sharedHolds {
+		for holder := range hold.holders {
+			holders[id] = append(holders[id], holder)
+		}
+		if len(hold.deletes) > 0 {
+			deletes[id] = append([]SharedExtentDelete(nil), hold.deletes...)
+		}
+	}
+	return
+}
+
+// AddSharedDeletes defers the deletions of the extents held, which are sent by the snapshot of
+// the raft leader. The ones already deferred are skipped.
+func (s *ExtentStore) AddSharedDeletes(deletes map[uint64][]SharedExtentDelete) (err error) {
+	s.sharedMutex.Lock()
+	defer s.sharedMutex.Unlock()
+	records := make([]holdRecord, 0)
+	for id, ds := range deletes {
+		hold, ok := s.sharedHolds[id]
+		if !ok {
+			continue
+		}
+		for _, d := range ds {
+			if containsSharedDelete(hold.deletes, d) {
+				continue
+			}
+			records = append(records, holdRecord{kind: holdRecordDelete, extentID: id, arg1: uint64(d.Offset), arg2: uint64(d.Size)})
+		}
+	}
+	if len(records) == 0 {
+		return
+	}
+	if err = s.appendSharedHoldRecords(records); err != nil {
+		return
+	}
+	for _, r := range records {
+		s.applySharedHoldRecord(r)
+	}
+	return
+}
+
+func containsSharedDelete(deletes []SharedExtentDelete, d SharedExtentDelete) bool {
+	for _, e := range deletes {
+		if e == d {
+			return true
+		}
+	}
+	return false
+}
+
+// IsHeldExtent returns true if the extent is held by some clone.
+func (s *ExtentStore) IsHeldExtent(extentID uint64) bool {
+	s.sharedMutex.RLock()
+	defer s.sharedMutex.RUnlock()
+	_, ok := s.sharedHolds[extentID]
+	return ok
+}
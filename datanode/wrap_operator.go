@@ -225,6 +225,16 @@ func (s *DataNode) OperatePacket(p *repl.Packet, c net.Conn) (err error) {
 		s.handlePacketToQueryBadDiskRecoverProgress(p)
 	case proto.OpDeleteBackupDirectories:
 		s.handlePacketToOpDeleteBackupDirectories(p)
+	case proto.OpRepairExtentBlocks:
+		s.handlePacketToRepairExtentBlocks(p)
+	case proto.OpScrubExtentBlocks:
+		s.handlePacketToScrubExtentBlocks(p)
+	case proto.OpMarkSharedExtents:
+		s.handleMarkSharedExtentsPacket(p)
+	case proto.OpReleaseSharedExtents:
+		s.handleReleaseSharedExtentsPacket(p)
+	case proto.OpReleaseCloneHolds:
+		s.handlePacketToReleaseCloneHolds(p)
 	default:
 		p.PackErrorBody(repl.ErrorUnknownOp.Error(), repl.ErrorUnknownOp.Error()+strconv.Itoa(int(p.Opcode)))
 	}
@@ -1055,6 +1065,12 @@ func (s *DataNode) handleRandomWritePacket(p *repl.Packet) {
 				log.LogDebugf("action[handleRandomWritePacket opcod %v seq %v dpid %v resultCode %v extid %v", p.Opcode, p.VerSeq, p.PartitionID, p.ResultCode, p.ExtentID)
 				return
 			}
+			if p.ResultCode == proto.OpTryOtherExtent {
+				// the extent is shared by the files cloned, the client has to copy on write
+				p.PackErrorBody(ActionWrite, storage.SharedExtentError.Error())
+				p.ResultCode = proto.OpTryOtherExtent
+				return
+			}
 			p.PacketOkReply()
 		}
 	}()
@@ -1109,6 +1125,108 @@ func (s *DataNode) handleRandomWritePacket(p *repl.Packet) {
 		p.Opcode, p.VerSeq, p.PartitionID, partition.verSeq, err, p.ResultCode)
 }
 
+// handleMarkSharedExtentsPacket marks the extents shared through raft, sent by the metanode
+// leader to the raft leader of the data partition before the files sharing them are cloned.
+func (s *DataNode) handleMarkSharedExtentsPacket(p *repl.Packet) {
+	var err error
+	defer func() {
+		if err != nil {
+			p.PackErrorBody(ActionMarkSharedExtents, err.Error())
+		} else {
+			p.PacketOkReply()
+		}
+	}()
+
+	partition := p.Object.(*DataPartition)
+	if !partition.isNormalType() {
+		err = raft.ErrStopped
+		return
+	}
+	if _, isLeader := partition.IsRaftLeader(); !isLeader {
+		err = raft.ErrNotLeader
+		return
+	}
+	req := new(proto.MarkSharedExtentsRequest)
+	if err = json.Unmarshal(p.Data[:p.Size], req); err != nil {
+		return
+	}
+	if req.Holder != 0 {
+		err = partition.HoldSharedExtents(req.Holder, req.ExtentIds)
+		return
+	}
+	err = partition.MarkSharedExtents(req.ExtentIds)
+}
+
+// handleReleaseSharedExtentsPacket releases the extents held through raft, sent by the metanode
+// leader of the clone once the clone refers to them no longer.
+func (s *DataNode) handleReleaseSharedExtentsPacket(p *repl.Packet) {
+	var err error
+	defer func() {
+		if err != nil {
+			p.PackErrorBody(ActionReleaseSharedExtents, err.Error())
+		} else {
+			p.PacketOkReply()
+		}
+	}()
+
+	partition := p.Object.(*DataPartition)
+	if !partition.isNormalType() {
+		err = raft.ErrStopped
+		return
+	}
+	if _, isLeader := partition.IsRaftLeader(); !isLeader {
+		err = raft.ErrNotLeader
+		return
+	}
+	req := new(proto.ReleaseSharedExtentsRequest)
+	if err = json.Unmarshal(p.Data[:p.Size], req); err != nil {
+		return
+	}
+	if len(req.ExtentIds) == 0 {
+		err = fmt.Errorf("no extent to be released")
+		return
+	}
+	err = partition.ReleaseSharedExtents(req.Holders, req.ExtentIds)
+}
+
+// handlePacketToReleaseCloneHolds releases all the extents held by the meta partitions of the
+// clone deleted, sent by the master to the raft leader of the data partition of the source.
+func (s *DataNode) handlePacketToReleaseCloneHolds(p *repl.Packet) {
+	var err error
+	defer func() {
+		if err != nil {
+			p.PackErrorBody(ActionReleaseCloneHolds, err.Error())
+		} else {
+			p.PacketOkReply()
+		}
+	}()
+
+	task := &proto.AdminTask{}
+	if err = json.Unmarshal(p.Data, task); err != nil {
+		return
+	}
+	data, err := json.Marshal(task.Request)
+	if err != nil {
+		return
+	}
+	req := new(proto.ReleaseSharedExtentsRequest)
+	if err = json.Unmarshal(data, req); err != nil {
+		return
+	}
+	p.AddMesgLog(string(data))
+	partition := s.space.Partition(req.PartitionId)
+	if partition == nil {
+		// the data partition is deleted with the extents
+		log.LogWarnf("action[handlePacketToReleaseCloneHolds] dp(%v) not found", req.PartitionId)
+		return
+	}
+	if _, isLeader := partition.IsRaftLeader(); !isLeader {
+		err = raft.ErrNotLeader
+		return
+	}
+	err = partition.ReleaseSharedExtents(req.Holders, nil)
+}
+
 func (s *DataNode) handleStreamReadPacket(p *repl.Packet, connect net.Conn, isRepairRead bool) {
 	var err error
 	defer func() {
@@ -1485,7 +1603,8 @@ func (s *DataNode) handlePacketToAddDataPartitionRaftMember(p *repl.Packet) {
 	defer dp.setRestoreReplicaFinish()
 	p.PartitionID = req.PartitionId
 	// check if peer is already added
-	if dp.IsExistReplicaWithNodeId(req.AddPeer.Addr, req.AddPeer.ID) {
+	changeType, ok := dp.addPeerChangeType(req.AddPeer)
+	if !ok {
 		if err = dp.hasNodeIDConflict(req.AddPeer.Addr, req.AddPeer.ID); err != nil {
 			log.LogWarnf("action[handlePacketToAddDataPartitionRaftMember] partition %v node id conflict: %v",
 				req.PartitionId, err)
@@ -1510,7 +1629,7 @@ func (s *DataNode) handlePacketToAddDataPartitionRaftMember(p *repl.Packet) {
 	log.LogInfof("action[handlePacketToAddDataPartitionRaftMember] before ChangeRaftMember %v which is sync. partition id %v", req.AddPeer, req.PartitionId)
 
 	if req.AddPeer.ID != 0 {
-		_, err = dp.ChangeRaftMember(raftProto.ConfAddNode, raftProto.Peer{ID: req.AddPeer.ID}, reqData)
+		_, err = dp.ChangeRaftMember(changeType, raftProto.Peer{ID: req.AddPeer.ID}, reqData)
 		if err != nil {
 			return
 		}
@@ -1765,6 +1884,75 @@ func (s *DataNode) handlePacketToStopDataPartitionRepair(p *repl.Packet) {
 	log.LogInfof("action[handlePacketToStopDataPartitionRepair] %v stop %v success", request.PartitionId, request.Stop)
 }
 
+func (s *DataNode) handlePacketToRepairExtentBlocks(p *repl.Packet) {
+	var repaired int
+	task := &proto.AdminTask{}
+	err := json.Unmarshal(p.Data, task)
+	defer func() {
+		if err != nil {
+			p.PackErrorBody(ActionRepairExtentBlocks, err.Error())
+		} else {
+			buf := make([]byte, 8)
+			binary.BigEndian.PutUint64(buf, uint64(repaired))
+			p.PacketOkWithBody(buf)
+		}
+	}()
+	if err != nil {
+		return
+	}
+	request := &proto.RepairExtentBlocksRequest{}
+	if task.OpCode != proto.OpRepairExtentBlocks {
+		err = fmt.Errorf("action[handlePacketToRepairExtentBlocks] illegal opcode ")
+		log.LogWarnf("action[handlePacketToRepairExtentBlocks] illegal opcode ")
+		return
+	}
+
+	bytes, _ := json.Marshal(task.Request)
+	p.AddMesgLog(string(bytes))
+	err = json.Unmarshal(bytes, request)
+	if err != nil {
+		return
+	}
+	dp := s.space.Partition(request.PartitionId)
+	if dp == nil {
+		err = proto.ErrDataPartitionNotExists
+		log.LogWarnf("action[handlePacketToRepairExtentBlocks] cannot find dp %v", request.PartitionId)
+		return
+	}
+	repaired, err = dp.repairExtentBlocks(request.ExtentId, request.Offset, request.Size, request.BlockCrcs)
+	if err != nil {
+		log.LogWarnf("action[handlePacketToRepairExtentBlocks] dp %v extent %v offset %v size %v repaired %v blocks, err %v",
+			request.PartitionId, request.ExtentId, request.Offset, request.Size, repaired, err)
+		return
+	}
+	log.LogInfof("action[handlePacketToRepairExtentBlocks] dp %v extent %v offset %v size %v repaired %v blocks",
+		request.PartitionId, request.ExtentId, request.Offset, request.Size, repaired)
+}
+
+func (s *DataNode) handlePacketToScrubExtentBlocks(p *repl.Packet) {
+	var (
+		buf  []byte
+		err  error
+		resp = &proto.ScrubExtentBlocksResponse{}
+	)
+	defer func() {
+		if err != nil {
+			p.PackErrorBody(ActionScrubExtentBlocks, err.Error())
+		} else {
+			p.PacketOkWithByte(buf)
+		}
+	}()
+	request := &proto.ScrubExtentBlocksRequest{}
+	if err = json.Unmarshal(p.Data[:p.Size], request); err != nil {
+		return
+	}
+	partition := p.Object.(*DataPartition)
+	if resp.ExtentSize, resp.Crcs, err = partition.scrubExtentBlocks(request.ExtentId, request.Offset, request.Size); err != nil {
+		return
+	}
+	buf, err = json.Marshal(resp)
+}
+
 func (s *DataNode) handlePacketToRecoverDataReplicaMeta(p *repl.Packet) {
 	task := &proto.AdminTask{}
 	err := json.Unmarshal(p.Data, task)
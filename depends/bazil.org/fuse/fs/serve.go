@@ -294,8 +294,6 @@ func nodeAttr(ctx context.Context, n Node, attr *fuse.Attr) error {
 // Other FUSE requests can be handled by implementing methods from the
 // Handle* interfaces. The most common to implement are HandleReader,
 // HandleReadDirer, and HandleWriter.
-//
-// TODO implement methods: Getlk, Setlk, Setlkw
 type Handle interface {
 }
 
@@ -306,6 +304,34 @@ type HandleFlusher interface {
 	Flush(ctx context.Context, req *fuse.FlushRequest) error
 }
 
+type HandleFallocater interface {
+	// Fallocate preallocates, deallocates or zeroes the byte range,
+	// see fallocate(2) for the modes in req.Mode.
+	Fallocate(ctx context.Context, req *fuse.FallocateRequest) error
+}
+
+// HandleCopyFileRanger copies the byte range of the handle into the
+// handle req.HandleOut, returning the number of bytes copied. The
+// error fuse.ENOTSUP makes the kernel copy the data itself.
+type HandleCopyFileRanger interface {
+	CopyFileRange(ctx context.Context, req *fuse.CopyFileRangeRequest, out Handle) (uint64, error)
+}
+
+// HandleLocker handles the file locks, it is used only if the
+// filesystem is mounted with fuse.LockingPOSIX or fuse.LockingFlock,
+// otherwise the locks are handled locally by the kernel.
+type HandleLocker interface {
+	// Setlk acquires or releases the lock on the byte range. If the
+	// lock is held by others, it returns EAGAIN, or waits until the
+	// lock is granted if req.Wait is set.
+	Setlk(ctx context.Context, req *fuse.SetlkRequest) error
+
+	// Getlk returns a lock conflicting with the given one in
+	// resp.Lock, or leaves resp.Lock.Type as fuse.LockUnlock if there
+	// is not any.
+	Getlk(ctx context.Context, req *fuse.GetlkRequest, resp *fuse.GetlkResponse) error
+}
+
 type HandleReadAller interface {
 	ReadAll(ctx context.Context) ([]byte, error)
 }
@@ -1929,6 +1955,78 @@ func (c *Server) handleRequest(ctx context.Context, node Node, snode *serveNode,
 		r.Respond()
 		return nil
 
+	case *fuse.FallocateRequest:
+		shandle := c.getHandle(r.Handle)
+		if shandle == nil {
+			return fuse.ESTALE
+		}
+		h, ok := shandle.handle.(HandleFallocater)
+		if !ok {
+			return fuse.ENOSYS
+		}
+		if err := h.Fallocate(ctx, r); err != nil {
+			return err
+		}
+		done(nil)
+		r.Respond()
+		return nil
+
+	case *fuse.CopyFileRangeRequest:
+		shandle := c.getHandle(r.Handle)
+		if shandle == nil {
+			return fuse.ESTALE
+		}
+		outHandle := c.getHandle(r.HandleOut)
+		if outHandle == nil {
+			return fuse.ESTALE
+		}
+		h, ok := shandle.handle.(HandleCopyFileRanger)
+		if !ok {
+			return fuse.ENOTSUP
+		}
+		size, err := h.CopyFileRange(ctx, r, outHandle.handle)
+		if err != nil {
+			return err
+		}
+		done(size)
+		r.Respond(size)
+		return nil
+
+	case *fuse.SetlkRequest:
+		shandle := c.getHandle(r.Handle)
+		if shandle == nil {
+			return fuse.ESTALE
+		}
+		h, ok := shandle.handle.(HandleLocker)
+		if !ok {
+			return fuse.ENOSYS
+		}
+		if err := h.Setlk(ctx, r); err != nil {
+			return err
+		}
+		done(nil)
+		r.Respond()
+		return nil
+
+	case *fuse.GetlkRequest:
+		shandle := c.getHandle(r.Handle)
+		if shandle == nil {
+			return fuse.ESTALE
+		}
+		h, ok := shandle.handle.(HandleLocker)
+		if !ok {
+			return fuse.ENOSYS
+		}
+		s := &fuse.GetlkResponse{
+			Lock: fuse.FileLock{Type: fuse.LockUnlock},
+		}
+		if err := h.Getlk(ctx, r, s); err != nil {
+			return err
+		}
+		done(s)
+		r.Respond(s)
+		return nil
+
 	case *fuse.ReleaseRequest:
 		shandle := c.getHandle(r.Handle)
 		if shandle == nil {
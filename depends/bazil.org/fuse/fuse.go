@@ -980,12 +980,39 @@ loop:
 			Flags:        InitFlags(in.Flags),
 		}
 
-	case opGetlk:
-		panic("opGetlk")
-	case opSetlk:
-		panic("opSetlk")
-	case opSetlkw:
-		panic("opSetlkw")
+	case opGetlk, opSetlk, opSetlkw:
+		in := (*lkIn)(m.data())
+		if m.len() < lkInSize(c.proto) {
+			goto corrupt
+		}
+		lk := FileLock{
+			Start: in.Lk.Start,
+			End:   in.Lk.End,
+			Type:  LockType(in.Lk.Type),
+			Pid:   in.Lk.Pid,
+		}
+		var flags LockFlags
+		if c.proto.GE(Protocol{7, 9}) {
+			flags = LockFlags(in.LkFlags)
+		}
+		if m.hdr.Opcode == opGetlk {
+			req = &GetlkRequest{
+				Header:    m.Header(),
+				Handle:    HandleID(in.Fh),
+				LockOwner: in.Owner,
+				Lock:      lk,
+				LockFlags: flags,
+			}
+		} else {
+			req = &SetlkRequest{
+				Header:    m.Header(),
+				Handle:    HandleID(in.Fh),
+				LockOwner: in.Owner,
+				Lock:      lk,
+				LockFlags: flags,
+				Wait:      m.hdr.Opcode == opSetlkw,
+			}
+		}
 
 	case opAccess:
 		in := (*accessIn)(m.data())
@@ -1032,6 +1059,35 @@ loop:
 	case opBmap:
 		panic("opBmap")
 
+	case opFallocate:
+		in := (*fallocateIn)(m.data())
+		if m.len() < unsafe.Sizeof(*in) {
+			goto corrupt
+		}
+		req = &FallocateRequest{
+			Header: m.Header(),
+			Handle: HandleID(in.Fh),
+			Offset: in.Offset,
+			Length: in.Length,
+			Mode:   FallocateFlags(in.Mode),
+		}
+
+	case opCopyFileRange:
+		in := (*copyFileRangeIn)(m.data())
+		if m.len() < unsafe.Sizeof(*in) {
+			goto corrupt
+		}
+		req = &CopyFileRangeRequest{
+			Header:    m.Header(),
+			Handle:    HandleID(in.FhIn),
+			Offset:    in.OffIn,
+			NodeOut:   NodeID(in.NodeidOut),
+			HandleOut: HandleID(in.FhOut),
+			OffsetOut: in.OffOut,
+			Len:       in.Len,
+			Flags:     in.Flags,
+		}
+
 	case opDestroy:
 		req = &DestroyRequest{
 			Header: m.Header(),
@@ -1825,7 +1881,7 @@ type ReleaseRequest struct {
 	Handle       HandleID
 	Flags        OpenFlags // flags from OpenRequest
 	ReleaseFlags ReleaseFlags
-	LockOwner    uint32
+	LockOwner    uint64
 }
 
 var _ = Request(&ReleaseRequest{})
@@ -2125,6 +2181,151 @@ func (r *FlushRequest) Respond() {
 	r.respond(buf)
 }
 
+// A FallocateRequest asks to allocate, deallocate or zero the byte
+// range of an opened file, as fallocate(2) does.
+type FallocateRequest struct {
+	Header `json:"-"`
+	Handle HandleID
+	Offset uint64
+	Length uint64
+	Mode   FallocateFlags
+}
+
+var _ = Request(&FallocateRequest{})
+
+func (r *FallocateRequest) String() string {
+	return fmt.Sprintf("Fallocate [%s] %v %d @%d mode=%v", &r.Header, r.Handle, r.Length, r.Offset, r.Mode)
+}
+
+// Respond replies to the request, indicating that the fallocate succeeded.
+func (r *FallocateRequest) Respond() {
+	buf := newBuffer(0)
+	r.respond(buf)
+}
+
+// A CopyFileRangeRequest asks to copy the byte range of an opened
+// file into another opened file of the filesystem, as
+// copy_file_range(2) does.
+type CopyFileRangeRequest struct {
+	Header    `json:"-"`
+	Handle    HandleID
+	Offset    uint64
+	NodeOut   NodeID
+	HandleOut HandleID
+	OffsetOut uint64
+	Len       uint64
+	Flags     uint64
+}
+
+var _ = Request(&CopyFileRangeRequest{})
+
+func (r *CopyFileRangeRequest) String() string {
+	return fmt.Sprintf("CopyFileRange [%s] %v %d @%d -> %v %v @%d flags=%#x",
+		&r.Header, r.Handle, r.Len, r.Offset, r.NodeOut, r.HandleOut, r.OffsetOut, r.Flags)
+}
+
+// Respond replies to the request with the number of bytes copied.
+func (r *CopyFileRangeRequest) Respond(size uint64) {
+	buf := newBuffer(unsafe.Sizeof(writeOut{}))
+	out := (*writeOut)(buf.alloc(unsafe.Sizeof(writeOut{})))
+	out.Size = uint32(size)
+	r.respond(buf)
+}
+
+// LockType is the type of a file lock.
+type LockType uint32
+
+const (
+	LockRead   LockType = syscall.F_RDLCK
+	LockWrite  LockType = syscall.F_WRLCK
+	LockUnlock LockType = syscall.F_UNLCK
+)
+
+func (t LockType) String() string {
+	switch t {
+	case LockRead:
+		return "read"
+	case LockWrite:
+		return "write"
+	case LockUnlock:
+		return "unlock"
+	}
+	return fmt.Sprintf("LockType(%d)", uint32(t))
+}
+
+// A FileLock describes a lock on a byte range, End is inclusive.
+type FileLock struct {
+	Start uint64
+	End   uint64
+	Type  LockType
+	Pid   uint32
+}
+
+// A SetlkRequest asks to acquire or release a lock on a byte range
+// of an opened file, as fcntl(F_SETLK) and flock(2) do. If Wait is
+// set, the request blocks until the lock is granted, as F_SETLKW does.
+type SetlkRequest struct {
+	Header    `json:"-"`
+	Handle    HandleID
+	LockOwner uint64
+	Lock      FileLock
+	LockFlags LockFlags
+	Wait      bool
+}
+
+var _ = Request(&SetlkRequest{})
+
+func (r *SetlkRequest) String() string {
+	return fmt.Sprintf("Setlk [%s] %v owner=%#x range=%d-%d type=%v pid=%d fl=%v wait=%v",
+		&r.Header, r.Handle, r.LockOwner, r.Lock.Start, r.Lock.End, r.Lock.Type, r.Lock.Pid, r.LockFlags, r.Wait)
+}
+
+// Respond replies to the request, indicating that the lock is set.
+func (r *SetlkRequest) Respond() {
+	buf := newBuffer(0)
+	r.respond(buf)
+}
+
+// A GetlkRequest asks for a lock conflicting with the given one, as
+// fcntl(F_GETLK) does.
+type GetlkRequest struct {
+	Header    `json:"-"`
+	Handle    HandleID
+	LockOwner uint64
+	Lock      FileLock
+	LockFlags LockFlags
+}
+
+var _ = Request(&GetlkRequest{})
+
+func (r *GetlkRequest) String() string {
+	return fmt.Sprintf("Getlk [%s] %v owner=%#x range=%d-%d type=%v pid=%d",
+		&r.Header, r.Handle, r.LockOwner, r.Lock.Start, r.Lock.End, r.Lock.Type, r.Lock.Pid)
+}
+
+// Respond replies to the request with the conflicting lock.
+func (r *GetlkRequest) Respond(resp *GetlkResponse) {
+	buf := newBuffer(unsafe.Sizeof(lkOut{}))
+	out := (*lkOut)(buf.alloc(unsafe.Sizeof(lkOut{})))
+	out.Lk = fileLock{
+		Start: resp.Lock.Start,
+		End:   resp.Lock.End,
+		Type:  uint32(resp.Lock.Type),
+		Pid:   resp.Lock.Pid,
+	}
+	r.respond(buf)
+}
+
+// A GetlkResponse is the response to a GetlkRequest, the type of Lock
+// is LockUnlock if there is no conflicting lock.
+type GetlkResponse struct {
+	Lock FileLock
+}
+
+func (r *GetlkResponse) String() string {
+	return fmt.Sprintf("Getlk range=%d-%d type=%v pid=%d", r.Lock.Start, r.Lock.End, r.Lock.Type, r.Lock.Pid)
+}
+
 // A RemoveRequest asks to remove a file or directory from the
 // directory r.Node.
 type RemoveRequest struct {
@@ -339,7 +339,8 @@ func flagString(f uint32, names []flagName) string {
 type ReleaseFlags uint32
 
 const (
-	ReleaseFlush ReleaseFlags = 1 << 0
+	ReleaseFlush       ReleaseFlags = 1 << 0
+	ReleaseFlockUnlock ReleaseFlags = 1 << 1
 )
 
 func (fl ReleaseFlags) String() string {
@@ -348,6 +349,7 @@ func (fl ReleaseFlags) String() string {
 
 var releaseFlagNames = []flagName{
 	{uint32(ReleaseFlush), "ReleaseFlush"},
+	{uint32(ReleaseFlockUnlock), "ReleaseFlockUnlock"},
 }
 
 // Opcodes
@@ -390,6 +392,9 @@ const (
 	opDestroy     = 38
 	opIoctl       = 39 // Linux?
 	opPoll        = 40 // Linux?
+	opFallocate   = 43 // Linux?
+
+	opCopyFileRange = 47 // Linux 4.20
 
 	// OS X
 	opSetvolname = 61
@@ -549,7 +554,7 @@ type releaseIn struct {
 	Fh           uint64
 	Flags        uint32
 	ReleaseFlags uint32
-	LockOwner    uint32
+	LockOwner    uint64
 }
 
 type flushIn struct {
@@ -688,6 +693,23 @@ func lkInSize(p Protocol) uintptr {
 	}
 }
 
+// The LockFlags are used in the Getlk and Setlk exchanges.
+type LockFlags uint32
+
+const (
+	// LockFlock is set if the lock is a BSD-style flock(2) lock
+	// rather than a POSIX fcntl(2) record lock.
+	LockFlock LockFlags = 1 << 0
+)
+
+func (fl LockFlags) String() string {
+	return flagString(uint32(fl), lockFlagNames)
+}
+
+var lockFlagNames = []flagName{
+	{uint32(LockFlock), "LockFlock"},
+}
+
 type lkOut struct {
 	Lk fileLock
 }
@@ -715,6 +737,43 @@ type initOut struct {
 	MaxWrite     uint32
 }
 
+type fallocateIn struct {
+	Fh     uint64
+	Offset uint64
+	Length uint64
+	Mode   uint32
+	_      uint32
+}
+
+// The FallocateFlags are used in the Fallocate exchange.
+type FallocateFlags uint32
+
+const (
+	FallocateKeepSize  FallocateFlags = 0x01
+	FallocatePunchHole FallocateFlags = 0x02
+	FallocateZeroRange FallocateFlags = 0x10
+)
+
+func (fl FallocateFlags) String() string {
+	return flagString(uint32(fl), fallocateFlagNames)
+}
+
+var fallocateFlagNames = []flagName{
+	{uint32(FallocateKeepSize), "FallocateKeepSize"},
+	{uint32(FallocatePunchHole), "FallocatePunchHole"},
+	{uint32(FallocateZeroRange), "FallocateZeroRange"},
+}
+
+type copyFileRangeIn struct {
+	FhIn      uint64
+	OffIn     uint64
+	NodeidOut uint64
+	FhOut     uint64
+	OffOut    uint64
+	Len       uint64
+	Flags     uint64
+}
+
 type interruptIn struct {
 	Unique uint64
 }
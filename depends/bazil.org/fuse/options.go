@@ -330,6 +330,27 @@ func PosixACL() MountOption {
 	}
 }
 
+// LockingPOSIX enables POSIX record locks to be handled by the
+// filesystem rather than locally by the kernel, so that fcntl(2)
+// locks are coordinated across mounts. The handles should implement
+// fs.HandleLocker.
+func LockingPOSIX() MountOption {
+	return func(conf *mountConfig) error {
+		conf.initFlags |= InitPosixLocks
+		return nil
+	}
+}
+
+// LockingFlock enables flock(2) locks to be handled by the filesystem
+// rather than locally by the kernel. The handles should implement
+// fs.HandleLocker.
+func LockingFlock() MountOption {
+	return func(conf *mountConfig) error {
+		conf.initFlags |= InitFlockLocks
+		return nil
+	}
+}
+
 // RequestTimeout set request timeout.
 func RequestTimeout(timeout int64) MountOption {
 	return func(conf *mountConfig) error {
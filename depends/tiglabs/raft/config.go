@@ -46,6 +46,7 @@ const (
 	defaultSizePerMsg      = MB
 	defaultHeartbeatAddr   = ":3016"
 	defaultReplicateAddr   = ":2015"
+	defaultMaxLearnerLag   = 100
 )
 
 // Config contains the parameters to start a raft server.
@@ -107,7 +108,11 @@ type Config struct {
 	// in that case.
 	// LeaseCheck MUST be enabled if ReadOnlyOption is ReadOnlyLeaseBased.
 	ReadOnlyOption ReadOnlyOption
-	transport      Transport
+	// MaxLearnerLag is how many entries the log of the learner may be behind the commit of the leader
+	// to be promoted to a voter.
+	// The default value is 100.
+	MaxLearnerLag uint64
+	transport     Transport
 }
 
 // TransportConfig raft server transport config
@@ -153,6 +158,7 @@ func DefaultConfig() *Config {
 		AppBufferSize:   defaultSizeAppBuffer,
 		RetainLogs:      defaultRetainLogs,
 		LeaseCheck:      false,
+		MaxLearnerLag:   defaultMaxLearnerLag,
 	}
 	conf.HeartbeatAddr = defaultHeartbeatAddr
 	conf.ReplicateAddr = defaultReplicateAddr
@@ -27,6 +27,9 @@ var (
 	ErrStopped       = errors.New("raft is already shutdown.")
 	ErrSnapping      = errors.New("raft is doing snapshot.")
 	ErrRetryLater    = errors.New("retry later")
+
+	ErrNotLearner         = errors.New("raft peer is not a learner.")
+	ErrLearnerNotCaughtUp = errors.New("raft learner has not caught up with the leader.")
 )
 
 type FatalError struct {
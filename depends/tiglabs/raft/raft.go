@@ -68,11 +68,26 @@ func (s *peerState) change(c *proto.ConfChange) {
 	s.mu.Lock()
 	switch c.Type {
 	case proto.ConfAddNode:
-		s.peers[c.Peer.ID] = c.Peer
+		// the existing peer is kept as it is, the same as raftFsm.addPeer does
+		if _, ok := s.peers[c.Peer.ID]; !ok {
+			s.peers[c.Peer.ID] = c.Peer
+		}
 	case proto.ConfRemoveNode:
 		delete(s.peers, c.Peer.ID)
 	case proto.ConfUpdateNode:
 		s.peers[c.Peer.ID] = c.Peer
+	case proto.ConfAddLearner:
+		// the voter is never demoted to a learner
+		if _, ok := s.peers[c.Peer.ID]; !ok {
+			peer := c.Peer
+			peer.Type = proto.PeerLearner
+			s.peers[c.Peer.ID] = peer
+		}
+	case proto.ConfPromoteLearner:
+		if peer, ok := s.peers[c.Peer.ID]; ok {
+			peer.Type = proto.PeerNormal
+			s.peers[c.Peer.ID] = peer
+		}
 	}
 	s.mu.Unlock()
 }
@@ -473,6 +488,12 @@ func (s *raft) proposeMemberChange(cc *proto.ConfChange, future *Future) {
 		future.respond(nil, ErrNotLeader)
 		return
 	}
+	if cc.Type == proto.ConfPromoteLearner {
+		if err := s.checkLearnerCaughtUp(cc.Peer.ID); err != nil {
+			future.respond(nil, err)
+			return
+		}
+	}
 
 	pr := pool.getProposal()
 	pr.cmdType = proto.EntryConfChange
@@ -486,6 +507,26 @@ func (s *raft) proposeMemberChange(cc *proto.ConfChange, future *Future) {
 	}
 }
 
+// checkLearnerCaughtUp returns nil if the learner replicates the log within MaxLearnerLag entries
+// behind the commit of the leader, so that it's safe to promote it without stalling the commit.
+func (s *raft) checkLearnerCaughtUp(id uint64) error {
+	st := s.status()
+	if st == nil {
+		return ErrStopped
+	}
+	if st.State != stateLeader.String() {
+		return ErrNotLeader
+	}
+	replica, ok := st.Replicas[id]
+	if !ok || !replica.IsLearner {
+		return ErrNotLearner
+	}
+	if replica.Snapshoting || replica.Match+s.config.MaxLearnerLag < st.Commit {
+		return ErrLearnerNotCaughtUp
+	}
+	return nil
+}
+
 func (s *raft) reciveMessage(m *proto.Message) {
 	if s.restoringSnapshot.Get() {
 		return
@@ -794,6 +835,7 @@ func (s *raft) getStatus() *Status {
 				Active:      p.active,
 				LastActive:  p.lastActive,
 				Inflight:    p.count,
+				IsLearner:   p.isLearner(),
 			}
 		}
 	}
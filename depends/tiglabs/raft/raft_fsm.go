@@ -436,6 +436,12 @@ func (r *raftFsm) applyConfChange(cc *proto.ConfChange) (ok bool) {
 		return r.removePeer(cc.Peer)
 	case proto.ConfUpdateNode:
 		r.updatePeer(cc.Peer)
+	case proto.ConfAddLearner:
+		peer := cc.Peer
+		peer.Type = proto.PeerLearner
+		r.addPeer(peer)
+	case proto.ConfPromoteLearner:
+		r.promoteLearner(cc.Peer)
 	}
 	return
 }
@@ -488,8 +494,36 @@ func (r *raftFsm) updatePeer(peer proto.Peer) {
 	}
 }
 
+// promoteLearner turns the learner into a voter, the quorum grows from then on.
+func (r *raftFsm) promoteLearner(peer proto.Peer) {
+	r.pendingConf = false
+	replica, ok := r.replicas[peer.ID]
+	if !ok || !replica.isLearner() {
+		return
+	}
+	replica.peer.Type = proto.PeerNormal
+	if peer.ID == r.config.NodeID {
+		r.electionElapsed = 0
+	}
+}
+
+func (r *raftFsm) isLearner(id uint64) bool {
+	replica, ok := r.replicas[id]
+	return ok && replica.isLearner()
+}
+
+// voters returns the number of the replicas voting, all but the learners.
+func (r *raftFsm) voters() (n int) {
+	for _, replica := range r.replicas {
+		if !replica.isLearner() {
+			n++
+		}
+	}
+	return
+}
+
 func (r *raftFsm) quorum() int {
-	return len(r.replicas)/2 + 1
+	return r.voters()/2 + 1
 }
 
 func (r *raftFsm) send(m *proto.Message) {
@@ -207,5 +207,5 @@ func (r *raftFsm) handleAppendEntries(m *proto.Message) {
 func (r *raftFsm) promotable() bool {
 	// todo check snapshot
 	pr, ok := r.replicas[r.config.NodeID]
-	return ok && pr.state != replicaStateSnapshot
+	return ok && !pr.isLearner() && pr.state != replicaStateSnapshot
 }
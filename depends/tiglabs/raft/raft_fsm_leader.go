@@ -308,7 +308,9 @@ func stepLeader(r *raftFsm, m *proto.Message) {
 		if logger.IsEnableDebug() {
 			logger.Debug("raft[%d] recv check quorum resp from %d, index=%d", r.id, m.From, m.Index)
 		}
-		r.readOnly.recvAck(m.Index, m.From, r.quorum())
+		if !r.isLearner(m.From) {
+			r.readOnly.recvAck(m.Index, m.From, r.quorum())
+		}
 		proto.ReturnMessage(m)
 		return
 	}
@@ -364,7 +366,9 @@ func stepPreCandidate(r *raftFsm, m *proto.Message) {
 		if logger.IsEnableDebug() {
 			logger.Debug("raft[%d] recv check quorum resp from %d, index=%d", r.id, m.From, m.Index)
 		}
-		r.readOnly.recvAck(m.Index, m.From, r.quorum())
+		if !r.isLearner(m.From) {
+			r.readOnly.recvAck(m.Index, m.From, r.quorum())
+		}
 		proto.ReturnMessage(m)
 		return
 
@@ -439,14 +443,19 @@ func (r *raftFsm) tickElectionAck() {
 func (r *raftFsm) checkLeaderLease() bool {
 	var act int
 	for id, peer := range r.replicas {
+		// the learners don't count in the quorum
+		vote := 1
+		if peer.isLearner() {
+			vote = 0
+		}
 		if id == r.config.NodeID || peer.state == replicaStateSnapshot {
-			act++
+			act += vote
 			continue
 		}
 
 		if peer.active {
 			peer.active = false
-			act++
+			act += vote
 		} else {
 			r.monitorZombie(peer)
 		}
@@ -458,8 +467,14 @@ func (r *raftFsm) checkLeaderLease() bool {
 func (r *raftFsm) maybeCommit() bool {
 	mis := make(util.Uint64Slice, 0, len(r.replicas))
 	for _, rp := range r.replicas {
+		if rp.isLearner() {
+			continue
+		}
 		mis = append(mis, rp.match)
 	}
+	if len(mis) == 0 {
+		return false
+	}
 	sort.Sort(sort.Reverse(mis))
 	mci := mis[r.quorum()-1]
 	isCommit := r.raftLog.maybeCommit(mci, r.term)
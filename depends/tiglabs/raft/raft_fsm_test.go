@@ -1782,3 +1782,113 @@ func TestFastLogRejection(t *testing.T) {
 		})
 	}
 }
+
+// newLearnerNetwork returns the network of the voters 1, 2 and the learner 3.
+func newLearnerNetwork() *network {
+	nt := newNetwork(nil, nil, nil)
+	for _, p := range nt.peers {
+		p.(*raftFsm).replicas[3].peer.Type = proto.PeerLearner
+	}
+	return nt
+}
+
+func TestAddLearner(t *testing.T) {
+	sm := newTestRaftFsm(10, 1,
+		newTestRaftConfig(1, withStorage(stor.DefaultMemoryStorage()), withPeers(1, 2)))
+	sm.applyConfChange(&proto.ConfChange{Type: proto.ConfAddLearner, Peer: proto.Peer{ID: 3, PeerID: 3}})
+
+	if !sm.isLearner(3) {
+		t.Fatalf("peer 3 is not a learner")
+	}
+	if q := sm.quorum(); q != 2 {
+		t.Errorf("quorum = %d, want 2", q)
+	}
+}
+
+func TestAddLearnerExistingVoter(t *testing.T) {
+	sm := newTestRaftFsm(10, 1,
+		newTestRaftConfig(1, withStorage(stor.DefaultMemoryStorage()), withPeers(1, 2, 3)))
+	cc := &proto.ConfChange{Type: proto.ConfAddLearner, Peer: proto.Peer{ID: 3, PeerID: 3}}
+	sm.applyConfChange(cc)
+
+	if sm.isLearner(3) {
+		t.Fatalf("voter 3 is demoted to a learner")
+	}
+	if q := sm.quorum(); q != 2 {
+		t.Errorf("quorum = %d, want 2", q)
+	}
+
+	// the peers of the raft agree with the fsm
+	ps := &peerState{peers: map[uint64]proto.Peer{3: {ID: 3, PeerID: 3, Type: proto.PeerNormal}}}
+	ps.change(cc)
+	if peer := ps.peers[3]; peer.Type != proto.PeerNormal {
+		t.Errorf("peer type = %v, want %v", peer.Type, proto.PeerNormal)
+	}
+	ps.change(&proto.ConfChange{Type: proto.ConfAddLearner, Peer: proto.Peer{ID: 4, PeerID: 4}})
+	if peer := ps.peers[4]; peer.Type != proto.PeerLearner {
+		t.Errorf("peer type = %v, want %v", peer.Type, proto.PeerLearner)
+	}
+}
+
+func TestLearnerCannotCampaign(t *testing.T) {
+	nt := newLearnerNetwork()
+	learner := nt.peers[3].(*raftFsm)
+	if learner.promotable() {
+		t.Fatalf("promotable = %v, want false", learner.promotable())
+	}
+
+	nt.send(proto.Message{From: 3, To: 3, Type: proto.LocalMsgHup})
+	if learner.state != stateFollower {
+		t.Errorf("state = %s, want %v", learner.state, stateFollower)
+	}
+	if learner.term != 0 {
+		t.Errorf("term = %d, want 0", learner.term)
+	}
+
+	nt.send(proto.Message{From: 1, To: 1, Type: proto.LocalMsgHup})
+	if sm := nt.peers[1].(*raftFsm); sm.state != stateLeader {
+		t.Errorf("state = %s, want %v", sm.state, stateLeader)
+	}
+	if learner.leader != 1 {
+		t.Errorf("lead = %d, want 1", learner.leader)
+	}
+}
+
+func TestLearnerNotCountedInQuorum(t *testing.T) {
+	nt := newLearnerNetwork()
+	nt.send(proto.Message{From: 1, To: 1, Type: proto.LocalMsgHup})
+	leader := nt.peers[1].(*raftFsm)
+	if leader.state != stateLeader {
+		t.Fatalf("state = %s, want %v", leader.state, stateLeader)
+	}
+
+	// the learner replicates the entry, which isn't committed without the other voter
+	committed := leader.raftLog.committed
+	nt.isolate(2)
+	entry := &proto.Entry{Term: leader.term, Index: leader.raftLog.lastIndex() + 1, Data: []byte("somedata")}
+	nt.send(proto.Message{From: 1, To: 1, Type: proto.LocalMsgProp, Entries: []*proto.Entry{entry}})
+	if m := leader.replicas[3].match; m != leader.raftLog.lastIndex() {
+		t.Errorf("learner match = %d, want %d", m, leader.raftLog.lastIndex())
+	}
+	if leader.raftLog.committed != committed {
+		t.Errorf("committed = %d, want %d", leader.raftLog.committed, committed)
+	}
+	if gr := leader.poll(3, true); gr != 0 {
+		t.Errorf("granted = %d, want 0", gr)
+	}
+
+	// the promoted learner counts in the quorum
+	for _, p := range nt.peers {
+		p.(*raftFsm).applyConfChange(&proto.ConfChange{Type: proto.ConfPromoteLearner, Peer: proto.Peer{ID: 3, PeerID: 3}})
+	}
+	if leader.isLearner(3) {
+		t.Fatalf("peer 3 is still a learner")
+	}
+	leader.maybeCommit()
+	if leader.raftLog.committed != leader.raftLog.lastIndex() {
+		t.Errorf("committed = %d, want %d", leader.raftLog.committed, leader.raftLog.lastIndex())
+	}
+	if !nt.peers[3].(*raftFsm).promotable() {
+		t.Errorf("promotable = false, want true")
+	}
+}
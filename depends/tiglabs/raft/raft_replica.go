@@ -51,6 +51,10 @@ func newReplica(peer proto.Peer, maxInflight int) *replica {
 	return repl
 }
 
+func (r *replica) isLearner() bool {
+	return r.peer.Type == proto.PeerLearner
+}
+
 func (r *replica) resetState(state replicaState) {
 	logger.Debug("raft resetState from [%v]", r)
 	r.paused = false
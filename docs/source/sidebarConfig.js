@@ -47,6 +47,7 @@ module.exports = [
                     'user-guide/cli/user.md',
                     'user-guide/cli/nodeset.md',
                     'user-guide/cli/quota.md',
+                    'user-guide/cli/fsck.md',
                     'user-guide/cli/blobstore-cli.md',
                 ]
             },
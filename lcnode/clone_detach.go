@@ -0,0 +1,201 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package lcnode
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"sync/atomic"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// VolCloneDataClient reads and writes the data of the clone volume for the detach.
+type VolCloneDataClient interface {
+	ExtentApi
+	IsSharedPartition(partitionID uint64) bool
+}
+
+// detach rewrites the data of the clone shared with the source level by level from the root, so
+// that the clone no longer refers to the snapshot of the source.
+func (s *VolCloneScanner) detach() error {
+	dirs := []*volCloneDir{{dst: proto.RootIno, path: "/"}}
+	for len(dirs) > 0 {
+		dir := dirs[0]
+		dirs = dirs[1:]
+		subDirs, err := s.detachDir(dir)
+		if err != nil {
+			return fmt.Errorf("detach %v: %v", dir.path, err)
+		}
+		dirs = append(dirs, subDirs...)
+	}
+	return nil
+}
+
+func (s *VolCloneScanner) detachDir(dir *volCloneDir) (subDirs []*volCloneDir, err error) {
+	atomic.AddInt64(&s.currentStat.DirCount, 1)
+	marker := ""
+	for {
+		if s.stopped() {
+			return nil, errVolCloneStopped
+		}
+		var children []proto.Dentry
+		if children, err = s.dstMw.ReadDirLimit_ll(dir.dst, marker, uint64(defaultReadDirLimit)); err != nil {
+			return
+		}
+		childrenNr := len(children)
+		if marker != "" && childrenNr > 0 && children[0].Name == marker {
+			children = children[1:]
+		}
+		if len(children) > 0 {
+			_ = s.limiter.WaitN(context.Background(), len(children))
+			var dirs []*volCloneDir
+			if dirs, err = s.detachChildren(dir, children); err != nil {
+				return
+			}
+			subDirs = append(subDirs, dirs...)
+		}
+		if childrenNr < defaultReadDirLimit || len(children) == 0 {
+			break
+		}
+		marker = children[len(children)-1].Name
+	}
+	return
+}
+
+func (s *VolCloneScanner) detachChildren(dir *volCloneDir, children []proto.Dentry) (subDirs []*volCloneDir, err error) {
+	inodes := make([]uint64, 0, len(children))
+	for _, child := range children {
+		inodes = append(inodes, child.Inode)
+	}
+	infos := make(map[uint64]*proto.InodeInfo, len(inodes))
+	for _, info := range s.dstMw.BatchInodeGet(inodes) {
+		infos[info.Inode] = info
+	}
+
+	for _, child := range children {
+		info, ok := infos[child.Inode]
+		if !ok {
+			// removed meanwhile
+			continue
+		}
+		fullPath := path.Join(dir.path, child.Name)
+		if proto.IsDir(info.Mode) {
+			subDirs = append(subDirs, &volCloneDir{dst: info.Inode, path: fullPath})
+			continue
+		}
+		if !proto.IsRegular(info.Mode) {
+			continue
+		}
+		if info.Nlink > 1 {
+			if _, ok := s.hardLinks[info.Inode]; ok {
+				continue
+			}
+			s.hardLinks[info.Inode] = info.Inode
+		}
+		if err = s.detachFile(info, fullPath); err != nil {
+			return
+		}
+		atomic.AddInt64(&s.currentStat.FileCount, 1)
+	}
+	return
+}
+
+// sharedKeys returns the keys on the data partitions shared from the source.
+func (s *VolCloneScanner) sharedKeys(eks []proto.ExtentKey) (shared []proto.ExtentKey) {
+	for _, ek := range eks {
+		if s.ec.IsSharedPartition(ek.PartitionId) {
+			shared = append(shared, ek)
+		}
+	}
+	return
+}
+
+// detachFile rewrites the ranges of the file on the shared extents into the data partitions of the
+// clone. The keys replaced are checked
+// by the metanode against the ones read at the open of the stream, so the rewrite of the range
+// written by others meanwhile conflicts and fails instead of overwriting the new data, and the
+// detach is to be run again.
+func (s *VolCloneScanner) detachFile(info *proto.InodeInfo, fullPath string) (err error) {
+	_, _, eks, _, err := s.dstMw.GetInlineExtents(info.Inode, false, false, false)
+	if err != nil {
+		return
+	}
+	shared := s.sharedKeys(eks)
+	if len(shared) == 0 {
+		return
+	}
+	log.LogDebugf("clone(%v): detach %v shared extents(%v)", s.ID, fullPath, len(shared))
+	if err = s.rewriteKeys(info.Inode, info.StorageClass, shared, fullPath, &s.currentStat.DetachedBytes); err != nil {
+		return
+	}
+	atomic.AddInt64(&s.currentStat.ExtentNum, int64(len(shared)))
+
+	if _, _, eks, _, err = s.dstMw.GetInlineExtents(info.Inode, false, false, false); err != nil {
+		return
+	}
+	if left := s.sharedKeys(eks); len(left) > 0 {
+		return fmt.Errorf("%v extents of %v are still shared, the file may be written during the detach", len(left), fullPath)
+	}
+	return nil
+}
+
+// rewriteKeys reads the ranges of the keys of the clone file and writes them back, which the
+// stream writes copy-on-write into the data partitions of the clone.
+func (s *VolCloneScanner) rewriteKeys(ino uint64, storageClass uint32, eks []proto.ExtentKey, fullPath string, written *uint64) (err error) {
+	if err = s.ec.OpenStream(ino, false, false); err != nil {
+		return
+	}
+	defer func() {
+		if closeErr := s.ec.CloseStream(ino); closeErr != nil {
+			log.LogWarnf("clone(%v): close stream of %v err(%v)", s.ID, fullPath, closeErr)
+		}
+	}()
+
+	buf := make([]byte, 2*util.BlockSize)
+	for _, ek := range eks {
+		for done := 0; done < int(ek.Size); {
+			if s.stopped() {
+				return errVolCloneStopped
+			}
+			size := int(ek.Size) - done
+			if size > len(buf) {
+				size = len(buf)
+			}
+			offset := int(ek.FileOffset) + done
+			var n int
+			if n, err = s.ec.Read(ino, buf[:size], offset, size, storageClass, false); err != nil && err != io.EOF {
+				return fmt.Errorf("read %v at %v: %v", fullPath, offset, err)
+			}
+			// the file is truncated meanwhile
+			if n == 0 {
+				break
+			}
+			if _, err = s.ec.Write(ino, offset, buf[:n], 0, nil, storageClass, false); err != nil {
+				return fmt.Errorf("write %v at %v: %v", fullPath, offset, err)
+			}
+			done += n
+			atomic.AddUint64(written, uint64(n))
+		}
+	}
+	if err = s.ec.Flush(ino); err != nil {
+		return fmt.Errorf("flush %v: %v", fullPath, err)
+	}
+	return nil
+}
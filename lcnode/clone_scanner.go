@@ -0,0 +1,425 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package lcnode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sync/atomic"
+	"time"
+
+	"github.com/cubefs/cubefs/datanode/storage"
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+	"golang.org/x/time/rate"
+)
+
+// The clone copies the metadata of the source volume at the snapshot into the empty clone volume
+// level by level from the root. The extent keys of the files are appended to the clone as they
+// are, so the data stays in the data partitions of the source, which are read only to the clone.
+// The hard links are recreated by linking the inode copied first, and the attributes of the
+// directory are set after its children are created, since creating them updates the times.
+//
+// The copy is never resumed, the clone is left failed on any error and should be deleted.
+//
+// The detach task walks the clone the same way, and rewrites the ranges of the files still on the
+// extents shared with the source into the clone, see detachFile.
+
+var errVolCloneStopped = errors.New("clone stopped")
+
+type VolCloneScanner struct {
+	ID          string
+	Volume      string
+	srcMw       VolCloneMetaWrapper // nil for the detach
+	dstMw       VolCloneMetaWrapper
+	ec          VolCloneDataClient // the data client of the clone for the detach
+	lcnode      *LcNode
+	adminTask   *proto.AdminTask
+	task        *proto.VolCloneTask
+	limiter     *rate.Limiter
+	startTime   time.Time
+	currentStat *proto.VolCloneStatistics
+	hardLinks   map[uint64]uint64 // the inode of the clone by the one of the source
+	stopC       chan bool
+}
+
+// volCloneDir is the directory to be copied, src and dst are the inodes in the source and clone.
+type volCloneDir struct {
+	src  *proto.InodeInfo
+	dst  uint64
+	path string
+}
+
+func NewVolCloneScanner(adminTask *proto.AdminTask, l *LcNode) (*VolCloneScanner, error) {
+	request := adminTask.Request.(*proto.VolCloneTaskRequest)
+	if request.Task.Detach {
+		dstMw, err := l.newVolumeMetaWrapper(request.Task.CloneVol)
+		if err != nil {
+			return nil, err
+		}
+		ec, err := l.newVolumeExtentClient(request.Task.CloneVol, dstMw)
+		if err != nil {
+			dstMw.Close()
+			return nil, err
+		}
+		return newVolCloneScanner(adminTask, l, nil, dstMw, ec), nil
+	}
+	srcMw, err := l.newVolumeMetaWrapper(request.Task.SourceVol)
+	if err != nil {
+		return nil, err
+	}
+	// the metadata of the source is read as it was at the snapshot
+	srcMw.VerReadSeq = request.Task.ReadSeq
+	dstMw, err := l.newVolumeMetaWrapper(request.Task.CloneVol)
+	if err != nil {
+		srcMw.Close()
+		return nil, err
+	}
+	// the tiny extents are rewritten into the clone
+	ec, err := l.newVolumeExtentClient(request.Task.CloneVol, dstMw)
+	if err != nil {
+		srcMw.Close()
+		dstMw.Close()
+		return nil, err
+	}
+	return newVolCloneScanner(adminTask, l, srcMw, dstMw, ec), nil
+}
+
+func newVolCloneScanner(adminTask *proto.AdminTask, l *LcNode, srcMw, dstMw VolCloneMetaWrapper, ec VolCloneDataClient) *VolCloneScanner {
+	request := adminTask.Request.(*proto.VolCloneTaskRequest)
+	return &VolCloneScanner{
+		ID:          request.Task.Id,
+		Volume:      request.Task.CloneVol,
+		srcMw:       srcMw,
+		dstMw:       dstMw,
+		ec:          ec,
+		lcnode:      l,
+		adminTask:   adminTask,
+		task:        request.Task,
+		limiter:     rate.NewLimiter(cloneLimitPerSecond, defaultReadDirLimit),
+		startTime:   time.Now(),
+		currentStat: &proto.VolCloneStatistics{},
+		hardLinks:   make(map[uint64]uint64),
+		stopC:       make(chan bool),
+	}
+}
+
+func (l *LcNode) startVolClone(adminTask *proto.AdminTask) {
+	request := adminTask.Request.(*proto.VolCloneTaskRequest)
+	log.LogInfof("startVolClone: clone task(%v) received!", request.Task)
+	response := &proto.VolCloneTaskResponse{}
+	adminTask.Response = response
+
+	l.scannerMutex.Lock()
+	if _, ok := l.volCloneScanners[request.Task.Id]; ok {
+		log.LogInfof("startVolClone: clone task(%v) is already running!", request.Task)
+		l.scannerMutex.Unlock()
+		return
+	}
+
+	scanner, err := NewVolCloneScanner(adminTask, l)
+	if err != nil {
+		log.LogErrorf("startVolClone: NewVolCloneScanner err(%v)", err)
+		t := time.Now()
+		response.ID = request.Task.Id
+		response.LcNode = l.localServerAddr
+		response.Task = request.Task
+		response.EndTime = &t
+		response.Done = true
+		response.Status = proto.TaskFailed
+		response.Result = err.Error()
+		l.scannerMutex.Unlock()
+		return
+	}
+	l.volCloneScanners[scanner.ID] = scanner
+	l.scannerMutex.Unlock()
+
+	go scanner.Start()
+}
+
+func (s *VolCloneScanner) Stop() {
+	defer func() {
+		if r := recover(); r != nil {
+			log.LogErrorf("VolCloneScanner Stop err:%v", r)
+		}
+	}()
+	close(s.stopC)
+	log.LogDebugf("clone scanner(%v) stopped", s.ID)
+}
+
+func (s *VolCloneScanner) stopped() bool {
+	select {
+	case <-s.stopC:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *VolCloneScanner) Start() {
+	response := s.adminTask.Response.(*proto.VolCloneTaskResponse)
+	response.StartTime = &s.startTime
+	log.LogInfof("clone start(%v): source(%v) ver(%v) readSeq(%v) clone(%v) detach(%v)",
+		s.ID, s.task.SourceVol, s.task.Ver, s.task.ReadSeq, s.task.CloneVol, s.task.Detach)
+
+	var err error
+	if s.task.Detach {
+		err = s.detach()
+	} else {
+		err = s.run()
+	}
+
+	t := time.Now()
+	s.fillResponse(response)
+	response.EndTime = &t
+	response.Done = true
+	if err != nil {
+		response.Status = proto.TaskFailed
+		response.Result = err.Error()
+	} else {
+		response.Status = proto.TaskSucceeds
+	}
+
+	s.lcnode.scannerMutex.Lock()
+	if _, ok := s.lcnode.volCloneScanners[s.ID]; ok {
+		s.Stop()
+		delete(s.lcnode.volCloneScanners, s.ID)
+	}
+	s.lcnode.scannerMutex.Unlock()
+	if s.ec != nil {
+		s.ec.Close()
+	}
+	if s.srcMw != nil {
+		s.srcMw.Close()
+	}
+	s.dstMw.Close()
+
+	s.lcnode.respondToMaster(s.adminTask)
+	log.LogInfof("clone completed(%v): status(%v) result(%v) stat(%+v)", s.ID, response.Status, response.Result, *s.currentStat)
+}
+
+func (s *VolCloneScanner) run() error {
+	infos := s.srcMw.BatchInodeGet([]uint64{proto.RootIno})
+	if len(infos) == 0 {
+		return fmt.Errorf("root of volume(%v) not found", s.task.SourceVol)
+	}
+	if err := s.copyXAttrs(infos[0].Inode, proto.RootIno, "/"); err != nil {
+		return err
+	}
+
+	dirs := []*volCloneDir{{src: infos[0], dst: proto.RootIno, path: "/"}}
+	for len(dirs) > 0 {
+		dir := dirs[0]
+		dirs = dirs[1:]
+		subDirs, err := s.copyDir(dir)
+		if err != nil {
+			return fmt.Errorf("copy %v: %v", dir.path, err)
+		}
+		dirs = append(dirs, subDirs...)
+	}
+	return nil
+}
+
+// copyDir copies the children of the directory, and returns the sub directories to be copied.
+func (s *VolCloneScanner) copyDir(dir *volCloneDir) (subDirs []*volCloneDir, err error) {
+	atomic.AddInt64(&s.currentStat.DirCount, 1)
+	marker := ""
+	for {
+		if s.stopped() {
+			return nil, errVolCloneStopped
+		}
+		var children []proto.Dentry
+		if children, err = s.srcMw.ReadDirLimit_ll(dir.src.Inode, marker, uint64(defaultReadDirLimit)); err != nil {
+			return
+		}
+		childrenNr := len(children)
+		if marker != "" && childrenNr > 0 && children[0].Name == marker {
+			children = children[1:]
+		}
+		if len(children) > 0 {
+			_ = s.limiter.WaitN(context.Background(), len(children))
+			var dirs []*volCloneDir
+			if dirs, err = s.copyChildren(dir, children); err != nil {
+				return
+			}
+			subDirs = append(subDirs, dirs...)
+		}
+		if childrenNr < defaultReadDirLimit || len(children) == 0 {
+			break
+		}
+		marker = children[len(children)-1].Name
+	}
+	err = s.copyAttr(dir.src, dir.dst, dir.path)
+	return
+}
+
+func (s *VolCloneScanner) copyChildren(dir *volCloneDir, children []proto.Dentry) (subDirs []*volCloneDir, err error) {
+	inodes := make([]uint64, 0, len(children))
+	for _, child := range children {
+		inodes = append(inodes, child.Inode)
+	}
+	infos := make(map[uint64]*proto.InodeInfo, len(inodes))
+	for _, info := range s.srcMw.BatchInodeGet(inodes) {
+		infos[info.Inode] = info
+	}
+
+	for _, child := range children {
+		info, ok := infos[child.Inode]
+		if !ok {
+			return nil, fmt.Errorf("inode(%v) of %v not found", child.Inode, child.Name)
+		}
+		fullPath := path.Join(dir.path, child.Name)
+		if dst, ok := s.hardLinks[info.Inode]; ok {
+			if _, err = s.dstMw.Link(dir.dst, child.Name, dst, fullPath); err != nil {
+				return
+			}
+			atomic.AddInt64(&s.currentStat.HardLinkNum, 1)
+			continue
+		}
+
+		var created *proto.InodeInfo
+		if created, err = s.dstMw.Create_ll(dir.dst, child.Name, info.Mode, info.Uid, info.Gid, info.Target, fullPath, false); err != nil {
+			return
+		}
+		if err = s.copyXAttrs(info.Inode, created.Inode, fullPath); err != nil {
+			return
+		}
+		switch {
+		case proto.IsDir(info.Mode):
+			subDirs = append(subDirs, &volCloneDir{src: info, dst: created.Inode, path: fullPath})
+			continue
+		case proto.IsRegular(info.Mode):
+			if err = s.copyExtents(info, created.Inode, fullPath); err != nil {
+				return
+			}
+			if info.Nlink > 1 {
+				s.hardLinks[info.Inode] = created.Inode
+			}
+			atomic.AddInt64(&s.currentStat.FileCount, 1)
+		case proto.IsSymlink(info.Mode):
+			atomic.AddInt64(&s.currentStat.SymlinkCount, 1)
+		default:
+			atomic.AddInt64(&s.currentStat.FileCount, 1)
+		}
+		if err = s.copyAttr(info, created.Inode, fullPath); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// copyExtents appends the extent keys of the source file to the clone, the data is shared. The
+// data of the tiny file stored in the inode is copied, and so are the ranges on the tiny extents,
+// which are shared by many files and can not be held for the clone by the data partitions of the
+// source, so they are rewritten into the clone.
+func (s *VolCloneScanner) copyExtents(info *proto.InodeInfo, dst uint64, fullPath string) error {
+	if !proto.IsStorageClassReplica(info.StorageClass) || info.HasMigrationEk {
+		return fmt.Errorf("storage class(%v) of %v is not supported", proto.StorageClassString(info.StorageClass), fullPath)
+	}
+	_, _, eks, inline, err := s.srcMw.GetInlineExtents(info.Inode, false, false, false)
+	if err != nil {
+		return err
+	}
+	var (
+		end  uint64
+		tiny []proto.ExtentKey
+	)
+	if len(inline) > 0 {
+		if err = s.dstMw.WriteInline(dst, 0, inline); err != nil {
+			return err
+		}
+		end = uint64(len(inline))
+	}
+	for i := 0; i < len(eks); i += defaultCloneExtentBatch {
+		batch := eks[i:]
+		if len(batch) > defaultCloneExtentBatch {
+			batch = batch[:defaultCloneExtentBatch]
+		}
+		for j := range batch {
+			batch[j].SnapInfo = nil
+			if batch[j].FileOffset+uint64(batch[j].Size) > end {
+				end = batch[j].FileOffset + uint64(batch[j].Size)
+			}
+			if storage.IsTinyExtent(batch[j].ExtentId) {
+				tiny = append(tiny, batch[j])
+				continue
+			}
+			atomic.AddUint64(&s.currentStat.SharedBytes, uint64(batch[j].Size))
+		}
+		if err = s.dstMw.AppendExtentKeys(dst, batch, info.StorageClass); err != nil {
+			return err
+		}
+		atomic.AddInt64(&s.currentStat.ExtentNum, int64(len(batch)))
+	}
+	// the hole at the end of the file
+	if info.Size > end {
+		if err = s.dstMw.Truncate(dst, info.Size, fullPath); err != nil {
+			return err
+		}
+	}
+	if len(tiny) > 0 {
+		var copied uint64
+		return s.rewriteKeys(dst, info.StorageClass, tiny, fullPath, &copied)
+	}
+	return nil
+}
+
+func (s *VolCloneScanner) copyXAttrs(src, dst uint64, fullPath string) error {
+	xattrs, err := s.srcMw.XAttrGetAll_ll(src)
+	if err != nil {
+		return err
+	}
+	if xattrs == nil || len(xattrs.XAttrs) == 0 {
+		return nil
+	}
+	log.LogDebugf("clone(%v): copy xattrs of %v", s.ID, fullPath)
+	return s.dstMw.BatchSetXAttr_ll(dst, xattrs.XAttrs)
+}
+
+func (s *VolCloneScanner) copyAttr(info *proto.InodeInfo, dst uint64, fullPath string) error {
+	valid := proto.AttrAccessTime | proto.AttrModifyTime
+	if dst == proto.RootIno {
+		valid |= proto.AttrMode | proto.AttrUid | proto.AttrGid
+	}
+	if err := s.dstMw.Setattr(dst, valid, info.Mode, info.Uid, info.Gid, info.AccessTime.Unix(), info.ModifyTime.Unix()); err != nil {
+		log.LogErrorf("clone(%v): set attr of %v err(%v)", s.ID, fullPath, err)
+		return err
+	}
+	return nil
+}
+
+func (s *VolCloneScanner) fillResponse(response *proto.VolCloneTaskResponse) {
+	response.ID = s.ID
+	response.LcNode = s.lcnode.localServerAddr
+	response.Task = s.task
+	response.VolCloneStatistics = proto.VolCloneStatistics{
+		DirCount:      atomic.LoadInt64(&s.currentStat.DirCount),
+		FileCount:     atomic.LoadInt64(&s.currentStat.FileCount),
+		SymlinkCount:  atomic.LoadInt64(&s.currentStat.SymlinkCount),
+		HardLinkNum:   atomic.LoadInt64(&s.currentStat.HardLinkNum),
+		ExtentNum:     atomic.LoadInt64(&s.currentStat.ExtentNum),
+		SharedBytes:   atomic.LoadUint64(&s.currentStat.SharedBytes),
+		DetachedBytes: atomic.LoadUint64(&s.currentStat.DetachedBytes),
+	}
+}
+
+// progress returns the progress of the running task for the heartbeat.
+func (s *VolCloneScanner) progress() *proto.VolCloneTaskResponse {
+	response := &proto.VolCloneTaskResponse{StartTime: &s.startTime}
+	s.fillResponse(response)
+	return response
+}
@@ -0,0 +1,357 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package lcnode
+
+import (
+	"io"
+	"os"
+	"sort"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util"
+	"github.com/stretchr/testify/require"
+)
+
+type mockCloneMeta struct {
+	inodes   map[uint64]*proto.InodeInfo
+	dentries map[uint64][]proto.Dentry
+	extents  map[uint64][]proto.ExtentKey
+	inline   map[uint64][]byte
+	xattrs   map[uint64]map[string]string
+	nextIno  uint64
+}
+
+func newMockCloneMeta() *mockCloneMeta {
+	m := &mockCloneMeta{
+		inodes:   make(map[uint64]*proto.InodeInfo),
+		dentries: make(map[uint64][]proto.Dentry),
+		extents:  make(map[uint64][]proto.ExtentKey),
+		inline:   make(map[uint64][]byte),
+		xattrs:   make(map[uint64]map[string]string),
+		nextIno:  proto.RootIno,
+	}
+	m.inodes[proto.RootIno] = &proto.InodeInfo{Inode: proto.RootIno, Mode: proto.Mode(os.ModeDir | 0o755), Nlink: 2}
+	return m
+}
+
+func (m *mockCloneMeta) lookup(parent uint64, name string) *proto.InodeInfo {
+	for _, d := range m.dentries[parent] {
+		if d.Name == name {
+			return m.inodes[d.Inode]
+		}
+	}
+	return nil
+}
+
+func (m *mockCloneMeta) ReadDirLimit_ll(parentID uint64, from string, limit uint64) ([]proto.Dentry, error) {
+	children := make([]proto.Dentry, 0)
+	for _, d := range m.dentries[parentID] {
+		if d.Name >= from && uint64(len(children)) < limit {
+			children = append(children, d)
+		}
+	}
+	return children, nil
+}
+
+func (m *mockCloneMeta) BatchInodeGet(inodes []uint64) []*proto.InodeInfo {
+	infos := make([]*proto.InodeInfo, 0)
+	for _, ino := range inodes {
+		if info, ok := m.inodes[ino]; ok {
+			infos = append(infos, info)
+		}
+	}
+	return infos
+}
+
+func (m *mockCloneMeta) GetInlineExtents(inode uint64, isCache, openForWrite, isMigration bool) (gen uint64, size uint64, extents []proto.ExtentKey, inline []byte, err error) {
+	extents = make([]proto.ExtentKey, len(m.extents[inode]))
+	copy(extents, m.extents[inode])
+	return 0, m.inodes[inode].Size, extents, m.inline[inode], nil
+}
+
+func (m *mockCloneMeta) XAttrGetAll_ll(inode uint64) (*proto.XAttrInfo, error) {
+	return &proto.XAttrInfo{Inode: inode, XAttrs: m.xattrs[inode]}, nil
+}
+
+func (m *mockCloneMeta) Create_ll(parentID uint64, name string, mode, uid, gid uint32, target []byte, fullPath string, ignoreExist bool) (*proto.InodeInfo, error) {
+	if m.lookup(parentID, name) != nil {
+		return nil, syscall.EEXIST
+	}
+	m.nextIno++
+	info := &proto.InodeInfo{Inode: m.nextIno, Mode: mode, Uid: uid, Gid: gid, Target: target, Nlink: 1, StorageClass: proto.StorageClass_Replica_SSD}
+	m.inodes[info.Inode] = info
+	m.addDentry(parentID, name, info.Inode)
+	return info, nil
+}
+
+func (m *mockCloneMeta) addDentry(parent uint64, name string, ino uint64) {
+	m.dentries[parent] = append(m.dentries[parent], proto.Dentry{Name: name, Inode: ino})
+	sort.Slice(m.dentries[parent], func(i, j int) bool { return m.dentries[parent][i].Name < m.dentries[parent][j].Name })
+}
+
+func (m *mockCloneMeta) Link(parentID uint64, name string, ino uint64, fullPath string) (*proto.InodeInfo, error) {
+	m.addDentry(parentID, name, ino)
+	m.inodes[ino].Nlink++
+	return m.inodes[ino], nil
+}
+
+func (m *mockCloneMeta) AppendExtentKeys(inode uint64, eks []proto.ExtentKey, storageClass uint32) error {
+	m.extents[inode] = append(m.extents[inode], eks...)
+	for _, ek := range eks {
+		if end := ek.FileOffset + uint64(ek.Size); end > m.inodes[inode].Size {
+			m.inodes[inode].Size = end
+		}
+	}
+	return nil
+}
+
+func (m *mockCloneMeta) WriteInline(inode, offset uint64, data []byte) error {
+	m.inline[inode] = append(m.inline[inode][:offset], data...)
+	if end := offset + uint64(len(data)); end > m.inodes[inode].Size {
+		m.inodes[inode].Size = end
+	}
+	return nil
+}
+
+func (m *mockCloneMeta) Truncate(inode, size uint64, fullPath string) error {
+	m.inodes[inode].Size = size
+	return nil
+}
+
+func (m *mockCloneMeta) Setattr(inode uint64, valid, mode, uid, gid uint32, atime, mtime int64) error {
+	info := m.inodes[inode]
+	if valid&proto.AttrMode != 0 {
+		info.Mode = mode
+	}
+	if valid&proto.AttrUid != 0 {
+		info.Uid = uid
+	}
+	if valid&proto.AttrGid != 0 {
+		info.Gid = gid
+	}
+	if valid&proto.AttrAccessTime != 0 {
+		info.AccessTime = time.Unix(atime, 0)
+	}
+	if valid&proto.AttrModifyTime != 0 {
+		info.ModifyTime = time.Unix(mtime, 0)
+	}
+	return nil
+}
+
+func (m *mockCloneMeta) BatchSetXAttr_ll(inode uint64, attrs map[string]string) error {
+	m.xattrs[inode] = attrs
+	return nil
+}
+
+func (m *mockCloneMeta) Close() error {
+	return nil
+}
+
+func runVolCloneTest(src, dst *mockCloneMeta) *proto.VolCloneTaskResponse {
+	cloneLimitPerSecond = defaultCloneLimitPerSecond
+	adminTask := &proto.AdminTask{
+		Request: &proto.VolCloneTaskRequest{Task: &proto.VolCloneTask{
+			Id:        "clone_vol:1",
+			SourceVol: "test_vol",
+			CloneVol:  "clone_vol",
+			Ver:       1,
+			ReadSeq:   2,
+		}},
+		Response: &proto.VolCloneTaskResponse{},
+	}
+	data := &mockCloneData{meta: dst, shared: map[uint64]bool{1: true, 2: true}, written: make(map[uint64][]proto.ExtentKey)}
+	newVolCloneScanner(adminTask, &LcNode{}, src, dst, data).Start()
+	return adminTask.Response.(*proto.VolCloneTaskResponse)
+}
+
+func TestVolCloneScanner(t *testing.T) {
+	mtime := time.Date(2023, 6, 1, 8, 0, 0, 0, time.UTC)
+	src := newMockCloneMeta()
+	src.inodes[proto.RootIno].Uid = 100
+	src.inodes[proto.RootIno].ModifyTime = mtime
+	dir, _ := src.Create_ll(proto.RootIno, "dir", proto.Mode(os.ModeDir|0o700), 1, 2, nil, "/dir", false)
+	dir.ModifyTime = mtime
+	file, _ := src.Create_ll(dir.Inode, "file", 0o644, 1, 2, nil, "/dir/file", false)
+	src.AppendExtentKeys(file.Inode, []proto.ExtentKey{
+		{FileOffset: 0, PartitionId: 1, ExtentId: 1025, Size: 4096, SnapInfo: &proto.ExtSnapInfo{VerSeq: 1}},
+		{FileOffset: 8192, PartitionId: 2, ExtentId: 1026, Size: 4096},
+		{FileOffset: 12288, PartitionId: 2, ExtentId: 1, ExtentOffset: 8192, Size: 4096},
+	}, proto.StorageClass_Replica_SSD)
+	file.Size = 20480
+	src.xattrs[file.Inode] = map[string]string{"user.k": "v"}
+	src.Link(proto.RootIno, "link", file.Inode, "/link")
+	src.Create_ll(proto.RootIno, "symlink", proto.Mode(os.ModeSymlink|0o777), 0, 0, []byte("dir/file"), "/symlink", false)
+	tiny, _ := src.Create_ll(proto.RootIno, "tiny", 0o644, 0, 0, nil, "/tiny", false)
+	src.WriteInline(tiny.Inode, 0, []byte("tiny"))
+	tiny.Size = 8
+
+	dst := newMockCloneMeta()
+	response := runVolCloneTest(src, dst)
+	require.True(t, response.Done)
+	require.Equal(t, uint8(proto.TaskSucceeds), response.Status, response.Result)
+	require.Equal(t, int64(2), response.DirCount)
+	require.Equal(t, int64(2), response.FileCount)
+	require.Equal(t, int64(1), response.SymlinkCount)
+	require.Equal(t, int64(1), response.HardLinkNum)
+	require.Equal(t, int64(3), response.ExtentNum)
+	require.Equal(t, uint64(8192), response.SharedBytes)
+
+	root := dst.inodes[proto.RootIno]
+	require.Equal(t, uint32(100), root.Uid)
+	require.Equal(t, mtime.Unix(), root.ModifyTime.Unix())
+	cloneDir := dst.lookup(proto.RootIno, "dir")
+	require.NotNil(t, cloneDir)
+	require.True(t, proto.IsDir(cloneDir.Mode))
+	require.Equal(t, mtime.Unix(), cloneDir.ModifyTime.Unix())
+
+	// the extent keys are shared without the versions of the source
+	cloneFile := dst.lookup(cloneDir.Inode, "file")
+	require.NotNil(t, cloneFile)
+	require.Equal(t, uint64(20480), cloneFile.Size)
+	require.Equal(t, uint32(2), cloneFile.Nlink)
+	require.Equal(t, cloneFile, dst.lookup(proto.RootIno, "link"))
+	require.Len(t, dst.extents[cloneFile.Inode], 3)
+	require.Equal(t, uint64(1025), dst.extents[cloneFile.Inode][0].ExtentId)
+	require.Nil(t, dst.extents[cloneFile.Inode][0].SnapInfo)
+	// the range on the tiny extent is rewritten into the clone
+	require.Equal(t, uint64(12288), dst.extents[cloneFile.Inode][2].FileOffset)
+	require.Equal(t, uint64(100), dst.extents[cloneFile.Inode][2].PartitionId)
+	require.NotNil(t, src.extents[file.Inode][0].SnapInfo)
+	require.Equal(t, "v", dst.xattrs[cloneFile.Inode]["user.k"])
+	require.Equal(t, []byte("dir/file"), dst.lookup(proto.RootIno, "symlink").Target)
+
+	// the inline data is copied with the hole at the end
+	cloneTiny := dst.lookup(proto.RootIno, "tiny")
+	require.Equal(t, []byte("tiny"), dst.inline[cloneTiny.Inode])
+	require.Equal(t, uint64(8), cloneTiny.Size)
+}
+
+func TestVolCloneScannerUnsupported(t *testing.T) {
+	src := newMockCloneMeta()
+	file, _ := src.Create_ll(proto.RootIno, "file", 0o644, 0, 0, nil, "/file", false)
+	file.StorageClass = proto.StorageClass_BlobStore
+
+	response := runVolCloneTest(src, newMockCloneMeta())
+	require.True(t, response.Done)
+	require.Equal(t, uint8(proto.TaskFailed), response.Status)
+	require.Contains(t, response.Result, "not supported")
+}
+
+// mockCloneData replaces the keys overlapped by the ranges written with the ones in the partition
+// of the clone on the flush, unless the rewrite conflicts.
+type mockCloneData struct {
+	meta     *mockCloneMeta
+	shared   map[uint64]bool
+	written  map[uint64][]proto.ExtentKey
+	conflict bool
+	nextExt  uint64
+}
+
+func (m *mockCloneData) OpenStream(inode uint64, openForWrite, isCache bool) error { return nil }
+func (m *mockCloneData) CloseStream(inode uint64) error                            { return nil }
+func (m *mockCloneData) Close() error                                              { return nil }
+
+func (m *mockCloneData) IsSharedPartition(partitionID uint64) bool {
+	return m.shared[partitionID]
+}
+
+func (m *mockCloneData) Read(inode uint64, data []byte, offset int, size int, storageClass uint32, isMigration bool) (int, error) {
+	if end := int(m.meta.inodes[inode].Size); offset+size > end {
+		if size = end - offset; size <= 0 {
+			return 0, io.EOF
+		}
+	}
+	return size, nil
+}
+
+func (m *mockCloneData) Write(inode uint64, offset int, data []byte, flags int, checkFunc func() error, storageClass uint32, isMigration bool) (int, error) {
+	m.nextExt++
+	m.written[inode] = append(m.written[inode], proto.ExtentKey{FileOffset: uint64(offset), PartitionId: 100, ExtentId: m.nextExt, Size: uint32(len(data))})
+	return len(data), nil
+}
+
+func (m *mockCloneData) Flush(inode uint64) error {
+	written := m.written[inode]
+	delete(m.written, inode)
+	if m.conflict {
+		return nil
+	}
+	for _, w := range written {
+		eks := m.meta.extents[inode][:0]
+		for _, ek := range m.meta.extents[inode] {
+			if ek.FileOffset+uint64(ek.Size) <= w.FileOffset || ek.FileOffset >= w.FileOffset+uint64(w.Size) {
+				eks = append(eks, ek)
+			}
+		}
+		m.meta.extents[inode] = append(eks, w)
+	}
+	return nil
+}
+
+func runVolCloneDetachTest(meta *mockCloneMeta, data *mockCloneData) *proto.VolCloneTaskResponse {
+	cloneLimitPerSecond = defaultCloneLimitPerSecond
+	adminTask := &proto.AdminTask{
+		Request: &proto.VolCloneTaskRequest{Task: &proto.VolCloneTask{
+			Id:        "clone_vol:detach:1",
+			SourceVol: "test_vol",
+			CloneVol:  "clone_vol",
+			Ver:       1,
+			Detach:    true,
+		}},
+		Response: &proto.VolCloneTaskResponse{},
+	}
+	newVolCloneScanner(adminTask, &LcNode{}, nil, meta, data).Start()
+	return adminTask.Response.(*proto.VolCloneTaskResponse)
+}
+
+func TestVolCloneScannerDetach(t *testing.T) {
+	meta := newMockCloneMeta()
+	dir, _ := meta.Create_ll(proto.RootIno, "dir", proto.Mode(os.ModeDir|0o755), 0, 0, nil, "/dir", false)
+	file, _ := meta.Create_ll(dir.Inode, "file", 0o644, 0, 0, nil, "/dir/file", false)
+	meta.AppendExtentKeys(file.Inode, []proto.ExtentKey{
+		{FileOffset: 0, PartitionId: 1, ExtentId: 1025, Size: 4096},
+		{FileOffset: 4096, PartitionId: 5, ExtentId: 1025, Size: 4096},
+		{FileOffset: 8192, PartitionId: 2, ExtentId: 1026, Size: 3 * util.BlockSize},
+	}, proto.StorageClass_Replica_SSD)
+	meta.Link(proto.RootIno, "link", file.Inode, "/link")
+	own, _ := meta.Create_ll(proto.RootIno, "own", 0o644, 0, 0, nil, "/own", false)
+	meta.AppendExtentKeys(own.Inode, []proto.ExtentKey{{PartitionId: 5, ExtentId: 1026, Size: 4096}}, proto.StorageClass_Replica_SSD)
+
+	data := &mockCloneData{meta: meta, shared: map[uint64]bool{1: true, 2: true}, written: make(map[uint64][]proto.ExtentKey)}
+	response := runVolCloneDetachTest(meta, data)
+	require.True(t, response.Done)
+	require.Equal(t, uint8(proto.TaskSucceeds), response.Status, response.Result)
+	require.Equal(t, int64(2), response.DirCount)
+	require.Equal(t, int64(2), response.FileCount)
+	require.Equal(t, int64(2), response.ExtentNum)
+	require.Equal(t, uint64(4096+3*util.BlockSize), response.DetachedBytes)
+
+	// the file of the hard links is rewritten once, the extents of the clone are kept
+	require.Equal(t, uint64(3), data.nextExt)
+	for _, ek := range meta.extents[file.Inode] {
+		require.False(t, data.shared[ek.PartitionId], "%v", ek)
+	}
+	require.Equal(t, uint64(5), meta.extents[own.Inode][0].PartitionId)
+
+	// the rewrite conflicts with the writes of others
+	meta.extents[own.Inode] = []proto.ExtentKey{{PartitionId: 1, ExtentId: 1027, Size: 4096}}
+	data.conflict = true
+	response = runVolCloneDetachTest(meta, data)
+	require.Equal(t, uint8(proto.TaskFailed), response.Status)
+	require.Contains(t, response.Result, "still shared")
+}
@@ -32,6 +32,10 @@ const (
 	configLcNodeTaskCountLimit         = "lcNodeTaskCountLimit"
 	configDelayDelMinute               = "delayDelMinute"
 	configUseCreateTime                = "useCreateTime"
+	configReplicationTargets           = "replicationTargets"
+	configReplicationInterval          = "replicationInterval"
+	configFsckLimitPerSecondStr        = "fsckLimitPerSecond"
+	configCloneLimitPerSecondStr       = "cloneLimitPerSecond"
 )
 
 // Default of configuration value
@@ -55,6 +59,27 @@ const (
 	defaultAllocRetryInterval       = 100
 	defaultWriteRetryInterval       = 100
 	defaultExtenthandlerMaxRetryMin = 10
+
+	defaultReplicationInterval        = 10  // seconds between two rounds of replication
+	defaultReplicationRecheckInterval = 600 // seconds before rechecking volume without replication queue
+	defaultReplicationLease           = 60  // seconds of worker lease on replication queue
+	defaultReplicationBatch           = 100
+	maxReplicationRetry               = 5
+	defaultReplicationTarget          = "default"
+
+	defaultRestoreInterval        = 30  // seconds between two rounds of restore
+	defaultRestoreRecheckInterval = 600 // seconds before rechecking volume without restore directory
+	defaultRestoreLease           = 120 // seconds of worker lease on restore directory
+	defaultRestoreBatch           = 100
+	maxRestoreRetry               = 5
+
+	defaultFsckLimitPerSecond = 10000 // inodes checked per second of each fsck task
+	defaultFsckInodeBatch     = 1000
+	defaultFsckMultipartBatch = 1000
+	fsckLostFoundDir          = "lost+found"
+
+	defaultCloneLimitPerSecond = 10000 // inodes copied per second of each clone task
+	defaultCloneExtentBatch    = 1000
 )
 
 var (
@@ -70,4 +95,7 @@ var (
 	maxDirChanNum             = 1000000
 	delayDelMinute            uint64
 	useCreateTime             bool
+	replicationInterval       int64
+	fsckLimitPerSecond        rate.Limit
+	cloneLimitPerSecond       rate.Limit
 )
@@ -0,0 +1,659 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package lcnode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/cubefs/cubefs/datanode/storage"
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/master"
+	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/log"
+	"golang.org/x/time/rate"
+)
+
+// The fsck walks the meta partitions of the volume one by one in batches of inodes, so only the
+// states needed by the cross-checks are kept in memory rather than the whole inode and dentry
+// lists. While walking, the children of each directory are checked for dangling dentries, and the
+// linked inodes and the referenced extents are recorded. Once the walk is complete, the inodes
+// referenced by no dentry and the extents of the data partitions referenced by no inode are taken
+// as orphans. The orphans are never checked after an incomplete walk, and the ones modified within
+// the safe window are always skipped.
+//
+// The repairs are all done through the metanode operations, which are logged by raft:
+//   - the dangling dentry is deleted only if it still points to the lost inode
+//   - the orphan inode is linked into /lost+found as #<ino>, nothing is deleted
+//   - the orphan extents are queued into the extent deletion of a meta partition, and deleted as
+//     the ones of the evicted inodes
+
+const (
+	fsckInodeReferenced uint8 = 1 << iota // linked by some dentry
+	fsckInodeCandidate                    // linked inode old enough to be checked as orphan
+)
+
+var errFsckStopped = errors.New("fsck stopped")
+
+type FsckScanner struct {
+	ID          string
+	Volume      string
+	mw          FsckMetaWrapper
+	cluster     FsckClusterApi
+	lcnode      *LcNode
+	adminTask   *proto.AdminTask
+	task        *proto.FsckTask
+	limiter     *rate.Limiter
+	startTime   time.Time
+	currentStat *proto.FsckStatistics
+	issueMutex  sync.Mutex
+	issues      []*proto.FsckIssue
+	incomplete  bool
+	inodes      map[uint64]uint8
+	extents     map[uint64]map[uint64]struct{} // referenced extents by data partition
+	lostFound   uint64
+	stopC       chan bool
+}
+
+func NewFsckScanner(adminTask *proto.AdminTask, l *LcNode) (*FsckScanner, error) {
+	request := adminTask.Request.(*proto.FsckTaskRequest)
+	metaWrapper, err := l.newVolumeMetaWrapper(request.Task.VolName)
+	if err != nil {
+		return nil, err
+	}
+	return newFsckScanner(adminTask, l, metaWrapper, &fsckCluster{mc: l.mc, connPool: util.NewConnectPool()}), nil
+}
+
+func newFsckScanner(adminTask *proto.AdminTask, l *LcNode, mw FsckMetaWrapper, cluster FsckClusterApi) *FsckScanner {
+	request := adminTask.Request.(*proto.FsckTaskRequest)
+	if request.Task.SafeWindow <= 0 {
+		request.Task.SafeWindow = proto.DefaultFsckSafeWindow
+	}
+	return &FsckScanner{
+		ID:          request.Task.Id,
+		Volume:      request.Task.VolName,
+		mw:          mw,
+		cluster:     cluster,
+		lcnode:      l,
+		adminTask:   adminTask,
+		task:        request.Task,
+		limiter:     rate.NewLimiter(fsckLimitPerSecond, defaultFsckInodeBatch),
+		startTime:   time.Now(),
+		currentStat: &proto.FsckStatistics{VolName: request.Task.VolName},
+		inodes:      make(map[uint64]uint8),
+		extents:     make(map[uint64]map[uint64]struct{}),
+		stopC:       make(chan bool),
+	}
+}
+
+func (l *LcNode) startFsck(adminTask *proto.AdminTask) {
+	request := adminTask.Request.(*proto.FsckTaskRequest)
+	log.LogInfof("startFsck: fsck task(%v) received!", request.Task)
+	response := &proto.FsckTaskResponse{}
+	adminTask.Response = response
+
+	l.scannerMutex.Lock()
+	if _, ok := l.fsckScanners[request.Task.Id]; ok {
+		log.LogInfof("startFsck: fsck task(%v) is already running!", request.Task)
+		l.scannerMutex.Unlock()
+		return
+	}
+
+	scanner, err := NewFsckScanner(adminTask, l)
+	if err != nil {
+		log.LogErrorf("startFsck: NewFsckScanner err(%v)", err)
+		t := time.Now()
+		response.ID = request.Task.Id
+		response.LcNode = l.localServerAddr
+		response.FsckTask = request.Task
+		response.EndTime = &t
+		response.Done = true
+		response.Status = proto.TaskFailed
+		response.Result = err.Error()
+		l.scannerMutex.Unlock()
+		return
+	}
+	l.fsckScanners[scanner.ID] = scanner
+	l.scannerMutex.Unlock()
+
+	go scanner.Start()
+}
+
+func (s *FsckScanner) Stop() {
+	defer func() {
+		if r := recover(); r != nil {
+			log.LogErrorf("FsckScanner Stop err:%v", r)
+		}
+	}()
+	close(s.stopC)
+	log.LogDebugf("fsck scanner(%v) stopped", s.ID)
+}
+
+func (s *FsckScanner) stopped() bool {
+	select {
+	case <-s.stopC:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *FsckScanner) Start() {
+	response := s.adminTask.Response.(*proto.FsckTaskResponse)
+	response.StartTime = &s.startTime
+	log.LogInfof("fsck start(%v): volume(%v) dryRun(%v) checkExtent(%v) safeWindow(%v)",
+		s.ID, s.Volume, s.task.DryRun, s.task.CheckExtent, s.task.SafeWindow)
+
+	err := s.run()
+
+	t := time.Now()
+	s.fillResponse(response)
+	response.OrphanCheckSkipped = s.incomplete || err != nil
+	response.EndTime = &t
+	response.Done = true
+	switch {
+	case err != nil:
+		response.Status = proto.TaskFailed
+		response.Result = err.Error()
+	case s.currentStat.ErrorSkippedNum > 0:
+		response.Status = proto.TaskFailed
+		response.Result = fmt.Sprintf("%v errors skipped", s.currentStat.ErrorSkippedNum)
+	default:
+		response.Status = proto.TaskSucceeds
+	}
+
+	s.lcnode.scannerMutex.Lock()
+	if _, ok := s.lcnode.fsckScanners[s.ID]; ok {
+		s.Stop()
+		delete(s.lcnode.fsckScanners, s.ID)
+	}
+	s.lcnode.scannerMutex.Unlock()
+	s.mw.Close()
+
+	s.lcnode.respondToMaster(s.adminTask)
+	log.LogInfof("fsck completed(%v): status(%v) result(%v) stat(%+v)", s.ID, response.Status, response.Result, *s.currentStat)
+}
+
+func (s *FsckScanner) run() (err error) {
+	mps, err := s.cluster.GetMetaPartitions(s.Volume)
+	if err != nil {
+		return
+	}
+	sort.Slice(mps, func(i, j int) bool { return mps[i].PartitionID < mps[j].PartitionID })
+	atomic.StoreInt64(&s.currentStat.MetaPartitionNum, int64(len(mps)))
+
+	for _, mp := range mps {
+		if err = s.checkMetaPartition(mp); err == errFsckStopped {
+			return
+		}
+		if err != nil {
+			log.LogErrorf("fsck(%v): check meta partition(%v) err(%v)", s.ID, mp.PartitionID, err)
+			s.skipError()
+			continue
+		}
+		atomic.AddInt64(&s.currentStat.MetaPartitionChecked, 1)
+	}
+	err = nil
+
+	if s.incomplete {
+		log.LogWarnf("fsck(%v): orphans are not checked since the walk is incomplete", s.ID)
+		return
+	}
+	if err = s.checkOrphanInodes(); err != nil {
+		return
+	}
+	if s.task.CheckExtent {
+		err = s.checkOrphanExtents(mps)
+	}
+	return
+}
+
+// skipError counts the error skipped, the orphans are not checked afterwards.
+func (s *FsckScanner) skipError() {
+	s.incomplete = true
+	atomic.AddInt64(&s.currentStat.ErrorSkippedNum, 1)
+}
+
+func (s *FsckScanner) safeBefore() time.Time {
+	return s.startTime.Add(-time.Duration(s.task.SafeWindow) * time.Second)
+}
+
+func (s *FsckScanner) checkMetaPartition(mp *proto.MetaPartitionView) (err error) {
+	atomic.StoreUint64(&s.currentStat.CurrentPartitionID, mp.PartitionID)
+	end := mp.End
+	if mp.MaxInodeID < end {
+		end = mp.MaxInodeID
+	}
+	inodes := make([]uint64, 0, defaultFsckInodeBatch)
+	for start := mp.Start; start <= end; start += defaultFsckInodeBatch {
+		if s.stopped() {
+			return errFsckStopped
+		}
+		inodes = inodes[:0]
+		for ino := start; ino <= end && ino-start < defaultFsckInodeBatch; ino++ {
+			inodes = append(inodes, ino)
+		}
+		_ = s.limiter.WaitN(context.Background(), len(inodes))
+		var infos []*proto.InodeInfo
+		if infos, err = s.mw.BatchInodeGetInPartition(mp.PartitionID, inodes); err != nil {
+			return
+		}
+		for _, info := range infos {
+			s.checkInode(info)
+		}
+		atomic.StoreUint64(&s.currentStat.CurrentInode, inodes[len(inodes)-1])
+		if end-start < defaultFsckInodeBatch {
+			break
+		}
+	}
+	return s.referMultipartInodes(mp.PartitionID)
+}
+
+func (s *FsckScanner) checkInode(info *proto.InodeInfo) {
+	atomic.AddInt64(&s.currentStat.InodeScannedNum, 1)
+	if proto.IsDir(info.Mode) {
+		s.checkDir(info.Inode)
+		return
+	}
+	if info.Nlink > 0 && info.ModifyTime.Before(s.safeBefore()) {
+		s.inodes[info.Inode] |= fsckInodeCandidate
+	}
+	if s.task.CheckExtent {
+		if err := s.referExtents(info); err != nil {
+			log.LogErrorf("fsck(%v): get extents of inode(%v) err(%v)", s.ID, info.Inode, err)
+			s.skipError()
+		}
+	}
+}
+
+func (s *FsckScanner) referExtents(info *proto.InodeInfo) (err error) {
+	refer := func(isMigration bool) error {
+		_, _, eks, err := s.mw.GetExtents(info.Inode, false, false, isMigration)
+		if err == syscall.ENOENT {
+			// deleted since, the extents are deleted along with the inode
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for _, ek := range eks {
+			if storage.IsTinyExtent(ek.ExtentId) {
+				continue
+			}
+			dp, ok := s.extents[ek.PartitionId]
+			if !ok {
+				dp = make(map[uint64]struct{})
+				s.extents[ek.PartitionId] = dp
+			}
+			dp[ek.ExtentId] = struct{}{}
+			atomic.AddInt64(&s.currentStat.ExtentScannedNum, 1)
+		}
+		return nil
+	}
+	if !proto.IsStorageClassBlobStore(info.StorageClass) {
+		if err = refer(false); err != nil {
+			return
+		}
+	}
+	if info.HasMigrationEk && proto.IsStorageClassReplica(info.MigrationStorageClass) {
+		err = refer(true)
+	}
+	return
+}
+
+// referMultipartInodes refers the inodes of the uploaded parts, which are linked by no dentry
+// until the multipart upload is completed.
+func (s *FsckScanner) referMultipartInodes(partitionID uint64) error {
+	var keyMarker, idMarker string
+	for {
+		multiparts, err := s.mw.ListMultipartInPartition(partitionID, keyMarker, idMarker, defaultFsckMultipartBatch)
+		if err != nil {
+			return err
+		}
+		if keyMarker != "" && len(multiparts) > 0 && multiparts[0].Path == keyMarker && multiparts[0].ID == idMarker {
+			multiparts = multiparts[1:]
+		}
+		if len(multiparts) == 0 {
+			return nil
+		}
+		for _, multipart := range multiparts {
+			for _, part := range multipart.Parts {
+				s.inodes[part.Inode] |= fsckInodeReferenced
+			}
+		}
+		last := multiparts[len(multiparts)-1]
+		keyMarker, idMarker = last.Path, last.ID
+	}
+}
+
+func (s *FsckScanner) checkDir(parent uint64) {
+	marker := ""
+	for {
+		if s.stopped() {
+			return
+		}
+		children, err := s.mw.ReadDirLimit_ll(parent, marker, uint64(defaultReadDirLimit))
+		if err == syscall.ENOENT {
+			// deleted since
+			return
+		}
+		if err != nil {
+			log.LogErrorf("fsck(%v): ReadDirLimit_ll parent(%v) marker(%v) err(%v)", s.ID, parent, marker, err)
+			s.skipError()
+			return
+		}
+		childrenNr := len(children)
+		if marker != "" && childrenNr > 0 && children[0].Name == marker {
+			children = children[1:]
+		}
+		if len(children) == 0 {
+			return
+		}
+		atomic.AddInt64(&s.currentStat.DentryScannedNum, int64(len(children)))
+
+		inodes := make([]uint64, 0, len(children))
+		for _, child := range children {
+			s.inodes[child.Inode] |= fsckInodeReferenced
+			inodes = append(inodes, child.Inode)
+		}
+		found := make(map[uint64]struct{}, len(inodes))
+		for _, info := range s.mw.BatchInodeGet(inodes) {
+			found[info.Inode] = struct{}{}
+		}
+		for _, child := range children {
+			if _, ok := found[child.Inode]; !ok {
+				s.checkDanglingDentry(parent, child)
+			}
+		}
+
+		if childrenNr < defaultReadDirLimit {
+			return
+		}
+		marker = children[len(children)-1].Name
+	}
+}
+
+// checkDanglingDentry confirms the dentry missed by the batch get, and deletes it only if it
+// still points to the lost inode.
+func (s *FsckScanner) checkDanglingDentry(parent uint64, child proto.Dentry) {
+	if _, err := s.mw.InodeGet_ll(child.Inode); err != syscall.ENOENT {
+		if err != nil {
+			log.LogErrorf("fsck(%v): InodeGet_ll inode(%v) err(%v)", s.ID, child.Inode, err)
+			atomic.AddInt64(&s.currentStat.ErrorSkippedNum, 1)
+		}
+		return
+	}
+	if ino, _, err := s.mw.Lookup_ll(parent, child.Name); err != nil || ino != child.Inode {
+		// removed or replaced since
+		return
+	}
+
+	atomic.AddInt64(&s.currentStat.DanglingDentryNum, 1)
+	issue := &proto.FsckIssue{
+		Type:     proto.FsckIssueDanglingDentry,
+		ParentID: parent,
+		Name:     child.Name,
+		Inode:    child.Inode,
+	}
+	if !s.task.DryRun {
+		_, err := s.mw.DeleteWithCond_ll(parent, child.Inode, child.Name, false, "")
+		s.setRepaired(issue, err)
+	}
+	s.addIssue(issue)
+}
+
+func (s *FsckScanner) checkOrphanInodes() error {
+	orphans := make([]uint64, 0)
+	for ino, state := range s.inodes {
+		if state == fsckInodeCandidate {
+			orphans = append(orphans, ino)
+		}
+	}
+	sort.Slice(orphans, func(i, j int) bool { return orphans[i] < orphans[j] })
+
+	for _, ino := range orphans {
+		if s.stopped() {
+			return errFsckStopped
+		}
+		info, err := s.mw.InodeGet_ll(ino)
+		if err == syscall.ENOENT {
+			continue
+		}
+		if err != nil {
+			log.LogErrorf("fsck(%v): InodeGet_ll inode(%v) err(%v)", s.ID, ino, err)
+			atomic.AddInt64(&s.currentStat.ErrorSkippedNum, 1)
+			continue
+		}
+		if info.Nlink == 0 || !info.ModifyTime.Before(s.safeBefore()) {
+			continue
+		}
+
+		atomic.AddInt64(&s.currentStat.OrphanInodeNum, 1)
+		issue := &proto.FsckIssue{
+			Type:  proto.FsckIssueOrphanInode,
+			Inode: ino,
+			Size:  info.Size,
+		}
+		if !s.task.DryRun {
+			s.setRepaired(issue, s.linkToLostFound(info))
+		}
+		s.addIssue(issue)
+	}
+	return nil
+}
+
+// linkToLostFound links the orphan inode into /lost+found, which is created if not exist.
+func (s *FsckScanner) linkToLostFound(info *proto.InodeInfo) error {
+	if s.lostFound == 0 {
+		ino, mode, err := s.mw.Lookup_ll(proto.RootIno, fsckLostFoundDir)
+		if err == syscall.ENOENT {
+			var dir *proto.InodeInfo
+			if dir, err = s.mw.Create_ll(proto.RootIno, fsckLostFoundDir, proto.Mode(os.ModeDir|0o700), 0, 0, nil, "/"+fsckLostFoundDir, false); err == nil {
+				ino, mode = dir.Inode, dir.Mode
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if !proto.IsDir(mode) {
+			return fmt.Errorf("/%v is not a directory", fsckLostFoundDir)
+		}
+		s.lostFound = ino
+	}
+	name := fmt.Sprintf("#%d", info.Inode)
+	return s.mw.DentryCreate_ll(s.lostFound, name, info.Inode, info.Mode, "/"+fsckLostFoundDir+"/"+name)
+}
+
+func (s *FsckScanner) checkOrphanExtents(mps []*proto.MetaPartitionView) error {
+	dps, err := s.cluster.GetDataPartitions(s.Volume)
+	if err != nil {
+		return err
+	}
+	if len(mps) == 0 {
+		return nil
+	}
+	// the partitions shared from the source of the clone hold the extents referred by the source
+	owned := dps[:0]
+	for _, dp := range dps {
+		if dp.CloneSource == "" {
+			owned = append(owned, dp)
+		}
+	}
+	dps = owned
+	sort.Slice(dps, func(i, j int) bool { return dps[i].PartitionID < dps[j].PartitionID })
+	atomic.StoreInt64(&s.currentStat.DataPartitionNum, int64(len(dps)))
+	before := s.safeBefore().Unix()
+
+	for _, dp := range dps {
+		if s.stopped() {
+			return errFsckStopped
+		}
+		atomic.StoreUint64(&s.currentStat.CurrentPartitionID, dp.PartitionID)
+		infos, err := s.cluster.GetExtents(dp)
+		if err != nil {
+			log.LogErrorf("fsck(%v): get extents of data partition(%v) err(%v)", s.ID, dp.PartitionID, err)
+			atomic.AddInt64(&s.currentStat.ErrorSkippedNum, 1)
+			continue
+		}
+
+		referenced := s.extents[dp.PartitionID]
+		eks := make([]proto.ExtentKey, 0)
+		issues := make([]*proto.FsckIssue, 0)
+		for _, ei := range infos {
+			if storage.IsTinyExtent(ei.FileID) || ei.IsDeleted || ei.ModifyTime >= before {
+				continue
+			}
+			if _, ok := referenced[ei.FileID]; ok {
+				continue
+			}
+			atomic.AddInt64(&s.currentStat.OrphanExtentNum, 1)
+			eks = append(eks, proto.ExtentKey{PartitionId: dp.PartitionID, ExtentId: ei.FileID})
+			issues = append(issues, &proto.FsckIssue{
+				Type:        proto.FsckIssueOrphanExtent,
+				PartitionID: dp.PartitionID,
+				ExtentID:    ei.FileID,
+				Size:        ei.Size,
+			})
+		}
+		if len(eks) > 0 && !s.task.DryRun {
+			err = s.mw.FsckDeleteExtents(mps[dp.PartitionID%uint64(len(mps))].PartitionID, eks)
+			for _, issue := range issues {
+				s.setRepaired(issue, err)
+			}
+		}
+		for _, issue := range issues {
+			s.addIssue(issue)
+		}
+		atomic.AddInt64(&s.currentStat.DataPartitionChecked, 1)
+	}
+	return nil
+}
+
+func (s *FsckScanner) setRepaired(issue *proto.FsckIssue, err error) {
+	if err != nil {
+		log.LogErrorf("fsck(%v): repair issue(%+v) err(%v)", s.ID, issue, err)
+		issue.Err = err.Error()
+		atomic.AddInt64(&s.currentStat.ErrorSkippedNum, 1)
+		return
+	}
+	log.LogInfof("fsck(%v): issue(%+v) repaired", s.ID, issue)
+	issue.Repaired = true
+	atomic.AddInt64(&s.currentStat.RepairedNum, 1)
+}
+
+func (s *FsckScanner) addIssue(issue *proto.FsckIssue) {
+	log.LogWarnf("fsck(%v): found issue(%+v)", s.ID, issue)
+	s.issueMutex.Lock()
+	defer s.issueMutex.Unlock()
+	if len(s.issues) < proto.FsckMaxReportIssues {
+		s.issues = append(s.issues, issue)
+	}
+}
+
+func (s *FsckScanner) fillResponse(response *proto.FsckTaskResponse) {
+	response.ID = s.ID
+	response.LcNode = s.lcnode.localServerAddr
+	response.FsckTask = s.task
+	response.FsckStatistics = proto.FsckStatistics{
+		VolName:              s.Volume,
+		MetaPartitionNum:     atomic.LoadInt64(&s.currentStat.MetaPartitionNum),
+		MetaPartitionChecked: atomic.LoadInt64(&s.currentStat.MetaPartitionChecked),
+		DataPartitionNum:     atomic.LoadInt64(&s.currentStat.DataPartitionNum),
+		DataPartitionChecked: atomic.LoadInt64(&s.currentStat.DataPartitionChecked),
+		CurrentPartitionID:   atomic.LoadUint64(&s.currentStat.CurrentPartitionID),
+		CurrentInode:         atomic.LoadUint64(&s.currentStat.CurrentInode),
+		InodeScannedNum:      atomic.LoadInt64(&s.currentStat.InodeScannedNum),
+		DentryScannedNum:     atomic.LoadInt64(&s.currentStat.DentryScannedNum),
+		ExtentScannedNum:     atomic.LoadInt64(&s.currentStat.ExtentScannedNum),
+		DanglingDentryNum:    atomic.LoadInt64(&s.currentStat.DanglingDentryNum),
+		OrphanInodeNum:       atomic.LoadInt64(&s.currentStat.OrphanInodeNum),
+		OrphanExtentNum:      atomic.LoadInt64(&s.currentStat.OrphanExtentNum),
+		RepairedNum:          atomic.LoadInt64(&s.currentStat.RepairedNum),
+		ErrorSkippedNum:      atomic.LoadInt64(&s.currentStat.ErrorSkippedNum),
+	}
+	s.issueMutex.Lock()
+	response.Issues = make([]*proto.FsckIssue, len(s.issues))
+	copy(response.Issues, s.issues)
+	s.issueMutex.Unlock()
+}
+
+// progress returns the progress of the running task for the heartbeat.
+func (s *FsckScanner) progress() *proto.FsckTaskResponse {
+	response := &proto.FsckTaskResponse{StartTime: &s.startTime}
+	s.fillResponse(response)
+	return response
+}
+
+// fsckCluster gets the extents of the data partition from the leader, or the first host if the
+// leader is unknown.
+type fsckCluster struct {
+	mc       *master.MasterClient
+	connPool *util.ConnectPool
+}
+
+func (c *fsckCluster) GetMetaPartitions(volName string) ([]*proto.MetaPartitionView, error) {
+	return c.mc.ClientAPI().GetMetaPartitions(volName)
+}
+
+func (c *fsckCluster) GetDataPartitions(volName string) ([]*proto.DataPartitionResponse, error) {
+	view, err := c.mc.ClientAPI().GetDataPartitions(volName)
+	if err != nil {
+		return nil, err
+	}
+	return view.DataPartitions, nil
+}
+
+func (c *fsckCluster) GetExtents(dp *proto.DataPartitionResponse) (infos []*storage.ExtentInfo, err error) {
+	addr := dp.LeaderAddr
+	if addr == "" {
+		if len(dp.Hosts) == 0 {
+			return nil, fmt.Errorf("data partition(%v) has no host", dp.PartitionID)
+		}
+		addr = dp.Hosts[0]
+	}
+	conn, err := c.connPool.GetConnect(addr)
+	if err != nil {
+		return
+	}
+	defer func() {
+		c.connPool.PutConnect(conn, err != nil)
+	}()
+
+	p := proto.NewPacketReqID()
+	p.Opcode = proto.OpGetAllWatermarks
+	p.PartitionID = dp.PartitionID
+	p.ExtentType = proto.NormalExtentType
+	if err = p.WriteToConn(conn); err != nil {
+		return
+	}
+	if err = p.ReadFromConnWithVer(conn, proto.GetAllWatermarksDeadLineTime); err != nil {
+		return
+	}
+	if p.ResultCode != proto.OpOk {
+		err = fmt.Errorf("data partition(%v) addr(%v) result(%v) %v", dp.PartitionID, addr, p.GetResultMsg(), string(p.Data))
+		return
+	}
+	infos = make([]*storage.ExtentInfo, 0)
+	err = json.Unmarshal(p.Data, &infos)
+	return
+}
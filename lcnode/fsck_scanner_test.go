@@ -0,0 +1,242 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package lcnode
+
+import (
+	"os"
+	"sort"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/datanode/storage"
+	"github.com/cubefs/cubefs/proto"
+	"github.com/stretchr/testify/require"
+)
+
+type mockFsckMeta struct {
+	inodes     map[uint64]*proto.InodeInfo
+	dentries   map[uint64][]proto.Dentry
+	extents    map[uint64][]proto.ExtentKey
+	multiparts []*proto.MultipartInfo
+	deleted    []proto.ExtentKey
+	nextIno    uint64
+}
+
+func newMockFsckMeta() *mockFsckMeta {
+	return &mockFsckMeta{
+		inodes:   make(map[uint64]*proto.InodeInfo),
+		dentries: make(map[uint64][]proto.Dentry),
+		extents:  make(map[uint64][]proto.ExtentKey),
+		nextIno:  7,
+	}
+}
+
+func (m *mockFsckMeta) addInode(ino uint64, mode os.FileMode, mtime time.Time) {
+	m.inodes[ino] = &proto.InodeInfo{Inode: ino, Mode: proto.Mode(mode), Nlink: 1, ModifyTime: mtime}
+}
+
+func (m *mockFsckMeta) addDentry(parent uint64, name string, ino uint64) {
+	m.dentries[parent] = append(m.dentries[parent], proto.Dentry{Name: name, Inode: ino})
+	sort.Slice(m.dentries[parent], func(i, j int) bool { return m.dentries[parent][i].Name < m.dentries[parent][j].Name })
+}
+
+func (m *mockFsckMeta) BatchInodeGetInPartition(partitionID uint64, inodes []uint64) ([]*proto.InodeInfo, error) {
+	return m.BatchInodeGet(inodes), nil
+}
+
+func (m *mockFsckMeta) BatchInodeGet(inodes []uint64) []*proto.InodeInfo {
+	infos := make([]*proto.InodeInfo, 0)
+	for _, ino := range inodes {
+		if info, ok := m.inodes[ino]; ok {
+			infos = append(infos, info)
+		}
+	}
+	return infos
+}
+
+func (m *mockFsckMeta) ListMultipartInPartition(partitionID uint64, keyMarker, multipartIdMarker string, maxUploads uint64) ([]*proto.MultipartInfo, error) {
+	if keyMarker != "" {
+		return nil, nil
+	}
+	return m.multiparts, nil
+}
+
+func (m *mockFsckMeta) GetExtents(inode uint64, isCache, openForWrite, isMigration bool) (gen uint64, size uint64, extents []proto.ExtentKey, err error) {
+	return 0, 0, m.extents[inode], nil
+}
+
+func (m *mockFsckMeta) ReadDirLimit_ll(parentID uint64, from string, limit uint64) ([]proto.Dentry, error) {
+	children := make([]proto.Dentry, 0)
+	for _, d := range m.dentries[parentID] {
+		if d.Name >= from {
+			children = append(children, d)
+		}
+	}
+	return children, nil
+}
+
+func (m *mockFsckMeta) Lookup_ll(parentID uint64, name string) (inode uint64, mode uint32, err error) {
+	for _, d := range m.dentries[parentID] {
+		if d.Name == name {
+			return d.Inode, d.Type, nil
+		}
+	}
+	return 0, 0, syscall.ENOENT
+}
+
+func (m *mockFsckMeta) InodeGet_ll(inode uint64) (*proto.InodeInfo, error) {
+	if info, ok := m.inodes[inode]; ok {
+		return info, nil
+	}
+	return nil, syscall.ENOENT
+}
+
+func (m *mockFsckMeta) DeleteWithCond_ll(parentID, cond uint64, name string, isDir bool, fullPath string) (*proto.InodeInfo, error) {
+	children := m.dentries[parentID]
+	for i, d := range children {
+		if d.Name == name && d.Inode == cond {
+			m.dentries[parentID] = append(children[:i], children[i+1:]...)
+			return nil, nil
+		}
+	}
+	return nil, syscall.ENOENT
+}
+
+func (m *mockFsckMeta) Create_ll(parentID uint64, name string, mode, uid, gid uint32, target []byte, fullPath string, ignoreExist bool) (*proto.InodeInfo, error) {
+	m.nextIno++
+	m.inodes[m.nextIno] = &proto.InodeInfo{Inode: m.nextIno, Mode: mode, Nlink: 2, ModifyTime: time.Now()}
+	m.addDentry(parentID, name, m.nextIno)
+	return m.inodes[m.nextIno], nil
+}
+
+func (m *mockFsckMeta) DentryCreate_ll(parentID uint64, name string, inode uint64, mode uint32, fullPath string) error {
+	m.addDentry(parentID, name, inode)
+	return nil
+}
+
+func (m *mockFsckMeta) FsckDeleteExtents(partitionID uint64, eks []proto.ExtentKey) error {
+	m.deleted = append(m.deleted, eks...)
+	return nil
+}
+
+func (m *mockFsckMeta) Close() error {
+	return nil
+}
+
+type mockFsckCluster struct {
+	extents []*storage.ExtentInfo
+}
+
+func (c *mockFsckCluster) GetMetaPartitions(volName string) ([]*proto.MetaPartitionView, error) {
+	return []*proto.MetaPartitionView{{PartitionID: 1, Start: 0, End: 1 << 24, MaxInodeID: 10}}, nil
+}
+
+func (c *mockFsckCluster) GetDataPartitions(volName string) ([]*proto.DataPartitionResponse, error) {
+	return []*proto.DataPartitionResponse{{PartitionID: 1}, {PartitionID: 2, CloneSource: "src_vol"}}, nil
+}
+
+func (c *mockFsckCluster) GetExtents(dp *proto.DataPartitionResponse) ([]*storage.ExtentInfo, error) {
+	return c.extents, nil
+}
+
+// newFsckTestVolume builds the volume with one dangling dentry, one orphan inode and one orphan
+// extent, along with the recent ones which must be skipped.
+func newFsckTestVolume() (*mockFsckMeta, *mockFsckCluster) {
+	old := time.Now().Add(-2 * time.Hour)
+	m := newMockFsckMeta()
+	m.addInode(proto.RootIno, os.ModeDir, old)
+	m.addInode(2, 0, old)
+	m.addDentry(proto.RootIno, "file", 2)
+	m.addDentry(proto.RootIno, "dangling", 5)
+	m.addInode(3, 0, old)        // orphan
+	m.addInode(4, 0, time.Now()) // recent
+	m.addInode(6, 0, old)        // multipart part
+	m.extents[2] = []proto.ExtentKey{{PartitionId: 1, ExtentId: 1025}}
+	m.multiparts = []*proto.MultipartInfo{{ID: "id", Path: "obj", Parts: []*proto.MultipartPartInfo{{ID: 1, Inode: 6}}}}
+
+	c := &mockFsckCluster{extents: []*storage.ExtentInfo{
+		{FileID: 1, ModifyTime: old.Unix()},                     // tiny
+		{FileID: 1025, ModifyTime: old.Unix()},                  // referenced
+		{FileID: 1026, ModifyTime: old.Unix(), Size: 4096},      // orphan
+		{FileID: 1027, ModifyTime: time.Now().Unix()},           // recent
+		{FileID: 1028, ModifyTime: old.Unix(), IsDeleted: true}, // deleted
+	}}
+	return m, c
+}
+
+func runFsckTest(t *testing.T, m *mockFsckMeta, c *mockFsckCluster, dryRun bool) *proto.FsckTaskResponse {
+	fsckLimitPerSecond = defaultFsckLimitPerSecond
+	adminTask := &proto.AdminTask{
+		Request: &proto.FsckTaskRequest{Task: &proto.FsckTask{
+			Id:          "test_vol:1",
+			VolName:     "test_vol",
+			DryRun:      dryRun,
+			CheckExtent: true,
+		}},
+		Response: &proto.FsckTaskResponse{},
+	}
+	scanner := newFsckScanner(adminTask, &LcNode{}, m, c)
+	scanner.Start()
+	response := adminTask.Response.(*proto.FsckTaskResponse)
+	require.True(t, response.Done)
+	require.Equal(t, uint8(proto.TaskSucceeds), response.Status, response.Result)
+	require.False(t, response.OrphanCheckSkipped)
+	require.Equal(t, int64(1), response.MetaPartitionChecked)
+	require.Equal(t, int64(1), response.DataPartitionChecked)
+	require.Equal(t, int64(1), response.DanglingDentryNum)
+	require.Equal(t, int64(1), response.OrphanInodeNum)
+	require.Equal(t, int64(1), response.OrphanExtentNum)
+	require.Len(t, response.Issues, 3)
+	return response
+}
+
+func TestFsckScannerDryRun(t *testing.T) {
+	m, c := newFsckTestVolume()
+	response := runFsckTest(t, m, c, true)
+	require.Equal(t, int64(0), response.RepairedNum)
+	for _, issue := range response.Issues {
+		require.False(t, issue.Repaired)
+	}
+	require.Len(t, m.dentries[proto.RootIno], 2)
+	require.Empty(t, m.deleted)
+}
+
+func TestFsckScannerRepair(t *testing.T) {
+	m, c := newFsckTestVolume()
+	response := runFsckTest(t, m, c, false)
+	require.Equal(t, int64(3), response.RepairedNum)
+
+	// the dangling dentry is deleted and the orphan inode is linked into lost+found
+	lostFound, _, err := m.Lookup_ll(proto.RootIno, fsckLostFoundDir)
+	require.NoError(t, err)
+	_, _, err = m.Lookup_ll(proto.RootIno, "dangling")
+	require.Equal(t, syscall.ENOENT, err)
+	ino, _, err := m.Lookup_ll(lostFound, "#3")
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), ino)
+	require.Equal(t, []proto.ExtentKey{{PartitionId: 1, ExtentId: 1026}}, m.deleted)
+
+	// nothing left to repair
+	c.extents = c.extents[:2]
+	adminTask := &proto.AdminTask{
+		Request:  &proto.FsckTaskRequest{Task: &proto.FsckTask{Id: "test_vol:2", VolName: "test_vol", CheckExtent: true}},
+		Response: &proto.FsckTaskResponse{},
+	}
+	newFsckScanner(adminTask, &LcNode{}, m, c).Start()
+	response = adminTask.Response.(*proto.FsckTaskResponse)
+	require.Equal(t, uint8(proto.TaskSucceeds), response.Status, response.Result)
+	require.Empty(t, response.Issues)
+}
@@ -41,6 +41,9 @@ func (l *LcNode) opMasterHeartbeat(conn net.Conn, p *proto.Packet, remoteAddr st
 		resp = &proto.LcNodeHeartbeatResponse{
 			LcScanningTasks:       make(map[string]*proto.LcNodeRuleTaskResponse),
 			SnapshotScanningTasks: make(map[string]*proto.SnapshotVerDelTaskResponse),
+			FsckScanningTasks:     make(map[string]*proto.FsckTaskResponse),
+			CloneScanningTasks:    make(map[string]*proto.VolCloneTaskResponse),
+			TrashScanningTasks:    make(map[string]*proto.TrashTaskResponse),
 		}
 		adminTask = &proto.AdminTask{
 			Request: req,
@@ -100,6 +103,15 @@ func (l *LcNode) opMasterHeartbeat(conn net.Conn, p *proto.Packet, remoteAddr st
 			}
 			resp.SnapshotScanningTasks[scanner.ID] = info
 		}
+		for _, scanner := range l.fsckScanners {
+			resp.FsckScanningTasks[scanner.ID] = scanner.progress()
+		}
+		for _, scanner := range l.volCloneScanners {
+			resp.CloneScanningTasks[scanner.ID] = scanner.progress()
+		}
+		for _, scanner := range l.trashScanners {
+			resp.TrashScanningTasks[scanner.ID] = scanner.progress()
+		}
 		l.scannerMutex.RUnlock()
 
 		resp.LcTaskCountLimit = lcNodeTaskCountLimit
@@ -195,3 +207,105 @@ func (l *LcNode) opSnapshotVerDel(conn net.Conn, p *proto.Packet) (err error) {
 
 	return
 }
+
+func (l *LcNode) opFsck(conn net.Conn, p *proto.Packet) (err error) {
+	go func() {
+		p.PacketOkReply()
+		if err := p.WriteToConn(conn); err != nil {
+			log.LogErrorf("ack master response: %s", err.Error())
+		}
+	}()
+	data := p.Data
+	var (
+		req       = &proto.FsckTaskRequest{}
+		resp      = &proto.FsckTaskResponse{}
+		adminTask = &proto.AdminTask{
+			Request: req,
+		}
+	)
+
+	decoder := json.NewDecoder(bytes.NewBuffer(data))
+	decoder.UseNumber()
+	if err = decoder.Decode(adminTask); err != nil {
+		resp.LcNode = l.localServerAddr
+		resp.Status = proto.TaskFailed
+		resp.Done = true
+		resp.Result = err.Error()
+		adminTask.Response = resp
+		l.respondToMaster(adminTask)
+		return
+	}
+
+	l.startFsck(adminTask)
+	l.respondToMaster(adminTask)
+
+	return
+}
+
+func (l *LcNode) opVolClone(conn net.Conn, p *proto.Packet) (err error) {
+	go func() {
+		p.PacketOkReply()
+		if err := p.WriteToConn(conn); err != nil {
+			log.LogErrorf("ack master response: %s", err.Error())
+		}
+	}()
+	data := p.Data
+	var (
+		req       = &proto.VolCloneTaskRequest{}
+		resp      = &proto.VolCloneTaskResponse{}
+		adminTask = &proto.AdminTask{
+			Request: req,
+		}
+	)
+
+	decoder := json.NewDecoder(bytes.NewBuffer(data))
+	decoder.UseNumber()
+	if err = decoder.Decode(adminTask); err != nil {
+		resp.LcNode = l.localServerAddr
+		resp.Status = proto.TaskFailed
+		resp.Done = true
+		resp.Result = err.Error()
+		adminTask.Response = resp
+		l.respondToMaster(adminTask)
+		return
+	}
+
+	l.startVolClone(adminTask)
+	l.respondToMaster(adminTask)
+
+	return
+}
+
+func (l *LcNode) opTrash(conn net.Conn, p *proto.Packet) (err error) {
+	go func() {
+		p.PacketOkReply()
+		if err := p.WriteToConn(conn); err != nil {
+			log.LogErrorf("ack master response: %s", err.Error())
+		}
+	}()
+	data := p.Data
+	var (
+		req       = &proto.TrashTaskRequest{}
+		resp      = &proto.TrashTaskResponse{}
+		adminTask = &proto.AdminTask{
+			Request: req,
+		}
+	)
+
+	decoder := json.NewDecoder(bytes.NewBuffer(data))
+	decoder.UseNumber()
+	if err = decoder.Decode(adminTask); err != nil {
+		resp.LcNode = l.localServerAddr
+		resp.Status = proto.TaskFailed
+		resp.Done = true
+		resp.Result = err.Error()
+		adminTask.Response = resp
+		l.respondToMaster(adminTask)
+		return
+	}
+
+	l.startTrash(adminTask)
+	l.respondToMaster(adminTask)
+
+	return
+}
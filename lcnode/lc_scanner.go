@@ -143,6 +143,7 @@ func NewS3Scanner(adminTask *proto.AdminTask, l *LcNode) (*LcScanner, error) {
 		OnAppendExtentKey:           metaWrapper.AppendExtentKey,
 		OnSplitExtentKey:            metaWrapper.SplitExtentKey,
 		OnGetExtents:                metaWrapper.GetExtents,
+		OnGetInlineExtents:          metaWrapper.GetInlineExtents,
 		OnTruncate:                  metaWrapper.Truncate,
 		OnRenewalForbiddenMigration: metaWrapper.RenewalForbiddenMigration,
 		VolStorageClass:             volumeInfo.VolStorageClass,
@@ -14,7 +14,10 @@
 
 package lcnode
 
-import "github.com/cubefs/cubefs/proto"
+import (
+	"github.com/cubefs/cubefs/datanode/storage"
+	"github.com/cubefs/cubefs/proto"
+)
 
 type MetaWrapper interface {
 	ReadDirLimitForSnapShotClean(parentID uint64, from string, limit uint64, verSeq uint64, isDir bool) ([]proto.Dentry, error)
@@ -28,3 +31,69 @@ type MetaWrapper interface {
 	ReadDirLimit_ll(parentID uint64, from string, limit uint64) ([]proto.Dentry, error)
 	Close() error
 }
+
+// QueueMetaWrapper is the meta wrapper of volume used by the workers consuming the reserved
+// directories, e.g. the replication and restore workers.
+type QueueMetaWrapper interface {
+	LookupPath(subdir string) (uint64, error)
+	Lookup_ll(parentID uint64, name string) (inode uint64, mode uint32, err error)
+	InodeGet_ll(inode uint64) (*proto.InodeInfo, error)
+	ReadDirLimit_ll(parentID uint64, from string, limit uint64) ([]proto.Dentry, error)
+	XAttrGet_ll(inode uint64, name string) (*proto.XAttrInfo, error)
+	XAttrGetAll_ll(inode uint64) (*proto.XAttrInfo, error)
+	XAttrSet_ll(inode uint64, name, value []byte) error
+	XAttrDel_ll(inode uint64, name string) error
+	Delete_ll(parentID uint64, name string, isDir bool, fullPath string) (*proto.InodeInfo, error)
+	Evict(inode uint64, fullPath string) error
+	LockDir(ino uint64, lease uint64, lockId int64) (retLockId int64, err error)
+	GetObjExtents(inode uint64) (gen uint64, size uint64, extents []proto.ExtentKey, objExtents []proto.ObjExtentKey, err error)
+	Close() error
+}
+
+type FsckMetaWrapper interface {
+	BatchInodeGetInPartition(partitionID uint64, inodes []uint64) ([]*proto.InodeInfo, error)
+	BatchInodeGet(inodes []uint64) []*proto.InodeInfo
+	ListMultipartInPartition(partitionID uint64, keyMarker, multipartIdMarker string, maxUploads uint64) ([]*proto.MultipartInfo, error)
+	GetExtents(inode uint64, isCache, openForWrite, isMigration bool) (gen uint64, size uint64, extents []proto.ExtentKey, err error)
+	ReadDirLimit_ll(parentID uint64, from string, limit uint64) ([]proto.Dentry, error)
+	Lookup_ll(parentID uint64, name string) (inode uint64, mode uint32, err error)
+	InodeGet_ll(inode uint64) (*proto.InodeInfo, error)
+	DeleteWithCond_ll(parentID, cond uint64, name string, isDir bool, fullPath string) (inode *proto.InodeInfo, err error)
+	Create_ll(parentID uint64, name string, mode, uid, gid uint32, target []byte, fullPath string, ignoreExist bool) (*proto.InodeInfo, error)
+	DentryCreate_ll(parentID uint64, name string, inode uint64, mode uint32, fullPath string) error
+	FsckDeleteExtents(partitionID uint64, eks []proto.ExtentKey) error
+	Close() error
+}
+
+// VolCloneMetaWrapper reads the snapshot of the source volume, and writes the clone volume.
+type VolCloneMetaWrapper interface {
+	ReadDirLimit_ll(parentID uint64, from string, limit uint64) ([]proto.Dentry, error)
+	BatchInodeGet(inodes []uint64) []*proto.InodeInfo
+	GetInlineExtents(inode uint64, isCache, openForWrite, isMigration bool) (gen uint64, size uint64, extents []proto.ExtentKey, inline []byte, err error)
+	XAttrGetAll_ll(inode uint64) (*proto.XAttrInfo, error)
+	Create_ll(parentID uint64, name string, mode, uid, gid uint32, target []byte, fullPath string, ignoreExist bool) (*proto.InodeInfo, error)
+	Link(parentID uint64, name string, ino uint64, fullPath string) (*proto.InodeInfo, error)
+	AppendExtentKeys(inode uint64, eks []proto.ExtentKey, storageClass uint32) error
+	WriteInline(inode, offset uint64, data []byte) error
+	Truncate(inode, size uint64, fullPath string) error
+	Setattr(inode uint64, valid, mode, uid, gid uint32, atime, mtime int64) error
+	BatchSetXAttr_ll(inode uint64, attrs map[string]string) error
+	Close() error
+}
+
+// TrashMetaWrapper manages the trash of the volume, it never moves the entries deleted into the
+// trash.
+type TrashMetaWrapper interface {
+	ListTrash(subdir string, filter *proto.TrashFilter) ([]*proto.TrashEntry, error)
+	RestoreTrash(subdir string, filter *proto.TrashFilter, conflict string) ([]*proto.TrashRestoreResult, error)
+	PurgeTrash(subdir string, filter *proto.TrashFilter) ([]*proto.TrashEntry, error)
+	Close() error
+}
+
+// FsckClusterApi gets the partitions of the volume from the master, and the extents of the data
+// partitions from the datanodes.
+type FsckClusterApi interface {
+	GetMetaPartitions(volName string) ([]*proto.MetaPartitionView, error)
+	GetDataPartitions(volName string) ([]*proto.DataPartitionResponse, error)
+	GetExtents(dp *proto.DataPartitionResponse) ([]*storage.ExtentInfo, error)
+}
@@ -0,0 +1,256 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package lcnode
+
+import (
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/data/stream"
+	"github.com/cubefs/cubefs/sdk/meta"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// queueWorker finds the volumes having the reserved directory of a service, e.g. the replication
+// queue, and walks the entries of them in every round. A directory is processed by one worker of
+// the cluster at a time, which holds the lease on it.
+type queueWorker struct {
+	name     string // name of worker in logs
+	dir      string // reserved directory under volume root
+	interval time.Duration
+	recheck  time.Duration // interval of rechecking the volume without the directory
+	lease    uint64        // seconds of the lease on the directory
+	batch    uint64
+	lcnode   *LcNode
+	volumes  map[string]*queueVolume
+	absent   map[string]time.Time // volumes without the directory, and the time they are checked
+	stopC    chan bool
+
+	prepare func() bool // returns false to skip the round, may be nil
+	process func(v *queueVolume)
+}
+
+type queueVolume struct {
+	worker  string // name of worker in logs
+	name    string
+	dir     string
+	mw      QueueMetaWrapper
+	ec      ExtentApi
+	dirIno  uint64
+	lockId  int64          // id of the lease held on the directory, 0 if never held
+	retries map[string]int // retry count of entries
+}
+
+func newQueueWorker(l *LcNode, name, dir string) *queueWorker {
+	return &queueWorker{
+		name:    name,
+		dir:     dir,
+		lcnode:  l,
+		volumes: make(map[string]*queueVolume),
+		absent:  make(map[string]time.Time),
+		stopC:   l.stopC,
+	}
+}
+
+func (w *queueWorker) run() {
+	log.LogInfof("%v: start: interval(%v)", w.name, w.interval)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stopC:
+			for _, v := range w.volumes {
+				v.close()
+			}
+			log.LogInfof("%v: stop", w.name)
+			return
+		case <-ticker.C:
+			if w.prepare != nil && !w.prepare() {
+				continue
+			}
+			w.refreshVolumes()
+			var wg sync.WaitGroup
+			for _, v := range w.volumes {
+				wg.Add(1)
+				go func(v *queueVolume) {
+					defer wg.Done()
+					w.process(v)
+				}(v)
+			}
+			wg.Wait()
+		}
+	}
+}
+
+// refreshVolumes opens the volumes having the directory, and closes the deleted ones.
+func (w *queueWorker) refreshVolumes() {
+	vols, err := w.lcnode.mc.AdminAPI().ListVols("")
+	if err != nil {
+		log.LogErrorf("%v: list volumes fail: err(%v)", w.name, err)
+		return
+	}
+	exists := make(map[string]struct{}, len(vols))
+	for _, vol := range vols {
+		if vol.Status == 1 {
+			continue
+		}
+		exists[vol.Name] = struct{}{}
+		if _, ok := w.volumes[vol.Name]; ok {
+			continue
+		}
+		if checkTime, ok := w.absent[vol.Name]; ok && time.Since(checkTime) < w.recheck {
+			continue
+		}
+		var v *queueVolume
+		if v, err = w.openVolume(vol.Name); err != nil {
+			log.LogWarnf("%v: open volume fail: volume(%v) err(%v)", w.name, vol.Name, err)
+			continue
+		}
+		if v == nil {
+			w.absent[vol.Name] = time.Now()
+			continue
+		}
+		delete(w.absent, vol.Name)
+		w.volumes[vol.Name] = v
+		log.LogInfof("%v: found directory(%v): volume(%v)", w.name, w.dir, vol.Name)
+	}
+	for name, v := range w.volumes {
+		if _, ok := exists[name]; !ok {
+			v.close()
+			delete(w.volumes, name)
+		}
+	}
+	for name := range w.absent {
+		if _, ok := exists[name]; !ok {
+			delete(w.absent, name)
+		}
+	}
+}
+
+// openVolume returns nil if the volume has no such directory.
+func (w *queueWorker) openVolume(name string) (v *queueVolume, err error) {
+	var metaWrapper *meta.MetaWrapper
+	if metaWrapper, err = w.lcnode.newVolumeMetaWrapper(name); err != nil {
+		return
+	}
+	defer func() {
+		if v == nil {
+			metaWrapper.Close()
+		}
+	}()
+	var dirIno uint64
+	if dirIno, err = lookupReservedDir(metaWrapper, name, w.dir); err != nil {
+		if err == syscall.ENOENT {
+			err = nil
+		}
+		return
+	}
+	var extentClient *stream.ExtentClient
+	if extentClient, err = w.lcnode.newVolumeExtentClient(name, metaWrapper); err != nil {
+		return
+	}
+	v = &queueVolume{
+		worker:  w.name,
+		name:    name,
+		dir:     w.dir,
+		mw:      metaWrapper,
+		ec:      extentClient,
+		dirIno:  dirIno,
+		retries: make(map[string]int),
+	}
+	return
+}
+
+// walkVolume feeds the entries of the directory to fn in order under the lease, until fn returns
+// false or there are no more entries.
+func (w *queueWorker) walkVolume(v *queueVolume, fn func(entry proto.Dentry) bool) {
+	from := ""
+	for {
+		if !v.acquireLease(w.lease) {
+			return
+		}
+		entries, err := v.mw.ReadDirLimit_ll(v.dirIno, from, w.batch)
+		if err != nil {
+			log.LogErrorf("%v: read directory fail: volume(%v) from(%v) err(%v)", w.name, v.name, from, err)
+			return
+		}
+		processed := 0
+		for _, entry := range entries {
+			if entry.Name == from {
+				continue
+			}
+			select {
+			case <-w.stopC:
+				return
+			default:
+			}
+			if !fn(entry) {
+				return
+			}
+			from = entry.Name
+			processed++
+		}
+		if processed == 0 {
+			return
+		}
+	}
+}
+
+// acquireLease makes sure that only one worker processes the directory of volume. The lease is
+// taken or renewed by the metanode as the lock of directory, which is checked and set in one
+// apply, and fails with syscall.EEXIST while the lease is held by other worker.
+func (v *queueVolume) acquireLease(lease uint64) bool {
+	lockId, err := v.mw.LockDir(v.dirIno, lease, v.lockId)
+	if err == syscall.EEXIST {
+		log.LogDebugf("%v: directory is held by other worker: volume(%v)", v.worker, v.name)
+		return false
+	}
+	if err != nil {
+		log.LogWarnf("%v: acquire lease fail: volume(%v) err(%v)", v.worker, v.name, err)
+		return false
+	}
+	v.lockId = lockId
+	return true
+}
+
+// removeEntry unlinks the entry from the directory, and forgets its retries.
+func (v *queueVolume) removeEntry(entry proto.Dentry) {
+	delete(v.retries, entry.Name)
+	fullPath := v.dir + "/" + entry.Name
+	if _, err := v.mw.Delete_ll(v.dirIno, entry.Name, false, fullPath); err != nil && err != syscall.ENOENT {
+		log.LogWarnf("%v: delete entry fail: volume(%v) entry(%v) err(%v)", v.worker, v.name, entry.Name, err)
+		return
+	}
+	if err := v.mw.Evict(entry.Inode, fullPath); err != nil {
+		log.LogWarnf("%v: evict entry fail: volume(%v) entry(%v) err(%v)", v.worker, v.name, entry.Name, err)
+	}
+}
+
+func (v *queueVolume) close() {
+	if v.lockId != 0 {
+		// release the lease for other workers to take over at once
+		if _, err := v.mw.LockDir(v.dirIno, 0, v.lockId); err != nil {
+			log.LogWarnf("%v: release lease fail: volume(%v) err(%v)", v.worker, v.name, err)
+		}
+	}
+	if err := v.ec.Close(); err != nil {
+		log.LogWarnf("%v: close extent client fail: volume(%v) err(%v)", v.worker, v.name, err)
+	}
+	if err := v.mw.Close(); err != nil {
+		log.LogWarnf("%v: close meta wrapper fail: volume(%v) err(%v)", v.worker, v.name, err)
+	}
+}
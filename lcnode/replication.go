@@ -0,0 +1,482 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package lcnode
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/config"
+	"github.com/cubefs/cubefs/util/errors"
+	"github.com/cubefs/cubefs/util/log"
+	"golang.org/x/net/http/httpguts"
+)
+
+const (
+	headerReplicationStatus = "x-amz-replication-status"
+	replicationPathSep      = "/"
+)
+
+// the prefixes of the xattrs kept by the filesystem itself, e.g. the trash and dir stats
+var internalXAttrPrefixes = []string{"cfs.", "cfs_"}
+
+var (
+	errReplicationUnsupported = errors.New("object can not be replicated")
+	errReplicationNoTarget    = errors.New("no replication target for destination account")
+)
+
+// ReplicationTarget is the S3 endpoint and credential of the destination cluster.
+type ReplicationTarget struct {
+	Endpoint  string `json:"endpoint"`
+	Region    string `json:"region"`
+	AccessKey string `json:"accessKey"`
+	SecretKey string `json:"secretKey"`
+}
+
+// parseReplicationTargets parses the replication targets keyed by destination account, the target
+// with key "default" is used if the account of destination is not specified or not found.
+// Example:
+//
+//	"replicationTargets": {
+//	  "default": {
+//	    "endpoint": "http://192.168.0.11:80",
+//	    "region": "cfs_dr",
+//	    "accessKey": "...",
+//	    "secretKey": "..."
+//	  }
+//	}
+func parseReplicationTargets(cfg *config.Config) (targets map[string]*ReplicationTarget, err error) {
+	targets = make(map[string]*ReplicationTarget)
+	raw := cfg.GetValue(configReplicationTargets)
+	if raw == nil {
+		return
+	}
+	var data []byte
+	if data, err = json.Marshal(raw); err != nil {
+		return
+	}
+	if err = json.Unmarshal(data, &targets); err != nil {
+		return
+	}
+	for account, target := range targets {
+		if target == nil || target.Endpoint == "" || target.AccessKey == "" || target.SecretKey == "" {
+			return nil, fmt.Errorf("invalid replication target of account(%v)", account)
+		}
+	}
+	return
+}
+
+// ReplicationWorker consumes the replication queues of all volumes and applies
+// the queued changes to the destination buckets.
+type ReplicationWorker struct {
+	*queueWorker
+	clients map[string]*s3.S3
+}
+
+func NewReplicationWorker(l *LcNode, targets map[string]*ReplicationTarget) *ReplicationWorker {
+	w := &ReplicationWorker{
+		queueWorker: newQueueWorker(l, "ReplicationWorker", proto.ReplicationQueueDir),
+		clients:     make(map[string]*s3.S3),
+	}
+	w.interval = time.Duration(replicationInterval) * time.Second
+	w.recheck = defaultReplicationRecheckInterval * time.Second
+	w.lease = defaultReplicationLease
+	w.batch = defaultReplicationBatch
+	w.process = w.processVolume
+	sess := session.Must(session.NewSession())
+	for account, target := range targets {
+		ac := aws.NewConfig()
+		ac.Endpoint = aws.String(target.Endpoint)
+		ac.Region = aws.String(target.Region)
+		ac.Credentials = credentials.NewStaticCredentials(target.AccessKey, target.SecretKey, "")
+		ac.S3ForcePathStyle = aws.Bool(true)
+		w.clients[account] = s3.New(sess, ac)
+	}
+	return w
+}
+
+func loadReplicationConfig(v *queueVolume) (config *proto.ReplicationConfiguration, err error) {
+	var info *proto.XAttrInfo
+	if info, err = v.mw.XAttrGet_ll(proto.RootIno, proto.XAttrKeyReplicationConfig); err != nil {
+		return
+	}
+	raw := info.Get(proto.XAttrKeyReplicationConfig)
+	if len(raw) == 0 {
+		return
+	}
+	config = &proto.ReplicationConfiguration{}
+	if err = json.Unmarshal(raw, config); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// processVolume applies the queued tasks of volume in order. It stops at the first task
+// failing with a retryable error, which is retried in the next round.
+func (w *ReplicationWorker) processVolume(v *queueVolume) {
+	config, err := loadReplicationConfig(v)
+	if err != nil {
+		log.LogErrorf("ReplicationWorker: load replication config fail: volume(%v) err(%v)", v.name, err)
+		return
+	}
+	w.walkVolume(v, func(entry proto.Dentry) bool {
+		return w.processEntry(v, config, entry)
+	})
+}
+
+// processEntry returns false if the task should be retried later.
+func (w *ReplicationWorker) processEntry(v *queueVolume, config *proto.ReplicationConfiguration,
+	entry proto.Dentry) bool {
+	info, err := v.mw.XAttrGet_ll(entry.Inode, proto.XAttrKeyReplicationTask)
+	if err != nil && err != syscall.ENOENT {
+		log.LogWarnf("ReplicationWorker: get task fail: volume(%v) entry(%v) err(%v)", v.name, entry.Name, err)
+		return false
+	}
+	task := &proto.ReplicationTask{}
+	if err != nil || json.Unmarshal(info.Get(proto.XAttrKeyReplicationTask), task) != nil {
+		log.LogWarnf("ReplicationWorker: drop invalid task: volume(%v) entry(%v) err(%v)", v.name, entry.Name, err)
+		v.removeEntry(entry)
+		return true
+	}
+
+	switch task.Op {
+	case proto.ReplicationOpPut:
+		err = w.replicatePut(v, config, task)
+	case proto.ReplicationOpDelete:
+		err = w.replicateDelete(config, task)
+	case proto.ReplicationOpTagging:
+		err = w.replicateTagging(v, config, task)
+	default:
+		log.LogWarnf("ReplicationWorker: drop task of unknown op: volume(%v) task(%+v)", v.name, task)
+	}
+	if err != nil && isReplicationRetryable(err) {
+		if v.retries[entry.Name]++; v.retries[entry.Name] < maxReplicationRetry {
+			log.LogWarnf("ReplicationWorker: replicate fail and retry later: volume(%v) task(%+v) retry(%v) err(%v)",
+				v.name, task, v.retries[entry.Name], err)
+			return false
+		}
+	}
+	if err != nil {
+		log.LogErrorf("ReplicationWorker: replicate fail: volume(%v) task(%+v) err(%v)", v.name, task, err)
+		if task.Op == proto.ReplicationOpPut {
+			v.setReplicationStatus(task, proto.ReplicationStatusFailed)
+		}
+	} else {
+		log.LogDebugf("ReplicationWorker: replicate success: volume(%v) task(%+v)", v.name, task)
+	}
+	v.removeEntry(entry)
+	return true
+}
+
+func isReplicationRetryable(err error) bool {
+	if err == errReplicationUnsupported || err == errReplicationNoTarget {
+		return false
+	}
+	if reqErr, ok := err.(awserr.RequestFailure); ok {
+		code := reqErr.StatusCode()
+		return code >= http.StatusInternalServerError || code == http.StatusRequestTimeout ||
+			code == http.StatusTooManyRequests
+	}
+	return true
+}
+
+func (w *ReplicationWorker) client(rule *proto.ReplicationRule) (*s3.S3, error) {
+	if client, ok := w.clients[rule.Destination.Account]; ok {
+		return client, nil
+	}
+	if client, ok := w.clients[defaultReplicationTarget]; ok {
+		return client, nil
+	}
+	return nil, errReplicationNoTarget
+}
+
+// replicaRequest marks the request as issued by replication, so that the destination
+// stores the object as replica and does not replicate the change again.
+func replicaRequest(req *request.Request) error {
+	req.HTTPRequest.Header.Set(headerReplicationStatus, proto.ReplicationStatusReplica)
+	return req.Send()
+}
+
+// isUserMetadata reports whether the xattr of the object is the user metadata, which is sent as
+// x-amz-meta-* header. The xattrs of ObjectNode and the filesystem, and the ones set through the
+// POSIX clients that cannot be sent as header are not.
+func isUserMetadata(key, value string) bool {
+	if strings.HasPrefix(key, proto.XAttrKeyOSSPrefix) {
+		return false
+	}
+	for _, prefix := range internalXAttrPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return false
+		}
+	}
+	return httpguts.ValidHeaderFieldName(key) && httpguts.ValidHeaderFieldValue(value)
+}
+
+// objectState is the current state of the replicated object, nil if the object does not exist.
+type objectState struct {
+	inode *proto.InodeInfo
+	xattr *proto.XAttrInfo
+	tags  map[string]string
+}
+
+func (v *queueVolume) loadObject(key string) (state *objectState, err error) {
+	var ino uint64
+	if ino, err = v.mw.LookupPath(replicationPathSep + key); err != nil {
+		if err == syscall.ENOENT {
+			err = nil
+		}
+		return
+	}
+	state = &objectState{}
+	if state.inode, err = v.mw.InodeGet_ll(ino); err != nil {
+		return nil, err
+	}
+	if state.xattr, err = v.mw.XAttrGetAll_ll(ino); err != nil {
+		return nil, err
+	}
+	state.tags = make(map[string]string)
+	if raw := state.xattr.Get(proto.XAttrKeyOSSTagging); len(raw) > 0 {
+		var values url.Values
+		if values, err = url.ParseQuery(string(raw)); err != nil {
+			return nil, err
+		}
+		for key := range values {
+			state.tags[key] = values.Get(key)
+		}
+	}
+	return
+}
+
+func (v *queueVolume) setReplicationStatus(task *proto.ReplicationTask, status string) {
+	state, err := v.loadObject(task.Key)
+	if err != nil || state == nil || string(state.xattr.Get(proto.XAttrKeyOSSVersionId)) != task.VersionId {
+		return
+	}
+	if err = v.mw.XAttrSet_ll(state.inode.Inode, []byte(proto.XAttrKeyReplicationStatus), []byte(status)); err != nil {
+		log.LogWarnf("ReplicationWorker: set replication status fail: volume(%v) key(%v) status(%v) err(%v)",
+			v.name, task.Key, status, err)
+	}
+}
+
+func (w *ReplicationWorker) replicatePut(v *queueVolume, config *proto.ReplicationConfiguration,
+	task *proto.ReplicationTask) (err error) {
+	var state *objectState
+	if state, err = v.loadObject(task.Key); err != nil || state == nil {
+		return
+	}
+	// the object has been overwritten, and the new version is replicated by its own task
+	if string(state.xattr.Get(proto.XAttrKeyOSSVersionId)) != task.VersionId ||
+		string(state.xattr.Get(proto.XAttrKeyReplicationStatus)) == proto.ReplicationStatusReplica {
+		return
+	}
+	ino := state.inode.Inode
+	rule := config.MatchRule(task.Key, state.tags)
+	if rule == nil {
+		return v.mw.XAttrDel_ll(ino, proto.XAttrKeyReplicationStatus)
+	}
+	// the data key of encrypted object is not available in the destination
+	if len(state.xattr.Get(proto.XAttrKeyOSSSSE)) > 0 || proto.IsStorageClassBlobStore(state.inode.StorageClass) {
+		return errReplicationUnsupported
+	}
+	var client *s3.S3
+	if client, err = w.client(rule); err != nil {
+		return
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(rule.Destination.BucketName()),
+		Key:    aws.String(task.Key),
+	}
+	if mime := string(state.xattr.Get(proto.XAttrKeyOSSMIME)); mime != "" {
+		input.ContentType = aws.String(mime)
+	}
+	if disposition := string(state.xattr.Get(proto.XAttrKeyOSSDISPOSITION)); disposition != "" {
+		input.ContentDisposition = aws.String(disposition)
+	}
+	if cacheControl := string(state.xattr.Get(proto.XAttrKeyOSSCacheControl)); cacheControl != "" {
+		input.CacheControl = aws.String(cacheControl)
+	}
+	if expires, err := http.ParseTime(string(state.xattr.Get(proto.XAttrKeyOSSExpires))); err == nil {
+		input.Expires = aws.Time(expires)
+	}
+	if len(state.tags) > 0 {
+		values := url.Values{}
+		for key, value := range state.tags {
+			values.Set(key, value)
+		}
+		input.Tagging = aws.String(values.Encode())
+	}
+	if rule.Destination.StorageClass != "" {
+		input.StorageClass = aws.String(rule.Destination.StorageClass)
+	}
+	metadata := make(map[string]*string)
+	for key, value := range state.xattr.XAttrs {
+		if isUserMetadata(key, value) {
+			metadata[key] = aws.String(value)
+		}
+	}
+	if len(metadata) > 0 {
+		input.Metadata = metadata
+	}
+
+	if os.FileMode(state.inode.Mode).IsDir() {
+		input.Body = bytes.NewReader(nil)
+	} else {
+		if err = v.ec.OpenStream(ino, false, false); err != nil {
+			return
+		}
+		defer func() {
+			if closeErr := v.ec.CloseStream(ino); closeErr != nil {
+				log.LogWarnf("ReplicationWorker: close stream fail: volume(%v) inode(%v) err(%v)", v.name, ino, closeErr)
+			}
+		}()
+		input.Body = newExtentReader(v.ec, ino, int64(state.inode.Size), state.inode.StorageClass)
+		input.ContentLength = aws.Int64(int64(state.inode.Size))
+	}
+	req, _ := client.PutObjectRequest(input)
+	if err = replicaRequest(req); err != nil {
+		return
+	}
+	if err = v.mw.XAttrSet_ll(ino, []byte(proto.XAttrKeyReplicationStatus),
+		[]byte(proto.ReplicationStatusCompleted)); err != nil {
+		log.LogWarnf("ReplicationWorker: set replication status fail: volume(%v) key(%v) err(%v)",
+			v.name, task.Key, err)
+		err = nil
+	}
+	return
+}
+
+func (w *ReplicationWorker) replicateDelete(config *proto.ReplicationConfiguration,
+	task *proto.ReplicationTask) (err error) {
+	// rules filtering by tags never match delete markers
+	rule := config.MatchRule(task.Key, map[string]string{})
+	if rule == nil || !rule.IsDeleteMarkerReplicated() {
+		return
+	}
+	var client *s3.S3
+	if client, err = w.client(rule); err != nil {
+		return
+	}
+	req, _ := client.DeleteObjectRequest(&s3.DeleteObjectInput{
+		Bucket: aws.String(rule.Destination.BucketName()),
+		Key:    aws.String(task.Key),
+	})
+	return replicaRequest(req)
+}
+
+func (w *ReplicationWorker) replicateTagging(v *queueVolume, config *proto.ReplicationConfiguration,
+	task *proto.ReplicationTask) (err error) {
+	var state *objectState
+	if state, err = v.loadObject(task.Key); err != nil || state == nil {
+		return
+	}
+	// tags of object not replicated yet are carried by the put task
+	if string(state.xattr.Get(proto.XAttrKeyReplicationStatus)) != proto.ReplicationStatusCompleted {
+		return
+	}
+	rule := config.MatchRule(task.Key, state.tags)
+	if rule == nil {
+		return
+	}
+	var client *s3.S3
+	if client, err = w.client(rule); err != nil {
+		return
+	}
+	bucket := aws.String(rule.Destination.BucketName())
+	if len(state.tags) == 0 {
+		req, _ := client.DeleteObjectTaggingRequest(&s3.DeleteObjectTaggingInput{Bucket: bucket, Key: aws.String(task.Key)})
+		return replicaRequest(req)
+	}
+	keys := make([]string, 0, len(state.tags))
+	for key := range state.tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	tagSet := make([]*s3.Tag, 0, len(keys))
+	for _, key := range keys {
+		tagSet = append(tagSet, &s3.Tag{Key: aws.String(key), Value: aws.String(state.tags[key])})
+	}
+	req, _ := client.PutObjectTaggingRequest(&s3.PutObjectTaggingInput{
+		Bucket:  bucket,
+		Key:     aws.String(task.Key),
+		Tagging: &s3.Tagging{TagSet: tagSet},
+	})
+	return replicaRequest(req)
+}
+
+// extentReader reads the data of inode by extent client, it is seekable since
+// the request body is read twice for signing and sending.
+type extentReader struct {
+	ec           ExtentApi
+	inode        uint64
+	size         int64
+	offset       int64
+	storageClass uint32
+}
+
+func newExtentReader(ec ExtentApi, inode uint64, size int64, storageClass uint32) *extentReader {
+	return &extentReader{ec: ec, inode: inode, size: size, storageClass: storageClass}
+}
+
+func (r *extentReader) Read(p []byte) (n int, err error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if rest := r.size - r.offset; int64(len(p)) > rest {
+		p = p[:rest]
+	}
+	n, err = r.ec.Read(r.inode, p, int(r.offset), len(p), r.storageClass, false)
+	r.offset += int64(n)
+	if err == io.EOF {
+		err = nil
+	}
+	if err == nil && n == 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return
+}
+
+func (r *extentReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("extentReader.Seek: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("extentReader.Seek: negative position")
+	}
+	r.offset = offset
+	return offset, nil
+}
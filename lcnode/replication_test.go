@@ -0,0 +1,320 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package lcnode
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/stretchr/testify/require"
+)
+
+type mockReplicationMeta struct {
+	sync.Mutex
+	paths   map[string]uint64
+	inodes  map[uint64]*proto.InodeInfo
+	xattrs  map[uint64]map[string]string
+	entries map[string]uint64
+	locks   map[uint64]*mockDirLock
+	lockIds int64
+}
+
+type mockDirLock struct {
+	id     int64
+	expire int64
+}
+
+func newMockReplicationMeta() *mockReplicationMeta {
+	return &mockReplicationMeta{
+		paths:   make(map[string]uint64),
+		inodes:  make(map[uint64]*proto.InodeInfo),
+		xattrs:  make(map[uint64]map[string]string),
+		entries: make(map[string]uint64),
+		locks:   make(map[uint64]*mockDirLock),
+	}
+}
+
+func (m *mockReplicationMeta) setXAttr(inode uint64, key, value string) {
+	if m.xattrs[inode] == nil {
+		m.xattrs[inode] = make(map[string]string)
+	}
+	m.xattrs[inode][key] = value
+}
+
+func (m *mockReplicationMeta) addObject(path string, inode uint64, size uint64, xattrs map[string]string) {
+	m.paths[path] = inode
+	m.inodes[inode] = &proto.InodeInfo{Inode: inode, Size: size}
+	for k, v := range xattrs {
+		m.setXAttr(inode, k, v)
+	}
+}
+
+func (m *mockReplicationMeta) addTask(name string, inode uint64, task *proto.ReplicationTask) {
+	data, _ := json.Marshal(task)
+	m.entries[name] = inode
+	m.setXAttr(inode, proto.XAttrKeyReplicationTask, string(data))
+}
+
+func (m *mockReplicationMeta) LookupPath(subdir string) (uint64, error) {
+	m.Lock()
+	defer m.Unlock()
+	if ino, ok := m.paths[subdir]; ok {
+		return ino, nil
+	}
+	return 0, syscall.ENOENT
+}
+
+func (m *mockReplicationMeta) Lookup_ll(parentID uint64, name string) (inode uint64, mode uint32, err error) {
+	return 0, 0, syscall.ENOENT
+}
+
+func (m *mockReplicationMeta) InodeGet_ll(inode uint64) (*proto.InodeInfo, error) {
+	m.Lock()
+	defer m.Unlock()
+	if info, ok := m.inodes[inode]; ok {
+		return info, nil
+	}
+	return nil, syscall.ENOENT
+}
+
+func (m *mockReplicationMeta) ReadDirLimit_ll(parentID uint64, from string, limit uint64) ([]proto.Dentry, error) {
+	m.Lock()
+	defer m.Unlock()
+	dentries := make([]proto.Dentry, 0)
+	for name, inode := range m.entries {
+		if name >= from {
+			dentries = append(dentries, proto.Dentry{Name: name, Inode: inode})
+		}
+	}
+	sort.Slice(dentries, func(i, j int) bool { return dentries[i].Name < dentries[j].Name })
+	if uint64(len(dentries)) > limit {
+		dentries = dentries[:limit]
+	}
+	return dentries, nil
+}
+
+func (m *mockReplicationMeta) XAttrGet_ll(inode uint64, name string) (*proto.XAttrInfo, error) {
+	m.Lock()
+	defer m.Unlock()
+	info := &proto.XAttrInfo{Inode: inode, XAttrs: make(map[string]string)}
+	if value, ok := m.xattrs[inode][name]; ok {
+		info.XAttrs[name] = value
+	}
+	return info, nil
+}
+
+func (m *mockReplicationMeta) XAttrGetAll_ll(inode uint64) (*proto.XAttrInfo, error) {
+	m.Lock()
+	defer m.Unlock()
+	info := &proto.XAttrInfo{Inode: inode, XAttrs: make(map[string]string)}
+	for k, v := range m.xattrs[inode] {
+		info.XAttrs[k] = v
+	}
+	return info, nil
+}
+
+func (m *mockReplicationMeta) XAttrSet_ll(inode uint64, name, value []byte) error {
+	m.Lock()
+	defer m.Unlock()
+	m.setXAttr(inode, string(name), string(value))
+	return nil
+}
+
+func (m *mockReplicationMeta) XAttrDel_ll(inode uint64, name string) error {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.xattrs[inode], name)
+	return nil
+}
+
+func (m *mockReplicationMeta) Delete_ll(parentID uint64, name string, isDir bool, fullPath string) (*proto.InodeInfo, error) {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.entries, name)
+	return nil, nil
+}
+
+func (m *mockReplicationMeta) Evict(inode uint64, fullPath string) error {
+	return nil
+}
+
+// LockDir takes the lease like the metanode does, see fsmLockDir.
+func (m *mockReplicationMeta) LockDir(ino uint64, lease uint64, lockId int64) (int64, error) {
+	m.Lock()
+	defer m.Unlock()
+	held := m.locks[ino]
+	if lease == 0 {
+		if held != nil && held.id == lockId {
+			delete(m.locks, ino)
+		}
+		return lockId, nil
+	}
+	if lockId == 0 {
+		m.lockIds++
+		lockId = m.lockIds
+	}
+	now := time.Now().Unix()
+	if held != nil && held.id != lockId && held.expire > now {
+		return 0, syscall.EEXIST
+	}
+	m.locks[ino] = &mockDirLock{id: lockId, expire: now + int64(lease)}
+	return lockId, nil
+}
+
+func (m *mockReplicationMeta) GetObjExtents(inode uint64) (gen uint64, size uint64, extents []proto.ExtentKey,
+	objExtents []proto.ObjExtentKey, err error) {
+	return 0, 0, nil, nil, nil
+}
+
+func (m *mockReplicationMeta) Close() error {
+	return nil
+}
+
+type replicatedRequest struct {
+	method string
+	path   string
+	query  string
+	header http.Header
+	body   string
+}
+
+func TestReplicationWorkerProcessVolume(t *testing.T) {
+	var (
+		lock     sync.Mutex
+		requests []*replicatedRequest
+		failures = 4 // exceeds the retries of SDK
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		lock.Lock()
+		defer lock.Unlock()
+		if r.URL.Path == "/backup/flaky" && failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		requests = append(requests, &replicatedRequest{
+			method: r.Method, path: r.URL.Path, query: r.URL.RawQuery, header: r.Header, body: string(body),
+		})
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	config := &proto.ReplicationConfiguration{Rules: []*proto.ReplicationRule{{
+		ID:                      "all",
+		Status:                  proto.RuleEnabled,
+		Destination:             &proto.ReplicationDestination{Bucket: "arn:aws:s3:::backup"},
+		DeleteMarkerReplication: &proto.DeleteMarkerReplication{Status: proto.RuleEnabled},
+	}}}
+	data, _ := json.Marshal(config)
+
+	mw := newMockReplicationMeta()
+	mw.setXAttr(proto.RootIno, proto.XAttrKeyReplicationConfig, string(data))
+	mw.addObject("/a", 10, 5, map[string]string{
+		proto.XAttrKeyOSSVersionId:      "v1",
+		proto.XAttrKeyOSSMIME:           "text/plain",
+		proto.XAttrKeyOSSTagging:        "k=v",
+		"color":                         "red",
+		"cfs.trash.path":                "/a",
+		"bad name":                      "x",
+		proto.XAttrKeyReplicationStatus: proto.ReplicationStatusPending,
+	})
+	mw.addObject("/flaky", 11, 1, map[string]string{
+		proto.XAttrKeyReplicationStatus: proto.ReplicationStatusPending,
+	})
+	mw.addTask("0001", 100, &proto.ReplicationTask{Op: proto.ReplicationOpPut, Key: "a", VersionId: "v1"})
+	mw.addTask("0002", 101, &proto.ReplicationTask{Op: proto.ReplicationOpPut, Key: "a", VersionId: "v0"})
+	mw.addTask("0003", 102, &proto.ReplicationTask{Op: proto.ReplicationOpPut, Key: "flaky"})
+	mw.addTask("0004", 103, &proto.ReplicationTask{Op: proto.ReplicationOpDelete, Key: "b", VersionId: "v2"})
+
+	l := &LcNode{localServerAddr: "127.0.0.1:17510", stopC: make(chan bool)}
+	w := NewReplicationWorker(l, map[string]*ReplicationTarget{
+		defaultReplicationTarget: {Endpoint: server.URL, Region: "cfs_dr", AccessKey: "ak", SecretKey: "sk"},
+	})
+	v := &queueVolume{name: "vol", dir: proto.ReplicationQueueDir, mw: mw, ec: NewMockExtentClient(), dirIno: 2, retries: make(map[string]int)}
+
+	// the flaky task blocks the following ones until it is retried
+	w.processVolume(v)
+	require.Len(t, mw.entries, 2)
+	require.Len(t, requests, 1)
+	require.Equal(t, 1, v.retries["0003"])
+	put := requests[0]
+	require.Equal(t, http.MethodPut, put.method)
+	require.Equal(t, "/backup/a", put.path)
+	require.Equal(t, "aaaaa", put.body)
+	require.Equal(t, "text/plain", put.header.Get("Content-Type"))
+	require.Equal(t, "k=v", put.header.Get("X-Amz-Tagging"))
+	require.Equal(t, "red", put.header.Get("X-Amz-Meta-Color"))
+	for name := range put.header {
+		require.False(t, strings.HasPrefix(name, "X-Amz-Meta-Cfs") || strings.HasPrefix(name, "X-Amz-Meta-Bad"), name)
+	}
+	require.Equal(t, proto.ReplicationStatusReplica, put.header.Get(headerReplicationStatus))
+	require.Equal(t, proto.ReplicationStatusCompleted, mw.xattrs[10][proto.XAttrKeyReplicationStatus])
+
+	w.processVolume(v)
+	require.Len(t, mw.entries, 0)
+	require.Len(t, requests, 3)
+	require.Equal(t, "/backup/flaky", requests[1].path)
+	require.Equal(t, proto.ReplicationStatusCompleted, mw.xattrs[11][proto.XAttrKeyReplicationStatus])
+	require.Equal(t, http.MethodDelete, requests[2].method)
+	require.Equal(t, "/backup/b", requests[2].path)
+
+	// queue held by other worker is skipped
+	mw.addTask("0005", 104, &proto.ReplicationTask{Op: proto.ReplicationOpTagging, Key: "a"})
+	mw.locks[2] = &mockDirLock{id: 100, expire: 9999999999}
+	w.processVolume(v)
+	require.Len(t, mw.entries, 1)
+
+	mw.locks[2] = &mockDirLock{id: 100, expire: 1}
+	w.processVolume(v)
+	require.Len(t, mw.entries, 0)
+	require.Len(t, requests, 4)
+	require.Equal(t, "/backup/a", requests[3].path)
+	require.True(t, strings.Contains(requests[3].query, "tagging"))
+	require.Equal(t, v.lockId, mw.locks[2].id)
+
+	// the lease is released once the volume is closed
+	v.close()
+	require.NotContains(t, mw.locks, uint64(2))
+}
+
+func TestExtentReaderSeek(t *testing.T) {
+	r := newExtentReader(NewMockExtentClient(), 1, 10, proto.StorageClass_Replica_HDD)
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, "aaaaaaaaaa", string(data))
+
+	offset, err := r.Seek(4, io.SeekStart)
+	require.NoError(t, err)
+	require.Equal(t, int64(4), offset)
+	data, err = io.ReadAll(r)
+	require.NoError(t, err)
+	require.Len(t, data, 6)
+
+	offset, err = r.Seek(-2, io.SeekEnd)
+	require.NoError(t, err)
+	require.Equal(t, int64(8), offset)
+	_, err = r.Seek(-20, io.SeekCurrent)
+	require.Error(t, err)
+}
@@ -0,0 +1,213 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package lcnode
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"path"
+	"syscall"
+	"time"
+
+	"github.com/cubefs/cubefs/blobstore/api/access"
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/data/blobstore"
+	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// RestoreWorker fills the restore entries linked by ObjectNode with the data of objects in
+// BlobStore, and removes the restored copies once expired.
+type RestoreWorker struct {
+	*queueWorker
+	ebs EbsApi
+}
+
+func NewRestoreWorker(l *LcNode) *RestoreWorker {
+	w := &RestoreWorker{queueWorker: newQueueWorker(l, "RestoreWorker", proto.RestoreQueueDir)}
+	w.interval = defaultRestoreInterval * time.Second
+	w.recheck = defaultRestoreRecheckInterval * time.Second
+	w.lease = defaultRestoreLease
+	w.batch = defaultRestoreBatch
+	// objects are only transitioned to BlobStore in clusters having one
+	w.prepare = w.initEbsClient
+	w.process = w.processVolume
+	return w
+}
+
+func (w *RestoreWorker) initEbsClient() bool {
+	if w.ebs != nil {
+		return true
+	}
+	l := w.lcnode
+	if l.ebsAddr == "" {
+		return false
+	}
+	ebsClient, err := blobstore.NewEbsClient(access.Config{
+		ConnMode: access.NoLimitConnMode,
+		Consul: access.ConsulConfig{
+			Address: l.ebsAddr,
+		},
+		MaxSizePutOnce: MaxSizePutOnce,
+		Logger: &access.Logger{
+			Filename: path.Join(l.logDir, "ebs.log"),
+		},
+	})
+	if err != nil {
+		log.LogErrorf("RestoreWorker: new ebs client fail: addr(%v) err(%v)", l.ebsAddr, err)
+		return false
+	}
+	w.ebs = ebsClient
+	return true
+}
+
+// processVolume walks through the restore directory of volume. Unlike replication, entries are
+// independent of each other, a failed entry is retried in the next round without blocking others.
+func (w *RestoreWorker) processVolume(v *queueVolume) {
+	w.walkVolume(v, func(entry proto.Dentry) bool {
+		w.processEntry(v, entry, time.Now())
+		return true
+	})
+}
+
+func (w *RestoreWorker) processEntry(v *queueVolume, entry proto.Dentry, now time.Time) {
+	info, err := v.mw.XAttrGet_ll(entry.Inode, proto.XAttrKeyRestoreTask)
+	if err != nil && err != syscall.ENOENT {
+		log.LogWarnf("RestoreWorker: get task fail: volume(%v) entry(%v) err(%v)", v.name, entry.Name, err)
+		return
+	}
+	task := &proto.RestoreTask{}
+	if err != nil || json.Unmarshal(info.Get(proto.XAttrKeyRestoreTask), task) != nil {
+		log.LogWarnf("RestoreWorker: drop invalid task: volume(%v) entry(%v) err(%v)", v.name, entry.Name, err)
+		v.removeEntry(entry)
+		return
+	}
+
+	if task.Expiry != 0 {
+		if now.Unix() < task.Expiry {
+			return
+		}
+		// the restored copy is expired, the object is read from BlobStore again
+		v.clearRestoreStatus(task)
+		v.removeEntry(entry)
+		log.LogInfof("RestoreWorker: restored copy expired: volume(%v) task(%+v)", v.name, task)
+		return
+	}
+
+	if err = w.restore(v, entry, task, now); err != nil {
+		if v.retries[entry.Name]++; v.retries[entry.Name] < maxRestoreRetry {
+			log.LogWarnf("RestoreWorker: restore fail and retry later: volume(%v) task(%+v) retry(%v) err(%v)",
+				v.name, task, v.retries[entry.Name], err)
+			return
+		}
+		log.LogErrorf("RestoreWorker: restore fail: volume(%v) task(%+v) err(%v)", v.name, task, err)
+		v.clearRestoreStatus(task)
+		v.removeEntry(entry)
+	}
+	delete(v.retries, entry.Name)
+}
+
+// restore copies the data of object from BlobStore into the restore entry, and marks the object
+// restored. The entry is dropped if the object is removed or not in BlobStore any more.
+func (w *RestoreWorker) restore(v *queueVolume, entry proto.Dentry, task *proto.RestoreTask, now time.Time) (err error) {
+	var object *proto.InodeInfo
+	if object, err = v.mw.InodeGet_ll(task.Inode); err != nil && err != syscall.ENOENT {
+		return
+	}
+	if err == syscall.ENOENT || !proto.IsStorageClassBlobStore(object.StorageClass) {
+		log.LogInfof("RestoreWorker: drop task of object not in BlobStore: volume(%v) task(%+v)", v.name, task)
+		v.clearRestoreStatus(task)
+		v.removeEntry(entry)
+		return nil
+	}
+	var copyInfo *proto.InodeInfo
+	if copyInfo, err = v.mw.InodeGet_ll(entry.Inode); err != nil {
+		return
+	}
+	var objExtents []proto.ObjExtentKey
+	if _, _, _, objExtents, err = v.mw.GetObjExtents(task.Inode); err != nil {
+		return
+	}
+	if err = w.copyObject(v, entry.Inode, copyInfo.StorageClass, objExtents); err != nil {
+		return
+	}
+
+	task.Expiry = proto.RestoreExpiry(now, task.Days)
+	var data []byte
+	if data, err = json.Marshal(task); err != nil {
+		return
+	}
+	if err = v.mw.XAttrSet_ll(entry.Inode, []byte(proto.XAttrKeyRestoreTask), data); err != nil {
+		return
+	}
+	status := &proto.RestoreStatus{Expiry: task.Expiry, Inode: entry.Inode, StorageClass: copyInfo.StorageClass}
+	if data, err = json.Marshal(status); err != nil {
+		return
+	}
+	if err = v.mw.XAttrSet_ll(task.Inode, []byte(proto.XAttrKeyRestoreStatus), data); err != nil {
+		return
+	}
+	log.LogInfof("RestoreWorker: restore success: volume(%v) task(%+v) copy(%v)", v.name, task, entry.Inode)
+	return
+}
+
+func (w *RestoreWorker) copyObject(v *queueVolume, inode uint64, storageClass uint32, objExtents []proto.ObjExtentKey) (err error) {
+	if err = v.ec.OpenStream(inode, false, false); err != nil {
+		return
+	}
+	defer func() {
+		if closeErr := v.ec.CloseStream(inode); closeErr != nil {
+			log.LogWarnf("RestoreWorker: close stream fail: volume(%v) inode(%v) err(%v)", v.name, inode, closeErr)
+		}
+	}()
+	buf := make([]byte, 2*util.BlockSize)
+	for _, oek := range objExtents {
+		if err = w.copyExtent(v, inode, storageClass, oek, buf); err != nil {
+			return
+		}
+	}
+	return v.ec.Flush(inode)
+}
+
+func (w *RestoreWorker) copyExtent(v *queueVolume, inode uint64, storageClass uint32, oek proto.ObjExtentKey, buf []byte) (err error) {
+	var body io.ReadCloser
+	if body, err = w.ebs.Get(context.Background(), v.name, 0, oek.Size, oek); err != nil {
+		return
+	}
+	defer body.Close()
+	offset := int(oek.FileOffset)
+	var readN int
+	for rest := oek.Size; rest > 0; rest -= uint64(readN) {
+		size := len(buf)
+		if uint64(size) > rest {
+			size = int(rest)
+		}
+		if readN, err = io.ReadFull(body, buf[:size]); err != nil {
+			return
+		}
+		if _, err = v.ec.Write(inode, offset, buf[:readN], 0, nil, storageClass, false); err != nil {
+			return
+		}
+		offset += readN
+	}
+	return
+}
+
+func (v *queueVolume) clearRestoreStatus(task *proto.RestoreTask) {
+	if err := v.mw.XAttrDel_ll(task.Inode, proto.XAttrKeyRestoreStatus); err != nil && err != syscall.ENOENT {
+		log.LogWarnf("RestoreWorker: clear restore status fail: volume(%v) task(%+v) err(%v)", v.name, task, err)
+	}
+}
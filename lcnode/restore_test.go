@@ -0,0 +1,149 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package lcnode
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/stretchr/testify/require"
+)
+
+type mockRestoreMeta struct {
+	*mockReplicationMeta
+	objExtents map[uint64][]proto.ObjExtentKey
+}
+
+func (m *mockRestoreMeta) GetObjExtents(inode uint64) (gen uint64, size uint64, extents []proto.ExtentKey,
+	objExtents []proto.ObjExtentKey, err error) {
+	return 0, 0, nil, m.objExtents[inode], nil
+}
+
+type mockRestoreEbs struct {
+	data []byte
+	err  error
+}
+
+func (m *mockRestoreEbs) Put(ctx context.Context, volName string, f io.Reader, size uint64) (oek []proto.ObjExtentKey, md5 [][]byte, err error) {
+	return nil, nil, errors.New("not supported")
+}
+
+func (m *mockRestoreEbs) Get(ctx context.Context, volName string, offset uint64, size uint64, oek proto.ObjExtentKey) (io.ReadCloser, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	start := oek.FileOffset + offset
+	return io.NopCloser(bytes.NewReader(m.data[start : start+size])), nil
+}
+
+type mockRestoreExtent struct {
+	MockExtentClient
+	written map[uint64][]byte
+}
+
+func (m *mockRestoreExtent) Write(inode uint64, offset int, data []byte, flags int, checkFunc func() error,
+	storageClass uint32, isMigration bool) (int, error) {
+	buf := m.written[inode]
+	if len(buf) < offset+len(data) {
+		buf = append(buf, make([]byte, offset+len(data)-len(buf))...)
+	}
+	copy(buf[offset:], data)
+	m.written[inode] = buf
+	return len(data), nil
+}
+
+func addRestoreTask(m *mockRestoreMeta, entryIno uint64, task *proto.RestoreTask) proto.Dentry {
+	name := strconv.FormatUint(task.Inode, 10)
+	data, _ := json.Marshal(task)
+	m.entries[name] = entryIno
+	m.inodes[entryIno] = &proto.InodeInfo{Inode: entryIno, StorageClass: proto.StorageClass_Replica_HDD}
+	m.setXAttr(entryIno, proto.XAttrKeyRestoreTask, string(data))
+	return proto.Dentry{Name: name, Inode: entryIno}
+}
+
+func TestRestoreWorker(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	meta := &mockRestoreMeta{
+		mockReplicationMeta: newMockReplicationMeta(),
+		objExtents: map[uint64][]proto.ObjExtentKey{
+			10: {{FileOffset: 0, Size: 4000}, {FileOffset: 4000, Size: 6000}},
+		},
+	}
+	meta.inodes[10] = &proto.InodeInfo{Inode: 10, Size: uint64(len(data)), StorageClass: proto.StorageClass_BlobStore}
+	meta.inodes[11] = &proto.InodeInfo{Inode: 11, StorageClass: proto.StorageClass_Replica_HDD}
+	ebs := &mockRestoreEbs{data: data}
+	ec := &mockRestoreExtent{written: make(map[uint64][]byte)}
+	v := &queueVolume{name: "vol", dir: proto.RestoreQueueDir, mw: meta, ec: ec, dirIno: 1, retries: make(map[string]int)}
+	w := NewRestoreWorker(&LcNode{localServerAddr: "127.0.0.1:17510", stopC: make(chan bool)})
+	w.ebs = ebs
+
+	now := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	entry := addRestoreTask(meta, 100, &proto.RestoreTask{Key: "a", Inode: 10, Days: 1})
+	w.processEntry(v, entry, now)
+	require.Equal(t, data, ec.written[100])
+	status := &proto.RestoreStatus{}
+	require.NoError(t, json.Unmarshal([]byte(meta.xattrs[10][proto.XAttrKeyRestoreStatus]), status))
+	require.Equal(t, proto.RestoreStatus{Expiry: proto.RestoreExpiry(now, 1), Inode: 100,
+		StorageClass: proto.StorageClass_Replica_HDD}, *status)
+	require.True(t, status.IsRestored(now))
+
+	// the copy is kept until expired
+	w.processEntry(v, entry, now.Add(time.Hour))
+	require.Contains(t, meta.entries, entry.Name)
+	w.processEntry(v, entry, now.AddDate(0, 0, 2))
+	require.NotContains(t, meta.entries, entry.Name)
+	require.NotContains(t, meta.xattrs[10], proto.XAttrKeyRestoreStatus)
+
+	// tasks of objects not in BlobStore are dropped
+	meta.setXAttr(11, proto.XAttrKeyRestoreStatus, `{"ongoing":true}`)
+	entry = addRestoreTask(meta, 101, &proto.RestoreTask{Key: "b", Inode: 11, Days: 1})
+	w.processEntry(v, entry, now)
+	require.NotContains(t, meta.entries, entry.Name)
+	require.NotContains(t, meta.xattrs[11], proto.XAttrKeyRestoreStatus)
+
+	// failed restores are retried before giving up
+	ebs.err = errors.New("blobstore unavailable")
+	meta.setXAttr(10, proto.XAttrKeyRestoreStatus, `{"ongoing":true}`)
+	entry = addRestoreTask(meta, 102, &proto.RestoreTask{Key: "a", Inode: 10, Days: 1})
+	for i := 1; i < maxRestoreRetry; i++ {
+		w.processEntry(v, entry, now)
+		require.Contains(t, meta.entries, entry.Name)
+		require.Equal(t, i, v.retries[entry.Name])
+	}
+	w.processEntry(v, entry, now)
+	require.NotContains(t, meta.entries, entry.Name)
+	require.NotContains(t, meta.xattrs[10], proto.XAttrKeyRestoreStatus)
+	require.Empty(t, v.retries)
+
+	// the whole directory is processed under lease
+	ebs.err = nil
+	addRestoreTask(meta, 103, &proto.RestoreTask{Key: "a", Inode: 10, Days: 1})
+	w.processVolume(v)
+	require.Equal(t, data, ec.written[103])
+	require.Equal(t, v.lockId, meta.locks[1].id)
+
+	// the directory held by other worker is skipped
+	meta.locks[1] = &mockDirLock{id: v.lockId + 1, expire: 9999999999}
+	addRestoreTask(meta, 104, &proto.RestoreTask{Key: "a", Inode: 10, Days: 1})
+	w.processVolume(v)
+	require.NotContains(t, ec.written, uint64(104))
+}
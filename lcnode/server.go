@@ -23,6 +23,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/cubefs/cubefs/cmd/common"
@@ -55,12 +56,20 @@ type LcNode struct {
 	control          common.Control
 	lcScanners       map[string]*LcScanner
 	snapshotScanners map[string]*SnapshotScanner
+	fsckScanners     map[string]*FsckScanner
+	volCloneScanners map[string]*VolCloneScanner
+	trashScanners    map[string]*TrashScanner
+
+	replicationTargets map[string]*ReplicationTarget // destination clusters of bucket replication
 }
 
 func NewServer() *LcNode {
 	return &LcNode{
 		lcScanners:       make(map[string]*LcScanner),
 		snapshotScanners: make(map[string]*SnapshotScanner),
+		fsckScanners:     make(map[string]*FsckScanner),
+		volCloneScanners: make(map[string]*VolCloneScanner),
+		trashScanners:    make(map[string]*TrashScanner),
 	}
 }
 
@@ -99,6 +108,11 @@ func doStart(s common.Server, cfg *config.Config) (err error) {
 
 	l.httpServiceStart()
 
+	if len(l.replicationTargets) > 0 {
+		go NewReplicationWorker(l, l.replicationTargets).run()
+	}
+	go NewRestoreWorker(l).run()
+
 	log.LogInfo("lcnode start successfully")
 
 	return
@@ -180,6 +194,24 @@ func (l *LcNode) parseConfig(cfg *config.Config) (err error) {
 	}
 	log.LogWarnf("loadConfig: setup config: %v(%v)", configLcScanLimitPerSecondStr, lcScanLimitPerSecond)
 
+	// parse fsckLimitPerSecond
+	fsckLimitNum := cfg.GetInt64(configFsckLimitPerSecondStr)
+	if fsckLimitNum <= 0 {
+		fsckLimitPerSecond = defaultFsckLimitPerSecond
+	} else {
+		fsckLimitPerSecond = rate.Limit(fsckLimitNum)
+	}
+	log.LogWarnf("loadConfig: setup config: %v(%v)", configFsckLimitPerSecondStr, fsckLimitPerSecond)
+
+	// parse cloneLimitPerSecond
+	cloneLimitNum := cfg.GetInt64(configCloneLimitPerSecondStr)
+	if cloneLimitNum <= 0 {
+		cloneLimitPerSecond = defaultCloneLimitPerSecond
+	} else {
+		cloneLimitPerSecond = rate.Limit(cloneLimitNum)
+	}
+	log.LogWarnf("loadConfig: setup config: %v(%v)", configCloneLimitPerSecondStr, cloneLimitPerSecond)
+
 	// parse lcNodeTaskCount
 	count := cfg.GetInt(configLcNodeTaskCountLimit)
 	if count <= 0 || count > maxLcNodeTaskCountLimit {
@@ -202,6 +234,20 @@ func (l *LcNode) parseConfig(cfg *config.Config) (err error) {
 	useCreateTime = cfg.GetBool(configUseCreateTime)
 	log.LogWarnf("loadConfig: setup config: %v(%v)", configUseCreateTime, useCreateTime)
 
+	// parse replicationTargets, bucket replication is disabled if no target is configured
+	if l.replicationTargets, err = parseReplicationTargets(cfg); err != nil {
+		log.LogErrorf("parseConfig err: %v", err)
+		return
+	}
+	log.LogWarnf("loadConfig: setup config: %v(%v)", configReplicationTargets, len(l.replicationTargets))
+
+	// parse replicationInterval
+	replicationInterval = cfg.GetInt64(configReplicationInterval)
+	if replicationInterval <= 0 {
+		replicationInterval = defaultReplicationInterval
+	}
+	log.LogWarnf("loadConfig: setup config: %v(%v)", configReplicationInterval, replicationInterval)
+
 	stream.SetExentRetryArgs(defaultAllocRetryInterval, defaultWriteRetryInterval, defaultExtenthandlerMaxRetryMin, true)
 
 	return
@@ -329,6 +375,12 @@ func (l *LcNode) handlePacket(conn net.Conn, p *proto.Packet, remoteAddr string)
 		err = l.opLcScan(conn, p)
 	case proto.OpLcNodeSnapshotVerDel:
 		err = l.opSnapshotVerDel(conn, p)
+	case proto.OpLcNodeFsck:
+		err = l.opFsck(conn, p)
+	case proto.OpLcNodeVolClone:
+		err = l.opVolClone(conn, p)
+	case proto.OpLcNodeTrash:
+		err = l.opTrash(conn, p)
 	default:
 		err = fmt.Errorf("%s unknown Opcode: %d, reqId: %d", remoteAddr,
 			p.Opcode, p.GetReqID())
@@ -363,6 +415,69 @@ func (l *LcNode) stopScanners() {
 		s.Stop()
 		delete(l.snapshotScanners, s.ID)
 	}
+	for _, s := range l.fsckScanners {
+		s.Stop()
+		delete(l.fsckScanners, s.ID)
+	}
+	for _, s := range l.volCloneScanners {
+		s.Stop()
+		delete(l.volCloneScanners, s.ID)
+	}
+}
+
+// newVolumeMetaWrapper opens the meta wrapper of volume for the background workers.
+func (l *LcNode) newVolumeMetaWrapper(name string) (*meta.MetaWrapper, error) {
+	return meta.NewMetaWrapper(&meta.MetaConfig{
+		Volume:               name,
+		Masters:              l.masters,
+		Authenticate:         false,
+		ValidateOwner:        false,
+		InnerReq:             true,
+		MetaSendTimeout:      600,
+		DisableTrashByClient: true,
+		AccessReservedNames:  true,
+	})
+}
+
+// lookupReservedDir returns the reserved directory of the services under the volume root, ENOENT
+// is returned if the name is taken by the entry of the user.
+func lookupReservedDir(metaWrapper *meta.MetaWrapper, volume, name string) (ino uint64, err error) {
+	if ino, _, err = metaWrapper.Lookup_ll(proto.RootIno, name); err != nil {
+		return
+	}
+	var reserved bool
+	if reserved, err = metaWrapper.IsReservedDir(ino); err != nil {
+		return
+	}
+	if !reserved {
+		log.LogWarnf("lookupReservedDir: name taken by user: volume(%v) name(%v) ino(%v)", volume, name, ino)
+		return 0, syscall.ENOENT
+	}
+	return
+}
+
+// newVolumeExtentClient opens the extent client of volume for the background workers.
+func (l *LcNode) newVolumeExtentClient(name string, metaWrapper *meta.MetaWrapper) (*stream.ExtentClient, error) {
+	volumeInfo, err := l.mc.AdminAPI().GetVolumeSimpleInfo(name)
+	if err != nil {
+		return nil, err
+	}
+	return stream.NewExtentClient(&stream.ExtentConfig{
+		Volume:                      name,
+		Masters:                     l.masters,
+		FollowerRead:                false,
+		OnAppendExtentKey:           metaWrapper.AppendExtentKey,
+		OnSplitExtentKey:            metaWrapper.SplitExtentKey,
+		OnGetExtents:                metaWrapper.GetExtents,
+		OnGetInlineExtents:          metaWrapper.GetInlineExtents,
+		OnTruncate:                  metaWrapper.Truncate,
+		OnRenewalForbiddenMigration: metaWrapper.RenewalForbiddenMigration,
+		VolStorageClass:             volumeInfo.VolStorageClass,
+		VolAllowedStorageClass:      volumeInfo.AllowedStorageClass,
+		VolCacheDpStorageClass:      volumeInfo.CacheDpStorageClass,
+		OnForbiddenMigration:        metaWrapper.ForbiddenMigration,
+		InnerReq:                    true,
+	})
 }
 
 func (l *LcNode) httpServiceStart() {
@@ -404,6 +519,20 @@ func (l *LcNode) httpServiceStopScanner(w http.ResponseWriter, r *http.Request)
 	log.LogInfof("receive httpServiceStopScanner id: %v", id)
 
 	l.scannerMutex.RLock()
+	if fsckScanner, ok := l.fsckScanners[id]; ok {
+		l.scannerMutex.RUnlock()
+		log.LogInfof("receive httpServiceStopScanner: stop fsck task %v", id)
+		fsckScanner.Stop()
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if cloneScanner, ok := l.volCloneScanners[id]; ok {
+		l.scannerMutex.RUnlock()
+		log.LogInfof("receive httpServiceStopScanner: stop clone task %v", id)
+		cloneScanner.Stop()
+		w.WriteHeader(http.StatusOK)
+		return
+	}
 	scanner, ok := l.lcScanners[id]
 	if !ok {
 		msg := fmt.Sprintf("task id(%v) not exist", id)
@@ -487,6 +616,7 @@ func (l *LcNode) httpServiceGetFile(w http.ResponseWriter, r *http.Request) {
 		OnAppendExtentKey:           metaWrapper.AppendExtentKey,
 		OnSplitExtentKey:            metaWrapper.SplitExtentKey,
 		OnGetExtents:                metaWrapper.GetExtents,
+		OnGetInlineExtents:          metaWrapper.GetInlineExtents,
 		OnTruncate:                  metaWrapper.Truncate,
 		OnRenewalForbiddenMigration: metaWrapper.RenewalForbiddenMigration,
 		VolStorageClass:             uint32(vsc),
@@ -0,0 +1,174 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package lcnode
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// TrashScanner lists, restores or purges the entries in the trash of the volume for the trash
+// task started by the master. The task is done by one call of the meta wrapper, so its progress
+// is only reported as running until it's done.
+type TrashScanner struct {
+	ID        string
+	Volume    string
+	mw        TrashMetaWrapper
+	lcnode    *LcNode
+	adminTask *proto.AdminTask
+	task      *proto.TrashTask
+	startTime time.Time
+}
+
+func NewTrashScanner(adminTask *proto.AdminTask, l *LcNode) (*TrashScanner, error) {
+	request := adminTask.Request.(*proto.TrashTaskRequest)
+	mw, err := l.newVolumeMetaWrapper(request.Task.VolName)
+	if err != nil {
+		return nil, err
+	}
+	return newTrashScanner(adminTask, l, mw), nil
+}
+
+func newTrashScanner(adminTask *proto.AdminTask, l *LcNode, mw TrashMetaWrapper) *TrashScanner {
+	request := adminTask.Request.(*proto.TrashTaskRequest)
+	return &TrashScanner{
+		ID:        request.Task.Id,
+		Volume:    request.Task.VolName,
+		mw:        mw,
+		lcnode:    l,
+		adminTask: adminTask,
+		task:      request.Task,
+		startTime: time.Now(),
+	}
+}
+
+func (l *LcNode) startTrash(adminTask *proto.AdminTask) {
+	request := adminTask.Request.(*proto.TrashTaskRequest)
+	log.LogInfof("startTrash: trash task(%v) received!", request.Task)
+	response := &proto.TrashTaskResponse{}
+	adminTask.Response = response
+
+	l.scannerMutex.Lock()
+	if _, ok := l.trashScanners[request.Task.Id]; ok {
+		log.LogInfof("startTrash: trash task(%v) is already running!", request.Task)
+		l.scannerMutex.Unlock()
+		return
+	}
+
+	scanner, err := NewTrashScanner(adminTask, l)
+	if err != nil {
+		log.LogErrorf("startTrash: NewTrashScanner err(%v)", err)
+		t := time.Now()
+		response.ID = request.Task.Id
+		response.LcNode = l.localServerAddr
+		response.TrashTask = request.Task
+		response.EndTime = &t
+		response.Done = true
+		response.Status = proto.TaskFailed
+		response.Result = err.Error()
+		l.scannerMutex.Unlock()
+		return
+	}
+	l.trashScanners[scanner.ID] = scanner
+	l.scannerMutex.Unlock()
+
+	go scanner.Start()
+}
+
+func (s *TrashScanner) Start() {
+	response := s.adminTask.Response.(*proto.TrashTaskResponse)
+	response.StartTime = &s.startTime
+	log.LogInfof("trash start(%v): vol(%v) op(%v) subdir(%v) filter(%+v) conflict(%v)",
+		s.ID, s.Volume, s.task.Op, s.task.Subdir, s.task.Filter, s.task.Conflict)
+
+	s.fillResponse(response)
+	err := s.run(response)
+
+	t := time.Now()
+	response.EndTime = &t
+	response.Done = true
+	if err != nil {
+		response.Status = proto.TaskFailed
+		response.Result = err.Error()
+	} else {
+		response.Status = proto.TaskSucceeds
+	}
+
+	s.lcnode.scannerMutex.Lock()
+	delete(s.lcnode.trashScanners, s.ID)
+	s.lcnode.scannerMutex.Unlock()
+	s.mw.Close()
+
+	s.lcnode.respondToMaster(s.adminTask)
+	log.LogInfof("trash completed(%v): status(%v) result(%v) entries(%v) failed(%v)",
+		s.ID, response.Status, response.Result, response.EntryNum, response.FailedNum)
+}
+
+// run does the op of the task, the entries are reported even if it fails, since the purge may
+// be half done.
+func (s *TrashScanner) run(response *proto.TrashTaskResponse) (err error) {
+	filter := s.task.Filter
+	if filter == nil {
+		filter = &proto.TrashFilter{Uid: -1}
+	}
+	switch s.task.Op {
+	case proto.TrashOpList, proto.TrashOpPurge:
+		var entries []*proto.TrashEntry
+		if s.task.Op == proto.TrashOpList {
+			entries, err = s.mw.ListTrash(s.task.Subdir, filter)
+		} else {
+			entries, err = s.mw.PurgeTrash(s.task.Subdir, filter)
+		}
+		response.EntryNum = int64(len(entries))
+		if len(entries) > proto.TrashMaxReportEntries {
+			entries = entries[:proto.TrashMaxReportEntries]
+			response.Truncated = true
+		}
+		response.Entries = entries
+	case proto.TrashOpRestore:
+		var results []*proto.TrashRestoreResult
+		results, err = s.mw.RestoreTrash(s.task.Subdir, filter, s.task.Conflict)
+		for _, r := range results {
+			if r.Err != "" {
+				response.FailedNum++
+			}
+		}
+		response.EntryNum = int64(len(results)) - response.FailedNum
+		if len(results) > proto.TrashMaxReportEntries {
+			results = results[:proto.TrashMaxReportEntries]
+			response.Truncated = true
+		}
+		response.Restored = results
+	default:
+		err = fmt.Errorf("invalid trash op %v", s.task.Op)
+	}
+	return
+}
+
+func (s *TrashScanner) fillResponse(response *proto.TrashTaskResponse) {
+	response.ID = s.ID
+	response.LcNode = s.lcnode.localServerAddr
+	response.TrashTask = s.task
+}
+
+// progress returns the progress of the running task for the heartbeat.
+func (s *TrashScanner) progress() *proto.TrashTaskResponse {
+	response := &proto.TrashTaskResponse{StartTime: &s.startTime}
+	s.fillResponse(response)
+	return response
+}
@@ -0,0 +1,122 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package lcnode
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/stretchr/testify/require"
+)
+
+type mockTrashMeta struct {
+	entries  []*proto.TrashEntry
+	purgeErr error
+	subdir   string
+	filter   *proto.TrashFilter
+	conflict string
+	closed   bool
+}
+
+func (m *mockTrashMeta) match(subdir string, filter *proto.TrashFilter) (entries []*proto.TrashEntry) {
+	m.subdir, m.filter = subdir, filter
+	for _, e := range m.entries {
+		if filter.Match(e) {
+			entries = append(entries, e)
+		}
+	}
+	return
+}
+
+func (m *mockTrashMeta) ListTrash(subdir string, filter *proto.TrashFilter) ([]*proto.TrashEntry, error) {
+	return m.match(subdir, filter), nil
+}
+
+func (m *mockTrashMeta) RestoreTrash(subdir string, filter *proto.TrashFilter, conflict string) (results []*proto.TrashRestoreResult, err error) {
+	m.conflict = conflict
+	for _, e := range m.match(subdir, filter) {
+		r := &proto.TrashRestoreResult{Entry: e, Restored: e.Path}
+		if e.Uid == 0 {
+			r.Restored, r.Err = "", "path taken"
+		}
+		results = append(results, r)
+	}
+	return
+}
+
+func (m *mockTrashMeta) PurgeTrash(subdir string, filter *proto.TrashFilter) ([]*proto.TrashEntry, error) {
+	return m.match(subdir, filter), m.purgeErr
+}
+
+func (m *mockTrashMeta) Close() error {
+	m.closed = true
+	return nil
+}
+
+func runTrashTest(m *mockTrashMeta, task *proto.TrashTask) *proto.TrashTaskResponse {
+	task.Id = "test_vol:1"
+	task.VolName = "test_vol"
+	adminTask := &proto.AdminTask{
+		Request:  &proto.TrashTaskRequest{Task: task},
+		Response: &proto.TrashTaskResponse{},
+	}
+	newTrashScanner(adminTask, &LcNode{}, m).Start()
+	return adminTask.Response.(*proto.TrashTaskResponse)
+}
+
+func TestTrashScanner(t *testing.T) {
+	m := &mockTrashMeta{}
+	for i := 0; i < 4; i++ {
+		m.entries = append(m.entries, &proto.TrashEntry{Path: fmt.Sprintf("/dir/f%d", i), Inode: uint64(i + 10), Uid: uint32(i % 2)})
+	}
+
+	response := runTrashTest(m, &proto.TrashTask{Op: proto.TrashOpList, Subdir: "/sub", Filter: &proto.TrashFilter{Uid: 1}})
+	require.True(t, response.Done)
+	require.Equal(t, uint8(proto.TaskSucceeds), response.Status, response.Result)
+	require.Equal(t, int64(2), response.EntryNum)
+	require.Len(t, response.Entries, 2)
+	require.Equal(t, "/sub", m.subdir)
+	require.True(t, m.closed)
+
+	response = runTrashTest(m, &proto.TrashTask{Op: proto.TrashOpRestore, Conflict: proto.TrashConflictRename,
+		Filter: &proto.TrashFilter{Uid: -1}})
+	require.Equal(t, uint8(proto.TaskSucceeds), response.Status, response.Result)
+	require.Equal(t, proto.TrashConflictRename, m.conflict)
+	require.Equal(t, int64(2), response.EntryNum)
+	require.Equal(t, int64(2), response.FailedNum)
+	require.Len(t, response.Restored, 4)
+
+	// the entries purged are reported even if the purge fails
+	m.purgeErr = errors.New("purge failed")
+	response = runTrashTest(m, &proto.TrashTask{Op: proto.TrashOpPurge, Filter: &proto.TrashFilter{Path: "/dir/f1", Uid: -1}})
+	require.Equal(t, uint8(proto.TaskFailed), response.Status)
+	require.Equal(t, "purge failed", response.Result)
+	require.Equal(t, int64(1), response.EntryNum)
+	require.Equal(t, "/dir/f1", response.Entries[0].Path)
+}
+
+func TestTrashScannerTruncated(t *testing.T) {
+	m := &mockTrashMeta{}
+	for i := 0; i < proto.TrashMaxReportEntries+1; i++ {
+		m.entries = append(m.entries, &proto.TrashEntry{Path: fmt.Sprintf("/f%d", i)})
+	}
+	response := runTrashTest(m, &proto.TrashTask{Op: proto.TrashOpList})
+	require.Equal(t, uint8(proto.TaskSucceeds), response.Status, response.Result)
+	require.Equal(t, int64(proto.TrashMaxReportEntries+1), response.EntryNum)
+	require.Len(t, response.Entries, proto.TrashMaxReportEntries)
+	require.True(t, response.Truncated)
+}
@@ -404,6 +404,7 @@ type updateVolReq struct {
 	deleteLockTime           int64
 	followerRead             bool
 	metaFollowerRead         bool
+	metaFollowerReadIndex    bool
 	directRead               bool
 	leaderRetryTimeout       int64
 	authenticate             bool
@@ -428,6 +429,8 @@ type updateVolReq struct {
 	enablePersistAccessTime  bool
 	volStorageClass          uint32
 	forbidWriteOpOfProtoVer0 bool
+	inlineDataThreshold      uint32
+	compression              string
 	quotaOfClass             uint64
 	quotaClass               uint32
 }
@@ -583,6 +586,10 @@ func parseVolUpdateReq(r *http.Request, vol *Vol, req *updateVolReq) (err error)
 		return
 	}
 
+	if req.metaFollowerReadIndex, err = extractBoolWithDefault(r, proto.MetaFollowerReadIndexKey, vol.MetaFollowerReadIndex); err != nil {
+		return
+	}
+
 	if req.directRead, err = extractBoolWithDefault(r, proto.VolEnableDirectRead, vol.DirectRead); err != nil {
 		return
 	}
@@ -627,6 +634,27 @@ func parseVolUpdateReq(r *http.Request, vol *Vol, req *updateVolReq) (err error)
 		return
 	}
 
+	if req.inlineDataThreshold, err = extractUint32WithDefault(r, inlineDataThresholdKey, vol.InlineDataThreshold); err != nil {
+		return
+	}
+	if req.inlineDataThreshold > proto.MaxInlineDataSize {
+		return fmt.Errorf("%s(%v) exceeds the maximum %v", inlineDataThresholdKey, req.inlineDataThreshold, proto.MaxInlineDataSize)
+	}
+	if req.inlineDataThreshold > 0 && !proto.IsHot(vol.VolType) {
+		return fmt.Errorf("%s is only supported by the hot volume", inlineDataThresholdKey)
+	}
+
+	req.compression = vol.Compression
+	if _, ok := r.Form[compressionKey]; ok {
+		req.compression = r.FormValue(compressionKey)
+	}
+	if req.compression != "" && !compressor.IsValidEncoding(req.compression) {
+		return fmt.Errorf("%s(%v) is not a supported encoding", compressionKey, req.compression)
+	}
+	if req.compression != "" && !proto.IsHot(vol.VolType) {
+		return fmt.Errorf("%s is only supported by the hot volume", compressionKey)
+	}
+
 	req.quotaClass, err = extractUint32(r, quotaClass)
 	if err != nil {
 		log.LogErrorf("[parseVolUpdateReq] vol(%v) err: %v", vol.Name, err.Error())
@@ -783,6 +811,7 @@ type createVolReq struct {
 	deleteLockTime          int64
 	followerRead            bool
 	metaFollowerRead        bool
+	metaFollowerReadIndex   bool
 	authenticate            bool
 	crossZone               bool
 	normalZonesFirst        bool
@@ -794,6 +823,7 @@ type createVolReq struct {
 	DpReadOnlyWhenVolFull   bool
 	enableTransaction       proto.TxOpMask
 	enableQuota             bool
+	metaStoreMode           proto.StoreMode
 	txTimeout               int64
 	txConflictRetryNum      int64
 	txConflictRetryInterval int64
@@ -808,6 +838,10 @@ type createVolReq struct {
 	volStorageClass     uint32
 	allowedStorageClass []uint32
 	cacheDpStorageClass uint32
+
+	// writable clone
+	cloneSource string
+	cloneVer    uint64
 }
 
 func checkCacheAction(action int) error {
@@ -982,6 +1016,10 @@ func parseRequestToCreateVol(r *http.Request, req *createVolReq) (err error) {
 		return
 	}
 
+	if req.metaFollowerReadIndex, err = extractBoolWithDefault(r, proto.MetaFollowerReadIndexKey, false); err != nil {
+		return
+	}
+
 	if req.authenticate, err = extractBoolWithDefault(r, authenticateKey, false); err != nil {
 		return
 	}
@@ -1040,6 +1078,10 @@ func parseRequestToCreateVol(r *http.Request, req *createVolReq) (err error) {
 		return
 	}
 
+	if req.metaStoreMode, err = proto.ParseStoreMode(r.FormValue(proto.MetaStoreModeKey)); err != nil {
+		return
+	}
+
 	if req.trashInterval, err = extractInt64WithDefault(r, trashIntervalKey, 0); err != nil {
 		return
 	}
@@ -1164,6 +1206,24 @@ func parseRequestToDecommissionDataPartition(r *http.Request) (ID uint64, nodeAd
 	return extractDataPartitionIDAndAddr(r)
 }
 
+func parseRequestToReportBadDataReplica(r *http.Request) (ID uint64, nodeAddr string, extentID uint64, offset, size int64, err error) {
+	if ID, nodeAddr, err = extractDataPartitionIDAndAddr(r); err != nil {
+		return
+	}
+	if r.FormValue(extentKey) == "" {
+		err = keyNotFound(extentKey)
+		return
+	}
+	if extentID, err = extractUint64(r, extentKey); err != nil {
+		return
+	}
+	if offset, err = extractInt64WithDefault(r, offsetKey, 0); err != nil {
+		return
+	}
+	size, err = extractInt64WithDefault(r, sizeKey, 0)
+	return
+}
+
 func extractNodeAddr(r *http.Request) (nodeAddr string, err error) {
 	if nodeAddr = r.FormValue(addrKey); nodeAddr == "" {
 		err = keyNotFound(addrKey)
@@ -2221,6 +2281,26 @@ func parseS3QosReq(r *http.Request, req *proto.S3QosRequest) (err error) {
 	return
 }
 
+func parseS3PublicAccessBlock(r *http.Request) (block *proto.S3PublicAccessBlock, err error) {
+	if err = r.ParseForm(); err != nil {
+		return
+	}
+	block = &proto.S3PublicAccessBlock{}
+	if block.BlockPublicAcls, err = extractBoolWithDefault(r, blockPublicAclsKey, false); err != nil {
+		return
+	}
+	if block.IgnorePublicAcls, err = extractBoolWithDefault(r, ignorePublicAclsKey, false); err != nil {
+		return
+	}
+	if block.BlockPublicPolicy, err = extractBoolWithDefault(r, blockPublicPolicyKey, false); err != nil {
+		return
+	}
+	if block.RestrictPublicBuckets, err = extractBoolWithDefault(r, restrictPublicBucketsKey, false); err != nil {
+		return
+	}
+	return
+}
+
 func parseRequestToSetDiskBrokenThreshold(r *http.Request) (ratio float64, err error) {
 	if err = r.ParseForm(); err != nil {
 		return
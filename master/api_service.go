@@ -632,6 +632,8 @@ func (m *Server) UidOperate(w http.ResponseWriter, r *http.Request) {
 		op      uint64
 		value   string
 		capSize uint64
+		typ     proto.QuotaOwnerType
+		limit   *proto.UidSpaceInfo
 		uidList []*proto.UidSpaceInfo
 		uidInfo *proto.UidSpaceInfo
 		ok      bool
@@ -654,6 +656,11 @@ func (m *Server) UidOperate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if typ, err = proto.ParseQuotaOwnerType(r.FormValue(quotaOwnerTypeKey)); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+
 	if op != util.UidLimitList {
 		if uid, err = extractUint32(r, UIDKey); err != nil {
 			err = keyNotFound(UIDKey)
@@ -670,7 +677,14 @@ func (m *Server) UidOperate(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	log.LogDebugf("uidOperate. name %v op %v uid %v", volName, op, uid)
+	if op == util.UidSetLimit {
+		if limit, err = parseUidLimit(r); err != nil {
+			sendErrReply(w, r, newErrHTTPReply(err))
+			return
+		}
+	}
+
+	log.LogDebugf("uidOperate. name %v op %v %v %v", volName, op, typ, uid)
 	if vol, err = m.cluster.getVol(volName); err != nil {
 		log.LogDebugf("aclOperate. name %v not found", volName)
 		sendErrReply(w, r, newErrHTTPReply(err))
@@ -679,17 +693,19 @@ func (m *Server) UidOperate(w http.ResponseWriter, r *http.Request) {
 	ok = true
 	switch op {
 	case util.UidGetLimit:
-		ok, uidInfo = vol.uidSpaceManager.checkUid(uid)
+		ok, uidInfo = vol.uidSpaceManager.checkUid(typ, uid)
 		uidList = append(uidList, uidInfo)
-	case util.UidAddLimit, util.UidDelLimit:
+	case util.UidAddLimit, util.UidDelLimit, util.UidSetLimit:
 		cmd := &UidCmd{
-			op:   op,
-			uid:  uid,
-			size: capSize,
+			op:    op,
+			typ:   typ,
+			uid:   uid,
+			size:  capSize,
+			limit: limit,
 		}
 		ok = vol.uidSpaceManager.pushUidCmd(cmd)
 	case util.UidLimitList:
-		uidList = vol.uidSpaceManager.listAll()
+		uidList = vol.uidSpaceManager.listAll(typ)
 	default:
 		// do nothing
 	}
@@ -702,6 +718,36 @@ func (m *Server) UidOperate(w http.ResponseWriter, r *http.Request) {
 	_ = sendOkReply(w, r, newSuccessHTTPReply(rsp))
 }
 
+// parseUidLimit parses the hard and soft limits of util.UidSetLimit, 0 means no limit.
+func parseUidLimit(r *http.Request) (limit *proto.UidSpaceInfo, err error) {
+	limit = &proto.UidSpaceInfo{}
+	if limit.LimitSize, err = extractUint64WithDefault(r, CapacityKey, 0); err != nil {
+		return
+	}
+	if limit.SoftSize, err = extractUint64WithDefault(r, softCapacityKey, 0); err != nil {
+		return
+	}
+	if limit.LimitFiles, err = extractUint64WithDefault(r, MaxFilesKey, 0); err != nil {
+		return
+	}
+	if limit.SoftFiles, err = extractUint64WithDefault(r, softFilesKey, 0); err != nil {
+		return
+	}
+	if limit.GracePeriod, err = extractInt64WithDefault(r, gracePeriodKey, 0); err != nil {
+		return
+	}
+	if limit.LimitSize == 0 && limit.SoftSize == 0 && limit.LimitFiles == 0 && limit.SoftFiles == 0 {
+		return nil, fmt.Errorf("no limit of bytes or files is specified")
+	}
+	if limit.LimitSize != 0 && limit.SoftSize > limit.LimitSize {
+		return nil, fmt.Errorf("soft limit of bytes %v is larger than the hard limit %v", limit.SoftSize, limit.LimitSize)
+	}
+	if limit.LimitFiles != 0 && limit.SoftFiles > limit.LimitFiles {
+		return nil, fmt.Errorf("soft limit of files %v is larger than the hard limit %v", limit.SoftFiles, limit.LimitFiles)
+	}
+	return
+}
+
 func (m *Server) aclOperate(w http.ResponseWriter, r *http.Request) {
 	var (
 		ip      string
@@ -2121,6 +2167,43 @@ func (m *Server) decommissionDataPartition(w http.ResponseWriter, r *http.Reques
 	sendOkReply(w, r, newSuccessHTTPReply(rstMsg))
 }
 
+// reportBadDataReplica handles the report from the client that the data of the extent read from the
+// replica doesn't match the crc of the blocks, and asks the replica to repair the extent.
+func (m *Server) reportBadDataReplica(w http.ResponseWriter, r *http.Request) {
+	var (
+		dp          *DataPartition
+		partitionID uint64
+		addr        string
+		extentID    uint64
+		offset      int64
+		size        int64
+		err         error
+	)
+	metric := exporter.NewTPCnt(apiToMetricsName(proto.AdminReportBadDataReplica))
+	defer func() {
+		doStatAndMetric(proto.AdminReportBadDataReplica, metric, err, nil)
+	}()
+
+	if partitionID, addr, extentID, offset, size, err = parseRequestToReportBadDataReplica(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if dp, err = m.cluster.getDataPartitionByID(partitionID); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(proto.ErrDataPartitionNotExists))
+		return
+	}
+	if !dp.hasHost(addr) {
+		err = fmt.Errorf("dataPartitionID :%v not have host %v", partitionID, addr)
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	log.LogWarnf("action[reportBadDataReplica] dp[%v] replica[%v] extent[%v] offset[%v] size[%v] reported by %v",
+		partitionID, addr, extentID, offset, size, r.RemoteAddr)
+	m.cluster.repairBadDataReplica(dp, addr, extentID, offset, size)
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("repair of extent %v of dataPartitionID :%v on node:%v is triggered",
+		extentID, partitionID, addr)))
+}
+
 func (m *Server) diagnoseDataPartition(w http.ResponseWriter, r *http.Request) {
 	var (
 		err                         error
@@ -2523,6 +2606,7 @@ func (m *Server) updateVol(w http.ResponseWriter, r *http.Request) {
 	newArgs.deleteLockTime = req.deleteLockTime
 	newArgs.followerRead = req.followerRead
 	newArgs.metaFollowerRead = req.metaFollowerRead
+	newArgs.metaFollowerReadIndex = req.metaFollowerReadIndex
 	newArgs.directRead = req.directRead
 	newArgs.authenticate = req.authenticate
 	newArgs.dpSelectorName = req.dpSelectorName
@@ -2547,6 +2631,8 @@ func (m *Server) updateVol(w http.ResponseWriter, r *http.Request) {
 	newArgs.enableAutoDpMetaRepair = req.enableAutoDpMetaRepair
 	newArgs.volStorageClass = req.volStorageClass
 	newArgs.forbidWriteOpOfProtoVer0 = req.forbidWriteOpOfProtoVer0
+	newArgs.inlineDataThreshold = req.inlineDataThreshold
+	newArgs.compression = req.compression
 
 	log.LogWarnf("[updateVolOut] name [%s], z1 [%s], z2[%s] replicaNum[%v], FR[%v], metaFR[%v]",
 		req.name, req.zoneName, vol.zoneName, req.replicaNum, req.followerRead, req.metaFollowerRead)
@@ -3070,6 +3156,7 @@ func (m *Server) getVolSimpleInfo(w http.ResponseWriter, r *http.Request) {
 	}
 
 	volView := newSimpleView(vol)
+	volView.Clones = m.cluster.getVolClones(vol.Name, 0)
 
 	sendOkReply(w, r, newSuccessHTTPReply(volView))
 }
@@ -3093,24 +3180,26 @@ func newSimpleView(vol *Vol) (view *proto.SimpleVolView) {
 	}
 
 	view = &proto.SimpleVolView{
-		ID:                 vol.ID,
-		Name:               vol.Name,
-		Owner:              vol.Owner,
-		ZoneName:           vol.zoneName,
-		DpReplicaNum:       vol.dpReplicaNum,
-		MpReplicaNum:       vol.mpReplicaNum,
-		InodeCount:         volInodeCount,
-		DentryCount:        volDentryCount,
-		MaxMetaPartitionID: maxPartitionID,
-		Status:             vol.Status,
-		Capacity:           vol.Capacity,
-		FollowerRead:       vol.FollowerRead,
-		MetaFollowerRead:   vol.MetaFollowerRead,
-		DirectRead:         vol.DirectRead,
-		LeaderRetryTimeOut: vol.LeaderRetryTimeout,
+		ID:                    vol.ID,
+		Name:                  vol.Name,
+		Owner:                 vol.Owner,
+		ZoneName:              vol.zoneName,
+		DpReplicaNum:          vol.dpReplicaNum,
+		MpReplicaNum:          vol.mpReplicaNum,
+		InodeCount:            volInodeCount,
+		DentryCount:           volDentryCount,
+		MaxMetaPartitionID:    maxPartitionID,
+		Status:                vol.Status,
+		Capacity:              vol.Capacity,
+		FollowerRead:          vol.FollowerRead,
+		MetaFollowerRead:      vol.MetaFollowerRead,
+		MetaFollowerReadIndex: vol.MetaFollowerReadIndex,
+		DirectRead:            vol.DirectRead,
+		LeaderRetryTimeOut:    vol.LeaderRetryTimeout,
 
 		EnablePosixAcl:          vol.enablePosixAcl,
 		EnableQuota:             vol.enableQuota,
+		MetaStoreMode:           vol.metaStoreMode.String(),
 		EnableTransactionV1:     proto.GetMaskString(vol.enableTransaction),
 		EnableTransaction:       "off",
 		TxTimeout:               vol.txTimeout,
@@ -3156,6 +3245,13 @@ func newSimpleView(vol *Vol) (view *proto.SimpleVolView) {
 		CacheDpStorageClass:      vol.cacheDpStorageClass,
 		ForbidWriteOpOfProtoVer0: vol.ForbidWriteOpOfProtoVer0.Load(),
 		QuotaOfStorageClass:      quotaOfClass,
+
+		CloneSource: vol.CloneSource,
+		CloneVer:    vol.CloneVer,
+		CloneStatus: vol.CloneStatus,
+
+		InlineDataThreshold: vol.InlineDataThreshold,
+		Compression:         vol.Compression,
 	}
 	view.AllowedStorageClass = make([]uint32, len(vol.allowedStorageClass))
 	copy(view.AllowedStorageClass, vol.allowedStorageClass)
@@ -3166,6 +3262,7 @@ func newSimpleView(vol *Vol) (view *proto.SimpleVolView) {
 		view.Uids = append(view.Uids, proto.UidSimpleInfo{
 			UID:     uid.Uid,
 			Limited: uid.Limited,
+			Type:    uid.Type,
 		})
 	}
 	return
@@ -5686,6 +5783,9 @@ func volStat(vol *Vol, countByMeta bool) (stat *proto.VolStatInfo) {
 		stat.TxCnt += mp.TxCnt
 		stat.TxRbInoCnt += mp.TxRbInoCnt
 		stat.TxRbDenCnt += mp.TxRbDenCnt
+		if mp.RootDirStat != nil {
+			stat.RootDirStat = mp.RootDirStat
+		}
 	}
 	vol.mpsLock.RUnlock()
 
@@ -5696,11 +5796,14 @@ func volStat(vol *Vol, countByMeta bool) (stat *proto.VolStatInfo) {
 	stat.StatMigrateStorageClass = vol.StatMigrateStorageClass
 	stat.StatByDpMediaType = vol.StatByDpMediaType
 	stat.MetaFollowerRead = vol.MetaFollowerRead
+	stat.MetaFollowerReadIndex = vol.MetaFollowerReadIndex
 	stat.LeaderRetryTimeOut = int(vol.LeaderRetryTimeout)
 
 	log.LogDebugf("[volStat] vol[%v] total[%v],usedSize[%v] TrashInterval[%v] DefaultStorageClass[%v]",
 		vol.Name, stat.TotalSize, stat.UsedSize, stat.TrashInterval, stat.DefaultStorageClass)
 	if proto.IsHot(vol.VolType) {
+		stat.LogicalUsedSize = vol.logicalUsedSpace()
+		stat.PhysicalUsedSize = vol.cfsUsedSpace()
 		return
 	}
 
@@ -7352,6 +7455,201 @@ func (m *Server) adminLcNode(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// adminFsck starts, stops and reports the online fsck of the volume, which is run by lcnode.
+func (m *Server) adminFsck(w http.ResponseWriter, r *http.Request) {
+	metric := exporter.NewTPCnt(apiToMetricsName(proto.AdminFsck))
+	defer func() {
+		doStatAndMetric(proto.AdminFsck, metric, nil, nil)
+	}()
+
+	if err := r.ParseForm(); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if m.cluster.partition == nil || !m.cluster.partition.IsRaftLeader() {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: "not leader"})
+		return
+	}
+	vol := r.FormValue("vol")
+	switch op := r.FormValue("op"); op {
+	case "start":
+		task := &proto.FsckTask{VolName: vol}
+		var err error
+		if task.DryRun, err = extractBoolWithDefault(r, "dryRun", true); err != nil {
+			sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+			return
+		}
+		if task.CheckExtent, err = extractBoolWithDefault(r, "checkExtent", true); err != nil {
+			sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+			return
+		}
+		if task.SafeWindow, err = extractInt64WithDefault(r, "safeWindow", proto.DefaultFsckSafeWindow); err != nil {
+			sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+			return
+		}
+		err = m.cluster.fsckMgr.startFsck(task)
+		auditlog.LogMasterOp("AdminFsck", fmt.Sprintf("op(start), vol(%v), dryRun(%v), checkExtent(%v), safeWindow(%v)",
+			vol, task.DryRun, task.CheckExtent, task.SafeWindow), err)
+		if err != nil {
+			sendErrReply(w, r, newErrHTTPReply(err))
+			return
+		}
+		sendOkReply(w, r, newSuccessHTTPReply(task))
+	case "stop":
+		err := m.cluster.fsckMgr.stopFsck(vol)
+		auditlog.LogMasterOp("AdminFsck", fmt.Sprintf("op(stop), vol(%v)", vol), err)
+		if err != nil {
+			sendErrReply(w, r, newErrHTTPReply(err))
+			return
+		}
+		sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("fsck of volume(%v) is notified to stop", vol)))
+	case "status", "":
+		sendOkReply(w, r, newSuccessHTTPReply(m.cluster.fsckMgr.fsckTaskStatus.GetResults(vol)))
+	default:
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: "invalid op"})
+	}
+}
+
+// adminVolTrash starts the task listing, restoring or purging the entries in the trash of the
+// volume on an lcnode, and reports the results of the tasks.
+func (m *Server) adminVolTrash(w http.ResponseWriter, r *http.Request) {
+	metric := exporter.NewTPCnt(apiToMetricsName(proto.AdminVolTrash))
+	defer func() {
+		doStatAndMetric(proto.AdminVolTrash, metric, nil, nil)
+	}()
+
+	if err := r.ParseForm(); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if m.cluster.partition == nil || !m.cluster.partition.IsRaftLeader() {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: "not leader"})
+		return
+	}
+	switch op := r.FormValue("op"); op {
+	case proto.TrashOpList, proto.TrashOpRestore, proto.TrashOpPurge:
+		name, err := extractName(r)
+		if err != nil {
+			sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+			return
+		}
+		task := &proto.TrashTask{
+			VolName:  name,
+			Op:       op,
+			Subdir:   r.FormValue("subdir"),
+			Filter:   &proto.TrashFilter{Path: r.FormValue("path")},
+			Conflict: r.FormValue("conflict"),
+		}
+		if task.Filter.Uid, err = extractInt64WithDefault(r, "uid", -1); err != nil {
+			sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+			return
+		}
+		if task.Filter.From, err = extractInt64WithDefault(r, "from", 0); err != nil {
+			sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+			return
+		}
+		if task.Filter.To, err = extractInt64WithDefault(r, "to", 0); err != nil {
+			sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+			return
+		}
+		err = m.cluster.trashMgr.startTrashTask(task)
+		auditlog.LogMasterOp("AdminVolTrash", fmt.Sprintf("op(%v), vol(%v), subdir(%v), filter(%+v), conflict(%v)",
+			op, name, task.Subdir, *task.Filter, task.Conflict), err)
+		if err != nil {
+			sendErrReply(w, r, newErrHTTPReply(err))
+			return
+		}
+		sendOkReply(w, r, newSuccessHTTPReply(task))
+	case "status", "":
+		sendOkReply(w, r, newSuccessHTTPReply(m.cluster.trashMgr.trashTaskStatus.GetResults(r.FormValue(nameKey))))
+	default:
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: "invalid op"})
+	}
+}
+
+// adminVolClone creates the writable clone of the volume from a snapshot, detaches the clone
+// from the source, and reports the progress of the tasks of the clones.
+func (m *Server) adminVolClone(w http.ResponseWriter, r *http.Request) {
+	metric := exporter.NewTPCnt(apiToMetricsName(proto.AdminVolClone))
+	defer func() {
+		doStatAndMetric(proto.AdminVolClone, metric, nil, nil)
+	}()
+
+	if err := r.ParseForm(); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if m.cluster.partition == nil || !m.cluster.partition.IsRaftLeader() {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: "not leader"})
+		return
+	}
+	switch op := r.FormValue("op"); op {
+	case "create":
+		name, err := extractName(r)
+		if err != nil {
+			sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+			return
+		}
+		cloneName := r.FormValue("cloneName")
+		if !volNameRegexp.MatchString(cloneName) {
+			sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: proto.ErrVolNameRegExpNotMatch.Error()})
+			return
+		}
+		ver := r.FormValue("ver")
+		if ver == "" {
+			sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: keyNotFound("ver").Error()})
+			return
+		}
+		src, err := m.cluster.getVol(name)
+		if err != nil {
+			sendErrReply(w, r, newErrHTTPReply(proto.ErrVolNotExists))
+			return
+		}
+		owner := src.Owner
+		if r.FormValue(volOwnerKey) != "" {
+			if owner, err = extractOwner(r); err != nil {
+				sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+				return
+			}
+		}
+
+		var task *proto.VolCloneTask
+		snapshot, err := m.cluster.findCloneSnapshot(src, ver)
+		if err == nil {
+			req := newVolCloneReq(src, cloneName, owner, snapshot.Ver)
+			if err = m.checkCreateVolReq(req); err == nil {
+				if task, err = m.cluster.cloneVol(req, snapshot); err == nil {
+					err = m.associateVolWithUser(owner, cloneName)
+				}
+			}
+		}
+		auditlog.LogMasterOp("AdminVolClone", fmt.Sprintf("op(create), vol(%v), cloneName(%v), ver(%v), owner(%v)",
+			name, cloneName, ver, owner), err)
+		if err != nil {
+			sendErrReply(w, r, newErrHTTPReply(err))
+			return
+		}
+		sendOkReply(w, r, newSuccessHTTPReply(task))
+	case "detach":
+		name, err := extractName(r)
+		if err != nil {
+			sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+			return
+		}
+		task, err := m.cluster.detachVolClone(name)
+		auditlog.LogMasterOp("AdminVolClone", fmt.Sprintf("op(detach), vol(%v)", name), err)
+		if err != nil {
+			sendErrReply(w, r, newErrHTTPReply(err))
+			return
+		}
+		sendOkReply(w, r, newSuccessHTTPReply(task))
+	case "status", "":
+		sendOkReply(w, r, newSuccessHTTPReply(m.cluster.volCloneMgr.taskStatus.GetResults(r.FormValue(nameKey))))
+	default:
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: "invalid op"})
+	}
+}
+
 func (m *Server) S3QosSet(w http.ResponseWriter, r *http.Request) {
 	var (
 		param = &proto.S3QosRequest{}
@@ -7506,6 +7804,79 @@ func (m *Server) S3QosDelete(w http.ResponseWriter, r *http.Request) {
 	sendOkReply(w, r, newSuccessHTTPReply("success"))
 }
 
+// S3PublicAccessBlockSet sets the public access block of the cluster, which the objectnodes load
+// and enforce on all the buckets.
+func (m *Server) S3PublicAccessBlockSet(w http.ResponseWriter, r *http.Request) {
+	var (
+		block *proto.S3PublicAccessBlock
+		err   error
+	)
+	metric := exporter.NewTPCnt(apiToMetricsName(proto.S3PublicAccessBlockSet))
+	defer func() {
+		doStatAndMetric(proto.S3PublicAccessBlockSet, metric, err, nil)
+	}()
+
+	if block, err = parseS3PublicAccessBlock(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	metadata := new(RaftCmd)
+	metadata.Op = opSyncS3PublicAccessBlockSet
+	metadata.K = S3PublicAccessBlockKey
+	if metadata.V, err = json.Marshal(block); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	// raft sync
+	if err = m.cluster.submit(metadata); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	// memory cache
+	m.cluster.setS3PublicAccessBlock(block)
+
+	log.LogInfof("[S3PublicAccessBlockSet] set %+v", block)
+	sendOkReply(w, r, newSuccessHTTPReply(block))
+}
+
+// S3PublicAccessBlockGet returns the public access block of the cluster, whose settings are all
+// false if it's not set.
+func (m *Server) S3PublicAccessBlockGet(w http.ResponseWriter, r *http.Request) {
+	var err error
+	metric := exporter.NewTPCnt(apiToMetricsName(proto.S3PublicAccessBlockGet))
+	defer func() {
+		doStatAndMetric(proto.S3PublicAccessBlockGet, metric, err, nil)
+	}()
+
+	block := &proto.S3PublicAccessBlock{}
+	if current := m.cluster.getS3PublicAccessBlock(); current != nil {
+		*block = *current
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(block))
+}
+
+func (m *Server) S3PublicAccessBlockDelete(w http.ResponseWriter, r *http.Request) {
+	var err error
+	metric := exporter.NewTPCnt(apiToMetricsName(proto.S3PublicAccessBlockDelete))
+	defer func() {
+		doStatAndMetric(proto.S3PublicAccessBlockDelete, metric, err, nil)
+	}()
+
+	metadata := new(RaftCmd)
+	metadata.Op = opSyncS3PublicAccessBlockDelete
+	metadata.K = S3PublicAccessBlockKey
+	// raft sync
+	if err = m.cluster.submit(metadata); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	// memory cache
+	m.cluster.setS3PublicAccessBlock(nil)
+
+	log.LogInfof("[S3PublicAccessBlockDelete] deleted")
+	sendOkReply(w, r, newSuccessHTTPReply("success"))
+}
+
 func parseS3QoSKey(key string) (api, uid, limitType, nodes string, err error) {
 	s3qosInfo := strings.TrimPrefix(key, S3QoSPrefix)
 	strs := strings.Split(s3qosInfo, keySeparator)
@@ -76,6 +76,9 @@ type ClusterTopoSubItem struct {
 	maxInodeNotEqualMP    *sync.Map
 	dentryCountNotEqualMP *sync.Map
 
+	badReplicaRepairs sync.Map // the repairs in flight of the extents reported corrupt on the replicas
+	raftLearners      sync.Map // the time the raft learners not promoted yet are first seen, see learnerKey
+
 	mnMutex sync.RWMutex // meta node mutex
 	dnMutex sync.RWMutex // data node mutex
 	nsMutex sync.RWMutex // nodeset mutex
@@ -133,9 +136,15 @@ type Cluster struct {
 	QosAcceptLimit *rate.Limiter
 	apiLimiter     *ApiLimiter
 
+	s3PublicAccessBlock     *proto.S3PublicAccessBlock // nil if not set
+	s3PublicAccessBlockLock sync.RWMutex
+
 	followerReadManager *followerReadManager
 	lcMgr               *lifecycleManager
 	snapshotMgr         *snapshotDelManager
+	fsckMgr             *fsckManager
+	volCloneMgr         *volCloneManager
+	trashMgr            *trashManager
 
 	ac           *authSDK.AuthClient
 	masterClient *masterSDK.MasterClient
@@ -425,6 +434,12 @@ func newCluster(name string, leaderInfo *LeaderInfo, fsm *MetadataFsm, partition
 	c.lcMgr.cluster = c
 	c.snapshotMgr = newSnapshotManager()
 	c.snapshotMgr.cluster = c
+	c.fsckMgr = newFsckManager()
+	c.fsckMgr.cluster = c
+	c.volCloneMgr = newVolCloneManager()
+	c.volCloneMgr.cluster = c
+	c.trashMgr = newTrashManager()
+	c.trashMgr.cluster = c
 	c.S3ApiQosQuota = new(sync.Map)
 	c.MarkDiskBrokenThreshold.Store(defaultMarkDiskBrokenThreshold)
 	c.EnableAutoDpMetaRepair.Store(defaultEnableDpMetaRepair)
@@ -446,6 +461,7 @@ func (c *Cluster) scheduleTask() {
 	c.scheduleToCheckVolQos()
 	c.scheduleToCheckDiskRecoveryProgress()
 	c.scheduleToCheckMetaPartitionRecoveryProgress()
+	c.scheduleToPromoteLearners()
 	c.scheduleToLoadMetaPartitions()
 	c.scheduleToCheckNodeSetGrpManagerStatus()
 	c.scheduleToCheckFollowerReadCache()
@@ -453,6 +469,9 @@ func (c *Cluster) scheduleTask() {
 	c.scheduleToCheckDecommissionDisk()
 	c.scheduleToLcScan()
 	c.scheduleToSnapshotDelVerScan()
+	c.scheduleToFsck()
+	c.scheduleToVolClone()
+	c.scheduleToTrash()
 	c.scheduleToBadDisk()
 	c.scheduleToCheckVolUid()
 	c.scheduleToCheckDataReplicaMeta()
@@ -741,6 +760,7 @@ func (c *Cluster) checkDataPartitions() {
 	vols := c.allVols()
 	for _, vol := range vols {
 		vol.checkDataPartitions(c)
+		c.updateCloneSharedPartitions(vol)
 		if c.metaReady {
 			vol.dataPartitions.updateResponseCache(true, 0, vol)
 			vol.dataPartitions.updateCompressCache(true, 0, vol)
@@ -1733,6 +1753,10 @@ func (c *Cluster) markDeleteVol(name, authKey string, force bool, isNotCancel bo
 		return
 	}
 
+	if clones := c.getVolClones(name, 0); len(clones) > 0 {
+		return fmt.Errorf("vol %s is referenced by the clones %v, deletion not permitted", name, clones)
+	}
+
 	if !c.cfg.volForceDeletion {
 		volDentryCount := uint64(0)
 		mpsCopy := vol.cloneMetaPartitionMap()
@@ -2602,6 +2626,16 @@ func (c *Cluster) decommissionSingleDp(dp *DataPartition, newAddr, offlineAddr s
 						goto ERR
 					}
 					continue
+				} else if dp.isRaftLearner(newAddr) { // wait for the promotion before removing the old replica
+					if c.learnerPromoteTimedOut(learnerKey(dataPartitionLearner, dp.PartitionID, newAddr)) {
+						err = fmt.Errorf("action[decommissionSingleDp] dp %v new replica %v is not promoted from raft learner in %v",
+							dp.PartitionID, newAddr, learnerPromoteTimeout)
+						dp.DecommissionNeedRollback = true
+						c.raftLearners.Delete(learnerKey(dataPartitionLearner, dp.PartitionID, newAddr))
+						log.LogWarnf("action[decommissionSingleDp] dp %v err:%v", dp.PartitionID, err)
+						goto ERR
+					}
+					continue
 				} else {
 					dp.SetSpecialReplicaDecommissionStep(SpecialDecommissionWaitAddResFin)
 					c.syncUpdateDataPartition(dp)
@@ -3029,7 +3063,9 @@ func (c *Cluster) addDataReplica(dp *DataPartition, addr string, ignoreDecommiss
 		return
 	}
 
-	addPeer := proto.Peer{ID: targetDataNode.ID, Addr: addr, HeartbeatPort: targetDataNode.HeartbeatPort, ReplicaPort: targetDataNode.ReplicaPort}
+	// the replica is added as a raft learner, and promoted to a voter by promoteLearners once it
+	// catches up with the leader
+	addPeer := proto.Peer{ID: targetDataNode.ID, Addr: addr, HeartbeatPort: targetDataNode.HeartbeatPort, ReplicaPort: targetDataNode.ReplicaPort, IsLearner: true}
 
 	if !proto.IsNormalDp(dp.PartitionType) {
 		return fmt.Errorf("action[addDataReplica] [%d] is not normal dp, not support add or delete replica", dp.PartitionID)
@@ -3513,17 +3549,17 @@ func (c *Cluster) migrateMetaNode(srcAddr, targetAddr string, limit int) (err er
 	defer metaNode.MigrateLock.Unlock()
 
 	partitions := c.getAllMetaPartitionByMetaNode(srcAddr)
-	if targetAddr != "" {
-		toBeOfflineMps = make([]*MetaPartition, 0)
-		for _, mp := range partitions {
-			if contains(mp.Hosts, targetAddr) {
-				continue
-			}
-
-			toBeOfflineMps = append(toBeOfflineMps, mp)
+	toBeOfflineMps = make([]*MetaPartition, 0)
+	for _, mp := range partitions {
+		if targetAddr != "" && contains(mp.Hosts, targetAddr) {
+			continue
 		}
-	} else {
-		toBeOfflineMps = partitions
+		// the replica is removed after the learner replacing it is promoted
+		if mp.MigrateSrcAddr == srcAddr {
+			continue
+		}
+
+		toBeOfflineMps = append(toBeOfflineMps, mp)
 	}
 
 	if len(toBeOfflineMps) <= 0 && len(partitions) != 0 {
@@ -3573,6 +3609,12 @@ func (c *Cluster) migrateMetaNode(srcAddr, targetAddr string, limit int) (err er
 		return
 	}
 
+	if left := len(c.getAllMetaPartitionByMetaNode(srcAddr)); left > 0 {
+		log.LogWarnf("action[migrateMetaNode] clusterID[%v] migrate from [%s] to [%s] success, [%d] replicas are waiting for "+
+			"the new replicas promoted to be removed", c.Name, srcAddr, targetAddr, left)
+		return
+	}
+
 	if err = c.syncDeleteMetaNode(metaNode); err != nil {
 		msg = fmt.Sprintf("action[migrateMetaNode], clusterID[%v] node[%v] synDelMetaNode failed,err[%s]",
 			c.Name, srcAddr, err.Error())
@@ -3942,6 +3984,7 @@ func (c *Cluster) doCreateVol(req *createVolReq) (vol *Vol, err error) {
 		ReplicaNum:              defaultReplicaNum,
 		FollowerRead:            req.followerRead,
 		MetaFollowerRead:        req.metaFollowerRead,
+		MetaFollowerReadIndex:   req.metaFollowerReadIndex,
 		Authenticate:            req.authenticate,
 		CrossZone:               req.crossZone,
 		DefaultPriority:         req.normalZonesFirst,
@@ -3951,6 +3994,7 @@ func (c *Cluster) doCreateVol(req *createVolReq) (vol *Vol, err error) {
 		Description:             req.description,
 		EnablePosixAcl:          req.enablePosixAcl,
 		EnableQuota:             req.enableQuota,
+		MetaStoreMode:           req.metaStoreMode,
 		EnableTransaction:       req.enableTransaction,
 		TxTimeout:               req.txTimeout,
 		TxConflictRetryNum:      req.txConflictRetryNum,
@@ -3982,6 +4026,12 @@ func (c *Cluster) doCreateVol(req *createVolReq) (vol *Vol, err error) {
 		VolStorageClass:     req.volStorageClass,
 		AllowedStorageClass: req.allowedStorageClass,
 		CacheDpStorageClass: req.cacheDpStorageClass,
+
+		CloneSource: req.cloneSource,
+		CloneVer:    req.cloneVer,
+	}
+	if req.cloneSource != "" {
+		vv.CloneStatus = proto.VolCloneCopying
 	}
 
 	vv.QuotaOfClass = make([]*proto.StatOfStorageClass, 0)
@@ -5408,6 +5458,18 @@ func (c *Cluster) addLcNode(nodeAddr string) (id uint64, err error) {
 	c.snapshotMgr.lcNodeStatus.Lock()
 	c.snapshotMgr.lcNodeStatus.WorkingCount[nodeAddr] = 0
 	c.snapshotMgr.lcNodeStatus.Unlock()
+
+	c.fsckMgr.lcNodeStatus.Lock()
+	c.fsckMgr.lcNodeStatus.WorkingCount[nodeAddr] = 0
+	c.fsckMgr.lcNodeStatus.Unlock()
+
+	c.volCloneMgr.lcNodeStatus.Lock()
+	c.volCloneMgr.lcNodeStatus.WorkingCount[nodeAddr] = 0
+	c.volCloneMgr.lcNodeStatus.Unlock()
+
+	c.trashMgr.lcNodeStatus.Lock()
+	c.trashMgr.lcNodeStatus.WorkingCount[nodeAddr] = 0
+	c.trashMgr.lcNodeStatus.Unlock()
 	log.LogInfof("action[addLcNode], clusterID[%v], lcNodeAddr: %v, id: %v, success", c.Name, nodeAddr, ln.ID)
 	return ln.ID, nil
 
@@ -5569,6 +5631,9 @@ func (c *Cluster) clearLcNodes() {
 func (c *Cluster) delLcNode(nodeAddr string) (err error) {
 	c.lcMgr.lcNodeStatus.RemoveNode(nodeAddr)
 	c.snapshotMgr.lcNodeStatus.RemoveNode(nodeAddr)
+	c.fsckMgr.lcNodeStatus.RemoveNode(nodeAddr)
+	c.volCloneMgr.lcNodeStatus.RemoveNode(nodeAddr)
+	c.trashMgr.lcNodeStatus.RemoveNode(nodeAddr)
 
 	lcNode, err := c.lcNode(nodeAddr)
 	if err != nil {
@@ -6264,3 +6329,15 @@ func (c *Cluster) checkMultipleReplicasOnSameMachine(hosts []string) (err error)
 	}
 	return nil
 }
+
+func (c *Cluster) getS3PublicAccessBlock() *proto.S3PublicAccessBlock {
+	c.s3PublicAccessBlockLock.RLock()
+	defer c.s3PublicAccessBlockLock.RUnlock()
+	return c.s3PublicAccessBlock
+}
+
+func (c *Cluster) setS3PublicAccessBlock(block *proto.S3PublicAccessBlock) {
+	c.s3PublicAccessBlockLock.Lock()
+	c.s3PublicAccessBlock = block
+	c.s3PublicAccessBlockLock.Unlock()
+}
@@ -95,6 +95,32 @@ func (c *Cluster) loadDataPartition(dp *DataPartition) {
 	}()
 }
 
+// repairBadDataReplica asks the replica reported to have the corrupt blocks of the extent to repair
+// them from the other replicas. The reports of the extent on the replica are ignored while one is
+// being repaired.
+func (c *Cluster) repairBadDataReplica(dp *DataPartition, addr string, extentID uint64, offset, size int64) {
+	key := fmt.Sprintf("%v_%v_%v", dp.PartitionID, addr, extentID)
+	if _, loaded := c.badReplicaRepairs.LoadOrStore(key, struct{}{}); loaded {
+		return
+	}
+	go func() {
+		defer c.badReplicaRepairs.Delete(key)
+		dataNode, err := c.dataNode(addr)
+		if err != nil {
+			log.LogWarnf("action[repairBadDataReplica] dp[%v] replica[%v] err[%v]", dp.PartitionID, addr, err)
+			return
+		}
+		task := dp.createTaskToRepairExtentBlocks(addr, extentID, offset, size)
+		if _, err = dataNode.TaskManager.syncSendAdminTask(task); err != nil {
+			Warn(c.Name, fmt.Sprintf("action[repairBadDataReplica] clusterID[%v] dp[%v] replica[%v] extent[%v] offset[%v] size[%v] failed, err[%v]",
+				c.Name, dp.PartitionID, addr, extentID, offset, size, err))
+			return
+		}
+		log.LogInfof("action[repairBadDataReplica] dp[%v] replica[%v] extent[%v] offset[%v] size[%v] repaired",
+			dp.PartitionID, addr, extentID, offset, size)
+	}()
+}
+
 func (c *Cluster) migrateMetaPartition(srcAddr, targetAddr string, mp *MetaPartition) (err error) {
 	var (
 		newPeers        []proto.Peer
@@ -117,6 +143,11 @@ func (c *Cluster) migrateMetaPartition(srcAddr, targetAddr string, mp *MetaParti
 			mp.volName, srcAddr, mp.PartitionID)
 		return fmt.Errorf("migrateMetaPartition src [%s] is not exist in mp(%d)", srcAddr, mp.PartitionID)
 	}
+	if mp.MigrateSrcAddr != "" {
+		mp.RUnlock()
+		return fmt.Errorf("migrateMetaPartition mp(%d) is migrating from [%s], wait for the new replica promoted",
+			mp.PartitionID, mp.MigrateSrcAddr)
+	}
 	oldHosts = mp.Hosts
 	mp.RUnlock()
 
@@ -179,20 +210,18 @@ func (c *Cluster) migrateMetaPartition(srcAddr, targetAddr string, mp *MetaParti
 		return err
 	}
 
-	if err = c.deleteMetaReplica(mp, srcAddr, false, false); err != nil {
-		goto errHandler
-	}
-
+	// the new replica joins as a learner, and the old one is removed by promoteLearners after the
+	// new one is promoted, so the quorum is kept all the time
 	if err = c.addMetaReplica(mp, newPeers[0].Addr); err != nil {
 		goto errHandler
 	}
 
+	mp.Lock()
 	mp.IsRecover = true
-	c.putBadMetaPartitions(srcAddr, mp.PartitionID)
-
-	mp.RLock()
+	mp.MigrateSrcAddr = srcAddr
 	c.syncUpdateMetaPartition(mp)
-	mp.RUnlock()
+	mp.Unlock()
+	c.putBadMetaPartitions(srcAddr, mp.PartitionID)
 
 	Warn(c.Name, fmt.Sprintf("action[migrateMetaPartition] clusterID[%v] vol[%v] meta partition[%v] "+
 		"migrate addr[%v] success,new addr[%v]", c.Name, mp.volName, mp.PartitionID, srcAddr, newPeers[0].Addr))
@@ -461,6 +490,8 @@ func (c *Cluster) updateMetaPartitionOfflinePeerIDWithLock(mp *MetaPartition, pe
 	return
 }
 
+// addMetaReplica adds the replica as a raft learner, and it's promoted to a voter by promoteLearners
+// once it catches up with the leader, so that the replica catching up doesn't count in the quorum.
 func (c *Cluster) addMetaReplica(partition *MetaPartition, addr string) (err error) {
 	defer func() {
 		if err != nil {
@@ -477,7 +508,7 @@ func (c *Cluster) addMetaReplica(partition *MetaPartition, addr string) (err err
 	if err != nil {
 		return
 	}
-	addPeer := proto.Peer{ID: metaNode.ID, Addr: addr, HeartbeatPort: metaNode.HeartbeatPort, ReplicaPort: metaNode.ReplicaPort}
+	addPeer := proto.Peer{ID: metaNode.ID, Addr: addr, HeartbeatPort: metaNode.HeartbeatPort, ReplicaPort: metaNode.ReplicaPort, IsLearner: true}
 	if err = c.addMetaPartitionRaftMember(partition, addPeer); err != nil {
 		return
 	}
@@ -28,6 +28,9 @@ const (
 	diskPathKey             = "disk"
 	nameKey                 = "name"
 	idKey                   = "id"
+	extentKey               = "extent"
+	offsetKey               = "offset"
+	sizeKey                 = "size"
 	countKey                = "count"
 	enableKey               = "enable"
 	thresholdKey            = "threshold"
@@ -116,11 +119,21 @@ const (
 	TimeOut                         = "timeout"
 	CountByMeta                     = "countByMeta"
 	dpReadOnlyWhenVolFull           = "dpReadOnlyWhenVolFull"
+	inlineDataThresholdKey          = "inlineDataThreshold"
+	compressionKey                  = "compression"
+	blockPublicAclsKey              = "blockPublicAcls"
+	ignorePublicAclsKey             = "ignorePublicAcls"
+	blockPublicPolicyKey            = "blockPublicPolicy"
+	restrictPublicBucketsKey        = "restrictPublicBuckets"
 	PeriodicKey                     = "periodic"
 	IPKey                           = "ip"
 	OperateKey                      = "op"
 	UIDKey                          = "uid"
 	CapacityKey                     = "capacity"
+	quotaOwnerTypeKey               = "quotaType"
+	softCapacityKey                 = "softCapacity"
+	softFilesKey                    = "softFiles"
+	gracePeriodKey                  = "grace"
 	configKey                       = "config"
 	MaxFilesKey                     = "maxFiles"
 	MaxBytesKey                     = "maxBytes"
@@ -212,6 +225,7 @@ const (
 	EmptyCrcValue                          uint32 = 4045511210
 	DefaultZoneName                               = proto.DefaultZoneName
 	retrySendSyncTaskInternal                     = 3 * time.Second
+	learnerPromoteTimeout                         = 10 * time.Minute
 	defaultRangeOfCountDifferencesAllowed         = 50
 	defaultMinusOfMaxInodeID                      = 1000
 	defaultNodeSetGrpBatchCnt                     = 3
@@ -318,6 +332,9 @@ const (
 
 	opSyncS3QosSet    uint32 = 0x60
 	opSyncS3QosDelete uint32 = 0x61
+
+	opSyncS3PublicAccessBlockSet    uint32 = 0x62
+	opSyncS3PublicAccessBlockDelete uint32 = 0x63
 )
 
 const (
@@ -372,6 +389,8 @@ const (
 	lcTaskPrefix     = keySeparator + lcTaskAcronym + keySeparator
 	lcResultPrefix   = keySeparator + lcResultAcronym + keySeparator
 	S3QoSPrefix      = keySeparator + S3QoS + keySeparator
+
+	S3PublicAccessBlockKey = keySeparator + "s3pab"
 )
 
 // selector enum
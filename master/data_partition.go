@@ -593,6 +593,28 @@ func (partition *DataPartition) checkReplicaNum(c *Cluster, vol *Vol) {
 	}
 }
 
+func (partition *DataPartition) getLearners() (learners []proto.Peer) {
+	partition.RLock()
+	defer partition.RUnlock()
+	for _, peer := range partition.Peers {
+		if peer.IsLearner {
+			learners = append(learners, peer)
+		}
+	}
+	return
+}
+
+func (partition *DataPartition) isRaftLearner(addr string) bool {
+	partition.RLock()
+	defer partition.RUnlock()
+	for _, peer := range partition.Peers {
+		if peer.Addr == addr {
+			return peer.IsLearner
+		}
+	}
+	return false
+}
+
 func (partition *DataPartition) hostsToString() (hosts string) {
 	return strings.Join(partition.Hosts, underlineSeparator)
 }
@@ -1492,11 +1514,21 @@ func (partition *DataPartition) Decommission(c *Cluster) bool {
 			goto errHandler
 		}
 	} else {
-		if err = c.removeDataReplica(partition, srcAddr, false, partition.DecommissionRaftForce); err != nil {
-			goto errHandler
-		}
-		if err = c.addDataReplica(partition, targetAddr, false); err != nil {
-			goto errHandler
+		if partition.DecommissionRaftForce {
+			if err = c.removeDataReplica(partition, srcAddr, false, true); err != nil {
+				goto errHandler
+			}
+			if err = c.addDataReplica(partition, targetAddr, false); err != nil {
+				goto errHandler
+			}
+		} else {
+			// the new replica joins as a learner, and the old one is removed by promoteLearners after
+			// the new one is promoted, so the quorum is kept all the time
+			if !partition.hasHost(targetAddr) {
+				if err = c.addDataReplica(partition, targetAddr, false); err != nil {
+					goto errHandler
+				}
+			}
 		}
 		newReplica, _ := partition.getReplica(targetAddr)
 		newReplica.Status = proto.Recovering // in case heartbeat response is not arrived
@@ -1823,6 +1855,12 @@ func (partition *DataPartition) createTaskToStopDataPartitionRepair(addr string,
 	return
 }
 
+func (partition *DataPartition) createTaskToRepairExtentBlocks(addr string, extentID uint64, offset, size int64) (task *proto.AdminTask) {
+	task = proto.NewAdminTask(proto.OpRepairExtentBlocks, addr, newRepairExtentBlocksRequest(partition.PartitionID, extentID, offset, size))
+	partition.resetTaskID(task)
+	return
+}
+
 func (partition *DataPartition) TryAcquireDecommissionToken(c *Cluster) bool {
 	var (
 		zone            *Zone
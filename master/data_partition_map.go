@@ -41,6 +41,7 @@ type DataPartitionMap struct {
 	readMutex               sync.RWMutex
 	partitionMapByMediaType map[uint32]map[uint64]struct{} // level-1 key: mediaType, level-2 key: dpId
 	rwCntByMediaType        map[uint32]int                 // readable and writable dp count by mediaType
+	sharedPartitions        []*proto.DataPartitionResponse // read only partitions of the clone source
 }
 
 func newDataPartitionMap(volName string) (dpMap *DataPartitionMap) {
@@ -213,6 +214,18 @@ func (dpMap *DataPartitionMap) getDataPartitionCompressCache() []byte {
 	return dpMap.responseCompressCache
 }
 
+func (dpMap *DataPartitionMap) getSharedPartitions() []*proto.DataPartitionResponse {
+	dpMap.RLock()
+	defer dpMap.RUnlock()
+	return dpMap.sharedPartitions
+}
+
+func (dpMap *DataPartitionMap) setSharedPartitions(partitions []*proto.DataPartitionResponse) {
+	dpMap.Lock()
+	defer dpMap.Unlock()
+	dpMap.sharedPartitions = partitions
+}
+
 func (dpMap *DataPartitionMap) setDataPartitionResponseCache(responseCache []byte) {
 	dpMap.Lock()
 	defer dpMap.Unlock()
@@ -242,6 +255,10 @@ func (dpMap *DataPartitionMap) updateResponseCache(needsUpdate bool, minPartitio
 			return
 		}
 		dpResps := dpMap.getDataPartitionsView(minPartitionID)
+		if minPartitionID == 0 {
+			// the clone reads the extents shared with the source in the partitions of the source
+			dpResps = append(dpResps, dpMap.getSharedPartitions()...)
+		}
 		log.LogDebugf("[updateResponseCache] vol(%v) needsUpdate(%v) minPartitionID(%v) volType(%v)  dpNum(%v)",
 			dpMap.volName, needsUpdate, minPartitionID, vol.VolType, len(dpResps))
 		if len(dpResps) == 0 && proto.IsHot(vol.VolType) {
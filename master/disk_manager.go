@@ -184,6 +184,15 @@ func (c *Cluster) checkDiskRecoveryProgress() {
 						partitionID, newReplica.Addr, newReplica.Status)
 					continue // change dp decommission status in decommission function
 				}
+				// the old replica is removed by promoteLearners after the new one is promoted
+				if partition.isRaftLearner(newReplica.Addr) ||
+					(!partition.DecommissionRaftForce && partition.DecommissionType != AutoAddReplica &&
+						partition.hasHost(partition.DecommissionSrcAddr)) {
+					log.LogInfof("[checkDiskRecoveryProgress] dp(%v) wait for new replica(%v) promoted and old replica(%v) removed",
+						partitionID, newReplica.Addr, partition.DecommissionSrcAddr)
+					newBadDpIds = append(newBadDpIds, partitionID)
+					continue
+				}
 				// do not add to BadDataPartitionIds
 				if newReplica.isUnavailable() {
 					partition.DecommissionNeedRollback = true
@@ -0,0 +1,327 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const (
+	fsckResultKeepTime   = time.Hour * 24
+	fsckHeartbeatTimeout = time.Minute * 10
+)
+
+// fsckManager dispatches the online fsck tasks started by the admin API to the idle lcnodes, at
+// most one task is running for each volume. The progress of the running tasks is updated by the
+// heartbeats of lcnodes.
+type fsckManager struct {
+	cluster        *Cluster
+	fsckTaskStatus *fsckTaskStatus
+	lcNodeStatus   *lcNodeStatus
+	idleNodeCh     chan struct{}
+	exitCh         chan struct{}
+}
+
+func newFsckManager() *fsckManager {
+	log.LogInfof("action[newFsckManager] construct")
+	return &fsckManager{
+		fsckTaskStatus: newFsckTaskStatus(),
+		lcNodeStatus:   newLcNodeStatus(),
+		idleNodeCh:     make(chan struct{}, 1000),
+		exitCh:         make(chan struct{}),
+	}
+}
+
+func (m *fsckManager) process() {
+	for {
+		select {
+		case <-m.exitCh:
+			log.LogInfo("exitCh notified, fsckManager process exit")
+			return
+		case <-m.idleNodeCh:
+			task := m.fsckTaskStatus.GetOneTask()
+			if task == nil {
+				continue
+			}
+
+			nodeAddr := m.lcNodeStatus.GetIdleNode("")
+			if nodeAddr == "" {
+				log.LogWarn("no idle lcnode, redo fsck task")
+				m.fsckTaskStatus.RedoTask(task)
+				continue
+			}
+
+			val, ok := m.cluster.lcNodes.Load(nodeAddr)
+			if !ok {
+				log.LogErrorf("lcNodes.Load, nodeAddr(%v) is not available, redo fsck task", nodeAddr)
+				m.lcNodeStatus.RemoveNode(nodeAddr)
+				m.fsckTaskStatus.RedoTask(task)
+				continue
+			}
+
+			node := val.(*LcNode)
+			adminTask := node.createFsckTask(m.cluster.masterAddr(), task)
+			m.cluster.addLcNodeTasks([]*proto.AdminTask{adminTask})
+			log.LogInfof("add fsck task(%v) to lcnode(%v)", *task, nodeAddr)
+		}
+	}
+}
+
+func (m *fsckManager) notifyIdleLcNode() {
+	m.fsckTaskStatus.RLock()
+	defer m.fsckTaskStatus.RUnlock()
+
+	if len(m.fsckTaskStatus.ToBeChecked) > 0 {
+		select {
+		case m.idleNodeCh <- struct{}{}:
+			log.LogDebug("action[handleLcNodeHeartbeatResp], fsckManager routine notified!")
+		default:
+		}
+	}
+}
+
+// startFsck queues the fsck task of the volume, the repairs are not allowed on the volume with
+// snapshots since the extents may be shared by the versions.
+func (m *fsckManager) startFsck(task *proto.FsckTask) (err error) {
+	vol, err := m.cluster.getVol(task.VolName)
+	if err != nil {
+		return
+	}
+	if !task.DryRun && vol.VersionMgr.enabled {
+		return fmt.Errorf("volume(%v) with snapshots can only be checked in dry run", task.VolName)
+	}
+	if proto.IsCold(vol.VolType) {
+		task.CheckExtent = false
+	}
+	if task.SafeWindow <= 0 {
+		task.SafeWindow = proto.DefaultFsckSafeWindow
+	}
+	task.Id = fmt.Sprintf("%s:%d", task.VolName, time.Now().Unix())
+
+	if err = m.fsckTaskStatus.AddTask(task); err != nil {
+		return
+	}
+	select {
+	case m.idleNodeCh <- struct{}{}:
+	default:
+	}
+	log.LogInfof("action[startFsck] fsck task(%v) added", *task)
+	return
+}
+
+// stopFsck drops the queued task of the volume, or notifies the lcnode to stop the running one.
+func (m *fsckManager) stopFsck(volName string) (err error) {
+	st := m.fsckTaskStatus
+	st.Lock()
+	for id, task := range st.ToBeChecked {
+		if task.VolName == volName {
+			delete(st.ToBeChecked, id)
+			t := time.Now()
+			st.Results[id] = &proto.FsckTaskResponse{
+				ID:             id,
+				EndTime:        &t,
+				UpdateTime:     &t,
+				Done:           true,
+				Status:         proto.TaskFailed,
+				Result:         "stopped before running",
+				FsckTask:       task,
+				FsckStatistics: proto.FsckStatistics{VolName: volName},
+			}
+			st.Unlock()
+			log.LogInfof("action[stopFsck] queued fsck task(%v) dropped", id)
+			return
+		}
+	}
+	running := st.getRunning(volName)
+	var id, node string
+	if running != nil {
+		id, node = running.ID, running.LcNode
+	}
+	st.Unlock()
+
+	if running == nil {
+		return fmt.Errorf("no fsck task of volume(%v) to stop", volName)
+	}
+	if node == "" {
+		return fmt.Errorf("fsck task(%v) is not running in lcnode yet, please retry", id)
+	}
+	client := &http.Client{Timeout: time.Second * 5}
+	resp, err := client.Get(getLcStopUrl(node, id))
+	if err != nil {
+		return
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("stop fsck task(%v) in lcnode(%v) failed, status(%v), please retry", id, node, resp.StatusCode)
+	}
+	log.LogInfof("action[stopFsck] fsck task(%v) in lcnode(%v) notified to stop", id, node)
+	return
+}
+
+//----------------------------------------------
+
+type fsckTaskStatus struct {
+	sync.RWMutex
+	ToBeChecked map[string]*proto.FsckTask
+	Results     map[string]*proto.FsckTaskResponse
+}
+
+func newFsckTaskStatus() *fsckTaskStatus {
+	return &fsckTaskStatus{
+		ToBeChecked: make(map[string]*proto.FsckTask),
+		Results:     make(map[string]*proto.FsckTaskResponse),
+	}
+}
+
+// getRunning returns the result of the task of the volume not done yet, with the lock held.
+func (st *fsckTaskStatus) getRunning(volName string) *proto.FsckTaskResponse {
+	for _, r := range st.Results {
+		if !r.Done && r.FsckTask != nil && r.FsckTask.VolName == volName {
+			return r
+		}
+	}
+	return nil
+}
+
+func (st *fsckTaskStatus) AddTask(task *proto.FsckTask) error {
+	st.Lock()
+	defer st.Unlock()
+	for _, t := range st.ToBeChecked {
+		if t.VolName == task.VolName {
+			return fmt.Errorf("fsck task(%v) of volume(%v) is waiting", t.Id, task.VolName)
+		}
+	}
+	if r := st.getRunning(task.VolName); r != nil {
+		return fmt.Errorf("fsck task(%v) of volume(%v) is running", r.ID, task.VolName)
+	}
+	st.ToBeChecked[task.Id] = task
+	return nil
+}
+
+func (st *fsckTaskStatus) GetOneTask() (task *proto.FsckTask) {
+	st.Lock()
+	defer st.Unlock()
+	for _, t := range st.ToBeChecked {
+		task = t
+		break
+	}
+	if task == nil {
+		return
+	}
+
+	delete(st.ToBeChecked, task.Id)
+	t := time.Now()
+	st.Results[task.Id] = &proto.FsckTaskResponse{
+		ID:             task.Id,
+		UpdateTime:     &t,
+		FsckTask:       task,
+		FsckStatistics: proto.FsckStatistics{VolName: task.VolName},
+	}
+	return
+}
+
+func (st *fsckTaskStatus) RedoTask(task *proto.FsckTask) {
+	st.Lock()
+	defer st.Unlock()
+	delete(st.Results, task.Id)
+	st.ToBeChecked[task.Id] = task
+}
+
+// UpdateResult updates the result of the task unless it is already done.
+func (st *fsckTaskStatus) UpdateResult(resp *proto.FsckTaskResponse) {
+	st.Lock()
+	defer st.Unlock()
+	if r, ok := st.Results[resp.ID]; ok && r.Done {
+		return
+	}
+	t := time.Now()
+	resp.UpdateTime = &t
+	st.Results[resp.ID] = resp
+}
+
+// GetResults returns the results of the volume, or all the volumes if volName is empty, the
+// latest first.
+func (st *fsckTaskStatus) GetResults(volName string) (results []*proto.FsckTaskResponse) {
+	st.RLock()
+	defer st.RUnlock()
+	results = make([]*proto.FsckTaskResponse, 0)
+	for _, task := range st.ToBeChecked {
+		if volName == "" || task.VolName == volName {
+			results = append(results, &proto.FsckTaskResponse{
+				ID:             task.Id,
+				FsckTask:       task,
+				Result:         "waiting",
+				FsckStatistics: proto.FsckStatistics{VolName: task.VolName},
+			})
+		}
+	}
+	for _, r := range st.Results {
+		if volName == "" || r.VolName == volName {
+			results = append(results, r)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].ID > results[j].ID })
+	return
+}
+
+func (st *fsckTaskStatus) DeleteOldResult() {
+	st.Lock()
+	defer st.Unlock()
+	now := time.Now()
+	for k, v := range st.Results {
+		if v.Done && v.EndTime != nil && now.After(v.EndTime.Add(fsckResultKeepTime)) {
+			delete(st.Results, k)
+			continue
+		}
+		// the lcnode may be lost, the task is not redone since the repairs may be half done
+		if !v.Done && v.UpdateTime != nil && now.After(v.UpdateTime.Add(fsckHeartbeatTimeout)) {
+			v.Done = true
+			v.EndTime = &now
+			v.Status = proto.TaskFailed
+			v.Result = fmt.Sprintf("no progress from lcnode(%v) since %v", v.LcNode, v.UpdateTime)
+			log.LogWarnf("fsck task(%v) is not updated, mark failed", k)
+		}
+	}
+}
+
+func (c *Cluster) scheduleToFsck() {
+	go c.fsckMgr.process()
+	go func() {
+		for {
+			if c.partition != nil && c.partition.IsRaftLeader() {
+				c.fsckMgr.fsckTaskStatus.DeleteOldResult()
+			}
+			time.Sleep(time.Second * defaultIntervalToCheck)
+		}
+	}()
+}
+
+func (c *Cluster) handleLcNodeFsckResp(nodeAddr string, resp *proto.FsckTaskResponse) (err error) {
+	if !resp.Done {
+		log.LogInfof("action[handleLcNodeFsckResp] fsck received, lcNode[%v] task[%v]", nodeAddr, resp.ID)
+		return
+	}
+	c.fsckMgr.fsckTaskStatus.UpdateResult(resp)
+	log.LogInfof("action[handleLcNodeFsckResp] fsck done, lcNode[%v] task[%v] status[%v] result[%v] stat[%+v]",
+		nodeAddr, resp.ID, resp.Status, resp.Result, resp.FsckStatistics)
+	return
+}
@@ -481,6 +481,15 @@ func (m *Server) registerAPIRoutes(router *mux.Router) {
 	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
 		Path(proto.AdminLcNode).
 		HandlerFunc(m.adminLcNode)
+	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
+		Path(proto.AdminFsck).
+		HandlerFunc(m.adminFsck)
+	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
+		Path(proto.AdminVolTrash).
+		HandlerFunc(m.adminVolTrash)
+	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
+		Path(proto.AdminVolClone).
+		HandlerFunc(m.adminVolClone)
 
 	// node task response APIs
 	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
@@ -574,6 +583,9 @@ func (m *Server) registerAPIRoutes(router *mux.Router) {
 	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
 		Path(proto.AdminDiagnoseDataPartition).
 		HandlerFunc(m.diagnoseDataPartition)
+	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
+		Path(proto.AdminReportBadDataReplica).
+		HandlerFunc(m.reportBadDataReplica)
 	router.NewRoute().Methods(http.MethodGet).
 		Path(proto.ClientDataPartitions).
 		HandlerFunc(m.getDataPartitions)
@@ -850,6 +862,17 @@ func (m *Server) registerAPIRoutes(router *mux.Router) {
 	router.NewRoute().Methods(http.MethodDelete, http.MethodPost).
 		Path(proto.S3QoSDelete).
 		HandlerFunc(m.S3QosDelete)
+
+	// S3 public access block of the cluster
+	router.NewRoute().Methods(http.MethodPut, http.MethodPost).
+		Path(proto.S3PublicAccessBlockSet).
+		HandlerFunc(m.S3PublicAccessBlockSet)
+	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
+		Path(proto.S3PublicAccessBlockGet).
+		HandlerFunc(m.S3PublicAccessBlockGet)
+	router.NewRoute().Methods(http.MethodDelete, http.MethodPost).
+		Path(proto.S3PublicAccessBlockDelete).
+		HandlerFunc(m.S3PublicAccessBlockDelete)
 }
 
 func (m *Server) registerHandler(router *mux.Router, model string, schema *graphql.Schema) {
@@ -0,0 +1,245 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// The replicas are added to the partitions as raft learners, and promoteLearners promotes them to
+// voters in the background once the raft leader accepts, i.e. the learners catch up with the log.
+// A learner not promoted in learnerPromoteTimeout is rolled back. The replicas replaced by the
+// learners are removed only after the learners are promoted, so the quorum is kept all the time.
+
+const (
+	dataPartitionLearner = "dp"
+	metaPartitionLearner = "mp"
+)
+
+func learnerKey(partitionType string, partitionID uint64, addr string) string {
+	return fmt.Sprintf("%v_%v_%v", partitionType, partitionID, addr)
+}
+
+// learnerPromoteTimedOut returns true if the learner is not promoted in learnerPromoteTimeout since
+// it's first seen by the leader master.
+func (c *Cluster) learnerPromoteTimedOut(key string) bool {
+	since, _ := c.raftLearners.LoadOrStore(key, time.Now())
+	return time.Since(since.(time.Time)) > learnerPromoteTimeout
+}
+
+func (c *Cluster) scheduleToPromoteLearners() {
+	c.runTask(&cTask{
+		tickTime: time.Second * defaultIntervalToCheckDataPartition,
+		name:     "scheduleToPromoteLearners",
+		function: func() (fin bool) {
+			if c.partition != nil && c.partition.IsRaftLeader() {
+				c.promoteLearners()
+			}
+			return
+		},
+	})
+}
+
+func (c *Cluster) promoteLearners() {
+	defer func() {
+		if r := recover(); r != nil {
+			log.LogWarnf("promoteLearners occurred panic,err[%v]", r)
+			WarnBySpecialKey(fmt.Sprintf("%v_%v_scheduling_job_panic", c.Name, ModuleName),
+				"promoteLearners occurred panic")
+		}
+	}()
+
+	vols := c.allVols()
+	for _, vol := range vols {
+		for _, dp := range vol.dataPartitions.clonePartitions() {
+			c.promoteDataPartitionLearners(dp)
+		}
+		for _, mp := range vol.cloneMetaPartitionMap() {
+			c.promoteMetaPartitionLearners(mp)
+		}
+	}
+}
+
+func (c *Cluster) promoteDataPartitionLearners(dp *DataPartition) {
+	for _, learner := range dp.getLearners() {
+		key := learnerKey(dataPartitionLearner, dp.PartitionID, learner.Addr)
+		err := c.promoteDataPartitionLearner(dp, learner)
+		if err == nil {
+			c.raftLearners.Delete(key)
+			log.LogInfof("action[promoteDataPartitionLearners] dp %v raft learner %v promoted", dp.PartitionID, learner.Addr)
+			continue
+		}
+		log.LogInfof("action[promoteDataPartitionLearners] dp %v promote raft learner %v err %v", dp.PartitionID, learner.Addr, err)
+		if !c.learnerPromoteTimedOut(key) {
+			continue
+		}
+		if dp.IsDecommissionRunning() && dp.DecommissionDstAddr == learner.Addr {
+			// the special replica decommission rolls back itself when it finds the timeout
+			if dp.isSpecialReplicaCnt() && !dp.DecommissionRaftForce {
+				continue
+			}
+			dp.DecommissionNeedRollback = true
+			dp.SetDecommissionStatus(DecommissionFail)
+			dp.DecommissionErrorMessage = fmt.Sprintf("Decommission target node %v is not promoted from raft learner in %v",
+				learner.Addr, learnerPromoteTimeout)
+			Warn(c.Name, fmt.Sprintf("action[promoteDataPartitionLearners] clusterID[%v] dp[%v] %v",
+				c.Name, dp.PartitionID, dp.DecommissionErrorMessage))
+			dp.RLock()
+			c.syncUpdateDataPartition(dp)
+			dp.RUnlock()
+			c.raftLearners.Delete(key)
+			continue
+		}
+		Warn(c.Name, fmt.Sprintf("action[promoteDataPartitionLearners] clusterID[%v] dp[%v] raft learner %v is not promoted in %v, remove it",
+			c.Name, dp.PartitionID, learner.Addr, learnerPromoteTimeout))
+		if err = c.removeDataReplica(dp, learner.Addr, false, false); err != nil {
+			log.LogWarnf("action[promoteDataPartitionLearners] dp %v remove raft learner %v err %v", dp.PartitionID, learner.Addr, err)
+			continue
+		}
+		c.raftLearners.Delete(key)
+	}
+	c.removeDecommissionSrcReplica(dp)
+}
+
+// promoteDataPartitionLearner asks the raft leader to promote the learner of the data partition to a
+// voter, the leader refuses it until the learner catches up with the log.
+func (c *Cluster) promoteDataPartitionLearner(dp *DataPartition, learner proto.Peer) (err error) {
+	promotePeer := learner
+	promotePeer.IsLearner = false
+	dp.RLock()
+	leaderAddr := dp.getLeaderAddr()
+	if leaderAddr == "" && len(dp.Hosts) > 0 {
+		// the follower forwards the request to the leader
+		leaderAddr = dp.Hosts[0]
+	}
+	dp.RUnlock()
+	if _, err = c.buildAddDataPartitionRaftMemberTaskAndSyncSendTask(dp, promotePeer, leaderAddr); err != nil {
+		return
+	}
+
+	dp.Lock()
+	defer dp.Unlock()
+	peers := make([]proto.Peer, 0, len(dp.Peers))
+	for _, peer := range dp.Peers {
+		if peer.ID == learner.ID && peer.Addr == learner.Addr {
+			peer.IsLearner = false
+		}
+		peers = append(peers, peer)
+	}
+	return dp.update("promoteDataPartitionLearner", dp.VolName, peers, dp.Hosts, c)
+}
+
+// removeDecommissionSrcReplica removes the old replica of the running decommission once the new one
+// is promoted. The raft force decommission removes the old replica first, and the special replica
+// decommission removes it itself.
+func (c *Cluster) removeDecommissionSrcReplica(dp *DataPartition) {
+	if !dp.IsDecommissionRunning() || dp.DecommissionRaftForce || dp.isSpecialReplicaCnt() ||
+		dp.DecommissionType == AutoAddReplica {
+		return
+	}
+	srcAddr, dstAddr := dp.DecommissionSrcAddr, dp.DecommissionDstAddr
+	dp.RLock()
+	waiting := srcAddr != "" && dp.hasHost(srcAddr) && dp.hasHost(dstAddr)
+	dp.RUnlock()
+	if !waiting || dp.isRaftLearner(dstAddr) {
+		return
+	}
+	if err := c.removeDataReplica(dp, srcAddr, false, false); err != nil {
+		// retried in the next round
+		log.LogWarnf("action[removeDecommissionSrcReplica] dp %v remove replica %v err %v", dp.PartitionID, srcAddr, err)
+		return
+	}
+	log.LogInfof("action[removeDecommissionSrcReplica] dp %v replica %v removed after %v promoted", dp.PartitionID, srcAddr, dstAddr)
+}
+
+func (c *Cluster) promoteMetaPartitionLearners(mp *MetaPartition) {
+	for _, learner := range mp.getLearners() {
+		key := learnerKey(metaPartitionLearner, mp.PartitionID, learner.Addr)
+		err := c.promoteMetaPartitionLearner(mp, learner)
+		if err == nil {
+			c.raftLearners.Delete(key)
+			log.LogInfof("action[promoteMetaPartitionLearners] mp %v raft learner %v promoted", mp.PartitionID, learner.Addr)
+			continue
+		}
+		log.LogInfof("action[promoteMetaPartitionLearners] mp %v promote raft learner %v err %v", mp.PartitionID, learner.Addr, err)
+		if !c.learnerPromoteTimedOut(key) {
+			continue
+		}
+		// roll back the add, and give up the migration the learner is added for
+		Warn(c.Name, fmt.Sprintf("action[promoteMetaPartitionLearners] clusterID[%v] mp[%v] raft learner %v is not promoted in %v, remove it",
+			c.Name, mp.PartitionID, learner.Addr, learnerPromoteTimeout))
+		if err = c.deleteMetaReplica(mp, learner.Addr, false, false); err != nil {
+			log.LogWarnf("action[promoteMetaPartitionLearners] mp %v remove raft learner %v err %v", mp.PartitionID, learner.Addr, err)
+			continue
+		}
+		c.raftLearners.Delete(key)
+		mp.Lock()
+		mp.MigrateSrcAddr = ""
+		c.syncUpdateMetaPartition(mp)
+		mp.Unlock()
+	}
+	c.removeMetaMigrateSrcReplica(mp)
+}
+
+// promoteMetaPartitionLearner asks the raft leader to promote the learner of the meta partition to a
+// voter, the leader refuses it until the learner catches up with the log.
+func (c *Cluster) promoteMetaPartitionLearner(mp *MetaPartition, learner proto.Peer) (err error) {
+	promotePeer := learner
+	promotePeer.IsLearner = false
+	mp.RLock()
+	err = c.addMetaPartitionRaftMember(mp, promotePeer)
+	mp.RUnlock()
+	if err != nil {
+		return
+	}
+
+	mp.Lock()
+	defer mp.Unlock()
+	newPeers := make([]proto.Peer, 0, len(mp.Peers))
+	for _, peer := range mp.Peers {
+		if peer.ID == learner.ID && peer.Addr == learner.Addr {
+			peer.IsLearner = false
+		}
+		newPeers = append(newPeers, peer)
+	}
+	return mp.persistToRocksDB("promoteMetaPartitionLearner", mp.volName, mp.Hosts, newPeers, c)
+}
+
+// removeMetaMigrateSrcReplica removes the replica migrated from once the learners are promoted.
+func (c *Cluster) removeMetaMigrateSrcReplica(mp *MetaPartition) {
+	mp.RLock()
+	srcAddr := mp.MigrateSrcAddr
+	exist := contains(mp.Hosts, srcAddr)
+	mp.RUnlock()
+	if srcAddr == "" || len(mp.getLearners()) > 0 {
+		return
+	}
+	if exist {
+		if err := c.deleteMetaReplica(mp, srcAddr, false, false); err != nil {
+			// retried in the next round
+			log.LogWarnf("action[removeMetaMigrateSrcReplica] mp %v remove replica %v err %v", mp.PartitionID, srcAddr, err)
+			return
+		}
+	}
+	mp.Lock()
+	mp.MigrateSrcAddr = ""
+	c.syncUpdateMetaPartition(mp)
+	mp.Unlock()
+	log.LogInfof("action[removeMetaMigrateSrcReplica] mp %v replica %v removed", mp.PartitionID, srcAddr)
+}
@@ -88,3 +88,33 @@ func (lcNode *LcNode) createSnapshotVerDelTask(masterAddr string, sTask *proto.S
 	task = proto.NewAdminTaskEx(proto.OpLcNodeSnapshotVerDel, lcNode.Addr, request, request.Task.Id)
 	return
 }
+
+func (lcNode *LcNode) createFsckTask(masterAddr string, fTask *proto.FsckTask) (task *proto.AdminTask) {
+	request := &proto.FsckTaskRequest{
+		MasterAddr: masterAddr,
+		LcNodeAddr: lcNode.Addr,
+		Task:       fTask,
+	}
+	task = proto.NewAdminTaskEx(proto.OpLcNodeFsck, lcNode.Addr, request, request.Task.Id)
+	return
+}
+
+func (lcNode *LcNode) createVolCloneTask(masterAddr string, cTask *proto.VolCloneTask) (task *proto.AdminTask) {
+	request := &proto.VolCloneTaskRequest{
+		MasterAddr: masterAddr,
+		LcNodeAddr: lcNode.Addr,
+		Task:       cTask,
+	}
+	task = proto.NewAdminTaskEx(proto.OpLcNodeVolClone, lcNode.Addr, request, request.Task.Id)
+	return
+}
+
+func (lcNode *LcNode) createTrashTask(masterAddr string, tTask *proto.TrashTask) (task *proto.AdminTask) {
+	request := &proto.TrashTaskRequest{
+		MasterAddr: masterAddr,
+		LcNodeAddr: lcNode.Addr,
+		Task:       tTask,
+	}
+	task = proto.NewAdminTaskEx(proto.OpLcNodeTrash, lcNode.Addr, request, request.Task.Id)
+	return
+}
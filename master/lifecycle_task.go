@@ -52,6 +52,15 @@ func (c *Cluster) handleLcNodeTaskResponse(nodeAddr string, task *proto.AdminTas
 	case proto.OpLcNodeSnapshotVerDel:
 		response := task.Response.(*proto.SnapshotVerDelTaskResponse)
 		err = c.handleLcNodeSnapshotScanResp(task.OperatorAddr, response)
+	case proto.OpLcNodeFsck:
+		response := task.Response.(*proto.FsckTaskResponse)
+		err = c.handleLcNodeFsckResp(task.OperatorAddr, response)
+	case proto.OpLcNodeVolClone:
+		response := task.Response.(*proto.VolCloneTaskResponse)
+		err = c.handleLcNodeVolCloneResp(task.OperatorAddr, response)
+	case proto.OpLcNodeTrash:
+		response := task.Response.(*proto.TrashTaskResponse)
+		err = c.handleLcNodeTrashResp(task.OperatorAddr, response)
 	default:
 		err = fmt.Errorf(fmt.Sprintf("lc unknown operate code %v", task.OpCode))
 		goto errHandler
@@ -89,6 +98,9 @@ func (c *Cluster) handleLcNodeHeartbeatResp(nodeAddr string, resp *proto.LcNodeH
 	log.LogInfof("action[handleLcNodeHeartbeatResp], lcNode[%v], LcScanningTasks[%v], SnapshotScanningTasks[%v]", nodeAddr, len(resp.LcScanningTasks), len(resp.SnapshotScanningTasks))
 	c.lcMgr.lcNodeStatus.UpdateNode(nodeAddr, len(resp.LcScanningTasks))
 	c.snapshotMgr.lcNodeStatus.UpdateNode(nodeAddr, len(resp.SnapshotScanningTasks))
+	c.fsckMgr.lcNodeStatus.UpdateNode(nodeAddr, len(resp.FsckScanningTasks))
+	c.volCloneMgr.lcNodeStatus.UpdateNode(nodeAddr, len(resp.CloneScanningTasks))
+	c.trashMgr.lcNodeStatus.UpdateNode(nodeAddr, len(resp.TrashScanningTasks))
 
 	// handle LcScanningTasks
 	for _, taskRsp := range resp.LcScanningTasks {
@@ -135,6 +147,33 @@ func (c *Cluster) handleLcNodeHeartbeatResp(nodeAddr string, resp *proto.LcNodeH
 		}
 	}
 
+	// handle FsckScanningTasks
+	for _, taskRsp := range resp.FsckScanningTasks {
+		c.fsckMgr.fsckTaskStatus.UpdateResult(taskRsp)
+		log.LogDebugf("action[handleLcNodeHeartbeatResp], lcNode[%v] fsck taskRsp: %v", nodeAddr, taskRsp.ID)
+	}
+	for i := len(resp.FsckScanningTasks); i < resp.LcTaskCountLimit; i++ {
+		c.fsckMgr.notifyIdleLcNode()
+	}
+
+	// handle CloneScanningTasks
+	for _, taskRsp := range resp.CloneScanningTasks {
+		c.updateVolCloneResult(taskRsp)
+		log.LogDebugf("action[handleLcNodeHeartbeatResp], lcNode[%v] clone taskRsp: %v", nodeAddr, taskRsp.ID)
+	}
+	for i := len(resp.CloneScanningTasks); i < resp.LcTaskCountLimit; i++ {
+		c.volCloneMgr.notifyIdleLcNode()
+	}
+
+	// handle TrashScanningTasks
+	for _, taskRsp := range resp.TrashScanningTasks {
+		c.trashMgr.trashTaskStatus.UpdateResult(taskRsp)
+		log.LogDebugf("action[handleLcNodeHeartbeatResp], lcNode[%v] trash taskRsp: %v", nodeAddr, taskRsp.ID)
+	}
+	for i := len(resp.TrashScanningTasks); i < resp.LcTaskCountLimit; i++ {
+		c.trashMgr.notifyIdleLcNode()
+	}
+
 	log.LogInfof("action[handleLcNodeHeartbeatResp], lcNode[%v], heartbeat success", nodeAddr)
 	return
 }
@@ -11,10 +11,16 @@ import (
 	"github.com/cubefs/cubefs/util/log"
 )
 
+// uidSpaceKey is the user or group of UidSpaceInfo.
+type uidSpaceKey struct {
+	typ proto.QuotaOwnerType
+	id  uint32
+}
+
 type UidSpaceManager struct {
 	volName        string
 	mpSpaceMetrics map[uint64][]*proto.UidReportSpaceInfo
-	uidInfo        map[uint32]*proto.UidSpaceInfo
+	uidInfo        map[uidSpaceKey]*proto.UidSpaceInfo
 	c              *Cluster
 	vol            *Vol
 	msgChan        chan *proto.MetaPartitionReport
@@ -27,10 +33,12 @@ type UidSpaceFsm struct {
 }
 
 type UidCmd struct {
-	op   uint64
-	uid  uint32
-	size uint64
-	wg   sync.WaitGroup
+	op    uint64
+	typ   proto.QuotaOwnerType
+	uid   uint32
+	size  uint64
+	limit *proto.UidSpaceInfo // the limits to set for util.UidSetLimit
+	wg    sync.WaitGroup
 }
 
 func (vol *Vol) initUidSpaceManager(c *Cluster) {
@@ -39,7 +47,7 @@ func (vol *Vol) initUidSpaceManager(c *Cluster) {
 		vol:            vol,
 		volName:        vol.Name,
 		mpSpaceMetrics: make(map[uint64][]*proto.UidReportSpaceInfo),
-		uidInfo:        make(map[uint32]*proto.UidSpaceInfo),
+		uidInfo:        make(map[uidSpaceKey]*proto.UidSpaceInfo),
 		msgChan:        make(chan *proto.MetaPartitionReport, 10000),
 		cmdChan:        make(chan *UidCmd, 1000),
 	}
@@ -50,7 +58,7 @@ func (uMgr *UidSpaceManager) pushUidCmd(cmd *UidCmd) bool {
 	select {
 	case uMgr.cmdChan <- cmd:
 	default:
-		log.LogWarnf("vol %v volUidUpdate, uid %v op %v be missed", uMgr.volName, cmd.uid, cmd.op)
+		log.LogWarnf("vol %v volUidUpdate, %v %v op %v be missed", uMgr.volName, cmd.typ, cmd.uid, cmd.op)
 		return false
 	}
 	log.LogDebugf("pushUidCmd. vol %v cmd (%v) wait result", uMgr.volName, cmd)
@@ -61,49 +69,85 @@ func (uMgr *UidSpaceManager) pushUidCmd(cmd *UidCmd) bool {
 
 func (uMgr *UidSpaceManager) addUid(cmd *UidCmd) {
 	defer cmd.wg.Done()
-	if uidInfo, ok := uMgr.uidInfo[cmd.uid]; ok {
+	key := uidSpaceKey{typ: cmd.typ, id: cmd.uid}
+	if uidInfo, ok := uMgr.uidInfo[key]; ok {
 		if uidInfo.Enabled {
-			log.LogWarnf("UidSpaceManager.addUid vol %v add %v already exist", uMgr.volName, cmd.uid)
+			log.LogWarnf("UidSpaceManager.addUid vol %v add %v %v already exist", uMgr.volName, cmd.typ, cmd.uid)
 			return
 		}
 	}
-	uMgr.uidInfo[cmd.uid] = &proto.UidSpaceInfo{
+	uMgr.uidInfo[key] = &proto.UidSpaceInfo{
 		LimitSize: cmd.size,
 		VolName:   uMgr.volName,
 		Uid:       cmd.uid,
+		Type:      cmd.typ,
 		Enabled:   true,
 	}
 	uMgr.persist()
-	log.LogWarnf("UidSpaceManager.vol %v addUid %v success", uMgr.volName, cmd.uid)
+	log.LogWarnf("UidSpaceManager.vol %v addUid %v %v success", uMgr.volName, cmd.typ, cmd.uid)
+}
+
+// setUid sets the hard and soft limits of the user or group, the usage is kept.
+func (uMgr *UidSpaceManager) setUid(cmd *UidCmd) {
+	defer cmd.wg.Done()
+	key := uidSpaceKey{typ: cmd.typ, id: cmd.uid}
+	info := &proto.UidSpaceInfo{
+		VolName: uMgr.volName,
+		Uid:     cmd.uid,
+		Type:    cmd.typ,
+		CTime:   time.Now().Unix(),
+	}
+	if old, ok := uMgr.uidInfo[key]; ok {
+		infoCopy := *old
+		info = &infoCopy
+	}
+	info.Enabled = true
+	info.LimitSize = cmd.limit.LimitSize
+	info.SoftSize = cmd.limit.SoftSize
+	info.LimitFiles = cmd.limit.LimitFiles
+	info.SoftFiles = cmd.limit.SoftFiles
+	info.GracePeriod = cmd.limit.GracePeriod
+	info.UpdateLimited(time.Now().Unix())
+	uMgr.uidInfo[key] = info
+	uMgr.persist()
+	log.LogWarnf("UidSpaceManager.vol %v setUid %v %v limits(%v) success", uMgr.volName, cmd.typ, cmd.uid, info)
 }
 
 func (uMgr *UidSpaceManager) removeUid(cmd *UidCmd) bool {
 	defer cmd.wg.Done()
-	if _, ok := uMgr.uidInfo[cmd.uid]; !ok {
-		log.LogWarnf("UidSpaceManager.vol %v uid del uid %v not exist", uMgr.volName, cmd.uid)
+	key := uidSpaceKey{typ: cmd.typ, id: cmd.uid}
+	info, ok := uMgr.uidInfo[key]
+	if !ok {
+		log.LogWarnf("UidSpaceManager.vol %v uid del %v %v not exist", uMgr.volName, cmd.typ, cmd.uid)
 		return true
 	}
-	uMgr.uidInfo[cmd.uid].Enabled = false
-	uMgr.uidInfo[cmd.uid].Limited = false
+	infoCopy := *info
+	infoCopy.Enabled = false
+	infoCopy.UpdateLimited(time.Now().Unix())
+	uMgr.uidInfo[key] = &infoCopy
 	uMgr.persist()
-	log.LogWarnf("UidSpaceManager.vol %v del %v success", uMgr.volName, cmd.uid)
+	log.LogWarnf("UidSpaceManager.vol %v del %v %v success", uMgr.volName, cmd.typ, cmd.uid)
 	return true
 }
 
-func (uMgr *UidSpaceManager) checkUid(uid uint32) (ok bool, uidInfo *proto.UidSpaceInfo) {
+func (uMgr *UidSpaceManager) checkUid(typ proto.QuotaOwnerType, uid uint32) (ok bool, uidInfo *proto.UidSpaceInfo) {
 	uMgr.rwMutex.RLock()
 	defer uMgr.rwMutex.RUnlock()
-	uidInfo, ok = uMgr.uidInfo[uid]
+	uidInfo, ok = uMgr.uidInfo[uidSpaceKey{typ: typ, id: uid}]
 	return
 }
 
-func (uMgr *UidSpaceManager) listAll() (rsp []*proto.UidSpaceInfo) {
+// listAll returns the users or groups which have the limits or use the space of the volume.
+func (uMgr *UidSpaceManager) listAll(typ proto.QuotaOwnerType) (rsp []*proto.UidSpaceInfo) {
 	uMgr.rwMutex.RLock()
 	defer uMgr.rwMutex.RUnlock()
 
 	log.LogDebugf("UidSpaceManager. listAll vol %v, info %v", uMgr.volName, len(uMgr.uidInfo))
 	for _, t := range uMgr.uidInfo {
-		log.LogDebugf("UidSpaceManager. listAll vol %v, uid %v, info %v", t.VolName, t.Uid, t)
+		if t.Type != typ {
+			continue
+		}
+		log.LogDebugf("UidSpaceManager. listAll vol %v, %v %v, info %v", t.VolName, t.Type, t.Uid, t)
 		rsp = append(rsp, t)
 	}
 	return
@@ -137,8 +181,8 @@ func (uMgr *UidSpaceManager) load(c *Cluster, val []byte) (err error) {
 		return
 	}
 	for _, info := range uidFsm.UidSpaceArr {
-		uMgr.uidInfo[info.Uid] = info
-		log.LogDebugf("vol %v uid %v load usedSize %v limit %v enabled %v", uMgr.volName, info.Uid, info.UsedSize, info.LimitSize, info.Limited)
+		uMgr.uidInfo[uidSpaceKey{typ: info.Type, id: info.Uid}] = info
+		log.LogDebugf("vol %v %v %v load usedSize %v limit %v enabled %v", uMgr.volName, info.Type, info.Uid, info.UsedSize, info.LimitSize, info.Limited)
 	}
 	return
 }
@@ -148,7 +192,7 @@ func (uMgr *UidSpaceManager) getSpaceOp() (rsp []*proto.UidSpaceInfo) {
 	defer uMgr.rwMutex.RUnlock()
 	for _, info := range uMgr.uidInfo {
 		rsp = append(rsp, info)
-		log.LogDebugf("getSpaceOp. vol %v uid %v enabled %v", info.VolName, info.Uid, info.Limited)
+		log.LogDebugf("getSpaceOp. vol %v %v %v enabled %v", info.VolName, info.Type, info.Uid, info.Limited)
 	}
 	return
 }
@@ -162,10 +206,15 @@ func (uMgr *UidSpaceManager) scheduleUidUpdate() {
 		case cmd := <-uMgr.cmdChan:
 			uMgr.rwMutex.Lock()
 			log.LogDebugf("vol %v scheduleUidUpdate.cmd(%v)", uMgr.volName, cmd)
-			if cmd.op == util.UidAddLimit {
+			switch cmd.op {
+			case util.UidAddLimit:
 				uMgr.addUid(cmd)
-			} else if cmd.op == util.UidDelLimit {
+			case util.UidSetLimit:
+				uMgr.setUid(cmd)
+			case util.UidDelLimit:
 				uMgr.removeUid(cmd)
+			default:
+				cmd.wg.Done()
 			}
 			uMgr.rwMutex.Unlock()
 			log.LogDebugf("vol %v scheduleUidUpdate.cmd(%v) left", uMgr.volName, cmd)
@@ -187,59 +236,52 @@ func (uMgr *UidSpaceManager) pushUidMsg(report *proto.MetaPartitionReport) {
 	}
 }
 
+// reCalculate sums up the usage reported by the meta partitions, and updates the limited states
+// of the users and groups. The infos are replaced instead of modified, as they may be in use by
+// the readers.
 func (uMgr *UidSpaceManager) reCalculate() {
 	uMgr.rwMutex.Lock()
 	defer uMgr.rwMutex.Unlock()
 
-	for _, info := range uMgr.uidInfo {
-		info.UsedSize = 0
+	now := time.Now().Unix()
+	uidInfo := make(map[uidSpaceKey]*proto.UidSpaceInfo, len(uMgr.uidInfo))
+	for key, info := range uMgr.uidInfo {
+		infoCopy := *info
+		infoCopy.UsedSize, infoCopy.UsedFiles = 0, 0
+		uidInfo[key] = &infoCopy
 	}
-
-	uidInfo := make(map[uint32]*proto.UidSpaceInfo)
-	for mpId, info := range uMgr.mpSpaceMetrics {
+	for _, info := range uMgr.mpSpaceMetrics {
 		for _, space := range info {
-			if _, ok := uMgr.uidInfo[space.Uid]; !ok {
-				log.LogDebugf("vol %v volUidUpdate.uid %v not found", uMgr.volName, space.Uid)
-				uMgr.uidInfo[space.Uid] = &proto.UidSpaceInfo{
+			key := uidSpaceKey{typ: space.Type, id: space.Uid}
+			if _, ok := uidInfo[key]; !ok {
+				log.LogDebugf("vol %v volUidUpdate.%v %v not found", uMgr.volName, space.Type, space.Uid)
+				uidInfo[key] = &proto.UidSpaceInfo{
 					VolName: uMgr.volName,
 					Uid:     space.Uid,
-					CTime:   time.Now().Unix(),
+					Type:    space.Type,
+					CTime:   now,
 				}
 			}
-			if _, ok := uidInfo[space.Uid]; !ok {
-				infoCopy := *uMgr.uidInfo[space.Uid]
-				uidInfo[space.Uid] = &infoCopy
-			}
-
-			// log.LogDebugf("volUidUpdate.vol %v uid %v from mpId %v useSize %v add %v", uMgr.vol, space.Uid, mpId, uidInfo[space.Uid].UsedSize, space.Size)
-			uidInfo[space.Uid].UsedSize += space.Size
-			if !uidInfo[space.Uid].Enabled {
-				uidInfo[space.Uid].Limited = false
-				continue
-			}
-			if uidInfo[space.Uid].UsedSize > uMgr.uidInfo[space.Uid].LimitSize {
-				uidInfo[space.Uid].Limited = true
-				log.LogWarnf("volUidUpdate.vol %v uid %v from mpId %v useSize %v add %v", uMgr.vol, space.Uid, mpId, uidInfo[space.Uid].UsedSize, space.Size)
-			} else {
-				uidInfo[space.Uid].Limited = false
-				log.LogWarnf("volUidUpdate.vol %v uid %v from mpId %v useSize %v add %v", uMgr.vol, space.Uid, mpId, uidInfo[space.Uid].UsedSize, space.Size)
-			}
+			uidInfo[key].UsedSize += space.Size
+			uidInfo[key].UsedFiles += space.Files
 		}
 	}
 
+	graceChanged := false
 	for _, info := range uidInfo {
-		if _, ok := uMgr.uidInfo[info.Uid]; !ok {
-			log.LogErrorf("volUidUpdate.uid %v not found", info.Uid)
-			continue
+		if info.UpdateLimited(now) {
+			graceChanged = true
 		}
-		uMgr.uidInfo[info.Uid] = info
-	}
-	for _, info := range uMgr.uidInfo {
-		if info.UsedSize == 0 {
-			info.Limited = false
+		if info.Limited || info.LimitedFiles {
+			log.LogWarnf("volUidUpdate.vol %v %v %v useSize %v files %v limited %v limitedFiles %v",
+				uMgr.volName, info.Type, info.Uid, info.UsedSize, info.UsedFiles, info.Limited, info.LimitedFiles)
 		}
 	}
-	// log.LogDebugf("volUidUpdate. uid count %v", len(uMgr.uidInfo))
+	uMgr.uidInfo = uidInfo
+	// persist the grace timers, which are not recoverable from the usage
+	if graceChanged {
+		uMgr.persist()
+	}
 }
 
 func (uMgr *UidSpaceManager) volUidUpdate(report *proto.MetaPartitionReport) {
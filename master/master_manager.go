@@ -261,6 +261,12 @@ func (m *Server) loadMetadata() {
 	}
 	log.LogInfo("action[loadS3QoSInfo] end")
 
+	log.LogInfo("action[loadS3PublicAccessBlock] begin")
+	if err = m.cluster.loadS3PublicAccessBlock(); err != nil {
+		panic(err)
+	}
+	log.LogInfo("action[loadS3PublicAccessBlock] end")
+
 	m.cluster.checkMediaVaild()
 
 	log.LogInfo("action[loadMetadata] end")
@@ -32,6 +32,7 @@ type MetaReplica struct {
 	start                     uint64 // lower bound of the inode id
 	end                       uint64 // upper bound of the inode id
 	dataSize                  uint64
+	logicalSize               uint64
 	nodeID                    uint64
 	MaxInodeID                uint64
 	InodeCount                uint64
@@ -46,6 +47,7 @@ type MetaReplica struct {
 	ForbidWriteOpOfProtoVer0  bool
 	StatByStorageClass        []*proto.StatOfStorageClass
 	StatByMigrateStorageClass []*proto.StatOfStorageClass
+	RootDirStat               *proto.DirStat
 	metaNode                  *MetaNode
 }
 
@@ -61,6 +63,7 @@ type MetaPartition struct {
 	TxCnt                     uint64
 	TxRbInoCnt                uint64
 	TxRbDenCnt                uint64
+	RootDirStat               *proto.DirStat // the recursive stats of the root directory if it's kept by the partition
 	Replicas                  []*MetaReplica
 	LeaderReportTime          int64
 	ReplicaNum                uint8
@@ -71,12 +74,14 @@ type MetaPartition struct {
 	Hosts                     []string
 	Peers                     []proto.Peer
 	OfflinePeerID             uint64
+	MigrateSrcAddr            string // the replica removed after the learner replacing it is promoted
 	MissNodes                 map[string]int64
 	LoadResponse              []*proto.MetaPartitionLoadResponse
 	offlineMutex              sync.RWMutex
 	uidInfo                   []*proto.UidReportSpaceInfo
 	EqualCheckPass            bool
 	VerSeq                    uint64
+	StoreMode                 proto.StoreMode
 	heartBeatDone             bool
 	ForbidWriteOpOfProtoVer0  bool
 	StatByStorageClass        []*proto.StatOfStorageClass
@@ -117,6 +122,17 @@ func (mp *MetaPartition) setHosts(hosts []string) {
 	mp.Hosts = hosts
 }
 
+func (mp *MetaPartition) getLearners() (learners []proto.Peer) {
+	mp.RLock()
+	defer mp.RUnlock()
+	for _, peer := range mp.Peers {
+		if peer.IsLearner {
+			learners = append(learners, peer)
+		}
+	}
+	return
+}
+
 func (mp *MetaPartition) hostsToString() (hosts string) {
 	return strings.Join(mp.Hosts, underlineSeparator)
 }
@@ -215,6 +231,17 @@ func (mp *MetaPartition) dataSize() uint64 {
 	return maxSize
 }
 
+func (mp *MetaPartition) logicalSize() uint64 {
+	maxSize := uint64(0)
+	for _, mr := range mp.Replicas {
+		if maxSize < mr.logicalSize {
+			maxSize = mr.logicalSize
+		}
+	}
+
+	return maxSize
+}
+
 func (mp *MetaPartition) checkEnd(c *Cluster, maxPartitionID uint64) {
 	if mp.PartitionID < maxPartitionID {
 		return
@@ -427,6 +454,7 @@ func (mp *MetaPartition) updateMetaPartition(mgr *proto.MetaPartitionReport, met
 	mp.setDentryCount()
 	mp.setFreeListLen()
 	mp.SetTxCnt()
+	mp.setRootDirStat()
 	mp.removeMissingReplica(metaNode.Addr)
 	mp.setUidInfo(mgr)
 	mp.setStatByStorageClass()
@@ -639,6 +667,7 @@ func (mp *MetaPartition) buildNewMetaPartitionTasks(specifyAddrs []string, peers
 		Members:     peers,
 		VolName:     volName,
 		VerSeq:      mp.VerSeq,
+		StoreMode:   mp.StoreMode,
 	}
 	if specifyAddrs == nil {
 		hosts = mp.Hosts
@@ -700,6 +729,7 @@ func (mp *MetaPartition) createTaskToCreateReplica(host string) (t *proto.AdminT
 		Members:     mp.Peers,
 		VolName:     mp.volName,
 		VerSeq:      mp.VerSeq,
+		StoreMode:   mp.StoreMode,
 	}
 	t = proto.NewAdminTask(proto.OpCreateMetaPartition, host, req)
 	resetMetaPartitionTaskID(t, mp.PartitionID)
@@ -781,7 +811,9 @@ func (mr *MetaReplica) updateMetric(mgr *proto.MetaPartitionReport) {
 	mr.TxRbDenCnt = mgr.TxRbDenCnt
 	mr.FreeListLen = mgr.FreeListLen
 	mr.dataSize = mgr.Size
+	mr.logicalSize = mgr.LogicalSize
 	mr.ForbidWriteOpOfProtoVer0 = mgr.ForbidWriteOpOfProtoVer0
+	mr.RootDirStat = mgr.RootDirStat
 
 	if mgr.StatByStorageClass != nil {
 		mr.StatByStorageClass = mgr.StatByStorageClass
@@ -949,6 +981,17 @@ func (mp *MetaPartition) SetTxCnt() {
 	mp.TxCnt, mp.TxRbInoCnt, mp.TxRbDenCnt = txCnt, rbInoCnt, rbDenCnt
 }
 
+// setRootDirStat takes the stats of the root directory reported by the leader, which are the
+// latest ones applied.
+func (mp *MetaPartition) setRootDirStat() {
+	for _, r := range mp.Replicas {
+		if r.IsLeader && r.RootDirStat != nil {
+			mp.RootDirStat = r.RootDirStat
+			return
+		}
+	}
+}
+
 func (mp *MetaPartition) setStatByStorageClass() {
 	var mpNormalStat *proto.StatOfStorageClass
 	var mpMigrateStat *proto.StatOfStorageClass
@@ -118,32 +118,34 @@ func newClusterValue(c *Cluster) (cv *clusterValue) {
 }
 
 type metaPartitionValue struct {
-	PartitionID   uint64
-	Start         uint64
-	End           uint64
-	VolID         uint64
-	ReplicaNum    uint8
-	Status        int8
-	VolName       string
-	Hosts         string
-	OfflinePeerID uint64
-	Peers         []proto.Peer
-	IsRecover     bool
+	PartitionID    uint64
+	Start          uint64
+	End            uint64
+	VolID          uint64
+	ReplicaNum     uint8
+	Status         int8
+	VolName        string
+	Hosts          string
+	OfflinePeerID  uint64
+	Peers          []proto.Peer
+	IsRecover      bool
+	MigrateSrcAddr string
 }
 
 func newMetaPartitionValue(mp *MetaPartition) (mpv *metaPartitionValue) {
 	mpv = &metaPartitionValue{
-		PartitionID:   mp.PartitionID,
-		Start:         mp.Start,
-		End:           mp.End,
-		VolID:         mp.volID,
-		ReplicaNum:    mp.ReplicaNum,
-		Status:        mp.Status,
-		VolName:       mp.volName,
-		Hosts:         mp.hostsToString(),
-		Peers:         mp.Peers,
-		OfflinePeerID: mp.OfflinePeerID,
-		IsRecover:     mp.IsRecover,
+		PartitionID:    mp.PartitionID,
+		Start:          mp.Start,
+		End:            mp.End,
+		VolID:          mp.volID,
+		ReplicaNum:     mp.ReplicaNum,
+		Status:         mp.Status,
+		VolName:        mp.volName,
+		Hosts:          mp.hostsToString(),
+		Peers:          mp.Peers,
+		OfflinePeerID:  mp.OfflinePeerID,
+		IsRecover:      mp.IsRecover,
+		MigrateSrcAddr: mp.MigrateSrcAddr,
 	}
 	return
 }
@@ -287,6 +289,7 @@ type volValue struct {
 	Owner                 string
 	FollowerRead          bool
 	MetaFollowerRead      bool
+	MetaFollowerReadIndex bool
 	DirectRead            bool
 	Authenticate          bool
 	DpReadOnlyWhenVolFull bool
@@ -322,6 +325,7 @@ type volValue struct {
 
 	EnablePosixAcl bool
 	EnableQuota    bool
+	MetaStoreMode  proto.StoreMode
 
 	EnableTransaction       proto.TxOpMask
 	TxTimeout               int64
@@ -348,6 +352,13 @@ type volValue struct {
 	CacheDpStorageClass      uint32
 	ForbidWriteOpOfProtoVer0 bool
 	QuotaOfClass             []*proto.StatOfStorageClass
+
+	CloneSource string
+	CloneVer    uint64
+	CloneStatus uint8
+
+	InlineDataThreshold uint32
+	Compression         string
 }
 
 func (v *volValue) Bytes() (raw []byte, err error) {
@@ -372,6 +383,7 @@ func newVolValue(vol *Vol) (vv *volValue) {
 		Owner:                   vol.Owner,
 		FollowerRead:            vol.FollowerRead,
 		MetaFollowerRead:        vol.MetaFollowerRead,
+		MetaFollowerReadIndex:   vol.MetaFollowerReadIndex,
 		DirectRead:              vol.DirectRead,
 		LeaderRetryTimeOut:      vol.LeaderRetryTimeout,
 		Authenticate:            vol.authenticate,
@@ -388,6 +400,7 @@ func newVolValue(vol *Vol) (vv *volValue) {
 		DefaultPriority:         vol.defaultPriority,
 		EnablePosixAcl:          vol.enablePosixAcl,
 		EnableQuota:             vol.enableQuota,
+		MetaStoreMode:           vol.metaStoreMode,
 		EnableTransaction:       vol.enableTransaction,
 		TxTimeout:               vol.txTimeout,
 		TxConflictRetryNum:      vol.txConflictRetryNum,
@@ -431,6 +444,13 @@ func newVolValue(vol *Vol) (vv *volValue) {
 		VolStorageClass:          vol.volStorageClass,
 		CacheDpStorageClass:      vol.cacheDpStorageClass,
 		ForbidWriteOpOfProtoVer0: vol.ForbidWriteOpOfProtoVer0.Load(),
+
+		CloneSource: vol.CloneSource,
+		CloneVer:    vol.CloneVer,
+		CloneStatus: vol.CloneStatus,
+
+		InlineDataThreshold: vol.InlineDataThreshold,
+		Compression:         vol.Compression,
 	}
 	vv.AllowedStorageClass = make([]uint32, len(vol.allowedStorageClass))
 	copy(vv.AllowedStorageClass, vol.allowedStorageClass)
@@ -1739,10 +1759,12 @@ func (c *Cluster) loadMetaPartitions() (err error) {
 			}
 		}
 		mp := newMetaPartition(mpv.PartitionID, mpv.Start, mpv.End, vol.mpReplicaNum, vol.Name, mpv.VolID, 0)
+		mp.StoreMode = vol.metaStoreMode
 		mp.setHosts(strings.Split(mpv.Hosts, underlineSeparator))
 		mp.setPeers(mpv.Peers)
 		mp.OfflinePeerID = mpv.OfflinePeerID
 		mp.IsRecover = mpv.IsRecover
+		mp.MigrateSrcAddr = mpv.MigrateSrcAddr
 		vol.addMetaPartition(mp)
 		c.addBadMetaParitionIdMap(mp)
 		log.LogInfof("action[loadMetaPartitions],vol[%v],mp[%v]", vol.Name, mp.PartitionID)
@@ -1835,6 +1857,24 @@ func (c *Cluster) loadS3ApiQosInfo() (err error) {
 	return
 }
 
+// load the s3 public access block of the cluster to memory cache
+func (c *Cluster) loadS3PublicAccessBlock() (err error) {
+	result, err := c.fsm.store.SeekForPrefix([]byte(S3PublicAccessBlockKey))
+	if err != nil {
+		return fmt.Errorf("loadS3PublicAccessBlock get failed, err [%v]", err)
+	}
+	var block *proto.S3PublicAccessBlock
+	if value, ok := result[S3PublicAccessBlockKey]; ok {
+		block = &proto.S3PublicAccessBlock{}
+		if err = json.Unmarshal(value, block); err != nil {
+			return fmt.Errorf("loadS3PublicAccessBlock unmarshal failed, err [%v]", err)
+		}
+	}
+	log.LogInfof("loadS3PublicAccessBlock: %+v", block)
+	c.setS3PublicAccessBlock(block)
+	return
+}
+
 func (c *Cluster) checkMediaVaild() {
 	log.LogWarnf("checkMediaVaild: start check checkMediaVaild")
 	defer func() {
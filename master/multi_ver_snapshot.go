@@ -594,6 +594,14 @@ func (verMgr *VolVersionManager) createVer2PhaseTask(cluster *Cluster, verSeq ui
 		}
 	}()
 
+	if op == proto.DeleteVersion {
+		// the extents are held for the clones once the keys are copied
+		if clones := cluster.getCopyingVolClones(verMgr.vol.Name, verSeq); len(clones) > 0 {
+			err = fmt.Errorf("version %v is being copied by the clones %v", verSeq, clones)
+			return
+		}
+	}
+
 	if verRsp, err, op = verMgr.initVer2PhaseTask(verSeq, op); err != nil {
 		return
 	}
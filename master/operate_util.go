@@ -89,6 +89,16 @@ func newStopDataPartitionRepairRequest(ID uint64, stop bool) (req *proto.StopDat
 	return
 }
 
+func newRepairExtentBlocksRequest(ID, extentID uint64, offset, size int64) (req *proto.RepairExtentBlocksRequest) {
+	req = &proto.RepairExtentBlocksRequest{
+		PartitionId: ID,
+		ExtentId:    extentID,
+		Offset:      offset,
+		Size:        size,
+	}
+	return
+}
+
 func newRecoverDataReplicaMetaRequest(ID uint64, peers []proto.Peer, hosts []string) (req *proto.RecoverDataReplicaMetaRequest) {
 	req = &proto.RecoverDataReplicaMetaRequest{
 		PartitionId: ID,
@@ -129,6 +139,12 @@ func unmarshalTaskResponse(task *proto.AdminTask) (err error) {
 		response = &proto.LcNodeRuleTaskResponse{}
 	case proto.OpLcNodeSnapshotVerDel:
 		response = &proto.SnapshotVerDelTaskResponse{}
+	case proto.OpLcNodeFsck:
+		response = &proto.FsckTaskResponse{}
+	case proto.OpLcNodeVolClone:
+		response = &proto.VolCloneTaskResponse{}
+	case proto.OpLcNodeTrash:
+		response = &proto.TrashTaskResponse{}
 
 	default:
 		log.LogError(fmt.Sprintf("unknown operate code(%v)", task.OpCode))
@@ -0,0 +1,284 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const (
+	// the results carry the entries of the trash, they are kept shorter than the ones of fsck
+	trashResultKeepTime   = time.Hour
+	trashHeartbeatTimeout = time.Minute * 10
+)
+
+// trashManager dispatches the trash tasks started by the admin API to the idle lcnodes, which
+// list, restore or purge the entries in the trash of the volume. At most one task is running for
+// each volume, so that the restores and purges never race on the same entries.
+type trashManager struct {
+	cluster         *Cluster
+	trashTaskStatus *trashTaskStatus
+	lcNodeStatus    *lcNodeStatus
+	idleNodeCh      chan struct{}
+	exitCh          chan struct{}
+}
+
+func newTrashManager() *trashManager {
+	log.LogInfof("action[newTrashManager] construct")
+	return &trashManager{
+		trashTaskStatus: newTrashTaskStatus(),
+		lcNodeStatus:    newLcNodeStatus(),
+		idleNodeCh:      make(chan struct{}, 1000),
+		exitCh:          make(chan struct{}),
+	}
+}
+
+func (m *trashManager) process() {
+	for {
+		select {
+		case <-m.exitCh:
+			log.LogInfo("exitCh notified, trashManager process exit")
+			return
+		case <-m.idleNodeCh:
+			task := m.trashTaskStatus.GetOneTask()
+			if task == nil {
+				continue
+			}
+
+			nodeAddr := m.lcNodeStatus.GetIdleNode("")
+			if nodeAddr == "" {
+				log.LogWarn("no idle lcnode, redo trash task")
+				m.trashTaskStatus.RedoTask(task)
+				continue
+			}
+
+			val, ok := m.cluster.lcNodes.Load(nodeAddr)
+			if !ok {
+				log.LogErrorf("lcNodes.Load, nodeAddr(%v) is not available, redo trash task", nodeAddr)
+				m.lcNodeStatus.RemoveNode(nodeAddr)
+				m.trashTaskStatus.RedoTask(task)
+				continue
+			}
+
+			node := val.(*LcNode)
+			adminTask := node.createTrashTask(m.cluster.masterAddr(), task)
+			m.cluster.addLcNodeTasks([]*proto.AdminTask{adminTask})
+			log.LogInfof("add trash task(%v) to lcnode(%v)", *task, nodeAddr)
+		}
+	}
+}
+
+func (m *trashManager) notifyIdleLcNode() {
+	m.trashTaskStatus.RLock()
+	defer m.trashTaskStatus.RUnlock()
+
+	if len(m.trashTaskStatus.ToBeDone) > 0 {
+		select {
+		case m.idleNodeCh <- struct{}{}:
+			log.LogDebug("action[handleLcNodeHeartbeatResp], trashManager routine notified!")
+		default:
+		}
+	}
+}
+
+// startTrashTask queues the trash task of the volume.
+func (m *trashManager) startTrashTask(task *proto.TrashTask) (err error) {
+	if _, err = m.cluster.getVol(task.VolName); err != nil {
+		return
+	}
+	switch task.Op {
+	case proto.TrashOpList, proto.TrashOpPurge:
+	case proto.TrashOpRestore:
+		switch task.Conflict {
+		case "":
+			task.Conflict = proto.TrashConflictSkip
+		case proto.TrashConflictSkip, proto.TrashConflictRename, proto.TrashConflictOverwrite:
+		default:
+			return fmt.Errorf("invalid conflict way %v", task.Conflict)
+		}
+	default:
+		return fmt.Errorf("invalid trash op %v", task.Op)
+	}
+	if task.Subdir == "" {
+		task.Subdir = "/"
+	}
+	if task.Filter == nil {
+		task.Filter = &proto.TrashFilter{Uid: -1}
+	}
+	task.Id = fmt.Sprintf("%s:%d", task.VolName, time.Now().UnixNano())
+
+	if err = m.trashTaskStatus.AddTask(task); err != nil {
+		return
+	}
+	select {
+	case m.idleNodeCh <- struct{}{}:
+	default:
+	}
+	log.LogInfof("action[startTrashTask] trash task(%v) added", *task)
+	return
+}
+
+//----------------------------------------------
+
+type trashTaskStatus struct {
+	sync.RWMutex
+	ToBeDone map[string]*proto.TrashTask
+	Results  map[string]*proto.TrashTaskResponse
+}
+
+func newTrashTaskStatus() *trashTaskStatus {
+	return &trashTaskStatus{
+		ToBeDone: make(map[string]*proto.TrashTask),
+		Results:  make(map[string]*proto.TrashTaskResponse),
+	}
+}
+
+// getRunning returns the result of the task of the volume not done yet, with the lock held.
+func (st *trashTaskStatus) getRunning(volName string) *proto.TrashTaskResponse {
+	for _, r := range st.Results {
+		if !r.Done && r.TrashTask != nil && r.TrashTask.VolName == volName {
+			return r
+		}
+	}
+	return nil
+}
+
+func (st *trashTaskStatus) AddTask(task *proto.TrashTask) error {
+	st.Lock()
+	defer st.Unlock()
+	for _, t := range st.ToBeDone {
+		if t.VolName == task.VolName {
+			return fmt.Errorf("trash task(%v) of volume(%v) is waiting", t.Id, task.VolName)
+		}
+	}
+	if r := st.getRunning(task.VolName); r != nil {
+		return fmt.Errorf("trash task(%v) of volume(%v) is running", r.ID, task.VolName)
+	}
+	st.ToBeDone[task.Id] = task
+	return nil
+}
+
+func (st *trashTaskStatus) GetOneTask() (task *proto.TrashTask) {
+	st.Lock()
+	defer st.Unlock()
+	for _, t := range st.ToBeDone {
+		task = t
+		break
+	}
+	if task == nil {
+		return
+	}
+
+	delete(st.ToBeDone, task.Id)
+	t := time.Now()
+	st.Results[task.Id] = &proto.TrashTaskResponse{
+		ID:         task.Id,
+		UpdateTime: &t,
+		TrashTask:  task,
+	}
+	return
+}
+
+func (st *trashTaskStatus) RedoTask(task *proto.TrashTask) {
+	st.Lock()
+	defer st.Unlock()
+	delete(st.Results, task.Id)
+	st.ToBeDone[task.Id] = task
+}
+
+// UpdateResult updates the result of the task unless it is already done.
+func (st *trashTaskStatus) UpdateResult(resp *proto.TrashTaskResponse) {
+	st.Lock()
+	defer st.Unlock()
+	if r, ok := st.Results[resp.ID]; ok && r.Done {
+		return
+	}
+	t := time.Now()
+	resp.UpdateTime = &t
+	st.Results[resp.ID] = resp
+}
+
+// GetResults returns the results of the volume, or all the volumes if volName is empty, the
+// latest first.
+func (st *trashTaskStatus) GetResults(volName string) (results []*proto.TrashTaskResponse) {
+	st.RLock()
+	defer st.RUnlock()
+	results = make([]*proto.TrashTaskResponse, 0)
+	for _, task := range st.ToBeDone {
+		if volName == "" || task.VolName == volName {
+			results = append(results, &proto.TrashTaskResponse{
+				ID:        task.Id,
+				TrashTask: task,
+				Result:    "waiting",
+			})
+		}
+	}
+	for _, r := range st.Results {
+		if volName == "" || (r.TrashTask != nil && r.TrashTask.VolName == volName) {
+			results = append(results, r)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].ID > results[j].ID })
+	return
+}
+
+func (st *trashTaskStatus) DeleteOldResult() {
+	st.Lock()
+	defer st.Unlock()
+	now := time.Now()
+	for k, v := range st.Results {
+		if v.Done && v.EndTime != nil && now.After(v.EndTime.Add(trashResultKeepTime)) {
+			delete(st.Results, k)
+			continue
+		}
+		// the lcnode may be lost, the task is not redone since the restores may be half done
+		if !v.Done && v.UpdateTime != nil && now.After(v.UpdateTime.Add(trashHeartbeatTimeout)) {
+			v.Done = true
+			v.EndTime = &now
+			v.Status = proto.TaskFailed
+			v.Result = fmt.Sprintf("no progress from lcnode(%v) since %v", v.LcNode, v.UpdateTime)
+			log.LogWarnf("trash task(%v) is not updated, mark failed", k)
+		}
+	}
+}
+
+func (c *Cluster) scheduleToTrash() {
+	go c.trashMgr.process()
+	go func() {
+		for {
+			if c.partition != nil && c.partition.IsRaftLeader() {
+				c.trashMgr.trashTaskStatus.DeleteOldResult()
+			}
+			time.Sleep(time.Second * defaultIntervalToCheck)
+		}
+	}()
+}
+
+func (c *Cluster) handleLcNodeTrashResp(nodeAddr string, resp *proto.TrashTaskResponse) (err error) {
+	if !resp.Done {
+		log.LogInfof("action[handleLcNodeTrashResp] trash received, lcNode[%v] task[%v]", nodeAddr, resp.ID)
+		return
+	}
+	c.trashMgr.trashTaskStatus.UpdateResult(resp)
+	log.LogInfof("action[handleLcNodeTrashResp] trash done, lcNode[%v] task[%v] status[%v] result[%v] entries[%v] failed[%v]",
+		nodeAddr, resp.ID, resp.Status, resp.Result, resp.EntryNum, resp.FailedNum)
+	return
+}
@@ -39,6 +39,7 @@ type VolVarargs struct {
 	deleteLockTime           int64  // h
 	followerRead             bool
 	metaFollowerRead         bool
+	metaFollowerReadIndex    bool
 	directRead               bool
 	authenticate             bool
 	dpSelectorName           string
@@ -64,6 +65,8 @@ type VolVarargs struct {
 	allowedStorageClass      []uint32
 	forbidWriteOpOfProtoVer0 bool
 	quotaByClass             map[uint32]uint64
+	inlineDataThreshold      uint32
+	compression              string
 }
 
 // nolint: structcheck
@@ -146,15 +149,19 @@ type Vol struct {
 	NeedToLowerReplica       bool
 	FollowerRead             bool
 	MetaFollowerRead         bool
+	MetaFollowerReadIndex    bool
 	DirectRead               bool
 	enableQuota              bool
+	metaStoreMode            proto.StoreMode
 	DisableAuditLog          bool
 	DpReadOnlyWhenVolFull    bool // only if this switch is on, all dp becomes readonly when vol is full
 	ReadOnlyForVolFull       bool // only if the switch DpReadOnlyWhenVolFull is on, mark vol is readonly when is full
 	AccessTimeInterval       int64
 	EnablePersistAccessTime  bool
 	AccessTimeValidInterval  int64
-	LeaderRetryTimeout       int64 // s
+	LeaderRetryTimeout       int64  // s
+	InlineDataThreshold      uint32 // the files not larger than it are stored in the inode
+	Compression              string // the encoding the client compresses the extent blocks with
 	EnableAutoMetaRepair     atomicutil.Bool
 	ForbidWriteOpOfProtoVer0 atomicutil.Bool
 
@@ -181,6 +188,11 @@ type Vol struct {
 	StatMigrateStorageClass []*proto.StatOfStorageClass
 	StatByDpMediaType       []*proto.StatOfStorageClass
 	QuotaByClass            []*proto.StatOfStorageClass
+
+	// writable clone, the extents of the source are shared until they are overwritten
+	CloneSource string
+	CloneVer    uint64
+	CloneStatus uint8
 }
 
 func newVol(vv volValue) (vol *Vol) {
@@ -196,6 +208,7 @@ func newVol(vv volValue) (vol *Vol) {
 	vol.Capacity = vv.Capacity
 	vol.FollowerRead = vv.FollowerRead
 	vol.MetaFollowerRead = vv.MetaFollowerRead
+	vol.MetaFollowerReadIndex = vv.MetaFollowerReadIndex
 	vol.DirectRead = vv.DirectRead
 	vol.LeaderRetryTimeout = vv.LeaderRetryTimeOut
 	vol.authenticate = vv.Authenticate
@@ -210,6 +223,7 @@ func newVol(vv volValue) (vol *Vol) {
 	vol.domainId = vv.DomainId
 	vol.enablePosixAcl = vv.EnablePosixAcl
 	vol.enableQuota = vv.EnableQuota
+	vol.metaStoreMode = vv.MetaStoreMode
 	vol.enableTransaction = vv.EnableTransaction
 	vol.txTimeout = vv.TxTimeout
 	vol.txConflictRetryNum = vv.TxConflictRetryNum
@@ -263,6 +277,12 @@ func newVol(vv volValue) (vol *Vol) {
 	vol.StatMigrateStorageClass = make([]*proto.StatOfStorageClass, 0)
 	vol.ForbidWriteOpOfProtoVer0.Store(defaultVolForbidWriteOpOfProtoVersion0)
 
+	vol.CloneSource = vv.CloneSource
+	vol.CloneVer = vv.CloneVer
+	vol.CloneStatus = vv.CloneStatus
+	vol.InlineDataThreshold = vv.InlineDataThreshold
+	vol.Compression = vv.Compression
+
 	vol.QuotaByClass = vv.QuotaOfClass
 	if len(vol.QuotaByClass) == 0 {
 		for _, c := range vol.allowedStorageClass {
@@ -1391,6 +1411,21 @@ func (vol *Vol) ebsUsedSpace() uint64 {
 	return size
 }
 
+// logicalUsedSpace returns the sum of the extent keys and inline data of the files reported by the
+// meta partitions, which is the size of the data before compressed: the holes of the sparse files
+// and the ranges punched are not counted, and the file with hard links is counted once.
+func (vol *Vol) logicalUsedSpace() uint64 {
+	size := uint64(0)
+	vol.mpsLock.RLock()
+	defer vol.mpsLock.RUnlock()
+
+	for _, mp := range vol.MetaPartitions {
+		size += mp.logicalSize()
+	}
+
+	return size
+}
+
 func (vol *Vol) updateViewCache(c *Cluster) {
 	view := proto.NewVolView(vol.Name, vol.Status, vol.FollowerRead, vol.createTime, vol.CacheTTL, vol.VolType, vol.DeleteLockTime)
 	view.SetOwner(vol.Owner)
@@ -1526,7 +1561,12 @@ func (vol *Vol) checkStatus(c *Cluster) {
 	if len(metaTasks) == 0 && len(dataTasks) == 0 {
 		go func() {
 			vol.Deleting = true
-			vol.deleteVolFromStore(c)
+			// retried by the next check until the holds of the clone are released
+			if err := c.releaseCloneHolds(vol); err != nil {
+				log.LogErrorf("action[volCheckStatus] vol[%v] err[%v]", vol.Name, err)
+			} else {
+				vol.deleteVolFromStore(c)
+			}
 			vol.Deleting = false
 		}()
 	}
@@ -1805,6 +1845,7 @@ func (vol *Vol) doCreateMetaPartition(c *Cluster, start, end uint64) (mp *MetaPa
 	}
 
 	mp = newMetaPartition(partitionID, start, end, vol.mpReplicaNum, vol.Name, vol.ID, vol.VersionMgr.getLatestVer())
+	mp.StoreMode = vol.metaStoreMode
 	mp.setHosts(hosts)
 	mp.setPeers(peers)
 
@@ -1862,6 +1903,7 @@ func setVolFromArgs(args *VolVarargs, vol *Vol) {
 	vol.DeleteLockTime = args.deleteLockTime
 	vol.FollowerRead = args.followerRead
 	vol.MetaFollowerRead = args.metaFollowerRead
+	vol.MetaFollowerReadIndex = args.metaFollowerReadIndex
 	vol.DirectRead = args.directRead
 	vol.authenticate = args.authenticate
 	vol.enablePosixAcl = args.enablePosixAcl
@@ -1875,6 +1917,8 @@ func setVolFromArgs(args *VolVarargs, vol *Vol) {
 	vol.dpReplicaNum = args.dpReplicaNum
 	vol.crossZone = args.crossZone
 	vol.LeaderRetryTimeout = args.leaderRetryTimeout
+	vol.InlineDataThreshold = args.inlineDataThreshold
+	vol.Compression = args.compression
 
 	if proto.IsVolSupportStorageClass(args.allowedStorageClass, proto.StorageClass_BlobStore) {
 		vol.EbsBlkSize = args.coldArgs.objBlockSize
@@ -1942,6 +1986,7 @@ func getVolVarargs(vol *Vol) *VolVarargs {
 		deleteLockTime:           vol.DeleteLockTime,
 		followerRead:             vol.FollowerRead,
 		metaFollowerRead:         vol.MetaFollowerRead,
+		metaFollowerReadIndex:    vol.MetaFollowerReadIndex,
 		directRead:               vol.DirectRead,
 		leaderRetryTimeout:       vol.LeaderRetryTimeout,
 		authenticate:             vol.authenticate,
@@ -1965,6 +2010,8 @@ func getVolVarargs(vol *Vol) *VolVarargs {
 		allowedStorageClass:      append([]uint32{}, vol.allowedStorageClass...),
 		forbidWriteOpOfProtoVer0: vol.ForbidWriteOpOfProtoVer0.Load(),
 		quotaByClass:             quotaByClass,
+		inlineDataThreshold:      vol.InlineDataThreshold,
+		compression:              vol.Compression,
 	}
 }
 
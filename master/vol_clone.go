@@ -0,0 +1,546 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// A writable clone is a new volume sharing the data with the source volume at a snapshot. The
+// metadata of the snapshot is copied into the clone by lcnode with the extent keys kept as they
+// are, and the data partitions of the source are served read only to the clone, so the clone
+// diverges copy-on-write: the shared extents are never written or freed by the clone.
+//
+// The refs of the shared extents are counted per extent across the volumes by the data partitions
+// of the source: the meta partitions of the clone hold the extents as the keys are copied, and
+// release them once the clone drops them, or all at once as the clone is deleted. The extents
+// deleted by the source are kept until the last hold is released, so the snapshot version can be
+// deleted once the keys are copied. The ranges on the tiny extents, which are shared by many files
+// and can not be held, are rewritten into the clone by the copy. The source volume can not be
+// deleted while some clone still refers to it, the detach rewrites the data still shared into the
+// data partitions of the clone, after which the clone no longer refers to the source.
+
+const (
+	volCloneResultKeepTime   = time.Hour * 24
+	volCloneHeartbeatTimeout = time.Minute * 10
+)
+
+// volCloneManager dispatches the metadata copies of the clones to the idle lcnodes, the progress
+// of the running tasks is updated by the heartbeats of lcnodes.
+type volCloneManager struct {
+	cluster      *Cluster
+	taskStatus   *volCloneTaskStatus
+	lcNodeStatus *lcNodeStatus
+	idleNodeCh   chan struct{}
+	exitCh       chan struct{}
+}
+
+func newVolCloneManager() *volCloneManager {
+	log.LogInfof("action[newVolCloneManager] construct")
+	return &volCloneManager{
+		taskStatus:   newVolCloneTaskStatus(),
+		lcNodeStatus: newLcNodeStatus(),
+		idleNodeCh:   make(chan struct{}, 1000),
+		exitCh:       make(chan struct{}),
+	}
+}
+
+func (m *volCloneManager) process() {
+	for {
+		select {
+		case <-m.exitCh:
+			log.LogInfo("exitCh notified, volCloneManager process exit")
+			return
+		case <-m.idleNodeCh:
+			task := m.taskStatus.GetOneTask()
+			if task == nil {
+				continue
+			}
+
+			nodeAddr := m.lcNodeStatus.GetIdleNode("")
+			if nodeAddr == "" {
+				log.LogWarn("no idle lcnode, redo clone task")
+				m.taskStatus.RedoTask(task)
+				continue
+			}
+
+			val, ok := m.cluster.lcNodes.Load(nodeAddr)
+			if !ok {
+				log.LogErrorf("lcNodes.Load, nodeAddr(%v) is not available, redo clone task", nodeAddr)
+				m.lcNodeStatus.RemoveNode(nodeAddr)
+				m.taskStatus.RedoTask(task)
+				continue
+			}
+
+			node := val.(*LcNode)
+			adminTask := node.createVolCloneTask(m.cluster.masterAddr(), task)
+			m.cluster.addLcNodeTasks([]*proto.AdminTask{adminTask})
+			log.LogInfof("add clone task(%v) to lcnode(%v)", *task, nodeAddr)
+		}
+	}
+}
+
+func (m *volCloneManager) notifyIdleLcNode() {
+	m.taskStatus.RLock()
+	defer m.taskStatus.RUnlock()
+
+	if len(m.taskStatus.ToBeCloned) > 0 {
+		select {
+		case m.idleNodeCh <- struct{}{}:
+			log.LogDebug("action[handleLcNodeHeartbeatResp], volCloneManager routine notified!")
+		default:
+		}
+	}
+}
+
+func (m *volCloneManager) addTask(task *proto.VolCloneTask) {
+	m.taskStatus.AddTask(task)
+	select {
+	case m.idleNodeCh <- struct{}{}:
+	default:
+	}
+	log.LogInfof("action[addTask] clone task(%v) added", *task)
+}
+
+//----------------------------------------------
+
+type volCloneTaskStatus struct {
+	sync.RWMutex
+	ToBeCloned map[string]*proto.VolCloneTask
+	Results    map[string]*proto.VolCloneTaskResponse
+}
+
+func newVolCloneTaskStatus() *volCloneTaskStatus {
+	return &volCloneTaskStatus{
+		ToBeCloned: make(map[string]*proto.VolCloneTask),
+		Results:    make(map[string]*proto.VolCloneTaskResponse),
+	}
+}
+
+func (st *volCloneTaskStatus) AddTask(task *proto.VolCloneTask) {
+	st.Lock()
+	defer st.Unlock()
+	st.ToBeCloned[task.Id] = task
+}
+
+func (st *volCloneTaskStatus) GetOneTask() (task *proto.VolCloneTask) {
+	st.Lock()
+	defer st.Unlock()
+	for _, t := range st.ToBeCloned {
+		task = t
+		break
+	}
+	if task == nil {
+		return
+	}
+
+	delete(st.ToBeCloned, task.Id)
+	t := time.Now()
+	st.Results[task.Id] = &proto.VolCloneTaskResponse{
+		ID:         task.Id,
+		UpdateTime: &t,
+		Task:       task,
+	}
+	return
+}
+
+func (st *volCloneTaskStatus) RedoTask(task *proto.VolCloneTask) {
+	st.Lock()
+	defer st.Unlock()
+	delete(st.Results, task.Id)
+	st.ToBeCloned[task.Id] = task
+}
+
+// UpdateResult updates the result of the task unless it is already done, it returns true if the
+// task is done by this update.
+func (st *volCloneTaskStatus) UpdateResult(resp *proto.VolCloneTaskResponse) (done bool) {
+	st.Lock()
+	defer st.Unlock()
+	if r, ok := st.Results[resp.ID]; ok && r.Done {
+		return false
+	}
+	t := time.Now()
+	resp.UpdateTime = &t
+	st.Results[resp.ID] = resp
+	return resp.Done
+}
+
+// hasTask returns true if the copy of the clone is waiting or running.
+func (st *volCloneTaskStatus) hasTask(cloneVol string) bool {
+	st.RLock()
+	defer st.RUnlock()
+	for _, task := range st.ToBeCloned {
+		if task.CloneVol == cloneVol {
+			return true
+		}
+	}
+	for _, r := range st.Results {
+		if !r.Done && r.Task != nil && r.Task.CloneVol == cloneVol {
+			return true
+		}
+	}
+	return false
+}
+
+// GetResults returns the results of the clone, or the clones of the source, or all the clones if
+// volName is empty, the latest first.
+func (st *volCloneTaskStatus) GetResults(volName string) (results []*proto.VolCloneTaskResponse) {
+	st.RLock()
+	defer st.RUnlock()
+	match := func(task *proto.VolCloneTask) bool {
+		return volName == "" || task.CloneVol == volName || task.SourceVol == volName
+	}
+	results = make([]*proto.VolCloneTaskResponse, 0)
+	for _, task := range st.ToBeCloned {
+		if match(task) {
+			results = append(results, &proto.VolCloneTaskResponse{
+				ID:     task.Id,
+				Task:   task,
+				Result: "waiting",
+			})
+		}
+	}
+	for _, r := range st.Results {
+		if r.Task != nil && match(r.Task) {
+			results = append(results, r)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].ID > results[j].ID })
+	return
+}
+
+// DeleteOldResult drops the results kept for long, and marks the tasks failed if the lcnode is
+// lost, the failed ones are returned.
+func (st *volCloneTaskStatus) DeleteOldResult() (failed []*proto.VolCloneTaskResponse) {
+	st.Lock()
+	defer st.Unlock()
+	now := time.Now()
+	for k, v := range st.Results {
+		if v.Done && v.EndTime != nil && now.After(v.EndTime.Add(volCloneResultKeepTime)) {
+			delete(st.Results, k)
+			continue
+		}
+		// the copy is not redone since the clone may be half copied
+		if !v.Done && v.UpdateTime != nil && now.After(v.UpdateTime.Add(volCloneHeartbeatTimeout)) {
+			v.Done = true
+			v.EndTime = &now
+			v.Status = proto.TaskFailed
+			v.Result = fmt.Sprintf("no progress from lcnode(%v) since %v", v.LcNode, v.UpdateTime)
+			failed = append(failed, v)
+			log.LogWarnf("clone task(%v) is not updated, mark failed", k)
+		}
+	}
+	return
+}
+
+//----------------------------------------------
+
+func newVolCloneReq(src *Vol, name, owner string, ver uint64) *createVolReq {
+	req := &createVolReq{
+		name:                    name,
+		owner:                   owner,
+		dpSize:                  int(src.dataPartitionSize / util.GB),
+		mpCount:                 defaultInitMetaPartitionCount,
+		dpCount:                 defaultInitDataPartitionCnt,
+		dpReplicaNum:            src.dpReplicaNum,
+		capacity:                int(src.Capacity),
+		deleteLockTime:          src.DeleteLockTime,
+		followerRead:            src.FollowerRead,
+		metaFollowerRead:        src.MetaFollowerRead,
+		metaFollowerReadIndex:   src.MetaFollowerReadIndex,
+		authenticate:            src.authenticate,
+		crossZone:               src.crossZone,
+		normalZonesFirst:        src.defaultPriority,
+		domainId:                src.domainId,
+		zoneName:                src.zoneName,
+		description:             fmt.Sprintf("clone of %v at version %v", src.Name, ver),
+		volType:                 src.VolType,
+		enablePosixAcl:          src.enablePosixAcl,
+		DpReadOnlyWhenVolFull:   src.DpReadOnlyWhenVolFull,
+		enableTransaction:       src.enableTransaction,
+		metaStoreMode:           src.metaStoreMode,
+		txTimeout:               src.txTimeout,
+		txConflictRetryNum:      src.txConflictRetryNum,
+		txConflictRetryInterval: src.txConflictRetryInterval,
+		qosLimitArgs:            &qosArgs{},
+		trashInterval:           src.TrashInterval,
+		accessTimeValidInterval: src.AccessTimeValidInterval,
+		enablePersistAccessTime: src.EnablePersistAccessTime,
+		volStorageClass:         src.volStorageClass,
+		cacheDpStorageClass:     src.cacheDpStorageClass,
+		cloneSource:             src.Name,
+		cloneVer:                ver,
+	}
+	req.allowedStorageClass = make([]uint32, len(src.allowedStorageClass))
+	copy(req.allowedStorageClass, src.allowedStorageClass)
+	return req
+}
+
+// findCloneSnapshot returns the snapshot of the source volume to be cloned, which is named by the
+// snapshot name or the version.
+func (c *Cluster) findCloneSnapshot(src *Vol, name string) (snapshot *proto.VolSnapshot, err error) {
+	if src.Status == proto.VolStatusMarkDelete {
+		return nil, fmt.Errorf("volume(%v) is deleted", src.Name)
+	}
+	if !proto.IsHot(src.VolType) {
+		return nil, fmt.Errorf("volume(%v) is not a hot one", src.Name)
+	}
+	if src.CloneSource != "" {
+		return nil, fmt.Errorf("volume(%v) is a clone of volume(%v), which can not be cloned", src.Name, src.CloneSource)
+	}
+	snapshot = proto.FindSnapshot(src.VersionMgr.getVersionList().GetSnapshots(), name)
+	if snapshot == nil {
+		return nil, fmt.Errorf("snapshot(%v) of volume(%v) not found", name, src.Name)
+	}
+	return
+}
+
+// cloneVol creates the clone volume and queues the copy of the metadata of the snapshot.
+func (c *Cluster) cloneVol(req *createVolReq, snapshot *proto.VolSnapshot) (task *proto.VolCloneTask, err error) {
+	var vol *Vol
+	if vol, err = c.createVol(req); err != nil {
+		return
+	}
+	c.updateCloneSharedPartitions(vol)
+	vol.dataPartitions.updateResponseCache(true, 0, vol)
+	vol.dataPartitions.updateCompressCache(true, 0, vol)
+
+	task = &proto.VolCloneTask{
+		Id:        fmt.Sprintf("%s:%d", req.name, time.Now().Unix()),
+		SourceVol: req.cloneSource,
+		CloneVol:  req.name,
+		Ver:       snapshot.Ver,
+		ReadSeq:   snapshot.ReadSeq,
+	}
+	// the version may be deleted before the clone is created, which refers to it from now on
+	src, err := c.getVol(req.cloneSource)
+	if err == nil {
+		_, err = c.findCloneSnapshot(src, fmt.Sprint(snapshot.Ver))
+	}
+	if err != nil {
+		c.finishVolClone(vol.Name, proto.VolCloneCopying, proto.VolCloneFailed)
+		return nil, err
+	}
+	c.volCloneMgr.addTask(task)
+	return
+}
+
+// detachVolClone queues the rewrite of the data the clone still shares with the source, the
+// clone is detached from the source once it succeeds.
+func (c *Cluster) detachVolClone(name string) (task *proto.VolCloneTask, err error) {
+	vol, err := c.getVol(name)
+	if err != nil {
+		return
+	}
+	if vol.CloneSource == "" || vol.CloneStatus != proto.VolCloneReady {
+		return nil, fmt.Errorf("volume(%v) is not a clone ready to be detached, status(%v)",
+			name, proto.VolCloneStatusString(vol.CloneStatus))
+	}
+	// the versions of the clone keep the extent keys shared, which are never rewritten
+	if snapshots := vol.VersionMgr.getVersionList().GetSnapshots(); len(snapshots) > 0 {
+		return nil, fmt.Errorf("clone(%v) has %v snapshots, which should be deleted first", name, len(snapshots))
+	}
+	if c.volCloneMgr.taskStatus.hasTask(name) {
+		return nil, fmt.Errorf("clone(%v) has a task waiting or running", name)
+	}
+	task = &proto.VolCloneTask{
+		Id:        fmt.Sprintf("%s:detach:%d", name, time.Now().Unix()),
+		SourceVol: vol.CloneSource,
+		CloneVol:  name,
+		Ver:       vol.CloneVer,
+		Detach:    true,
+	}
+	c.volCloneMgr.addTask(task)
+	return
+}
+
+// getVolClones returns the clones of the volume at the version, or at any version if ver is 0,
+// the ones being deleted are included since they may still read the shared extents, while the
+// detached ones refer to the source no longer.
+func (c *Cluster) getVolClones(name string, ver uint64) (clones []string) {
+	clones = make([]string, 0)
+	for _, vol := range c.allVols() {
+		if vol.CloneSource == name && vol.CloneStatus != proto.VolCloneDetached && (ver == 0 || vol.CloneVer == ver) {
+			clones = append(clones, vol.Name)
+		}
+	}
+	sort.Strings(clones)
+	return
+}
+
+// getCopyingVolClones returns the clones of the volume at the version whose metadata are being
+// copied, the extents of the snapshot are not held for them until the keys are copied.
+func (c *Cluster) getCopyingVolClones(name string, ver uint64) (clones []string) {
+	clones = make([]string, 0)
+	for _, vol := range c.allVols() {
+		if vol.CloneSource == name && vol.CloneStatus == proto.VolCloneCopying && vol.CloneVer == ver {
+			clones = append(clones, vol.Name)
+		}
+	}
+	sort.Strings(clones)
+	return
+}
+
+// releaseCloneHolds releases the holds of the meta partitions of the clone deleted on the extents
+// of the source, the data partitions of the source delete the extents dropped by the source
+// meanwhile once no other clone holds them.
+func (c *Cluster) releaseCloneHolds(vol *Vol) (err error) {
+	if vol.CloneSource == "" {
+		return
+	}
+	src, err := c.getVol(vol.CloneSource)
+	if err != nil {
+		// the source is deleted with the data partitions after the detach
+		log.LogWarnf("action[releaseCloneHolds] clone(%v) source(%v) err(%v)", vol.Name, vol.CloneSource, err)
+		return nil
+	}
+	vol.mpsLock.RLock()
+	holders := make([]uint64, 0, len(vol.MetaPartitions))
+	for id := range vol.MetaPartitions {
+		holders = append(holders, id)
+	}
+	vol.mpsLock.RUnlock()
+	if len(holders) == 0 {
+		return
+	}
+	sort.Slice(holders, func(i, j int) bool { return holders[i] < holders[j] })
+
+	src.dataPartitions.RLock()
+	partitions := make([]*DataPartition, 0, len(src.dataPartitions.partitions))
+	partitions = append(partitions, src.dataPartitions.partitions...)
+	src.dataPartitions.RUnlock()
+	for _, dp := range partitions {
+		leaderAddr := dp.getLeaderAddrWithLock()
+		if leaderAddr == "" {
+			return fmt.Errorf("release the holds of clone(%v) on dp(%v): %v", vol.Name, dp.PartitionID, proto.ErrNoLeader)
+		}
+		var dataNode *DataNode
+		if dataNode, err = c.dataNode(leaderAddr); err != nil {
+			return
+		}
+		task := proto.NewAdminTask(proto.OpReleaseCloneHolds, leaderAddr,
+			&proto.ReleaseSharedExtentsRequest{PartitionId: dp.PartitionID, Holders: holders})
+		dp.resetTaskID(task)
+		if _, err = dataNode.TaskManager.syncSendAdminTask(task); err != nil {
+			return fmt.Errorf("release the holds of clone(%v) on dp(%v): %v", vol.Name, dp.PartitionID, err)
+		}
+	}
+	log.LogInfof("action[releaseCloneHolds] clone(%v) source(%v) holders(%v) dps(%v)", vol.Name, src.Name, holders, len(partitions))
+	return
+}
+
+// updateCloneSharedPartitions serves the data partitions of the source to the clone read only.
+func (c *Cluster) updateCloneSharedPartitions(vol *Vol) {
+	if vol.CloneSource == "" {
+		return
+	}
+	src, err := c.getVol(vol.CloneSource)
+	if err != nil {
+		log.LogErrorf("action[updateCloneSharedPartitions] clone(%v) source(%v) err(%v)", vol.Name, vol.CloneSource, err)
+		return
+	}
+	partitions := src.dataPartitions.getDataPartitionsView(0)
+	for _, dp := range partitions {
+		dp.Status = proto.ReadOnly
+		dp.CloneSource = src.Name
+	}
+	vol.dataPartitions.setSharedPartitions(partitions)
+}
+
+// finishVolClone updates the status of the clone by the task finished, unless the status is
+// changed from the one the task started at.
+func (c *Cluster) finishVolClone(name string, from, status uint8) {
+	vol, err := c.getVol(name)
+	if err != nil {
+		log.LogErrorf("action[finishVolClone] clone(%v) err(%v)", name, err)
+		return
+	}
+	vol.volLock.Lock()
+	defer vol.volLock.Unlock()
+	if vol.CloneStatus != from {
+		return
+	}
+	vol.CloneStatus = status
+	if err = c.syncUpdateVol(vol); err != nil {
+		vol.CloneStatus = from
+		log.LogErrorf("action[finishVolClone] clone(%v) status(%v) persist err(%v)", name, status, err)
+		return
+	}
+	log.LogInfof("action[finishVolClone] clone(%v) status(%v)", name, proto.VolCloneStatusString(status))
+}
+
+// checkVolClones marks the clones failed if the copies are lost, such as by the change of the
+// master leader, the failed clone is left to be deleted by the user.
+func (c *Cluster) checkVolClones() {
+	for _, r := range c.volCloneMgr.taskStatus.DeleteOldResult() {
+		// the clone stays ready if the detach fails
+		if !r.Task.Detach {
+			c.finishVolClone(r.Task.CloneVol, proto.VolCloneCopying, proto.VolCloneFailed)
+		}
+	}
+	for _, vol := range c.allVols() {
+		if vol.CloneStatus == proto.VolCloneCopying && !c.volCloneMgr.taskStatus.hasTask(vol.Name) {
+			log.LogWarnf("action[checkVolClones] the copy of clone(%v) is lost, mark failed", vol.Name)
+			c.finishVolClone(vol.Name, proto.VolCloneCopying, proto.VolCloneFailed)
+		}
+	}
+}
+
+func (c *Cluster) scheduleToVolClone() {
+	go c.volCloneMgr.process()
+	go func() {
+		for {
+			if c.partition != nil && c.partition.IsRaftLeader() && c.metaReady {
+				c.checkVolClones()
+			}
+			time.Sleep(time.Second * defaultIntervalToCheck)
+		}
+	}()
+}
+
+func (c *Cluster) handleLcNodeVolCloneResp(nodeAddr string, resp *proto.VolCloneTaskResponse) (err error) {
+	if !resp.Done {
+		log.LogInfof("action[handleLcNodeVolCloneResp] clone received, lcNode[%v] task[%v]", nodeAddr, resp.ID)
+		return
+	}
+	c.updateVolCloneResult(resp)
+	log.LogInfof("action[handleLcNodeVolCloneResp] clone done, lcNode[%v] task[%v] status[%v] result[%v] stat[%+v]",
+		nodeAddr, resp.ID, resp.Status, resp.Result, resp.VolCloneStatistics)
+	return
+}
+
+func (c *Cluster) updateVolCloneResult(resp *proto.VolCloneTaskResponse) {
+	if !c.volCloneMgr.taskStatus.UpdateResult(resp) || resp.Task == nil {
+		return
+	}
+	if resp.Task.Detach {
+		if resp.Status != proto.TaskFailed {
+			c.finishVolClone(resp.Task.CloneVol, proto.VolCloneReady, proto.VolCloneDetached)
+		}
+		return
+	}
+	status := proto.VolCloneReady
+	if resp.Status == proto.TaskFailed {
+		status = proto.VolCloneFailed
+	}
+	c.finishVolClone(resp.Task.CloneVol, proto.VolCloneCopying, status)
+}
@@ -0,0 +1,43 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetCopyingVolClones(t *testing.T) {
+	c := &Cluster{}
+	c.vols = make(map[string]*Vol)
+	for _, vol := range []*Vol{
+		{Name: "src"},
+		{Name: "copying", CloneSource: "src", CloneVer: 1, CloneStatus: proto.VolCloneCopying},
+		{Name: "ready", CloneSource: "src", CloneVer: 1, CloneStatus: proto.VolCloneReady},
+		{Name: "other_ver", CloneSource: "src", CloneVer: 2, CloneStatus: proto.VolCloneCopying},
+	} {
+		c.vols[vol.Name] = vol
+	}
+
+	// the snapshot is deletable once the keys are copied into the clones, which hold the extents
+	require.Equal(t, []string{"copying"}, c.getCopyingVolClones("src", 1))
+	require.Equal(t, []string{"copying", "ready"}, c.getVolClones("src", 1))
+	require.Empty(t, c.getCopyingVolClones("src", 3))
+
+	// the clone refers to the source no longer, nothing to be released
+	require.NoError(t, c.releaseCloneHolds(&Vol{Name: "gone", CloneSource: "deleted"}))
+}
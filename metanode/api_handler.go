@@ -82,6 +82,8 @@ func (m *MetaNode) registerAPIHandler() (err error) {
 	http.HandleFunc("/getInodeAccessTime", m.getInodeAccessTimeHandler)
 	// for hybrid cloud debug
 	http.HandleFunc("/getInodeWithExtentKey", m.getInodeWithExtentKeyHandler)
+	http.HandleFunc("/getChanges", m.getChangesHandler)
+	http.HandleFunc("/getDirStat", m.getDirStatHandler)
 	// http.HandleFunc("/setInodeCreateTime", m.setInodeCreateTimeHandler)
 	// http.HandleFunc("/deleteMigrateExtentKey", m.deleteMigrateExtentKeyHandler)
 	// http.HandleFunc("/updateExtentKeyAfterMigration", m.updateExtentKeyAfterMigrationHandler)
@@ -161,6 +163,77 @@ func (m *MetaNode) getPartitionByIDHandler(w http.ResponseWriter, r *http.Reques
 	resp.Msg = http.StatusText(http.StatusOK)
 }
 
+// getChangesHandler long-polls the changes of the partition after the index from, waiting at
+// most wait milliseconds if there are none.
+func (m *MetaNode) getChangesHandler(w http.ResponseWriter, r *http.Request) {
+	resp := NewAPIResponse(http.StatusBadRequest, "")
+	defer func() {
+		data, _ := resp.Marshal()
+		if _, err := w.Write(data); err != nil {
+			log.LogErrorf("[getChangesHandler] response %s", err)
+		}
+	}()
+	var pid, from common.Uint
+	var limit, wait common.Int
+	if err := parseArgs(r, pid.PID(), from.Key("from").OmitEmpty(),
+		limit.Key("limit").OmitEmpty(), wait.Key("wait").OmitEmpty()); err != nil {
+		resp.Msg = err.Error()
+		return
+	}
+	mp, err := m.metadataManager.GetPartition(pid.V)
+	if err != nil {
+		resp.Code = http.StatusNotFound
+		resp.Msg = err.Error()
+		return
+	}
+	req := &proto.GetChangesRequest{
+		PartitionID: pid.V,
+		From:        from.V,
+		Limit:       int(limit.V),
+		Wait:        wait.V,
+	}
+	changes, err := mp.ReadChanges(req, changesMaxWait)
+	if err != nil {
+		resp.Code = http.StatusInternalServerError
+		resp.Msg = err.Error()
+		return
+	}
+	resp.Data = changes
+	resp.Code = http.StatusOK
+	resp.Msg = http.StatusText(http.StatusOK)
+}
+
+// getDirStatHandler returns the recursive stats of the directory kept by the partition.
+func (m *MetaNode) getDirStatHandler(w http.ResponseWriter, r *http.Request) {
+	resp := NewAPIResponse(http.StatusBadRequest, "")
+	defer func() {
+		data, _ := resp.Marshal()
+		if _, err := w.Write(data); err != nil {
+			log.LogErrorf("[getDirStatHandler] response %s", err)
+		}
+	}()
+	var pid, ino common.Uint
+	if err := parseArgs(r, pid.PID(), ino.Ino()); err != nil {
+		resp.Msg = err.Error()
+		return
+	}
+	mp, err := m.metadataManager.GetPartition(pid.V)
+	if err != nil {
+		resp.Code = http.StatusNotFound
+		resp.Msg = err.Error()
+		return
+	}
+	stat, err := mp.DirStatOf(ino.V)
+	if err != nil {
+		resp.Code = http.StatusNotFound
+		resp.Msg = err.Error()
+		return
+	}
+	resp.Data = stat
+	resp.Code = http.StatusOK
+	resp.Msg = http.StatusText(http.StatusOK)
+}
+
 func (m *MetaNode) getLeaderPartitionsHandler(w http.ResponseWriter, r *http.Request) {
 	resp := NewAPIResponse(http.StatusOK, http.StatusText(http.StatusOK))
 	mps := m.metadataManager.GetLeaderPartitions()
@@ -235,7 +308,9 @@ func (m *MetaNode) getAllInodesHandler(w http.ResponseWriter, r *http.Request) {
 		return true
 	}
 
-	mp.GetInodeTree().Ascend(f)
+	inodeTree := mp.GetInodeTree()
+	defer inodeTree.Release()
+	inodeTree.Ascend(f)
 }
 
 func (m *MetaNode) getSplitKeyHandler(w http.ResponseWriter, r *http.Request) {
@@ -623,7 +698,9 @@ func (m *MetaNode) getAllDentriesHandler(w http.ResponseWriter, r *http.Request)
 		isFirst   = true
 	)
 
-	mp.GetDentryTree().Ascend(func(i BtreeItem) bool {
+	dentryTree := mp.GetDentryTree()
+	defer dentryTree.Release()
+	dentryTree.Ascend(func(i BtreeItem) bool {
 		den, _ := i.(*Dentry).getDentryFromVerList(verSeq, false)
 		if den == nil || den.isDeleted() {
 			return true
@@ -30,7 +30,8 @@ type (
 // BTree is the wrapper of Google's btree.
 type BTree struct {
 	sync.RWMutex
-	tree *btree.BTree
+	tree  *btree.BTree
+	store *treeStore // nil unless the tree is backed by the kv store, see btree_store.go
 }
 
 // NewBtree creates a new btree.
@@ -42,6 +43,12 @@ func NewBtree() *BTree {
 
 // Get returns the object of the given key in the btree.
 func (b *BTree) Get(key BtreeItem) (item BtreeItem) {
+	if b.store != nil {
+		b.Lock()
+		item = b.load(key)
+		b.Unlock()
+		return
+	}
 	b.RLock()
 	item = b.tree.Get(key)
 	b.RUnlock()
@@ -50,6 +57,10 @@ func (b *BTree) Get(key BtreeItem) (item BtreeItem) {
 
 func (b *BTree) CopyGet(key BtreeItem) (item BtreeItem) {
 	b.Lock()
+	if b.store != nil && b.load(key) == nil {
+		b.Unlock()
+		return
+	}
 	item = b.tree.CopyGet(key)
 	b.Unlock()
 	return
@@ -57,9 +68,7 @@ func (b *BTree) CopyGet(key BtreeItem) (item BtreeItem) {
 
 // Find searches for the given key in the btree.
 func (b *BTree) Find(key BtreeItem, fn func(i BtreeItem)) {
-	b.RLock()
-	item := b.tree.Get(key)
-	b.RUnlock()
+	item := b.Get(key)
 	if item == nil {
 		return
 	}
@@ -68,6 +77,9 @@ func (b *BTree) Find(key BtreeItem, fn func(i BtreeItem)) {
 
 func (b *BTree) CopyFind(key BtreeItem, fn func(i BtreeItem)) {
 	b.Lock()
+	if b.store != nil {
+		b.load(key)
+	}
 	item := b.tree.CopyGet(key)
 	fn(item)
 	b.Unlock()
@@ -76,7 +88,11 @@ func (b *BTree) CopyFind(key BtreeItem, fn func(i BtreeItem)) {
 // Has checks if the key exists in the btree.
 func (b *BTree) Has(key BtreeItem) (ok bool) {
 	b.RLock()
-	ok = b.tree.Has(key)
+	if b.store != nil {
+		ok = b.has(key)
+	} else {
+		ok = b.tree.Has(key)
+	}
 	b.RUnlock()
 	return
 }
@@ -84,20 +100,43 @@ func (b *BTree) Has(key BtreeItem) (ok bool) {
 // Delete deletes the object by the given key.
 func (b *BTree) Delete(key BtreeItem) (item BtreeItem) {
 	b.Lock()
-	item = b.tree.Delete(key)
+	if b.store != nil {
+		item = b.deleteItem(key)
+	} else {
+		item = b.tree.Delete(key)
+	}
 	b.Unlock()
 	return
 }
 
-func (b *BTree) Execute(fn func(tree *btree.BTree) interface{}) interface{} {
+// CopyExecute calls fn with the item of the key copied on write as CopyGet does, the item is nil
+// if not found. The item is deleted if fn returns del, and the result of fn is returned.
+func (b *BTree) CopyExecute(key BtreeItem, fn func(item BtreeItem) (result interface{}, del bool)) interface{} {
 	b.Lock()
 	defer b.Unlock()
-	return fn(b.tree)
+	var item BtreeItem
+	if b.store == nil || b.load(key) != nil {
+		item = b.tree.CopyGet(key)
+	}
+	result, del := fn(item)
+	if del {
+		if b.store != nil {
+			b.deleteItem(key)
+		} else {
+			b.tree.Delete(key)
+		}
+	}
+	return result
 }
 
 // ReplaceOrInsert is the wrapper of google's btree ReplaceOrInsert.
 func (b *BTree) ReplaceOrInsert(key BtreeItem, replace bool) (item BtreeItem, ok bool) {
 	b.Lock()
+	if b.store != nil {
+		item, ok = b.replaceOrInsert(key, replace)
+		b.Unlock()
+		return
+	}
 	if replace {
 		item = b.tree.ReplaceOrInsert(key)
 		b.Unlock()
@@ -121,6 +160,10 @@ func (b *BTree) ReplaceOrInsert(key BtreeItem, replace bool) (item BtreeItem, ok
 // This function scans the entire btree. When the data is huge, it is not recommended to use this function online.
 // Instead, it is recommended to call GetTree to obtain the snapshot of the current btree, and then do the scan on the snapshot.
 func (b *BTree) Ascend(fn func(i BtreeItem) bool) {
+	if b.store != nil {
+		b.ascendView(nil, nil, fn)
+		return
+	}
 	b.RLock()
 	b.tree.Ascend(fn)
 	b.RUnlock()
@@ -128,6 +171,10 @@ func (b *BTree) Ascend(fn func(i BtreeItem) bool) {
 
 // AscendRange is the wrapper of the google's btree AscendRange.
 func (b *BTree) AscendRange(greaterOrEqual, lessThan BtreeItem, iterator func(i BtreeItem) bool) {
+	if b.store != nil {
+		b.ascendView(greaterOrEqual, lessThan, iterator)
+		return
+	}
 	b.RLock()
 	b.tree.AscendRange(greaterOrEqual, lessThan, iterator)
 	b.RUnlock()
@@ -135,6 +182,10 @@ func (b *BTree) AscendRange(greaterOrEqual, lessThan BtreeItem, iterator func(i
 
 // AscendGreaterOrEqual is the wrapper of the google's btree AscendGreaterOrEqual
 func (b *BTree) AscendGreaterOrEqual(pivot BtreeItem, iterator func(i BtreeItem) bool) {
+	if b.store != nil {
+		b.ascendView(pivot, nil, iterator)
+		return
+	}
 	b.RLock()
 	b.tree.AscendGreaterOrEqual(pivot, iterator)
 	b.RUnlock()
@@ -143,6 +194,11 @@ func (b *BTree) AscendGreaterOrEqual(pivot BtreeItem, iterator func(i BtreeItem)
 // GetTree returns the snapshot of a btree.
 func (b *BTree) GetTree() *BTree {
 	b.Lock()
+	if b.store != nil {
+		v := b.view()
+		b.Unlock()
+		return v
+	}
 	t := b.tree.Clone()
 	b.Unlock()
 	nb := NewBtree()
@@ -150,23 +206,76 @@ func (b *BTree) GetTree() *BTree {
 	return nb
 }
 
+// GetTreeToStore returns the snapshot of a btree to be stored. The changes of the tree backed by
+// the kv store are sealed, and are committed to the kv store with the snapshot.
+func (b *BTree) GetTreeToStore() *BTree {
+	if b.store == nil {
+		return b.GetTree()
+	}
+	b.Lock()
+	b.seal()
+	v := b.view()
+	b.Unlock()
+	return v
+}
+
+// ascendView scans the tree itself if it is a view, otherwise a snapshot of it which is released
+// after the scan, so that the scans over the kv store do not block the writers.
+func (b *BTree) ascendView(start, end BtreeItem, fn func(i BtreeItem) bool) {
+	if b.store.snap != nil {
+		b.ascendStore(start, end, fn)
+		return
+	}
+	v := b.GetTree()
+	defer v.Release()
+	v.ascendStore(start, end, fn)
+}
+
+// Release releases the snapshot of the kv store held by the snapshot of a btree returned by
+// GetTree or GetTreeToStore, which must not be used after that. It does nothing for the trees
+// in memory.
+func (b *BTree) Release() {
+	if b == nil || b.store == nil {
+		return
+	}
+	b.Lock()
+	b.release()
+	b.Unlock()
+}
+
 // Reset resets the current btree.
 func (b *BTree) Reset() {
 	b.Lock()
-	b.tree.Clear(true)
+	if b.store != nil {
+		b.reset()
+	} else {
+		b.tree.Clear(true)
+	}
 	b.Unlock()
 }
 
 // Len returns the total number of items in the btree.
 func (b *BTree) Len() (size int) {
 	b.RLock()
-	size = b.tree.Len()
+	if b.store != nil {
+		size = b.store.count
+	} else {
+		size = b.tree.Len()
+	}
 	b.RUnlock()
 	return
 }
 
 // MaxItem returns the largest item in the btree.
 func (b *BTree) MaxItem() BtreeItem {
+	if b.store != nil {
+		var item BtreeItem
+		b.Ascend(func(i BtreeItem) bool {
+			item = i
+			return true
+		})
+		return item
+	}
 	b.RLock()
 	item := b.tree.Max()
 	b.RUnlock()
@@ -0,0 +1,588 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"sync/atomic"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/btree"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// A BTree backed by the KVStore keeps only the items accessed since the last store tick in the
+// in-memory tree, which acts as the write-back cache of the store:
+//
+//   - the items read from the store are cached, as the fsm modifies the items in place;
+//   - at the store tick, the cached items and the deleted keys are sealed into an encoded layer,
+//     and the cache is emptied;
+//   - the layers are committed to the store by the store goroutine along with the other snapshot
+//     files in one batch, and dropped from memory after that.
+//
+// Lookups go through the cache, the deleted keys, the sealed layers from new to old and at last
+// the store. The encoding of the keys keeps the order of the items, so the scans merge the cache
+// and the layers with the range of the store.
+//
+// The raft snapshot of such a tree is the key-values of a view of it, which are read from the
+// store as they are stored along with the cache and the layers. The follower writes them into a
+// new generation of the tree in its store instead of building the tree in memory, see kvIngest.
+
+// treeCodec encodes the items of a tree to the key-values of KVStore.
+type treeCodec struct {
+	table  byte
+	key    func(item BtreeItem) []byte
+	encode func(item BtreeItem) ([]byte, error)
+	decode func(value []byte) (BtreeItem, error)
+}
+
+func uint64Key(v uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, v)
+	return key
+}
+
+var inodeCodec = &treeCodec{
+	table: kvTableInode,
+	key:   func(item BtreeItem) []byte { return uint64Key(item.(*Inode).Inode) },
+	encode: func(item BtreeItem) ([]byte, error) {
+		return item.(*Inode).Marshal()
+	},
+	decode: func(value []byte) (BtreeItem, error) {
+		ino := NewInode(0, 0)
+		if err := ino.Unmarshal(value); err != nil {
+			return nil, err
+		}
+		if ino.LeaseExpireTime == 0 {
+			ino.LeaseExpireTime = uint64(ino.ModifyTime) + proto.ForbiddenMigrationRenewalSeonds
+		}
+		return ino, nil
+	},
+}
+
+var dentryCodec = &treeCodec{
+	table: kvTableDentry,
+	key: func(item BtreeItem) []byte {
+		d := item.(*Dentry)
+		return append(uint64Key(d.ParentId), d.Name...)
+	},
+	encode: func(item BtreeItem) ([]byte, error) {
+		return item.(*Dentry).Marshal()
+	},
+	decode: func(value []byte) (BtreeItem, error) {
+		d := &Dentry{}
+		if err := d.Unmarshal(value); err != nil {
+			return nil, err
+		}
+		return d, nil
+	},
+}
+
+var extendCodec = &treeCodec{
+	table: kvTableExtend,
+	key:   func(item BtreeItem) []byte { return uint64Key(item.(*Extend).inode) },
+	encode: func(item BtreeItem) ([]byte, error) {
+		return item.(*Extend).Bytes()
+	},
+	decode: func(value []byte) (BtreeItem, error) {
+		return NewExtendFromBytes(value)
+	},
+}
+
+// the key of multipart is the object key and the upload id separated by a zero byte, which keeps
+// the order as long as the object key has no zero byte.
+var multipartCodec = &treeCodec{
+	table: kvTableMultipart,
+	key: func(item BtreeItem) []byte {
+		m := item.(*Multipart)
+		key := make([]byte, 0, len(m.key)+len(m.id)+1)
+		key = append(append(key, m.key...), 0)
+		return append(key, m.id...)
+	},
+	encode: func(item BtreeItem) ([]byte, error) {
+		return item.(*Multipart).Bytes()
+	},
+	decode: func(value []byte) (item BtreeItem, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("decode multipart: %v", r)
+			}
+		}()
+		return MultipartFromBytes(value), nil
+	},
+}
+
+// kvItem is the encoded item, the value is nil if the item is deleted. The item is set instead
+// of the value for the items in the cache during scans.
+type kvItem struct {
+	key   []byte
+	value []byte
+	item  BtreeItem
+}
+
+func (i *kvItem) Less(than btree.Item) bool {
+	return bytes.Compare(i.key, than.(*kvItem).key) < 0
+}
+
+func (i *kvItem) Copy() btree.Item {
+	return i
+}
+
+// ascendKVItems ascends the items in [start, end), nil means unbounded.
+func ascendKVItems(tree *btree.BTree, start, end []byte, fn func(it *kvItem) bool) {
+	iter := func(i btree.Item) bool { return fn(i.(*kvItem)) }
+	switch {
+	case start == nil && end == nil:
+		tree.Ascend(iter)
+	case end == nil:
+		tree.AscendGreaterOrEqual(&kvItem{key: start}, iter)
+	case start == nil:
+		tree.AscendLessThan(&kvItem{key: end}, iter)
+	default:
+		tree.AscendRange(&kvItem{key: start}, &kvItem{key: end}, iter)
+	}
+}
+
+// storeLayer is the changes of a tree sealed at a store tick, which are not committed yet.
+type storeLayer struct {
+	seq     uint64
+	cleared bool // the items committed before the layer are dropped
+	count   int  // the number of items in the tree after the layer
+	items   *btree.BTree
+}
+
+// kvSnapshotRef is the snapshot of KVStore shared by the views of a tree, it is released once all
+// the views referring to it are released.
+type kvSnapshotRef struct {
+	snap KVSnapshot
+	refs int32
+}
+
+func newKVSnapshotRef(kv KVStore) *kvSnapshotRef {
+	return &kvSnapshotRef{snap: kv.NewSnapshot(), refs: 1}
+}
+
+func (ref *kvSnapshotRef) acquire() *kvSnapshotRef {
+	atomic.AddInt32(&ref.refs, 1)
+	return ref
+}
+
+func (ref *kvSnapshotRef) release() {
+	if atomic.AddInt32(&ref.refs, -1) == 0 {
+		ref.snap.Release()
+	}
+}
+
+// treeStore is the state of a tree backed by KVStore, it is protected by the lock of the tree.
+type treeStore struct {
+	kv       KVStore
+	codec    *treeCodec
+	gen      uint64 // the generation of the tree in the store
+	prefix   []byte
+	countKey []byte
+	snap     *kvSnapshotRef // set for the views returned by GetTree
+	owner    *BTree         // the live tree of the view, to drop the committed layers
+	deleted  map[string]struct{}
+	clean    map[string]uint32 // crc of the cached items as they were loaded, unchanged ones are not sealed
+	cleared  bool
+	layers   []*storeLayer // from old to new
+	count    int
+	seq      uint64
+}
+
+func newTreeStore(kv KVStore, pid, gen uint64, codec *treeCodec) *treeStore {
+	return &treeStore{
+		kv:       kv,
+		codec:    codec,
+		gen:      gen,
+		prefix:   kvTreePrefix(pid, codec.table, gen),
+		countKey: kvCountKey(pid, codec.table, gen),
+		deleted:  make(map[string]struct{}),
+		clean:    make(map[string]uint32),
+	}
+}
+
+// newStoreBtree returns the tree of the partition of the generation backed by the kv store.
+func newStoreBtree(kv KVStore, pid, gen uint64, codec *treeCodec) (b *BTree, err error) {
+	s := newTreeStore(kv, pid, gen, codec)
+	var count []byte
+	if count, err = kv.Get(nil, s.countKey); err != nil {
+		return
+	}
+	if len(count) == 8 {
+		s.count = int(binary.BigEndian.Uint64(count))
+	}
+	b = NewBtree()
+	b.store = s
+	return
+}
+
+func (s *treeStore) fatalf(format string, v ...interface{}) {
+	msg := fmt.Sprintf("treeStore(%x): %s", s.prefix, fmt.Sprintf(format, v...))
+	log.LogCritical(msg)
+	panic(msg)
+}
+
+func (s *treeStore) kvSnap() KVSnapshot {
+	if s.snap == nil {
+		return nil
+	}
+	return s.snap.snap
+}
+
+func (s *treeStore) decode(value []byte) BtreeItem {
+	item, err := s.codec.decode(value)
+	if err != nil {
+		s.fatalf("decode: %v", err)
+	}
+	return item
+}
+
+// lookup returns the item of the key under the cache, and the encoded value of it.
+func (s *treeStore) lookup(key []byte) (BtreeItem, []byte) {
+	if _, ok := s.deleted[string(key)]; ok || s.cleared {
+		return nil, nil
+	}
+	for i := len(s.layers) - 1; i >= 0; i-- {
+		l := s.layers[i]
+		if it := l.items.Get(&kvItem{key: key}); it != nil {
+			value := it.(*kvItem).value
+			if value == nil {
+				return nil, nil
+			}
+			value = append([]byte(nil), value...)
+			return s.decode(value), value
+		}
+		if l.cleared {
+			return nil, nil
+		}
+	}
+	value, err := s.kv.Get(s.kvSnap(), kvKey(s.prefix, key))
+	if err != nil {
+		s.fatalf("get %x: %v", key, err)
+	}
+	if value == nil {
+		return nil, nil
+	}
+	return s.decode(value), value
+}
+
+// load returns the item in the cache, or loads it into the cache.
+func (b *BTree) load(key BtreeItem) BtreeItem {
+	if item := b.tree.Get(key); item != nil {
+		return item
+	}
+	s := b.store
+	k := s.codec.key(key)
+	item, value := s.lookup(k)
+	if item != nil {
+		b.tree.ReplaceOrInsert(item)
+		s.clean[string(k)] = crc32.ChecksumIEEE(value)
+	}
+	return item
+}
+
+func (b *BTree) has(key BtreeItem) bool {
+	if b.tree.Has(key) {
+		return true
+	}
+	item, _ := b.store.lookup(b.store.codec.key(key))
+	return item != nil
+}
+
+func (b *BTree) deleteItem(key BtreeItem) BtreeItem {
+	item := b.load(key)
+	if item == nil {
+		return nil
+	}
+	s := b.store
+	k := string(s.codec.key(key))
+	b.tree.Delete(key)
+	delete(s.clean, k)
+	s.deleted[k] = struct{}{}
+	s.count--
+	return item
+}
+
+func (b *BTree) replaceOrInsert(item BtreeItem, replace bool) (BtreeItem, bool) {
+	old := b.load(item)
+	if old != nil && !replace {
+		return old, false
+	}
+	s := b.store
+	k := string(s.codec.key(item))
+	b.tree.ReplaceOrInsert(item)
+	delete(s.clean, k)
+	delete(s.deleted, k)
+	if old == nil {
+		s.count++
+	}
+	return old, true
+}
+
+func (b *BTree) reset() {
+	s := b.store
+	b.tree = btree.New(defaultBTreeDegree)
+	s.deleted = make(map[string]struct{})
+	s.clean = make(map[string]uint32)
+	s.layers = nil
+	s.cleared = true
+	s.count = 0
+}
+
+// view returns the read-only snapshot of the tree.
+func (b *BTree) view() *BTree {
+	s := b.store
+	vs := &treeStore{
+		kv:       s.kv,
+		codec:    s.codec,
+		gen:      s.gen,
+		prefix:   s.prefix,
+		countKey: s.countKey,
+		snap:     s.snap,
+		owner:    s.owner,
+		deleted:  make(map[string]struct{}, len(s.deleted)),
+		clean:    make(map[string]uint32),
+		cleared:  s.cleared,
+		layers:   append([]*storeLayer(nil), s.layers...),
+		count:    s.count,
+		seq:      s.seq,
+	}
+	for k := range s.deleted {
+		vs.deleted[k] = struct{}{}
+	}
+	if vs.owner == nil {
+		vs.owner = b
+	}
+	if vs.snap == nil {
+		vs.snap = newKVSnapshotRef(s.kv)
+	} else {
+		vs.snap.acquire()
+	}
+	return &BTree{tree: b.tree.Clone(), store: vs}
+}
+
+// release releases the snapshot of the kv store held by the view.
+func (b *BTree) release() {
+	s := b.store
+	if s.snap == nil {
+		return
+	}
+	s.snap.release()
+	s.snap = nil
+	s.layers = nil
+}
+
+// seal moves the changes in the cache to a new layer, and empties the cache.
+func (b *BTree) seal() {
+	s := b.store
+	layer := &storeLayer{cleared: s.cleared, items: btree.New(defaultBTreeDegree)}
+	b.tree.Ascend(func(i BtreeItem) bool {
+		key := s.codec.key(i)
+		value, err := s.codec.encode(i)
+		if err != nil {
+			s.fatalf("encode %x: %v", key, err)
+		}
+		if crc, ok := s.clean[string(key)]; ok && crc == crc32.ChecksumIEEE(value) {
+			return true
+		}
+		layer.items.ReplaceOrInsert(&kvItem{key: key, value: value})
+		return true
+	})
+	for k := range s.deleted {
+		layer.items.ReplaceOrInsert(&kvItem{key: []byte(k)})
+	}
+	if layer.items.Len() > 0 || layer.cleared {
+		s.seq++
+		layer.seq = s.seq
+		layer.count = s.count
+		s.layers = append(s.layers, layer)
+	}
+	b.tree = btree.New(defaultBTreeDegree)
+	s.deleted = make(map[string]struct{})
+	s.clean = make(map[string]uint32)
+	s.cleared = false
+}
+
+// commitTo adds the layers of the view to the batch, and returns the seq of the last one.
+func (b *BTree) commitTo(batch *KVBatch) (seq uint64) {
+	s := b.store
+	for _, l := range s.layers {
+		if l.cleared {
+			batch.DeleteRange(s.prefix, kvPrefixEnd(s.prefix))
+		}
+		ascendKVItems(l.items, nil, nil, func(it *kvItem) bool {
+			if it.value == nil {
+				batch.Delete(kvKey(s.prefix, it.key))
+			} else {
+				batch.Put(kvKey(s.prefix, it.key), it.value)
+			}
+			return true
+		})
+		seq = l.seq
+		batch.Put(s.countKey, uint64Key(uint64(l.count)))
+	}
+	return
+}
+
+// dropCommitted drops the layers committed to the store from the live tree of the view.
+func (b *BTree) dropCommitted(seq uint64) {
+	owner := b.store.owner
+	if owner == nil || seq == 0 {
+		return
+	}
+	owner.Lock()
+	s := owner.store
+	layers := s.layers[:0]
+	for _, l := range s.layers {
+		if l.seq > seq {
+			layers = append(layers, l)
+		}
+	}
+	for i := len(layers); i < len(s.layers); i++ {
+		s.layers[i] = nil
+	}
+	s.layers = layers
+	owner.Unlock()
+}
+
+// overlay returns the items in [start, end) over the store, which are the items in the cache
+// and the layers, and tells whether the items in the store are hidden by a reset.
+func (b *BTree) overlay(start, end BtreeItem, startKey, endKey []byte) (items []*kvItem, hideKV bool) {
+	s := b.store
+	merged := btree.New(defaultBTreeDegree)
+	iter := func(i BtreeItem) bool {
+		merged.ReplaceOrInsert(&kvItem{key: s.codec.key(i), item: i})
+		return true
+	}
+	switch {
+	case start == nil && end == nil:
+		b.tree.Ascend(iter)
+	case end == nil:
+		b.tree.AscendGreaterOrEqual(start, iter)
+	case start == nil:
+		b.tree.AscendLessThan(end, iter)
+	default:
+		b.tree.AscendRange(start, end, iter)
+	}
+	hideKV = s.cleared
+	for i := len(s.layers) - 1; i >= 0 && !hideKV; i-- {
+		ascendKVItems(s.layers[i].items, startKey, endKey, func(it *kvItem) bool {
+			if _, ok := s.deleted[string(it.key)]; !ok && !merged.Has(it) {
+				merged.ReplaceOrInsert(it)
+			}
+			return true
+		})
+		hideKV = s.layers[i].cleared
+	}
+	items = make([]*kvItem, 0, merged.Len())
+	merged.Ascend(func(i btree.Item) bool {
+		items = append(items, i.(*kvItem))
+		return true
+	})
+	return
+}
+
+// ascendStore calls fn on the items in [start, end) of the view in order.
+func (b *BTree) ascendStore(start, end BtreeItem, fn func(i BtreeItem) bool) {
+	s := b.store
+	b.ascendMerged(start, end, func(it *kvItem) bool {
+		if it.item != nil {
+			return fn(it.item)
+		}
+		return fn(s.decode(it.value))
+	})
+}
+
+// ascendKV calls fn on the keys and the encoded values of the items of the view in order, the
+// items in the store are passed as they are stored.
+func (b *BTree) ascendKV(fn func(key, value []byte) bool) {
+	s := b.store
+	b.ascendMerged(nil, nil, func(it *kvItem) bool {
+		if it.item == nil {
+			return fn(it.key, it.value)
+		}
+		value, err := s.codec.encode(it.item)
+		if err != nil {
+			s.fatalf("encode %x: %v", it.key, err)
+		}
+		return fn(it.key, value)
+	})
+}
+
+// ascendMerged calls fn on the items in [start, end) of the view in order, which are the items
+// of the cache, or the encoded ones of the layers and the store.
+func (b *BTree) ascendMerged(start, end BtreeItem, fn func(it *kvItem) bool) {
+	s := b.store
+	var startKey, endKey []byte
+	if start != nil {
+		startKey = s.codec.key(start)
+	}
+	if end != nil {
+		endKey = s.codec.key(end)
+	}
+	b.RLock()
+	overlay, hideKV := b.overlay(start, end, startKey, endKey)
+	b.RUnlock()
+
+	emit := func(it *kvItem) bool {
+		if it.item == nil && it.value == nil {
+			return true
+		}
+		return fn(it)
+	}
+	if !hideKV {
+		rangeEnd := kvPrefixEnd(s.prefix)
+		if endKey != nil {
+			rangeEnd = kvKey(s.prefix, endKey)
+		}
+		stopped := false
+		err := s.kv.Range(s.kvSnap(), kvKey(s.prefix, startKey), rangeEnd, func(k, v []byte) bool {
+			key := k[len(s.prefix):]
+			for len(overlay) > 0 && bytes.Compare(overlay[0].key, key) < 0 {
+				if !emit(overlay[0]) {
+					stopped = true
+					return false
+				}
+				overlay = overlay[1:]
+			}
+			if len(overlay) > 0 && bytes.Equal(overlay[0].key, key) {
+				it := overlay[0]
+				overlay = overlay[1:]
+				stopped = !emit(it)
+				return !stopped
+			}
+			if _, ok := s.deleted[string(key)]; ok {
+				return true
+			}
+			stopped = !fn(&kvItem{key: key, value: v})
+			return !stopped
+		})
+		if err != nil {
+			s.fatalf("range: %v", err)
+		}
+		if stopped {
+			return
+		}
+	}
+	for _, it := range overlay {
+		if !emit(it) {
+			return
+		}
+	}
+}
@@ -0,0 +1,395 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/stretchr/testify/require"
+)
+
+// memKVStore is the KVStore in memory for tests.
+type memKVStore struct {
+	sync.RWMutex
+	data  map[string][]byte
+	snaps int32 // the snapshots not released
+}
+
+type memKVSnapshot struct {
+	data  map[string][]byte
+	store *memKVStore
+}
+
+func (s *memKVSnapshot) Release() {
+	atomic.AddInt32(&s.store.snaps, -1)
+}
+
+func newMemKVStore() *memKVStore {
+	return &memKVStore{data: make(map[string][]byte)}
+}
+
+func (m *memKVStore) source(snap KVSnapshot) map[string][]byte {
+	if snap != nil {
+		return snap.(*memKVSnapshot).data
+	}
+	return m.data
+}
+
+func (m *memKVStore) Get(snap KVSnapshot, key []byte) ([]byte, error) {
+	m.RLock()
+	defer m.RUnlock()
+	if value, ok := m.source(snap)[string(key)]; ok {
+		return append([]byte(nil), value...), nil
+	}
+	return nil, nil
+}
+
+func (m *memKVStore) Range(snap KVSnapshot, start, end []byte, fn func(key, value []byte) bool) error {
+	m.RLock()
+	data := m.source(snap)
+	keys := make([]string, 0)
+	for k := range data {
+		if bytes.Compare([]byte(k), start) >= 0 && (end == nil || bytes.Compare([]byte(k), end) < 0) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	values := make([][]byte, len(keys))
+	for i, k := range keys {
+		values[i] = append([]byte(nil), data[k]...)
+	}
+	m.RUnlock()
+	for i, k := range keys {
+		if !fn([]byte(k), values[i]) {
+			break
+		}
+	}
+	return nil
+}
+
+func (m *memKVStore) Write(batch *KVBatch, sync bool) error {
+	m.Lock()
+	defer m.Unlock()
+	for _, op := range batch.Ops {
+		switch op.Type {
+		case KVOpPut:
+			m.data[string(op.Key)] = append([]byte(nil), op.Value...)
+		case KVOpDelete:
+			delete(m.data, string(op.Key))
+		case KVOpDeleteRange:
+			for k := range m.data {
+				if bytes.Compare([]byte(k), op.Key) >= 0 && bytes.Compare([]byte(k), op.Value) < 0 {
+					delete(m.data, k)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (m *memKVStore) NewSnapshot() KVSnapshot {
+	m.RLock()
+	defer m.RUnlock()
+	data := make(map[string][]byte, len(m.data))
+	for k, v := range m.data {
+		data[k] = v
+	}
+	atomic.AddInt32(&m.snaps, 1)
+	return &memKVSnapshot{data: data, store: m}
+}
+
+func (m *memKVStore) Close() error {
+	return nil
+}
+
+func storeTreeInodes(tree *BTree) (inodes []uint64) {
+	tree.Ascend(func(i BtreeItem) bool {
+		inodes = append(inodes, i.(*Inode).Inode)
+		return true
+	})
+	return
+}
+
+// commitStoreTree commits the changes of the tree as the store goroutine does.
+func commitStoreTree(t *testing.T, kv KVStore, tree *BTree) {
+	view := tree.GetTreeToStore()
+	batch := &KVBatch{}
+	seq := view.commitTo(batch)
+	require.NoError(t, kv.Write(batch, true))
+	view.dropCommitted(seq)
+	view.Release()
+}
+
+func TestStoreBtree(t *testing.T) {
+	kv := newMemKVStore()
+	tree, err := newStoreBtree(kv, 1, 0, inodeCodec)
+	require.NoError(t, err)
+
+	for ino := uint64(1); ino <= 10; ino++ {
+		_, ok := tree.ReplaceOrInsert(NewInode(ino, FileModeType), false)
+		require.True(t, ok)
+	}
+	commitStoreTree(t, kv, tree)
+	require.Equal(t, 10, tree.Len())
+	require.Equal(t, 0, tree.tree.Len())
+
+	// the items of the store are merged with the changes in the cache and the layers
+	tree.Delete(NewInode(3, 0))
+	tree.ReplaceOrInsert(NewInode(11, 0), false)
+	tree.GetTreeToStore()
+	tree.Delete(NewInode(5, 0))
+	ino := tree.Get(NewInode(7, 0)).(*Inode)
+	ino.Size = 100
+	require.Equal(t, 9, tree.Len())
+	require.Equal(t, []uint64{1, 2, 4, 6, 7, 8, 9, 10, 11}, storeTreeInodes(tree))
+	require.Nil(t, tree.Get(NewInode(3, 0)))
+	require.False(t, tree.Has(NewInode(5, 0)))
+
+	var ranged []uint64
+	tree.AscendRange(NewInode(2, 0), NewInode(8, 0), func(i BtreeItem) bool {
+		ranged = append(ranged, i.(*Inode).Inode)
+		return true
+	})
+	require.Equal(t, []uint64{2, 4, 6, 7}, ranged)
+
+	// the view is not affected by the later changes
+	view := tree.GetTree()
+	tree.Delete(NewInode(1, 0))
+	require.Equal(t, 9, view.Len())
+	require.Equal(t, []uint64{1, 2, 4, 6, 7, 8, 9, 10, 11}, storeTreeInodes(view))
+
+	commitStoreTree(t, kv, tree)
+	require.Empty(t, tree.store.layers)
+
+	// reopen the tree from the store
+	tree, err = newStoreBtree(kv, 1, 0, inodeCodec)
+	require.NoError(t, err)
+	require.Equal(t, 8, tree.Len())
+	require.Equal(t, []uint64{2, 4, 6, 7, 8, 9, 10, 11}, storeTreeInodes(tree))
+	require.Equal(t, uint64(100), tree.Get(NewInode(7, 0)).(*Inode).Size)
+
+	// the trees of other partitions are not affected
+	other, err := newStoreBtree(kv, 2, 0, inodeCodec)
+	require.NoError(t, err)
+	require.Equal(t, 0, other.Len())
+	require.Empty(t, storeTreeInodes(other))
+}
+
+func TestStoreBtreeReleaseSnapshot(t *testing.T) {
+	kv := newMemKVStore()
+	tree, err := newStoreBtree(kv, 1, 0, inodeCodec)
+	require.NoError(t, err)
+	for ino := uint64(1); ino <= 3; ino++ {
+		tree.ReplaceOrInsert(NewInode(ino, FileModeType), false)
+	}
+	commitStoreTree(t, kv, tree)
+	require.Equal(t, []uint64{1, 2, 3}, storeTreeInodes(tree))
+	require.Equal(t, int32(0), atomic.LoadInt32(&kv.snaps))
+
+	// the views of a view share its snapshot, which is released with the last of them
+	view := tree.GetTree()
+	sub := view.GetTree()
+	require.Equal(t, int32(1), atomic.LoadInt32(&kv.snaps))
+	view.Release()
+	require.Equal(t, []uint64{1, 2, 3}, storeTreeInodes(sub))
+	require.Equal(t, int32(1), atomic.LoadInt32(&kv.snaps))
+	sub.Release()
+	sub.Release()
+	require.Equal(t, int32(0), atomic.LoadInt32(&kv.snaps))
+
+	// the trees in memory have nothing to release
+	NewBtree().GetTree().Release()
+}
+
+func TestStoreBtreeReset(t *testing.T) {
+	kv := newMemKVStore()
+	tree, err := newStoreBtree(kv, 1, 0, dentryCodec)
+	require.NoError(t, err)
+	for _, name := range []string{"a", "b", "c"} {
+		tree.ReplaceOrInsert(&Dentry{ParentId: 1, Name: name, Inode: 2}, false)
+	}
+	commitStoreTree(t, kv, tree)
+
+	// the items in the store are hidden once reset, and dropped by the next commit
+	tree.Reset()
+	tree.ReplaceOrInsert(&Dentry{ParentId: 1, Name: "d", Inode: 3}, false)
+	require.Equal(t, 1, tree.Len())
+	require.Nil(t, tree.Get(&Dentry{ParentId: 1, Name: "a"}))
+	tree.GetTreeToStore()
+	require.Equal(t, 1, tree.GetTree().Len())
+
+	commitStoreTree(t, kv, tree)
+	tree, err = newStoreBtree(kv, 1, 0, dentryCodec)
+	require.NoError(t, err)
+	require.Equal(t, 1, tree.Len())
+	var names []string
+	tree.Ascend(func(i BtreeItem) bool {
+		names = append(names, i.(*Dentry).Name)
+		return true
+	})
+	require.Equal(t, []string{"d"}, names)
+}
+
+func TestMetaPartitionCommitStore(t *testing.T) {
+	mp := NewMetaPartitionForTest()
+	mp.kvStore = newMemKVStore()
+	mp.config.StoreMode = proto.StoreModeRocksDB
+	require.NoError(t, mp.openStore())
+
+	mp.inodeTree.ReplaceOrInsert(NewInode(1, 0), false)
+	mp.dentryTree.ReplaceOrInsert(&Dentry{ParentId: 1, Name: "a", Inode: 2}, false)
+
+	tmpDir, err := os.MkdirTemp("", "commit_store")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	require.NoError(t, os.WriteFile(path.Join(tmpDir, applyIDFile), []byte("10|10"), 0o644))
+
+	sm := &storeMsg{
+		applyIndex:    10,
+		inodeTree:     mp.inodeTree.GetTreeToStore(),
+		dentryTree:    mp.dentryTree.GetTreeToStore(),
+		extendTree:    mp.extendTree.GetTreeToStore(),
+		multipartTree: mp.multipartTree.GetTreeToStore(),
+		dirStatTree:   mp.dirStatTree.GetTreeToStore(),
+	}
+	require.NoError(t, mp.commitStore(tmpDir, sm))
+
+	// the snapshot files are restored along with the trees
+	snapshotDir := path.Join(tmpDir, "snapshot")
+	require.NoError(t, mp.restoreSnapshotFiles(snapshotDir))
+	data, err := os.ReadFile(path.Join(snapshotDir, applyIDFile))
+	require.NoError(t, err)
+	require.Equal(t, "10|10", string(data))
+
+	require.NoError(t, mp.openStore())
+	require.Equal(t, 1, mp.inodeTree.Len())
+	require.Equal(t, 1, mp.dentryTree.Len())
+
+	require.NoError(t, mp.dropStore())
+	require.NoError(t, mp.openStore())
+	require.Equal(t, 0, mp.inodeTree.Len())
+	require.Equal(t, 0, mp.dentryTree.Len())
+}
+
+func TestStoreBtreeAscendKV(t *testing.T) {
+	kv := newMemKVStore()
+	tree, err := newStoreBtree(kv, 1, 0, inodeCodec)
+	require.NoError(t, err)
+	for ino := uint64(1); ino <= 5; ino++ {
+		tree.ReplaceOrInsert(NewInode(ino, FileModeType), false)
+	}
+	commitStoreTree(t, kv, tree)
+	tree.Delete(NewInode(2, 0))
+	tree.GetTreeToStore()
+	tree.Get(NewInode(4, 0)).(*Inode).Size = 100
+	tree.ReplaceOrInsert(NewInode(6, 0), false)
+
+	// the key-values merge the store with the layers and the cache
+	view := tree.GetTree()
+	defer view.Release()
+	var inodes []uint64
+	view.ascendKV(func(key, value []byte) bool {
+		ino := tree.store.decode(value).(*Inode)
+		require.Equal(t, uint64Key(ino.Inode), key)
+		if ino.Inode == 4 {
+			require.Equal(t, uint64(100), ino.Size)
+		}
+		inodes = append(inodes, ino.Inode)
+		return true
+	})
+	require.Equal(t, []uint64{1, 3, 4, 5, 6}, inodes)
+}
+
+func TestMetaPartitionKVIngest(t *testing.T) {
+	leader := newMemKVStore()
+	tree, err := newStoreBtree(leader, 1, 0, inodeCodec)
+	require.NoError(t, err)
+	for ino := uint64(1); ino <= 3; ino++ {
+		tree.ReplaceOrInsert(NewInode(ino, FileModeType), false)
+	}
+	commitStoreTree(t, leader, tree)
+	tree.ReplaceOrInsert(NewInode(4, FileModeType), false)
+
+	mp := NewMetaPartitionForTest()
+	mp.kvStore = newMemKVStore()
+	mp.config.StoreMode = proto.StoreModeRocksDB
+	require.NoError(t, mp.openStore())
+	mp.inodeTree.ReplaceOrInsert(NewInode(10, 0), false)
+	mp.dentryTree.ReplaceOrInsert(&Dentry{ParentId: 1, Name: "a", Inode: 10}, false)
+	tmpDir, err := os.MkdirTemp("", "kv_ingest")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	commit := func() {
+		require.NoError(t, mp.commitStore(tmpDir, &storeMsg{
+			inodeTree:     mp.inodeTree.GetTreeToStore(),
+			dentryTree:    mp.dentryTree.GetTreeToStore(),
+			extendTree:    mp.extendTree.GetTreeToStore(),
+			multipartTree: mp.multipartTree.GetTreeToStore(),
+			dirStatTree:   mp.dirStatTree.GetTreeToStore(),
+		}))
+	}
+	commit()
+
+	// the snapshot failed to apply is dropped when the partition is opened again
+	ingest, err := mp.newKVIngest()
+	require.NoError(t, err)
+	require.NoError(t, ingest.put(kvTableInode, uint64Key(20), []byte("partial")))
+	require.NoError(t, ingest.flush(true))
+	require.NoError(t, mp.openStore())
+	require.Equal(t, uint64(0), mp.kvGen)
+	require.Equal(t, []uint64{10}, storeTreeInodes(mp.inodeTree))
+	uncommitted := kvTreePrefix(mp.config.PartitionId, kvTableInode, 1)
+	require.NoError(t, mp.kvStore.Range(nil, uncommitted, kvPrefixEnd(uncommitted),
+		func(key, value []byte) bool {
+			t.Fatalf("key %x of the uncommitted generation", key)
+			return false
+		}))
+
+	// the key-values of the leader are written into the next generation
+	ingest, err = mp.newKVIngest()
+	require.NoError(t, err)
+	view := tree.GetTree()
+	view.ascendKV(func(key, value []byte) bool {
+		require.NoError(t, ingest.put(kvTableInode, key, value))
+		return true
+	})
+	view.Release()
+	trees, err := ingest.finish()
+	require.NoError(t, err)
+	require.Equal(t, []uint64{1, 2, 3, 4}, storeTreeInodes(trees[0]))
+	require.Equal(t, 4, trees[0].Len())
+	require.Equal(t, 0, trees[1].Len())
+
+	// the generation is switched to once committed, and the former one is dropped
+	mp.inodeTree, mp.dentryTree, mp.extendTree, mp.multipartTree = trees[0], trees[1], trees[2], trees[3]
+	commit()
+	require.Equal(t, uint64(1), mp.kvGen)
+	require.NoError(t, mp.openStore())
+	require.Equal(t, uint64(1), mp.kvGen)
+	require.Equal(t, []uint64{1, 2, 3, 4}, storeTreeInodes(mp.inodeTree))
+	require.Equal(t, 0, mp.dentryTree.Len())
+	prefix := kvTablePrefix(mp.config.PartitionId, kvTableDentry)
+	require.NoError(t, mp.kvStore.Range(nil, prefix, kvPrefixEnd(prefix), func(key, value []byte) bool {
+		t.Fatalf("key %x of the former generation", key)
+		return false
+	}))
+}
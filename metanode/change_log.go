@@ -0,0 +1,404 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// The changes of the namespace applied by the partition are kept in the change log for the
+// change feed of the volume. The changes are recorded by the fsm ops while applying, including
+// the ones made by the transactions and their rollbacks, and addressed by the raft index of the
+// apply, so the consumer can read them from any replica and resume from the last one it read.
+//
+// The log is kept on disk by the partition, see changeLogStore, and the latest changes are cached
+// in memory. The changes replayed from the raft log after restart are skipped if they are on disk.
+// The changes are lost if they are dropped by the retention, or by installing a snapshot from the
+// leader. Reading from the index before the oldest change kept gets the expired reply, and every
+// reply carries the index the changes are kept after, so the consumer checks it against its cursor
+// and reports the lost changes as a gap.
+
+// the changes cached in memory by each partition, 0 to disable the change log
+var changeLogCapacity int
+
+const (
+	changesMaxWait       = 30 * time.Second
+	changesPacketMaxWait = 3 * time.Second // within the read deadline of the client
+)
+
+type changeLog struct {
+	sync.Mutex
+	capacity int
+	started  bool
+	first    uint64 // the changes applied after it are kept
+	cached   uint64 // the changes applied after it are cached in memory
+	last     uint64 // the last apply recorded
+	events   []*proto.ChangeEvent
+	notify   chan struct{}
+	store    *changeLogStore // nil if the log is only kept in memory
+
+	// the apply being recorded, only touched by the apply goroutine
+	applying bool
+	index    uint64
+	time     int64
+	added    bool
+	pending  []*proto.ChangeEvent // the changes of the apply to be stored
+	tx       *proto.TransactionInfo
+}
+
+func newChangeLog(capacity int) *changeLog {
+	if capacity <= 0 {
+		return nil
+	}
+	return &changeLog{capacity: capacity, notify: make(chan struct{})}
+}
+
+// open loads the log kept in the dir by the partition loaded to the index applied. The log is
+// dropped if it ends before the index, as the changes in between are unknown.
+func (c *changeLog) open(dir string, applied uint64) (err error) {
+	if c == nil {
+		return
+	}
+	var store *changeLogStore
+	if store, err = openChangeLogStore(dir); err != nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.store = store
+	if store.empty() {
+		return
+	}
+	if store.last < applied {
+		log.LogWarnf("changeLog: dir(%v) ends at %v before the applied %v, drop it", dir, store.last, applied)
+		return store.reset()
+	}
+	c.started = true
+	c.first = store.first()
+	c.cached = store.last
+	c.last = store.last
+	return
+}
+
+// begin starts recording the changes of the apply at the index of the op submitted at the time,
+// so the changes are the same on all the replicas. The changes are kept only from the first apply
+// recorded, as the ones before are unknown.
+func (c *changeLog) begin(index uint64, opTime int64) {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	if !c.started {
+		c.started = true
+		c.first = index - 1
+		c.cached = index - 1
+	}
+	// replayed by raft after restart, and kept on disk
+	replayed := index <= c.last
+	if !replayed {
+		c.last = index
+	}
+	c.Unlock()
+	c.applying = !replayed
+	c.index = index
+	c.time = opTime
+	c.added = false
+	c.pending = nil
+	c.tx = nil
+}
+
+func (c *changeLog) end() {
+	if c == nil {
+		return
+	}
+	c.applying = false
+	c.tx = nil
+	if !c.added {
+		return
+	}
+	c.Lock()
+	if c.store != nil {
+		if err := c.store.append(&changeRecord{Index: c.index, Events: c.pending}, c.first); err != nil {
+			c.dropStore(err)
+		}
+	}
+	c.pending = nil
+	close(c.notify)
+	c.notify = make(chan struct{})
+	c.Unlock()
+}
+
+// dropStore keeps the log only in memory if it fails to be stored, with the lock held.
+func (c *changeLog) dropStore(err error) {
+	log.LogErrorf("changeLog: store in dir(%v) fail, keep the changes in memory only: %v", c.store.dir, err)
+	_ = c.store.reset()
+	c.store = nil
+	c.first = c.cached
+}
+
+// sync persists the log up to the index applied, it's called before the snapshot is stored.
+func (c *changeLog) sync(applied uint64) {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	if c.store == nil {
+		return
+	}
+	if err := c.store.sync(applied); err != nil {
+		c.dropStore(err)
+		return
+	}
+	if !c.store.empty() {
+		c.first = c.store.first()
+	}
+}
+
+func (c *changeLog) close() {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	if c.store != nil {
+		if err := c.store.closeFile(); err != nil {
+			log.LogErrorf("changeLog: close dir(%v) fail: %v", c.store.dir, err)
+		}
+	}
+}
+
+// inTx marks the changes after it in the apply as made by the transaction.
+func (c *changeLog) inTx(tx *proto.TransactionInfo) {
+	if c == nil || !c.applying {
+		return
+	}
+	c.tx = tx
+}
+
+func (c *changeLog) add(event *proto.ChangeEvent) {
+	if c == nil || !c.applying {
+		return
+	}
+	event.Index = c.index
+	event.Time = c.time
+	if c.tx != nil {
+		event.TxID = c.tx.TxID
+		if c.tx.TxType == proto.TxTypeRename {
+			switch event.Type {
+			case proto.ChangeCreate:
+				event.Type = proto.ChangeRenameTo
+			case proto.ChangeUnlink:
+				event.Type = proto.ChangeRenameFrom
+			}
+		}
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.events = append(c.events, event)
+	c.pending = append(c.pending, event)
+	c.added = true
+	if len(c.events) > c.capacity {
+		c.evict(len(c.events) - c.capacity + c.capacity/4)
+	}
+}
+
+// evict drops the oldest n changes from memory, together with the rest of the changes of the
+// last apply dropped.
+func (c *changeLog) evict(n int) {
+	if n >= len(c.events) {
+		n = len(c.events)
+	}
+	c.cached = c.events[n-1].Index
+	if c.store == nil {
+		c.first = c.cached
+	}
+	for n < len(c.events) && c.events[n].Index == c.cached {
+		n++
+	}
+	c.events = append(make([]*proto.ChangeEvent, 0, c.capacity), c.events[n:]...)
+}
+
+// reset drops all the changes, as the ones between the last change and the snapshot installed
+// are unknown.
+func (c *changeLog) reset() {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	c.started = false
+	c.last = 0
+	c.events = nil
+	if c.store != nil {
+		if err := c.store.reset(); err != nil {
+			c.dropStore(err)
+		}
+	}
+	c.Unlock()
+}
+
+// read returns the changes after the index from, at least the limit unless the changes of the
+// last apply exceed it. The read from 0 starts from the oldest change kept. The changes not cached
+// in memory are read from disk.
+func (c *changeLog) read(from uint64, limit int) (resp *proto.GetChangesResponse, err error) {
+	c.Lock()
+	resp = &proto.GetChangesResponse{First: c.first}
+	if from < c.first && from != 0 {
+		resp.Expired = true
+	}
+	if from < c.first {
+		from = c.first
+	}
+	if c.store != nil && from < c.cached {
+		if err = c.store.flush(); err != nil {
+			c.dropStore(err)
+			c.Unlock()
+			return c.read(from, limit)
+		}
+		dir, segments := c.store.dir, c.store.locate(from)
+		c.Unlock()
+		resp.Events, err = readChangeSegments(dir, segments, from, limit)
+		return
+	}
+	defer c.Unlock()
+	start := sort.Search(len(c.events), func(i int) bool {
+		return c.events[i].Index > from
+	})
+	end := start + limit
+	if end >= len(c.events) {
+		end = len(c.events)
+	} else {
+		for end < len(c.events) && c.events[end].Index == c.events[end-1].Index {
+			end++
+		}
+	}
+	resp.Events = make([]*proto.ChangeEvent, end-start)
+	copy(resp.Events, c.events[start:end])
+	return
+}
+
+// wait returns when there are changes after the index from, or the timeout expires.
+func (c *changeLog) wait(from uint64, timeout time.Duration, stopC chan bool) {
+	c.Lock()
+	if n := len(c.events); n > 0 && c.events[n-1].Index > from {
+		c.Unlock()
+		return
+	}
+	notify := c.notify
+	c.Unlock()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-notify:
+	case <-timer.C:
+	case <-stopC:
+	}
+}
+
+func (mp *metaPartition) recordDentryChange(typ string, den *Dentry, oldIno uint64) {
+	mp.changes.add(&proto.ChangeEvent{
+		PartitionID: mp.config.PartitionId,
+		Type:        typ,
+		Inode:       den.Inode,
+		ParentID:    den.ParentId,
+		Name:        den.Name,
+		Mode:        den.Type,
+		OldInode:    oldIno,
+	})
+}
+
+func (mp *metaPartition) recordInodeChange(typ string, ino *Inode) {
+	mp.changes.add(&proto.ChangeEvent{
+		PartitionID: mp.config.PartitionId,
+		Type:        typ,
+		Inode:       ino.Inode,
+		Mode:        ino.Type,
+		Size:        ino.Size,
+	})
+}
+
+func (mp *metaPartition) recordSetAttrChange(req *SetattrRequest) {
+	mp.changes.add(&proto.ChangeEvent{
+		PartitionID: mp.config.PartitionId,
+		Type:        proto.ChangeSetAttr,
+		Inode:       req.Inode,
+		Mode:        req.Mode,
+		Valid:       req.Valid,
+	})
+}
+
+func (mp *metaPartition) recordXAttrChange(extend *Extend) {
+	var keys []string
+	extend.Range(func(key, value []byte) bool {
+		keys = append(keys, string(key))
+		return true
+	})
+	mp.changes.add(&proto.ChangeEvent{
+		PartitionID: mp.config.PartitionId,
+		Type:        proto.ChangeXAttr,
+		Inode:       extend.inode,
+		Keys:        keys,
+	})
+}
+
+// GetChanges reads the changes of the partition, waiting for the new ones if there are none.
+func (mp *metaPartition) GetChanges(req *proto.GetChangesRequest, p *Packet) (err error) {
+	resp, err := mp.ReadChanges(req, changesPacketMaxWait)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpNotPerm, []byte(err.Error()))
+		return
+	}
+	reply, err := json.Marshal(resp)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(reply)
+	return
+}
+
+// ReadChanges reads the changes of the partition, waiting at most maxWait for the new ones.
+func (mp *metaPartition) ReadChanges(req *proto.GetChangesRequest, maxWait time.Duration) (resp *proto.GetChangesResponse, err error) {
+	if mp.changes == nil {
+		return nil, ErrChangeLogDisabled
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = proto.DefaultChangesLimit
+	} else if limit > proto.MaxChangesLimit {
+		limit = proto.MaxChangesLimit
+	}
+	if resp, err = mp.changes.read(req.From, limit); err != nil {
+		return
+	}
+	if len(resp.Events) == 0 && !resp.Expired && req.Wait > 0 {
+		wait := time.Duration(req.Wait) * time.Millisecond
+		if wait > maxWait {
+			wait = maxWait
+		}
+		mp.changes.wait(req.From, wait, mp.stopC)
+		if resp, err = mp.changes.read(req.From, limit); err != nil {
+			return
+		}
+	}
+	resp.Applied = mp.getApplyID()
+	return
+}
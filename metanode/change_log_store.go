@@ -0,0 +1,319 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util"
+)
+
+// The changes are appended to the segment files under the change log dir of the partition, one
+// line of json for the changes of each apply, so the line torn by a crash is dropped as a whole.
+// A segment is named by the raft index the changes in it are applied after. The log is synced
+// with a record of the apply index before the snapshot is stored, so the changes of the applies
+// in the snapshot are never lost, and the ones after are recorded again by replaying the raft log.
+// The oldest segments are dropped when they are older than the retention, or the segments exceed
+// the max size.
+
+const (
+	changeLogDir        = "changelog"
+	changeSegmentSuffix = ".log"
+)
+
+var (
+	changeLogRetention   = 24 * time.Hour
+	changeLogMaxSize     = int64(util.GB)
+	changeSegmentMaxSize = int64(64 * util.MB)
+)
+
+// changeRecord is a line of the segment, the record without changes marks the index the log is
+// synced to.
+type changeRecord struct {
+	Index  uint64               `json:"index"`
+	Events []*proto.ChangeEvent `json:"events,omitempty"`
+}
+
+type changeSegment struct {
+	first   uint64 // the changes in the segment are applied after it
+	size    int64
+	modTime time.Time
+}
+
+func (s *changeSegment) name() string {
+	return fmt.Sprintf("%020d%s", s.first, changeSegmentSuffix)
+}
+
+type changeLogStore struct {
+	dir      string
+	segments []*changeSegment
+	last     uint64 // the index of the last record
+	file     *os.File
+	writer   *bufio.Writer
+}
+
+// openChangeLogStore loads the segments in the dir, the torn record at the end of the last segment
+// is truncated.
+func openChangeLogStore(dir string) (s *changeLogStore, err error) {
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return
+	}
+	var entries []os.DirEntry
+	if entries, err = os.ReadDir(dir); err != nil {
+		return
+	}
+	s = &changeLogStore{dir: dir}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, changeSegmentSuffix) {
+			continue
+		}
+		var first uint64
+		if first, err = strconv.ParseUint(strings.TrimSuffix(name, changeSegmentSuffix), 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid change log segment %v", name)
+		}
+		var info os.FileInfo
+		if info, err = entry.Info(); err != nil {
+			return
+		}
+		s.segments = append(s.segments, &changeSegment{first: first, size: info.Size(), modTime: info.ModTime()})
+	}
+	sort.Slice(s.segments, func(i, j int) bool { return s.segments[i].first < s.segments[j].first })
+	if len(s.segments) == 0 {
+		return
+	}
+	if err = s.openLast(); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// openLast opens the last segment for appending, and finds the last record in it.
+func (s *changeLogStore) openLast() (err error) {
+	seg := s.segments[len(s.segments)-1]
+	if s.file, err = os.OpenFile(path.Join(s.dir, seg.name()), os.O_RDWR, 0o644); err != nil {
+		return
+	}
+	s.last = seg.first
+	var valid int64
+	reader := bufio.NewReader(s.file)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil {
+			break
+		}
+		record := &changeRecord{}
+		if json.Unmarshal(line, record) != nil {
+			break
+		}
+		valid += int64(len(line))
+		if record.Index > s.last {
+			s.last = record.Index
+		}
+	}
+	if valid < seg.size {
+		if err = s.file.Truncate(valid); err != nil {
+			return
+		}
+		seg.size = valid
+	}
+	if _, err = s.file.Seek(valid, io.SeekStart); err != nil {
+		return
+	}
+	s.writer = bufio.NewWriter(s.file)
+	return
+}
+
+func (s *changeLogStore) empty() bool {
+	return len(s.segments) == 0
+}
+
+func (s *changeLogStore) first() uint64 {
+	return s.segments[0].first
+}
+
+// append writes the record, a new segment is started after the index after if there is none.
+func (s *changeLogStore) append(record *changeRecord, after uint64) (err error) {
+	if s.file == nil {
+		if len(s.segments) > 0 {
+			after = s.last
+		}
+		seg := &changeSegment{first: after}
+		if s.file, err = os.OpenFile(path.Join(s.dir, seg.name()), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644); err != nil {
+			return
+		}
+		s.writer = bufio.NewWriter(s.file)
+		s.segments = append(s.segments, seg)
+		s.last = after
+	}
+	var data []byte
+	if data, err = json.Marshal(record); err != nil {
+		return
+	}
+	data = append(data, '\n')
+	if _, err = s.writer.Write(data); err != nil {
+		return
+	}
+	seg := s.segments[len(s.segments)-1]
+	seg.size += int64(len(data))
+	seg.modTime = time.Now()
+	s.last = record.Index
+	if seg.size >= changeSegmentMaxSize {
+		err = s.closeFile()
+	}
+	return
+}
+
+func (s *changeLogStore) flush() error {
+	if s.writer == nil {
+		return nil
+	}
+	return s.writer.Flush()
+}
+
+// sync persists the log and marks it complete to the index applied, the old segments are dropped
+// by the retention.
+func (s *changeLogStore) sync(applied uint64) (err error) {
+	if applied > s.last && len(s.segments) > 0 {
+		if err = s.append(&changeRecord{Index: applied}, s.last); err != nil {
+			return
+		}
+	}
+	if err = s.flush(); err != nil {
+		return
+	}
+	if s.file != nil {
+		if err = s.file.Sync(); err != nil {
+			return
+		}
+	}
+	return s.retain()
+}
+
+// retain drops the oldest segments beyond the retention, the last segment is always kept.
+func (s *changeLogStore) retain() (err error) {
+	var total int64
+	for _, seg := range s.segments {
+		total += seg.size
+	}
+	expire := time.Now().Add(-changeLogRetention)
+	for len(s.segments) > 1 {
+		seg := s.segments[0]
+		if total <= changeLogMaxSize && seg.modTime.After(expire) {
+			break
+		}
+		if err = os.Remove(path.Join(s.dir, seg.name())); err != nil && !os.IsNotExist(err) {
+			return
+		}
+		total -= seg.size
+		s.segments = s.segments[1:]
+	}
+	return nil
+}
+
+func (s *changeLogStore) closeFile() (err error) {
+	if s.file == nil {
+		return
+	}
+	if err = s.writer.Flush(); err == nil {
+		err = s.file.Sync()
+	}
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	s.file, s.writer = nil, nil
+	return
+}
+
+// reset drops all the segments.
+func (s *changeLogStore) reset() (err error) {
+	_ = s.closeFile()
+	for _, seg := range s.segments {
+		if err = os.Remove(path.Join(s.dir, seg.name())); err != nil && !os.IsNotExist(err) {
+			return
+		}
+	}
+	s.segments = nil
+	s.last = 0
+	return nil
+}
+
+// locate returns the segments holding the changes after the index from.
+func (s *changeLogStore) locate(from uint64) []*changeSegment {
+	i := sort.Search(len(s.segments), func(i int) bool {
+		return s.segments[i].first > from
+	})
+	if i > 0 {
+		i--
+	}
+	segments := make([]*changeSegment, len(s.segments)-i)
+	copy(segments, s.segments[i:])
+	return segments
+}
+
+// readChangeSegments reads the changes after the index from in the segments, at least the limit
+// unless the changes of the last apply exceed it. The segment dropped by the retention while
+// reading ends the read.
+func readChangeSegments(dir string, segments []*changeSegment, from uint64, limit int) (events []*proto.ChangeEvent, err error) {
+	for _, seg := range segments {
+		if err = readChangeSegment(path.Join(dir, seg.name()), from, limit, &events); err != nil {
+			if os.IsNotExist(err) {
+				err = nil
+			}
+			return
+		}
+		if len(events) >= limit {
+			return
+		}
+	}
+	return
+}
+
+func readChangeSegment(name string, from uint64, limit int, events *[]*proto.ChangeEvent) (err error) {
+	var file *os.File
+	if file, err = os.Open(name); err != nil {
+		return
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	for len(*events) < limit {
+		var line []byte
+		if line, err = reader.ReadBytes('\n'); err != nil {
+			// the partial line is being appended
+			if err == io.EOF {
+				err = nil
+			}
+			return
+		}
+		record := &changeRecord{}
+		if err = json.Unmarshal(line, record); err != nil {
+			return
+		}
+		if record.Index > from {
+			*events = append(*events, record.Events...)
+		}
+	}
+	return
+}
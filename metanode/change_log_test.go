@@ -0,0 +1,227 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func readChanges(t *testing.T, c *changeLog, from uint64, limit int) *proto.GetChangesResponse {
+	resp, err := c.read(from, limit)
+	require.NoError(t, err)
+	return resp
+}
+
+func TestChangeLogRecord(t *testing.T) {
+	mp := NewMetaPartitionForTest()
+	mp.changes = newChangeLog(100)
+	mp.uidManager = NewUidMgr(mp.config.VolName, mp.config.PartitionId)
+	mp.fsmCreateInode(NewInode(proto.RootIno, proto.Mode(os.ModeDir|0o755)))
+	mp.fsmCreateInode(NewInode(10, FileModeType))
+
+	// the changes made outside the applies, e.g. by loading the snapshot, are not recorded
+	require.Equal(t, proto.OpOk, mp.fsmCreateDentry(&Dentry{ParentId: proto.RootIno, Name: "a", Inode: 10, Type: FileModeType}, false))
+	require.Empty(t, readChanges(t, mp.changes, 0, 10).Events)
+
+	mp.changes.begin(5, 1700000000)
+	mp.fsmCreateDentry(&Dentry{ParentId: proto.RootIno, Name: "b", Inode: 10, Type: FileModeType}, false)
+	mp.changes.end()
+	mp.changes.begin(6, 0)
+	mp.fsmCreateDentry(&Dentry{ParentId: proto.RootIno, Name: "b", Inode: 10, Type: FileModeType}, false)
+	mp.changes.end()
+	mp.changes.begin(7, 0)
+	mp.fsmDeleteDentry(&Dentry{ParentId: proto.RootIno, Name: "a"}, false)
+	mp.changes.end()
+	mp.changes.begin(8, 0)
+	mp.fsmSetAttr(&SetattrRequest{Inode: 10, Mode: 0o600, Valid: proto.AttrMode})
+	mp.changes.end()
+
+	resp, err := mp.ReadChanges(&proto.GetChangesRequest{}, time.Second)
+	require.NoError(t, err)
+	require.False(t, resp.Expired)
+	require.Equal(t, uint64(4), resp.First)
+	require.Len(t, resp.Events, 3)
+	require.Equal(t, proto.ChangeCreate, resp.Events[0].Type)
+	require.Equal(t, uint64(5), resp.Events[0].Index)
+	require.Equal(t, "b", resp.Events[0].Name)
+	require.Equal(t, int64(1700000000), resp.Events[0].Time)
+	require.Equal(t, proto.ChangeUnlink, resp.Events[1].Type)
+	require.Equal(t, uint64(10), resp.Events[1].Inode)
+	require.Equal(t, proto.ChangeSetAttr, resp.Events[2].Type)
+	require.Equal(t, proto.AttrMode, resp.Events[2].Valid)
+
+	resp, err = mp.ReadChanges(&proto.GetChangesRequest{From: 7}, time.Second)
+	require.NoError(t, err)
+	require.Len(t, resp.Events, 1)
+	require.Equal(t, uint64(8), resp.Events[0].Index)
+
+	mp.changes = nil
+	_, err = mp.ReadChanges(&proto.GetChangesRequest{}, time.Second)
+	require.Equal(t, ErrChangeLogDisabled, err)
+}
+
+func TestChangeLogRename(t *testing.T) {
+	c := newChangeLog(10)
+	c.begin(1, 0)
+	c.inTx(&proto.TransactionInfo{TxID: "1_1", TxType: proto.TxTypeRename})
+	c.add(&proto.ChangeEvent{Type: proto.ChangeUnlink, Inode: 10, Name: "a"})
+	c.add(&proto.ChangeEvent{Type: proto.ChangeCreate, Inode: 10, Name: "b"})
+	c.end()
+	c.begin(2, 0)
+	c.inTx(&proto.TransactionInfo{TxID: "1_2", TxType: proto.TxTypeCreate})
+	c.add(&proto.ChangeEvent{Type: proto.ChangeCreate, Inode: 11, Name: "c"})
+	c.end()
+
+	events := readChanges(t, c, 0, 10).Events
+	require.Len(t, events, 3)
+	require.Equal(t, proto.ChangeRenameFrom, events[0].Type)
+	require.Equal(t, proto.ChangeRenameTo, events[1].Type)
+	require.Equal(t, "1_1", events[1].TxID)
+	require.Equal(t, proto.ChangeCreate, events[2].Type)
+	require.Equal(t, "1_2", events[2].TxID)
+}
+
+func TestChangeLogEvictAndLimit(t *testing.T) {
+	c := newChangeLog(8)
+	for index := uint64(1); index <= 5; index++ {
+		c.begin(index, 0)
+		c.add(&proto.ChangeEvent{Type: proto.ChangeWrite, Inode: index})
+		c.add(&proto.ChangeEvent{Type: proto.ChangeSetAttr, Inode: index})
+		c.end()
+	}
+
+	// the whole applies are evicted when the log is full
+	resp := readChanges(t, c, 0, 100)
+	require.Equal(t, uint64(2), resp.First)
+	require.Len(t, resp.Events, 6)
+	require.Equal(t, uint64(3), resp.Events[0].Index)
+	require.True(t, readChanges(t, c, 1, 100).Expired)
+	require.False(t, readChanges(t, c, 2, 100).Expired)
+
+	// the changes of an apply are never split by the limit
+	resp = readChanges(t, c, 2, 3)
+	require.Len(t, resp.Events, 4)
+	require.Equal(t, uint64(4), resp.Events[3].Index)
+	require.Empty(t, readChanges(t, c, 5, 100).Events)
+
+	c.reset()
+	c.begin(10, 0)
+	c.end()
+	require.Equal(t, uint64(9), readChanges(t, c, 0, 100).First)
+	require.True(t, readChanges(t, c, 5, 100).Expired)
+}
+
+func TestChangeLogWait(t *testing.T) {
+	c := newChangeLog(8)
+	c.begin(1, 0)
+	c.end()
+
+	done := make(chan struct{})
+	go func() {
+		c.wait(1, 10*time.Second, nil)
+		close(done)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	c.begin(2, 0)
+	c.add(&proto.ChangeEvent{Type: proto.ChangeCreate, Inode: 10})
+	c.end()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait is not notified by the new changes")
+	}
+
+	start := time.Now()
+	c.wait(2, 20*time.Millisecond, nil)
+	require.True(t, time.Since(start) >= 20*time.Millisecond)
+}
+
+func recordChanges(c *changeLog, from, to uint64) {
+	for index := from; index <= to; index++ {
+		c.begin(index, int64(index))
+		c.add(&proto.ChangeEvent{Type: proto.ChangeWrite, Inode: index})
+		c.add(&proto.ChangeEvent{Type: proto.ChangeSetAttr, Inode: index})
+		c.end()
+	}
+}
+
+func TestChangeLogStore(t *testing.T) {
+	dir := t.TempDir()
+	defer func(size int64) { changeSegmentMaxSize = size }(changeSegmentMaxSize)
+	changeSegmentMaxSize = 512
+
+	c := newChangeLog(4)
+	require.NoError(t, c.open(dir, 0))
+	recordChanges(c, 1, 20)
+	c.sync(20)
+
+	// the changes evicted from memory are read from disk
+	resp := readChanges(t, c, 0, 100)
+	require.Equal(t, uint64(0), resp.First)
+	require.Len(t, resp.Events, 40)
+	require.Equal(t, uint64(1), resp.Events[0].Index)
+	resp = readChanges(t, c, 7, 3)
+	require.Len(t, resp.Events, 4)
+	require.Equal(t, uint64(8), resp.Events[0].Index)
+	require.Equal(t, uint64(9), resp.Events[3].Index)
+
+	// the applies not synced are recorded again by replaying the raft log after restart, the torn
+	// record is dropped
+	recordChanges(c, 21, 22)
+	c.close()
+	segments, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.True(t, len(segments) > 1)
+	last, err := os.OpenFile(path.Join(dir, segments[len(segments)-1].Name()), os.O_WRONLY|os.O_APPEND, 0o644)
+	require.NoError(t, err)
+	_, err = last.WriteString(`{"index":23,"ev`)
+	require.NoError(t, err)
+	last.Close()
+
+	c = newChangeLog(4)
+	require.NoError(t, c.open(dir, 20))
+	recordChanges(c, 21, 24)
+	resp = readChanges(t, c, 0, 100)
+	require.Equal(t, uint64(0), resp.First)
+	require.Len(t, resp.Events, 48)
+	for i, event := range resp.Events {
+		require.Equal(t, uint64(i/2+1), event.Index)
+	}
+
+	// the oldest segments are dropped by the retention
+	defer func(size int64) { changeLogMaxSize = size }(changeLogMaxSize)
+	changeLogMaxSize = 1024
+	c.sync(24)
+	resp = readChanges(t, c, 1, 100)
+	require.True(t, resp.Expired)
+	require.True(t, resp.First > 1)
+	require.Equal(t, resp.First+1, resp.Events[0].Index)
+	c.close()
+
+	// the log ending before the snapshot is dropped, as the changes in between are unknown
+	c = newChangeLog(4)
+	require.NoError(t, c.open(dir, 30))
+	recordChanges(c, 31, 31)
+	resp = readChanges(t, c, 0, 100)
+	require.Equal(t, uint64(30), resp.First)
+	require.Len(t, resp.Events, 2)
+	c.close()
+}
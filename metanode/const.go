@@ -191,6 +191,27 @@ const (
 	opFSMCreateLinkInodeOnce = 67
 	// dir lock
 	opFSMLockDir = 68
+	// file lock
+	opFSMFileLock = 70
+	// fallocate
+	opFSMFallocate = 71
+	// orphan extents found by fsck
+	opFSMFsckDeleteExtents = 72
+	// clone the extents of an inode
+	opFSMCloneInode        = 77
+	opFSMSharedExtentsSnap = 78
+	// write the data of the tiny file into the inode
+	opFSMWriteInline = 79
+	// the recursive stats of the directories
+	opFSMUpdateDirStat = 80
+	opFSMAckDirStat    = 81
+	opFSMDirStatsSnap  = 82
+	// link the dentries created before the stats are maintained
+	opFSMBackfillDirStat = 83
+	// archive the current version of an object
+	opFSMArchiveDentry = 84
+	// the entry of the dir stat tree in the raft snapshot
+	opFSMDirStatEntrySnap = 85
 
 	opFSMSyncInodeAccessTime = 69
 
@@ -205,6 +226,9 @@ const (
 	opFSMInternalBatchFreeInodeMigrationExtentKey = 89
 	opFSMSetInodeCreateTime                       = 90 // for debug
 	opFSMSetMigrationExtentKeyDeleteImmediately   = 91
+
+	// the key-value of the tree backed by the kv store in the raft snapshot
+	opFSMStoreKVSnap = 92
 )
 
 // new inode opCode
@@ -215,6 +239,12 @@ const (
 var (
 	ErrNoLeader   = errors.New("no leader")
 	ErrNotALeader = errors.New("not a leader")
+
+	ErrReadIndexTimeout = errors.New("wait for applying the read index timeout")
+
+	ErrChangeLogDisabled = errors.New("change log is disabled")
+	ErrInodeNotFound     = errors.New("inode not found")
+	ErrNotDirectory      = errors.New("not a directory")
 )
 
 // Default configuration
@@ -243,7 +273,10 @@ const (
 	cfgRetainLogs                = "retainLogs"                // string, raft RetainLogs
 	cfgRaftSyncSnapFormatVersion = "raftSyncSnapFormatVersion" // int, format version of snapshot that raft leader sent to follower
 	cfgServiceIDKey              = "serviceIDKey"
-	cfgEnableGcTimer             = "enableGcTimer" // bool
+	cfgEnableGcTimer             = "enableGcTimer"           // bool
+	cfgChangeLogCapacity         = "changeLogCapacity"       // int, the changes cached in memory by each partition for the change feed
+	cfgChangeLogRetentionHours   = "changeLogRetentionHours" // int, the hours the changes are kept on disk
+	cfgChangeLogMaxSizeMB        = "changeLogMaxSizeMB"      // int, the max size of the changes kept on disk by each partition
 
 	metaNodeDeleteBatchCountKey = "batchCount"
 	configNameResolveInterval   = "nameResolveInterval" // int
@@ -254,6 +287,9 @@ const (
 	intervalToPersistData = time.Minute * 5
 	intervalToSyncCursor  = time.Minute * 1
 
+	// the follower serving the read gives up the read index and forwards the read to the leader after it
+	readIndexTimeout = time.Second * 3
+
 	defaultDelExtentsCnt               = 100000
 	defaultMaxQuotaGoroutine           = 5
 	defaultQuotaSwitch                 = true
@@ -30,6 +30,7 @@ type DataPartition struct {
 	PartitionType string
 	Hosts         []string
 	IsDiscard     bool
+	CloneSource   string // shared from the source volume of the clone, never deleted by the clone
 }
 
 // GetAllAddrs returns all addresses of the data partition.
@@ -0,0 +1,801 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/errors"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// The recursive stats of the directories, the files, the sub directories and the bytes of the
+// files in the tree under each directory, maintained by the applies of the partitions like the
+// rstats of CephFS.
+//
+// The stats are kept only for the directories, in the dir stat tree of the partition of the
+// directory, which is persisted along with the other trees. A file keeps the parents linking it
+// in the inode instead, and counts its size in the stats of the first one.
+//
+// The dentry is kept by the partition of its parent while the inode may be kept by another one,
+// so the stats are maintained by the updates sent to the partition of the inode:
+//   - the dentry created or deleted links or unlinks the inode to or from the parent. The stats
+//     of the inode, the size of the file or the stats of the directory, are added to the first
+//     parent it's linked to, so a file with hard links counts once.
+//   - the size of the linked file changed, or the stats of the linked directory changed, adds the
+//     change to the stats of the first parent, and so on up to the root.
+//
+// The update to the inode of the partition itself is applied at once, the others are queued and
+// sent to the partitions of the inodes in the background by the leader, which drops them after
+// they are applied. The updates are applied by the partition at most once by the seq of each
+// partition sending them, as they may be sent again after the leader changes.
+//
+// The inodes linked before the metanode supports the stats are counted by the backfill, which
+// scans the dentries of the partition once in batches by the leader and links the ones not linked
+// yet. The stats are complete after the backfills of all the partitions are done and the updates
+// made by them are forwarded.
+
+const (
+	dirStatsVersion          = 3
+	dirStatForwardInterval   = time.Second
+	dirStatForwardBatchSize  = 1000
+	dirStatBackfillBatchSize = 10000
+)
+
+// dirStatEntry is the item of the dir stat tree.
+type dirStatEntry struct {
+	ino     uint64
+	parents []uint64 // the parents linking the directory, the stats are added to the first one
+	files   int64
+	dirs    int64
+	bytes   int64
+}
+
+func (e *dirStatEntry) Less(than BtreeItem) bool {
+	other, ok := than.(*dirStatEntry)
+	return ok && e.ino < other.ino
+}
+
+func (e *dirStatEntry) Copy() BtreeItem {
+	c := *e
+	c.parents = append([]uint64(nil), e.parents...)
+	return &c
+}
+
+func (e *dirStatEntry) empty() bool {
+	return len(e.parents) == 0 && e.files == 0 && e.dirs == 0 && e.bytes == 0
+}
+
+func (e *dirStatEntry) Marshal() ([]byte, error) {
+	buff := bytes.NewBuffer(make([]byte, 0, 44+8*len(e.parents)))
+	for _, v := range []interface{}{e.ino, e.files, e.dirs, e.bytes, uint32(len(e.parents)), e.parents} {
+		if err := binary.Write(buff, binary.BigEndian, v); err != nil {
+			return nil, err
+		}
+	}
+	return buff.Bytes(), nil
+}
+
+func (e *dirStatEntry) Unmarshal(buff *bytes.Buffer) (err error) {
+	var n uint32
+	for _, v := range []interface{}{&e.ino, &e.files, &e.dirs, &e.bytes, &n} {
+		if err = binary.Read(buff, binary.BigEndian, v); err != nil {
+			return
+		}
+	}
+	if int(n)*8 > buff.Len() {
+		return io.ErrUnexpectedEOF
+	}
+	e.parents = nil
+	if n > 0 {
+		e.parents = make([]uint64, n)
+		err = binary.Read(buff, binary.BigEndian, e.parents)
+	}
+	return
+}
+
+var dirStatCodec = &treeCodec{
+	table: kvTableDirStat,
+	key:   func(item BtreeItem) []byte { return uint64Key(item.(*dirStatEntry).ino) },
+	encode: func(item BtreeItem) ([]byte, error) {
+		return item.(*dirStatEntry).Marshal()
+	},
+	decode: func(value []byte) (BtreeItem, error) {
+		e := &dirStatEntry{}
+		if err := e.Unmarshal(bytes.NewBuffer(value)); err != nil {
+			return nil, err
+		}
+		return e, nil
+	},
+}
+
+// linkStatParent adds the parent to the parents, and returns whether the stats are to be added to
+// it. The parent linked already is skipped by the backfill.
+func linkStatParent(parents *[]uint64, parent uint64, backfill bool) bool {
+	if backfill {
+		for _, p := range *parents {
+			if p == parent {
+				return false
+			}
+		}
+	}
+	*parents = append(*parents, parent)
+	return len(*parents) == 1
+}
+
+// unlinkStatParent removes the parent from the parents, and returns whether the stats were added
+// to it.
+func unlinkStatParent(parents *[]uint64, parent uint64) (found, first bool) {
+	for i, p := range *parents {
+		if p == parent {
+			*parents = append((*parents)[:i], (*parents)[i+1:]...)
+			if len(*parents) == 0 {
+				*parents = nil
+			}
+			return true, i == 0
+		}
+	}
+	return false, false
+}
+
+// dirStats is the state of the updates of the stats, the stats themselves are kept in the dir stat
+// tree and the inodes of the partition.
+type dirStats struct {
+	// also protects the stats in the dir stat tree read by GetDirStat
+	sync.RWMutex
+	seq     uint64                 // the seq of the last update queued
+	pending []*proto.DirStatUpdate // the updates queued to the other partitions
+	applied map[uint64]uint64      // the seq of the last update applied from each partition
+
+	// the backfill is done, or the key of the last dentry scanned by it
+	backfilled     bool
+	backfillParent uint64
+	backfillName   string
+
+	applying bool // only touched by the apply goroutine
+}
+
+func newDirStats() *dirStats {
+	return &dirStats{
+		applied: make(map[uint64]uint64),
+	}
+}
+
+func (ds *dirStats) clone() *dirStats {
+	ds.RLock()
+	defer ds.RUnlock()
+	c := newDirStats()
+	c.seq = ds.seq
+	c.pending = make([]*proto.DirStatUpdate, len(ds.pending))
+	for i, u := range ds.pending {
+		cu := *u
+		c.pending[i] = &cu
+	}
+	for pid, seq := range ds.applied {
+		c.applied[pid] = seq
+	}
+	c.backfilled = ds.backfilled
+	c.backfillParent = ds.backfillParent
+	c.backfillName = ds.backfillName
+	return c
+}
+
+func (ds *dirStats) isEmpty() bool {
+	ds.RLock()
+	defer ds.RUnlock()
+	return len(ds.pending) == 0 && len(ds.applied) == 0 &&
+		!ds.backfilled && ds.backfillParent == 0 && ds.backfillName == ""
+}
+
+func (ds *dirStats) begin() {
+	if ds != nil {
+		ds.applying = true
+	}
+}
+
+func (ds *dirStats) end() {
+	if ds != nil {
+		ds.applying = false
+	}
+}
+
+// updating returns whether the apply going on maintains the stats.
+func (ds *dirStats) updating() bool {
+	return ds != nil && ds.applying
+}
+
+func (ds *dirStats) queue(u *proto.DirStatUpdate) {
+	ds.Lock()
+	defer ds.Unlock()
+	ds.seq++
+	u.Seq = ds.seq
+	ds.pending = append(ds.pending, u)
+}
+
+// accept returns whether the update from the partition is not applied yet.
+func (ds *dirStats) accept(from uint64, u *proto.DirStatUpdate) bool {
+	ds.Lock()
+	defer ds.Unlock()
+	if u.Seq <= ds.applied[from] {
+		return false
+	}
+	ds.applied[from] = u.Seq
+	return true
+}
+
+func (ds *dirStats) ack(seq uint64) {
+	ds.Lock()
+	defer ds.Unlock()
+	i := sort.Search(len(ds.pending), func(i int) bool {
+		return ds.pending[i].Seq > seq
+	})
+	ds.pending = append([]*proto.DirStatUpdate(nil), ds.pending[i:]...)
+}
+
+func (ds *dirStats) pendingUpdates(limit int) (updates []*proto.DirStatUpdate) {
+	ds.RLock()
+	defer ds.RUnlock()
+	if len(ds.pending) < limit {
+		limit = len(ds.pending)
+	}
+	updates = make([]*proto.DirStatUpdate, limit)
+	copy(updates, ds.pending[:limit])
+	return
+}
+
+// Marshal marshals the state of the updates, followed by the entries of the dir stat tree if it's
+// not nil, which is the case of the partitions keeping the trees in memory.
+func (ds *dirStats) Marshal(tree *BTree) (buf []byte, crc uint32, err error) {
+	ds.RLock()
+	defer ds.RUnlock()
+	buffer := bytes.NewBuffer(make([]byte, 0, 64+len(ds.pending)*64))
+	write := func(vals ...interface{}) {
+		for _, v := range vals {
+			if err == nil {
+				err = binary.Write(buffer, binary.BigEndian, v)
+			}
+		}
+	}
+	write(int32(dirStatsVersion), ds.backfilled, ds.backfillParent, uint32(len(ds.backfillName)),
+		[]byte(ds.backfillName), ds.seq, uint32(len(ds.applied)))
+	for pid, seq := range ds.applied {
+		write(pid, seq)
+	}
+	write(uint32(len(ds.pending)))
+	for _, u := range ds.pending {
+		write(u.Seq, u.Type, u.Inode, u.Parent, u.IsDir, u.Files, u.Dirs, u.Bytes)
+	}
+	if tree != nil && err == nil {
+		tree.Ascend(func(i BtreeItem) bool {
+			var raw []byte
+			if raw, err = i.(*dirStatEntry).Marshal(); err != nil {
+				return false
+			}
+			buffer.Write(raw)
+			return true
+		})
+	}
+	if err != nil {
+		return
+	}
+	buf = buffer.Bytes()
+	crc = crc32.ChecksumIEEE(buf)
+	return
+}
+
+// UnMarshal unmarshals the state of the updates, and inserts the entries following it into the
+// tree.
+func (ds *dirStats) UnMarshal(data []byte, tree *BTree) (err error) {
+	buff := bytes.NewBuffer(data)
+	read := func(vals ...interface{}) {
+		for _, v := range vals {
+			if err == nil {
+				err = binary.Read(buff, binary.BigEndian, v)
+			}
+		}
+	}
+	var (
+		version int32
+		n       uint32
+	)
+	ds.Lock()
+	defer ds.Unlock()
+	read(&version)
+	if version >= 2 {
+		read(&ds.backfilled, &ds.backfillParent, &n)
+		if err == nil && n > 0 {
+			name := make([]byte, n)
+			read(name)
+			ds.backfillName = string(name)
+		}
+	}
+	read(&ds.seq, &n)
+	for i := uint32(0); i < n && err == nil; i++ {
+		var pid, seq uint64
+		read(&pid, &seq)
+		ds.applied[pid] = seq
+	}
+	read(&n)
+	for i := uint32(0); i < n && err == nil; i++ {
+		u := &proto.DirStatUpdate{}
+		read(&u.Seq, &u.Type, &u.Inode, &u.Parent, &u.IsDir, &u.Files, &u.Dirs, &u.Bytes)
+		ds.pending = append(ds.pending, u)
+	}
+	if err == nil && version < dirStatsVersion {
+		// the stats of the files were kept along with the ones of the directories, they are all
+		// dropped and counted again by the backfill, only the seqs are kept
+		log.LogWarnf("dirStats UnMarshal: stats of version(%v) are dropped to backfill again", version)
+		ds.pending = nil
+		ds.backfilled, ds.backfillParent, ds.backfillName = false, 0, ""
+		return
+	}
+	for buff.Len() != 0 && err == nil {
+		e := &dirStatEntry{}
+		if err = e.Unmarshal(buff); err != nil {
+			break
+		}
+		if tree == nil {
+			err = errors.New("unexpected entries")
+			break
+		}
+		tree.ReplaceOrInsert(e, true)
+	}
+	if err != nil {
+		err = errors.NewErrorf("invalid dirStats data: %v", err)
+		log.LogErrorf("dirStats UnMarshal err(%v)", err)
+	}
+	return
+}
+
+func (mp *metaPartition) isLocalInode(ino uint64) bool {
+	return ino >= mp.config.Start && ino <= mp.config.End
+}
+
+// applyDirStatUpdates applies the updates to the inodes of the partition and the ones made by
+// them, and queues the others.
+func (mp *metaPartition) applyDirStatUpdates(updates ...*proto.DirStatUpdate) {
+	for len(updates) > 0 {
+		u := updates[0]
+		updates = updates[1:]
+		if !mp.isLocalInode(u.Inode) {
+			mp.dirStats.queue(u)
+			continue
+		}
+		switch u.Type {
+		case proto.DirStatLink, proto.DirStatBackfill:
+			item := mp.inodeTree.CopyGet(NewInode(u.Inode, 0))
+			if item == nil {
+				// the inode is deleted before the link arrives, it's unlinked already
+				continue
+			}
+			if u.IsDir {
+				updates = append(updates, mp.linkDirStat(u)...)
+			} else {
+				updates = append(updates, linkFileStat(item.(*Inode), u)...)
+			}
+		case proto.DirStatUnlink:
+			if item := mp.dirStatTree.CopyGet(&dirStatEntry{ino: u.Inode}); item != nil {
+				updates = append(updates, mp.unlinkDirStat(item.(*dirStatEntry), u)...)
+			} else if item = mp.inodeTree.CopyGet(NewInode(u.Inode, 0)); item != nil {
+				// the inode deleted is unlinked from all the parents already, see
+				// unlinkDeletedFileStat
+				updates = append(updates, unlinkFileStat(item.(*Inode), u)...)
+			}
+		case proto.DirStatDelta:
+			updates = append(updates, mp.addDirStat(u)...)
+		}
+	}
+}
+
+func newDirStatDelta(parent uint64, files, dirs, bytes int64) *proto.DirStatUpdate {
+	return &proto.DirStatUpdate{Type: proto.DirStatDelta, Inode: parent, Files: files, Dirs: dirs, Bytes: bytes}
+}
+
+func linkFileStat(ino *Inode, u *proto.DirStatUpdate) (updates []*proto.DirStatUpdate) {
+	ino.Lock()
+	defer ino.Unlock()
+	if linkStatParent(&ino.StatParents, u.Parent, u.Type == proto.DirStatBackfill) {
+		updates = append(updates, newDirStatDelta(u.Parent, 1, 0, int64(ino.Size)))
+	}
+	return
+}
+
+func unlinkFileStat(ino *Inode, u *proto.DirStatUpdate) (updates []*proto.DirStatUpdate) {
+	ino.Lock()
+	defer ino.Unlock()
+	if found, first := unlinkStatParent(&ino.StatParents, u.Parent); found && first {
+		size := int64(ino.Size)
+		updates = append(updates, newDirStatDelta(u.Parent, -1, 0, -size))
+		if len(ino.StatParents) > 0 {
+			updates = append(updates, newDirStatDelta(ino.StatParents[0], 1, 0, size))
+		}
+	}
+	return
+}
+
+// unlinkDeletedFileStat unlinks the file deleted before the unlinks sent by the partitions of its
+// dentries arrive.
+func (mp *metaPartition) unlinkDeletedFileStat(ino *Inode) {
+	if !mp.dirStats.updating() || len(ino.StatParents) == 0 {
+		return
+	}
+	mp.applyDirStatUpdates(newDirStatDelta(ino.StatParents[0], -1, 0, -int64(ino.Size)))
+}
+
+// dirStatEntryOf returns the entry of the directory in the dir stat tree to modify, which is
+// created if it's not there.
+func (mp *metaPartition) dirStatEntryOf(ino uint64) *dirStatEntry {
+	if item := mp.dirStatTree.CopyGet(&dirStatEntry{ino: ino}); item != nil {
+		return item.(*dirStatEntry)
+	}
+	e := &dirStatEntry{ino: ino}
+	mp.dirStatTree.ReplaceOrInsert(e, true)
+	return e
+}
+
+// releaseDirStat drops the entry without the stats and the parents from the dir stat tree.
+func (mp *metaPartition) releaseDirStat(e *dirStatEntry) {
+	if e.empty() {
+		mp.dirStatTree.Delete(e)
+	}
+}
+
+func (mp *metaPartition) linkDirStat(u *proto.DirStatUpdate) (updates []*proto.DirStatUpdate) {
+	e := mp.dirStatEntryOf(u.Inode)
+	mp.dirStats.Lock()
+	if linkStatParent(&e.parents, u.Parent, u.Type == proto.DirStatBackfill) {
+		updates = append(updates, newDirStatDelta(u.Parent, e.files, e.dirs+1, e.bytes))
+	}
+	mp.dirStats.Unlock()
+	mp.releaseDirStat(e)
+	return
+}
+
+func (mp *metaPartition) unlinkDirStat(e *dirStatEntry, u *proto.DirStatUpdate) (updates []*proto.DirStatUpdate) {
+	mp.dirStats.Lock()
+	if found, first := unlinkStatParent(&e.parents, u.Parent); found && first {
+		updates = append(updates, newDirStatDelta(u.Parent, -e.files, -e.dirs-1, -e.bytes))
+		if len(e.parents) > 0 {
+			updates = append(updates, newDirStatDelta(e.parents[0], e.files, e.dirs+1, e.bytes))
+		}
+	}
+	mp.dirStats.Unlock()
+	mp.releaseDirStat(e)
+	return
+}
+
+func (mp *metaPartition) addDirStat(u *proto.DirStatUpdate) (updates []*proto.DirStatUpdate) {
+	e := mp.dirStatEntryOf(u.Inode)
+	mp.dirStats.Lock()
+	e.files += u.Files
+	e.dirs += u.Dirs
+	e.bytes += u.Bytes
+	if len(e.parents) > 0 {
+		updates = append(updates, newDirStatDelta(e.parents[0], u.Files, u.Dirs, u.Bytes))
+	}
+	mp.dirStats.Unlock()
+	mp.releaseDirStat(e)
+	return
+}
+
+func (mp *metaPartition) updateDirStatLink(den *Dentry, oldIno uint64) {
+	if !mp.dirStats.updating() {
+		return
+	}
+	if oldIno != 0 {
+		mp.applyDirStatUpdates(&proto.DirStatUpdate{Type: proto.DirStatUnlink, Inode: oldIno, Parent: den.ParentId})
+	}
+	mp.applyDirStatUpdates(&proto.DirStatUpdate{
+		Type: proto.DirStatLink, Inode: den.Inode, Parent: den.ParentId, IsDir: proto.IsDir(den.Type),
+	})
+}
+
+func (mp *metaPartition) updateDirStatUnlink(den *Dentry) {
+	if !mp.dirStats.updating() {
+		return
+	}
+	mp.applyDirStatUpdates(&proto.DirStatUpdate{Type: proto.DirStatUnlink, Inode: den.Inode, Parent: den.ParentId})
+}
+
+// updateDirStatSize adds the change of the size of the file to the stats of its parent.
+func (mp *metaPartition) updateDirStatSize(ino *Inode, oldSize int64) {
+	if !mp.dirStats.updating() || len(ino.StatParents) == 0 || int64(ino.Size) == oldSize {
+		return
+	}
+	mp.applyDirStatUpdates(newDirStatDelta(ino.StatParents[0], 0, 0, int64(ino.Size)-oldSize))
+}
+
+func (mp *metaPartition) fsmUpdateDirStat(req *proto.UpdateDirStatRequest) (status uint8) {
+	for _, u := range req.Updates {
+		if mp.dirStats.accept(req.From, u) {
+			mp.applyDirStatUpdates(&proto.DirStatUpdate{
+				Type: u.Type, Inode: u.Inode, Parent: u.Parent, IsDir: u.IsDir, Files: u.Files, Dirs: u.Dirs, Bytes: u.Bytes,
+			})
+		}
+	}
+	return proto.OpOk
+}
+
+func (mp *metaPartition) fsmAckDirStat(seq uint64) {
+	mp.dirStats.ack(seq)
+}
+
+// dirStatBackfillRequest links the dentries scanned by the backfill, and moves its cursor to the
+// last one, or marks it done.
+type dirStatBackfillRequest struct {
+	Keys []*dirStatBackfillKey `json:"keys"`
+	Done bool                  `json:"done"`
+}
+
+type dirStatBackfillKey struct {
+	Parent uint64 `json:"pino"`
+	Name   string `json:"name"`
+}
+
+// backfillCursor returns whether the backfill is done, or the key of the last dentry scanned.
+func (ds *dirStats) backfillCursor() (done bool, parent uint64, name string) {
+	ds.RLock()
+	defer ds.RUnlock()
+	return ds.backfilled, ds.backfillParent, ds.backfillName
+}
+
+func (ds *dirStats) setBackfillCursor(done bool, parent uint64, name string) {
+	ds.Lock()
+	defer ds.Unlock()
+	ds.backfilled = done
+	ds.backfillParent = parent
+	ds.backfillName = name
+}
+
+// fsmBackfillDirStat links the dentries still there, the ones linked already are skipped by the
+// partitions of their inodes.
+func (mp *metaPartition) fsmBackfillDirStat(req *dirStatBackfillRequest) {
+	done, parent, name := mp.dirStats.backfillCursor()
+	if done {
+		return
+	}
+	for _, key := range req.Keys {
+		item := mp.dentryTree.Get(&Dentry{ParentId: key.Parent, Name: key.Name})
+		if item == nil || item.(*Dentry).isDeleted() {
+			continue
+		}
+		den := item.(*Dentry)
+		mp.applyDirStatUpdates(&proto.DirStatUpdate{
+			Type: proto.DirStatBackfill, Inode: den.Inode, Parent: den.ParentId, IsDir: proto.IsDir(den.Type),
+		})
+	}
+	if n := len(req.Keys); n > 0 {
+		parent, name = req.Keys[n-1].Parent, req.Keys[n-1].Name
+	}
+	mp.dirStats.setBackfillCursor(req.Done, parent, name)
+	if req.Done {
+		log.LogInfof("[fsmBackfillDirStat] vol(%v) mp(%v) dir stats backfill done", mp.config.VolName, mp.config.PartitionId)
+	}
+}
+
+// nextDirStatBackfill returns the next batch of the dentries scanned from the cursor of the
+// backfill, nil if the backfill is done.
+func (mp *metaPartition) nextDirStatBackfill() *dirStatBackfillRequest {
+	done, parent, name := mp.dirStats.backfillCursor()
+	if done {
+		return nil
+	}
+	req := &dirStatBackfillRequest{Done: true}
+	mp.dentryTree.AscendGreaterOrEqual(&Dentry{ParentId: parent, Name: name}, func(i BtreeItem) bool {
+		den := i.(*Dentry)
+		if den.ParentId == parent && den.Name == name {
+			return true
+		}
+		if len(req.Keys) == dirStatBackfillBatchSize {
+			req.Done = false
+			return false
+		}
+		req.Keys = append(req.Keys, &dirStatBackfillKey{Parent: den.ParentId, Name: den.Name})
+		return true
+	})
+	return req
+}
+
+// backfillDirStats submits the next batch of the backfill.
+func (mp *metaPartition) backfillDirStats() (err error) {
+	req := mp.nextDirStatBackfill()
+	if req == nil {
+		return
+	}
+	reqBytes, err := json.Marshal(req)
+	if err != nil {
+		return
+	}
+	_, err = mp.submit(opFSMBackfillDirStat, reqBytes)
+	return
+}
+
+// UpdateDirStat applies the updates of the stats sent by the other partition.
+func (mp *metaPartition) UpdateDirStat(req *proto.UpdateDirStatRequest, p *Packet) (err error) {
+	reqBytes, err := json.Marshal(req)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	resp, err := mp.submit(opFSMUpdateDirStat, reqBytes)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
+	p.PacketErrorWithBody(resp.(uint8), nil)
+	return
+}
+
+// GetDirStat returns the recursive stats of the directory.
+func (mp *metaPartition) GetDirStat(req *proto.GetDirStatRequest, p *Packet) (err error) {
+	stat, err := mp.DirStatOf(req.Inode)
+	if err == ErrInodeNotFound {
+		p.PacketErrorWithBody(proto.OpNotExistErr, []byte(err.Error()))
+		return
+	} else if err != nil {
+		p.PacketErrorWithBody(proto.OpArgMismatchErr, []byte(err.Error()))
+		return
+	}
+	reply, err := json.Marshal(stat)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(reply)
+	return
+}
+
+// DirStatOf returns the recursive stats of the directory, which are zeros if nothing is counted.
+func (mp *metaPartition) DirStatOf(ino uint64) (stat *proto.DirStat, err error) {
+	item := mp.inodeTree.Get(NewInode(ino, 0))
+	if item == nil || item.(*Inode).ShouldDelete() {
+		return nil, ErrInodeNotFound
+	}
+	if !proto.IsDir(item.(*Inode).Type) {
+		return nil, ErrNotDirectory
+	}
+	stat = &proto.DirStat{}
+	if item = mp.dirStatTree.Get(&dirStatEntry{ino: ino}); item != nil {
+		e := item.(*dirStatEntry)
+		mp.dirStats.RLock()
+		stat.Files, stat.Dirs, stat.Bytes = e.files, e.dirs, e.bytes
+		mp.dirStats.RUnlock()
+	}
+	return stat, nil
+}
+
+// dirStatXAttr returns the value of the extended attribute serving the stats of the directory.
+func (mp *metaPartition) dirStatXAttr(ino uint64, key string) string {
+	stat, err := mp.DirStatOf(ino)
+	if err != nil {
+		return ""
+	}
+	switch key {
+	case proto.DirStatFilesKey:
+		return strconv.FormatInt(stat.Files, 10)
+	case proto.DirStatDirsKey:
+		return strconv.FormatInt(stat.Dirs, 10)
+	default:
+		return strconv.FormatInt(stat.Bytes, 10)
+	}
+}
+
+// GetRootDirStat returns the stats of the root directory if it's kept by the partition.
+func (mp *metaPartition) GetRootDirStat() *proto.DirStat {
+	if !mp.isLocalInode(proto.RootIno) {
+		return nil
+	}
+	stat, _ := mp.DirStatOf(proto.RootIno)
+	return stat
+}
+
+func (mp *metaPartition) startDirStatForward() {
+	var views []*proto.MetaPartitionView
+	timer := time.NewTimer(dirStatForwardInterval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			if _, ok := mp.IsLeader(); ok {
+				var err error
+				if views, err = mp.forwardDirStats(views); err != nil {
+					log.LogWarnf("[forwardDirStats] vol(%v) mp(%v) err(%v)", mp.config.VolName, mp.config.PartitionId, err)
+					views = nil
+				}
+				if err = mp.backfillDirStats(); err != nil {
+					log.LogWarnf("[backfillDirStats] vol(%v) mp(%v) err(%v)", mp.config.VolName, mp.config.PartitionId, err)
+				}
+			}
+			timer.Reset(dirStatForwardInterval)
+		case <-mp.stopC:
+			return
+		}
+	}
+}
+
+// forwardDirStats sends the updates queued to the partitions of their inodes, and drops them
+// after they are all applied.
+func (mp *metaPartition) forwardDirStats(views []*proto.MetaPartitionView) ([]*proto.MetaPartitionView, error) {
+	updates := mp.dirStats.pendingUpdates(dirStatForwardBatchSize)
+	if len(updates) == 0 {
+		return views, nil
+	}
+	var err error
+	if views == nil {
+		if views, err = masterClient.ClientAPI().GetMetaPartitions(mp.config.VolName); err != nil {
+			return nil, err
+		}
+	}
+	groups := make(map[*proto.MetaPartitionView][]*proto.DirStatUpdate)
+	for _, u := range updates {
+		view := findMetaPartitionView(views, u.Inode)
+		if view == nil {
+			return nil, errors.NewErrorf("no partition of inode(%v)", u.Inode)
+		}
+		group := groups[view]
+		// the consecutive changes of the same directory are merged, with the seq of the last one
+		if n := len(group); n > 0 && group[n-1].Type == proto.DirStatDelta && u.Type == proto.DirStatDelta &&
+			group[n-1].Inode == u.Inode {
+			merged := *group[n-1]
+			merged.Seq = u.Seq
+			merged.Files += u.Files
+			merged.Dirs += u.Dirs
+			merged.Bytes += u.Bytes
+			group[n-1] = &merged
+			continue
+		}
+		groups[view] = append(group, u)
+	}
+	for view, group := range groups {
+		req := &proto.UpdateDirStatRequest{
+			VolName:     mp.config.VolName,
+			PartitionID: view.PartitionID,
+			From:        mp.config.PartitionId,
+			Updates:     group,
+		}
+		pkt, err := buildTxPacket(req, view.PartitionID, proto.OpMetaUpdateDirStat)
+		if err != nil {
+			return views, err
+		}
+		if status := mp.txProcessor.txManager.txSendToMpWithAddrs(strings.Join(view.Members, ","), pkt); status != proto.OpOk {
+			return nil, errors.NewErrorf("update mp(%v) status(%v)", view.PartitionID, proto.GetStatusStr(status))
+		}
+	}
+	reqBytes, err := json.Marshal(updates[len(updates)-1].Seq)
+	if err != nil {
+		return views, err
+	}
+	_, err = mp.submit(opFSMAckDirStat, reqBytes)
+	return views, err
+}
+
+func findMetaPartitionView(views []*proto.MetaPartitionView, ino uint64) *proto.MetaPartitionView {
+	for _, view := range views {
+		if ino >= view.Start && ino <= view.End {
+			return view
+		}
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"os"
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func newDirStatTestPartition(t *testing.T) *metaPartition {
+	mp := NewMetaPartitionForTest()
+	mp.uidManager = NewUidMgr(mp.config.VolName, mp.config.PartitionId)
+	mp.config.Start = proto.RootIno
+	mp.config.End = 100
+	require.Equal(t, proto.OpOk, mp.fsmCreateInode(NewInode(proto.RootIno, proto.Mode(os.ModeDir|0o755))))
+	mp.dirStats.begin()
+	t.Cleanup(mp.dirStats.end)
+	return mp
+}
+
+func createDirStatTestDentry(t *testing.T, mp *metaPartition, parent, ino uint64, name string, mode uint32) {
+	if mp.isLocalInode(ino) {
+		require.Equal(t, proto.OpOk, mp.fsmCreateInode(NewInode(ino, mode)))
+	}
+	require.Equal(t, proto.OpOk, mp.fsmCreateDentry(&Dentry{ParentId: parent, Name: name, Inode: ino, Type: mode}, false))
+}
+
+func requireDirStat(t *testing.T, mp *metaPartition, ino uint64, files, dirs, bytes int64) {
+	stat, err := mp.DirStatOf(ino)
+	require.NoError(t, err)
+	require.Equal(t, proto.DirStat{Files: files, Dirs: dirs, Bytes: bytes}, *stat)
+}
+
+func TestDirStatsInPartition(t *testing.T) {
+	mp := newDirStatTestPartition(t)
+	dirMode := proto.Mode(os.ModeDir | 0o755)
+	createDirStatTestDentry(t, mp, proto.RootIno, 2, "a", dirMode)
+	createDirStatTestDentry(t, mp, 2, 3, "b", dirMode)
+	createDirStatTestDentry(t, mp, 3, 10, "f", 0o644)
+	requireDirStat(t, mp, proto.RootIno, 1, 2, 0)
+	requireDirStat(t, mp, 3, 1, 0, 0)
+
+	// the size changed is added up to the root
+	file := mp.inodeTree.Get(NewInode(10, 0)).(*Inode)
+	file.Size = 4096
+	mp.updateDirStatSize(file, 0)
+	requireDirStat(t, mp, proto.RootIno, 1, 2, 4096)
+	requireDirStat(t, mp, 2, 1, 1, 4096)
+
+	// the file keeps its parents in the inode, only the directories are in the tree
+	require.Equal(t, []uint64{3}, file.StatParents)
+	require.Nil(t, mp.dirStatTree.Get(&dirStatEntry{ino: 10}))
+	require.NotNil(t, mp.dirStatTree.Get(&dirStatEntry{ino: 3}))
+
+	// the hard link counts once, and the stats move to the next link when the first is unlinked
+	require.Equal(t, proto.OpOk, mp.fsmCreateDentry(&Dentry{ParentId: proto.RootIno, Name: "link", Inode: 10, Type: 0o644}, false))
+	requireDirStat(t, mp, proto.RootIno, 1, 2, 4096)
+	mp.fsmDeleteDentry(&Dentry{ParentId: 3, Name: "f", Inode: 10}, false)
+	requireDirStat(t, mp, 3, 0, 0, 0)
+	requireDirStat(t, mp, 2, 0, 1, 0)
+	requireDirStat(t, mp, proto.RootIno, 1, 2, 4096)
+
+	mp.fsmDeleteDentry(&Dentry{ParentId: proto.RootIno, Name: "link", Inode: 10}, false)
+	mp.fsmDeleteDentry(&Dentry{ParentId: 2, Name: "b", Inode: 3}, false)
+	requireDirStat(t, mp, proto.RootIno, 0, 1, 0)
+
+	_, err := mp.DirStatOf(10)
+	require.Equal(t, ErrNotDirectory, err)
+	_, err = mp.DirStatOf(99)
+	require.Equal(t, ErrInodeNotFound, err)
+	require.Equal(t, "1", mp.dirStatXAttr(proto.RootIno, proto.DirStatDirsKey))
+}
+
+func TestDirStatsAcrossPartitions(t *testing.T) {
+	mp := newDirStatTestPartition(t)
+	createDirStatTestDentry(t, mp, proto.RootIno, 2, "a", proto.Mode(os.ModeDir|0o755))
+
+	// the link of the remote inode is queued to its partition
+	createDirStatTestDentry(t, mp, 2, 200, "remote", 0o644)
+	updates := mp.dirStats.pendingUpdates(dirStatForwardBatchSize)
+	require.Len(t, updates, 1)
+	require.Equal(t, proto.DirStatUpdate{Seq: 1, Type: proto.DirStatLink, Inode: 200, Parent: 2}, *updates[0])
+	requireDirStat(t, mp, proto.RootIno, 0, 1, 0)
+
+	// the delta sent back by the partition of the inode is applied once
+	req := &proto.UpdateDirStatRequest{From: 2, Updates: []*proto.DirStatUpdate{
+		{Seq: 5, Type: proto.DirStatDelta, Inode: 2, Files: 1, Bytes: 100},
+	}}
+	require.Equal(t, proto.OpOk, mp.fsmUpdateDirStat(req))
+	require.Equal(t, proto.OpOk, mp.fsmUpdateDirStat(req))
+	requireDirStat(t, mp, proto.RootIno, 1, 1, 100)
+	requireDirStat(t, mp, 2, 1, 0, 100)
+
+	mp.fsmAckDirStat(1)
+	require.Empty(t, mp.dirStats.pendingUpdates(dirStatForwardBatchSize))
+}
+
+func TestDirStatsBackfill(t *testing.T) {
+	mp := newDirStatTestPartition(t)
+	dirMode := proto.Mode(os.ModeDir | 0o755)
+
+	// the dentries created before the stats are maintained
+	mp.dirStats.end()
+	createDirStatTestDentry(t, mp, proto.RootIno, 2, "old", dirMode)
+	createDirStatTestDentry(t, mp, 2, 10, "f", 0o644)
+	mp.inodeTree.Get(NewInode(10, 0)).(*Inode).Size = 100
+	createDirStatTestDentry(t, mp, proto.RootIno, 200, "remote", 0o644)
+	mp.dirStats.begin()
+	createDirStatTestDentry(t, mp, proto.RootIno, 11, "new", 0o644)
+	requireDirStat(t, mp, proto.RootIno, 1, 0, 0)
+
+	// the dentries linked already are not counted again
+	for req := mp.nextDirStatBackfill(); req != nil; req = mp.nextDirStatBackfill() {
+		mp.fsmBackfillDirStat(req)
+	}
+	requireDirStat(t, mp, proto.RootIno, 2, 1, 100)
+	requireDirStat(t, mp, 2, 1, 0, 100)
+	updates := mp.dirStats.pendingUpdates(dirStatForwardBatchSize)
+	require.Len(t, updates, 1)
+	require.Equal(t, proto.DirStatUpdate{Seq: 1, Type: proto.DirStatBackfill, Inode: 200, Parent: proto.RootIno}, *updates[0])
+
+	mp.fsmBackfillDirStat(&dirStatBackfillRequest{Keys: []*dirStatBackfillKey{{Parent: 2, Name: "f"}}, Done: true})
+	requireDirStat(t, mp, proto.RootIno, 2, 1, 100)
+}
+
+func TestDirStatsDeletedFile(t *testing.T) {
+	mp := newDirStatTestPartition(t)
+	createDirStatTestDentry(t, mp, proto.RootIno, 10, "f", 0o644)
+	file := mp.inodeTree.Get(NewInode(10, 0)).(*Inode)
+	file.Size = 100
+	mp.updateDirStatSize(file, 0)
+	requireDirStat(t, mp, proto.RootIno, 1, 0, 100)
+
+	// the file deleted before the unlink of its dentry arrives is unlinked at once
+	mp.internalDeleteInode(NewInode(10, 0))
+	requireDirStat(t, mp, proto.RootIno, 0, 0, 0)
+	mp.applyDirStatUpdates(&proto.DirStatUpdate{Type: proto.DirStatUnlink, Inode: 10, Parent: proto.RootIno})
+	requireDirStat(t, mp, proto.RootIno, 0, 0, 0)
+	require.Equal(t, 0, mp.dirStatTree.Len())
+}
+
+func TestDirStatsMarshal(t *testing.T) {
+	mp := newDirStatTestPartition(t)
+	createDirStatTestDentry(t, mp, proto.RootIno, 2, "a", proto.Mode(os.ModeDir|0o755))
+	createDirStatTestDentry(t, mp, 2, 10, "f", 0o644)
+	ds := mp.dirStats
+	ds.queue(&proto.DirStatUpdate{Type: proto.DirStatUnlink, Inode: 200, Parent: 1})
+	ds.accept(2, &proto.DirStatUpdate{Seq: 3})
+	ds.setBackfillCursor(false, 1, "a")
+	data, crc, err := ds.Marshal(mp.dirStatTree)
+	require.NoError(t, err)
+	require.NotZero(t, crc)
+
+	loaded, tree := newDirStats(), NewBtree()
+	require.NoError(t, loaded.UnMarshal(data, tree))
+	require.Equal(t, ds.pending, loaded.pending)
+	require.Equal(t, ds.applied, loaded.applied)
+	require.Equal(t, ds.seq, loaded.seq)
+	done, parent, name := loaded.backfillCursor()
+	require.False(t, done)
+	require.Equal(t, uint64(1), parent)
+	require.Equal(t, "a", name)
+	require.Equal(t, 2, tree.Len())
+	require.Equal(t, &dirStatEntry{ino: 2, parents: []uint64{proto.RootIno}, files: 1}, tree.Get(&dirStatEntry{ino: 2}))
+	require.Error(t, newDirStats().UnMarshal(data[:len(data)-1], NewBtree()))
+
+	// the state is sent without the entries in the raft snapshot
+	data, _, err = ds.Marshal(nil)
+	require.NoError(t, err)
+	require.NoError(t, newDirStats().UnMarshal(data, nil))
+
+	// the file keeps its parents through the marshal of the inode
+	file := mp.inodeTree.Get(NewInode(10, 0)).(*Inode)
+	raw, err := file.Marshal()
+	require.NoError(t, err)
+	ino := NewInode(0, 0)
+	require.NoError(t, ino.Unmarshal(raw))
+	require.Equal(t, []uint64{2}, ino.StatParents)
+}
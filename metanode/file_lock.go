@@ -0,0 +1,93 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/json"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+const innerFileLockKey = "cfs_inner_xattr_file_lock_key"
+
+// fileLocks is the file locks held on an inode, which are kept in the extend of the inode.
+type fileLocks []*proto.FileLock
+
+func parseFileLocks(data []byte) (locks fileLocks, err error) {
+	if len(data) == 0 {
+		return
+	}
+	err = json.Unmarshal(data, &locks)
+	return
+}
+
+// purge drops the locks whose leases have expired.
+func (locks fileLocks) purge(now int64) fileLocks {
+	out := locks[:0]
+	for _, lk := range locks {
+		if lk.Expire > now {
+			out = append(out, lk)
+		}
+	}
+	return out
+}
+
+// conflict returns the first lock which conflicts with the given one.
+func (locks fileLocks) conflict(lk *proto.FileLock) *proto.FileLock {
+	for _, held := range locks {
+		if held.Conflicts(lk) {
+			return held
+		}
+	}
+	return nil
+}
+
+// set applies the lock with the POSIX semantics, the locks of the same owner within the byte
+// range are replaced by the new one, or released if the type is FileLockUnlock. A lock partly
+// covered by the range is split to keep the uncovered parts.
+func (locks fileLocks) set(lk *proto.FileLock) fileLocks {
+	out := make(fileLocks, 0, len(locks)+2)
+	for _, held := range locks {
+		if !held.SameOwner(lk) || !held.Overlaps(lk) {
+			out = append(out, held)
+			continue
+		}
+		if held.Start < lk.Start {
+			left := *held
+			left.End = lk.Start - 1
+			out = append(out, &left)
+		}
+		if held.End > lk.End {
+			right := *held
+			right.Start = lk.End + 1
+			out = append(out, &right)
+		}
+	}
+	if lk.Type != proto.FileLockUnlock {
+		out = append(out, lk)
+	}
+	return out
+}
+
+// renew extends the leases of the locks held by the client, and returns the number of them.
+func (locks fileLocks) renew(client uint64, expire int64) (held int) {
+	for _, lk := range locks {
+		if lk.Client == client {
+			lk.Expire = expire
+			held++
+		}
+	}
+	return
+}
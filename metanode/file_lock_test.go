@@ -0,0 +1,115 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileLocksSet(t *testing.T) {
+	owner := &proto.FileLock{Client: 1, Owner: 1, Type: proto.FileLockWrite, Start: 0, End: 99}
+	locks := fileLocks{}.set(owner)
+	require.Len(t, locks, 1)
+
+	// unlock the middle of the range splits the lock
+	unlock := *owner
+	unlock.Type = proto.FileLockUnlock
+	unlock.Start, unlock.End = 10, 19
+	locks = locks.set(&unlock)
+	require.Len(t, locks, 2)
+	require.Equal(t, uint64(9), locks[0].End)
+	require.Equal(t, uint64(20), locks[1].Start)
+	require.Equal(t, uint64(99), owner.End)
+
+	// the range unlocked is free for others, while the rest is not
+	other := &proto.FileLock{Client: 2, Owner: 1, Type: proto.FileLockRead, Start: 10, End: 19}
+	require.Nil(t, locks.conflict(other))
+	other.End = 20
+	require.NotNil(t, locks.conflict(other))
+
+	// downgrade to read lock, which is shared with others
+	read := *owner
+	read.Type = proto.FileLockRead
+	read.End = math.MaxUint64
+	locks = locks.set(&read)
+	require.Len(t, locks, 1)
+	require.Nil(t, locks.conflict(other))
+	other.Type = proto.FileLockWrite
+	require.NotNil(t, locks.conflict(other))
+
+	// flock and POSIX locks do not conflict
+	other.Flock = true
+	require.Nil(t, locks.conflict(other))
+
+	unlock.Start, unlock.End = 0, math.MaxUint64
+	require.Empty(t, locks.set(&unlock))
+}
+
+func TestFileLocksExpire(t *testing.T) {
+	locks := fileLocks{
+		{Client: 1, Owner: 1, Type: proto.FileLockWrite, End: 9, Expire: 10},
+		{Client: 1, Owner: 2, Type: proto.FileLockWrite, Start: 10, End: 19, Expire: 20},
+		{Client: 2, Owner: 1, Type: proto.FileLockWrite, Start: 20, End: 29, Expire: 20},
+	}
+	require.Equal(t, 2, locks.renew(1, 30))
+	locks = locks.purge(20)
+	require.Len(t, locks, 2)
+	require.Equal(t, uint64(2), locks[1].Owner)
+}
+
+func TestFsmFileLock(t *testing.T) {
+	mp := &metaPartition{extendTree: NewBtree()}
+	now := time.Now()
+	req := func(client uint64, typ uint8, submit time.Time) *proto.FileLockRequest {
+		return &proto.FileLockRequest{
+			Inode:      1,
+			Op:         proto.FileLockOpSet,
+			Lock:       proto.FileLock{Client: client, Owner: 1, Type: typ, End: math.MaxUint64},
+			Lease:      10,
+			SubmitTime: submit,
+		}
+	}
+
+	resp := mp.fsmFileLock(req(1, proto.FileLockWrite, now))
+	require.Equal(t, proto.OpOk, resp.Status)
+	resp = mp.fsmFileLock(req(2, proto.FileLockRead, now))
+	require.Equal(t, proto.OpExistErr, resp.Status)
+	require.Equal(t, uint64(1), resp.Conflict.Client)
+
+	query := req(2, proto.FileLockRead, now)
+	query.Op = proto.FileLockOpGet
+	require.NotNil(t, mp.getFileLock(query).Conflict)
+
+	// the lock is taken over by others once the lease expires
+	resp = mp.fsmFileLock(req(2, proto.FileLockWrite, now.Add(11*time.Second)))
+	require.Equal(t, proto.OpOk, resp.Status)
+
+	renew := req(2, proto.FileLockUnlock, now.Add(15*time.Second))
+	renew.Op = proto.FileLockOpRenew
+	require.Equal(t, 1, mp.fsmFileLock(renew).Held)
+	resp = mp.fsmFileLock(req(1, proto.FileLockWrite, now.Add(22*time.Second)))
+	require.Equal(t, proto.OpExistErr, resp.Status)
+
+	resp = mp.fsmFileLock(req(2, proto.FileLockUnlock, now.Add(22*time.Second)))
+	require.Equal(t, proto.OpOk, resp.Status)
+	item := mp.extendTree.Get(NewExtend(1))
+	_, ok := item.(*Extend).Get([]byte(innerFileLockKey))
+	require.False(t, ok)
+}
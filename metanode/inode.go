@@ -46,6 +46,10 @@ const (
 	V4EnableHybridCloud   uint64 = 0x08
 	// V4EBSExtentsFlag       uint64 = 0x20
 	V4MigrationExtentsFlag uint64 = 0x40
+	V5InlineDataFlag       uint64 = 0x80
+	// the replica extents are followed by the compression fields of the keys
+	V6CompressedExtentsFlag uint64 = 0x100
+	V7DirStatParentsFlag    uint64 = 0x200
 )
 
 // Inode wraps necessary properties of `Inode` information in the file system.
@@ -102,6 +106,14 @@ type Inode struct {
 	HybridCloudExtentsMigration *SortedHybridCloudExtentsMigration
 	ClientID                    uint32
 	LeaseExpireTime             uint64
+
+	// the data of the tiny file kept in the inode instead of extents, the bytes after it up to
+	// Size are zeros. It's dropped once the file is stored in extents.
+	InlineData []byte
+
+	// the parents linking the file counted by the dir stats, the file adds its Size to the stats
+	// of the first one, see dir_stat.go.
+	StatParents []uint64
 }
 
 func (i *Inode) LeaseNotExpire() bool {
@@ -463,6 +475,9 @@ func (i *Inode) String() string {
 	}
 	buff.WriteString(fmt.Sprintf("ClientID[%v]", i.ClientID))
 	buff.WriteString(fmt.Sprintf("LeaseExpireTime[%v]", i.LeaseExpireTime))
+	if len(i.InlineData) > 0 {
+		buff.WriteString(fmt.Sprintf("InlineData[%v]", len(i.InlineData)))
+	}
 	buff.WriteString("}")
 	return buff.String()
 }
@@ -529,6 +544,8 @@ func (i *Inode) Copy() BtreeItem {
 	newIno.Extents = i.Extents.Clone()
 	newIno.LeaseExpireTime = i.LeaseExpireTime
 	newIno.ClientID = i.ClientID
+	newIno.InlineData = i.copyInlineData()
+	newIno.StatParents = i.copyStatParents()
 	// newIno.ObjExtents = i.ObjExtents.Clone()
 	if i.multiSnap != nil {
 		newIno.multiSnap = &InodeMultiSnap{
@@ -586,6 +603,8 @@ func (i *Inode) CopyDirectly() BtreeItem {
 	newIno.Extents = i.Extents.Clone()
 	newIno.LeaseExpireTime = i.LeaseExpireTime
 	newIno.ClientID = i.ClientID
+	newIno.InlineData = i.copyInlineData()
+	newIno.StatParents = i.copyStatParents()
 	// newIno.ObjExtents = i.ObjExtents.Clone()
 	if i.HybridCloudExtents.sortedEks != nil {
 		if proto.IsStorageClassReplica(i.StorageClass) {
@@ -827,6 +846,15 @@ func (i *Inode) MarshalInodeValue(buff *bytes.Buffer) {
 		reserved |= V4MigrationExtentsFlag
 		log.LogDebugf("MarshalInodeValue ino(%v) V4MigrationExtentsFlag", i.Inode)
 	}
+	if len(i.InlineData) > 0 {
+		reserved |= V5InlineDataFlag
+	}
+	if i.hasCompressedExtents() {
+		reserved |= V6CompressedExtentsFlag
+	}
+	if len(i.StatParents) > 0 {
+		reserved |= V7DirStatParentsFlag
+	}
 
 	log.LogDebugf("MarshalInodeValue ino(%v) storageClass(%v) Reserved(%v) ClientID(%v) LeaseExpireTime(%v)",
 		i.Inode, i.StorageClass, reserved, i.ClientID, i.LeaseExpireTime)
@@ -875,6 +903,9 @@ func (i *Inode) MarshalInodeValue(buff *bytes.Buffer) {
 		if _, err = buff.Write(extData); err != nil {
 			panic(err)
 		}
+		if reserved&V6CompressedExtentsFlag > 0 && len(extData) > 0 {
+			marshalCompressExtents(buff, replicaExtents)
+		}
 	}
 
 	if i.multiSnap != nil {
@@ -893,6 +924,22 @@ func (i *Inode) MarshalInodeValue(buff *bytes.Buffer) {
 	if err = binary.Write(buff, binary.BigEndian, &i.LeaseExpireTime); err != nil {
 		panic(err)
 	}
+	if reserved&V5InlineDataFlag > 0 {
+		if err = binary.Write(buff, binary.BigEndian, uint32(len(i.InlineData))); err != nil {
+			panic(err)
+		}
+		if _, err = buff.Write(i.InlineData); err != nil {
+			panic(err)
+		}
+	}
+	if reserved&V7DirStatParentsFlag > 0 {
+		if err = binary.Write(buff, binary.BigEndian, uint32(len(i.StatParents))); err != nil {
+			panic(err)
+		}
+		if err = binary.Write(buff, binary.BigEndian, i.StatParents); err != nil {
+			panic(err)
+		}
+	}
 
 	if reserved&V4MigrationExtentsFlag > 0 {
 		sem := i.HybridCloudExtentsMigration
@@ -930,6 +977,9 @@ func (i *Inode) MarshalInodeValue(buff *bytes.Buffer) {
 			if _, err = buff.Write(extData); err != nil {
 				panic(err)
 			}
+			if reserved&V6CompressedExtentsFlag > 0 && len(extData) > 0 {
+				marshalCompressExtents(buff, replicaExtents)
+			}
 		} else if proto.IsStorageClassBlobStore(sem.storageClass) {
 			log.LogDebugf("MarshalInodeValue ino(%v)migrationStorageClass(%v) marshall V4MigrationExtentsFlag SortedObjExtents Reserved(%v) ",
 				i.Inode, sem.storageClass, reserved)
@@ -1145,6 +1195,12 @@ func (i *Inode) UnmarshalInodeValue(buff *bytes.Buffer) (err error) {
 				err = UnmarshalInodeFiledError("HybridCloudExtents.SortedExtents(v4)", err)
 				return
 			}
+			if i.Reserved&V6CompressedExtentsFlag > 0 {
+				if err = unmarshalCompressExtents(buff, eks); err != nil {
+					err = UnmarshalInodeFiledError("HybridCloudExtents.CompressExtents(v6)", err)
+					return
+				}
+			}
 			i.HybridCloudExtents.sortedEks = eks
 			if ekRef != nil {
 				if i.multiSnap == nil {
@@ -1188,6 +1244,36 @@ func (i *Inode) UnmarshalInodeValue(buff *bytes.Buffer) (err error) {
 			err = UnmarshalInodeFiledError("LeaseExpireTime(v4)", err)
 			return
 		}
+		if i.Reserved&V5InlineDataFlag > 0 {
+			inlineSize := uint32(0)
+			if err = binary.Read(buff, binary.BigEndian, &inlineSize); err != nil {
+				err = UnmarshalInodeFiledError("inlineSize(v5)", err)
+				return
+			}
+			if inlineSize > proto.MaxInlineDataSize {
+				return proto.ErrBufferSizeExceedMaximum
+			}
+			i.InlineData = make([]byte, inlineSize)
+			if _, err = io.ReadFull(buff, i.InlineData); err != nil {
+				err = UnmarshalInodeFiledError("InlineData(v5)", err)
+				return
+			}
+		}
+		if i.Reserved&V7DirStatParentsFlag > 0 {
+			parents := uint32(0)
+			if err = binary.Read(buff, binary.BigEndian, &parents); err != nil {
+				err = UnmarshalInodeFiledError("StatParents(v7)", err)
+				return
+			}
+			if int(parents)*8 > buff.Len() {
+				return proto.ErrBufferSizeExceedMaximum
+			}
+			i.StatParents = make([]uint64, parents)
+			if err = binary.Read(buff, binary.BigEndian, i.StatParents); err != nil {
+				err = UnmarshalInodeFiledError("StatParents(v7)", err)
+				return
+			}
+		}
 
 		if i.StorageClass == proto.StorageClass_Unspecified && isFile {
 			i.StorageClass = proto.StorageClass_BlobStore
@@ -1224,6 +1310,12 @@ func (i *Inode) UnmarshalInodeValue(buff *bytes.Buffer) (err error) {
 						err = UnmarshalInodeFiledError("HybridCloudExtentsMigration.SortedExtents(v4)", err)
 						return
 					}
+					if i.Reserved&V6CompressedExtentsFlag > 0 {
+						if err = unmarshalCompressExtents(buff, i.HybridCloudExtentsMigration.sortedEks.(*SortedExtents)); err != nil {
+							err = UnmarshalInodeFiledError("HybridCloudExtentsMigration.CompressExtents(v6)", err)
+							return
+						}
+					}
 				}
 
 			} else if proto.IsStorageClassBlobStore(i.HybridCloudExtentsMigration.storageClass) {
@@ -1253,6 +1345,44 @@ func (i *Inode) UnmarshalInodeValue(buff *bytes.Buffer) (err error) {
 	return
 }
 
+// hasCompressedExtents returns if any replica extent key of the inode, including the keys being
+// migrated, refers to a compressed block.
+func (i *Inode) hasCompressedExtents() bool {
+	if i.HybridCloudExtents != nil {
+		if eks, ok := i.HybridCloudExtents.sortedEks.(*SortedExtents); ok && eks.HasCompressed() {
+			return true
+		}
+	}
+	if i.HybridCloudExtentsMigration != nil {
+		if eks, ok := i.HybridCloudExtentsMigration.sortedEks.(*SortedExtents); ok && eks.HasCompressed() {
+			return true
+		}
+	}
+	return false
+}
+
+func marshalCompressExtents(buff *bytes.Buffer, eks *SortedExtents) {
+	data := eks.MarshalCompressBinary()
+	if err := binary.Write(buff, binary.BigEndian, uint32(len(data))); err != nil {
+		panic(err)
+	}
+	if _, err := buff.Write(data); err != nil {
+		panic(err)
+	}
+}
+
+func unmarshalCompressExtents(buff *bytes.Buffer, eks *SortedExtents) (err error) {
+	size := uint32(0)
+	if err = binary.Read(buff, binary.BigEndian, &size); err != nil {
+		return
+	}
+	data := make([]byte, size)
+	if _, err = io.ReadFull(buff, data); err != nil {
+		return
+	}
+	return eks.UnmarshalCompressBinary(data)
+}
+
 func (i *Inode) GetSpaceSize() (extSize uint64) {
 	if i.IsTempFile() || !i.IsFile() {
 		return
@@ -1262,6 +1392,7 @@ func (i *Inode) GetSpaceSize() (extSize uint64) {
 	}
 	if proto.IsStorageClassReplica(i.StorageClass) {
 		extSize += i.HybridCloudExtents.sortedEks.(*SortedExtents).LayerSize()
+		extSize += uint64(len(i.InlineData))
 	} else {
 		extSize += i.HybridCloudExtents.sortedEks.(*SortedObjExtents).LayerSize()
 	}
@@ -1341,6 +1472,9 @@ func (i *Inode) AppendExtents(eks []proto.ExtentKey, ct int64, volType int) (del
 		}
 		delExtents = append(delExtents, delItems...)
 	}
+	if len(eks) > 0 {
+		i.InlineData = nil
+	}
 	i.Generation++
 	i.ModifyTime = ct
 
@@ -2094,6 +2228,7 @@ func (i *Inode) AppendExtentWithCheck(param *AppendExtParam) (delExtents []proto
 		if i.Size < size {
 			i.Size = size
 		}
+		i.InlineData = nil
 		i.Generation++
 		i.ModifyTime = param.ct
 	}
@@ -2101,6 +2236,15 @@ func (i *Inode) AppendExtentWithCheck(param *AppendExtParam) (delExtents []proto
 }
 
 func (i *Inode) ExtentsTruncate(length uint64, ct int64, doOnLastKey func(*proto.ExtentKey), insertRefMap func(ek *proto.ExtentKey)) (delExtents []proto.ExtentKey) {
+	if len(i.InlineData) > 0 {
+		if uint64(len(i.InlineData)) > length {
+			i.InlineData = i.InlineData[:length:length]
+		}
+		i.Size = length
+		i.ModifyTime = ct
+		i.Generation++
+		return
+	}
 	if i.HybridCloudExtents.sortedEks != nil {
 		extents := i.HybridCloudExtents.sortedEks.(*SortedExtents)
 		delExtents = extents.Truncate(length, doOnLastKey, insertRefMap)
@@ -2111,6 +2255,58 @@ func (i *Inode) ExtentsTruncate(length uint64, ct int64, doOnLastKey func(*proto
 	return
 }
 
+// Fallocate punches the range of extents if the mode asks to, and extends the size unless
+// proto.FallocKeepSize is set. The modes only allocating the range are rejected before proposed,
+// and are kept here for the entries proposed by the older versions.
+func (i *Inode) Fallocate(mode uint32, offset, length uint64, ct int64, insertRefMap func(ek *proto.ExtentKey)) (delExtents []proto.ExtentKey) {
+	if mode&(proto.FallocPunchHole|proto.FallocZeroRange) != 0 && i.HybridCloudExtents.sortedEks != nil {
+		extents := i.HybridCloudExtents.sortedEks.(*SortedExtents)
+		delExtents = extents.PunchHole(offset, length, insertRefMap)
+	}
+	if mode&proto.FallocKeepSize == 0 && offset+length > i.Size {
+		i.Size = offset + length
+	}
+	i.ModifyTime = ct
+	i.Generation++
+	return
+}
+
+func (i *Inode) copyStatParents() []uint64 {
+	if len(i.StatParents) == 0 {
+		return nil
+	}
+	return append([]uint64(nil), i.StatParents...)
+}
+
+func (i *Inode) copyInlineData() []byte {
+	if len(i.InlineData) == 0 {
+		return nil
+	}
+	data := make([]byte, len(i.InlineData))
+	copy(data, i.InlineData)
+	return data
+}
+
+// WriteInline writes the data at the offset of the inline data, the hole before the offset is
+// filled with zeros. The inline data is replaced rather than modified in place, as it may be
+// shared by the snapshot of the partition being stored.
+func (i *Inode) WriteInline(offset uint64, data []byte, ct int64) {
+	end := offset + uint64(len(data))
+	size := uint64(len(i.InlineData))
+	if size < end {
+		size = end
+	}
+	inline := make([]byte, size)
+	copy(inline, i.InlineData)
+	copy(inline[offset:], data)
+	i.InlineData = inline
+	if i.Size < end {
+		i.Size = end
+	}
+	i.ModifyTime = ct
+	i.Generation++
+}
+
 // IncNLink increases the nLink value by one.
 func (i *Inode) IncNLink(verSeq uint64) {
 	if i.getVer() < verSeq {
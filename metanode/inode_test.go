@@ -631,3 +631,51 @@ func (i *OldVersionInode) UnmarshalValue(val []byte) (err error) {
 
 	return
 }
+
+func TestInlineDataInode_Marshal(t *testing.T) {
+	ino := NewInode(1024, FileModeType)
+	ino.StorageClass = proto.StorageClass_Replica_SSD
+	ino.HybridCloudExtents.sortedEks = NewSortedExtents()
+	ino.WriteInline(4, []byte("hello"), timeutil.GetCurrentTimeUnix())
+	require.Equal(t, uint64(9), ino.Size)
+	require.Equal(t, []byte("\x00\x00\x00\x00hello"), ino.InlineData)
+
+	data, err := ino.Marshal()
+	require.NoError(t, err)
+	targetIno := NewInode(0, 0)
+	require.NoError(t, targetIno.Unmarshal(data))
+	require.Equal(t, ino.InlineData, targetIno.InlineData)
+	require.True(t, targetIno.Reserved&V5InlineDataFlag > 0)
+
+	temp := ino.Copy().(*Inode)
+	require.Equal(t, ino.InlineData, temp.InlineData)
+	temp.InlineData[0] = 1
+	require.Zero(t, ino.InlineData[0])
+}
+
+func TestCompressedExtentsInode_Marshal(t *testing.T) {
+	ino := NewInode(1024, FileModeType)
+	ino.StorageClass = proto.StorageClass_Replica_SSD
+	eks := NewSortedExtents()
+	eks.eks = append(eks.eks,
+		proto.ExtentKey{FileOffset: 0, PartitionId: 1, ExtentId: 1024, Size: 1000, CompressSize: 300},
+		proto.ExtentKey{FileOffset: 1000, PartitionId: 1, ExtentId: 1024, ExtentOffset: 300, Size: 500, CompressSize: 200, CompressOffset: 100})
+	ino.HybridCloudExtents.sortedEks = eks
+	ino.Size = 1500
+
+	data, err := ino.Marshal()
+	require.NoError(t, err)
+	targetIno := NewInode(0, 0)
+	require.NoError(t, targetIno.Unmarshal(data))
+	require.True(t, targetIno.Reserved&V6CompressedExtentsFlag > 0)
+	require.True(t, eks.Equals(targetIno.HybridCloudExtents.sortedEks.(*SortedExtents)))
+
+	eks.eks[0].CompressSize = 0
+	eks.eks[1].CompressSize, eks.eks[1].CompressOffset = 0, 0
+	data, err = ino.Marshal()
+	require.NoError(t, err)
+	targetIno = NewInode(0, 0)
+	require.NoError(t, targetIno.Unmarshal(data))
+	require.Zero(t, targetIno.Reserved&V6CompressedExtentsFlag)
+	require.True(t, eks.Equals(targetIno.HybridCloudExtents.sortedEks.(*SortedExtents)))
+}
@@ -0,0 +1,178 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+// KVStore is the ordered key-value store which persists the metadata of the meta partitions not
+// in proto.StoreModeMem, all such partitions of the metanode share one store.
+type KVStore interface {
+	// Get returns the value of the key in the snapshot, or the latest one if snap is nil.
+	// A nil value is returned if the key does not exist.
+	Get(snap KVSnapshot, key []byte) ([]byte, error)
+	// Range calls fn on the keys in [start, end) of the snapshot in order until fn returns false,
+	// the key and value passed to fn are owned by fn.
+	Range(snap KVSnapshot, start, end []byte, fn func(key, value []byte) bool) error
+	// Write applies the batch atomically.
+	Write(batch *KVBatch, sync bool) error
+	// NewSnapshot returns a consistent view of the store, which must be released after use.
+	NewSnapshot() KVSnapshot
+	Close() error
+}
+
+// KVSnapshot is a point-in-time view of a KVStore.
+type KVSnapshot interface {
+	Release()
+}
+
+type KVOpType uint8
+
+const (
+	KVOpPut KVOpType = iota
+	KVOpDelete
+	KVOpDeleteRange
+)
+
+// KVOp is an operation of KVBatch, the Value is the end key for KVOpDeleteRange.
+type KVOp struct {
+	Type  KVOpType
+	Key   []byte
+	Value []byte
+}
+
+// KVBatch is the operations applied to a KVStore in order and atomically.
+type KVBatch struct {
+	Ops []KVOp
+}
+
+func (b *KVBatch) Put(key, value []byte) {
+	b.Ops = append(b.Ops, KVOp{Type: KVOpPut, Key: key, Value: value})
+}
+
+func (b *KVBatch) Delete(key []byte) {
+	b.Ops = append(b.Ops, KVOp{Type: KVOpDelete, Key: key})
+}
+
+// DeleteRange deletes the keys in [start, end).
+func (b *KVBatch) DeleteRange(start, end []byte) {
+	b.Ops = append(b.Ops, KVOp{Type: KVOpDeleteRange, Key: start, Value: end})
+}
+
+// KVStoreOpener opens the KVStore in the directory.
+type KVStoreOpener func(dir string) (KVStore, error)
+
+var (
+	kvStoreOpenersMu sync.RWMutex
+	kvStoreOpeners   = make(map[proto.StoreMode]KVStoreOpener)
+)
+
+// RegisterKVStore registers the KVStore of the store mode. The engines depending on cgo are
+// registered by the packages imported by the server binary, so that this package keeps free
+// of cgo.
+func RegisterKVStore(mode proto.StoreMode, opener KVStoreOpener) {
+	kvStoreOpenersMu.Lock()
+	kvStoreOpeners[mode] = opener
+	kvStoreOpenersMu.Unlock()
+}
+
+func openKVStore(mode proto.StoreMode, dir string) (KVStore, error) {
+	kvStoreOpenersMu.RLock()
+	opener, ok := kvStoreOpeners[mode]
+	kvStoreOpenersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("meta store mode %v is not supported by this metanode", mode)
+	}
+	return opener(dir)
+}
+
+// The keys in KVStore are prefixed by the partition id and the table of the partition. The keys
+// of the trees are further prefixed by the generation of the trees, a raft snapshot is written
+// into a new generation, which replaces the current one once committed with the snapshot files.
+const (
+	kvTableMeta byte = iota + 1 // the generation and the item counts of the trees
+	kvTableFile                 // the snapshot files other than the trees
+	kvTableInode
+	kvTableDentry
+	kvTableExtend
+	kvTableMultipart
+	kvTableDirStat
+)
+
+// kvMetaGeneration is the key of the generation of the trees in the meta table, the keys of the
+// item counts are the table and the generation of the tree.
+const kvMetaGeneration byte = 0
+
+var kvTreeTables = []byte{kvTableInode, kvTableDentry, kvTableExtend, kvTableMultipart, kvTableDirStat}
+
+func kvPartitionPrefix(pid uint64) []byte {
+	prefix := make([]byte, 8, 9)
+	binary.BigEndian.PutUint64(prefix, pid)
+	return prefix
+}
+
+func kvTablePrefix(pid uint64, table byte) []byte {
+	return append(kvPartitionPrefix(pid), table)
+}
+
+func kvGenerationKey(pid uint64) []byte {
+	return kvKey(kvTablePrefix(pid, kvTableMeta), []byte{kvMetaGeneration})
+}
+
+func kvCountPrefix(pid uint64, table byte) []byte {
+	return kvKey(kvTablePrefix(pid, kvTableMeta), []byte{table})
+}
+
+// kvTreePrefix returns the prefix of the keys of the tree of the generation.
+func kvTreePrefix(pid uint64, table byte, gen uint64) []byte {
+	return kvKey(kvTablePrefix(pid, table), uint64Key(gen))
+}
+
+func kvCountKey(pid uint64, table byte, gen uint64) []byte {
+	return kvKey(kvCountPrefix(pid, table), uint64Key(gen))
+}
+
+// deleteOtherGenerations deletes the trees of the generations other than gen.
+func deleteOtherGenerations(batch *KVBatch, pid, gen uint64) {
+	for _, table := range kvTreeTables {
+		for _, prefix := range [][]byte{kvTablePrefix(pid, table), kvCountPrefix(pid, table)} {
+			current := kvKey(prefix, uint64Key(gen))
+			batch.DeleteRange(prefix, current)
+			batch.DeleteRange(kvPrefixEnd(current), kvPrefixEnd(prefix))
+		}
+	}
+}
+
+func kvKey(prefix, key []byte) []byte {
+	k := make([]byte, 0, len(prefix)+len(key))
+	return append(append(k, prefix...), key...)
+}
+
+// kvPrefixEnd returns the smallest key greater than all the keys with the prefix.
+func kvPrefixEnd(prefix []byte) []byte {
+	end := append([]byte(nil), prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		end[i]++
+		if end[i] != 0 {
+			return end[:i+1]
+		}
+	}
+	return nil
+}
@@ -103,6 +103,8 @@ type metadataManager struct {
 	verUpdateChan        chan string
 	enableGcTimer        bool
 	gcTimer              *util.RecycleTimer
+	kvStoreMu            sync.Mutex
+	kvStores             map[proto.StoreMode]KVStore
 }
 
 func (m *metadataManager) GetAllVolumes() (volumes *util.Set) {
@@ -257,6 +259,8 @@ func (m *metadataManager) HandleMetadataOperation(conn net.Conn, p *Packet, remo
 		err = m.opBatchDeleteDentry(conn, p, remoteAddr)
 	case proto.OpMetaUpdateDentry:
 		err = m.opUpdateDentry(conn, p, remoteAddr)
+	case proto.OpMetaArchiveDentry:
+		err = m.opArchiveDentry(conn, p, remoteAddr)
 	case proto.OpMetaReadDir:
 		err = m.opReadDir(conn, p, remoteAddr)
 	case proto.OpMetaReadDirOnly:
@@ -279,6 +283,22 @@ func (m *metadataManager) HandleMetadataOperation(conn net.Conn, p *Packet, remo
 		err = m.opMetaExtentsDel(conn, p, remoteAddr)
 	case proto.OpMetaTruncate:
 		err = m.opMetaExtentsTruncate(conn, p, remoteAddr)
+	case proto.OpMetaFallocate:
+		err = m.opMetaFallocate(conn, p, remoteAddr)
+	case proto.OpMetaFsckDeleteExtents:
+		err = m.opMetaFsckDeleteExtents(conn, p, remoteAddr)
+	case proto.OpMetaCloneInode:
+		err = m.opMetaCloneInode(conn, p, remoteAddr)
+	case proto.OpMetaWriteInline:
+		err = m.opMetaWriteInline(conn, p, remoteAddr)
+	case proto.OpMetaGetChanges:
+		err = m.opMetaGetChanges(conn, p, remoteAddr)
+	case proto.OpMetaGetDirStat:
+		err = m.opMetaGetDirStat(conn, p, remoteAddr)
+	case proto.OpMetaUpdateDirStat:
+		err = m.opMetaUpdateDirStat(conn, p, remoteAddr)
+	case proto.OpMetaReadIndex:
+		err = m.opMetaReadIndex(conn, p, remoteAddr)
 	case proto.OpMetaLookup:
 		err = m.opMetaLookup(conn, p, remoteAddr)
 	case proto.OpDeleteMetaPartition:
@@ -327,6 +347,9 @@ func (m *metadataManager) HandleMetadataOperation(conn net.Conn, p *Packet, remo
 	// operation for dir lock
 	case proto.OpMetaLockDir:
 		err = m.opMetaLockDir(conn, p, remoteAddr)
+	// operation for file lock
+	case proto.OpMetaFileLock:
+		err = m.opMetaFileLock(conn, p, remoteAddr)
 	// operations for multipart session
 	case proto.OpCreateMultipart:
 		err = m.opCreateMultipart(conn, p, remoteAddr)
@@ -536,6 +559,33 @@ func (m *metadataManager) onStop() {
 	if m.gcTimer != nil {
 		m.gcTimer.Stop()
 	}
+
+	m.kvStoreMu.Lock()
+	for mode, kv := range m.kvStores {
+		if err := kv.Close(); err != nil {
+			log.LogErrorf("onStop: close kv store(%v) err(%v)", mode, err)
+		}
+	}
+	m.kvStores = nil
+	m.kvStoreMu.Unlock()
+}
+
+// getKVStore returns the kv store of the store mode shared by the partitions, which is opened
+// on the first use.
+func (m *metadataManager) getKVStore(mode proto.StoreMode) (kv KVStore, err error) {
+	m.kvStoreMu.Lock()
+	defer m.kvStoreMu.Unlock()
+	if kv = m.kvStores[mode]; kv != nil {
+		return
+	}
+	if kv, err = openKVStore(mode, path.Join(m.rootDir, mode.String())); err != nil {
+		return
+	}
+	if m.kvStores == nil {
+		m.kvStores = make(map[proto.StoreMode]KVStore)
+	}
+	m.kvStores[mode] = kv
+	return
 }
 
 // LoadMetaPartition returns the meta partition with the specified volName.
@@ -732,6 +782,7 @@ func (m *metadataManager) createPartition(request *proto.CreateMetaPartitionRequ
 		RootDir:     path.Join(m.rootDir, partitionPrefix+partitionId),
 		ConnPool:    m.connPool,
 		VerSeq:      request.VerSeq,
+		StoreMode:   request.StoreMode,
 	}
 	mpc.AfterStop = func() {
 		m.detachPartition(request.PartitionID)
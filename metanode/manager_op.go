@@ -174,6 +174,7 @@ func (m *metadataManager) opMasterHeartbeat(conn net.Conn, p *Packet,
 				MaxInodeID:                mConf.Cursor,
 				VolName:                   mConf.VolName,
 				Size:                      partition.DataSize(),
+				LogicalSize:               partition.LogicalSize(),
 				InodeCnt:                  uint64(partition.GetInodeTreeLen()),
 				DentryCnt:                 uint64(partition.GetDentryTreeLen()),
 				FreeListLen:               uint64(partition.GetFreeListLen()),
@@ -183,6 +184,7 @@ func (m *metadataManager) opMasterHeartbeat(conn net.Conn, p *Packet,
 				StatByMigrateStorageClass: partition.GetMigrateStatByStorageClass(),
 				ForbidWriteOpOfProtoVer0:  mpForbidWriteVer0,
 				LocalPeers:                mConf.Peers,
+				RootDirStat:               partition.GetRootDirStat(),
 			}
 			mpr.TxCnt, mpr.TxRbInoCnt, mpr.TxRbDenCnt = partition.TxGetCnt()
 
@@ -872,6 +874,41 @@ func (m *metadataManager) opUpdateDentry(conn net.Conn, p *Packet,
 	return
 }
 
+func (m *metadataManager) opArchiveDentry(conn net.Conn, p *Packet,
+	remoteAddr string,
+) (err error) {
+	req := &proto.ArchiveDentryRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClientWithVer(conn, p)
+		err = errors.NewErrorf("[%v],req[%v],err[%v]", p.GetOpMsgWithReqAndResult(), req, string(p.Data))
+		return
+	}
+	mp, err := m.getPartition(req.PartitionID)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClientWithVer(conn, p)
+		err = errors.NewErrorf("[%v],req[%v],err[%v]", p.GetOpMsgWithReqAndResult(), req, string(p.Data))
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+
+	if err = m.checkMultiVersionStatus(mp, p); err != nil {
+		err = errors.NewErrorf("[%v],req[%v],err[%v]", p.GetOpMsgWithReqAndResult(), req, string(p.Data))
+		m.respondToClientWithVer(conn, p)
+		return
+	}
+
+	err = mp.ArchiveDentry(req, p, remoteAddr)
+	m.updatePackRspSeq(mp, p)
+	m.respondToClientWithVer(conn, p)
+	log.LogDebugf("%s [opArchiveDentry] req: %d - %v; resp: %v, body: %s",
+		remoteAddr, p.GetReqID(), req, p.GetResultMsg(), p.Data)
+	return
+}
+
 func (m *metadataManager) opTxMetaUnlinkInode(conn net.Conn, p *Packet, remoteAddr string) (err error) {
 	req := &proto.TxUnlinkInodeRequest{}
 	if err = json.Unmarshal(p.Data, req); err != nil {
@@ -1450,6 +1487,236 @@ func (m *metadataManager) opMetaExtentsTruncate(conn net.Conn, p *Packet,
 	return
 }
 
+func (m *metadataManager) opMetaFallocate(conn net.Conn, p *Packet,
+	remoteAddr string,
+) (err error) {
+	req := &proto.FallocateRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClientWithVer(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	mp, err := m.getPartition(req.PartitionID)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClientWithVer(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+
+	if err = m.checkForbidWriteOpOfProtoVer0(p.ProtoVersion, mp.IsForbidWriteOpOfProtoVer0()); err != nil {
+		log.LogWarnf("[opMetaFallocate] reqId(%v) mpId(%v) ino(%v) err: %v", p.ReqID, req.PartitionID, req.Inode, err)
+		p.PacketErrorWithBody(proto.OpWriteOpOfProtoVerForbidden, ([]byte)(err.Error()))
+		m.respondToClientWithVer(conn, p)
+		return
+	}
+
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+	if err = m.checkMultiVersionStatus(mp, p); err != nil {
+		err = errors.NewErrorf("[%v],req[%v],err[%v]", p.GetOpMsgWithReqAndResult(), req, string(p.Data))
+		m.respondToClientWithVer(conn, p)
+		return
+	}
+
+	if err = mp.Fallocate(req, p, remoteAddr); err != nil {
+		log.LogErrorf("[opMetaFallocate] mpId(%v) ino(%v) err: %v", req.PartitionID, req.Inode, err)
+	}
+
+	m.updatePackRspSeq(mp, p)
+	m.respondToClientWithVer(conn, p)
+	log.LogDebugf("%s [opMetaFallocate] req: %d - %v, resp body: %v, "+
+		"resp body: %s", remoteAddr, p.GetReqID(), req, p.GetResultMsg(), p.Data)
+	return
+}
+
+func (m *metadataManager) opMetaFsckDeleteExtents(conn net.Conn, p *Packet,
+	remoteAddr string,
+) (err error) {
+	req := &proto.FsckDeleteExtentsRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClientWithVer(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	mp, err := m.getPartition(req.PartitionID)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClientWithVer(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+
+	if err = mp.FsckDeleteExtents(req, p); err != nil {
+		log.LogErrorf("[opMetaFsckDeleteExtents] mpId(%v) extents(%v) err: %v", req.PartitionID, len(req.Extents), err)
+	}
+
+	m.respondToClientWithVer(conn, p)
+	log.LogDebugf("%s [opMetaFsckDeleteExtents] req: %d - %v, resp body: %v, "+
+		"resp body: %s", remoteAddr, p.GetReqID(), req, p.GetResultMsg(), p.Data)
+	return
+}
+
+func (m *metadataManager) opMetaCloneInode(conn net.Conn, p *Packet,
+	remoteAddr string,
+) (err error) {
+	req := &proto.CloneInodeRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClientWithVer(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	mp, err := m.getPartition(req.PartitionID)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClientWithVer(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+
+	if err = mp.CloneInode(req, p, remoteAddr); err != nil {
+		log.LogErrorf("[opMetaCloneInode] mpId(%v) ino(%v) dst(%v) err: %v", req.PartitionID, req.Inode, req.DstInode, err)
+	}
+
+	m.respondToClientWithVer(conn, p)
+	log.LogDebugf("%s [opMetaCloneInode] req: %d - %v, resp body: %v, "+
+		"resp body: %s", remoteAddr, p.GetReqID(), req, p.GetResultMsg(), p.Data)
+	return
+}
+
+func (m *metadataManager) opMetaWriteInline(conn net.Conn, p *Packet,
+	remoteAddr string,
+) (err error) {
+	req := &proto.WriteInlineRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClientWithVer(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	mp, err := m.getPartition(req.PartitionID)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClientWithVer(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+
+	if err = mp.WriteInline(req, p, remoteAddr); err != nil {
+		log.LogErrorf("[opMetaWriteInline] mpId(%v) ino(%v) offset(%v) size(%v) err: %v",
+			req.PartitionID, req.Inode, req.Offset, len(req.Data), err)
+	}
+
+	m.respondToClientWithVer(conn, p)
+	log.LogDebugf("%s [opMetaWriteInline] req: %d - ino(%v) offset(%v) size(%v), resp: %v",
+		remoteAddr, p.GetReqID(), req.Inode, req.Offset, len(req.Data), p.GetResultMsg())
+	return
+}
+
+func (m *metadataManager) opMetaGetChanges(conn net.Conn, p *Packet,
+	remoteAddr string,
+) (err error) {
+	req := &proto.GetChangesRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClientWithVer(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	mp, err := m.getPartition(req.PartitionID)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClientWithVer(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+
+	if err = mp.GetChanges(req, p); err != nil {
+		log.LogErrorf("[opMetaGetChanges] mpId(%v) from(%v) err: %v", req.PartitionID, req.From, err)
+	}
+
+	m.respondToClientWithVer(conn, p)
+	log.LogDebugf("%s [opMetaGetChanges] req: %d - pid(%v) from(%v) limit(%v), resp: %v",
+		remoteAddr, p.GetReqID(), req.PartitionID, req.From, req.Limit, p.GetResultMsg())
+	return
+}
+
+func (m *metadataManager) opMetaGetDirStat(conn net.Conn, p *Packet,
+	remoteAddr string,
+) (err error) {
+	req := &proto.GetDirStatRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClientWithVer(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	mp, err := m.getPartition(req.PartitionID)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClientWithVer(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+
+	if err = mp.GetDirStat(req, p); err != nil {
+		log.LogErrorf("[opMetaGetDirStat] mpId(%v) ino(%v) err: %v", req.PartitionID, req.Inode, err)
+	}
+
+	m.respondToClientWithVer(conn, p)
+	log.LogDebugf("%s [opMetaGetDirStat] req: %d - pid(%v) ino(%v), resp: %v",
+		remoteAddr, p.GetReqID(), req.PartitionID, req.Inode, p.GetResultMsg())
+	return
+}
+
+// opMetaUpdateDirStat applies the updates of the directory stats sent by the other metanode.
+func (m *metadataManager) opMetaUpdateDirStat(conn net.Conn, p *Packet,
+	remoteAddr string,
+) (err error) {
+	req := &proto.UpdateDirStatRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	mp, err := m.getPartition(req.PartitionID)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+
+	err = mp.UpdateDirStat(req, p)
+	m.respondToClient(conn, p)
+	log.LogDebugf("%s [opMetaUpdateDirStat] req: %d - pid(%v) from(%v) updates(%v), resp: %v",
+		remoteAddr, p.GetReqID(), req.PartitionID, req.From, len(req.Updates), p.GetResultMsg())
+	return
+}
+
 func (m *metadataManager) opMetaClearInodeCache(conn net.Conn, p *Packet,
 	remoteAddr string,
 ) (err error) {
@@ -1682,7 +1949,8 @@ func (m *metadataManager) opAddMetaPartitionRaftMember(conn net.Conn,
 		return err
 	}
 
-	if mp.IsExsitPeer(req.AddPeer) {
+	changeType, ok := mp.AddPeerChangeType(req.AddPeer)
+	if !ok {
 		p.PacketOkReply()
 		m.respondToClientWithVer(conn, p)
 		return
@@ -1708,7 +1976,7 @@ func (m *metadataManager) opAddMetaPartitionRaftMember(conn net.Conn,
 		m.respondToClientWithVer(conn, p)
 		return
 	}
-	_, err = mp.ChangeMember(raftProto.ConfAddNode,
+	_, err = mp.ChangeMember(changeType,
 		raftProto.Peer{ID: req.AddPeer.ID}, reqData)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
@@ -2055,6 +2323,40 @@ func (m *metadataManager) opMetaLockDir(conn net.Conn, p *Packet, remoteAddr str
 	return
 }
 
+func (m *metadataManager) opMetaFileLock(conn net.Conn, p *Packet, remoteAddr string) (err error) {
+	req := &proto.FileLockRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	mp, err := m.getPartition(req.PartitionId)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[%v] req: %v, resp: %v", p.GetOpMsgWithReqAndResult(), req, err.Error())
+		return
+	}
+	if !m.serveProxy(conn, mp, p) {
+		return
+	}
+
+	start := time.Now()
+	if mp.IsEnableAuditLog() {
+		defer func() {
+			err1 := fmt.Errorf("data(%s)_err(%v)_status(%s)", p.Data, err, p.GetResultMsg())
+			auditlog.LogInodeOp(remoteAddr, "", p.GetOpMsg(), req.String(), err1, time.Since(start).Milliseconds(), req.Inode, 0)
+		}()
+	}
+
+	err = mp.FileLock(req, p)
+	_ = m.respondToClient(conn, p)
+	log.LogDebugf("%s [opMetaFileLock] req: %d - %v, resp: %v, body: %s",
+		remoteAddr, p.GetReqID(), req, p.GetResultMsg(), p.Data)
+	return
+}
+
 func (m *metadataManager) opMetaGetAllXAttr(conn net.Conn, p *Packet, remoteAddr string) (err error) {
 	req := &proto.GetAllXAttrRequest{}
 	if err = json.Unmarshal(p.Data, req); err != nil {
@@ -2523,6 +2825,48 @@ func (m *metadataManager) opMetaGetAppliedID(conn net.Conn, p *Packet, remote st
 	return
 }
 
+// opMetaReadIndex serves the follower asking for the read index. It's never forwarded, the follower
+// turns to the leader it knows.
+func (m *metadataManager) opMetaReadIndex(conn net.Conn, p *Packet, remote string) (err error) {
+	req := &proto.ReadIndexRequest{}
+	if err = json.Unmarshal(p.Data, req); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[opMetaReadIndex] req: %v, resp: %v", req, err.Error())
+		return
+	}
+
+	mp, err := m.getPartition(req.PartitionID)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[opMetaReadIndex] req: %v, resp: %v", req, err.Error())
+		return
+	}
+
+	if _, ok := mp.IsLeader(); !ok {
+		err = ErrNotALeader
+		p.PacketErrorWithBody(proto.OpAgain, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		return
+	}
+
+	index, err := mp.ReadIndex()
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, ([]byte)(err.Error()))
+		m.respondToClient(conn, p)
+		err = errors.NewErrorf("[opMetaReadIndex] req: %v, resp: %v", req, err.Error())
+		return
+	}
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, index)
+	p.PacketOkWithBody(buf)
+	m.respondToClient(conn, p)
+	log.LogDebugf("%s [opMetaReadIndex] req: %d - %v, resp: %v, index: %v",
+		remote, p.GetReqID(), req, p.GetResultMsg(), index)
+	return
+}
+
 func (m *metadataManager) opMetaGetUniqID(conn net.Conn, p *Packet,
 	remoteAddr string,
 ) (err error) {
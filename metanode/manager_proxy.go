@@ -15,8 +15,10 @@
 package metanode
 
 import (
+	"encoding/binary"
 	"fmt"
 	"net"
+	"time"
 
 	"github.com/cubefs/cubefs/datanode/storage"
 
@@ -44,6 +46,7 @@ func (m *metadataManager) IsForbiddenOp(mp MetaPartition, reqOp uint8) bool {
 		proto.OpMetaBatchDeleteDentry,
 		proto.OpMetaUpdateDentry,
 		proto.OpMetaTxUpdateDentry,
+		proto.OpMetaArchiveDentry,
 		// extend
 		proto.OpMetaUpdateXAttr,
 		proto.OpMetaSetXAttr,
@@ -123,6 +126,16 @@ func (m *metadataManager) serveProxy(conn net.Conn, mp MetaPartition,
 		return
 	}
 
+	// the linearizable read served by the follower, or by the leader if it fails
+	if leaderAddr != "" && p.IsReadMetaPkt() && p.IsReadIndexMetaPkt() {
+		if err = m.applyReadIndex(mp, leaderAddr); err == nil {
+			log.LogDebugf("read from follower by read index: p(%v), pid(%v)", p, mp.GetBaseConfig().PartitionId)
+			return true
+		}
+		log.LogWarnf("serveProxy: read index from leader(%v) failed and forward to it: p(%v), err(%v)", leaderAddr, p, err)
+		err = nil
+	}
+
 	if leaderAddr == "" {
 		if followerRead() {
 			log.LogDebugf("read from follower: p(%v), arg(%v)", p, mp.GetBaseConfig().PartitionId)
@@ -175,3 +188,49 @@ end:
 		p.GetResultMsg(), p)
 	return
 }
+
+// applyReadIndex gets the read index from the leader and waits until the partition applies it, after
+// which the local read sees every write acknowledged before the read. The concurrent reads of the
+// partition share one round trip to the leader.
+func (m *metadataManager) applyReadIndex(mp MetaPartition, leaderAddr string) (err error) {
+	index, err := mp.BatchReadIndex(func() (uint64, error) {
+		return m.fetchReadIndex(mp, leaderAddr)
+	})
+	if err != nil {
+		return
+	}
+	return mp.WaitApplied(index, readIndexTimeout)
+}
+
+// fetchReadIndex asks the leader for the read index of the partition.
+func (m *metadataManager) fetchReadIndex(mp MetaPartition, leaderAddr string) (index uint64, err error) {
+	pid := mp.GetBaseConfig().PartitionId
+	p := proto.NewPacketReqID()
+	p.Opcode = proto.OpMetaReadIndex
+	p.PartitionID = pid
+	if err = p.MarshalData(&proto.ReadIndexRequest{VolName: mp.GetVolName(), PartitionID: pid}); err != nil {
+		return
+	}
+
+	mConn, err := m.connPool.GetConnect(leaderAddr)
+	if err != nil {
+		return
+	}
+	if err = p.WriteToConn(mConn); err != nil {
+		m.connPool.PutConnect(mConn, ForceClosedConnect)
+		return
+	}
+	if err = p.ReadFromConnWithVer(mConn, int(readIndexTimeout/time.Second)); err != nil {
+		m.connPool.PutConnect(mConn, ForceClosedConnect)
+		return
+	}
+	m.connPool.PutConnect(mConn, NoClosedConnect)
+
+	if p.ResultCode != proto.OpOk {
+		return 0, fmt.Errorf("mpId(%v) %v: %v", pid, p.GetResultMsg(), string(p.Data))
+	}
+	if len(p.Data) < 8 {
+		return 0, fmt.Errorf("mpId(%v) invalid read index of %v bytes", pid, len(p.Data))
+	}
+	return binary.BigEndian.Uint64(p.Data), nil
+}
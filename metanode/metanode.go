@@ -227,6 +227,13 @@ func (m *MetaNode) parseConfig(cfg *config.Config) (err error) {
 	}
 
 	m.serviceIDKey = cfg.GetString(cfgServiceIDKey)
+	changeLogCapacity = int(cfg.GetInt(cfgChangeLogCapacity))
+	if hours := cfg.GetInt64(cfgChangeLogRetentionHours); hours > 0 {
+		changeLogRetention = time.Duration(hours) * time.Hour
+	}
+	if sizeMB := cfg.GetInt64(cfgChangeLogMaxSizeMB); sizeMB > 0 {
+		changeLogMaxSize = sizeMB * util.MB
+	}
 
 	total, _, err := util.GetMemInfo()
 	if err != nil {
@@ -106,6 +106,34 @@ func NewPacketToBatchDeleteExtent(dp *DataPartition, exts []*proto.DelExtentPara
 	return p
 }
 
+// NewPacketToMarkSharedExtents returns a new packet to mark the extents of the data partition shared,
+// which are held by the holder as well if it is set.
+func NewPacketToMarkSharedExtents(dp *DataPartition, extentIDs []uint64, holder uint64) *Packet {
+	p := new(Packet)
+	p.Magic = proto.ProtoMagic
+	p.Opcode = proto.OpMarkSharedExtents
+	p.ExtentType = proto.NormalExtentType
+	p.PartitionID = dp.PartitionID
+	p.Data, _ = json.Marshal(&proto.MarkSharedExtentsRequest{PartitionId: dp.PartitionID, ExtentIds: extentIDs, Holder: holder})
+	p.Size = uint32(len(p.Data))
+	p.ReqID = proto.GenerateRequestID()
+	return p
+}
+
+// NewPacketToReleaseSharedExtents returns a new packet to release the extents of the data partition
+// held by the holder.
+func NewPacketToReleaseSharedExtents(dp *DataPartition, extentIDs []uint64, holder uint64) *Packet {
+	p := new(Packet)
+	p.Magic = proto.ProtoMagic
+	p.Opcode = proto.OpReleaseSharedExtents
+	p.ExtentType = proto.NormalExtentType
+	p.PartitionID = dp.PartitionID
+	p.Data, _ = json.Marshal(&proto.ReleaseSharedExtentsRequest{PartitionId: dp.PartitionID, Holders: []uint64{holder}, ExtentIds: extentIDs})
+	p.Size = uint32(len(p.Data))
+	p.ReqID = proto.GenerateRequestID()
+	return p
+}
+
 // NewPacketToDeleteExtent returns a new packet to delete the extent.
 func NewPacketToFreeInodeOnRaftFollower(partitionID uint64, freeInodes []byte) *Packet {
 	p := new(Packet)
@@ -94,6 +94,7 @@ type MetaPartitionConfig struct {
 	NodeId                   uint64              `json:"-"`
 	RootDir                  string              `json:"-"`
 	VerSeq                   uint64              `json:"ver_seq"`
+	StoreMode                proto.StoreMode     `json:"store_mode"`
 	BeforeStart              func()              `json:"-"`
 	AfterStart               func()              `json:"-"`
 	BeforeStop               func()              `json:"-"`
@@ -166,6 +167,7 @@ type OpExtend interface {
 	ListXAttr(req *proto.ListXAttrRequest, p *Packet) (err error)
 	UpdateXAttr(req *proto.UpdateXAttrRequest, p *Packet) (err error)
 	LockDir(req *proto.LockDirRequest, p *Packet) (err error)
+	FileLock(req *proto.FileLockRequest, p *Packet) (err error)
 }
 
 // OpDentry defines the interface for the dentry operations.
@@ -174,6 +176,7 @@ type OpDentry interface {
 	DeleteDentry(req *DeleteDentryReq, p *Packet, remoteAddr string) (err error)
 	DeleteDentryBatch(req *BatchDeleteDentryReq, p *Packet, remoteAddr string) (err error)
 	UpdateDentry(req *UpdateDentryReq, p *Packet, remoteAddr string) (err error)
+	ArchiveDentry(req *proto.ArchiveDentryRequest, p *Packet, remoteAddr string) (err error)
 	ReadDir(req *ReadDirReq, p *Packet) (err error)
 	ReadDirLimit(req *ReadDirLimitReq, p *Packet) (err error)
 	ReadDirOnly(req *ReadDirOnlyReq, p *Packet) (err error)
@@ -205,6 +208,10 @@ type OpExtent interface {
 	ExtentsList(req *proto.GetExtentsRequest, p *Packet) (err error)
 	ObjExtentsList(req *proto.GetExtentsRequest, p *Packet) (err error)
 	ExtentsTruncate(req *ExtentsTruncateReq, p *Packet, remoteAddr string) (err error)
+	Fallocate(req *proto.FallocateRequest, p *Packet, remoteAddr string) (err error)
+	FsckDeleteExtents(req *proto.FsckDeleteExtentsRequest, p *Packet) (err error)
+	CloneInode(req *proto.CloneInodeRequest, p *Packet, remoteAddr string) (err error)
+	WriteInline(req *proto.WriteInlineRequest, p *Packet, remoteAddr string) (err error)
 	BatchExtentAppend(req *proto.AppendExtentKeysRequest, p *Packet) (err error)
 	// ExtentsDelete(req *proto.DelExtentKeyRequest, p *Packet) (err error)
 }
@@ -258,6 +265,9 @@ type OpPartition interface {
 	GetUniqId() uint64
 	IsFollowerRead() bool
 	SetFollowerRead(bool)
+	ReadIndex() (index uint64, err error)
+	BatchReadIndex(fetch func() (uint64, error)) (index uint64, err error)
+	WaitApplied(index uint64, timeout time.Duration) error
 	GetBaseConfig() MetaPartitionConfig
 	ResponseLoadMetaPartition(p *Packet) (err error)
 	PersistMetadata() (err error)
@@ -267,10 +277,17 @@ type OpPartition interface {
 	UpdatePartition(req *UpdatePartitionReq, resp *UpdatePartitionResp) (err error)
 	DeleteRaft() error
 	IsExsitPeer(peer proto.Peer) bool
+	AddPeerChangeType(peer proto.Peer) (changeType raftproto.ConfChangeType, ok bool)
 	TryToLeader(groupID uint64) error
 	CanRemoveRaftMember(peer proto.Peer) error
 	IsEquareCreateMetaPartitionRequst(request *proto.CreateMetaPartitionRequest) (err error)
 	GetUniqID(p *Packet, num uint32) (err error)
+	GetChanges(req *proto.GetChangesRequest, p *Packet) (err error)
+	ReadChanges(req *proto.GetChangesRequest, maxWait time.Duration) (resp *proto.GetChangesResponse, err error)
+	UpdateDirStat(req *proto.UpdateDirStatRequest, p *Packet) (err error)
+	GetDirStat(req *proto.GetDirStatRequest, p *Packet) (err error)
+	DirStatOf(ino uint64) (stat *proto.DirStat, err error)
+	GetRootDirStat() *proto.DirStat
 }
 
 // MetaPartition defines the interface for the meta partition operations.
@@ -278,6 +295,7 @@ type MetaPartition interface {
 	Start(isCreate bool) error
 	Stop()
 	DataSize() uint64
+	LogicalSize() uint64
 	GetFreeListLen() int
 	OpMeta
 	LoadSnapshot(path string) error
@@ -294,6 +312,37 @@ type MetaPartition interface {
 	GetMigrateStatByStorageClass() []*proto.StatOfStorageClass
 }
 
+// uidSpaceKey is the user or group that the space and files are accounted to.
+type uidSpaceKey struct {
+	typ proto.QuotaOwnerType
+	id  uint32
+}
+
+// uidUsage is the space and files used by a user or group.
+type uidUsage struct {
+	size  int64
+	files int64
+}
+
+// uidLimit is the limited states of a user or group set by the master.
+type uidLimit struct {
+	size  bool
+	files bool
+}
+
+func ownerKeys(uid, gid uint32) [2]uidSpaceKey {
+	return [2]uidSpaceKey{{typ: proto.QuotaOwnerUser, id: uid}, {typ: proto.QuotaOwnerGroup, id: gid}}
+}
+
+func accumUsage(accum *sync.Map, key uidSpaceKey, size, files int64) {
+	usage := uidUsage{size: size, files: files}
+	if val, ok := accum.Load(key); ok {
+		usage.size += val.(uidUsage).size
+		usage.files += val.(uidUsage).files
+	}
+	accum.Store(key, usage)
+}
+
 type UidManager struct {
 	accumDelta        *sync.Map
 	accumBase         *sync.Map
@@ -316,72 +365,103 @@ func NewUidMgr(volName string, mpID uint64) (mgr *UidManager) {
 		accumRebuildBase:  new(sync.Map),
 		uidAcl:            new(sync.Map),
 	}
-	var uid uint32
-	mgr.uidAcl.Store(uid, false)
+	mgr.uidAcl.Store(uidSpaceKey{}, uidLimit{})
 	log.LogDebugf("NewUidMgr init")
 	return
 }
 
-func (uMgr *UidManager) addUidSpace(uid uint32, inode uint64, eks []proto.ExtentKey) (status uint8) {
-	uMgr.acLock.Lock()
-	defer uMgr.acLock.Unlock()
+// addUsage accounts the usage to the user and group, the caller must hold acLock.
+func (uMgr *UidManager) addUsage(uid, gid uint32, size, files int64) {
+	for _, key := range ownerKeys(uid, gid) {
+		accumUsage(uMgr.accumDelta, key, size, files)
+		if uMgr.rbuilding {
+			accumUsage(uMgr.accumRebuildDelta, key, size, files)
+		}
+	}
+}
 
-	status = proto.OpOk
-	if uMgr.getUidAcl(uid) {
-		log.LogWarnf("addUidSpace.volname [%v] mp[%v] uid %v be set full", uMgr.mpID, uMgr.volName, uid)
-		return proto.OpNoSpaceErr
+// isLimited tells whether the writes of the owners of the inode are denied, or the creates
+// if files is set. The caller must hold acLock.
+func (uMgr *UidManager) isLimited(ino *Inode, files bool) bool {
+	for _, key := range ownerKeys(ino.Uid, ino.Gid) {
+		limit := uMgr.getUidAcl(key)
+		if limit.size || (files && limit.files) {
+			log.LogWarnf("isLimited.volname [%v] mp[%v] %v %v be set full, files %v",
+				uMgr.volName, uMgr.mpID, key.typ, key.id, files)
+			return true
+		}
 	}
-	if eks == nil {
-		return
+	return false
+}
+
+// checkCreate checks the quotas of the owners before creating the inode.
+func (uMgr *UidManager) checkCreate(ino *Inode) (status uint8) {
+	uMgr.acLock.RLock()
+	defer uMgr.acLock.RUnlock()
+	if uMgr.isLimited(ino, true) {
+		return proto.OpNoSpaceErr
 	}
+	return proto.OpOk
+}
+
+// addUidFiles accounts the inode created or deleted to the owners.
+func (uMgr *UidManager) addUidFiles(ino *Inode, files int64) {
+	uMgr.acLock.Lock()
+	defer uMgr.acLock.Unlock()
+	uMgr.addUsage(ino.Uid, ino.Gid, 0, files)
+}
+
+func (uMgr *UidManager) addUidSpace(ino *Inode, eks []proto.ExtentKey) (status uint8) {
 	var size int64
 	for _, ek := range eks {
 		size += int64(ek.Size)
 	}
-	if val, ok := uMgr.accumDelta.Load(uid); ok {
-		size += val.(int64)
-	}
-	uMgr.accumDelta.Store(uid, size)
+	return uMgr.addUidSize(ino, size)
+}
 
-	if uMgr.rbuilding {
-		if val, ok := uMgr.accumRebuildDelta.Load(uid); ok {
-			size += val.(int64)
-		}
-		uMgr.accumRebuildDelta.Store(uid, size)
+// addUidSize accounts the space written to the inode to the owners, the write is denied if the
+// owners are limited.
+func (uMgr *UidManager) addUidSize(ino *Inode, size int64) (status uint8) {
+	uMgr.acLock.Lock()
+	defer uMgr.acLock.Unlock()
+
+	status = proto.OpOk
+	if uMgr.isLimited(ino, false) {
+		return proto.OpNoSpaceErr
 	}
+	uMgr.addUsage(ino.Uid, ino.Gid, size, 0)
 	return
 }
 
-func (uMgr *UidManager) doMinusUidSpace(uid uint32, inode uint64, size uint64) {
+func (uMgr *UidManager) doMinusUidSpace(ino *Inode, size uint64) {
 	uMgr.acLock.Lock()
 	defer uMgr.acLock.Unlock()
-
-	doWork := func(delta *sync.Map) {
-		var rsvSize int64
-		if val, ok := delta.Load(uid); ok {
-			delta.Store(uid, val.(int64)-int64(size))
-		} else {
-			rsvSize -= int64(size)
-			delta.Store(uid, rsvSize)
-		}
-	}
-	doWork(uMgr.accumDelta)
-	if uMgr.rbuilding {
-		doWork(uMgr.accumRebuildDelta)
-	}
+	uMgr.addUsage(ino.Uid, ino.Gid, -int64(size), 0)
 }
 
-func (uMgr *UidManager) minusUidSpace(uid uint32, inode uint64, eks []proto.ExtentKey) {
+func (uMgr *UidManager) minusUidSpace(ino *Inode, eks []proto.ExtentKey) {
 	var size uint64
 	for _, ek := range eks {
 		size += uint64(ek.Size)
 	}
-	uMgr.doMinusUidSpace(uid, inode, size)
+	uMgr.doMinusUidSpace(ino, size)
 }
 
-func (uMgr *UidManager) getUidAcl(uid uint32) (enable bool) {
-	if val, ok := uMgr.uidAcl.Load(uid); ok {
-		enable = val.(bool)
+// chownUidSpace moves the usage of the inode from the old owners to the current ones.
+func (uMgr *UidManager) chownUidSpace(ino *Inode, oldUid, oldGid uint32) {
+	if ino.Uid == oldUid && ino.Gid == oldGid {
+		return
+	}
+	size, files := inodeUsage(ino)
+	uMgr.acLock.Lock()
+	defer uMgr.acLock.Unlock()
+	uMgr.addUsage(oldUid, oldGid, -size, -files)
+	uMgr.addUsage(ino.Uid, ino.Gid, size, files)
+}
+
+func (uMgr *UidManager) getUidAcl(key uidSpaceKey) (limit uidLimit) {
+	if val, ok := uMgr.uidAcl.Load(key); ok {
+		limit = val.(uidLimit)
 	}
 	return
 }
@@ -396,7 +476,8 @@ func (uMgr *UidManager) setUidAcl(info []*proto.UidSpaceInfo) {
 			continue
 		}
 		// log.LogDebugf("setUidAcl.volname [%v] uid %v be set enable %v", uMgr.volName, uidInfo.Uid, uidInfo.Limited)
-		uMgr.uidAcl.Store(uidInfo.Uid, uidInfo.Limited)
+		uMgr.uidAcl.Store(uidSpaceKey{typ: uidInfo.Type, id: uidInfo.Uid},
+			uidLimit{size: uidInfo.Limited, files: uidInfo.LimitedFiles})
 	}
 }
 
@@ -407,30 +488,33 @@ func (uMgr *UidManager) getAllUidSpace() (rsp []*proto.UidReportSpaceInfo) {
 	var ok bool
 
 	uMgr.accumDelta.Range(func(key, value interface{}) bool {
-		var size int64
-		size += value.(int64)
-		if baseInfo, ok := uMgr.accumBase.Load(key.(uint32)); ok {
-			size += baseInfo.(int64)
-			if size < 0 {
-				log.LogErrorf("getAllUidSpace. mp[%v] uid %v size small than 0 %v, old %v, new %v", uMgr.mpID, key.(uint32), size, value.(int64), baseInfo.(int64))
+		usage := value.(uidUsage)
+		if baseInfo, ok := uMgr.accumBase.Load(key.(uidSpaceKey)); ok {
+			usage.size += baseInfo.(uidUsage).size
+			usage.files += baseInfo.(uidUsage).files
+			if usage.size < 0 || usage.files < 0 {
+				log.LogErrorf("getAllUidSpace. mp[%v] owner %v usage small than 0 %v, old %v, new %v",
+					uMgr.mpID, key.(uidSpaceKey), usage, value.(uidUsage), baseInfo.(uidUsage))
 				return false
 			}
 		}
-		uMgr.accumBase.Store(key.(uint32), size)
+		uMgr.accumBase.Store(key.(uidSpaceKey), usage)
 		return true
 	})
 
 	uMgr.accumDelta = new(sync.Map)
 
 	uMgr.accumBase.Range(func(key, value interface{}) bool {
-		var size int64
-		if size, ok = value.(int64); !ok {
+		var usage uidUsage
+		if usage, ok = value.(uidUsage); !ok {
 			log.LogErrorf("getAllUidSpace. mp[%v] accumBase key %v size type %v", uMgr.mpID, reflect.TypeOf(key), reflect.TypeOf(value))
 			return false
 		}
 		rsp = append(rsp, &proto.UidReportSpaceInfo{
-			Uid:  key.(uint32),
-			Size: uint64(size),
+			Uid:   key.(uidSpaceKey).id,
+			Type:  key.(uidSpaceKey).typ,
+			Size:  uint64(usage.size),
+			Files: uint64(usage.files),
 		})
 		// log.LogDebugf("getAllUidSpace. mp[%v] accumBase uid %v size %v", uMgr.mpID, key.(uint32), size)
 		return true
@@ -467,12 +551,20 @@ func (uMgr *UidManager) accumRebuildFin(rebuild bool) {
 	uMgr.accumRebuildDelta = new(sync.Map)
 }
 
+// inodeUsage returns the space and files of the inode accounted to the owners, the inodes
+// unlinked are not accounted.
+func inodeUsage(ino *Inode) (size, files int64) {
+	if ino.IsTempFile() {
+		return
+	}
+	return int64(ino.GetSpaceSize()), 1
+}
+
 func (uMgr *UidManager) accumInoUidSize(ino *Inode, accum *sync.Map) {
-	size := ino.GetSpaceSize()
-	if val, ok := accum.Load(ino.Uid); ok {
-		size += uint64(val.(int64))
+	size, files := inodeUsage(ino)
+	for _, key := range ownerKeys(ino.Uid, ino.Gid) {
+		accumUsage(accum, key, size, files)
 	}
-	accum.Store(ino.Uid, int64(size))
 }
 
 type OpQuota interface {
@@ -555,12 +647,16 @@ func (ew *BlobStoreClientWrapper) getBlobStoreClient() (blobClient *blobstore.Bl
 type metaPartition struct {
 	config                    *MetaPartitionConfig
 	size                      uint64                // For partition all file size
+	logicalSize               uint64                // For partition all the data of the files, without the holes
 	applyID                   uint64                // Inode/Dentry max applyID, this index will be update after restoring from the dumped data.
 	storedApplyId             uint64                // update after store snapshot to disk
 	dentryTree                *BTree                // btree for dentries
 	inodeTree                 *BTree                // btree for inodes
 	extendTree                *BTree                // btree for inode extend (XAttr) management
 	multipartTree             *BTree                // collection for multipart management
+	dirStatTree               *BTree                // the recursive stats of the directories
+	kvStore                   KVStore               // persists the trees above unless in proto.StoreModeMem
+	kvGen                     uint64                // the generation of the trees committed to kvStore
 	txProcessor               *TransactionProcessor // transction processor
 	raftPartition             raftstore.Partition
 	stopC                     chan bool
@@ -583,6 +679,10 @@ type metaPartition struct {
 	mqMgr                     *MetaQuotaManager
 	nonIdempotent             sync.Mutex
 	uniqChecker               *uniqChecker
+	sharedExtents             *sharedExtents
+	cloneHoldMutex            sync.Mutex // the holds of the extents of the source volume, see releaseBorrowedExtents
+	changes                   *changeLog
+	dirStats                  *dirStats
 	verSeq                    uint64
 	multiVersionList          *proto.VolVersionInfoList
 	verUpdateChan             chan []byte
@@ -593,6 +693,8 @@ type metaPartition struct {
 	statByStorageClass        []*proto.StatOfStorageClass
 	statByMigrateStorageClass []*proto.StatOfStorageClass
 	syncAtimeCh               chan uint64
+	appliedWaiters            appliedWaiters   // the reads waiting for the read index applied
+	readIndexBatch            readIndexBatcher // the reads asking the leader for the read index
 }
 
 // IsLeader returns the raft leader address and if the current meta partition is the leader.
@@ -620,6 +722,46 @@ func (mp *metaPartition) IsFollowerRead() (ok bool) {
 	return true
 }
 
+// ReadIndex confirms the leadership with the quorum and returns the index applied by the leader,
+// which covers every write acknowledged before the call. The follower serves the read after it
+// applies the index.
+func (mp *metaPartition) ReadIndex() (index uint64, err error) {
+	if mp.raftPartition == nil {
+		return 0, ErrNotALeader
+	}
+	if err = mp.raftPartition.ReadIndex(); err != nil {
+		return
+	}
+	return mp.GetAppliedID(), nil
+}
+
+// BatchReadIndex returns the read index fetched from the leader, in one round trip shared with
+// the other reads of the partition asking for it at the same time.
+func (mp *metaPartition) BatchReadIndex(fetch func() (uint64, error)) (index uint64, err error) {
+	return mp.readIndexBatch.do(fetch)
+}
+
+// WaitApplied waits until the partition applies the raft log of the index, woken by the apply.
+func (mp *metaPartition) WaitApplied(index uint64, timeout time.Duration) error {
+	if mp.GetAppliedID() >= index {
+		return nil
+	}
+	waiter := mp.appliedWaiters.add(index)
+	if mp.GetAppliedID() >= index {
+		mp.appliedWaiters.remove(waiter)
+		return nil
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-waiter.done:
+		return nil
+	case <-timer.C:
+		mp.appliedWaiters.remove(waiter)
+		return ErrReadIndexTimeout
+	}
+}
+
 func (mp *metaPartition) IsForbidden() bool {
 	return mp.config.Forbidden
 }
@@ -698,6 +840,7 @@ func (mp *metaPartition) updateSize() {
 			select {
 			case <-timer.C:
 				size := uint64(0)
+				logicalSize := uint64(0)
 				migrateSize := uint64(0)
 				migrateInodeCnt := uint32(0)
 
@@ -707,9 +850,11 @@ func (mp *metaPartition) updateSize() {
 				var statMigStorageClass *proto.StatOfStorageClass
 				var ok bool
 
-				mp.inodeTree.GetTree().Ascend(func(item BtreeItem) bool {
+				inodeTree := mp.inodeTree.GetTree()
+				inodeTree.Ascend(func(item BtreeItem) bool {
 					inode := item.(*Inode)
 					size += inode.Size
+					logicalSize += inode.GetSpaceSize()
 
 					// stat normal Extents
 					if statStorageClass, ok = statStorageClassMap[inode.StorageClass]; !ok {
@@ -737,7 +882,9 @@ func (mp *metaPartition) updateSize() {
 
 					return true
 				})
+				inodeTree.Release()
 				mp.size = size
+				mp.logicalSize = logicalSize
 
 				normalToSlice := make([]*proto.StatOfStorageClass, 0)
 				for _, stat := range statStorageClassMap {
@@ -776,6 +923,12 @@ func (mp *metaPartition) DataSize() uint64 {
 	return mp.size
 }
 
+// LogicalSize returns the size of the data of the files before compressed, which is the sum of the
+// extent keys and inline data, so the holes of the sparse files are not counted.
+func (mp *metaPartition) LogicalSize() uint64 {
+	return mp.logicalSize
+}
+
 func (mp *metaPartition) GetFreeListLen() int {
 	return mp.freeList.Len()
 }
@@ -929,6 +1082,7 @@ func (mp *metaPartition) onStart(isCreate bool) (err error) {
 	}
 
 	go mp.startCheckerEvict()
+	go mp.startDirStatForward()
 
 	log.LogDebugf("[before raft] get mp[%v] applied(%d),inodeCount(%d),dentryCount(%d)", mp.config.PartitionId, mp.applyID, mp.inodeTree.Len(), mp.dentryTree.Len())
 
@@ -964,6 +1118,7 @@ func (mp *metaPartition) startScheduleTask() {
 func (mp *metaPartition) onStop() {
 	mp.stopRaft()
 	mp.stop()
+	mp.changes.close()
 	if mp.delInodeFp != nil {
 		mp.delInodeFp.Sync()
 		mp.delInodeFp.Close()
@@ -999,6 +1154,9 @@ func (mp *metaPartition) startRaft() (err error) {
 			HeartbeatPort: heartbeatPort,
 			ReplicaPort:   replicaPort,
 		}
+		if peer.IsLearner {
+			rp.Peer.Type = raftproto.PeerLearner
+		}
 		peers = append(peers, rp)
 	}
 	log.LogInfof("start partition id=%d,applyID:%v raft peers: %s",
@@ -1055,6 +1213,7 @@ func NewMetaPartition(conf *MetaPartitionConfig, manager *metadataManager) MetaP
 		inodeTree:      NewBtree(),
 		extendTree:     NewBtree(),
 		multipartTree:  NewBtree(),
+		dirStatTree:    NewBtree(),
 		stopC:          make(chan bool),
 		storeChan:      make(chan *storeMsg, 100),
 		freeList:       newFreeList(),
@@ -1065,6 +1224,9 @@ func NewMetaPartition(conf *MetaPartitionConfig, manager *metadataManager) MetaP
 		vol:            NewVol(),
 		manager:        manager,
 		uniqChecker:    newUniqChecker(),
+		sharedExtents:  newSharedExtents(),
+		changes:        newChangeLog(changeLogCapacity),
+		dirStats:       newDirStats(),
 		verSeq:         conf.VerSeq,
 		multiVersionList: &proto.VolVersionInfoList{
 			TemporaryVerMap: make(map[uint64]*proto.VolVersionInfo),
@@ -1187,6 +1349,8 @@ const (
 	CRC_COUNT_TX_STUFF   int = 7
 	CRC_COUNT_UINQ_STUFF int = 8
 	CRC_COUNT_MULTI_VER  int = 9
+	CRC_COUNT_SHARED_EXT int = 10
+	CRC_COUNT_DIR_STATS  int = 11
 )
 
 func (mp *metaPartition) LoadSnapshot(snapshotPath string) (err error) {
@@ -1201,9 +1365,16 @@ func (mp *metaPartition) LoadSnapshot(snapshotPath string) (err error) {
 		nil, // loading quota info from extend requires mp.loadInode() has been completed, so skip mp.loadExtend() here
 		mp.loadMultipart,
 	}
+	loadExtend := mp.loadExtend
+	if mp.kvStore != nil {
+		// the trees are in the kv store, only the states derived from them are loaded
+		loadFuncs = []func(rootDir string, crc uint32) error{mp.loadInodeStats, nil, nil, nil}
+		loadExtend = mp.loadExtendStats
+	}
 
 	crc_count := len(crcs)
-	if crc_count != CRC_COUNT_BASIC && crc_count != CRC_COUNT_TX_STUFF && crc_count != CRC_COUNT_UINQ_STUFF && crc_count != CRC_COUNT_MULTI_VER {
+	if crc_count != CRC_COUNT_BASIC && crc_count != CRC_COUNT_TX_STUFF && crc_count != CRC_COUNT_UINQ_STUFF &&
+		crc_count != CRC_COUNT_MULTI_VER && crc_count != CRC_COUNT_SHARED_EXT && crc_count != CRC_COUNT_DIR_STATS {
 		log.LogErrorf("action[LoadSnapshot] crc array length %d not match", len(crcs))
 		return ErrSnapshotCrcMismatch
 	}
@@ -1222,13 +1393,20 @@ func (mp *metaPartition) LoadSnapshot(snapshotPath string) (err error) {
 		loadFuncs = append(loadFuncs, mp.loadUniqChecker)
 	}
 
-	if crc_count == CRC_COUNT_MULTI_VER {
+	if crc_count >= CRC_COUNT_MULTI_VER {
 		if err = mp.loadMultiVer(snapshotPath, crcs[CRC_COUNT_MULTI_VER-1]); err != nil {
 			return
 		}
 	} else {
 		mp.storeMultiVersion(snapshotPath, &storeMsg{multiVerList: mp.multiVersionList.VerList})
 	}
+	if crc_count >= CRC_COUNT_SHARED_EXT {
+		// the multi version is loaded above
+		loadFuncs = append(loadFuncs, nil, mp.loadSharedExtents)
+	}
+	if crc_count >= CRC_COUNT_DIR_STATS {
+		loadFuncs = append(loadFuncs, mp.loadDirStats)
+	}
 
 	errs := make([]error, len(loadFuncs))
 	var wg sync.WaitGroup
@@ -1265,7 +1443,7 @@ func (mp *metaPartition) LoadSnapshot(snapshotPath string) (err error) {
 		}
 	}
 
-	if err = mp.loadExtend(snapshotPath, crcs[2]); err != nil {
+	if err = loadExtend(snapshotPath, crcs[2]); err != nil {
 		return
 	}
 
@@ -1291,6 +1469,14 @@ func (mp *metaPartition) load(isCreate bool) (err error) {
 	if err = mp.loadMetadata(); err != nil {
 		return
 	}
+	defer func() {
+		if err == nil {
+			err = mp.changes.open(path.Join(mp.config.RootDir, changeLogDir), mp.applyID)
+		}
+	}()
+	if err = mp.openStore(); err != nil {
+		return
+	}
 	// 1. create new metaPartition, no need to load snapshot
 	// 2. store the snapshot files for new mp, because
 	// mp.load() will check all the snapshot files when mn startup
@@ -1303,6 +1489,11 @@ func (mp *metaPartition) load(isCreate bool) (err error) {
 	}
 
 	snapshotPath := path.Join(mp.config.RootDir, snapshotDir)
+	if mp.kvStore != nil {
+		if err = mp.restoreSnapshotFiles(snapshotPath); err != nil {
+			return
+		}
+	}
 	if _, err = os.Stat(snapshotPath); err != nil {
 		log.LogErrorf("load snapshot failed, err: %s", err.Error())
 		return nil
@@ -1319,6 +1510,8 @@ func (mp *metaPartition) load(isCreate bool) (err error) {
 
 func (mp *metaPartition) store(sm *storeMsg) (err error) {
 	log.LogWarnf("metaPartition %d store apply %v", mp.config.PartitionId, sm.applyIndex)
+	// the changes of the applies in the snapshot are not recorded again after restart
+	mp.changes.sync(sm.applyIndex)
 	tmpDir := path.Join(mp.config.RootDir, snapshotDirTmp)
 	if _, err = os.Stat(tmpDir); err == nil {
 		// TODO Unhandled errors
@@ -1345,6 +1538,15 @@ func (mp *metaPartition) store(sm *storeMsg) (err error) {
 		mp.storeTxRbDentry,
 		mp.storeUniqChecker,
 		mp.storeMultiVersion,
+		mp.storeSharedExtents,
+		mp.storeDirStats,
+	}
+	if mp.kvStore != nil {
+		// the trees are committed to the kv store incrementally, see commitStore
+		storeFuncs[0] = mp.storeInodeStats
+		storeFuncs[1] = storeNothing
+		storeFuncs[2] = mp.storeExtendStats
+		storeFuncs[3] = storeNothing
 	}
 	for _, storeFunc := range storeFuncs {
 		var crc uint32
@@ -1371,6 +1573,11 @@ func (mp *metaPartition) store(sm *storeMsg) (err error) {
 	if err = fileutil.WriteFileWithSync(path.Join(tmpDir, SnapshotSign), crcBuffer.Bytes(), 0o775); err != nil {
 		return
 	}
+	if mp.kvStore != nil {
+		if err = mp.commitStore(tmpDir, sm); err != nil {
+			return
+		}
+	}
 	snapshotDir := path.Join(mp.config.RootDir, snapshotDir)
 	// check snapshot backup
 	backupDir := path.Join(mp.config.RootDir, snapshotBackup)
@@ -1481,6 +1688,24 @@ func (mp *metaPartition) IsExsitPeer(peer proto.Peer) bool {
 	return false
 }
 
+// AddPeerChangeType returns the raft member change to add the peer, as a learner if it's requested
+// so, by promoting the learner added before, or as a voter. It returns false if the peer is added.
+func (mp *metaPartition) AddPeerChangeType(peer proto.Peer) (changeType raftproto.ConfChangeType, ok bool) {
+	for _, hasExsitPeer := range mp.config.Peers {
+		if hasExsitPeer.Addr != peer.Addr || hasExsitPeer.ID != peer.ID {
+			continue
+		}
+		if hasExsitPeer.IsLearner && !peer.IsLearner {
+			return raftproto.ConfPromoteLearner, true
+		}
+		return
+	}
+	if peer.IsLearner {
+		return raftproto.ConfAddLearner, true
+	}
+	return raftproto.ConfAddNode, true
+}
+
 func (mp *metaPartition) TryToLeader(groupID uint64) error {
 	return mp.raftPartition.TryToLeader(groupID)
 }
@@ -1522,10 +1747,16 @@ func (mp *metaPartition) Reset() (err error) {
 	mp.inodeTree.Reset()
 	mp.extendTree.Reset()
 	mp.multipartTree.Reset()
+	mp.dirStatTree.Reset()
 	mp.config.Cursor = 0
 	mp.config.UniqId = 0
 	mp.applyID = 0
 	mp.txProcessor.Reset()
+	if mp.kvStore != nil {
+		if err = mp.dropStore(); err != nil {
+			return
+		}
+	}
 
 	// remove files
 	filenames := []string{applyIDFile, dentryFile, inodeFile, extendFile, multipartFile, verdataFile, txInfoFile, txRbInodeFile, txRbDentryFile, TxIDFile}
@@ -1631,7 +1862,9 @@ func (mp *metaPartition) delPartitionDentriesVersion(verSeq uint64, wg *sync.Wai
 	count := 0
 	needSleep := false
 
-	mp.dentryTree.GetTree().Ascend(func(i BtreeItem) bool {
+	dentryTree := mp.dentryTree.GetTree()
+	defer dentryTree.Release()
+	dentryTree.Ascend(func(i BtreeItem) bool {
 		if _, ok := mp.IsLeader(); !ok {
 			return false
 		}
@@ -1670,7 +1903,9 @@ func (mp *metaPartition) delPartitionExtendsVersion(verSeq uint64, wg *sync.Wait
 	count := 0
 	needSleep := false
 
-	mp.extendTree.GetTree().Ascend(func(treeItem BtreeItem) bool {
+	extendTree := mp.extendTree.GetTree()
+	defer extendTree.Release()
+	extendTree.Ascend(func(treeItem BtreeItem) bool {
 		if _, ok := mp.IsLeader(); !ok {
 			return false
 		}
@@ -1707,7 +1942,9 @@ func (mp *metaPartition) delPartitionInodesVersion(verSeq uint64, wg *sync.WaitG
 	count := 0
 	needSleep := false
 
-	mp.inodeTree.GetTree().Ascend(func(i BtreeItem) bool {
+	inodeTree := mp.inodeTree.GetTree()
+	defer inodeTree.Release()
+	inodeTree.Ascend(func(i BtreeItem) bool {
 		if _, ok := mp.IsLeader(); !ok {
 			return false
 		}
@@ -1812,7 +2049,9 @@ func (mp *metaPartition) InodeTTLScan(cacheTTL int) {
 	// begin
 	count := 0
 	needSleep := false
-	mp.inodeTree.GetTree().Ascend(func(i BtreeItem) bool {
+	inodeTree := mp.inodeTree.GetTree()
+	defer inodeTree.Release()
+	inodeTree.Ascend(func(i BtreeItem) bool {
 		inode := i.(*Inode)
 		// dir type just skip
 		if proto.IsDir(inode.Type) {
@@ -1879,11 +2118,14 @@ func (mp *metaPartition) storeSnapshotFiles() (err error) {
 		dentryTree:     NewBtree(),
 		extendTree:     NewBtree(),
 		multipartTree:  NewBtree(),
+		dirStatTree:    NewBtree(),
 		txTree:         NewBtree(),
 		txRbInodeTree:  NewBtree(),
 		txRbDentryTree: NewBtree(),
 		uniqId:         mp.GetUniqId(),
 		uniqChecker:    newUniqChecker(),
+		sharedExtents:  newSharedExtents(),
+		dirStats:       newDirStats(),
 		multiVerList:   mp.multiVersionList.VerList,
 	}
 
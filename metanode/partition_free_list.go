@@ -87,6 +87,7 @@ func (mp *metaPartition) UpdateVolumeView(dataView *proto.DataPartitionsView, vo
 				Hosts:       view.DataPartitions[i].Hosts,
 				ReplicaNum:  view.DataPartitions[i].ReplicaNum,
 				IsDiscard:   view.DataPartitions[i].IsDiscard,
+				CloneSource: view.DataPartitions[i].CloneSource,
 			}
 		}
 		return newView
@@ -259,7 +260,7 @@ func (mp *metaPartition) batchDeleteExtentsByPartition(partitionDeleteExtents ma
 	for partitionID, extents := range partitionDeleteExtents {
 		dp := mp.vol.GetPartition(partitionID)
 		// NOTE: if dp is discard, skip it
-		if dp.IsDiscard {
+		if dp != nil && dp.IsDiscard {
 			log.LogWarnf("action[batchDeleteExtentsByPartition] dp(%v) is discard, skip extents count(%v)", partitionID, len(extents))
 			continue
 		}
@@ -488,6 +489,7 @@ func (mp *metaPartition) deleteMarkedReplicaInodes(inoSlice []uint64, isCache,
 	log.LogDebugf("[deleteMarkedReplicaInodes] mp[%v] inoSlice[%v] isCache[%v] isMigration[%v]",
 		mp.config.PartitionId, inoSlice, isCache, isMigration)
 	deleteExtentsByPartition := make(map[uint64][]*proto.DelExtentParam)
+	freedShared := make(map[proto.SharedExtentID]uint32)
 	allInodes := make([]*Inode, 0)
 	for _, ino := range inoSlice {
 		ref := &Inode{Inode: ino}
@@ -514,6 +516,12 @@ func (mp *metaPartition) deleteMarkedReplicaInodes(inoSlice []uint64, isCache,
 
 		extInfo := inode.GetAllExtsOfflineInode(mp.config.PartitionId, isCache, isMigration)
 		for dpID, inodeExts := range extInfo {
+			if !isCache && !isMigration {
+				// the shared extents are kept for the clones, the refs are released with the inode
+				if inodeExts = mp.sharedExtents.filter(inodeExts, freedShared); len(inodeExts) == 0 {
+					continue
+				}
+			}
 			exts, ok := deleteExtentsByPartition[dpID]
 			if !ok {
 				exts = make([]*proto.DelExtentParam, 0)
@@ -612,6 +620,10 @@ func (mp *metaPartition) doBatchDeleteExtentsByPartition(partitionID uint64, ext
 			partitionID)
 		return
 	}
+	if dp.CloneSource != "" {
+		// the extents are shared from the source volume, which deletes them once released
+		return mp.releaseBorrowedExtents(dp, exts)
+	}
 
 	for _, ext := range exts {
 		if ext.PartitionId != partitionID {
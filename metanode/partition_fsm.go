@@ -15,6 +15,7 @@
 package metanode
 
 import (
+	"bytes"
 	"encoding/binary"
 	"encoding/json"
 	"errors"
@@ -55,6 +56,10 @@ func (mp *metaPartition) Apply(command []byte, index uint64) (resp interface{},
 
 	mp.nonIdempotent.Lock()
 	defer mp.nonIdempotent.Unlock()
+	mp.changes.begin(index, msg.T)
+	defer mp.changes.end()
+	mp.dirStats.begin()
+	defer mp.dirStats.end()
 
 	switch msg.Op {
 	case opFSMCreateInode:
@@ -122,6 +127,48 @@ func (mp *metaPartition) Apply(command []byte, index uint64) (resp interface{},
 			return
 		}
 		resp = mp.fsmExtentsTruncate(ino)
+	case opFSMFallocate:
+		req := &proto.FallocateRequest{}
+		if err = json.Unmarshal(msg.V, req); err != nil {
+			return
+		}
+		resp = mp.fsmFallocate(req)
+	case opFSMCloneInode:
+		req := &fsmCloneInodeRequest{}
+		if err = json.Unmarshal(msg.V, req); err != nil {
+			return
+		}
+		resp = mp.fsmCloneInode(req)
+	case opFSMUpdateDirStat:
+		req := &proto.UpdateDirStatRequest{}
+		if err = json.Unmarshal(msg.V, req); err != nil {
+			return
+		}
+		resp = mp.fsmUpdateDirStat(req)
+	case opFSMAckDirStat:
+		var seq uint64
+		if err = json.Unmarshal(msg.V, &seq); err != nil {
+			return
+		}
+		mp.fsmAckDirStat(seq)
+	case opFSMBackfillDirStat:
+		req := &dirStatBackfillRequest{}
+		if err = json.Unmarshal(msg.V, req); err != nil {
+			return
+		}
+		mp.fsmBackfillDirStat(req)
+	case opFSMWriteInline:
+		req := &fsmWriteInlineRequest{}
+		if err = json.Unmarshal(msg.V, req); err != nil {
+			return
+		}
+		resp = mp.fsmWriteInline(req)
+	case opFSMFsckDeleteExtents:
+		var eks []proto.ExtentKey
+		if err = json.Unmarshal(msg.V, &eks); err != nil {
+			return
+		}
+		resp = mp.fsmFsckDeleteExtents(eks)
 	case opFSMCreateLinkInode:
 		ino := NewInode(0, 0)
 		if err = ino.Unmarshal(msg.V); err != nil {
@@ -217,6 +264,12 @@ func (mp *metaPartition) Apply(command []byte, index uint64) (resp interface{},
 		}
 
 		resp = mp.fsmUpdateDentry(den)
+	case opFSMArchiveDentry:
+		req := &fsmArchiveDentryRequest{}
+		if err = json.Unmarshal(msg.V, req); err != nil {
+			return
+		}
+		resp = mp.fsmArchiveDentry(req)
 	case opFSMUpdatePartition:
 		req := &UpdatePartitionReq{}
 		if err = json.Unmarshal(msg.V, req); err != nil {
@@ -264,10 +317,11 @@ func (mp *metaPartition) Apply(command []byte, index uint64) (resp interface{},
 	case opFSMSentToChanWithVer:
 		resp = mp.fsmSendToChan(msg.V, true)
 	case opFSMStoreTick:
-		inodeTree := mp.inodeTree.GetTree()
-		dentryTree := mp.dentryTree.GetTree()
-		extendTree := mp.extendTree.GetTree()
-		multipartTree := mp.multipartTree.GetTree()
+		inodeTree := mp.inodeTree.GetTreeToStore()
+		dentryTree := mp.dentryTree.GetTreeToStore()
+		extendTree := mp.extendTree.GetTreeToStore()
+		multipartTree := mp.multipartTree.GetTreeToStore()
+		dirStatTree := mp.dirStatTree.GetTreeToStore()
 		txTree := mp.txProcessor.txManager.txTree.GetTree()
 		txRbInodeTree := mp.txProcessor.txResource.txRbInodeTree.GetTree()
 		txRbDentryTree := mp.txProcessor.txResource.txRbDentryTree.GetTree()
@@ -276,6 +330,8 @@ func (mp *metaPartition) Apply(command []byte, index uint64) (resp interface{},
 		uidRebuild := mp.acucumRebuildStart()
 		uniqId := mp.GetUniqId()
 		uniqChecker := mp.uniqChecker.clone()
+		sharedExtents := mp.sharedExtents.clone()
+		dirStats := mp.dirStats.clone()
 		msg := &storeMsg{
 			command:        opFSMStoreTick,
 			applyIndex:     index,
@@ -284,6 +340,7 @@ func (mp *metaPartition) Apply(command []byte, index uint64) (resp interface{},
 			dentryTree:     dentryTree,
 			extendTree:     extendTree,
 			multipartTree:  multipartTree,
+			dirStatTree:    dirStatTree,
 			txTree:         txTree,
 			txRbInodeTree:  txRbInodeTree,
 			txRbDentryTree: txRbDentryTree,
@@ -291,6 +348,8 @@ func (mp *metaPartition) Apply(command []byte, index uint64) (resp interface{},
 			uidRebuild:     uidRebuild,
 			uniqId:         uniqId,
 			uniqChecker:    uniqChecker,
+			sharedExtents:  sharedExtents,
+			dirStats:       dirStats,
 			multiVerList:   mp.GetAllVerList(),
 		}
 		log.LogDebugf("opFSMStoreTick: quotaRebuild [%v] uidRebuild [%v]", quotaRebuild, uidRebuild)
@@ -327,6 +386,12 @@ func (mp *metaPartition) Apply(command []byte, index uint64) (resp interface{},
 			return
 		}
 		resp = mp.fsmLockDir(req)
+	case opFSMFileLock:
+		req := &proto.FileLockRequest{}
+		if err = json.Unmarshal(msg.V, req); err != nil {
+			return
+		}
+		resp = mp.fsmFileLock(req)
 	case opFSMCreateMultipart:
 		var multipart *Multipart
 		multipart = MultipartFromBytes(msg.V)
@@ -651,12 +716,18 @@ func (mp *metaPartition) ApplyMemberChange(confChange *raftproto.ConfChange, ind
 		updated bool
 	)
 	switch confChange.Type {
-	case raftproto.ConfAddNode:
+	case raftproto.ConfAddNode, raftproto.ConfAddLearner:
 		req := &proto.AddMetaPartitionRaftMemberRequest{}
 		if err = json.Unmarshal(confChange.Context, req); err != nil {
 			return
 		}
 		updated, err = mp.confAddNode(req, index)
+	case raftproto.ConfPromoteLearner:
+		req := &proto.AddMetaPartitionRaftMemberRequest{}
+		if err = json.Unmarshal(confChange.Context, req); err != nil {
+			return
+		}
+		updated = mp.confPromoteLearner(req)
 	case raftproto.ConfRemoveNode:
 		req := &proto.RemoveMetaPartitionRaftMemberRequest{}
 		if err = json.Unmarshal(confChange.Context, req); err != nil {
@@ -695,15 +766,19 @@ func (mp *metaPartition) ApplySnapshot(peers []raftproto.Peer, iter raftproto.Sn
 		txID           uint64
 		uniqID         uint64
 		cursor         uint64
-		inodeTree      = NewBtree()
-		dentryTree     = NewBtree()
-		extendTree     = NewBtree()
-		multipartTree  = NewBtree()
+		inodeTree      = mp.newApplyingTree(inodeCodec)
+		dentryTree     = mp.newApplyingTree(dentryCodec)
+		extendTree     = mp.newApplyingTree(extendCodec)
+		multipartTree  = mp.newApplyingTree(multipartCodec)
+		dirStatTree    = mp.newApplyingTree(dirStatCodec)
 		txTree         = NewBtree()
 		txRbInodeTree  = NewBtree()
 		txRbDentryTree = NewBtree()
 		uniqChecker    = newUniqChecker()
+		sharedExtents  = newSharedExtents()
+		dirStats       = newDirStats()
 		verList        []*proto.VolVersionInfo
+		ingest         *kvIngest // the trees sent as the key-values of the kv store
 	)
 
 	blockUntilStoreSnapshot := func() {
@@ -745,19 +820,31 @@ func (mp *metaPartition) ApplySnapshot(peers []raftproto.Peer, iter raftproto.Sn
 	}
 
 	defer func() {
+		if err == io.EOF && ingest != nil {
+			var trees []*BTree
+			if trees, err = ingest.finish(); err == nil {
+				inodeTree, dentryTree, extendTree, multipartTree, dirStatTree = trees[0], trees[1], trees[2], trees[3], trees[4]
+				err = io.EOF
+			}
+		}
 		if err == io.EOF {
 			mp.applyID = appIndexID
+			mp.appliedWaiters.notify(appIndexID)
 			mp.config.UniqId = uniqID
 			mp.txProcessor.txManager.txIdAlloc.setTransactionID(txID)
 			mp.inodeTree = inodeTree
 			mp.dentryTree = dentryTree
 			mp.extendTree = extendTree
 			mp.multipartTree = multipartTree
+			mp.dirStatTree = dirStatTree
 			mp.config.Cursor = cursor
 			mp.txProcessor.txManager.txTree = txTree
 			mp.txProcessor.txResource.txRbInodeTree = txRbInodeTree
 			mp.txProcessor.txResource.txRbDentryTree = txRbDentryTree
 			mp.uniqChecker = uniqChecker
+			mp.sharedExtents = sharedExtents
+			mp.dirStats = dirStats
+			mp.changes.reset()
 			mp.multiVersionList.VerList = make([]*proto.VolVersionInfo, len(verList))
 			copy(mp.multiVersionList.VerList, verList)
 			mp.verSeq = mp.multiVersionList.GetLastVer()
@@ -768,15 +855,18 @@ func (mp *metaPartition) ApplySnapshot(peers []raftproto.Peer, iter raftproto.Sn
 				command:        opFSMStoreTick,
 				applyIndex:     mp.applyID,
 				txId:           mp.txProcessor.txManager.txIdAlloc.getTransactionID(),
-				inodeTree:      mp.inodeTree.GetTree(),
-				dentryTree:     mp.dentryTree.GetTree(),
-				extendTree:     mp.extendTree.GetTree(),
-				multipartTree:  mp.multipartTree.GetTree(),
+				inodeTree:      mp.inodeTree.GetTreeToStore(),
+				dentryTree:     mp.dentryTree.GetTreeToStore(),
+				extendTree:     mp.extendTree.GetTreeToStore(),
+				multipartTree:  mp.multipartTree.GetTreeToStore(),
+				dirStatTree:    mp.dirStatTree.GetTreeToStore(),
 				txTree:         mp.txProcessor.txManager.txTree.GetTree(),
 				txRbInodeTree:  mp.txProcessor.txResource.txRbInodeTree.GetTree(),
 				txRbDentryTree: mp.txProcessor.txResource.txRbDentryTree.GetTree(),
 				uniqId:         mp.GetUniqId(),
 				uniqChecker:    uniqChecker.clone(),
+				sharedExtents:  sharedExtents.clone(),
+				dirStats:       dirStats.clone(),
 				multiVerList:   mp.GetVerList(),
 			}
 			select {
@@ -910,6 +1000,22 @@ func (mp *metaPartition) ApplySnapshot(peers []raftproto.Peer, iter raftproto.Sn
 		case opFSMVerListSnapShot:
 			json.Unmarshal(snap.V, &verList)
 			log.LogDebugf("ApplySnapshot: create verList: partitionID(%v) snap.V(%v) verList(%v)", mp.config.PartitionId, snap.V, verList)
+		case opFSMStoreKVSnap:
+			if len(snap.K) == 0 {
+				err = fmt.Errorf("ApplySnapshot: partitionID(%v) empty key of kv snapshot", mp.config.PartitionId)
+				return
+			}
+			if ingest == nil {
+				if ingest, err = mp.newKVIngest(); err != nil {
+					return
+				}
+			}
+			if snap.K[0] == kvTableInode && len(snap.K) == 9 && cursor < binary.BigEndian.Uint64(snap.K[1:]) {
+				cursor = binary.BigEndian.Uint64(snap.K[1:])
+			}
+			if err = ingest.put(snap.K[0], snap.K[1:], snap.V); err != nil {
+				return
+			}
 		case opExtentFileSnapshot:
 			fileName := string(snap.K)
 			fileName = path.Join(mp.config.RootDir, fileName)
@@ -925,6 +1031,25 @@ func (mp *metaPartition) ApplySnapshot(peers []raftproto.Peer, iter raftproto.Sn
 				return
 			}
 			log.LogDebugf("ApplySnapshot: write snap uniqChecker")
+		case opFSMSharedExtentsSnap:
+			if err = sharedExtents.UnMarshal(snap.V); err != nil {
+				log.LogErrorf("ApplySnapshot: unmarshal snap sharedExtents fail")
+				return
+			}
+			log.LogDebugf("ApplySnapshot: write snap sharedExtents")
+		case opFSMDirStatsSnap:
+			if err = dirStats.UnMarshal(snap.V, nil); err != nil {
+				log.LogErrorf("ApplySnapshot: unmarshal snap dirStats fail")
+				return
+			}
+			log.LogDebugf("ApplySnapshot: write snap dirStats")
+		case opFSMDirStatEntrySnap:
+			e := &dirStatEntry{}
+			if err = e.Unmarshal(bytes.NewBuffer(snap.V)); err != nil {
+				return
+			}
+			dirStatTree.ReplaceOrInsert(e, true)
+			log.LogDebugf("ApplySnapshot: create dir stat: partitionID(%v) inode(%v)", mp.config.PartitionId, e.ino)
 
 		default:
 			if leaderSnapFormatVer != math.MaxUint32 && leaderSnapFormatVer > mp.manager.metaNode.raftSyncSnapFormatVersion {
@@ -1005,6 +1130,7 @@ func (mp *metaPartition) submit(op uint32, data []byte) (resp interface{}, err e
 	log.LogDebugf("submit. op [%v]", op)
 	snap := NewMetaItem(0, nil, nil)
 	snap.Op = op
+	snap.T = time.Now().Unix()
 	if data != nil {
 		snap.V = data
 	}
@@ -1021,6 +1147,7 @@ func (mp *metaPartition) submit(op uint32, data []byte) (resp interface{}, err e
 
 func (mp *metaPartition) uploadApplyID(applyId uint64) {
 	atomic.StoreUint64(&mp.applyID, applyId)
+	mp.appliedWaiters.notify(applyId)
 }
 
 func (mp *metaPartition) getApplyID() (applyId uint64) {
@@ -129,6 +129,16 @@ func (mp *metaPartition) confAddNode(req *proto.AddMetaPartitionRaftMemberReques
 	return
 }
 
+func (mp *metaPartition) confPromoteLearner(req *proto.AddMetaPartitionRaftMemberRequest) (updated bool) {
+	for i, peer := range mp.config.Peers {
+		if peer.ID == req.AddPeer.ID && peer.IsLearner {
+			mp.config.Peers[i].IsLearner = false
+			updated = true
+		}
+	}
+	return
+}
+
 func (mp *metaPartition) confRemoveNode(req *proto.RemoveMetaPartitionRaftMemberRequest, index uint64) (updated bool, err error) {
 	var canRemoveSelf bool
 	if canRemoveSelf, err = mp.canRemoveSelf(); err != nil {
@@ -18,7 +18,6 @@ import (
 	"strings"
 
 	"github.com/cubefs/cubefs/proto"
-	"github.com/cubefs/cubefs/util/btree"
 	"github.com/cubefs/cubefs/util/log"
 )
 
@@ -34,6 +33,7 @@ func NewDentryResponse() *DentryResponse {
 }
 
 func (mp *metaPartition) fsmTxCreateDentry(txDentry *TxDentry) (status uint8) {
+	mp.changes.inTx(txDentry.TxInfo)
 	done := mp.txProcessor.txManager.txInRMDone(txDentry.TxInfo.TxID)
 	if done {
 		log.LogWarnf("fsmTxCreateDentry: tx is already finish. txId %s", txDentry.TxInfo.TxID)
@@ -124,6 +124,8 @@ func (mp *metaPartition) fsmCreateDentry(dentry *Dentry,
 				parIno.IncNLink(mp.verSeq)
 				parIno.SetMtime()
 			}
+			mp.recordDentryChange(proto.ChangeCreate, d, 0)
+			mp.updateDirStatLink(d, 0)
 			return
 		} else if proto.OsModeType(dentry.Type) != proto.OsModeType(d.Type) && !proto.IsSymlink(dentry.Type) && !proto.IsSymlink(d.Type) {
 			log.LogErrorf("action[fsmCreateDentry] ParentId [%v] get [%v] but should del, dentry name [%v], inode[%v], type[%v,%v],dir[%v,%v]",
@@ -143,6 +145,8 @@ func (mp *metaPartition) fsmCreateDentry(dentry *Dentry,
 		parIno.IncNLink(mp.verSeq)
 		parIno.SetMtime()
 	}
+	mp.recordDentryChange(proto.ChangeCreate, dentry, 0)
+	mp.updateDirStatLink(dentry, 0)
 	return
 }
 
@@ -180,6 +184,7 @@ func (mp *metaPartition) getDentry(dentry *Dentry) (*Dentry, uint8) {
 }
 
 func (mp *metaPartition) fsmTxDeleteDentry(txDentry *TxDentry) (resp *DentryResponse) {
+	mp.changes.inTx(txDentry.TxInfo)
 	resp = NewDentryResponse()
 	resp.Status = proto.OpOk
 	if mp.txProcessor.txManager.txInRMDone(txDentry.TxInfo.TxID) {
@@ -223,6 +228,8 @@ func (mp *metaPartition) fsmTxDeleteDentry(txDentry *TxDentry) (resp *DentryResp
 	mp.dentryTree.Delete(tmpDen)
 	// parent link count not change
 	resp.Msg = item.(*Dentry)
+	mp.recordDentryChange(proto.ChangeUnlink, resp.Msg, 0)
+	mp.updateDirStatUnlink(resp.Msg)
 	return
 }
 
@@ -239,22 +246,21 @@ func (mp *metaPartition) fsmDeleteDentry(denParm *Dentry, checkInode bool) (resp
 	)
 	if checkInode {
 		log.LogDebugf("action[fsmDeleteDentry] mp[%v] delete param %v", mp.config.PartitionId, denParm)
-		item = mp.dentryTree.Execute(func(tree *btree.BTree) interface{} {
-			d := tree.CopyGet(denParm)
+		item = mp.dentryTree.CopyExecute(denParm, func(d BtreeItem) (interface{}, bool) {
 			if d == nil {
-				return nil
+				return nil, false
 			}
 			den := d.(*Dentry)
 			if den.Inode != denParm.Inode {
-				return nil
+				return nil, false
 			}
 			if mp.verSeq == 0 {
 				log.LogDebugf("action[fsmDeleteDentry] mp[%v] volume snapshot not enabled,delete directly", mp.config.PartitionId)
 				denFound = den
-				return mp.dentryTree.tree.Delete(den)
+				return den, true
 			}
 			denFound, doMore, clean = den.deleteVerSnapshot(denParm.getSeqFiled(), mp.verSeq, mp.GetVerList())
-			return den
+			return den, false
 		})
 	} else {
 		log.LogDebugf("action[fsmDeleteDentry] mp[%v] denParm dentry %v", mp.config.PartitionId, denParm)
@@ -305,6 +311,8 @@ func (mp *metaPartition) fsmDeleteDentry(denParm *Dentry, checkInode bool) (resp
 			})
 	}
 	resp.Msg = denFound
+	mp.recordDentryChange(proto.ChangeUnlink, denFound, 0)
+	mp.updateDirStatUnlink(denFound)
 	return
 }
 
@@ -323,6 +331,7 @@ func (mp *metaPartition) fsmBatchDeleteDentry(db DentryBatch) []*DentryResponse
 }
 
 func (mp *metaPartition) fsmTxUpdateDentry(txUpDateDentry *TxUpdateDentry) (resp *DentryResponse) {
+	mp.changes.inTx(txUpDateDentry.TxInfo)
 	resp = NewDentryResponse()
 	resp.Status = proto.OpOk
 
@@ -363,6 +372,8 @@ func (mp *metaPartition) fsmTxUpdateDentry(txUpDateDentry *TxUpdateDentry) (resp
 	d := item.(*Dentry)
 	d.Inode, newDen.Inode = newDen.Inode, d.Inode
 	resp.Msg = newDen
+	mp.recordDentryChange(proto.ChangeCreate, d, newDen.Inode)
+	mp.updateDirStatLink(d, newDen.Inode)
 	return
 }
 
@@ -388,6 +399,8 @@ func (mp *metaPartition) fsmUpdateDentry(dentry *Dentry) (
 		}
 		d.Inode, dentry.Inode = dentry.Inode, d.Inode
 		resp.Msg = dentry
+		mp.recordDentryChange(proto.ChangeCreate, d, dentry.Inode)
+		mp.updateDirStatLink(d, dentry.Inode)
 	})
 	return
 }
@@ -493,3 +506,159 @@ func (mp *metaPartition) readDirLimit(req *ReadDirLimitReq) (resp *ReadDirLimitR
 	log.LogDebugf("action[readDirLimit] mp[%v] resp %v", mp.config.PartitionId, resp)
 	return
 }
+
+type fsmArchiveDentryRequest struct {
+	ParentID    uint64            `json:"pino"`
+	Name        string            `json:"name"`
+	Inode       uint64            `json:"ino"`
+	NewInode    uint64            `json:"nino"`
+	ArchiveID   uint64            `json:"aino"`
+	ArchiveName string            `json:"aname"`
+	Promote     string            `json:"promote"`
+	Marker      []byte            `json:"marker"` // the marshaled inode of the delete marker
+	MarkerName  string            `json:"mname"`
+	MarkerAttrs map[string]string `json:"mattrs"`
+	VerSeq      uint64            `json:"seq"`
+}
+
+type archiveDentryResponse struct {
+	Status   uint8
+	Dropped  uint64
+	Replaced []uint64
+	Marker   *Inode
+}
+
+// fsmArchiveDentry checks all the dentries and inodes the request touches before any of them is
+// changed, and creates the delete marker before the dentries, so a request failing leaves the
+// partition as it was.
+func (mp *metaPartition) fsmArchiveDentry(req *fsmArchiveDentryRequest) (resp *archiveDentryResponse) {
+	resp = &archiveDentryResponse{Status: proto.OpOk}
+	isDir := func(ino uint64) bool {
+		item := mp.inodeTree.Get(NewInode(ino, 0))
+		return item != nil && !item.(*Inode).ShouldDelete() && proto.IsDir(item.(*Inode).Type)
+	}
+	if !isDir(req.ArchiveID) || (req.Inode != 0 && !isDir(req.ParentID)) {
+		resp.Status = proto.OpNotExistErr
+		return
+	}
+	names := []*Dentry{{ParentId: req.ArchiveID, Name: req.ArchiveName}, {ParentId: req.ArchiveID, Name: req.MarkerName}}
+	var current, promoted *Dentry
+	if req.Inode != 0 {
+		names = append(names, &Dentry{ParentId: req.ParentID, Name: req.Name})
+		item := mp.dentryTree.Get(&Dentry{ParentId: req.ParentID, Name: req.Name})
+		if item == nil || item.(*Dentry).isDeleted() || item.(*Dentry).Inode != req.Inode {
+			log.LogWarnf("action[fsmArchiveDentry] mp[%v] current version changed, req %v dentry %v",
+				mp.config.PartitionId, req, item)
+			resp.Status = proto.OpNotExistErr
+			return
+		}
+		current = item.(*Dentry)
+	}
+	if req.Promote != "" {
+		names = append(names, &Dentry{ParentId: req.ArchiveID, Name: req.Promote})
+		item := mp.dentryTree.Get(&Dentry{ParentId: req.ArchiveID, Name: req.Promote})
+		if item == nil || item.(*Dentry).isDeleted() {
+			resp.Status = proto.OpNotExistErr
+			return
+		}
+		promoted = item.(*Dentry)
+	}
+	for _, d := range names {
+		if d.Name == "" {
+			continue
+		}
+		if status := mp.dentryInTx(d.ParentId, d.Name); status != proto.OpOk {
+			resp.Status = status
+			return
+		}
+	}
+	var marker *Inode
+	if len(req.Marker) > 0 {
+		marker = NewInode(0, 0)
+		if err := marker.Unmarshal(req.Marker); err != nil {
+			log.LogErrorf("action[fsmArchiveDentry] mp[%v] unmarshal marker err %v", mp.config.PartitionId, err)
+			resp.Status = proto.OpErr
+			return
+		}
+		if status := mp.uidManager.checkCreate(marker); status != proto.OpOk {
+			resp.Status = status
+			return
+		}
+		if mp.inodeTree.Get(marker) != nil {
+			resp.Status = proto.OpExistErr
+			return
+		}
+	}
+	// the new current version in another partition is checked by the client before the request
+	if req.NewInode != 0 && mp.isLocalInode(req.NewInode) {
+		item := mp.inodeTree.Get(NewInode(req.NewInode, 0))
+		if item == nil || item.(*Inode).ShouldDelete() {
+			resp.Status = proto.OpNotExistErr
+			return
+		}
+	}
+
+	if marker != nil {
+		if mp.config.Cursor < marker.Inode {
+			mp.config.Cursor = marker.Inode
+		}
+		if resp.Status = mp.fsmCreateInode(marker); resp.Status != proto.OpOk {
+			return
+		}
+		if len(req.MarkerAttrs) > 0 {
+			extend := NewExtend(marker.Inode)
+			for key, value := range req.MarkerAttrs {
+				extend.Put([]byte(key), []byte(value), 0)
+			}
+			if err := mp.fsmSetXAttr(extend); err != nil {
+				log.LogErrorf("action[fsmArchiveDentry] mp[%v] set marker xattr err %v", mp.config.PartitionId, err)
+			}
+		}
+	}
+	if current != nil {
+		newIno := req.NewInode
+		if promoted != nil {
+			newIno = promoted.Inode
+		}
+		if newIno != 0 {
+			den := &Dentry{ParentId: req.ParentID, Name: req.Name, Inode: newIno}
+			den.setVerSeq(req.VerSeq)
+			mp.fsmUpdateDentry(den)
+		} else {
+			den := &Dentry{ParentId: req.ParentID, Name: req.Name, Inode: current.Inode}
+			den.setVerSeq(req.VerSeq)
+			mp.fsmDeleteDentry(den, false)
+		}
+		if req.ArchiveName != "" {
+			mp.linkArchivedDentry(&Dentry{ParentId: req.ArchiveID, Name: req.ArchiveName, Inode: req.Inode, Type: current.Type}, req.VerSeq, resp)
+		} else {
+			resp.Dropped = req.Inode
+		}
+	}
+	if promoted != nil {
+		den := &Dentry{ParentId: req.ArchiveID, Name: req.Promote, Inode: promoted.Inode}
+		den.setVerSeq(req.VerSeq)
+		mp.fsmDeleteDentry(den, false)
+	}
+	if marker != nil {
+		mp.linkArchivedDentry(&Dentry{ParentId: req.ArchiveID, Name: req.MarkerName, Inode: marker.Inode, Type: marker.Type}, req.VerSeq, resp)
+		resp.Marker = marker
+	}
+	return
+}
+
+// linkArchivedDentry links the version into the archive directory, the version archived under the
+// same name before is replaced and returned to be released.
+func (mp *metaPartition) linkArchivedDentry(dentry *Dentry, verSeq uint64, resp *archiveDentryResponse) {
+	dentry.setVerSeq(verSeq)
+	if item := mp.dentryTree.Get(dentry); item != nil && !item.(*Dentry).isDeleted() {
+		ino := dentry.Inode
+		if r := mp.fsmUpdateDentry(dentry); r.Status == proto.OpOk && r.Msg.Inode != 0 && r.Msg.Inode != ino {
+			resp.Replaced = append(resp.Replaced, r.Msg.Inode)
+		}
+		return
+	}
+	if status := mp.fsmCreateDentry(dentry, false); status != proto.OpOk {
+		log.LogErrorf("action[linkArchivedDentry] mp[%v] link dentry %v status %v", mp.config.PartitionId, dentry, status)
+	}
+}
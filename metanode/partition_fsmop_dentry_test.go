@@ -0,0 +1,113 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"os"
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func requireDentryInode(t *testing.T, mp *metaPartition, parent uint64, name string, ino uint64) {
+	item := mp.dentryTree.Get(&Dentry{ParentId: parent, Name: name})
+	if ino == 0 {
+		require.Nil(t, item)
+		return
+	}
+	require.NotNil(t, item)
+	require.Equal(t, ino, item.(*Dentry).Inode)
+}
+
+func TestArchiveDentry(t *testing.T) {
+	mp := newDirStatTestPartition(t)
+	dirMode := proto.Mode(os.ModeDir | 0o755)
+	createDirStatTestDentry(t, mp, proto.RootIno, 5, "archive", dirMode)
+	createDirStatTestDentry(t, mp, proto.RootIno, 10, "obj", 0o644)
+	require.Equal(t, proto.OpOk, mp.fsmCreateInode(NewInode(11, 0o644)))
+
+	// the replaced version is archived with the new version linked
+	resp := mp.fsmArchiveDentry(&fsmArchiveDentryRequest{
+		ParentID: proto.RootIno, Name: "obj", Inode: 10, NewInode: 11, ArchiveID: 5, ArchiveName: "v1",
+	})
+	require.Equal(t, proto.OpOk, resp.Status)
+	requireDentryInode(t, mp, proto.RootIno, "obj", 11)
+	requireDentryInode(t, mp, 5, "v1", 10)
+
+	// nothing changes if the new version in the partition does not exist
+	resp = mp.fsmArchiveDentry(&fsmArchiveDentryRequest{
+		ParentID: proto.RootIno, Name: "obj", Inode: 11, NewInode: 13, ArchiveID: 5, ArchiveName: "v2",
+	})
+	require.Equal(t, proto.OpNotExistErr, resp.Status)
+	requireDentryInode(t, mp, proto.RootIno, "obj", 11)
+	requireDentryInode(t, mp, 5, "v2", 0)
+
+	// nor if the delete marker can not be created
+	exist, err := NewInode(11, 0o644).Marshal()
+	require.NoError(t, err)
+	resp = mp.fsmArchiveDentry(&fsmArchiveDentryRequest{
+		ParentID: proto.RootIno, Name: "obj", Inode: 11, ArchiveID: 5, ArchiveName: "v2",
+		Marker: exist, MarkerName: "v3",
+	})
+	require.Equal(t, proto.OpExistErr, resp.Status)
+	requireDentryInode(t, mp, proto.RootIno, "obj", 11)
+	requireDentryInode(t, mp, 5, "v2", 0)
+	requireDentryInode(t, mp, 5, "v3", 0)
+
+	// nothing changes if the current version is not the one expected
+	resp = mp.fsmArchiveDentry(&fsmArchiveDentryRequest{
+		ParentID: proto.RootIno, Name: "obj", Inode: 10, ArchiveID: 5, ArchiveName: "v2", MarkerName: "v3",
+	})
+	require.Equal(t, proto.OpNotExistErr, resp.Status)
+	requireDentryInode(t, mp, proto.RootIno, "obj", 11)
+	requireDentryInode(t, mp, 5, "v2", 0)
+
+	// the current version is archived with the delete marker created
+	marker := NewInode(20, 0o644)
+	val, err := marker.Marshal()
+	require.NoError(t, err)
+	resp = mp.fsmArchiveDentry(&fsmArchiveDentryRequest{
+		ParentID: proto.RootIno, Name: "obj", Inode: 11, ArchiveID: 5, ArchiveName: "v2",
+		Marker: val, MarkerName: "v3", MarkerAttrs: map[string]string{"oss:deleteMarker": "true"},
+	})
+	require.Equal(t, proto.OpOk, resp.Status)
+	require.Equal(t, uint64(20), resp.Marker.Inode)
+	requireDentryInode(t, mp, proto.RootIno, "obj", 0)
+	requireDentryInode(t, mp, 5, "v2", 11)
+	requireDentryInode(t, mp, 5, "v3", 20)
+	extend := mp.extendTree.Get(NewExtend(20)).(*Extend)
+	value, _ := extend.Get([]byte("oss:deleteMarker"))
+	require.Equal(t, "true", string(value))
+
+	// the current version deleted is dropped with the archived version promoted
+	createDirStatTestDentry(t, mp, proto.RootIno, 12, "obj", 0o644)
+	resp = mp.fsmArchiveDentry(&fsmArchiveDentryRequest{
+		ParentID: proto.RootIno, Name: "obj", Inode: 12, ArchiveID: 5, Promote: "v2",
+	})
+	require.Equal(t, proto.OpOk, resp.Status)
+	require.Equal(t, uint64(12), resp.Dropped)
+	requireDentryInode(t, mp, proto.RootIno, "obj", 11)
+	requireDentryInode(t, mp, 5, "v2", 0)
+
+	// the version archived under the same name is replaced
+	resp = mp.fsmArchiveDentry(&fsmArchiveDentryRequest{
+		ParentID: proto.RootIno, Name: "obj", Inode: 11, ArchiveID: 5, ArchiveName: "v1",
+	})
+	require.Equal(t, proto.OpOk, resp.Status)
+	require.Equal(t, []uint64{10}, resp.Replaced)
+	requireDentryInode(t, mp, proto.RootIno, "obj", 0)
+	requireDentryInode(t, mp, 5, "v1", 11)
+}
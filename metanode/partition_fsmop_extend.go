@@ -15,6 +15,7 @@
 package metanode
 
 import (
+	"encoding/json"
 	"fmt"
 	"math"
 
@@ -144,6 +145,71 @@ func (mp *metaPartition) fsmUnlockDir(req *proto.LockDirRequest) (resp *proto.Lo
 	return
 }
 
+func (mp *metaPartition) fsmFileLock(req *proto.FileLockRequest) (resp *proto.FileLockResponse) {
+	mp.xattrLock.Lock()
+	defer mp.xattrLock.Unlock()
+
+	resp = &proto.FileLockResponse{Status: proto.OpOk}
+	now := req.SubmitTime.Unix()
+	expire := now + int64(req.Lease)
+
+	var existExtend *Extend
+	var locks fileLocks
+	if treeItem := mp.extendTree.CopyGet(NewExtend(req.Inode)); treeItem != nil {
+		existExtend = treeItem.(*Extend)
+		oldValue, _ := existExtend.Get([]byte(innerFileLockKey))
+		var err error
+		if locks, err = parseFileLocks(oldValue); err != nil {
+			log.LogErrorf("fsmFileLock: parse locks failed, req %s, old %s, err %s", req.String(), string(oldValue), err.Error())
+		}
+	}
+	locks = locks.purge(now)
+
+	switch req.Op {
+	case proto.FileLockOpSet:
+		lk := req.Lock
+		if lk.Type != proto.FileLockUnlock {
+			if conflict := locks.conflict(&lk); conflict != nil {
+				log.LogDebugf("fsmFileLock: conflict, req %s, conflict %v", req.String(), conflict)
+				resp.Conflict = conflict
+				resp.Status = proto.OpExistErr
+				return
+			}
+		}
+		lk.Expire = expire
+		locks = locks.set(&lk)
+	case proto.FileLockOpRenew:
+		resp.Held = locks.renew(req.Lock.Client, expire)
+	default:
+		log.LogErrorf("fsmFileLock: unknown op, req %s", req.String())
+		resp.Status = proto.OpArgMismatchErr
+		return
+	}
+
+	log.LogDebugf("fsmFileLock: req %s, locks %d", req.String(), len(locks))
+
+	if len(locks) == 0 {
+		if existExtend != nil {
+			existExtend.Remove([]byte(innerFileLockKey))
+		}
+		return
+	}
+	newVal, err := json.Marshal(locks)
+	if err != nil {
+		log.LogErrorf("fsmFileLock: marshal locks failed, req %s, err %s", req.String(), err.Error())
+		resp.Status = proto.OpErr
+		return
+	}
+	newExtend := NewExtend(req.Inode)
+	newExtend.Put([]byte(innerFileLockKey), newVal, 0)
+	if existExtend == nil {
+		mp.extendTree.ReplaceOrInsert(newExtend, true)
+		return
+	}
+	existExtend.Merge(newExtend, true)
+	return
+}
+
 func (mp *metaPartition) fsmSetXAttr(extend *Extend) (err error) {
 	if mp.GetVerSeq() > 0 {
 		extend.setVersion(mp.GetVerSeq())
@@ -164,7 +230,7 @@ func (mp *metaPartition) fsmSetXAttr(extend *Extend) (err error) {
 		}
 		e.Merge(extend, true)
 	}
-
+	mp.recordXAttrChange(extend)
 	return
 }
 
@@ -181,6 +247,7 @@ func (mp *metaPartition) fsmRemoveXAttr(reqExtend *Extend) (err error) {
 			e.Remove(key)
 			return true
 		})
+		mp.recordXAttrChange(reqExtend)
 		return
 	}
 
@@ -210,6 +277,7 @@ func (mp *metaPartition) fsmRemoveXAttr(reqExtend *Extend) (err error) {
 			e.Remove(key)
 			return true
 		})
+		mp.recordXAttrChange(reqExtend)
 	} else if reqExtend.getVersion() == e.getVersion() {
 		var globalNewVer uint64
 		if globalNewVer, err = mp.multiVersionList.GetNextNewerVer(reqExtend.getVersion()); err != nil {
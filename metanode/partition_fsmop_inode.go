@@ -76,15 +76,16 @@ func (mp *metaPartition) fsmTxCreateInode(txIno *TxInode, quotaIds []uint32) (st
 
 // Create and inode and attach it to the inode tree.
 func (mp *metaPartition) fsmCreateInode(ino *Inode) (status uint8) {
-	if status = mp.uidManager.addUidSpace(ino.Uid, ino.Inode, nil); status != proto.OpOk {
+	if status = mp.uidManager.checkCreate(ino); status != proto.OpOk {
 		return
 	}
 
 	status = proto.OpOk
 	if _, ok := mp.inodeTree.ReplaceOrInsert(ino, false); !ok {
 		status = proto.OpExistErr
+		return
 	}
-
+	mp.uidManager.addUidFiles(ino, 1)
 	return
 }
 
@@ -382,6 +383,7 @@ func (mp *metaPartition) fsmUnlinkInode(ino *Inode, uniqID uint64) (resp *InodeR
 			log.LogDebugf("action[fsmUnlinkInode] mp[%v] ino[%v] really be deleted, empty dir", mp.config.PartitionId, inode)
 			mp.inodeTree.Delete(inode)
 			mp.updateUsedInfo(0, -1, inode.Inode)
+			mp.uidManager.addUidFiles(inode, -1)
 		}
 	} else if inode.IsTempFile() {
 		// all snapshot between create to last deletion cleaned
@@ -390,7 +392,8 @@ func (mp *metaPartition) fsmUnlinkInode(ino *Inode, uniqID uint64) (resp *InodeR
 			log.LogDebugf("action[fsmUnlinkInode] mp[%v] unlink inode[%v] and push to freeList", mp.config.PartitionId, inode)
 			inode.AccessTime = time.Now().Unix()
 			mp.freeList.Push(inode.Inode)
-			mp.uidManager.doMinusUidSpace(inode.Uid, inode.Inode, inode.Size)
+			mp.uidManager.doMinusUidSpace(inode, inode.Size)
+			mp.uidManager.addUidFiles(inode, -1)
 			log.LogDebugf("action[fsmUnlinkInode] mp[%v] ino[%v]", mp.config.PartitionId, inode)
 		}
 	}
@@ -462,6 +465,10 @@ func (mp *metaPartition) internalDeleteBatch(val []byte) error {
 
 func (mp *metaPartition) internalDeleteInode(ino *Inode) {
 	log.LogDebugf("action[internalDeleteInode] vol(%v) mp(%v) ino[%v] really be deleted", mp.config.VolName, mp.config.PartitionId, ino)
+	if item := mp.inodeTree.Get(ino); item != nil {
+		mp.sharedExtents.releaseInode(item.(*Inode))
+		mp.unlinkDeletedFileStat(item.(*Inode))
+	}
 	mp.inodeTree.Delete(ino)
 	mp.freeList.Remove(ino.Inode)
 	mp.extendTree.Delete(&Extend{inode: ino.Inode}) // Also delete extend attribute.
@@ -479,18 +486,22 @@ func (mp *metaPartition) fsmAppendExtents(ino *Inode) (status uint8) {
 		return
 	}
 	oldSize := int64(ino2.Size)
+	oldInline := len(ino2.InlineData)
 	eks := ino.HybridCloudExtents.sortedEks.(*SortedExtents).CopyExtents()
-	if status = mp.uidManager.addUidSpace(ino2.Uid, ino2.Inode, eks); status != proto.OpOk {
+	if status = mp.uidManager.addUidSpace(ino2, eks); status != proto.OpOk {
 		return
 	}
 	delExtents := ino2.AppendExtents(eks, ino.ModifyTime, mp.volType)
+	mp.minusUidInline(ino2, oldInline)
 	mp.updateUsedInfo(int64(ino2.Size)-oldSize, 0, ino2.Inode)
 	log.LogInfof("fsmAppendExtents mpId[%v].inode[%v] deleteExtents(%v)", mp.config.PartitionId, ino2.Inode, delExtents)
-	mp.uidManager.minusUidSpace(ino2.Uid, ino2.Inode, delExtents)
+	mp.uidManager.minusUidSpace(ino2, delExtents)
 
 	log.LogInfof("fsmAppendExtents mpId[%v].inode[%v] DecSplitExts deleteExtents(%v)", mp.config.PartitionId, ino2.Inode, delExtents)
 	ino2.DecSplitExts(mp.config.PartitionId, delExtents)
-	mp.extDelCh <- delExtents
+	mp.extDelCh <- mp.sharedExtents.release(ino2, delExtents)
+	mp.recordInodeChange(proto.ChangeWrite, ino2)
+	mp.updateDirStatSize(ino2, oldSize)
 	return
 }
 
@@ -559,7 +570,7 @@ func (mp *metaPartition) fsmAppendExtentsWithCheck(ino *Inode, isSplit bool) (st
 		discardExtentKey = eks[1:]
 	}
 
-	if status = mp.uidManager.addUidSpace(fsmIno.Uid, fsmIno.Inode, eks[:1]); status != proto.OpOk {
+	if status = mp.uidManager.addUidSpace(fsmIno, eks[:1]); status != proto.OpOk {
 		log.LogErrorf("fsmAppendExtentsWithCheck.mp[%v] addUidSpace status [%v]", mp.config.PartitionId, status)
 		return
 	}
@@ -580,11 +591,17 @@ func (mp *metaPartition) fsmAppendExtentsWithCheck(ino *Inode, isSplit bool) (st
 	}
 
 	if !isSplit {
+		oldInline := len(fsmIno.InlineData)
 		delExtents, status = fsmIno.AppendExtentWithCheck(appendExtParam)
 		if status == proto.OpOk {
+			mp.minusUidInline(fsmIno, oldInline)
 			log.LogInfof("action[fsmAppendExtentsWithCheck] mp[%v] DecSplitExts delExtents [%v]", mp.config.PartitionId, delExtents)
 			fsmIno.DecSplitExts(appendExtParam.mpId, delExtents)
-			mp.extDelCh <- delExtents
+			mp.extDelCh <- mp.sharedExtents.release(fsmIno, delExtents)
+			if !isCache && !isMigration {
+				mp.recordInodeChange(proto.ChangeWrite, fsmIno)
+				mp.updateDirStatSize(fsmIno, oldSize)
+			}
 		}
 		// conflict need delete eks[0], to clear garbage data
 		if status == proto.OpConflictExtentsErr {
@@ -607,13 +624,13 @@ func (mp *metaPartition) fsmAppendExtentsWithCheck(ino *Inode, isSplit bool) (st
 		log.LogInfof("action[fsmAppendExtentsWithCheck] mp[%v] DecSplitExts delExtents [%v]", mp.config.PartitionId, delExtents)
 		fsmIno.DecSplitExts(mp.config.PartitionId, delExtents)
 		mp.extDelCh <- delExtents
-		mp.uidManager.minusUidSpace(fsmIno.Uid, fsmIno.Inode, delExtents)
+		mp.uidManager.minusUidSpace(fsmIno, delExtents)
 	}
 
 	// conflict need delete eks[0], to clear garbage data
 	if status == proto.OpConflictExtentsErr {
 		mp.extDelCh <- eks[:1]
-		mp.uidManager.minusUidSpace(fsmIno.Uid, fsmIno.Inode, eks[:1])
+		mp.uidManager.minusUidSpace(fsmIno, eks[:1])
 		log.LogDebugf("fsmAppendExtentsWithCheck mp[%v] delExtents inode[%v] ek(%v)", mp.config.PartitionId, fsmIno.Inode, delExtents)
 	}
 
@@ -645,12 +662,16 @@ func (mp *metaPartition) fsmAppendObjExtents(ino *Inode) (status uint8) {
 	}
 	// eks := ino.ObjExtents.CopyExtents()
 	eks := ino.HybridCloudExtents.sortedEks.(*SortedObjExtents).CopyExtents()
+	oldSize := int64(inode.Size)
 	err := inode.AppendObjExtents(eks, ino.ModifyTime)
 	// if err is not nil, means obj eks exist overlap.
 	if err != nil {
 		log.LogErrorf("fsmAppendExtents inode[%v] err(%v)", inode.Inode, err)
 		status = proto.OpConflictExtentsErr
+		return
 	}
+	mp.recordInodeChange(proto.ChangeWrite, inode)
+	mp.updateDirStatSize(inode, oldSize)
 	return
 }
 
@@ -692,7 +713,7 @@ func (mp *metaPartition) fsmExtentsTruncate(ino *Inode) (resp *InodeResponse) {
 	doOnLastKey := func(lastKey *proto.ExtentKey) {
 		var eks []proto.ExtentKey
 		eks = append(eks, *lastKey)
-		mp.uidManager.minusUidSpace(i.Uid, i.Inode, eks)
+		mp.uidManager.minusUidSpace(i, eks)
 	}
 
 	insertSplitKey := func(ek *proto.ExtentKey) {
@@ -709,9 +730,17 @@ func (mp *metaPartition) fsmExtentsTruncate(ino *Inode) (resp *InodeResponse) {
 		return
 	}
 	oldSize := int64(i.Size)
+	oldInline := len(i.InlineData)
+	inline := oldInline > 0
 	delExtents := i.ExtentsTruncate(ino.Size, ino.ModifyTime, doOnLastKey, insertSplitKey)
+	mp.minusUidInline(i, oldInline)
+	mp.recordInodeChange(proto.ChangeWrite, i)
+	mp.updateDirStatSize(i, oldSize)
 
 	if len(delExtents) == 0 {
+		if inline {
+			mp.updateUsedInfo(int64(i.Size)-oldSize, 0, i.Inode)
+		}
 		return
 	}
 
@@ -723,11 +752,270 @@ func (mp *metaPartition) fsmExtentsTruncate(ino *Inode) (resp *InodeResponse) {
 	// now we should delete the extent
 	log.LogInfof("fsmExtentsTruncate.mp (%v) inode[%v] DecSplitExts exts(%v)", mp.config.PartitionId, i.Inode, delExtents)
 	i.DecSplitExts(mp.config.PartitionId, delExtents)
-	mp.extDelCh <- delExtents
-	mp.uidManager.minusUidSpace(i.Uid, i.Inode, delExtents)
+	mp.extDelCh <- mp.sharedExtents.release(i, delExtents)
+	mp.uidManager.minusUidSpace(i, delExtents)
+	return
+}
+
+func (mp *metaPartition) fsmFallocate(req *proto.FallocateRequest) (resp *InodeResponse) {
+	var err error
+	resp = NewInodeResponse()
+	log.LogDebugf("fsmFallocate. req %v mpId(%v)", req, mp.config.PartitionId)
+	resp.Status = proto.OpOk
+	item := mp.inodeTree.Get(NewInode(req.Inode, 0))
+	if item == nil {
+		resp.Status = proto.OpNotExistErr
+		return
+	}
+	i := item.(*Inode)
+	if !proto.IsStorageClassReplica(i.StorageClass) {
+		log.LogWarnf("[fsmFallocate] mpId(%v) ino(%v) storageClass is %v, not allowed fallocate",
+			mp.config.PartitionId, i.Inode, proto.StorageClassString(i.StorageClass))
+		resp.Status = proto.OpArgMismatchErr
+		return
+	}
+	if i.HybridCloudExtents.sortedEks != nil {
+		if value, ok := i.HybridCloudExtents.sortedEks.(*SortedExtents); !ok {
+			log.LogWarnf("[fsmFallocate] mpId(%v) ino(%v) storageClass(%v), extent actualType is [%T] but expect SortedExtents",
+				mp.config.PartitionId, i.Inode, i.StorageClass, value)
+			resp.Status = proto.OpArgMismatchErr
+			return
+		}
+	}
+	if i.ShouldDelete() {
+		resp.Status = proto.OpNotExistErr
+		return
+	}
+	// the client moves the inline data into extents before fallocate
+	if proto.IsDir(i.Type) || len(i.InlineData) > 0 {
+		resp.Status = proto.OpArgMismatchErr
+		return
+	}
+
+	insertSplitKey := func(ek *proto.ExtentKey) {
+		i.insertEkRefMap(mp.config.PartitionId, ek)
+	}
+
+	if i.getVer() != mp.verSeq {
+		i.CreateVer(mp.verSeq)
+	}
+	i.Lock()
+	defer i.Unlock()
+
+	if err = i.CreateLowerVersion(i.getVer(), mp.multiVersionList); err != nil {
+		return
+	}
+	oldSize := int64(i.Size)
+	delExtents := i.Fallocate(req.Mode, req.Offset, req.Length, req.SubmitTime.Unix(), insertSplitKey)
+	mp.updateUsedInfo(int64(i.Size)-oldSize, 0, i.Inode)
+	mp.recordInodeChange(proto.ChangeWrite, i)
+	mp.updateDirStatSize(i, oldSize)
+	if len(delExtents) == 0 {
+		return
+	}
+
+	if delExtents, err = i.RestoreExts2NextLayer(mp.config.PartitionId, delExtents, mp.verSeq, 0); err != nil {
+		panic("RestoreExts2NextLayer should not be error")
+	}
+
+	log.LogInfof("fsmFallocate.mp (%v) inode[%v] DecSplitExts exts(%v)", mp.config.PartitionId, i.Inode, delExtents)
+	i.DecSplitExts(mp.config.PartitionId, delExtents)
+	mp.extDelCh <- mp.sharedExtents.release(i, delExtents)
+	mp.uidManager.minusUidSpace(i, delExtents)
 	return
 }
 
+// fsmFsckDeleteExtents queues the orphan extents found by fsck to be deleted, the extents belong
+// to no inode so nothing else of the partition changes.
+func (mp *metaPartition) fsmFsckDeleteExtents(eks []proto.ExtentKey) (status uint8) {
+	log.LogInfof("fsmFsckDeleteExtents: mp(%v) orphan extents(%v)", mp.config.PartitionId, eks)
+	mp.extDelCh <- eks
+	return proto.OpOk
+}
+
+type fsmWriteInlineRequest struct {
+	Inode  uint64 `json:"ino"`
+	Offset uint64 `json:"off"`
+	Data   []byte `json:"data"`
+	Time   int64  `json:"time"`
+}
+
+// fsmWriteInline writes the data into the inode of the tiny file, which has no extents.
+func (mp *metaPartition) fsmWriteInline(req *fsmWriteInlineRequest) (resp *InodeResponse) {
+	resp = NewInodeResponse()
+	resp.Status = proto.OpOk
+	item := mp.inodeTree.CopyGet(NewInode(req.Inode, 0))
+	if item == nil || item.(*Inode).ShouldDelete() {
+		resp.Status = proto.OpNotExistErr
+		return
+	}
+	i := item.(*Inode)
+	extents, ok := i.HybridCloudExtents.sortedEks.(*SortedExtents)
+	if !ok || extents.Len() != 0 || !proto.IsRegular(i.Type) || !proto.IsStorageClassReplica(i.StorageClass) ||
+		i.HybridCloudExtentsMigration.sortedEks != nil || req.Offset+uint64(len(req.Data)) > proto.MaxInlineDataSize {
+		resp.Status = proto.OpArgMismatchErr
+		return
+	}
+	// the inline data grown is accounted to the owners as the extents appended
+	if grown := int64(req.Offset) + int64(len(req.Data)) - int64(len(i.InlineData)); grown > 0 {
+		if resp.Status = mp.uidManager.addUidSize(i, grown); resp.Status != proto.OpOk {
+			return
+		}
+	}
+	i.Lock()
+	oldSize := int64(i.Size)
+	i.WriteInline(req.Offset, req.Data, req.Time)
+	i.Unlock()
+	mp.updateUsedInfo(int64(i.Size)-oldSize, 0, i.Inode)
+	mp.recordInodeChange(proto.ChangeWrite, i)
+	mp.updateDirStatSize(i, oldSize)
+	resp.Msg = i
+	return
+}
+
+// minusUidInline returns the space of the inline data dropped from the inode to the owners,
+// oldInline is the size of the inline data before the inode is written.
+func (mp *metaPartition) minusUidInline(ino *Inode, oldInline int) {
+	if dropped := oldInline - len(ino.InlineData); dropped > 0 {
+		mp.uidManager.doMinusUidSpace(ino, uint64(dropped))
+	}
+}
+
+// fsmCloneInodeRequest clones the inode into the inode DstInode, which is created if Create is
+// set, or else is an empty file.
+type fsmCloneInodeRequest struct {
+	Inode    uint64 `json:"ino"`
+	DstInode uint64 `json:"dst"`
+	Create   bool   `json:"create"`
+	Uid      uint32 `json:"uid"`
+	Gid      uint32 `json:"gid"`
+	Time     int64  `json:"time"`
+	// Marked is set if the extents in Shared are marked shared on the data partitions, the
+	// clone is refused if the source has extents not marked since.
+	Marked bool                   `json:"marked,omitempty"`
+	Shared []proto.SharedExtentID `json:"shared,omitempty"`
+}
+
+// fsmCloneInode copies the extent keys of the regular file into the destination, the extents
+// are counted in the shared extents so that they are deleted only after all the files drop them.
+func (mp *metaPartition) fsmCloneInode(req *fsmCloneInodeRequest) (resp *InodeResponse) {
+	resp = NewInodeResponse()
+	resp.Status = proto.OpOk
+	item := mp.inodeTree.Get(NewInode(req.Inode, 0))
+	if item == nil || item.(*Inode).ShouldDelete() {
+		resp.Status = proto.OpNotExistErr
+		return
+	}
+	src := item.(*Inode)
+	eks, status := cloneableExtents(src)
+	if status != proto.OpOk {
+		resp.Status = status
+		return
+	}
+	if req.Marked && !allExtentsMarked(eks, req.Shared) {
+		log.LogWarnf("fsmCloneInode: mp(%v) ino(%v) is written after the extents are marked shared",
+			mp.config.PartitionId, src.Inode)
+		resp.Status = proto.OpAgain
+		return
+	}
+
+	var dst *Inode
+	if req.Create {
+		dst = NewInode(req.DstInode, src.Type)
+		dst.Uid = req.Uid
+		dst.Gid = req.Gid
+		dst.CreateTime = req.Time
+		dst.AccessTime = req.Time
+		dst.setVer(mp.verSeq)
+		dst.StorageClass = src.StorageClass
+		dst.HybridCloudExtents.sortedEks = NewSortedExtents()
+		if resp.Status = mp.uidManager.checkCreate(dst); resp.Status != proto.OpOk {
+			return
+		}
+	} else {
+		if item = mp.inodeTree.Get(NewInode(req.DstInode, 0)); item == nil || item.(*Inode).ShouldDelete() {
+			resp.Status = proto.OpNotExistErr
+			return
+		}
+		dst = item.(*Inode)
+		if dstEks, status := cloneableExtents(dst); status != proto.OpOk || dst.Size != 0 || len(dstEks) != 0 {
+			log.LogWarnf("fsmCloneInode: mp(%v) ino(%v) is not an empty file to clone into", mp.config.PartitionId, dst.Inode)
+			resp.Status = proto.OpArgMismatchErr
+			return
+		}
+	}
+	// the inline data copied is accounted to the owners of the destination with the extents
+	space := int64(len(src.InlineData))
+	for _, ek := range eks {
+		space += int64(ek.Size)
+	}
+	if resp.Status = mp.uidManager.addUidSize(dst, space); resp.Status != proto.OpOk {
+		return
+	}
+	if req.Create {
+		if _, ok := mp.inodeTree.ReplaceOrInsert(dst, false); !ok {
+			mp.uidManager.doMinusUidSpace(dst, uint64(space))
+			resp.Status = proto.OpExistErr
+			return
+		}
+		mp.uidManager.addUidFiles(dst, 1)
+	}
+
+	dst.Lock()
+	dst.HybridCloudExtents.sortedEks = NewSortedExtentsFromEks(eks)
+	dst.InlineData = src.copyInlineData()
+	dst.Size = src.Size
+	dst.ModifyTime = req.Time
+	dst.Generation++
+	dst.Unlock()
+	// the source is copied on write from now on, the clients caching its extents refresh them
+	src.Lock()
+	src.Generation++
+	src.Unlock()
+	mp.sharedExtents.share(eks)
+	mp.updateUsedInfo(int64(dst.Size), 0, dst.Inode)
+	mp.recordInodeChange(proto.ChangeWrite, dst)
+	mp.updateDirStatSize(dst, 0)
+	log.LogInfof("fsmCloneInode: mp(%v) ino(%v) cloned into ino(%v), extents(%v) size(%v)",
+		mp.config.PartitionId, src.Inode, dst.Inode, len(eks), dst.Size)
+	resp.Msg = dst
+	return
+}
+
+func allExtentsMarked(eks []proto.ExtentKey, marked []proto.SharedExtentID) bool {
+	ids := make(map[proto.SharedExtentID]struct{}, len(marked))
+	for _, id := range marked {
+		ids[id] = struct{}{}
+	}
+	for i := range eks {
+		if _, ok := ids[sharedExtentIDOf(&eks[i])]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// cloneableExtents returns the extent keys of the inode, if it is a regular file of replicas
+// with no tiny extents, which are shared by the files and cannot be counted by extent.
+func cloneableExtents(ino *Inode) (eks []proto.ExtentKey, status uint8) {
+	if !proto.IsRegular(ino.Type) || !proto.IsStorageClassReplica(ino.StorageClass) ||
+		ino.HybridCloudExtentsMigration.sortedEks != nil {
+		return nil, proto.OpArgMismatchErr
+	}
+	extents, ok := ino.HybridCloudExtents.sortedEks.(*SortedExtents)
+	if !ok {
+		return nil, proto.OpArgMismatchErr
+	}
+	eks = extents.CopyExtents()
+	for i := range eks {
+		if storage.IsTinyExtent(eks[i].ExtentId) || eks[i].IsSplit() {
+			return nil, proto.OpArgMismatchErr
+		}
+		eks[i].SnapInfo = nil
+	}
+	return eks, proto.OpOk
+}
+
 func (mp *metaPartition) fsmEvictInode(ino *Inode) (resp *InodeResponse) {
 	resp = NewInodeResponse()
 	log.LogDebugf("action[fsmEvictInode] inode[%v]", ino)
@@ -796,7 +1084,10 @@ func (mp *metaPartition) fsmSetAttr(req *SetattrRequest) (err error) {
 	if ino.ShouldDelete() {
 		return
 	}
+	oldUid, oldGid := ino.Uid, ino.Gid
 	ino.SetAttr(req)
+	mp.uidManager.chownUidSpace(ino, oldUid, oldGid)
+	mp.recordSetAttrChange(req)
 	return
 }
 
@@ -823,7 +1114,7 @@ func (mp *metaPartition) fsmExtentsEmpty(ino *Inode) (status uint8) {
 
 	if len(tinyEks) > 0 {
 		mp.extDelCh <- tinyEks
-		mp.uidManager.minusUidSpace(i.Uid, i.Inode, tinyEks)
+		mp.uidManager.minusUidSpace(i, tinyEks)
 		log.LogDebugf("fsmExtentsEmpty mp[%v] inode[%d] tinyEks(%v)", mp.config.PartitionId, ino.Inode, tinyEks)
 	}
 
@@ -1110,6 +1401,12 @@ func (mp *metaPartition) fsmUpdateExtentKeyAfterMigration(inoParam *Inode) (resp
 		return
 	}
 
+	if len(i.InlineData) > 0 {
+		log.LogWarnf("fsmUpdateExtentKeyAfterMigration: inode with inline data is not migrated %d", inoParam.Inode)
+		resp.Status = proto.OpNotPerm
+		return
+	}
+
 	if i.LeaseExpireTime != inoParam.LeaseExpireTime {
 		log.LogWarnf("fsmUpdateExtentKeyAfterMigration: inode is forbidden to migrate. gen %d, reqGen %d, ino %d",
 			i.LeaseExpireTime, inoParam.LeaseExpireTime, i.Inode)
@@ -35,6 +35,7 @@ type MetaItem struct {
 	Op uint32 `json:"Op"`
 	K  []byte `json:"k"`
 	V  []byte `json:"v"`
+	T  int64  `json:"t,omitempty"` // unix seconds when the op is submitted, only in the raft log
 }
 
 // MarshalJson
@@ -125,6 +126,13 @@ type fileData struct {
 	data     []byte
 }
 
+// kvData is the key-value of the tree of the table backed by the kv store.
+type kvData struct {
+	table byte
+	key   []byte
+	value []byte
+}
+
 const (
 	// initial version
 	SnapFormatVersion_0 uint32 = iota
@@ -145,10 +153,13 @@ type MetaItemIterator struct {
 	dentryTree        *BTree
 	extendTree        *BTree
 	multipartTree     *BTree
+	dirStatTree       *BTree
 	txTree            *BTree
 	txRbInodeTree     *BTree
 	txRbDentryTree    *BTree
 	uniqChecker       *uniqChecker
+	sharedExtents     *sharedExtents
+	dirStats          *dirStats
 	verList           []*proto.VolVersionInfo
 
 	filenames []string
@@ -186,6 +197,14 @@ func (siw *SnapItemWrapper) UnmarshalKey(k []byte) (err error) {
 	return
 }
 
+// release releases the snapshots of the trees once all the items are produced.
+func (si *MetaItemIterator) release() {
+	for _, tree := range []*BTree{si.inodeTree, si.dentryTree, si.extendTree, si.multipartTree,
+		si.dirStatTree, si.txTree, si.txRbInodeTree, si.txRbDentryTree} {
+		tree.Release()
+	}
+}
+
 // newMetaItemIterator returns a new MetaItemIterator.
 func newMetaItemIterator(mp *metaPartition) (si *MetaItemIterator, err error) {
 	si = new(MetaItemIterator)
@@ -200,10 +219,13 @@ func newMetaItemIterator(mp *metaPartition) (si *MetaItemIterator, err error) {
 	si.dentryTree = mp.dentryTree.GetTree()
 	si.extendTree = mp.extendTree.GetTree()
 	si.multipartTree = mp.multipartTree.GetTree()
+	si.dirStatTree = mp.dirStatTree.GetTree()
 	si.txTree = mp.txProcessor.txManager.txTree.GetTree()
 	si.txRbInodeTree = mp.txProcessor.txResource.txRbInodeTree.GetTree()
 	si.txRbDentryTree = mp.txProcessor.txResource.txRbDentryTree.GetTree()
 	si.uniqChecker = mp.uniqChecker.clone()
+	si.sharedExtents = mp.sharedExtents.clone()
+	si.dirStats = mp.dirStats.clone()
 	si.verList = mp.GetAllVerList()
 	mp.nonIdempotent.Unlock()
 
@@ -215,6 +237,7 @@ func newMetaItemIterator(mp *metaPartition) (si *MetaItemIterator, err error) {
 	filenames := make([]string, 0)
 	var fileInfos []os.DirEntry
 	if fileInfos, err = os.ReadDir(mp.config.RootDir); err != nil {
+		si.release()
 		return
 	}
 
@@ -231,6 +254,7 @@ func newMetaItemIterator(mp *metaPartition) (si *MetaItemIterator, err error) {
 	// start data producer
 	go func(iter *MetaItemIterator) {
 		defer func() {
+			iter.release()
 			close(iter.dataCh)
 			close(iter.errorCh)
 		}()
@@ -294,33 +318,22 @@ func newMetaItemIterator(mp *metaPartition) (si *MetaItemIterator, err error) {
 			panic(fmt.Sprintf("invalid raftSyncSnapFormatVersione: %v", si.SnapFormatVersion))
 		}
 
-		// process inodes
-		iter.inodeTree.Ascend(func(i BtreeItem) bool {
-			return produceItem(i)
-		})
-		if checkClose() {
-			return
-		}
-		// process dentries
-		iter.dentryTree.Ascend(func(i BtreeItem) bool {
-			return produceItem(i)
-		})
-		if checkClose() {
-			return
-		}
-		// process extends
-		iter.extendTree.Ascend(func(i BtreeItem) bool {
-			return produceItem(i)
-		})
-		if checkClose() {
-			return
-		}
-		// process multiparts
-		iter.multipartTree.Ascend(func(i BtreeItem) bool {
-			return produceItem(i)
-		})
-		if checkClose() {
-			return
+		// process inodes, dentries, extends, multiparts and dir stats, the trees backed by the kv
+		// store are sent as the key-values in the store
+		for _, tree := range []*BTree{iter.inodeTree, iter.dentryTree, iter.extendTree, iter.multipartTree, iter.dirStatTree} {
+			if tree.store != nil {
+				table := tree.store.codec.table
+				tree.ascendKV(func(key, value []byte) bool {
+					return produceItem(&kvData{table: table, key: key, value: value})
+				})
+			} else {
+				tree.Ascend(func(i BtreeItem) bool {
+					return produceItem(i)
+				})
+			}
+			if checkClose() {
+				return
+			}
 		}
 
 		if si.SnapFormatVersion == SnapFormatVersion_1 {
@@ -351,6 +364,20 @@ func newMetaItemIterator(mp *metaPartition) (si *MetaItemIterator, err error) {
 					return
 				}
 			}
+
+			// sent only if there are clones, so that the followers of old versions still work
+			if si.sharedExtents.Len() > 0 {
+				produceItem(si.sharedExtents)
+				if checkClose() {
+					return
+				}
+			}
+			if !si.dirStats.isEmpty() {
+				produceItem(si.dirStats)
+				if checkClose() {
+					return
+				}
+			}
 		}
 
 		// process extent del files
@@ -476,6 +503,9 @@ func (si *MetaItemIterator) Next() (data []byte, err error) {
 		snap = NewMetaItem(opFSMTxRbDentrySnapshot, []byte(typedItem.txDentryInfo.GetKey()), val)
 	case *fileData:
 		snap = NewMetaItem(opExtentFileSnapshot, []byte(typedItem.filename), typedItem.data)
+	case *kvData:
+		key := make([]byte, 0, len(typedItem.key)+1)
+		snap = NewMetaItem(opFSMStoreKVSnap, append(append(key, typedItem.table), typedItem.key...), typedItem.value)
 	case *uniqChecker:
 		var raw []byte
 		if raw, _, err = typedItem.Marshal(); err != nil {
@@ -484,6 +514,30 @@ func (si *MetaItemIterator) Next() (data []byte, err error) {
 			return
 		}
 		snap = NewMetaItem(opFSMUniqCheckerSnap, nil, raw)
+	case *sharedExtents:
+		var raw []byte
+		if raw, _, err = typedItem.Marshal(); err != nil {
+			si.err = err
+			si.Close()
+			return
+		}
+		snap = NewMetaItem(opFSMSharedExtentsSnap, nil, raw)
+	case *dirStats:
+		var raw []byte
+		if raw, _, err = typedItem.Marshal(nil); err != nil {
+			si.err = err
+			si.Close()
+			return
+		}
+		snap = NewMetaItem(opFSMDirStatsSnap, nil, raw)
+	case *dirStatEntry:
+		var raw []byte
+		if raw, err = typedItem.Marshal(); err != nil {
+			si.err = err
+			si.Close()
+			return
+		}
+		snap = NewMetaItem(opFSMDirStatEntrySnap, uint64Key(typedItem.ino), raw)
 	default:
 		panic(fmt.Sprintf("unknown item type: %v", reflect.TypeOf(item).Name()))
 	}
@@ -517,6 +517,92 @@ func (mp *metaPartition) UpdateDentry(req *UpdateDentryReq, p *Packet, remoteAdd
 	return
 }
 
+// ArchiveDentry moves the current version of an object into the version archive directory of
+// its key and links the new current version or the delete marker in one apply, so the key never
+// loses its current version or its delete marker halfway.
+func (mp *metaPartition) ArchiveDentry(req *proto.ArchiveDentryRequest, p *Packet, remoteAddr string) (err error) {
+	start := time.Now()
+	if mp.IsEnableAuditLog() {
+		defer func() {
+			auditlog.LogDentryOp(remoteAddr, mp.GetVolName(), p.GetOpMsg(), req.Name, req.GetFullPath(), err, time.Since(start).Milliseconds(), req.Inode, req.ParentID)
+		}()
+	}
+	if req.Inode == 0 && (req.NewInode != 0 || req.Promote != "") || req.NewInode != 0 && req.Promote != "" ||
+		req.Inode == 0 && req.MarkerName == "" {
+		err = fmt.Errorf("invalid archive request %v", req)
+		p.PacketErrorWithBody(proto.OpArgMismatchErr, []byte(err.Error()))
+		return
+	}
+
+	fsmReq := &fsmArchiveDentryRequest{
+		ParentID:    req.ParentID,
+		Name:        req.Name,
+		Inode:       req.Inode,
+		NewInode:    req.NewInode,
+		ArchiveID:   req.ArchiveID,
+		ArchiveName: req.ArchiveName,
+		Promote:     req.Promote,
+		MarkerName:  req.MarkerName,
+		MarkerAttrs: req.MarkerAttrs,
+		VerSeq:      mp.verSeq,
+	}
+	if req.MarkerName != "" {
+		var storageClass uint32
+		if storageClass, err = mp.checkCreateInoStorageClassForCompatibility(req.StorageType, 0); err != nil {
+			p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+			return
+		}
+		var inoID uint64
+		if inoID, err = mp.nextInodeID(); err != nil {
+			p.PacketErrorWithBody(proto.OpInodeFullErr, []byte(err.Error()))
+			return
+		}
+		marker := NewInode(inoID, req.MarkerMode)
+		marker.setVer(mp.verSeq)
+		marker.StorageClass = storageClass
+		if proto.IsStorageClassReplica(storageClass) {
+			marker.HybridCloudExtents.sortedEks = NewSortedExtents()
+		} else {
+			marker.HybridCloudExtents.sortedEks = NewSortedObjExtents()
+		}
+		if fsmReq.Marker, err = marker.Marshal(); err != nil {
+			p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+			return
+		}
+	}
+	val, err := json.Marshal(fsmReq)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	r, err := mp.submit(opFSMArchiveDentry, val)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
+	resp := r.(*archiveDentryResponse)
+	if resp.Status != proto.OpOk {
+		err = fmt.Errorf("archive dentry req %v status %v", req, resp.Status)
+		p.PacketErrorWithBody(resp.Status, []byte(err.Error()))
+		return
+	}
+	reply := &proto.ArchiveDentryResponse{
+		Dropped:  resp.Dropped,
+		Replaced: resp.Replaced,
+	}
+	if resp.Marker != nil {
+		reply.Marker = &proto.InodeInfo{}
+		replyInfo(reply.Marker, resp.Marker, make(map[uint32]*proto.MetaQuotaInfo))
+	}
+	var body []byte
+	if body, err = json.Marshal(reply); err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(body)
+	return
+}
+
 func (mp *metaPartition) ReadDirOnly(req *ReadDirOnlyReq, p *Packet) (err error) {
 	resp := mp.readDirOnly(req)
 	reply, err := json.Marshal(resp)
@@ -594,7 +680,7 @@ func (mp *metaPartition) Lookup(req *LookupReq, p *Packet) (err error) {
 	return
 }
 
-// GetDentryTree returns the dentry tree stored in the meta partition.
+// GetDentryTree returns the dentry tree stored in the meta partition, the caller releases it by Release after use.
 func (mp *metaPartition) GetDentryTree() *BTree {
 	return mp.dentryTree.GetTree()
 }
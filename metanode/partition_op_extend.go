@@ -126,8 +126,9 @@ func (mp *metaPartition) GetXAttr(req *proto.GetXAttrRequest, p *Packet) (err er
 		Inode:       req.Inode,
 		Key:         req.Key,
 	}
-	treeItem := mp.extendTree.Get(NewExtend(req.Inode))
-	if treeItem != nil {
+	if proto.IsDirStatKey(req.Key) {
+		response.Value = mp.dirStatXAttr(req.Inode, req.Key)
+	} else if treeItem := mp.extendTree.Get(NewExtend(req.Inode)); treeItem != nil {
 		if extend := treeItem.(*Extend).GetExtentByVersion(req.VerSeq); extend != nil {
 			if value, exist := extend.Get([]byte(req.Key)); exist {
 				response.Value = string(value)
@@ -279,3 +280,57 @@ func (mp *metaPartition) LockDir(req *proto.LockDirRequest, p *Packet) (err erro
 	p.PacketErrorWithBody(status, reply)
 	return
 }
+
+func (mp *metaPartition) FileLock(req *proto.FileLockRequest, p *Packet) (err error) {
+	req.SubmitTime = time.Now()
+
+	var resp *proto.FileLockResponse
+	if req.Op == proto.FileLockOpGet {
+		resp = mp.getFileLock(req)
+	} else {
+		var val []byte
+		if val, err = json.Marshal(req); err != nil {
+			p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+			return err
+		}
+		var r interface{}
+		if r, err = mp.submit(opFSMFileLock, val); err != nil {
+			p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+			return err
+		}
+		resp = r.(*proto.FileLockResponse)
+	}
+
+	status := resp.Status
+	var reply []byte
+	reply, err = json.Marshal(resp)
+	if err != nil {
+		status = proto.OpErr
+		reply = []byte(err.Error())
+	}
+	p.PacketErrorWithBody(status, reply)
+	return
+}
+
+// getFileLock looks for the lock conflicting with the requested one, which does not change
+// anything and is served by the leader without submitting.
+func (mp *metaPartition) getFileLock(req *proto.FileLockRequest) (resp *proto.FileLockResponse) {
+	resp = &proto.FileLockResponse{Status: proto.OpOk}
+	treeItem := mp.extendTree.Get(NewExtend(req.Inode))
+	if treeItem == nil {
+		return
+	}
+	mp.xattrLock.Lock()
+	value, _ := treeItem.(*Extend).Get([]byte(innerFileLockKey))
+	locks, err := parseFileLocks(value)
+	mp.xattrLock.Unlock()
+	if err != nil {
+		log.LogErrorf("getFileLock: parse locks failed, req %s, err %s", req.String(), err.Error())
+		resp.Status = proto.OpErr
+		return
+	}
+	if conflict := locks.purge(req.SubmitTime.Unix()).conflict(&req.Lock); conflict != nil {
+		resp.Conflict = conflict
+	}
+	return
+}
@@ -24,7 +24,9 @@ import (
 
 	"github.com/cubefs/cubefs/util/timeutil"
 
+	"github.com/cubefs/cubefs/datanode/storage"
 	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util"
 	"github.com/cubefs/cubefs/util/auditlog"
 	"github.com/cubefs/cubefs/util/errors"
 	"github.com/cubefs/cubefs/util/exporter"
@@ -51,17 +53,16 @@ func (mp *metaPartition) CheckQuota(inodeId uint64, p *Packet) (iParm *Inode, in
 	inode = item.(*Inode)
 	iParm.StorageClass = inode.StorageClass
 
-	mp.uidManager.acLock.Lock()
-	if mp.uidManager.getUidAcl(inode.Uid) {
-		log.LogWarnf("CheckQuota UidSpace.volName[%v] mp[%v] uid %v be set full", mp.uidManager.mpID, mp.uidManager.volName, inode.Uid)
-		mp.uidManager.acLock.Unlock()
+	mp.uidManager.acLock.RLock()
+	limited := mp.uidManager.isLimited(inode, false)
+	mp.uidManager.acLock.RUnlock()
+	if limited {
 		status = proto.OpNoSpaceErr
 		err = errors.New("CheckQuota UidSpace is over quota")
 		reply := []byte(err.Error())
 		p.PacketErrorWithBody(status, reply)
 		return
 	}
-	mp.uidManager.acLock.Unlock()
 	return
 }
 
@@ -467,6 +468,7 @@ func (mp *metaPartition) ExtentsList(req *proto.GetExtentsRequest, p *Packet) (e
 		if vIno = mp.getInodeByVer(vIno); vIno != nil {
 			resp.Generation = vIno.Generation
 			resp.Size = vIno.Size
+			resp.InlineData = vIno.copyInlineData()
 		}
 	} else {
 		if req.IsCache || proto.IsStorageClassBlobStore(ino.StorageClass) {
@@ -517,7 +519,9 @@ func (mp *metaPartition) ExtentsList(req *proto.GetExtentsRequest, p *Packet) (e
 						return true
 					})
 				}
+				resp.InlineData = ino.copyInlineData()
 			})
+			resp.Shared = mp.sharedExtents.sharedOf(resp.Extents)
 		}
 	}
 	if req.VerAll {
@@ -646,6 +650,413 @@ func (mp *metaPartition) ExtentsTruncate(req *ExtentsTruncateReq, p *Packet, rem
 	return
 }
 
+// Fallocate punches or zeroes the byte range of the file, the modes accepted are checked by
+// proto.IsValidFallocateMode.
+func (mp *metaPartition) Fallocate(req *proto.FallocateRequest, p *Packet, remoteAddr string) (err error) {
+	if !proto.IsHot(mp.volType) {
+		err = fmt.Errorf("only support hot vol")
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	start := time.Now()
+	if mp.IsEnableAuditLog() {
+		defer func() {
+			auditlog.LogInodeOp(remoteAddr, mp.GetVolName(), p.GetOpMsg(), req.GetFullPath(), err, time.Since(start).Milliseconds(), req.Inode, 0)
+		}()
+	}
+	if !proto.IsValidFallocateMode(req.Mode) || req.Length == 0 || req.Offset+req.Length < req.Offset {
+		err = fmt.Errorf("invalid fallocate, mode(%#x) offset(%v) length(%v)", req.Mode, req.Offset, req.Length)
+		p.PacketErrorWithBody(proto.OpArgMismatchErr, []byte(err.Error()))
+		return
+	}
+	item := mp.inodeTree.CopyGet(NewInode(req.Inode, 0))
+	if item == nil {
+		err = fmt.Errorf("inode[%v] is not exist", req.Inode)
+		p.PacketErrorWithBody(proto.OpNotExistErr, []byte(err.Error()))
+		return
+	}
+	i := item.(*Inode)
+	if !proto.IsStorageClassReplica(i.StorageClass) {
+		err = fmt.Errorf("inode %v storageClass(%v) do not support fallocate operation", req.Inode, i.StorageClass)
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	grow := req.Mode&proto.FallocKeepSize == 0 && req.Offset+req.Length > i.Size
+	if status := mp.isOverQuota(req.Inode, grow, false); status != 0 {
+		log.LogErrorf("Fallocate fail status [%v]", status)
+		err = errors.New("Fallocate is over quota")
+		p.PacketErrorWithBody(status, []byte(err.Error()))
+		return
+	}
+
+	req.SubmitTime = time.Now()
+	val, err := json.Marshal(req)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	resp, err := mp.submit(opFSMFallocate, val)
+	if err != nil {
+		log.LogErrorf("[Fallocate] mpId(%v) ino(%v) submit fsm return err: %v",
+			mp.config.PartitionId, req.Inode, err)
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
+	msg := resp.(*InodeResponse)
+	p.PacketErrorWithBody(msg.Status, nil)
+	return
+}
+
+// FsckDeleteExtents deletes the orphan extents found by fsck, which are referenced by no inode of
+// the volume. The extents are queued into the extent deletion of the partition on all replicas.
+func (mp *metaPartition) FsckDeleteExtents(req *proto.FsckDeleteExtentsRequest, p *Packet) (err error) {
+	if !proto.IsHot(mp.volType) {
+		err = fmt.Errorf("only support hot vol")
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	for _, ek := range req.Extents {
+		if ek.PartitionId == 0 || storage.IsTinyExtent(ek.ExtentId) {
+			err = fmt.Errorf("invalid orphan extent(%v)", ek)
+			p.PacketErrorWithBody(proto.OpArgMismatchErr, []byte(err.Error()))
+			return
+		}
+	}
+	if len(req.Extents) == 0 {
+		p.PacketOkReply()
+		return
+	}
+	val, err := json.Marshal(req.Extents)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	resp, err := mp.submit(opFSMFsckDeleteExtents, val)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
+	p.PacketErrorWithBody(resp.(uint8), nil)
+	return
+}
+
+// CloneInode clones the regular file into a new inode, or into the empty file of the request,
+// by copying the extent keys rather than the data.
+func (mp *metaPartition) CloneInode(req *proto.CloneInodeRequest, p *Packet, remoteAddr string) (err error) {
+	if !proto.IsHot(mp.volType) {
+		err = fmt.Errorf("only support hot vol")
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	// the extents are shared across the versions of the snapshots by their own refs
+	if mp.verSeq > 0 {
+		err = fmt.Errorf("clone is not supported on the volume with snapshots")
+		p.PacketErrorWithBody(proto.OpArgMismatchErr, []byte(err.Error()))
+		return
+	}
+	fsmReq := &fsmCloneInodeRequest{
+		Inode:    req.Inode,
+		DstInode: req.DstInode,
+		Uid:      req.Uid,
+		Gid:      req.Gid,
+		Time:     timeutil.GetCurrentTimeUnix(),
+		Marked:   true,
+	}
+	// the data partitions refuse to overwrite the extents in place once they are marked, so that
+	// the writers which don't know the extents are shared copy on write too
+	item := mp.inodeTree.Get(NewInode(req.Inode, 0))
+	if item == nil {
+		err = fmt.Errorf("inode[%v] is not exist", req.Inode)
+		p.PacketErrorWithBody(proto.OpNotExistErr, []byte(err.Error()))
+		return
+	}
+	eks, status := cloneableExtents(item.(*Inode))
+	if status != proto.OpOk {
+		err = fmt.Errorf("inode[%v] can not be cloned", req.Inode)
+		p.PacketErrorWithBody(status, []byte(err.Error()))
+		return
+	}
+	if fsmReq.Shared, err = mp.markSharedExtents(eks); err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
+	if fsmReq.DstInode == 0 {
+		if fsmReq.DstInode, err = mp.nextInodeID(); err != nil {
+			p.PacketErrorWithBody(proto.OpInodeFullErr, []byte(err.Error()))
+			return
+		}
+		fsmReq.Create = true
+	}
+	val, err := json.Marshal(fsmReq)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	r, err := mp.submit(opFSMCloneInode, val)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
+	resp := r.(*InodeResponse)
+	if resp.Status != proto.OpOk {
+		p.PacketErrorWithBody(resp.Status, nil)
+		return
+	}
+	reply := &proto.CloneInodeResponse{Info: &proto.InodeInfo{}}
+	replyInfo(reply.Info, resp.Msg, make(map[uint32]*proto.MetaQuotaInfo))
+	data, err := json.Marshal(reply)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(data)
+	return
+}
+
+// markSharedExtents marks the extents of the keys shared on the data partitions, and returns the
+// ids of the extents marked.
+func (mp *metaPartition) markSharedExtents(eks []proto.ExtentKey) (ids []proto.SharedExtentID, err error) {
+	extentsByPartition := make(map[uint64][]uint64)
+	seen := make(map[proto.SharedExtentID]struct{}, len(eks))
+	for i := range eks {
+		id := sharedExtentIDOf(&eks[i])
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		ids = append(ids, id)
+		extentsByPartition[id.PartitionId] = append(extentsByPartition[id.PartitionId], id.ExtentId)
+	}
+	for partitionID, extentIDs := range extentsByPartition {
+		if err = mp.doMarkSharedExtentsByPartition(partitionID, extentIDs); err != nil {
+			log.LogWarnf("[markSharedExtents] vol(%v) mp(%v) dp(%v) extents(%v) err(%v)",
+				mp.config.VolName, mp.config.PartitionId, partitionID, extentIDs, err)
+			return nil, err
+		}
+	}
+	return
+}
+
+func (mp *metaPartition) doMarkSharedExtentsByPartition(partitionID uint64, extentIDs []uint64) (err error) {
+	dp := mp.vol.GetPartition(partitionID)
+	if dp == nil || len(dp.Hosts) < 1 {
+		return errors.NewErrorf("unknown dataPartitionID=%d in vol", partitionID)
+	}
+	return mp.sendToDataPartitionLeader(dp, func() *Packet { return NewPacketToMarkSharedExtents(dp, extentIDs, 0) })
+}
+
+// sendToDataPartitionLeader sends the request applied by raft on the leader of the data partition,
+// which is usually the first host.
+func (mp *metaPartition) sendToDataPartitionLeader(dp *DataPartition, newPacket func() *Packet) (err error) {
+	for _, host := range dp.Hosts {
+		if err = mp.doSendToDataNode(dp, host, newPacket()); err == nil {
+			return
+		}
+	}
+	return
+}
+
+func (mp *metaPartition) doSendToDataNode(dp *DataPartition, host string, p *Packet) (err error) {
+	addr := util.ShiftAddrPort(host, smuxPortShift)
+	conn, err := smuxPool.GetConnect(addr)
+	defer func() {
+		smuxPool.PutConnect(conn, ForceClosedConnect)
+	}()
+	if err != nil {
+		return errors.NewErrorf("get conn from pool %s, extents partitionId=%d", err.Error(), dp.PartitionID)
+	}
+	if err = p.WriteToConn(conn); err != nil {
+		return errors.NewErrorf("write to dataNode %s, %s", p.GetUniqueLogId(), err.Error())
+	}
+	if err = p.ReadFromConnWithVer(conn, proto.ReadDeadlineTime); err != nil {
+		return errors.NewErrorf("read response from dataNode %s, %s", p.GetUniqueLogId(), err.Error())
+	}
+	if p.ResultCode != proto.OpOk {
+		return errors.NewErrorf("%s response: %s", p.GetUniqueLogId(), p.GetResultMsg())
+	}
+	return
+}
+
+// borrowedExtents returns the normal extents of the keys on the data partitions shared from the
+// source volume of the clone, by the data partition.
+func (mp *metaPartition) borrowedExtents(eks []proto.ExtentKey) (borrowed map[uint64][]uint64) {
+	seen := make(map[proto.SharedExtentID]struct{})
+	for i := range eks {
+		if storage.IsTinyExtent(eks[i].ExtentId) {
+			continue
+		}
+		dp := mp.vol.GetPartition(eks[i].PartitionId)
+		if dp == nil || dp.CloneSource == "" {
+			continue
+		}
+		id := sharedExtentIDOf(&eks[i])
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		if borrowed == nil {
+			borrowed = make(map[uint64][]uint64)
+		}
+		borrowed[id.PartitionId] = append(borrowed[id.PartitionId], id.ExtentId)
+	}
+	return
+}
+
+// holdBorrowedExtents holds the extents of the source volume by the partition before the keys
+// are appended, so that the deletions of them by the source are deferred until the partition
+// releases them, see releaseBorrowedExtents.
+func (mp *metaPartition) holdBorrowedExtents(borrowed map[uint64][]uint64) (err error) {
+	for partitionID, extentIDs := range borrowed {
+		dp := mp.vol.GetPartition(partitionID)
+		if dp == nil || len(dp.Hosts) < 1 {
+			return errors.NewErrorf("unknown dataPartitionID=%d in vol", partitionID)
+		}
+		ids := extentIDs
+		if err = mp.sendToDataPartitionLeader(dp, func() *Packet {
+			return NewPacketToMarkSharedExtents(dp, ids, mp.config.PartitionId)
+		}); err != nil {
+			log.LogWarnf("[holdBorrowedExtents] vol(%v) mp(%v) dp(%v) extents(%v) err(%v)",
+				mp.config.VolName, mp.config.PartitionId, partitionID, extentIDs, err)
+			return
+		}
+	}
+	return
+}
+
+// releaseBorrowedExtents releases the extents of the source volume dropped by the clone, which
+// the partition deletes on the data partitions shared from the source. The keys dropped are not
+// always the last ones of their extents, so the inodes are scanned and the extents still referred
+// to are kept held. The source deletes the extent once the clones holding it release it, if it
+// has dropped the extent as well. The ranges of the tiny extents are rewritten into the clone as
+// they are copied, and the keys replaced are left to the source.
+func (mp *metaPartition) releaseBorrowedExtents(dp *DataPartition, exts []*proto.DelExtentParam) (err error) {
+	candidates := make(map[uint64]struct{})
+	for _, ext := range exts {
+		if !storage.IsTinyExtent(ext.ExtentId) {
+			candidates[ext.ExtentId] = struct{}{}
+		}
+	}
+	if len(candidates) == 0 {
+		return
+	}
+	// the keys appended meanwhile are held before the scan, or seen by it
+	mp.cloneHoldMutex.Lock()
+	defer mp.cloneHoldMutex.Unlock()
+	mp.dropReferencedExtents(dp.PartitionID, candidates)
+	if len(candidates) == 0 {
+		return
+	}
+	extentIDs := make([]uint64, 0, len(candidates))
+	for id := range candidates {
+		extentIDs = append(extentIDs, id)
+	}
+	if err = mp.sendToDataPartitionLeader(dp, func() *Packet {
+		return NewPacketToReleaseSharedExtents(dp, extentIDs, mp.config.PartitionId)
+	}); err != nil {
+		log.LogWarnf("[releaseBorrowedExtents] vol(%v) mp(%v) dp(%v) shared from vol(%v) extents(%v) err(%v)",
+			mp.config.VolName, mp.config.PartitionId, dp.PartitionID, dp.CloneSource, extentIDs, err)
+		return
+	}
+	log.LogInfof("[releaseBorrowedExtents] vol(%v) mp(%v) dp(%v) shared from vol(%v) released extents(%v)",
+		mp.config.VolName, mp.config.PartitionId, dp.PartitionID, dp.CloneSource, extentIDs)
+	return
+}
+
+// dropReferencedExtents removes the extents of the data partition still referred to by the
+// inodes, in any version, from the candidates. The inodes marked deleted are skipped, since their
+// extents are being freed.
+func (mp *metaPartition) dropReferencedExtents(partitionID uint64, candidates map[uint64]struct{}) {
+	tree := mp.inodeTree.GetTree()
+	defer tree.Release()
+	visit := func(_ int, ek proto.ExtentKey) bool {
+		if ek.PartitionId == partitionID {
+			delete(candidates, ek.ExtentId)
+		}
+		return len(candidates) > 0
+	}
+	tree.Ascend(func(item BtreeItem) bool {
+		ino := item.(*Inode)
+		if proto.IsDir(ino.Type) || ino.ShouldDelete() {
+			return true
+		}
+		ino.RLock()
+		for layer := 0; layer < ino.getLayerLen()+1 && len(candidates) > 0; layer++ {
+			dIno := ino
+			if layer > 0 {
+				dIno = ino.multiSnap.multiVersions[layer-1]
+			}
+			if extents, ok := dIno.HybridCloudExtents.sortedEks.(*SortedExtents); ok {
+				extents.Range(visit)
+			}
+		}
+		ino.RUnlock()
+		return len(candidates) > 0
+	})
+}
+
+// WriteInline writes the data of the tiny file into the inode rather than extents. The request
+// is rejected with proto.OpArgMismatchErr if the file cannot be kept inline, and the client
+// writes the data into extents instead.
+func (mp *metaPartition) WriteInline(req *proto.WriteInlineRequest, p *Packet, remoteAddr string) (err error) {
+	if !proto.IsHot(mp.volType) {
+		err = fmt.Errorf("only support hot vol")
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	start := time.Now()
+	if mp.IsEnableAuditLog() {
+		defer func() {
+			auditlog.LogInodeOp(remoteAddr, mp.GetVolName(), p.GetOpMsg(), req.GetFullPath(), err, time.Since(start).Milliseconds(), req.Inode, 0)
+		}()
+	}
+	// the inline data is not kept by the versions of the snapshots
+	if mp.verSeq > 0 || req.Offset+uint64(len(req.Data)) > proto.MaxInlineDataSize {
+		err = fmt.Errorf("inline data is not allowed, offset(%v) size(%v) verSeq(%v)", req.Offset, len(req.Data), mp.verSeq)
+		p.PacketErrorWithBody(proto.OpArgMismatchErr, []byte(err.Error()))
+		return
+	}
+	item := mp.inodeTree.Get(NewInode(req.Inode, 0))
+	if item == nil {
+		err = fmt.Errorf("inode[%v] is not exist", req.Inode)
+		p.PacketErrorWithBody(proto.OpNotExistErr, []byte(err.Error()))
+		return
+	}
+	grow := req.Offset+uint64(len(req.Data)) > item.(*Inode).Size
+	if status := mp.isOverQuota(req.Inode, grow, false); status != 0 {
+		err = errors.New("WriteInline is over quota")
+		p.PacketErrorWithBody(status, []byte(err.Error()))
+		return
+	}
+	if grow {
+		mp.uidManager.acLock.RLock()
+		limited := mp.uidManager.isLimited(item.(*Inode), false)
+		mp.uidManager.acLock.RUnlock()
+		if limited {
+			err = errors.New("WriteInline UidSpace is over quota")
+			p.PacketErrorWithBody(proto.OpNoSpaceErr, []byte(err.Error()))
+			return
+		}
+	}
+
+	val, err := json.Marshal(&fsmWriteInlineRequest{
+		Inode:  req.Inode,
+		Offset: req.Offset,
+		Data:   req.Data,
+		Time:   timeutil.GetCurrentTimeUnix(),
+	})
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	resp, err := mp.submit(opFSMWriteInline, val)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
+	p.PacketErrorWithBody(resp.(*InodeResponse).Status, nil)
+	return
+}
+
 func (mp *metaPartition) BatchExtentAppend(req *proto.AppendExtentKeysRequest, p *Packet) (err error) {
 	if !proto.IsHot(mp.volType) {
 		err = fmt.Errorf("only support hot vol")
@@ -671,6 +1082,16 @@ func (mp *metaPartition) BatchExtentAppend(req *proto.AppendExtentKeysRequest, p
 		return
 	}
 
+	// the keys copied into the clone refer to the extents of the source volume
+	if borrowed := mp.borrowedExtents(req.Extents); len(borrowed) > 0 {
+		mp.cloneHoldMutex.Lock()
+		defer mp.cloneHoldMutex.Unlock()
+		if err = mp.holdBorrowedExtents(borrowed); err != nil {
+			p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+			return
+		}
+	}
+
 	extents := req.Extents
 	ino.HybridCloudExtents.sortedEks = NewSortedExtents()
 	for _, extent := range extents {
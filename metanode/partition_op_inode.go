@@ -867,7 +867,7 @@ func (mp *metaPartition) SetAttr(req *SetattrRequest, reqData []byte, p *Packet)
 	return
 }
 
-// GetInodeTree returns the inode tree.
+// GetInodeTree returns the inode tree, the caller releases it by Release after use.
 func (mp *metaPartition) GetInodeTree() *BTree {
 	return mp.inodeTree.GetTree()
 }
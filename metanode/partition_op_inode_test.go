@@ -75,3 +75,36 @@ func TestInodeGetPerf(t *testing.T) {
 		t.Logf("TestInodeGetPerf: cnt %d, cost %dus", testNum, time.Since(start).Microseconds())
 	}
 }
+
+func TestWriteInline(t *testing.T) {
+	mp := NewMetaPartitionForTest()
+	mp.uidManager = NewUidMgr(mp.config.VolName, mp.config.PartitionId)
+	file := newCloneTestFile(mp, 10, nil)
+
+	resp := mp.fsmWriteInline(&fsmWriteInlineRequest{Inode: file.Inode, Data: []byte("tiny file")})
+	require.Equal(t, proto.OpOk, resp.Status)
+	resp = mp.fsmWriteInline(&fsmWriteInlineRequest{Inode: file.Inode, Offset: 5, Data: []byte("data")})
+	require.Equal(t, proto.OpOk, resp.Status)
+	require.Equal(t, []byte("tiny data"), file.InlineData)
+	require.Equal(t, uint64(9), file.Size)
+	resp = mp.fsmWriteInline(&fsmWriteInlineRequest{Inode: file.Inode, Offset: proto.MaxInlineDataSize, Data: []byte("x")})
+	require.Equal(t, proto.OpArgMismatchErr, resp.Status)
+
+	// the clone keeps its own copy of the inline data
+	resp = mp.fsmCloneInode(&fsmCloneInodeRequest{Inode: file.Inode, DstInode: 11, Create: true})
+	require.Equal(t, proto.OpOk, resp.Status)
+	require.Equal(t, []byte("tiny data"), resp.Msg.InlineData)
+
+	mp.fsmExtentsTruncate(&Inode{Inode: file.Inode, Size: 4})
+	require.Equal(t, []byte("tiny"), file.InlineData)
+	require.Equal(t, uint64(4), file.Size)
+
+	// the inline data is dropped once the file is stored in extents
+	ino := NewInode(file.Inode, FileModeType)
+	ino.HybridCloudExtents.sortedEks = NewSortedExtentsFromEks([]proto.ExtentKey{{PartitionId: 1, ExtentId: 1025, Size: 4096}})
+	require.Equal(t, proto.OpOk, mp.fsmAppendExtents(ino))
+	require.Nil(t, file.InlineData)
+	require.Equal(t, uint64(4096), file.Size)
+	resp = mp.fsmWriteInline(&fsmWriteInlineRequest{Inode: file.Inode, Data: []byte("tiny")})
+	require.Equal(t, proto.OpArgMismatchErr, resp.Status)
+}
@@ -0,0 +1,138 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// appliedWaiter is woken once the partition applies the raft log of the index.
+type appliedWaiter struct {
+	index uint64
+	done  chan struct{}
+}
+
+// appliedWaiters holds the reads waiting for the partition to apply their read index, which are
+// woken by the apply of the raft log instead of polling the applied index.
+type appliedWaiters struct {
+	sync.Mutex
+	count   int32 // the number of the waiters, read without the lock by the apply
+	waiters []*appliedWaiter
+}
+
+// add registers the waiter of the index. The caller checks the applied index after adding, so the
+// index applied meanwhile is never missed.
+func (w *appliedWaiters) add(index uint64) *appliedWaiter {
+	waiter := &appliedWaiter{index: index, done: make(chan struct{})}
+	w.Lock()
+	w.waiters = append(w.waiters, waiter)
+	atomic.StoreInt32(&w.count, int32(len(w.waiters)))
+	w.Unlock()
+	return waiter
+}
+
+func (w *appliedWaiters) remove(waiter *appliedWaiter) {
+	w.Lock()
+	defer w.Unlock()
+	for i, other := range w.waiters {
+		if other == waiter {
+			w.waiters = append(w.waiters[:i], w.waiters[i+1:]...)
+			break
+		}
+	}
+	atomic.StoreInt32(&w.count, int32(len(w.waiters)))
+}
+
+// notify wakes the waiters of the indexes applied.
+func (w *appliedWaiters) notify(applied uint64) {
+	if atomic.LoadInt32(&w.count) == 0 {
+		return
+	}
+	w.Lock()
+	defer w.Unlock()
+	waiters := w.waiters[:0]
+	for _, waiter := range w.waiters {
+		if waiter.index <= applied {
+			close(waiter.done)
+			continue
+		}
+		waiters = append(waiters, waiter)
+	}
+	for i := len(waiters); i < len(w.waiters); i++ {
+		w.waiters[i] = nil
+	}
+	w.waiters = waiters
+	atomic.StoreInt32(&w.count, int32(len(w.waiters)))
+}
+
+// readIndexRound is one round trip to the leader for the read index, shared by the reads arriving
+// before it starts.
+type readIndexRound struct {
+	fetch func() (uint64, error)
+	reads int // the number of the reads sharing the round
+	done  chan struct{}
+	index uint64
+	err   error
+}
+
+// readIndexBatcher batches the concurrent reads of the partition asking for the read index into
+// one round trip to the leader. The read arriving while a round is in flight joins the next round
+// rather than the one in flight, whose index may not cover the writes acknowledged before the read.
+type readIndexBatcher struct {
+	sync.Mutex
+	running bool
+	next    *readIndexRound
+}
+
+// do returns the read index fetched by the round the caller joins. The fetch of the caller opening
+// the round is used.
+func (b *readIndexBatcher) do(fetch func() (uint64, error)) (uint64, error) {
+	b.Lock()
+	round := b.next
+	if round == nil {
+		round = &readIndexRound{fetch: fetch, done: make(chan struct{})}
+		b.next = round
+	}
+	round.reads++
+	if !b.running {
+		b.running = true
+		go b.run()
+	}
+	b.Unlock()
+
+	<-round.done
+	return round.index, round.err
+}
+
+func (b *readIndexBatcher) run() {
+	for {
+		b.Lock()
+		round := b.next
+		if round == nil {
+			b.running = false
+			b.Unlock()
+			return
+		}
+		b.next = nil
+		b.Unlock()
+
+		round.index, round.err = round.fetch()
+		log.LogDebugf("readIndexBatcher: reads(%v) index(%v) err(%v)", round.reads, round.index, round.err)
+		close(round.done)
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/depends/tiglabs/raft"
+	raftstoremock "github.com/cubefs/cubefs/util/mocktest/raftstore"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadIndex(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mp := &metaPartition{}
+	_, err := mp.ReadIndex()
+	require.Equal(t, ErrNotALeader, err)
+
+	raftPartition := raftstoremock.NewMockPartition(mockCtrl)
+	mp.raftPartition = raftPartition
+	atomic.StoreUint64(&mp.applyID, 10)
+	raftPartition.EXPECT().ReadIndex().Return(nil)
+	index, err := mp.ReadIndex()
+	require.NoError(t, err)
+	require.Equal(t, uint64(10), index)
+
+	raftPartition.EXPECT().ReadIndex().Return(raft.ErrNotLeader)
+	_, err = mp.ReadIndex()
+	require.Equal(t, raft.ErrNotLeader, err)
+}
+
+func TestWaitApplied(t *testing.T) {
+	mp := &metaPartition{}
+	atomic.StoreUint64(&mp.applyID, 5)
+	require.NoError(t, mp.WaitApplied(5, time.Millisecond))
+	require.Equal(t, ErrReadIndexTimeout, mp.WaitApplied(6, 10*time.Millisecond))
+	require.Empty(t, mp.appliedWaiters.waiters)
+
+	// the waiters are woken by the apply of their index
+	var wg sync.WaitGroup
+	for _, index := range []uint64{6, 7, 8} {
+		wg.Add(1)
+		go func(index uint64) {
+			defer wg.Done()
+			require.NoError(t, mp.WaitApplied(index, time.Second))
+			require.GreaterOrEqual(t, mp.GetAppliedID(), index)
+		}(index)
+	}
+	require.Eventually(t, func() bool {
+		mp.appliedWaiters.Lock()
+		defer mp.appliedWaiters.Unlock()
+		return len(mp.appliedWaiters.waiters) == 3
+	}, time.Second, time.Millisecond)
+	mp.uploadApplyID(7)
+	require.Eventually(t, func() bool {
+		mp.appliedWaiters.Lock()
+		defer mp.appliedWaiters.Unlock()
+		return len(mp.appliedWaiters.waiters) == 1
+	}, time.Second, time.Millisecond)
+	mp.uploadApplyID(8)
+	wg.Wait()
+	require.Empty(t, mp.appliedWaiters.waiters)
+}
+
+func TestBatchReadIndex(t *testing.T) {
+	mp := &metaPartition{}
+	var fetches int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	fetch := func() (uint64, error) {
+		n := atomic.AddInt32(&fetches, 1)
+		if n == 1 {
+			close(started)
+			<-release
+		}
+		return uint64(n * 10), nil
+	}
+
+	var wg sync.WaitGroup
+	read := func(expected uint64) {
+		defer wg.Done()
+		index, err := mp.BatchReadIndex(fetch)
+		require.NoError(t, err)
+		require.Equal(t, expected, index)
+	}
+	wg.Add(1)
+	go read(10)
+	<-started
+	// the reads arriving while the round is in flight share the next round
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go read(20)
+	}
+	require.Eventually(t, func() bool {
+		mp.readIndexBatch.Lock()
+		defer mp.readIndexBatch.Unlock()
+		return mp.readIndexBatch.next != nil && mp.readIndexBatch.next.reads == 10
+	}, time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+	require.Equal(t, int32(2), atomic.LoadInt32(&fetches))
+
+	// the error of the round is returned to all its reads
+	_, err := mp.BatchReadIndex(func() (uint64, error) { return 0, ErrNotALeader })
+	require.Equal(t, ErrNotALeader, err)
+}
@@ -29,6 +29,7 @@ import (
 
 	"github.com/cubefs/cubefs/proto"
 	"github.com/cubefs/cubefs/util/errors"
+	"github.com/cubefs/cubefs/util/fileutil"
 	"github.com/cubefs/cubefs/util/log"
 	mmap "github.com/edsrzf/mmap-go"
 )
@@ -51,6 +52,8 @@ const (
 	metadataFileTmp         = ".meta"
 	uniqIDFile              = "uniqID"
 	uniqCheckerFile         = "uniqChecker"
+	sharedExtentsFile       = "sharedExtents"
+	dirStatsFile            = "dirStats"
 	verdataFile             = "multiVer"
 	StaleMetadataSuffix     = ".old"
 	StaleMetadataTimeFormat = "20060102150405.000000000"
@@ -138,6 +141,7 @@ func (mp *metaPartition) loadMetadata() (err error) {
 	mp.config.Start = mConf.Start
 	mp.config.End = mConf.End
 	mp.config.Peers = mConf.Peers
+	mp.config.StoreMode = mConf.StoreMode
 	mp.config.Cursor = mp.config.Start
 	mp.config.UniqId = 0
 
@@ -227,8 +231,10 @@ func (mp *metaPartition) loadInode(rootDir string, crc uint32) (err error) {
 		}
 
 		mp.size += ino.Size
+		mp.logicalSize += ino.GetSpaceSize()
 
-		mp.fsmCreateInode(ino)
+		// the usage of the owners is accounted by acucumUidSizeByLoad
+		mp.inodeTree.ReplaceOrInsert(ino, false)
 		mp.checkAndInsertFreeList(ino)
 		if mp.config.Cursor < ino.Inode {
 			mp.config.Cursor = ino.Inode
@@ -1144,6 +1150,7 @@ func (mp *metaPartition) storeInode(rootDir string,
 	}()
 
 	size := uint64(0)
+	logicalSize := uint64(0)
 
 	var data []byte
 	lenBuf := make([]byte, 4)
@@ -1159,6 +1166,7 @@ func (mp *metaPartition) storeInode(rootDir string,
 		}
 
 		size += ino.Size
+		logicalSize += ino.GetSpaceSize()
 		mp.fileStats(ino)
 
 		// set length
@@ -1182,6 +1190,7 @@ func (mp *metaPartition) storeInode(rootDir string,
 	mp.acucumRebuildFin(sm.uidRebuild)
 	crc = sign.Sum32()
 	mp.size = size
+	mp.logicalSize = logicalSize
 
 	log.LogInfof("storeInode: store complete: partitoinID(%v) volume(%v) numInodes(%v) crc(%v), size (%d)",
 		mp.config.PartitionId, mp.config.VolName, sm.inodeTree.Len(), crc, size)
@@ -1395,6 +1404,26 @@ func (mp *metaPartition) doStoreUniqID(rootDir string, uniqId uint64) (err error
 	return
 }
 
+func (mp *metaPartition) loadSharedExtents(rootDir string, crc uint32) (err error) {
+	filename := path.Join(rootDir, sharedExtentsFile)
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		log.LogErrorf("loadSharedExtents read file %s err(%s)", filename, err)
+		err = errors.NewErrorf("[loadSharedExtents] OpenFile: %v", err.Error())
+		return
+	}
+	if res := crc32.ChecksumIEEE(data); res != crc {
+		log.LogErrorf("[loadSharedExtents]: check crc mismatch, expected[%d], actual[%d]", crc, res)
+		return ErrSnapshotCrcMismatch
+	}
+	if err = mp.sharedExtents.UnMarshal(data); err != nil {
+		err = errors.NewErrorf("[loadSharedExtents] Unmarshal: %v", err.Error())
+		return
+	}
+	log.LogInfof("loadSharedExtents partition(%v) complete, shared extents(%v)", mp.config.PartitionId, mp.sharedExtents.Len())
+	return
+}
+
 func (mp *metaPartition) storeUniqID(rootDir string, sm *storeMsg) (err error) {
 	return mp.doStoreUniqID(rootDir, sm.uniqId)
 }
@@ -1426,3 +1455,62 @@ func (mp *metaPartition) storeUniqChecker(rootDir string, sm *storeMsg) (crc uin
 		mp.config.UniqId, mp.config.VolName, crc)
 	return
 }
+
+func (mp *metaPartition) storeSharedExtents(rootDir string, sm *storeMsg) (crc uint32, err error) {
+	se := sm.sharedExtents
+	if se == nil {
+		se = newSharedExtents()
+	}
+	var data []byte
+	if data, crc, err = se.Marshal(); err != nil {
+		return
+	}
+	if err = fileutil.WriteFileWithSync(path.Join(rootDir, sharedExtentsFile), data, 0o755); err != nil {
+		return
+	}
+	log.LogInfof("storeSharedExtents: store complete: PartitionID(%v) volume(%v) crc(%v)",
+		mp.config.PartitionId, mp.config.VolName, crc)
+	return
+}
+
+func (mp *metaPartition) loadDirStats(rootDir string, crc uint32) (err error) {
+	filename := path.Join(rootDir, dirStatsFile)
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		log.LogErrorf("loadDirStats read file %s err(%s)", filename, err)
+		err = errors.NewErrorf("[loadDirStats] OpenFile: %v", err.Error())
+		return
+	}
+	if res := crc32.ChecksumIEEE(data); res != crc {
+		log.LogErrorf("[loadDirStats]: check crc mismatch, expected[%d], actual[%d]", crc, res)
+		return ErrSnapshotCrcMismatch
+	}
+	if err = mp.dirStats.UnMarshal(data, mp.dirStatTree); err != nil {
+		err = errors.NewErrorf("[loadDirStats] Unmarshal: %v", err.Error())
+		return
+	}
+	log.LogInfof("loadDirStats partition(%v) complete, entries(%v)", mp.config.PartitionId, mp.dirStatTree.Len())
+	return
+}
+
+func (mp *metaPartition) storeDirStats(rootDir string, sm *storeMsg) (crc uint32, err error) {
+	ds := sm.dirStats
+	if ds == nil {
+		ds = newDirStats()
+	}
+	// the entries of the tree backed by the kv store are committed with the other trees
+	tree := sm.dirStatTree
+	if mp.kvStore != nil {
+		tree = nil
+	}
+	var data []byte
+	if data, crc, err = ds.Marshal(tree); err != nil {
+		return
+	}
+	if err = fileutil.WriteFileWithSync(path.Join(rootDir, dirStatsFile), data, 0o755); err != nil {
+		return
+	}
+	log.LogInfof("storeDirStats: store complete: PartitionID(%v) volume(%v) crc(%v)",
+		mp.config.PartitionId, mp.config.VolName, crc)
+	return
+}
@@ -0,0 +1,356 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/errors"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// The partitions not in proto.StoreModeMem keep the inode, dentry, extend, multipart and dir stat trees in
+// the kv store of the metanode. At each store tick only the changes of the trees since the last
+// tick are committed, along with the other snapshot files, in one batch of the kv store. The
+// snapshot files are restored from the kv store before loading, so the trees and the apply id in
+// the snapshot always match.
+//
+// The raft snapshot sends the key-values of the trees instead of the items, which the follower
+// writes into a new generation of the trees in the kv store as they arrive. The new generation is
+// switched to when committed with the snapshot files, the current one is kept until then, so the
+// snapshot failed to apply leaves the partition as it was.
+
+// openStore replaces the trees by the ones backed by the kv store for the store mode.
+func (mp *metaPartition) openStore() (err error) {
+	if mp.config.StoreMode == proto.StoreModeMem {
+		return
+	}
+	if mp.kvStore == nil {
+		if mp.kvStore, err = mp.manager.getKVStore(mp.config.StoreMode); err != nil {
+			return
+		}
+	}
+	pid := mp.config.PartitionId
+	var gen []byte
+	if gen, err = mp.kvStore.Get(nil, kvGenerationKey(pid)); err != nil {
+		return errors.NewErrorf("[openStore] partition(%v): %v", pid, err)
+	}
+	mp.kvGen = 0
+	if len(gen) == 8 {
+		mp.kvGen = binary.BigEndian.Uint64(gen)
+	}
+	if err = mp.dropUncommittedGeneration(); err != nil {
+		return errors.NewErrorf("[openStore] partition(%v): %v", pid, err)
+	}
+	trees := []**BTree{&mp.inodeTree, &mp.dentryTree, &mp.extendTree, &mp.multipartTree, &mp.dirStatTree}
+	for i, codec := range kvTreeCodecs {
+		if *trees[i], err = newStoreBtree(mp.kvStore, pid, mp.kvGen, codec); err != nil {
+			return errors.NewErrorf("[openStore] partition(%v) table(%v): %v", pid, codec.table, err)
+		}
+	}
+	log.LogInfof("openStore: partition(%v) store mode(%v) generation(%v) inodes(%v) dentries(%v)",
+		pid, mp.config.StoreMode, mp.kvGen, mp.inodeTree.Len(), mp.dentryTree.Len())
+	return
+}
+
+// dropUncommittedGeneration drops the trees written by the raft snapshot which was not committed
+// before the metanode stopped.
+func (mp *metaPartition) dropUncommittedGeneration() (err error) {
+	pid := mp.config.PartitionId
+	found := false
+	for _, table := range kvTreeTables {
+		start := kvPrefixEnd(kvTreePrefix(pid, table, mp.kvGen))
+		if err = mp.kvStore.Range(nil, start, kvPrefixEnd(kvTablePrefix(pid, table)), func(key, value []byte) bool {
+			found = true
+			return false
+		}); err != nil || found {
+			break
+		}
+	}
+	if err != nil || !found {
+		return
+	}
+	batch := &KVBatch{}
+	deleteOtherGenerations(batch, pid, mp.kvGen)
+	log.LogWarnf("dropUncommittedGeneration: partition(%v) generation(%v)", pid, mp.kvGen)
+	return mp.kvStore.Write(batch, true)
+}
+
+// newApplyingTree returns the empty tree to apply the raft snapshot into. The tree backed by the
+// kv store drops all the items in the store once the snapshot is committed, before that all the
+// items of the snapshot are kept in memory.
+func (mp *metaPartition) newApplyingTree(codec *treeCodec) *BTree {
+	if mp.kvStore == nil {
+		return NewBtree()
+	}
+	b := NewBtree()
+	b.store = newTreeStore(mp.kvStore, mp.config.PartitionId, mp.inodeTree.store.gen, codec)
+	b.reset()
+	return b
+}
+
+var kvTreeCodecs = []*treeCodec{inodeCodec, dentryCodec, extendCodec, multipartCodec, dirStatCodec}
+
+const kvIngestBatchSize = 4 * 1024 * 1024
+
+// kvIngest writes the key-values of the trees in the raft snapshot into a new generation of the
+// trees in the kv store.
+type kvIngest struct {
+	mp     *metaPartition
+	gen    uint64
+	batch  *KVBatch
+	size   int
+	counts map[byte]uint64
+}
+
+// newKVIngest returns the ingest into the generation next to the trees of the partition, and
+// drops what a former snapshot failed to apply left in it.
+func (mp *metaPartition) newKVIngest() (ingest *kvIngest, err error) {
+	if mp.kvStore == nil {
+		return nil, fmt.Errorf("partition(%v) store mode(%v) has no kv store", mp.config.PartitionId, mp.config.StoreMode)
+	}
+	pid := mp.config.PartitionId
+	ingest = &kvIngest{
+		mp:     mp,
+		gen:    mp.inodeTree.store.gen + 1,
+		batch:  &KVBatch{},
+		counts: make(map[byte]uint64),
+	}
+	for _, table := range kvTreeTables {
+		prefix := kvTreePrefix(pid, table, ingest.gen)
+		ingest.batch.DeleteRange(prefix, kvPrefixEnd(prefix))
+		ingest.batch.Delete(kvCountKey(pid, table, ingest.gen))
+	}
+	if err = ingest.flush(false); err != nil {
+		return nil, err
+	}
+	log.LogInfof("newKVIngest: partition(%v) generation(%v)", pid, ingest.gen)
+	return
+}
+
+// put adds the key-value of the tree of the table, the key is the one in the tree.
+func (ingest *kvIngest) put(table byte, key, value []byte) (err error) {
+	if table < kvTableInode || table > kvTableDirStat {
+		return fmt.Errorf("unknown table(%v) of the kv snapshot", table)
+	}
+	ingest.batch.Put(kvKey(kvTreePrefix(ingest.mp.config.PartitionId, table, ingest.gen), key), value)
+	ingest.counts[table]++
+	ingest.size += len(key) + len(value)
+	if ingest.size >= kvIngestBatchSize {
+		err = ingest.flush(false)
+	}
+	return
+}
+
+func (ingest *kvIngest) flush(sync bool) (err error) {
+	if err = ingest.mp.kvStore.Write(ingest.batch, sync); err != nil {
+		return errors.NewErrorf("[kvIngest] partition(%v) generation(%v): %v", ingest.mp.config.PartitionId, ingest.gen, err)
+	}
+	ingest.batch = &KVBatch{}
+	ingest.size = 0
+	return
+}
+
+// finish writes the rest of the key-values with the item counts, and opens the trees of the
+// generation, which are committed with the snapshot files by the next store.
+func (ingest *kvIngest) finish() (trees []*BTree, err error) {
+	pid := ingest.mp.config.PartitionId
+	for _, table := range kvTreeTables {
+		ingest.batch.Put(kvCountKey(pid, table, ingest.gen), uint64Key(ingest.counts[table]))
+	}
+	if err = ingest.flush(true); err != nil {
+		return
+	}
+	trees = make([]*BTree, len(kvTreeCodecs))
+	for i, codec := range kvTreeCodecs {
+		if trees[i], err = newStoreBtree(ingest.mp.kvStore, pid, ingest.gen, codec); err != nil {
+			return
+		}
+	}
+	log.LogInfof("kvIngest: partition(%v) generation(%v) inodes(%v) dentries(%v) extends(%v) multiparts(%v)",
+		pid, ingest.gen, ingest.counts[kvTableInode], ingest.counts[kvTableDentry],
+		ingest.counts[kvTableExtend], ingest.counts[kvTableMultipart])
+	return
+}
+
+// dropStore deletes all the data of the partition in the kv store.
+func (mp *metaPartition) dropStore() (err error) {
+	prefix := kvPartitionPrefix(mp.config.PartitionId)
+	batch := &KVBatch{}
+	batch.DeleteRange(prefix, kvPrefixEnd(prefix))
+	if err = mp.kvStore.Write(batch, true); err != nil {
+		log.LogErrorf("dropStore: partition(%v) err(%v)", mp.config.PartitionId, err)
+	}
+	return
+}
+
+func storeNothing(rootDir string, sm *storeMsg) (crc uint32, err error) {
+	return
+}
+
+// storeInodeStats rebuilds the statistics as storeInode does, the size of the partition is left
+// to updateSize.
+func (mp *metaPartition) storeInodeStats(rootDir string, sm *storeMsg) (crc uint32, err error) {
+	if sm.uidRebuild || mp.manager.fileStatsEnable {
+		sm.inodeTree.Ascend(func(i BtreeItem) bool {
+			ino := i.(*Inode)
+			if sm.uidRebuild {
+				mp.acucumUidSizeByStore(ino)
+			}
+			mp.fileStats(ino)
+			return true
+		})
+	}
+	mp.acucumRebuildFin(sm.uidRebuild)
+	return
+}
+
+// storeExtendStats rebuilds the quota statistics as storeExtend does.
+func (mp *metaPartition) storeExtendStats(rootDir string, sm *storeMsg) (crc uint32, err error) {
+	if sm.quotaRebuild {
+		sIno := NewSimpleInode(0)
+		sm.extendTree.Ascend(func(i BtreeItem) bool {
+			e := i.(*Extend)
+			sIno.Inode = e.GetInode()
+			mp.statisticExtendByStore(e, sIno)
+			return true
+		})
+	}
+	mp.mqMgr.statisticRebuildFin(sm.quotaRebuild)
+	return
+}
+
+// commitStore commits the sealed changes of the trees and the snapshot files in tmpDir to the
+// kv store in one batch.
+func (mp *metaPartition) commitStore(tmpDir string, sm *storeMsg) (err error) {
+	pid := mp.config.PartitionId
+	batch := &KVBatch{}
+	trees := []*BTree{sm.inodeTree, sm.dentryTree, sm.extendTree, sm.multipartTree, sm.dirStatTree}
+	seqs := make([]uint64, len(trees))
+	for i, tree := range trees {
+		if tree.store != nil {
+			seqs[i] = tree.commitTo(batch)
+		}
+	}
+	changes := len(batch.Ops)
+
+	// switch to the generation written by the raft snapshot
+	gen := mp.kvGen
+	if sm.inodeTree.store != nil {
+		gen = sm.inodeTree.store.gen
+	}
+	batch.Put(kvGenerationKey(pid), uint64Key(gen))
+	if gen != mp.kvGen {
+		deleteOtherGenerations(batch, pid, gen)
+	}
+
+	filePrefix := kvTablePrefix(pid, kvTableFile)
+	batch.DeleteRange(filePrefix, kvPrefixEnd(filePrefix))
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		var data []byte
+		if data, err = os.ReadFile(path.Join(tmpDir, entry.Name())); err != nil {
+			return
+		}
+		batch.Put(kvKey(filePrefix, []byte(entry.Name())), data)
+	}
+	if err = mp.kvStore.Write(batch, true); err != nil {
+		return errors.NewErrorf("[commitStore] partition(%v) apply(%v): %v", pid, sm.applyIndex, err)
+	}
+	if gen != mp.kvGen {
+		log.LogInfof("commitStore: partition(%v) apply(%v) generation(%v) replaces(%v)", pid, sm.applyIndex, gen, mp.kvGen)
+		mp.kvGen = gen
+	}
+	for i, tree := range trees {
+		if tree.store != nil {
+			tree.dropCommitted(seqs[i])
+		}
+	}
+	log.LogInfof("commitStore: partition(%v) apply(%v) changes(%v) files(%v)", pid, sm.applyIndex, changes, len(entries))
+	return
+}
+
+// restoreSnapshotFiles restores the snapshot files committed with the trees, which may be newer
+// than the ones in the snapshot directory if the metanode crashed before renaming it.
+func (mp *metaPartition) restoreSnapshotFiles(snapshotPath string) (err error) {
+	filePrefix := kvTablePrefix(mp.config.PartitionId, kvTableFile)
+	files := make(map[string][]byte)
+	err = mp.kvStore.Range(nil, filePrefix, kvPrefixEnd(filePrefix), func(key, value []byte) bool {
+		files[string(key[len(filePrefix):])] = value
+		return true
+	})
+	if err != nil || len(files) == 0 {
+		return
+	}
+	if err = os.RemoveAll(snapshotPath); err != nil {
+		return
+	}
+	if err = os.MkdirAll(snapshotPath, 0o775); err != nil {
+		return
+	}
+	for name, data := range files {
+		if err = os.WriteFile(path.Join(snapshotPath, name), data, 0o775); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// loadInodeStats rebuilds the states derived from the inodes as loadInode does.
+func (mp *metaPartition) loadInodeStats(rootDir string, crc uint32) (err error) {
+	var numInodes uint64
+	inodeTree := mp.inodeTree.GetTree()
+	defer inodeTree.Release()
+	inodeTree.Ascend(func(i BtreeItem) bool {
+		ino := i.(*Inode)
+		mp.acucumUidSizeByLoad(ino)
+		mp.size += ino.Size
+		mp.logicalSize += ino.GetSpaceSize()
+		mp.checkAndInsertFreeList(ino)
+		if ino.IsTempFile() {
+			// keep the access time set for the temp file
+			mp.inodeTree.ReplaceOrInsert(ino, true)
+		}
+		if mp.config.Cursor < ino.Inode {
+			mp.config.Cursor = ino.Inode
+		}
+		numInodes++
+		return true
+	})
+	log.LogInfof("loadInodeStats: load complete: partitonID(%v) volume(%v) numInodes(%v)",
+		mp.config.PartitionId, mp.config.VolName, numInodes)
+	return
+}
+
+// loadExtendStats rebuilds the quota statistics as loadExtend does.
+func (mp *metaPartition) loadExtendStats(rootDir string, crc uint32) (err error) {
+	ino := NewSimpleInode(0)
+	extendTree := mp.extendTree.GetTree()
+	defer extendTree.Release()
+	extendTree.Ascend(func(i BtreeItem) bool {
+		mp.statisticExtendByLoad(i.(*Extend), ino)
+		return true
+	})
+	log.LogInfof("loadExtendStats: load complete: partitionID(%v) volume(%v) numExtends(%v)",
+		mp.config.PartitionId, mp.config.VolName, mp.extendTree.Len())
+	return
+}
@@ -34,6 +34,7 @@ type storeMsg struct {
 	dentryTree     *BTree
 	extendTree     *BTree
 	multipartTree  *BTree
+	dirStatTree    *BTree
 	txTree         *BTree
 	txRbInodeTree  *BTree
 	txRbDentryTree *BTree
@@ -41,9 +42,19 @@ type storeMsg struct {
 	uidRebuild     bool
 	uniqId         uint64
 	uniqChecker    *uniqChecker
+	sharedExtents  *sharedExtents
+	dirStats       *dirStats
 	multiVerList   []*proto.VolVersionInfo
 }
 
+// release releases the snapshots of the trees once the msg is stored or dropped.
+func (msg *storeMsg) release() {
+	for _, tree := range []*BTree{msg.inodeTree, msg.dentryTree, msg.extendTree, msg.multipartTree,
+		msg.dirStatTree, msg.txTree, msg.txRbInodeTree, msg.txRbDentryTree} {
+		tree.Release()
+	}
+}
+
 func (mp *metaPartition) startSchedule(curIndex uint64) {
 	timer := time.NewTimer(time.Hour * 24 * 365)
 	timer.Stop()
@@ -67,6 +78,7 @@ func (mp *metaPartition) startSchedule(curIndex uint64) {
 					" truncate raft log")
 			}
 			curIndex = msg.applyIndex
+			msg.release()
 		} else {
 			// retry again
 			mp.storeChan <- msg
@@ -94,6 +106,9 @@ func (mp *metaPartition) startSchedule(curIndex uint64) {
 			select {
 			case <-stopC:
 				timer.Stop()
+				for _, msg := range msgs {
+					msg.release()
+				}
 				return
 
 			case <-readyChan:
@@ -103,11 +118,17 @@ func (mp *metaPartition) startSchedule(curIndex uint64) {
 				)
 				for _, msg := range msgs {
 					if curIndex >= msg.applyIndex {
+						msg.release()
 						continue
 					}
 					if maxIdx < msg.applyIndex {
+						if maxMsg != nil {
+							maxMsg.release()
+						}
 						maxIdx = msg.applyIndex
 						maxMsg = msg
+					} else {
+						msg.release()
 					}
 				}
 				if maxMsg != nil {
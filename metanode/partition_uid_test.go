@@ -0,0 +1,124 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func uidUsageOf(rsp []*proto.UidReportSpaceInfo, typ proto.QuotaOwnerType, id uint32) (size, files uint64) {
+	for _, info := range rsp {
+		if info.Type == typ && info.Uid == id {
+			return info.Size, info.Files
+		}
+	}
+	return
+}
+
+func TestUidManagerOwnerQuota(t *testing.T) {
+	mp := NewMetaPartitionForTest()
+	mp.uidManager = NewUidMgr(mp.config.VolName, mp.config.PartitionId)
+
+	ino := NewInode(10, FileModeType)
+	ino.Uid, ino.Gid = 1, 100
+	require.Equal(t, proto.OpOk, mp.fsmCreateInode(ino))
+	eks := []proto.ExtentKey{{Size: 4096}}
+	require.Equal(t, proto.OpOk, mp.uidManager.addUidSpace(ino, eks))
+
+	rsp := mp.uidManager.getAllUidSpace()
+	size, files := uidUsageOf(rsp, proto.QuotaOwnerUser, 1)
+	require.Equal(t, uint64(4096), size)
+	require.Equal(t, uint64(1), files)
+	size, files = uidUsageOf(rsp, proto.QuotaOwnerGroup, 100)
+	require.Equal(t, uint64(4096), size)
+	require.Equal(t, uint64(1), files)
+
+	// chown moves the usage to the new owners
+	mp.fsmSetAttr(&SetattrRequest{Inode: ino.Inode, Valid: proto.AttrGid, Gid: 200})
+	rsp = mp.uidManager.getAllUidSpace()
+	_, files = uidUsageOf(rsp, proto.QuotaOwnerGroup, 100)
+	require.Equal(t, uint64(0), files)
+	_, files = uidUsageOf(rsp, proto.QuotaOwnerGroup, 200)
+	require.Equal(t, uint64(1), files)
+
+	// the files limit of the group denies the creates only
+	mp.uidManager.setUidAcl([]*proto.UidSpaceInfo{
+		{VolName: mp.config.VolName, Type: proto.QuotaOwnerGroup, Uid: 200, LimitedFiles: true},
+	})
+	other := NewInode(11, FileModeType)
+	other.Uid, other.Gid = 2, 200
+	require.Equal(t, proto.OpNoSpaceErr, mp.fsmCreateInode(other))
+	require.Equal(t, proto.OpOk, mp.uidManager.addUidSpace(ino, eks))
+
+	// the bytes limit of the user denies both
+	mp.uidManager.setUidAcl([]*proto.UidSpaceInfo{
+		{VolName: mp.config.VolName, Type: proto.QuotaOwnerUser, Uid: 1, Limited: true},
+	})
+	require.Equal(t, proto.OpNoSpaceErr, mp.uidManager.addUidSpace(ino, eks))
+	other.Gid = 300
+	require.Equal(t, proto.OpOk, mp.fsmCreateInode(other))
+	other = NewInode(12, FileModeType)
+	other.Uid = 1
+	require.Equal(t, proto.OpNoSpaceErr, mp.fsmCreateInode(other))
+}
+
+func TestUidManagerInlineData(t *testing.T) {
+	mp := NewMetaPartitionForTest()
+	mp.uidManager = NewUidMgr(mp.config.VolName, mp.config.PartitionId)
+	userSize := func(uid uint32) uint64 {
+		size, _ := uidUsageOf(mp.uidManager.getAllUidSpace(), proto.QuotaOwnerUser, uid)
+		return size
+	}
+
+	file := NewInode(10, FileModeType)
+	file.Uid = 1
+	file.StorageClass = proto.StorageClass_Replica_SSD
+	file.HybridCloudExtents.sortedEks = NewSortedExtents()
+	require.Equal(t, proto.OpOk, mp.fsmCreateInode(file))
+
+	// only the inline data grown is accounted
+	require.Equal(t, proto.OpOk, mp.fsmWriteInline(&fsmWriteInlineRequest{Inode: file.Inode, Data: []byte("tiny file")}).Status)
+	require.Equal(t, uint64(9), userSize(1))
+	require.Equal(t, proto.OpOk, mp.fsmWriteInline(&fsmWriteInlineRequest{Inode: file.Inode, Offset: 5, Data: []byte("data")}).Status)
+	require.Equal(t, uint64(9), userSize(1))
+	require.Equal(t, uint64(9), file.GetSpaceSize())
+
+	// the clone accounts its copy of the inline data to its owner
+	resp := mp.fsmCloneInode(&fsmCloneInodeRequest{Inode: file.Inode, DstInode: 11, Create: true, Uid: 2})
+	require.Equal(t, proto.OpOk, resp.Status)
+	require.Equal(t, uint64(9), userSize(2))
+
+	mp.fsmExtentsTruncate(&Inode{Inode: file.Inode, Size: 4})
+	require.Equal(t, uint64(4), userSize(1))
+
+	// the bytes limit of the owner denies the inline data grown only
+	mp.uidManager.setUidAcl([]*proto.UidSpaceInfo{
+		{VolName: mp.config.VolName, Type: proto.QuotaOwnerUser, Uid: 1, Limited: true},
+	})
+	require.Equal(t, proto.OpNoSpaceErr, mp.fsmWriteInline(&fsmWriteInlineRequest{Inode: file.Inode, Offset: 4, Data: []byte("x")}).Status)
+	require.Equal(t, proto.OpOk, mp.fsmWriteInline(&fsmWriteInlineRequest{Inode: file.Inode, Data: []byte("TINY")}).Status)
+	require.Equal(t, uint64(4), userSize(1))
+	mp.uidManager.setUidAcl(nil)
+
+	// the inline data dropped for the extents is returned
+	ino := NewInode(file.Inode, FileModeType)
+	ino.HybridCloudExtents.sortedEks = NewSortedExtentsFromEks([]proto.ExtentKey{{PartitionId: 1, ExtentId: 1025, Size: 4096}})
+	require.Equal(t, proto.OpOk, mp.fsmAppendExtents(ino))
+	require.Nil(t, file.InlineData)
+	require.Equal(t, uint64(4096), userSize(1))
+}
@@ -0,0 +1,176 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package rocksdbstore registers the RocksDB engine of proto.StoreModeRocksDB for the meta
+// partitions, it is imported by the server binary only as it depends on cgo.
+package rocksdbstore
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/cubefs/cubefs/metanode"
+	"github.com/cubefs/cubefs/proto"
+	"github.com/tecbot/gorocksdb"
+)
+
+const (
+	defaultLruCacheSize    = 256 * 1024 * 1024
+	defaultWriteBufferSize = 64 * 1024 * 1024
+)
+
+var ErrClosed = errors.New("rocksdb store is closed")
+
+func init() {
+	metanode.RegisterKVStore(proto.StoreModeRocksDB, func(dir string) (metanode.KVStore, error) {
+		return Open(dir, defaultLruCacheSize, defaultWriteBufferSize)
+	})
+}
+
+// Store implements metanode.KVStore with RocksDB.
+type Store struct {
+	mu sync.RWMutex // guards db against Close
+	db *gorocksdb.DB
+}
+
+type snapshot struct {
+	s    *Store
+	snap *gorocksdb.Snapshot
+	once sync.Once
+}
+
+func (snap *snapshot) Release() {
+	snap.once.Do(func() {
+		snap.s.mu.RLock()
+		if snap.s.db != nil {
+			snap.s.db.ReleaseSnapshot(snap.snap)
+		}
+		snap.s.mu.RUnlock()
+	})
+}
+
+// Open opens the RocksDB in the directory.
+func Open(dir string, lruCacheSize, writeBufferSize int) (s *Store, err error) {
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return
+	}
+	tableOpts := gorocksdb.NewDefaultBlockBasedTableOptions()
+	tableOpts.SetBlockCache(gorocksdb.NewLRUCache(uint64(lruCacheSize)))
+	tableOpts.SetFilterPolicy(gorocksdb.NewBloomFilter(10))
+	opts := gorocksdb.NewDefaultOptions()
+	opts.SetBlockBasedTableFactory(tableOpts)
+	opts.SetCreateIfMissing(true)
+	opts.SetWriteBufferSize(writeBufferSize)
+	opts.SetMaxWriteBufferNumber(2)
+	db, err := gorocksdb.OpenDb(opts, dir)
+	if err != nil {
+		return nil, fmt.Errorf("open rocksdb %v: %v", dir, err)
+	}
+	return &Store{db: db}, nil
+}
+
+func (s *Store) readOptions(snap metanode.KVSnapshot) *gorocksdb.ReadOptions {
+	ro := gorocksdb.NewDefaultReadOptions()
+	if snap != nil {
+		ro.SetSnapshot(snap.(*snapshot).snap)
+	}
+	return ro
+}
+
+func (s *Store) Get(snap metanode.KVSnapshot, key []byte) (value []byte, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.db == nil {
+		return nil, ErrClosed
+	}
+	ro := s.readOptions(snap)
+	defer ro.Destroy()
+	return s.db.GetBytes(ro, key)
+}
+
+func (s *Store) Range(snap metanode.KVSnapshot, start, end []byte, fn func(key, value []byte) bool) (err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.db == nil {
+		return ErrClosed
+	}
+	ro := s.readOptions(snap)
+	ro.SetFillCache(false)
+	defer ro.Destroy()
+	it := s.db.NewIterator(ro)
+	defer it.Close()
+	for it.Seek(start); it.Valid(); it.Next() {
+		key := copyAndFree(it.Key())
+		if end != nil && bytes.Compare(key, end) >= 0 {
+			break
+		}
+		if !fn(key, copyAndFree(it.Value())) {
+			break
+		}
+	}
+	return it.Err()
+}
+
+func copyAndFree(slice *gorocksdb.Slice) []byte {
+	data := append([]byte(nil), slice.Data()...)
+	slice.Free()
+	return data
+}
+
+func (s *Store) Write(batch *metanode.KVBatch, sync bool) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.db == nil {
+		return ErrClosed
+	}
+	wb := gorocksdb.NewWriteBatch()
+	defer wb.Destroy()
+	for _, op := range batch.Ops {
+		switch op.Type {
+		case metanode.KVOpPut:
+			wb.Put(op.Key, op.Value)
+		case metanode.KVOpDelete:
+			wb.Delete(op.Key)
+		case metanode.KVOpDeleteRange:
+			wb.DeleteRange(op.Key, op.Value)
+		}
+	}
+	wo := gorocksdb.NewDefaultWriteOptions()
+	wo.SetSync(sync)
+	defer wo.Destroy()
+	return s.db.Write(wo, wb)
+}
+
+func (s *Store) NewSnapshot() metanode.KVSnapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	snap := &snapshot{s: s}
+	if s.db != nil {
+		snap.snap = s.db.NewSnapshot()
+	}
+	return snap
+}
+
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.db != nil {
+		s.db.Close()
+		s.db = nil
+	}
+	return nil
+}
@@ -0,0 +1,271 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"sync"
+
+	"github.com/cubefs/cubefs/datanode/storage"
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/errors"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// The extents shared by the inodes cloned by fsmCloneInode. The refs of an extent is the number
+// of the inodes referring to it besides the first one, an inode counts once however many extent
+// keys of it refer to the extent.
+//
+// The extent in the table is never deleted when an inode drops it. The inode dropping its last
+// key of the extent releases one ref, and the extent is deleted as usual by the last inode left
+// after all the refs are released. The ranges dropped by an inode which still holds the rest of
+// the extent are not punched, so the space is freed only when the whole extent is deleted.
+//
+// Only the normal extents are shared, the files with tiny extents are not cloned.
+
+const (
+	sharedExtentsVersion   = 1
+	sharedExtentRecordSize = 20
+)
+
+type sharedExtents struct {
+	sync.RWMutex
+	refs map[proto.SharedExtentID]uint32
+}
+
+func newSharedExtents() *sharedExtents {
+	return &sharedExtents{refs: make(map[proto.SharedExtentID]uint32)}
+}
+
+func sharedExtentIDOf(ek *proto.ExtentKey) proto.SharedExtentID {
+	return proto.SharedExtentID{PartitionId: ek.PartitionId, ExtentId: ek.ExtentId}
+}
+
+func (se *sharedExtents) clone() *sharedExtents {
+	se.RLock()
+	defer se.RUnlock()
+	refs := make(map[proto.SharedExtentID]uint32, len(se.refs))
+	for id, n := range se.refs {
+		refs[id] = n
+	}
+	return &sharedExtents{refs: refs}
+}
+
+func (se *sharedExtents) Len() int {
+	se.RLock()
+	defer se.RUnlock()
+	return len(se.refs)
+}
+
+func (se *sharedExtents) Marshal() (buf []byte, crc uint32, err error) {
+	se.RLock()
+	defer se.RUnlock()
+	buffer := bytes.NewBuffer(make([]byte, 0, 4+len(se.refs)*sharedExtentRecordSize))
+	if err = binary.Write(buffer, binary.BigEndian, int32(sharedExtentsVersion)); err != nil {
+		return
+	}
+	for id, n := range se.refs {
+		if err = binary.Write(buffer, binary.BigEndian, id.PartitionId); err != nil {
+			return
+		}
+		if err = binary.Write(buffer, binary.BigEndian, id.ExtentId); err != nil {
+			return
+		}
+		if err = binary.Write(buffer, binary.BigEndian, n); err != nil {
+			return
+		}
+	}
+	buf = buffer.Bytes()
+	crc = crc32.ChecksumIEEE(buf)
+	return
+}
+
+func (se *sharedExtents) UnMarshal(data []byte) (err error) {
+	if len(data) < 4 || (len(data)-4)%sharedExtentRecordSize != 0 {
+		err = errors.New("invalid sharedExtents file length")
+		log.LogErrorf("sharedExtents UnMarshal err(%v)", err)
+		return
+	}
+	buff := bytes.NewBuffer(data)
+	var version int32
+	if err = binary.Read(buff, binary.BigEndian, &version); err != nil {
+		return
+	}
+	se.Lock()
+	defer se.Unlock()
+	for buff.Len() != 0 {
+		var (
+			id proto.SharedExtentID
+			n  uint32
+		)
+		if err = binary.Read(buff, binary.BigEndian, &id.PartitionId); err != nil {
+			return
+		}
+		if err = binary.Read(buff, binary.BigEndian, &id.ExtentId); err != nil {
+			return
+		}
+		if err = binary.Read(buff, binary.BigEndian, &n); err != nil {
+			return
+		}
+		se.refs[id] = n
+	}
+	return
+}
+
+// share counts one more inode referring to the extents of the keys.
+func (se *sharedExtents) share(eks []proto.ExtentKey) {
+	se.Lock()
+	defer se.Unlock()
+	seen := make(map[proto.SharedExtentID]struct{}, len(eks))
+	for i := range eks {
+		if storage.IsTinyExtent(eks[i].ExtentId) {
+			continue
+		}
+		id := sharedExtentIDOf(&eks[i])
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		se.refs[id]++
+	}
+}
+
+// release filters out the keys of the shared extents from the keys dropped by the inode, and
+// releases the refs of the extents no longer referred to by the inode. The keys left are the
+// ones to be deleted.
+func (se *sharedExtents) release(ino *Inode, eks []proto.ExtentKey) []proto.ExtentKey {
+	if len(eks) == 0 || se.Len() == 0 {
+		return eks
+	}
+	se.Lock()
+	defer se.Unlock()
+	dropped := make(map[proto.SharedExtentID]struct{})
+	left := make([]proto.ExtentKey, 0, len(eks))
+	for i := range eks {
+		id := sharedExtentIDOf(&eks[i])
+		if _, ok := se.refs[id]; !ok || storage.IsTinyExtent(eks[i].ExtentId) {
+			left = append(left, eks[i])
+			continue
+		}
+		dropped[id] = struct{}{}
+	}
+	if len(dropped) == 0 {
+		return eks
+	}
+	if ino != nil && ino.HybridCloudExtents.sortedEks != nil {
+		if extents, ok := ino.HybridCloudExtents.sortedEks.(*SortedExtents); ok {
+			extents.Range(func(_ int, ek proto.ExtentKey) bool {
+				delete(dropped, sharedExtentIDOf(&ek))
+				return len(dropped) > 0
+			})
+		}
+	}
+	for id := range dropped {
+		se.doRelease(id)
+	}
+	return left
+}
+
+// releaseInode releases the refs of the extents referred to by the inode to be deleted.
+func (se *sharedExtents) releaseInode(ino *Inode) {
+	if se.Len() == 0 || ino.HybridCloudExtents.sortedEks == nil {
+		return
+	}
+	extents, ok := ino.HybridCloudExtents.sortedEks.(*SortedExtents)
+	if !ok {
+		return
+	}
+	se.Lock()
+	defer se.Unlock()
+	released := make(map[proto.SharedExtentID]struct{})
+	extents.Range(func(_ int, ek proto.ExtentKey) bool {
+		id := sharedExtentIDOf(&ek)
+		if _, ok := released[id]; ok {
+			return true
+		}
+		if _, ok := se.refs[id]; ok && !storage.IsTinyExtent(ek.ExtentId) {
+			released[id] = struct{}{}
+			se.doRelease(id)
+		}
+		return true
+	})
+}
+
+func (se *sharedExtents) doRelease(id proto.SharedExtentID) {
+	if se.refs[id] <= 1 {
+		delete(se.refs, id)
+		return
+	}
+	se.refs[id]--
+}
+
+func (se *sharedExtents) isShared(ek *proto.ExtentKey) bool {
+	se.RLock()
+	defer se.RUnlock()
+	_, ok := se.refs[sharedExtentIDOf(ek)]
+	return ok && !storage.IsTinyExtent(ek.ExtentId)
+}
+
+// filter returns the keys of the extents which can be deleted with the inode freed in a batch:
+// the extents not shared, and the shared extents the inode is the last holder of. The refs are
+// released only after the inodes of the batch are deleted, so the holders freed before in the
+// same batch are counted in freed, or the extent held only by the inodes of the batch leaks.
+func (se *sharedExtents) filter(eks []*proto.ExtentKey, freed map[proto.SharedExtentID]uint32) []*proto.ExtentKey {
+	if se.Len() == 0 {
+		return eks
+	}
+	se.RLock()
+	defer se.RUnlock()
+	left := make([]*proto.ExtentKey, 0, len(eks))
+	counted := make(map[proto.SharedExtentID]struct{})
+	for _, ek := range eks {
+		id := sharedExtentIDOf(ek)
+		refs, ok := se.refs[id]
+		if !ok || storage.IsTinyExtent(ek.ExtentId) {
+			left = append(left, ek)
+			continue
+		}
+		if _, ok = counted[id]; !ok {
+			counted[id] = struct{}{}
+			freed[id]++
+		}
+		// the extent shared refs times is held by refs+1 inodes
+		if freed[id] > refs {
+			left = append(left, ek)
+		}
+	}
+	return left
+}
+
+// sharedOf returns the shared extents among the keys.
+func (se *sharedExtents) sharedOf(eks []proto.ExtentKey) (ids []proto.SharedExtentID) {
+	if se.Len() == 0 {
+		return
+	}
+	seen := make(map[proto.SharedExtentID]struct{})
+	for i := range eks {
+		if !se.isShared(&eks[i]) {
+			continue
+		}
+		id := sharedExtentIDOf(&eks[i])
+		if _, ok := seen[id]; !ok {
+			seen[id] = struct{}{}
+			ids = append(ids, id)
+		}
+	}
+	return
+}
@@ -0,0 +1,179 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func newCloneTestFile(mp *metaPartition, ino uint64, eks []proto.ExtentKey) *Inode {
+	file := NewInode(ino, FileModeType)
+	file.StorageClass = proto.StorageClass_Replica_SSD
+	file.HybridCloudExtents.sortedEks = NewSortedExtentsFromEks(eks)
+	for _, ek := range eks {
+		file.Size += uint64(ek.Size)
+	}
+	mp.fsmCreateInode(file)
+	return file
+}
+
+func drainExtDelCh(mp *metaPartition) (eks []proto.ExtentKey) {
+	for {
+		select {
+		case dropped := <-mp.extDelCh:
+			eks = append(eks, dropped...)
+		default:
+			return
+		}
+	}
+}
+
+func TestCloneInodeSharedExtents(t *testing.T) {
+	mp := NewMetaPartitionForTest()
+	mp.uidManager = NewUidMgr(mp.config.VolName, mp.config.PartitionId)
+	src := newCloneTestFile(mp, 10, []proto.ExtentKey{
+		{FileOffset: 0, PartitionId: 1, ExtentId: 1025, Size: 4096},
+		{FileOffset: 4096, PartitionId: 1, ExtentId: 1026, Size: 4096},
+		{FileOffset: 8192, PartitionId: 1, ExtentId: 1025, ExtentOffset: 8192, Size: 4096},
+	})
+
+	resp := mp.fsmCloneInode(&fsmCloneInodeRequest{Inode: src.Inode, DstInode: 11, Create: true, Uid: 1, Gid: 2})
+	require.Equal(t, proto.OpOk, resp.Status)
+	dst := resp.Msg
+	require.Equal(t, src.Size, dst.Size)
+	require.Equal(t, uint32(1), dst.Uid)
+	require.Equal(t, 3, dst.HybridCloudExtents.sortedEks.(*SortedExtents).Len())
+	require.Equal(t, 2, mp.sharedExtents.Len())
+
+	// clone into an empty file, the extents are counted once per file
+	empty := newCloneTestFile(mp, 12, nil)
+	resp = mp.fsmCloneInode(&fsmCloneInodeRequest{Inode: src.Inode, DstInode: empty.Inode})
+	require.Equal(t, proto.OpOk, resp.Status)
+	require.Equal(t, uint32(2), mp.sharedExtents.refs[proto.SharedExtentID{PartitionId: 1, ExtentId: 1025}])
+	resp = mp.fsmCloneInode(&fsmCloneInodeRequest{Inode: src.Inode, DstInode: empty.Inode})
+	require.Equal(t, proto.OpArgMismatchErr, resp.Status)
+
+	tiny := newCloneTestFile(mp, 13, []proto.ExtentKey{{PartitionId: 1, ExtentId: 1, Size: 100}})
+	resp = mp.fsmCloneInode(&fsmCloneInodeRequest{Inode: tiny.Inode, DstInode: 14, Create: true})
+	require.Equal(t, proto.OpArgMismatchErr, resp.Status)
+
+	// the partial drop keeps the extent from deletion and holds the ref
+	mp.fsmExtentsTruncate(&Inode{Inode: src.Inode, Size: 4096})
+	require.Empty(t, drainExtDelCh(mp))
+	require.Equal(t, uint32(2), mp.sharedExtents.refs[proto.SharedExtentID{PartitionId: 1, ExtentId: 1025}])
+	require.Equal(t, uint32(1), mp.sharedExtents.refs[proto.SharedExtentID{PartitionId: 1, ExtentId: 1026}])
+
+	mp.fsmExtentsTruncate(&Inode{Inode: src.Inode, Size: 0})
+	require.Empty(t, drainExtDelCh(mp))
+	require.Equal(t, uint32(1), mp.sharedExtents.refs[proto.SharedExtentID{PartitionId: 1, ExtentId: 1025}])
+
+	// the inode deleted releases its refs, and the last file deletes the extents
+	mp.internalDeleteInode(&Inode{Inode: empty.Inode})
+	require.Equal(t, 0, mp.sharedExtents.Len())
+	mp.fsmExtentsTruncate(&Inode{Inode: dst.Inode, Size: 0})
+	require.Len(t, drainExtDelCh(mp), 3)
+}
+
+func TestSharedExtentsMarshal(t *testing.T) {
+	se := newSharedExtents()
+	se.share([]proto.ExtentKey{{PartitionId: 1, ExtentId: 1025}, {PartitionId: 2, ExtentId: 1025}})
+	se.share([]proto.ExtentKey{{PartitionId: 1, ExtentId: 1025}})
+	data, crc, err := se.Marshal()
+	require.NoError(t, err)
+	require.NotZero(t, crc)
+
+	loaded := newSharedExtents()
+	require.NoError(t, loaded.UnMarshal(data))
+	require.Equal(t, se.refs, loaded.refs)
+	require.Error(t, loaded.UnMarshal(data[:len(data)-1]))
+}
+
+func TestCloneInodeMarkedExtents(t *testing.T) {
+	mp := NewMetaPartitionForTest()
+	mp.uidManager = NewUidMgr(mp.config.VolName, mp.config.PartitionId)
+	src := newCloneTestFile(mp, 10, []proto.ExtentKey{
+		{FileOffset: 0, PartitionId: 1, ExtentId: 1025, Size: 4096},
+		{FileOffset: 4096, PartitionId: 1, ExtentId: 1026, Size: 4096},
+	})
+	gen := src.Generation
+
+	// the source is written with a new extent after the extents are marked
+	marked := []proto.SharedExtentID{{PartitionId: 1, ExtentId: 1025}}
+	resp := mp.fsmCloneInode(&fsmCloneInodeRequest{Inode: src.Inode, DstInode: 11, Create: true, Marked: true, Shared: marked})
+	require.Equal(t, proto.OpAgain, resp.Status)
+	require.Equal(t, 0, mp.sharedExtents.Len())
+
+	marked = append(marked, proto.SharedExtentID{PartitionId: 1, ExtentId: 1026})
+	resp = mp.fsmCloneInode(&fsmCloneInodeRequest{Inode: src.Inode, DstInode: 11, Create: true, Marked: true, Shared: marked})
+	require.Equal(t, proto.OpOk, resp.Status)
+	require.Equal(t, gen+1, src.Generation)
+}
+
+func TestSharedExtentsFilterBatch(t *testing.T) {
+	se := newSharedExtents()
+	eks := []proto.ExtentKey{{PartitionId: 1, ExtentId: 1025}, {PartitionId: 1, ExtentId: 1026}}
+	se.share(eks)
+	se.share(eks[:1])
+
+	// the extent 1025 is held by three inodes and 1026 by two, all freed in the same batch
+	freed := make(map[proto.SharedExtentID]uint32)
+	require.Empty(t, se.filter([]*proto.ExtentKey{&eks[0], &eks[1]}, freed))
+	require.Equal(t, []*proto.ExtentKey{&eks[1]}, se.filter([]*proto.ExtentKey{&eks[0], &eks[1]}, freed))
+	require.Equal(t, []*proto.ExtentKey{&eks[0]}, se.filter([]*proto.ExtentKey{&eks[0]}, freed))
+
+	unshared := &proto.ExtentKey{PartitionId: 1, ExtentId: 1027}
+	require.Equal(t, []*proto.ExtentKey{unshared}, se.filter([]*proto.ExtentKey{unshared}, freed))
+}
+
+func TestBorrowedExtentsReferenced(t *testing.T) {
+	mp := NewMetaPartitionForTest()
+	mp.uidManager = NewUidMgr(mp.config.VolName, mp.config.PartitionId)
+	mp.vol = NewVol()
+	mp.vol.UpdatePartitions(&DataPartitionsView{DataPartitions: []*DataPartition{
+		{PartitionID: 1, Hosts: []string{"127.0.0.1:17310"}},
+		{PartitionID: 2, Hosts: []string{"127.0.0.1:17310"}, CloneSource: "src"},
+	}})
+
+	// the keys on the data partitions of the source are held, the tiny extents never are
+	borrowed := mp.borrowedExtents([]proto.ExtentKey{
+		{PartitionId: 1, ExtentId: 1025},
+		{PartitionId: 2, ExtentId: 1025},
+		{PartitionId: 2, ExtentId: 1025, ExtentOffset: 4096},
+		{PartitionId: 2, ExtentId: 1},
+		{PartitionId: 2, ExtentId: 1026},
+	})
+	require.Equal(t, map[uint64][]uint64{2: {1025, 1026}}, borrowed)
+
+	newCloneTestFile(mp, 10, []proto.ExtentKey{
+		{FileOffset: 0, PartitionId: 2, ExtentId: 1025, Size: 4096},
+		{FileOffset: 4096, PartitionId: 1, ExtentId: 1026, Size: 4096},
+	})
+	deleted := newCloneTestFile(mp, 11, []proto.ExtentKey{{FileOffset: 0, PartitionId: 2, ExtentId: 1026, Size: 4096}})
+	deleted.SetDeleteMark()
+
+	// the extents referred to by the inodes left are kept held
+	candidates := map[uint64]struct{}{1025: {}, 1026: {}, 1027: {}}
+	mp.dropReferencedExtents(2, candidates)
+	require.Equal(t, map[uint64]struct{}{1026: {}, 1027: {}}, candidates)
+
+	// the tiny extents are not released, so nothing is sent
+	require.NoError(t, mp.releaseBorrowedExtents(mp.vol.GetPartition(2), []*proto.DelExtentParam{
+		{ExtentKey: &proto.ExtentKey{PartitionId: 2, ExtentId: 1}},
+		{ExtentKey: &proto.ExtentKey{PartitionId: 2, ExtentId: 1025}},
+	}))
+}
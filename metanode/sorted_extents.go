@@ -3,6 +3,7 @@ package metanode
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"sync"
 
 	"github.com/cubefs/cubefs/datanode/storage"
@@ -92,6 +93,47 @@ func (se *SortedExtents) UnmarshalBinary(data []byte, v3 bool) (err error, split
 	return
 }
 
+// HasCompressed returns if any key refers to a compressed block.
+func (se *SortedExtents) HasCompressed() bool {
+	se.RLock()
+	defer se.RUnlock()
+	for i := range se.eks {
+		if se.eks[i].IsCompressed() {
+			return true
+		}
+	}
+	return false
+}
+
+// MarshalCompressBinary marshals the compression fields of the keys in order. They are kept apart
+// from the binary format of the keys, which is shared by the inodes written by the old versions.
+func (se *SortedExtents) MarshalCompressBinary() []byte {
+	se.RLock()
+	defer se.RUnlock()
+
+	data := make([]byte, 0, proto.ExtentCompressSize*len(se.eks))
+	for i := range se.eks {
+		data = se.eks[i].MarshalCompressBinary(data)
+	}
+	return data
+}
+
+func (se *SortedExtents) UnmarshalCompressBinary(data []byte) (err error) {
+	se.Lock()
+	defer se.Unlock()
+
+	if len(data) != proto.ExtentCompressSize*len(se.eks) {
+		return fmt.Errorf("compress data size(%v) mismatches %v extents", len(data), len(se.eks))
+	}
+	buf := bytes.NewBuffer(data)
+	for i := range se.eks {
+		if err = se.eks[i].UnmarshalCompressBinary(buf); err != nil {
+			return
+		}
+	}
+	return
+}
+
 func (se *SortedExtents) Append(ek proto.ExtentKey) (deleteExtents []proto.ExtentKey) {
 	endOffset := ek.FileOffset + uint64(ek.Size)
 
@@ -144,6 +186,7 @@ func (se *SortedExtents) Append(ek proto.ExtentKey) (deleteExtents []proto.Exten
 			deleteExtents = append(deleteExtents, key)
 		}
 	}
+	deleteExtents = filterReferencedExtents(se.eks, deleteExtents)
 	return
 }
 
@@ -151,6 +194,11 @@ func storeEkSplit(mpId uint64, inodeID uint64, ekRef *sync.Map, ek *proto.Extent
 	if !clusterEnableSnapshot {
 		return
 	}
+	// the split key is freed by punching its range of the extent, which doesn't work for the key
+	// sharing a compressed block, so the extent is kept until no key refers to it
+	if ek.IsCompressed() {
+		return
+	}
 	if ekRef == nil {
 		log.LogErrorf("[storeEkSplit] mpId [%v] inodeID %v ekRef nil", mpId, inodeID)
 		return
@@ -457,6 +505,9 @@ func (se *SortedExtents) AppendWithCheck(inodeID uint64, ek proto.ExtentKey, add
 
 	endIndex = startIndex + len(invalidExtents)
 	se.instertWithDiscard(ek, startIndex, endIndex)
+	// the keys are checked against the ones discarded by the client, but the extent of the compressed
+	// keys may be still referenced by the others
+	deleteExtents = filterReferencedExtents(se.eks, deleteExtents)
 	return
 }
 
@@ -480,6 +531,7 @@ func (se *SortedExtents) Truncate(offset uint64, doOnLastKey func(*proto.ExtentK
 		deleteExtents = make([]proto.ExtentKey, len(se.eks)-endIndex)
 		copy(deleteExtents, se.eks[endIndex:])
 		se.eks = se.eks[:endIndex]
+		deleteExtents = filterReferencedExtents(se.eks, deleteExtents)
 	}
 
 	numKeys := len(se.eks)
@@ -491,7 +543,7 @@ func (se *SortedExtents) Truncate(offset uint64, doOnLastKey func(*proto.ExtentK
 			}
 			originSize := lastKey.Size
 			lastKey.Size = uint32(offset - lastKey.FileOffset)
-			if !clusterEnableSnapshot {
+			if !clusterEnableSnapshot || lastKey.IsCompressed() {
 				return
 			}
 
@@ -503,7 +555,7 @@ func (se *SortedExtents) Truncate(offset uint64, doOnLastKey func(*proto.ExtentK
 
 			rsKey.Size = originSize - lastKey.Size
 			rsKey.FileOffset += uint64(lastKey.Size)
-			rsKey.ExtentOffset += uint64(lastKey.Size)
+			rsKey.SkipHead(uint64(lastKey.Size))
 			if insertRefMap != nil {
 				insertRefMap(rsKey)
 			}
@@ -515,6 +567,104 @@ func (se *SortedExtents) Truncate(offset uint64, doOnLastKey func(*proto.ExtentK
 	return
 }
 
+// PunchHole removes the keys within [offset, offset+size), the keys partly covered by the range
+// are split to keep the parts out of it. The keys removed are returned to be deleted, except the
+// compressed ones whose extent is still referenced by the keys left, see filterReferencedExtents.
+// Like Truncate, the split parts of normal extents are not freed unless snapshot is enabled.
+func (se *SortedExtents) PunchHole(offset, size uint64, insertRefMap func(ek *proto.ExtentKey)) (deleteExtents []proto.ExtentKey) {
+	end := offset + size
+
+	se.Lock()
+	defer se.Unlock()
+
+	eks := make([]proto.ExtentKey, 0, len(se.eks)+1)
+	removed := make([]proto.ExtentKey, 0)
+	for _, key := range se.eks {
+		keyEnd := key.FileOffset + uint64(key.Size)
+		if keyEnd <= offset || key.FileOffset >= end {
+			eks = append(eks, key)
+			continue
+		}
+		if key.FileOffset >= offset && keyEnd <= end {
+			removed = append(removed, key)
+			continue
+		}
+
+		midKey := key
+		if key.FileOffset < offset {
+			leftKey := key
+			leftKey.Size = uint32(offset - key.FileOffset)
+			eks = append(eks, leftKey)
+			if clusterEnableSnapshot && insertRefMap != nil {
+				insertRefMap(&eks[len(eks)-1])
+			}
+			midKey.FileOffset = offset
+			midKey.SkipHead(offset - key.FileOffset)
+		}
+		if keyEnd > end {
+			rightKey := key
+			rightKey.FileOffset = end
+			rightKey.SkipHead(end - key.FileOffset)
+			rightKey.Size = uint32(keyEnd - end)
+			eks = append(eks, rightKey)
+			if clusterEnableSnapshot && insertRefMap != nil {
+				insertRefMap(&eks[len(eks)-1])
+			}
+			keyEnd = end
+		}
+		midKey.Size = uint32(keyEnd - midKey.FileOffset)
+		if clusterEnableSnapshot {
+			if insertRefMap != nil {
+				insertRefMap(&midKey)
+			}
+		} else if !storage.IsTinyExtent(key.ExtentId) && !key.IsCompressed() {
+			// the extent is still referenced by the keys left, so the range is punched from it as a
+			// split key. The compressed blocks may be shared with the other keys, so they are kept.
+			midKey.SnapInfo = &proto.ExtSnapInfo{VerSeq: key.GetSeq(), IsSplit: true}
+		}
+		removed = append(removed, midKey)
+	}
+	se.eks = eks
+
+	deleteExtents = filterReferencedExtents(eks, removed)
+	log.LogDebugf("SortedExtents.PunchHole offset %v size %v, deleteExtents %v", offset, size, deleteExtents)
+	return
+}
+
+// filterReferencedExtents returns the keys removed except the compressed ones whose extent is still
+// referenced by the keys left or deleted already. The compressed blocks written by a client share
+// the extent in many keys, and the whole extent is deleted for them, while the range of the other
+// keys is punched from the extent, so they are returned as they are.
+func filterReferencedExtents(eks []proto.ExtentKey, removed []proto.ExtentKey) (deleteExtents []proto.ExtentKey) {
+	compressed := false
+	for i := range removed {
+		if removed[i].IsCompressed() {
+			compressed = true
+			break
+		}
+	}
+	if !compressed {
+		return removed
+	}
+	referenced := make(map[proto.SharedExtentID]struct{}, len(eks))
+	for i := range eks {
+		referenced[proto.SharedExtentID{PartitionId: eks[i].PartitionId, ExtentId: eks[i].ExtentId}] = struct{}{}
+	}
+	deleteExtents = make([]proto.ExtentKey, 0, len(removed))
+	for _, key := range removed {
+		if !key.IsCompressed() || key.IsSplit() || storage.IsTinyExtent(key.ExtentId) {
+			deleteExtents = append(deleteExtents, key)
+			continue
+		}
+		id := proto.SharedExtentID{PartitionId: key.PartitionId, ExtentId: key.ExtentId}
+		if _, ok := referenced[id]; !ok {
+			referenced[id] = struct{}{}
+			deleteExtents = append(deleteExtents, key)
+		}
+	}
+	return
+}
+
 func (se *SortedExtents) insert(ek proto.ExtentKey, startIdx int) {
 	se.eks = append(se.eks, ek)
 	size := len(se.eks)
@@ -1,6 +1,7 @@
 package metanode
 
 import (
+	"sync"
 	"testing"
 
 	"github.com/cubefs/cubefs/proto"
@@ -95,6 +96,70 @@ func TestTruncate01(t *testing.T) {
 	}
 }
 
+func TestPunchHole01(t *testing.T) {
+	defer func(enable bool) { clusterEnableSnapshot = enable }(clusterEnableSnapshot)
+	clusterEnableSnapshot = false
+
+	se := NewSortedExtents()
+	se.AppendWithCheck(0, proto.ExtentKey{FileOffset: 0, Size: 1000, ExtentId: 100}, nil, nil)
+	se.AppendWithCheck(0, proto.ExtentKey{FileOffset: 1000, Size: 1000, ExtentId: 101}, nil, nil)
+	se.AppendWithCheck(0, proto.ExtentKey{FileOffset: 2000, Size: 1000, ExtentId: 102}, nil, nil)
+	se.AppendWithCheck(0, proto.ExtentKey{FileOffset: 3000, Size: 1000, ExtentId: 1}, nil, nil)
+	delExtents := se.PunchHole(500, 3000, nil)
+	t.Logf("\ndel: %v\neks: %v", delExtents, se.eks)
+	// the split part of the normal extent is punched as a split key, and the one of tiny extent is freed
+	if len(delExtents) != 4 || delExtents[0].ExtentId != 100 || !delExtents[0].IsSplit() ||
+		delExtents[0].ExtentOffset != 500 || delExtents[0].Size != 500 ||
+		delExtents[1].ExtentId != 101 || delExtents[1].IsSplit() || delExtents[2].ExtentId != 102 ||
+		delExtents[3].ExtentId != 1 || delExtents[3].IsSplit() || delExtents[3].ExtentOffset != 0 || delExtents[3].Size != 500 ||
+		len(se.eks) != 2 || se.eks[0].Size != 500 || se.eks[0].IsSplit() ||
+		se.eks[1].FileOffset != 3500 || se.eks[1].ExtentOffset != 500 || se.eks[1].Size != 500 {
+		t.Fail()
+	}
+
+	// the range of the extent still referenced by the keys left is punched from it
+	se = NewSortedExtents()
+	se.AppendWithCheck(0, proto.ExtentKey{FileOffset: 0, Size: 1000, ExtentId: 100}, nil, nil)
+	se.AppendWithCheck(0, proto.ExtentKey{FileOffset: 1000, Size: 1000, ExtentId: 101}, nil, nil)
+	se.AppendWithCheck(0, proto.ExtentKey{FileOffset: 2000, Size: 1000, ExtentId: 100, ExtentOffset: 2000}, nil, nil)
+	delExtents = se.PunchHole(0, 1000, nil)
+	t.Logf("\ndel: %v\neks: %v", delExtents, se.eks)
+	if len(delExtents) != 1 || delExtents[0].ExtentId != 100 || delExtents[0].ExtentOffset != 0 ||
+		len(se.eks) != 2 || se.Size() != 3000 {
+		t.Fail()
+	}
+
+	// the compressed blocks may be shared with the other keys
+	se = NewSortedExtents()
+	se.AppendWithCheck(0, proto.ExtentKey{FileOffset: 0, Size: 3000, ExtentId: 100, CompressSize: 1000}, nil, nil)
+	delExtents = se.PunchHole(1000, 1000, nil)
+	t.Logf("\ndel: %v\neks: %v", delExtents, se.eks)
+	if len(delExtents) != 0 || len(se.eks) != 2 {
+		t.Fail()
+	}
+}
+
+func TestPunchHole02(t *testing.T) {
+	defer func(enable bool) { clusterEnableSnapshot = enable }(clusterEnableSnapshot)
+	clusterEnableSnapshot = true
+
+	refs := 0
+	insertRefMap := func(ek *proto.ExtentKey) {
+		ek.SetSplit(true)
+		refs++
+	}
+	se := NewSortedExtents()
+	se.AppendWithCheck(0, proto.ExtentKey{FileOffset: 0, Size: 3000, ExtentId: 100}, nil, nil)
+	delExtents := se.PunchHole(1000, 1000, insertRefMap)
+	t.Logf("\ndel: %v\neks: %v", delExtents, se.eks)
+	if len(delExtents) != 1 || !delExtents[0].IsSplit() ||
+		delExtents[0].ExtentOffset != 1000 || delExtents[0].Size != 1000 ||
+		len(se.eks) != 2 || !se.eks[0].IsSplit() || !se.eks[1].IsSplit() ||
+		se.eks[1].FileOffset != 2000 || se.eks[1].ExtentOffset != 2000 || refs != 3 {
+		t.Fail()
+	}
+}
+
 func TestSortedMarshal(t *testing.T) {
 	se := NewSortedExtents()
 
@@ -137,3 +202,109 @@ func TestSortedMarshal(t *testing.T) {
 		}
 	}
 }
+
+// The compressed blocks share the extent, which is deleted only if no key refers to it.
+func TestPunchHole03(t *testing.T) {
+	defer func(enable bool) { clusterEnableSnapshot = enable }(clusterEnableSnapshot)
+	clusterEnableSnapshot = true
+
+	ekRef := new(sync.Map)
+	insertRefMap := func(ek *proto.ExtentKey) { storeEkSplit(0, 0, ekRef, ek) }
+	se := NewSortedExtents()
+	se.AppendWithCheck(0, proto.ExtentKey{FileOffset: 0, Size: 1000, ExtentId: 100, CompressSize: 300}, insertRefMap, nil)
+	se.AppendWithCheck(0, proto.ExtentKey{FileOffset: 1000, Size: 1000, ExtentId: 100, ExtentOffset: 300, CompressSize: 200}, insertRefMap, nil)
+	se.AppendWithCheck(0, proto.ExtentKey{FileOffset: 2000, Size: 1000, ExtentId: 100, ExtentOffset: 500, CompressSize: 400}, insertRefMap, nil)
+	delExtents := se.PunchHole(500, 1000, insertRefMap)
+	t.Logf("\ndel: %v\neks: %v", delExtents, se.eks)
+	if len(delExtents) != 0 || len(se.eks) != 3 || se.eks[0].IsSplit() || se.eks[1].IsSplit() ||
+		se.eks[0].Size != 500 || se.eks[0].CompressOffset != 0 ||
+		se.eks[1].FileOffset != 1500 || se.eks[1].Size != 500 ||
+		se.eks[1].ExtentOffset != 300 || se.eks[1].CompressOffset != 500 {
+		t.Fail()
+	}
+
+	delExtents = se.PunchHole(0, 3000, nil)
+	t.Logf("\ndel: %v\neks: %v", delExtents, se.eks)
+	if len(delExtents) != 1 || delExtents[0].ExtentId != 100 || len(se.eks) != 0 {
+		t.Fail()
+	}
+}
+
+func TestAppendCompressed(t *testing.T) {
+	refFunc := func(*proto.ExtentKey) {}
+	se := NewSortedExtents()
+	se.AppendWithCheck(0, proto.ExtentKey{FileOffset: 0, Size: 1000, ExtentId: 100, CompressSize: 300}, refFunc, nil)
+	se.AppendWithCheck(0, proto.ExtentKey{FileOffset: 1000, Size: 1000, ExtentId: 100, ExtentOffset: 300, CompressSize: 200}, refFunc, nil)
+	discard := []proto.ExtentKey{se.eks[0]}
+	delExtents, status := se.AppendWithCheck(0, proto.ExtentKey{FileOffset: 0, Size: 1000, ExtentId: 200, CompressSize: 100}, nil, discard)
+	t.Logf("\ndel: %v\nstatus: %v\neks: %v", delExtents, status, se.eks)
+	if status != proto.OpOk || len(delExtents) != 0 || len(se.eks) != 2 || se.eks[0].ExtentId != 200 {
+		t.Fail()
+	}
+
+	delExtents = se.Truncate(500, nil, nil)
+	t.Logf("\ndel: %v\neks: %v", delExtents, se.eks)
+	if len(delExtents) != 1 || delExtents[0].ExtentId != 100 ||
+		len(se.eks) != 1 || se.eks[0].Size != 500 || se.eks[0].CompressSize != 100 {
+		t.Fail()
+	}
+}
+
+// the range of the uncompressed key is punched from the extent, so it's deleted even if the rest of
+// the extent is still referenced by the file
+func TestAppendUncompressedShared(t *testing.T) {
+	se := NewSortedExtents()
+	se.Append(proto.ExtentKey{FileOffset: 0, Size: 1000, ExtentId: 100})
+	se.Append(proto.ExtentKey{FileOffset: 2000, Size: 1000, ExtentId: 100, ExtentOffset: 2000})
+	delExtents := se.Append(proto.ExtentKey{FileOffset: 0, Size: 1000, ExtentId: 200})
+	t.Logf("\ndel: %v\neks: %v", delExtents, se.eks)
+	if len(delExtents) != 1 || delExtents[0].ExtentId != 100 || delExtents[0].ExtentOffset != 0 || len(se.eks) != 2 {
+		t.Fail()
+	}
+
+	refFunc := func(*proto.ExtentKey) {}
+	discard := []proto.ExtentKey{se.eks[1]}
+	se.Append(proto.ExtentKey{FileOffset: 4000, Size: 1000, ExtentId: 100, ExtentOffset: 4000})
+	delExtents, status := se.AppendWithCheck(0, proto.ExtentKey{FileOffset: 2000, Size: 1000, ExtentId: 300}, refFunc, discard)
+	t.Logf("\ndel: %v\nstatus: %v\neks: %v", delExtents, status, se.eks)
+	if status != proto.OpOk || len(delExtents) != 1 || delExtents[0].ExtentOffset != 2000 {
+		t.Fail()
+	}
+}
+
+func TestTruncateUncompressedShared(t *testing.T) {
+	se := NewSortedExtents()
+	se.Append(proto.ExtentKey{FileOffset: 0, Size: 1000, ExtentId: 100})
+	se.Append(proto.ExtentKey{FileOffset: 1000, Size: 1000, ExtentId: 200})
+	se.Append(proto.ExtentKey{FileOffset: 2000, Size: 1000, ExtentId: 100, ExtentOffset: 2000})
+	delExtents := se.Truncate(1000, nil, nil)
+	t.Logf("\ndel: %v\neks: %v", delExtents, se.eks)
+	if len(delExtents) != 2 || delExtents[0].ExtentId != 200 || delExtents[1].ExtentId != 100 ||
+		delExtents[1].ExtentOffset != 2000 || len(se.eks) != 1 {
+		t.Fail()
+	}
+}
+
+func TestSortedMarshalCompressed(t *testing.T) {
+	se := NewSortedExtents()
+	se.eks = append(se.eks, proto.ExtentKey{FileOffset: 0, Size: 1000, ExtentId: 100, CompressSize: 300, CompressOffset: 10})
+	se.eks = append(se.eks, proto.ExtentKey{FileOffset: 1000, Size: 1000, ExtentId: 100, ExtentOffset: 300})
+
+	data, err := se.MarshalBinary(false)
+	if err != nil {
+		t.Fail()
+	}
+	se2 := NewSortedExtents()
+	if err, _ = se2.UnmarshalBinary(data, false); err != nil {
+		t.Fail()
+	}
+	if err = se2.UnmarshalCompressBinary(se.MarshalCompressBinary()); err != nil {
+		t.Fail()
+	}
+	if !se.Equals(se2) || !se2.HasCompressed() || se2.eks[0].CompressOffset != 10 {
+		t.Fail()
+	}
+	if se2.UnmarshalCompressBinary(make([]byte, proto.ExtentCompressSize)) == nil {
+		t.Fail()
+	}
+}
@@ -1408,7 +1408,8 @@ func (tr *TransactionResource) rollbackInodeInternal(rbInode *TxRollbackInode) (
 		if item == nil || ino.IsTempFile() || ino.ShouldDelete() {
 			mp.freeList.Remove(rbInode.inode.Inode)
 			if mp.uidManager != nil {
-				mp.uidManager.addUidSpace(rbInode.inode.Uid, rbInode.inode.Inode, rbInode.inode.Extents.eks)
+				mp.uidManager.addUidSpace(rbInode.inode, rbInode.inode.Extents.eks)
+				mp.uidManager.addUidFiles(rbInode.inode, 1)
 			}
 			if mp.mqMgr != nil && len(rbInode.quotaIds) > 0 && item == nil {
 				mp.setInodeQuota(rbInode.quotaIds, rbInode.inode.Inode)
@@ -1424,7 +1425,8 @@ func (tr *TransactionResource) rollbackInodeInternal(rbInode *TxRollbackInode) (
 	case TxDelete:
 		if rsp := tr.txProcessor.mp.getInode(rbInode.inode, false); rsp.Status == proto.OpOk {
 			if tr.txProcessor.mp.uidManager != nil {
-				tr.txProcessor.mp.uidManager.doMinusUidSpace(rbInode.inode.Uid, rbInode.inode.Inode, rbInode.inode.Size)
+				tr.txProcessor.mp.uidManager.doMinusUidSpace(rbInode.inode, rbInode.inode.Size)
+				tr.txProcessor.mp.uidManager.addUidFiles(rbInode.inode, -1)
 			}
 
 			if tr.txProcessor.mp.mqMgr != nil && len(rbInode.quotaIds) > 0 {
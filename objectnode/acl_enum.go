@@ -75,12 +75,14 @@ var (
 		// object read
 		proto.OSSGetObjectAction:  PermissionRead,
 		proto.OSSHeadObjectAction: PermissionRead,
+		// object select
+		proto.OSSSelectObjectContentAction: PermissionRead,
 		// object acp
 		proto.OSSPutObjectAclAction: PermissionWriteAcp,
 		proto.OSSGetObjectAclAction: PermissionReadAcp,
 	}
 	aclApiList             = []proto.Action{proto.OSSPutBucketAclAction, proto.OSSGetBucketAclAction, proto.OSSPutObjectAclAction, proto.OSSGetObjectAclAction}
-	objectACLSupportedApis = []proto.Action{proto.OSSGetObjectAction, proto.OSSHeadObjectAction, proto.OSSPutObjectAclAction, proto.OSSGetObjectAclAction}
+	objectACLSupportedApis = []proto.Action{proto.OSSGetObjectAction, proto.OSSHeadObjectAction, proto.OSSSelectObjectContentAction, proto.OSSPutObjectAclAction, proto.OSSGetObjectAclAction}
 )
 
 var (
@@ -97,6 +97,11 @@ func (o *ObjectNode) putBucketACLHandler(w http.ResponseWriter, r *http.Request)
 			GetRequestID(r), param.bucket, err)
 		return
 	}
+	if err = o.checkPublicAcl(vol, acl); err != nil {
+		log.LogErrorf("putBucketACLHandler: public acl blocked: requestID(%v) volume(%v) acl(%+v) err(%v)",
+			GetRequestID(r), param.bucket, acl, err)
+		return
+	}
 	if err = putBucketACL(vol, acl); err != nil {
 		log.LogErrorf("putBucketACLHandler: put acl fail: requestID(%v) volume(%v) acl(%+v) err(%v)",
 			GetRequestID(r), param.bucket, acl, err)
@@ -208,6 +213,11 @@ func (o *ObjectNode) putObjectACLHandler(w http.ResponseWriter, r *http.Request)
 			GetRequestID(r), param.bucket, param.object, err)
 		return
 	}
+	if err = o.checkPublicAcl(vol, acl); err != nil {
+		log.LogErrorf("putObjectACLHandler: public acl blocked: requestID(%v) volume(%v) path(%v) acl(%+v) err(%v)",
+			GetRequestID(r), param.bucket, param.object, acl, err)
+		return
+	}
 	if oldAcl != nil {
 		originalOwner := oldAcl.GetOwner()
 		if oldAcl.IsEmpty() {
@@ -35,6 +35,7 @@ const (
 	ContextKeyAccessKey     = "access_key"
 	ContextKeyRequester     = "requester"
 	ContextKeyOwner         = "owner"
+	ContextKeyWebsite       = "website"
 )
 
 func SetRequestID(r *http.Request, requestID string) {
@@ -72,3 +73,8 @@ func SetResponseErrorMessage(r *http.Request, message string) {
 func getResponseErrorMessage(r *http.Request) string {
 	return mux.Vars(r)[ContextKeyErrorMessage]
 }
+
+// isWebsiteRequest reports whether the request is sent to the static website endpoint.
+func isWebsiteRequest(r *http.Request) bool {
+	return mux.Vars(r)[ContextKeyWebsite] != ""
+}
@@ -119,6 +119,11 @@ func (o *ObjectNode) createBucketHandler(w http.ResponseWriter, r *http.Request)
 		log.LogErrorf("createBucketHandler: parse acl fail: requestID(%v) err(%v)", GetRequestID(r), err)
 		return
 	}
+	// only the settings of cluster take effect before the bucket is created
+	if err = o.checkPublicAcl(nil, acl); err != nil {
+		log.LogErrorf("createBucketHandler: public acl blocked: requestID(%v) acl(%+v) err(%v)", GetRequestID(r), acl, err)
+		return
+	}
 
 	if err = o.mc.AdminAPI().CreateDefaultVolume(bucket, userInfo.UserID); err != nil {
 		log.LogErrorf("createBucketHandler: create bucket fail: requestID(%v) volume(%v) accessKey(%v) err(%v)",
@@ -68,6 +68,10 @@ func (o *ObjectNode) createMultipleUploadHandler(w http.ResponseWriter, r *http.
 			GetRequestID(r), err)
 		return
 	}
+	if vol.isReservedObjectKey(param.Object()) {
+		errorCode = InvalidKey
+		return
+	}
 
 	// QPS and Concurrency Limit
 	rateLimit := o.AcquireRateLimiter()
@@ -116,6 +120,18 @@ func (o *ObjectNode) createMultipleUploadHandler(w http.ResponseWriter, r *http.
 			GetRequestID(r), acl, err)
 		return
 	}
+	if err = o.checkPublicAcl(vol, acl); err != nil {
+		log.LogErrorf("createMultipleUploadHandler: public acl blocked: requestID(%v) volume(%v) acl(%+v) err(%v)",
+			GetRequestID(r), vol.Name(), acl, err)
+		return
+	}
+	// Server-side encryption
+	var encryption *ObjectEncryption
+	if encryption, err = o.newObjectEncryption(r, vol); err != nil {
+		log.LogErrorf("createMultipleUploadHandler: new encryption fail: requestID(%v) volume(%v) path(%v) err(%v)",
+			GetRequestID(r), vol.Name(), param.Object(), err)
+		return
+	}
 	opt := &PutFileOption{
 		MIMEType:     contentType,
 		Disposition:  contentDisposition,
@@ -124,6 +140,7 @@ func (o *ObjectNode) createMultipleUploadHandler(w http.ResponseWriter, r *http.
 		CacheControl: cacheControl,
 		Expires:      expires,
 		ACL:          acl,
+		Encryption:   encryption,
 	}
 
 	var uploadID string
@@ -133,6 +150,7 @@ func (o *ObjectNode) createMultipleUploadHandler(w http.ResponseWriter, r *http.
 		return
 	}
 
+	encryption.SetResponseHeader(w)
 	initResult := InitMultipartResult{
 		Bucket:   param.Bucket(),
 		Key:      param.Object(),
@@ -242,6 +260,15 @@ func (o *ObjectNode) uploadPartHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer rateLimit.ReleaseLimitResource(vol.owner, param.apiName)
 
+	// the part is encrypted if the multipart upload is encrypted
+	var encryption *ObjectEncryption
+	if encryption, err = o.loadMultipartEncryption(r, vol, param.Object(), uploadId); err != nil {
+		log.LogErrorf("uploadPartHandler: load encryption fail: requestID(%v) volume(%v) path(%v) uploadId(%v) err(%v)",
+			GetRequestID(r), vol.Name(), param.Object(), uploadId, err)
+		err = handleWritePartErr(err)
+		return
+	}
+
 	// Flow Control
 	var reader io.Reader
 	if length > DefaultFlowLimitSize {
@@ -252,7 +279,7 @@ func (o *ObjectNode) uploadPartHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Write Part
 	start := time.Now()
-	fsFileInfo, err := vol.WritePart(param.Object(), uploadId, partNumberInt, reader)
+	fsFileInfo, err := vol.WritePart(param.Object(), uploadId, partNumberInt, reader, encryption)
 	span.AppendTrackLog("part.w", start, err)
 	if err != nil {
 		log.LogErrorf("uploadPartHandler: write part fail: requestID(%v) volume(%v) path(%v) uploadId(%v) part(%v) err(%v)",
@@ -271,6 +298,7 @@ func (o *ObjectNode) uploadPartHandler(w http.ResponseWriter, r *http.Request) {
 
 	// write header to response
 	w.Header()[ETag] = []string{"\"" + fsFileInfo.ETag + "\""}
+	encryption.SetResponseHeader(w)
 }
 
 // Upload part copy
@@ -361,6 +389,19 @@ func (o *ObjectNode) uploadPartCopyHandler(w http.ResponseWriter, r *http.Reques
 	if errorCode != nil {
 		return
 	}
+	// the source object is decrypted by the copy source SSE-C key or the master key
+	if err = o.unsealObjectEncryption(r, srcFileInfo.Encryption, true); err != nil {
+		log.LogErrorf("uploadPartCopyHandler: unseal source encryption fail: requestId(%v) srcVol(%v) path(%v) err(%v)",
+			GetRequestID(r), srcBucket, srcObject, err)
+		return
+	}
+	var encryption *ObjectEncryption
+	if encryption, err = o.loadMultipartEncryption(r, vol, param.Object(), uploadId); err != nil {
+		log.LogErrorf("uploadPartCopyHandler: load encryption fail: requestID(%v) volume(%v) path(%v) uploadId(%v) err(%v)",
+			GetRequestID(r), vol.Name(), param.Object(), uploadId, err)
+		err = handleWritePartErr(err)
+		return
+	}
 
 	// step4: extract range params
 	copyRange := r.Header.Get(XAmzCopySourceRange)
@@ -380,14 +421,18 @@ func (o *ObjectNode) uploadPartCopyHandler(w http.ResponseWriter, r *http.Reques
 	if err != nil {
 		return
 	}
-	reader, writer := io.Pipe()
+	reader, pipeWriter := io.Pipe()
+	var writer io.Writer = pipeWriter
+	if srcFileInfo.Encryption != nil {
+		writer = srcFileInfo.Encryption.DecryptWriter(pipeWriter, fb)
+	}
 	go func() {
 		err = srcVol.readFile(srcFileInfo.Inode, size, srcObject, writer, fb, cl, srcFileInfo.StorageClass)
 		if err != nil {
 			log.LogErrorf("uploadPartCopyHandler: read srcObj err(%v): requestId(%v) srcVol(%v) path(%v)",
 				err, GetRequestID(r), srcBucket, srcObject)
 		}
-		writer.CloseWithError(err)
+		pipeWriter.CloseWithError(err)
 	}()
 
 	// step5: upload part by copy and flow control
@@ -398,7 +443,7 @@ func (o *ObjectNode) uploadPartCopyHandler(w http.ResponseWriter, r *http.Reques
 		rd = reader
 	}
 	start = time.Now()
-	fsFileInfo, err := vol.WritePart(param.Object(), uploadId, partNumberInt, rd)
+	fsFileInfo, err := vol.WritePart(param.Object(), uploadId, partNumberInt, rd, encryption)
 	span.AppendTrackLog("part.w", start, err)
 	if err != nil {
 		log.LogErrorf("uploadPartCopyHandler: write part fail: requestID(%v) volume(%v) path(%v) uploadId(%v) part(%v) err(%v)",
@@ -419,6 +464,7 @@ func (o *ObjectNode) uploadPartCopyHandler(w http.ResponseWriter, r *http.Reques
 
 	Etag := "\"" + fsFileInfo.ETag + "\""
 	w.Header()[ETag] = []string{Etag}
+	encryption.SetResponseHeader(w)
 	response := NewS3CopyPartResult(Etag, fsFileInfo.CreateTime.UTC().Format(time.RFC3339)).String()
 
 	writeSuccessResponseXML(w, []byte(response))
@@ -672,13 +718,16 @@ func (o *ObjectNode) completeMultipartUploadHandler(w http.ResponseWriter, r *ht
 		errorCode = KeyTooLong
 		return
 	}
-
 	var vol *Volume
 	if vol, err = o.getVol(param.Bucket()); err != nil {
 		log.LogErrorf("completeMultipartUploadHandler: load volume fail: requestID(%v) err(%v)",
 			GetRequestID(r), err)
 		return
 	}
+	if vol.isReservedObjectKey(param.Object()) {
+		errorCode = InvalidKey
+		return
+	}
 
 	// QPS and Concurrency Limit
 	rateLimit := o.AcquireRateLimiter()
@@ -775,6 +824,15 @@ func (o *ObjectNode) completeMultipartUploadHandler(w http.ResponseWriter, r *ht
 		return
 	}
 
+	setVersionIdHeader(w, vol, fsFileInfo.VersionId)
+	fsFileInfo.Encryption.SetResponseHeader(w)
+	if err = vol.enqueueReplication(r, proto.ReplicationOpPut, param.Object(), fsFileInfo.VersionId, fsFileInfo.Inode); err != nil {
+		return
+	}
+	o.notifyObjectEvent(param, vol, EventObjectCreatedCompleteMultipartUpload, NotificationObject{
+		Key: param.Object(), Size: fsFileInfo.Size, ETag: fsFileInfo.ETag, VersionID: fsFileInfo.VersionId,
+	})
+
 	completeResult := CompleteMultipartResult{
 		Bucket: param.Bucket(),
 		Key:    param.Object(),
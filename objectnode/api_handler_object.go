@@ -154,17 +154,32 @@ func (o *ObjectNode) getObjectHandler(w http.ResponseWriter, r *http.Request) {
 	responseContentDisposition := r.URL.Query().Get(ParamResponseContentDisposition)
 
 	// get object meta
+	versionId := r.URL.Query().Get(ParamVersionId)
 	start := time.Now()
-	fileInfo, xattr, err := vol.ObjectMeta(param.Object())
+	fileInfo, xattr, err := vol.ObjectVersionMeta(param.Object(), versionId)
 	span.AppendTrackLog("meta.r", start, err)
 	if err != nil {
-		log.LogErrorf("getObjectHandler: get file meta fail: requestId(%v) volume(%v) path(%v) err(%v)",
-			GetRequestID(r), vol.Name(), param.Object(), err)
+		log.LogErrorf("getObjectHandler: get file meta fail: requestId(%v) volume(%v) path(%v) versionId(%v) err(%v)",
+			GetRequestID(r), vol.Name(), param.Object(), versionId, err)
 		if err == syscall.ENOENT {
-			errorCode = NoSuchKey
+			errorCode = noSuchObjectError(versionId)
 		}
 		return
 	}
+	if errorCode = checkDeleteMarker(w, fileInfo); errorCode != nil {
+		return
+	}
+	setVersionIdHeader(w, vol, fileInfo.VersionId)
+
+	// encrypted object can only be read with the key it is encrypted by
+	if err = o.unsealObjectEncryption(r, fileInfo.Encryption, false); err != nil {
+		log.LogErrorf("getObjectHandler: unseal encryption fail: requestId(%v) volume(%v) path(%v) err(%v)",
+			GetRequestID(r), vol.Name(), param.Object(), err)
+		return
+	}
+	fileInfo.Encryption.SetResponseHeader(w)
+	setReplicationStatusHeader(w, fileInfo.ReplicationStatus)
+	setRestoreHeader(w, fileInfo.RestoreStatus)
 
 	// header condition check
 	errorCode = CheckConditionInHeader(r, fileInfo)
@@ -287,10 +302,15 @@ func (o *ObjectNode) getObjectHandler(w http.ResponseWriter, r *http.Request) {
 	} else {
 		writer = w
 	}
+	if fileInfo.Encryption != nil {
+		writer = fileInfo.Encryption.DecryptWriter(writer, offset)
+	}
 
 	// read file
 	start = time.Now()
-	err = vol.readFile(fileInfo.Inode, fileSize, param.Object(), writer, offset, size, fileInfo.StorageClass)
+	// objects in BlobStore are read from the restored copy if any
+	readIno, readStorageClass := fileInfo.readSource(time.Now())
+	err = vol.readFile(readIno, fileSize, param.Object(), writer, offset, size, readStorageClass)
 	span.AppendTrackLog("file.r", start, err)
 	if err != nil {
 		log.LogErrorf("getObjectHandler: read file fail: requestID(%v) volume(%v) path(%v) offset(%v) size(%v) err(%v)",
@@ -397,17 +417,32 @@ func (o *ObjectNode) headObjectHandler(w http.ResponseWriter, r *http.Request) {
 	defer rateLimit.ReleaseLimitResource(vol.owner, param.apiName)
 
 	// get object meta
+	versionId := r.URL.Query().Get(ParamVersionId)
 	start := time.Now()
-	fileInfo, _, err := vol.ObjectMeta(param.Object())
+	fileInfo, _, err := vol.ObjectVersionMeta(param.Object(), versionId)
 	span.AppendTrackLog("meta.r", start, err)
 	if err != nil {
-		log.LogErrorf("headObjectHandler: get file meta fail: requestId(%v) volume(%v) path(%v) err(%v)",
-			GetRequestID(r), vol.Name(), param.Object(), err)
+		log.LogErrorf("headObjectHandler: get file meta fail: requestId(%v) volume(%v) path(%v) versionId(%v) err(%v)",
+			GetRequestID(r), vol.Name(), param.Object(), versionId, err)
 		if err == syscall.ENOENT {
-			errorCode = NoSuchKey
+			errorCode = noSuchObjectError(versionId)
 		}
 		return
 	}
+	if errorCode = checkDeleteMarker(w, fileInfo); errorCode != nil {
+		return
+	}
+	setVersionIdHeader(w, vol, fileInfo.VersionId)
+
+	// encrypted object can only be read with the key it is encrypted by
+	if err = o.unsealObjectEncryption(r, fileInfo.Encryption, false); err != nil {
+		log.LogErrorf("headObjectHandler: unseal encryption fail: requestId(%v) volume(%v) path(%v) err(%v)",
+			GetRequestID(r), vol.Name(), param.Object(), err)
+		return
+	}
+	fileInfo.Encryption.SetResponseHeader(w)
+	setReplicationStatusHeader(w, fileInfo.ReplicationStatus)
+	setRestoreHeader(w, fileInfo.RestoreStatus)
 
 	// parse request header
 	match := r.Header.Get(IfMatch)
@@ -614,6 +649,16 @@ func (o *ObjectNode) deleteObjectsHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	pab, err := o.publicAccessBlock(vol)
+	if err != nil {
+		log.LogErrorf("deleteObjectsHandler: load public access block fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+	if pab.ignorePublicAcls() {
+		acl = acl.withoutPublicGrants()
+	}
+
 	allowByAcl := false
 	if acl == nil && userInfo.UserID == vol.owner {
 		allowByAcl = true
@@ -635,6 +680,7 @@ func (o *ObjectNode) deleteObjectsHandler(w http.ResponseWriter, r *http.Request
 				HOST:     param.r.Host,
 			}
 			result = policy.IsAllowed(param, userInfo.UserID, vol.owner, conditionCheck)
+			result = pab.restrictPolicyResult(policy, result, userInfo.UserID, vol.owner)
 		}
 		if result == POLICY_DENY || (result == POLICY_UNKNOW && !allowByAcl) {
 			deletedErrors = append(deletedErrors, Error{
@@ -651,16 +697,22 @@ func (o *ObjectNode) deleteObjectsHandler(w http.ResponseWriter, r *http.Request
 		if err = rateLimit.AcquireLimitResource(vol.owner, DELETE_OBJECT); err != nil {
 			return
 		}
-		if err1 := vol.DeletePath(object.Key); err1 != nil {
-			log.LogErrorf("deleteObjectsHandler: delete object failed: requestID(%v) volume(%v) path(%v) err(%v)",
-				GetRequestID(r), vol.Name(), object.Key, err1)
+		if deleted, err1 := vol.DeleteObject(object.Key, object.VersionId); err1 != nil {
+			log.LogErrorf("deleteObjectsHandler: delete object failed: requestID(%v) volume(%v) path(%v) versionId(%v) err(%v)",
+				GetRequestID(r), vol.Name(), object.Key, object.VersionId, err1)
 			if !strings.Contains(err1.Error(), AccessDenied.ErrorMessage) {
-				deletedErrors = append(deletedErrors, Error{Key: object.Key, Code: "InternalError", Message: err1.Error()})
+				deletedErrors = append(deletedErrors, Error{Key: object.Key, VersionId: object.VersionId,
+					Code: "InternalError", Message: err1.Error()})
 			} else {
-				deletedErrors = append(deletedErrors, Error{Key: object.Key, Code: "AccessDenied", Message: err1.Error()})
+				deletedErrors = append(deletedErrors, Error{Key: object.Key, VersionId: object.VersionId,
+					Code: "AccessDenied", Message: err1.Error()})
 			}
+		} else if err1 = vol.enqueueDeleteReplication(r, object.Key, object.VersionId, deleted); err1 != nil {
+			deletedErrors = append(deletedErrors, Error{Key: object.Key, VersionId: object.VersionId,
+				Code: "InternalError", Message: err1.Error()})
 		} else {
-			deletedObjects = append(deletedObjects, Deleted{Key: object.Key})
+			deletedObjects = append(deletedObjects, NewDeleted(object.Key, object.VersionId, deleted))
+			o.notifyObjectRemoved(param, vol, object.Key, object.VersionId, deleted)
 		}
 		rateLimit.ReleaseLimitResource(vol.owner, param.apiName)
 	}
@@ -736,6 +788,10 @@ func (o *ObjectNode) copyObjectHandler(w http.ResponseWriter, r *http.Request) {
 			GetRequestID(r), param.Bucket(), err)
 		return
 	}
+	if vol.isReservedObjectKey(param.Object()) {
+		errorCode = InvalidKey
+		return
+	}
 
 	// QPS and Concurrency Limit
 	rateLimit := o.AcquireRateLimiter()
@@ -771,7 +827,7 @@ func (o *ObjectNode) copyObjectHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// parse x-amz-copy-source header
-	sourceBucket, sourceObject, _, err := extractSrcBucketKey(r)
+	sourceBucket, sourceObject, sourceVersionId, err := extractSrcBucketKey(r)
 	if err != nil {
 		log.LogErrorf("copyObjectHandler: copySource(%v) argument invalid: requestID(%v) volume(%v) err(%v)",
 			r.Header.Get(XAmzCopySource), GetRequestID(r), param.Bucket(), err)
@@ -791,6 +847,11 @@ func (o *ObjectNode) copyObjectHandler(w http.ResponseWriter, r *http.Request) {
 			GetRequestID(r), param.Bucket(), acl, err)
 		return
 	}
+	if err = o.checkPublicAcl(vol, acl); err != nil {
+		log.LogErrorf("copyObjectHandler: public acl blocked: requestID(%v) volume(%v) acl(%+v) err(%v)",
+			GetRequestID(r), param.Bucket(), acl, err)
+		return
+	}
 
 	// get src object meta
 	var sourceVol *Volume
@@ -802,16 +863,32 @@ func (o *ObjectNode) copyObjectHandler(w http.ResponseWriter, r *http.Request) {
 
 	// get object meta
 	start := time.Now()
-	fileInfo, _, err := sourceVol.ObjectMeta(sourceObject)
+	fileInfo, _, err := sourceVol.ObjectVersionMeta(sourceObject, sourceVersionId)
 	span.AppendTrackLog("meta.r", start, err)
 	if err != nil {
-		log.LogErrorf("copyObjectHandler: get object meta fail: requestID(%v) srcVolume(%v) srcObject(%v) err(%v)",
-			GetRequestID(r), sourceBucket, sourceObject, err)
+		log.LogErrorf("copyObjectHandler: get object meta fail: requestID(%v) srcVolume(%v) srcObject(%v) srcVersionId(%v) err(%v)",
+			GetRequestID(r), sourceBucket, sourceObject, sourceVersionId, err)
 		if err == syscall.ENOENT {
-			errorCode = NoSuchKey
+			errorCode = noSuchObjectError(sourceVersionId)
 		}
 		return
 	}
+	if fileInfo.DeleteMarker {
+		errorCode = CopySourceDeleteMarker
+		return
+	}
+	// the source object is decrypted by the copy source SSE-C key or the master key
+	if err = o.unsealObjectEncryption(r, fileInfo.Encryption, true); err != nil {
+		log.LogErrorf("copyObjectHandler: unseal source encryption fail: requestID(%v) srcVolume(%v) srcObject(%v) err(%v)",
+			GetRequestID(r), sourceBucket, sourceObject, err)
+		return
+	}
+	var encryption *ObjectEncryption
+	if encryption, err = o.newObjectEncryption(r, vol); err != nil {
+		log.LogErrorf("copyObjectHandler: new encryption fail: requestID(%v) volume(%v) path(%v) err(%v)",
+			GetRequestID(r), vol.Name(), param.Object(), err)
+		return
+	}
 	if fileInfo.Size > SinglePutLimit {
 		errorCode = EntityTooLarge
 		return
@@ -880,13 +957,16 @@ func (o *ObjectNode) copyObjectHandler(w http.ResponseWriter, r *http.Request) {
 
 	// copy file
 	opt := &PutFileOption{
-		MIMEType:     contentType,
-		Disposition:  contentDisposition,
-		Metadata:     metadata,
-		CacheControl: cacheControl,
-		Expires:      expires,
-		ACL:          acl,
-		ObjectLock:   objetLock,
+		MIMEType:         contentType,
+		Disposition:      contentDisposition,
+		Metadata:         metadata,
+		CacheControl:     cacheControl,
+		Expires:          expires,
+		ACL:              acl,
+		ObjectLock:       objetLock,
+		SourceVersionId:  sourceVersionId,
+		Encryption:       encryption,
+		SourceEncryption: fileInfo.Encryption,
 	}
 	start = time.Now()
 	fsFileInfo, err := vol.CopyFile(sourceVol, sourceObject, param.Object(), metadataDirective, opt)
@@ -909,6 +989,18 @@ func (o *ObjectNode) copyObjectHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if sourceVersionId != "" {
+		w.Header().Set(XAmzCopySourceVersionId, sourceVersionId)
+	}
+	setVersionIdHeader(w, vol, fsFileInfo.VersionId)
+	fsFileInfo.Encryption.SetResponseHeader(w)
+	if err = vol.enqueueReplication(r, proto.ReplicationOpPut, param.Object(), fsFileInfo.VersionId, fsFileInfo.Inode); err != nil {
+		return
+	}
+	o.notifyObjectEvent(param, vol, EventObjectCreatedCopy, NotificationObject{
+		Key: param.Object(), Size: fsFileInfo.Size, ETag: fsFileInfo.ETag, VersionID: fsFileInfo.VersionId,
+	})
+
 	copyResult := CopyResult{
 		ETag:         "\"" + fsFileInfo.ETag + "\"",
 		LastModified: formatTimeISO(fsFileInfo.ModifyTime),
@@ -1261,6 +1353,10 @@ func (o *ObjectNode) putObjectHandler(w http.ResponseWriter, r *http.Request) {
 			GetRequestID(r), param.Bucket(), err)
 		return
 	}
+	if vol.isReservedObjectKey(param.Object()) {
+		errorCode = InvalidKey
+		return
+	}
 
 	// Get request MD5, if request MD5 is not empty, compute and verify it.
 	requestMD5 := r.Header.Get(ContentMD5)
@@ -1321,6 +1417,11 @@ func (o *ObjectNode) putObjectHandler(w http.ResponseWriter, r *http.Request) {
 			GetRequestID(r), vol.Name(), param.Object(), acl, err)
 		return
 	}
+	if err = o.checkPublicAcl(vol, acl); err != nil {
+		log.LogErrorf("putObjectHandler: public acl blocked: requestID(%v) volume(%v) path(%v) acl(%+v) err(%v)",
+			GetRequestID(r), vol.Name(), param.Object(), acl, err)
+		return
+	}
 
 	// Verify ContentLength
 	length := GetContentLength(r)
@@ -1353,6 +1454,13 @@ func (o *ObjectNode) putObjectHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	// Checking user-defined metadata
 	metadata := ParseUserDefinedMetadata(r.Header)
+	// Server-side encryption
+	var encryption *ObjectEncryption
+	if encryption, err = o.newObjectEncryption(r, vol); err != nil {
+		log.LogErrorf("putObjectHandler: new encryption fail: requestID(%v) volume(%v) path(%v) err(%v)",
+			GetRequestID(r), vol.Name(), param.Object(), err)
+		return
+	}
 	// Audit file write
 	log.LogInfof("Audit: put object: requestID(%v) remote(%v) volume(%v) path(%v) type(%v)",
 		GetRequestID(r), getRequestIP(r), vol.Name(), param.Object(), contentType)
@@ -1375,6 +1483,10 @@ func (o *ObjectNode) putObjectHandler(w http.ResponseWriter, r *http.Request) {
 		Expires:      expires,
 		ACL:          acl,
 		ObjectLock:   objetLock,
+		Encryption:   encryption,
+	}
+	if isReplicaRequest(r) {
+		opt.ReplicationStatus = proto.ReplicationStatusReplica
 	}
 	start := time.Now()
 	fsFileInfo, err := vol.PutObject(param.Object(), reader, opt)
@@ -1396,6 +1508,14 @@ func (o *ObjectNode) putObjectHandler(w http.ResponseWriter, r *http.Request) {
 
 	// set response header
 	w.Header()[ETag] = []string{wrapUnescapedQuot(fsFileInfo.ETag)}
+	setVersionIdHeader(w, vol, fsFileInfo.VersionId)
+	fsFileInfo.Encryption.SetResponseHeader(w)
+	if err = vol.enqueueReplication(r, proto.ReplicationOpPut, param.Object(), fsFileInfo.VersionId, fsFileInfo.Inode); err != nil {
+		return
+	}
+	o.notifyObjectEvent(param, vol, EventObjectCreatedPut, NotificationObject{
+		Key: param.Object(), Size: fsFileInfo.Size, ETag: fsFileInfo.ETag, VersionID: fsFileInfo.VersionId,
+	})
 }
 
 // Post object
@@ -1486,6 +1606,10 @@ func (o *ObjectNode) postObjectHandler(w http.ResponseWriter, r *http.Request) {
 		errorCode.ErrorMessage = fmt.Sprintf("%s (%s)", errorCode.ErrorMessage, "Invalid utf8 string or the key is too long")
 		return
 	}
+	if vol.isReservedObjectKey(key) {
+		errorCode = InvalidKey
+		return
+	}
 
 	var aclInfo *AccessControlPolicy
 	if acl := formReq.MultipartFormValue("acl"); acl != "" {
@@ -1496,6 +1620,11 @@ func (o *ObjectNode) postObjectHandler(w http.ResponseWriter, r *http.Request) {
 			errorCode.ErrorMessage = fmt.Sprintf("%s (%v)", errorCode.ErrorMessage, err)
 			return
 		}
+		if err = o.checkPublicAcl(vol, aclInfo); err != nil {
+			log.LogErrorf("postObjectHandler: public acl blocked: requestID(%v) volume(%v) acl(%v) err(%v)",
+				GetRequestID(r), param.Bucket(), acl, err)
+			return
+		}
 	}
 
 	var tagging *Tagging
@@ -1585,6 +1714,19 @@ func (o *ObjectNode) postObjectHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// server-side encryption specified by form field or bucket default, SSE-C is not supported by form
+	sseRequest := &SSERequest{Algorithm: forms[strings.ToLower(XAmzServerSideEncryption)]}
+	if sseRequest.Algorithm != "" && sseRequest.Algorithm != SSEAlgorithmAES256 {
+		errorCode = InvalidEncryptionAlgorithm
+		return
+	}
+	var encryption *ObjectEncryption
+	if encryption, err = o.newObjectEncryptionByRequest(sseRequest, vol); err != nil {
+		log.LogErrorf("postObjectHandler: new encryption fail: requestID(%v) volume(%v) path(%v) err(%v)",
+			GetRequestID(r), vol.Name(), key, err)
+		return
+	}
+
 	// flow control
 	var reader io.Reader
 	if size > DefaultFlowLimitSize {
@@ -1603,6 +1745,7 @@ func (o *ObjectNode) postObjectHandler(w http.ResponseWriter, r *http.Request) {
 		Expires:      expires,
 		ACL:          aclInfo,
 		ObjectLock:   objetLock,
+		Encryption:   encryption,
 	}
 	start := time.Now()
 	fsFileInfo, err := vol.PutObject(key, reader, putOpt)
@@ -1625,6 +1768,14 @@ func (o *ObjectNode) postObjectHandler(w http.ResponseWriter, r *http.Request) {
 	// set response header
 	etag := wrapUnescapedQuot(fsFileInfo.ETag)
 	w.Header()[ETag] = []string{etag}
+	setVersionIdHeader(w, vol, fsFileInfo.VersionId)
+	fsFileInfo.Encryption.SetResponseHeader(w)
+	if err = vol.enqueueReplication(r, proto.ReplicationOpPut, key, fsFileInfo.VersionId, fsFileInfo.Inode); err != nil {
+		return
+	}
+	o.notifyObjectEvent(param, vol, EventObjectCreatedPost, NotificationObject{
+		Key: key, Size: fsFileInfo.Size, ETag: fsFileInfo.ETag, VersionID: fsFileInfo.VersionId,
+	})
 
 	// return response depending on success_action_xxx parameter
 	if successRedirectURL != nil {
@@ -1703,21 +1854,32 @@ func (o *ObjectNode) deleteObjectHandler(w http.ResponseWriter, r *http.Request)
 	defer rateLimit.ReleaseLimitResource(vol.owner, param.apiName)
 
 	// Audit deletion
-	log.LogInfof("Audit: delete object: requestID(%v) remote(%v) volume(%v) path(%v)",
-		GetRequestID(r), getRequestIP(r), vol.Name(), param.Object())
+	versionId := r.URL.Query().Get(ParamVersionId)
+	log.LogInfof("Audit: delete object: requestID(%v) remote(%v) volume(%v) path(%v) versionId(%v)",
+		GetRequestID(r), getRequestIP(r), vol.Name(), param.Object(), versionId)
 
 	// Delete file
 	start := time.Now()
-	err = vol.DeletePath(param.Object())
+	result, err := vol.DeleteObject(param.Object(), versionId)
 	span.AppendTrackLog("file.d", start, err)
 	if err != nil {
 		log.LogErrorf("deleteObjectHandler: Volume delete file fail: "+
-			"requestID(%v) volume(%v) path(%v) err(%v)", GetRequestID(r), vol.Name(), param.Object(), err)
+			"requestID(%v) volume(%v) path(%v) versionId(%v) err(%v)", GetRequestID(r), vol.Name(), param.Object(), versionId, err)
 		if strings.Contains(err.Error(), AccessDenied.ErrorMessage) {
 			err = AccessDenied
 		}
 		return
 	}
+	if result.DeleteMarker {
+		w.Header().Set(XAmzDeleteMarker, "true")
+	}
+	if result.VersionId != "" {
+		w.Header().Set(XAmzVersionId, result.VersionId)
+	}
+	if err = vol.enqueueDeleteReplication(r, param.Object(), versionId, result); err != nil {
+		return
+	}
+	o.notifyObjectRemoved(param, vol, param.Object(), versionId, result)
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -1856,6 +2018,9 @@ func (o *ObjectNode) putObjectTaggingHandler(w http.ResponseWriter, r *http.Requ
 		}
 		return
 	}
+	if err = vol.enqueueReplication(r, proto.ReplicationOpTagging, param.Object(), "", 0); err != nil {
+		return
+	}
 }
 
 // Delete object tagging
@@ -1905,6 +2070,9 @@ func (o *ObjectNode) deleteObjectTaggingHandler(w http.ResponseWriter, r *http.R
 		}
 		return
 	}
+	if err = vol.enqueueReplication(r, proto.ReplicationOpTagging, param.Object(), "", 0); err != nil {
+		return
+	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -165,6 +165,7 @@ func (o *ObjectNode) authMiddleware(next http.Handler) http.Handler {
 			auth, err := NewAuth(r)
 			if err != nil && err == MissingSecurityElement {
 				// anonymous request will be authed in policy and acl check step
+				o.checkReplicaRequest(r, "")
 				next.ServeHTTP(w, r)
 				return
 			}
@@ -179,6 +180,7 @@ func (o *ObjectNode) authMiddleware(next http.Handler) http.Handler {
 				o.errorResponse(w, r, err, nil)
 				return
 			}
+			o.checkReplicaRequest(r, auth.Credential().AccessKey)
 
 			next.ServeHTTP(w, r)
 		})
@@ -14,7 +14,11 @@
 
 package objectnode
 
-import "os"
+import (
+	"os"
+
+	"github.com/cubefs/cubefs/proto"
+)
 
 const (
 	MaxRetry = 3
@@ -38,6 +42,7 @@ const (
 	Range              = "Range"
 	Expect             = "Expect"
 	XForwardedExpect   = "X-Forwarded-Expect"
+	XForwardedProto    = "X-Forwarded-Proto"
 	Location           = "Location"
 	CacheControl       = "Cache-Control"
 	Expires            = "Expires"
@@ -85,6 +90,19 @@ const (
 	XAmzSecurityToken               = "X-Amz-Security-Token" // #nosec G101
 	XAmzObjectLockMode              = "X-Amz-Object-Lock-Mode"
 	XAmzObjectLockRetainUntilDate   = "X-Amz-Object-Lock-Retain-Until-Date"
+	XAmzVersionId                   = "x-amz-version-id"
+	XAmzDeleteMarker                = "x-amz-delete-marker"
+	XAmzCopySourceVersionId         = "x-amz-copy-source-version-id"
+	XAmzReplicationStatus           = "x-amz-replication-status"
+	XAmzRestore                     = "x-amz-restore"
+
+	XAmzServerSideEncryption           = "x-amz-server-side-encryption"
+	XAmzSSECustomerAlgorithm           = "x-amz-server-side-encryption-customer-algorithm"
+	XAmzSSECustomerKey                 = "x-amz-server-side-encryption-customer-key"
+	XAmzSSECustomerKeyMD5              = "x-amz-server-side-encryption-customer-key-MD5"
+	XAmzCopySourceSSECustomerAlgorithm = "x-amz-copy-source-server-side-encryption-customer-algorithm"
+	XAmzCopySourceSSECustomerKey       = "x-amz-copy-source-server-side-encryption-customer-key"
+	XAmzCopySourceSSECustomerKeyMD5    = "x-amz-copy-source-server-side-encryption-customer-key-MD5"
 
 	HeaderNameXAmzDecodedContentLength = "x-amz-decoded-content-length"
 )
@@ -95,6 +113,7 @@ const (
 	ValueContentTypeStream    = "application/octet-stream"
 	ValueContentTypeXML       = "application/xml"
 	ValueContentTypeJSON      = "application/json"
+	ValueContentTypeHTML      = "text/html; charset=utf-8"
 	ValueContentTypeDirectory = "application/directory"
 	ValueMultipartFormData    = "multipart/form-data"
 )
@@ -115,6 +134,9 @@ const (
 	ParamMaxKeys    = "max-keys"
 	ParamStartAfter = "start-after"
 	ParamKey        = "key"
+	ParamVersionId  = "versionId"
+
+	ParamVersionIdMarker = "version-id-marker"
 
 	ParamMaxParts       = "max-parts"
 	ParamUploadIdMarker = "upload-id-marker"
@@ -143,20 +165,30 @@ const (
 
 // XAttr keys for ObjectNode compatible feature
 const (
-	XAttrKeyOSSPrefix       = "oss:"
-	XAttrKeyOSSETag         = "oss:etag"
-	XAttrKeyOSSTagging      = "oss:tagging"
-	XAttrKeyOSSPolicy       = "oss:policy"
-	XAttrKeyOSSACL          = "oss:acl"
-	XAttrKeyOSSMIME         = "oss:mime"
-	XAttrKeyOSSDISPOSITION  = "oss:disposition"
-	XAttrKeyOSSCORS         = "oss:cors"
-	XAttrKeyOSSLock         = "oss:lock"
-	XAttrKeyOSSCacheControl = "oss:cache"
-	XAttrKeyOSSExpires      = "oss:expires"
+	XAttrKeyOSSPrefix       = proto.XAttrKeyOSSPrefix
+	XAttrKeyOSSETag         = proto.XAttrKeyOSSETag
+	XAttrKeyOSSTagging      = proto.XAttrKeyOSSTagging
+	XAttrKeyOSSPolicy       = proto.XAttrKeyOSSPolicy
+	XAttrKeyOSSACL          = proto.XAttrKeyOSSACL
+	XAttrKeyOSSMIME         = proto.XAttrKeyOSSMIME
+	XAttrKeyOSSDISPOSITION  = proto.XAttrKeyOSSDISPOSITION
+	XAttrKeyOSSCORS         = proto.XAttrKeyOSSCORS
+	XAttrKeyOSSLock         = proto.XAttrKeyOSSLock
+	XAttrKeyOSSCacheControl = proto.XAttrKeyOSSCacheControl
+	XAttrKeyOSSExpires      = proto.XAttrKeyOSSExpires
+	XAttrKeyOSSVersioning   = proto.XAttrKeyOSSVersioning
+	XAttrKeyOSSVersionId    = proto.XAttrKeyOSSVersionId
+	XAttrKeyOSSDeleteMarker = proto.XAttrKeyOSSDeleteMarker
+	XAttrKeyOSSEncryption   = proto.XAttrKeyOSSEncryption
+	XAttrKeyOSSSSE          = proto.XAttrKeyOSSSSE
+	XAttrKeyOSSSSEParts     = proto.XAttrKeyOSSSSEParts
+	XAttrKeyOSSSSEPartIV    = proto.XAttrKeyOSSSSEPartIV
+	XAttrKeyOSSNotification = proto.XAttrKeyOSSNotification
+	XAttrKeyOSSWebsite      = proto.XAttrKeyOSSWebsite
+	XAttrKeyOSSPublicAccess = proto.XAttrKeyOSSPublicAccess
 
 	// Deprecated
-	XAttrKeyOSSETagDeprecated = "oss:tag"
+	XAttrKeyOSSETagDeprecated = proto.XAttrKeyOSSETagDeprecated
 )
 
 const (
@@ -18,6 +18,8 @@ import (
 	"os"
 	"sort"
 	"time"
+
+	"github.com/cubefs/cubefs/proto"
 )
 
 type FSFileInfo struct {
@@ -35,6 +37,13 @@ type FSFileInfo struct {
 	Metadata        map[string]string `graphql:"-"` // User-defined metadata
 	RetainUntilDate string
 	StorageClass    uint32
+	VersionId       string
+	DeleteMarker    bool
+	Encryption      *ObjectEncryption `graphql:"-"`
+	// replication status of the object, empty if not replicated
+	ReplicationStatus string
+	// restore status of the object in BlobStore, nil if never restored
+	RestoreStatus *proto.RestoreStatus `graphql:"-"`
 }
 
 type Prefixes []string
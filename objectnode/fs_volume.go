@@ -92,6 +92,14 @@ type PutFileOption struct {
 	CacheControl string
 	Expires      string
 	ObjectLock   *ObjectLockConfig
+	// version of the source object, only used by CopyFile
+	SourceVersionId string
+	// encryption of the object to be written, nil if not encrypted
+	Encryption *ObjectEncryption
+	// unsealed encryption of the source object, only used by CopyFile
+	SourceEncryption *ObjectEncryption
+	// replication status of the object, only REPLICA is specified by request
+	ReplicationStatus string
 }
 
 type ListFilesV1Option struct {
@@ -211,6 +219,42 @@ func (v *Volume) loadOSSMeta() {
 		return
 	}
 	v.metaLoader.storeObjectLock(objectlock)
+
+	var versioning *VersioningConfiguration
+	if versioning, err = v.loadBucketVersioning(); err != nil {
+		return
+	}
+	v.metaLoader.storeVersioning(versioning)
+
+	var encryption *ServerSideEncryptionConfiguration
+	if encryption, err = v.loadBucketEncryption(); err != nil {
+		return
+	}
+	v.metaLoader.storeEncryption(encryption)
+
+	var replication *proto.ReplicationConfiguration
+	if replication, err = v.loadBucketReplication(); err != nil {
+		return
+	}
+	v.metaLoader.storeReplication(replication)
+
+	var notification *NotificationConfiguration
+	if notification, err = v.loadBucketNotification(); err != nil {
+		return
+	}
+	v.metaLoader.storeNotification(notification)
+
+	var website *WebsiteConfiguration
+	if website, err = v.loadBucketWebsite(); err != nil {
+		return
+	}
+	v.metaLoader.storeWebsite(website)
+
+	var publicAccess *PublicAccessBlockConfiguration
+	if publicAccess, err = v.loadBucketPublicAccess(); err != nil {
+		return
+	}
+	v.metaLoader.storePublicAccess(publicAccess)
 	v.metaLoader.setSynced()
 }
 
@@ -290,6 +334,96 @@ func (v *Volume) loadObjectLock() (configuration *ObjectLockConfig, err error) {
 	return configuration, nil
 }
 
+func (v *Volume) loadBucketVersioning() (configuration *VersioningConfiguration, err error) {
+	var raw []byte
+	if raw, err = v.store.Get(v.name, bucketRootPath, XAttrKeyOSSVersioning); err != nil {
+		return
+	}
+	if len(raw) == 0 {
+		return
+	}
+	configuration = &VersioningConfiguration{}
+	if err = json.Unmarshal(raw, configuration); err != nil {
+		return
+	}
+	return configuration, nil
+}
+
+func (v *Volume) loadBucketEncryption() (configuration *ServerSideEncryptionConfiguration, err error) {
+	var raw []byte
+	if raw, err = v.store.Get(v.name, bucketRootPath, XAttrKeyOSSEncryption); err != nil {
+		return
+	}
+	if len(raw) == 0 {
+		return
+	}
+	configuration = &ServerSideEncryptionConfiguration{}
+	if err = json.Unmarshal(raw, configuration); err != nil {
+		return
+	}
+	return configuration, nil
+}
+
+func (v *Volume) loadBucketReplication() (configuration *proto.ReplicationConfiguration, err error) {
+	var raw []byte
+	if raw, err = v.store.Get(v.name, bucketRootPath, proto.XAttrKeyReplicationConfig); err != nil {
+		return
+	}
+	if len(raw) == 0 {
+		return
+	}
+	configuration = &proto.ReplicationConfiguration{}
+	if err = json.Unmarshal(raw, configuration); err != nil {
+		return
+	}
+	return configuration, nil
+}
+
+func (v *Volume) loadBucketNotification() (configuration *NotificationConfiguration, err error) {
+	var raw []byte
+	if raw, err = v.store.Get(v.name, bucketRootPath, XAttrKeyOSSNotification); err != nil {
+		return
+	}
+	if len(raw) == 0 {
+		return
+	}
+	configuration = &NotificationConfiguration{}
+	if err = json.Unmarshal(raw, configuration); err != nil {
+		return
+	}
+	return configuration, nil
+}
+
+func (v *Volume) loadBucketWebsite() (configuration *WebsiteConfiguration, err error) {
+	var raw []byte
+	if raw, err = v.store.Get(v.name, bucketRootPath, XAttrKeyOSSWebsite); err != nil {
+		return
+	}
+	if len(raw) == 0 {
+		return
+	}
+	configuration = &WebsiteConfiguration{}
+	if err = json.Unmarshal(raw, configuration); err != nil {
+		return
+	}
+	return configuration, nil
+}
+
+func (v *Volume) loadBucketPublicAccess() (configuration *PublicAccessBlockConfiguration, err error) {
+	var raw []byte
+	if raw, err = v.store.Get(v.name, bucketRootPath, XAttrKeyOSSPublicAccess); err != nil {
+		return
+	}
+	if len(raw) == 0 {
+		return
+	}
+	configuration = &PublicAccessBlockConfiguration{}
+	if err = json.Unmarshal(raw, configuration); err != nil {
+		return
+	}
+	return configuration, nil
+}
+
 func (v *Volume) getInodeFromPath(path string) (inode uint64, err error) {
 	if path == "/" {
 		return volumeRootInode, nil
@@ -729,14 +863,19 @@ func (v *Volume) PutObject(path string, reader io.Reader, opt *PutFileOption) (f
 		}
 	}()
 
+	var encryption *ObjectEncryption
+	if opt != nil {
+		encryption = opt.Encryption
+	}
+	writeReader, writeHash := encryptWriteSource(encryption, reader, md5Hash, 0)
 	if proto.IsCold(v.volType) || proto.IsStorageClassBlobStore(invisibleTempDataInode.StorageClass) {
-		if _, err = v.ebsWrite(invisibleTempDataInode.Inode, reader, md5Hash, invisibleTempDataInode.StorageClass); err != nil {
+		if _, err = v.ebsWrite(invisibleTempDataInode.Inode, writeReader, writeHash, invisibleTempDataInode.StorageClass); err != nil {
 			log.LogErrorf("PutObject: ebs write fail: volume(%v) path(%v) inode(%v) err(%v)",
 				v.name, path, invisibleTempDataInode.Inode, err)
 			return
 		}
 	} else {
-		if _, err = v.streamWrite(invisibleTempDataInode.Inode, reader, md5Hash, invisibleTempDataInode.StorageClass); err != nil {
+		if _, err = v.streamWrite(invisibleTempDataInode.Inode, writeReader, writeHash, invisibleTempDataInode.StorageClass, 0); err != nil {
 			log.LogErrorf("PutObject: stream write fail: volume(%v) path(%v) inode(%v) err(%v)",
 				v.name, path, invisibleTempDataInode.Inode, err)
 			return
@@ -791,6 +930,16 @@ func (v *Volume) PutObject(path string, reader io.Reader, opt *PutFileOption) (f
 	if opt != nil && opt.ObjectLock != nil && opt.ObjectLock.ToRetention() != nil {
 		attr.XAttrs[XAttrKeyOSSLock] = formatRetentionDateStr(finalInode.ModifyTime, opt.ObjectLock.ToRetention())
 	}
+	if encryption != nil {
+		attr.XAttrs[XAttrKeyOSSSSE] = encryption.Encode()
+	}
+	if opt != nil && len(opt.ReplicationStatus) > 0 {
+		attr.XAttrs[proto.XAttrKeyReplicationStatus] = opt.ReplicationStatus
+	}
+	versionId := v.newObjectVersionId()
+	if versionId != "" {
+		attr.XAttrs[XAttrKeyOSSVersionId] = versionId
+	}
 
 	// If user-defined metadata have been specified, use extend attributes for storage.
 	if opt != nil && len(opt.Metadata) > 0 {
@@ -818,6 +967,8 @@ func (v *Volume) PutObject(path string, reader io.Reader, opt *PutFileOption) (f
 		ModifyTime: finalInode.ModifyTime,
 		ETag:       etagValue.ETag(),
 		Inode:      finalInode.Inode,
+		VersionId:  versionId,
+		Encryption: encryption,
 	}
 
 	// apply new inode to dentry
@@ -860,8 +1011,8 @@ func (v *Volume) applyInodeToDEntry(parentId uint64, name string, inode uint64,
 			err = syscall.EINVAL
 			return
 		}
-		// current implementation doesn't support object versioning, so uploading a object with a key already existed in bucket
-		// is implemented with replacing the old one instead.
+		// uploading a object with a key already existed in bucket is implemented with replacing the old one,
+		// the old one is kept as a noncurrent version if versioning is active.
 		// refer: https://docs.aws.amazon.com/AmazonS3/latest/userguide/upload-objects.html
 		if err = v.applyInodeToExistDentry(parentId, name, inode, isCompleteMultipart, fullPath, storageClass); err != nil {
 			log.LogErrorf("applyInodeToDEntry: apply inode to exist dentry fail: parentID(%v) name(%v) inode(%v) err(%v)",
@@ -982,6 +1133,10 @@ func (v *Volume) InitMultipart(path string, opt *PutFileOption) (multipartID str
 	if opt != nil && opt.ACL != nil {
 		extend[XAttrKeyOSSACL] = opt.ACL.Encode()
 	}
+	// If encryption have been specified, all parts are encrypted by the same data key.
+	if opt != nil && opt.Encryption != nil {
+		extend[XAttrKeyOSSSSE] = opt.Encryption.Encode()
+	}
 
 	if v.mw.EnableQuota {
 		var parentId uint64
@@ -1005,7 +1160,9 @@ func (v *Volume) InitMultipart(path string, opt *PutFileOption) (multipartID str
 	return multipartID, nil
 }
 
-func (v *Volume) WritePart(path string, multipartId string, partId uint16, reader io.Reader) (*FSFileInfo, error) {
+// WritePart writes the data of part, the data is encrypted if the encryption of multipart upload is specified.
+func (v *Volume) WritePart(path string, multipartId string, partId uint16, reader io.Reader,
+	encryption *ObjectEncryption) (*FSFileInfo, error) {
 	var exist bool
 	var err error
 	defer func() {
@@ -1069,15 +1226,30 @@ func (v *Volume) WritePart(path string, multipartId string, partId uint16, reade
 				v.name, path, multipartId, partId, tempInodeInfo.Inode, closeErr)
 		}
 	}()
+	// every upload of the part is encrypted with its own IV kept by the part inode, so that the
+	// part uploaded again never reuses the key stream
+	if encryption != nil {
+		if encryption, err = encryption.ForPart(); err != nil {
+			return nil, err
+		}
+		if err = v.mw.XAttrSet_ll(tempInodeInfo.Inode, []byte(XAttrKeyOSSSSEPartIV),
+			[]byte(hex.EncodeToString(encryption.IV))); err != nil {
+			log.LogErrorf("WritePart: meta set part IV fail: volume(%v) path(%v) multipartID(%v) partID(%v) inode(%v) err(%v)",
+				v.name, path, multipartId, partId, tempInodeInfo.Inode, err)
+			return nil, err
+		}
+	}
+	writeReader, writeHash := encryptWriteSource(encryption, reader, md5Hash, partId)
 	if proto.IsCold(v.volType) || proto.IsStorageClassBlobStore(tempInodeInfo.StorageClass) {
-		if size, err = v.ebsWrite(tempInodeInfo.Inode, reader, md5Hash, tempInodeInfo.StorageClass); err != nil {
+		if size, err = v.ebsWrite(tempInodeInfo.Inode, writeReader, writeHash, tempInodeInfo.StorageClass); err != nil {
 			log.LogErrorf("WritePart: ebs write fail: volume(%v) inode(%v) multipartID(%v) partID(%v) err(%v)",
 				v.name, tempInodeInfo.Inode, multipartId, partId, err)
 			return nil, err
 		}
 	} else {
 		// Write data to data node
-		if size, err = v.streamWrite(tempInodeInfo.Inode, reader, md5Hash, tempInodeInfo.StorageClass); err != nil {
+		// the extents of the parts are collected by CompleteMultipart, so the part is never inline
+		if size, err = v.streamWrite(tempInodeInfo.Inode, writeReader, writeHash, tempInodeInfo.StorageClass, proto.FlagsNoInline); err != nil {
 			log.LogErrorf("WritePart: stream write fail: volume(%v) inode(%v) multipartID(%v) partID(%v) err(%v)",
 				v.name, tempInodeInfo.Inode, multipartId, partId, err)
 			return nil, err
@@ -1114,6 +1286,44 @@ func (v *Volume) WritePart(path string, multipartId string, partId uint16, reade
 	return fInfo, nil
 }
 
+// loadPartIVs returns the IVs of the encrypted parts by inode, the parts uploaded before the IV
+// of part is introduced have none.
+func (v *Volume) loadPartIVs(parts []*proto.MultipartPartInfo) (ivs map[uint64][]byte, err error) {
+	inodes := make([]uint64, 0, len(parts))
+	for _, part := range parts {
+		inodes = append(inodes, part.Inode)
+	}
+	var xattrs []*proto.XAttrInfo
+	if xattrs, err = v.mw.BatchGetXAttr(inodes, []string{XAttrKeyOSSSSEPartIV}); err != nil {
+		return
+	}
+	ivs = make(map[uint64][]byte)
+	for _, xattr := range xattrs {
+		raw := xattr.Get(XAttrKeyOSSSSEPartIV)
+		if len(raw) == 0 {
+			continue
+		}
+		var iv []byte
+		if iv, err = hex.DecodeString(string(raw)); err != nil || len(iv) != sseIVSize {
+			return nil, fmt.Errorf("invalid IV of part inode(%v): %v", xattr.Inode, string(raw))
+		}
+		ivs[xattr.Inode] = iv
+	}
+	return
+}
+
+// MultipartEncryption returns the encryption specified when the multipart upload is initiated,
+// it returns nil if the upload is not encrypted.
+func (v *Volume) MultipartEncryption(path, multipartID string) (encryption *ObjectEncryption, err error) {
+	var multipartInfo *proto.MultipartInfo
+	if multipartInfo, err = v.mw.GetMultipart_ll(path, multipartID); err != nil {
+		log.LogErrorf("MultipartEncryption: meta get multipart fail: volume(%v) multipartID(%v) path(%v) err(%v)",
+			v.name, multipartID, path, err)
+		return
+	}
+	return ParseObjectEncryption([]byte(multipartInfo.Extend[XAttrKeyOSSSSE]), nil)
+}
+
 func (v *Volume) AbortMultipart(path string, multipartID string) (err error) {
 	defer func() {
 		log.LogInfof("Audit: AbortMultipart: volume(%v) path(%v) multipartID(%v) err(%v)",
@@ -1307,6 +1517,32 @@ func (v *Volume) CompleteMultipart(path, multipartID string, multipartInfo *prot
 	if objectLock != nil && objectLock.ToRetention() != nil {
 		attrs[XAttrKeyOSSLock] = formatRetentionDateStr(finalInode.ModifyTime, objectLock.ToRetention())
 	}
+	// every part is encrypted with its own IV and counter, so the number, size and IV of parts are
+	// required for decryption
+	var encryption *ObjectEncryption
+	if encryption, err = ParseObjectEncryption([]byte(extend[XAttrKeyOSSSSE]), nil); err != nil {
+		log.LogErrorf("CompleteMultipart: parse multipart encryption fail: volume(%v) multipartID(%v) err(%v)",
+			v.name, multipartID, err)
+		return
+	}
+	if encryption != nil {
+		var partIVs map[uint64][]byte
+		if partIVs, err = v.loadPartIVs(parts); err != nil {
+			log.LogErrorf("CompleteMultipart: load part IVs fail: volume(%v) multipartID(%v) err(%v)",
+				v.name, multipartID, err)
+			return
+		}
+		sseParts := make([]SSEPart, 0, len(parts))
+		for _, part := range parts {
+			sseParts = append(sseParts, SSEPart{Number: part.ID, Size: part.Size, IV: partIVs[part.Inode]})
+		}
+		encryption = encryption.WithParts(sseParts)
+		attrs[XAttrKeyOSSSSEParts] = encryption.EncodeParts()
+	}
+	versionId := v.newObjectVersionId()
+	if versionId != "" {
+		attrs[XAttrKeyOSSVersionId] = versionId
+	}
 	if err = v.mw.BatchSetXAttr_ll(finalInode.Inode, attrs); err != nil {
 		log.LogErrorf("CompleteMultipart: store multipart extend fail: volume(%v) multipartID(%v) inode(%v) "+
 			"attrs(%v) err(%v)", v.name, multipartID, finalInode.Inode, attrs, err)
@@ -1365,6 +1601,8 @@ func (v *Volume) CompleteMultipart(path, multipartID string, multipartInfo *prot
 		ModifyTime: time.Now(),
 		ETag:       etagValue.ETag(),
 		Inode:      finalInode.Inode,
+		VersionId:  versionId,
+		Encryption: encryption,
 	}
 
 	return fInfo, nil
@@ -1376,7 +1614,7 @@ func (v *Volume) ebsWrite(inode uint64, reader io.Reader, h hash.Hash, storageCl
 	return
 }
 
-func (v *Volume) streamWrite(inode uint64, reader io.Reader, h hash.Hash, storageClass uint32) (size uint64, err error) {
+func (v *Volume) streamWrite(inode uint64, reader io.Reader, h hash.Hash, storageClass uint32, flags int) (size uint64, err error) {
 	var (
 		buf                   = make([]byte, 2*util.BlockSize)
 		teeReader             = io.TeeReader(reader, h)
@@ -1402,7 +1640,7 @@ func (v *Volume) streamWrite(inode uint64, reader io.Reader, h hash.Hash, storag
 				}
 				return nil
 			}
-			if writeN, err = v.ec.Write(inode, offset, buf[:readN], 0, checkFunc, storageClass, false); err != nil {
+			if writeN, err = v.ec.Write(inode, offset, buf[:readN], flags, checkFunc, storageClass, false); err != nil {
 				log.LogErrorf("streamWrite: data write tmp file fail, inode(%v) offset(%v) err(%v)", inode, offset, err)
 				exporter.Warning(fmt.Sprintf("write data fail: volume(%v) inode(%v) offset(%v) size(%v) err(%v)",
 					v.name, inode, offset, readN, err))
@@ -1481,6 +1719,17 @@ func (v *Volume) applyInodeToNewDentry(parentID uint64, name string, inode uint6
 
 func (v *Volume) applyInodeToExistDentry(parentID uint64, name string, inode uint64, isCompleteMultipart bool,
 	fullPath string, storageClass uint32) (err error) {
+	// keep the replaced inode as a noncurrent version if versioning is active
+	var replaced bool
+	if replaced, err = v.replaceCurrentVersion(parentID, name, inode, isCompleteMultipart, fullPath, storageClass); err != nil {
+		log.LogErrorf("applyInodeToExistDentry: replace current version fail: volume(%v) path(%v) inode(%v) err(%v)",
+			v.name, fullPath, inode, err)
+		return
+	}
+	if replaced {
+		return
+	}
+
 	var oldInode uint64
 	oldInode, err = v.mw.DentryUpdate_ll(parentID, name, inode, fullPath)
 	if err != nil {
@@ -1712,6 +1961,13 @@ func (v *Volume) ObjectMeta(path string) (info *FSFileInfo, xattr *proto.XAttrIn
 		break
 	}
 
+	return v.objectMetaByInode(path, inode, mode, inoInfo)
+}
+
+// objectMetaByInode assembles the object meta of the given inode, which may be linked at
+// the object path or be a noncurrent version kept in the version archive.
+func (v *Volume) objectMetaByInode(path string, inode uint64, mode os.FileMode, inoInfo *proto.InodeInfo) (
+	info *FSFileInfo, xattr *proto.XAttrInfo, err error) {
 	var (
 		etagValue    ETagValue
 		mimeType     string
@@ -1790,6 +2046,23 @@ func (v *Volume) ObjectMeta(path string) (info *FSFileInfo, xattr *proto.XAttrIn
 		}
 	}
 
+	var encryption *ObjectEncryption
+	if encryption, err = ParseObjectEncryption(xattr.Get(XAttrKeyOSSSSE), xattr.Get(XAttrKeyOSSSSEParts)); err != nil {
+		log.LogErrorf("getObjectMeta: parse encryption fail: volume(%v) path(%v) err(%v)",
+			v.Name(), path, err)
+		return
+	}
+
+	var restoreStatus *proto.RestoreStatus
+	if raw := xattr.Get(proto.XAttrKeyRestoreStatus); len(raw) > 0 {
+		restoreStatus = &proto.RestoreStatus{}
+		if err = json.Unmarshal(raw, restoreStatus); err != nil {
+			log.LogWarnf("getObjectMeta: parse restore status fail: volume(%v) path(%v) status(%v) err(%v)",
+				v.Name(), path, string(raw), err)
+			restoreStatus, err = nil, nil
+		}
+	}
+
 	// Validating ETag value.
 	if !mode.IsDir() && (!etagValue.Valid() || etagValue.TS.Before(inoInfo.ModifyTime)) {
 		log.LogWarnf("ObjectMeta: etag invalid or before inode modTime: volume(%v) path(%v) inoInfo(%v) etagVal(%v)",
@@ -1797,20 +2070,25 @@ func (v *Volume) ObjectMeta(path string) (info *FSFileInfo, xattr *proto.XAttrIn
 	}
 
 	info = &FSFileInfo{
-		Path:            path,
-		Size:            int64(inoInfo.Size),
-		Mode:            os.FileMode(inoInfo.Mode),
-		CreateTime:      inoInfo.CreateTime,
-		ModifyTime:      inoInfo.ModifyTime,
-		ETag:            etagValue.ETag(),
-		Inode:           inoInfo.Inode,
-		MIMEType:        mimeType,
-		Disposition:     disposition,
-		CacheControl:    cacheControl,
-		Expires:         expires,
-		Metadata:        metadata,
-		RetainUntilDate: retainUntilDate,
-		StorageClass:    inoInfo.StorageClass,
+		Path:              path,
+		Size:              int64(inoInfo.Size),
+		Mode:              os.FileMode(inoInfo.Mode),
+		CreateTime:        inoInfo.CreateTime,
+		ModifyTime:        inoInfo.ModifyTime,
+		ETag:              etagValue.ETag(),
+		Inode:             inoInfo.Inode,
+		MIMEType:          mimeType,
+		Disposition:       disposition,
+		CacheControl:      cacheControl,
+		Expires:           expires,
+		Metadata:          metadata,
+		RetainUntilDate:   retainUntilDate,
+		StorageClass:      inoInfo.StorageClass,
+		VersionId:         string(xattr.Get(XAttrKeyOSSVersionId)),
+		DeleteMarker:      len(xattr.Get(XAttrKeyOSSDeleteMarker)) > 0,
+		Encryption:        encryption,
+		ReplicationStatus: string(xattr.Get(proto.XAttrKeyReplicationStatus)),
+		RestoreStatus:     restoreStatus,
 	}
 	return
 }
@@ -1834,6 +2112,11 @@ func (v *Volume) Close() error {
 //	pathname did not exist, or the pathname was an empty string.
 func (v *Volume) recursiveLookupTarget(path string, notUseCache bool) (parent uint64, ino uint64, name string, mode os.FileMode, err error) {
 	parent = rootIno
+	// The version archive is only reachable with version IDs.
+	if v.isReservedObjectKey(path) {
+		err = syscall.ENOENT
+		return
+	}
 	pathIterator := NewPathIterator(path)
 	if !pathIterator.HasNext() {
 		err = syscall.ENOENT
@@ -2057,7 +2340,24 @@ func (v *Volume) recursiveMakeDirectory(path string) (partentIno uint64, err err
 
 // Deprecated
 func (v *Volume) lookupDirectories(dirs []string, autoCreate bool) (inode uint64, err error) {
-	parentId := rootIno
+	return v.lookupDirectoriesUnder(rootIno, dirs, autoCreate)
+}
+
+// lookupReservedDirectories looks up the directories under the reserved directory dirs[0]. The
+// reserved name taken by the user is taken as ENOENT if autoCreate is not set, since nothing of
+// the services is kept there.
+func (v *Volume) lookupReservedDirectories(dirs []string, autoCreate bool) (inode uint64, err error) {
+	var parentId uint64
+	if parentId, err = v.reservedDir(dirs[0], autoCreate); err != nil {
+		if err == ReservedNameTaken && !autoCreate {
+			err = syscall.ENOENT
+		}
+		return
+	}
+	return v.lookupDirectoriesUnder(parentId, dirs[1:], autoCreate)
+}
+
+func (v *Volume) lookupDirectoriesUnder(parentId uint64, dirs []string, autoCreate bool) (inode uint64, err error) {
 	// check and create dirs
 	for _, dir := range dirs {
 		curIno, curMode, lookupErr := v.mw.Lookup_ll(parentId, dir)
@@ -2106,6 +2406,62 @@ func (v *Volume) lookupDirectories(dirs []string, autoCreate bool) (inode uint64
 	return
 }
 
+// reservedDir returns the reserved directory under the volume root, which is created and marked
+// if autoCreate is set. ReservedNameTaken is returned if the name is taken by the entry of the
+// user, which is never used for the internal data.
+func (v *Volume) reservedDir(name string, autoCreate bool) (inode uint64, err error) {
+	var mode uint32
+	inode, mode, err = v.mw.Lookup_ll(rootIno, name)
+	if err == syscall.ENOENT && autoCreate {
+		var info *proto.InodeInfo
+		info, err = v.mw.Create_ll(rootIno, name, uint32(DefaultDirMode), 0, 0, nil, "/"+name, false)
+		if err == nil {
+			if err = v.mw.XAttrSet_ll(info.Inode, []byte(proto.ReservedDirXAttrKey), []byte("1")); err != nil {
+				log.LogErrorf("reservedDir: mark fail: volume(%v) name(%v) ino(%v) err(%v)", v.name, name, info.Inode, err)
+				return 0, err
+			}
+			log.LogInfof("reservedDir: created: volume(%v) name(%v) ino(%v)", v.name, name, info.Inode)
+			return info.Inode, nil
+		}
+		if err != syscall.EEXIST {
+			return 0, err
+		}
+		inode, mode, err = v.mw.Lookup_ll(rootIno, name)
+	}
+	if err != nil {
+		return 0, err
+	}
+	var reserved bool
+	if reserved, err = v.mw.IsReservedDir(inode); err != nil {
+		return 0, err
+	}
+	if !reserved || !os.FileMode(mode).IsDir() {
+		log.LogWarnf("reservedDir: name taken by user: volume(%v) name(%v) ino(%v)", v.name, name, inode)
+		return 0, ReservedNameTaken
+	}
+	return inode, nil
+}
+
+// isReservedObjectKey reports whether the key points into the reserved directories, the keys
+// under the entries of the users taking the reserved names are not.
+func (v *Volume) isReservedObjectKey(key string) bool {
+	if !isReservedObjectKey(key) {
+		return false
+	}
+	name := strings.SplitN(strings.TrimPrefix(key, pathSep), pathSep, 2)[0]
+	_, err := v.reservedDir(name, false)
+	return err != ReservedNameTaken
+}
+
+// isReservedChild reports whether the child of the root is a reserved directory.
+func (v *Volume) isReservedChild(child *proto.Dentry) bool {
+	if !proto.IsReservedName(child.Name) {
+		return false
+	}
+	reserved, err := v.mw.IsReservedDir(child.Inode)
+	return reserved || err != nil
+}
+
 func (v *Volume) listFilesV1(prefix, marker, delimiter string, maxKeys uint64, onlyObject bool) (infos []*FSFileInfo,
 	prefixes Prefixes, nextMarker string, err error,
 ) {
@@ -2211,6 +2567,11 @@ func (v *Volume) listFilesV2(prefix, startAfter, contToken, delimiter string, ma
 }
 
 func (v *Volume) findParentId(prefix string) (inode uint64, prefixDirs []string, err error) {
+	return v.findParentIdFrom(proto.RootIno, prefix)
+}
+
+// findParentIdFrom is like findParentId but resolves the prefix starting from the given directory.
+func (v *Volume) findParentIdFrom(startIno uint64, prefix string) (inode uint64, prefixDirs []string, err error) {
 	prefixDirs = make([]string, 0)
 
 	// if prefix and marker are both not empty, use marker
@@ -2219,10 +2580,10 @@ func (v *Volume) findParentId(prefix string) (inode uint64, prefixDirs []string,
 		dirs = strings.Split(prefix, "/")
 	}
 	if len(dirs) <= 1 {
-		return proto.RootIno, prefixDirs, nil
+		return startIno, prefixDirs, nil
 	}
 
-	parentId := proto.RootIno
+	parentId := startIno
 	for index, dir := range dirs {
 
 		// Because lookup can only retrieve dentry whose name exactly matches,
@@ -2329,6 +2690,9 @@ readDir:
 		if child.Name == lastKey {
 			continue
 		}
+		if parentId == proto.RootIno && v.isReservedChild(&child) {
+			continue
+		}
 		path := strings.Join(append(dirs, child.Name), pathSep)
 		if os.FileMode(child.Type).IsDir() {
 			path += pathSep
@@ -2439,7 +2803,7 @@ func (v *Volume) supplyListFileInfo(fileInfos []*FSFileInfo) (err error) {
 	}
 
 	// Get MD5 information in batches, then update to fileInfos
-	keys := []string{XAttrKeyOSSETag, XAttrKeyOSSETagDeprecated}
+	keys := []string{XAttrKeyOSSETag, XAttrKeyOSSETagDeprecated, XAttrKeyOSSVersionId, XAttrKeyOSSDeleteMarker}
 	xattrs, err := v.mw.BatchGetXAttr(inodes, keys)
 	if err != nil {
 		log.LogErrorf("supplyListFileInfo: batch get xattr fail, inodes(%v), err(%v)", inodes, err)
@@ -2459,6 +2823,13 @@ func (v *Volume) supplyListFileInfo(fileInfos []*FSFileInfo) (err error) {
 		var etagValue ETagValue
 		if i >= 0 && i < len(xattrs) && xattrs[i].Inode == fileInfo.Inode {
 			xattr := xattrs[i]
+			if versionId := xattr.Get(XAttrKeyOSSVersionId); len(versionId) > 0 {
+				fileInfo.VersionId = string(versionId)
+			}
+			if len(xattr.Get(XAttrKeyOSSDeleteMarker)) > 0 {
+				fileInfo.DeleteMarker = true
+				continue
+			}
 			rawETag := string(xattr.Get(XAttrKeyOSSETag))
 			if len(rawETag) == 0 {
 				rawETag = string(xattr.Get(XAttrKeyOSSETagDeprecated))
@@ -2616,7 +2987,15 @@ func (v *Volume) CopyFile(sv *Volume, sourcePath, targetPath, metaDirective stri
 		sInodeInfo *proto.InodeInfo
 	)
 
-	if _, sInode, sName, sMode, err = sv.recursiveLookupTarget(sourcePath, false); err != nil {
+	sourceIsCurrent := true
+	if opt != nil && opt.SourceVersionId != "" {
+		if sInode, sMode, sourceIsCurrent, err = sv.lookupObjectVersion(sourcePath, opt.SourceVersionId); err != nil {
+			log.LogErrorf("CopyFile: look up source version fail, source path(%v) versionId(%v) err(%v)",
+				sourcePath, opt.SourceVersionId, err)
+			return
+		}
+		sName = opt.SourceVersionId
+	} else if _, sInode, sName, sMode, err = sv.recursiveLookupTarget(sourcePath, false); err != nil {
 		log.LogErrorf("CopyFile: look up source path fail, source path(%v) err(%v)", sourcePath, err)
 		return
 	}
@@ -2644,10 +3023,16 @@ func (v *Volume) CopyFile(sv *Volume, sourcePath, targetPath, metaDirective stri
 		}
 	}()
 
+	var sourceEncryption, encryption *ObjectEncryption
+	if opt != nil {
+		sourceEncryption, encryption = opt.SourceEncryption, opt.Encryption
+	}
+
 	var xattr *proto.XAttrInfo
 	// if source path is same with target path, just reset file metadata
 	// source path is same with target path, and metadata directive is not 'REPLACE', objectNode does nothing
-	if targetPath == sourcePath && v.name == sv.name {
+	// the data must be rewritten if the source or target object is encrypted
+	if targetPath == sourcePath && v.name == sv.name && sourceIsCurrent && sourceEncryption == nil && encryption == nil {
 		if metaDirective != MetadataDirectiveReplace {
 			log.LogInfof("CopyFile: targetPath(%v) is equal with sourcePath(%v),but metaDirective(%v) is not REPLACE",
 				targetPath, sourcePath, metaDirective)
@@ -2779,9 +3164,14 @@ func (v *Volume) CopyFile(sv *Volume, sourcePath, targetPath, metaDirective stri
 		}
 	}
 
-	// create target file inode and set target inode to be source file inode
-	if tInodeInfo, err = v.mw.InodeCreate_ll(tParentId, uint32(sMode), 0, 0, nil, make([]uint64, 0), targetPath); err != nil {
-		return
+	// clone the extent keys of the source into the target instead of copying the data if possible
+	fileSize := sInodeInfo.Size
+	md5Value := ""
+	if tInodeInfo, md5Value = v.cloneFile(sv, sourcePath, sInode, sInodeInfo, sourceEncryption, encryption); tInodeInfo == nil {
+		// create target file inode and set target inode to be source file inode
+		if tInodeInfo, err = v.mw.InodeCreate_ll(tParentId, uint32(sMode), 0, 0, nil, make([]uint64, 0), targetPath); err != nil {
+			return
+		}
 	}
 
 	defer func() {
@@ -2796,96 +3186,11 @@ func (v *Volume) CopyFile(sv *Volume, sourcePath, targetPath, metaDirective stri
 		}
 	}()
 
-	isCache = false
-	if proto.IsCold(v.volType) || proto.IsStorageClassBlobStore(tInodeInfo.StorageClass) {
-		isCache = true
-	}
-	if err = v.ec.OpenStream(tInodeInfo.Inode, true, isCache); err != nil {
-		return
-	}
-	defer func() {
-		if closeErr := v.ec.CloseStream(tInodeInfo.Inode); closeErr != nil {
-			log.LogErrorf("CopyFile: close target path stream fail: volume(%v) path(%v) inode(%v) err(%v)",
-				v.name, targetPath, tInodeInfo.Inode, closeErr)
-		}
-	}()
-
-	// write data to invisibleTempDataInode from source object
-	var (
-		fileSize    = sInodeInfo.Size
-		md5Hash     = md5.New()
-		md5Value    string
-		readN       int
-		writeN      int
-		readOffset  int
-		writeOffset int
-		readSize    int
-		rest        int
-		buf         = make([]byte, 2*util.BlockSize)
-	)
-
-	var sctx context.Context
-	var ebsReader *blobstore.Reader
-	var tctx context.Context
-	var ebsWriter *blobstore.Writer
-	if proto.IsCold(v.volType) || proto.IsStorageClassBlobStore(sInodeInfo.StorageClass) {
-		sctx = context.Background()
-		ebsReader = v.getEbsReader(sInode, sInodeInfo.StorageClass)
-	}
-	if proto.IsCold(v.volType) || proto.IsStorageClassBlobStore(tInodeInfo.StorageClass) {
-		tctx = context.Background()
-		ebsWriter = v.getEbsWriter(tInodeInfo.Inode, tInodeInfo.StorageClass)
-	}
-
-	for {
-		if rest = int(fileSize) - readOffset; rest <= 0 {
-			break
-		}
-		readSize = len(buf)
-		if rest < len(buf) {
-			readSize = rest
-		}
-		buf = buf[:readSize]
-		if proto.IsCold(v.volType) || proto.IsStorageClassBlobStore(sInodeInfo.StorageClass) {
-			readN, err = ebsReader.Read(sctx, buf, readOffset, readSize)
-		} else {
-			readN, err = sv.ec.Read(sInode, buf, readOffset, readSize, sInodeInfo.StorageClass, false)
-		}
-		if err != nil && err != io.EOF {
+	if md5Value == "" {
+		if md5Value, err = v.copyFileData(sv, sInode, sInodeInfo, tInodeInfo, targetPath, sourceEncryption, encryption); err != nil {
 			return
 		}
-		if readN > 0 {
-			if proto.IsCold(v.volType) || proto.IsStorageClassBlobStore(tInodeInfo.StorageClass) {
-				writeN, err = ebsWriter.WriteWithoutPool(tctx, writeOffset, buf[:readN])
-			} else {
-				writeN, err = v.ec.Write(tInodeInfo.Inode, writeOffset, buf[:readN], 0, nil, tInodeInfo.StorageClass, false)
-			}
-			if err != nil {
-				log.LogErrorf("CopyFile: write target path from source fail, volume(%v) path(%v) inode(%v) target offset(%v) err(%v)",
-					v.name, targetPath, tInodeInfo.Inode, writeOffset, err)
-				return
-			}
-			readOffset += readN
-			writeOffset += writeN
-			md5Hash.Write(buf[:readN])
-		}
-		if err == io.EOF {
-			err = nil
-			break
-		}
-	}
-	// flush
-	if proto.IsCold(v.volType) || proto.IsStorageClassBlobStore(tInodeInfo.StorageClass) {
-		err = ebsWriter.FlushWithoutPool(tInodeInfo.Inode, tctx)
-	} else {
-		v.ec.Flush(tInodeInfo.Inode)
-	}
-	if err != nil {
-		log.LogErrorf("CopyFile: data flush inode fail, volume(%v) inode(%v), path (%v) err(%v)", v.name, tInodeInfo.Inode, targetPath, err)
-		return
 	}
-
-	md5Value = hex.EncodeToString(md5Hash.Sum(nil))
 	log.LogDebugf("Audit: copy file: write file finished, volume(%v), path(%v), etag(%v)", v.name, targetPath, md5Value)
 
 	var finalInode *proto.InodeInfo
@@ -2907,6 +3212,13 @@ func (v *Volume) CopyFile(sv *Volume, sourcePath, targetPath, metaDirective stri
 		},
 	}
 	targetAttr.XAttrs[XAttrKeyOSSETag] = etagValue.Encode()
+	versionId := v.newObjectVersionId()
+	if versionId != "" {
+		targetAttr.XAttrs[XAttrKeyOSSVersionId] = versionId
+	}
+	if encryption != nil {
+		targetAttr.XAttrs[XAttrKeyOSSSSE] = encryption.Encode()
+	}
 
 	// copy source file metadata to write target file metadata
 	if metaDirective != MetadataDirectiveReplace {
@@ -2915,7 +3227,9 @@ func (v *Volume) CopyFile(sv *Volume, sourcePath, targetPath, metaDirective stri
 			return
 		}
 		for key, val := range xattr.XAttrs {
-			if key == XAttrKeyOSSETag {
+			if key == XAttrKeyOSSETag || key == XAttrKeyOSSVersionId || key == XAttrKeyOSSDeleteMarker ||
+				key == XAttrKeyOSSSSE || key == XAttrKeyOSSSSEParts || key == proto.XAttrKeyReplicationStatus ||
+				key == proto.XAttrKeyRestoreStatus {
 				continue
 			}
 			targetAttr.XAttrs[key] = val
@@ -2986,6 +3300,8 @@ func (v *Volume) CopyFile(sv *Volume, sourcePath, targetPath, metaDirective stri
 		CreateTime: tInodeInfo.CreateTime,
 		ETag:       md5Value,
 		Inode:      tInodeInfo.Inode,
+		VersionId:  versionId,
+		Encryption: encryption,
 	}
 
 	// apply new inode to dentry
@@ -3003,6 +3319,134 @@ func (v *Volume) CopyFile(sv *Volume, sourcePath, targetPath, metaDirective stri
 	return
 }
 
+// cloneFile clones the source file of the same volume into a new inode sharing the extents, which
+// has the same data and ETag as the source. It returns nil if the source can't be cloned, and the
+// data should be copied instead.
+func (v *Volume) cloneFile(sv *Volume, sourcePath string, sInode uint64, sInodeInfo *proto.InodeInfo,
+	sourceEncryption, encryption *ObjectEncryption) (info *proto.InodeInfo, md5Value string) {
+	if v.name != sv.name || sourceEncryption != nil || encryption != nil || proto.IsCold(v.volType) ||
+		!proto.IsStorageClassReplica(sInodeInfo.StorageClass) {
+		return nil, ""
+	}
+	xattr, err := sv.mw.XAttrGet_ll(sInode, XAttrKeyOSSETag)
+	if err != nil {
+		return nil, ""
+	}
+	// the ETag of a multipart object or a stale one is not the md5 of the data
+	etagValue := ParseETagValue(string(xattr.Get(XAttrKeyOSSETag)))
+	if !etagValue.Valid() || etagValue.PartNum != 0 || etagValue.TS.Before(sInodeInfo.ModifyTime) {
+		return nil, ""
+	}
+	if info, err = v.mw.CloneInode(sInode, 0, 0, 0); err != nil {
+		log.LogInfof("cloneFile: copy the data instead: volume(%v) source path(%v) inode(%v) err(%v)",
+			v.name, sourcePath, sInode, err)
+		return nil, ""
+	}
+	return info, etagValue.Value
+}
+
+// copyFileData copies the data of the source file into the target inode, and returns the md5 of it.
+func (v *Volume) copyFileData(sv *Volume, sInode uint64, sInodeInfo, tInodeInfo *proto.InodeInfo, targetPath string,
+	sourceEncryption, encryption *ObjectEncryption) (md5Value string, err error) {
+	isCache := false
+	if proto.IsCold(v.volType) || proto.IsStorageClassBlobStore(tInodeInfo.StorageClass) {
+		isCache = true
+	}
+	if err = v.ec.OpenStream(tInodeInfo.Inode, true, isCache); err != nil {
+		return
+	}
+	defer func() {
+		if closeErr := v.ec.CloseStream(tInodeInfo.Inode); closeErr != nil {
+			log.LogErrorf("CopyFile: close target path stream fail: volume(%v) path(%v) inode(%v) err(%v)",
+				v.name, targetPath, tInodeInfo.Inode, closeErr)
+		}
+	}()
+
+	// write data to invisibleTempDataInode from source object
+	var (
+		fileSize    = sInodeInfo.Size
+		md5Hash     = md5.New()
+		readN       int
+		writeN      int
+		readOffset  int
+		writeOffset int
+		readSize    int
+		rest        int
+		buf         = make([]byte, 2*util.BlockSize)
+	)
+
+	var sctx context.Context
+	var ebsReader *blobstore.Reader
+	var tctx context.Context
+	var ebsWriter *blobstore.Writer
+	if proto.IsCold(v.volType) || proto.IsStorageClassBlobStore(sInodeInfo.StorageClass) {
+		sctx = context.Background()
+		ebsReader = v.getEbsReader(sInode, sInodeInfo.StorageClass)
+	}
+	if proto.IsCold(v.volType) || proto.IsStorageClassBlobStore(tInodeInfo.StorageClass) {
+		tctx = context.Background()
+		ebsWriter = v.getEbsWriter(tInodeInfo.Inode, tInodeInfo.StorageClass)
+	}
+
+	for {
+		if rest = int(fileSize) - readOffset; rest <= 0 {
+			break
+		}
+		readSize = len(buf)
+		if rest < len(buf) {
+			readSize = rest
+		}
+		buf = buf[:readSize]
+		if proto.IsCold(v.volType) || proto.IsStorageClassBlobStore(sInodeInfo.StorageClass) {
+			readN, err = ebsReader.Read(sctx, buf, readOffset, readSize)
+		} else {
+			readN, err = sv.ec.Read(sInode, buf, readOffset, readSize, sInodeInfo.StorageClass, false)
+		}
+		if err != nil && err != io.EOF {
+			return
+		}
+		if readN > 0 {
+			// the ETag is computed on the plaintext
+			if sourceEncryption != nil {
+				sourceEncryption.XORAt(buf[:readN], uint64(readOffset))
+			}
+			md5Hash.Write(buf[:readN])
+			if encryption != nil {
+				encryption.XORAt(buf[:readN], uint64(writeOffset))
+			}
+			if proto.IsCold(v.volType) || proto.IsStorageClassBlobStore(tInodeInfo.StorageClass) {
+				writeN, err = ebsWriter.WriteWithoutPool(tctx, writeOffset, buf[:readN])
+			} else {
+				writeN, err = v.ec.Write(tInodeInfo.Inode, writeOffset, buf[:readN], 0, nil, tInodeInfo.StorageClass, false)
+			}
+			if err != nil {
+				log.LogErrorf("CopyFile: write target path from source fail, volume(%v) path(%v) inode(%v) target offset(%v) err(%v)",
+					v.name, targetPath, tInodeInfo.Inode, writeOffset, err)
+				return
+			}
+			readOffset += readN
+			writeOffset += writeN
+		}
+		if err == io.EOF {
+			err = nil
+			break
+		}
+	}
+	// flush
+	if proto.IsCold(v.volType) || proto.IsStorageClassBlobStore(tInodeInfo.StorageClass) {
+		err = ebsWriter.FlushWithoutPool(tInodeInfo.Inode, tctx)
+	} else {
+		v.ec.Flush(tInodeInfo.Inode)
+	}
+	if err != nil {
+		log.LogErrorf("CopyFile: data flush inode fail, volume(%v) inode(%v), path (%v) err(%v)", v.name, tInodeInfo.Inode, targetPath, err)
+		return
+	}
+
+	md5Value = hex.EncodeToString(md5Hash.Sum(nil))
+	return
+}
+
 func (v *Volume) copyFile(parentID uint64, newFileName string, sourceFileInode uint64, mode uint32, newPath string, sourcePath string) (info *proto.InodeInfo, err error) {
 	if err = v.mw.DentryCreate_ll(parentID, newFileName, sourceFileInode, mode, newPath); err != nil {
 		return
@@ -3030,10 +3474,11 @@ func NewVolume(config *VolumeConfig) (*Volume, error) {
 	}
 
 	metaConfig := &meta.MetaConfig{
-		Volume:        config.Volume,
-		Masters:       config.Masters,
-		Authenticate:  false,
-		ValidateOwner: false,
+		Volume:              config.Volume,
+		Masters:             config.Masters,
+		Authenticate:        false,
+		ValidateOwner:       false,
+		AccessReservedNames: true,
 		OnAsyncTaskError: func(err error) {
 			config.OnAsyncTaskError.OnError(err)
 		},
@@ -3057,12 +3502,15 @@ func NewVolume(config *VolumeConfig) (*Volume, error) {
 		OnAppendExtentKey:           metaWrapper.AppendExtentKey,
 		OnSplitExtentKey:            metaWrapper.SplitExtentKey,
 		OnGetExtents:                metaWrapper.GetExtents,
+		OnGetInlineExtents:          metaWrapper.GetInlineExtents,
+		OnWriteInline:               metaWrapper.WriteInline,
 		OnTruncate:                  metaWrapper.Truncate,
 		OnRenewalForbiddenMigration: metaWrapper.RenewalForbiddenMigration,
 		VolStorageClass:             volumeInfo.VolStorageClass,
 		VolAllowedStorageClass:      volumeInfo.AllowedStorageClass,
 		VolCacheDpStorageClass:      volumeInfo.CacheDpStorageClass,
 		OnForbiddenMigration:        metaWrapper.ForbiddenMigration,
+		OnIsSharedExtent:            metaWrapper.IsSharedExtent,
 	}
 
 	if proto.IsCold(volumeInfo.VolType) || proto.IsStorageClassBlobStore(volumeInfo.VolStorageClass) {
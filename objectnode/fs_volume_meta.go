@@ -18,6 +18,7 @@ import (
 	"sync"
 	"sync/atomic"
 
+	"github.com/cubefs/cubefs/proto"
 	"golang.org/x/sync/singleflight"
 )
 
@@ -26,10 +27,22 @@ type ossMetaLoader interface {
 	loadACL() (p *AccessControlPolicy, err error)
 	loadCORS() (cors *CORSConfiguration, err error)
 	loadObjectLock() (config *ObjectLockConfig, err error)
+	loadVersioning() (config *VersioningConfiguration, err error)
+	loadEncryption() (config *ServerSideEncryptionConfiguration, err error)
+	loadReplication() (config *proto.ReplicationConfiguration, err error)
+	loadNotification() (config *NotificationConfiguration, err error)
+	loadWebsite() (config *WebsiteConfiguration, err error)
+	loadPublicAccess() (config *PublicAccessBlockConfiguration, err error)
 	storePolicy(p *Policy)
 	storeACL(p *AccessControlPolicy)
 	storeCORS(cors *CORSConfiguration)
 	storeObjectLock(config *ObjectLockConfig)
+	storeVersioning(config *VersioningConfiguration)
+	storeEncryption(config *ServerSideEncryptionConfiguration)
+	storeReplication(config *proto.ReplicationConfiguration)
+	storeNotification(config *NotificationConfiguration)
+	storeWebsite(config *WebsiteConfiguration)
+	storePublicAccess(config *PublicAccessBlockConfiguration)
 	setSynced()
 }
 
@@ -50,10 +63,22 @@ type OSSMeta struct {
 	acl        *AccessControlPolicy
 	corsConfig *CORSConfiguration
 	lockConfig *ObjectLockConfig
+	versioning *VersioningConfiguration
+	encryption *ServerSideEncryptionConfiguration
+	replConfig *proto.ReplicationConfiguration
+	notifyConf *NotificationConfiguration
+	website    *WebsiteConfiguration
+	pubAccess  *PublicAccessBlockConfiguration
 	policyLock sync.RWMutex
 	aclLock    sync.RWMutex
 	corsLock   sync.RWMutex
 	objectLock sync.RWMutex
+	verLock    sync.RWMutex
+	sseLock    sync.RWMutex
+	replLock   sync.RWMutex
+	notifyLock sync.RWMutex
+	siteLock   sync.RWMutex
+	pabLock    sync.RWMutex
 }
 
 func (c *cacheMetaLoader) loadPolicy() (p *Policy, err error) {
@@ -152,6 +177,150 @@ func (c *cacheMetaLoader) storeObjectLock(config *ObjectLockConfig) {
 	c.om.objectLock.Unlock()
 }
 
+func (c *cacheMetaLoader) loadVersioning() (config *VersioningConfiguration, err error) {
+	c.om.verLock.RLock()
+	config = c.om.versioning
+	c.om.verLock.RUnlock()
+	if config == nil && atomic.LoadInt32(c.synced) == 0 {
+		ret, err, _ := c.sf.Do(XAttrKeyOSSVersioning, func() (interface{}, error) {
+			vc, err := c.sml.loadVersioning()
+			return vc, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		config = ret.(*VersioningConfiguration)
+		c.storeVersioning(config)
+	}
+	return
+}
+
+func (c *cacheMetaLoader) storeVersioning(config *VersioningConfiguration) {
+	c.om.verLock.Lock()
+	c.om.versioning = config
+	c.om.verLock.Unlock()
+}
+
+func (c *cacheMetaLoader) loadEncryption() (config *ServerSideEncryptionConfiguration, err error) {
+	c.om.sseLock.RLock()
+	config = c.om.encryption
+	c.om.sseLock.RUnlock()
+	if config == nil && atomic.LoadInt32(c.synced) == 0 {
+		ret, err, _ := c.sf.Do(XAttrKeyOSSEncryption, func() (interface{}, error) {
+			ec, err := c.sml.loadEncryption()
+			return ec, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		config = ret.(*ServerSideEncryptionConfiguration)
+		c.storeEncryption(config)
+	}
+	return
+}
+
+func (c *cacheMetaLoader) storeEncryption(config *ServerSideEncryptionConfiguration) {
+	c.om.sseLock.Lock()
+	c.om.encryption = config
+	c.om.sseLock.Unlock()
+}
+
+func (c *cacheMetaLoader) loadReplication() (config *proto.ReplicationConfiguration, err error) {
+	c.om.replLock.RLock()
+	config = c.om.replConfig
+	c.om.replLock.RUnlock()
+	if config == nil && atomic.LoadInt32(c.synced) == 0 {
+		ret, err, _ := c.sf.Do(proto.XAttrKeyReplicationConfig, func() (interface{}, error) {
+			rc, err := c.sml.loadReplication()
+			return rc, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		config = ret.(*proto.ReplicationConfiguration)
+		c.storeReplication(config)
+	}
+	return
+}
+
+func (c *cacheMetaLoader) storeReplication(config *proto.ReplicationConfiguration) {
+	c.om.replLock.Lock()
+	c.om.replConfig = config
+	c.om.replLock.Unlock()
+}
+
+func (c *cacheMetaLoader) loadNotification() (config *NotificationConfiguration, err error) {
+	c.om.notifyLock.RLock()
+	config = c.om.notifyConf
+	c.om.notifyLock.RUnlock()
+	if config == nil && atomic.LoadInt32(c.synced) == 0 {
+		ret, err, _ := c.sf.Do(XAttrKeyOSSNotification, func() (interface{}, error) {
+			nc, err := c.sml.loadNotification()
+			return nc, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		config = ret.(*NotificationConfiguration)
+		c.storeNotification(config)
+	}
+	return
+}
+
+func (c *cacheMetaLoader) storeNotification(config *NotificationConfiguration) {
+	c.om.notifyLock.Lock()
+	c.om.notifyConf = config
+	c.om.notifyLock.Unlock()
+}
+
+func (c *cacheMetaLoader) loadWebsite() (config *WebsiteConfiguration, err error) {
+	c.om.siteLock.RLock()
+	config = c.om.website
+	c.om.siteLock.RUnlock()
+	if config == nil && atomic.LoadInt32(c.synced) == 0 {
+		ret, err, _ := c.sf.Do(XAttrKeyOSSWebsite, func() (interface{}, error) {
+			wc, err := c.sml.loadWebsite()
+			return wc, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		config = ret.(*WebsiteConfiguration)
+		c.storeWebsite(config)
+	}
+	return
+}
+
+func (c *cacheMetaLoader) storeWebsite(config *WebsiteConfiguration) {
+	c.om.siteLock.Lock()
+	c.om.website = config
+	c.om.siteLock.Unlock()
+}
+
+func (c *cacheMetaLoader) loadPublicAccess() (config *PublicAccessBlockConfiguration, err error) {
+	c.om.pabLock.RLock()
+	config = c.om.pubAccess
+	c.om.pabLock.RUnlock()
+	if config == nil && atomic.LoadInt32(c.synced) == 0 {
+		ret, err, _ := c.sf.Do(XAttrKeyOSSPublicAccess, func() (interface{}, error) {
+			pc, err := c.sml.loadPublicAccess()
+			return pc, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		config = ret.(*PublicAccessBlockConfiguration)
+		c.storePublicAccess(config)
+	}
+	return
+}
+
+func (c *cacheMetaLoader) storePublicAccess(config *PublicAccessBlockConfiguration) {
+	c.om.pabLock.Lock()
+	c.om.pubAccess = config
+	c.om.pabLock.Unlock()
+}
+
 func (c *cacheMetaLoader) setSynced() {
 	atomic.StoreInt32(c.synced, 1)
 }
@@ -188,6 +357,54 @@ func (s *strictMetaLoader) storeObjectLock(cors *ObjectLockConfig) {
 	// do nothing
 }
 
+func (s *strictMetaLoader) loadVersioning() (config *VersioningConfiguration, err error) {
+	return s.v.loadBucketVersioning()
+}
+
+func (s *strictMetaLoader) storeVersioning(config *VersioningConfiguration) {
+	// do nothing
+}
+
+func (s *strictMetaLoader) loadEncryption() (config *ServerSideEncryptionConfiguration, err error) {
+	return s.v.loadBucketEncryption()
+}
+
+func (s *strictMetaLoader) storeEncryption(config *ServerSideEncryptionConfiguration) {
+	// do nothing
+}
+
+func (s *strictMetaLoader) loadReplication() (config *proto.ReplicationConfiguration, err error) {
+	return s.v.loadBucketReplication()
+}
+
+func (s *strictMetaLoader) storeReplication(config *proto.ReplicationConfiguration) {
+	// do nothing
+}
+
+func (s *strictMetaLoader) loadNotification() (config *NotificationConfiguration, err error) {
+	return s.v.loadBucketNotification()
+}
+
+func (s *strictMetaLoader) storeNotification(config *NotificationConfiguration) {
+	// do nothing
+}
+
+func (s *strictMetaLoader) loadWebsite() (config *WebsiteConfiguration, err error) {
+	return s.v.loadBucketWebsite()
+}
+
+func (s *strictMetaLoader) storeWebsite(config *WebsiteConfiguration) {
+	// do nothing
+}
+
+func (s *strictMetaLoader) loadPublicAccess() (config *PublicAccessBlockConfiguration, err error) {
+	return s.v.loadBucketPublicAccess()
+}
+
+func (s *strictMetaLoader) storePublicAccess(config *PublicAccessBlockConfiguration) {
+	// do nothing
+}
+
 func (s *strictMetaLoader) setSynced() {
 	// do nothing
 }
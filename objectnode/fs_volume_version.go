@@ -0,0 +1,656 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// Object versioning keeps the current version of an object at its object path, just like an
+// unversioned bucket does. When the current version is overwritten or deleted, its inode is
+// re-linked into the version archive instead of being released, so the data extents stay
+// referenced by the metanode until the version is deleted permanently. Delete markers are
+// empty inodes in the version archive flagged by XAttrKeyOSSDeleteMarker.
+//
+// The archive directory of a key is created in the meta partition of the parent directory of
+// the object, so that archiving the current version and linking the new current version or the
+// delete marker are applied by the metanode as one request.
+
+type DeleteObjectResult struct {
+	VersionId    string
+	DeleteMarker bool
+}
+
+type ListVersionsOption struct {
+	Prefix          string
+	Delimiter       string
+	KeyMarker       string
+	VersionIdMarker string
+	MaxKeys         uint64
+}
+
+type ObjectVersionInfo struct {
+	*FSFileInfo
+	IsLatest bool
+}
+
+type ListVersionsResult struct {
+	Versions            []*ObjectVersionInfo
+	CommonPrefixes      []string
+	NextKeyMarker       string
+	NextVersionIdMarker string
+	Truncated           bool
+}
+
+func (v *Volume) loadVersioning() *VersioningConfiguration {
+	config, err := v.metaLoader.loadVersioning()
+	if err != nil {
+		log.LogWarnf("loadVersioning: load versioning config fail: volume(%v) err(%v)", v.name, err)
+		return nil
+	}
+	return config
+}
+
+// newObjectVersionId returns the version ID of a newly written object, empty means null version.
+func (v *Volume) newObjectVersionId() string {
+	if v.loadVersioning().IsEnabled() {
+		return NewVersionId()
+	}
+	return ""
+}
+
+func versionArchivePath(key, versionId string) string {
+	return versionArchiveDir + pathSep + key + pathSep + versionId
+}
+
+func versionArchiveDirs(key string) []string {
+	return append([]string{versionArchiveDir}, strings.Split(key, pathSep)...)
+}
+
+func (v *Volume) objectVersionId(inode uint64) (versionId string, err error) {
+	var xattr *proto.XAttrInfo
+	if xattr, err = v.mw.XAttrGet_ll(inode, XAttrKeyOSSVersionId); err != nil {
+		return
+	}
+	return formatVersionId(string(xattr.Get(XAttrKeyOSSVersionId))), nil
+}
+
+// versionDir returns the version archive directory of the key, which is created in the
+// partition of the inode near. The parent directory of the object is passed as near, so the
+// versions of the key are switched in one request.
+func (v *Volume) versionDir(near uint64, key string) (dirIno uint64, err error) {
+	dirs := versionArchiveDirs(key)
+	var upper uint64
+	if upper, err = v.lookupReservedDirectories(dirs[:len(dirs)-1], true); err != nil {
+		return
+	}
+	name := dirs[len(dirs)-1]
+	dirPath := versionArchiveDir + pathSep + key
+	var mode uint32
+	if dirIno, mode, err = v.mw.Lookup_ll(upper, name); err != syscall.ENOENT {
+		if err == nil && !os.FileMode(mode).IsDir() {
+			err = syscall.EEXIST
+		}
+		return
+	}
+	if near == 0 {
+		near = upper
+	}
+	var info *proto.InodeInfo
+	if info, err = v.mw.InodeCreateNear_ll(near, uint32(DefaultDirMode), 0, 0, dirPath); err != nil {
+		log.LogErrorf("versionDir: create dir fail: volume(%v) key(%v) near(%v) err(%v)", v.name, key, near, err)
+		return
+	}
+	if err = v.mw.DentryCreate_ll(upper, name, info.Inode, uint32(DefaultDirMode), dirPath); err != nil {
+		v.releaseInode(info.Inode, dirPath)
+		if err == syscall.EEXIST {
+			// created by another request
+			return v.versionDir(near, key)
+		}
+		log.LogErrorf("versionDir: link dir fail: volume(%v) key(%v) inode(%v) err(%v)", v.name, key, info.Inode, err)
+		return
+	}
+	return info.Inode, nil
+}
+
+// relocateVersionDir moves the version archive directory of the key into the partition of the
+// parent directory, which is left in another partition if the parent directory was deleted and
+// created again. The versions are linked into the new directory before it takes the place of
+// the old one, so none of them is lost if the relocation is interrupted.
+func (v *Volume) relocateVersionDir(parentId uint64, key string, oldDir uint64) (dirIno uint64, err error) {
+	dirs := versionArchiveDirs(key)
+	var upper uint64
+	if upper, err = v.lookupReservedDirectories(dirs[:len(dirs)-1], false); err != nil {
+		return
+	}
+	dirPath := versionArchiveDir + pathSep + key
+	var children []proto.Dentry
+	if children, err = v.mw.ReadDirLimit_ll(oldDir, "", 0); err != nil {
+		return
+	}
+	var info *proto.InodeInfo
+	if info, err = v.mw.InodeCreateNear_ll(parentId, uint32(DefaultDirMode), 0, 0, dirPath); err != nil {
+		return
+	}
+	for _, child := range children {
+		err = v.mw.DentryCreate_ll(info.Inode, child.Name, child.Inode, child.Type, dirPath+pathSep+child.Name)
+		if err != nil && err != syscall.EEXIST {
+			log.LogErrorf("relocateVersionDir: link version fail: volume(%v) key(%v) version(%v) err(%v)",
+				v.name, key, child.Name, err)
+			v.releaseInode(info.Inode, dirPath)
+			return
+		}
+	}
+	var replaced uint64
+	if replaced, err = v.mw.DentryUpdate_ll(upper, dirs[len(dirs)-1], info.Inode, dirPath); err != nil {
+		v.releaseInode(info.Inode, dirPath)
+		return
+	}
+	if replaced != oldDir {
+		log.LogWarnf("relocateVersionDir: dir replaced concurrently: volume(%v) key(%v) old(%v) replaced(%v)",
+			v.name, key, oldDir, replaced)
+	}
+	for _, child := range children {
+		if _, err = v.mw.DentryDelete_ll(oldDir, child.Name, dirPath+pathSep+child.Name); err != nil {
+			log.LogWarnf("relocateVersionDir: unlink old version fail: volume(%v) key(%v) version(%v) err(%v)",
+				v.name, key, child.Name, err)
+		}
+	}
+	v.releaseInode(oldDir, dirPath)
+	log.LogInfof("relocateVersionDir: volume(%v) key(%v) old(%v) new(%v) versions(%v)",
+		v.name, key, oldDir, info.Inode, len(children))
+	return info.Inode, nil
+}
+
+// archiveDentry applies the request switching the versions of the key, and releases the
+// versions unlinked by it.
+func (v *Volume) archiveDentry(req *proto.ArchiveDentryRequest, key string) (resp *proto.ArchiveDentryResponse, err error) {
+	req.FullPaths = []string{pathSep + key}
+	resp, err = v.mw.ArchiveDentry_ll(req)
+	if err == syscall.EXDEV {
+		if req.ArchiveID, err = v.relocateVersionDir(req.ParentID, key, req.ArchiveID); err != nil {
+			log.LogErrorf("archiveDentry: relocate archive dir fail: volume(%v) key(%v) err(%v)", v.name, key, err)
+			return
+		}
+		resp, err = v.mw.ArchiveDentry_ll(req)
+	}
+	if err != nil {
+		log.LogErrorf("archiveDentry: volume(%v) key(%v) req(%+v) err(%v)", v.name, key, req, err)
+		return
+	}
+	if resp.Dropped != 0 {
+		if evictErr := v.ec.EvictStream(resp.Dropped); evictErr != nil {
+			log.LogWarnf("archiveDentry: evict stream fail: volume(%v) key(%v) inode(%v) err(%v)",
+				v.name, key, resp.Dropped, evictErr)
+		}
+		v.releaseInode(resp.Dropped, pathSep+key)
+		deleteAttrCache(resp.Dropped, v.name)
+	}
+	for _, inode := range resp.Replaced {
+		v.releaseInode(inode, versionArchiveDir+pathSep+key)
+	}
+	if req.Inode != 0 {
+		deleteDentryCache(req.ParentID, req.Name, v.name)
+	}
+	log.LogDebugf("archiveDentry: volume(%v) key(%v) req(%+v) resp(%+v)", v.name, key, req, resp)
+	return
+}
+
+// replaceCurrentVersion links the inode as the current version of the key and archives the
+// replaced version in the same request. It returns false if versioning is not active or the
+// replaced version is not kept, and the caller replaces it as an unversioned bucket does.
+func (v *Volume) replaceCurrentVersion(parentID uint64, name string, inode uint64, isCompleteMultipart bool,
+	fullPath string, storageClass uint32) (replaced bool, err error) {
+	config := v.loadVersioning()
+	if !config.IsActive() {
+		return false, nil
+	}
+	key := strings.TrimPrefix(fullPath, pathSep)
+	var oldInode uint64
+	if oldInode, _, err = v.mw.Lookup_ll(parentID, name); err != nil {
+		return
+	}
+	if oldInode == inode {
+		return true, nil
+	}
+	if isCompleteMultipart {
+		// the concurrent completeMultipart requests of the same upload share the extents, which are
+		// not kept as two versions
+		var isSameExtent bool
+		if isSameExtent, err = v.referenceExtentKey(oldInode, inode, storageClass); err != nil || isSameExtent {
+			return false, err
+		}
+	}
+	if !config.IsEnabled() {
+		// the new current version is a null version, so the archived one is obsolete
+		if err = v.purgeNullVersion(key); err != nil {
+			return
+		}
+	}
+	var versionId string
+	if versionId, err = v.objectVersionId(oldInode); err != nil {
+		log.LogErrorf("replaceCurrentVersion: get version id fail: volume(%v) key(%v) inode(%v) err(%v)",
+			v.name, key, oldInode, err)
+		return
+	}
+	if versionId == NullVersionId && !config.IsEnabled() {
+		return false, nil
+	}
+	var dirIno uint64
+	if dirIno, err = v.versionDir(parentID, key); err != nil {
+		log.LogErrorf("replaceCurrentVersion: make archive dir fail: volume(%v) key(%v) err(%v)", v.name, key, err)
+		return
+	}
+	if _, err = v.archiveDentry(&proto.ArchiveDentryRequest{
+		ParentID:    parentID,
+		Name:        name,
+		Inode:       oldInode,
+		NewInode:    inode,
+		ArchiveID:   dirIno,
+		ArchiveName: versionId,
+	}, key); err != nil {
+		return
+	}
+	log.LogDebugf("replaceCurrentVersion: volume(%v) key(%v) inode(%v) archived(%v) versionId(%v)",
+		v.name, key, inode, oldInode, versionId)
+	return true, nil
+}
+
+func (v *Volume) releaseInode(inode uint64, path string) {
+	if _, err := v.mw.InodeUnlink_ll(inode, path); err != nil {
+		log.LogWarnf("releaseInode: unlink inode fail: volume(%v) path(%v) inode(%v) err(%v)",
+			v.name, path, inode, err)
+	}
+	if err := v.mw.Evict(inode, path); err != nil {
+		log.LogWarnf("releaseInode: evict inode fail: volume(%v) path(%v) inode(%v) err(%v)",
+			v.name, path, inode, err)
+	}
+}
+
+// purgeNullVersion permanently deletes the archived null version of the key if exists,
+// since there is at most one null version of every key.
+func (v *Volume) purgeNullVersion(key string) (err error) {
+	if err = v.deleteArchivedVersion(key, NullVersionId, nil); err == syscall.ENOENT {
+		err = nil
+	}
+	return
+}
+
+func (v *Volume) deleteArchivedVersion(key, versionId string, result *DeleteObjectResult) (err error) {
+	var dirIno uint64
+	if dirIno, err = v.lookupReservedDirectories(versionArchiveDirs(key), false); err != nil {
+		return
+	}
+	var inode uint64
+	if inode, _, err = v.mw.Lookup_ll(dirIno, versionId); err != nil {
+		return
+	}
+	var xattr *proto.XAttrInfo
+	if xattr, err = v.mw.XAttrGetAll_ll(inode); err != nil {
+		return
+	}
+	if result != nil {
+		result.DeleteMarker = len(xattr.Get(XAttrKeyOSSDeleteMarker)) > 0
+	}
+	archivePath := versionArchivePath(key, versionId)
+	if objectLock, _ := v.metaLoader.loadObjectLock(); objectLock != nil {
+		if err = isObjectLocked(v, inode, versionId, archivePath); err != nil {
+			return
+		}
+	}
+	if _, err = v.mw.Delete_ll(dirIno, versionId, false, archivePath); err != nil {
+		log.LogErrorf("deleteArchivedVersion: delete dentry fail: volume(%v) key(%v) versionId(%v) err(%v)",
+			v.name, key, versionId, err)
+		return
+	}
+	if err = v.ec.EvictStream(inode); err != nil {
+		log.LogWarnf("deleteArchivedVersion: evict stream fail: volume(%v) key(%v) inode(%v) err(%v)",
+			v.name, key, inode, err)
+	}
+	if err = v.mw.Evict(inode, archivePath); err != nil {
+		log.LogWarnf("deleteArchivedVersion: evict inode fail: volume(%v) key(%v) inode(%v) err(%v)",
+			v.name, key, inode, err)
+	}
+	log.LogInfof("deleteArchivedVersion: volume(%v) key(%v) versionId(%v) inode(%v)", v.name, key, versionId, inode)
+	return nil
+}
+
+// DeleteObject deletes the object or one of its versions.
+// If versionId is empty and versioning is active, the current version is kept as a noncurrent
+// version and a delete marker becomes the latest version. Otherwise the specified version is
+// removed permanently, and if it was the current version, the newest noncurrent version takes
+// its place.
+func (v *Volume) DeleteObject(path, versionId string) (result *DeleteObjectResult, err error) {
+	defer func() {
+		log.LogInfof("Audit: DeleteObject: volume(%v) path(%v) versionId(%v) result(%+v) err(%v)",
+			v.name, path, versionId, result, err)
+	}()
+	result = &DeleteObjectResult{}
+	config := v.loadVersioning()
+	if versionId == "" && !config.IsActive() {
+		err = v.DeletePath(path)
+		return
+	}
+	if versionId != "" && !isValidVersionId(versionId) {
+		err = InvalidVersionId
+		return
+	}
+
+	key := strings.TrimPrefix(path, pathSep)
+	parent, ino, name, mode, err := v.recursiveLookupTarget(path, true)
+	if err != nil && err != syscall.ENOENT {
+		return
+	}
+	exist := err == nil
+	err = nil
+	if exist && mode.IsDir() {
+		// directories are not versioned
+		err = v.DeletePath(path)
+		return
+	}
+	var currentVersionId string
+	if exist {
+		if currentVersionId, err = v.objectVersionId(ino); err != nil {
+			return
+		}
+	}
+
+	if versionId == "" {
+		result.DeleteMarker = true
+		result.VersionId = NullVersionId
+		if config.IsEnabled() {
+			result.VersionId = NewVersionId()
+		}
+		req := &proto.ArchiveDentryRequest{
+			MarkerName:  result.VersionId,
+			MarkerMode:  uint32(DefaultFileMode),
+			MarkerAttrs: map[string]string{XAttrKeyOSSDeleteMarker: "true"},
+		}
+		if result.VersionId != NullVersionId {
+			req.MarkerAttrs[XAttrKeyOSSVersionId] = result.VersionId
+		}
+		// the null version archived is replaced by the null version archived or the null marker
+		if currentVersionId == NullVersionId || !config.IsEnabled() {
+			if err = v.purgeNullVersion(key); err != nil {
+				return
+			}
+		}
+		var near uint64
+		if exist {
+			req.ParentID, req.Name, req.Inode, near = parent, name, ino, parent
+			// the null version is replaced by the null marker if versioning is suspended
+			if currentVersionId != NullVersionId || config.IsEnabled() {
+				req.ArchiveName = currentVersionId
+			}
+		}
+		if req.ArchiveID, err = v.versionDir(near, key); err != nil {
+			return
+		}
+		_, err = v.archiveDentry(req, key)
+		return
+	}
+
+	result.VersionId = versionId
+	if exist && currentVersionId == versionId {
+		err = v.deleteCurrentVersion(parent, ino, name, path)
+		return
+	}
+	if err = v.deleteArchivedVersion(key, versionId, result); err == syscall.ENOENT {
+		err = nil
+	}
+	return
+}
+
+// deleteCurrentVersion deletes the current version of the key permanently, and makes the newest
+// noncurrent version the current version in the same request. Nothing is promoted if the newest
+// version is a delete marker.
+func (v *Volume) deleteCurrentVersion(parent, ino uint64, name, path string) (err error) {
+	key := strings.TrimPrefix(path, pathSep)
+	var dirIno uint64
+	if dirIno, err = v.lookupReservedDirectories(versionArchiveDirs(key), false); err == syscall.ENOENT {
+		return v.DeletePath(path)
+	}
+	if err != nil {
+		return
+	}
+	var children []proto.Dentry
+	if children, err = v.mw.ReadDirLimit_ll(dirIno, "", 0); err != nil {
+		return
+	}
+	req := &proto.ArchiveDentryRequest{
+		ParentID:  parent,
+		Name:      name,
+		Inode:     ino,
+		ArchiveID: dirIno,
+	}
+	for _, child := range children {
+		if !os.FileMode(child.Type).IsRegular() {
+			continue
+		}
+		var xattr *proto.XAttrInfo
+		if xattr, err = v.mw.XAttrGet_ll(child.Inode, XAttrKeyOSSDeleteMarker); err != nil {
+			return
+		}
+		if len(xattr.Get(XAttrKeyOSSDeleteMarker)) == 0 {
+			req.Promote = child.Name
+		}
+		break
+	}
+	_, err = v.archiveDentry(req, key)
+	return
+}
+
+// lookupObjectVersion returns the inode of the specified version of the object, isCurrent
+// reports whether it is the current version linked at the object path.
+func (v *Volume) lookupObjectVersion(path, versionId string) (inode uint64, mode os.FileMode, isCurrent bool, err error) {
+	if !isValidVersionId(versionId) {
+		err = InvalidVersionId
+		return
+	}
+	_, inode, _, mode, err = v.recursiveLookupTarget(path, false)
+	if err != nil && err != syscall.ENOENT {
+		return
+	}
+	if err == nil && mode.IsRegular() {
+		var currentVersionId string
+		if currentVersionId, err = v.objectVersionId(inode); err != nil {
+			return
+		}
+		if currentVersionId == versionId {
+			isCurrent = true
+			return
+		}
+	}
+
+	key := strings.TrimPrefix(path, pathSep)
+	var dirIno uint64
+	if dirIno, err = v.lookupReservedDirectories(versionArchiveDirs(key), false); err != nil {
+		return
+	}
+	var rawMode uint32
+	if inode, rawMode, err = v.mw.Lookup_ll(dirIno, versionId); err != nil {
+		return
+	}
+	mode = os.FileMode(rawMode)
+	return
+}
+
+// ObjectVersionMeta returns the meta of the specified version of the object.
+// An empty version ID refers to the current version.
+func (v *Volume) ObjectVersionMeta(path, versionId string) (info *FSFileInfo, xattr *proto.XAttrInfo, err error) {
+	if versionId == "" {
+		return v.ObjectMeta(path)
+	}
+	var (
+		inode     uint64
+		mode      os.FileMode
+		isCurrent bool
+	)
+	if inode, mode, isCurrent, err = v.lookupObjectVersion(path, versionId); err != nil {
+		return
+	}
+	if isCurrent {
+		return v.ObjectMeta(path)
+	}
+	var inoInfo *proto.InodeInfo
+	if inoInfo, err = v.mw.InodeGet_ll(inode); err != nil {
+		return
+	}
+	if info, xattr, err = v.objectMetaByInode(path, inode, mode, inoInfo); err != nil {
+		return
+	}
+	// the version ID of null version is not stored in xattr
+	info.VersionId = versionId
+	return
+}
+
+// ListObjectVersions lists current versions, noncurrent versions and delete markers in key order.
+// Versions of the same key are listed from the newest to the oldest, since the null version ID
+// sorts after all generated version IDs, the archived null version of a key is listed last.
+func (v *Volume) ListObjectVersions(opt *ListVersionsOption) (result *ListVersionsResult, err error) {
+	result = &ListVersionsResult{}
+	maxKeys := opt.MaxKeys
+	if maxKeys == 0 {
+		return
+	}
+
+	// Each source is listed one more than max keys to determine whether the result is truncated.
+	var currents []*FSFileInfo
+	if currents, _, _, err = v.listFilesV1(opt.Prefix, opt.KeyMarker, "", maxKeys+1, true); err != nil {
+		log.LogErrorf("ListObjectVersions: list current versions fail: volume(%v) opt(%+v) err(%v)", v.name, opt, err)
+		return
+	}
+	var archived []*FSFileInfo
+	if archived, err = v.listArchivedVersions(opt, maxKeys+1); err != nil {
+		log.LogErrorf("ListObjectVersions: list noncurrent versions fail: volume(%v) opt(%+v) err(%v)", v.name, opt, err)
+		return
+	}
+
+	currentKeys := make(map[string]struct{}, len(currents))
+	for _, current := range currents {
+		currentKeys[current.Path] = struct{}{}
+	}
+	// the key whose newer versions have been listed
+	var prevKey string
+	if opt.VersionIdMarker != "" {
+		prevKey = opt.KeyMarker
+	}
+
+	prefixMap := PrefixMap(make(map[string]struct{}))
+	var count uint64
+	i, j := 0, 0
+	for i < len(currents) || j < len(archived) {
+		next := &ObjectVersionInfo{}
+		if j >= len(archived) || (i < len(currents) && currents[i].Path <= archived[j].Path) {
+			next.FSFileInfo = currents[i]
+			next.VersionId = formatVersionId(next.VersionId)
+			next.IsLatest = true
+			i++
+		} else {
+			next.FSFileInfo = archived[j]
+			// a delete marker is the latest version if there is no current version of the key
+			_, hasCurrent := currentKeys[next.Path]
+			next.IsLatest = next.DeleteMarker && !hasCurrent && next.Path != prevKey
+			j++
+		}
+		prevKey = next.Path
+		if count >= maxKeys {
+			result.Truncated = true
+			break
+		}
+		if opt.Delimiter != "" {
+			nonPrefixPart := strings.TrimPrefix(next.Path, opt.Prefix)
+			if idx := strings.Index(nonPrefixPart, opt.Delimiter); idx >= 0 {
+				commonPrefix := opt.Prefix + nonPrefixPart[:idx] + opt.Delimiter
+				if !prefixMap.contain(commonPrefix) {
+					prefixMap.AddPrefix(commonPrefix)
+					count++
+				}
+				result.NextKeyMarker, result.NextVersionIdMarker = next.Path, next.VersionId
+				continue
+			}
+		}
+		result.Versions = append(result.Versions, next)
+		result.NextKeyMarker, result.NextVersionIdMarker = next.Path, next.VersionId
+		count++
+	}
+	if !result.Truncated {
+		result.NextKeyMarker, result.NextVersionIdMarker = "", ""
+	}
+	result.CommonPrefixes = prefixMap.Prefixes()
+	return
+}
+
+// listArchivedVersions lists noncurrent versions and delete markers after the markers, the
+// path of returned file info is the object key and the version ID is filled.
+func (v *Volume) listArchivedVersions(opt *ListVersionsOption, limit uint64) (infos []*FSFileInfo, err error) {
+	var archiveIno uint64
+	if archiveIno, err = v.lookupReservedDirectories([]string{versionArchiveDir}, false); err == syscall.ENOENT {
+		return nil, nil
+	}
+	if err != nil {
+		return
+	}
+	parentId, dirs, err := v.findParentIdFrom(archiveIno, opt.Prefix)
+	if err == syscall.ENOENT {
+		return nil, nil
+	}
+	if err != nil {
+		return
+	}
+
+	marker := opt.KeyMarker
+	if opt.KeyMarker != "" && opt.VersionIdMarker != "" {
+		marker = opt.KeyMarker + pathSep + opt.VersionIdMarker
+	}
+	for uint64(len(infos)) < limit {
+		var (
+			batch      []*FSFileInfo
+			nextMarker string
+		)
+		batch, _, nextMarker, _, err = v.recursiveScan(nil, PrefixMap(make(map[string]struct{})), parentId, limit,
+			limit, 0, dirs, opt.Prefix, marker, "", true, true)
+		if err != nil {
+			return
+		}
+		if len(batch) == 0 {
+			break
+		}
+		// the marker of recursive scan is exclusive, continue from the last scanned version
+		marker = batch[len(batch)-1].Path
+		for _, info := range batch {
+			key, versionId := splitVersionPath(info.Path)
+			// all versions of key marker have been listed if version id marker is not specified
+			if key == opt.KeyMarker && opt.VersionIdMarker == "" {
+				continue
+			}
+			info.Path, info.VersionId = key, versionId
+			infos = append(infos, info)
+		}
+		if nextMarker == "" {
+			break
+		}
+	}
+	if uint64(len(infos)) > limit {
+		infos = infos[:limit]
+	}
+	err = v.supplyListFileInfo(infos)
+	return
+}
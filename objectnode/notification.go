@@ -0,0 +1,333 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/EventNotifications.html
+
+const (
+	MaxNotificationConfigSize = 128 << 10 // 128KB
+
+	NotificationARNPrefix = "arn:cubefs:sqs:"
+
+	NotificationFilterPrefix = "prefix"
+	NotificationFilterSuffix = "suffix"
+)
+
+// Supported event types of bucket notification.
+const (
+	EventObjectCreatedAll                     = "s3:ObjectCreated:*"
+	EventObjectCreatedPut                     = "s3:ObjectCreated:Put"
+	EventObjectCreatedPost                    = "s3:ObjectCreated:Post"
+	EventObjectCreatedCopy                    = "s3:ObjectCreated:Copy"
+	EventObjectCreatedCompleteMultipartUpload = "s3:ObjectCreated:CompleteMultipartUpload"
+	EventObjectRemovedAll                     = "s3:ObjectRemoved:*"
+	EventObjectRemovedDelete                  = "s3:ObjectRemoved:Delete"
+	EventObjectRemovedDeleteMarkerCreated     = "s3:ObjectRemoved:DeleteMarkerCreated"
+)
+
+var supportedNotificationEvents = map[string]struct{}{
+	EventObjectCreatedAll:                     {},
+	EventObjectCreatedPut:                     {},
+	EventObjectCreatedPost:                    {},
+	EventObjectCreatedCopy:                    {},
+	EventObjectCreatedCompleteMultipartUpload: {},
+	EventObjectRemovedAll:                     {},
+	EventObjectRemovedDelete:                  {},
+	EventObjectRemovedDeleteMarkerCreated:     {},
+}
+
+// NotificationConfiguration is the event notification configuration of a bucket.
+// Only queue destinations are supported, the queue is the ARN of a notification target
+// configured on ObjectNode, see NotificationConfig.
+type NotificationConfiguration struct {
+	XMLNS                       string                `xml:"xmlns,attr,omitempty" json:"-"`
+	XMLName                     xml.Name              `xml:"NotificationConfiguration" json:"-"`
+	QueueConfigurations         []*QueueConfiguration `xml:"QueueConfiguration" json:"queues,omitempty"`
+	TopicConfigurations         []xmlAnyElement       `xml:"TopicConfiguration" json:"-"`
+	CloudFunctionConfigurations []xmlAnyElement       `xml:"CloudFunctionConfiguration" json:"-"`
+}
+
+type xmlAnyElement struct {
+	XMLName xml.Name
+}
+
+type QueueConfiguration struct {
+	ID     string              `xml:"Id,omitempty" json:"id"`
+	Queue  string              `xml:"Queue" json:"queue"`
+	Events []string            `xml:"Event" json:"events"`
+	Filter *NotificationFilter `xml:"Filter,omitempty" json:"filter,omitempty"`
+}
+
+type NotificationFilter struct {
+	S3Key NotificationS3KeyFilter `xml:"S3Key" json:"s3_key"`
+}
+
+type NotificationS3KeyFilter struct {
+	FilterRules []NotificationFilterRule `xml:"FilterRule" json:"rules"`
+}
+
+type NotificationFilterRule struct {
+	Name  string `xml:"Name" json:"name"`
+	Value string `xml:"Value" json:"value"`
+}
+
+// ParseNotificationConfig parses the notification configuration from request body,
+// hasTarget reports whether the queue ARN refers to a notification target of ObjectNode.
+func ParseNotificationConfig(body []byte, hasTarget func(arn string) bool) (*NotificationConfiguration, error) {
+	config := &NotificationConfiguration{}
+	if err := UnmarshalXMLEntity(body, config); err != nil {
+		return nil, MalformedXML
+	}
+	if len(config.TopicConfigurations) > 0 || len(config.CloudFunctionConfigurations) > 0 {
+		return nil, NotImplemented
+	}
+	if err := config.validate(hasTarget); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (c *NotificationConfiguration) validate(hasTarget func(arn string) bool) error {
+	ids := make(map[string]struct{}, len(c.QueueConfigurations))
+	for i, queue := range c.QueueConfigurations {
+		if queue == nil {
+			return MalformedXML
+		}
+		if queue.ID == "" {
+			queue.ID = fmt.Sprintf("notification-%d", i+1)
+		}
+		if _, exist := ids[queue.ID]; exist {
+			return InvalidNotificationID
+		}
+		ids[queue.ID] = struct{}{}
+		if len(queue.Events) == 0 {
+			return InvalidNotificationEvent
+		}
+		for _, event := range queue.Events {
+			if _, ok := supportedNotificationEvents[event]; !ok {
+				return InvalidNotificationEvent
+			}
+		}
+		if err := queue.Filter.validate(); err != nil {
+			return err
+		}
+		if !hasTarget(queue.Queue) {
+			return InvalidNotificationDestination
+		}
+	}
+	return nil
+}
+
+func (f *NotificationFilter) validate() error {
+	if f == nil {
+		return nil
+	}
+	names := make(map[string]struct{}, 2)
+	for i := range f.S3Key.FilterRules {
+		rule := &f.S3Key.FilterRules[i]
+		rule.Name = strings.ToLower(rule.Name)
+		if rule.Name != NotificationFilterPrefix && rule.Name != NotificationFilterSuffix {
+			return InvalidNotificationFilter
+		}
+		if _, exist := names[rule.Name]; exist {
+			return InvalidNotificationFilter
+		}
+		names[rule.Name] = struct{}{}
+	}
+	return nil
+}
+
+func (f *NotificationFilter) match(key string) bool {
+	if f == nil {
+		return true
+	}
+	for _, rule := range f.S3Key.FilterRules {
+		switch rule.Name {
+		case NotificationFilterPrefix:
+			if !strings.HasPrefix(key, rule.Value) {
+				return false
+			}
+		case NotificationFilterSuffix:
+			if !strings.HasSuffix(key, rule.Value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (q *QueueConfiguration) matchEvent(eventName string) bool {
+	for _, event := range q.Events {
+		if event == eventName {
+			return true
+		}
+		if strings.HasSuffix(event, "*") && strings.HasPrefix(eventName, strings.TrimSuffix(event, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match returns the queue configurations which should be notified of the event of the object.
+func (c *NotificationConfiguration) Match(eventName, key string) []*QueueConfiguration {
+	if c == nil {
+		return nil
+	}
+	var queues []*QueueConfiguration
+	for _, queue := range c.QueueConfigurations {
+		if queue.matchEvent(eventName) && queue.Filter.match(key) {
+			queues = append(queues, queue)
+		}
+	}
+	return queues
+}
+
+// parseNotificationARN parses the ARN in form of "arn:cubefs:sqs:<region>:<id>:<type>",
+// and returns the name of notification target which is "<type>:<id>".
+func parseNotificationARN(arn string) (target string, ok bool) {
+	if !strings.HasPrefix(arn, NotificationARNPrefix) {
+		return
+	}
+	parts := strings.Split(arn, ":")
+	if len(parts) != 6 || parts[4] == "" || parts[5] == "" {
+		return
+	}
+	return parts[5] + ":" + parts[4], true
+}
+
+func storeBucketNotification(bytes []byte, vol *Volume) (err error) {
+	return vol.store.Put(vol.name, bucketRootPath, XAttrKeyOSSNotification, bytes)
+}
+
+func deleteBucketNotification(vol *Volume) (err error) {
+	return vol.store.Delete(vol.name, bucketRootPath, XAttrKeyOSSNotification)
+}
+
+// NotificationEvent is the message delivered to notification targets, which is compatible
+// with the event message structure of Amazon S3.
+type NotificationEvent struct {
+	Records []NotificationRecord `json:"Records"`
+}
+
+type NotificationRecord struct {
+	EventVersion      string                 `json:"eventVersion"`
+	EventSource       string                 `json:"eventSource"`
+	AwsRegion         string                 `json:"awsRegion"`
+	EventTime         string                 `json:"eventTime"`
+	EventName         string                 `json:"eventName"`
+	UserIdentity      NotificationIdentity   `json:"userIdentity"`
+	RequestParameters map[string]string      `json:"requestParameters"`
+	ResponseElements  map[string]string      `json:"responseElements"`
+	S3                NotificationS3Metadata `json:"s3"`
+}
+
+type NotificationIdentity struct {
+	PrincipalID string `json:"principalId"`
+}
+
+type NotificationS3Metadata struct {
+	SchemaVersion   string             `json:"s3SchemaVersion"`
+	ConfigurationID string             `json:"configurationId"`
+	Bucket          NotificationBucket `json:"bucket"`
+	Object          NotificationObject `json:"object"`
+}
+
+type NotificationBucket struct {
+	Name          string               `json:"name"`
+	OwnerIdentity NotificationIdentity `json:"ownerIdentity"`
+	ARN           string               `json:"arn"`
+}
+
+type NotificationObject struct {
+	Key       string `json:"key"`
+	Size      int64  `json:"size,omitempty"`
+	ETag      string `json:"eTag,omitempty"`
+	VersionID string `json:"versionId,omitempty"`
+	Sequencer string `json:"sequencer"`
+}
+
+// notifyObjectEvent publishes the event of the object to the targets of all matched notification
+// configurations of the bucket. The request never fails because of notifications, errors are logged.
+func (o *ObjectNode) notifyObjectEvent(param *RequestParam, vol *Volume, eventName string, object NotificationObject) {
+	if o.notifier == nil {
+		return
+	}
+	config, err := vol.metaLoader.loadNotification()
+	if err != nil {
+		log.LogWarnf("notifyObjectEvent: load notification config fail: requestID(%v) volume(%v) err(%v)",
+			param.RequestID(), vol.Name(), err)
+		return
+	}
+	queues := config.Match(eventName, object.Key)
+	if len(queues) == 0 {
+		return
+	}
+
+	key := object.Key
+	now := time.Now().UTC()
+	object.Key = url.QueryEscape(key)
+	object.Sequencer = fmt.Sprintf("%016X", now.UnixNano())
+	record := NotificationRecord{
+		EventVersion:      "2.1",
+		EventSource:       "cubefs:s3",
+		AwsRegion:         o.region,
+		EventTime:         now.Format(time.RFC3339Nano),
+		EventName:         strings.TrimPrefix(eventName, "s3:"),
+		UserIdentity:      NotificationIdentity{PrincipalID: param.Requester()},
+		RequestParameters: map[string]string{"sourceIPAddress": param.sourceIP},
+		ResponseElements:  map[string]string{"x-amz-request-id": param.RequestID()},
+		S3: NotificationS3Metadata{
+			SchemaVersion: "1.0",
+			Bucket: NotificationBucket{
+				Name:          vol.Name(),
+				OwnerIdentity: NotificationIdentity{PrincipalID: vol.owner},
+				ARN:           "arn:aws:s3:::" + vol.Name(),
+			},
+			Object: object,
+		},
+	}
+	for _, queue := range queues {
+		record.S3.ConfigurationID = queue.ID
+		data, err := json.Marshal(&NotificationEvent{Records: []NotificationRecord{record}})
+		if err != nil {
+			log.LogErrorf("notifyObjectEvent: json.Marshal event fail: requestID(%v) volume(%v) record(%+v) err(%v)",
+				param.RequestID(), vol.Name(), record, err)
+			return
+		}
+		o.notifier.Publish(queue.Queue, vol.Name()+pathSep+key, data)
+	}
+}
+
+// notifyObjectRemoved publishes the removal event of the object. Deleting without version ID
+// creates a delete marker if versioning is active, while the others remove the object permanently.
+func (o *ObjectNode) notifyObjectRemoved(param *RequestParam, vol *Volume, key, versionId string, result *DeleteObjectResult) {
+	eventName := EventObjectRemovedDelete
+	if result != nil && result.DeleteMarker && versionId == "" {
+		eventName = EventObjectRemovedDeleteMarkerCreated
+		versionId = result.VersionId
+	}
+	o.notifyObjectEvent(param, vol, eventName, NotificationObject{Key: key, VersionID: versionId})
+}
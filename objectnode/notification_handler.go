@@ -0,0 +1,138 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// Get Bucket Notification Configuration
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketNotificationConfiguration.html
+func (o *ObjectNode) getBucketNotificationHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	param := ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("getBucketNotificationHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+
+	var config *NotificationConfiguration
+	if config, err = vol.metaLoader.loadNotification(); err != nil {
+		log.LogErrorf("getBucketNotificationHandler: load notification fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	// an empty configuration is returned if notification is not configured
+	output := &NotificationConfiguration{XMLNS: S3Namespace}
+	if config != nil {
+		output.QueueConfigurations = config.QueueConfigurations
+	}
+	var data []byte
+	if data, err = MarshalXMLEntity(output); err != nil {
+		log.LogErrorf("getBucketNotificationHandler: xml marshal fail: requestID(%v) volume(%v) config(%+v) err(%v)",
+			GetRequestID(r), vol.Name(), output, err)
+		return
+	}
+
+	writeSuccessResponseXML(w, data)
+}
+
+// Put Bucket Notification Configuration
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketNotificationConfiguration.html
+func (o *ObjectNode) putBucketNotificationHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	param := ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("putBucketNotificationHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+
+	var body []byte
+	if body, err = io.ReadAll(io.LimitReader(r.Body, MaxNotificationConfigSize+1)); err != nil {
+		log.LogErrorf("putBucketNotificationHandler: read request body fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	if len(body) > MaxNotificationConfigSize {
+		errorCode = EntityTooLarge
+		return
+	}
+	var config *NotificationConfiguration
+	if config, err = ParseNotificationConfig(body, o.notifier.HasTarget); err != nil {
+		log.LogErrorf("putBucketNotificationHandler: parse notification config fail: requestID(%v) volume(%v) config(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(body), err)
+		return
+	}
+
+	// an empty configuration disables notifications on the bucket
+	if len(config.QueueConfigurations) == 0 {
+		if err = deleteBucketNotification(vol); err != nil {
+			log.LogErrorf("putBucketNotificationHandler: delete notification config fail: requestID(%v) volume(%v) err(%v)",
+				GetRequestID(r), vol.Name(), err)
+			return
+		}
+		vol.metaLoader.storeNotification(nil)
+		log.LogInfof("Audit: putBucketNotificationHandler: notification disabled: requestID(%v) volume(%v)",
+			GetRequestID(r), vol.Name())
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if body, err = json.Marshal(config); err != nil {
+		log.LogErrorf("putBucketNotificationHandler: json.Marshal notification config fail: requestID(%v) volume(%v) config(%+v) err(%v)",
+			GetRequestID(r), vol.Name(), config, err)
+		return
+	}
+	if err = storeBucketNotification(body, vol); err != nil {
+		log.LogErrorf("putBucketNotificationHandler: store notification config fail: requestID(%v) volume(%v) config(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(body), err)
+		return
+	}
+	vol.metaLoader.storeNotification(config)
+	log.LogInfof("Audit: putBucketNotificationHandler: requestID(%v) volume(%v) config(%v)",
+		GetRequestID(r), vol.Name(), string(body))
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,300 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/cubefs/cubefs/blobstore/util/retry"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const (
+	NotificationTargetKafka   = "kafka"
+	NotificationTargetWebhook = "webhook"
+
+	defaultNotificationQueueSize  = 10000
+	defaultNotificationWorkers    = 4
+	defaultNotificationRetryTimes = 5
+
+	notificationWebhookTimeout = 5 * time.Second
+)
+
+var NotificationWebhookUserAgent = "Golang cubefs/objectnode notification webhook"
+
+// NotificationConfig is the configuration of bucket notification targets. The target with id
+// "<id>" of type "<type>" is referred by the ARN "arn:cubefs:sqs:<region>:<id>:<type>" in the
+// notification configuration of buckets.
+type NotificationConfig struct {
+	QueueSize  int                                  `json:"queueSize"`
+	Workers    int                                  `json:"workers"`
+	RetryTimes int                                  `json:"retryTimes"`
+	Kafka      map[string]KafkaNotificationConfig   `json:"kafka"`
+	Webhook    map[string]WebhookNotificationConfig `json:"webhook"`
+}
+
+type KafkaNotificationConfig struct {
+	Enable bool `json:"enable"`
+
+	KafkaConfig
+}
+
+type WebhookNotificationConfig struct {
+	Enable bool `json:"enable"`
+
+	WebhookConfig
+}
+
+// NotificationTarget is the destination of bucket notification events.
+type NotificationTarget interface {
+	Name() string
+	Send(key string, data []byte) error
+	Close() error
+}
+
+type KafkaNotificationTarget struct {
+	name     string
+	producer sarama.SyncProducer
+
+	KafkaNotificationConfig
+}
+
+func NewKafkaNotificationTarget(id string, conf KafkaNotificationConfig) (*KafkaNotificationTarget, error) {
+	if err := conf.FixConfig(); err != nil {
+		return nil, err
+	}
+
+	producer, err := conf.BuildSyncProducer()
+	if err != nil {
+		return nil, err
+	}
+
+	return &KafkaNotificationTarget{
+		name:                    NotificationTargetKafka + ":" + id,
+		producer:                producer,
+		KafkaNotificationConfig: conf,
+	}, nil
+}
+
+func (k *KafkaNotificationTarget) Name() string {
+	return k.name
+}
+
+// Send sends the event with the object as message key, so that events of the same object
+// are always delivered to the same partition in order.
+func (k *KafkaNotificationTarget) Send(key string, data []byte) error {
+	_, _, err := k.producer.SendMessage(&sarama.ProducerMessage{
+		Topic: k.Topic,
+		Key:   sarama.StringEncoder(key),
+		Value: sarama.ByteEncoder(data),
+	})
+
+	return err
+}
+
+func (k *KafkaNotificationTarget) Close() error {
+	var err error
+	if k.producer != nil {
+		err = k.producer.Close()
+	}
+
+	return err
+}
+
+type WebhookNotificationTarget struct {
+	name   string
+	client *http.Client
+
+	WebhookNotificationConfig
+}
+
+func NewWebhookNotificationTarget(id string, conf WebhookNotificationConfig) (*WebhookNotificationTarget, error) {
+	if err := conf.FixConfig(); err != nil {
+		return nil, err
+	}
+
+	client, err := conf.BuildClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return &WebhookNotificationTarget{
+		name:                      NotificationTargetWebhook + ":" + id,
+		client:                    client,
+		WebhookNotificationConfig: conf,
+	}, nil
+}
+
+func (w *WebhookNotificationTarget) Name() string {
+	return w.name
+}
+
+func (w *WebhookNotificationTarget) Send(key string, data []byte) error {
+	req, err := http.NewRequest(http.MethodPost, w.Endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set(ContentType, ValueContentTypeJSON)
+	req.Header.Set(UserAgent, NotificationWebhookUserAgent)
+	if w.Authorization != "" {
+		req.Header.Set(Authorization, w.Authorization)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), notificationWebhookTimeout)
+	defer cancel()
+	resp, err := w.client.Do(req.WithContext(ctx))
+	if resp != nil && resp.Body != nil {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	if err != nil || resp.StatusCode/100 == 2 {
+		return err
+	}
+
+	return fmt.Errorf("%s returns '%s' statuscode", w.Endpoint, resp.Status)
+}
+
+func (w *WebhookNotificationTarget) Close() error {
+	return nil
+}
+
+type notificationMessage struct {
+	target NotificationTarget
+	key    string
+	data   []byte
+}
+
+// Notifier delivers bucket notification events to the targets asynchronously. Events are
+// buffered in a bounded queue and dropped if the queue is full, each event is retried with
+// exponential backoff before giving up.
+type Notifier struct {
+	targets    map[string]NotificationTarget
+	queue      chan *notificationMessage
+	retryTimes int
+	stopC      chan struct{}
+	wg         sync.WaitGroup
+}
+
+func NewNotifier(conf *NotificationConfig) (*Notifier, error) {
+	if conf.QueueSize <= 0 {
+		conf.QueueSize = defaultNotificationQueueSize
+	}
+	if conf.Workers <= 0 {
+		conf.Workers = defaultNotificationWorkers
+	}
+	if conf.RetryTimes <= 0 {
+		conf.RetryTimes = defaultNotificationRetryTimes
+	}
+	n := &Notifier{
+		targets:    make(map[string]NotificationTarget),
+		queue:      make(chan *notificationMessage, conf.QueueSize),
+		retryTimes: conf.RetryTimes,
+		stopC:      make(chan struct{}),
+	}
+	for id, cfg := range conf.Kafka {
+		if cfg.Enable {
+			target, err := NewKafkaNotificationTarget(id, cfg)
+			if err != nil {
+				n.closeTargets()
+				return nil, err
+			}
+			n.targets[target.Name()] = target
+		}
+	}
+	for id, cfg := range conf.Webhook {
+		if cfg.Enable {
+			target, err := NewWebhookNotificationTarget(id, cfg)
+			if err != nil {
+				n.closeTargets()
+				return nil, err
+			}
+			n.targets[target.Name()] = target
+		}
+	}
+	for i := 0; i < conf.Workers; i++ {
+		n.wg.Add(1)
+		go n.worker()
+	}
+
+	return n, nil
+}
+
+func (n *Notifier) target(arn string) NotificationTarget {
+	name, ok := parseNotificationARN(arn)
+	if !ok {
+		return nil
+	}
+	return n.targets[name]
+}
+
+// HasTarget reports whether the ARN refers to a configured notification target.
+func (n *Notifier) HasTarget(arn string) bool {
+	return n != nil && n.target(arn) != nil
+}
+
+// Publish puts the event into the delivery queue of the target referred by the ARN.
+func (n *Notifier) Publish(arn, key string, data []byte) {
+	target := n.target(arn)
+	if target == nil {
+		log.LogWarnf("Notifier: target not found: arn(%v) key(%v)", arn, key)
+		return
+	}
+	select {
+	case n.queue <- &notificationMessage{target: target, key: key, data: data}:
+	default:
+		log.LogErrorf("Notifier: queue is full, drop event: target(%v) key(%v) event(%v)",
+			target.Name(), key, string(data))
+	}
+}
+
+func (n *Notifier) worker() {
+	defer n.wg.Done()
+	for {
+		select {
+		case <-n.stopC:
+			return
+		case msg := <-n.queue:
+			err := retry.ExponentialBackoff(n.retryTimes, 100).On(func() error {
+				return msg.target.Send(msg.key, msg.data)
+			})
+			if err != nil {
+				log.LogErrorf("Notifier: send event fail: target(%v) key(%v) event(%v) err(%v)",
+					msg.target.Name(), msg.key, string(msg.data), err)
+			}
+		}
+	}
+}
+
+func (n *Notifier) closeTargets() {
+	for _, target := range n.targets {
+		if err := target.Close(); err != nil {
+			log.LogWarnf("Notifier: close target fail: target(%v) err(%v)", target.Name(), err)
+		}
+	}
+}
+
+// Close stops the delivery workers and closes all targets.
+func (n *Notifier) Close() {
+	close(n.stopC)
+	n.wg.Wait()
+	n.closeTargets()
+}
@@ -0,0 +1,167 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNotificationConfig(t *testing.T) {
+	hasTarget := func(arn string) bool {
+		return arn == "arn:cubefs:sqs:cfs_dev:ingest:kafka"
+	}
+	config, err := ParseNotificationConfig([]byte(`
+<NotificationConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <QueueConfiguration>
+    <Id>images</Id>
+    <Queue>arn:cubefs:sqs:cfs_dev:ingest:kafka</Queue>
+    <Event>s3:ObjectCreated:*</Event>
+    <Filter>
+      <S3Key>
+        <FilterRule><Name>Prefix</Name><Value>images/</Value></FilterRule>
+        <FilterRule><Name>Suffix</Name><Value>.jpg</Value></FilterRule>
+      </S3Key>
+    </Filter>
+  </QueueConfiguration>
+  <QueueConfiguration>
+    <Queue>arn:cubefs:sqs:cfs_dev:ingest:kafka</Queue>
+    <Event>s3:ObjectRemoved:DeleteMarkerCreated</Event>
+  </QueueConfiguration>
+</NotificationConfiguration>`), hasTarget)
+	require.NoError(t, err)
+	require.Len(t, config.QueueConfigurations, 2)
+	require.Equal(t, "notification-2", config.QueueConfigurations[1].ID)
+	require.Equal(t, NotificationFilterPrefix, config.QueueConfigurations[0].Filter.S3Key.FilterRules[0].Name)
+
+	require.Len(t, config.Match(EventObjectCreatedPut, "images/a.jpg"), 1)
+	require.Len(t, config.Match(EventObjectCreatedCompleteMultipartUpload, "images/b.jpg"), 1)
+	require.Len(t, config.Match(EventObjectCreatedPut, "images/a.png"), 0)
+	require.Len(t, config.Match(EventObjectCreatedPut, "docs/a.jpg"), 0)
+	require.Len(t, config.Match(EventObjectRemovedDeleteMarkerCreated, "docs/a.jpg"), 1)
+	require.Len(t, config.Match(EventObjectRemovedDelete, "docs/a.jpg"), 0)
+
+	var nilConfig *NotificationConfiguration
+	require.Nil(t, nilConfig.Match(EventObjectCreatedPut, "a"))
+
+	_, err = ParseNotificationConfig([]byte(`<NotificationConfiguration><QueueConfiguration>`), hasTarget)
+	require.Equal(t, MalformedXML, err)
+
+	_, err = ParseNotificationConfig([]byte(`
+<NotificationConfiguration>
+  <TopicConfiguration><Topic>arn:aws:sns:us-east-1:1:topic</Topic><Event>s3:ObjectCreated:*</Event></TopicConfiguration>
+</NotificationConfiguration>`), hasTarget)
+	require.Equal(t, NotImplemented, err)
+
+	_, err = ParseNotificationConfig([]byte(`
+<NotificationConfiguration>
+  <QueueConfiguration><Queue>arn:cubefs:sqs:cfs_dev:ingest:kafka</Queue><Event>s3:ObjectRestore:*</Event></QueueConfiguration>
+</NotificationConfiguration>`), hasTarget)
+	require.Equal(t, InvalidNotificationEvent, err)
+
+	_, err = ParseNotificationConfig([]byte(`
+<NotificationConfiguration>
+  <QueueConfiguration>
+    <Queue>arn:cubefs:sqs:cfs_dev:ingest:kafka</Queue>
+    <Event>s3:ObjectCreated:Put</Event>
+    <Filter><S3Key><FilterRule><Name>middle</Name><Value>a</Value></FilterRule></S3Key></Filter>
+  </QueueConfiguration>
+</NotificationConfiguration>`), hasTarget)
+	require.Equal(t, InvalidNotificationFilter, err)
+
+	_, err = ParseNotificationConfig([]byte(`
+<NotificationConfiguration>
+  <QueueConfiguration><Id>a</Id><Queue>arn:cubefs:sqs:cfs_dev:ingest:kafka</Queue><Event>s3:ObjectCreated:Put</Event></QueueConfiguration>
+  <QueueConfiguration><Id>a</Id><Queue>arn:cubefs:sqs:cfs_dev:ingest:kafka</Queue><Event>s3:ObjectCreated:Copy</Event></QueueConfiguration>
+</NotificationConfiguration>`), hasTarget)
+	require.Equal(t, InvalidNotificationID, err)
+
+	_, err = ParseNotificationConfig([]byte(`
+<NotificationConfiguration>
+  <QueueConfiguration><Queue>arn:cubefs:sqs:cfs_dev:other:kafka</Queue><Event>s3:ObjectCreated:Put</Event></QueueConfiguration>
+</NotificationConfiguration>`), hasTarget)
+	require.Equal(t, InvalidNotificationDestination, err)
+}
+
+func TestParseNotificationARN(t *testing.T) {
+	target, ok := parseNotificationARN("arn:cubefs:sqs:cfs_dev:pipeline:webhook")
+	require.True(t, ok)
+	require.Equal(t, "webhook:pipeline", target)
+	target, ok = parseNotificationARN("arn:cubefs:sqs::pipeline:webhook")
+	require.True(t, ok)
+	require.Equal(t, "webhook:pipeline", target)
+
+	for _, arn := range []string{"", "arn:aws:sqs:us-east-1:1:queue", "arn:cubefs:sqs:cfs_dev:pipeline", "arn:cubefs:sqs:cfs_dev::webhook"} {
+		_, ok = parseNotificationARN(arn)
+		require.False(t, ok, arn)
+	}
+}
+
+func TestNotifierPublish(t *testing.T) {
+	var (
+		lock     sync.Mutex
+		received []string
+		failures = 2
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		lock.Lock()
+		defer lock.Unlock()
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		received = append(received, string(body))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	_, err := NewNotifier(&NotificationConfig{Webhook: map[string]WebhookNotificationConfig{
+		"invalid": {Enable: true, WebhookConfig: WebhookConfig{Endpoint: "tcp://127.0.0.1:80"}},
+	}})
+	require.Error(t, err)
+
+	conf := &NotificationConfig{Workers: 1, Webhook: map[string]WebhookNotificationConfig{
+		"pipeline": {Enable: true, WebhookConfig: WebhookConfig{Endpoint: server.URL}},
+		"disabled": {Enable: false},
+	}}
+	notifier, err := NewNotifier(conf)
+	require.NoError(t, err)
+	defer notifier.Close()
+	require.Equal(t, defaultNotificationRetryTimes, conf.RetryTimes)
+
+	var nilNotifier *Notifier
+	require.False(t, nilNotifier.HasTarget("arn:cubefs:sqs:cfs_dev:pipeline:webhook"))
+	require.True(t, notifier.HasTarget("arn:cubefs:sqs:cfs_dev:pipeline:webhook"))
+	require.False(t, notifier.HasTarget("arn:cubefs:sqs:cfs_dev:disabled:webhook"))
+	require.False(t, notifier.HasTarget("arn:cubefs:sqs:cfs_dev:pipeline:kafka"))
+
+	// failed deliveries are retried
+	notifier.Publish("arn:cubefs:sqs:cfs_dev:pipeline:webhook", "vol/a", []byte(`{"Records":[]}`))
+	notifier.Publish("arn:cubefs:sqs:cfs_dev:unknown:webhook", "vol/a", []byte(`{}`))
+	require.Eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(received) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, `{"Records":[]}`, received[0])
+}
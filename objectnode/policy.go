@@ -140,176 +140,193 @@ func (p *Policy) IsAllowed(params *RequestParam, reqUid, ownerUid string, condit
 
 func (o *ObjectNode) policyCheck(f http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var (
-			err error
-			ec  *ErrorCode
-		)
-		allowed := false
-		defer func() {
-			if allowed {
-				f(w, r)
-			} else {
-				if ec == nil && err == nil {
-					ec = AccessDenied
-				}
-				o.errorResponse(w, r, err, ec)
-			}
-		}()
-
-		param := ParseRequestParam(r)
-		if param.Bucket() == "" {
-			log.LogDebugf("policyCheck: no bucket specified: requestID(%v)", GetRequestID(r))
-			allowed = true
+		// requests of website endpoint are checked against the key resolved by website configuration
+		if isWebsiteRequest(r) {
+			f(w, r)
 			return
 		}
-
-		// step1. The account level api does not need to check any user policy and volume policy.
-		if IsAccountLevelApi(param.apiName) {
-			if !isAnonymous(param.accessKey) {
-				allowed = true
-				return
-			}
-			log.LogErrorf("policyCheck: anonymous user is not allowed by api(%v) requestID(%v)",
-				param.apiName, GetRequestID(r))
-			allowed = false
+		allowed, ec, err := o.checkPolicy(r)
+		if allowed {
+			f(w, r)
 			return
 		}
-		if bucket := mux.Vars(r)[ContextKeyBucket]; len(bucket) > 0 {
-			if _, err = o.getVol(bucket); err != nil {
-				allowed = false
-				return
-			}
+		if ec == nil && err == nil {
+			ec = AccessDenied
 		}
+		o.errorResponse(w, r, err, ec)
+	}
+}
 
-		// step2. Check user policy
-		userInfo := new(proto.UserInfo)
-		var userPolicy *proto.UserPolicy
-		isOwner := false
-		if isAnonymous(param.accessKey) && apiAllowAnonymous(param.apiName) {
-			log.LogDebugf("anonymous user: requestID(%v)", GetRequestID(r))
-			goto policycheck
-		}
-		if isAnonymous(param.accessKey) && !apiAllowAnonymous(param.apiName) {
-			log.LogErrorf("policyCheck: anonymous user is not allowed by api(%v) requestID(%v)",
-				param.apiName, GetRequestID(r))
-			allowed = false
+// checkPolicy checks whether the request is allowed by user policy, bucket policy and ACL.
+func (o *ObjectNode) checkPolicy(r *http.Request) (allowed bool, ec *ErrorCode, err error) {
+	param := ParseRequestParam(r)
+	if param.Bucket() == "" {
+		log.LogDebugf("policyCheck: no bucket specified: requestID(%v)", GetRequestID(r))
+		allowed = true
+		return
+	}
+
+	// step1. The account level api does not need to check any user policy and volume policy.
+	if IsAccountLevelApi(param.apiName) {
+		if !isAnonymous(param.accessKey) {
+			allowed = true
 			return
 		}
-		userInfo, err = o.getUserInfoByAccessKey(param.AccessKey())
-		if err != nil {
-			log.LogErrorf("user policy check: load user policy from master fail: requestID(%v) accessKey(%v) err(%v)",
-				GetRequestID(r), param.AccessKey(), err)
+		log.LogErrorf("policyCheck: anonymous user is not allowed by api(%v) requestID(%v)",
+			param.apiName, GetRequestID(r))
+		allowed = false
+		return
+	}
+	if bucket := mux.Vars(r)[ContextKeyBucket]; len(bucket) > 0 {
+		if _, err = o.getVol(bucket); err != nil {
 			allowed = false
 			return
 		}
-		// White list for admin and root user.
-		if userInfo.UserType == proto.UserTypeRoot || userInfo.UserType == proto.UserTypeAdmin {
-			log.LogDebugf("user policy check: user is admin: requestID(%v) userID(%v) accessKey(%v) volume(%v)",
-				GetRequestID(r), userInfo.UserID, param.AccessKey(), param.Bucket())
-			allowed = true
+	}
+
+	// step2. Check user policy
+	userInfo := new(proto.UserInfo)
+	var userPolicy *proto.UserPolicy
+	isOwner := false
+	if isAnonymous(param.accessKey) && apiAllowAnonymous(param.apiName) {
+		log.LogDebugf("anonymous user: requestID(%v)", GetRequestID(r))
+		goto policycheck
+	}
+	if isAnonymous(param.accessKey) && !apiAllowAnonymous(param.apiName) {
+		log.LogErrorf("policyCheck: anonymous user is not allowed by api(%v) requestID(%v)",
+			param.apiName, GetRequestID(r))
+		allowed = false
+		return
+	}
+	userInfo, err = o.getUserInfoByAccessKey(param.AccessKey())
+	if err != nil {
+		log.LogErrorf("user policy check: load user policy from master fail: requestID(%v) accessKey(%v) err(%v)",
+			GetRequestID(r), param.AccessKey(), err)
+		allowed = false
+		return
+	}
+	// White list for admin and root user.
+	if userInfo.UserType == proto.UserTypeRoot || userInfo.UserType == proto.UserTypeAdmin {
+		log.LogDebugf("user policy check: user is admin: requestID(%v) userID(%v) accessKey(%v) volume(%v)",
+			GetRequestID(r), userInfo.UserID, param.AccessKey(), param.Bucket())
+		allowed = true
+		return
+	}
+	userPolicy = userInfo.Policy
+	isOwner = userPolicy.IsOwn(param.Bucket())
+	// The bucket is not owned by request user who has not been authorized, so bucket policy should be checked.
+	if !isOwner && userPolicy.IsAuthorizedS3(param.Bucket(), param.apiName) {
+		log.LogInfof("user policy check:  permission url(%v) requestID(%v) userID(%v) accessKey(%v) volume(%v) object(%v) action(%v) authorizedVols(%v)",
+			r.URL, GetRequestID(r), userInfo.UserID, param.AccessKey(), param.Bucket(), param.Object(), param.Action(), userPolicy.AuthorizedVols)
+		allowed = true
+		return
+	}
+	// copy api should check srcBucket policy additionally
+	if param.apiName == COPY_OBJECT || param.apiName == UPLOAD_PART_COPY {
+		err = o.allowedBySrcBucketPolicy(param, userInfo.UserID)
+		if err != nil {
 			return
 		}
-		userPolicy = userInfo.Policy
-		isOwner = userPolicy.IsOwn(param.Bucket())
-		// The bucket is not owned by request user who has not been authorized, so bucket policy should be checked.
-		if !isOwner && userPolicy.IsAuthorizedS3(param.Bucket(), param.apiName) {
-			log.LogInfof("user policy check:  permission url(%v) requestID(%v) userID(%v) accessKey(%v) volume(%v) object(%v) action(%v) authorizedVols(%v)",
-				r.URL, GetRequestID(r), userInfo.UserID, param.AccessKey(), param.Bucket(), param.Object(), param.Action(), userPolicy.AuthorizedVols)
-			allowed = true
-			return
+	}
+	// batch delete will delay to check just before delete for each key
+	if param.apiName == BATCH_DELETE {
+		log.LogDebugf("user policy check: delete objects delay check: requestID(%v) userID(%v) volume(%v)",
+			GetRequestID(r), userInfo.UserID, param.Bucket())
+		allowed = true
+		return
+	}
+
+	// step3. Check bucket policy
+policycheck:
+	vol, acl, policy, err := o.loadBucketMeta(param.Bucket())
+	if err != nil {
+		log.LogErrorf("bucket policy check: load bucket metadata fail: requestID(%v) err(%v)", GetRequestID(r), err)
+		allowed = false
+		return
+	}
+	log.LogDebugf("bucket policy check: load bucket metadata, requestID(%v) userPolicy(%v/%+v) vol(%v/%v) acl(%+v) policy(%+v)",
+		GetRequestID(r), userInfo.UserID, userInfo.Policy, vol.Name(), vol.GetOwner(), acl, policy)
+	// public access block settings take effect no matter what the ACL and policy are
+	var pab *PublicAccessBlockConfiguration
+	if pab, err = o.publicAccessBlock(vol); err != nil {
+		log.LogErrorf("bucket policy check: load public access block fail: requestID(%v) err(%v)", GetRequestID(r), err)
+		allowed = false
+		return
+	}
+	if pab.ignorePublicAcls() {
+		acl = acl.withoutPublicGrants()
+	}
+	if vol != nil && policy != nil && !policy.IsEmpty() {
+		log.LogDebugf("bucket policy check: requestID(%v) policy(%v)", GetRequestID(r), policy)
+		conditionCheck := map[string]string{
+			SOURCEIP: param.sourceIP,
+			REFERER:  param.r.Referer(),
+			HOST:     param.r.Host,
 		}
-		// copy api should check srcBucket policy additionally
-		if param.apiName == COPY_OBJECT || param.apiName == UPLOAD_PART_COPY {
-			err = o.allowedBySrcBucketPolicy(param, userInfo.UserID)
-			if err != nil {
-				return
-			}
+		if !IsBucketApi(param.apiName) {
+			conditionCheck[KEYNAME] = param.object
 		}
-		// batch delete will delay to check just before delete for each key
-		if param.apiName == BATCH_DELETE {
-			log.LogDebugf("user policy check: delete objects delay check: requestID(%v) userID(%v) volume(%v)",
-				GetRequestID(r), userInfo.UserID, param.Bucket())
+		pcr := policy.IsAllowed(param, userInfo.UserID, vol.owner, conditionCheck)
+		pcr = pab.restrictPolicyResult(policy, pcr, userInfo.UserID, vol.owner)
+		switch pcr {
+		case POLICY_ALLOW:
 			allowed = true
+			log.LogDebugf("bucket policy check: policy allowed: requestID(%v)", GetRequestID(r))
 			return
-		}
-
-		// step3. Check bucket policy
-	policycheck:
-		vol, acl, policy, err := o.loadBucketMeta(param.Bucket())
-		if err != nil {
-			log.LogErrorf("bucket policy check: load bucket metadata fail: requestID(%v) err(%v)", GetRequestID(r), err)
+		case POLICY_DENY:
 			allowed = false
+			log.LogWarnf("bucket policy check: policy not allowed: requestID(%v) ", GetRequestID(r))
 			return
+		case POLICY_UNKNOW:
+			// policy check result is unknown so that acl should be checked
+			log.LogWarnf("bucket policy check: policy unknown: requestID(%v) ", GetRequestID(r))
+		default:
+			// do nothing
 		}
-		log.LogDebugf("bucket policy check: load bucket metadata, requestID(%v) userPolicy(%v/%+v) vol(%v/%v) acl(%+v) policy(%+v)",
-			GetRequestID(r), userInfo.UserID, userInfo.Policy, vol.Name(), vol.GetOwner(), acl, policy)
-		if vol != nil && policy != nil && !policy.IsEmpty() {
-			log.LogDebugf("bucket policy check: requestID(%v) policy(%v)", GetRequestID(r), policy)
-			conditionCheck := map[string]string{
-				SOURCEIP: param.sourceIP,
-				REFERER:  param.r.Referer(),
-				HOST:     param.r.Host,
-			}
-			if !IsBucketApi(param.apiName) {
-				conditionCheck[KEYNAME] = param.object
-			}
-			pcr := policy.IsAllowed(param, userInfo.UserID, vol.owner, conditionCheck)
-			switch pcr {
-			case POLICY_ALLOW:
-				allowed = true
-				log.LogDebugf("bucket policy check: policy allowed: requestID(%v)", GetRequestID(r))
-				return
-			case POLICY_DENY:
-				allowed = false
-				log.LogWarnf("bucket policy check: policy not allowed: requestID(%v) ", GetRequestID(r))
-				return
-			case POLICY_UNKNOW:
-				// policy check result is unknown so that acl should be checked
-				log.LogWarnf("bucket policy check: policy unknown: requestID(%v) ", GetRequestID(r))
-			default:
-				// do nothing
-			}
-		}
+	}
 
-		// step4. Check acl
-		if IsApiSupportByACL(param.Action()) {
-			if vol != nil && IsApiSupportByObjectAcl(param.Action()) {
-				if param.Object() == "" {
-					ec = InvalidKey
-					log.LogErrorf("acl check: no object key specified: requestID(%v) volume(%v) action(%v)",
-						GetRequestID(r), param.Bucket(), param.Action())
-					return
-				}
-				if acl, err = getObjectACL(vol, param.object, true); err != nil && err != syscall.ENOENT {
-					log.LogErrorf("acl check: get object acl fail: requestID(%v) volume(%v) action(%v) err(%v)",
-						GetRequestID(r), param.Bucket(), param.Action(), err)
-					return
-				}
-				err = nil
-			}
-			if acl == nil && !isOwner {
-				allowed = false
-				log.LogWarnf("acl check: empty acl disallows: requestID(%v) reqUid(%v) ownerUid(%v) volume(%v) action(%v)",
-					GetRequestID(r), userInfo.UserID, vol.GetOwner(), param.Bucket(), param.Action())
+	// step4. Check acl
+	if IsApiSupportByACL(param.Action()) {
+		if vol != nil && IsApiSupportByObjectAcl(param.Action()) {
+			if param.Object() == "" {
+				ec = InvalidKey
+				log.LogErrorf("acl check: no object key specified: requestID(%v) volume(%v) action(%v)",
+					GetRequestID(r), param.Bucket(), param.Action())
 				return
 			}
-			if acl != nil && !acl.IsAllowed(userInfo.UserID, param.Action()) {
-				allowed = false
-				log.LogWarnf("acl check: acl not allowed: requestID(%v) reqUid(%v) acl(%+v) volume(%v) action(%v)",
-					GetRequestID(r), userInfo.UserID, acl, param.Bucket(), param.Action())
+			if acl, err = getObjectACL(vol, param.object, true); err != nil && err != syscall.ENOENT {
+				log.LogErrorf("acl check: get object acl fail: requestID(%v) volume(%v) action(%v) err(%v)",
+					GetRequestID(r), param.Bucket(), param.Action(), err)
 				return
 			}
-		} else if !isOwner {
+			err = nil
+			if pab.ignorePublicAcls() {
+				acl = acl.withoutPublicGrants()
+			}
+		}
+		if acl == nil && !isOwner {
 			allowed = false
-			log.LogWarnf("acl check: action not support acl: requestID(%v) reqUid(%v) ownerUid(%v) volume(%v) action(%v)",
+			log.LogWarnf("acl check: empty acl disallows: requestID(%v) reqUid(%v) ownerUid(%v) volume(%v) action(%v)",
 				GetRequestID(r), userInfo.UserID, vol.GetOwner(), param.Bucket(), param.Action())
 			return
 		}
-
-		allowed = true
-		log.LogDebugf("bucket acl check: action allowed: requestID(%v) reqUid(%v) accessKey(%v) volume(%v) action(%v)",
-			GetRequestID(r), userInfo, param.AccessKey(), param.Bucket(), param.Action())
+		if acl != nil && !acl.IsAllowed(userInfo.UserID, param.Action()) {
+			allowed = false
+			log.LogWarnf("acl check: acl not allowed: requestID(%v) reqUid(%v) acl(%+v) volume(%v) action(%v)",
+				GetRequestID(r), userInfo.UserID, acl, param.Bucket(), param.Action())
+			return
+		}
+	} else if !isOwner {
+		allowed = false
+		log.LogWarnf("acl check: action not support acl: requestID(%v) reqUid(%v) ownerUid(%v) volume(%v) action(%v)",
+			GetRequestID(r), userInfo.UserID, vol.GetOwner(), param.Bucket(), param.Action())
+		return
 	}
+
+	allowed = true
+	log.LogDebugf("bucket acl check: action allowed: requestID(%v) reqUid(%v) accessKey(%v) volume(%v) action(%v)",
+		GetRequestID(r), userInfo, param.AccessKey(), param.Bucket(), param.Action())
+	return
 }
 
 func (o *ObjectNode) loadBucketMeta(bucket string) (vol *Volume, acl *AccessControlPolicy, policy *Policy, err error) {
@@ -339,6 +356,11 @@ func (o *ObjectNode) allowedBySrcBucketPolicy(param *RequestParam, reqUid string
 	}
 	paramCopy.apiName = GET_OBJECT
 	paramCopy.action = proto.OSSGetObjectAction
+	pab, err := o.publicAccessBlock(vol)
+	if err != nil {
+		log.LogErrorf("srcBucket policy check: load public access block fail: requestID(%v) err(%v)", GetRequestID(paramCopy.r), err)
+		return
+	}
 	if vol != nil && policy != nil && !policy.IsEmpty() {
 		conditionCheck := map[string]string{
 			SOURCEIP: paramCopy.sourceIP,
@@ -347,6 +369,7 @@ func (o *ObjectNode) allowedBySrcBucketPolicy(param *RequestParam, reqUid string
 			HOST:     paramCopy.r.Host,
 		}
 		pcr := policy.IsAllowed(&paramCopy, reqUid, vol.owner, conditionCheck)
+		pcr = pab.restrictPolicyResult(policy, pcr, reqUid, vol.owner)
 		switch pcr {
 		case POLICY_ALLOW:
 			log.LogDebugf("srcBucket policy check: policy allowed: requestID(%v)", GetRequestID(paramCopy.r))
@@ -370,6 +393,9 @@ func (o *ObjectNode) allowedBySrcBucketPolicy(param *RequestParam, reqUid string
 		return
 	}
 	err = nil
+	if pab.ignorePublicAcls() {
+		acl = acl.withoutPublicGrants()
+	}
 	if acl == nil && !isOwner {
 		log.LogWarnf("srcBucket acl check: empty acl disallows: requestID(%v) reqUid(%v) ownerUid(%v) volume(%v) action(%v)",
 			GetRequestID(paramCopy.r), reqUid, vol.owner, srcBucketId, paramCopy.Action())
@@ -42,7 +42,7 @@ const (
 // if more s3 api is supported by policy, need extend bucketApiList, objectApiList
 var (
 	bucketApiList = SliceString{LIST_OBJECTS, LIST_OBJECTS_V2, HEAD_BUCKET, DELETE_BUCKET, LIST_MULTIPART_UPLOADS, GET_BUCKET_LOCATION, GET_OBJECT_LOCK_CFG, PUT_OBJECT_LOCK_CFG}
-	objectApiList = SliceString{GET_OBJECT, HEAD_OBJECT, DELETE_OBJECT, PUT_OBJECT, POST_OBJECT, INITIALE_MULTIPART_UPLOAD, UPLOAD_PART, UPLOAD_PART_COPY, COMPLETE_MULTIPART_UPLOAD, COPY_OBJECT, ABORT_MULTIPART_UPLOAD, LIST_PARTS, BATCH_DELETE, GET_OBJECT_RETENTION}
+	objectApiList = SliceString{GET_OBJECT, HEAD_OBJECT, SELECT_OBJECT_CONTENT, DELETE_OBJECT, PUT_OBJECT, POST_OBJECT, INITIALE_MULTIPART_UPLOAD, UPLOAD_PART, UPLOAD_PART_COPY, COMPLETE_MULTIPART_UPLOAD, COPY_OBJECT, ABORT_MULTIPART_UPLOAD, LIST_PARTS, BATCH_DELETE, GET_OBJECT_RETENTION}
 )
 
 type SliceString []string
@@ -72,7 +72,7 @@ const (
 // action => api list, this should be consistent with bucketApiList&&objectApiList
 var S3ActionToApis = map[string]SliceString{
 	ACTION_PUT_OBJECT:                    {PUT_OBJECT, POST_OBJECT, COPY_OBJECT, INITIALE_MULTIPART_UPLOAD, UPLOAD_PART, UPLOAD_PART_COPY, COMPLETE_MULTIPART_UPLOAD},
-	ACTION_GET_OBJECT:                    {GET_OBJECT, HEAD_OBJECT},
+	ACTION_GET_OBJECT:                    {GET_OBJECT, HEAD_OBJECT, SELECT_OBJECT_CONTENT},
 	ACTION_DELETE_OBJECT:                 {DELETE_OBJECT, BATCH_DELETE},
 	ACTION_ABORT_MULTIPART_UPLOAD:        {ABORT_MULTIPART_UPLOAD},
 	ACTION_LIST_BUCKET:                   {LIST_OBJECTS, LIST_OBJECTS_V2, HEAD_BUCKET},
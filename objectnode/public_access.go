@@ -0,0 +1,189 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"encoding/xml"
+	"net"
+	"strings"
+)
+
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/access-control-block-public-access.html
+
+const MaxPublicAccessBlockSize = 4 << 10 // 4KB
+
+// PublicAccessBlockConfiguration is the public access block settings of a bucket or the cluster,
+// the settings of cluster are stored on master and take effect on all buckets.
+type PublicAccessBlockConfiguration struct {
+	XMLNS                 string   `xml:"xmlns,attr,omitempty" json:"-"`
+	XMLName               xml.Name `xml:"PublicAccessBlockConfiguration" json:"-"`
+	BlockPublicAcls       bool     `xml:"BlockPublicAcls" json:"blockPublicAcls"`
+	IgnorePublicAcls      bool     `xml:"IgnorePublicAcls" json:"ignorePublicAcls"`
+	BlockPublicPolicy     bool     `xml:"BlockPublicPolicy" json:"blockPublicPolicy"`
+	RestrictPublicBuckets bool     `xml:"RestrictPublicBuckets" json:"restrictPublicBuckets"`
+}
+
+// ParsePublicAccessBlockConfig parses the public access block configuration from request body.
+func ParsePublicAccessBlockConfig(body []byte) (config *PublicAccessBlockConfiguration, err error) {
+	config = &PublicAccessBlockConfiguration{}
+	if err = UnmarshalXMLEntity(body, config); err != nil {
+		return nil, MalformedXML
+	}
+	return config, nil
+}
+
+// merge returns the effective settings, a flag is enabled if it is enabled by either of them.
+func (c *PublicAccessBlockConfiguration) merge(other *PublicAccessBlockConfiguration) *PublicAccessBlockConfiguration {
+	if c == nil {
+		return other
+	}
+	if other == nil {
+		return c
+	}
+	return &PublicAccessBlockConfiguration{
+		BlockPublicAcls:       c.BlockPublicAcls || other.BlockPublicAcls,
+		IgnorePublicAcls:      c.IgnorePublicAcls || other.IgnorePublicAcls,
+		BlockPublicPolicy:     c.BlockPublicPolicy || other.BlockPublicPolicy,
+		RestrictPublicBuckets: c.RestrictPublicBuckets || other.RestrictPublicBuckets,
+	}
+}
+
+func (c *PublicAccessBlockConfiguration) blockPublicAcls() bool {
+	return c != nil && c.BlockPublicAcls
+}
+
+func (c *PublicAccessBlockConfiguration) ignorePublicAcls() bool {
+	return c != nil && c.IgnorePublicAcls
+}
+
+func (c *PublicAccessBlockConfiguration) blockPublicPolicy() bool {
+	return c != nil && c.BlockPublicPolicy
+}
+
+func (c *PublicAccessBlockConfiguration) restrictPublicBuckets() bool {
+	return c != nil && c.RestrictPublicBuckets
+}
+
+func storeBucketPublicAccessBlock(bytes []byte, vol *Volume) (err error) {
+	return vol.store.Put(vol.name, bucketRootPath, XAttrKeyOSSPublicAccess, bytes)
+}
+
+func deleteBucketPublicAccessBlock(vol *Volume) (err error) {
+	return vol.store.Delete(vol.name, bucketRootPath, XAttrKeyOSSPublicAccess)
+}
+
+// isPublic returns true if the grant is given to all users or all authenticated users.
+func (g *Grant) isPublic() bool {
+	return g.Grantee.Type == TypeGroup && (g.Grantee.URI == GroupAllUser || g.Grantee.URI == GroupAuthenticated)
+}
+
+// IsPublic returns true if any grant of the ACL is public.
+func (acp *AccessControlPolicy) IsPublic() bool {
+	if acp == nil {
+		return false
+	}
+	for i := range acp.Acl.Grants {
+		if acp.Acl.Grants[i].isPublic() {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutPublicGrants returns the ACL in which the public grants are ignored.
+func (acp *AccessControlPolicy) withoutPublicGrants() *AccessControlPolicy {
+	if !acp.IsPublic() {
+		return acp
+	}
+	out := &AccessControlPolicy{Owner: acp.Owner}
+	for _, g := range acp.Acl.Grants {
+		if !g.isPublic() {
+			out.Acl.Grants = append(out.Acl.Grants, g)
+		}
+	}
+	if len(out.Acl.Grants) == 0 {
+		// an ACL without any grant allows its owner, so only the owner keeps full control
+		out.AddGrant(acp.Owner.Id, TypeCanonicalUser, PermissionFullControl)
+	}
+	return out
+}
+
+// IsPublic returns true if any statement of the policy grants access to everyone.
+func (p *Policy) IsPublic() bool {
+	if p == nil {
+		return false
+	}
+	for i := range p.Statements {
+		if p.Statements[i].isPublic() {
+			return true
+		}
+	}
+	return false
+}
+
+// isPublic returns true if the statement allows the wildcard principal, and is not restricted to
+// fixed source IP ranges.
+func (s *Statement) isPublic() bool {
+	if !strings.EqualFold(s.Effect, Allow) || !s.hasWildcardPrincipal() {
+		return false
+	}
+	for _, op := range s.Condition {
+		if ipOp, ok := op.(*ipAddressOp); ok && ipOp.isFixed() {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Statement) hasWildcardPrincipal() bool {
+	switch p := s.Principal.(type) {
+	case string:
+		return PrincipalElementType(p) == Principal_Any
+	case map[string]interface{}:
+		switch pval := p[S3_PRINCIPAL_PREFIX].(type) {
+		case string:
+			return PrincipalElementType(pval) == Principal_Any
+		case []interface{}:
+			for _, v := range pval {
+				if e, ok := v.(string); ok && PrincipalElementType(e) == Principal_Any {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// isFixed returns true if all the networks are no wider than /8 of IPv4 or /32 of IPv6.
+func (op *ipAddressOp) isFixed() bool {
+	for _, infos := range op.m {
+		for _, info := range infos {
+			ones, bits := info.Net.Mask.Size()
+			if (bits == 8*net.IPv4len && ones < 8) || (bits == 8*net.IPv6len && ones < 32) {
+				return false
+			}
+		}
+	}
+	return len(op.m) > 0
+}
+
+// restrictPolicyResult ignores the allowance of public bucket policy for the requesters other than
+// the bucket owner if public buckets are restricted, the denial is kept anyway.
+func (c *PublicAccessBlockConfiguration) restrictPolicyResult(policy *Policy, result PolicyCheckResult, reqUid, ownerUid string) PolicyCheckResult {
+	if result == POLICY_ALLOW && reqUid != ownerUid && c.restrictPublicBuckets() && policy.IsPublic() {
+		return POLICY_UNKNOW
+	}
+	return result
+}
@@ -0,0 +1,217 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetPublicAccessBlock.html
+func (o *ObjectNode) getPublicAccessBlockHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	param := ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("getPublicAccessBlockHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+
+	var config *PublicAccessBlockConfiguration
+	if config, err = vol.metaLoader.loadPublicAccess(); err != nil {
+		log.LogErrorf("getPublicAccessBlockHandler: load public access block fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	if config == nil {
+		errorCode = NoSuchPublicAccessBlock
+		return
+	}
+	output := *config
+	output.XMLNS = S3Namespace
+	var data []byte
+	if data, err = MarshalXMLEntity(&output); err != nil {
+		log.LogErrorf("getPublicAccessBlockHandler: xml marshal fail: requestID(%v) volume(%v) config(%+v) err(%v)",
+			GetRequestID(r), vol.Name(), config, err)
+		return
+	}
+
+	writeSuccessResponseXML(w, data)
+}
+
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutPublicAccessBlock.html
+func (o *ObjectNode) putPublicAccessBlockHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	param := ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("putPublicAccessBlockHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+	var body []byte
+	if body, err = io.ReadAll(io.LimitReader(r.Body, MaxPublicAccessBlockSize+1)); err != nil {
+		log.LogErrorf("putPublicAccessBlockHandler: read request body fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	if len(body) > MaxPublicAccessBlockSize {
+		errorCode = EntityTooLarge
+		return
+	}
+	var config *PublicAccessBlockConfiguration
+	if config, err = ParsePublicAccessBlockConfig(body); err != nil {
+		log.LogErrorf("putPublicAccessBlockHandler: parse public access block fail: requestID(%v) volume(%v) config(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(body), err)
+		return
+	}
+	var data []byte
+	if data, err = json.Marshal(config); err != nil {
+		log.LogErrorf("putPublicAccessBlockHandler: json marshal fail: requestID(%v) volume(%v) config(%+v) err(%v)",
+			GetRequestID(r), vol.Name(), config, err)
+		return
+	}
+	if err = storeBucketPublicAccessBlock(data, vol); err != nil {
+		log.LogErrorf("putPublicAccessBlockHandler: store public access block fail: requestID(%v) volume(%v) config(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(data), err)
+		return
+	}
+	vol.metaLoader.storePublicAccess(config)
+
+	log.LogInfof("Audit: putPublicAccessBlockHandler: requestID(%v) volume(%v) config(%v)",
+		GetRequestID(r), vol.Name(), string(data))
+}
+
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeletePublicAccessBlock.html
+func (o *ObjectNode) deletePublicAccessBlockHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	param := ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("deletePublicAccessBlockHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+	if err = deleteBucketPublicAccessBlock(vol); err != nil {
+		log.LogErrorf("deletePublicAccessBlockHandler: delete public access block fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	vol.metaLoader.storePublicAccess(nil)
+
+	log.LogInfof("Audit: deletePublicAccessBlockHandler: requestID(%v) volume(%v)", GetRequestID(r), vol.Name())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// publicAccessBlock returns the effective public access block settings of the volume, which is
+// merged from the settings of cluster and bucket, nil means nothing is blocked.
+func (o *ObjectNode) publicAccessBlock(vol *Volume) (*PublicAccessBlockConfiguration, error) {
+	o.publicAccessMutex.RLock()
+	cluster := o.publicAccess
+	o.publicAccessMutex.RUnlock()
+	if vol == nil {
+		return cluster, nil
+	}
+	config, err := vol.metaLoader.loadPublicAccess()
+	if err != nil {
+		return nil, err
+	}
+	return cluster.merge(config), nil
+}
+
+func (o *ObjectNode) requestPublicAccessBlock() ([]byte, error) {
+	block, err := o.mc.AdminAPI().GetS3PublicAccessBlock()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(block)
+}
+
+// reloadPublicAccessBlock applies the public access block settings of cluster loaded from master,
+// or from the local copy of the last loaded if master is unavailable.
+func (o *ObjectNode) reloadPublicAccessBlock(data []byte) error {
+	block := &proto.S3PublicAccessBlock{}
+	if err := json.Unmarshal(data, block); err != nil {
+		return err
+	}
+	var config *PublicAccessBlockConfiguration
+	if *block != (proto.S3PublicAccessBlock{}) {
+		config = &PublicAccessBlockConfiguration{
+			BlockPublicAcls:       block.BlockPublicAcls,
+			IgnorePublicAcls:      block.IgnorePublicAcls,
+			BlockPublicPolicy:     block.BlockPublicPolicy,
+			RestrictPublicBuckets: block.RestrictPublicBuckets,
+		}
+	}
+	o.publicAccessMutex.Lock()
+	o.publicAccess = config
+	o.publicAccessMutex.Unlock()
+	log.LogInfof("reloadPublicAccessBlock: public access block of cluster(%+v)", *block)
+	return nil
+}
+
+// checkPublicAcl returns PublicAclsBlocked if the ACL to be set is public while public ACLs are
+// blocked, the vol is nil for the bucket to be created.
+func (o *ObjectNode) checkPublicAcl(vol *Volume, acl *AccessControlPolicy) error {
+	if !acl.IsPublic() {
+		return nil
+	}
+	config, err := o.publicAccessBlock(vol)
+	if err != nil {
+		return err
+	}
+	if config.blockPublicAcls() {
+		return PublicAclsBlocked
+	}
+	return nil
+}
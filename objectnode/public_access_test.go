@@ -0,0 +1,126 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePublicAccessBlockConfig(t *testing.T) {
+	config, err := ParsePublicAccessBlockConfig([]byte(`
+<PublicAccessBlockConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <BlockPublicAcls>true</BlockPublicAcls>
+  <RestrictPublicBuckets>true</RestrictPublicBuckets>
+</PublicAccessBlockConfiguration>`))
+	require.NoError(t, err)
+	require.True(t, config.blockPublicAcls())
+	require.False(t, config.ignorePublicAcls())
+	require.False(t, config.blockPublicPolicy())
+	require.True(t, config.restrictPublicBuckets())
+
+	_, err = ParsePublicAccessBlockConfig([]byte(`<PublicAccessBlockConfiguration><BlockPublicAcls>yes`))
+	require.Equal(t, MalformedXML, err)
+
+	// the cluster settings and bucket settings are merged
+	var none *PublicAccessBlockConfiguration
+	require.False(t, none.blockPublicAcls())
+	require.Equal(t, config, none.merge(config))
+	merged := (&PublicAccessBlockConfiguration{IgnorePublicAcls: true}).merge(config)
+	require.True(t, merged.blockPublicAcls())
+	require.True(t, merged.ignorePublicAcls())
+	require.False(t, merged.blockPublicPolicy())
+}
+
+func TestReloadPublicAccessBlock(t *testing.T) {
+	o := &ObjectNode{}
+	require.NoError(t, o.reloadPublicAccessBlock([]byte(`{"blockPublicAcls":true,"restrictPublicBuckets":true}`)))
+	config, err := o.publicAccessBlock(nil)
+	require.NoError(t, err)
+	require.True(t, config.blockPublicAcls())
+	require.False(t, config.ignorePublicAcls())
+	require.True(t, config.restrictPublicBuckets())
+
+	// the settings deleted on master block nothing
+	require.NoError(t, o.reloadPublicAccessBlock([]byte(`{}`)))
+	config, err = o.publicAccessBlock(nil)
+	require.NoError(t, err)
+	require.Nil(t, config)
+
+	require.Error(t, o.reloadPublicAccessBlock([]byte(`{`)))
+}
+
+func TestPublicAcl(t *testing.T) {
+	acl := &AccessControlPolicy{}
+	acl.SetPrivate("owner")
+	require.False(t, acl.IsPublic())
+	require.Equal(t, acl, acl.withoutPublicGrants())
+
+	acl = &AccessControlPolicy{}
+	acl.SetAuthenticatedRead("owner")
+	acl.AddGrant("user", TypeCanonicalUser, PermissionRead)
+	require.True(t, acl.IsPublic())
+	require.True(t, acl.IsAllowed("other", proto.OSSGetObjectAction))
+	ignored := acl.withoutPublicGrants()
+	require.False(t, ignored.IsPublic())
+	require.False(t, ignored.IsAllowed("other", proto.OSSGetObjectAction))
+	require.True(t, ignored.IsAllowed("user", proto.OSSGetObjectAction))
+	require.True(t, ignored.IsAllowed("owner", proto.OSSGetObjectAction))
+	require.Len(t, acl.Acl.Grants, 3)
+
+	acl = &AccessControlPolicy{Owner: Owner{Id: "owner"}}
+	acl.AddGrant(GroupAllUser, TypeGroup, PermissionRead)
+	ignored = acl.withoutPublicGrants()
+	require.False(t, ignored.IsAllowed(AnonymousUser, proto.OSSGetObjectAction))
+	require.True(t, ignored.IsAllowed("owner", proto.OSSGetObjectAction))
+
+	var none *AccessControlPolicy
+	require.False(t, none.IsPublic())
+	require.Nil(t, none.withoutPublicGrants())
+}
+
+func TestPublicPolicy(t *testing.T) {
+	cases := []struct {
+		policy string
+		public bool
+	}{
+		{`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*"}]}`, true},
+		{`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"AWS":["1001","*"]},"Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*"}]}`, true},
+		{`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"AWS":"1001"},"Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*"}]}`, false},
+		{`{"Version":"2012-10-17","Statement":[{"Effect":"Deny","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*"}]}`, false},
+		{`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*",
+			"Condition":{"IpAddress":{"aws:SourceIp":"192.168.0.0/16"}}}]}`, false},
+		{`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*",
+			"Condition":{"IpAddress":{"aws:SourceIp":["192.168.0.0/16","0.0.0.0/0"]}}}]}`, true},
+		{`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*",
+			"Condition":{"NotIpAddress":{"aws:SourceIp":"192.168.0.0/16"}}}]}`, true},
+	}
+	for _, c := range cases {
+		policy, err := ParsePolicy([]byte(c.policy))
+		require.NoError(t, err)
+		require.Equal(t, c.public, policy.IsPublic(), c.policy)
+	}
+
+	policy, err := ParsePolicy([]byte(cases[0].policy))
+	require.NoError(t, err)
+	restrict := &PublicAccessBlockConfiguration{RestrictPublicBuckets: true}
+	require.Equal(t, POLICY_UNKNOW, restrict.restrictPolicyResult(policy, POLICY_ALLOW, "user", "owner"))
+	require.Equal(t, POLICY_ALLOW, restrict.restrictPolicyResult(policy, POLICY_ALLOW, "owner", "owner"))
+	require.Equal(t, POLICY_DENY, restrict.restrictPolicyResult(policy, POLICY_DENY, "user", "owner"))
+	var none *PublicAccessBlockConfiguration
+	require.Equal(t, POLICY_ALLOW, none.restrictPolicyResult(policy, POLICY_ALLOW, "user", "owner"))
+}
@@ -0,0 +1,186 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/replication.html
+
+const (
+	MaxReplicationConfigSize = 128 << 10 // 128KB
+)
+
+// ParseReplicationConfig parses the replication configuration from request body.
+func ParseReplicationConfig(body []byte) (config *proto.ReplicationConfiguration, err error) {
+	config = &proto.ReplicationConfiguration{}
+	if err = UnmarshalXMLEntity(body, config); err != nil {
+		return nil, MalformedXML
+	}
+	if err = config.Validate(); err != nil {
+		return nil, &ErrorCode{
+			ErrorCode:    "InvalidArgument",
+			ErrorMessage: err.Error(),
+			StatusCode:   http.StatusBadRequest,
+		}
+	}
+	return config, nil
+}
+
+func storeBucketReplication(bytes []byte, vol *Volume) (err error) {
+	return vol.store.Put(vol.name, bucketRootPath, proto.XAttrKeyReplicationConfig, bytes)
+}
+
+func deleteBucketReplication(vol *Volume) (err error) {
+	return vol.store.Delete(vol.name, bucketRootPath, proto.XAttrKeyReplicationConfig)
+}
+
+// isReplicaRequest reports whether the request is issued by the replication worker of source bucket,
+// changes made by such requests are not replicated again to avoid replication loops. The header is
+// trusted since it's dropped by checkReplicaRequest from the requests of others.
+func isReplicaRequest(r *http.Request) bool {
+	return r.Header.Get(XAmzReplicationStatus) == proto.ReplicationStatusReplica
+}
+
+// checkReplicaRequest drops the replication status header from the request not signed by the
+// access key of the replication workers, the anonymous request has an empty access key.
+func (o *ObjectNode) checkReplicaRequest(r *http.Request, accessKey string) {
+	if r.Header.Get(XAmzReplicationStatus) == "" {
+		return
+	}
+	if _, ok := o.replicationAccessKeys[accessKey]; ok && accessKey != "" {
+		return
+	}
+	log.LogWarnf("checkReplicaRequest: drop replication status from untrusted request: requestID(%v) accessKey(%v) status(%v)",
+		GetRequestID(r), accessKey, r.Header.Get(XAmzReplicationStatus))
+	r.Header.Del(XAmzReplicationStatus)
+}
+
+func setReplicationStatusHeader(w http.ResponseWriter, status string) {
+	if status != "" {
+		w.Header().Set(XAmzReplicationStatus, status)
+	}
+}
+
+// newReplicationTaskName returns the name of a new replication queue entry of the object version.
+// The names sort lexically by the versions, which are ordered by the time they are created rather
+// than the time the tasks are appended, so a directory listing yields the tasks of the versions in
+// order. The tasks of the null versions and of the taggings are ordered by the time appended.
+func newReplicationTaskName(versionId string) string {
+	seq := uint64(time.Now().UnixNano())
+	if versionId != NullVersionId && isValidVersionId(versionId) {
+		// the version IDs sort from the newest, see NewVersionId
+		if ts, err := strconv.ParseUint(versionId[:16], 16, 64); err == nil {
+			seq = math.MaxUint64 - ts
+		}
+	}
+	random := make([]byte, 4)
+	_, _ = rand.Read(random)
+	return fmt.Sprintf("%016x%s", seq, hex.EncodeToString(random))
+}
+
+// enqueueReplication appends a replication task of the object if it is replicated by any rule,
+// the changes made by the replica requests are never replicated again. The object with the
+// specified inode is marked as pending replication for put operations. The error fails the
+// request although the change itself has been applied, so that the client retries the change
+// and the task is appended again rather than the change never being replicated.
+func (v *Volume) enqueueReplication(r *http.Request, op, path, versionId string, inode uint64) (err error) {
+	if isReplicaRequest(r) {
+		return
+	}
+	config, err := v.metaLoader.loadReplication()
+	if err != nil {
+		log.LogErrorf("enqueueReplication: load replication config fail: volume(%v) err(%v)", v.name, err)
+		return
+	}
+	key := strings.TrimPrefix(path, pathSep)
+	if op == proto.ReplicationOpDelete {
+		// rules filtering by tags never match delete markers
+		if rule := config.MatchRule(key, map[string]string{}); rule == nil || !rule.IsDeleteMarkerReplicated() {
+			return
+		}
+	} else if config.MatchRule(key, nil) == nil {
+		return
+	}
+
+	// mark the object before the task is visible, so that the final status set by worker is not overwritten
+	if inode != 0 && op == proto.ReplicationOpPut {
+		if err = v.mw.XAttrSet_ll(inode, []byte(proto.XAttrKeyReplicationStatus),
+			[]byte(proto.ReplicationStatusPending)); err != nil {
+			log.LogErrorf("enqueueReplication: set replication status fail: volume(%v) key(%v) inode(%v) err(%v)",
+				v.name, key, inode, err)
+			return
+		}
+		updateAttrCache(inode, proto.XAttrKeyReplicationStatus, proto.ReplicationStatusPending, v.name)
+	}
+	task := &proto.ReplicationTask{
+		Op:        op,
+		Key:       key,
+		VersionId: versionId,
+		Time:      time.Now().UnixNano(),
+	}
+	if err = v.appendReplicationTask(task); err != nil {
+		log.LogErrorf("enqueueReplication: append task fail: volume(%v) task(%+v) err(%v)", v.name, task, err)
+		return
+	}
+	log.LogDebugf("enqueueReplication: volume(%v) task(%+v)", v.name, task)
+	return
+}
+
+// enqueueDeleteReplication replicates the delete marker created by the deletion, only the delete
+// markers are replicated, deleting a specific version is never replicated.
+func (v *Volume) enqueueDeleteReplication(r *http.Request, path, versionId string, result *DeleteObjectResult) error {
+	if !result.DeleteMarker || versionId != "" {
+		return nil
+	}
+	return v.enqueueReplication(r, proto.ReplicationOpDelete, path, result.VersionId, 0)
+}
+
+func (v *Volume) appendReplicationTask(task *proto.ReplicationTask) (err error) {
+	var data []byte
+	if data, err = json.Marshal(task); err != nil {
+		return
+	}
+	var dirIno uint64
+	if dirIno, err = v.lookupReservedDirectories([]string{proto.ReplicationQueueDir}, true); err != nil {
+		return
+	}
+	name := newReplicationTaskName(task.VersionId)
+	path := proto.ReplicationQueueDir + pathSep + name
+	var entry *proto.InodeInfo
+	if entry, err = v.mw.InodeCreate_ll(dirIno, DefaultFileMode, 0, 0, nil, make([]uint64, 0), path); err != nil {
+		return
+	}
+	// the entry is linked after the task is set, so that the worker never sees an empty entry
+	if err = v.mw.XAttrSet_ll(entry.Inode, []byte(proto.XAttrKeyReplicationTask), data); err == nil {
+		err = v.mw.DentryCreate_ll(dirIno, name, entry.Inode, DefaultFileMode, path)
+	}
+	if err != nil {
+		v.releaseInode(entry.Inode, path)
+	}
+	return
+}
@@ -0,0 +1,168 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// Get Bucket Replication
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketReplication.html
+func (o *ObjectNode) getBucketReplicationHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	param := ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("getBucketReplicationHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+
+	var config *proto.ReplicationConfiguration
+	if config, err = vol.metaLoader.loadReplication(); err != nil {
+		log.LogErrorf("getBucketReplicationHandler: load replication fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	if config == nil {
+		errorCode = NoSuchReplicationConfiguration
+		return
+	}
+	output := &proto.ReplicationConfiguration{XMLNS: S3Namespace, Role: config.Role, Rules: config.Rules}
+	var data []byte
+	if data, err = MarshalXMLEntity(output); err != nil {
+		log.LogErrorf("getBucketReplicationHandler: xml marshal fail: requestID(%v) volume(%v) config(%+v) err(%v)",
+			GetRequestID(r), vol.Name(), output, err)
+		return
+	}
+
+	writeSuccessResponseXML(w, data)
+}
+
+// Put Bucket Replication
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketReplication.html
+func (o *ObjectNode) putBucketReplicationHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	param := ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("putBucketReplicationHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+	// noncurrent versions must be kept, otherwise the replicated changes may be lost before applied
+	if !vol.loadVersioning().IsEnabled() {
+		errorCode = ReplicationVersioningRequired
+		return
+	}
+
+	var body []byte
+	if body, err = io.ReadAll(io.LimitReader(r.Body, MaxReplicationConfigSize+1)); err != nil {
+		log.LogErrorf("putBucketReplicationHandler: read request body fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	if len(body) > MaxReplicationConfigSize {
+		errorCode = EntityTooLarge
+		return
+	}
+	var config *proto.ReplicationConfiguration
+	if config, err = ParseReplicationConfig(body); err != nil {
+		log.LogErrorf("putBucketReplicationHandler: parse replication config fail: requestID(%v) volume(%v) config(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(body), err)
+		return
+	}
+	if _, err = vol.reservedDir(proto.ReplicationQueueDir, true); err != nil {
+		log.LogErrorf("putBucketReplicationHandler: make queue dir fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	if body, err = json.Marshal(config); err != nil {
+		log.LogErrorf("putBucketReplicationHandler: json.Marshal replication config fail: requestID(%v) volume(%v) config(%+v) err(%v)",
+			GetRequestID(r), vol.Name(), config, err)
+		return
+	}
+	if err = storeBucketReplication(body, vol); err != nil {
+		log.LogErrorf("putBucketReplicationHandler: store replication config fail: requestID(%v) volume(%v) config(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(body), err)
+		return
+	}
+	vol.metaLoader.storeReplication(config)
+	log.LogInfof("Audit: putBucketReplicationHandler: requestID(%v) volume(%v) config(%v)",
+		GetRequestID(r), vol.Name(), string(body))
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Delete Bucket Replication
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketReplication.html
+func (o *ObjectNode) deleteBucketReplicationHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	param := ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("deleteBucketReplicationHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+
+	if err = deleteBucketReplication(vol); err != nil {
+		log.LogErrorf("deleteBucketReplicationHandler: delete replication config fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	vol.metaLoader.storeReplication(nil)
+	log.LogInfof("Audit: deleteBucketReplicationHandler: requestID(%v) volume(%v)", GetRequestID(r), vol.Name())
+
+	w.WriteHeader(http.StatusNoContent)
+}
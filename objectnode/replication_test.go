@@ -0,0 +1,143 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReplicationConfig(t *testing.T) {
+	config, err := ParseReplicationConfig([]byte(`
+<ReplicationConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Role>arn:aws:iam::123456789012:role/replication</Role>
+  <Rule>
+    <ID>logs</ID>
+    <Priority>1</Priority>
+    <Status>Enabled</Status>
+    <Filter>
+      <And>
+        <Prefix>logs/</Prefix>
+        <Tag><Key>replicate</Key><Value>true</Value></Tag>
+      </And>
+    </Filter>
+    <Destination>
+      <Bucket>arn:aws:s3:::backup</Bucket>
+      <StorageClass>STANDARD</StorageClass>
+    </Destination>
+  </Rule>
+  <Rule>
+    <Priority>2</Priority>
+    <Status>Enabled</Status>
+    <Filter><Prefix>images/</Prefix></Filter>
+    <DeleteMarkerReplication><Status>Enabled</Status></DeleteMarkerReplication>
+    <Destination><Bucket>arn:aws:s3:::backup</Bucket><Account>dr</Account></Destination>
+  </Rule>
+</ReplicationConfiguration>`))
+	require.NoError(t, err)
+	require.Len(t, config.Rules, 2)
+	require.Equal(t, "logs", config.Rules[0].ID)
+	require.Equal(t, "rule-2", config.Rules[1].ID)
+	require.Equal(t, "backup", config.Rules[0].Destination.BucketName())
+	require.Equal(t, "STANDARD", config.Rules[0].Destination.StorageClass)
+	require.Equal(t, "dr", config.Rules[1].Destination.Account)
+	require.True(t, config.Rules[1].IsDeleteMarkerReplicated())
+	require.Nil(t, config.MatchRule("logs/a", map[string]string{}))
+	require.Equal(t, "rule-2", config.MatchRule("images/a", map[string]string{}).ID)
+
+	_, err = ParseReplicationConfig([]byte(`<ReplicationConfiguration><Rule>`))
+	require.Equal(t, MalformedXML, err)
+
+	_, err = ParseReplicationConfig([]byte(`<ReplicationConfiguration></ReplicationConfiguration>`))
+	require.Error(t, err)
+	require.Equal(t, proto.ReplicationErrMissingRules.Error(), err.(*ErrorCode).ErrorMessage)
+
+	_, err = ParseReplicationConfig([]byte(`
+<ReplicationConfiguration>
+  <Rule><Status>Enabled</Status><Destination><Bucket>backup</Bucket></Destination></Rule>
+</ReplicationConfiguration>`))
+	require.Error(t, err)
+	require.Equal(t, proto.ReplicationErrInvalidDestination.Error(), err.(*ErrorCode).ErrorMessage)
+}
+
+func TestNewReplicationTaskName(t *testing.T) {
+	names := make([]string, 0, 10)
+	for i := 0; i < 10; i++ {
+		names = append(names, newReplicationTaskName(""))
+		time.Sleep(time.Microsecond)
+	}
+	require.True(t, sort.StringsAreSorted(names))
+	require.True(t, isReservedObjectKey(proto.ReplicationQueueDir+"/"+names[0]))
+
+	// the tasks are ordered by the versions rather than the time they are appended
+	older := NewVersionId()
+	time.Sleep(time.Microsecond)
+	newer := NewVersionId()
+	time.Sleep(time.Microsecond)
+	newerName := newReplicationTaskName(newer)
+	require.Less(t, newReplicationTaskName(older), newerName)
+	require.Less(t, names[len(names)-1], newReplicationTaskName(NullVersionId))
+}
+
+func TestCheckReplicaRequest(t *testing.T) {
+	o := &ObjectNode{replicationAccessKeys: map[string]struct{}{"replicator": {}}}
+	for _, c := range []struct {
+		accessKey string
+		replica   bool
+	}{
+		{"replicator", true},
+		{"user", false},
+		{"", false},
+	} {
+		r, _ := http.NewRequest(http.MethodPut, "/bucket/key", nil)
+		r.Header.Set(XAmzReplicationStatus, proto.ReplicationStatusReplica)
+		o.checkReplicaRequest(r, c.accessKey)
+		require.Equal(t, c.replica, isReplicaRequest(r), c.accessKey)
+	}
+}
+
+type replicationMetaLoader struct {
+	ossMetaLoader
+	loads int
+}
+
+func (l *replicationMetaLoader) loadReplication() (*proto.ReplicationConfiguration, error) {
+	l.loads++
+	return nil, errors.New("no replication configuration")
+}
+
+func TestEnqueueReplicationSkipsReplica(t *testing.T) {
+	loader := &replicationMetaLoader{}
+	v := &Volume{name: "vol", metaLoader: loader}
+	r, _ := http.NewRequest(http.MethodPut, "/bucket/key", nil)
+
+	// the change made by the replication worker is never replicated back
+	r.Header.Set(XAmzReplicationStatus, proto.ReplicationStatusReplica)
+	for _, op := range []string{proto.ReplicationOpPut, proto.ReplicationOpDelete, proto.ReplicationOpTagging} {
+		require.NoError(t, v.enqueueReplication(r, op, "/key", "", 10))
+	}
+	require.Zero(t, loader.loads)
+
+	r.Header.Del(XAmzReplicationStatus)
+	// the failure is returned to fail the request, which is retried by the client
+	require.Error(t, v.enqueueReplication(r, proto.ReplicationOpPut, "/key", "", 10))
+	require.Equal(t, 1, loader.loads)
+}
@@ -0,0 +1,170 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/restoring-objects.html
+
+const (
+	MaxRestoreRequestSize = 64 << 10 // 64KB
+
+	RestoreRequestTypeSelect = "SELECT"
+)
+
+type RestoreRequest struct {
+	XMLName              xml.Name              `xml:"RestoreRequest"`
+	Days                 int                   `xml:"Days,omitempty"`
+	Type                 string                `xml:"Type,omitempty"`
+	GlacierJobParameters *GlacierJobParameters `xml:"GlacierJobParameters,omitempty"`
+}
+
+// GlacierJobParameters is accepted for compatibility, all restores are processed in the same way.
+type GlacierJobParameters struct {
+	Tier string `xml:"Tier"`
+}
+
+// ParseRestoreRequest parses the restore request from request body.
+func ParseRestoreRequest(body []byte) (req *RestoreRequest, err error) {
+	req = &RestoreRequest{}
+	if err = UnmarshalXMLEntity(body, req); err != nil {
+		return nil, MalformedXML
+	}
+	if req.Type == RestoreRequestTypeSelect {
+		return nil, NotImplemented
+	}
+	if req.Type != "" {
+		return nil, MalformedXML
+	}
+	if req.Days < 1 || req.Days > proto.MaxRestoreDays {
+		return nil, InvalidRestoreDays
+	}
+	return req, nil
+}
+
+func setRestoreHeader(w http.ResponseWriter, status *proto.RestoreStatus) {
+	if value := status.Header(); value != "" {
+		w.Header().Set(XAmzRestore, value)
+	}
+}
+
+// readSource returns the inode and storage class the data of object is read from, which is
+// the restored copy if the object is restored and the copy is not expired.
+func (info *FSFileInfo) readSource(now time.Time) (inode uint64, storageClass uint32) {
+	if proto.IsStorageClassBlobStore(info.StorageClass) && info.RestoreStatus.IsRestored(now) {
+		return info.RestoreStatus.Inode, info.RestoreStatus.StorageClass
+	}
+	return info.Inode, info.StorageClass
+}
+
+func (v *Volume) setRestoreStatus(inode uint64, status *proto.RestoreStatus) (err error) {
+	var data []byte
+	if data, err = json.Marshal(status); err != nil {
+		return
+	}
+	if err = v.mw.XAttrSet_ll(inode, []byte(proto.XAttrKeyRestoreStatus), data); err != nil {
+		return
+	}
+	updateAttrCache(inode, proto.XAttrKeyRestoreStatus, string(data), v.name)
+	return
+}
+
+// restoreObject requests a temporary copy of the object in BlobStore for the days. If the object
+// is restored already, the expiry of the copy is extended and true is returned, otherwise a restore
+// entry is linked into the restore directory for the worker of LcNode to fill.
+func (v *Volume) restoreObject(info *FSFileInfo, key string, days int) (restored bool, err error) {
+	if !proto.IsStorageClassReplica(v.mw.GetStorageClass()) {
+		return false, RestoreNotSupported
+	}
+	var dirIno uint64
+	if dirIno, err = v.lookupReservedDirectories([]string{proto.RestoreQueueDir}, true); err != nil {
+		return
+	}
+	name := strconv.FormatUint(info.Inode, 10)
+	path := proto.RestoreQueueDir + pathSep + name
+	now := time.Now()
+
+	var entryIno uint64
+	entryIno, _, err = v.mw.Lookup_ll(dirIno, name)
+	if err != nil && err != syscall.ENOENT {
+		return
+	}
+	if err == nil {
+		var xattr *proto.XAttrInfo
+		if xattr, err = v.mw.XAttrGet_ll(entryIno, proto.XAttrKeyRestoreTask); err != nil {
+			return
+		}
+		task := &proto.RestoreTask{}
+		if err = json.Unmarshal(xattr.Get(proto.XAttrKeyRestoreTask), task); err != nil {
+			return
+		}
+		if task.Expiry == 0 {
+			return false, RestoreAlreadyInProgress
+		}
+		// the copy is restored already, only the expiry is extended
+		var entry *proto.InodeInfo
+		if entry, err = v.mw.InodeGet_ll(entryIno); err != nil {
+			return
+		}
+		task.Days, task.Expiry = days, proto.RestoreExpiry(now, days)
+		var data []byte
+		if data, err = json.Marshal(task); err != nil {
+			return
+		}
+		if err = v.mw.XAttrSet_ll(entryIno, []byte(proto.XAttrKeyRestoreTask), data); err != nil {
+			return
+		}
+		status := &proto.RestoreStatus{Expiry: task.Expiry, Inode: entryIno, StorageClass: entry.StorageClass}
+		if err = v.setRestoreStatus(info.Inode, status); err != nil {
+			return
+		}
+		return true, nil
+	}
+
+	if err = v.setRestoreStatus(info.Inode, &proto.RestoreStatus{Ongoing: true}); err != nil {
+		return
+	}
+	var data []byte
+	if data, err = json.Marshal(&proto.RestoreTask{Key: key, Inode: info.Inode, Days: days, Time: now.Unix()}); err != nil {
+		return
+	}
+	var entry *proto.InodeInfo
+	if entry, err = v.mw.InodeCreate_ll(dirIno, DefaultFileMode, 0, 0, nil, make([]uint64, 0), path); err != nil {
+		return
+	}
+	// the entry is linked after the task is set, so that the worker never sees an empty entry
+	if err = v.mw.XAttrSet_ll(entry.Inode, []byte(proto.XAttrKeyRestoreTask), data); err == nil {
+		err = v.mw.DentryCreate_ll(dirIno, name, entry.Inode, DefaultFileMode, path)
+	}
+	if err != nil {
+		v.releaseInode(entry.Inode, path)
+		if err == syscall.EEXIST {
+			return false, RestoreAlreadyInProgress
+		}
+		// the object must not be left ongoing without a restore entry
+		_ = v.mw.XAttrDel_ll(info.Inode, proto.XAttrKeyRestoreStatus)
+		deleteAttrCache(info.Inode, v.name)
+	}
+	return
+}
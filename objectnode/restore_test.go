@@ -0,0 +1,75 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRestoreRequest(t *testing.T) {
+	req, err := ParseRestoreRequest([]byte(`
+<RestoreRequest xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Days>2</Days>
+  <GlacierJobParameters><Tier>Standard</Tier></GlacierJobParameters>
+</RestoreRequest>`))
+	require.NoError(t, err)
+	require.Equal(t, 2, req.Days)
+	require.Equal(t, "Standard", req.GlacierJobParameters.Tier)
+
+	_, err = ParseRestoreRequest([]byte(`<RestoreRequest><Days>2`))
+	require.Equal(t, MalformedXML, err)
+	_, err = ParseRestoreRequest([]byte(`<RestoreRequest><Type>SELECT</Type></RestoreRequest>`))
+	require.Equal(t, NotImplemented, err)
+	for _, body := range []string{
+		`<RestoreRequest></RestoreRequest>`,
+		`<RestoreRequest><Days>0</Days></RestoreRequest>`,
+		`<RestoreRequest><Days>30001</Days></RestoreRequest>`,
+	} {
+		_, err = ParseRestoreRequest([]byte(body))
+		require.Equal(t, InvalidRestoreDays, err, body)
+	}
+}
+
+func TestRestoreReadSource(t *testing.T) {
+	now := time.Now()
+	info := &FSFileInfo{Inode: 10, StorageClass: proto.StorageClass_Replica_HDD}
+	ino, storageClass := info.readSource(now)
+	require.Equal(t, uint64(10), ino)
+	require.Equal(t, proto.StorageClass_Replica_HDD, storageClass)
+
+	info.StorageClass = proto.StorageClass_BlobStore
+	info.RestoreStatus = &proto.RestoreStatus{Ongoing: true}
+	ino, storageClass = info.readSource(now)
+	require.Equal(t, uint64(10), ino)
+	require.Equal(t, proto.StorageClass_BlobStore, storageClass)
+
+	info.RestoreStatus = &proto.RestoreStatus{Expiry: proto.RestoreExpiry(now, 1), Inode: 20, StorageClass: proto.StorageClass_Replica_SSD}
+	ino, storageClass = info.readSource(now)
+	require.Equal(t, uint64(20), ino)
+	require.Equal(t, proto.StorageClass_Replica_SSD, storageClass)
+	ino, _ = info.readSource(now.AddDate(0, 0, 3))
+	require.Equal(t, uint64(10), ino)
+
+	w := httptest.NewRecorder()
+	setRestoreHeader(w, nil)
+	require.Empty(t, w.Header().Get(XAmzRestore))
+	setRestoreHeader(w, &proto.RestoreStatus{Ongoing: true})
+	require.Equal(t, `ongoing-request="true"`, w.Header().Get(XAmzRestore))
+}
@@ -190,6 +190,45 @@ type ListBucketResult struct {
 	CommonPrefixes []*CommonPrefix `xml:"CommonPrefixes"`
 }
 
+type ObjectVersion struct {
+	XMLName      xml.Name     `xml:"Version"`
+	Key          string       `xml:"Key"`
+	VersionId    string       `xml:"VersionId"`
+	IsLatest     bool         `xml:"IsLatest"`
+	LastModified string       `xml:"LastModified"`
+	ETag         string       `xml:"ETag"`
+	Size         int          `xml:"Size"`
+	StorageClass string       `xml:"StorageClass"`
+	Owner        *BucketOwner `xml:"Owner,omitempty"`
+}
+
+type DeleteMarkerEntry struct {
+	XMLName      xml.Name     `xml:"DeleteMarker"`
+	Key          string       `xml:"Key"`
+	VersionId    string       `xml:"VersionId"`
+	IsLatest     bool         `xml:"IsLatest"`
+	LastModified string       `xml:"LastModified"`
+	Owner        *BucketOwner `xml:"Owner,omitempty"`
+}
+
+type ListVersionsResultXML struct {
+	XMLName             xml.Name             `xml:"ListVersionsResult"`
+	XMLNS               string               `xml:"xmlns,attr"`
+	Name                string               `xml:"Name"`
+	Prefix              string               `xml:"Prefix"`
+	KeyMarker           string               `xml:"KeyMarker"`
+	VersionIdMarker     string               `xml:"VersionIdMarker"`
+	NextKeyMarker       string               `xml:"NextKeyMarker,omitempty"`
+	NextVersionIdMarker string               `xml:"NextVersionIdMarker,omitempty"`
+	MaxKeys             uint64               `xml:"MaxKeys"`
+	Delimiter           string               `xml:"Delimiter,omitempty"`
+	EncodingType        string               `xml:"EncodingType,omitempty"`
+	IsTruncated         bool                 `xml:"IsTruncated"`
+	Versions            []*ObjectVersion     `xml:"Version"`
+	DeleteMarkers       []*DeleteMarkerEntry `xml:"DeleteMarker"`
+	CommonPrefixes      []*CommonPrefix      `xml:"CommonPrefixes"`
+}
+
 func NewParts(fsParts []*FSPart) []*Part {
 	parts := make([]*Part, 0)
 	for _, fsPart := range fsParts {
@@ -90,6 +90,46 @@ var (
 	ObjectLockConfigurationNotFound     = &ErrorCode{"ObjectLockConfigurationNotFoundError", "Object Lock configuration does not exist for this bucket", http.StatusNotFound}
 	TooManyRequests                     = &ErrorCode{"TooManyRequests", "too many requests, please retry later", http.StatusTooManyRequests}
 	MalformedPOSTRequest                = &ErrorCode{ErrorCode: "MalformedPOSTRequest", ErrorMessage: "The body of your POST request is not well-formed multipart/form-data.", StatusCode: http.StatusBadRequest}
+	InvalidVersioningStatus             = &ErrorCode{ErrorCode: "IllegalVersioningConfigurationException", ErrorMessage: "The versioning configuration specified in the request is invalid.", StatusCode: http.StatusBadRequest}
+	NoSuchVersion                       = &ErrorCode{ErrorCode: "NoSuchVersion", ErrorMessage: "The specified version does not exist.", StatusCode: http.StatusNotFound}
+	InvalidVersionId                    = &ErrorCode{ErrorCode: "InvalidArgument", ErrorMessage: "Invalid version id specified.", StatusCode: http.StatusBadRequest}
+	MethodNotAllowed                    = &ErrorCode{ErrorCode: "MethodNotAllowed", ErrorMessage: "The specified method is not allowed against this resource.", StatusCode: http.StatusMethodNotAllowed}
+	CopySourceDeleteMarker              = &ErrorCode{ErrorCode: "InvalidRequest", ErrorMessage: "The source of a copy request may not specifically refer to a delete marker by version id.", StatusCode: http.StatusBadRequest}
+	ReservedNameTaken                   = &ErrorCode{ErrorCode: "InvalidBucketState", ErrorMessage: "The name reserved for the internal data of the bucket is taken by an existing object or directory.", StatusCode: http.StatusConflict}
+	NotImplemented                      = &ErrorCode{ErrorCode: "NotImplemented", ErrorMessage: "A header you provided implies functionality that is not implemented.", StatusCode: http.StatusNotImplemented}
+	NoSuchEncryptionConfiguration       = &ErrorCode{ErrorCode: "ServerSideEncryptionConfigurationNotFoundError", ErrorMessage: "The server side encryption configuration was not found.", StatusCode: http.StatusNotFound}
+	InvalidEncryptionAlgorithm          = &ErrorCode{ErrorCode: "InvalidEncryptionAlgorithmError", ErrorMessage: "The encryption request you specified is not valid. The valid value is AES256.", StatusCode: http.StatusBadRequest}
+	InvalidSSERequest                   = &ErrorCode{ErrorCode: "InvalidArgument", ErrorMessage: "Server side encryption with customer provided key is incompatible with the encryption method specified.", StatusCode: http.StatusBadRequest}
+	InvalidSSECustomerKey               = &ErrorCode{ErrorCode: "InvalidArgument", ErrorMessage: "The secret key was invalid for the specified algorithm.", StatusCode: http.StatusBadRequest}
+	SSECustomerKeyMD5Mismatch           = &ErrorCode{ErrorCode: "InvalidArgument", ErrorMessage: "The calculated MD5 hash of the key did not match the hash that was provided.", StatusCode: http.StatusBadRequest}
+	SSECustomerKeyMissing               = &ErrorCode{ErrorCode: "InvalidRequest", ErrorMessage: "The object was stored using a form of Server Side Encryption. The correct parameters must be provided to retrieve the object.", StatusCode: http.StatusBadRequest}
+	SSECustomerKeyMismatch              = &ErrorCode{ErrorCode: "AccessDenied", ErrorMessage: "The provided encryption key does not match the key used to encrypt the object.", StatusCode: http.StatusForbidden}
+	SSECustomerKeyInsecure              = &ErrorCode{ErrorCode: "InvalidRequest", ErrorMessage: "Requests specifying Server Side Encryption with Customer provided keys must be made over a secure connection.", StatusCode: http.StatusBadRequest}
+	SSENotConfigured                    = &ErrorCode{ErrorCode: "NotImplemented", ErrorMessage: "Server side encryption with ObjectNode managed key is not configured.", StatusCode: http.StatusNotImplemented}
+	SSEMasterKeyMissing                 = &ErrorCode{ErrorCode: "InternalError", ErrorMessage: "The master key used to encrypt the object is not available.", StatusCode: http.StatusInternalServerError}
+	NoSuchReplicationConfiguration      = &ErrorCode{ErrorCode: "ReplicationConfigurationNotFoundError", ErrorMessage: "The replication configuration was not found.", StatusCode: http.StatusNotFound}
+	ReplicationVersioningRequired       = &ErrorCode{ErrorCode: "InvalidRequest", ErrorMessage: "Versioning must be 'Enabled' on the bucket to apply a replication configuration.", StatusCode: http.StatusBadRequest}
+	ReplicationConfigurationPresent     = &ErrorCode{ErrorCode: "InvalidBucketState", ErrorMessage: "A replication configuration is present on this bucket, so the versioning state cannot be changed.", StatusCode: http.StatusConflict}
+	InvalidNotificationID               = &ErrorCode{ErrorCode: "InvalidArgument", ErrorMessage: "The notification configuration ID must be unique.", StatusCode: http.StatusBadRequest}
+	InvalidNotificationEvent            = &ErrorCode{ErrorCode: "InvalidArgument", ErrorMessage: "The event is not supported for notifications.", StatusCode: http.StatusBadRequest}
+	InvalidNotificationFilter           = &ErrorCode{ErrorCode: "InvalidArgument", ErrorMessage: "The filter rule name must be either prefix or suffix and cannot be specified more than once.", StatusCode: http.StatusBadRequest}
+	InvalidNotificationDestination      = &ErrorCode{ErrorCode: "InvalidArgument", ErrorMessage: "Unable to validate the destination configuration, the queue is not configured.", StatusCode: http.StatusBadRequest}
+	InvalidObjectState                  = &ErrorCode{ErrorCode: "InvalidObjectState", ErrorMessage: "The operation is not valid for the object's storage class.", StatusCode: http.StatusForbidden}
+	RestoreAlreadyInProgress            = &ErrorCode{ErrorCode: "RestoreAlreadyInProgress", ErrorMessage: "Object restore is already in progress.", StatusCode: http.StatusConflict}
+	InvalidRestoreDays                  = &ErrorCode{ErrorCode: "InvalidArgument", ErrorMessage: "The days of restore must be between 1 and 30000.", StatusCode: http.StatusBadRequest}
+	RestoreNotSupported                 = &ErrorCode{ErrorCode: "InvalidRequest", ErrorMessage: "The bucket has no replica storage class to restore objects to.", StatusCode: http.StatusBadRequest}
+	NoSuchWebsiteConfiguration          = &ErrorCode{ErrorCode: "NoSuchWebsiteConfiguration", ErrorMessage: "The specified bucket does not have a website configuration.", StatusCode: http.StatusNotFound}
+	NoSuchPublicAccessBlock             = &ErrorCode{ErrorCode: "NoSuchPublicAccessBlockConfiguration", ErrorMessage: "The public access block configuration was not found.", StatusCode: http.StatusNotFound}
+	PublicAclsBlocked                   = &ErrorCode{ErrorCode: "AccessDenied", ErrorMessage: "Public ACLs are blocked by the public access block settings.", StatusCode: http.StatusForbidden}
+	PublicPolicyBlocked                 = &ErrorCode{ErrorCode: "AccessDenied", ErrorMessage: "Public policies are blocked by the public access block settings.", StatusCode: http.StatusForbidden}
+	InvalidExpressionType               = &ErrorCode{ErrorCode: "InvalidExpressionType", ErrorMessage: "The ExpressionType is invalid. Only SQL expressions are supported.", StatusCode: http.StatusBadRequest}
+	InvalidCompressionFormat            = &ErrorCode{ErrorCode: "InvalidCompressionFormat", ErrorMessage: "The file is not in a supported compression format. Only GZIP and BZIP2 are supported.", StatusCode: http.StatusBadRequest}
+	InvalidFileHeaderInfo               = &ErrorCode{ErrorCode: "InvalidFileHeaderInfo", ErrorMessage: "The FileHeaderInfo is invalid. Only NONE, USE, and IGNORE are supported.", StatusCode: http.StatusBadRequest}
+	InvalidJsonType                     = &ErrorCode{ErrorCode: "InvalidJsonType", ErrorMessage: "The JsonType is invalid. Only DOCUMENT and LINES are supported.", StatusCode: http.StatusBadRequest}
+	InvalidQuoteFields                  = &ErrorCode{ErrorCode: "InvalidQuoteFields", ErrorMessage: "The QuoteFields is invalid. Only ALWAYS and ASNEEDED are supported.", StatusCode: http.StatusBadRequest}
+	InvalidSelectDelimiter              = &ErrorCode{ErrorCode: "InvalidRequestParameter", ErrorMessage: "The delimiters and quote characters must be a single character, the record delimiter can also be CRLF.", StatusCode: http.StatusBadRequest}
+	InvalidScanRange                    = &ErrorCode{ErrorCode: "InvalidRequestParameter", ErrorMessage: "The ScanRange is invalid or is not supported by the input serialization.", StatusCode: http.StatusBadRequest}
+	ParquetNotSupported                 = &ErrorCode{ErrorCode: "NotImplemented", ErrorMessage: "Parquet input serialization is not supported.", StatusCode: http.StatusNotImplemented}
 )
 
 type ErrorCode struct {
@@ -16,6 +16,7 @@ package objectnode
 
 import (
 	"net/http"
+	"regexp"
 
 	"github.com/cubefs/cubefs/proto"
 
@@ -26,6 +27,12 @@ import (
 func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 	var bucketRouters []*mux.Router
 	bRouter := router.PathPrefix("/").Subrouter()
+	// website endpoints are matched before object storage domains
+	for _, d := range o.webDomains {
+		website := "{" + ContextKeyWebsite + ":" + regexp.QuoteMeta(d) + "}"
+		o.registerWebsiteRouters(bRouter.Host("{bucket:.+}." + website).Subrouter())
+		o.registerWebsiteRouters(bRouter.Host("{bucket:.+}." + website + ":{port:[0-9]+}").Subrouter())
+	}
 	for _, d := range o.domains {
 		bucketRouters = append(bucketRouters, bRouter.Host("{bucket:.+}."+d).Subrouter())
 		bucketRouters = append(bucketRouters, bRouter.Host("{bucket:.+}."+d+":{port:[0-9]+}").Subrouter())
@@ -179,11 +186,10 @@ func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 
 		// Get bucket encryption
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketEncryption.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSGetBucketEncryptionAction)).
 			Methods(http.MethodGet).
 			Queries("encryption", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.getBucketEncryptionHandler)
 
 		// Get bucket cors
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketCors.html
@@ -195,19 +201,17 @@ func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 
 		// Get bucket website
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketWebsite.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSGetBucketWebsiteAction)).
 			Methods(http.MethodGet).
 			Queries("website", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.getBucketWebsiteHandler)
 
 		// Get public access block
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetPublicAccessBlock.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSGetPublicAccessBlockAction)).
 			Methods(http.MethodGet).
 			Queries("publicAccessBlock", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.getPublicAccessBlockHandler)
 
 		// Get bucket request payment
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketRequestPayment.html
@@ -219,11 +223,17 @@ func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 
 		// Get bucket replication
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketReplication.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSGetBucketReplicationAction)).
 			Methods(http.MethodGet).
 			Queries("replication", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.getBucketReplicationHandler)
+
+		// Get bucket notification
+		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketNotificationConfiguration.html
+		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSGetBucketNotificationAction)).
+			Methods(http.MethodGet).
+			Queries("notification", "").
+			HandlerFunc(o.getBucketNotificationHandler)
 
 		// Get bucket lifecycle
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketLifecycle.html
@@ -242,19 +252,17 @@ func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 
 		// Get bucket versioning
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketVersioning.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSGetBucketVersioningAction)).
 			Methods(http.MethodGet).
 			Queries("versioning", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.getBucketVersioningHandler)
 
 		// List object versions
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_ListObjectVersions.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSListObjectVersionsAction)).
 			Methods(http.MethodGet).
 			Queries("versions", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.listObjectVersionsHandler)
 
 		// List objects version 1
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_ListObjects.html
@@ -282,12 +290,19 @@ func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 
 		// Restore object
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_RestoreObject.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSRestoreObjectAction)).
 			Methods(http.MethodPost).
 			Path("/{object:.+}").
 			Queries("restore", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.restoreObjectHandler)
+
+		// Select object content
+		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_SelectObjectContent.html
+		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSSelectObjectContentAction)).
+			Methods(http.MethodPost).
+			Path("/{object:.+}").
+			Queries("select", "", "select-type", "2").
+			HandlerFunc(o.selectObjectContentHandler)
 
 		// Delete objects (multiple objects)
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteObjects.html
@@ -408,11 +423,10 @@ func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 
 		// Put bucket encryption
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketEncryption.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSPutBucketEncryptionAction)).
 			Methods(http.MethodPut).
 			Queries("encryption", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.putBucketEncryptionHandler)
 
 		// Put bucket cors
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketCors.html
@@ -424,19 +438,17 @@ func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 
 		// Put bucket website
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketWebsite.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSPutBucketWebsiteAction)).
 			Methods(http.MethodPut).
 			Queries("website", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.putBucketWebsiteHandler)
 
 		// Put public access block
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutPublicAccessBlock.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSPutPublicAccessBlockAction)).
 			Methods(http.MethodPut).
 			Queries("publicAccessBlock", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.putPublicAccessBlockHandler)
 
 		// Put bucket request payment
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketRequestPayment.html
@@ -448,11 +460,17 @@ func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 
 		// Put bucket replication
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketReplication.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSPutBucketReplicationAction)).
 			Methods(http.MethodPut).
 			Queries("replication", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.putBucketReplicationHandler)
+
+		// Put bucket notification
+		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketNotificationConfiguration.html
+		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSPutBucketNotificationAction)).
+			Methods(http.MethodPut).
+			Queries("notification", "").
+			HandlerFunc(o.putBucketNotificationHandler)
 
 		// Put bucket lifecycle
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketLifecycle.html
@@ -471,11 +489,10 @@ func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 
 		// Put bucket versioning
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketVersioning.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSPutBucketVersioningAction)).
 			Methods(http.MethodPut).
 			Queries("versioning", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.putBucketVersioningHandler)
 
 		// Create bucket
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_CreateBucket.html
@@ -532,11 +549,10 @@ func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 
 		// Delete bucket encryption
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketEncryption.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSDeleteBucketEncryptionAction)).
 			Methods(http.MethodDelete).
 			Queries("encryption", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.deleteBucketEncryptionHandler)
 
 		// Delete bucket cors
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketCors.html
@@ -548,27 +564,24 @@ func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 
 		// Delete bucket website
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketWebsite.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSDeleteBucketWebsiteAction)).
 			Methods(http.MethodDelete).
 			Queries("website", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.deleteBucketWebsiteHandler)
 
 		// Delete public access block
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeletePublicAccessBlock.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSDeletePublicAccessBlockAction)).
 			Methods(http.MethodDelete).
 			Queries("publicAccessBlock", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.deletePublicAccessBlockHandler)
 
 		// Delete bucket replication
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketReplication.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSDeleteBucketReplicationAction)).
 			Methods(http.MethodDelete).
 			Queries("replication", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.deleteBucketReplicationHandler)
 
 		// Delete bucket lifecycle
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketLifecycle.html
@@ -625,3 +638,20 @@ func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 	// Unsupported operation
 	router.NotFoundHandler = http.HandlerFunc(o.unsupportedOperationHandler)
 }
+
+// register website endpoint routers, which serve the objects of bucket as static website
+func (o *ObjectNode) registerWebsiteRouters(r *mux.Router) {
+	r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSHeadObjectAction)).
+		Methods(http.MethodHead).
+		Path("/{object:.*}").
+		HandlerFunc(o.websiteHandler)
+
+	r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSGetObjectAction)).
+		Methods(http.MethodGet).
+		Path("/{object:.*}").
+		HandlerFunc(o.websiteHandler)
+
+	// website endpoints only support GET and HEAD requests
+	r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSGetObjectAction)).
+		HandlerFunc(o.websiteHandler)
+}
@@ -79,6 +79,7 @@ const (
 	GET_OBJECT_TAGGING         = "GetObjectTagging"           // api:  Get /<bucketname>/<objname>?tagging   , host=<bucket>.domain
 	GET_OBJECT_RETENTION       = "GetObjectRetention"         // api:  Get /<bucketname>/<objname>?retention, host=<bucket>.domain
 	HEAD_OBJECT                = "HeadObject"                 // api:  HEAD /<ObjectName> , host=<bucket>.domain
+	SELECT_OBJECT_CONTENT      = "SelectObjectContent"        // api:  POST /<ObjectName>?select&select-type=2 , host=<bucket>.domain
 	OPTIONS_OBJECT             = "OptionsObject"              // api:  OPTIONS /<ObjectName>, host=<bucket>.domain
 	POST_OBJECT                = "PostObject"                 // api:  Post /  , host=<bucket>.domain
 	PUT_OBJECT                 = "PutObject"                  // api:  Put  /<objname>,  host=<bucket>.domain
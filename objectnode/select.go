@@ -0,0 +1,393 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_SelectObjectContent.html
+
+const (
+	MaxSelectRequestSize = 512 << 10 // 512KB
+
+	SelectExpressionTypeSQL = "SQL"
+
+	SelectCompressionNone  = "NONE"
+	SelectCompressionGzip  = "GZIP"
+	SelectCompressionBzip2 = "BZIP2"
+
+	SelectFileHeaderNone   = "NONE"
+	SelectFileHeaderUse    = "USE"
+	SelectFileHeaderIgnore = "IGNORE"
+
+	SelectJSONTypeDocument = "DOCUMENT"
+	SelectJSONTypeLines    = "LINES"
+
+	SelectQuoteFieldsAlways   = "ALWAYS"
+	SelectQuoteFieldsAsNeeded = "ASNEEDED"
+)
+
+type SelectObjectContentRequest struct {
+	XMLName             xml.Name                  `xml:"SelectObjectContentRequest"`
+	Expression          string                    `xml:"Expression"`
+	ExpressionType      string                    `xml:"ExpressionType"`
+	RequestProgress     *SelectRequestProgress    `xml:"RequestProgress,omitempty"`
+	InputSerialization  SelectInputSerialization  `xml:"InputSerialization"`
+	OutputSerialization SelectOutputSerialization `xml:"OutputSerialization"`
+	ScanRange           *SelectScanRange          `xml:"ScanRange,omitempty"`
+}
+
+type SelectRequestProgress struct {
+	Enabled bool `xml:"Enabled"`
+}
+
+type SelectInputSerialization struct {
+	CompressionType string              `xml:"CompressionType,omitempty"`
+	CSV             *SelectCSVInput     `xml:"CSV,omitempty"`
+	JSON            *SelectJSONInput    `xml:"JSON,omitempty"`
+	Parquet         *SelectParquetInput `xml:"Parquet,omitempty"`
+}
+
+type SelectCSVInput struct {
+	AllowQuotedRecordDelimiter bool    `xml:"AllowQuotedRecordDelimiter,omitempty"`
+	Comments                   *string `xml:"Comments,omitempty"`
+	FieldDelimiter             string  `xml:"FieldDelimiter,omitempty"`
+	FileHeaderInfo             string  `xml:"FileHeaderInfo,omitempty"`
+	QuoteCharacter             string  `xml:"QuoteCharacter,omitempty"`
+	QuoteEscapeCharacter       string  `xml:"QuoteEscapeCharacter,omitempty"`
+	RecordDelimiter            string  `xml:"RecordDelimiter,omitempty"`
+}
+
+type SelectJSONInput struct {
+	Type string `xml:"Type"`
+}
+
+type SelectParquetInput struct{}
+
+type SelectOutputSerialization struct {
+	CSV  *SelectCSVOutput  `xml:"CSV,omitempty"`
+	JSON *SelectJSONOutput `xml:"JSON,omitempty"`
+}
+
+type SelectCSVOutput struct {
+	FieldDelimiter       string `xml:"FieldDelimiter,omitempty"`
+	QuoteCharacter       string `xml:"QuoteCharacter,omitempty"`
+	QuoteEscapeCharacter string `xml:"QuoteEscapeCharacter,omitempty"`
+	QuoteFields          string `xml:"QuoteFields,omitempty"`
+	RecordDelimiter      string `xml:"RecordDelimiter,omitempty"`
+}
+
+type SelectJSONOutput struct {
+	RecordDelimiter string `xml:"RecordDelimiter,omitempty"`
+}
+
+type SelectScanRange struct {
+	Start *int64 `xml:"Start,omitempty"`
+	End   *int64 `xml:"End,omitempty"`
+}
+
+func (c *SelectCSVInput) fieldDelimiter() string {
+	if c.FieldDelimiter == "" {
+		return ","
+	}
+	return c.FieldDelimiter
+}
+
+func (c *SelectCSVInput) recordDelimiter() string {
+	if c.RecordDelimiter == "" {
+		return "\n"
+	}
+	return c.RecordDelimiter
+}
+
+func (c *SelectCSVInput) comments() string {
+	if c.Comments == nil {
+		return "#"
+	}
+	return *c.Comments
+}
+
+func (c *SelectCSVOutput) fieldDelimiter() string {
+	if c.FieldDelimiter == "" {
+		return ","
+	}
+	return c.FieldDelimiter
+}
+
+func (c *SelectCSVOutput) recordDelimiter() string {
+	if c.RecordDelimiter == "" {
+		return "\n"
+	}
+	return c.RecordDelimiter
+}
+
+func (c *SelectCSVOutput) quoteCharacter() string {
+	if c.QuoteCharacter == "" {
+		return `"`
+	}
+	return c.QuoteCharacter
+}
+
+func (c *SelectCSVOutput) quoteEscapeCharacter() string {
+	if c.QuoteEscapeCharacter == "" {
+		return c.quoteCharacter()
+	}
+	return c.QuoteEscapeCharacter
+}
+
+func (j *SelectJSONOutput) recordDelimiter() string {
+	if j.RecordDelimiter == "" {
+		return "\n"
+	}
+	return j.RecordDelimiter
+}
+
+func isSingleChar(s string) bool {
+	return utf8.RuneCountInString(s) == 1
+}
+
+func (c *SelectCSVInput) validate() *ErrorCode {
+	switch strings.ToUpper(c.FileHeaderInfo) {
+	case "", SelectFileHeaderNone, SelectFileHeaderUse, SelectFileHeaderIgnore:
+	default:
+		return InvalidFileHeaderInfo
+	}
+	// the quote and escape character of CSV parser are always the double quote
+	if !isSingleChar(c.fieldDelimiter()) || (c.QuoteCharacter != "" && c.QuoteCharacter != `"`) ||
+		(c.QuoteEscapeCharacter != "" && c.QuoteEscapeCharacter != `"`) || utf8.RuneCountInString(c.comments()) > 1 {
+		return InvalidSelectDelimiter
+	}
+	if delim := c.recordDelimiter(); delim != "\r\n" && len(delim) != 1 {
+		return InvalidSelectDelimiter
+	}
+	return nil
+}
+
+func (c *SelectCSVOutput) validate() *ErrorCode {
+	switch strings.ToUpper(c.QuoteFields) {
+	case "", SelectQuoteFieldsAlways, SelectQuoteFieldsAsNeeded:
+	default:
+		return InvalidQuoteFields
+	}
+	if !isSingleChar(c.fieldDelimiter()) || !isSingleChar(c.quoteCharacter()) || !isSingleChar(c.quoteEscapeCharacter()) {
+		return InvalidSelectDelimiter
+	}
+	if delim := c.recordDelimiter(); delim != "\r\n" && !isSingleChar(delim) {
+		return InvalidSelectDelimiter
+	}
+	return nil
+}
+
+func (req *SelectObjectContentRequest) validate() *ErrorCode {
+	if strings.ToUpper(req.ExpressionType) != SelectExpressionTypeSQL {
+		return InvalidExpressionType
+	}
+	if len(req.Expression) > MaxSelectExpressionSize {
+		return NewError("ExpressionTooLong", "The SQL expression is too long.", http.StatusBadRequest)
+	}
+	input, output := &req.InputSerialization, &req.OutputSerialization
+	switch strings.ToUpper(input.CompressionType) {
+	case "", SelectCompressionNone, SelectCompressionGzip, SelectCompressionBzip2:
+	default:
+		return InvalidCompressionFormat
+	}
+	inputs := 0
+	if input.CSV != nil {
+		inputs++
+		if ec := input.CSV.validate(); ec != nil {
+			return ec
+		}
+	}
+	if input.JSON != nil {
+		inputs++
+		switch strings.ToUpper(input.JSON.Type) {
+		case SelectJSONTypeDocument, SelectJSONTypeLines:
+		default:
+			return InvalidJsonType
+		}
+	}
+	if input.Parquet != nil {
+		return ParquetNotSupported
+	}
+	if inputs != 1 {
+		return MalformedXML
+	}
+	outputs := 0
+	if output.CSV != nil {
+		outputs++
+		if ec := output.CSV.validate(); ec != nil {
+			return ec
+		}
+	}
+	if output.JSON != nil {
+		outputs++
+		if delim := output.JSON.recordDelimiter(); delim != "\r\n" && !isSingleChar(delim) {
+			return InvalidSelectDelimiter
+		}
+	}
+	if outputs != 1 {
+		return MalformedXML
+	}
+	if rng := req.ScanRange; rng != nil {
+		// the records of input must be split by lines to be scanned in range
+		if (input.CSV == nil || input.CSV.AllowQuotedRecordDelimiter || !strings.HasSuffix(input.CSV.recordDelimiter(), "\n")) &&
+			(input.JSON == nil || strings.ToUpper(input.JSON.Type) != SelectJSONTypeLines) {
+			return InvalidScanRange
+		}
+		if c := strings.ToUpper(input.CompressionType); c != "" && c != SelectCompressionNone {
+			return InvalidScanRange
+		}
+		if (rng.Start == nil && rng.End == nil) || (rng.Start != nil && *rng.Start < 0) ||
+			(rng.End != nil && *rng.End < 0) || (rng.Start != nil && rng.End != nil && *rng.Start > *rng.End) {
+			return InvalidScanRange
+		}
+	}
+	return nil
+}
+
+// ParseSelectRequest parses the select request from request body.
+func ParseSelectRequest(body []byte) (req *SelectObjectContentRequest, err error) {
+	req = &SelectObjectContentRequest{}
+	if err = UnmarshalXMLEntity(body, req); err != nil {
+		return nil, MalformedXML
+	}
+	if ec := req.validate(); ec != nil {
+		return nil, ec
+	}
+	return req, nil
+}
+
+// scanRange returns the inclusive range of object to scan, the ok is false if the range is empty.
+func (req *SelectObjectContentRequest) scanRange(size int64) (start, end int64, ok bool) {
+	start, end = 0, size-1
+	if rng := req.ScanRange; rng != nil {
+		switch {
+		case rng.Start != nil && rng.End != nil:
+			start, end = *rng.Start, *rng.End
+		case rng.Start != nil:
+			start = *rng.Start
+		default:
+			// the last bytes of object if only the end is specified
+			start = size - *rng.End
+		}
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end > size-1 {
+		end = size - 1
+	}
+	return start, end, start <= end
+}
+
+// executeSelect runs the query over the data of object and writes the results as event stream.
+// The error is sent as an error event if the stream is started, otherwise nothing is written so
+// that the error can be responded as a normal error.
+func executeSelect(w io.Writer, src io.Reader, req *SelectObjectContentRequest, query *selectQuery) (started bool, err error) {
+	scanned := &countingReader{r: src}
+	processed := &countingReader{r: scanned}
+	es := newEventStreamWriter(w, scanned, processed, req.RequestProgress != nil && req.RequestProgress.Enabled)
+	if err = runSelect(es, processed, req, query); err != nil {
+		if es.sent == 0 {
+			return false, err
+		}
+		ec, ok := err.(*ErrorCode)
+		if !ok {
+			ec = InternalErrorCode(err)
+		}
+		_ = es.sendError(ec)
+		return true, err
+	}
+	return true, es.finish()
+}
+
+func runSelect(es *eventStreamWriter, processed *countingReader, req *SelectObjectContentRequest, query *selectQuery) (err error) {
+	input, output := &req.InputSerialization, &req.OutputSerialization
+	switch strings.ToUpper(input.CompressionType) {
+	case SelectCompressionGzip:
+		var gz *gzip.Reader
+		if gz, err = gzip.NewReader(processed.r); err != nil {
+			return InvalidCompressionFormat
+		}
+		defer gz.Close()
+		processed.r = gz
+	case SelectCompressionBzip2:
+		processed.r = bzip2.NewReader(processed.r)
+	}
+
+	var reader selectRecordReader
+	if input.CSV != nil {
+		if reader, err = newCSVRecordReader(processed, input.CSV); err != nil {
+			return
+		}
+	} else {
+		reader = newJSONRecordReader(processed)
+	}
+	var writer selectRecordWriter
+	if output.CSV != nil {
+		writer = newCSVRecordWriter(output.CSV)
+	} else {
+		writer = &jsonRecordWriter{recordDelimiter: output.JSON.recordDelimiter()}
+	}
+
+	var count int64
+	for query.aggregate() || query.limit < 0 || count < query.limit {
+		var rec selectRecord
+		if rec, err = reader.Read(); err == io.EOF {
+			break
+		}
+		if err != nil {
+			return
+		}
+		var matched bool
+		if matched, err = query.match(rec); err != nil {
+			return
+		}
+		if matched && query.aggregate() {
+			if err = query.accumulate(rec); err != nil {
+				return
+			}
+		} else if matched {
+			var fields []selectField
+			if fields, err = query.project(rec); err != nil {
+				return
+			}
+			if err = writer.Write(&es.buf, fields); err != nil {
+				return
+			}
+			count++
+		}
+		if err = es.tick(); err != nil {
+			return
+		}
+	}
+	if query.aggregate() && query.limit != 0 {
+		var fields []selectField
+		if fields, err = query.project(nil); err != nil {
+			return
+		}
+		if err = writer.Write(&es.buf, fields); err != nil {
+			return
+		}
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"time"
+)
+
+// The response of SelectObjectContent is a stream of messages in event stream encoding:
+//
+//	total length (4) | headers length (4) | prelude crc (4) | headers | payload | message crc (4)
+//
+// https://docs.aws.amazon.com/AmazonS3/latest/API/RESTSelectObjectAppendix.html
+
+const (
+	eventHeaderTypeString = 7
+
+	eventTypeRecords  = "Records"
+	eventTypeStats    = "Stats"
+	eventTypeProgress = "Progress"
+	eventTypeCont     = "Cont"
+	eventTypeEnd      = "End"
+
+	selectMaxRecordsPayload = 128 << 10 // 128KB
+	selectKeepAlive         = time.Second
+)
+
+type eventHeader struct {
+	name  string
+	value string
+}
+
+// encodeEventMessage encodes the message with headers of string type.
+func encodeEventMessage(headers []eventHeader, payload []byte) []byte {
+	hbuf := new(bytes.Buffer)
+	for _, h := range headers {
+		hbuf.WriteByte(byte(len(h.name)))
+		hbuf.WriteString(h.name)
+		hbuf.WriteByte(eventHeaderTypeString)
+		_ = binary.Write(hbuf, binary.BigEndian, uint16(len(h.value)))
+		hbuf.WriteString(h.value)
+	}
+
+	total := 4 + 4 + 4 + hbuf.Len() + len(payload) + 4
+	msg := make([]byte, total)
+	binary.BigEndian.PutUint32(msg[0:], uint32(total))
+	binary.BigEndian.PutUint32(msg[4:], uint32(hbuf.Len()))
+	binary.BigEndian.PutUint32(msg[8:], crc32.ChecksumIEEE(msg[:8]))
+	n := 12 + copy(msg[12:], hbuf.Bytes())
+	n += copy(msg[n:], payload)
+	binary.BigEndian.PutUint32(msg[n:], crc32.ChecksumIEEE(msg[:n]))
+	return msg
+}
+
+func eventMessage(eventType, contentType string, payload []byte) []byte {
+	headers := []eventHeader{{name: ":event-type", value: eventType}}
+	if contentType != "" {
+		headers = append(headers, eventHeader{name: ":content-type", value: contentType})
+	}
+	headers = append(headers, eventHeader{name: ":message-type", value: "event"})
+	return encodeEventMessage(headers, payload)
+}
+
+func errorEventMessage(ec *ErrorCode) []byte {
+	return encodeEventMessage([]eventHeader{
+		{name: ":error-code", value: ec.ErrorCode},
+		{name: ":error-message", value: ec.ErrorMessage},
+		{name: ":message-type", value: "error"},
+	}, nil)
+}
+
+// SelectStats is the payload of Stats and Progress events.
+type SelectStats struct {
+	BytesScanned   int64 `xml:"BytesScanned"`
+	BytesProcessed int64 `xml:"BytesProcessed"`
+	BytesReturned  int64 `xml:"BytesReturned"`
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (n int, err error) {
+	n, err = c.r.Read(p)
+	c.n += int64(n)
+	return
+}
+
+// eventStreamWriter writes the records and events of select response.
+type eventStreamWriter struct {
+	w        io.Writer
+	buf      bytes.Buffer
+	scanned  *countingReader
+	process  *countingReader
+	returned int64
+	progress bool
+	lastSent time.Time
+	sent     int
+	err      error
+}
+
+func newEventStreamWriter(w io.Writer, scanned, processed *countingReader, progress bool) *eventStreamWriter {
+	return &eventStreamWriter{w: w, scanned: scanned, process: processed, progress: progress, lastSent: time.Now()}
+}
+
+func (e *eventStreamWriter) send(msg []byte) error {
+	if e.err != nil {
+		return e.err
+	}
+	if _, e.err = e.w.Write(msg); e.err != nil {
+		return e.err
+	}
+	if f, ok := e.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	e.lastSent = time.Now()
+	e.sent++
+	return nil
+}
+
+func (e *eventStreamWriter) stats() *SelectStats {
+	return &SelectStats{BytesScanned: e.scanned.n, BytesProcessed: e.process.n, BytesReturned: e.returned}
+}
+
+// sendStats sends the Stats or Progress event, which have the same payload.
+func (e *eventStreamWriter) sendStats(eventType string) error {
+	payload, err := xml.Marshal(&struct {
+		XMLName xml.Name
+		*SelectStats
+	}{XMLName: xml.Name{Local: eventType}, SelectStats: e.stats()})
+	if err != nil {
+		return err
+	}
+	return e.send(eventMessage(eventType, ValueContentTypeXML, payload))
+}
+
+// flush sends the buffered records as a Records event.
+func (e *eventStreamWriter) flush() error {
+	if e.buf.Len() == 0 {
+		return nil
+	}
+	e.returned += int64(e.buf.Len())
+	msg := eventMessage(eventTypeRecords, ValueContentTypeStream, e.buf.Bytes())
+	e.buf.Reset()
+	return e.send(msg)
+}
+
+// tick flushes the records if the buffer is full, and keeps the connection alive with
+// Progress or Cont events while no records are sent.
+func (e *eventStreamWriter) tick() error {
+	if e.buf.Len() >= selectMaxRecordsPayload {
+		return e.flush()
+	}
+	if time.Since(e.lastSent) < selectKeepAlive {
+		return nil
+	}
+	if err := e.flush(); err != nil {
+		return err
+	}
+	if e.progress {
+		return e.sendStats(eventTypeProgress)
+	}
+	return e.send(eventMessage(eventTypeCont, "", nil))
+}
+
+// finish sends the rest of records, the Stats and the End events.
+func (e *eventStreamWriter) finish() error {
+	if err := e.flush(); err != nil {
+		return err
+	}
+	if err := e.sendStats(eventTypeStats); err != nil {
+		return err
+	}
+	return e.send(eventMessage(eventTypeEnd, "", nil))
+}
+
+func (e *eventStreamWriter) sendError(ec *ErrorCode) error {
+	e.buf.Reset()
+	return e.send(errorEventMessage(ec))
+}
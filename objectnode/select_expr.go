@@ -0,0 +1,737 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Values of select expressions are nil (NULL or MISSING), bool, int64, float64, string,
+// *selectObject and []interface{}, the last two are only read from JSON documents.
+
+func selectEvalError(format string, args ...interface{}) *ErrorCode {
+	return NewError("EvaluatorInvalidArguments", fmt.Sprintf(format, args...), http.StatusBadRequest)
+}
+
+type selectExpr interface {
+	eval(rec selectRecord) (interface{}, error)
+}
+
+type selectLiteral struct {
+	value interface{}
+}
+
+func (e *selectLiteral) eval(rec selectRecord) (interface{}, error) {
+	return e.value, nil
+}
+
+type selectPathElem struct {
+	name    string
+	quoted  bool
+	index   int
+	isIndex bool
+}
+
+type selectColumn struct {
+	path []selectPathElem
+}
+
+func (e *selectColumn) eval(rec selectRecord) (interface{}, error) {
+	if rec == nil {
+		return nil, selectEvalError("Column %v is referenced without record", e.path[0].name)
+	}
+	v, ok := rec.column(e.path[0].name, e.path[0].quoted)
+	if !ok {
+		return nil, nil
+	}
+	for _, elem := range e.path[1:] {
+		switch value := v.(type) {
+		case *selectObject:
+			if elem.isIndex {
+				return nil, nil
+			}
+			if v, ok = value.get(elem.name, elem.quoted); !ok {
+				return nil, nil
+			}
+		case []interface{}:
+			if !elem.isIndex || elem.index >= len(value) {
+				return nil, nil
+			}
+			v = value[elem.index]
+		default:
+			return nil, nil
+		}
+	}
+	return v, nil
+}
+
+type selectNot struct {
+	expr selectExpr
+}
+
+func (e *selectNot) eval(rec selectRecord) (interface{}, error) {
+	v, err := e.expr.eval(rec)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return nil, selectEvalError("NOT requires a boolean operand")
+	}
+	return !b, nil
+}
+
+type selectLogical struct {
+	op          string
+	left, right selectExpr
+}
+
+// eval evaluates AND and OR in three-valued logic.
+func (e *selectLogical) eval(rec selectRecord) (interface{}, error) {
+	l, err := evalBool(e.left, rec)
+	if err != nil {
+		return nil, err
+	}
+	if l != nil && *l == (e.op == "OR") {
+		return *l, nil
+	}
+	r, err := evalBool(e.right, rec)
+	if err != nil {
+		return nil, err
+	}
+	if r != nil && *r == (e.op == "OR") {
+		return *r, nil
+	}
+	if l == nil || r == nil {
+		return nil, nil
+	}
+	return *r, nil
+}
+
+func evalBool(e selectExpr, rec selectRecord) (*bool, error) {
+	v, err := e.eval(rec)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return nil, selectEvalError("AND and OR require boolean operands")
+	}
+	return &b, nil
+}
+
+type selectCompare struct {
+	op          string
+	left, right selectExpr
+}
+
+func (e *selectCompare) eval(rec selectRecord) (interface{}, error) {
+	l, err := e.left.eval(rec)
+	if err != nil {
+		return nil, err
+	}
+	r, err := e.right.eval(rec)
+	if err != nil {
+		return nil, err
+	}
+	if l == nil || r == nil {
+		return nil, nil
+	}
+	c, err := compareSelectValues(l, r)
+	if err != nil {
+		return nil, err
+	}
+	switch e.op {
+	case "=":
+		return c == 0, nil
+	case "!=", "<>":
+		return c != 0, nil
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	default:
+		return c >= 0, nil
+	}
+}
+
+type selectIsNull struct {
+	expr   selectExpr
+	negate bool
+}
+
+func (e *selectIsNull) eval(rec selectRecord) (interface{}, error) {
+	v, err := e.expr.eval(rec)
+	if err != nil {
+		return nil, err
+	}
+	return (v == nil) != e.negate, nil
+}
+
+type selectLike struct {
+	expr, pattern, escape selectExpr
+	negate                bool
+	cache                 map[string]*regexp.Regexp
+}
+
+func (e *selectLike) eval(rec selectRecord) (interface{}, error) {
+	v, err := e.expr.eval(rec)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	p, err := e.pattern.eval(rec)
+	if err != nil || p == nil {
+		return nil, err
+	}
+	escape := ""
+	if e.escape != nil {
+		var esc interface{}
+		if esc, err = e.escape.eval(rec); err != nil {
+			return nil, err
+		}
+		if escape = formatSelectValue(esc); len([]rune(escape)) > 1 {
+			return nil, selectEvalError("ESCAPE requires a single character")
+		}
+	}
+	pattern := formatSelectValue(p)
+	re, ok := e.cache[escape+pattern]
+	if !ok {
+		if re, err = likeToRegexp(pattern, escape); err != nil {
+			return nil, err
+		}
+		if e.cache == nil {
+			e.cache = make(map[string]*regexp.Regexp)
+		}
+		e.cache[escape+pattern] = re
+	}
+	return re.MatchString(formatSelectValue(v)) != e.negate, nil
+}
+
+func likeToRegexp(pattern, escape string) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	sb.WriteString("(?s)^")
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		if escape != "" && string(c) == escape {
+			if i+1 >= len(runes) {
+				return nil, selectEvalError("LIKE pattern ends with escape character")
+			}
+			i++
+			sb.WriteString(regexp.QuoteMeta(string(runes[i])))
+			continue
+		}
+		switch c {
+		case '%':
+			sb.WriteString(".*")
+		case '_':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
+
+type selectIn struct {
+	expr   selectExpr
+	list   []selectExpr
+	negate bool
+}
+
+func (e *selectIn) eval(rec selectRecord) (interface{}, error) {
+	v, err := e.expr.eval(rec)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	for _, item := range e.list {
+		var iv interface{}
+		if iv, err = item.eval(rec); err != nil {
+			return nil, err
+		}
+		if iv == nil {
+			continue
+		}
+		if c, err := compareSelectValues(v, iv); err == nil && c == 0 {
+			return !e.negate, nil
+		}
+	}
+	return e.negate, nil
+}
+
+type selectBetween struct {
+	expr, lower, upper selectExpr
+	negate             bool
+}
+
+func (e *selectBetween) eval(rec selectRecord) (interface{}, error) {
+	var values [3]interface{}
+	for i, expr := range []selectExpr{e.expr, e.lower, e.upper} {
+		v, err := expr.eval(rec)
+		if err != nil || v == nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	lc, err := compareSelectValues(values[0], values[1])
+	if err != nil {
+		return nil, err
+	}
+	uc, err := compareSelectValues(values[0], values[2])
+	if err != nil {
+		return nil, err
+	}
+	return (lc >= 0 && uc <= 0) != e.negate, nil
+}
+
+type selectArithmetic struct {
+	op          string
+	left, right selectExpr
+}
+
+func (e *selectArithmetic) eval(rec selectRecord) (interface{}, error) {
+	l, err := e.left.eval(rec)
+	if err != nil {
+		return nil, err
+	}
+	r, err := e.right.eval(rec)
+	if err != nil {
+		return nil, err
+	}
+	if l == nil || r == nil {
+		return nil, nil
+	}
+	if e.op == "||" {
+		return formatSelectValue(l) + formatSelectValue(r), nil
+	}
+	ln, ok := toSelectNumber(l)
+	if !ok {
+		return nil, selectEvalError("Operator %v requires numeric operands but found %v", e.op, formatSelectValue(l))
+	}
+	rn, ok := toSelectNumber(r)
+	if !ok {
+		return nil, selectEvalError("Operator %v requires numeric operands but found %v", e.op, formatSelectValue(r))
+	}
+	li, lInt := ln.(int64)
+	ri, rInt := rn.(int64)
+	if lInt && rInt {
+		switch e.op {
+		case "+":
+			return li + ri, nil
+		case "-":
+			return li - ri, nil
+		case "*":
+			return li * ri, nil
+		case "/", "%":
+			if ri == 0 {
+				return nil, selectEvalError("Division by zero")
+			}
+			if e.op == "/" {
+				return li / ri, nil
+			}
+			return li % ri, nil
+		}
+	}
+	lf, rf := toSelectFloat(ln), toSelectFloat(rn)
+	switch e.op {
+	case "+":
+		return lf + rf, nil
+	case "-":
+		return lf - rf, nil
+	case "*":
+		return lf * rf, nil
+	case "/":
+		if rf == 0 {
+			return nil, selectEvalError("Division by zero")
+		}
+		return lf / rf, nil
+	default:
+		if rf == 0 {
+			return nil, selectEvalError("Division by zero")
+		}
+		return math.Mod(lf, rf), nil
+	}
+}
+
+const (
+	selectTypeInt = iota
+	selectTypeFloat
+	selectTypeString
+	selectTypeBool
+)
+
+type selectCast struct {
+	expr selectExpr
+	typ  int
+}
+
+func (e *selectCast) eval(rec selectRecord) (interface{}, error) {
+	v, err := e.expr.eval(rec)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	switch e.typ {
+	case selectTypeInt:
+		n, ok := toSelectNumber(v)
+		if !ok {
+			return nil, NewError("CastFailed", fmt.Sprintf("Cannot cast '%v' to INT", formatSelectValue(v)), http.StatusBadRequest)
+		}
+		if f, isFloat := n.(float64); isFloat {
+			return int64(f), nil
+		}
+		return n, nil
+	case selectTypeFloat:
+		n, ok := toSelectNumber(v)
+		if !ok {
+			return nil, NewError("CastFailed", fmt.Sprintf("Cannot cast '%v' to FLOAT", formatSelectValue(v)), http.StatusBadRequest)
+		}
+		return toSelectFloat(n), nil
+	case selectTypeBool:
+		switch value := v.(type) {
+		case bool:
+			return value, nil
+		case string:
+			if b, err := strconv.ParseBool(strings.TrimSpace(value)); err == nil {
+				return b, nil
+			}
+		case int64:
+			return value != 0, nil
+		}
+		return nil, NewError("CastFailed", fmt.Sprintf("Cannot cast '%v' to BOOL", formatSelectValue(v)), http.StatusBadRequest)
+	default:
+		return formatSelectValue(v), nil
+	}
+}
+
+type selectFunction struct {
+	name string
+	args []selectExpr
+}
+
+// the minimum and maximum number of arguments of functions, the maximum is -1 if unlimited
+var selectFunctionArity = map[string][2]int{
+	"LOWER":            {1, 1},
+	"UPPER":            {1, 1},
+	"TRIM":             {1, 1},
+	"CHAR_LENGTH":      {1, 1},
+	"CHARACTER_LENGTH": {1, 1},
+	"SUBSTRING":        {2, 3},
+	"COALESCE":         {1, -1},
+	"NULLIF":           {2, 2},
+	"ABS":              {1, 1},
+}
+
+func (e *selectFunction) validate() error {
+	arity, ok := selectFunctionArity[e.name]
+	if !ok {
+		return NewError("UnsupportedFunction", fmt.Sprintf("Function %v is not supported", e.name), http.StatusBadRequest)
+	}
+	if len(e.args) < arity[0] || (arity[1] >= 0 && len(e.args) > arity[1]) {
+		return NewError("IncorrectSqlFunctionArgumentType",
+			fmt.Sprintf("Incorrect number of arguments for function %v", e.name), http.StatusBadRequest)
+	}
+	return nil
+}
+
+func (e *selectFunction) eval(rec selectRecord) (interface{}, error) {
+	args := make([]interface{}, len(e.args))
+	for i, arg := range e.args {
+		v, err := arg.eval(rec)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	switch e.name {
+	case "COALESCE":
+		for _, v := range args {
+			if v != nil {
+				return v, nil
+			}
+		}
+		return nil, nil
+	case "NULLIF":
+		if args[0] == nil || args[1] == nil {
+			return args[0], nil
+		}
+		if c, err := compareSelectValues(args[0], args[1]); err == nil && c == 0 {
+			return nil, nil
+		}
+		return args[0], nil
+	}
+	for _, v := range args {
+		if v == nil {
+			return nil, nil
+		}
+	}
+	switch e.name {
+	case "LOWER":
+		return strings.ToLower(formatSelectValue(args[0])), nil
+	case "UPPER":
+		return strings.ToUpper(formatSelectValue(args[0])), nil
+	case "TRIM":
+		return strings.TrimSpace(formatSelectValue(args[0])), nil
+	case "CHAR_LENGTH", "CHARACTER_LENGTH":
+		return int64(len([]rune(formatSelectValue(args[0])))), nil
+	case "ABS":
+		n, ok := toSelectNumber(args[0])
+		if !ok {
+			return nil, selectEvalError("ABS requires a numeric argument")
+		}
+		if i, isInt := n.(int64); isInt {
+			if i < 0 {
+				return -i, nil
+			}
+			return i, nil
+		}
+		return math.Abs(n.(float64)), nil
+	default:
+		// SUBSTRING is indexed from 1, and the start before 1 shortens the length
+		runes := []rune(formatSelectValue(args[0]))
+		start, ok := toSelectNumber(args[1])
+		if !ok {
+			return nil, selectEvalError("SUBSTRING requires a numeric start")
+		}
+		from := int64(toSelectFloat(start))
+		to := int64(len(runes)) + 1
+		if len(args) == 3 {
+			length, ok := toSelectNumber(args[2])
+			if !ok || toSelectFloat(length) < 0 {
+				return nil, selectEvalError("SUBSTRING requires a non-negative length")
+			}
+			to = from + int64(toSelectFloat(length))
+		}
+		if from < 1 {
+			from = 1
+		}
+		if to > int64(len(runes))+1 {
+			to = int64(len(runes)) + 1
+		}
+		if from >= to {
+			return "", nil
+		}
+		return string(runes[from-1 : to-1]), nil
+	}
+}
+
+const (
+	aggCount = iota
+	aggSum
+	aggAvg
+	aggMin
+	aggMax
+)
+
+var selectAggregateFuncs = map[string]int{
+	"COUNT": aggCount,
+	"SUM":   aggSum,
+	"AVG":   aggAvg,
+	"MIN":   aggMin,
+	"MAX":   aggMax,
+}
+
+// selectAggregate is an aggregate function, the arg is nil for COUNT(*).
+type selectAggregate struct {
+	fn    int
+	arg   selectExpr
+	count int64
+	sum   interface{}
+	value interface{}
+}
+
+func (a *selectAggregate) accumulate(rec selectRecord) error {
+	if a.arg == nil {
+		a.count++
+		return nil
+	}
+	v, err := a.arg.eval(rec)
+	if err != nil || v == nil {
+		return err
+	}
+	a.count++
+	switch a.fn {
+	case aggSum, aggAvg:
+		n, ok := toSelectNumber(v)
+		if !ok {
+			return selectEvalError("SUM and AVG require numeric values but found %v", formatSelectValue(v))
+		}
+		if a.sum == nil {
+			a.sum = n
+			return nil
+		}
+		si, sInt := a.sum.(int64)
+		ni, nInt := n.(int64)
+		if sInt && nInt {
+			a.sum = si + ni
+		} else {
+			a.sum = toSelectFloat(a.sum) + toSelectFloat(n)
+		}
+	case aggMin, aggMax:
+		if n, ok := toSelectNumber(v); ok {
+			v = n
+		}
+		if a.value == nil {
+			a.value = v
+			return nil
+		}
+		c, err := compareSelectValues(v, a.value)
+		if err != nil {
+			return err
+		}
+		if (a.fn == aggMin && c < 0) || (a.fn == aggMax && c > 0) {
+			a.value = v
+		}
+	}
+	return nil
+}
+
+// eval returns the result of aggregation.
+func (a *selectAggregate) eval(rec selectRecord) (interface{}, error) {
+	switch a.fn {
+	case aggCount:
+		return a.count, nil
+	case aggSum:
+		return a.sum, nil
+	case aggAvg:
+		if a.count == 0 {
+			return nil, nil
+		}
+		return toSelectFloat(a.sum) / float64(a.count), nil
+	default:
+		return a.value, nil
+	}
+}
+
+// toSelectNumber converts the value to int64 or float64, strings are parsed as numbers
+// because the fields of CSV are always strings.
+func toSelectNumber(v interface{}) (interface{}, bool) {
+	switch value := v.(type) {
+	case int64, float64:
+		return value, true
+	case string:
+		s := strings.TrimSpace(value)
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i, true
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+func toSelectFloat(n interface{}) float64 {
+	switch value := n.(type) {
+	case int64:
+		return float64(value)
+	case float64:
+		return value
+	}
+	return 0
+}
+
+// compareSelectValues compares two non-nil values, strings are compared as numbers if compared
+// with numbers.
+func compareSelectValues(l, r interface{}) (int, error) {
+	_, lStr := l.(string)
+	_, rStr := r.(string)
+	if !lStr || !rStr {
+		if ln, ok := toSelectNumber(l); ok {
+			if rn, ok := toSelectNumber(r); ok {
+				li, lInt := ln.(int64)
+				ri, rInt := rn.(int64)
+				if lInt && rInt {
+					return compareInt64(li, ri), nil
+				}
+				lf, rf := toSelectFloat(ln), toSelectFloat(rn)
+				switch {
+				case lf < rf:
+					return -1, nil
+				case lf > rf:
+					return 1, nil
+				}
+				return 0, nil
+			}
+		}
+	}
+	lb, lBool := l.(bool)
+	rb, rBool := r.(bool)
+	if lBool || rBool {
+		if !lBool || !rBool {
+			if lBool {
+				rb, rBool = parseSelectBool(r)
+			} else {
+				lb, lBool = parseSelectBool(l)
+			}
+			if !lBool || !rBool {
+				return 0, selectEvalError("Cannot compare %v with %v", formatSelectValue(l), formatSelectValue(r))
+			}
+		}
+		switch {
+		case lb == rb:
+			return 0, nil
+		case !lb:
+			return -1, nil
+		}
+		return 1, nil
+	}
+	return strings.Compare(formatSelectValue(l), formatSelectValue(r)), nil
+}
+
+func compareInt64(l, r int64) int {
+	switch {
+	case l < r:
+		return -1
+	case l > r:
+		return 1
+	}
+	return 0
+}
+
+func parseSelectBool(v interface{}) (bool, bool) {
+	if s, ok := v.(string); ok {
+		if b, err := strconv.ParseBool(strings.TrimSpace(s)); err == nil {
+			return b, true
+		}
+	}
+	return false, false
+}
+
+// formatSelectValue formats the value as string, NULL is formatted as empty string.
+func formatSelectValue(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case bool:
+		return strconv.FormatBool(value)
+	case int64:
+		return strconv.FormatInt(value, 10)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		data, _ := marshalSelectJSON(value)
+		return string(data)
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// Select Object Content
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_SelectObjectContent.html
+func (o *ObjectNode) selectObjectContentHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	param := ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	if param.Object() == "" {
+		errorCode = InvalidKey
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("selectObjectContentHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+
+	var body []byte
+	if body, err = io.ReadAll(io.LimitReader(r.Body, MaxSelectRequestSize+1)); err != nil {
+		log.LogErrorf("selectObjectContentHandler: read request body fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	if len(body) > MaxSelectRequestSize {
+		errorCode = EntityTooLarge
+		return
+	}
+	var req *SelectObjectContentRequest
+	if req, err = ParseSelectRequest(body); err != nil {
+		log.LogErrorf("selectObjectContentHandler: parse select request fail: requestID(%v) volume(%v) body(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(body), err)
+		return
+	}
+	var query *selectQuery
+	if query, err = parseSelectQuery(req.Expression); err != nil {
+		log.LogErrorf("selectObjectContentHandler: parse expression fail: requestID(%v) volume(%v) expression(%v) err(%v)",
+			GetRequestID(r), vol.Name(), req.Expression, err)
+		return
+	}
+
+	var fileInfo *FSFileInfo
+	if fileInfo, _, err = vol.ObjectVersionMeta(param.Object(), ""); err != nil {
+		log.LogErrorf("selectObjectContentHandler: get file meta fail: requestID(%v) volume(%v) path(%v) err(%v)",
+			GetRequestID(r), vol.Name(), param.Object(), err)
+		if err == syscall.ENOENT {
+			errorCode = NoSuchKey
+		}
+		return
+	}
+	if fileInfo.DeleteMarker || fileInfo.Mode.IsDir() {
+		errorCode = NoSuchKey
+		return
+	}
+	// encrypted object can only be read with the key it is encrypted by
+	if err = o.unsealObjectEncryption(r, fileInfo.Encryption, false); err != nil {
+		log.LogErrorf("selectObjectContentHandler: unseal encryption fail: requestID(%v) volume(%v) path(%v) err(%v)",
+			GetRequestID(r), vol.Name(), param.Object(), err)
+		return
+	}
+
+	// the object is read from the byte before scan range to find the first record in range
+	var src io.Reader = bytes.NewReader(nil)
+	if start, end, ok := req.scanRange(fileInfo.Size); ok {
+		offset := start
+		if offset > 0 {
+			offset--
+		}
+		reader, pipeWriter := io.Pipe()
+		defer reader.Close()
+		var writer io.Writer = pipeWriter
+		if fileInfo.Encryption != nil {
+			writer = fileInfo.Encryption.DecryptWriter(pipeWriter, uint64(offset))
+		}
+		readIno, readStorageClass := fileInfo.readSource(time.Now())
+		go func() {
+			rerr := vol.readFile(readIno, uint64(fileInfo.Size), param.Object(), writer, uint64(offset),
+				uint64(fileInfo.Size-offset), readStorageClass)
+			if rerr != nil && rerr != io.ErrClosedPipe {
+				log.LogErrorf("selectObjectContentHandler: read file fail: requestID(%v) volume(%v) path(%v) err(%v)",
+					GetRequestID(r), vol.Name(), param.Object(), rerr)
+			}
+			pipeWriter.CloseWithError(rerr)
+		}()
+		src = reader
+		if req.ScanRange != nil {
+			src = newScanRangeReader(reader, start, end)
+		}
+	}
+
+	start := time.Now()
+	var started bool
+	if started, err = executeSelect(w, src, req, query); err != nil {
+		log.LogErrorf("selectObjectContentHandler: select fail: requestID(%v) volume(%v) path(%v) expression(%v) err(%v)",
+			GetRequestID(r), vol.Name(), param.Object(), req.Expression, err)
+		// the error is sent in event stream once the response is started
+		if started {
+			err = nil
+		}
+		return
+	}
+	log.LogDebugf("selectObjectContentHandler: select done: requestID(%v) volume(%v) path(%v) expression(%v) cost(%v)",
+		GetRequestID(r), vol.Name(), param.Object(), req.Expression, time.Since(start))
+}
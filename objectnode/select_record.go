@@ -0,0 +1,457 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type selectField struct {
+	name  string
+	value interface{}
+}
+
+// selectRecord is a record of the input of select request.
+type selectRecord interface {
+	// column returns the top level value of the column, the name is matched case-insensitively
+	// unless it is quoted.
+	column(name string, quoted bool) (interface{}, bool)
+	// fields returns all the fields of record in order, which are the output of SELECT *.
+	fields() []selectField
+}
+
+type selectRecordReader interface {
+	Read() (selectRecord, error)
+}
+
+type selectRecordWriter interface {
+	Write(buf *bytes.Buffer, fields []selectField) error
+}
+
+// positionalColumn parses the positional column name like _1, _2, and returns the index from 0.
+func positionalColumn(name string) (int, bool) {
+	if !strings.HasPrefix(name, "_") {
+		return 0, false
+	}
+	n, err := strconv.Atoi(name[1:])
+	if err != nil || n < 1 {
+		return 0, false
+	}
+	return n - 1, true
+}
+
+func matchColumnName(name, target string, quoted bool) bool {
+	if quoted {
+		return name == target
+	}
+	return strings.EqualFold(name, target)
+}
+
+type csvRecord struct {
+	header []string
+	values []string
+}
+
+func (r *csvRecord) column(name string, quoted bool) (interface{}, bool) {
+	for i, h := range r.header {
+		if matchColumnName(name, h, quoted) && i < len(r.values) {
+			return r.values[i], true
+		}
+	}
+	if i, ok := positionalColumn(name); ok && i < len(r.values) {
+		return r.values[i], true
+	}
+	return nil, false
+}
+
+func (r *csvRecord) fields() []selectField {
+	fields := make([]selectField, len(r.values))
+	for i, v := range r.values {
+		name := "_" + strconv.Itoa(i+1)
+		if i < len(r.header) {
+			name = r.header[i]
+		}
+		fields[i] = selectField{name: name, value: v}
+	}
+	return fields
+}
+
+// byteReplaceReader replaces the custom record delimiter with '\n' for the CSV parser.
+type byteReplaceReader struct {
+	r        io.Reader
+	old, new byte
+}
+
+func (r *byteReplaceReader) Read(p []byte) (n int, err error) {
+	n, err = r.r.Read(p)
+	for i := 0; i < n; i++ {
+		if p[i] == r.old {
+			p[i] = r.new
+		}
+	}
+	return
+}
+
+type csvRecordReader struct {
+	reader *csv.Reader
+	header []string
+}
+
+func newCSVRecordReader(r io.Reader, input *SelectCSVInput) (reader *csvRecordReader, err error) {
+	if delim := input.recordDelimiter(); delim != "\n" && delim != "\r\n" {
+		r = &byteReplaceReader{r: r, old: delim[0], new: '\n'}
+	}
+	cr := csv.NewReader(r)
+	cr.Comma = []rune(input.fieldDelimiter())[0]
+	if comments := input.comments(); comments != "" {
+		cr.Comment = []rune(comments)[0]
+	}
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	reader = &csvRecordReader{reader: cr}
+
+	switch strings.ToUpper(input.FileHeaderInfo) {
+	case SelectFileHeaderUse, SelectFileHeaderIgnore:
+		var header []string
+		if header, err = cr.Read(); err != nil && err != io.EOF {
+			return nil, csvParsingError(err)
+		}
+		if strings.ToUpper(input.FileHeaderInfo) == SelectFileHeaderUse {
+			reader.header = header
+		}
+	}
+	return reader, nil
+}
+
+func csvParsingError(err error) *ErrorCode {
+	return NewError("CSVParsingError", fmt.Sprintf("Encountered an error parsing the CSV file: %v", err), http.StatusBadRequest)
+}
+
+func (r *csvRecordReader) Read() (selectRecord, error) {
+	values, err := r.reader.Read()
+	if err == io.EOF {
+		return nil, err
+	}
+	if err != nil {
+		return nil, csvParsingError(err)
+	}
+	return &csvRecord{header: r.header, values: values}, nil
+}
+
+// selectObject is a JSON object which keeps the order of keys.
+type selectObject struct {
+	keys   []string
+	values []interface{}
+}
+
+func (o *selectObject) get(name string, quoted bool) (interface{}, bool) {
+	for i, k := range o.keys {
+		if k == name {
+			return o.values[i], true
+		}
+	}
+	if !quoted {
+		for i, k := range o.keys {
+			if strings.EqualFold(k, name) {
+				return o.values[i], true
+			}
+		}
+	}
+	return nil, false
+}
+
+type jsonRecord struct {
+	value interface{}
+}
+
+func (r *jsonRecord) column(name string, quoted bool) (interface{}, bool) {
+	if obj, ok := r.value.(*selectObject); ok {
+		return obj.get(name, quoted)
+	}
+	if i, ok := positionalColumn(name); ok && i == 0 {
+		return r.value, true
+	}
+	return nil, false
+}
+
+func (r *jsonRecord) fields() []selectField {
+	obj, ok := r.value.(*selectObject)
+	if !ok {
+		return []selectField{{name: "_1", value: r.value}}
+	}
+	fields := make([]selectField, len(obj.keys))
+	for i, k := range obj.keys {
+		fields[i] = selectField{name: k, value: obj.values[i]}
+	}
+	return fields
+}
+
+// jsonRecordReader reads the top level values of JSON input as records, so that both of
+// JSON document and JSON lines are supported.
+type jsonRecordReader struct {
+	decoder *json.Decoder
+}
+
+func newJSONRecordReader(r io.Reader) *jsonRecordReader {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	return &jsonRecordReader{decoder: decoder}
+}
+
+func jsonParsingError(err error) *ErrorCode {
+	return NewError("JSONParsingError", fmt.Sprintf("Encountered an error parsing the JSON file: %v", err), http.StatusBadRequest)
+}
+
+func (r *jsonRecordReader) Read() (selectRecord, error) {
+	value, err := decodeSelectJSON(r.decoder)
+	if err == io.EOF {
+		return nil, err
+	}
+	if err != nil {
+		return nil, jsonParsingError(err)
+	}
+	return &jsonRecord{value: value}, nil
+}
+
+// decodeSelectJSON decodes the next JSON value with the order of object keys kept.
+func decodeSelectJSON(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := token.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			obj := &selectObject{}
+			for decoder.More() {
+				var key json.Token
+				if key, err = decoder.Token(); err != nil {
+					return nil, unexpectedEOF(err)
+				}
+				var value interface{}
+				if value, err = decodeSelectJSON(decoder); err != nil {
+					return nil, unexpectedEOF(err)
+				}
+				obj.keys = append(obj.keys, key.(string))
+				obj.values = append(obj.values, value)
+			}
+			if _, err = decoder.Token(); err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			return obj, nil
+		case '[':
+			array := make([]interface{}, 0)
+			for decoder.More() {
+				var value interface{}
+				if value, err = decodeSelectJSON(decoder); err != nil {
+					return nil, unexpectedEOF(err)
+				}
+				array = append(array, value)
+			}
+			if _, err = decoder.Token(); err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			return array, nil
+		}
+		return nil, fmt.Errorf("unexpected delimiter %v", t)
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i, nil
+		}
+		return t.Float64()
+	default:
+		// string, bool and nil
+		return t, nil
+	}
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// marshalSelectJSON marshals the value with the order of object keys kept.
+func marshalSelectJSON(v interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := writeSelectJSON(buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeSelectJSON(buf *bytes.Buffer, v interface{}) error {
+	switch value := v.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(value))
+	case int64:
+		buf.WriteString(strconv.FormatInt(value, 10))
+	case float64:
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			buf.WriteString("null")
+		} else {
+			buf.WriteString(strconv.FormatFloat(value, 'f', -1, 64))
+		}
+	case string:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+	case *selectObject:
+		buf.WriteByte('{')
+		for i, k := range value.keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeSelectJSON(buf, k); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if err := writeSelectJSON(buf, value.values[i]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, item := range value {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeSelectJSON(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	default:
+		return fmt.Errorf("unsupported value type %T", v)
+	}
+	return nil
+}
+
+type csvRecordWriter struct {
+	fieldDelimiter  string
+	recordDelimiter string
+	quote           string
+	quoteEscape     string
+	quoteAlways     bool
+}
+
+func newCSVRecordWriter(output *SelectCSVOutput) *csvRecordWriter {
+	return &csvRecordWriter{
+		fieldDelimiter:  output.fieldDelimiter(),
+		recordDelimiter: output.recordDelimiter(),
+		quote:           output.quoteCharacter(),
+		quoteEscape:     output.quoteEscapeCharacter(),
+		quoteAlways:     strings.ToUpper(output.QuoteFields) == SelectQuoteFieldsAlways,
+	}
+}
+
+func (w *csvRecordWriter) Write(buf *bytes.Buffer, fields []selectField) error {
+	for i, f := range fields {
+		if i > 0 {
+			buf.WriteString(w.fieldDelimiter)
+		}
+		s := formatSelectValue(f.value)
+		if w.quoteAlways || strings.Contains(s, w.fieldDelimiter) || strings.Contains(s, w.quote) ||
+			strings.ContainsAny(s, "\r\n") || strings.Contains(s, w.recordDelimiter) {
+			s = w.quote + strings.ReplaceAll(s, w.quote, w.quoteEscape+w.quote) + w.quote
+		}
+		buf.WriteString(s)
+	}
+	buf.WriteString(w.recordDelimiter)
+	return nil
+}
+
+type jsonRecordWriter struct {
+	recordDelimiter string
+}
+
+func (w *jsonRecordWriter) Write(buf *bytes.Buffer, fields []selectField) error {
+	obj := &selectObject{keys: make([]string, len(fields)), values: make([]interface{}, len(fields))}
+	for i, f := range fields {
+		obj.keys[i], obj.values[i] = f.name, f.value
+	}
+	if err := writeSelectJSON(buf, obj); err != nil {
+		return err
+	}
+	buf.WriteString(w.recordDelimiter)
+	return nil
+}
+
+// scanRangeReader reads the records which start in the scan range [start, end] of object. It reads
+// the object from the byte before the range if the range does not start from 0, so that the first
+// partial record is skipped, and the record starts in the range is read to its end.
+type scanRangeReader struct {
+	r           *bufio.Reader
+	offset      int64
+	end         int64
+	skipping    bool
+	recordStart bool
+}
+
+// newScanRangeReader returns the reader of records in range, the r reads the object from offset
+// max(start-1, 0).
+func newScanRangeReader(r io.Reader, start, end int64) *scanRangeReader {
+	s := &scanRangeReader{r: bufio.NewReader(r), end: end, skipping: start > 0, recordStart: start == 0}
+	if start > 0 {
+		s.offset = start - 1
+	}
+	return s
+}
+
+func (s *scanRangeReader) Read(p []byte) (n int, err error) {
+	for n < len(p) {
+		if s.recordStart && s.offset > s.end {
+			break
+		}
+		var c byte
+		if c, err = s.r.ReadByte(); err != nil {
+			break
+		}
+		s.offset++
+		if s.skipping {
+			if c == '\n' {
+				s.skipping = false
+				s.recordStart = true
+			}
+			continue
+		}
+		p[n] = c
+		n++
+		s.recordStart = c == '\n'
+	}
+	if n > 0 {
+		return n, nil
+	}
+	if err == nil {
+		err = io.EOF
+	}
+	return 0, err
+}
@@ -0,0 +1,757 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The SQL subset of S3 Select:
+//
+//	SELECT * | expr [[AS] alias], ... FROM S3Object[[*]] [[AS] alias] [WHERE expr] [LIMIT n]
+//
+// Expressions support literals, column references by name or position (_1, _2, ...), nested paths of
+// JSON documents (s.a.b[0]), arithmetic, comparison, logical operators, LIKE, IN, BETWEEN, IS [NOT] NULL,
+// CAST, some string functions and the aggregate functions COUNT, SUM, AVG, MIN and MAX.
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/s3-select-sql-reference.html
+
+const (
+	MaxSelectExpressionSize = 256 << 10 // 256KB
+
+	selectTableName = "s3object"
+)
+
+func selectParseError(format string, args ...interface{}) *ErrorCode {
+	return NewError("ParseUnexpectedToken", fmt.Sprintf(format, args...), http.StatusBadRequest)
+}
+
+func selectUnsupportedError(format string, args ...interface{}) *ErrorCode {
+	return NewError("ParseUnsupportedSyntax", fmt.Sprintf(format, args...), http.StatusBadRequest)
+}
+
+type selectTokenKind int
+
+const (
+	tokenEOF selectTokenKind = iota
+	tokenIdent
+	tokenQuotedIdent
+	tokenString
+	tokenNumber
+	tokenOperator
+)
+
+type selectToken struct {
+	kind  selectTokenKind
+	text  string
+	upper string
+	pos   int
+}
+
+func (t selectToken) is(keyword string) bool {
+	return (t.kind == tokenIdent || t.kind == tokenOperator) && t.upper == keyword
+}
+
+func (t selectToken) String() string {
+	if t.kind == tokenEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("'%s' at position %d", t.text, t.pos)
+}
+
+var selectOperators = []string{"<>", "!=", "<=", ">=", "||", "=", "<", ">", "+", "-", "*", "/", "%", "(", ")", ",", ".", "[", "]", ";"}
+
+func tokenizeSelect(sql string) (tokens []selectToken, err error) {
+	runes := []rune(sql)
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '\'' || c == '"':
+			// '' and "" are the escaped quotes in strings and quoted identifiers
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(runes); j++ {
+				if runes[j] == c {
+					if j+1 < len(runes) && runes[j+1] == c {
+						sb.WriteRune(c)
+						j++
+						continue
+					}
+					break
+				}
+				sb.WriteRune(runes[j])
+			}
+			if j >= len(runes) {
+				return nil, selectParseError("Unterminated quote at position %d", i)
+			}
+			kind := tokenString
+			if c == '"' {
+				kind = tokenQuotedIdent
+			}
+			tokens = append(tokens, selectToken{kind: kind, text: sb.String(), pos: i})
+			i = j + 1
+		case unicode.IsDigit(c) || (c == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			j := i
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.') {
+				j++
+			}
+			if j < len(runes) && (runes[j] == 'e' || runes[j] == 'E') {
+				j++
+				if j < len(runes) && (runes[j] == '+' || runes[j] == '-') {
+					j++
+				}
+				for j < len(runes) && unicode.IsDigit(runes[j]) {
+					j++
+				}
+			}
+			tokens = append(tokens, selectToken{kind: tokenNumber, text: string(runes[i:j]), pos: i})
+			i = j
+		case unicode.IsLetter(c) || c == '_':
+			j := i
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_') {
+				j++
+			}
+			text := string(runes[i:j])
+			tokens = append(tokens, selectToken{kind: tokenIdent, text: text, upper: strings.ToUpper(text), pos: i})
+			i = j
+		default:
+			matched := false
+			for _, op := range selectOperators {
+				if strings.HasPrefix(string(runes[i:]), op) {
+					tokens = append(tokens, selectToken{kind: tokenOperator, text: op, upper: op, pos: i})
+					i += len([]rune(op))
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, selectParseError("Unexpected character '%c' at position %d", c, i)
+			}
+		}
+	}
+	tokens = append(tokens, selectToken{kind: tokenEOF, pos: len(runes)})
+	return
+}
+
+// selectQuery is the parsed SQL expression of select request.
+type selectQuery struct {
+	star  bool
+	items []*selectItem
+	where selectExpr
+	limit int64
+	alias string
+	aggs  []*selectAggregate
+}
+
+type selectItem struct {
+	expr  selectExpr
+	alias string
+}
+
+type selectParser struct {
+	tokens []selectToken
+	pos    int
+	alias  string
+	// state of projection used to check the usage of aggregate functions
+	inProjection bool
+	inAggregate  bool
+	plainColumn  bool
+	aggs         []*selectAggregate
+}
+
+var selectReservedWords = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "LIMIT": true, "AS": true, "AND": true, "OR": true,
+	"NOT": true, "LIKE": true, "ESCAPE": true, "IN": true, "BETWEEN": true, "IS": true, "NULL": true,
+	"MISSING": true, "TRUE": true, "FALSE": true, "CAST": true,
+}
+
+// parseSelectQuery parses the SQL expression of select request.
+func parseSelectQuery(sql string) (query *selectQuery, err error) {
+	var tokens []selectToken
+	if tokens, err = tokenizeSelect(sql); err != nil {
+		return
+	}
+	p := &selectParser{tokens: tokens}
+	if query, err = p.parseQuery(); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (p *selectParser) peek() selectToken {
+	return p.tokens[p.pos]
+}
+
+func (p *selectParser) next() selectToken {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *selectParser) accept(keyword string) bool {
+	if p.peek().is(keyword) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *selectParser) expect(keyword string) error {
+	if t := p.next(); !t.is(keyword) {
+		return selectParseError("Expected '%s' but found %v", keyword, t)
+	}
+	return nil
+}
+
+// acceptAlias accepts an optional alias with or without AS.
+func (p *selectParser) acceptAlias() (alias string, err error) {
+	hasAs := p.accept("AS")
+	t := p.peek()
+	if t.kind == tokenQuotedIdent || (t.kind == tokenIdent && !selectReservedWords[t.upper]) {
+		p.pos++
+		return t.text, nil
+	}
+	if hasAs {
+		return "", selectParseError("Expected alias but found %v", t)
+	}
+	return "", nil
+}
+
+func (p *selectParser) parseQuery() (q *selectQuery, err error) {
+	if err = p.expect("SELECT"); err != nil {
+		return
+	}
+	q = &selectQuery{limit: -1}
+	// the alias of table is needed to resolve column references, so the projection is parsed after FROM
+	projStart := p.pos
+	depth := 0
+	for t := p.peek(); t.kind != tokenEOF && !(depth == 0 && t.is("FROM")); t = p.peek() {
+		if t.is("(") {
+			depth++
+		} else if t.is(")") {
+			depth--
+		}
+		p.pos++
+	}
+	if err = p.expect("FROM"); err != nil {
+		return nil, selectUnsupportedError("A FROM clause must be provided")
+	}
+	if err = p.parseFrom(q); err != nil {
+		return
+	}
+	fromEnd := p.pos
+
+	p.pos = projStart
+	if p.accept("*") {
+		q.star = true
+	} else {
+		p.inProjection = true
+		for {
+			item := &selectItem{}
+			if item.expr, err = p.parseExpr(); err != nil {
+				return
+			}
+			if item.alias, err = p.acceptAlias(); err != nil {
+				return
+			}
+			q.items = append(q.items, item)
+			if !p.accept(",") {
+				break
+			}
+		}
+		p.inProjection = false
+	}
+	if t := p.peek(); !t.is("FROM") {
+		return nil, selectParseError("Unexpected token %v", t)
+	}
+	if len(p.aggs) > 0 && p.plainColumn {
+		return nil, selectUnsupportedError("Aggregate functions cannot be mixed with other columns in projection")
+	}
+	q.aggs = p.aggs
+
+	p.pos = fromEnd
+	if p.accept("WHERE") {
+		if q.where, err = p.parseExpr(); err != nil {
+			return
+		}
+		if len(p.aggs) > len(q.aggs) {
+			return nil, selectUnsupportedError("Aggregate functions are not allowed in WHERE clause")
+		}
+	}
+	if p.accept("LIMIT") {
+		t := p.next()
+		var limit int64
+		if t.kind != tokenNumber {
+			return nil, selectParseError("Expected number after LIMIT but found %v", t)
+		}
+		if limit, err = strconv.ParseInt(t.text, 10, 64); err != nil || limit < 0 {
+			return nil, selectParseError("Invalid LIMIT %v", t.text)
+		}
+		q.limit = limit
+	}
+	p.accept(";")
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, selectParseError("Unexpected token %v", t)
+	}
+	return q, nil
+}
+
+func (p *selectParser) parseFrom(q *selectQuery) (err error) {
+	t := p.next()
+	if t.kind != tokenIdent || t.upper != strings.ToUpper(selectTableName) {
+		return NewError("InvalidDataSource", "Only S3Object is supported as data source", http.StatusBadRequest)
+	}
+	if p.accept("[") {
+		if err = p.expect("*"); err != nil {
+			return
+		}
+		if err = p.expect("]"); err != nil {
+			return
+		}
+	}
+	if p.peek().is(".") || p.peek().is("[") {
+		return selectUnsupportedError("Paths in FROM clause are not supported")
+	}
+	q.alias, err = p.acceptAlias()
+	p.alias = q.alias
+	return
+}
+
+func (p *selectParser) parseExpr() (selectExpr, error) {
+	return p.parseOr()
+}
+
+func (p *selectParser) parseOr() (e selectExpr, err error) {
+	if e, err = p.parseAnd(); err != nil {
+		return
+	}
+	for p.accept("OR") {
+		var right selectExpr
+		if right, err = p.parseAnd(); err != nil {
+			return
+		}
+		e = &selectLogical{op: "OR", left: e, right: right}
+	}
+	return
+}
+
+func (p *selectParser) parseAnd() (e selectExpr, err error) {
+	if e, err = p.parseNot(); err != nil {
+		return
+	}
+	for p.accept("AND") {
+		var right selectExpr
+		if right, err = p.parseNot(); err != nil {
+			return
+		}
+		e = &selectLogical{op: "AND", left: e, right: right}
+	}
+	return
+}
+
+func (p *selectParser) parseNot() (e selectExpr, err error) {
+	if p.accept("NOT") {
+		if e, err = p.parseNot(); err != nil {
+			return
+		}
+		return &selectNot{expr: e}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *selectParser) parseComparison() (e selectExpr, err error) {
+	if e, err = p.parseAdditive(); err != nil {
+		return
+	}
+	t := p.peek()
+	switch {
+	case t.is("=") || t.is("!=") || t.is("<>") || t.is("<") || t.is("<=") || t.is(">") || t.is(">="):
+		p.pos++
+		var right selectExpr
+		if right, err = p.parseAdditive(); err != nil {
+			return
+		}
+		return &selectCompare{op: t.text, left: e, right: right}, nil
+	case t.is("IS"):
+		p.pos++
+		negate := p.accept("NOT")
+		if !p.accept("NULL") && !p.accept("MISSING") {
+			return nil, selectParseError("Expected NULL or MISSING but found %v", p.peek())
+		}
+		return &selectIsNull{expr: e, negate: negate}, nil
+	}
+
+	negate := false
+	if t.is("NOT") {
+		if n := p.tokens[p.pos+1]; n.is("LIKE") || n.is("IN") || n.is("BETWEEN") {
+			p.pos++
+			negate = true
+		}
+	}
+	switch {
+	case p.accept("LIKE"):
+		like := &selectLike{expr: e, negate: negate}
+		if like.pattern, err = p.parseAdditive(); err != nil {
+			return
+		}
+		if p.accept("ESCAPE") {
+			if like.escape, err = p.parseAdditive(); err != nil {
+				return
+			}
+		}
+		return like, nil
+	case p.accept("IN"):
+		in := &selectIn{expr: e, negate: negate}
+		if err = p.expect("("); err != nil {
+			return
+		}
+		for {
+			var item selectExpr
+			if item, err = p.parseExpr(); err != nil {
+				return
+			}
+			in.list = append(in.list, item)
+			if !p.accept(",") {
+				break
+			}
+		}
+		if err = p.expect(")"); err != nil {
+			return
+		}
+		return in, nil
+	case p.accept("BETWEEN"):
+		between := &selectBetween{expr: e, negate: negate}
+		if between.lower, err = p.parseAdditive(); err != nil {
+			return
+		}
+		if err = p.expect("AND"); err != nil {
+			return
+		}
+		if between.upper, err = p.parseAdditive(); err != nil {
+			return
+		}
+		return between, nil
+	}
+	return e, nil
+}
+
+func (p *selectParser) parseAdditive() (e selectExpr, err error) {
+	if e, err = p.parseMultiplicative(); err != nil {
+		return
+	}
+	for t := p.peek(); t.is("+") || t.is("-") || t.is("||"); t = p.peek() {
+		p.pos++
+		var right selectExpr
+		if right, err = p.parseMultiplicative(); err != nil {
+			return
+		}
+		e = &selectArithmetic{op: t.text, left: e, right: right}
+	}
+	return
+}
+
+func (p *selectParser) parseMultiplicative() (e selectExpr, err error) {
+	if e, err = p.parseUnary(); err != nil {
+		return
+	}
+	for t := p.peek(); t.is("*") || t.is("/") || t.is("%"); t = p.peek() {
+		p.pos++
+		var right selectExpr
+		if right, err = p.parseUnary(); err != nil {
+			return
+		}
+		e = &selectArithmetic{op: t.text, left: e, right: right}
+	}
+	return
+}
+
+func (p *selectParser) parseUnary() (e selectExpr, err error) {
+	if p.accept("-") {
+		if e, err = p.parseUnary(); err != nil {
+			return
+		}
+		return &selectArithmetic{op: "-", left: &selectLiteral{value: int64(0)}, right: e}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *selectParser) parsePrimary() (e selectExpr, err error) {
+	t := p.next()
+	switch t.kind {
+	case tokenString:
+		return &selectLiteral{value: t.text}, nil
+	case tokenNumber:
+		if i, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+			return &selectLiteral{value: i}, nil
+		}
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, selectParseError("Invalid number %v", t)
+		}
+		return &selectLiteral{value: f}, nil
+	case tokenQuotedIdent:
+		return p.parseColumn(t)
+	case tokenOperator:
+		if t.is("(") {
+			if e, err = p.parseExpr(); err != nil {
+				return
+			}
+			if err = p.expect(")"); err != nil {
+				return
+			}
+			return e, nil
+		}
+	case tokenIdent:
+		switch t.upper {
+		case "NULL", "MISSING":
+			return &selectLiteral{value: nil}, nil
+		case "TRUE":
+			return &selectLiteral{value: true}, nil
+		case "FALSE":
+			return &selectLiteral{value: false}, nil
+		case "CAST":
+			return p.parseCast()
+		}
+		if selectReservedWords[t.upper] {
+			break
+		}
+		if p.peek().is("(") {
+			p.pos++
+			return p.parseFunction(t)
+		}
+		return p.parseColumn(t)
+	}
+	return nil, selectParseError("Unexpected token %v", t)
+}
+
+func (p *selectParser) parseCast() (e selectExpr, err error) {
+	if err = p.expect("("); err != nil {
+		return
+	}
+	cast := &selectCast{}
+	if cast.expr, err = p.parseExpr(); err != nil {
+		return
+	}
+	if err = p.expect("AS"); err != nil {
+		return
+	}
+	t := p.next()
+	switch t.upper {
+	case "INT", "INTEGER", "BIGINT":
+		cast.typ = selectTypeInt
+	case "FLOAT", "DOUBLE", "DECIMAL", "NUMERIC", "REAL":
+		cast.typ = selectTypeFloat
+	case "STRING", "VARCHAR", "CHAR", "TEXT":
+		cast.typ = selectTypeString
+	case "BOOL", "BOOLEAN":
+		cast.typ = selectTypeBool
+	default:
+		return nil, selectUnsupportedError("Unsupported CAST type %v", t)
+	}
+	// DECIMAL(p, s) and VARCHAR(n) are accepted with the precision ignored
+	if p.accept("(") {
+		for t := p.next(); !t.is(")"); t = p.next() {
+			if t.kind == tokenEOF {
+				return nil, selectParseError("Expected ')' but found %v", t)
+			}
+		}
+	}
+	if err = p.expect(")"); err != nil {
+		return
+	}
+	return cast, nil
+}
+
+func (p *selectParser) parseFunction(name selectToken) (e selectExpr, err error) {
+	if agg, ok := selectAggregateFuncs[name.upper]; ok {
+		if p.inAggregate {
+			return nil, selectUnsupportedError("Nested aggregate function %v", name)
+		}
+		a := &selectAggregate{fn: agg}
+		if p.accept("*") {
+			if agg != aggCount {
+				return nil, selectParseError("Unexpected '*' in %v", name)
+			}
+		} else {
+			p.inAggregate = true
+			a.arg, err = p.parseExpr()
+			p.inAggregate = false
+			if err != nil {
+				return
+			}
+		}
+		if err = p.expect(")"); err != nil {
+			return
+		}
+		p.aggs = append(p.aggs, a)
+		return a, nil
+	}
+
+	fn := &selectFunction{name: name.upper}
+	switch name.upper {
+	case "SUBSTRING":
+		// SUBSTRING(s FROM start [FOR length]) is the same as SUBSTRING(s, start[, length])
+		var arg selectExpr
+		if arg, err = p.parseExpr(); err != nil {
+			return
+		}
+		fn.args = append(fn.args, arg)
+		if p.accept("FROM") {
+			if arg, err = p.parseExpr(); err != nil {
+				return
+			}
+			fn.args = append(fn.args, arg)
+			if p.accept("FOR") {
+				if arg, err = p.parseExpr(); err != nil {
+					return
+				}
+				fn.args = append(fn.args, arg)
+			}
+			if err = p.expect(")"); err != nil {
+				return
+			}
+			return fn, fn.validate()
+		}
+		if !p.accept(",") {
+			break
+		}
+		fallthrough
+	default:
+		if p.accept(")") {
+			return fn, fn.validate()
+		}
+		for {
+			var arg selectExpr
+			if arg, err = p.parseExpr(); err != nil {
+				return
+			}
+			fn.args = append(fn.args, arg)
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+	if err = p.expect(")"); err != nil {
+		return
+	}
+	return fn, fn.validate()
+}
+
+func (p *selectParser) parseColumn(first selectToken) (e selectExpr, err error) {
+	col := &selectColumn{}
+	col.path = append(col.path, selectPathElem{name: first.text, quoted: first.kind == tokenQuotedIdent})
+	for {
+		if p.accept(".") {
+			t := p.next()
+			if t.kind != tokenIdent && t.kind != tokenQuotedIdent {
+				return nil, selectParseError("Expected column name but found %v", t)
+			}
+			col.path = append(col.path, selectPathElem{name: t.text, quoted: t.kind == tokenQuotedIdent})
+			continue
+		}
+		if p.accept("[") {
+			t := p.next()
+			var index int
+			if t.kind != tokenNumber {
+				return nil, selectUnsupportedError("Only numeric array index is supported but found %v", t)
+			}
+			if index, err = strconv.Atoi(t.text); err != nil || index < 0 {
+				return nil, selectParseError("Invalid array index %v", t)
+			}
+			if err = p.expect("]"); err != nil {
+				return
+			}
+			col.path = append(col.path, selectPathElem{index: index, isIndex: true})
+			continue
+		}
+		break
+	}
+	// the alias of table is the first element of path
+	if len(col.path) > 1 && !col.path[0].isIndex && (strings.EqualFold(col.path[0].name, p.alias) ||
+		strings.EqualFold(col.path[0].name, selectTableName)) {
+		col.path = col.path[1:]
+	}
+	if col.path[0].isIndex {
+		return nil, selectParseError("Invalid column reference %v", first)
+	}
+	if p.inProjection && !p.inAggregate {
+		p.plainColumn = true
+	}
+	return col, nil
+}
+
+// aggregate reports whether the query is an aggregation, which outputs only one record.
+func (q *selectQuery) aggregate() bool {
+	return len(q.aggs) > 0
+}
+
+// match reports whether the record is matched by the WHERE clause.
+func (q *selectQuery) match(rec selectRecord) (bool, error) {
+	if q.where == nil {
+		return true, nil
+	}
+	v, err := q.where.eval(rec)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	return ok && b, nil
+}
+
+// accumulate feeds the matched record to the aggregate functions.
+func (q *selectQuery) accumulate(rec selectRecord) error {
+	for _, agg := range q.aggs {
+		if err := agg.accumulate(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// project returns the output fields of the record, the record is nil for the result of aggregation.
+func (q *selectQuery) project(rec selectRecord) (fields []selectField, err error) {
+	if q.star {
+		return rec.fields(), nil
+	}
+	fields = make([]selectField, 0, len(q.items))
+	for i, item := range q.items {
+		var v interface{}
+		if v, err = item.expr.eval(rec); err != nil {
+			return
+		}
+		fields = append(fields, selectField{name: item.name(i), value: v})
+	}
+	return
+}
+
+// name returns the name of output field, which is the alias, the column name or the position.
+func (item *selectItem) name(i int) string {
+	if item.alias != "" {
+		return item.alias
+	}
+	if col, ok := item.expr.(*selectColumn); ok {
+		if last := col.path[len(col.path)-1]; !last.isIndex {
+			return last.name
+		}
+	}
+	return "_" + strconv.Itoa(i+1)
+}
@@ -0,0 +1,282 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type selectEvent struct {
+	headers map[string]string
+	payload []byte
+}
+
+func decodeSelectEvents(t *testing.T, data []byte) (events []selectEvent) {
+	for len(data) > 0 {
+		require.True(t, len(data) >= 16)
+		total := binary.BigEndian.Uint32(data[0:])
+		hlen := binary.BigEndian.Uint32(data[4:])
+		require.Equal(t, crc32.ChecksumIEEE(data[:8]), binary.BigEndian.Uint32(data[8:]))
+		require.Equal(t, crc32.ChecksumIEEE(data[:total-4]), binary.BigEndian.Uint32(data[total-4:]))
+		event := selectEvent{headers: make(map[string]string)}
+		hdata := data[12 : 12+hlen]
+		for len(hdata) > 0 {
+			nlen := int(hdata[0])
+			name := string(hdata[1 : 1+nlen])
+			require.Equal(t, byte(eventHeaderTypeString), hdata[1+nlen])
+			vlen := int(binary.BigEndian.Uint16(hdata[2+nlen:]))
+			event.headers[name] = string(hdata[4+nlen : 4+nlen+vlen])
+			hdata = hdata[4+nlen+vlen:]
+		}
+		event.payload = data[12+hlen : total-4]
+		events = append(events, event)
+		data = data[total:]
+	}
+	return
+}
+
+func runSelectTest(t *testing.T, input string, req *SelectObjectContentRequest) (records string, events []selectEvent) {
+	require.Nil(t, req.validate())
+	query, err := parseSelectQuery(req.Expression)
+	require.NoError(t, err)
+	out := new(bytes.Buffer)
+	_, err = executeSelect(out, strings.NewReader(input), req, query)
+	require.NoError(t, err)
+	events = decodeSelectEvents(t, out.Bytes())
+	for _, e := range events {
+		if e.headers[":event-type"] == eventTypeRecords {
+			records += string(e.payload)
+		}
+	}
+	require.Equal(t, eventTypeStats, events[len(events)-2].headers[":event-type"])
+	require.Equal(t, eventTypeEnd, events[len(events)-1].headers[":event-type"])
+	return
+}
+
+func csvSelectRequest(expr, header string) *SelectObjectContentRequest {
+	return &SelectObjectContentRequest{
+		Expression:          expr,
+		ExpressionType:      SelectExpressionTypeSQL,
+		InputSerialization:  SelectInputSerialization{CSV: &SelectCSVInput{FileHeaderInfo: header}},
+		OutputSerialization: SelectOutputSerialization{CSV: &SelectCSVOutput{}},
+	}
+}
+
+const selectTestCSV = `name,age,city
+alice,30,"New York, NY"
+bob,25,London
+# comment line
+carol,35,Paris
+dave,,Berlin
+`
+
+func TestSelectCSV(t *testing.T) {
+	cases := []struct {
+		expr   string
+		header string
+		result string
+	}{
+		{"SELECT * FROM S3Object", SelectFileHeaderUse, "alice,30,\"New York, NY\"\nbob,25,London\ncarol,35,Paris\ndave,,Berlin\n"},
+		{"select s.name from s3object s where s.age > 28", SelectFileHeaderUse, "alice\ncarol\n"},
+		{"SELECT _1, _3 FROM S3Object WHERE _2 = '25'", SelectFileHeaderIgnore, "bob,London\n"},
+		{"SELECT name FROM S3Object WHERE city LIKE '%o%' AND age IS NOT NULL", SelectFileHeaderUse, "alice\nbob\n"},
+		{"SELECT name FROM S3Object WHERE age = ''", SelectFileHeaderUse, "dave\n"},
+		{"SELECT UPPER(name), CAST(age AS INT) + 1 FROM S3Object WHERE name IN ('bob', 'carol')", SelectFileHeaderUse, "BOB,26\nCAROL,36\n"},
+		{"SELECT name FROM S3Object WHERE age BETWEEN 26 AND 40 LIMIT 1", SelectFileHeaderUse, "alice\n"},
+		{"SELECT COUNT(*), SUM(CAST(age AS INT)), MAX(age), MIN(name) FROM S3Object WHERE age <> ''", SelectFileHeaderUse, "3,90,35,alice\n"},
+		{"SELECT AVG(age) FROM S3Object s WHERE s.name != 'dave'", SelectFileHeaderUse, "30\n"},
+		{"SELECT SUBSTRING(city FROM 1 FOR 3), CHAR_LENGTH(city) FROM S3Object LIMIT 2", SelectFileHeaderUse, "New,12\nLon,6\n"},
+		{"SELECT * FROM S3Object LIMIT 0", SelectFileHeaderUse, ""},
+		{"SELECT * FROM S3Object WHERE NOT (age < 30 OR age IS NULL OR age = '')", SelectFileHeaderUse, "alice,30,\"New York, NY\"\ncarol,35,Paris\n"},
+	}
+	for _, c := range cases {
+		records, _ := runSelectTest(t, selectTestCSV, csvSelectRequest(c.expr, c.header))
+		require.Equal(t, c.result, records, c.expr)
+	}
+
+	// JSON output with the names of header
+	req := csvSelectRequest("SELECT name, age AS years FROM S3Object LIMIT 1", SelectFileHeaderUse)
+	req.OutputSerialization = SelectOutputSerialization{JSON: &SelectJSONOutput{}}
+	records, _ := runSelectTest(t, selectTestCSV, req)
+	require.Equal(t, "{\"name\":\"alice\",\"years\":\"30\"}\n", records)
+
+	// quote all fields with custom delimiters
+	req = csvSelectRequest("SELECT name, city FROM S3Object LIMIT 2", SelectFileHeaderUse)
+	req.OutputSerialization.CSV = &SelectCSVOutput{FieldDelimiter: "|", RecordDelimiter: "\r\n", QuoteFields: "ALWAYS"}
+	records, _ = runSelectTest(t, selectTestCSV, req)
+	require.Equal(t, "\"alice\"|\"New York, NY\"\r\n\"bob\"|\"London\"\r\n", records)
+
+	// gzip compressed input
+	buf := new(bytes.Buffer)
+	gz := gzip.NewWriter(buf)
+	_, _ = gz.Write([]byte(selectTestCSV))
+	require.NoError(t, gz.Close())
+	req = csvSelectRequest("SELECT COUNT(*) FROM S3Object", SelectFileHeaderUse)
+	req.InputSerialization.CompressionType = SelectCompressionGzip
+	req.RequestProgress = &SelectRequestProgress{Enabled: true}
+	records, events := runSelectTest(t, buf.String(), req)
+	require.Equal(t, "4\n", records)
+	stats := string(events[len(events)-2].payload)
+	require.Contains(t, stats, "<BytesScanned>"+itoa(buf.Len())+"</BytesScanned>")
+	require.Contains(t, stats, "<BytesProcessed>"+itoa(len(selectTestCSV))+"</BytesProcessed>")
+	require.Contains(t, stats, "<BytesReturned>2</BytesReturned>")
+}
+
+func itoa(n int) string {
+	return formatSelectValue(int64(n))
+}
+
+func TestSelectJSON(t *testing.T) {
+	input := `{"id": 1, "user": {"name": "alice", "tags": ["a", "b"]}, "score": 9.5}
+{"id": 2, "user": {"name": "bob", "tags": []}, "score": 7}
+{"id": 3, "user": {"name": "carol"}, "active": true}
+`
+	newRequest := func(expr string) *SelectObjectContentRequest {
+		return &SelectObjectContentRequest{
+			Expression:          expr,
+			ExpressionType:      SelectExpressionTypeSQL,
+			InputSerialization:  SelectInputSerialization{JSON: &SelectJSONInput{Type: SelectJSONTypeLines}},
+			OutputSerialization: SelectOutputSerialization{JSON: &SelectJSONOutput{}},
+		}
+	}
+	cases := []struct {
+		expr   string
+		result string
+	}{
+		{"SELECT * FROM S3Object[*] s WHERE s.id = 2", "{\"id\":2,\"user\":{\"name\":\"bob\",\"tags\":[]},\"score\":7}\n"},
+		{"SELECT s.user.name, s.user.tags[1] AS tag FROM S3Object[*] s WHERE s.score >= 9", "{\"name\":\"alice\",\"tag\":\"b\"}\n"},
+		{"SELECT s.id FROM S3Object s WHERE s.score IS NULL OR s.active = true", "{\"id\":3}\n"},
+		{"SELECT SUM(s.score), COUNT(s.score), AVG(s.id) FROM S3Object s", "{\"_1\":16.5,\"_2\":2,\"_3\":2}\n"},
+		{"SELECT s.\"user\".name || '-' || s.id FROM S3Object s LIMIT 1", "{\"_1\":\"alice-1\"}\n"},
+	}
+	for _, c := range cases {
+		records, _ := runSelectTest(t, input, newRequest(c.expr))
+		require.Equal(t, c.result, records, c.expr)
+	}
+
+	// JSON document in multiple lines
+	req := newRequest("SELECT s.a FROM S3Object s")
+	req.InputSerialization.JSON.Type = SelectJSONTypeDocument
+	req.OutputSerialization = SelectOutputSerialization{CSV: &SelectCSVOutput{}}
+	records, _ := runSelectTest(t, "{\n  \"a\": [1, 2]\n}\n{\"a\": \"x\"}", req)
+	require.Equal(t, "\"[1,2]\"\nx\n", records)
+}
+
+func TestSelectError(t *testing.T) {
+	for _, expr := range []string{
+		"SELECT",
+		"SELECT * FROM",
+		"SELECT * FROM t",
+		"SELECT a FROM S3Object WHERE",
+		"SELECT a, COUNT(*) FROM S3Object",
+		"SELECT a FROM S3Object WHERE COUNT(*) > 1",
+		"SELECT FOO(a) FROM S3Object",
+		"SELECT a FROM S3Object LIMIT x",
+		"SELECT 'a FROM S3Object",
+		"SELECT a FROM S3Object[*].b",
+	} {
+		_, err := parseSelectQuery(expr)
+		require.Error(t, err, expr)
+		_, ok := err.(*ErrorCode)
+		require.True(t, ok, expr)
+	}
+
+	// errors in the middle of stream are sent as error events
+	req := csvSelectRequest("SELECT name FROM S3Object WHERE CAST(age AS INT) > 0", SelectFileHeaderUse)
+	query, err := parseSelectQuery(req.Expression)
+	require.NoError(t, err)
+	out := new(bytes.Buffer)
+	started, err := executeSelect(out, strings.NewReader("name,age\n"+strings.Repeat("a,1\n", 100000)+"b,x\n"), req, query)
+	require.Error(t, err)
+	require.True(t, started)
+	events := decodeSelectEvents(t, out.Bytes())
+	last := events[len(events)-1]
+	require.Equal(t, "error", last.headers[":message-type"])
+	require.Equal(t, "CastFailed", last.headers[":error-code"])
+
+	// nothing is written if failed before any record is sent
+	out.Reset()
+	started, err = executeSelect(out, strings.NewReader("name,age\nb,x\n"), req, query)
+	require.Error(t, err)
+	require.False(t, started)
+	require.Zero(t, out.Len())
+}
+
+func TestParseSelectRequest(t *testing.T) {
+	req, err := ParseSelectRequest([]byte(`
+<SelectObjectContentRequest xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Expression>SELECT * FROM S3Object</Expression>
+  <ExpressionType>SQL</ExpressionType>
+  <InputSerialization>
+    <CompressionType>NONE</CompressionType>
+    <CSV><FileHeaderInfo>USE</FileHeaderInfo><Comments></Comments></CSV>
+  </InputSerialization>
+  <OutputSerialization><JSON><RecordDelimiter>,</RecordDelimiter></JSON></OutputSerialization>
+  <ScanRange><Start>10</Start></ScanRange>
+</SelectObjectContentRequest>`))
+	require.NoError(t, err)
+	require.Equal(t, "", req.InputSerialization.CSV.comments())
+	require.Equal(t, ",", req.OutputSerialization.JSON.recordDelimiter())
+	start, end, ok := req.scanRange(100)
+	require.True(t, ok)
+	require.Equal(t, []int64{10, 99}, []int64{start, end})
+
+	for body, ec := range map[string]*ErrorCode{
+		`<SelectObjectContentRequest><ExpressionType>SQL</ExpressionType>`:                                MalformedXML,
+		`<SelectObjectContentRequest><ExpressionType>XPATH</ExpressionType></SelectObjectContentRequest>`: InvalidExpressionType,
+		`<SelectObjectContentRequest><ExpressionType>SQL</ExpressionType><InputSerialization><Parquet/></InputSerialization>
+<OutputSerialization><CSV/></OutputSerialization></SelectObjectContentRequest>`: ParquetNotSupported,
+		`<SelectObjectContentRequest><ExpressionType>SQL</ExpressionType><InputSerialization><CompressionType>ZIP</CompressionType>
+<CSV/></InputSerialization><OutputSerialization><CSV/></OutputSerialization></SelectObjectContentRequest>`: InvalidCompressionFormat,
+		`<SelectObjectContentRequest><ExpressionType>SQL</ExpressionType><InputSerialization><CSV/><JSON><Type>LINES</Type></JSON>
+</InputSerialization><OutputSerialization><CSV/></OutputSerialization></SelectObjectContentRequest>`: MalformedXML,
+		`<SelectObjectContentRequest><ExpressionType>SQL</ExpressionType><InputSerialization><JSON><Type>DOCUMENT</Type></JSON>
+</InputSerialization><OutputSerialization><CSV/></OutputSerialization><ScanRange><Start>1</Start></ScanRange></SelectObjectContentRequest>`: InvalidScanRange,
+		`<SelectObjectContentRequest><ExpressionType>SQL</ExpressionType><InputSerialization><CSV><FieldDelimiter>ab</FieldDelimiter></CSV>
+</InputSerialization><OutputSerialization><CSV/></OutputSerialization></SelectObjectContentRequest>`: InvalidSelectDelimiter,
+	} {
+		_, err = ParseSelectRequest([]byte(body))
+		require.Equal(t, ec, err, body)
+	}
+}
+
+func TestScanRangeReader(t *testing.T) {
+	data := "aaa\nbbb\nccc\nddd\n"
+	read := func(start, end int64) string {
+		offset := start
+		if offset > 0 {
+			offset--
+		}
+		out, err := io.ReadAll(newScanRangeReader(strings.NewReader(data[offset:]), start, end))
+		require.NoError(t, err)
+		return string(out)
+	}
+	require.Equal(t, data, read(0, 15))
+	require.Equal(t, "aaa\n", read(0, 0))
+	require.Equal(t, "aaa\nbbb\n", read(0, 4))
+	// records started before the range are skipped
+	require.Equal(t, "bbb\nccc\n", read(1, 8))
+	require.Equal(t, "bbb\n", read(4, 5))
+	require.Equal(t, "", read(5, 7))
+	require.Equal(t, "ddd\n", read(9, 100))
+}
@@ -93,6 +93,18 @@ const (
 	// The configuration in the example will allow ObjectNode to automatically resolve "* .object.cube.io".
 	configDomains = "domains"
 
+	// String array configuration item, used to configure the domain names of the static website endpoint.
+	// Requests to "<bucket>.<website domain>" are served according to the website configuration of the
+	// bucket, they must not overlap with the domains of object storage interface.
+	// Example:
+	//		{
+	//			"websiteDomains": [
+	//				"website.cube.io"
+	//			]
+	//		}
+	// The configuration in the example will serve "*.website.cube.io" as static websites.
+	configWebsiteDomains = "websiteDomains"
+
 	disabledActions               = "disabledActions"
 	configSignatureIgnoredActions = "signatureIgnoredActions"
 
@@ -154,6 +166,51 @@ const (
 
 	// s3 QoS config refresh interval
 	s3QoSRefreshIntervalSec = "s3QoSRefreshIntervalSec"
+
+	// refresh interval of the public access block settings of cluster stored on master
+	publicAccessBlockRefreshIntervalSec = "publicAccessBlockRefreshIntervalSec"
+
+	// String type configuration item, used to configure the path of the master key file for SSE-S3.
+	// The file contains a 256-bit key encoded in hex or base64, all ObjectNodes of the cluster must use
+	// the same key. SSE-S3 is disabled if not configured, while SSE-C is always available.
+	// Example:
+	//		{
+	//			"sseMasterKeyFile": "/cfs/conf/sse_master.key"
+	//		}
+	configSSEMasterKeyFile = "sseMasterKeyFile"
+
+	// Map type configuration item, used to configure the targets of bucket event notification. For
+	// detailed parameters, see the NotificationConfig structure. The target is referred by the ARN
+	// "arn:cubefs:sqs:<region>:<id>:<kafka|webhook>" in bucket notification configurations.
+	// Example:
+	//		{
+	//			"notification": {
+	//				"queueSize": 10000,
+	//				"kafka": {
+	//					"ingest": {
+	//						"enable": true,
+	//						"topic": "object_event_topic",
+	//						"brokers": "192.168.80.130:9095,192.168.80.131:9095"
+	//					}
+	//				},
+	//				"webhook": {
+	//					"pipeline": {
+	//						"enable": true,
+	//						"endpoint": "http://192.168.80.140:8080/events"
+	//					}
+	//				}
+	//			}
+	//		}
+	configNotification = "notification"
+
+	// String array type configuration item, used to configure the access keys of the replication
+	// workers of the source clusters. The "x-amz-replication-status: REPLICA" header is only trusted
+	// from the requests signed by them, and dropped from the others.
+	// Example:
+	//		{
+	//			"replicationAccessKeys": ["AKCubeFSReplicaExample"]
+	//		}
+	configReplicationAccessKeys = "replicationAccessKeys"
 )
 
 // Default of configuration value
@@ -164,6 +221,9 @@ const (
 	defaultMaxInodeAttrCacheNum   = 1000000
 	defaultS3QoSReloadIntervalSec = 300
 	defaultS3QoSConfName          = "s3qosInfo.conf"
+
+	defaultPublicAccessBlockReloadIntervalSec = 60
+	defaultPublicAccessBlockConfName          = "s3PublicAccessBlock.conf"
 	// ebs
 	MaxSizePutOnce = int64(1) << 23
 )
@@ -182,6 +242,7 @@ var (
 
 type ObjectNode struct {
 	domains    []string
+	webDomains []string // domains of static website endpoint
 	wildcards  Wildcards
 	listen     string
 	region     string
@@ -205,6 +266,14 @@ type ObjectNode struct {
 	rateLimit               RateLimiter
 	limitMutex              sync.RWMutex
 	disableCreateBucketByS3 bool
+
+	sseMasterKey *SSEMasterKey // master key of SSE-S3, nil if not configured
+	notifier     *Notifier     // deliver bucket event notifications, nil if not configured
+
+	publicAccess      *PublicAccessBlockConfiguration // public access block settings of cluster, loaded from master
+	publicAccessMutex sync.RWMutex
+
+	replicationAccessKeys map[string]struct{} // access keys of replication workers of source clusters
 }
 
 func (o *ObjectNode) Start(cfg *config.Config) (err error) {
@@ -240,6 +309,10 @@ func (o *ObjectNode) loadConfig(cfg *config.Config) (err error) {
 	}
 	log.LogInfof("loadConfig: setup config: %v(%v)", configDomains, domains)
 
+	// parse website domain
+	o.webDomains = cfg.GetStringSlice(configWebsiteDomains)
+	log.LogInfof("loadConfig: setup config: %v(%v)", configWebsiteDomains, o.webDomains)
+
 	// parse master config
 	masters := cfg.GetStringSlice(configMasterAddr)
 	if len(masters) == 0 {
@@ -291,6 +364,31 @@ func (o *ObjectNode) loadConfig(cfg *config.Config) (err error) {
 	log.LogInfof("loadConfig: strict: %v", strict)
 	o.disableCreateBucketByS3 = cfg.GetBool(disableCreateBucketByS3)
 
+	// parse SSE master key
+	if keyFile := cfg.GetString(configSSEMasterKeyFile); keyFile != "" {
+		if o.sseMasterKey, err = LoadSSEMasterKey(keyFile); err != nil {
+			log.LogErrorf("loadConfig: load SSE master key fail: file(%v) err(%v)", keyFile, err)
+			return config.NewIllegalConfigError(configSSEMasterKeyFile)
+		}
+		log.LogInfof("loadConfig: SSE master key loaded: id(%v)", o.sseMasterKey.ID())
+	}
+
+	// parse bucket notification targets
+	if rawNotification := cfg.GetValue(configNotification); rawNotification != nil {
+		if err = o.setNotification(rawNotification); err != nil {
+			err = fmt.Errorf("invalid %v configuration: %v", configNotification, err)
+			return
+		}
+		log.LogInfof("loadConfig: setup config: %v(%v)", configNotification, rawNotification)
+	}
+
+	// parse access keys of replication workers
+	o.replicationAccessKeys = make(map[string]struct{})
+	for _, accessKey := range cfg.GetStringSlice(configReplicationAccessKeys) {
+		o.replicationAccessKeys[accessKey] = struct{}{}
+	}
+	log.LogInfof("loadConfig: setup config: %v(%v)", configReplicationAccessKeys, len(o.replicationAccessKeys))
+
 	o.mc = master.NewMasterClient(masters, false)
 	poolSize := cfg.GetInt64(proto.CfgHttpPoolSize)
 	log.LogWarnf("loadConfig: http pool size %d", poolSize)
@@ -374,6 +472,21 @@ func (o *ObjectNode) setAuditLog(raw interface{}) error {
 	return nil
 }
 
+func (o *ObjectNode) setNotification(raw interface{}) error {
+	var conf NotificationConfig
+	if err := ParseJSONEntity(raw, &conf); err != nil {
+		return err
+	}
+	notifier, err := NewNotifier(&conf)
+	if err != nil {
+		return err
+	}
+	o.notifier = notifier
+	o.closes = append(o.closes, func() { o.notifier.Close() })
+
+	return nil
+}
+
 func handleStart(s common.Server, cfg *config.Config) (err error) {
 	o, ok := s.(*ObjectNode)
 	if !ok {
@@ -420,6 +533,18 @@ func handleStart(s common.Server, cfg *config.Config) (err error) {
 		o.limitMutex.Unlock()
 	}
 
+	// public access block settings of cluster, all the objectnodes enforce the same settings
+	// stored on master
+	reloadConf = &reloadconf.ReloadConf{
+		ConfName:      defaultPublicAccessBlockConfName,
+		ReloadSec:     cfg.GetIntWithDefault(publicAccessBlockRefreshIntervalSec, defaultPublicAccessBlockReloadIntervalSec),
+		RequestRemote: o.requestPublicAccessBlock,
+	}
+	if err = reloadconf.StartReload(reloadConf, o.reloadPublicAccessBlock); err != nil {
+		log.LogErrorf("handleStart: load public access block of cluster fail: err(%v)", err)
+		return
+	}
+
 	exporter.RegistConsul(ci.Cluster, cfg.GetString("role"), cfg)
 
 	// start rest api
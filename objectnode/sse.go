@@ -0,0 +1,571 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/serv-side-encryption.html
+//
+// Every encrypted object has its own random data key. Object data is encrypted with AES-256 in CTR
+// mode, so that the ciphertext has the same size as the plaintext and any range of the object can be
+// decrypted independently. The data key is sealed with AES-256-GCM by the master key of ObjectNode
+// (SSE-S3) or by the key provided by the customer (SSE-C), and is stored with the other encryption
+// metadata as inode xattrs. The customer-provided key itself is never stored.
+
+const (
+	SSEAlgorithmAES256 = "AES256"
+	SSEAlgorithmKMS    = "aws:kms"
+
+	sseTypeS3 = "SSE-S3"
+	sseTypeC  = "SSE-C"
+
+	sseKeySize = 32
+	sseIVSize  = aes.BlockSize
+
+	MaxEncryptionConfigSize = 1 << 10 // 1KB
+)
+
+var (
+	errSSEMasterKeyLength = errors.New("the master key must be 32 bytes encoded in hex or base64")
+	errSSEKeyMismatch     = errors.New("the provided key does not match the encryption key of object")
+)
+
+// SSEMasterKey is the key used to seal the data keys of SSE-S3 objects.
+type SSEMasterKey struct {
+	id  string
+	key []byte
+}
+
+// LoadSSEMasterKey loads the master key from the key file, the content of the file is a 256-bit key
+// encoded in hex or base64.
+func LoadSSEMasterKey(path string) (*SSEMasterKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSSEMasterKey(string(bytes.TrimSpace(data)))
+}
+
+func ParseSSEMasterKey(encoded string) (*SSEMasterKey, error) {
+	var key []byte
+	if len(encoded) == hex.EncodedLen(sseKeySize) {
+		key, _ = hex.DecodeString(encoded)
+	} else {
+		key, _ = base64.StdEncoding.DecodeString(encoded)
+	}
+	if len(key) != sseKeySize {
+		return nil, errSSEMasterKeyLength
+	}
+	// the ID identifies the master key without disclosing it
+	sum := sha256.Sum256(key)
+	return &SSEMasterKey{id: hex.EncodeToString(sum[:8]), key: key}, nil
+}
+
+func (k *SSEMasterKey) ID() string {
+	return k.id
+}
+
+// ServerSideEncryptionConfiguration is the default encryption configuration of a bucket.
+type ServerSideEncryptionConfiguration struct {
+	XMLNS   string                     `xml:"xmlns,attr,omitempty" json:"-"`
+	XMLName xml.Name                   `xml:"ServerSideEncryptionConfiguration" json:"-"`
+	Rules   []ServerSideEncryptionRule `xml:"Rule" json:"rules"`
+}
+
+type ServerSideEncryptionRule struct {
+	ApplyServerSideEncryptionByDefault *ServerSideEncryptionByDefault `xml:"ApplyServerSideEncryptionByDefault" json:"default"`
+	BucketKeyEnabled                   bool                           `xml:"BucketKeyEnabled,omitempty" json:"bucket_key_enabled,omitempty"`
+}
+
+type ServerSideEncryptionByDefault struct {
+	SSEAlgorithm   string `xml:"SSEAlgorithm" json:"sse_algorithm"`
+	KMSMasterKeyID string `xml:"KMSMasterKeyID,omitempty" json:"kms_master_key_id,omitempty"`
+}
+
+func ParseEncryptionConfig(data []byte) (*ServerSideEncryptionConfiguration, error) {
+	config := &ServerSideEncryptionConfiguration{}
+	if err := xml.Unmarshal(data, config); err != nil {
+		return nil, MalformedXML
+	}
+	if len(config.Rules) != 1 || config.Rules[0].ApplyServerSideEncryptionByDefault == nil {
+		return nil, MalformedXML
+	}
+	switch config.Rules[0].ApplyServerSideEncryptionByDefault.SSEAlgorithm {
+	case SSEAlgorithmAES256:
+	case SSEAlgorithmKMS:
+		return nil, NotImplemented
+	default:
+		return nil, MalformedXML
+	}
+	return config, nil
+}
+
+// DefaultSSEAlgorithm returns the algorithm applied to objects uploaded without encryption headers.
+func (c *ServerSideEncryptionConfiguration) DefaultSSEAlgorithm() string {
+	if c == nil || len(c.Rules) == 0 || c.Rules[0].ApplyServerSideEncryptionByDefault == nil {
+		return ""
+	}
+	return c.Rules[0].ApplyServerSideEncryptionByDefault.SSEAlgorithm
+}
+
+func storeBucketEncryption(bytes []byte, vol *Volume) (err error) {
+	return vol.store.Put(vol.name, bucketRootPath, XAttrKeyOSSEncryption, bytes)
+}
+
+func deleteBucketEncryption(vol *Volume) (err error) {
+	return vol.store.Delete(vol.name, bucketRootPath, XAttrKeyOSSEncryption)
+}
+
+// SSERequest is the encryption options specified by request headers.
+type SSERequest struct {
+	Algorithm   string // value of x-amz-server-side-encryption
+	CustomerKey []byte // decoded customer-provided key of SSE-C
+	KeyMD5      string // base64 encoded MD5 digest of customer-provided key
+}
+
+func (s *SSERequest) IsCustomer() bool {
+	return s != nil && len(s.CustomerKey) > 0
+}
+
+// ParseSSERequest parses the SSE headers of request, if copySource is true, the SSE-C headers
+// of copy source are parsed.
+func ParseSSERequest(r *http.Request, copySource bool) (*SSERequest, error) {
+	algorithmHeader, keyHeader, keyMD5Header := XAmzSSECustomerAlgorithm, XAmzSSECustomerKey, XAmzSSECustomerKeyMD5
+	if copySource {
+		algorithmHeader, keyHeader, keyMD5Header = XAmzCopySourceSSECustomerAlgorithm, XAmzCopySourceSSECustomerKey,
+			XAmzCopySourceSSECustomerKeyMD5
+	}
+	req := &SSERequest{}
+	if !copySource {
+		req.Algorithm = r.Header.Get(XAmzServerSideEncryption)
+		switch req.Algorithm {
+		case "", SSEAlgorithmAES256:
+		case SSEAlgorithmKMS:
+			return nil, NotImplemented
+		default:
+			return nil, InvalidEncryptionAlgorithm
+		}
+	}
+
+	algorithm, key, keyMD5 := r.Header.Get(algorithmHeader), r.Header.Get(keyHeader), r.Header.Get(keyMD5Header)
+	if algorithm == "" && key == "" && keyMD5 == "" {
+		return req, nil
+	}
+	// the customer key is never accepted in plain text
+	if !isSecureRequest(r) {
+		return nil, SSECustomerKeyInsecure
+	}
+	if req.Algorithm != "" {
+		// SSE-S3 and SSE-C can not be specified together
+		return nil, InvalidSSERequest
+	}
+	if algorithm != SSEAlgorithmAES256 {
+		return nil, InvalidEncryptionAlgorithm
+	}
+	customerKey, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(customerKey) != sseKeySize {
+		return nil, InvalidSSECustomerKey
+	}
+	sum := md5.Sum(customerKey)
+	if keyMD5 != base64.StdEncoding.EncodeToString(sum[:]) {
+		return nil, SSECustomerKeyMD5Mismatch
+	}
+	req.CustomerKey = customerKey
+	req.KeyMD5 = keyMD5
+	return req, nil
+}
+
+// isSecureRequest reports whether the request is made over TLS, to the ObjectNode or to the proxy
+// in front of it which sets X-Forwarded-Proto.
+func isSecureRequest(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+	return strings.EqualFold(r.Header.Get(XForwardedProto), "https")
+}
+
+// SSEPart records the part number, size and IV of a part of multipart uploaded object, each
+// part upload is encrypted with its own random IV, so that the part uploaded again never reuses
+// the key stream. The parts uploaded before have no IV, and use the IV of the object.
+type SSEPart struct {
+	Number uint16
+	Size   uint64
+	IV     []byte
+}
+
+// ObjectEncryption is the encryption metadata of an object.
+type ObjectEncryption struct {
+	Type        string `json:"type"`
+	Algorithm   string `json:"algorithm"`
+	MasterKeyID string `json:"master_key_id,omitempty"`
+	KeyMD5      string `json:"key_md5,omitempty"`
+	SealedKey   []byte `json:"sealed_key"`
+	IV          []byte `json:"iv"`
+
+	parts   []SSEPart
+	dataKey []byte
+	block   cipher.Block
+}
+
+// NewObjectEncryption creates the encryption of a new object with a random data key.
+func NewObjectEncryption(req *SSERequest, masterKey *SSEMasterKey) (*ObjectEncryption, error) {
+	e := &ObjectEncryption{
+		Algorithm: SSEAlgorithmAES256,
+		IV:        make([]byte, sseIVSize),
+		dataKey:   make([]byte, sseKeySize),
+	}
+	if _, err := io.ReadFull(rand.Reader, e.dataKey); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(rand.Reader, e.IV); err != nil {
+		return nil, err
+	}
+	var sealKey []byte
+	if req.IsCustomer() {
+		e.Type = sseTypeC
+		e.KeyMD5 = req.KeyMD5
+		sealKey = req.CustomerKey
+	} else {
+		if masterKey == nil {
+			return nil, SSENotConfigured
+		}
+		e.Type = sseTypeS3
+		e.MasterKeyID = masterKey.ID()
+		sealKey = masterKey.key
+	}
+	var err error
+	if e.SealedKey, err = sealDataKey(sealKey, e.dataKey); err != nil {
+		return nil, err
+	}
+	if e.block, err = aes.NewCipher(e.dataKey); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// ParseObjectEncryption parses the encryption metadata of object from xattrs, it returns nil if
+// the object is not encrypted.
+func ParseObjectEncryption(raw, rawParts []byte) (*ObjectEncryption, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	e := &ObjectEncryption{}
+	if err := json.Unmarshal(raw, e); err != nil {
+		return nil, err
+	}
+	if len(rawParts) > 0 {
+		parts, err := parseSSEParts(string(rawParts))
+		if err != nil {
+			return nil, err
+		}
+		e.parts = parts
+	}
+	return e, nil
+}
+
+func (e *ObjectEncryption) Encode() string {
+	data, _ := json.Marshal(e)
+	return string(data)
+}
+
+func (e *ObjectEncryption) IsCustomer() bool {
+	return e != nil && e.Type == sseTypeC
+}
+
+// Unseal recovers the data key of object by the master key or the customer-provided key.
+func (e *ObjectEncryption) Unseal(req *SSERequest, masterKey *SSEMasterKey) (err error) {
+	if e.block != nil {
+		return nil
+	}
+	var sealKey []byte
+	if e.IsCustomer() {
+		if !req.IsCustomer() {
+			return SSECustomerKeyMissing
+		}
+		if req.KeyMD5 != e.KeyMD5 {
+			return SSECustomerKeyMismatch
+		}
+		sealKey = req.CustomerKey
+	} else {
+		if masterKey == nil || masterKey.ID() != e.MasterKeyID {
+			return SSEMasterKeyMissing
+		}
+		sealKey = masterKey.key
+	}
+	if e.dataKey, err = openDataKey(sealKey, e.SealedKey); err != nil {
+		return SSECustomerKeyMismatch
+	}
+	e.block, err = aes.NewCipher(e.dataKey)
+	return
+}
+
+// SetResponseHeader sets the SSE headers of response.
+func (e *ObjectEncryption) SetResponseHeader(w http.ResponseWriter) {
+	if e == nil {
+		return
+	}
+	if e.IsCustomer() {
+		w.Header().Set(XAmzSSECustomerAlgorithm, e.Algorithm)
+		w.Header().Set(XAmzSSECustomerKeyMD5, e.KeyMD5)
+		return
+	}
+	w.Header().Set(XAmzServerSideEncryption, e.Algorithm)
+}
+
+// ForPart returns a copy of the encryption with a new random IV for the upload of a part.
+func (e *ObjectEncryption) ForPart() (*ObjectEncryption, error) {
+	c := *e
+	c.IV = make([]byte, sseIVSize)
+	if _, err := io.ReadFull(rand.Reader, c.IV); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// WithParts returns a copy of the encryption for the object completed by the parts.
+func (e *ObjectEncryption) WithParts(parts []SSEPart) *ObjectEncryption {
+	c := *e
+	c.parts = parts
+	return &c
+}
+
+func (e *ObjectEncryption) EncodeParts() string {
+	return formatSSEParts(e.parts)
+}
+
+// counter returns the initial counter block for the data at offset of the part.
+// The IV is treated as a 128-bit big endian integer, the part number is added to its
+// high 64 bits and the block index of offset is added to the whole integer.
+func counter(iv []byte, partNumber uint16, offset uint64) []byte {
+	ctr := make([]byte, sseIVSize)
+	copy(ctr, iv)
+	addToCounter(ctr[:8], uint64(partNumber))
+	addToCounter(ctr, offset/aes.BlockSize)
+	return ctr
+}
+
+func addToCounter(ctr []byte, n uint64) {
+	for i := len(ctr) - 1; i >= 0 && n > 0; i-- {
+		sum := uint64(ctr[i]) + n&0xff
+		ctr[i] = byte(sum)
+		n = n>>8 + sum>>8
+	}
+}
+
+// xorKeyStream encrypts or decrypts the data at offset of the part with the IV in place.
+func (e *ObjectEncryption) xorKeyStream(data []byte, iv []byte, partNumber uint16, offset uint64) {
+	if len(data) == 0 {
+		return
+	}
+	stream := cipher.NewCTR(e.block, counter(iv, partNumber, offset))
+	if skip := int(offset % aes.BlockSize); skip > 0 {
+		var discard [aes.BlockSize]byte
+		stream.XORKeyStream(discard[:skip], discard[:skip])
+	}
+	stream.XORKeyStream(data, data)
+}
+
+// XORAt encrypts or decrypts the data at offset of object in place.
+func (e *ObjectEncryption) XORAt(data []byte, offset uint64) {
+	if len(e.parts) == 0 {
+		e.xorKeyStream(data, e.IV, 0, offset)
+		return
+	}
+	var partStart uint64
+	for _, part := range e.parts {
+		if len(data) == 0 {
+			return
+		}
+		partEnd := partStart + part.Size
+		if offset < partEnd {
+			n := partEnd - offset
+			if n > uint64(len(data)) {
+				n = uint64(len(data))
+			}
+			iv := part.IV
+			if len(iv) == 0 {
+				iv = e.IV
+			}
+			e.xorKeyStream(data[:n], iv, part.Number, offset-partStart)
+			data = data[n:]
+			offset += n
+		}
+		partStart = partEnd
+	}
+}
+
+// EncryptReader returns a reader which encrypts the data read from reader as the part of object
+// with the IV of the encryption, the part number of single uploaded object is 0.
+func (e *ObjectEncryption) EncryptReader(reader io.Reader, partNumber uint16) io.Reader {
+	return &sseReader{reader: reader, enc: e, partNumber: partNumber}
+}
+
+// DecryptWriter returns a writer which decrypts the data of object from offset before writing.
+func (e *ObjectEncryption) DecryptWriter(writer io.Writer, offset uint64) io.Writer {
+	return &sseWriter{writer: writer, enc: e, offset: offset}
+}
+
+// encryptWriteSource wraps the reader to encrypt the data written as the part of object. The hash is
+// fed with the plaintext, so that the ETag of an encrypted object is the same as the unencrypted one.
+// The returned hash is a placeholder for the writing methods.
+func encryptWriteSource(enc *ObjectEncryption, reader io.Reader, h hash.Hash, partNumber uint16) (io.Reader, hash.Hash) {
+	if enc == nil {
+		return reader, h
+	}
+	return enc.EncryptReader(io.TeeReader(reader, h), partNumber), discardHash{}
+}
+
+type discardHash struct{}
+
+func (discardHash) Write(p []byte) (int, error) { return len(p), nil }
+func (discardHash) Sum(b []byte) []byte         { return b }
+func (discardHash) Reset()                      {}
+func (discardHash) Size() int                   { return 0 }
+func (discardHash) BlockSize() int              { return 1 }
+
+type sseReader struct {
+	reader     io.Reader
+	enc        *ObjectEncryption
+	partNumber uint16
+	offset     uint64
+}
+
+func (r *sseReader) Read(p []byte) (n int, err error) {
+	n, err = r.reader.Read(p)
+	if n > 0 {
+		r.enc.xorKeyStream(p[:n], r.enc.IV, r.partNumber, r.offset)
+		r.offset += uint64(n)
+	}
+	return
+}
+
+type sseWriter struct {
+	writer io.Writer
+	enc    *ObjectEncryption
+	offset uint64
+	buf    []byte
+}
+
+func (w *sseWriter) Write(p []byte) (n int, err error) {
+	// the data passed in must not be modified
+	if cap(w.buf) < len(p) {
+		w.buf = make([]byte, len(p))
+	}
+	buf := w.buf[:len(p)]
+	copy(buf, p)
+	w.enc.XORAt(buf, w.offset)
+	n, err = w.writer.Write(buf)
+	w.offset += uint64(n)
+	return
+}
+
+func sealDataKey(key, dataKey []byte) ([]byte, error) {
+	aead, err := newKeyAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, dataKey, nil), nil
+}
+
+func openDataKey(key, sealed []byte) ([]byte, error) {
+	aead, err := newKeyAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errSSEKeyMismatch
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	dataKey, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, errSSEKeyMismatch
+	}
+	return dataKey, nil
+}
+
+func newKeyAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// formatSSEParts encodes parts as "number:size" pairs separated by comma.
+func formatSSEParts(parts []SSEPart) string {
+	items := make([]string, 0, len(parts))
+	for _, part := range parts {
+		item := fmt.Sprintf("%d:%d", part.Number, part.Size)
+		if len(part.IV) > 0 {
+			item += ":" + hex.EncodeToString(part.IV)
+		}
+		items = append(items, item)
+	}
+	return strings.Join(items, ",")
+}
+
+func parseSSEParts(raw string) ([]SSEPart, error) {
+	items := strings.Split(raw, ",")
+	parts := make([]SSEPart, 0, len(items))
+	for _, item := range items {
+		fields := strings.SplitN(item, ":", 3)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("invalid encrypted part: %v", item)
+		}
+		number, err := strconv.ParseUint(fields[0], 10, 16)
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		part := SSEPart{Number: uint16(number), Size: size}
+		if len(fields) == 3 {
+			if part.IV, err = hex.DecodeString(fields[2]); err != nil || len(part.IV) != sseIVSize {
+				return nil, fmt.Errorf("invalid IV of encrypted part: %v", item)
+			}
+		}
+		parts = append(parts, part)
+	}
+	if !sort.SliceIsSorted(parts, func(i, j int) bool { return parts[i].Number < parts[j].Number }) {
+		return nil, fmt.Errorf("encrypted parts out of order: %v", raw)
+	}
+	return parts, nil
+}
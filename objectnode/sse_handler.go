@@ -0,0 +1,212 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// Get Bucket Encryption
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketEncryption.html
+func (o *ObjectNode) getBucketEncryptionHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	param := ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("getBucketEncryptionHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+
+	var config *ServerSideEncryptionConfiguration
+	if config, err = vol.metaLoader.loadEncryption(); err != nil {
+		log.LogErrorf("getBucketEncryptionHandler: load encryption fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	if config == nil {
+		errorCode = NoSuchEncryptionConfiguration
+		return
+	}
+	output := &ServerSideEncryptionConfiguration{XMLNS: S3Namespace, Rules: config.Rules}
+	var data []byte
+	if data, err = MarshalXMLEntity(output); err != nil {
+		log.LogErrorf("getBucketEncryptionHandler: xml marshal fail: requestID(%v) volume(%v) config(%+v) err(%v)",
+			GetRequestID(r), vol.Name(), output, err)
+		return
+	}
+
+	writeSuccessResponseXML(w, data)
+}
+
+// Put Bucket Encryption
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketEncryption.html
+func (o *ObjectNode) putBucketEncryptionHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	param := ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("putBucketEncryptionHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+
+	var body []byte
+	if body, err = io.ReadAll(io.LimitReader(r.Body, MaxEncryptionConfigSize+1)); err != nil {
+		log.LogErrorf("putBucketEncryptionHandler: read request body fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	if len(body) > MaxEncryptionConfigSize {
+		errorCode = EntityTooLarge
+		return
+	}
+	var config *ServerSideEncryptionConfiguration
+	if config, err = ParseEncryptionConfig(body); err != nil {
+		log.LogErrorf("putBucketEncryptionHandler: parse encryption config fail: requestID(%v) volume(%v) config(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(body), err)
+		return
+	}
+	// objects can not be encrypted by default if there is no master key
+	if config.DefaultSSEAlgorithm() == SSEAlgorithmAES256 && o.sseMasterKey == nil {
+		errorCode = SSENotConfigured
+		return
+	}
+	if body, err = json.Marshal(config); err != nil {
+		log.LogErrorf("putBucketEncryptionHandler: json.Marshal encryption config fail: requestID(%v) volume(%v) config(%+v) err(%v)",
+			GetRequestID(r), vol.Name(), config, err)
+		return
+	}
+	if err = storeBucketEncryption(body, vol); err != nil {
+		log.LogErrorf("putBucketEncryptionHandler: store encryption config fail: requestID(%v) volume(%v) config(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(body), err)
+		return
+	}
+	vol.metaLoader.storeEncryption(config)
+	log.LogInfof("Audit: putBucketEncryptionHandler: requestID(%v) volume(%v) algorithm(%v)",
+		GetRequestID(r), vol.Name(), config.DefaultSSEAlgorithm())
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Delete Bucket Encryption
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketEncryption.html
+func (o *ObjectNode) deleteBucketEncryptionHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	param := ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("deleteBucketEncryptionHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+
+	if err = deleteBucketEncryption(vol); err != nil {
+		log.LogErrorf("deleteBucketEncryptionHandler: delete encryption config fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	vol.metaLoader.storeEncryption(nil)
+	log.LogInfof("Audit: deleteBucketEncryptionHandler: requestID(%v) volume(%v)", GetRequestID(r), vol.Name())
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// newObjectEncryption creates the encryption of the object to be written by request. The bucket default
+// encryption is applied if no SSE header is specified, it returns nil if the object is not encrypted.
+func (o *ObjectNode) newObjectEncryption(r *http.Request, vol *Volume) (*ObjectEncryption, error) {
+	req, err := ParseSSERequest(r, false)
+	if err != nil {
+		return nil, err
+	}
+	return o.newObjectEncryptionByRequest(req, vol)
+}
+
+func (o *ObjectNode) newObjectEncryptionByRequest(req *SSERequest, vol *Volume) (*ObjectEncryption, error) {
+	if req.Algorithm == "" && !req.IsCustomer() {
+		config, err := vol.metaLoader.loadEncryption()
+		if err != nil {
+			return nil, err
+		}
+		if req.Algorithm = config.DefaultSSEAlgorithm(); req.Algorithm == "" {
+			return nil, nil
+		}
+	}
+	return NewObjectEncryption(req, o.sseMasterKey)
+}
+
+// unsealObjectEncryption recovers the data key of an encrypted object by the SSE headers of request,
+// the SSE-C headers of copy source are used if copySource is true.
+func (o *ObjectNode) unsealObjectEncryption(r *http.Request, enc *ObjectEncryption, copySource bool) error {
+	if enc == nil {
+		return nil
+	}
+	req, err := ParseSSERequest(r, copySource)
+	if err != nil {
+		return err
+	}
+	return enc.Unseal(req, o.sseMasterKey)
+}
+
+// loadMultipartEncryption loads the encryption of multipart upload and recovers the data key by the
+// SSE headers of request, it returns nil if the upload is not encrypted.
+func (o *ObjectNode) loadMultipartEncryption(r *http.Request, vol *Volume, path, uploadId string) (
+	*ObjectEncryption, error) {
+	enc, err := vol.MultipartEncryption(path, uploadId)
+	if err != nil {
+		return nil, err
+	}
+	if err = o.unsealObjectEncryption(r, enc, false); err != nil {
+		return nil, err
+	}
+	return enc, nil
+}
@@ -0,0 +1,341 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSSEMasterKey(t *testing.T) *SSEMasterKey {
+	key := make([]byte, sseKeySize)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	masterKey, err := ParseSSEMasterKey(hex.EncodeToString(key))
+	require.NoError(t, err)
+	return masterKey
+}
+
+func newTestCustomerKey(t *testing.T) (key, keyMD5 string) {
+	raw := make([]byte, sseKeySize)
+	_, err := rand.Read(raw)
+	require.NoError(t, err)
+	sum := md5.Sum(raw)
+	return base64.StdEncoding.EncodeToString(raw), base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func TestParseSSEMasterKey(t *testing.T) {
+	key := bytes.Repeat([]byte{0x5a}, sseKeySize)
+	hexKey, err := ParseSSEMasterKey(hex.EncodeToString(key))
+	require.NoError(t, err)
+	base64Key, err := ParseSSEMasterKey(base64.StdEncoding.EncodeToString(key))
+	require.NoError(t, err)
+	require.Equal(t, hexKey.ID(), base64Key.ID())
+	require.Len(t, hexKey.ID(), 16)
+
+	_, err = ParseSSEMasterKey(hex.EncodeToString(key[:16]))
+	require.Equal(t, errSSEMasterKeyLength, err)
+	_, err = ParseSSEMasterKey("not a key")
+	require.Equal(t, errSSEMasterKeyLength, err)
+}
+
+func TestParseEncryptionConfig(t *testing.T) {
+	tests := []struct {
+		value       string
+		expectedErr error
+	}{
+		{
+			value: `<ServerSideEncryptionConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+						<Rule>
+							<ApplyServerSideEncryptionByDefault>
+								<SSEAlgorithm>AES256</SSEAlgorithm>
+							</ApplyServerSideEncryptionByDefault>
+						</Rule>
+					</ServerSideEncryptionConfiguration>`,
+		},
+		{
+			value: `<ServerSideEncryptionConfiguration>
+						<Rule>
+							<ApplyServerSideEncryptionByDefault>
+								<SSEAlgorithm>aws:kms</SSEAlgorithm>
+								<KMSMasterKeyID>key</KMSMasterKeyID>
+							</ApplyServerSideEncryptionByDefault>
+						</Rule>
+					</ServerSideEncryptionConfiguration>`,
+			expectedErr: NotImplemented,
+		},
+		{
+			value: `<ServerSideEncryptionConfiguration>
+						<Rule>
+							<ApplyServerSideEncryptionByDefault>
+								<SSEAlgorithm>DES</SSEAlgorithm>
+							</ApplyServerSideEncryptionByDefault>
+						</Rule>
+					</ServerSideEncryptionConfiguration>`,
+			expectedErr: MalformedXML,
+		},
+		{
+			value:       `<ServerSideEncryptionConfiguration></ServerSideEncryptionConfiguration>`,
+			expectedErr: MalformedXML,
+		},
+		{
+			value:       `<ServerSideEncryptionConfiguration><Rule>`,
+			expectedErr: MalformedXML,
+		},
+	}
+	for _, tt := range tests {
+		config, err := ParseEncryptionConfig([]byte(tt.value))
+		require.Equal(t, tt.expectedErr, err)
+		if err == nil {
+			require.Equal(t, SSEAlgorithmAES256, config.DefaultSSEAlgorithm())
+		}
+	}
+
+	var config *ServerSideEncryptionConfiguration
+	require.Equal(t, "", config.DefaultSSEAlgorithm())
+}
+
+func TestParseSSERequest(t *testing.T) {
+	key, keyMD5 := newTestCustomerKey(t)
+	newRequest := func(headers map[string]string) *http.Request {
+		r, _ := http.NewRequest(http.MethodPut, "/bucket/key", nil)
+		r.TLS = &tls.ConnectionState{}
+		for name, value := range headers {
+			r.Header.Set(name, value)
+		}
+		return r
+	}
+
+	req, err := ParseSSERequest(newRequest(nil), false)
+	require.NoError(t, err)
+	require.Equal(t, "", req.Algorithm)
+	require.False(t, req.IsCustomer())
+
+	req, err = ParseSSERequest(newRequest(map[string]string{XAmzServerSideEncryption: SSEAlgorithmAES256}), false)
+	require.NoError(t, err)
+	require.Equal(t, SSEAlgorithmAES256, req.Algorithm)
+
+	_, err = ParseSSERequest(newRequest(map[string]string{XAmzServerSideEncryption: "DES"}), false)
+	require.Equal(t, InvalidEncryptionAlgorithm, err)
+	_, err = ParseSSERequest(newRequest(map[string]string{XAmzServerSideEncryption: SSEAlgorithmKMS}), false)
+	require.Equal(t, NotImplemented, err)
+
+	customer := map[string]string{
+		XAmzSSECustomerAlgorithm: SSEAlgorithmAES256,
+		XAmzSSECustomerKey:       key,
+		XAmzSSECustomerKeyMD5:    keyMD5,
+	}
+	req, err = ParseSSERequest(newRequest(customer), false)
+	require.NoError(t, err)
+	require.True(t, req.IsCustomer())
+	require.Equal(t, keyMD5, req.KeyMD5)
+
+	// the customer key is rejected over plain HTTP, unless the proxy in front serves HTTPS
+	plain := newRequest(customer)
+	plain.TLS = nil
+	_, err = ParseSSERequest(plain, false)
+	require.Equal(t, SSECustomerKeyInsecure, err)
+	plain.Header.Set(XForwardedProto, "http")
+	_, err = ParseSSERequest(plain, false)
+	require.Equal(t, SSECustomerKeyInsecure, err)
+	plain.Header.Set(XForwardedProto, "https")
+	req, err = ParseSSERequest(plain, false)
+	require.NoError(t, err)
+	require.True(t, req.IsCustomer())
+	// SSE-S3 is not affected
+	plain = newRequest(map[string]string{XAmzServerSideEncryption: SSEAlgorithmAES256})
+	plain.TLS = nil
+	_, err = ParseSSERequest(plain, false)
+	require.NoError(t, err)
+
+	// copy source headers are parsed separately
+	req, err = ParseSSERequest(newRequest(customer), true)
+	require.NoError(t, err)
+	require.False(t, req.IsCustomer())
+	req, err = ParseSSERequest(newRequest(map[string]string{
+		XAmzCopySourceSSECustomerAlgorithm: SSEAlgorithmAES256,
+		XAmzCopySourceSSECustomerKey:       key,
+		XAmzCopySourceSSECustomerKeyMD5:    keyMD5,
+	}), true)
+	require.NoError(t, err)
+	require.True(t, req.IsCustomer())
+
+	customer[XAmzServerSideEncryption] = SSEAlgorithmAES256
+	_, err = ParseSSERequest(newRequest(customer), false)
+	require.Equal(t, InvalidSSERequest, err)
+	delete(customer, XAmzServerSideEncryption)
+
+	customer[XAmzSSECustomerKeyMD5] = base64.StdEncoding.EncodeToString(make([]byte, md5.Size))
+	_, err = ParseSSERequest(newRequest(customer), false)
+	require.Equal(t, SSECustomerKeyMD5Mismatch, err)
+
+	customer[XAmzSSECustomerKey] = base64.StdEncoding.EncodeToString([]byte("short"))
+	_, err = ParseSSERequest(newRequest(customer), false)
+	require.Equal(t, InvalidSSECustomerKey, err)
+
+	customer[XAmzSSECustomerAlgorithm] = "DES"
+	_, err = ParseSSERequest(newRequest(customer), false)
+	require.Equal(t, InvalidEncryptionAlgorithm, err)
+}
+
+func TestObjectEncryptionSeal(t *testing.T) {
+	masterKey := newTestSSEMasterKey(t)
+
+	_, err := NewObjectEncryption(&SSERequest{Algorithm: SSEAlgorithmAES256}, nil)
+	require.Equal(t, SSENotConfigured, err)
+
+	// SSE-S3
+	enc, err := NewObjectEncryption(&SSERequest{Algorithm: SSEAlgorithmAES256}, masterKey)
+	require.NoError(t, err)
+	require.False(t, enc.IsCustomer())
+	parsed, err := ParseObjectEncryption([]byte(enc.Encode()), nil)
+	require.NoError(t, err)
+	require.Equal(t, SSEMasterKeyMissing, parsed.Unseal(&SSERequest{}, nil))
+	require.Equal(t, SSEMasterKeyMissing, parsed.Unseal(&SSERequest{}, newTestSSEMasterKey(t)))
+	require.NoError(t, parsed.Unseal(&SSERequest{}, masterKey))
+	require.Equal(t, enc.dataKey, parsed.dataKey)
+
+	// SSE-C
+	key, keyMD5 := newTestCustomerKey(t)
+	customerKey, _ := base64.StdEncoding.DecodeString(key)
+	req := &SSERequest{Algorithm: "", CustomerKey: customerKey, KeyMD5: keyMD5}
+	enc, err = NewObjectEncryption(req, nil)
+	require.NoError(t, err)
+	require.True(t, enc.IsCustomer())
+	require.NotContains(t, enc.Encode(), key)
+	parsed, err = ParseObjectEncryption([]byte(enc.Encode()), nil)
+	require.NoError(t, err)
+	require.Equal(t, SSECustomerKeyMissing, parsed.Unseal(&SSERequest{}, masterKey))
+	otherKey, otherKeyMD5 := newTestCustomerKey(t)
+	otherCustomerKey, _ := base64.StdEncoding.DecodeString(otherKey)
+	require.Equal(t, SSECustomerKeyMismatch, parsed.Unseal(&SSERequest{CustomerKey: otherCustomerKey, KeyMD5: otherKeyMD5}, nil))
+	require.NoError(t, parsed.Unseal(req, nil))
+	require.Equal(t, enc.dataKey, parsed.dataKey)
+
+	// not encrypted
+	parsed, err = ParseObjectEncryption(nil, nil)
+	require.NoError(t, err)
+	require.Nil(t, parsed)
+}
+
+func TestObjectEncryptionRoundTrip(t *testing.T) {
+	enc, err := NewObjectEncryption(&SSERequest{Algorithm: SSEAlgorithmAES256}, newTestSSEMasterKey(t))
+	require.NoError(t, err)
+
+	plaintext := make([]byte, 10000)
+	_, err = rand.Read(plaintext)
+	require.NoError(t, err)
+
+	// encrypt with small reads so that the counter crosses block boundaries unaligned
+	md5Hash := md5.New()
+	reader, h := encryptWriteSource(enc, io.LimitReader(bytes.NewReader(plaintext), int64(len(plaintext))), md5Hash, 0)
+	ciphertext := make([]byte, 0, len(plaintext))
+	buf := make([]byte, 7)
+	for {
+		n, err := reader.Read(buf)
+		ciphertext = append(ciphertext, buf[:n]...)
+		_, _ = h.Write(buf[:n])
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+	}
+	require.Equal(t, len(plaintext), len(ciphertext))
+	require.NotEqual(t, plaintext, ciphertext)
+	sum := md5.Sum(plaintext)
+	require.Equal(t, sum[:], md5Hash.Sum(nil))
+
+	// decrypt arbitrary ranges
+	for _, rng := range [][2]int{{0, len(plaintext)}, {1, 17}, {15, 33}, {4095, 5000}, {9999, 10000}} {
+		out := &bytes.Buffer{}
+		writer := enc.DecryptWriter(out, uint64(rng[0]))
+		data := ciphertext[rng[0]:rng[1]]
+		_, err = writer.Write(data[:len(data)/2])
+		require.NoError(t, err)
+		_, err = writer.Write(data[len(data)/2:])
+		require.NoError(t, err)
+		require.Equal(t, plaintext[rng[0]:rng[1]], out.Bytes())
+		// the source data must not be modified
+		require.Equal(t, ciphertext[rng[0]:rng[1]], data)
+	}
+}
+
+func TestObjectEncryptionParts(t *testing.T) {
+	enc, err := NewObjectEncryption(&SSERequest{Algorithm: SSEAlgorithmAES256}, newTestSSEMasterKey(t))
+	require.NoError(t, err)
+
+	// parts may be uploaded with any number and size, the part 1 is uploaded before the IV of part
+	// is introduced
+	parts := []SSEPart{{Number: 1, Size: 100}, {Number: 3, Size: 37}, {Number: 4, Size: 64}}
+	var plaintext, ciphertext []byte
+	for i, part := range parts {
+		data := make([]byte, part.Size)
+		_, err = rand.Read(data)
+		require.NoError(t, err)
+		partEnc := enc
+		if i > 0 {
+			partEnc, err = enc.ForPart()
+			require.NoError(t, err)
+			require.NotEqual(t, enc.IV, partEnc.IV)
+			parts[i].IV = partEnc.IV
+		}
+		encrypted, err := io.ReadAll(partEnc.EncryptReader(bytes.NewReader(data), part.Number))
+		require.NoError(t, err)
+		plaintext = append(plaintext, data...)
+		ciphertext = append(ciphertext, encrypted...)
+	}
+
+	// the part uploaded again never reuses the key stream
+	again, err := enc.ForPart()
+	require.NoError(t, err)
+	zeros := make([]byte, parts[1].Size)
+	stream1, err := io.ReadAll(again.EncryptReader(bytes.NewReader(zeros), parts[1].Number))
+	require.NoError(t, err)
+	again, err = enc.ForPart()
+	require.NoError(t, err)
+	stream2, err := io.ReadAll(again.EncryptReader(bytes.NewReader(zeros), parts[1].Number))
+	require.NoError(t, err)
+	require.NotEqual(t, stream1, stream2)
+
+	completed := enc.WithParts(parts)
+	require.Equal(t, fmt.Sprintf("1:100,3:37:%x,4:64:%x", parts[1].IV, parts[2].IV), completed.EncodeParts())
+	parsed, err := ParseObjectEncryption([]byte(completed.Encode()), []byte(completed.EncodeParts()))
+	require.NoError(t, err)
+	require.Equal(t, parts, parsed.parts)
+	parsed.block = enc.block
+
+	for _, rng := range [][2]int{{0, len(plaintext)}, {90, 110}, {100, 137}, {136, 201}, {200, 201}} {
+		data := append([]byte(nil), ciphertext[rng[0]:rng[1]]...)
+		parsed.XORAt(data, uint64(rng[0]))
+		require.Equal(t, plaintext[rng[0]:rng[1]], data)
+	}
+
+	_, err = parseSSEParts("3:10,1:10")
+	require.Error(t, err)
+	_, err = parseSSEParts("1-10")
+	require.Error(t, err)
+	_, err = parseSSEParts("1:10:0102")
+	require.Error(t, err)
+}
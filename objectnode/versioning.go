@@ -0,0 +1,131 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/Versioning.html
+
+const (
+	VersioningEnabled   = "Enabled"
+	VersioningSuspended = "Suspended"
+
+	// NullVersionId is the version ID of objects written while versioning is not enabled.
+	NullVersionId = "null"
+
+	MaxVersioningConfigSize = 1 << 10 // 1KB
+
+	// Noncurrent versions and delete markers are kept in a hidden directory under the
+	// volume root. The layout mirrors the object key, every version of key "a/b" is an
+	// inode linked as "<versionArchiveDir>/a/b/<versionId>".
+	versionArchiveDir = proto.VersionArchiveDir
+)
+
+type VersioningConfiguration struct {
+	XMLNS     string    `xml:"xmlns,attr,omitempty" json:"-"`
+	XMLName   *xml.Name `xml:"VersioningConfiguration" json:"-"`
+	Status    string    `xml:"Status,omitempty" json:"status,omitempty"`
+	MfaDelete string    `xml:"MfaDelete,omitempty" json:"mfa_delete,omitempty"`
+}
+
+// IsEnabled reports whether new object versions should be kept.
+func (c *VersioningConfiguration) IsEnabled() bool {
+	return c != nil && c.Status == VersioningEnabled
+}
+
+// IsActive reports whether versioning has ever been enabled on the bucket,
+// in which case noncurrent versions must not be dropped silently.
+func (c *VersioningConfiguration) IsActive() bool {
+	return c != nil && (c.Status == VersioningEnabled || c.Status == VersioningSuspended)
+}
+
+func ParseVersioningConfig(data []byte) (*VersioningConfiguration, error) {
+	config := &VersioningConfiguration{}
+	if err := xml.Unmarshal(data, config); err != nil {
+		return nil, MalformedXML
+	}
+	switch config.Status {
+	case VersioningEnabled, VersioningSuspended:
+	default:
+		return nil, InvalidVersioningStatus
+	}
+	// MFA delete is not supported
+	if config.MfaDelete != "" && config.MfaDelete != "Disabled" {
+		return nil, InvalidVersioningStatus
+	}
+	return config, nil
+}
+
+func storeBucketVersioning(bytes []byte, vol *Volume) (err error) {
+	return vol.store.Put(vol.name, bucketRootPath, XAttrKeyOSSVersioning, bytes)
+}
+
+// NewVersionId returns a new object version ID. Version IDs generated later sort
+// lexically before earlier ones, so a directory listing of the version archive
+// yields the newest version first.
+func NewVersionId() string {
+	random := make([]byte, 4)
+	_, _ = rand.Read(random)
+	ts := uint64(math.MaxUint64) - uint64(time.Now().UnixNano())
+	return fmt.Sprintf("%016x%s", ts, hex.EncodeToString(random))
+}
+
+func isValidVersionId(versionId string) bool {
+	if versionId == NullVersionId {
+		return true
+	}
+	if len(versionId) != 24 {
+		return false
+	}
+	_, err := hex.DecodeString(versionId)
+	return err == nil
+}
+
+// formatVersionId returns the version ID presented to client.
+func formatVersionId(versionId string) string {
+	if versionId == "" {
+		return NullVersionId
+	}
+	return versionId
+}
+
+// isReservedObjectKey reports whether the key points into the hidden version archive, replication queue
+// or restore directory.
+func isReservedObjectKey(key string) bool {
+	key = strings.TrimPrefix(key, pathSep)
+	if idx := strings.Index(key, pathSep); idx >= 0 {
+		key = key[:idx]
+	}
+	return proto.IsReservedName(key)
+}
+
+// splitVersionPath splits the path of an archived version into object key and version ID.
+func splitVersionPath(path string) (key, versionId string) {
+	idx := strings.LastIndex(path, pathSep)
+	if idx < 0 {
+		return "", path
+	}
+	return path[:idx], path[idx+1:]
+}
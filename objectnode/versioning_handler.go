@@ -0,0 +1,321 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// Get Bucket Versioning
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketVersioning.html
+func (o *ObjectNode) getBucketVersioningHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	param := ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("getBucketVersioningHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+
+	var config *VersioningConfiguration
+	if config, err = vol.metaLoader.loadVersioning(); err != nil {
+		log.LogErrorf("getBucketVersioningHandler: load versioning fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	// an empty configuration is returned if versioning has never been enabled
+	output := &VersioningConfiguration{XMLNS: S3Namespace}
+	if config != nil {
+		output.Status = config.Status
+		output.MfaDelete = config.MfaDelete
+	}
+	var data []byte
+	if data, err = MarshalXMLEntity(output); err != nil {
+		log.LogErrorf("getBucketVersioningHandler: xml marshal fail: requestID(%v) volume(%v) config(%+v) err(%v)",
+			GetRequestID(r), vol.Name(), output, err)
+		return
+	}
+
+	writeSuccessResponseXML(w, data)
+}
+
+// Put Bucket Versioning
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketVersioning.html
+func (o *ObjectNode) putBucketVersioningHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	param := ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("putBucketVersioningHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+
+	var body []byte
+	if body, err = io.ReadAll(io.LimitReader(r.Body, MaxVersioningConfigSize+1)); err != nil {
+		log.LogErrorf("putBucketVersioningHandler: read request body fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	if len(body) > MaxVersioningConfigSize {
+		errorCode = EntityTooLarge
+		return
+	}
+	var config *VersioningConfiguration
+	if config, err = ParseVersioningConfig(body); err != nil {
+		log.LogErrorf("putBucketVersioningHandler: parse versioning config fail: requestID(%v) volume(%v) config(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(body), err)
+		return
+	}
+	if !config.IsEnabled() {
+		var replication *proto.ReplicationConfiguration
+		if replication, err = vol.metaLoader.loadReplication(); err != nil {
+			log.LogErrorf("putBucketVersioningHandler: load replication fail: requestID(%v) volume(%v) err(%v)",
+				GetRequestID(r), vol.Name(), err)
+			return
+		}
+		if replication != nil {
+			errorCode = ReplicationConfigurationPresent
+			return
+		}
+	}
+	// the archive is created before the versioning is enabled, so that it's refused if the name
+	// is taken by the existing entry
+	if config.IsEnabled() {
+		if _, err = vol.reservedDir(versionArchiveDir, true); err != nil {
+			log.LogErrorf("putBucketVersioningHandler: make archive dir fail: requestID(%v) volume(%v) err(%v)",
+				GetRequestID(r), vol.Name(), err)
+			return
+		}
+	}
+	if body, err = json.Marshal(config); err != nil {
+		log.LogErrorf("putBucketVersioningHandler: json.Marshal versioning config fail: requestID(%v) volume(%v) config(%+v) err(%v)",
+			GetRequestID(r), vol.Name(), config, err)
+		return
+	}
+	if err = storeBucketVersioning(body, vol); err != nil {
+		log.LogErrorf("putBucketVersioningHandler: store versioning config fail: requestID(%v) volume(%v) config(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(body), err)
+		return
+	}
+	vol.metaLoader.storeVersioning(config)
+	log.LogInfof("Audit: putBucketVersioningHandler: requestID(%v) volume(%v) status(%v)",
+		GetRequestID(r), vol.Name(), config.Status)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// List Object Versions
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_ListObjectVersions.html
+func (o *ObjectNode) listObjectVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	param := ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("listObjectVersionsHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+
+	// QPS and Concurrency Limit
+	rateLimit := o.AcquireRateLimiter()
+	if err = rateLimit.AcquireLimitResource(vol.owner, param.apiName); err != nil {
+		return
+	}
+	defer rateLimit.ReleaseLimitResource(vol.owner, param.apiName)
+
+	// get options
+	prefix := r.URL.Query().Get(ParamPrefix)
+	delimiter := r.URL.Query().Get(ParamPartDelimiter)
+	keyMarker := r.URL.Query().Get(ParamKeyMarker)
+	versionIdMarker := r.URL.Query().Get(ParamVersionIdMarker)
+	maxKeys := r.URL.Query().Get(ParamMaxKeys)
+	encodingType := r.URL.Query().Get(ParamEncodingType)
+
+	maxKeysInt := uint64(MaxKeys)
+	if maxKeys != "" {
+		if maxKeysInt, err = strconv.ParseUint(maxKeys, 10, 16); err != nil {
+			log.LogErrorf("listObjectVersionsHandler: parse max keys fail: requestID(%v) volume(%v) maxKeys(%v) err(%v)",
+				GetRequestID(r), vol.Name(), maxKeys, err)
+			errorCode = InvalidArgument
+			return
+		}
+		if maxKeysInt > MaxKeys {
+			maxKeysInt = MaxKeys
+		}
+	}
+	if encodingType != "" && encodingType != "url" {
+		errorCode = InvalidArgument
+		return
+	}
+	// version id marker must be used with key marker
+	if versionIdMarker != "" && (keyMarker == "" || !isValidVersionId(versionIdMarker)) {
+		errorCode = InvalidArgument
+		return
+	}
+	if keyMarker != "" && prefix != "" && !strings.HasPrefix(keyMarker, prefix) {
+		errorCode = InvalidArgument
+		return
+	}
+
+	option := &ListVersionsOption{
+		Prefix:          prefix,
+		Delimiter:       delimiter,
+		KeyMarker:       keyMarker,
+		VersionIdMarker: versionIdMarker,
+		MaxKeys:         maxKeysInt,
+	}
+	var result *ListVersionsResult
+	if result, err = vol.ListObjectVersions(option); err != nil {
+		log.LogErrorf("listObjectVersionsHandler: list versions fail: requestID(%v) volume(%v) option(%+v) err(%v)",
+			GetRequestID(r), vol.Name(), option, err)
+		return
+	}
+
+	bucketOwner := NewBucketOwner(vol)
+	output := &ListVersionsResultXML{
+		XMLNS:               S3Namespace,
+		Name:                param.Bucket(),
+		Prefix:              encodeKey(prefix, encodingType),
+		KeyMarker:           encodeKey(keyMarker, encodingType),
+		VersionIdMarker:     versionIdMarker,
+		NextKeyMarker:       encodeKey(result.NextKeyMarker, encodingType),
+		NextVersionIdMarker: result.NextVersionIdMarker,
+		MaxKeys:             maxKeysInt,
+		Delimiter:           encodeKey(delimiter, encodingType),
+		EncodingType:        encodingType,
+		IsTruncated:         result.Truncated,
+	}
+	for _, version := range result.Versions {
+		if version.DeleteMarker {
+			output.DeleteMarkers = append(output.DeleteMarkers, &DeleteMarkerEntry{
+				Key:          encodeKey(version.Path, encodingType),
+				VersionId:    version.VersionId,
+				IsLatest:     version.IsLatest,
+				LastModified: formatTimeISO(version.ModifyTime),
+				Owner:        bucketOwner,
+			})
+			continue
+		}
+		if version.Mode == 0 {
+			// Invalid file mode, which means that the inode of the file may not exist.
+			log.LogWarnf("listObjectVersionsHandler: invalid file found: requestID(%v) volume(%v) path(%v) inode(%v)",
+				GetRequestID(r), vol.Name(), version.Path, version.Inode)
+			continue
+		}
+		output.Versions = append(output.Versions, &ObjectVersion{
+			Key:          encodeKey(version.Path, encodingType),
+			VersionId:    version.VersionId,
+			IsLatest:     version.IsLatest,
+			LastModified: formatTimeISO(version.ModifyTime),
+			ETag:         wrapUnescapedQuot(version.ETag),
+			Size:         int(version.Size),
+			StorageClass: StorageClassStandard,
+			Owner:        bucketOwner,
+		})
+	}
+	for _, prefix := range result.CommonPrefixes {
+		output.CommonPrefixes = append(output.CommonPrefixes, &CommonPrefix{
+			Prefix: encodeKey(prefix, encodingType),
+		})
+	}
+
+	var data []byte
+	if data, err = MarshalXMLEntity(output); err != nil {
+		log.LogErrorf("listObjectVersionsHandler: xml marshal result fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+
+	writeSuccessResponseXML(w, data)
+}
+
+// noSuchObjectError returns the error code for a missing object or object version.
+func noSuchObjectError(versionId string) *ErrorCode {
+	if versionId != "" {
+		return NoSuchVersion
+	}
+	return NoSuchKey
+}
+
+// checkDeleteMarker rejects reading a delete marker specified by version ID.
+func checkDeleteMarker(w http.ResponseWriter, info *FSFileInfo) *ErrorCode {
+	if !info.DeleteMarker {
+		return nil
+	}
+	w.Header().Set(XAmzDeleteMarker, "true")
+	w.Header().Set(XAmzVersionId, formatVersionId(info.VersionId))
+	w.Header().Set(LastModified, formatTimeRFC1123(info.ModifyTime))
+	return MethodNotAllowed
+}
+
+// setVersionIdHeader sets the version ID of object in response if the bucket is versioned.
+func setVersionIdHeader(w http.ResponseWriter, vol *Volume, versionId string) {
+	if versionId != "" || vol.loadVersioning().IsActive() {
+		w.Header().Set(XAmzVersionId, formatVersionId(versionId))
+	}
+}
+
+func NewDeleted(key, versionId string, result *DeleteObjectResult) Deleted {
+	deleted := Deleted{Key: key, VersionId: versionId}
+	if result != nil && result.DeleteMarker {
+		deleted.DeleteMarker = "true"
+		if versionId == "" {
+			deleted.DeleteMarkerVersionId = result.VersionId
+		}
+	}
+	return deleted
+}
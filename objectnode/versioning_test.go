@@ -0,0 +1,113 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVersioningConfig(t *testing.T) {
+	tests := []struct {
+		value          string
+		expectedStatus string
+		expectedErr    error
+	}{
+		{
+			value: `<VersioningConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+						<Status>Enabled</Status>
+					</VersioningConfiguration>`,
+			expectedStatus: VersioningEnabled,
+		},
+		{
+			value: `<VersioningConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+						<Status>Suspended</Status>
+						<MfaDelete>Disabled</MfaDelete>
+					</VersioningConfiguration>`,
+			expectedStatus: VersioningSuspended,
+		},
+		{
+			value: `<VersioningConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+						<Status>enabled</Status>
+					</VersioningConfiguration>`,
+			expectedErr: InvalidVersioningStatus,
+		},
+		{
+			value:       `<VersioningConfiguration></VersioningConfiguration>`,
+			expectedErr: InvalidVersioningStatus,
+		},
+		{
+			value: `<VersioningConfiguration>
+						<Status>Enabled</Status>
+						<MfaDelete>Enabled</MfaDelete>
+					</VersioningConfiguration>`,
+			expectedErr: InvalidVersioningStatus,
+		},
+		{
+			value:       `<VersioningConfiguration><Status>Enabled</Status>`,
+			expectedErr: MalformedXML,
+		},
+	}
+	for _, tt := range tests {
+		config, err := ParseVersioningConfig([]byte(tt.value))
+		require.Equal(t, tt.expectedErr, err)
+		if err == nil {
+			require.Equal(t, tt.expectedStatus, config.Status)
+			require.True(t, config.IsActive())
+			require.Equal(t, tt.expectedStatus == VersioningEnabled, config.IsEnabled())
+		}
+	}
+
+	var config *VersioningConfiguration
+	require.False(t, config.IsActive())
+	require.False(t, config.IsEnabled())
+}
+
+func TestNewVersionId(t *testing.T) {
+	prev := NewVersionId()
+	require.True(t, isValidVersionId(prev))
+	for i := 0; i < 100; i++ {
+		time.Sleep(time.Microsecond)
+		next := NewVersionId()
+		require.True(t, isValidVersionId(next))
+		// newer version sorts before older one
+		require.Less(t, next, prev)
+		// null version sorts after all generated versions
+		require.Less(t, next, NullVersionId)
+		prev = next
+	}
+
+	require.True(t, isValidVersionId(NullVersionId))
+	require.False(t, isValidVersionId(""))
+	require.False(t, isValidVersionId("abc"))
+	require.False(t, isValidVersionId("zzzzzzzzzzzzzzzzzzzzzzzz"))
+	require.Equal(t, NullVersionId, formatVersionId(""))
+}
+
+func TestVersionArchivePath(t *testing.T) {
+	require.True(t, isReservedObjectKey(versionArchiveDir))
+	require.True(t, isReservedObjectKey("/"+versionArchiveDir+"/a/b"))
+	require.False(t, isReservedObjectKey(versionArchiveDir+"x/a"))
+	require.False(t, isReservedObjectKey("a/"+versionArchiveDir))
+
+	path := versionArchivePath("a/b/c.txt", NullVersionId)
+	require.True(t, isReservedObjectKey(path))
+	key, versionId := splitVersionPath("a/b/c.txt/" + NullVersionId)
+	require.Equal(t, "a/b/c.txt", key)
+	require.Equal(t, NullVersionId, versionId)
+	require.Equal(t, []string{versionArchiveDir, "a", "b", "c.txt"}, versionArchiveDirs("a/b/c.txt"))
+}
@@ -0,0 +1,252 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/WebsiteHosting.html
+
+const (
+	MaxWebsiteConfigSize   = 128 << 10 // 128KB
+	MaxWebsiteRoutingRules = 50
+
+	WebsiteProtocolHTTP  = "http"
+	WebsiteProtocolHTTPS = "https"
+)
+
+// WebsiteConfiguration is the static website configuration of a bucket.
+type WebsiteConfiguration struct {
+	XMLNS                 string                `xml:"xmlns,attr,omitempty" json:"-"`
+	XMLName               xml.Name              `xml:"WebsiteConfiguration" json:"-"`
+	ErrorDocument         *WebsiteErrorDocument `xml:"ErrorDocument,omitempty" json:"error,omitempty"`
+	IndexDocument         *WebsiteIndexDocument `xml:"IndexDocument,omitempty" json:"index,omitempty"`
+	RedirectAllRequestsTo *WebsiteRedirectAll   `xml:"RedirectAllRequestsTo,omitempty" json:"redirect_all,omitempty"`
+	RoutingRules          []*WebsiteRoutingRule `xml:"RoutingRules>RoutingRule,omitempty" json:"rules,omitempty"`
+}
+
+type WebsiteErrorDocument struct {
+	Key string `xml:"Key" json:"key"`
+}
+
+type WebsiteIndexDocument struct {
+	Suffix string `xml:"Suffix" json:"suffix"`
+}
+
+type WebsiteRedirectAll struct {
+	HostName string `xml:"HostName" json:"host"`
+	Protocol string `xml:"Protocol,omitempty" json:"protocol,omitempty"`
+}
+
+type WebsiteRoutingRule struct {
+	Condition *WebsiteCondition `xml:"Condition,omitempty" json:"condition,omitempty"`
+	Redirect  *WebsiteRedirect  `xml:"Redirect" json:"redirect"`
+}
+
+type WebsiteCondition struct {
+	HttpErrorCodeReturnedEquals string `xml:"HttpErrorCodeReturnedEquals,omitempty" json:"error_code,omitempty"`
+	KeyPrefixEquals             string `xml:"KeyPrefixEquals,omitempty" json:"prefix,omitempty"`
+}
+
+type WebsiteRedirect struct {
+	HostName             string `xml:"HostName,omitempty" json:"host,omitempty"`
+	HttpRedirectCode     string `xml:"HttpRedirectCode,omitempty" json:"code,omitempty"`
+	Protocol             string `xml:"Protocol,omitempty" json:"protocol,omitempty"`
+	ReplaceKeyPrefixWith string `xml:"ReplaceKeyPrefixWith,omitempty" json:"replace_prefix,omitempty"`
+	ReplaceKeyWith       string `xml:"ReplaceKeyWith,omitempty" json:"replace_key,omitempty"`
+}
+
+func invalidWebsiteArgument(format string, args ...interface{}) *ErrorCode {
+	return NewError("InvalidArgument", fmt.Sprintf(format, args...), http.StatusBadRequest)
+}
+
+func validWebsiteProtocol(protocol string) bool {
+	return protocol == "" || protocol == WebsiteProtocolHTTP || protocol == WebsiteProtocolHTTPS
+}
+
+func (c *WebsiteConfiguration) validate() *ErrorCode {
+	if c.RedirectAllRequestsTo != nil {
+		if c.IndexDocument != nil || c.ErrorDocument != nil || len(c.RoutingRules) > 0 {
+			return invalidWebsiteArgument("RedirectAllRequestsTo cannot be provided in conjunction with other Routing/Redirect configurations.")
+		}
+		if c.RedirectAllRequestsTo.HostName == "" {
+			return invalidWebsiteArgument("The HostName of RedirectAllRequestsTo must not be empty.")
+		}
+		if !validWebsiteProtocol(c.RedirectAllRequestsTo.Protocol) {
+			return invalidWebsiteArgument("Invalid protocol, protocol can be http or https.")
+		}
+		return nil
+	}
+	if c.IndexDocument == nil {
+		return invalidWebsiteArgument("A value for IndexDocument Suffix must be provided if RedirectAllRequestsTo is empty")
+	}
+	if suffix := c.IndexDocument.Suffix; suffix == "" || strings.Contains(suffix, pathSep) {
+		return invalidWebsiteArgument("The IndexDocument Suffix is not well formed")
+	}
+	if c.ErrorDocument != nil && c.ErrorDocument.Key == "" {
+		return invalidWebsiteArgument("The ErrorDocument Key is not well formed")
+	}
+	if len(c.RoutingRules) > MaxWebsiteRoutingRules {
+		return invalidWebsiteArgument("The number of routing rules must not exceed %v.", MaxWebsiteRoutingRules)
+	}
+	for _, rule := range c.RoutingRules {
+		if rule.Redirect == nil {
+			return invalidWebsiteArgument("A Redirect must be provided for each routing rule.")
+		}
+		if rule.Condition != nil && rule.Condition.HttpErrorCodeReturnedEquals != "" {
+			code, err := strconv.Atoi(rule.Condition.HttpErrorCodeReturnedEquals)
+			if err != nil || code < 400 || code > 599 {
+				return invalidWebsiteArgument("The provided HTTP error code (%v) is not valid. Valid codes are 4XX or 5XX.",
+					rule.Condition.HttpErrorCodeReturnedEquals)
+			}
+		}
+		redirect := rule.Redirect
+		if redirect.ReplaceKeyPrefixWith != "" && redirect.ReplaceKeyWith != "" {
+			return invalidWebsiteArgument("You can only define ReplaceKeyPrefix or ReplaceKey but not both.")
+		}
+		if redirect.HttpRedirectCode != "" {
+			code, err := strconv.Atoi(redirect.HttpRedirectCode)
+			if err != nil || code < 300 || code > 399 || code == http.StatusNotModified {
+				return invalidWebsiteArgument("The provided HTTP redirect code (%v) is not valid. Valid codes are 3XX except 300 and 304.",
+					redirect.HttpRedirectCode)
+			}
+		}
+		if !validWebsiteProtocol(redirect.Protocol) {
+			return invalidWebsiteArgument("Invalid protocol, protocol can be http or https.")
+		}
+	}
+	return nil
+}
+
+// ParseWebsiteConfig parses the website configuration from request body.
+func ParseWebsiteConfig(body []byte) (config *WebsiteConfiguration, err error) {
+	config = &WebsiteConfiguration{}
+	if err = UnmarshalXMLEntity(body, config); err != nil {
+		return nil, MalformedXML
+	}
+	if ec := config.validate(); ec != nil {
+		return nil, ec
+	}
+	return config, nil
+}
+
+func storeBucketWebsite(bytes []byte, vol *Volume) (err error) {
+	return vol.store.Put(vol.name, bucketRootPath, XAttrKeyOSSWebsite, bytes)
+}
+
+func deleteBucketWebsite(vol *Volume) (err error) {
+	return vol.store.Delete(vol.name, bucketRootPath, XAttrKeyOSSWebsite)
+}
+
+// IndexKey returns the key of the index document if the key refers to a folder.
+func (c *WebsiteConfiguration) IndexKey(key string) string {
+	if c.IndexDocument != nil && (key == "" || strings.HasSuffix(key, pathSep)) {
+		return key + c.IndexDocument.Suffix
+	}
+	return key
+}
+
+// MatchRoutingRule returns the first routing rule matching the key, the status code is zero if the
+// request is not failed, in which case the rules conditioned by error code are never matched.
+func (c *WebsiteConfiguration) MatchRoutingRule(key string, statusCode int) *WebsiteRoutingRule {
+	for _, rule := range c.RoutingRules {
+		if cond := rule.Condition; cond != nil {
+			if cond.KeyPrefixEquals != "" && !strings.HasPrefix(key, cond.KeyPrefixEquals) {
+				continue
+			}
+			if cond.HttpErrorCodeReturnedEquals != "" &&
+				cond.HttpErrorCodeReturnedEquals != strconv.Itoa(statusCode) {
+				continue
+			}
+		}
+		return rule
+	}
+	return nil
+}
+
+func websiteRequestProtocol(r *http.Request) string {
+	if proto := r.Header.Get(XForwardedProto); proto != "" {
+		return proto
+	}
+	if r.TLS != nil {
+		return WebsiteProtocolHTTPS
+	}
+	return WebsiteProtocolHTTP
+}
+
+func websiteLocation(protocol, host, key string) string {
+	u := url.URL{Scheme: protocol, Host: host, Path: pathSep + key}
+	return u.String()
+}
+
+// Location returns the location of the request redirected to.
+func (a *WebsiteRedirectAll) Location(r *http.Request, key string) string {
+	protocol := a.Protocol
+	if protocol == "" {
+		protocol = websiteRequestProtocol(r)
+	}
+	return websiteLocation(protocol, a.HostName, key)
+}
+
+// Location returns the location and status code of the request redirected by the rule.
+func (rule *WebsiteRoutingRule) Location(r *http.Request, key string) (location string, code int) {
+	redirect := rule.Redirect
+	protocol, host := redirect.Protocol, redirect.HostName
+	if protocol == "" {
+		protocol = websiteRequestProtocol(r)
+	}
+	if host == "" {
+		host = r.Host
+	}
+	switch {
+	case redirect.ReplaceKeyWith != "":
+		key = redirect.ReplaceKeyWith
+	case redirect.ReplaceKeyPrefixWith != "":
+		prefix := ""
+		if rule.Condition != nil {
+			prefix = rule.Condition.KeyPrefixEquals
+		}
+		key = redirect.ReplaceKeyPrefixWith + strings.TrimPrefix(key, prefix)
+	}
+	code = http.StatusMovedPermanently
+	if redirect.HttpRedirectCode != "" {
+		code, _ = strconv.Atoi(redirect.HttpRedirectCode)
+	}
+	return websiteLocation(protocol, host, key), code
+}
+
+// writeWebsiteError writes the error of website request as an HTML page, like browsers expect.
+func writeWebsiteError(w http.ResponseWriter, r *http.Request, ec *ErrorCode) {
+	SetResponseStatusCode(r, strconv.Itoa(ec.StatusCode))
+	SetResponseErrorMessage(r, ec.ErrorMessage)
+
+	title := fmt.Sprintf("%d %s", ec.StatusCode, http.StatusText(ec.StatusCode))
+	body := fmt.Sprintf("<html>\n<head><title>%s</title></head>\n<body>\n<h1>%s</h1>\n<ul>\n"+
+		"<li>Code: %s</li>\n<li>Message: %s</li>\n<li>RequestId: %s</li>\n</ul>\n</body>\n</html>\n",
+		title, title, html.EscapeString(ec.ErrorCode), html.EscapeString(ec.ErrorMessage), GetRequestID(r))
+	w.Header().Set(ContentType, ValueContentTypeHTML)
+	w.Header().Set(ContentLength, strconv.Itoa(len(body)))
+	w.WriteHeader(ec.StatusCode)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write([]byte(body))
+	}
+}
@@ -0,0 +1,319 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cubefs/cubefs/util/log"
+	"github.com/gorilla/mux"
+)
+
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketWebsite.html
+func (o *ObjectNode) getBucketWebsiteHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	param := ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("getBucketWebsiteHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+
+	var config *WebsiteConfiguration
+	if config, err = vol.metaLoader.loadWebsite(); err != nil {
+		log.LogErrorf("getBucketWebsiteHandler: load website fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	if config == nil {
+		errorCode = NoSuchWebsiteConfiguration
+		return
+	}
+	output := *config
+	output.XMLNS = S3Namespace
+	var data []byte
+	if data, err = MarshalXMLEntity(&output); err != nil {
+		log.LogErrorf("getBucketWebsiteHandler: xml marshal fail: requestID(%v) volume(%v) config(%+v) err(%v)",
+			GetRequestID(r), vol.Name(), config, err)
+		return
+	}
+
+	writeSuccessResponseXML(w, data)
+}
+
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketWebsite.html
+func (o *ObjectNode) putBucketWebsiteHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	param := ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("putBucketWebsiteHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+	var body []byte
+	if body, err = io.ReadAll(io.LimitReader(r.Body, MaxWebsiteConfigSize+1)); err != nil {
+		log.LogErrorf("putBucketWebsiteHandler: read request body fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	if len(body) > MaxWebsiteConfigSize {
+		errorCode = EntityTooLarge
+		return
+	}
+	var config *WebsiteConfiguration
+	if config, err = ParseWebsiteConfig(body); err != nil {
+		log.LogErrorf("putBucketWebsiteHandler: parse website config fail: requestID(%v) volume(%v) config(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(body), err)
+		return
+	}
+	var data []byte
+	if data, err = json.Marshal(config); err != nil {
+		log.LogErrorf("putBucketWebsiteHandler: json marshal fail: requestID(%v) volume(%v) config(%+v) err(%v)",
+			GetRequestID(r), vol.Name(), config, err)
+		return
+	}
+	if err = storeBucketWebsite(data, vol); err != nil {
+		log.LogErrorf("putBucketWebsiteHandler: store website config fail: requestID(%v) volume(%v) config(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(data), err)
+		return
+	}
+	vol.metaLoader.storeWebsite(config)
+
+	log.LogInfof("Audit: putBucketWebsiteHandler: requestID(%v) volume(%v) config(%v)",
+		GetRequestID(r), vol.Name(), string(data))
+}
+
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketWebsite.html
+func (o *ObjectNode) deleteBucketWebsiteHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	param := ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("deleteBucketWebsiteHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+	if err = deleteBucketWebsite(vol); err != nil {
+		log.LogErrorf("deleteBucketWebsiteHandler: delete website config fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	vol.metaLoader.storeWebsite(nil)
+
+	log.LogInfof("Audit: deleteBucketWebsiteHandler: requestID(%v) volume(%v)", GetRequestID(r), vol.Name())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func websiteErrorCode(err error) *ErrorCode {
+	if ec, ok := err.(*ErrorCode); ok {
+		return ec
+	}
+	return InternalErrorCode(err)
+}
+
+// websiteHandler serves the requests of website endpoint, the objects are read according to
+// the website configuration of bucket, and are checked against the bucket policy and ACL.
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/WebsiteEndpoints.html
+func (o *ObjectNode) websiteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeWebsiteError(w, r, MethodNotAllowed)
+		return
+	}
+
+	param := ParseRequestParam(r)
+	vol, err := o.getVol(param.Bucket())
+	if err != nil {
+		log.LogErrorf("websiteHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		writeWebsiteError(w, r, websiteErrorCode(err))
+		return
+	}
+	config, err := vol.metaLoader.loadWebsite()
+	if err != nil {
+		log.LogErrorf("websiteHandler: load website fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		writeWebsiteError(w, r, InternalErrorCode(err))
+		return
+	}
+	if config == nil {
+		writeWebsiteError(w, r, NoSuchWebsiteConfiguration)
+		return
+	}
+
+	key := param.Object()
+	if config.RedirectAllRequestsTo != nil {
+		http.Redirect(w, r, config.RedirectAllRequestsTo.Location(r, key), http.StatusMovedPermanently)
+		return
+	}
+	if rule := config.MatchRoutingRule(key, 0); rule != nil {
+		location, code := rule.Location(r, key)
+		http.Redirect(w, r, location, code)
+		return
+	}
+
+	ec := o.serveWebsiteObject(w, r, vol, config, key)
+	if ec == nil {
+		return
+	}
+	if rule := config.MatchRoutingRule(key, ec.StatusCode); rule != nil {
+		location, code := rule.Location(r, key)
+		http.Redirect(w, r, location, code)
+		return
+	}
+	if config.ErrorDocument != nil && o.serveWebsiteErrorDocument(w, r, vol, config.ErrorDocument.Key, ec.StatusCode) {
+		return
+	}
+	writeWebsiteError(w, r, ec)
+}
+
+// websiteObject checks the permission of anonymous GET on the key and returns its meta.
+func (o *ObjectNode) websiteObject(r *http.Request, vol *Volume, key string) (*FSFileInfo, *ErrorCode) {
+	mux.Vars(r)[ContextKeyObject] = key
+	allowed, ec, err := o.checkPolicy(r)
+	if !allowed {
+		if ec != nil {
+			return nil, ec
+		}
+		if err != nil {
+			return nil, websiteErrorCode(err)
+		}
+		return nil, AccessDenied
+	}
+	info, _, err := vol.ObjectVersionMeta(key, "")
+	if err == syscall.ENOENT || (err == nil && (info.Mode.IsDir() || info.DeleteMarker)) {
+		return nil, NoSuchKey
+	}
+	if err != nil {
+		log.LogErrorf("websiteHandler: get object meta fail: requestID(%v) volume(%v) key(%v) err(%v)",
+			GetRequestID(r), vol.Name(), key, err)
+		return nil, InternalErrorCode(err)
+	}
+	return info, nil
+}
+
+// serveWebsiteObject serves the object of key, or the index document if the key refers to a folder.
+func (o *ObjectNode) serveWebsiteObject(w http.ResponseWriter, r *http.Request, vol *Volume,
+	config *WebsiteConfiguration, key string) *ErrorCode {
+	objectKey := config.IndexKey(key)
+	_, ec := o.websiteObject(r, vol, objectKey)
+	if ec == NoSuchKey && key != "" && !strings.HasSuffix(key, pathSep) {
+		// the key without trailing slash may refer to a folder which has index document
+		if _, ec1 := o.websiteObject(r, vol, config.IndexKey(key+pathSep)); ec1 == nil {
+			http.Redirect(w, r, pathSep+key+pathSep, http.StatusFound)
+			return nil
+		}
+	}
+	if ec != nil {
+		return ec
+	}
+
+	mux.Vars(r)[ContextKeyObject] = objectKey
+	dropWebsiteQuery(r)
+	if r.Method == http.MethodHead {
+		o.headObjectHandler(w, r)
+	} else {
+		o.getObjectHandler(w, r)
+	}
+	return nil
+}
+
+// dropWebsiteQuery drops the query of the anonymous request delegated to the object handlers, as
+// the website endpoint serves the current version of object as is, without the parameters of REST
+// API such as versionId and response-* overrides.
+func dropWebsiteQuery(r *http.Request) {
+	r.URL.RawQuery = ""
+	r.Form, r.PostForm = nil, nil
+}
+
+// serveWebsiteErrorDocument serves the error document with the status code of failed request,
+// false is returned if the error document cannot be served.
+func (o *ObjectNode) serveWebsiteErrorDocument(w http.ResponseWriter, r *http.Request, vol *Volume,
+	key string, statusCode int) bool {
+	info, ec := o.websiteObject(r, vol, key)
+	if ec != nil {
+		log.LogWarnf("websiteHandler: error document unavailable: requestID(%v) volume(%v) key(%v) err(%v)",
+			GetRequestID(r), vol.Name(), key, ec)
+		return false
+	}
+	if err := o.unsealObjectEncryption(r, info.Encryption, false); err != nil {
+		log.LogWarnf("websiteHandler: unseal error document fail: requestID(%v) volume(%v) key(%v) err(%v)",
+			GetRequestID(r), vol.Name(), key, err)
+		return false
+	}
+
+	SetResponseStatusCode(r, strconv.Itoa(statusCode))
+	if len(info.MIMEType) > 0 {
+		w.Header().Set(ContentType, info.MIMEType)
+	} else {
+		w.Header().Set(ContentType, ValueContentTypeHTML)
+	}
+	w.Header().Set(ContentLength, strconv.FormatInt(info.Size, 10))
+	w.WriteHeader(statusCode)
+	if r.Method == http.MethodHead {
+		return true
+	}
+
+	var writer io.Writer = w
+	if info.Encryption != nil {
+		writer = info.Encryption.DecryptWriter(w, 0)
+	}
+	readIno, readStorageClass := info.readSource(time.Now())
+	if err := vol.readFile(readIno, uint64(info.Size), key, writer, 0, uint64(info.Size), readStorageClass); err != nil {
+		log.LogErrorf("websiteHandler: read error document fail: requestID(%v) volume(%v) key(%v) err(%v)",
+			GetRequestID(r), vol.Name(), key, err)
+	}
+	return true
+}
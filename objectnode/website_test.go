@@ -0,0 +1,153 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWebsiteConfig(t *testing.T) {
+	config, err := ParseWebsiteConfig([]byte(`
+<WebsiteConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <IndexDocument><Suffix>index.html</Suffix></IndexDocument>
+  <ErrorDocument><Key>error.html</Key></ErrorDocument>
+  <RoutingRules>
+    <RoutingRule>
+      <Condition><KeyPrefixEquals>docs/</KeyPrefixEquals></Condition>
+      <Redirect><ReplaceKeyPrefixWith>documents/</ReplaceKeyPrefixWith></Redirect>
+    </RoutingRule>
+    <RoutingRule>
+      <Condition><HttpErrorCodeReturnedEquals>404</HttpErrorCodeReturnedEquals></Condition>
+      <Redirect><HostName>example.com</HostName><HttpRedirectCode>302</HttpRedirectCode></Redirect>
+    </RoutingRule>
+  </RoutingRules>
+</WebsiteConfiguration>`))
+	require.NoError(t, err)
+	require.Equal(t, "index.html", config.IndexDocument.Suffix)
+	require.Equal(t, "error.html", config.ErrorDocument.Key)
+	require.Len(t, config.RoutingRules, 2)
+	require.Equal(t, "documents/", config.RoutingRules[0].Redirect.ReplaceKeyPrefixWith)
+
+	// the config is stored as json and loaded back without loss
+	data, err := json.Marshal(config)
+	require.NoError(t, err)
+	loaded := &WebsiteConfiguration{}
+	require.NoError(t, json.Unmarshal(data, loaded))
+	require.Equal(t, config.RoutingRules, loaded.RoutingRules)
+
+	config, err = ParseWebsiteConfig([]byte(`<WebsiteConfiguration>
+<RedirectAllRequestsTo><HostName>example.com</HostName><Protocol>https</Protocol></RedirectAllRequestsTo>
+</WebsiteConfiguration>`))
+	require.NoError(t, err)
+	require.Equal(t, "example.com", config.RedirectAllRequestsTo.HostName)
+
+	_, err = ParseWebsiteConfig([]byte(`<WebsiteConfiguration><IndexDocument>`))
+	require.Equal(t, MalformedXML, err)
+	for _, body := range []string{
+		`<WebsiteConfiguration></WebsiteConfiguration>`,
+		`<WebsiteConfiguration><IndexDocument><Suffix>a/index.html</Suffix></IndexDocument></WebsiteConfiguration>`,
+		`<WebsiteConfiguration><IndexDocument><Suffix>index.html</Suffix></IndexDocument>
+<RedirectAllRequestsTo><HostName>example.com</HostName></RedirectAllRequestsTo></WebsiteConfiguration>`,
+		`<WebsiteConfiguration><RedirectAllRequestsTo><HostName>example.com</HostName><Protocol>ftp</Protocol>
+</RedirectAllRequestsTo></WebsiteConfiguration>`,
+		`<WebsiteConfiguration><IndexDocument><Suffix>index.html</Suffix></IndexDocument><RoutingRules><RoutingRule>
+<Condition><HttpErrorCodeReturnedEquals>200</HttpErrorCodeReturnedEquals></Condition>
+<Redirect><HostName>example.com</HostName></Redirect></RoutingRule></RoutingRules></WebsiteConfiguration>`,
+		`<WebsiteConfiguration><IndexDocument><Suffix>index.html</Suffix></IndexDocument><RoutingRules><RoutingRule>
+<Redirect><HttpRedirectCode>304</HttpRedirectCode></Redirect></RoutingRule></RoutingRules></WebsiteConfiguration>`,
+		`<WebsiteConfiguration><IndexDocument><Suffix>index.html</Suffix></IndexDocument><RoutingRules><RoutingRule>
+<Redirect><ReplaceKeyWith>a</ReplaceKeyWith><ReplaceKeyPrefixWith>b</ReplaceKeyPrefixWith></Redirect>
+</RoutingRule></RoutingRules></WebsiteConfiguration>`,
+	} {
+		_, err = ParseWebsiteConfig([]byte(body))
+		ec, ok := err.(*ErrorCode)
+		require.True(t, ok, body)
+		require.Equal(t, "InvalidArgument", ec.ErrorCode, body)
+	}
+}
+
+func TestWebsiteRouting(t *testing.T) {
+	config := &WebsiteConfiguration{
+		IndexDocument: &WebsiteIndexDocument{Suffix: "index.html"},
+		RoutingRules: []*WebsiteRoutingRule{
+			{
+				Condition: &WebsiteCondition{KeyPrefixEquals: "docs/"},
+				Redirect:  &WebsiteRedirect{ReplaceKeyPrefixWith: "documents/"},
+			},
+			{
+				Condition: &WebsiteCondition{HttpErrorCodeReturnedEquals: "404"},
+				Redirect:  &WebsiteRedirect{HostName: "example.com", Protocol: "https", HttpRedirectCode: "302", ReplaceKeyWith: "404.html"},
+			},
+		},
+	}
+	require.Equal(t, "index.html", config.IndexKey(""))
+	require.Equal(t, "a/b/index.html", config.IndexKey("a/b/"))
+	require.Equal(t, "a/b", config.IndexKey("a/b"))
+
+	require.Nil(t, config.MatchRoutingRule("a.html", 0))
+	require.Nil(t, config.MatchRoutingRule("a.html", http.StatusForbidden))
+	rule := config.MatchRoutingRule("a.html", http.StatusNotFound)
+	require.Equal(t, config.RoutingRules[1], rule)
+
+	r := httptest.NewRequest(http.MethodGet, "http://bucket.website.cube.io/a.html", nil)
+	location, code := rule.Location(r, "a.html")
+	require.Equal(t, "https://example.com/404.html", location)
+	require.Equal(t, http.StatusFound, code)
+
+	rule = config.MatchRoutingRule("docs/a/b.html", 0)
+	require.Equal(t, config.RoutingRules[0], rule)
+	location, code = rule.Location(r, "docs/a/b.html")
+	require.Equal(t, "http://bucket.website.cube.io/documents/a/b.html", location)
+	require.Equal(t, http.StatusMovedPermanently, code)
+
+	r.Header.Set(XForwardedProto, "https")
+	all := &WebsiteRedirectAll{HostName: "example.com"}
+	require.Equal(t, "https://example.com/a.html", all.Location(r, "a.html"))
+	all.Protocol = "http"
+	require.Equal(t, "http://example.com/a.html", all.Location(r, "a.html"))
+}
+
+func TestWriteWebsiteError(t *testing.T) {
+	r := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "http://bucket.website.cube.io/a.html", nil), map[string]string{})
+	w := httptest.NewRecorder()
+	writeWebsiteError(w, r, NoSuchKey)
+	require.Equal(t, http.StatusNotFound, w.Code)
+	require.Equal(t, ValueContentTypeHTML, w.Header().Get(ContentType))
+	require.True(t, strings.Contains(w.Body.String(), "<li>Code: NoSuchKey</li>"))
+
+	r = mux.SetURLVars(httptest.NewRequest(http.MethodHead, "http://bucket.website.cube.io/a.html", nil), map[string]string{})
+	w = httptest.NewRecorder()
+	writeWebsiteError(w, r, AccessDenied)
+	require.Equal(t, http.StatusForbidden, w.Code)
+	require.Empty(t, w.Body.String())
+}
+
+func TestDropWebsiteQuery(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet,
+		"http://bucket.website.cube.io/a.html?versionId=v1&response-content-type=text/plain", nil)
+	require.Equal(t, "v1", r.FormValue(ParamVersionId))
+	dropWebsiteQuery(r)
+	require.Empty(t, r.URL.Query())
+	require.Empty(t, r.FormValue(ParamVersionId))
+	require.Empty(t, r.FormValue("response-content-type"))
+	require.Equal(t, "/a.html", r.URL.Path)
+}
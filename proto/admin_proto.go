@@ -51,6 +51,7 @@ const (
 	AdminCheckReplicaMeta                     = "/dataPartition/checkReplicaMeta"
 	AdminRecoverReplicaMeta                   = "/dataPartition/recoverReplicaMeta"
 	AdminRecoverBackupDataReplica             = "/dataPartition/recoverBackupDataReplica"
+	AdminReportBadDataReplica                 = "/dataPartition/reportBadReplica"
 	AdminDeleteDataReplica                    = "/dataReplica/delete"
 	AdminAddDataReplica                       = "/dataReplica/add"
 	AdminDeleteVol                            = "/vol/delete"
@@ -58,6 +59,8 @@ const (
 	AdminVolShrink                            = "/vol/shrink"
 	AdminVolExpand                            = "/vol/expand"
 	AdminVolForbidden                         = "/vol/forbidden"
+	AdminVolClone                             = "/vol/clone"
+	AdminVolTrash                             = "/vol/trash"
 	AdminVolEnableAuditLog                    = "/vol/auditlog"
 	AdminVolSetDpRepairBlockSize              = "/vol/setDpRepairBlockSize"
 	AdminCreateVol                            = "/admin/createVol"
@@ -110,6 +113,7 @@ const (
 	AdminGetAllLcNodeInfo = "/admin/getAllLcNodeInfo"
 
 	AdminLcNode = "/admin/lcnode"
+	AdminFsck   = "/admin/fsck"
 
 	AdminUpdateDecommissionDiskLimit = "/admin/updateDecommissionDiskLimit"
 	AdminEnableAutoDecommissionDisk  = "/admin/enableAutoDecommissionDisk"
@@ -272,6 +276,11 @@ const (
 	S3QoSDelete                  = "/s3/qos/delete"
 	AdminEnablePersistAccessTime = "/vol/enablePersistAccessTime"
 
+	// s3 public access block of the cluster
+	S3PublicAccessBlockSet    = "/s3/publicAccessBlock/set"
+	S3PublicAccessBlockGet    = "/s3/publicAccessBlock/get"
+	S3PublicAccessBlockDelete = "/s3/publicAccessBlock/delete"
+
 	AdminVolAddAllowedStorageClass = "/vol/addAllowedStorageClass"
 )
 
@@ -286,6 +295,7 @@ var GApiInfo map[string]string = map[string]string{
 	"admincreatepreloaddatapartition":    AdminCreatePreLoadDataPartition,
 	"admindecommissiondatapartition":     AdminDecommissionDataPartition,
 	"admindiagnosedatapartition":         AdminDiagnoseDataPartition,
+	"adminreportbaddatareplica":          AdminReportBadDataReplica,
 	"admindeletedatareplica":             AdminDeleteDataReplica,
 	"adminadddatareplica":                AdminAddDataReplica,
 	"admindeletevol":                     AdminDeleteVol,
@@ -384,13 +394,15 @@ var GApiInfo map[string]string = map[string]string{
 }
 
 const (
-	MetaFollowerReadKey    = "metaFollowerRead"
-	LeaderRetryTimeoutKey  = "leaderRetryTimeout"
-	VolEnableDirectRead    = "directRead"
-	HostKey                = "host"
-	ClientVerKey           = "clientVer"
-	RoleKey                = "role"
-	BcacheOnlyForNotSSDKey = "enableBcacheNotSSD"
+	MetaFollowerReadKey      = "metaFollowerRead"
+	MetaFollowerReadIndexKey = "metaFollowerReadIndex"
+	MetaStoreModeKey         = "metaStoreMode"
+	LeaderRetryTimeoutKey    = "leaderRetryTimeout"
+	VolEnableDirectRead      = "directRead"
+	HostKey                  = "host"
+	ClientVerKey             = "clientVer"
+	RoleKey                  = "role"
+	BcacheOnlyForNotSSDKey   = "enableBcacheNotSSD"
 )
 
 // const TimeFormat = "2006-01-02 15:04:05"
@@ -632,6 +644,8 @@ type DataPartitionDecommissionRequest struct {
 }
 
 // AddDataPartitionRaftMemberRequest defines the request of add raftMember a data partition.
+// The peer is added as a learner if AddPeer.IsLearner is set, and the learner added is promoted to
+// a voter by the request without it.
 type AddDataPartitionRaftMemberRequest struct {
 	PartitionId uint64
 	AddPeer     Peer
@@ -646,6 +660,8 @@ type RemoveDataPartitionRaftMemberRequest struct {
 }
 
 // AddMetaPartitionRaftMemberRequest defines the request of add raftMember a meta partition.
+// The peer is added as a learner if AddPeer.IsLearner is set, and the learner added is promoted to
+// a voter by the request without it.
 type AddMetaPartitionRaftMemberRequest struct {
 	PartitionId uint64
 	AddPeer     Peer
@@ -673,6 +689,18 @@ type LoadDataPartitionResponse struct {
 	VolName           string
 }
 
+// RepairExtentBlocksRequest asks the replica to repair the blocks of the extent failing the crc
+// check in the range from the other replicas. If BlockCrcs is set, the blocks are checked against
+// them instead of the crcs stored by the replica, BlockCrcs[i] being the crc expected for the i-th
+// block from Offset.
+type RepairExtentBlocksRequest struct {
+	PartitionId uint64
+	ExtentId    uint64
+	Offset      int64
+	Size        int64
+	BlockCrcs   []uint32 `json:",omitempty"`
+}
+
 type StopDataPartitionRepairRequest struct {
 	PartitionId uint64
 	Stop        bool
@@ -868,6 +896,7 @@ type MetaPartitionReport struct {
 	End                       uint64
 	Status                    int
 	Size                      uint64
+	LogicalSize               uint64 // the sum of the extent keys and inline data of the files
 	MaxInodeID                uint64
 	IsLeader                  bool
 	VolName                   string
@@ -883,6 +912,7 @@ type MetaPartitionReport struct {
 	StatByStorageClass        []*StatOfStorageClass
 	StatByMigrateStorageClass []*StatOfStorageClass
 	LocalPeers                []Peer
+	RootDirStat               *DirStat // the recursive stats of the root directory if it's in the partition
 }
 
 // MetaNodeHeartbeatResponse defines the response to the meta node heartbeat request.
@@ -904,6 +934,9 @@ type LcNodeHeartbeatResponse struct {
 	LcTaskCountLimit      int
 	LcScanningTasks       map[string]*LcNodeRuleTaskResponse
 	SnapshotScanningTasks map[string]*SnapshotVerDelTaskResponse
+	FsckScanningTasks     map[string]*FsckTaskResponse
+	CloneScanningTasks    map[string]*VolCloneTaskResponse
+	TrashScanningTasks    map[string]*TrashTaskResponse
 }
 
 // DeleteFileRequest defines the request to delete a file.
@@ -995,6 +1028,7 @@ type DataPartitionResponse struct {
 	PartitionTTL  int64
 	IsDiscard     bool
 	MediaType     uint32
+	CloneSource   string `json:",omitempty"` // the source volume owning the partition shared with the clone
 }
 
 // DataPartitionsView defines the view of a data partition
@@ -1172,8 +1206,9 @@ func NewLimitRsp2Client() *LimitRsp2Client {
 }
 
 type UidSimpleInfo struct {
-	UID     uint32
+	UID     uint32 // the gid for QuotaOwnerGroup
 	Limited bool
+	Type    QuotaOwnerType
 }
 
 // SimpleVolView defines the simple view of a volume
@@ -1194,6 +1229,7 @@ type SimpleVolView struct {
 	DpCnt                   int
 	FollowerRead            bool
 	MetaFollowerRead        bool
+	MetaFollowerReadIndex   bool
 	DirectRead              bool
 	NeedToLowerReplica      bool
 	Authenticate            bool
@@ -1206,6 +1242,7 @@ type SimpleVolView struct {
 	EnableToken             bool
 	EnablePosixAcl          bool
 	EnableQuota             bool
+	MetaStoreMode           string
 	EnableTransactionV1     string
 	EnableTransaction       string
 	TxTimeout               int64
@@ -1249,6 +1286,17 @@ type SimpleVolView struct {
 	CacheDpStorageClass      uint32
 	ForbidWriteOpOfProtoVer0 bool
 	QuotaOfStorageClass      []*StatOfStorageClass
+
+	// writable clone
+	CloneSource string
+	CloneVer    uint64
+	CloneStatus uint8
+	Clones      []string
+
+	// the files not larger than the threshold are stored in the inode, 0 to disable
+	InlineDataThreshold uint32
+	// the encoding the client compresses the blocks of the extents with, empty to disable
+	Compression string
 }
 
 type NodeSetInfo struct {
@@ -0,0 +1,73 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+
+	"github.com/cubefs/cubefs/util"
+)
+
+// BlockCrcMismatchError is returned if the data of the block read from the replica doesn't match
+// the crc stored with it.
+type BlockCrcMismatchError struct {
+	BlockNo  int64
+	Expected uint32
+	Actual   uint32
+}
+
+func (e *BlockCrcMismatchError) Error() string {
+	return fmt.Sprintf("block(%v) crc mismatch, expected(%v) actual(%v)", e.BlockNo, e.Expected, e.Actual)
+}
+
+// MarshalBlockCrcs encodes the crc of the blocks to carry in the Arg of the read reply.
+func MarshalBlockCrcs(crcs []uint32) []byte {
+	data := make([]byte, len(crcs)*util.PerBlockCrcSize)
+	for i, crc := range crcs {
+		binary.BigEndian.PutUint32(data[i*util.PerBlockCrcSize:], crc)
+	}
+	return data
+}
+
+// UnmarshalBlockCrcs decodes the crc of the blocks carried in the Arg of the read reply.
+func UnmarshalBlockCrcs(data []byte) []uint32 {
+	crcs := make([]uint32, len(data)/util.PerBlockCrcSize)
+	for i := range crcs {
+		crcs[i] = binary.BigEndian.Uint32(data[i*util.PerBlockCrcSize:])
+	}
+	return crcs
+}
+
+// VerifyBlockCrcs checks the data read from the extent offset by the crc of the blocks it
+// overlaps, the first of which is the block containing the offset. The blocks not fully in the
+// data, and the blocks whose crc is 0 as it's unknown, are skipped.
+func VerifyBlockCrcs(extentOffset int64, data []byte, crcs []uint32) error {
+	firstBlockNo := extentOffset / util.BlockSize
+	end := extentOffset + int64(len(data))
+	for i, crc := range crcs {
+		blockNo := firstBlockNo + int64(i)
+		blockOffset := blockNo * util.BlockSize
+		if crc == 0 || blockOffset < extentOffset || blockOffset+util.BlockSize > end {
+			continue
+		}
+		start := blockOffset - extentOffset
+		if actual := crc32.ChecksumIEEE(data[start : start+util.BlockSize]); actual != crc {
+			return &BlockCrcMismatchError{BlockNo: blockNo, Expected: crc, Actual: actual}
+		}
+	}
+	return nil
+}
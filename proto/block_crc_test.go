@@ -0,0 +1,45 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import (
+	"hash/crc32"
+	"testing"
+
+	"github.com/cubefs/cubefs/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockCrcs(t *testing.T) {
+	data := make([]byte, 2*util.BlockSize)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	crcs := []uint32{
+		crc32.ChecksumIEEE(data[:util.BlockSize]),
+		crc32.ChecksumIEEE(data[util.BlockSize:]),
+	}
+	require.Equal(t, crcs, UnmarshalBlockCrcs(MarshalBlockCrcs(crcs)))
+
+	require.NoError(t, VerifyBlockCrcs(util.BlockSize, data, crcs))
+	// the blocks not fully read are skipped
+	require.NoError(t, VerifyBlockCrcs(util.BlockSize+1, data[1:], crcs))
+
+	data[util.BlockSize] ^= 0xff
+	err := VerifyBlockCrcs(util.BlockSize, data, crcs)
+	require.Equal(t, &BlockCrcMismatchError{BlockNo: 2, Expected: crcs[1], Actual: crc32.ChecksumIEEE(data[util.BlockSize:])}, err)
+	// the block whose crc is unknown is skipped
+	require.NoError(t, VerifyBlockCrcs(util.BlockSize, data, []uint32{crcs[0], 0}))
+}
@@ -0,0 +1,87 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import (
+	"fmt"
+)
+
+// The types of the changes in the change feed of the volume.
+const (
+	ChangeCreate     = "create"     // the dentry is created, or replaced to refer to another inode
+	ChangeUnlink     = "unlink"     // the dentry is deleted
+	ChangeRenameFrom = "renameFrom" // the dentry is deleted by the rename in a transaction
+	ChangeRenameTo   = "renameTo"   // the dentry is created or replaced by the rename in a transaction
+	ChangeSetAttr    = "setattr"
+	ChangeXAttr      = "xattr" // the extended attributes are set or removed
+	ChangeWrite      = "write" // the data written, truncated or cloned is committed
+)
+
+const (
+	DefaultChangesLimit = 1000
+	MaxChangesLimit     = 10000
+)
+
+// ChangeEvent is a change of the namespace applied by the meta partition. The changes of the
+// partition are ordered by Index, the raft index of the apply making the change, which is the
+// same on all the replicas. An apply may make several changes with the same Index.
+type ChangeEvent struct {
+	PartitionID uint64   `json:"pid"`
+	Index       uint64   `json:"index"`
+	Type        string   `json:"type"`
+	Time        int64    `json:"time"` // unix seconds when the leader submits the op, 0 if logged by the older versions
+	Inode       uint64   `json:"ino"`
+	ParentID    uint64   `json:"pino,omitempty"`
+	Name        string   `json:"name,omitempty"`
+	Mode        uint32   `json:"mode,omitempty"`
+	OldInode    uint64   `json:"oldIno,omitempty"` // the inode the dentry referred to before it is replaced
+	Size        uint64   `json:"size,omitempty"`
+	Valid       uint32   `json:"valid,omitempty"` // the attributes set by setattr
+	Keys        []string `json:"keys,omitempty"`  // the extended attributes set or removed
+	TxID        string   `json:"tx,omitempty"`
+}
+
+func (e *ChangeEvent) String() string {
+	if e == nil {
+		return ""
+	}
+	return fmt.Sprintf("ChangeEvent{pid(%v) index(%v) type(%v) ino(%v) pino(%v) name(%v)}",
+		e.PartitionID, e.Index, e.Type, e.Inode, e.ParentID, e.Name)
+}
+
+// GetChangesRequest reads the changes of the partition after the index From, waiting at most
+// Wait milliseconds for the new changes if there are none.
+type GetChangesRequest struct {
+	VolName     string `json:"vol"`
+	PartitionID uint64 `json:"pid"`
+	From        uint64 `json:"from"`
+	Limit       int    `json:"limit"`
+	Wait        int64  `json:"wait"`
+}
+
+// GetChangesResponse returns the changes in the order they are applied. The changes of an apply
+// are never split across the responses, so the Index of the last change is the cursor to read
+// the next ones from. Expired is set if some changes after the cursor are no longer kept, and the
+// changes are returned from the oldest one kept.
+type GetChangesResponse struct {
+	Events  []*ChangeEvent `json:"events"`
+	First   uint64         `json:"first"`   // the changes after it are kept
+	Applied uint64         `json:"applied"` // the apply index of the replica, the cursor to read the new changes only
+	Expired bool           `json:"expired"`
+}
+
+// ChangeCursor is the position of the consumer in the change feed of the volume, by the index
+// of the last change read from each meta partition.
+type ChangeCursor map[uint64]uint64
@@ -0,0 +1,86 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import (
+	"time"
+)
+
+// The status of the volume cloned from a snapshot of the source volume.
+const (
+	VolCloneNone    uint8 = iota // not a clone
+	VolCloneCopying              // the metadata of the snapshot is being copied
+	VolCloneReady
+	VolCloneFailed
+	VolCloneDetached // the shared data is rewritten into the clone, the source is no longer referred to
+)
+
+func VolCloneStatusString(status uint8) string {
+	switch status {
+	case VolCloneNone:
+		return ""
+	case VolCloneCopying:
+		return "copying"
+	case VolCloneReady:
+		return "ready"
+	case VolCloneFailed:
+		return "failed"
+	case VolCloneDetached:
+		return "detached"
+	default:
+		return "unknown"
+	}
+}
+
+type VolCloneTaskRequest struct {
+	MasterAddr string
+	LcNodeAddr string
+	Task       *VolCloneTask
+}
+
+// VolCloneTask copies the metadata of the source volume at the snapshot into the clone volume,
+// the extent keys are copied as they are, so the data is shared until it is overwritten. The
+// detach task rewrites the data still shared into the clone instead.
+type VolCloneTask struct {
+	Id        string
+	SourceVol string
+	CloneVol  string
+	Ver       uint64 // the snapshot version of the source
+	ReadSeq   uint64 // the metadata of the source is read at it
+	Detach    bool
+}
+
+type VolCloneTaskResponse struct {
+	ID         string
+	LcNode     string
+	StartTime  *time.Time
+	EndTime    *time.Time
+	UpdateTime *time.Time
+	Done       bool
+	Status     uint8
+	Result     string
+	Task       *VolCloneTask
+	VolCloneStatistics
+}
+
+type VolCloneStatistics struct {
+	DirCount      int64
+	FileCount     int64
+	SymlinkCount  int64
+	HardLinkNum   int64
+	ExtentNum     int64
+	SharedBytes   uint64
+	DetachedBytes uint64
+}
@@ -0,0 +1,83 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+const (
+	ScrubStatusDisabled = "disabled"
+	ScrubStatusIdle     = "idle"
+	ScrubStatusRunning  = "running"
+)
+
+// ScrubExtentBlocksRequest asks the replica to re-read the range of the normal extent from disk and
+// compute the crc of every full block in it.
+type ScrubExtentBlocksRequest struct {
+	PartitionId uint64
+	ExtentId    uint64
+	Offset      int64
+	Size        int64
+}
+
+// ScrubExtentBlocksResponse carries the crcs computed by the replica, Crcs[i] being the crc of the
+// i-th block from the requested offset. Blocks beyond ExtentSize, the size of the extent on the
+// replica, are not included.
+type ScrubExtentBlocksResponse struct {
+	ExtentSize int64
+	Crcs       []uint32
+}
+
+// ScrubFinding records a replica whose block diverges from the other replicas.
+type ScrubFinding struct {
+	ExtentId    uint64 `json:"extentId"`
+	Offset      int64  `json:"offset"`
+	Replica     string `json:"replica"`
+	ExpectedCrc uint32 `json:"expectedCrc"` // 0 if no crc is held by a majority of the replicas
+	ActualCrc   uint32 `json:"actualCrc"`
+	Repaired    bool   `json:"repaired"`
+	Err         string `json:"err,omitempty"`
+	Time        int64  `json:"time"`
+}
+
+// DataPartitionScrubInfo is the scrub progress of a data partition in the current or the last round,
+// along with its latest findings.
+type DataPartitionScrubInfo struct {
+	PartitionId    uint64          `json:"partitionId"`
+	Disk           string          `json:"disk"`
+	Status         string          `json:"status"`
+	Rounds         uint64          `json:"rounds"`
+	LastStartTime  int64           `json:"lastStartTime"`
+	LastFinishTime int64           `json:"lastFinishTime"`
+	TotalExtents   int             `json:"totalExtents"`
+	ScannedExtents int             `json:"scannedExtents"`
+	ScannedBytes   uint64          `json:"scannedBytes"`
+	MismatchBlocks uint64          `json:"mismatchBlocks"`
+	RepairedBlocks uint64          `json:"repairedBlocks"`
+	Findings       []*ScrubFinding `json:"findings"`
+}
+
+// DiskScrubInfo is the scrub progress of a disk in the current or the last round.
+type DiskScrubInfo struct {
+	Disk              string `json:"disk"`
+	Status            string `json:"status"`
+	BytesPerSec       int    `json:"bytesPerSec"`
+	Rounds            uint64 `json:"rounds"`
+	LastStartTime     int64  `json:"lastStartTime"`
+	LastFinishTime    int64  `json:"lastFinishTime"`
+	CurrentPartition  uint64 `json:"currentPartition"`
+	TotalPartitions   int    `json:"totalPartitions"`
+	ScannedPartitions int    `json:"scannedPartitions"`
+	ScannedBytes      uint64 `json:"scannedBytes"`
+	MismatchBlocks    uint64 `json:"mismatchBlocks"`
+	RepairedBlocks    uint64 `json:"repairedBlocks"`
+}
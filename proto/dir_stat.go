@@ -0,0 +1,71 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+// The extended attributes of the directory serving its recursive stats maintained by the
+// metanode. They are read only and not listed.
+const (
+	DirStatFilesKey = "cfs.dir.rfiles"
+	DirStatDirsKey  = "cfs.dir.rsubdirs"
+	DirStatBytesKey = "cfs.dir.rbytes"
+)
+
+func IsDirStatKey(key string) bool {
+	return key == DirStatFilesKey || key == DirStatDirsKey || key == DirStatBytesKey
+}
+
+// DirStat is the recursive stats of the directory, the files, the sub directories and the bytes
+// of the files in the whole tree under it.
+type DirStat struct {
+	Files int64 `json:"files"`
+	Dirs  int64 `json:"dirs"`
+	Bytes int64 `json:"bytes"`
+}
+
+// The types of the updates of the directory stats sent between the meta partitions.
+const (
+	DirStatLink   uint8 = 1 // the inode is linked to the parent
+	DirStatUnlink uint8 = 2 // the inode is unlinked from the parent
+	DirStatDelta  uint8 = 3 // the stats of the directory change
+
+	// the inode is linked to the parent by the backfill, unless it's linked to the parent already
+	DirStatBackfill uint8 = 4
+)
+
+// DirStatUpdate is an update of the stats to the meta partition of Inode. Seq is assigned by the
+// partition sending it, and increases in the order the updates are made.
+type DirStatUpdate struct {
+	Seq    uint64 `json:"seq"`
+	Type   uint8  `json:"type"`
+	Inode  uint64 `json:"ino"`
+	Parent uint64 `json:"pino,omitempty"`
+	IsDir  bool   `json:"dir,omitempty"`
+	Files  int64  `json:"files,omitempty"`
+	Dirs   int64  `json:"dirs,omitempty"`
+	Bytes  int64  `json:"bytes,omitempty"`
+}
+
+type UpdateDirStatRequest struct {
+	VolName     string           `json:"vol"`
+	PartitionID uint64           `json:"pid"`
+	From        uint64           `json:"from"` // the partition sending the updates
+	Updates     []*DirStatUpdate `json:"updates"`
+}
+
+type GetDirStatRequest struct {
+	VolName     string `json:"vol"`
+	PartitionID uint64 `json:"pid"`
+	Inode       uint64 `json:"ino"`
+}
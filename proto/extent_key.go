@@ -37,6 +37,7 @@ var (
 	ExtentLength          = 40
 	ExtentKeyChecksumSize = 4
 	ExtentVerFieldSize    = 9 // ver(8) and isSplit(1)
+	ExtentCompressSize    = 8 // compressSize(4) and compressOffset(4)
 	ExtentV2Length        = ExtentKeyHeaderSize + ExtentLength + ExtentKeyChecksumSize
 	ExtentV3Length        = ExtentKeyHeaderSize + ExtentLength + ExtentKeyChecksumSize + ExtentVerFieldSize
 	InvalidKey            = errors.New("invalid key error")
@@ -60,6 +61,34 @@ type ExtentKey struct {
 	CRC          uint32
 	// snapshot
 	SnapInfo *ExtSnapInfo
+	// The key written by the volume enabling compression refers to part of a compressed block, which
+	// is stored at ExtentOffset in CompressSize bytes. The data of the key starts at CompressOffset in
+	// the decompressed block, and is Size bytes long.
+	CompressSize   uint32 `json:",omitempty"`
+	CompressOffset uint32 `json:",omitempty"`
+}
+
+// IsCompressed returns if the key refers to a compressed block.
+func (k *ExtentKey) IsCompressed() bool {
+	return k.CompressSize > 0
+}
+
+// PhysicalSize returns the size of the data the key refers to in the extent.
+func (k *ExtentKey) PhysicalSize() uint32 {
+	if k.IsCompressed() {
+		return k.CompressSize
+	}
+	return k.Size
+}
+
+// SkipHead moves the start of the key forward by n bytes of the data, the extent offset of the
+// compressed key is kept at its block.
+func (k *ExtentKey) SkipHead(n uint64) {
+	if k.IsCompressed() {
+		k.CompressOffset += uint32(n)
+		return
+	}
+	k.ExtentOffset += n
 }
 
 func (k *ExtentKey) GetModGen() uint64 {
@@ -90,7 +119,9 @@ func (k *ExtentKey) Equals(ek *ExtentKey) bool {
 		k.ExtentOffset != ek.ExtentOffset ||
 		k.FileOffset != ek.FileOffset ||
 		k.ExtentId != ek.ExtentId ||
-		k.CRC != ek.CRC {
+		k.CRC != ek.CRC ||
+		k.CompressSize != ek.CompressSize ||
+		k.CompressOffset != ek.CompressOffset {
 		return false
 	}
 	if k.SnapInfo == nil && ek.SnapInfo == nil {
@@ -184,7 +215,8 @@ func (k *ExtentKey) IsEqual(rightKey *ExtentKey) bool {
 }
 
 func (k *ExtentKey) IsCoveredWithDiffSeq(rightKey *ExtentKey) bool {
-	return k.PartitionId == rightKey.PartitionId &&
+	return !k.IsCompressed() && !rightKey.IsCompressed() &&
+		k.PartitionId == rightKey.PartitionId &&
 		k.ExtentId == rightKey.ExtentId &&
 		k.GetSeq() < rightKey.GetSeq() &&
 		k.ExtentOffset+uint64(k.Size) == rightKey.ExtentOffset &&
@@ -192,7 +224,8 @@ func (k *ExtentKey) IsCoveredWithDiffSeq(rightKey *ExtentKey) bool {
 }
 
 func (k *ExtentKey) IsSequenceWithSameSeq(rightKey *ExtentKey) bool {
-	return k.PartitionId == rightKey.PartitionId &&
+	return !k.IsCompressed() && !rightKey.IsCompressed() &&
+		k.PartitionId == rightKey.PartitionId &&
 		k.ExtentId == rightKey.ExtentId &&
 		k.GetSeq() == rightKey.GetSeq() &&
 		k.ExtentOffset+uint64(k.Size) == rightKey.ExtentOffset &&
@@ -205,7 +238,8 @@ func (k *ExtentKey) IsSameExtent(rightKey *ExtentKey) bool {
 }
 
 func (k *ExtentKey) IsSequenceWithDiffSeq(rightKey *ExtentKey) bool {
-	return k.PartitionId == rightKey.PartitionId &&
+	return !k.IsCompressed() && !rightKey.IsCompressed() &&
+		k.PartitionId == rightKey.PartitionId &&
 		k.ExtentId == rightKey.ExtentId &&
 		!(k.GetSeq() == rightKey.GetSeq()) &&
 		k.ExtentOffset+uint64(k.Size) == rightKey.ExtentOffset &&
@@ -213,13 +247,18 @@ func (k *ExtentKey) IsSequenceWithDiffSeq(rightKey *ExtentKey) bool {
 }
 
 func (k *ExtentKey) IsFileInSequence(rightKey *ExtentKey) bool {
-	return k.PartitionId == rightKey.PartitionId &&
+	return !k.IsCompressed() && !rightKey.IsCompressed() &&
+		k.PartitionId == rightKey.PartitionId &&
 		k.ExtentId == rightKey.ExtentId &&
 		k.ExtentOffset+uint64(k.Size) == rightKey.ExtentOffset
 }
 
 // String returns the string format of the extentKey.
 func (k ExtentKey) String() string {
+	if k.IsCompressed() {
+		return fmt.Sprintf("ExtentKey{FileOffset(%v),VerSeq(%v) Partition(%v),ExtentID(%v),ExtentOffset(%v),isSplit(%v),Size(%v),CRC(%v),CompressSize(%v),CompressOffset(%v)}",
+			k.FileOffset, k.GetSeq(), k.PartitionId, k.ExtentId, k.ExtentOffset, k.IsSplit(), k.Size, k.CRC, k.CompressSize, k.CompressOffset)
+	}
 	return fmt.Sprintf("ExtentKey{FileOffset(%v),VerSeq(%v) Partition(%v),ExtentID(%v),ExtentOffset(%v),isSplit(%v),Size(%v),CRC(%v)}",
 		k.FileOffset, k.GetSeq(), k.PartitionId, k.ExtentId, k.ExtentOffset, k.IsSplit(), k.Size, k.CRC)
 }
@@ -317,6 +356,22 @@ func (k *ExtentKey) UnmarshalBinary(buf *bytes.Buffer, v3 bool) (err error) {
 	return
 }
 
+// MarshalCompressBinary appends the binary format of the compression fields of the key to data.
+func (k *ExtentKey) MarshalCompressBinary(data []byte) []byte {
+	buf := make([]byte, ExtentCompressSize)
+	binary.BigEndian.PutUint32(buf[0:], k.CompressSize)
+	binary.BigEndian.PutUint32(buf[4:], k.CompressOffset)
+	return append(data, buf...)
+}
+
+// UnmarshalCompressBinary unmarshals the binary format of the compression fields of the key.
+func (k *ExtentKey) UnmarshalCompressBinary(buf *bytes.Buffer) (err error) {
+	if err = binary.Read(buf, binary.BigEndian, &k.CompressSize); err != nil {
+		return
+	}
+	return binary.Read(buf, binary.BigEndian, &k.CompressOffset)
+}
+
 func (k *ExtentKey) CheckSum(v3 bool) uint32 {
 	sign := crc32.NewIEEE()
 	buf, err := k.MarshalBinary(v3)
@@ -33,8 +33,13 @@ const (
 	FlagsSyncWrite int = 1 << iota
 	FlagsAppend
 	FlagsCache
+	FlagsNoInline // never store the data inline in the inode
 )
 
+// MaxInlineDataSize is the upper bound of the inline data threshold of the volumes, the files
+// not larger than the threshold are stored in the inode rather than in extents.
+const MaxInlineDataSize = 64 * 1024
+
 const (
 	FlagsSnapshotDel int = 1 << iota
 	FlagsSnapshotDelDir
@@ -482,6 +487,35 @@ type UpdateDentryResponse struct {
 	Inode uint64 `json:"ino"` // old inode number
 }
 
+// ArchiveDentryRequest moves the current version of an object into the version archive
+// directory of its key, and links the new current version, promotes an archived version or
+// creates a delete marker in the same apply. The archive directory must be in the partition of
+// the parent directory of the object.
+type ArchiveDentryRequest struct {
+	VolName     string            `json:"vol"`
+	PartitionID uint64            `json:"pid"`
+	ParentID    uint64            `json:"pino"`
+	Name        string            `json:"name"`
+	Inode       uint64            `json:"ino"`     // the current version expected at the name, 0 if none
+	NewInode    uint64            `json:"nino"`    // the new current version, 0 if none
+	ArchiveID   uint64            `json:"aino"`    // the version archive directory of the key
+	ArchiveName string            `json:"aname"`   // the name the current version is archived as, empty to drop it
+	Promote     string            `json:"promote"` // the archived version linked as the current version
+	MarkerName  string            `json:"marker"`  // the name of the delete marker created, empty if none
+	MarkerMode  uint32            `json:"mmode"`
+	MarkerAttrs map[string]string `json:"mattrs"`
+	StorageType uint32            `json:"storageType"`
+	RequestExtend
+}
+
+// ArchiveDentryResponse returns the inodes unlinked from the namespace, which are released by
+// the caller.
+type ArchiveDentryResponse struct {
+	Dropped  uint64     `json:"dropped"`  // the current version dropped rather than archived
+	Replaced []uint64   `json:"replaced"` // the archived versions replaced under the same names
+	Marker   *InodeInfo `json:"marker"`
+}
+
 type TxUpdateDentryRequest struct {
 	VolName     string           `json:"vol"`
 	PartitionID uint64           `json:"pid"`
@@ -724,7 +758,34 @@ type GetExtentsResponse struct {
 	Extents         []ExtentKey `json:"eks"`
 	LayerInfo       []LayerInfo `json:"layer"`
 	Status          int
-	LeaseExpireTime uint64 `json:"leaseExpireTime"`
+	LeaseExpireTime uint64           `json:"leaseExpireTime"`
+	Shared          []SharedExtentID `json:"shared,omitempty"` // the extents shared with other files
+	InlineData      []byte           `json:"inline,omitempty"` // the data of the file stored in the inode
+}
+
+// SharedExtentID is the extent shared by the files cloned by CloneInodeRequest, which must be
+// copied on write rather than overwritten in place.
+type SharedExtentID struct {
+	PartitionId uint64 `json:"pid"`
+	ExtentId    uint64 `json:"eid"`
+}
+
+// MarkSharedExtentsRequest marks the extents of the data partition shared, so that the data
+// partition refuses to overwrite them in place. The extents are also held by the holder if it is
+// set, which is the meta partition of the clone referring to the extents of the source volume.
+type MarkSharedExtentsRequest struct {
+	PartitionId uint64   `json:"pid"`
+	ExtentIds   []uint64 `json:"eids"`
+	Holder      uint64   `json:"holder,omitempty"`
+}
+
+// ReleaseSharedExtentsRequest releases the extents held by the meta partitions of the clone, or
+// all the extents they hold if ExtentIds is empty. The extents are deleted once no holder is left
+// if the source volume has deleted them meanwhile.
+type ReleaseSharedExtentsRequest struct {
+	PartitionId uint64   `json:"pid"`
+	Holders     []uint64 `json:"holders"`
+	ExtentIds   []uint64 `json:"eids,omitempty"`
 }
 
 // TruncateRequest defines the request to truncate.
@@ -736,6 +797,68 @@ type TruncateRequest struct {
 	RequestExtend
 }
 
+// Modes of fallocate, which are the same as the FALLOC_FL_* flags of Linux.
+const (
+	FallocKeepSize  uint32 = 0x01
+	FallocPunchHole uint32 = 0x02
+	FallocZeroRange uint32 = 0x10
+)
+
+// IsValidFallocateMode returns true if the mode is supported, punching hole has to keep the size
+// and is exclusive with zeroing range. The DataNodes don't preallocate the extents, so the modes
+// which only allocate the range, the default mode and FallocKeepSize alone, are not supported,
+// rather than extending the size without reserving the space.
+func IsValidFallocateMode(mode uint32) bool {
+	if mode&^(FallocKeepSize|FallocPunchHole|FallocZeroRange) != 0 {
+		return false
+	}
+	if mode&FallocPunchHole != 0 {
+		return mode == FallocPunchHole|FallocKeepSize
+	}
+	return mode&FallocZeroRange != 0
+}
+
+// FallocateRequest defines the request to allocate, punch or zero the byte range of a file.
+type FallocateRequest struct {
+	VolName     string    `json:"vol"`
+	PartitionID uint64    `json:"pid"`
+	Inode       uint64    `json:"ino"`
+	Mode        uint32    `json:"mode"`
+	Offset      uint64    `json:"off"`
+	Length      uint64    `json:"len"`
+	SubmitTime  time.Time `json:"submitTime"`
+	RequestExtend
+}
+
+// CloneInodeRequest clones the regular file into a new inode of the same meta partition, or
+// into the empty file DstInode if it is set. The extent keys are copied rather than the data,
+// so the extents are shared by the files until they are overwritten.
+type CloneInodeRequest struct {
+	VolName     string `json:"vol"`
+	PartitionID uint64 `json:"pid"`
+	Inode       uint64 `json:"ino"`
+	DstInode    uint64 `json:"dst"`
+	Uid         uint32 `json:"uid"`
+	Gid         uint32 `json:"gid"`
+	RequestExtend
+}
+
+type CloneInodeResponse struct {
+	Info *InodeInfo `json:"info"`
+}
+
+// WriteInlineRequest writes the data of the tiny file into the inode. It's rejected with
+// OpArgMismatchErr once the file has extents or grows beyond MaxInlineDataSize, and the data
+// should be written into extents instead.
+type WriteInlineRequest struct {
+	VolName     string `json:"vol"`
+	PartitionID uint64 `json:"pid"`
+	Inode       uint64 `json:"ino"`
+	Offset      uint64 `json:"off"`
+	Data        []byte `json:"data"`
+	RequestExtend
+}
+
 type EmptyExtentKeyRequest struct {
 	VolName     string `json:"vol"`
 	PartitionID uint64 `json:"pid"`
@@ -1055,6 +1178,13 @@ type GetAppliedIDRequest struct {
 	PartitionId uint64 `json:"pid"`
 }
 
+// ReadIndexRequest asks the leader of the partition for the index the follower must apply before
+// serving a linearizable read.
+type ReadIndexRequest struct {
+	VolName     string `json:"vol"`
+	PartitionID uint64 `json:"pid"`
+}
+
 type LockDirRequest struct {
 	VolName     string    `json:"vol"`
 	PartitionId uint64    `json:"pid"`
@@ -1074,6 +1204,78 @@ type LockDirResponse struct {
 	Status uint8 `json:"status"`
 }
 
+// Types of the file lock.
+const (
+	FileLockRead uint8 = iota + 1
+	FileLockWrite
+	FileLockUnlock
+)
+
+// Operations of the file lock.
+const (
+	FileLockOpSet   uint8 = iota + 1 // acquire or release the lock on a byte range
+	FileLockOpGet                    // query the lock conflicting with the given one
+	FileLockOpRenew                  // renew the leases of all the locks held by the client
+)
+
+// FileLock is a POSIX record lock or a flock lock on the byte range of an inode, the locks of
+// different kinds do not conflict with each other. The lock is owned by the lock owner of a
+// client, and is released automatically once the lease expires.
+type FileLock struct {
+	Client uint64 `json:"client"` // session id of the client
+	Owner  uint64 `json:"owner"`  // lock owner in the client
+	Pid    uint32 `json:"pid"`
+	Flock  bool   `json:"flock"`
+	Type   uint8  `json:"type"`
+	Start  uint64 `json:"start"`
+	End    uint64 `json:"end"`    // inclusive
+	Expire int64  `json:"expire"` // unix seconds
+}
+
+// SameOwner returns true if the locks are owned by the same lock owner.
+func (lk *FileLock) SameOwner(other *FileLock) bool {
+	return lk.Client == other.Client && lk.Owner == other.Owner && lk.Flock == other.Flock
+}
+
+// Overlaps returns true if the byte ranges of the locks overlap.
+func (lk *FileLock) Overlaps(other *FileLock) bool {
+	return lk.Start <= other.End && other.Start <= lk.End
+}
+
+// Conflicts returns true if the locks can not be held at the same time.
+func (lk *FileLock) Conflicts(other *FileLock) bool {
+	if lk.Flock != other.Flock || lk.SameOwner(other) || !lk.Overlaps(other) {
+		return false
+	}
+	return lk.Type == FileLockWrite || other.Type == FileLockWrite
+}
+
+func (lk *FileLock) String() string {
+	return fmt.Sprintf("FileLock{client(%v) owner(%v) pid(%v) flock(%v) type(%v) range(%v-%v) expire(%v)}",
+		lk.Client, lk.Owner, lk.Pid, lk.Flock, lk.Type, lk.Start, lk.End, lk.Expire)
+}
+
+type FileLockRequest struct {
+	VolName     string    `json:"vol"`
+	PartitionId uint64    `json:"pid"`
+	Inode       uint64    `json:"ino"`
+	Op          uint8     `json:"op"`
+	Lock        FileLock  `json:"lock"`
+	Lease       uint64    `json:"lease"` // unit seconds
+	SubmitTime  time.Time `json:"submitTime"`
+}
+
+func (lr *FileLockRequest) String() string {
+	data, _ := json.Marshal(lr)
+	return string(data)
+}
+
+type FileLockResponse struct {
+	Status   uint8     `json:"status"`
+	Conflict *FileLock `json:"conflict"` // the conflicting lock if the status is OpExistErr
+	Held     int       `json:"held"`     // number of the locks held by the client after renewal
+}
+
 type InodeAccessTime struct {
 	Inode      uint64    `json:"ino"`
 	AccessTime time.Time `json:"at"`
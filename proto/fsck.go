@@ -0,0 +1,103 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import (
+	"time"
+)
+
+const (
+	// DefaultFsckSafeWindow is the default seconds before the inodes and extents are checked as
+	// orphans, which keeps the ones being created out of the repairs.
+	DefaultFsckSafeWindow int64 = 3600
+	// FsckMaxReportIssues limits the issues kept in the report of the fsck task.
+	FsckMaxReportIssues = 1000
+)
+
+// The issues found by the online fsck.
+const (
+	FsckIssueDanglingDentry = "danglingDentry" // the dentry whose inode does not exist
+	FsckIssueOrphanInode    = "orphanInode"    // the inode linked but referenced by no dentry
+	FsckIssueOrphanExtent   = "orphanExtent"   // the extent in data partition referenced by no inode
+)
+
+type FsckTaskRequest struct {
+	MasterAddr string
+	LcNodeAddr string
+	Task       *FsckTask
+}
+
+// FsckTask checks the meta partitions of the volume one by one, and repairs the issues found
+// unless DryRun is set.
+type FsckTask struct {
+	Id          string
+	VolName     string
+	DryRun      bool
+	CheckExtent bool  // cross-check the extent keys of the inodes with the extents of the data partitions
+	SafeWindow  int64 // seconds, the inodes and extents modified within it are never taken as orphans
+}
+
+type FsckIssue struct {
+	Type        string
+	PartitionID uint64 // the data partition of the orphan extent
+	ParentID    uint64
+	Name        string
+	Inode       uint64
+	ExtentID    uint64
+	Size        uint64
+	Repaired    bool
+	Err         string `json:",omitempty"`
+}
+
+type FsckTaskResponse struct {
+	ID         string
+	LcNode     string
+	StartTime  *time.Time
+	EndTime    *time.Time
+	UpdateTime *time.Time
+	Done       bool
+	Status     uint8
+	Result     string
+	FsckTask   *FsckTask
+	FsckStatistics
+	Issues []*FsckIssue
+}
+
+type FsckStatistics struct {
+	VolName              string
+	MetaPartitionNum     int64
+	MetaPartitionChecked int64
+	DataPartitionNum     int64
+	DataPartitionChecked int64
+	CurrentPartitionID   uint64
+	CurrentInode         uint64
+	InodeScannedNum      int64
+	DentryScannedNum     int64
+	ExtentScannedNum     int64
+	DanglingDentryNum    int64
+	OrphanInodeNum       int64
+	OrphanExtentNum      int64
+	RepairedNum          int64
+	ErrorSkippedNum      int64
+	OrphanCheckSkipped   bool // the orphans are not checked since the walk is incomplete
+}
+
+// FsckDeleteExtentsRequest queues the orphan extents into the extent deletion of the meta
+// partition, the extents are deleted as the ones of the evicted inodes.
+type FsckDeleteExtentsRequest struct {
+	VolName     string      `json:"vol"`
+	PartitionID uint64      `json:"pid"`
+	Extents     []ExtentKey `json:"eks"`
+}
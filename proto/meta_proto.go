@@ -38,6 +38,9 @@ type Peer struct {
 	Addr          string `json:"addr"`
 	HeartbeatPort string `json:"raftHeartbeat"`
 	ReplicaPort   string `json:"raftReplica"`
+	// IsLearner is true for the replica added to the raft group not voting until it's promoted after
+	// catching up with the leader.
+	IsLearner bool `json:"isLearner,omitempty"`
 }
 
 // CreateMetaPartitionRequest defines the request to create a meta partition.
@@ -49,6 +52,42 @@ type CreateMetaPartitionRequest struct {
 	PartitionID uint64
 	Members     []Peer
 	VerSeq      uint64
+	StoreMode   StoreMode
+}
+
+// StoreMode is the engine which keeps the metadata of the meta partitions, it is chosen when the
+// volume is created.
+type StoreMode uint8
+
+const (
+	// StoreModeMem keeps all the metadata in memory, and dumps the whole partition periodically.
+	StoreModeMem StoreMode = iota
+	// StoreModeRocksDB keeps the metadata in RocksDB, only the items changed recently are kept in
+	// memory, and only the changes are written to RocksDB periodically.
+	StoreModeRocksDB
+)
+
+func (m StoreMode) String() string {
+	switch m {
+	case StoreModeMem:
+		return "mem"
+	case StoreModeRocksDB:
+		return "rocksdb"
+	default:
+		return fmt.Sprintf("unknown(%d)", m)
+	}
+}
+
+// ParseStoreMode parses the store mode by its name, the empty name means StoreModeMem.
+func ParseStoreMode(name string) (StoreMode, error) {
+	switch name {
+	case "", "mem":
+		return StoreModeMem, nil
+	case "rocksdb":
+		return StoreModeRocksDB, nil
+	default:
+		return StoreModeMem, fmt.Errorf("unknown meta store mode: %v", name)
+	}
 }
 
 // CreateMetaPartitionResponse defines the response to the request of creating a meta partition.
@@ -59,15 +98,95 @@ type CreateMetaPartitionResponse struct {
 	Result      string
 }
 
+// QuotaOwnerType tells whether the space of UidSpaceInfo is owned by a user or a group.
+type QuotaOwnerType uint8
+
+const (
+	QuotaOwnerUser QuotaOwnerType = iota
+	QuotaOwnerGroup
+)
+
+// DefaultQuotaGracePeriod is the seconds that the soft limits may be exceeded by default.
+const DefaultQuotaGracePeriod int64 = 7 * 24 * 3600
+
+func (t QuotaOwnerType) String() string {
+	switch t {
+	case QuotaOwnerUser:
+		return "user"
+	case QuotaOwnerGroup:
+		return "group"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(t))
+	}
+}
+
+func ParseQuotaOwnerType(name string) (QuotaOwnerType, error) {
+	switch name {
+	case "", "user", "uid":
+		return QuotaOwnerUser, nil
+	case "group", "gid":
+		return QuotaOwnerGroup, nil
+	default:
+		return 0, fmt.Errorf("invalid quota owner type %q", name)
+	}
+}
+
 type UidSpaceInfo struct {
 	VolName   string
-	Uid       uint32
+	Uid       uint32 // the gid for QuotaOwnerGroup
 	CTime     int64
 	Enabled   bool
-	Limited   bool
+	Limited   bool // the writes and creates are denied
 	UsedSize  uint64
-	LimitSize uint64
+	LimitSize uint64 // the hard limit of bytes, 0 means no limit
 	Rsv       string
+
+	Type             QuotaOwnerType
+	SoftSize         uint64
+	UsedFiles        uint64
+	LimitFiles       uint64
+	SoftFiles        uint64
+	LimitedFiles     bool  // the creates are denied
+	GracePeriod      int64 // seconds that the soft limits may be exceeded, 0 means DefaultQuotaGracePeriod
+	SizeGraceExpire  int64 // the unix time that the soft limit of bytes is enforced, 0 if not exceeded
+	FilesGraceExpire int64
+}
+
+// UpdateLimited updates the grace timers and the limited states by the usage at now, and tells
+// whether the grace timers are changed.
+func (info *UidSpaceInfo) UpdateLimited(now int64) (changed bool) {
+	if !info.Enabled {
+		changed = info.SizeGraceExpire != 0 || info.FilesGraceExpire != 0
+		info.Limited, info.LimitedFiles = false, false
+		info.SizeGraceExpire, info.FilesGraceExpire = 0, 0
+		return
+	}
+	sizeChanged := info.updateGraceExpire(info.UsedSize, info.SoftSize, &info.SizeGraceExpire, now)
+	filesChanged := info.updateGraceExpire(info.UsedFiles, info.SoftFiles, &info.FilesGraceExpire, now)
+	info.Limited = (info.LimitSize != 0 && info.UsedSize > info.LimitSize) ||
+		(info.SizeGraceExpire != 0 && now >= info.SizeGraceExpire)
+	info.LimitedFiles = (info.LimitFiles != 0 && info.UsedFiles >= info.LimitFiles) ||
+		(info.FilesGraceExpire != 0 && now >= info.FilesGraceExpire)
+	return sizeChanged || filesChanged
+}
+
+// updateGraceExpire starts the grace timer once the soft limit is exceeded, and stops it once
+// the usage is back under the soft limit.
+func (info *UidSpaceInfo) updateGraceExpire(used, soft uint64, expire *int64, now int64) (changed bool) {
+	if soft == 0 || used <= soft {
+		changed = *expire != 0
+		*expire = 0
+		return
+	}
+	if *expire != 0 {
+		return
+	}
+	grace := info.GracePeriod
+	if grace <= 0 {
+		grace = DefaultQuotaGracePeriod
+	}
+	*expire = now + grace
+	return true
 }
 
 type UidReportSpaceInfo struct {
@@ -75,6 +194,8 @@ type UidReportSpaceInfo struct {
 	Size  uint64
 	Rsv   string
 	MTime int64
+	Type  QuotaOwnerType
+	Files uint64
 }
 
 type QuotaUsedInfo struct {
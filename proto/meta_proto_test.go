@@ -0,0 +1,74 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUidSpaceInfoUpdateLimited(t *testing.T) {
+	info := &UidSpaceInfo{
+		Enabled:     true,
+		LimitSize:   100,
+		SoftSize:    50,
+		LimitFiles:  10,
+		SoftFiles:   5,
+		GracePeriod: 60,
+	}
+	now := int64(1000)
+	require.False(t, info.UpdateLimited(now))
+	require.False(t, info.Limited)
+
+	// the soft limits are allowed to be exceeded in the grace period
+	info.UsedSize, info.UsedFiles = 60, 6
+	require.True(t, info.UpdateLimited(now))
+	require.Equal(t, now+60, info.SizeGraceExpire)
+	require.Equal(t, now+60, info.FilesGraceExpire)
+	require.False(t, info.Limited)
+	require.False(t, info.LimitedFiles)
+	require.False(t, info.UpdateLimited(now+30))
+	require.Equal(t, now+60, info.SizeGraceExpire)
+
+	require.False(t, info.UpdateLimited(now+60))
+	require.True(t, info.Limited)
+	require.True(t, info.LimitedFiles)
+
+	// back under the soft limit of files
+	info.UsedFiles = 5
+	require.True(t, info.UpdateLimited(now+70))
+	require.Equal(t, int64(0), info.FilesGraceExpire)
+	require.True(t, info.Limited)
+	require.False(t, info.LimitedFiles)
+
+	// the hard limits are enforced at once
+	info.UsedSize, info.UsedFiles = 20, 10
+	info.UpdateLimited(now + 80)
+	require.False(t, info.Limited)
+	require.True(t, info.LimitedFiles)
+	require.Equal(t, now+80+60, info.FilesGraceExpire)
+
+	info.Enabled = false
+	require.True(t, info.UpdateLimited(now+90))
+	require.False(t, info.LimitedFiles)
+	require.Equal(t, int64(0), info.FilesGraceExpire)
+
+	typ, err := ParseQuotaOwnerType("group")
+	require.NoError(t, err)
+	require.Equal(t, QuotaOwnerGroup, typ)
+	_, err = ParseQuotaOwnerType("other")
+	require.Error(t, err)
+}
@@ -16,6 +16,7 @@ package proto
 
 import (
 	"fmt"
+	"strconv"
 	"sync"
 	"time"
 
@@ -298,10 +299,16 @@ type VolStatInfo struct {
 	DefaultStorageClass     uint32
 	CacheDpStorageClass     uint32
 	MetaFollowerRead        bool
+	MetaFollowerReadIndex   bool
 	LeaderRetryTimeOut      int
 	StatByStorageClass      []*StatOfStorageClass
 	StatMigrateStorageClass []*StatOfStorageClass
 	StatByDpMediaType       []*StatOfStorageClass
+	RootDirStat             *DirStat `json:",omitempty"` // the recursive stats of the root directory
+	// the bytes of the files without the holes, and the bytes they take in the data partitions of
+	// the hot volume, which differ if the volume compresses the data
+	LogicalUsedSize  uint64
+	PhysicalUsedSize uint64
 }
 
 // DataPartition represents the structure of storing the file contents.
@@ -527,6 +534,52 @@ func (v *VolVersionInfoList) GetLastVer() uint64 {
 	return v.VerList[len(v.VerList)-1].Ver
 }
 
+// SnapshotDirName is the virtual read-only directory under the mount root which lists the
+// snapshots of the volume.
+const SnapshotDirName = ".snapshot"
+
+const snapshotNameLayout = "2006-01-02_15-04-05.000000"
+
+// VolSnapshot is a committed version of the volume, it shows the volume as it was when the
+// next version was created.
+type VolSnapshot struct {
+	Name    string    // the UTC time the snapshot was taken
+	Ver     uint64    // the version, the same as the snapshotReadSeq mount option
+	ReadSeq uint64    // the sequence used to read the metadata of the snapshot
+	Time    time.Time // the time the snapshot was taken
+}
+
+// GetSnapshots returns the snapshots in ascending order, i.e. all the normal versions except the
+// current one, which is the live volume.
+func (v *VolVersionInfoList) GetSnapshots() (snapshots []*VolSnapshot) {
+	snapshots = make([]*VolSnapshot, 0)
+	for idx := 0; idx < len(v.VerList)-1; idx++ {
+		info := v.VerList[idx]
+		if info.Status != VersionNormal {
+			continue
+		}
+		next := v.VerList[idx+1].Ver
+		t := time.UnixMicro(int64(next)).UTC()
+		snapshots = append(snapshots, &VolSnapshot{
+			Name:    t.Format(snapshotNameLayout),
+			Ver:     info.Ver,
+			ReadSeq: next - 1,
+			Time:    t,
+		})
+	}
+	return
+}
+
+// FindSnapshot returns the snapshot named name, the version number is accepted as an alias.
+func FindSnapshot(snapshots []*VolSnapshot, name string) *VolSnapshot {
+	for _, snapshot := range snapshots {
+		if snapshot.Name == name || strconv.FormatUint(snapshot.Ver, 10) == name {
+			return snapshot
+		}
+	}
+	return nil
+}
+
 type DecommissionDiskLimitDetail struct {
 	NodeSetId uint64
 	Limit     int
@@ -0,0 +1,35 @@
+package proto
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVolSnapshots(t *testing.T) {
+	base := time.Date(2023, 6, 1, 8, 0, 0, 0, time.UTC)
+	ver := func(d time.Duration) uint64 { return uint64(base.Add(d).UnixMicro()) }
+	verList := &VolVersionInfoList{VerList: []*VolVersionInfo{
+		{Ver: 0, Status: VersionNormal},
+		{Ver: ver(time.Hour), Status: VersionDeleting},
+		{Ver: ver(2 * time.Hour), Status: VersionNormal},
+		{Ver: ver(3*time.Hour + time.Microsecond), Status: VersionNormal},
+	}}
+
+	// the deleting version and the current one are not snapshots
+	snapshots := verList.GetSnapshots()
+	require.Len(t, snapshots, 2)
+	require.Equal(t, "2023-06-01_09-00-00.000000", snapshots[0].Name)
+	require.Equal(t, uint64(0), snapshots[0].Ver)
+	require.Equal(t, ver(time.Hour)-1, snapshots[0].ReadSeq)
+	require.Equal(t, "2023-06-01_11-00-00.000001", snapshots[1].Name)
+	require.Equal(t, ver(2*time.Hour), snapshots[1].Ver)
+	require.Equal(t, ver(3*time.Hour+time.Microsecond)-1, snapshots[1].ReadSeq)
+
+	require.Equal(t, snapshots[1], FindSnapshot(snapshots, "2023-06-01_11-00-00.000001"))
+	require.Equal(t, snapshots[0], FindSnapshot(snapshots, "0"))
+	require.Nil(t, FindSnapshot(snapshots, "2023-06-01_10-00-00.000000"))
+
+	require.Empty(t, (&VolVersionInfoList{}).GetSnapshots())
+}
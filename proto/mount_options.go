@@ -48,6 +48,7 @@ const (
 	EnablePosixACL
 	EnableSummary
 	EnableUnixPermission
+	EnableFileLock
 	RequestTimeout
 
 	// adls
@@ -74,6 +75,7 @@ const (
 
 	// snapshot
 	SnapshotReadVerSeq
+	SnapshotDir
 
 	DisableMountSubtype
 	StreamRetryTimeOut
@@ -146,6 +148,7 @@ func InitMountOptions(opts []MountOption) {
 	opts[EnablePosixACL] = MountOption{"enablePosixACL", "Enable posix ACL support", "", false}
 	opts[EnableSummary] = MountOption{"enableSummary", "Enable content summary", "", false}
 	opts[EnableUnixPermission] = MountOption{"enableUnixPermission", "Enable unix permission check(e.g: 777/755)", "", false}
+	opts[EnableFileLock] = MountOption{"enableFileLock", "Enable POSIX and flock locks across clients", "", false}
 
 	opts[VolType] = MountOption{"volType", "volume type", "", int64(0)}
 	opts[EbsEndpoint] = MountOption{"ebsEndpoint", "Ebs service address", "", ""}
@@ -173,6 +176,7 @@ func InitMountOptions(opts []MountOption) {
 
 	opts[FileSystemName] = MountOption{"fileSystemName", "The explicit name of the filesystem", "", ""}
 	opts[SnapshotReadVerSeq] = MountOption{"snapshotReadSeq", "Snapshot read seq", "", int64(0)} // default false
+	opts[SnapshotDir] = MountOption{"snapshotDir", "Serve the snapshots under the read-only .snapshot directory of the mount root", "", true}
 	opts[DisableMountSubtype] = MountOption{"disableMountSubtype", "Disable Mount Subtype", "", false}
 	opts[StreamRetryTimeOut] = MountOption{"streamRetryTimeout", "max stream retry timeout, s", "", int64(0)}
 	opts[BcacheOnlyForNotSSD] = MountOption{"enableBcacheOnlyForNotSSD", "Enable block cache only for not ssd", "", false}
@@ -327,6 +331,7 @@ type MountOptions struct {
 	WriteThreads                 int64
 	EnableSummary                bool
 	EnableUnixPermission         bool
+	EnableFileLock               bool
 	NeedRestoreFuse              bool
 	MetaSendTimeout              int64
 	BuffersTotalLimit            int64
@@ -340,7 +345,8 @@ type MountOptions struct {
 	TrashDeleteExpiredDirGoroutineLimit int64
 	TrashRebuildGoroutineLimit          int64
 
-	VerReadSeq uint64
+	VerReadSeq  uint64
+	SnapshotDir bool
 	// disable mount subtype
 	DisableMountSubtype bool
 	// stream retry timeout
@@ -0,0 +1,44 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+// XAttr keys of the buckets and objects maintained by ObjectNode, which are read by the workers of
+// LcNode as well.
+const (
+	XAttrKeyOSSPrefix       = "oss:"
+	XAttrKeyOSSETag         = "oss:etag"
+	XAttrKeyOSSTagging      = "oss:tagging"
+	XAttrKeyOSSPolicy       = "oss:policy"
+	XAttrKeyOSSACL          = "oss:acl"
+	XAttrKeyOSSMIME         = "oss:mime"
+	XAttrKeyOSSDISPOSITION  = "oss:disposition"
+	XAttrKeyOSSCORS         = "oss:cors"
+	XAttrKeyOSSLock         = "oss:lock"
+	XAttrKeyOSSCacheControl = "oss:cache"
+	XAttrKeyOSSExpires      = "oss:expires"
+	XAttrKeyOSSVersioning   = "oss:versioning"
+	XAttrKeyOSSVersionId    = "oss:version-id"
+	XAttrKeyOSSDeleteMarker = "oss:delete-marker"
+	XAttrKeyOSSEncryption   = "oss:encryption"
+	XAttrKeyOSSSSE          = "oss:sse"
+	XAttrKeyOSSSSEParts     = "oss:sse-parts"
+	XAttrKeyOSSSSEPartIV    = "oss:sse-iv"
+	XAttrKeyOSSNotification = "oss:notification"
+	XAttrKeyOSSWebsite      = "oss:website"
+	XAttrKeyOSSPublicAccess = "oss:public-access"
+
+	// Deprecated
+	XAttrKeyOSSETagDeprecated = "oss:tag"
+)
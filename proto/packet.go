@@ -43,8 +43,9 @@ func GenerateRequestID() int64 {
 }
 
 const (
-	AddrSplit        = "/"
-	FollowerReadFlag = 'F'
+	AddrSplit         = "/"
+	FollowerReadFlag  = 'F'
+	ReadIndexReadFlag = 'I' // the follower serves the read after applying the read index of the leader
 )
 
 // Operations
@@ -106,6 +107,16 @@ const (
 	OpMetaExtentAddWithCheck       uint8 = 0x3A // Append extent key with discard extents check
 	OpMetaReadDirLimit             uint8 = 0x3D
 	OpMetaLockDir                  uint8 = 0x3E
+	OpMetaFileLock                 uint8 = 0x3F
+	OpMetaFallocate                uint8 = 0x5C
+	OpMetaFsckDeleteExtents        uint8 = 0x4A // used by online fsck only
+	OpMetaCloneInode               uint8 = 0x4C
+	OpMetaWriteInline              uint8 = 0x4D
+	OpMetaGetChanges               uint8 = 0x4E
+	OpMetaGetDirStat               uint8 = 0x4F
+	OpMetaUpdateDirStat            uint8 = 0x5D // sent by the metanode to propagate the directory stats
+	OpMetaReadIndex                uint8 = 0x5E // sent by the follower metanode to get the read index of the leader
+	OpMetaArchiveDentry            uint8 = 0x1A // used by the versioning of objectnode only
 
 	// Operations: Master -> MetaNode
 	OpCreateMetaPartition           uint8 = 0x40
@@ -129,6 +140,9 @@ const (
 	OpLcNodeHeartbeat      uint8 = 0x55
 	OpLcNodeScan           uint8 = 0x56
 	OpLcNodeSnapshotVerDel uint8 = 0x5B
+	OpLcNodeFsck           uint8 = 0x49
+	OpLcNodeVolClone       uint8 = 0x4B
+	OpLcNodeTrash          uint8 = 0x5F
 
 	// backUp
 	OpBatchLockNormalExtent   uint8 = 0x57
@@ -154,6 +168,8 @@ const (
 	OpRecoverBadDisk                uint8 = 0x6E
 	OpQueryBadDiskRecoverProgress   uint8 = 0x6F
 	OpDeleteBackupDirectories       uint8 = 0x80
+	OpRepairExtentBlocks            uint8 = 0x81
+	OpScrubExtentBlocks             uint8 = 0x78 // sent by the scrubbing leader to get the block crcs computed from disk
 
 	// Operations: MultipartInfo
 	OpCreateMultipart  uint8 = 0x70
@@ -162,9 +178,12 @@ const (
 	OpRemoveMultipart  uint8 = 0x73
 	OpListMultiparts   uint8 = 0x74
 
-	OpBatchDeleteExtent   uint8 = 0x75 // SDK to MetaNode
-	OpGcBatchDeleteExtent uint8 = 0x76 // SDK to MetaNode
-	OpGetExpiredMultipart uint8 = 0x77
+	OpBatchDeleteExtent    uint8 = 0x75 // SDK to MetaNode
+	OpGcBatchDeleteExtent  uint8 = 0x76 // SDK to MetaNode
+	OpGetExpiredMultipart  uint8 = 0x77
+	OpMarkSharedExtents    uint8 = 0x79 // MetaNode to DataNode, before the extents are shared by the files cloned
+	OpReleaseSharedExtents uint8 = 0x7A // MetaNode to DataNode, the clone refers to the extents held no longer
+	OpReleaseCloneHolds    uint8 = 0x7B // Master to DataNode, the holds of the clone deleted are released
 
 	// Operations: MetaNode Leader -> MetaNode Follower
 	OpMetaBatchDeleteInode  uint8 = 0x90
@@ -278,6 +297,7 @@ const (
 	OpLeaseOccupiedByOthers             uint8 = 0x86
 	OpLeaseGenerationNotMatch           uint8 = 0x87
 	OpWriteOpOfProtoVerForbidden        uint8 = 0x88
+	OpBlockCrcMismatchErr               uint8 = 0x89
 )
 
 const (
@@ -292,6 +312,7 @@ const (
 	MultiVersionFlag                          = 0x80
 	VersionListFlag                           = 0x40
 	PacketProtocolVersionFlag                 = 0x10
+	BlockCrcFlag                              = 0x20 // the read reply carries the stored crc of the blocks in Arg
 )
 
 // multi version operation
@@ -433,6 +454,12 @@ func (p *Packet) IsFollowerReadMetaPkt() bool {
 	return false
 }
 
+// IsReadIndexMetaPkt returns if the read is allowed on the follower after it applies the read
+// index of the leader.
+func (p *Packet) IsReadIndexMetaPkt() bool {
+	return p.ArgLen == 1 && p.Arg[0] == ReadIndexReadFlag
+}
+
 // GetStoreType returns the store type.
 func (p *Packet) GetStoreType() (m string) {
 	if IsNormalExtentType(p.ExtentType) {
@@ -511,6 +538,26 @@ func (p *Packet) GetOpMsg() (m string) {
 		m = "OpMetaReadDirLimit"
 	case OpMetaLockDir:
 		m = "OpMetaLockDir"
+	case OpMetaFileLock:
+		m = "OpMetaFileLock"
+	case OpMetaFallocate:
+		m = "OpMetaFallocate"
+	case OpMetaFsckDeleteExtents:
+		m = "OpMetaFsckDeleteExtents"
+	case OpMetaCloneInode:
+		m = "OpMetaCloneInode"
+	case OpMetaWriteInline:
+		m = "OpMetaWriteInline"
+	case OpMetaArchiveDentry:
+		m = "OpMetaArchiveDentry"
+	case OpMetaGetChanges:
+		m = "OpMetaGetChanges"
+	case OpMetaGetDirStat:
+		m = "OpMetaGetDirStat"
+	case OpMetaUpdateDirStat:
+		m = "OpMetaUpdateDirStat"
+	case OpMetaReadIndex:
+		m = "OpMetaReadIndex"
 	case OpMetaInodeGet:
 		m = "OpMetaInodeGet"
 	case OpMetaBatchInodeGet:
@@ -637,6 +684,12 @@ func (p *Packet) GetOpMsg() (m string) {
 		m = "OpBatchDeleteExtent"
 	case OpGcBatchDeleteExtent:
 		m = "OpGcBatchDeleteExtent"
+	case OpMarkSharedExtents:
+		m = "OpMarkSharedExtents"
+	case OpReleaseSharedExtents:
+		m = "OpReleaseSharedExtents"
+	case OpReleaseCloneHolds:
+		m = "OpReleaseCloneHolds"
 	case OpMetaClearInodeCache:
 		m = "OpMetaClearInodeCache"
 	case OpMetaTxCreateInode:
@@ -673,12 +726,22 @@ func (p *Packet) GetOpMsg() (m string) {
 		m = "OpMetaGetInodeQuota"
 	case OpStopDataPartitionRepair:
 		m = "OpStopDataPartitionRepair"
+	case OpRepairExtentBlocks:
+		m = "OpRepairExtentBlocks"
+	case OpScrubExtentBlocks:
+		m = "OpScrubExtentBlocks"
 	case OpLcNodeHeartbeat:
 		m = "OpLcNodeHeartbeat"
 	case OpLcNodeScan:
 		m = "OpLcNodeScan"
 	case OpLcNodeSnapshotVerDel:
 		m = "OpLcNodeSnapshotVerDel"
+	case OpLcNodeFsck:
+		m = "OpLcNodeFsck"
+	case OpLcNodeVolClone:
+		m = "OpLcNodeVolClone"
+	case OpLcNodeTrash:
+		m = "OpLcNodeTrash"
 	case OpMetaReadDirOnly:
 		m = "OpMetaReadDirOnly"
 	case OpBackupRead:
@@ -790,6 +853,8 @@ func (p *Packet) GetResultMsg() (m string) {
 		m = "OpLeaseGenerationNotMatch"
 	case OpWriteOpOfProtoVerForbidden:
 		m = "OpWriteOpOfProtoVerForbidden"
+	case OpBlockCrcMismatchErr:
+		m = "OpBlockCrcMismatchErr"
 	default:
 		return fmt.Sprintf("Unknown ResultCode(%v)", p.ResultCode)
 	}
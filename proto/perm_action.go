@@ -124,9 +124,9 @@ const (
 	OSSDeleteBucketLifecycleConfigurationAction Action = OSSActionPrefix + "DeleteBucketLifecycleConfiguration"
 
 	// Object storage version actions
-	OSSGetBucketVersioningAction Action = OSSActionPrefix + "GetBucketVersioning" // unsupported
-	OSSPutBucketVersioningAction Action = OSSActionPrefix + "PutBucketVersioning" // unsupported
-	OSSListObjectVersionsAction  Action = OSSActionPrefix + "ListObjectVersions"  // unsupported
+	OSSGetBucketVersioningAction Action = OSSActionPrefix + "GetBucketVersioning"
+	OSSPutBucketVersioningAction Action = OSSActionPrefix + "PutBucketVersioning"
+	OSSListObjectVersionsAction  Action = OSSActionPrefix + "ListObjectVersions"
 
 	// Object legal hold actions
 	OSSGetObjectLegalHoldAction Action = OSSActionPrefix + "GetObjectLegalHold" // unsupported
@@ -137,31 +137,38 @@ const (
 	OSSPutObjectRetentionAction Action = OSSActionPrefix + "PutObjectRetention" // unsupported
 
 	// Bucket encryption actions
-	OSSGetBucketEncryptionAction    Action = OSSActionPrefix + "GetBucketEncryption"    // unsupported
-	OSSPutBucketEncryptionAction    Action = OSSActionPrefix + "PutBucketEncryption"    // unsupported
-	OSSDeleteBucketEncryptionAction Action = OSSActionPrefix + "DeleteBucketEncryption" // unsupported
+	OSSGetBucketEncryptionAction    Action = OSSActionPrefix + "GetBucketEncryption"
+	OSSPutBucketEncryptionAction    Action = OSSActionPrefix + "PutBucketEncryption"
+	OSSDeleteBucketEncryptionAction Action = OSSActionPrefix + "DeleteBucketEncryption"
 
 	// Bucket website actions
-	OSSGetBucketWebsiteAction    Action = OSSActionPrefix + "GetBucketWebsite"    // unsupported
-	OSSPutBucketWebsiteAction    Action = OSSActionPrefix + "PutBucketWebsite"    // unsupported
-	OSSDeleteBucketWebsiteAction Action = OSSActionPrefix + "DeleteBucketWebsite" // unsupported
+	OSSGetBucketWebsiteAction    Action = OSSActionPrefix + "GetBucketWebsite"
+	OSSPutBucketWebsiteAction    Action = OSSActionPrefix + "PutBucketWebsite"
+	OSSDeleteBucketWebsiteAction Action = OSSActionPrefix + "DeleteBucketWebsite"
 
 	// Object restore actions
-	OSSRestoreObjectAction Action = OSSActionPrefix + "RestoreObject" // unsupported
+	OSSRestoreObjectAction Action = OSSActionPrefix + "RestoreObject"
+
+	// Object select actions
+	OSSSelectObjectContentAction Action = OSSActionPrefix + "SelectObjectContent"
 
 	// Public access block actions
-	OSSGetPublicAccessBlockAction    Action = OSSActionPrefix + "GetPublicAccessBlock"   // unsupported
-	OSSPutPublicAccessBlockAction    Action = OSSActionPrefix + "PutPublicAccessBlock"   // unsupported
-	OSSDeletePublicAccessBlockAction Action = OSSActionPrefix + "DeletePulicAccessBlock" // unuspported
+	OSSGetPublicAccessBlockAction    Action = OSSActionPrefix + "GetPublicAccessBlock"
+	OSSPutPublicAccessBlockAction    Action = OSSActionPrefix + "PutPublicAccessBlock"
+	OSSDeletePublicAccessBlockAction Action = OSSActionPrefix + "DeletePulicAccessBlock"
 
 	// Bucket request payment actions
 	OSSGetBucketRequestPaymentAction Action = OSSActionPrefix + "GetBucketRequestPayment" // unsupported
 	OSSPutBucketRequestPaymentAction Action = OSSActionPrefix + "PutBucketRequestPayment" // unsupported
 
 	// Bucket replication actions
-	OSSGetBucketReplicationAction    Action = OSSActionPrefix + "GetBucketReplicationAction"    // unsupported
-	OSSPutBucketReplicationAction    Action = OSSActionPrefix + "PutBucketReplicationAction"    // unsupported
-	OSSDeleteBucketReplicationAction Action = OSSActionPrefix + "DeleteBucketReplicationAction" // unsupported
+	OSSGetBucketReplicationAction    Action = OSSActionPrefix + "GetBucketReplicationAction"
+	OSSPutBucketReplicationAction    Action = OSSActionPrefix + "PutBucketReplicationAction"
+	OSSDeleteBucketReplicationAction Action = OSSActionPrefix + "DeleteBucketReplicationAction"
+
+	// Bucket notification actions
+	OSSGetBucketNotificationAction Action = OSSActionPrefix + "GetBucketNotification"
+	OSSPutBucketNotificationAction Action = OSSActionPrefix + "PutBucketNotification"
 
 	// STS actions
 	OSSGetFederationTokenAction Action = OSSActionPrefix + "GetFederationToken"
@@ -241,6 +248,7 @@ var AllActions = []Action{
 	OSSPutBucketWebsiteAction,
 	OSSDeleteBucketWebsiteAction,
 	OSSRestoreObjectAction,
+	OSSSelectObjectContentAction,
 	OSSGetPublicAccessBlockAction,
 	OSSPutPublicAccessBlockAction,
 	OSSDeletePublicAccessBlockAction,
@@ -249,6 +257,8 @@ var AllActions = []Action{
 	OSSGetBucketReplicationAction,
 	OSSPutBucketReplicationAction,
 	OSSDeleteBucketReplicationAction,
+	OSSGetBucketNotificationAction,
+	OSSPutBucketNotificationAction,
 	OSSOptionsObjectAction,
 	OSSGetFederationTokenAction,
 
@@ -0,0 +1,245 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Bucket replication is driven by a durable queue kept in the source volume. ObjectNode appends a task
+// to the queue directory for every replicated change, and the replication worker of LcNode consumes
+// the tasks in order and applies them to the destination bucket over S3.
+
+const (
+	// ReplicationQueueDir is the directory under volume root holding the replication tasks,
+	// it is invisible to S3 clients.
+	ReplicationQueueDir = ".cfs_replication"
+
+	XAttrKeyReplicationConfig = "oss:replication"        // replication configuration of bucket, set on volume root
+	XAttrKeyReplicationTask   = "oss:replication-task"   // task content, set on queue entry
+	XAttrKeyReplicationStatus = "oss:replication-status" // replication status of object
+
+	ReplicationStatusPending   = "PENDING"
+	ReplicationStatusCompleted = "COMPLETED"
+	ReplicationStatusFailed    = "FAILED"
+	ReplicationStatusReplica   = "REPLICA"
+
+	ReplicationOpPut     = "put"
+	ReplicationOpDelete  = "delete"
+	ReplicationOpTagging = "tagging"
+
+	replicationBucketARNPrefix = "arn:aws:s3:::"
+	MaxReplicationRules        = 1000
+)
+
+var (
+	ReplicationErrMissingRules       = errors.New("No replication rules found in request")
+	ReplicationErrTooManyRules       = errors.New("Rules number should not exceed allowed limit of 1000")
+	ReplicationErrInvalidRuleID      = errors.New("Rule ID must be unique and not longer than 255 characters")
+	ReplicationErrInvalidStatus      = errors.New("Rule status must be Enabled or Disabled")
+	ReplicationErrInvalidDestination = errors.New("Destination bucket must be a valid bucket ARN")
+	ReplicationErrInvalidPriority    = errors.New("Rule priority must be unique")
+	ReplicationErrInvalidFilter      = errors.New("Only one of Prefix, Tag or And can be specified in Filter")
+	ReplicationErrDeleteMarkerTag    = errors.New("Delete marker replication is not supported if any Tag filter is specified")
+)
+
+type ReplicationConfiguration struct {
+	XMLNS   string             `xml:"xmlns,attr,omitempty" json:"-"`
+	XMLName xml.Name           `xml:"ReplicationConfiguration" json:"-"`
+	Role    string             `xml:"Role,omitempty" json:"role,omitempty"`
+	Rules   []*ReplicationRule `xml:"Rule" json:"rules"`
+}
+
+type ReplicationRule struct {
+	ID                      string                   `xml:"ID,omitempty" json:"id,omitempty"`
+	Priority                int                      `xml:"Priority,omitempty" json:"priority,omitempty"`
+	Status                  string                   `xml:"Status" json:"status"`
+	Prefix                  *string                  `xml:"Prefix,omitempty" json:"prefix,omitempty"` // deprecated, use Filter instead
+	Filter                  *ReplicationFilter       `xml:"Filter,omitempty" json:"filter,omitempty"`
+	Destination             *ReplicationDestination  `xml:"Destination" json:"destination"`
+	DeleteMarkerReplication *DeleteMarkerReplication `xml:"DeleteMarkerReplication,omitempty" json:"delete_marker_replication,omitempty"`
+}
+
+type ReplicationFilter struct {
+	Prefix *string               `xml:"Prefix,omitempty" json:"prefix,omitempty"`
+	Tag    *ReplicationTag       `xml:"Tag,omitempty" json:"tag,omitempty"`
+	And    *ReplicationFilterAnd `xml:"And,omitempty" json:"and,omitempty"`
+}
+
+type ReplicationFilterAnd struct {
+	Prefix string           `xml:"Prefix,omitempty" json:"prefix,omitempty"`
+	Tags   []ReplicationTag `xml:"Tag" json:"tags,omitempty"`
+}
+
+type ReplicationTag struct {
+	Key   string `xml:"Key" json:"key"`
+	Value string `xml:"Value" json:"value"`
+}
+
+type ReplicationDestination struct {
+	Bucket       string `xml:"Bucket" json:"bucket"`
+	Account      string `xml:"Account,omitempty" json:"account,omitempty"`
+	StorageClass string `xml:"StorageClass,omitempty" json:"storage_class,omitempty"`
+}
+
+type DeleteMarkerReplication struct {
+	Status string `xml:"Status" json:"status"`
+}
+
+// ReplicationTask is a change of object to be replicated.
+type ReplicationTask struct {
+	Op        string `json:"op"`
+	Key       string `json:"key"`
+	VersionId string `json:"version_id,omitempty"`
+	Time      int64  `json:"time"` // unix nano of the change
+}
+
+// BucketName returns the name of destination bucket parsed from ARN.
+func (d *ReplicationDestination) BucketName() string {
+	return strings.TrimPrefix(d.Bucket, replicationBucketARNPrefix)
+}
+
+// Validate checks the replication configuration, and fills rule IDs which are not specified.
+func (c *ReplicationConfiguration) Validate() error {
+	if len(c.Rules) == 0 {
+		return ReplicationErrMissingRules
+	}
+	if len(c.Rules) > MaxReplicationRules {
+		return ReplicationErrTooManyRules
+	}
+	ids := make(map[string]struct{}, len(c.Rules))
+	priorities := make(map[int]struct{}, len(c.Rules))
+	for i, rule := range c.Rules {
+		if rule.ID == "" {
+			rule.ID = fmt.Sprintf("rule-%d", i+1)
+		}
+		if _, ok := ids[rule.ID]; ok || len(rule.ID) > MaxIdLength {
+			return ReplicationErrInvalidRuleID
+		}
+		ids[rule.ID] = struct{}{}
+		if rule.Status != RuleEnabled && rule.Status != RuleDisabled {
+			return ReplicationErrInvalidStatus
+		}
+		if rule.Destination == nil || !strings.HasPrefix(rule.Destination.Bucket, replicationBucketARNPrefix) ||
+			rule.Destination.BucketName() == "" || strings.Contains(rule.Destination.BucketName(), "/") {
+			return ReplicationErrInvalidDestination
+		}
+		if rule.DeleteMarkerReplication != nil && rule.DeleteMarkerReplication.Status != RuleEnabled &&
+			rule.DeleteMarkerReplication.Status != RuleDisabled {
+			return ReplicationErrInvalidStatus
+		}
+		if rule.Filter != nil {
+			specified := 0
+			if rule.Filter.Prefix != nil {
+				specified++
+			}
+			if rule.Filter.Tag != nil {
+				specified++
+			}
+			if rule.Filter.And != nil {
+				specified++
+			}
+			if specified > 1 || rule.Prefix != nil {
+				return ReplicationErrInvalidFilter
+			}
+			// priority is used to choose rule when multiple rules match the same object
+			if _, ok := priorities[rule.Priority]; ok {
+				return ReplicationErrInvalidPriority
+			}
+			priorities[rule.Priority] = struct{}{}
+		}
+		// the tags of deleted object are unknown
+		if rule.IsDeleteMarkerReplicated() && rule.HasTagFilter() {
+			return ReplicationErrDeleteMarkerTag
+		}
+	}
+	return nil
+}
+
+func (r *ReplicationRule) prefix() string {
+	switch {
+	case r.Prefix != nil:
+		return *r.Prefix
+	case r.Filter == nil:
+		return ""
+	case r.Filter.Prefix != nil:
+		return *r.Filter.Prefix
+	case r.Filter.And != nil:
+		return r.Filter.And.Prefix
+	}
+	return ""
+}
+
+func (r *ReplicationRule) tags() []ReplicationTag {
+	switch {
+	case r.Filter == nil:
+		return nil
+	case r.Filter.Tag != nil:
+		return []ReplicationTag{*r.Filter.Tag}
+	case r.Filter.And != nil:
+		return r.Filter.And.Tags
+	}
+	return nil
+}
+
+// HasTagFilter returns true if the rule filters objects by tags.
+func (r *ReplicationRule) HasTagFilter() bool {
+	return len(r.tags()) > 0
+}
+
+// Match returns true if the object with key and tags is replicated by the rule.
+// Tags are ignored if nil is passed.
+func (r *ReplicationRule) Match(key string, tags map[string]string) bool {
+	if r.Status != RuleEnabled || !strings.HasPrefix(key, r.prefix()) {
+		return false
+	}
+	if tags == nil {
+		return true
+	}
+	for _, tag := range r.tags() {
+		if value, ok := tags[tag.Key]; !ok || value != tag.Value {
+			return false
+		}
+	}
+	return true
+}
+
+// IsDeleteMarkerReplicated returns true if delete markers are replicated by the rule.
+func (r *ReplicationRule) IsDeleteMarkerReplicated() bool {
+	return r.DeleteMarkerReplication != nil && r.DeleteMarkerReplication.Status == RuleEnabled
+}
+
+// MatchRule returns the rule with the highest priority which matches the object,
+// it returns nil if the object is not replicated.
+func (c *ReplicationConfiguration) MatchRule(key string, tags map[string]string) *ReplicationRule {
+	if c == nil {
+		return nil
+	}
+	rules := make([]*ReplicationRule, 0, len(c.Rules))
+	for _, rule := range c.Rules {
+		if rule.Match(key, tags) {
+			rules = append(rules, rule)
+		}
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+	sort.SliceStable(rules, func(i, j int) bool { return rules[i].Priority > rules[j].Priority })
+	return rules[0]
+}
@@ -0,0 +1,98 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newReplicationRule(id string, priority int, filter *ReplicationFilter) *ReplicationRule {
+	return &ReplicationRule{
+		ID:          id,
+		Priority:    priority,
+		Status:      RuleEnabled,
+		Filter:      filter,
+		Destination: &ReplicationDestination{Bucket: "arn:aws:s3:::dst"},
+	}
+}
+
+func TestReplicationConfigurationValidate(t *testing.T) {
+	prefix := "logs/"
+
+	config := &ReplicationConfiguration{}
+	require.Equal(t, ReplicationErrMissingRules, config.Validate())
+
+	config = &ReplicationConfiguration{Rules: []*ReplicationRule{newReplicationRule("", 0, nil)}}
+	require.NoError(t, config.Validate())
+	require.Equal(t, "rule-1", config.Rules[0].ID)
+	require.Equal(t, "dst", config.Rules[0].Destination.BucketName())
+
+	config = &ReplicationConfiguration{Rules: []*ReplicationRule{
+		newReplicationRule("a", 0, nil), newReplicationRule("a", 1, nil),
+	}}
+	require.Equal(t, ReplicationErrInvalidRuleID, config.Validate())
+
+	rule := newReplicationRule("a", 0, nil)
+	rule.Status = "enabled"
+	config = &ReplicationConfiguration{Rules: []*ReplicationRule{rule}}
+	require.Equal(t, ReplicationErrInvalidStatus, config.Validate())
+
+	rule = newReplicationRule("a", 0, nil)
+	rule.Destination.Bucket = "dst"
+	config = &ReplicationConfiguration{Rules: []*ReplicationRule{rule}}
+	require.Equal(t, ReplicationErrInvalidDestination, config.Validate())
+
+	config = &ReplicationConfiguration{Rules: []*ReplicationRule{
+		newReplicationRule("a", 1, &ReplicationFilter{Prefix: &prefix}),
+		newReplicationRule("b", 1, &ReplicationFilter{}),
+	}}
+	require.Equal(t, ReplicationErrInvalidPriority, config.Validate())
+
+	config = &ReplicationConfiguration{Rules: []*ReplicationRule{
+		newReplicationRule("a", 1, &ReplicationFilter{Prefix: &prefix, Tag: &ReplicationTag{Key: "k", Value: "v"}}),
+	}}
+	require.Equal(t, ReplicationErrInvalidFilter, config.Validate())
+
+	rule = newReplicationRule("a", 1, &ReplicationFilter{Tag: &ReplicationTag{Key: "k", Value: "v"}})
+	rule.DeleteMarkerReplication = &DeleteMarkerReplication{Status: RuleEnabled}
+	config = &ReplicationConfiguration{Rules: []*ReplicationRule{rule}}
+	require.Equal(t, ReplicationErrDeleteMarkerTag, config.Validate())
+}
+
+func TestReplicationConfigurationMatchRule(t *testing.T) {
+	var config *ReplicationConfiguration
+	require.Nil(t, config.MatchRule("a", nil))
+
+	logs, images := "logs/", "images/"
+	config = &ReplicationConfiguration{Rules: []*ReplicationRule{
+		newReplicationRule("logs", 1, &ReplicationFilter{Prefix: &logs}),
+		newReplicationRule("tagged", 2, &ReplicationFilter{And: &ReplicationFilterAnd{
+			Prefix: logs,
+			Tags:   []ReplicationTag{{Key: "replicate", Value: "true"}},
+		}}),
+		newReplicationRule("images", 3, &ReplicationFilter{Prefix: &images}),
+	}}
+	config.Rules[2].Status = RuleDisabled
+	require.NoError(t, config.Validate())
+
+	require.Equal(t, "tagged", config.MatchRule("logs/1", nil).ID)
+	require.Equal(t, "tagged", config.MatchRule("logs/1", map[string]string{"replicate": "true"}).ID)
+	require.Equal(t, "logs", config.MatchRule("logs/1", map[string]string{"replicate": "false"}).ID)
+	require.Equal(t, "logs", config.MatchRule("logs/1", map[string]string{}).ID)
+	require.Nil(t, config.MatchRule("images/1", nil))
+	require.Nil(t, config.MatchRule("other", nil))
+}
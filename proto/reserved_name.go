@@ -0,0 +1,40 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+// The directories under the volume root keeping the internal data of ObjectNode and LcNode are
+// reserved. They are marked by ReservedDirXAttrKey as they are created, and the clients other than
+// the services maintaining them can neither see nor change them. The entries of the users taking
+// the names before are not marked, they stay accessible, and the services refuse to use the names.
+
+const (
+	// ReservedDirXAttrKey marks the reserved directory created by the services.
+	ReservedDirXAttrKey = "cfs.reserved"
+
+	// VersionArchiveDir is the directory under volume root holding the noncurrent versions and
+	// the delete markers of the objects, it is invisible to S3 clients.
+	VersionArchiveDir = ".cfs_versions"
+)
+
+// IsReservedName reports whether the name is reserved under the volume root, the entry taking
+// it is a reserved directory only if it's marked.
+func IsReservedName(name string) bool {
+	switch name {
+	case VersionArchiveDir, ReplicationQueueDir, RestoreQueueDir:
+		return true
+	default:
+		return false
+	}
+}
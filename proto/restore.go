@@ -0,0 +1,78 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Objects transitioned to BlobStore are restored to a temporary replica copy. ObjectNode links an
+// entry named by the inode of the object into the restore directory for every restore request, and
+// the restore worker of LcNode fills the entry with the data of the object. The entry itself is the
+// restored copy, it is removed by the worker once expired, which releases the replica data.
+
+const (
+	// RestoreQueueDir is the directory under volume root holding the restored copies,
+	// it is invisible to S3 clients.
+	RestoreQueueDir = ".cfs_restore"
+
+	XAttrKeyRestoreTask   = "oss:restore-task" // task content, set on restore entry
+	XAttrKeyRestoreStatus = "oss:restore"      // restore status of object
+
+	MaxRestoreDays = 30000
+)
+
+// RestoreTask is the restore request of an object, it is kept on the restore entry.
+type RestoreTask struct {
+	Key    string `json:"key"`
+	Inode  uint64 `json:"ino"`              // inode of the cold object
+	Days   int    `json:"days"`             // lifetime of the restored copy
+	Expiry int64  `json:"expiry,omitempty"` // unix time the restored copy expires, zero if not restored yet
+	Time   int64  `json:"time"`
+}
+
+// RestoreStatus is the restore status of an object, it is kept on the object.
+type RestoreStatus struct {
+	Ongoing      bool   `json:"ongoing"`
+	Expiry       int64  `json:"expiry,omitempty"` // unix time the restored copy expires
+	Inode        uint64 `json:"ino,omitempty"`    // inode of the restored copy
+	StorageClass uint32 `json:"sc,omitempty"`     // storage class of the restored copy
+}
+
+// IsRestored reports whether the restored copy is available at the time.
+func (s *RestoreStatus) IsRestored(now time.Time) bool {
+	return s != nil && !s.Ongoing && s.Inode != 0 && now.Unix() < s.Expiry
+}
+
+// Header returns the value of x-amz-restore header.
+func (s *RestoreStatus) Header() string {
+	if s == nil {
+		return ""
+	}
+	if s.Ongoing {
+		return `ongoing-request="true"`
+	}
+	return fmt.Sprintf(`ongoing-request="false", expiry-date="%s"`,
+		time.Unix(s.Expiry, 0).UTC().Format(http.TimeFormat))
+}
+
+// RestoreExpiry returns the expiry time of the restored copy. Like S3, the copy expires at
+// midnight UTC of the day after the specified days elapsed.
+func RestoreExpiry(restoreTime time.Time, days int) int64 {
+	expiry := restoreTime.UTC().AddDate(0, 0, days+1)
+	return time.Date(expiry.Year(), expiry.Month(), expiry.Day(), 0, 0, 0, 0, time.UTC).Unix()
+}
@@ -0,0 +1,38 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestoreStatus(t *testing.T) {
+	var status *RestoreStatus
+	require.False(t, status.IsRestored(time.Now()))
+	require.Equal(t, "", status.Header())
+
+	status = &RestoreStatus{Ongoing: true}
+	require.False(t, status.IsRestored(time.Now()))
+	require.Equal(t, `ongoing-request="true"`, status.Header())
+
+	restoreTime := time.Date(2012, 12, 18, 15, 4, 5, 0, time.UTC)
+	status = &RestoreStatus{Expiry: RestoreExpiry(restoreTime, 2), Inode: 10}
+	require.Equal(t, `ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"`, status.Header())
+	require.True(t, status.IsRestored(restoreTime))
+	require.False(t, status.IsRestored(time.Date(2012, 12, 21, 0, 0, 0, 0, time.UTC)))
+}
@@ -0,0 +1,129 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import (
+	"strings"
+	"time"
+)
+
+// The extended attributes recorded on the entry deleted into the trash.
+const (
+	TrashOriginPathKey = "cfs.trash.path" // the absolute path in the volume before the deletion
+	TrashDeleteTimeKey = "cfs.trash.time" // unix seconds of the deletion
+)
+
+// The ways to restore the entry whose original path is taken.
+const (
+	TrashConflictSkip      = "skip"      // the entry is kept in the trash
+	TrashConflictRename    = "rename"    // the entry is restored by the original name with a suffix
+	TrashConflictOverwrite = "overwrite" // the entry replaces the file, or the empty directory, taking the path
+)
+
+// The operations of the trash task run by the lcnode.
+const (
+	TrashOpList    = "list"
+	TrashOpRestore = "restore"
+	TrashOpPurge   = "purge"
+)
+
+// TrashMaxReportEntries limits the entries kept in the report of the trash task.
+const TrashMaxReportEntries = 10000
+
+// TrashEntry is a file deleted into the trash, or an empty directory if the directory itself is
+// deleted.
+type TrashEntry struct {
+	Path       string `json:"path"`      // the absolute path in the volume before the deletion
+	TrashPath  string `json:"trashPath"` // the absolute path in the trash
+	Inode      uint64 `json:"ino"`
+	Mode       uint32 `json:"mode"`
+	Uid        uint32 `json:"uid"`
+	Gid        uint32 `json:"gid"`
+	Size       uint64 `json:"size"`
+	DeleteTime int64  `json:"deleteTime"` // unix seconds, 0 if unknown
+}
+
+// TrashFilter selects the entries in the trash by the original path, the owner and the time of
+// the deletion. The zero values select all but Uid, which is -1 to select any owner.
+type TrashFilter struct {
+	Path string // the entry itself or the entries under the directory
+	Uid  int64  // -1 for any owner
+	From int64  // unix seconds, the entries deleted at or after it
+	To   int64  // unix seconds, the entries deleted before it
+}
+
+func (f *TrashFilter) Match(e *TrashEntry) bool {
+	if f.Path != "" && f.Path != "/" {
+		prefix := strings.TrimSuffix(f.Path, "/")
+		if e.Path != prefix && !strings.HasPrefix(e.Path, prefix+"/") {
+			return false
+		}
+	}
+	if f.Uid >= 0 && uint32(f.Uid) != e.Uid {
+		return false
+	}
+	if (f.From > 0 || f.To > 0) && e.DeleteTime == 0 {
+		return false
+	}
+	if f.From > 0 && e.DeleteTime < f.From {
+		return false
+	}
+	if f.To > 0 && e.DeleteTime >= f.To {
+		return false
+	}
+	return true
+}
+
+// TrashRestoreResult is the result of restoring the entry, the path restored to or the reason it's
+// not restored.
+type TrashRestoreResult struct {
+	Entry    *TrashEntry `json:"entry"`
+	Restored string      `json:"restored,omitempty"`
+	Err      string      `json:"err,omitempty"`
+}
+
+type TrashTaskRequest struct {
+	MasterAddr string
+	LcNodeAddr string
+	Task       *TrashTask
+}
+
+// TrashTask lists, restores or purges the entries in the trash of the subdir selected by the
+// filter.
+type TrashTask struct {
+	Id       string
+	VolName  string
+	Op       string
+	Subdir   string // the subdir mounted by the clients, whose .Trash directory is the trash
+	Filter   *TrashFilter
+	Conflict string // the way to restore the entry whose original path is taken
+}
+
+type TrashTaskResponse struct {
+	ID         string
+	LcNode     string
+	StartTime  *time.Time
+	EndTime    *time.Time
+	UpdateTime *time.Time
+	Done       bool
+	Status     uint8
+	Result     string
+	TrashTask  *TrashTask
+	EntryNum   int64                 // the entries listed, restored or purged
+	FailedNum  int64                 // the entries failed to restore
+	Entries    []*TrashEntry         // the entries listed or purged
+	Restored   []*TrashRestoreResult // the results of the entries restored
+	Truncated  bool                  // the entries or results are more than TrashMaxReportEntries
+}
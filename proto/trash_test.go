@@ -0,0 +1,36 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrashFilterMatch(t *testing.T) {
+	e := &TrashEntry{Path: "/a/b/f", Uid: 100, DeleteTime: 1000}
+	require.True(t, (&TrashFilter{Uid: -1}).Match(e))
+	require.True(t, (&TrashFilter{Path: "/a/b/", Uid: -1}).Match(e))
+	require.True(t, (&TrashFilter{Path: "/a/b/f", Uid: 100}).Match(e))
+	require.False(t, (&TrashFilter{Path: "/a/bb", Uid: -1}).Match(e))
+	require.False(t, (&TrashFilter{Uid: 0}).Match(e))
+
+	require.True(t, (&TrashFilter{Uid: -1, From: 1000, To: 1001}).Match(e))
+	require.False(t, (&TrashFilter{Uid: -1, From: 1001}).Match(e))
+	require.False(t, (&TrashFilter{Uid: -1, To: 1000}).Match(e))
+	// the entries deleted at an unknown time are out of any window
+	require.False(t, (&TrashFilter{Uid: -1, To: 1000}).Match(&TrashEntry{Path: "/a"}))
+}
@@ -64,6 +64,10 @@ type Partition interface {
 	// CommittedIndex returns the current index of the applied raft log in the raft store partition.
 	CommittedIndex() uint64
 
+	// ReadIndex confirms the leadership with the quorum and waits until the log committed
+	// at the time of the call is applied, so the local state machine is safe for a linearizable read.
+	ReadIndex() error
+
 	// Truncate raft log
 	Truncate(index uint64)
 	TryToLeader(nodeID uint64) error
@@ -137,6 +141,9 @@ func (p *partition) IsOfflinePeer() bool {
 	active := 0
 	sumPeers := 0
 	for _, peer := range status.Replicas {
+		if peer.IsLearner {
+			continue
+		}
 		if peer.Active {
 			active++
 		}
@@ -164,6 +171,13 @@ func (p *partition) CommittedIndex() (applied uint64) {
 	return
 }
 
+// ReadIndex confirms the leadership with the quorum and waits until the log committed
+// at the time of the call is applied. It fails if the partition is not the leader.
+func (p *partition) ReadIndex() (err error) {
+	_, err = p.raft.ReadIndex(p.id).Response()
+	return
+}
+
 // Submit submits command data to raft log.
 func (p *partition) Submit(cmd []byte) (resp interface{}, err error) {
 	if !p.IsRaftLeader() {
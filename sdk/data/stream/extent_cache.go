@@ -56,6 +56,7 @@ type ExtentCache struct {
 	root    *btree.BTree
 	discard *btree.BTree
 	verSeq  uint64
+	inline  []byte // the data of the tiny file stored in the inode, never modified in place
 }
 
 // NewExtentCache returns a new extent cache.
@@ -75,13 +76,13 @@ func (cache *ExtentCache) LogOutPut() {
 	})
 }
 
-func (cache *ExtentCache) RefreshForce(inode uint64, force bool, getExtents GetExtentsFunc, isCache bool, openForWrite, isMigration bool) error {
-	gen, size, extents, err := getExtents(inode, isCache, openForWrite, isMigration)
+func (cache *ExtentCache) RefreshForce(inode uint64, force bool, getExtents GetInlineExtentsFunc, isCache bool, openForWrite, isMigration bool) error {
+	gen, size, extents, inline, err := getExtents(inode, isCache, openForWrite, isMigration)
 	if err != nil {
 		return err
 	}
 	// log.LogDebugf("Local ExtentCache before update: ino(%v) gen(%v) size(%v) extents(%v)", inode, cache.gen, cache.size, cache.List())
-	cache.update(gen, size, force, extents)
+	cache.update(gen, size, force, extents, inline)
 	if log.EnableDebug() {
 		log.LogDebugf("[RefreshForce] Local ExtentCache after update: ino(%v) gen(%v) size(%v) extents(%v) openForWrite(%v) isMigration(%v)",
 			inode, cache.gen, cache.size, cache.List(), openForWrite, isMigration)
@@ -90,17 +91,20 @@ func (cache *ExtentCache) RefreshForce(inode uint64, force bool, getExtents GetE
 }
 
 // Refresh refreshes the extent cache.
-func (cache *ExtentCache) Refresh(inode uint64, getExtents GetExtentsFunc, isCache, openForWrite, isMigration bool) error {
+func (cache *ExtentCache) Refresh(inode uint64, getExtents GetInlineExtentsFunc, isCache, openForWrite, isMigration bool) error {
 	if cache.root.Len() > 0 {
 		return nil
 	}
+	if inline, _ := cache.Inline(); len(inline) > 0 {
+		return nil
+	}
 
-	gen, size, extents, err := getExtents(inode, isCache, openForWrite, isMigration)
+	gen, size, extents, inline, err := getExtents(inode, isCache, openForWrite, isMigration)
 	if err != nil {
 		return err
 	}
 
-	cache.update(gen, size, false, extents)
+	cache.update(gen, size, false, extents, inline)
 	if log.EnableDebug() {
 		log.LogDebugf("[Refresh] Local ExtentCache after update: ino(%v) gen(%v) size(%v) extents(%v) openForWrite(%v) isMigration(%v)",
 			inode, cache.gen, cache.size, cache.List(), openForWrite, isMigration)
@@ -108,7 +112,7 @@ func (cache *ExtentCache) Refresh(inode uint64, getExtents GetExtentsFunc, isCac
 	return nil
 }
 
-func (cache *ExtentCache) update(gen, size uint64, force bool, eks []proto.ExtentKey) {
+func (cache *ExtentCache) update(gen, size uint64, force bool, eks []proto.ExtentKey, inline []byte) {
 	cache.Lock()
 	defer cache.Unlock()
 
@@ -120,6 +124,7 @@ func (cache *ExtentCache) update(gen, size uint64, force bool, eks []proto.Exten
 
 	cache.gen = gen
 	cache.size = size
+	cache.inline = inline
 	cache.root.Clear(false)
 	for _, ek := range eks {
 		extent := ek
@@ -316,9 +321,45 @@ func (cache *ExtentCache) TruncDiscard(size uint64) {
 	for _, key := range discardExtents {
 		cache.discard.Delete(&key)
 	}
+	if uint64(len(cache.inline)) > size {
+		cache.inline = cache.inline[:size:size]
+	}
 	log.LogDebugf("truncate ExtentCache discard: ino(%v) size(%v) discard(%v)", cache.inode, size, discardExtents)
 }
 
+// Inline returns the data of the tiny file stored in the inode and the size of the file, the
+// data is nil if the file is stored in extents.
+func (cache *ExtentCache) Inline() (data []byte, size int) {
+	cache.RLock()
+	defer cache.RUnlock()
+	return cache.inline, int(cache.size)
+}
+
+// WriteInline writes the data into the cached inline data after it is written into the inode.
+func (cache *ExtentCache) WriteInline(offset int, data []byte) {
+	cache.Lock()
+	defer cache.Unlock()
+	end := offset + len(data)
+	size := len(cache.inline)
+	if size < end {
+		size = end
+	}
+	inline := make([]byte, size)
+	copy(inline, cache.inline)
+	copy(inline[offset:], data)
+	cache.inline = inline
+	if cache.size < uint64(end) {
+		cache.size = uint64(end)
+	}
+}
+
+// ClearInline drops the cached inline data once it is written into extents.
+func (cache *ExtentCache) ClearInline() {
+	cache.Lock()
+	defer cache.Unlock()
+	cache.inline = nil
+}
+
 // Max returns the max extent key in the cache.
 func (cache *ExtentCache) Max() *proto.ExtentKey {
 	cache.RLock()
@@ -421,6 +462,23 @@ func (cache *ExtentCache) GetEndForAppendWrite(offset uint64, verSeq uint64, nee
 	return ret
 }
 
+// HasCompressedInExtent returns true if any extent key in the same extent as the given one is
+// compressed, the size of such an extent is not the end of the extent key.
+func (cache *ExtentCache) HasCompressedInExtent(ek *proto.ExtentKey) (found bool) {
+	cache.RLock()
+	defer cache.RUnlock()
+
+	cache.root.Ascend(func(i btree.Item) bool {
+		key := i.(*proto.ExtentKey)
+		if key.IsCompressed() && key.IsSameExtent(ek) {
+			found = true
+			return false
+		}
+		return true
+	})
+	return
+}
+
 // PrepareReadRequests classifies the incoming request.
 func (cache *ExtentCache) PrepareReadRequests(offset, size int, data []byte) []*ExtentRequest {
 	requests := make([]*ExtentRequest, 0)
@@ -46,7 +46,11 @@ type (
 	SplitExtentKeyFunc            func(parentInode, inode uint64, key proto.ExtentKey, storageClass uint32) error
 	AppendExtentKeyFunc           func(parentInode, inode uint64, key proto.ExtentKey, discard []proto.ExtentKey, isCache bool, storageClass uint32, isMigration bool) (int, error)
 	GetExtentsFunc                func(inode uint64, isCache bool, openForWrite bool, isMigration bool) (uint64, uint64, []proto.ExtentKey, error)
+	GetInlineExtentsFunc          func(inode uint64, isCache bool, openForWrite bool, isMigration bool) (uint64, uint64, []proto.ExtentKey, []byte, error)
+	WriteInlineFunc               func(inode, offset uint64, data []byte) error
 	TruncateFunc                  func(inode, size uint64, fullPath string) error
+	FallocateFunc                 func(inode uint64, mode uint32, offset, length uint64, fullPath string) error
+	IsSharedExtentFunc            func(partitionID, extentID uint64) bool
 	EvictIcacheFunc               func(inode uint64)
 	LoadBcacheFunc                func(key string, buf []byte, offset uint64, size uint32) (int, error)
 	CacheBcacheFunc               func(key string, buf []byte) error
@@ -80,6 +84,7 @@ var (
 	flushRequestPool   *sync.Pool
 	releaseRequestPool *sync.Pool
 	truncRequestPool   *sync.Pool
+	fallocRequestPool  *sync.Pool
 	evictRequestPool   *sync.Pool
 )
 
@@ -100,6 +105,9 @@ func init() {
 	truncRequestPool = &sync.Pool{New: func() interface{} {
 		return &TruncRequest{}
 	}}
+	fallocRequestPool = &sync.Pool{New: func() interface{} {
+		return &FallocRequest{}
+	}}
 	evictRequestPool = &sync.Pool{New: func() interface{} {
 		return &EvictRequest{}
 	}}
@@ -112,26 +120,30 @@ func SetReqChansize(size int) {
 }
 
 type ExtentConfig struct {
-	Volume            string
-	Masters           []string
-	FollowerRead      bool
-	NearRead          bool
-	Preload           bool
-	ReadRate          int64
-	WriteRate         int64
-	BcacheEnable      bool
-	InnerReq          bool
-	BcacheDir         string
-	MaxStreamerLimit  int64
-	VerReadSeq        uint64
-	OnAppendExtentKey AppendExtentKeyFunc
-	OnSplitExtentKey  SplitExtentKeyFunc
-	OnGetExtents      GetExtentsFunc
-	OnTruncate        TruncateFunc
-	OnEvictIcache     EvictIcacheFunc
-	OnLoadBcache      LoadBcacheFunc
-	OnCacheBcache     CacheBcacheFunc
-	OnEvictBcache     EvictBacheFunc
+	Volume             string
+	Masters            []string
+	FollowerRead       bool
+	NearRead           bool
+	Preload            bool
+	ReadRate           int64
+	WriteRate          int64
+	BcacheEnable       bool
+	InnerReq           bool
+	BcacheDir          string
+	MaxStreamerLimit   int64
+	VerReadSeq         uint64
+	OnAppendExtentKey  AppendExtentKeyFunc
+	OnSplitExtentKey   SplitExtentKeyFunc
+	OnGetExtents       GetExtentsFunc
+	OnGetInlineExtents GetInlineExtentsFunc
+	OnWriteInline      WriteInlineFunc
+	OnTruncate         TruncateFunc
+	OnFallocate        FallocateFunc
+	OnIsSharedExtent   IsSharedExtentFunc
+	OnEvictIcache      EvictIcacheFunc
+	OnLoadBcache       LoadBcacheFunc
+	OnCacheBcache      CacheBcacheFunc
+	OnEvictBcache      EvictBacheFunc
 
 	DisableMetaCache             bool
 	MinWriteAbleDataPartitionCnt int
@@ -176,8 +188,12 @@ type ExtentClient struct {
 	appendExtentKey    AppendExtentKeyFunc
 	splitExtentKey     SplitExtentKeyFunc
 	getExtents         GetExtentsFunc
+	getInlineExtents   GetInlineExtentsFunc
+	writeInline        WriteInlineFunc // May be null, must check before using
 	truncate           TruncateFunc
-	evictIcache        EvictIcacheFunc // May be null, must check before using
+	fallocate          FallocateFunc      // May be null, must check before using
+	isSharedExtent     IsSharedExtentFunc // May be null, must check before using
+	evictIcache        EvictIcacheFunc    // May be null, must check before using
 	loadBcache         LoadBcacheFunc
 	cacheBcache        CacheBcacheFunc
 	evictBcache        EvictBacheFunc
@@ -206,6 +222,18 @@ func (client *ExtentClient) UidIsLimited(uid uint32) bool {
 	return false
 }
 
+func (client *ExtentClient) GidIsLimited(gid uint32) bool {
+	client.dataWrapper.UidLock.RLock()
+	defer client.dataWrapper.UidLock.RUnlock()
+	if gInfo, ok := client.dataWrapper.Gids[gid]; ok {
+		if gInfo.Limited {
+			log.LogDebugf("gid %v is limited", gid)
+			return true
+		}
+	}
+	return false
+}
+
 func (client *ExtentClient) readLimit() bool {
 	return client.readLimiter.Limit() != rate.Inf
 }
@@ -304,7 +332,17 @@ retry:
 	client.appendExtentKey = config.OnAppendExtentKey
 	client.splitExtentKey = config.OnSplitExtentKey
 	client.getExtents = config.OnGetExtents
+	client.getInlineExtents = config.OnGetInlineExtents
+	if client.getInlineExtents == nil {
+		client.getInlineExtents = func(inode uint64, isCache, openForWrite, isMigration bool) (uint64, uint64, []proto.ExtentKey, []byte, error) {
+			gen, size, extents, err := client.getExtents(inode, isCache, openForWrite, isMigration)
+			return gen, size, extents, nil, err
+		}
+	}
+	client.writeInline = config.OnWriteInline
 	client.truncate = config.OnTruncate
+	client.fallocate = config.OnFallocate
+	client.isSharedExtent = config.OnIsSharedExtent
 	client.evictIcache = config.OnEvictIcache
 	client.dataWrapper.InitFollowerRead(config.FollowerRead)
 	client.dataWrapper.SetNearRead(config.NearRead)
@@ -649,6 +687,36 @@ func (client *ExtentClient) Write(inode uint64, offset int, data []byte, flags i
 	return
 }
 
+// isShared tells whether the extent is shared with the source volume of the clone, or with the
+// files cloned by copying the extent keys, the extent must be copied on write then.
+func (client *ExtentClient) isShared(ek *proto.ExtentKey) bool {
+	if client.dataWrapper.IsSharedPartition(ek.PartitionId) {
+		return true
+	}
+	return client.isSharedExtent != nil && client.isSharedExtent(ek.PartitionId, ek.ExtentId)
+}
+
+// Fallocate punches or zeroes the byte range of the file, the dirty data is flushed before the
+// extents are changed by the metanode. The modes only allocating the range are not supported, see
+// proto.IsValidFallocateMode.
+func (client *ExtentClient) Fallocate(inode uint64, mode uint32, offset, length uint64, storageClass uint32, fullPath string) error {
+	prefix := fmt.Sprintf("Fallocate{ino(%v)mode(%v)offset(%v)length(%v)}", inode, mode, offset, length)
+	if client.fallocate == nil || !proto.IsValidFallocateMode(mode) {
+		return syscall.EOPNOTSUPP
+	}
+	s := client.GetStreamer(inode)
+	if s == nil {
+		log.LogErrorf("Prefix(%v): stream is not opened yet", prefix)
+		return syscall.EBADF
+	}
+	err := s.IssueFallocRequest(mode, offset, length, storageClass, fullPath)
+	if err != nil {
+		// the errno is returned as is, for the callers to report it
+		log.LogError(errors.Stack(errors.Trace(err, prefix)))
+	}
+	return err
+}
+
 func (client *ExtentClient) Truncate(mw *meta.MetaWrapper, parentIno uint64, inode uint64, size int, fullPath string) error {
 	prefix := fmt.Sprintf("Truncate{ino(%v)size(%v)}", inode, size)
 	s := client.GetStreamer(inode)
@@ -893,6 +961,12 @@ func (client *ExtentClient) CheckDataPartitionExsit(partitionID uint64) error {
 	return err
 }
 
+// IsSharedPartition returns true if the data partition is shared from the source volume of the
+// clone.
+func (client *ExtentClient) IsSharedPartition(partitionID uint64) bool {
+	return client.dataWrapper.IsSharedPartition(partitionID)
+}
+
 func (client *ExtentClient) GetDataPartitionForWrite(mediaType uint32) error {
 	exclude := make(map[string]struct{})
 	_, err := client.dataWrapper.GetDataPartitionForWrite(exclude, mediaType, 0)
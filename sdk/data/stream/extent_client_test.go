@@ -0,0 +1,91 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stream
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/data/wrapper"
+	"github.com/stretchr/testify/require"
+)
+
+type fallocateCall struct {
+	mode           uint32
+	offset, length uint64
+}
+
+func newFallocateTestClient(calls *[]fallocateCall, eks []proto.ExtentKey, size uint64) *ExtentClient {
+	return &ExtentClient{
+		dataWrapper: &wrapper.Wrapper{},
+		streamers:   make(map[uint64]*Streamer),
+		multiVerMgr: &MultiVerMgr{verList: &proto.VolVersionInfoList{}},
+		fallocate: func(inode uint64, mode uint32, offset, length uint64, fullPath string) error {
+			*calls = append(*calls, fallocateCall{mode: mode, offset: offset, length: length})
+			return nil
+		},
+		getInlineExtents: func(inode uint64, isCache, openForWrite, isMigration bool) (uint64, uint64, []proto.ExtentKey, []byte, error) {
+			return 2, size, eks, nil, nil
+		},
+	}
+}
+
+func TestExtentClientFallocateMode(t *testing.T) {
+	var calls []fallocateCall
+	client := newFallocateTestClient(&calls, nil, 0)
+
+	// allocating without punching or zeroing can not reserve the space
+	for _, mode := range []uint32{0, proto.FallocKeepSize, proto.FallocPunchHole, proto.FallocPunchHole | proto.FallocZeroRange} {
+		err := client.Fallocate(1, mode, 0, 4096, proto.StorageClass_Replica_HDD, "/f")
+		require.Equal(t, syscall.EOPNOTSUPP, err, "mode %#x", mode)
+	}
+	// the valid mode goes to the streamer of the file
+	err := client.Fallocate(1, proto.FallocZeroRange, 0, 4096, proto.StorageClass_Replica_HDD, "/f")
+	require.Equal(t, syscall.EBADF, err)
+	require.Empty(t, calls)
+}
+
+func TestStreamerFallocate(t *testing.T) {
+	var calls []fallocateCall
+	eks := []proto.ExtentKey{{FileOffset: 0, PartitionId: 1, ExtentId: 10, Size: 4096}}
+	client := newFallocateTestClient(&calls, eks, 16384)
+	s := &Streamer{
+		client:    client,
+		inode:     1,
+		extents:   NewExtentCache(1),
+		dirtylist: NewDirtyExtentList(),
+	}
+	s.extents.update(1, 8192, true, []proto.ExtentKey{
+		{FileOffset: 0, PartitionId: 1, ExtentId: 10, Size: 4096},
+		{FileOffset: 4096, PartitionId: 1, ExtentId: 11, Size: 4096},
+	}, nil)
+
+	mode := proto.FallocPunchHole | proto.FallocKeepSize
+	require.NoError(t, s.fallocate(mode, 4096, 4096, proto.StorageClass_Replica_HDD, "/f"))
+	require.Equal(t, []fallocateCall{{mode: mode, offset: 4096, length: 4096}}, calls)
+
+	// the keys punched are dropped from the cache, which is reloaded from the metanode
+	list := s.extents.List()
+	require.Len(t, list, 1)
+	require.Equal(t, uint64(10), list[0].ExtentId)
+	size, _ := s.extents.Size()
+	require.Equal(t, 16384, size)
+
+	// the stream in error never reaches the metanode
+	s.setError()
+	require.Error(t, s.fallocate(proto.FallocZeroRange, 0, 4096, proto.StorageClass_Replica_HDD, "/f"))
+	require.Len(t, calls, 1)
+}
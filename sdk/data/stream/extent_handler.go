@@ -28,6 +28,7 @@ import (
 	"github.com/cubefs/cubefs/sdk/data/wrapper"
 	"github.com/cubefs/cubefs/sdk/meta"
 	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/compressor"
 	"github.com/cubefs/cubefs/util/errors"
 	"github.com/cubefs/cubefs/util/log"
 	"github.com/cubefs/cubefs/util/stat"
@@ -47,6 +48,10 @@ const (
 	maxRetryExpVal   = 26
 )
 
+// The blocks compressed are merged into one key up to the size, which bounds the data read and
+// rewritten in whole for a small read or overwrite of the key.
+const maxCompressedKeySize = 4 * util.BlockSize
+
 var (
 	enableRetryTiny            = false
 	extentAllocRetryIntervalMs = 0 // ms
@@ -183,6 +188,16 @@ type ExtentHandler struct {
 	storageClass uint32
 
 	isMigration bool
+
+	// The encoding the data is compressed with before sent, empty if not compressed.
+	// Set once the handler is created. Every packet of the handler is a block packed in the
+	// extent one after another, and the blocks adjacent are merged into the keys in *pendingKeys*
+	// and *key*.
+	compression string
+	// Updated in *sender* ONLY, the size of the data written into the extent.
+	extentSize int
+	// Created and updated in *receiver* ONLY, the keys finished before *key* to be appended.
+	pendingKeys []proto.ExtentKey
 }
 
 // NewExtentHandler returns a new extent handler.
@@ -263,19 +278,34 @@ func (eh *ExtentHandler) write(data []byte, offset, size int, direct bool) (ek *
 		}
 		packsize := int(eh.packet.Size)
 		write = util.Min(size-total, blksize-packsize)
+		if eh.compression != "" {
+			// the packet never crosses the boundary of the blocks of the file, so that the block
+			// compressed is rewritten in whole by the overwrite
+			write = util.Min(write, blksize-(offset+total)%blksize)
+		}
 		if write > 0 {
 			copy(eh.packet.Data[packsize:packsize+write], data[total:total+write])
 			eh.packet.Size += uint32(write)
 			total += write
 		}
 
-		if int(eh.packet.Size) >= blksize {
+		if int(eh.packet.Size) >= blksize || eh.compression != "" && (offset+total)%blksize == 0 {
 			eh.flushPacket()
 		}
 	}
 
 	eh.size += total
 
+	if eh.compression != "" {
+		// The data of the handler is kept in many keys, so the local key covers this write only,
+		// and is replaced by the key of the block once it is appended.
+		ek = &proto.ExtentKey{
+			FileOffset: uint64(offset),
+			Size:       uint32(total),
+		}
+		return ek, nil
+	}
+
 	// This is just a local cache to prepare write requests.
 	// Partition and extent are not allocated.
 	ek = &proto.ExtentKey{
@@ -323,6 +353,10 @@ func (eh *ExtentHandler) sender() {
 			if eh.key != nil {
 				extOffset += int(eh.key.ExtentOffset)
 			}
+			if eh.compression != "" {
+				extOffset = eh.extentSize
+				eh.extentSize += int(packet.Size)
+			}
 
 			// fill the packet according to the extent
 			packet.PartitionID = eh.dp.PartitionID
@@ -458,7 +492,9 @@ func (eh *ExtentHandler) processReply(packet *Packet) {
 	if verUpdate {
 		fileOffset = reply.KernelOffset
 	}
-	if eh.key == nil || verUpdate {
+	if eh.compression != "" {
+		eh.addCompressedKey(packet, reply.VerSeq)
+	} else if eh.key == nil || verUpdate {
 		eh.key = &proto.ExtentKey{
 			FileOffset:   fileOffset,
 			PartitionId:  packet.PartitionID,
@@ -484,6 +520,41 @@ func (eh *ExtentHandler) processReply(packet *Packet) {
 	}
 }
 
+// addCompressedKey records the key of the packet written by the handler compressing the data.
+// The blocks compressed are merged into one key up to maxCompressedKeySize, and the blocks sent as
+// is into another, if they are contiguous in both the file and the extent.
+func (eh *ExtentHandler) addCompressedKey(packet *Packet, verSeq uint64) {
+	key := &proto.ExtentKey{
+		FileOffset:   packet.KernelOffset,
+		PartitionId:  packet.PartitionID,
+		ExtentId:     packet.ExtentID,
+		ExtentOffset: uint64(packet.ExtentOffset),
+		Size:         packet.rawSize,
+		SnapInfo: &proto.ExtSnapInfo{
+			VerSeq: verSeq,
+		},
+	}
+	if packet.Size < packet.rawSize {
+		key.CompressSize = packet.Size
+	}
+	if last := eh.key; last != nil {
+		if last.IsCompressed() == key.IsCompressed() && last.IsSameExtent(key) &&
+			last.FileOffset+uint64(last.Size) == key.FileOffset &&
+			last.ExtentOffset+uint64(last.PhysicalSize()) == key.ExtentOffset &&
+			(!key.IsCompressed() || last.Size+key.Size <= maxCompressedKeySize) {
+			last.Size += key.Size
+			if key.IsCompressed() {
+				last.CompressSize += key.CompressSize
+			}
+			return
+		}
+		if eh.dirty {
+			eh.pendingKeys = append(eh.pendingKeys, *last)
+		}
+	}
+	eh.key = key
+}
+
 func (eh *ExtentHandler) processReplyError(packet *Packet, errmsg string) {
 	log.LogDebugf("processReplyError begin: eh(%v) packet(%v) errmsg(%v)", eh, packet, errmsg)
 	eh.setClosed()
@@ -544,6 +615,11 @@ func (eh *ExtentHandler) appendExtentKey() (err error) {
 	eh.appendLK.Lock()
 	defer eh.appendLK.Unlock()
 
+	if err = eh.appendPendingKeys(); err != nil {
+		log.LogErrorf("action[appendExtentKey] %v append pending keys err %v", eh, err)
+		return
+	}
+
 	if eh.key != nil {
 		if eh.dirty {
 			if proto.IsCold(eh.stream.client.volumeType) || proto.IsStorageClassBlobStore(eh.storageClass) &&
@@ -619,6 +695,23 @@ func (eh *ExtentHandler) appendExtentKey() (err error) {
 	return
 }
 
+// appendPendingKeys appends the keys of the blocks finished before *key* in order.
+func (eh *ExtentHandler) appendPendingKeys() (err error) {
+	for len(eh.pendingKeys) > 0 {
+		ekey := eh.pendingKeys[0]
+		discard := eh.stream.extents.Append(&ekey, true)
+		if _, err = eh.stream.client.appendExtentKey(eh.stream.parentInode, eh.inode, ekey, discard, eh.stream.isCache, eh.storageClass, eh.isMigration); err != nil {
+			return
+		}
+		if len(discard) > 0 {
+			eh.stream.extents.RemoveDiscard(discard)
+		}
+		eh.pendingKeys = eh.pendingKeys[1:]
+	}
+	eh.pendingKeys = nil
+	return
+}
+
 // This function is meaningful to be called from stream writer flush method,
 // because there is no new write request.
 func (eh *ExtentHandler) waitForFlush() (err error) {
@@ -673,6 +766,7 @@ func (eh *ExtentHandler) recoverPacket(packet *Packet) error {
 		}
 
 		handler = NewExtentHandler(eh.stream, int(packet.KernelOffset), extentType, 0, eh.storageClass, eh.isMigration)
+		handler.compression = eh.compression
 		handler.setClosed()
 	}
 	handler.pushToRequest(packet)
@@ -817,11 +911,29 @@ func (eh *ExtentHandler) flushPacket() {
 		return
 	}
 
+	if eh.compression != "" {
+		eh.compressPacket(eh.packet)
+	}
 	eh.pushToRequest(eh.packet)
 	eh.packet = nil
 	log.LogDebugf("ExtentHandler flushPacket end: eh(%v)", eh)
 }
 
+// compressPacket replaces the data of the packet with the block compressed, unless the block is
+// not smaller.
+func (eh *ExtentHandler) compressPacket(packet *Packet) {
+	packet.rawSize = packet.Size
+	block, err := compressor.CompressBlock(eh.compression, packet.Data[:packet.Size])
+	if err != nil {
+		log.LogWarnf("ExtentHandler compressPacket: eh(%v) packet(%v) err(%v)", eh, packet, err)
+		return
+	}
+	if len(block) >= int(packet.Size) {
+		return
+	}
+	packet.Size = uint32(copy(packet.Data, block))
+}
+
 func (eh *ExtentHandler) pushToRequest(packet *Packet) {
 	// Increase before sending the packet, because inflight is used
 	// to determine if the handler has finished.
@@ -0,0 +1,97 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stream
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/compressor"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtentHandlerCompressPacket(t *testing.T) {
+	eh := &ExtentHandler{compression: compressor.EncodingZstd}
+
+	data := bytes.Repeat([]byte("compressed "), util.BlockSize/11)
+	packet := &Packet{}
+	packet.Data = make([]byte, util.BlockSize)
+	packet.Size = uint32(copy(packet.Data, data))
+	eh.compressPacket(packet)
+	require.Equal(t, uint32(len(data)), packet.rawSize)
+	require.Less(t, packet.Size, packet.rawSize)
+	raw, err := compressor.DecompressBlock(packet.Data[:packet.Size])
+	require.NoError(t, err)
+	require.Equal(t, data, raw)
+
+	// the data not smaller after compression is sent as is
+	data = make([]byte, util.BlockSize)
+	rand.New(rand.NewSource(1)).Read(data)
+	packet.Size = uint32(copy(packet.Data, data))
+	eh.compressPacket(packet)
+	require.Equal(t, packet.Size, packet.rawSize)
+	require.Equal(t, data, packet.Data[:packet.Size])
+}
+
+func TestExtentHandlerAddCompressedKey(t *testing.T) {
+	eh := &ExtentHandler{compression: compressor.EncodingZstd}
+	extentOffset := 0
+	add := func(rawSize, size int) {
+		packet := &Packet{rawSize: uint32(rawSize)}
+		packet.KernelOffset = uint64(eh.size)
+		packet.PartitionID, packet.ExtentID = 1, 1025
+		packet.ExtentOffset = int64(extentOffset)
+		packet.Size = uint32(size)
+		eh.addCompressedKey(packet, 0)
+		eh.size += rawSize
+		extentOffset += size
+		eh.dirty = true
+	}
+
+	// the blocks compressed are merged into one key up to maxCompressedKeySize
+	blocks := maxCompressedKeySize / util.BlockSize
+	for i := 0; i < blocks+1; i++ {
+		add(util.BlockSize, 1000)
+	}
+	require.Len(t, eh.pendingKeys, 1)
+	key := eh.pendingKeys[0]
+	require.Equal(t, uint64(0), key.FileOffset)
+	require.Equal(t, uint32(maxCompressedKeySize), key.Size)
+	require.Equal(t, uint64(0), key.ExtentOffset)
+	require.Equal(t, uint32(blocks*1000), key.CompressSize)
+	require.Equal(t, uint64(maxCompressedKeySize), eh.key.FileOffset)
+	require.Equal(t, uint32(1000), eh.key.CompressSize)
+
+	// the blocks sent as is are merged into a key of their own
+	add(util.BlockSize, util.BlockSize)
+	add(100, 100)
+	require.Len(t, eh.pendingKeys, 2)
+	require.False(t, eh.key.IsCompressed())
+	require.Equal(t, uint32(util.BlockSize+100), eh.key.Size)
+	require.Equal(t, uint64(blocks*1000+1000), eh.key.ExtentOffset)
+
+	add(util.BlockSize, 2000)
+	require.Len(t, eh.pendingKeys, 3)
+	require.Equal(t, uint32(2000), eh.key.CompressSize)
+
+	// the key appended already is not pending again
+	eh.dirty = false
+	eh.addCompressedKey(&Packet{rawSize: 100, Packet: proto.Packet{PartitionID: 1, ExtentID: 1026, Size: 100}}, 0)
+	require.Len(t, eh.pendingKeys, 3)
+	require.Equal(t, uint64(1026), eh.key.ExtentId)
+}
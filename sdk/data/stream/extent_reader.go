@@ -24,10 +24,17 @@ import (
 	"github.com/cubefs/cubefs/proto"
 	"github.com/cubefs/cubefs/sdk/data/wrapper"
 	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/compressor"
 	"github.com/cubefs/cubefs/util/errors"
 	"github.com/cubefs/cubefs/util/log"
 )
 
+// reportBadReplica reports the replica whose data fails the crc check of the blocks to the master,
+// which repairs the blocks from the other replicas.
+var reportBadReplica = func(dp *wrapper.DataPartition, addr string, extentID uint64, offset, size int64) {
+	dp.ClientWrapper.ReportBadReplica(dp.PartitionID, addr, extentID, offset, size)
+}
+
 // ExtentReader defines the struct of the extent reader.
 type ExtentReader struct {
 	inode        uint64
@@ -58,24 +65,76 @@ func (reader *ExtentReader) String() (m string) {
 
 // Read reads the extent request.
 func (reader *ExtentReader) Read(req *ExtentRequest) (readBytes int, err error) {
+	if reader.key.IsCompressed() {
+		return reader.readCompressed(req)
+	}
+
+	// NOTE: the blocks the request covers in whole are verified by the crc sent with them, and the
+	// others by the datanode
 	offset := req.FileOffset - int(reader.key.FileOffset) + int(reader.key.ExtentOffset)
-	size := req.Size
+	return reader.read(req, offset, req.Data[:req.Size], req.FileOffset)
+}
+
+// readCompressed reads the blocks the extent key is compressed into in whole, and decompresses the
+// ones the request covers.
+func (reader *ExtentReader) readCompressed(req *ExtentRequest) (readBytes int, err error) {
+	blocks := make([]byte, reader.key.CompressSize)
+	n, err := reader.read(req, int(reader.key.ExtentOffset), blocks, int(reader.key.FileOffset))
+	if err != nil {
+		return 0, err
+	}
+	if n != len(blocks) {
+		return 0, errors.New(fmt.Sprintf("readCompressed: short read of the blocks, key(%v) readBytes(%v)", reader.key, n))
+	}
+	start := int(reader.key.CompressOffset) + req.FileOffset - int(reader.key.FileOffset)
+	data, err := compressor.DecompressBlocks(blocks, start, req.Size)
+	if err != nil {
+		log.LogErrorf("Extent Reader readCompressed: key(%v) req(%v) err(%v)", reader.key, req, err)
+		return 0, err
+	}
+	readBytes = copy(req.Data[:req.Size], data)
+	return
+}
 
-	reqPacket := NewReadPacket(reader.key, offset, size, reader.inode, req.FileOffset, reader.followerRead)
+// read reads the range of the extent starting at the offset into the data.
+func (reader *ExtentReader) read(req *ExtentRequest, readOffset int, data []byte, kernelOffset int) (readBytes int, err error) {
+	readSize := len(data)
+	reqPacket := NewReadPacket(reader.key, readOffset, readSize, reader.inode, kernelOffset, reader.followerRead)
 	sc := NewStreamConn(reader.dp, reader.followerRead, reader.maxRetryTimeout)
 
-	log.LogDebugf("ExtentReader Read enter: size(%v) req(%v) reqPacket(%v)", size, req, reqPacket)
+	log.LogDebugf("ExtentReader Read enter: size(%v) req(%v) reqPacket(%v)", req.Size, req, reqPacket)
+
+	err = sc.Send(&reader.retryRead, reqPacket, reader.getReply(reqPacket, data, &readBytes))
+	if err == BlockCrcMismatchError {
+		log.LogErrorf("Extent Reader Read: block crc mismatch, addr(%v) req(%v) reqPacket(%v)", sc.currAddr, req, reqPacket)
+		reportBadReplica(reader.dp, sc.currAddr, reader.key.ExtentId, int64(readOffset), int64(readSize))
+		readBytes, err = reader.readFromOtherReplicas(reqPacket, data, sc.currAddr)
+	}
+
+	if err != nil {
+		// if cold vol and cach is invaild
+		if !reader.retryRead && (err == TryOtherAddrError || strings.Contains(err.Error(), "ExistErr")) {
+			log.LogWarnf("Extent Reader Read: err(%v) req(%v) reqPacket(%v)", err, req, reqPacket)
+		} else {
+			log.LogErrorf("Extent Reader Read: err(%v) req(%v) reqPacket(%v)", err, req, reqPacket)
+		}
+	}
+
+	log.LogDebugf("ExtentReader Read exit: req(%v) reqPacket(%v) readBytes(%v) err(%v)", req, reqPacket, readBytes, err)
+	return
+}
 
-	err = sc.Send(&reader.retryRead, reqPacket, func(conn *net.TCPConn) (error, bool) {
-		readBytes = 0
-		for readBytes < size {
+func (reader *ExtentReader) getReply(reqPacket *Packet, data []byte, readBytes *int) GetReplyFunc {
+	return func(conn *net.TCPConn) (error, bool) {
+		*readBytes = 0
+		for *readBytes < len(data) {
 			replyPacket := NewReply(reqPacket.ReqID, reader.dp.PartitionID, reqPacket.ExtentID)
-			bufSize := util.Min(util.ReadBlockSize, size-readBytes)
-			replyPacket.Data = req.Data[readBytes : readBytes+bufSize]
+			bufSize := util.Min(util.ReadBlockSize, len(data)-*readBytes)
+			replyPacket.Data = data[*readBytes : *readBytes+bufSize]
 			e := replyPacket.readFromConn(conn, proto.ReadDeadlineTime)
 
 			if e != nil {
-				log.LogWarnf("Extent Reader Read: failed to read from connect, ino(%v) req(%v) readBytes(%v) err(%v)", reader.inode, reqPacket, readBytes, e)
+				log.LogWarnf("Extent Reader Read: failed to read from connect, ino(%v) reqPacket(%v) readBytes(%v) err(%v)", reader.inode, reqPacket, *readBytes, e)
 				// Upon receiving TryOtherAddrError, other hosts will be retried.
 				return TryOtherAddrError, false
 			}
@@ -97,21 +156,41 @@ func (reader *ExtentReader) Read(req *ExtentRequest) (readBytes int, err error)
 				return e, false
 			}
 
-			readBytes += int(replyPacket.Size)
+			*readBytes += int(replyPacket.Size)
 		}
 		return nil, false
-	})
+	}
+}
 
-	if err != nil {
-		// if cold vol and cach is invaild
-		if !reader.retryRead && (err == TryOtherAddrError || strings.Contains(err.Error(), "ExistErr")) {
-			log.LogWarnf("Extent Reader Read: err(%v) req(%v) reqPacket(%v)", err, req, reqPacket)
+// readFromOtherReplicas reads the request again from the replicas other than the one whose data
+// doesn't match the crc of the blocks, and reports the replicas failing the check too.
+func (reader *ExtentReader) readFromOtherReplicas(reqPacket *Packet, data []byte, badAddr string) (readBytes int, err error) {
+	err = BlockCrcMismatchError
+	for _, addr := range reader.dp.Hosts {
+		if addr == "" || addr == badAddr {
+			continue
+		}
+		conn, e := StreamConnPool.GetConnect(addr)
+		if e != nil {
+			log.LogWarnf("readFromOtherReplicas: failed to get connection to addr(%v) reqPacket(%v) err(%v)", addr, reqPacket, e)
+			continue
+		}
+		p := NewReadPacket(reader.key, int(reqPacket.ExtentOffset), int(reqPacket.Size), reader.inode, int(reqPacket.KernelOffset), true)
+		p.ExtentType |= proto.PacketProtocolVersionFlag
+		sc := &StreamConn{dp: reader.dp, currAddr: addr}
+		err = sc.sendToConn(conn, p, reader.getReply(p, data, &readBytes))
+		StreamConnPool.PutConnectEx(conn, err)
+		if err == nil {
+			log.LogWarnf("readFromOtherReplicas: read from addr(%v) reqPacket(%v)", addr, p)
+			return
+		}
+		if err == BlockCrcMismatchError {
+			log.LogErrorf("readFromOtherReplicas: block crc mismatch, addr(%v) reqPacket(%v)", addr, p)
+			reportBadReplica(reader.dp, addr, reader.key.ExtentId, p.ExtentOffset, int64(p.Size))
 		} else {
-			log.LogErrorf("Extent Reader Read: err(%v) req(%v) reqPacket(%v)", err, req, reqPacket)
+			log.LogWarnf("readFromOtherReplicas: addr(%v) reqPacket(%v) err(%v)", addr, p, err)
 		}
 	}
-
-	log.LogDebugf("ExtentReader Read exit: req(%v) reqPacket(%v) readBytes(%v) err(%v)", req, reqPacket, readBytes, err)
 	return
 }
 
@@ -124,6 +203,11 @@ func (reader *ExtentReader) checkStreamReply(request *Packet, reply *Packet) (er
 		return ExtentNotFoundError
 	}
 
+	// the blocks read in part are verified by the datanode
+	if reply.ResultCode == proto.OpBlockCrcMismatchErr {
+		return BlockCrcMismatchError
+	}
+
 	if reply.ResultCode != proto.OpOk {
 		if request.Opcode == proto.OpStreamFollowerRead && reply.ResultCode != proto.OpForbidErr {
 			log.LogWarnf("checkStreamReply: ResultCode(%v) NOK, OpStreamFollowerRead return TryOtherAddrError, "+
@@ -142,5 +226,12 @@ func (reader *ExtentReader) checkStreamReply(request *Packet, reply *Packet) (er
 		err = errors.New(fmt.Sprintf("checkStreamReply: inconsistent CRC, expectCRC(%v) replyCRC(%v)", expectCrc, reply.CRC))
 		return
 	}
+	if reply.ArgLen > 0 {
+		crcs := proto.UnmarshalBlockCrcs(reply.Arg[:reply.ArgLen])
+		if e := proto.VerifyBlockCrcs(reply.ExtentOffset, reply.Data[:reply.Size], crcs); e != nil {
+			log.LogErrorf("checkStreamReply: req(%v) reply(%v) err(%v)", request, reply, e)
+			return BlockCrcMismatchError
+		}
+	}
 	return nil
 }
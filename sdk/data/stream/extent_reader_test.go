@@ -0,0 +1,223 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stream
+
+import (
+	"bytes"
+	"hash/crc32"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/data/wrapper"
+	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/compressor"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeReplica serves the reads of a normal extent, replying with the data of the replica and the
+// crc of the blocks stored, or with the result code of the partial block check of the datanode.
+type fakeReplica struct {
+	ln         net.Listener
+	data       []byte   // the extent stored
+	crcs       []uint32 // the crc of the blocks stored
+	resultCode uint8
+}
+
+func newFakeReplica(t *testing.T, data []byte, crcs []uint32, resultCode uint8) *fakeReplica {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	r := &fakeReplica{ln: ln, data: data, crcs: crcs, resultCode: resultCode}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go r.serve(conn)
+		}
+	}()
+	return r
+}
+
+func (r *fakeReplica) addr() string {
+	return r.ln.Addr().String()
+}
+
+func (r *fakeReplica) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		req := proto.NewPacket()
+		if err := req.ReadFromConnWithVer(conn, proto.ReadDeadlineTime); err != nil {
+			return
+		}
+		if r.resultCode != proto.OpOk {
+			reply := r.newReply(req, req.ExtentOffset)
+			reply.ResultCode = r.resultCode
+			if err := reply.WriteToConn(conn); err != nil {
+				return
+			}
+			continue
+		}
+		// the reply ends at the boundary of the block, as the datanode does
+		for offset, end := req.ExtentOffset, req.ExtentOffset+int64(req.Size); offset < end; {
+			size := util.Min(int(end-offset), util.ReadBlockSize-int(offset%util.BlockSize))
+			reply := r.newReply(req, offset)
+			reply.ResultCode = proto.OpOk
+			reply.Data = r.data[offset : offset+int64(size)]
+			reply.Size = uint32(size)
+			reply.CRC = crc32.ChecksumIEEE(reply.Data)
+			reply.Arg = proto.MarshalBlockCrcs(r.crcs[offset/util.BlockSize : (offset+int64(size)-1)/util.BlockSize+1])
+			reply.ArgLen = uint32(len(reply.Arg))
+			if err := reply.WriteToConn(conn); err != nil {
+				return
+			}
+			offset += int64(size)
+		}
+	}
+}
+
+func (r *fakeReplica) newReply(req *proto.Packet, offset int64) *proto.Packet {
+	reply := proto.NewPacket()
+	reply.ReqID, reply.PartitionID, reply.ExtentID = req.ReqID, req.PartitionID, req.ExtentID
+	reply.Opcode = req.Opcode
+	reply.ExtentOffset = offset
+	return reply
+}
+
+type badReplicaReport struct {
+	addr         string
+	offset, size int64
+}
+
+func testBlockCrcRead(t *testing.T, replicas []*fakeReplica, offset, size int) (data []byte, reports []badReplicaReport, err error) {
+	var mu sync.Mutex
+	report := reportBadReplica
+	reportBadReplica = func(dp *wrapper.DataPartition, addr string, extentID uint64, offset, size int64) {
+		mu.Lock()
+		defer mu.Unlock()
+		reports = append(reports, badReplicaReport{addr: addr, offset: offset, size: size})
+	}
+	defer func() { reportBadReplica = report }()
+
+	dp := &wrapper.DataPartition{}
+	dp.PartitionID = 1
+	for _, r := range replicas {
+		dp.Hosts = append(dp.Hosts, r.addr())
+	}
+	dp.LeaderAddr = dp.Hosts[0]
+	ek := &proto.ExtentKey{PartitionId: 1, ExtentId: 1025, Size: uint32(len(replicas[0].data))}
+	reader := NewExtentReader(1, ek, dp, false, true)
+	reader.maxRetryTimeout = time.Second
+
+	data = make([]byte, size)
+	n, err := reader.Read(NewExtentRequest(offset, size, data, ek))
+	return data[:n], reports, err
+}
+
+func TestExtentReaderBlockCrcMismatch(t *testing.T) {
+	proto.InitBufferPool(int64(32768))
+	good := make([]byte, 2*util.BlockSize)
+	for i := range good {
+		good[i] = byte(i)
+	}
+	crcs := []uint32{crc32.ChecksumIEEE(good[:util.BlockSize]), crc32.ChecksumIEEE(good[util.BlockSize:])}
+	bad := make([]byte, len(good))
+	copy(bad, good)
+	bad[util.BlockSize+100] ^= 0xff
+
+	healthy := newFakeReplica(t, good, crcs, proto.OpOk)
+	defer healthy.ln.Close()
+	corrupt := newFakeReplica(t, bad, crcs, proto.OpOk)
+	defer corrupt.ln.Close()
+	// the block read in part is checked by the datanode, which refuses the read
+	refused := newFakeReplica(t, bad, crcs, proto.OpBlockCrcMismatchErr)
+	defer refused.ln.Close()
+
+	// the block read in whole fails the crc check on the client, and is read again from the
+	// other replica
+	data, reports, err := testBlockCrcRead(t, []*fakeReplica{corrupt, healthy}, util.BlockSize, util.BlockSize)
+	require.NoError(t, err)
+	require.Equal(t, good[util.BlockSize:], data)
+	require.Equal(t, []badReplicaReport{{addr: corrupt.addr(), offset: util.BlockSize, size: util.BlockSize}}, reports)
+
+	data, reports, err = testBlockCrcRead(t, []*fakeReplica{refused, healthy}, util.BlockSize+10, 4096)
+	require.NoError(t, err)
+	require.Equal(t, good[util.BlockSize+10:util.BlockSize+10+4096], data)
+	require.Equal(t, []badReplicaReport{{addr: refused.addr(), offset: util.BlockSize + 10, size: 4096}}, reports)
+
+	// all the replicas fail the check
+	_, reports, err = testBlockCrcRead(t, []*fakeReplica{refused, corrupt}, util.BlockSize, util.BlockSize)
+	require.Equal(t, BlockCrcMismatchError, err)
+	require.Len(t, reports, 2)
+	require.Equal(t, refused.addr(), reports[0].addr)
+	require.Equal(t, corrupt.addr(), reports[1].addr)
+
+	// the data read from the healthy replica is not checked again
+	data, reports, err = testBlockCrcRead(t, []*fakeReplica{healthy, corrupt}, 0, 2*util.BlockSize)
+	require.NoError(t, err)
+	require.Equal(t, good, data)
+	require.Empty(t, reports)
+}
+
+func TestExtentReaderReadCompressed(t *testing.T) {
+	proto.InitBufferPool(int64(32768))
+	raw := bytes.Repeat([]byte("compressed blocks "), 3*util.BlockSize/18)
+	var extent []byte
+	for offset := 0; offset < len(raw); offset += util.BlockSize {
+		block, err := compressor.CompressBlock(compressor.EncodingLz4, raw[offset:util.Min(offset+util.BlockSize, len(raw))])
+		require.NoError(t, err)
+		extent = append(extent, block...)
+	}
+	// the key is packed after other data in the extent
+	extent = append(make([]byte, 100), extent...)
+	var crcs []uint32
+	for offset := 0; offset < len(extent); offset += util.BlockSize {
+		crcs = append(crcs, crc32.ChecksumIEEE(extent[offset:util.Min(offset+util.BlockSize, len(extent))]))
+	}
+	replica := newFakeReplica(t, extent, crcs, proto.OpOk)
+	defer replica.ln.Close()
+
+	dp := &wrapper.DataPartition{}
+	dp.PartitionID = 1
+	dp.Hosts = []string{replica.addr()}
+	dp.LeaderAddr = dp.Hosts[0]
+	read := func(ek *proto.ExtentKey, offset, size int) []byte {
+		reader := NewExtentReader(1, ek, dp, false, false)
+		reader.maxRetryTimeout = time.Second
+		data := make([]byte, size)
+		n, err := reader.Read(NewExtentRequest(offset, size, data, ek))
+		require.NoError(t, err)
+		return data[:n]
+	}
+
+	fileOffset := uint64(1 << 20)
+	ek := &proto.ExtentKey{
+		FileOffset: fileOffset, PartitionId: 1, ExtentId: 1025, ExtentOffset: 100,
+		Size: uint32(len(raw)), CompressSize: uint32(len(extent) - 100),
+	}
+	require.Equal(t, raw, read(ek, int(fileOffset), len(raw)))
+	require.Equal(t, raw[util.BlockSize-10:util.BlockSize+10], read(ek, int(fileOffset)+util.BlockSize-10, 20))
+
+	// the key split by the overwrite of its head refers to the data after the offset in the blocks
+	split := *ek
+	split.FileOffset += util.BlockSize + 5
+	split.Size -= util.BlockSize + 5
+	split.SkipHead(util.BlockSize + 5)
+	require.Equal(t, raw[util.BlockSize+5:util.BlockSize+105], read(&split, int(split.FileOffset), 100))
+	require.Equal(t, raw[2*util.BlockSize:], read(&split, int(fileOffset)+2*util.BlockSize, len(raw)-2*util.BlockSize))
+}
@@ -32,6 +32,9 @@ type Packet struct {
 	proto.Packet
 	inode    uint64
 	errCount int
+	// the size of the data before compression, set by the handler compressing the data. The data
+	// is sent as is if it is not smaller after compression.
+	rawSize uint32
 }
 
 // String returns the string format of the packet.
@@ -134,7 +137,7 @@ func NewReadPacket(key *proto.ExtentKey, extentOffset, size int, inode uint64, f
 	} else {
 		p.Opcode = proto.OpStreamRead
 	}
-	p.ExtentType = proto.NormalExtentType
+	p.ExtentType = proto.NormalExtentType | proto.BlockCrcFlag
 	p.ReqID = proto.GenerateRequestID()
 	p.RemainingFollowers = 0
 	p.inode = inode
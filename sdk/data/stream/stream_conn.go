@@ -34,6 +34,12 @@ var (
 	DpDiscardError      = errors.New("DpDiscardError")
 	LimitedIoError      = errors.New("LimitedIoError")
 	ExtentNotFoundError = errors.New("ExtentNotFoundError")
+	// BlockCrcMismatchError is returned if the data read from the replica doesn't match the crc of
+	// the blocks stored, which is read again from the other replicas.
+	BlockCrcMismatchError = errors.New("BlockCrcMismatchError")
+	// SharedExtentError is returned if the datanode refuses to overwrite the extent shared by the
+	// files cloned, which is copied on write then.
+	SharedExtentError = errors.New("SharedExtentError")
 )
 
 const (
@@ -134,7 +140,7 @@ func (sc *StreamConn) Send(retry *bool, req *Packet, getReply GetReplyFunc) (err
 
 	for i := 0; i < StreamSendMaxRetry; i++ {
 		err = sc.sendToDataPartition(req, retry, getReply)
-		if err == nil || err == proto.ErrCodeVersionOp || !*retry || err == TryOtherAddrError || strings.Contains(err.Error(), "OpForbidErr") || err == ExtentNotFoundError {
+		if err == nil || err == proto.ErrCodeVersionOp || !*retry || err == TryOtherAddrError || strings.Contains(err.Error(), "OpForbidErr") || err == ExtentNotFoundError || err == BlockCrcMismatchError {
 			return
 		}
 
@@ -108,18 +108,18 @@ func (s *Streamer) String() string {
 // TODO should we call it RefreshExtents instead?
 func (s *Streamer) GetExtents(isMigration bool) error {
 	if s.client.disableMetaCache || !s.needBCache {
-		return s.extents.RefreshForce(s.inode, false, s.client.getExtents, s.isCache, s.openForWrite, isMigration)
+		return s.extents.RefreshForce(s.inode, false, s.client.getInlineExtents, s.isCache, s.openForWrite, isMigration)
 	}
 
-	return s.extents.Refresh(s.inode, s.client.getExtents, s.isCache, s.openForWrite, isMigration)
+	return s.extents.Refresh(s.inode, s.client.getInlineExtents, s.isCache, s.openForWrite, isMigration)
 }
 
 func (s *Streamer) GetExtentsForce() error {
-	return s.extents.RefreshForce(s.inode, false, s.client.getExtents, s.isCache, s.openForWrite, false)
+	return s.extents.RefreshForce(s.inode, false, s.client.getInlineExtents, s.isCache, s.openForWrite, false)
 }
 
 func (s *Streamer) GetExtentsForceRefresh() error {
-	return s.extents.RefreshForce(s.inode, true, s.client.getExtents, s.isCache, s.openForWrite, false)
+	return s.extents.RefreshForce(s.inode, true, s.client.getInlineExtents, s.isCache, s.openForWrite, false)
 }
 
 // GetExtentReader returns the extent reader.
@@ -159,6 +159,9 @@ func (s *Streamer) read(data []byte, offset int, size int, storageClass uint32)
 		s.client.readLimiter.Wait(ctx)
 	}
 	s.client.LimitManager.ReadAlloc(ctx, size)
+	if inline, filesize := s.extents.Inline(); len(inline) > 0 {
+		return readInline(inline, filesize, data, offset, size)
+	}
 	requests = s.extents.PrepareReadRequests(offset, size, data)
 	for _, req := range requests {
 		if req.ExtentKey == nil {
@@ -292,6 +295,26 @@ func (s *Streamer) read(data []byte, offset int, size int, storageClass uint32)
 	return
 }
 
+// readInline reads the tiny file stored in the inode, the data after the inline data up to the
+// file size are zeros.
+func readInline(inline []byte, filesize int, data []byte, offset, size int) (total int, err error) {
+	if offset > filesize {
+		return
+	}
+	if offset+size > filesize {
+		size = filesize - offset
+		err = io.EOF
+	}
+	n := 0
+	if offset < len(inline) {
+		n = copy(data[:size], inline[offset:])
+	}
+	for i := n; i < size; i++ {
+		data[i] = 0
+	}
+	return size, err
+}
+
 func (s *Streamer) asyncBlockCache() {
 	if !s.needBCache || !s.isOpen {
 		return
@@ -95,6 +95,17 @@ type TruncRequest struct {
 	done     chan struct{}
 }
 
+// FallocRequest defines a fallocate request.
+type FallocRequest struct {
+	mode         uint32
+	offset       uint64
+	length       uint64
+	storageClass uint32
+	err          error
+	fullPath     string
+	done         chan struct{}
+}
+
 // EvictRequest defines an evict request.
 type EvictRequest struct {
 	err  error
@@ -177,6 +188,21 @@ func (s *Streamer) IssueTruncRequest(size int, fullPath string) error {
 	return err
 }
 
+func (s *Streamer) IssueFallocRequest(mode uint32, offset, length uint64, storageClass uint32, fullPath string) error {
+	request := fallocRequestPool.Get().(*FallocRequest)
+	request.mode = mode
+	request.offset = offset
+	request.length = length
+	request.storageClass = storageClass
+	request.fullPath = fullPath
+	request.done = make(chan struct{}, 1)
+	s.request <- request
+	<-request.done
+	err := request.err
+	fallocRequestPool.Put(request)
+	return err
+}
+
 func (s *Streamer) IssueEvictRequest() error {
 	request := evictRequestPool.Get().(*EvictRequest)
 	request.done = make(chan struct{}, 1)
@@ -279,6 +305,9 @@ func (s *Streamer) abortRequest(request interface{}) {
 	case *TruncRequest:
 		request.err = syscall.EAGAIN
 		request.done <- struct{}{}
+	case *FallocRequest:
+		request.err = syscall.EAGAIN
+		request.done <- struct{}{}
 	case *FlushRequest:
 		request.err = syscall.EAGAIN
 		request.done <- struct{}{}
@@ -309,6 +338,9 @@ func (s *Streamer) handleRequest(request interface{}) {
 	case *TruncRequest:
 		request.err = s.truncate(request.size, request.fullPath)
 		request.done <- struct{}{}
+	case *FallocRequest:
+		request.err = s.fallocate(request.mode, request.offset, request.length, request.storageClass, request.fullPath)
+		request.done <- struct{}{}
 	case *FlushRequest:
 		request.err = s.flush()
 		request.done <- struct{}{}
@@ -351,6 +383,15 @@ begin:
 	s.client.writeLimiter.Wait(ctx)
 	s.client.LimitManager.WriteAlloc(ctx, size)
 
+	if inline, err := s.writeInline(data, offset, size, flags, checkFunc, storageClass, isMigration); inline || err != nil {
+		if err != nil {
+			log.LogErrorf("Streamer write: ino(%v) offset(%v) size(%v) inline err(%v)", s.inode, offset, size, err)
+			return 0, err
+		}
+		log.LogDebugf("Streamer write exit: ino(%v) offset(%v) size(%v) inline", s.inode, offset, size)
+		return size, nil
+	}
+
 	requests := s.extents.PrepareWriteRequests(offset, size, data)
 	log.LogDebugf("Streamer write: ino(%v) prepared requests(%v)", s.inode, requests)
 
@@ -386,9 +427,26 @@ begin:
 			}
 			log.LogDebugf("action[streamer.write] inode [%v] latest seq [%v] extentkey seq [%v]  info [%v] before compare seq",
 				s.inode, s.verSeq, req.ExtentKey.GetSeq(), req.ExtentKey)
-			if req.ExtentKey.GetSeq() == s.verSeq {
+			if req.ExtentKey.IsCompressed() {
+				// the compressed block can not be modified in place
+				log.LogDebugf("action[streamer.write] ino %v doOverwriteCompressed extent key (%v)", s.inode, req.ExtentKey)
+				writeSize, err = s.doOverwriteCompressed(req, direct, storageClass, isMigration)
+			} else if s.client.isShared(req.ExtentKey) {
+				// copy on write, the extent is shared with the source volume of the clone or other files
+				log.LogDebugf("action[streamer.write] ino %v doWriteAppend on shared extent key (%v)", s.inode, req.ExtentKey)
+				writeSize, err = s.doWriteAppend(req, direct, storageClass, isMigration)
+			} else if req.ExtentKey.GetSeq() == s.verSeq {
 				writeSize, err = s.doOverwrite(req, direct, storageClass)
-				if err == proto.ErrCodeVersionOp {
+				if err == SharedExtentError {
+					// cloned by another client since the extents are cached, refresh them to learn
+					// the extents shared and copy on write
+					log.LogDebugf("action[streamer.write] ino %v doWriteAppend on shared extent key (%v)", s.inode, req.ExtentKey)
+					if err = s.GetExtentsForceRefresh(); err != nil {
+						log.LogErrorf("action[streamer.write] err %v", err)
+						return
+					}
+					writeSize, err = s.doWriteAppend(req, direct, storageClass, isMigration)
+				} else if err == proto.ErrCodeVersionOp {
 					log.LogDebugf("action[streamer.write] write need version update")
 					if err = s.GetExtentsForceRefresh(); err != nil {
 						log.LogErrorf("action[streamer.write] err %v", err)
@@ -437,6 +495,58 @@ begin:
 	return
 }
 
+// writeInline writes the data into the inode if the file is tiny enough to be stored inline,
+// and returns false if the data has to be written into extents. The inline data of the file is
+// moved into extents before the data is written into extents.
+func (s *Streamer) writeInline(data []byte, offset, size, flags int, checkFunc func() error,
+	storageClass uint32, isMigration bool,
+) (inline bool, err error) {
+	inlineData, _ := s.extents.Inline()
+	if s.inlineWritable(offset+size, flags, storageClass, isMigration) {
+		if checkFunc != nil {
+			if err = checkFunc(); err != nil {
+				return
+			}
+		}
+		if err = s.client.writeInline(s.inode, uint64(offset), data[:size]); err == nil {
+			s.extents.WriteInline(offset, data[:size])
+			return true, nil
+		}
+		if err != syscall.EOPNOTSUPP {
+			return
+		}
+		// the file is no longer inline or is not allowed to be inline, reload it
+		log.LogDebugf("writeInline: ino(%v) offset(%v) size(%v) fallback to extents", s.inode, offset, size)
+		if err = s.GetExtentsForceRefresh(); err != nil {
+			return
+		}
+		inlineData, _ = s.extents.Inline()
+	}
+	if len(inlineData) == 0 {
+		return false, nil
+	}
+
+	log.LogDebugf("writeInline: ino(%v) move inline data(%v) into extents", s.inode, len(inlineData))
+	req := NewExtentRequest(0, len(inlineData), inlineData, nil)
+	if _, err = s.doWriteAppend(req, false, storageClass, isMigration); err != nil {
+		return
+	}
+	s.extents.ClearInline()
+	return false, nil
+}
+
+func (s *Streamer) inlineWritable(end, flags int, storageClass uint32, isMigration bool) bool {
+	threshold := s.client.dataWrapper.InlineDataThreshold()
+	if threshold <= 0 || end > threshold || s.client.writeInline == nil || isMigration || s.isCache ||
+		flags&(proto.FlagsNoInline|proto.FlagsCache) != 0 || s.verSeq > 0 {
+		return false
+	}
+	if !proto.IsHot(s.client.volumeType) || !proto.IsStorageClassReplica(storageClass) {
+		return false
+	}
+	return s.handler == nil && s.dirtylist.Len() == 0 && s.extents.root.Len() == 0
+}
+
 func (s *Streamer) doOverWriteByAppend(req *ExtentRequest, direct bool, storageClass uint32, isMigration bool) (total int, extKey *proto.ExtentKey, err error, status int32) {
 	// the extent key needs to be updated because when preparing the requests,
 	// the obtained extent key could be a local key which can be inconsistent with the remote key.
@@ -621,6 +731,26 @@ func (s *Streamer) doDirectWriteByAppend(req *ExtentRequest, direct bool, op uin
 	return
 }
 
+// doOverwriteCompressed rewrites the whole range of the compressed extent key with the request
+// data merged in, since the compressed block can only be decompressed in whole.
+func (s *Streamer) doOverwriteCompressed(req *ExtentRequest, direct bool, storageClass uint32, isMigration bool) (total int, err error) {
+	ek := req.ExtentKey
+	reader, err := s.GetExtentReader(ek, storageClass)
+	if err != nil {
+		return
+	}
+	data := make([]byte, ek.Size)
+	if _, err = reader.Read(NewExtentRequest(int(ek.FileOffset), int(ek.Size), data, ek)); err != nil {
+		log.LogErrorf("doOverwriteCompressed: read ek(%v) err(%v)", ek, err)
+		return
+	}
+	copy(data[uint64(req.FileOffset)-ek.FileOffset:], req.Data[:req.Size])
+	if _, err = s.doWriteAppend(NewExtentRequest(int(ek.FileOffset), int(ek.Size), data, nil), direct, storageClass, isMigration); err != nil {
+		return
+	}
+	return req.Size, nil
+}
+
 func (s *Streamer) doOverwrite(req *ExtentRequest, direct bool, storageClass uint32) (total int, err error) {
 	var dp *wrapper.DataPartition
 
@@ -697,6 +827,10 @@ func (s *Streamer) doOverwrite(req *ExtentRequest, direct bool, storageClass uin
 				e = TryOtherAddrError
 			}
 
+			if replyPacket.ResultCode == proto.OpTryOtherExtent {
+				return SharedExtentError, false
+			}
+
 			if replyPacket.ResultCode == proto.ErrCodeVersionOpError {
 				e = proto.ErrCodeVersionOp
 				log.LogDebugf("action[doOverwrite] .UpdateLatestVer verseq (%v) be updated by datanode rsp (%v) ", s.verSeq, replyPacket)
@@ -714,6 +848,11 @@ func (s *Streamer) doOverwrite(req *ExtentRequest, direct bool, storageClass uin
 		log.LogDebugf("doOverwrite: ino(%v) req(%v) reqPacket(%v) err(%v) replyPacket(%v)", s.inode, req, reqPacket, err, replyPacket)
 
 		if err != nil || replyPacket.ResultCode != proto.OpOk {
+			if replyPacket.ResultCode == proto.OpTryOtherExtent {
+				err = SharedExtentError
+				log.LogWarnf("doOverwrite: extent is shared, ino(%v) req(%v) replyPacket(%v)", s.inode, req, replyPacket)
+				return
+			}
 			if replyPacket.ResultCode == proto.ErrCodeVersionOpError {
 				err = proto.ErrCodeVersionOp
 				log.LogWarnf("doOverwrite: need retry.ino(%v) req(%v) reqPacket(%v) err(%v) replyPacket(%v)", s.inode, req, reqPacket, err, replyPacket)
@@ -736,7 +875,13 @@ func (s *Streamer) doOverwrite(req *ExtentRequest, direct bool, storageClass uin
 func (s *Streamer) tryInitExtentHandlerByLastEk(offset, size int, isMigration bool) (isLastEkVerNotEqual bool) {
 	storeMode := s.GetStoreMod(offset, size)
 	getEndEkFunc := func() *proto.ExtentKey {
-		if ek := s.extents.GetEndForAppendWrite(uint64(offset), s.verSeq, false); ek != nil && !storage.IsTinyExtent(ek.ExtentId) {
+		// the shared extent is never appended
+		// nor is the extent the blocks compressed are packed in
+		if s.compression(storeMode, isMigration) != "" {
+			return nil
+		}
+		if ek := s.extents.GetEndForAppendWrite(uint64(offset), s.verSeq, false); ek != nil && !storage.IsTinyExtent(ek.ExtentId) &&
+			!s.client.isShared(ek) && !s.extents.HasCompressedInExtent(ek) {
 			return ek
 		}
 		return nil
@@ -817,6 +962,17 @@ func (s *Streamer) tryInitExtentHandlerByLastEk(offset, size int, isMigration bo
 	return
 }
 
+// compression returns the encoding the data appended is compressed with, empty if not compressed.
+// Only the normal extents of the hot volume are compressed, and not for the cache or the
+// snapshot, whose extent keys are split by offset.
+func (s *Streamer) compression(storeMode int, isMigration bool) string {
+	if storeMode != proto.NormalExtentType || isMigration || s.isCache || s.verSeq > 0 ||
+		!proto.IsHot(s.client.volumeType) {
+		return ""
+	}
+	return s.client.dataWrapper.Compression()
+}
+
 // First, attempt sequential writes using neighboring extent keys. If the last extent has a different version,
 // it indicates that the extent may have been fully utilized by the previous version.
 // Next, try writing and directly checking the extent at the datanode. If the extent cannot be reused, create a new extent for writing.
@@ -865,6 +1021,9 @@ func (s *Streamer) doWriteAppendEx(data []byte, offset, size int, direct bool, r
 		for i := 0; i < MaxNewHandlerRetry; i++ {
 			if s.handler == nil {
 				s.handler = NewExtentHandler(s, offset, storeMode, 0, storageClass, isMigration)
+				if proto.IsStorageClassReplica(storageClass) {
+					s.handler.compression = s.compression(storeMode, isMigration)
+				}
 				s.dirty = false
 			} else if s.handler.storeMode != storeMode {
 				// store mode changed, so close open handler and start a new one
@@ -1090,6 +1249,27 @@ func (s *Streamer) truncate(size int, fullPath string) error {
 	return s.GetExtentsForce()
 }
 
+func (s *Streamer) fallocate(mode uint32, offset, length uint64, storageClass uint32, fullPath string) error {
+	if atomic.LoadInt32(&s.status) >= StreamerError {
+		return errors.New(fmt.Sprintf("IssueFallocRequest: stream writer in error status, ino(%v)", s.inode))
+	}
+	// the metanode does not fallocate the inline file
+	if _, err := s.writeInline(nil, 0, 0, proto.FlagsNoInline, nil, storageClass, false); err != nil {
+		return err
+	}
+	err := s.closeOpenHandler()
+	if err != nil {
+		return err
+	}
+
+	err = s.client.fallocate(s.inode, mode, offset, length, fullPath)
+	if err != nil {
+		return err
+	}
+	// the extents within the range may be removed, so the cache has to be reloaded
+	return s.GetExtentsForceRefresh()
+}
+
 func (s *Streamer) updateVer(verSeq uint64) (err error) {
 	log.LogInfof("action[stream.updateVer] ver %v update to %v", s.verSeq, verSeq)
 	if s.verSeq != verSeq {
@@ -21,6 +21,7 @@ import (
 	"net"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/cubefs/cubefs/proto"
@@ -74,6 +75,7 @@ type Wrapper struct {
 
 	HostsStatus map[string]bool
 	Uids        map[uint32]*proto.UidSimpleInfo
+	Gids        map[uint32]*proto.UidSimpleInfo
 	UidLock     sync.RWMutex
 	preload     bool
 	LocalIp     string
@@ -86,6 +88,9 @@ type Wrapper struct {
 	volStorageClass        uint32
 	volAllowedStorageClass []uint32
 	volStatByClass         map[uint32]*proto.StatOfStorageClass
+
+	inlineDataThreshold uint32
+	compression         atomic.Value // string
 }
 
 // NewDataPartitionWrapper returns a new data partition wrapper.
@@ -188,11 +193,17 @@ func (w *Wrapper) UpdateUidsView(view *proto.SimpleVolView) {
 	w.UidLock.Lock()
 	defer w.UidLock.Unlock()
 	w.Uids = make(map[uint32]*proto.UidSimpleInfo)
+	w.Gids = make(map[uint32]*proto.UidSimpleInfo)
 	for _, uid := range view.Uids {
 		if !uid.Limited {
 			continue
 		}
-		w.Uids[uid.UID] = &uid
+		info := uid
+		if uid.Type == proto.QuotaOwnerGroup {
+			w.Gids[uid.UID] = &info
+		} else {
+			w.Uids[uid.UID] = &info
+		}
 	}
 	log.LogDebugf("uid info be updated to %v", view.Uids)
 }
@@ -216,6 +227,8 @@ func (w *Wrapper) GetSimpleVolView() (err error) {
 	w.dpSelectorParm = view.DpSelectorParm
 	w.volType = view.VolType
 	w.EnablePosixAcl = view.EnablePosixAcl
+	atomic.StoreUint32(&w.inlineDataThreshold, view.InlineDataThreshold)
+	w.compression.Store(view.Compression)
 
 	w.UpdateUidsView(view)
 
@@ -360,9 +373,33 @@ func (w *Wrapper) updateSimpleVolView() (err error) {
 		w.Lock.Unlock()
 	}
 
+	if threshold := atomic.LoadUint32(&w.inlineDataThreshold); threshold != view.InlineDataThreshold {
+		log.LogInfof("UpdateSimpleVolView: update inlineDataThreshold from old(%v) to new(%v)",
+			threshold, view.InlineDataThreshold)
+		atomic.StoreUint32(&w.inlineDataThreshold, view.InlineDataThreshold)
+	}
+
+	if compression := w.Compression(); compression != view.Compression {
+		log.LogInfof("UpdateSimpleVolView: update compression from old(%v) to new(%v)",
+			compression, view.Compression)
+		w.compression.Store(view.Compression)
+	}
+
 	return nil
 }
 
+// InlineDataThreshold returns the size of the files stored in the inode rather than extents.
+func (w *Wrapper) InlineDataThreshold() int {
+	return int(atomic.LoadUint32(&w.inlineDataThreshold))
+}
+
+// Compression returns the encoding the blocks of the extents are compressed with, empty if the
+// volume doesn't compress the data.
+func (w *Wrapper) Compression() string {
+	compression, _ := w.compression.Load().(string)
+	return compression
+}
+
 func (w *Wrapper) updateDataPartitionByRsp(forceUpdate bool, refreshPolicy RefreshDpPolicy, DataPartitions []*proto.DataPartitionResponse) (err error) {
 	convert := func(response *proto.DataPartitionResponse) *DataPartition {
 		return &DataPartition{
@@ -597,6 +634,13 @@ func (w *Wrapper) GetDataPartition(partitionID uint64) (*DataPartition, error) {
 	return dp, nil
 }
 
+// IsSharedPartition returns true if the data partition is shared from the source volume of the
+// clone, the extents in it are never written by the clone.
+func (w *Wrapper) IsSharedPartition(partitionID uint64) bool {
+	dp, ok := w.tryGetPartition(partitionID)
+	return ok && dp.CloneSource != ""
+}
+
 func (w *Wrapper) GetReadVerSeq() uint64 {
 	return w.verReadSeq
 }
@@ -645,6 +689,20 @@ func (w *Wrapper) WarningMsg() string {
 	return fmt.Sprintf("%s_client_warning", w.clusterName)
 }
 
+// ReportBadReplica reports the replica whose data of the extent doesn't match the crc of the blocks
+// to the master in the background, which asks the replica to repair it.
+func (w *Wrapper) ReportBadReplica(partitionID uint64, addr string, extentID uint64, offset, size int64) {
+	if w == nil || w.mc == nil {
+		return
+	}
+	go func() {
+		if err := w.mc.AdminAPI().ReportBadDataReplica(partitionID, addr, extentID, offset, size); err != nil {
+			log.LogWarnf("ReportBadReplica: dp(%v) addr(%v) extent(%v) offset(%v) size(%v) err(%v)",
+				partitionID, addr, extentID, offset, size, err)
+		}
+	}()
+}
+
 func (w *Wrapper) updateDataNodeStatus() (err error) {
 	var cv *proto.ClusterView
 	cv, err = w.mc.AdminAPI().GetCluster(false)
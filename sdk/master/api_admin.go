@@ -195,6 +195,19 @@ func (api *AdminAPI) DecommissionDataPartition(dataPartitionID uint64, nodeAddr
 	return
 }
 
+// ReportBadDataReplica reports that the blocks of the extent read from the replica don't match
+// their crc, for the master to ask the replica to repair them.
+func (api *AdminAPI) ReportBadDataReplica(dataPartitionID uint64, nodeAddr string, extentID uint64, offset, size int64) (err error) {
+	request := newRequest(post, proto.AdminReportBadDataReplica).Header(api.h)
+	request.addParam("id", strconv.FormatUint(dataPartitionID, 10))
+	request.addParam("addr", nodeAddr)
+	request.addParam("extent", strconv.FormatUint(extentID, 10))
+	request.addParam("offset", strconv.FormatInt(offset, 10))
+	request.addParam("size", strconv.FormatInt(size, 10))
+	_, err = api.mc.serveRequest(request)
+	return
+}
+
 func (api *AdminAPI) DecommissionMetaPartition(metaPartitionID uint64, nodeAddr, clientIDKey string) (err error) {
 	request := newRequest(get, proto.AdminDecommissionMetaPartition).Header(api.h)
 	request.addParam("id", strconv.FormatUint(metaPartitionID, 10))
@@ -295,6 +308,7 @@ func (api *AdminAPI) UpdateVolume(
 	request.addParam("capacity", strconv.FormatUint(vv.Capacity, 10))
 	request.addParam("followerRead", strconv.FormatBool(vv.FollowerRead))
 	request.addParam(proto.MetaFollowerReadKey, strconv.FormatBool(vv.MetaFollowerRead))
+	request.addParam(proto.MetaFollowerReadIndexKey, strconv.FormatBool(vv.MetaFollowerReadIndex))
 	request.addParam(proto.VolEnableDirectRead, strconv.FormatBool(vv.DirectRead))
 	request.addParam("ebsBlkSize", strconv.Itoa(vv.ObjBlockSize))
 	request.addParam("cacheCap", strconv.FormatUint(vv.CacheCapacity, 10))
@@ -317,6 +331,8 @@ func (api *AdminAPI) UpdateVolume(
 	request.addParam("enablePersistAccessTime", strconv.FormatBool(vv.EnablePersistAccessTime))
 	request.addParam("volStorageClass", strconv.FormatUint(uint64(vv.VolStorageClass), 10))
 	request.addParam("forbidWriteOpOfProtoVersion0", strconv.FormatBool(vv.ForbidWriteOpOfProtoVer0))
+	request.addParam("inlineDataThreshold", strconv.FormatUint(uint64(vv.InlineDataThreshold), 10))
+	request.addParam("compression", vv.Compression)
 	request.addParam(proto.LeaderRetryTimeoutKey, strconv.FormatUint(uint64(vv.LeaderRetryTimeOut), 10))
 
 	if txMask != "" {
@@ -386,7 +402,7 @@ func (api *AdminAPI) CreateVolName(volName, owner string, capacity uint64, delet
 	business string, mpCount, dpCount, replicaNum, dpSize int, followerRead bool, zoneName, cacheRuleKey string, ebsBlkSize,
 	cacheCapacity, cacheAction, cacheThreshold, cacheTTL, cacheHighWater, cacheLowWater, cacheLRUInterval int,
 	dpReadOnlyWhenVolFull bool, txMask string, txTimeout uint32, txConflictRetryNum int64, txConflictRetryInterval int64, optEnableQuota string,
-	clientIDKey string, volStorageClass uint32, allowedStorageClass string, optMetaFollowerRead string,
+	clientIDKey string, volStorageClass uint32, allowedStorageClass string, optMetaFollowerRead string, metaStoreMode string,
 ) (err error) {
 	request := newRequest(get, proto.AdminCreateVol).Header(api.h)
 	request.addParam("name", volName)
@@ -417,6 +433,7 @@ func (api *AdminAPI) CreateVolName(volName, owner string, capacity uint64, delet
 	request.addParam("clientIDKey", clientIDKey)
 	request.addParam("volStorageClass", strconv.FormatUint(uint64(volStorageClass), 10))
 	request.addParam("allowedStorageClass", allowedStorageClass)
+	request.addParam(proto.MetaStoreModeKey, metaStoreMode)
 
 	if txMask != "" {
 		request.addParam("enableTxMask", txMask)
@@ -834,6 +851,27 @@ func (api *AdminAPI) GetS3QoSInfo() (data []byte, err error) {
 	return api.mc.serveRequest(newRequest(get, proto.S3QoSGet).Header(api.h))
 }
 
+func (api *AdminAPI) GetS3PublicAccessBlock() (block *proto.S3PublicAccessBlock, err error) {
+	block = &proto.S3PublicAccessBlock{}
+	err = api.mc.requestWith(block, newRequest(get, proto.S3PublicAccessBlockGet).Header(api.h))
+	return
+}
+
+func (api *AdminAPI) SetS3PublicAccessBlock(block *proto.S3PublicAccessBlock) (err error) {
+	request := newRequest(post, proto.S3PublicAccessBlockSet).Header(api.h)
+	request.addParam("blockPublicAcls", strconv.FormatBool(block.BlockPublicAcls))
+	request.addParam("ignorePublicAcls", strconv.FormatBool(block.IgnorePublicAcls))
+	request.addParam("blockPublicPolicy", strconv.FormatBool(block.BlockPublicPolicy))
+	request.addParam("restrictPublicBuckets", strconv.FormatBool(block.RestrictPublicBuckets))
+	_, err = api.mc.serveRequest(request)
+	return
+}
+
+func (api *AdminAPI) DeleteS3PublicAccessBlock() (err error) {
+	_, err = api.mc.serveRequest(newRequest(post, proto.S3PublicAccessBlockDelete).Header(api.h))
+	return
+}
+
 func (api *AdminAPI) SetAutoDecommissionDisk(enable bool) (err error) {
 	request := newRequest(post, proto.AdminEnableAutoDecommissionDisk)
 	request.addParam("enable", strconv.FormatBool(enable))
@@ -913,3 +951,82 @@ func (api *AdminAPI) GetUpgradeCompatibleSettings() (upgradeCompatibleSettings *
 	err = api.mc.requestWith(upgradeCompatibleSettings, newRequest(get, proto.AdminGetUpgradeCompatibleSettings).Header(api.h))
 	return
 }
+
+func (api *AdminAPI) StartFsck(volName string, dryRun, checkExtent bool, safeWindow int64) (task *proto.FsckTask, err error) {
+	task = &proto.FsckTask{}
+	request := newRequest(post, proto.AdminFsck).Header(api.h)
+	request.addParam("op", "start")
+	request.addParam("vol", volName)
+	request.addParam("dryRun", strconv.FormatBool(dryRun))
+	request.addParam("checkExtent", strconv.FormatBool(checkExtent))
+	request.addParam("safeWindow", strconv.FormatInt(safeWindow, 10))
+	err = api.mc.requestWith(task, request)
+	return
+}
+
+func (api *AdminAPI) StopFsck(volName string) (err error) {
+	request := newRequest(post, proto.AdminFsck).Header(api.h)
+	request.addParam("op", "stop")
+	request.addParam("vol", volName)
+	err = api.mc.request(request)
+	return
+}
+
+func (api *AdminAPI) GetFsckStatus(volName string) (results []*proto.FsckTaskResponse, err error) {
+	results = make([]*proto.FsckTaskResponse, 0)
+	err = api.mc.requestWith(&results, newRequest(get, proto.AdminFsck).
+		Header(api.h).Param(anyParam{"op", "status"}, anyParam{"vol", volName}))
+	return
+}
+
+// StartTrashTask starts the task listing, restoring or purging the entries in the trash of the
+// subdir of the volume selected by the filter on an lcnode.
+func (api *AdminAPI) StartTrashTask(volName, op, subdir string, filter *proto.TrashFilter, conflict string) (task *proto.TrashTask, err error) {
+	task = &proto.TrashTask{}
+	request := newRequest(post, proto.AdminVolTrash).Header(api.h)
+	request.addParam("op", op)
+	request.addParam("name", volName)
+	request.addParam("subdir", subdir)
+	request.addParam("path", filter.Path)
+	request.addParam("uid", strconv.FormatInt(filter.Uid, 10))
+	request.addParam("from", strconv.FormatInt(filter.From, 10))
+	request.addParam("to", strconv.FormatInt(filter.To, 10))
+	request.addParam("conflict", conflict)
+	err = api.mc.requestWith(task, request)
+	return
+}
+
+func (api *AdminAPI) GetTrashTaskStatus(volName string) (results []*proto.TrashTaskResponse, err error) {
+	results = make([]*proto.TrashTaskResponse, 0)
+	err = api.mc.requestWith(&results, newRequest(get, proto.AdminVolTrash).
+		Header(api.h).Param(anyParam{"op", "status"}, anyParam{"name", volName}))
+	return
+}
+
+func (api *AdminAPI) CloneVolume(volName, cloneName, ver, owner string) (task *proto.VolCloneTask, err error) {
+	task = &proto.VolCloneTask{}
+	request := newRequest(post, proto.AdminVolClone).Header(api.h)
+	request.addParam("op", "create")
+	request.addParam("name", volName)
+	request.addParam("cloneName", cloneName)
+	request.addParam("ver", ver)
+	request.addParam("owner", owner)
+	err = api.mc.requestWith(task, request)
+	return
+}
+
+func (api *AdminAPI) DetachVolClone(cloneName string) (task *proto.VolCloneTask, err error) {
+	task = &proto.VolCloneTask{}
+	request := newRequest(post, proto.AdminVolClone).Header(api.h)
+	request.addParam("op", "detach")
+	request.addParam("name", cloneName)
+	err = api.mc.requestWith(task, request)
+	return
+}
+
+func (api *AdminAPI) GetVolCloneStatus(volName string) (results []*proto.VolCloneTaskResponse, err error) {
+	results = make([]*proto.VolCloneTaskResponse, 0)
+	err = api.mc.requestWith(&results, newRequest(get, proto.AdminVolClone).
+		Header(api.h).Param(anyParam{"op", "status"}, anyParam{"name", volName}))
+	return
+}
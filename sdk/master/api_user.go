@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util"
 	"github.com/cubefs/cubefs/util/ump"
 )
 
@@ -84,6 +85,35 @@ func (api *UserAPI) UidOperation(volName string, uid string, op uint32, val stri
 	return
 }
 
+// OwnerQuotaOperation gets, lists or removes the quotas of the users or groups of the volume.
+func (api *UserAPI) OwnerQuotaOperation(volName string, typ proto.QuotaOwnerType, id string, op uint32) (uidInfo *proto.UidSpaceRsp, err error) {
+	uidInfo = &proto.UidSpaceRsp{}
+	err = api.mc.requestWith(uidInfo, newRequest(get, proto.AdminUid).Header(api.h).Param(
+		anyParam{"name", volName},
+		anyParam{"quotaType", typ.String()},
+		anyParam{"uid", id},
+		anyParam{"op", op},
+	))
+	return
+}
+
+// SetOwnerQuota sets the hard and soft limits of the user or group of the volume.
+func (api *UserAPI) SetOwnerQuota(volName string, limit *proto.UidSpaceInfo) (uidInfo *proto.UidSpaceRsp, err error) {
+	uidInfo = &proto.UidSpaceRsp{}
+	err = api.mc.requestWith(uidInfo, newRequest(get, proto.AdminUid).Header(api.h).Param(
+		anyParam{"name", volName},
+		anyParam{"quotaType", limit.Type.String()},
+		anyParam{"uid", limit.Uid},
+		anyParam{"op", util.UidSetLimit},
+		anyParam{"capacity", limit.LimitSize},
+		anyParam{"softCapacity", limit.SoftSize},
+		anyParam{"maxFiles", limit.LimitFiles},
+		anyParam{"softFiles", limit.SoftFiles},
+		anyParam{"grace", limit.GracePeriod},
+	))
+	return
+}
+
 func (api *UserAPI) GetUserInfo(userID string) (userInfo *proto.UserInfo, err error) {
 	userInfo = &proto.UserInfo{}
 	err = api.mc.requestWith(userInfo, newRequest(get, proto.UserGetInfo).Header(api.h).addParam("user", userID))
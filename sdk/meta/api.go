@@ -41,6 +41,10 @@ const (
 	maxUniqID         = 5000
 )
 
+// ErrChangesExpired means some changes of the volume are lost by the change feed, the consumer
+// should resync the namespace before going on.
+var ErrChangesExpired = errors.New("changes expired")
+
 const (
 	BatchIgetRespBuf            = 1000
 	MaxSummaryGoroutineNum      = 120
@@ -61,6 +65,73 @@ const (
 	ForceUpdateRWMP             = "ForceUpdateRWMP"
 )
 
+// The directories under the volume root keeping the internal data of the services are marked by
+// proto.ReservedDirXAttrKey, and are neither visible nor changeable through the wrapper not
+// opened by the services. The entries of the users taking the reserved names before the services
+// are not marked, and are accessible as usual, but no new entries can be created by the names.
+
+// isReservedName reports whether the name of the entry to be created under the directory is
+// reserved.
+func (mw *MetaWrapper) isReservedName(parentID uint64, name string) bool {
+	return !mw.accessReservedNames && parentID == proto.RootIno && proto.IsReservedName(name)
+}
+
+// checkReserved returns ENOENT if the entry under the directory is a reserved directory.
+func (mw *MetaWrapper) checkReserved(parentID uint64, name string) (err error) {
+	if mw.isReservedName(parentID, name) {
+		_, _, err = mw.Lookup_ll(parentID, name)
+	}
+	return
+}
+
+// IsReservedDir reports whether the inode is a reserved directory of the services, the inodes
+// marked are cached since the mark is never removed.
+func (mw *MetaWrapper) IsReservedDir(ino uint64) (bool, error) {
+	if _, ok := mw.reservedDirs.Load(ino); ok {
+		return true, nil
+	}
+	info, err := mw.XAttrGet_ll(ino, proto.ReservedDirXAttrKey)
+	if err == syscall.ENOENT {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if len(info.Get(proto.ReservedDirXAttrKey)) == 0 {
+		return false, nil
+	}
+	mw.reservedDirs.Store(ino, struct{}{})
+	return true, nil
+}
+
+// hidesReserved reports whether the entry under the directory is hidden as a reserved directory,
+// the entry is hidden if it cannot be told.
+func (mw *MetaWrapper) hidesReserved(parentID uint64, name string, ino uint64) bool {
+	if !mw.isReservedName(parentID, name) {
+		return false
+	}
+	reserved, err := mw.IsReservedDir(ino)
+	if err != nil {
+		log.LogWarnf("hidesReserved: check ino(%v) name(%v) err(%v)", ino, name, err)
+		return true
+	}
+	return reserved
+}
+
+// filterReserved drops the reserved directories from the children of the directory.
+func (mw *MetaWrapper) filterReserved(parentID uint64, children []proto.Dentry) []proto.Dentry {
+	if mw.accessReservedNames || parentID != proto.RootIno {
+		return children
+	}
+	left := make([]proto.Dentry, 0, len(children))
+	for _, child := range children {
+		if !mw.hidesReserved(parentID, child.Name, child.Inode) {
+			left = append(left, child)
+		}
+	}
+	return left
+}
+
 func (mw *MetaWrapper) GetRootIno(subdir string) (uint64, error) {
 	rootIno, err := mw.LookupPath(subdir)
 	if err != nil {
@@ -107,6 +178,9 @@ func (mw *MetaWrapper) Statfs() (total, used, inodeCount uint64) {
 }
 
 func (mw *MetaWrapper) Create_ll(parentID uint64, name string, mode, uid, gid uint32, target []byte, fullPath string, ignoreExist bool) (*proto.InodeInfo, error) {
+	if mw.isReservedName(parentID, name) {
+		return nil, syscall.EPERM
+	}
 	// if mw.EnableTransaction {
 	var txMask proto.TxOpMask
 	if proto.IsRegular(mode) {
@@ -370,6 +444,9 @@ func (mw *MetaWrapper) Lookup_ll(parentID uint64, name string) (inode uint64, mo
 	if err != nil || status != statusOK {
 		return 0, 0, statusToErrno(status)
 	}
+	if mw.hidesReserved(parentID, name, inode) {
+		return 0, 0, syscall.ENOENT
+	}
 	// only save dir
 	if proto.IsDir(mode) {
 		mw.AddInoInfoCache(inode, parentID, name)
@@ -497,6 +574,75 @@ func (mw *MetaWrapper) BatchInodeGet(inodes []uint64) []*proto.InodeInfo {
 	return batchInfos
 }
 
+// BatchInodeGetInPartition returns the inodes of the meta partition. Unlike BatchInodeGet, an
+// error is returned if the partition fails to respond, so the missing inodes surely not exist.
+func (mw *MetaWrapper) BatchInodeGetInPartition(partitionID uint64, inodes []uint64) ([]*proto.InodeInfo, error) {
+	mp := mw.getPartitionByID(partitionID)
+	if mp == nil {
+		return nil, syscall.ENOENT
+	}
+	return mw.batchIgetFromPartition(mp, inodes)
+}
+
+// FsckDeleteExtents deletes the normal extents referenced by no inode of the volume through the
+// extent deletion of the meta partition.
+func (mw *MetaWrapper) FsckDeleteExtents(partitionID uint64, eks []proto.ExtentKey) error {
+	mp := mw.getPartitionByID(partitionID)
+	if mp == nil {
+		return syscall.ENOENT
+	}
+	status, err := mw.fsckDeleteExtents(mp, eks)
+	if err != nil || status != statusOK {
+		return statusErrToErrno(status, err)
+	}
+	return nil
+}
+
+// CloneInode clones the regular file into the empty file dst by copying the extent keys, so the
+// data is shared by the files rather than copied. If dst is 0 a new inode is created in the meta
+// partition of the file. EXDEV is returned if dst is in another partition, and EOPNOTSUPP if the
+// file cannot be cloned, the data should be copied in both cases.
+func (mw *MetaWrapper) CloneInode(inode, dst uint64, uid, gid uint32) (*proto.InodeInfo, error) {
+	mp := mw.getPartitionByInode(inode)
+	if mp == nil {
+		return nil, syscall.ENOENT
+	}
+	if dst != 0 && mw.getPartitionByInode(dst) != mp {
+		return nil, syscall.EXDEV
+	}
+	status, info, err := mw.icloneInode(mp, inode, dst, uid, gid)
+	if status == statusInval {
+		return nil, syscall.EOPNOTSUPP
+	}
+	if err != nil || status != statusOK {
+		return nil, statusErrToErrno(status, err)
+	}
+	return info, nil
+}
+
+// IsSharedExtent tells whether the extent is shared by the files cloned by CloneInode, as
+// reported by the extents got.
+func (mw *MetaWrapper) IsSharedExtent(partitionID, extentID uint64) bool {
+	_, ok := mw.sharedExtents.Load(proto.SharedExtentID{PartitionId: partitionID, ExtentId: extentID})
+	return ok
+}
+
+// CopyFile_ll creates the file name in the parent as a clone of the regular file, see CloneInode.
+func (mw *MetaWrapper) CopyFile_ll(inode, parentID uint64, name string, uid, gid uint32, fullPath string) (*proto.InodeInfo, error) {
+	info, err := mw.CloneInode(inode, 0, uid, gid)
+	if err != nil {
+		return nil, err
+	}
+	if err = mw.DentryCreate_ll(parentID, name, info.Inode, info.Mode, fullPath); err != nil {
+		if mp := mw.getPartitionByInode(info.Inode); mp != nil {
+			mw.iunlink(mp, info.Inode, mw.Client.GetLatestVer(), 0, fullPath)
+			mw.ievict(mp, info.Inode, fullPath)
+		}
+		return nil, err
+	}
+	return info, nil
+}
+
 // InodeDelete_ll is a low-level api that removes specified inode immediately
 // and do not effect extent data managed by this inode.
 func (mw *MetaWrapper) InodeDelete_ll(inode uint64, fullPath string) error {
@@ -652,6 +798,9 @@ func (mw *MetaWrapper) shouldNotMoveToTrash(parentMP *MetaPartition, parentIno u
  * and the caller should make sure InodeInfo is valid before using it.
  */
 func (mw *MetaWrapper) Delete_ll(parentID uint64, name string, isDir bool, fullPath string) (*proto.InodeInfo, error) {
+	if err := mw.checkReserved(parentID, name); err != nil {
+		return nil, err
+	}
 	if mw.enableTx(proto.TxOpMaskRemove) {
 		return mw.txDelete_ll(parentID, name, isDir, fullPath)
 	} else {
@@ -660,10 +809,16 @@ func (mw *MetaWrapper) Delete_ll(parentID uint64, name string, isDir bool, fullP
 }
 
 func (mw *MetaWrapper) DeleteWithCond_ll(parentID, cond uint64, name string, isDir bool, fullPath string) (*proto.InodeInfo, error) {
+	if err := mw.checkReserved(parentID, name); err != nil {
+		return nil, err
+	}
 	return mw.deletewithcond_ll(parentID, cond, name, isDir, fullPath)
 }
 
 func (mw *MetaWrapper) Delete_Ver_ll(parentID uint64, name string, isDir bool, verSeq uint64, fullPath string) (*proto.InodeInfo, error) {
+	if err := mw.checkReserved(parentID, name); err != nil {
+		return nil, err
+	}
 	if verSeq == 0 {
 		verSeq = math.MaxUint64
 	}
@@ -829,6 +984,9 @@ func (mw *MetaWrapper) txDelete_ll(parentID uint64, name string, isDir bool, ful
  */
 
 func (mw *MetaWrapper) Delete_ll_EX(parentID uint64, name string, isDir bool, verSeq uint64, fullPath string) (*proto.InodeInfo, error) {
+	if err := mw.checkReserved(parentID, name); err != nil {
+		return nil, err
+	}
 	var (
 		status          int
 		inode           uint64
@@ -1112,6 +1270,12 @@ func (mw *MetaWrapper) deletewithcond_ll(parentID, cond uint64, name string, isD
 }
 
 func (mw *MetaWrapper) Rename_ll(srcParentID uint64, srcName string, dstParentID uint64, dstName string, srcFullPath string, dstFullPath string, overwritten bool) (err error) {
+	if err = mw.checkReserved(srcParentID, srcName); err != nil {
+		return
+	}
+	if mw.isReservedName(dstParentID, dstName) {
+		return syscall.EPERM
+	}
 	if mw.enableTx(proto.TxOpMaskRename) {
 		return mw.txRename_ll(srcParentID, srcName, dstParentID, dstName, srcFullPath, dstFullPath, overwritten)
 	} else {
@@ -1532,7 +1696,7 @@ func (mw *MetaWrapper) ReadDir_ll(parentID uint64) ([]proto.Dentry, error) {
 	if err != nil || status != statusOK {
 		return nil, statusToErrno(status)
 	}
-	return children, nil
+	return mw.filterReserved(parentID, children), nil
 }
 
 // Read limit count dentries with parentID, start from string
@@ -1572,10 +1736,32 @@ func (mw *MetaWrapper) ReadDirLimit_ll(parentID uint64, from string, limit uint6
 	if err != nil || status != statusOK {
 		return nil, statusToErrno(status)
 	}
-	return children, nil
+	// fill up the page the reserved dentries are dropped from, as the callers take a short page
+	// for the end of the directory
+	entries := mw.filterReserved(parentID, children)
+	for requested := limit; uint64(len(children)) == requested && uint64(len(entries)) < limit; {
+		last := children[len(children)-1].Name
+		requested = limit - uint64(len(entries)) + 1
+		status, children, err = mw.readDirLimit(parentMP, parentID, last, requested, mw.VerReadSeq, 0)
+		if err != nil || status != statusOK {
+			return nil, statusToErrno(status)
+		}
+		more := children
+		if len(more) > 0 && more[0].Name == last {
+			more = more[1:]
+		}
+		entries = append(entries, mw.filterReserved(parentID, more)...)
+	}
+	if uint64(len(entries)) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
 }
 
 func (mw *MetaWrapper) DentryCreate_ll(parentID uint64, name string, inode uint64, mode uint32, fullPath string) error {
+	if mw.isReservedName(parentID, name) {
+		return syscall.EPERM
+	}
 	parentMP := mw.getPartitionByInode(parentID)
 	if parentMP == nil {
 		return syscall.ENOENT
@@ -1589,6 +1775,10 @@ func (mw *MetaWrapper) DentryCreate_ll(parentID uint64, name string, inode uint6
 }
 
 func (mw *MetaWrapper) DentryUpdate_ll(parentID uint64, name string, inode uint64, fullPath string) (oldInode uint64, err error) {
+	if mw.isReservedName(parentID, name) {
+		err = syscall.EPERM
+		return
+	}
 	parentMP := mw.getPartitionByInode(parentID)
 	if parentMP == nil {
 		err = syscall.ENOENT
@@ -1603,6 +1793,67 @@ func (mw *MetaWrapper) DentryUpdate_ll(parentID uint64, name string, inode uint6
 	return
 }
 
+// DentryDelete_ll deletes the dentry only, the inode it refers to is left as it is.
+func (mw *MetaWrapper) DentryDelete_ll(parentID uint64, name string, fullPath string) (inode uint64, err error) {
+	if err = mw.checkReserved(parentID, name); err != nil {
+		return
+	}
+	parentMP := mw.getPartitionByInode(parentID)
+	if parentMP == nil {
+		err = syscall.ENOENT
+		return
+	}
+	var status int
+	status, inode, _, err = mw.ddelete(parentMP, parentID, name, 0, mw.LastVerSeq, fullPath)
+	if err != nil || status != statusOK {
+		err = statusToErrno(status)
+		return
+	}
+	return
+}
+
+// ArchiveDentry_ll moves the current version of an object into its version archive directory
+// and links the new current version or the delete marker in the same request. EXDEV is returned
+// if the current version is switched but the archive directory is not in the partition of the
+// parent directory.
+func (mw *MetaWrapper) ArchiveDentry_ll(req *proto.ArchiveDentryRequest) (resp *proto.ArchiveDentryResponse, err error) {
+	mp := mw.getPartitionByInode(req.ArchiveID)
+	if mp == nil {
+		err = syscall.ENOENT
+		return
+	}
+	if req.Inode != 0 && mw.getPartitionByInode(req.ParentID) != mp {
+		err = syscall.EXDEV
+		return
+	}
+	// the new current version in the partition of the archive directory is checked by the apply
+	if req.NewInode != 0 && mw.getPartitionByInode(req.NewInode) != mp {
+		if _, err = mw.InodeGet_ll(req.NewInode); err != nil {
+			return
+		}
+	}
+	var status int
+	if status, resp, err = mw.darchive(mp, req); err != nil || status != statusOK {
+		err = statusToErrno(status)
+		return
+	}
+	return
+}
+
+// InodeCreateNear_ll creates the inode in the partition of the inode near, so the dentries under
+// the directory created are kept in the same partition as those under near.
+func (mw *MetaWrapper) InodeCreateNear_ll(near uint64, mode, uid, gid uint32, fullPath string) (*proto.InodeInfo, error) {
+	mp := mw.getPartitionByInode(near)
+	if mp == nil {
+		return nil, syscall.ENOENT
+	}
+	status, info, err := mw.icreate(mp, mode, uid, gid, nil, fullPath)
+	if err != nil || status != statusOK {
+		return nil, statusToErrno(status)
+	}
+	return info, nil
+}
+
 func (mw *MetaWrapper) SplitExtentKey(parentInode, inode uint64, ek proto.ExtentKey, storageClass uint32) error {
 	mp := mw.getPartitionByInode(inode)
 	if mp == nil {
@@ -1725,9 +1976,18 @@ func (mw *MetaWrapper) AppendObjExtentKeys(inode uint64, eks []proto.ObjExtentKe
 func (mw *MetaWrapper) GetExtents(inode uint64, isCache, openForWrite,
 	isMigration bool,
 ) (gen uint64, size uint64, extents []proto.ExtentKey, err error) {
+	gen, size, extents, _, err = mw.GetInlineExtents(inode, isCache, openForWrite, isMigration)
+	return
+}
+
+// GetInlineExtents returns the extents of the inode as GetExtents, and the data of the tiny file
+// stored in the inode, which is nil once the file is stored in extents.
+func (mw *MetaWrapper) GetInlineExtents(inode uint64, isCache, openForWrite,
+	isMigration bool,
+) (gen uint64, size uint64, extents []proto.ExtentKey, inline []byte, err error) {
 	mp := mw.getPartitionByInode(inode)
 	if mp == nil {
-		return 0, 0, nil, syscall.ENOENT
+		return 0, 0, nil, nil, syscall.ENOENT
 	}
 
 	resp, err := mw.getExtents(mp, inode, isCache, openForWrite, isMigration)
@@ -1738,17 +1998,127 @@ func (mw *MetaWrapper) GetExtents(inode uint64, isCache, openForWrite,
 			}
 		}
 		log.LogErrorf("GetExtents: ino(%v) err(%v)", inode, err)
-		return 0, 0, nil, err
+		return 0, 0, nil, nil, err
 	}
 	extents = resp.Extents
 	gen = resp.Generation
 	size = resp.Size
+	for _, id := range resp.Shared {
+		mw.sharedExtents.Store(id, struct{}{})
+	}
 
 	// log.LogDebugf("GetObjExtents stack[%v]", string(debug.Stack()))
 	if log.EnableDebug() {
-		log.LogDebugf("GetExtents: ino(%v) gen(%v) size(%v) extents(%v)", inode, gen, size, extents)
+		log.LogDebugf("GetExtents: ino(%v) gen(%v) size(%v) extents(%v) inline(%v)", inode, gen, size, extents, len(resp.InlineData))
+	}
+	return gen, size, extents, resp.InlineData, nil
+}
+
+// WriteInline writes the data at the offset of the tiny file into the inode. EOPNOTSUPP is
+// returned if the file cannot be kept inline, the data should be written into extents then.
+func (mw *MetaWrapper) WriteInline(inode, offset uint64, data []byte) error {
+	mp := mw.getPartitionByInode(inode)
+	if mp == nil {
+		return syscall.ENOENT
+	}
+	status, err := mw.iwriteInline(mp, inode, offset, data)
+	if status == statusInval {
+		return syscall.EOPNOTSUPP
+	}
+	if err != nil || status != statusOK {
+		return statusErrToErrno(status, err)
+	}
+	return nil
+}
+
+// GetChanges reads the changes of the meta partition after the index from, waiting at most wait
+// for the new changes if there are none.
+func (mw *MetaWrapper) GetChanges(pid, from uint64, limit int, wait time.Duration) (*proto.GetChangesResponse, error) {
+	mp := mw.getPartitionByID(pid)
+	if mp == nil {
+		return nil, syscall.ENOENT
+	}
+	_, resp, err := mw.getChanges(mp, from, limit, wait)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ReadChanges reads at most limit changes of each meta partition after the cursor, and moves
+// the cursor past the changes read. The partitions not in the cursor are read from index 0, so
+// the changes of a new partition are read from its first apply. ErrChangesExpired is returned
+// together with the changes read if the partition keeps the changes only after an index beyond
+// the cursor, i.e. the changes in between are lost, the cursor of the partition is moved past the
+// lost changes and the changes read then.
+func (mw *MetaWrapper) ReadChanges(cursor proto.ChangeCursor, limit int) (events []*proto.ChangeEvent, err error) {
+	var expired []uint64
+	for _, pid := range mw.partitionIDs() {
+		from := cursor[pid]
+		resp, e := mw.GetChanges(pid, from, limit, 0)
+		if e != nil {
+			log.LogErrorf("ReadChanges: mp(%v) from(%v) err(%v)", pid, from, e)
+			return events, e
+		}
+		if resp.Expired || resp.First > from {
+			log.LogWarnf("ReadChanges: vol(%v) mp(%v) changes lost from(%v) first(%v)",
+				mw.volname, pid, from, resp.First)
+			expired = append(expired, pid)
+			cursor[pid] = resp.First
+		}
+		if n := len(resp.Events); n > 0 {
+			events = append(events, resp.Events...)
+			cursor[pid] = resp.Events[n-1].Index
+		}
+	}
+	if len(expired) > 0 {
+		log.LogWarnf("ReadChanges: vol(%v) changes of mp(%v) expired", mw.volname, expired)
+		return events, ErrChangesExpired
+	}
+	return events, nil
+}
+
+// OldestChangeCursor returns the cursor to read all the changes kept.
+func (mw *MetaWrapper) OldestChangeCursor() (cursor proto.ChangeCursor, err error) {
+	cursor = make(proto.ChangeCursor)
+	for _, pid := range mw.partitionIDs() {
+		resp, e := mw.GetChanges(pid, 0, 1, 0)
+		if e != nil {
+			log.LogErrorf("OldestChangeCursor: mp(%v) err(%v)", pid, e)
+			return nil, e
+		}
+		cursor[pid] = resp.First
+	}
+	return cursor, nil
+}
+
+// LatestChangeCursor returns the cursor to read only the changes applied after now.
+func (mw *MetaWrapper) LatestChangeCursor() (cursor proto.ChangeCursor, err error) {
+	cursor = make(proto.ChangeCursor)
+	for _, pid := range mw.partitionIDs() {
+		resp, e := mw.GetChanges(pid, 0, 1, 0)
+		if e != nil {
+			log.LogErrorf("LatestChangeCursor: mp(%v) err(%v)", pid, e)
+			return nil, e
+		}
+		cursor[pid] = resp.Applied
+	}
+	return cursor, nil
+}
+
+// GetDirStat_ll returns the recursive stats of the directory maintained by the meta partition
+// of the inode.
+func (mw *MetaWrapper) GetDirStat_ll(inode uint64) (*proto.DirStat, error) {
+	mp := mw.getPartitionByInode(inode)
+	if mp == nil {
+		log.LogErrorf("GetDirStat_ll: no such partition, ino(%v)", inode)
+		return nil, syscall.ENOENT
 	}
-	return gen, size, extents, nil
+	status, stat, err := mw.getDirStat(mp, inode)
+	if err != nil || status != statusOK {
+		return nil, statusErrToErrno(status, err)
+	}
+	return stat, nil
 }
 
 func (mw *MetaWrapper) GetObjExtents(inode uint64) (gen uint64, size uint64, extents []proto.ExtentKey, objExtents []proto.ObjExtentKey, err error) {
@@ -1780,7 +2150,26 @@ func (mw *MetaWrapper) Truncate(inode, size uint64, fullPath string) error {
 	return nil
 }
 
+// Fallocate punches or zeroes the byte range of the inode according to the mode, which is
+// composed of proto.FallocKeepSize, proto.FallocPunchHole and proto.FallocZeroRange.
+func (mw *MetaWrapper) Fallocate(inode uint64, mode uint32, offset, length uint64, fullPath string) error {
+	mp := mw.getPartitionByInode(inode)
+	if mp == nil {
+		log.LogErrorf("Fallocate: No inode partition, ino(%v)", inode)
+		return syscall.ENOENT
+	}
+
+	status, err := mw.fallocate(mp, inode, mode, offset, length, fullPath)
+	if err != nil || status != statusOK {
+		return statusToErrno(status)
+	}
+	return nil
+}
+
 func (mw *MetaWrapper) Link(parentID uint64, name string, ino uint64, fullPath string) (*proto.InodeInfo, error) {
+	if mw.isReservedName(parentID, name) {
+		return nil, syscall.EPERM
+	}
 	// if mw.EnableTransaction {
 	if mw.EnableTransaction&proto.TxOpMaskLink > 0 {
 		return mw.txLink(parentID, name, ino, fullPath)
@@ -2275,6 +2664,20 @@ func (mw *MetaWrapper) ListMultipart_ll(prefix, delimiter, keyMarker string, mul
 	return sessions, nil
 }
 
+// ListMultipartInPartition lists the multipart sessions of the meta partition from the markers
+// inclusively. Unlike ListMultipart_ll, an error is returned if the partition fails to respond.
+func (mw *MetaWrapper) ListMultipartInPartition(partitionID uint64, keyMarker, multipartIdMarker string, maxUploads uint64) ([]*proto.MultipartInfo, error) {
+	mp := mw.getPartitionByID(partitionID)
+	if mp == nil {
+		return nil, syscall.ENOENT
+	}
+	status, resp, err := mw.listMultiparts(mp, "", "", keyMarker, multipartIdMarker, maxUploads)
+	if err != nil || status != statusOK {
+		return nil, statusErrToErrno(status, err)
+	}
+	return resp.Multiparts, nil
+}
+
 func (mw *MetaWrapper) XAttrSet_ll(inode uint64, name, value []byte) error {
 	var err error
 	mp := mw.getPartitionByInode(inode)
@@ -2454,7 +2857,7 @@ func (mw *MetaWrapper) ReadDirOnly_ll(parentID uint64) ([]proto.Dentry, error) {
 	if err != nil || status != statusOK {
 		return nil, statusToErrno(status)
 	}
-	return children, nil
+	return mw.filterReserved(parentID, children), nil
 }
 
 type SummaryInfo struct {
@@ -3079,6 +3482,65 @@ func (mw *MetaWrapper) LockDir(ino uint64, lease uint64, lockId int64) (retLockI
 	return
 }
 
+// SetFileLock acquires or releases the file lock on the inode, syscall.EAGAIN is returned along
+// with the conflicting lock if it is held by others.
+func (mw *MetaWrapper) SetFileLock(ino uint64, lock *proto.FileLock, lease uint64) (conflict *proto.FileLock, err error) {
+	resp, err := mw.doFileLock(ino, proto.FileLockOpSet, lock, lease)
+	if err != nil {
+		return
+	}
+	if resp.Conflict != nil {
+		return resp.Conflict, syscall.EAGAIN
+	}
+	return
+}
+
+// GetFileLock returns the lock conflicting with the given one, nil if there is not any.
+func (mw *MetaWrapper) GetFileLock(ino uint64, lock *proto.FileLock) (conflict *proto.FileLock, err error) {
+	resp, err := mw.doFileLock(ino, proto.FileLockOpGet, lock, 0)
+	if err != nil {
+		return
+	}
+	return resp.Conflict, nil
+}
+
+// RenewFileLocks renews the leases of the locks held by the client on the inode, and returns
+// the number of them.
+func (mw *MetaWrapper) RenewFileLocks(ino uint64, client uint64, lease uint64) (held int, err error) {
+	resp, err := mw.doFileLock(ino, proto.FileLockOpRenew, &proto.FileLock{Client: client}, lease)
+	if err != nil {
+		return
+	}
+	return resp.Held, nil
+}
+
+func (mw *MetaWrapper) doFileLock(ino uint64, op uint8, lock *proto.FileLock, lease uint64) (resp *proto.FileLockResponse, err error) {
+	mp := mw.getPartitionByInode(ino)
+	if mp == nil {
+		log.LogErrorf("doFileLock: no such partition, ino(%v)", ino)
+		return nil, syscall.ENOENT
+	}
+
+	req := &proto.FileLockRequest{
+		VolName:     mw.volname,
+		PartitionId: mp.PartitionID,
+		Inode:       ino,
+		Op:          op,
+		Lock:        *lock,
+		Lease:       lease,
+	}
+	status, resp, err := mw.fileLock(mp, req)
+	if err != nil {
+		log.LogErrorf("doFileLock: ino(%v) op(%v) lock(%v) status(%v) err(%v)", ino, op, lock, status, err)
+		if status == statusOK || status == statusExist {
+			// EAGAIN means the lock is held by others, so report the failures as EIO
+			return nil, syscall.EIO
+		}
+		return nil, statusToErrno(status)
+	}
+	return resp, nil
+}
+
 func (mw *MetaWrapper) GetStorageClass() uint32 {
 	return atomic.LoadUint32(&mw.DefaultStorageClass)
 }
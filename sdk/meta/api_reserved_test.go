@@ -0,0 +1,44 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package meta
+
+import (
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/btree"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReservedNames(t *testing.T) {
+	mw := &MetaWrapper{ranges: btree.New(32)}
+	assert.True(t, mw.isReservedName(proto.RootIno, proto.VersionArchiveDir))
+	assert.True(t, mw.isReservedName(proto.RootIno, proto.RestoreQueueDir))
+	assert.False(t, mw.isReservedName(proto.RootIno+1, proto.ReplicationQueueDir))
+	assert.False(t, mw.isReservedName(proto.RootIno, "a"))
+
+	// only the directories marked are hidden, the entry of the user taking the name is not
+	mw.reservedDirs.Store(uint64(10), struct{}{})
+	children := []proto.Dentry{{Name: ".cfs_replication", Inode: 10}, {Name: ".cfs_versions", Inode: 11}, {Name: "a", Inode: 10}}
+	assert.Equal(t, []proto.Dentry{children[1], children[2]}, mw.filterReserved(proto.RootIno, children))
+	assert.Len(t, mw.filterReserved(proto.RootIno+1, children), 3)
+	assert.True(t, mw.hidesReserved(proto.RootIno, proto.ReplicationQueueDir, 10))
+	assert.False(t, mw.hidesReserved(proto.RootIno, proto.VersionArchiveDir, 11))
+
+	mw.accessReservedNames = true
+	assert.False(t, mw.isReservedName(proto.RootIno, proto.VersionArchiveDir))
+	assert.False(t, mw.hidesReserved(proto.RootIno, proto.ReplicationQueueDir, 10))
+	assert.Len(t, mw.filterReserved(proto.RootIno, children), 3)
+}
@@ -17,6 +17,7 @@ package meta
 import (
 	"encoding/binary"
 	"fmt"
+	"math/rand"
 	"net"
 	"sync"
 	"syscall"
@@ -184,6 +185,15 @@ func (mw *MetaWrapper) sendToMetaPartition(mp *MetaPartition, req *proto.Packet)
 }
 
 func (mw *MetaWrapper) sendReadToMP(mp *MetaPartition, req *proto.Packet) (resp *proto.Packet, err error) {
+	if mw.FollowerReadIndex {
+		if resp, err = mw.readIndexFromHost(mp, req); err == nil && !resp.ShouldRetry() {
+			return
+		}
+		log.LogWarnf("sendReadToMP: read by read index failed, try to read from leader, req (%v), mp(%v), err(%v), resp(%v)", req, mp, err, resp)
+		req.ArgLen = 0
+		req.Arg = nil
+	}
+
 	leaderRetryTimeOut := mw.leaderRetryTimeout * 1000
 	if leaderRetryTimeOut <= 0 {
 		leaderRetryTimeOut = MinRetryTime * 1000
@@ -199,6 +209,27 @@ func (mw *MetaWrapper) sendReadToMP(mp *MetaPartition, req *proto.Packet) (resp
 	return mw.readQuorumFromHosts(mp, req)
 }
 
+// readIndexFromHost sends the read to a random replica of the partition. The follower serves it after
+// applying the read index of the leader, so the read is as fresh as the one served by the leader.
+func (mw *MetaWrapper) readIndexFromHost(mp *MetaPartition, req *proto.Packet) (resp *proto.Packet, err error) {
+	if len(mp.Members) == 0 {
+		return nil, errors.New(fmt.Sprintf("readIndexFromHost: no members, mp(%v)", mp))
+	}
+	addr := mp.Members[rand.Intn(len(mp.Members))]
+	req.ExtentType |= proto.PacketProtocolVersionFlag
+	req.ArgLen = 1
+	req.Arg = []byte{proto.ReadIndexReadFlag}
+
+	mc, err := mw.getConn(mp.PartitionID, addr)
+	if err != nil {
+		return
+	}
+	resp, err = mc.send(req)
+	mw.putConn(mc, err)
+	log.LogDebugf("readIndexFromHost: req(%v) addr(%v) resp(%v) err(%v)", req, addr, resp, err)
+	return
+}
+
 func (mw *MetaWrapper) readQuorumFromHosts(mp *MetaPartition, req *proto.Packet) (resp *proto.Packet, err error) {
 	var sendTimeLimit int
 	var mc *MetaConn
@@ -103,6 +103,9 @@ type MetaConfig struct {
 	VerReadSeq           uint64
 	InnerReq             bool
 	DisableTrashByClient bool
+	// AccessReservedNames is set by the services keeping their internal data in the reserved
+	// directories under the volume root, see proto.IsReservedName.
+	AccessReservedNames bool
 }
 
 type MetaWrapper struct {
@@ -135,6 +138,10 @@ type MetaWrapper struct {
 	rwPartitions []*MetaPartition
 	epoch        uint64
 
+	// The extents reported shared by the meta partitions, which are copied on write. They are
+	// never forgotten, since copying the extent released by the other files is harmless.
+	sharedExtents sync.Map
+
 	totalSize  uint64
 	usedSize   uint64
 	inodeCount uint64
@@ -183,6 +190,8 @@ type MetaWrapper struct {
 	subDir        string
 
 	disableTrashByClient bool
+	accessReservedNames  bool
+	reservedDirs         sync.Map // the inodes of the reserved directories
 
 	VerReadSeq          uint64
 	LastVerSeq          uint64
@@ -192,6 +201,7 @@ type MetaWrapper struct {
 	CacheDpStorageClass uint32
 	InnerReq            bool
 	FollowerRead        bool
+	FollowerReadIndex   bool // reads are served by any replica after it applies the read index of the leader
 }
 
 type uniqidRange struct {
@@ -257,6 +267,7 @@ func NewMetaWrapper(config *MetaConfig) (*MetaWrapper, error) {
 	mw.DefaultStorageClass = proto.StorageClass_Unspecified
 	mw.InnerReq = config.InnerReq
 	mw.disableTrashByClient = config.DisableTrashByClient
+	mw.accessReservedNames = config.AccessReservedNames
 
 	for limit > 0 {
 		err = mw.initMetaWrapper()
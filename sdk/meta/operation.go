@@ -679,6 +679,53 @@ func (mw *MetaWrapper) txDupdate(tx *Transaction, mp *MetaPartition, parentID ui
 	return statusOK, resp.Inode, nil
 }
 
+func (mw *MetaWrapper) darchive(mp *MetaPartition, req *proto.ArchiveDentryRequest) (status int, resp *proto.ArchiveDentryResponse, err error) {
+	bgTime := stat.BeginStat()
+	defer func() {
+		stat.EndStat("darchive", err, bgTime, 1)
+	}()
+
+	req.VolName = mw.volname
+	req.PartitionID = mp.PartitionID
+	req.StorageType = mw.DefaultStorageClass
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaArchiveDentry
+	packet.PartitionID = mp.PartitionID
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("darchive: req(%v) err(%v)", *req, err)
+		return
+	}
+
+	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	defer func() {
+		metric.SetWithLabels(err, map[string]string{exporter.Vol: mw.volname})
+	}()
+
+	packet, err = mw.sendToMetaPartitionWithTx(mp, packet)
+	if err != nil {
+		log.LogErrorf("darchive: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		err = errors.New(packet.GetResultMsg())
+		log.LogErrorf("darchive: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	resp = new(proto.ArchiveDentryResponse)
+	err = packet.UnmarshalData(resp)
+	if err != nil {
+		log.LogErrorf("darchive: packet(%v) mp(%v) err(%v) PacketData(%v)", packet, mp, err, string(packet.Data))
+		return
+	}
+	log.LogDebugf("darchive: packet(%v) mp(%v) req(%v) resp(%v)", packet, mp, *req, *resp)
+	return statusOK, resp, nil
+}
+
 func (mw *MetaWrapper) dupdate(mp *MetaPartition, parentID uint64, name string, newInode uint64, fullPath string) (status int, oldInode uint64, err error) {
 	bgTime := stat.BeginStat()
 	defer func() {
@@ -1080,8 +1127,18 @@ func (mw *MetaWrapper) iget(mp *MetaPartition, inode uint64, verSeq uint64) (sta
 
 func (mw *MetaWrapper) batchIget(wg *sync.WaitGroup, mp *MetaPartition, inodes []uint64, respCh chan []*proto.InodeInfo) {
 	defer wg.Done()
-	var err error
+	infos, err := mw.batchIgetFromPartition(mp, inodes)
+	if err != nil || len(infos) == 0 {
+		return
+	}
 
+	select {
+	case respCh <- infos:
+	default:
+	}
+}
+
+func (mw *MetaWrapper) batchIgetFromPartition(mp *MetaPartition, inodes []uint64) (infos []*proto.InodeInfo, err error) {
 	bgTime := stat.BeginStat()
 	defer func() {
 		stat.EndStat("batchIget", err, bgTime, 1)
@@ -1128,14 +1185,7 @@ func (mw *MetaWrapper) batchIget(wg *sync.WaitGroup, mp *MetaPartition, inodes [
 		return
 	}
 	log.LogDebugf("action[batchIget] resp %v", resp)
-	if len(resp.Infos) == 0 {
-		return
-	}
-
-	select {
-	case respCh <- resp.Infos:
-	default:
-	}
+	return resp.Infos, nil
 }
 
 func (mw *MetaWrapper) readDir(mp *MetaPartition, parentID uint64) (status int, children []proto.Dentry, err error) {
@@ -1480,6 +1530,196 @@ func (mw *MetaWrapper) truncate(mp *MetaPartition, inode, size uint64, fullPath
 	return statusOK, nil
 }
 
+func (mw *MetaWrapper) fallocate(mp *MetaPartition, inode uint64, mode uint32, offset, length uint64, fullPath string) (status int, err error) {
+	bgTime := stat.BeginStat()
+	defer func() {
+		stat.EndStat("fallocate", err, bgTime, 1)
+	}()
+
+	req := &proto.FallocateRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		Inode:       inode,
+		Mode:        mode,
+		Offset:      offset,
+		Length:      length,
+	}
+	req.FullPaths = []string{fullPath}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaFallocate
+	packet.PartitionID = mp.PartitionID
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("fallocate: ino(%v) mode(%v) offset(%v) length(%v) err(%v)", inode, mode, offset, length, err)
+		return
+	}
+
+	log.LogDebugf("fallocate enter: packet(%v) mp(%v) req(%v)", packet, mp, string(packet.Data))
+
+	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	defer func() {
+		metric.SetWithLabels(err, map[string]string{exporter.Vol: mw.volname})
+	}()
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("fallocate: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		err = errors.New(packet.GetResultMsg())
+		log.LogErrorf("fallocate: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	log.LogDebugf("fallocate exit: packet(%v) mp(%v) req(%v)", packet, mp, *req)
+	return statusOK, nil
+}
+
+func (mw *MetaWrapper) fsckDeleteExtents(mp *MetaPartition, eks []proto.ExtentKey) (status int, err error) {
+	bgTime := stat.BeginStat()
+	defer func() {
+		stat.EndStat("fsckDeleteExtents", err, bgTime, 1)
+	}()
+
+	req := &proto.FsckDeleteExtentsRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		Extents:     eks,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaFsckDeleteExtents
+	packet.PartitionID = mp.PartitionID
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("fsckDeleteExtents: mp(%v) extents(%v) err(%v)", mp, len(eks), err)
+		return
+	}
+
+	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	defer func() {
+		metric.SetWithLabels(err, map[string]string{exporter.Vol: mw.volname})
+	}()
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("fsckDeleteExtents: packet(%v) mp(%v) extents(%v) err(%v)", packet, mp, len(eks), err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		err = errors.New(packet.GetResultMsg())
+		log.LogErrorf("fsckDeleteExtents: packet(%v) mp(%v) extents(%v) result(%v)", packet, mp, len(eks), packet.GetResultMsg())
+		return
+	}
+
+	log.LogDebugf("fsckDeleteExtents exit: packet(%v) mp(%v) extents(%v)", packet, mp, eks)
+	return statusOK, nil
+}
+
+func (mw *MetaWrapper) icloneInode(mp *MetaPartition, inode, dst uint64, uid, gid uint32) (status int, info *proto.InodeInfo, err error) {
+	bgTime := stat.BeginStat()
+	defer func() {
+		stat.EndStat("icloneInode", err, bgTime, 1)
+	}()
+
+	req := &proto.CloneInodeRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		Inode:       inode,
+		DstInode:    dst,
+		Uid:         uid,
+		Gid:         gid,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaCloneInode
+	packet.PartitionID = mp.PartitionID
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("icloneInode: req(%v) err(%v)", *req, err)
+		return
+	}
+
+	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	defer func() {
+		metric.SetWithLabels(err, map[string]string{exporter.Vol: mw.volname})
+	}()
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("icloneInode: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		err = errors.New(packet.GetResultMsg())
+		log.LogErrorf("icloneInode: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	resp := new(proto.CloneInodeResponse)
+	err = packet.UnmarshalData(resp)
+	if err != nil || resp.Info == nil {
+		log.LogErrorf("icloneInode: packet(%v) mp(%v) req(%v) err(%v) PacketData(%v)", packet, mp, *req, err, string(packet.Data))
+		return
+	}
+	log.LogDebugf("icloneInode exit: packet(%v) mp(%v) req(%v) info(%v)", packet, mp, *req, resp.Info)
+	return statusOK, resp.Info, nil
+}
+
+func (mw *MetaWrapper) iwriteInline(mp *MetaPartition, inode, offset uint64, data []byte) (status int, err error) {
+	bgTime := stat.BeginStat()
+	defer func() {
+		stat.EndStat("iwriteInline", err, bgTime, 1)
+	}()
+
+	req := &proto.WriteInlineRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		Inode:       inode,
+		Offset:      offset,
+		Data:        data,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaWriteInline
+	packet.PartitionID = mp.PartitionID
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("iwriteInline: ino(%v) err(%v)", inode, err)
+		return
+	}
+
+	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	defer func() {
+		metric.SetWithLabels(err, map[string]string{exporter.Vol: mw.volname})
+	}()
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("iwriteInline: packet(%v) mp(%v) ino(%v) offset(%v) size(%v) err(%v)",
+			packet, mp, inode, offset, len(data), err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		err = errors.New(packet.GetResultMsg())
+		log.LogWarnf("iwriteInline: packet(%v) mp(%v) ino(%v) offset(%v) size(%v) result(%v)",
+			packet, mp, inode, offset, len(data), packet.GetResultMsg())
+		return
+	}
+	log.LogDebugf("iwriteInline exit: packet(%v) mp(%v) ino(%v) offset(%v) size(%v)", packet, mp, inode, offset, len(data))
+	return statusOK, nil
+}
+
 func (mw *MetaWrapper) txIlink(tx *Transaction, mp *MetaPartition, inode uint64, fullPath string) (status int, info *proto.InodeInfo, err error) {
 	bgTime := stat.BeginStat()
 	defer func() {
@@ -2949,6 +3189,49 @@ func (mw *MetaWrapper) lockDir(mp *MetaPartition, inode uint64, lease uint64, lo
 	return
 }
 
+func (mw *MetaWrapper) fileLock(mp *MetaPartition, req *proto.FileLockRequest) (status int, resp *proto.FileLockResponse, err error) {
+	bgTime := stat.BeginStat()
+	defer func() {
+		stat.EndStat("fileLock", err, bgTime, 1)
+	}()
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaFileLock
+	packet.PartitionID = mp.PartitionID
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("fileLock: matshal packet fail, err(%v)", err)
+		return
+	}
+
+	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	defer func() {
+		metric.SetWithLabels(err, map[string]string{exporter.Vol: mw.volname})
+	}()
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("fileLock: send to partition fail, packet(%v) mp(%v) req(%v) err(%v)",
+			packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK && status != statusExist {
+		err = errors.New(packet.GetResultMsg())
+		log.LogErrorf("fileLock: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	resp = new(proto.FileLockResponse)
+	if err = packet.UnmarshalData(resp); err != nil {
+		log.LogErrorf("fileLock: packet(%v) mp(%v) req(%v) err(%v) PacketData(%v)", packet, mp, *req, err, string(packet.Data))
+		return
+	}
+	log.LogDebugf("fileLock: packet(%v) mp(%v) req(%v) status(%v) conflict(%v)", packet, mp, *req, status, resp.Conflict)
+	return
+}
+
 func (mw *MetaWrapper) inodeAccessTimeGet(mp *MetaPartition, inode uint64) (status int, info *proto.InodeAccessTime, err error) {
 	bgTime := stat.BeginStat()
 	defer func() {
@@ -3144,3 +3427,101 @@ func (mw *MetaWrapper) deleteMigrationExtentKey(mp *MetaPartition, inode uint64,
 func (mw *MetaWrapper) forbiddenMigration(mp *MetaPartition, inode uint64) (status int, err error) {
 	return mw.renewalForbiddenMigration(mp, inode)
 }
+
+func (mw *MetaWrapper) getChanges(mp *MetaPartition, from uint64, limit int, wait time.Duration) (status int, resp *proto.GetChangesResponse, err error) {
+	bgTime := stat.BeginStat()
+	defer func() {
+		stat.EndStat("getChanges", err, bgTime, 1)
+	}()
+
+	req := &proto.GetChangesRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		From:        from,
+		Limit:       limit,
+		Wait:        wait.Milliseconds(),
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaGetChanges
+	packet.PartitionID = mp.PartitionID
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("getChanges: req(%v) err(%v)", *req, err)
+		return
+	}
+
+	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	defer func() {
+		metric.SetWithLabels(err, map[string]string{exporter.Vol: mw.volname})
+	}()
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("getChanges: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		err = errors.New(packet.GetResultMsg())
+		log.LogWarnf("getChanges: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	resp = new(proto.GetChangesResponse)
+	if err = packet.UnmarshalData(resp); err != nil {
+		log.LogErrorf("getChanges: packet(%v) mp(%v) err(%v) PacketData(%v)", packet, mp, err, string(packet.Data))
+		return
+	}
+	log.LogDebugf("getChanges exit: packet(%v) mp(%v) from(%v) changes(%v)", packet, mp, from, len(resp.Events))
+	return statusOK, resp, nil
+}
+
+func (mw *MetaWrapper) getDirStat(mp *MetaPartition, inode uint64) (status int, dirStat *proto.DirStat, err error) {
+	bgTime := stat.BeginStat()
+	defer func() {
+		stat.EndStat("getDirStat", err, bgTime, 1)
+	}()
+
+	req := &proto.GetDirStatRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		Inode:       inode,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaGetDirStat
+	packet.PartitionID = mp.PartitionID
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("getDirStat: req(%v) err(%v)", *req, err)
+		return
+	}
+
+	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	defer func() {
+		metric.SetWithLabels(err, map[string]string{exporter.Vol: mw.volname})
+	}()
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("getDirStat: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		err = errors.New(packet.GetResultMsg())
+		log.LogErrorf("getDirStat: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	dirStat = new(proto.DirStat)
+	if err = packet.UnmarshalData(dirStat); err != nil {
+		log.LogErrorf("getDirStat: packet(%v) mp(%v) err(%v) PacketData(%v)", packet, mp, err, string(packet.Data))
+		return
+	}
+	log.LogDebugf("getDirStat exit: packet(%v) mp(%v) ino(%v) dirStat(%v)", packet, mp, inode, *dirStat)
+	return statusOK, dirStat, nil
+}
@@ -16,6 +16,7 @@ package meta
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/cubefs/cubefs/util/btree"
 )
@@ -54,6 +55,18 @@ func (mw *MetaWrapper) addPartition(mp *MetaPartition) {
 	mw.ranges.ReplaceOrInsert(mp)
 }
 
+// partitionIDs returns the ids of the meta partitions in ascending order.
+func (mw *MetaWrapper) partitionIDs() []uint64 {
+	mw.RLock()
+	defer mw.RUnlock()
+	pids := make([]uint64, 0, len(mw.partitions))
+	for pid := range mw.partitions {
+		pids = append(pids, pid)
+	}
+	sort.Slice(pids, func(i, j int) bool { return pids[i] < pids[j] })
+	return pids
+}
+
 func (mw *MetaWrapper) deletePartition(mp *MetaPartition) {
 	delete(mw.partitions, mp.PartitionID)
 	mw.ranges.Delete(mp)
@@ -5,6 +5,7 @@ import (
 	"math/rand"
 	"os"
 	"path"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -239,26 +240,32 @@ retry:
 		log.LogWarnf("action[MoveToTrash] rename %v to %v failed:%v", srcPath, dstPath, err.Error())
 		return err
 	}
+	// record where and when the entry is deleted, for listing and restoring it
+	attrs := map[string]string{proto.TrashDeleteTimeKey: strconv.FormatInt(time.Now().Unix(), 10)}
+	if !strings.Contains(srcPath, UnknownPath) {
+		attrs[proto.TrashOriginPathKey] = srcPath
+	}
 	if needStoreXattr {
-		go func(name, dstPath string, parentID uint64) {
-			var (
-				info *proto.InodeInfo
-				err  error
-			)
-			info, err = trash.LookupEntry(parentID, path.Base(dstPath))
-			if err != nil {
-				log.LogWarnf("action[MoveToTrash] LookupEntry %v failed:%v", dstPath, err.Error())
-				return
-			}
-
-			err = trash.mw.XAttrSet_ll(info.Inode, []byte(OriginalName), []byte(name))
-			if err != nil {
-				log.LogWarnf("action[MoveToTrash] set xattr for %v[%v] failed:%v", dstPath, info.Inode, err.Error())
-				return
-			}
-			log.LogDebugf("action[MoveToTrash] set xattr for %v [%v]success:%v", dstPath, info.Inode, name)
-		}(originName, dstPath, trashCurrentIno)
+		attrs[OriginalName] = originName
 	}
+	go func(attrs map[string]string, dstPath string, parentID uint64) {
+		var (
+			info *proto.InodeInfo
+			err  error
+		)
+		info, err = trash.LookupEntry(parentID, path.Base(dstPath))
+		if err != nil {
+			log.LogWarnf("action[MoveToTrash] LookupEntry %v failed:%v", dstPath, err.Error())
+			return
+		}
+
+		err = trash.mw.BatchSetXAttr_ll(info.Inode, attrs)
+		if err != nil {
+			log.LogWarnf("action[MoveToTrash] set xattr for %v[%v] failed:%v", dstPath, info.Inode, err.Error())
+			return
+		}
+		log.LogDebugf("action[MoveToTrash] set xattr for %v [%v]success:%v", dstPath, info.Inode, attrs)
+	}(attrs, dstPath, trashCurrentIno)
 	// nil to check tmp file exist
 	trash.subDirCache.Put(dstPath, &proto.InodeInfo{})
 	log.LogDebugf("action[MoveToTrash] rename %v to %v success", srcPath, dstPath)
@@ -0,0 +1,393 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package meta
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/errors"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// The trash of the mount subdir is the .Trash directory under it. The entries deleted are moved
+// into the Current directory by their paths under the subdir, which is renamed to
+// Expired_<time of the last deletion> later and deleted after the trash interval. The entries
+// not moved to their paths yet are named by their paths with the slashes replaced.
+//
+// The entries are listed by walking the trash, with the original path and the time of the
+// deletion recorded by MoveToTrash in the extended attributes. Without them, the entries deleted
+// by the older clients are listed by their paths in the trash, and the time of the expired ones
+// by the time of the last deletion of the directory.
+
+// ErrTrashEnabled means the trash is managed by the wrapper moving the entries deleted into it.
+var ErrTrashEnabled = errors.New("trash should be disabled by the client to manage it")
+
+const trashBatchGetLimit = 1024
+
+var trashAttrKeys = []string{proto.TrashOriginPathKey, proto.TrashDeleteTimeKey, OriginalName}
+
+type trashWalker struct {
+	mw      *MetaWrapper
+	subdir  string
+	filter  *proto.TrashFilter
+	entries []*proto.TrashEntry
+}
+
+// ListTrash lists the entries in the trash of the subdir selected by the filter, the latest
+// deleted first.
+func (mw *MetaWrapper) ListTrash(subdir string, filter *proto.TrashFilter) ([]*proto.TrashEntry, error) {
+	subdir = path.Join("/", subdir)
+	trashRoot := path.Join(subdir, TrashPrefix)
+	rootIno, err := mw.LookupPath(trashRoot)
+	if err == syscall.ENOENT {
+		return nil, nil
+	} else if err != nil {
+		log.LogErrorf("ListTrash: lookup %v err(%v)", trashRoot, err)
+		return nil, err
+	}
+	batches, err := mw.readDirAll(rootIno)
+	if err != nil {
+		return nil, err
+	}
+	w := &trashWalker{mw: mw, subdir: subdir, filter: filter}
+	for _, batch := range batches {
+		if !proto.IsDir(batch.Type) {
+			continue
+		}
+		var batchTime int64
+		if batch.Name != CurrentName {
+			t, err := time.ParseInLocation(ExpiredTimeFormat, strings.TrimPrefix(batch.Name, ExpiredPrefix+"_"), time.Local)
+			if !strings.HasPrefix(batch.Name, ExpiredPrefix+"_") || err != nil {
+				continue
+			}
+			batchTime = t.Unix()
+		}
+		if _, err = w.walk(batch.Inode, path.Join(trashRoot, batch.Name), "", batchTime); err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(w.entries, func(i, j int) bool {
+		if w.entries[i].DeleteTime != w.entries[j].DeleteTime {
+			return w.entries[i].DeleteTime > w.entries[j].DeleteTime
+		}
+		return w.entries[i].Path < w.entries[j].Path
+	})
+	return w.entries, nil
+}
+
+// walk lists the entries under the directory of the trash, and returns the number of its children.
+func (w *trashWalker) walk(dirIno uint64, trashDir, rel string, batchTime int64) (int, error) {
+	children, err := w.mw.readDirAll(dirIno)
+	if err != nil {
+		return 0, err
+	}
+	var leaves []proto.Dentry
+	for _, child := range children {
+		if proto.IsDir(child.Type) && !isTrashTempName(child.Name) {
+			n, err := w.walk(child.Inode, path.Join(trashDir, child.Name), path.Join(rel, child.Name), batchTime)
+			if err != nil {
+				return 0, err
+			}
+			if n > 0 {
+				continue
+			}
+		}
+		// the files, and the directories deleted which are always empty
+		leaves = append(leaves, child)
+	}
+	for len(leaves) > 0 {
+		n := len(leaves)
+		if n > trashBatchGetLimit {
+			n = trashBatchGetLimit
+		}
+		if err = w.addEntries(leaves[:n], trashDir, rel, batchTime); err != nil {
+			return 0, err
+		}
+		leaves = leaves[n:]
+	}
+	return len(children), nil
+}
+
+func (w *trashWalker) addEntries(dentries []proto.Dentry, trashDir, rel string, batchTime int64) error {
+	inodes := make([]uint64, 0, len(dentries))
+	for _, d := range dentries {
+		inodes = append(inodes, d.Inode)
+	}
+	infos := make(map[uint64]*proto.InodeInfo, len(inodes))
+	for _, info := range w.mw.BatchInodeGet(inodes) {
+		infos[info.Inode] = info
+	}
+	xattrs, err := w.mw.BatchGetXAttr(inodes, trashAttrKeys)
+	if err != nil {
+		log.LogErrorf("ListTrash: get xattrs of %v err(%v)", trashDir, err)
+		return err
+	}
+	attrs := make(map[uint64]map[string]string, len(xattrs))
+	for _, xattr := range xattrs {
+		attrs[xattr.Inode] = xattr.XAttrs
+	}
+	for _, d := range dentries {
+		info, ok := infos[d.Inode]
+		if !ok {
+			log.LogWarnf("ListTrash: inode of %v not found", path.Join(trashDir, d.Name))
+			continue
+		}
+		e := &proto.TrashEntry{
+			TrashPath:  path.Join(trashDir, d.Name),
+			Inode:      d.Inode,
+			Mode:       info.Mode,
+			Uid:        info.Uid,
+			Gid:        info.Gid,
+			Size:       info.Size,
+			DeleteTime: batchTime,
+		}
+		name := d.Name
+		if isTrashTempName(name) {
+			name = decodeTrashName(name, attrs[d.Inode][OriginalName])
+		}
+		e.Path = path.Join(w.subdir, rel, name)
+		if origin := attrs[d.Inode][proto.TrashOriginPathKey]; origin != "" {
+			e.Path = origin
+		}
+		if t, err := strconv.ParseInt(attrs[d.Inode][proto.TrashDeleteTimeKey], 10, 64); err == nil {
+			e.DeleteTime = t
+		}
+		if w.filter == nil || w.filter.Match(e) {
+			w.entries = append(w.entries, e)
+		}
+	}
+	return nil
+}
+
+func isTrashTempName(name string) bool {
+	return strings.Contains(name, ParentDirPrefix) || strings.HasPrefix(name, LongNamePrefix)
+}
+
+// decodeTrashName returns the path under the subdir of the entry not moved to its path in the
+// trash yet. The long name is kept in the extended attribute, or only the base name is known.
+func decodeTrashName(name, originalName string) string {
+	if strings.HasPrefix(name, LongNamePrefix) {
+		if originalName == "" {
+			// remove the uuid
+			return strings.Split(strings.TrimPrefix(name, LongNamePrefix), ParentDirPrefix)[0]
+		}
+		name = originalName
+	}
+	name = strings.TrimPrefix(name, ParentDirPrefix)
+	return strings.ReplaceAll(name, ParentDirPrefix, "/")
+}
+
+func (mw *MetaWrapper) readDirAll(ino uint64) (dentries []proto.Dentry, err error) {
+	from := ""
+	for {
+		batches, err := mw.ReadDirLimit_ll(ino, from, DefaultReaddirLimit)
+		if err != nil {
+			log.LogErrorf("readDirAll: ino(%v) from(%v) err(%v)", ino, from, err)
+			return nil, err
+		}
+		if from != "" && len(batches) > 0 {
+			batches = batches[1:]
+		}
+		if len(batches) == 0 {
+			return dentries, nil
+		}
+		dentries = append(dentries, batches...)
+		from = batches[len(batches)-1].Name
+	}
+}
+
+func (mw *MetaWrapper) checkTrashManaged() error {
+	if !mw.disableTrash && !mw.disableTrashByClient {
+		return ErrTrashEnabled
+	}
+	return nil
+}
+
+// RestoreTrash restores the entries in the trash of the subdir selected by the filter to their
+// original paths, creating the missing parent directories. The entry whose path is taken is
+// handled by the conflict way, and the latest deleted entry is restored first if there are
+// several ones of the same path.
+func (mw *MetaWrapper) RestoreTrash(subdir string, filter *proto.TrashFilter, conflict string) ([]*proto.TrashRestoreResult, error) {
+	if err := mw.checkTrashManaged(); err != nil {
+		return nil, err
+	}
+	entries, err := mw.ListTrash(subdir, filter)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*proto.TrashRestoreResult, 0, len(entries))
+	dirs := make(map[string]struct{})
+	for _, e := range entries {
+		result := &proto.TrashRestoreResult{Entry: e}
+		if result.Restored, err = mw.restoreTrashEntry(e, conflict); err != nil {
+			log.LogWarnf("RestoreTrash: restore %v to %v err(%v)", e.TrashPath, e.Path, err)
+			result.Err = err.Error()
+		} else {
+			log.LogInfof("RestoreTrash: restore %v to %v", e.TrashPath, result.Restored)
+			dirs[path.Dir(e.TrashPath)] = struct{}{}
+		}
+		results = append(results, result)
+	}
+	mw.pruneTrashDirs(subdir, dirs)
+	return results, nil
+}
+
+func (mw *MetaWrapper) restoreTrashEntry(e *proto.TrashEntry, conflict string) (restored string, err error) {
+	srcParent, err := mw.LookupPath(path.Dir(e.TrashPath))
+	if err != nil {
+		return
+	}
+	dst := e.Path
+	dstParent, err := mw.makeDirAll(path.Dir(dst), e.Uid, e.Gid)
+	if err != nil {
+		return
+	}
+	overwrite := false
+	if _, mode, err := mw.Lookup_ll(dstParent, path.Base(dst)); err == nil {
+		switch {
+		case proto.IsDir(e.Mode) && proto.IsDir(mode):
+			// the directory is there already, so the one deleted is done with
+			_, err = mw.Delete_ll(srcParent, path.Base(e.TrashPath), true, e.TrashPath)
+			return dst, err
+		case conflict == proto.TrashConflictRename:
+			dst = fmt.Sprintf("%v.restored.%v", dst, e.Inode)
+		case conflict == proto.TrashConflictOverwrite:
+			overwrite = true
+		default:
+			return "", syscall.EEXIST
+		}
+	} else if err != syscall.ENOENT {
+		return "", err
+	}
+	if err = mw.Rename_ll(srcParent, path.Base(e.TrashPath), dstParent, path.Base(dst), e.TrashPath, dst, overwrite); err != nil {
+		return "", err
+	}
+	for _, key := range trashAttrKeys {
+		if err := mw.XAttrDel_ll(e.Inode, key); err != nil {
+			log.LogWarnf("restoreTrashEntry: remove xattr %v of %v err(%v)", key, dst, err)
+		}
+	}
+	return dst, nil
+}
+
+// makeDirAll creates the directory and the missing parents like mkdir -p, and returns the inode.
+func (mw *MetaWrapper) makeDirAll(dir string, uid, gid uint32) (ino uint64, err error) {
+	ino = proto.RootIno
+	dirPath := "/"
+	for _, name := range strings.Split(dir, "/") {
+		if name == "" {
+			continue
+		}
+		dirPath = path.Join(dirPath, name)
+		child, mode, err := mw.Lookup_ll(ino, name)
+		if err == syscall.ENOENT {
+			info, err := mw.Create_ll(ino, name, proto.Mode(os.ModeDir|0o755), uid, gid, nil, dirPath, false)
+			if err == syscall.EEXIST {
+				child, mode, err = mw.Lookup_ll(ino, name)
+			} else if err == nil {
+				child, mode = info.Inode, info.Mode
+			}
+			if err != nil {
+				return 0, err
+			}
+		} else if err != nil {
+			return 0, err
+		}
+		if !proto.IsDir(mode) {
+			return 0, syscall.ENOTDIR
+		}
+		ino = child
+	}
+	return ino, nil
+}
+
+// PurgeTrash deletes the entries in the trash of the subdir selected by the filter, and returns
+// the ones deleted.
+func (mw *MetaWrapper) PurgeTrash(subdir string, filter *proto.TrashFilter) ([]*proto.TrashEntry, error) {
+	if err := mw.checkTrashManaged(); err != nil {
+		return nil, err
+	}
+	entries, err := mw.ListTrash(subdir, filter)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		purged = make([]*proto.TrashEntry, 0, len(entries))
+		dirs   = make(map[string]struct{})
+		failed int
+	)
+	for _, e := range entries {
+		if err = mw.purgeTrashEntry(e); err != nil {
+			log.LogWarnf("PurgeTrash: delete %v err(%v)", e.TrashPath, err)
+			failed++
+			continue
+		}
+		purged = append(purged, e)
+		dirs[path.Dir(e.TrashPath)] = struct{}{}
+	}
+	mw.pruneTrashDirs(subdir, dirs)
+	if failed > 0 {
+		return purged, fmt.Errorf("%v entries failed to be deleted, the last err: %v", failed, err)
+	}
+	return purged, nil
+}
+
+func (mw *MetaWrapper) purgeTrashEntry(e *proto.TrashEntry) error {
+	parent, err := mw.LookupPath(path.Dir(e.TrashPath))
+	if err != nil {
+		return err
+	}
+	isDir := proto.IsDir(e.Mode)
+	info, err := mw.Delete_ll(parent, path.Base(e.TrashPath), isDir, e.TrashPath)
+	if err != nil {
+		return err
+	}
+	if !isDir && info != nil {
+		return mw.Evict(info.Inode, e.TrashPath)
+	}
+	return nil
+}
+
+// pruneTrashDirs deletes the directories of the trash left empty by restoring or purging the
+// entries, up to the Current and Expired ones.
+func (mw *MetaWrapper) pruneTrashDirs(subdir string, dirs map[string]struct{}) {
+	trashRoot := path.Join("/", subdir, TrashPrefix)
+	sorted := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		sorted = append(sorted, dir)
+	}
+	// the deeper ones first, so the parents may be empty then
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	for _, dir := range sorted {
+		for path.Dir(dir) != trashRoot && strings.HasPrefix(dir, trashRoot+"/") {
+			parent, err := mw.LookupPath(path.Dir(dir))
+			if err != nil {
+				break
+			}
+			if _, err = mw.Delete_ll(parent, path.Base(dir), true, dir); err != nil {
+				break
+			}
+			dir = path.Dir(dir)
+		}
+	}
+}
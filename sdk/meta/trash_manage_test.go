@@ -0,0 +1,33 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package meta
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeTrashName(t *testing.T) {
+	assert.True(t, isTrashTempName("a|__|b|__|f"))
+	assert.False(t, isTrashTempName("f_1700000000"))
+	assert.Equal(t, "f", decodeTrashName("|__|f", ""))
+	assert.Equal(t, "a/b/f", decodeTrashName("a|__|b|__|f", ""))
+
+	longName, originName := transferLongFileName("/.Trash/Current/a|__|long")
+	assert.Equal(t, "a|__|long", originName)
+	assert.Equal(t, "a/long", decodeTrashName(longName[len("/.Trash/Current/"):], originName))
+	assert.Equal(t, "long", decodeTrashName(longName[len("/.Trash/Current/"):], ""))
+}
@@ -176,9 +176,10 @@ func (mw *MetaWrapper) updateVolStatInfo() (err error) {
 	atomic.StoreUint64(&mw.inodeCount, info.InodeCount)
 	atomic.StoreUint32(&mw.DefaultStorageClass, info.DefaultStorageClass)
 	mw.FollowerRead = info.MetaFollowerRead
+	mw.FollowerReadIndex = info.MetaFollowerReadIndex
 	mw.leaderRetryTimeout = int64(info.LeaderRetryTimeOut)
-	log.LogInfof("[updateVolStatInfo]: info(%+v), defaultStorageClass(%v), followerRead(%v), timout(%v)",
-		info, proto.StorageClassString(info.DefaultStorageClass), mw.FollowerRead, mw.leaderRetryTimeout)
+	log.LogInfof("[updateVolStatInfo]: info(%+v), defaultStorageClass(%v), followerRead(%v), followerReadIndex(%v), timout(%v)",
+		info, proto.StorageClassString(info.DefaultStorageClass), mw.FollowerRead, mw.FollowerReadIndex, mw.leaderRetryTimeout)
 	// 0 means disable trash
 	if mw.disableTrashByClient {
 		log.LogDebugf("updateVolStatInfo: trash for %v is disabled by client", mw.volname)
@@ -0,0 +1,191 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/meta"
+	"github.com/cubefs/cubefs/util/log"
+	"github.com/cubefs/cubefs/util/ump"
+	"github.com/spf13/cobra"
+)
+
+var (
+	MasterAddr   string
+	VolName      string
+	CursorFile   string
+	FromNow      bool
+	SkipExpired  bool
+	Limit        int
+	Interval     time.Duration
+	KafkaBrokers string
+	KafkaTopic   string
+	KafkaVersion string
+	LogDir       string
+)
+
+func NewRootCmd() *cobra.Command {
+	var optShowVersion bool
+	c := &cobra.Command{
+		Use:   path.Base(os.Args[0]),
+		Short: "CubeFS change feed tool, streams the namespace changes of the volume to stdout or kafka",
+		Args:  cobra.MinimumNArgs(0),
+		Run: func(cmd *cobra.Command, args []string) {
+			if optShowVersion {
+				fmt.Fprintln(os.Stdout, proto.DumpVersion("CHANGEFEED"))
+				return
+			}
+			if err := run(); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	c.Flags().StringVarP(&MasterAddr, "master", "m", "", "master addresses")
+	c.Flags().StringVarP(&VolName, "vol", "V", "", "volume name")
+	c.Flags().StringVarP(&CursorFile, "cursor", "c", "changefeed.cursor", "file to keep the cursor of the feed")
+	c.Flags().BoolVarP(&FromNow, "from-now", "", false, "start from the changes applied after now if there is no cursor")
+	c.Flags().BoolVarP(&SkipExpired, "skip-expired", "", false, "go on from the oldest change kept if the changes after the cursor expire")
+	c.Flags().IntVarP(&Limit, "limit", "l", proto.DefaultChangesLimit, "max changes read from each meta partition at a time")
+	c.Flags().DurationVarP(&Interval, "interval", "i", time.Second, "interval to poll the meta partitions when there are no new changes")
+	c.Flags().StringVarP(&KafkaBrokers, "kafka-brokers", "", "", "kafka brokers separated by comma, print the changes to stdout if empty")
+	c.Flags().StringVarP(&KafkaTopic, "kafka-topic", "", "", "kafka topic")
+	c.Flags().StringVarP(&KafkaVersion, "kafka-version", "", "", "kafka version")
+	c.Flags().StringVarP(&LogDir, "log-dir", "", "changefeedlog", "log directory")
+	c.Flags().BoolVarP(&optShowVersion, "version", "v", false, "Show version information")
+
+	return c
+}
+
+func run() (err error) {
+	if MasterAddr == "" || VolName == "" {
+		return fmt.Errorf("master and vol are required")
+	}
+	if KafkaBrokers != "" && KafkaTopic == "" {
+		return fmt.Errorf("kafka-topic is required by kafka-brokers")
+	}
+
+	ump.InitUmp("changefeed", "")
+	if _, err = log.InitLog(LogDir, "changefeed", log.InfoLevel, nil, log.DefaultLogLeftSpaceLimitRatio); err != nil {
+		return fmt.Errorf("Init log failed: %v", err)
+	}
+	defer log.LogFlush()
+
+	mw, err := meta.NewMetaWrapper(&meta.MetaConfig{
+		Volume:  VolName,
+		Masters: strings.Split(MasterAddr, meta.HostsSeparator),
+	})
+	if err != nil {
+		return fmt.Errorf("NewMetaWrapper failed: %v", err)
+	}
+	defer mw.Close()
+
+	cursor, err := loadCursor(CursorFile)
+	if err != nil {
+		return fmt.Errorf("load cursor %v failed: %v", CursorFile, err)
+	}
+	if cursor == nil {
+		if FromNow {
+			if cursor, err = mw.LatestChangeCursor(); err != nil {
+				return fmt.Errorf("get latest cursor failed: %v", err)
+			}
+		} else if cursor, err = mw.OldestChangeCursor(); err != nil {
+			return fmt.Errorf("get oldest cursor failed: %v", err)
+		}
+	}
+
+	var s sink
+	if KafkaBrokers != "" {
+		s, err = newKafkaSink(KafkaBrokers, KafkaTopic, KafkaVersion)
+		if err != nil {
+			return fmt.Errorf("new kafka producer failed: %v", err)
+		}
+	} else {
+		s = newStdoutSink()
+	}
+	defer s.Close()
+
+	sigC := make(chan os.Signal, 1)
+	signal.Notify(sigC, syscall.SIGINT, syscall.SIGTERM)
+	for {
+		// the cursor is saved only after the changes are sent, so the changes are sent at
+		// least once even if the tool exits in between
+		next := make(proto.ChangeCursor, len(cursor))
+		for pid, index := range cursor {
+			next[pid] = index
+		}
+		events, err := mw.ReadChanges(next, Limit)
+		if err != nil && err != meta.ErrChangesExpired {
+			log.LogWarnf("read changes of vol(%v) failed: %v", VolName, err)
+		} else {
+			if len(events) > 0 {
+				if err := s.Send(events); err != nil {
+					return fmt.Errorf("send changes failed: %v", err)
+				}
+			}
+			if err == meta.ErrChangesExpired && !SkipExpired {
+				return fmt.Errorf("some changes after the cursor are lost, the namespace should be resynced")
+			}
+			cursor = next
+			if err := saveCursor(CursorFile, cursor); err != nil {
+				return fmt.Errorf("save cursor %v failed: %v", CursorFile, err)
+			}
+			if len(events) > 0 {
+				continue
+			}
+		}
+		select {
+		case sig := <-sigC:
+			log.LogInfof("changefeed exits on signal %v", sig)
+			return nil
+		case <-time.After(Interval):
+		}
+	}
+}
+
+func loadCursor(file string) (cursor proto.ChangeCursor, err error) {
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(data, &cursor)
+	return
+}
+
+func saveCursor(file string, cursor proto.ChangeCursor) (err error) {
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return
+	}
+	tmp := file + ".tmp"
+	if err = ioutil.WriteFile(tmp, data, 0o644); err != nil {
+		return
+	}
+	return os.Rename(tmp, file)
+}
@@ -0,0 +1,106 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/cubefs/cubefs/proto"
+)
+
+type sink interface {
+	Send(events []*proto.ChangeEvent) error
+	Close() error
+}
+
+// stdoutSink prints the changes as json lines.
+type stdoutSink struct {
+	w *bufio.Writer
+}
+
+func newStdoutSink() *stdoutSink {
+	return &stdoutSink{w: bufio.NewWriter(os.Stdout)}
+}
+
+func (s *stdoutSink) Send(events []*proto.ChangeEvent) error {
+	enc := json.NewEncoder(s.w)
+	for _, event := range events {
+		if err := enc.Encode(event); err != nil {
+			return err
+		}
+	}
+	return s.w.Flush()
+}
+
+func (s *stdoutSink) Close() error {
+	return s.w.Flush()
+}
+
+// kafkaSink sends the changes keyed by the meta partition, so the changes of a partition keep
+// their order in the kafka partition they are sent to.
+type kafkaSink struct {
+	topic    string
+	producer sarama.SyncProducer
+}
+
+func newKafkaSink(brokers, topic, version string) (*kafkaSink, error) {
+	cfg := sarama.NewConfig()
+	cfg.Version = sarama.V2_1_0_0
+	if version != "" {
+		v, err := sarama.ParseKafkaVersion(version)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Version = v
+	}
+	cfg.Producer.RequiredAcks = sarama.WaitForAll
+	cfg.Producer.Return.Errors = true
+	cfg.Producer.Return.Successes = true
+	cfg.Producer.Idempotent = true
+	cfg.Net.MaxOpenRequests = 1
+	cfg.Net.KeepAlive = 60 * time.Second
+
+	producer, err := sarama.NewSyncProducer(strings.Split(brokers, ","), cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &kafkaSink{topic: topic, producer: producer}, nil
+}
+
+func (s *kafkaSink) Send(events []*proto.ChangeEvent) error {
+	msgs := make([]*sarama.ProducerMessage, 0, len(events))
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		msgs = append(msgs, &sarama.ProducerMessage{
+			Topic: s.topic,
+			Key:   sarama.StringEncoder(strconv.FormatUint(event.PartitionID, 10)),
+			Value: sarama.ByteEncoder(data),
+		})
+	}
+	return s.producer.SendMessages(msgs)
+}
+
+func (s *kafkaSink) Close() error {
+	return s.producer.Close()
+}
@@ -153,6 +153,7 @@ func NewClient(config PreloadConfig) *PreLoadClient {
 		OnAppendExtentKey:           mw.AppendExtentKey,
 		OnSplitExtentKey:            mw.SplitExtentKey,
 		OnGetExtents:                mw.GetExtents,
+		OnGetInlineExtents:          mw.GetInlineExtents,
 		OnTruncate:                  mw.Truncate,
 		VolStorageClass:             view.VolStorageClass,
 		VolAllowedStorageClass:      view.AllowedStorageClass,
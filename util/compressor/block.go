@@ -0,0 +1,129 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package compressor
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// A compressed block is framed with a header naming its encoding, so that it can be decompressed
+// no matter which encoding is configured when it is read, followed by the size of the data and the
+// size of the block compressed, so that the blocks packed one after another can be told apart.
+const BlockHeaderSize = 9
+
+var ErrInvalidBlock = errors.New("invalid compressed block")
+
+var (
+	blockEncodingIDs = map[string]byte{
+		EncodingGzip:   1,
+		EncodingZstd:   2,
+		EncodingLz4:    3,
+		EncodingSnappy: 4,
+	}
+	blockEncodings = map[byte]string{}
+)
+
+func init() {
+	for encoding, id := range blockEncodingIDs {
+		blockEncodings[id] = encoding
+	}
+}
+
+// CompressBlock compresses the data with the encoding and returns the framed block.
+func CompressBlock(encoding string, data []byte) ([]byte, error) {
+	id, ok := blockEncodingIDs[encoding]
+	if !ok {
+		return nil, fmt.Errorf("unknown block encoding(%v)", encoding)
+	}
+	compressed, err := New(encoding).Compress(data)
+	if err != nil {
+		return nil, err
+	}
+	block := make([]byte, BlockHeaderSize+len(compressed))
+	block[0] = id
+	binary.BigEndian.PutUint32(block[1:5], uint32(len(data)))
+	binary.BigEndian.PutUint32(block[5:9], uint32(len(compressed)))
+	copy(block[BlockHeaderSize:], compressed)
+	return block, nil
+}
+
+// DecompressBlock decompresses the block framed by CompressBlock.
+func DecompressBlock(block []byte) ([]byte, error) {
+	encoding, rawSize, size, err := parseBlockHeader(block)
+	if err != nil {
+		return nil, err
+	}
+	if BlockHeaderSize+size != len(block) {
+		return nil, fmt.Errorf("%w: block size(%v) header size(%v)", ErrInvalidBlock, len(block), size)
+	}
+	return decompressBlock(encoding, block[BlockHeaderSize:], rawSize)
+}
+
+// DecompressBlocks returns the range of the data of the blocks framed by CompressBlock and packed
+// one after another. Only the blocks the range covers are decompressed.
+func DecompressBlocks(blocks []byte, offset, size int) ([]byte, error) {
+	data := make([]byte, 0, size)
+	for pos, rawOffset := 0, 0; pos < len(blocks) && len(data) < size; {
+		encoding, rawSize, blockSize, err := parseBlockHeader(blocks[pos:])
+		if err != nil {
+			return nil, err
+		}
+		end := pos + BlockHeaderSize + blockSize
+		if end > len(blocks) {
+			return nil, fmt.Errorf("%w: block at(%v) size(%v) exceeds(%v)", ErrInvalidBlock, pos, blockSize, len(blocks))
+		}
+		if offset < rawOffset+rawSize {
+			raw, err := decompressBlock(encoding, blocks[pos+BlockHeaderSize:end], rawSize)
+			if err != nil {
+				return nil, err
+			}
+			start := 0
+			if offset > rawOffset {
+				start = offset - rawOffset
+			}
+			data = append(data, raw[start:]...)
+		}
+		pos = end
+		rawOffset += rawSize
+	}
+	if len(data) < size {
+		return nil, fmt.Errorf("%w: data size(%v) less than offset(%v) size(%v)", ErrInvalidBlock, len(data), offset, size)
+	}
+	return data[:size], nil
+}
+
+func parseBlockHeader(block []byte) (encoding string, rawSize, size int, err error) {
+	if len(block) < BlockHeaderSize {
+		return "", 0, 0, ErrInvalidBlock
+	}
+	encoding, ok := blockEncodings[block[0]]
+	if !ok {
+		return "", 0, 0, fmt.Errorf("%w: unknown encoding id(%v)", ErrInvalidBlock, block[0])
+	}
+	return encoding, int(binary.BigEndian.Uint32(block[1:5])), int(binary.BigEndian.Uint32(block[5:9])), nil
+}
+
+func decompressBlock(encoding string, compressed []byte, rawSize int) ([]byte, error) {
+	raw, err := New(encoding).Decompress(compressed)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) != rawSize {
+		return nil, fmt.Errorf("%w: data size(%v) header size(%v)", ErrInvalidBlock, len(raw), rawSize)
+	}
+	return raw, nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package compressor_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cubefs/cubefs/util/compressor"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressor_Block(t *testing.T) {
+	data := bytes.Repeat([]byte("block of text "), 1024)
+	for _, encoding := range []string{
+		compressor.EncodingGzip, compressor.EncodingZstd,
+		compressor.EncodingLz4, compressor.EncodingSnappy,
+	} {
+		block, err := compressor.CompressBlock(encoding, data)
+		require.NoError(t, err)
+		require.Less(t, len(block), len(data))
+		// a block is decompressed without knowing its encoding
+		raw, err := compressor.DecompressBlock(block)
+		require.NoError(t, err)
+		require.Equal(t, data, raw)
+	}
+
+	_, err := compressor.CompressBlock("", data)
+	require.Error(t, err)
+	_, err = compressor.DecompressBlock(nil)
+	require.ErrorIs(t, err, compressor.ErrInvalidBlock)
+	_, err = compressor.DecompressBlock([]byte{0xff, 1, 2, 3})
+	require.ErrorIs(t, err, compressor.ErrInvalidBlock)
+}
+
+func TestCompressor_Blocks(t *testing.T) {
+	data := bytes.Repeat([]byte("blocks of text "), 1024)
+	var blocks []byte
+	for i, encoding := range []string{compressor.EncodingZstd, compressor.EncodingLz4, compressor.EncodingSnappy} {
+		block, err := compressor.CompressBlock(encoding, data[i*4096:(i+1)*4096])
+		require.NoError(t, err)
+		blocks = append(blocks, block...)
+	}
+
+	for _, r := range [][2]int{{0, 3 * 4096}, {100, 200}, {4000, 5000}, {4096, 4096}, {3*4096 - 1, 1}} {
+		raw, err := compressor.DecompressBlocks(blocks, r[0], r[1])
+		require.NoError(t, err)
+		require.Equal(t, data[r[0]:r[0]+r[1]], raw)
+	}
+	// a single block is decompressed in whole only
+	_, err := compressor.DecompressBlock(blocks)
+	require.ErrorIs(t, err, compressor.ErrInvalidBlock)
+	_, err = compressor.DecompressBlocks(blocks, 3*4096-1, 2)
+	require.ErrorIs(t, err, compressor.ErrInvalidBlock)
+	_, err = compressor.DecompressBlocks(blocks[:len(blocks)-1], 0, 3*4096)
+	require.ErrorIs(t, err, compressor.ErrInvalidBlock)
+}
@@ -14,7 +14,12 @@
 
 package compressor
 
-const EncodingGzip = "gzip"
+const (
+	EncodingGzip   = "gzip"
+	EncodingZstd   = "zstd"
+	EncodingLz4    = "lz4"
+	EncodingSnappy = "snappy"
+)
 
 // Compressor bytes compressor.
 // TODO: add stream Compressor.
@@ -33,6 +38,9 @@ var compressors = make(map[string]func() Compressor)
 func init() {
 	compressors[""] = func() Compressor { return none{} }
 	compressors[EncodingGzip] = func() Compressor { return gzipCompressor{} }
+	compressors[EncodingZstd] = func() Compressor { return zstdCompressor{} }
+	compressors[EncodingLz4] = func() Compressor { return lz4Compressor{} }
+	compressors[EncodingSnappy] = func() Compressor { return snappyCompressor{} }
 }
 
 func New(encoding string) Compressor {
@@ -41,3 +49,9 @@ func New(encoding string) Compressor {
 	}
 	return compressors[""]()
 }
+
+// IsValidEncoding returns if the encoding names a compressor other than none.
+func IsValidEncoding(encoding string) bool {
+	_, ok := compressors[encoding]
+	return ok && encoding != ""
+}
@@ -15,6 +15,7 @@
 package compressor_test
 
 import (
+	"bytes"
 	"crypto/rand"
 	"testing"
 
@@ -37,3 +38,28 @@ func TestCompressor_New(t *testing.T) {
 		require.Equal(t, buf, pbuf)
 	}
 }
+
+func TestCompressor_Encodings(t *testing.T) {
+	text := bytes.Repeat([]byte("2023/01/01 00:00:00.000000 [INFO ] compressible log line\n"), 64)
+	for _, encoding := range []string{
+		compressor.EncodingGzip, compressor.EncodingZstd,
+		compressor.EncodingLz4, compressor.EncodingSnappy,
+	} {
+		require.True(t, compressor.IsValidEncoding(encoding))
+		random := make([]byte, 1024)
+		rand.Read(random)
+		for _, buf := range [][]byte{random, text, {}} {
+			c := compressor.New(encoding)
+			cbuf, err := c.Compress(buf)
+			require.NoError(t, err, encoding)
+			pbuf, err := c.Decompress(cbuf)
+			require.NoError(t, err, encoding)
+			require.Equal(t, len(buf), len(pbuf), encoding)
+			require.True(t, bytes.Equal(buf, pbuf), encoding)
+		}
+		cbuf, _ := compressor.New(encoding).Compress(text)
+		require.Less(t, len(cbuf), len(text)/4, encoding)
+	}
+	require.False(t, compressor.IsValidEncoding(""))
+	require.False(t, compressor.IsValidEncoding("balaa"))
+}
@@ -0,0 +1,44 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package compressor
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/pierrec/lz4"
+)
+
+type lz4Compressor struct{}
+
+func (lz4Compressor) Compress(pb []byte) ([]byte, error) {
+	buffer := new(bytes.Buffer)
+	lw := lz4.NewWriter(buffer)
+	if _, err := lw.Write(pb); err != nil {
+		return nil, err
+	}
+	if err := lw.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func (lz4Compressor) Decompress(cb []byte) ([]byte, error) {
+	buffer := new(bytes.Buffer)
+	if _, err := io.Copy(buffer, lz4.NewReader(bytes.NewReader(cb))); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
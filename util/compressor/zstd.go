@@ -0,0 +1,55 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package compressor
+
+import (
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// The encoder and the decoder are safe for concurrent EncodeAll and DecodeAll,
+// and expensive to create, so they are shared by all the zstd compressors.
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+	zstdErr     error
+)
+
+func initZstd() {
+	if zstdEncoder, zstdErr = zstd.NewWriter(nil); zstdErr != nil {
+		return
+	}
+	zstdDecoder, zstdErr = zstd.NewReader(nil)
+}
+
+type zstdCompressor struct{}
+
+func (zstdCompressor) Compress(pb []byte) ([]byte, error) {
+	zstdOnce.Do(initZstd)
+	if zstdErr != nil {
+		return nil, zstdErr
+	}
+	return zstdEncoder.EncodeAll(pb, make([]byte, 0, len(pb)/2)), nil
+}
+
+func (zstdCompressor) Decompress(cb []byte) ([]byte, error) {
+	zstdOnce.Do(initZstd)
+	if zstdErr != nil {
+		return nil, zstdErr
+	}
+	return zstdDecoder.DecodeAll(cb, nil)
+}
@@ -82,6 +82,20 @@ func (mr *MockPartitionMockRecorder) CommittedIndex() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CommittedIndex", reflect.TypeOf((*MockPartition)(nil).CommittedIndex))
 }
 
+// ReadIndex mocks base method.
+func (m *MockPartition) ReadIndex() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadIndex")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReadIndex indicates an expected call of ReadIndex.
+func (mr *MockPartitionMockRecorder) ReadIndex() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadIndex", reflect.TypeOf((*MockPartition)(nil).ReadIndex))
+}
+
 // IsRestoring indicates an expected call of IsRestoring.
 func (mr *MockPartitionMockRecorder) IsRestoring() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
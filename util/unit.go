@@ -73,6 +73,7 @@ const (
 	UidAddLimit  = 1
 	UidDelLimit  = 2
 	UidGetLimit  = 3
+	UidSetLimit  = 4 // set the hard and soft limits of the user or group
 )
 
 const (